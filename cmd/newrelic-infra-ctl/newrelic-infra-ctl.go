@@ -50,6 +50,10 @@ func init() {
 }
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == setupCommand {
+		os.Exit(runSetup(os.Args[2:]))
+	}
+
 	flag.Parse()
 
 	ctx, cancel := context.WithCancel(context.Background())
@@ -0,0 +1,62 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+
+	"github.com/newrelic/infrastructure-agent/internal/agent/service"
+	"github.com/newrelic/infrastructure-agent/pkg/ctl/setup"
+)
+
+const setupCommand = "setup"
+
+// runSetup runs the guided setup with the arguments following the setup command, returning the exit code.
+func runSetup(args []string) int {
+	opts := setup.Options{}
+	flags := flag.NewFlagSet(setupCommand, flag.ExitOnError)
+	flags.StringVar(&opts.ConfigFile, "config", defaultSetupConfigFile(), "Agent configuration file to write")
+	flags.StringVar(&opts.LicenseKey, "license_key", "", "New Relic license key")
+	flags.StringVar(&opts.Proxy, "proxy", "", "Proxy URL for the agent connections [Optional]")
+	flags.StringVar(&opts.DisplayName, "display_name", "", "Display name of the host [Optional]")
+	flags.BoolVar(&opts.EnableProcessMetrics, "enable_process_metrics", false, "Enable process metrics")
+	flags.BoolVar(&opts.ForwardLogs, "forward_logs", false, "Forward the detected log files")
+	flags.BoolVar(&opts.InstallService, "install_service", false, "Install the agent service")
+	flags.BoolVar(&opts.SkipConnectivityCheck, "skip_connectivity_check", false, "Don't test the connectivity with New Relic")
+	nonInteractive := flags.Bool("non_interactive", false, "Take the values from the flags, without asking for them")
+	_ = flags.Parse(args)
+	opts.Interactive = !*nonInteractive
+
+	s := setup.New(opts, setup.NewPrompter(os.Stdin, os.Stdout), os.Stdout, func() error {
+		return service.Install(serviceExecutable())
+	})
+	if err := s.Run(); err != nil {
+		fmt.Fprintf(os.Stderr, "Setup failed: %s\n", err)
+		return 1
+	}
+	return 0
+}
+
+func defaultSetupConfigFile() string {
+	if runtime.GOOS == "windows" {
+		return filepath.Join(os.Getenv("ProgramFiles"), "New Relic", "newrelic-infra", "newrelic-infra.yml")
+	}
+	return filepath.Join("/etc", "newrelic-infra.yml")
+}
+
+// serviceExecutable returns the service wrapper binary installed next to this one.
+func serviceExecutable() string {
+	name := "newrelic-infra-service"
+	if runtime.GOOS == "windows" {
+		name += ".exe"
+	}
+	executable, err := os.Executable()
+	if err != nil {
+		return name
+	}
+	return filepath.Join(filepath.Dir(executable), name)
+}
@@ -0,0 +1,42 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/signal"
+
+	"github.com/newrelic/infrastructure-agent/internal/feature_flags"
+	"github.com/newrelic/infrastructure-agent/pkg/config"
+	v4 "github.com/newrelic/infrastructure-agent/pkg/integrations/v4"
+	"github.com/newrelic/infrastructure-agent/pkg/integrations/v4/emitter"
+)
+
+// dryRunIntegration executes once the integrations of the passed config file and prints their
+// payloads to the standard output, without submitting anything to the backend.
+func dryRunIntegration(c *config.Config, integrationConfig string) error {
+	if integrationConfig == "" {
+		return errors.New("-dry_run requires an -integration_config file")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	// allows interrupting long-running integrations
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	defer signal.Stop(interrupt)
+	go func() {
+		select {
+		case <-interrupt:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	integrationCfg := newIntegrationsConfig(c, pluginSourceDirs(c))
+	em := emitter.NewDryRunEmitter(os.Stdout, feature_flags.NewManager(c.Features))
+
+	return v4.DryRun(ctx, integrationCfg, newInstancesLookup(integrationCfg), em, integrationConfig)
+}
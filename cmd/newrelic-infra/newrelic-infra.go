@@ -22,10 +22,15 @@ import (
 
 	"github.com/newrelic/infrastructure-agent/internal/agent/cmdchannel"
 	ccBackoff "github.com/newrelic/infrastructure-agent/internal/agent/cmdchannel/backoff"
+	"github.com/newrelic/infrastructure-agent/internal/agent/cmdchannel/captureintegration"
 	"github.com/newrelic/infrastructure-agent/internal/agent/cmdchannel/fflag"
 	"github.com/newrelic/infrastructure-agent/internal/agent/cmdchannel/runintegration"
 	"github.com/newrelic/infrastructure-agent/internal/agent/cmdchannel/service"
 	"github.com/newrelic/infrastructure-agent/internal/agent/cmdchannel/stopintegration"
+	"github.com/newrelic/infrastructure-agent/internal/httpapi"
+	"github.com/newrelic/infrastructure-agent/internal/integrations/v4/breaker"
+	"github.com/newrelic/infrastructure-agent/internal/integrations/v4/bundle"
+	"github.com/newrelic/infrastructure-agent/internal/integrations/v4/capture"
 	"github.com/newrelic/infrastructure-agent/internal/integrations/v4/files"
 	"github.com/newrelic/infrastructure-agent/internal/integrations/v4/integration"
 	"github.com/newrelic/infrastructure-agent/internal/integrations/v4/v3legacy"
@@ -51,6 +56,7 @@ import (
 	"github.com/newrelic/infrastructure-agent/pkg/integrations/v4/logs"
 	wlog "github.com/newrelic/infrastructure-agent/pkg/log"
 	"github.com/newrelic/infrastructure-agent/pkg/plugins"
+	"github.com/newrelic/infrastructure-agent/pkg/plugins/simulation"
 	"github.com/newrelic/infrastructure-agent/pkg/trace"
 )
 
@@ -61,6 +67,9 @@ var (
 	cpuprofile   string
 	memprofile   string
 	verbose      int
+	simulate     string
+	dryRun       bool
+	dryRunConfig string
 	startTime    time.Time
 	buildVersion = "development"
 	gitCommit    = ""
@@ -79,6 +88,27 @@ func init() {
 	flag.StringVar(&memprofile, "memprofile", "", "Writes memory profile to `file`")
 
 	flag.IntVar(&verbose, "verbose", 0, "Higher numbers increase levels of logging. When enabled overrides provided config.")
+	flag.BoolVar(&dryRun, "dry_run", false, "Runs once the integrations of the -integration_config file, prints their payloads and exits, without sending anything")
+	flag.StringVar(&dryRunConfig, "integration_config", "", "Integration config `file` to be executed in -dry_run mode")
+
+	// simulate is meant for capacity testing, so it's not listed in the usage help.
+	flag.StringVar(&simulate, "simulate", "", "Submits synthetic samples, ie: `hosts=500,procs=200,seed=42`")
+	flag.Usage = usage
+}
+
+// hiddenFlags are supported but not listed in the usage help.
+var hiddenFlags = map[string]bool{"simulate": true}
+
+func usage() {
+	visible := flag.NewFlagSet(os.Args[0], flag.ContinueOnError)
+	visible.SetOutput(flag.CommandLine.Output())
+	flag.VisitAll(func(f *flag.Flag) {
+		if !hiddenFlags[f.Name] {
+			visible.Var(f.Value, f.Name, f.Usage)
+		}
+	})
+	fmt.Fprintf(visible.Output(), "Usage of %s:\n", os.Args[0])
+	visible.PrintDefaults()
 }
 
 var alog = wlog.WithComponent("New Relic Infrastructure Agent")
@@ -168,6 +198,14 @@ func main() {
 
 	logConfig(cfg)
 
+	if dryRun {
+		if err := dryRunIntegration(cfg, dryRunConfig); err != nil {
+			alog.WithError(err).Error("integration dry run failed")
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
 	err = initialize.OsProcess(cfg)
 	if err != nil {
 		alog.WithError(err).Error("Performing OS-specific process initialization...")
@@ -205,30 +243,24 @@ var aslog = wlog.WithComponent("AgentService").WithFields(logrus.Fields{
 })
 
 func initializeAgentAndRun(c *config.Config, logFwCfg config.LogForward) error {
-	pluginSourceDirs := []string{
-		c.CustomPluginInstallationDir,
-		filepath.Join(c.AgentDir, "custom-integrations"),
-		filepath.Join(c.AgentDir, config.DefaultIntegrationsDir),
-		filepath.Join(c.AgentDir, "bundled-plugins"),
-		filepath.Join(c.AgentDir, "plugins"),
-	}
-	pluginSourceDirs = helpers.RemoveEmptyAndDuplicateEntries(pluginSourceDirs)
-
-	integrationCfg := v4.NewConfig(
-		c.Verbose,
-		c.Features,
-		c.PassthroughEnvironment,
-		c.PluginInstanceDirs,
-		pluginSourceDirs,
-	)
+	pluginSourceDirs := pluginSourceDirs(c)
+	integrationCfg := newIntegrationsConfig(c, pluginSourceDirs)
 
 	userAgent := agent.GenerateUserAgent("New Relic Infrastructure Agent", buildVersion)
-	transport := backendhttp.BuildTransport(c, backendhttp.ClientTimeout)
+	transport := backendhttp.GetSharedTransport(c)
 	httpClient := backendhttp.GetHttpClient(backendhttp.ClientTimeout, transport)
 	cmdChannelURL := strings.TrimSuffix(c.CommandChannelURL, "/")
 	ccSvcURL := fmt.Sprintf("%s%s", cmdChannelURL, c.CommandChannelEndpoint)
 	caClient := commandapi.NewClient(ccSvcURL, c.License, userAgent, httpClient.Do)
 	ffManager := feature_flags.NewManager(c.Features)
+	capture.SetDir(c.IntegrationsDebugCaptureDir)
+	if c.IntegrationsDebugCaptureName != "" {
+		if err := capture.Start(c.IntegrationsDebugCaptureName, c.IntegrationsDebugCapturePayloads); err != nil {
+			aslog.WithError(err).Warn("Can't capture integration.")
+		}
+	}
+	// bundles must be installed before looking for the integrations definitions and executables
+	installIntegrationsBundles(c, httpClient.Do)
 	il := newInstancesLookup(integrationCfg)
 
 	// queues integration run requests
@@ -243,6 +275,7 @@ func initializeAgentAndRun(c *config.Config, logFwCfg config.LogForward) error {
 	ffHandler := cmdchannel.NewCmdHandler("set_feature_flag", ffHandle.Handle)
 	riHandler := runintegration.NewHandler(definitionQ, il, wlog.WithComponent("runintegration.Handler"))
 	siHandler := stopintegration.NewHandler(tracker, wlog.WithComponent("stopintegration.Handler"))
+	ciHandler := captureintegration.NewHandler(wlog.WithComponent("captureintegration.Handler"))
 	// Command channel service
 	ccService := service.NewService(
 		caClient,
@@ -252,6 +285,7 @@ func initializeAgentAndRun(c *config.Config, logFwCfg config.LogForward) error {
 		ffHandler,
 		riHandler,
 		siHandler,
+		ciHandler,
 	)
 	initCmdResponse, err := ccService.InitialFetch(context.Background())
 	if err != nil {
@@ -311,11 +345,23 @@ func initializeAgentAndRun(c *config.Config, logFwCfg config.LogForward) error {
 		os.Exit(1)
 	}
 
+	if simulate != "" {
+		spec, err := simulation.ParseSpec(simulate)
+		if err != nil {
+			fatal(err, "Invalid simulation spec.")
+		}
+		agt.RegisterPlugin(simulation.NewPlugin(agt.Context, spec))
+	}
+
 	metricsSenderConfig := dm.NewConfig(c.MetricURL, c.License, time.Duration(c.DMSubmissionPeriod)*time.Second, c.MaxMetricBatchEntitiesCount, c.MaxMetricBatchEntitiesQueue)
+	metricsSenderConfig.Spool = agt.Context.PayloadSpool()
+	metricsSenderConfig.Destinations = c.AdditionalDestinations
+	metricsSenderConfig.Throttle = agt.Context.Throttle()
 	dmSender, err := dm.NewDMSender(metricsSenderConfig, transport, agt.Context.IdContext().AgentIdentity)
 	if err != nil {
 		return err
 	}
+	agt.RegisterFlusher(dmSender)
 
 	var dmEmitter dm.Emitter
 	if enabled, exists := ffManager.GetFeatureFlag(fflag.FlagDMRegisterEnable); exists && enabled {
@@ -324,7 +370,12 @@ func initializeAgentAndRun(c *config.Config, logFwCfg config.LogForward) error {
 		dmEmitter = dm.NewNonRegisterEmitter(agt.GetContext(), dmSender)
 	}
 	integrationEmitter := emitter.NewIntegrationEmittor(agt, dmEmitter, ffManager)
-	integrationManager := v4.NewManager(integrationCfg, integrationEmitter, il, definitionQ, tracker)
+	breakers := breaker.NewRegistry(breaker.Config{
+		Threshold:  c.IntegrationsCircuitBreakerThreshold,
+		MinBackoff: breaker.DefaultMinBackoff,
+		MaxBackoff: time.Duration(c.IntegrationsCircuitBreakerMaxBackoffSec) * time.Second,
+	}, agt.Context.SendEvent)
+	integrationManager := v4.NewManager(integrationCfg, integrationEmitter, il, definitionQ, tracker, breakers)
 
 	// log-forwarder
 	fbIntCfg := v4.FBSupervisorConfig{
@@ -351,6 +402,10 @@ func initializeAgentAndRun(c *config.Config, logFwCfg config.LogForward) error {
 
 	go integrationManager.Start(agt.Context.Ctx)
 
+	if c.StatusServerEnabled {
+		go httpapi.NewStatusServer(c.StatusServerPort, breakers, agt.Context.RecentSamples(), transport.Stats()).Serve(agt.Context.Ctx)
+	}
+
 	go ccService.Run(agt.Context.Ctx, agt.Context.AgentIdnOrEmpty, initCmdResponse)
 
 	pluginRegistry := legacy.NewPluginRegistry(pluginSourceDirs, c.PluginInstanceDirs)
@@ -380,6 +435,54 @@ func initializeAgentAndRun(c *config.Config, logFwCfg config.LogForward) error {
 // newInstancesLookup creates an instance lookup that:
 // - looks in the v3 legacy definitions repository for defined commands
 // - looks in the definition folders (and bin/ subfolders) for executable names
+func pluginSourceDirs(c *config.Config) []string {
+	dirs := []string{
+		c.CustomPluginInstallationDir,
+		filepath.Join(c.AgentDir, "custom-integrations"),
+		filepath.Join(c.AgentDir, config.DefaultIntegrationsDir),
+		filepath.Join(c.AgentDir, "bundled-plugins"),
+		filepath.Join(c.AgentDir, "plugins"),
+	}
+	return helpers.RemoveEmptyAndDuplicateEntries(dirs)
+}
+
+// installIntegrationsBundles downloads and installs the integration bundles from the agent configuration.
+func installIntegrationsBundles(c *config.Config, do bundle.DoFn) {
+	if len(c.IntegrationsBundles) == 0 {
+		return
+	}
+	keys, err := bundle.LoadPublicKeys(c.IntegrationsBundlesPublicKeys)
+	if err != nil {
+		aslog.WithError(err).Error("can't load integrations bundles public keys, bundles won't be installed")
+		return
+	}
+	integrationsDir := filepath.Join(c.AgentDir, config.DefaultIntegrationsDir)
+	installer := bundle.NewInstaller(bundle.Config{
+		Repository:     c.IntegrationsBundlesRepository,
+		PublicKeys:     keys,
+		BinDir:         filepath.Join(integrationsDir, "bin"),
+		DefinitionsDir: integrationsDir,
+		ConfigDir:      c.PluginInstanceDirs[0],
+		StateDir:       filepath.Join(c.AgentDir, "integration-bundles"),
+	}, do)
+	var bundles []bundle.Bundle
+	for _, b := range c.IntegrationsBundles {
+		bundles = append(bundles, bundle.Bundle{Name: b.Name, Version: b.Version, URL: b.URL, SHA256: b.SHA256})
+	}
+	installer.InstallAll(context.Background(), bundles)
+}
+
+func newIntegrationsConfig(c *config.Config, pluginSourceDirs []string) v4.Configuration {
+	return v4.NewConfig(
+		c.Verbose,
+		c.Features,
+		c.PassthroughEnvironment,
+		c.PluginInstanceDirs,
+		pluginSourceDirs,
+		c.IntegrationsTelemetryEnabled,
+	)
+}
+
 func newInstancesLookup(cfg v4.Configuration) integration.InstancesLookup {
 	const executablesSubFolder = "bin"
 
@@ -20,7 +20,10 @@ import (
 
 	"github.com/newrelic/infrastructure-agent/internal/feature_flags"
 	"github.com/newrelic/infrastructure-agent/pkg/entity/host"
+	"github.com/newrelic/infrastructure-agent/pkg/metrics/enrich"
+	"github.com/newrelic/infrastructure-agent/pkg/metrics/recent"
 	"github.com/newrelic/infrastructure-agent/pkg/metrics/sampler"
+	"github.com/newrelic/infrastructure-agent/pkg/metrics/transform"
 	"github.com/newrelic/infrastructure-agent/pkg/trace"
 
 	"github.com/newrelic/infrastructure-agent/pkg/helpers/metric"
@@ -30,7 +33,9 @@ import (
 	"github.com/newrelic/infrastructure-agent/pkg/ipc"
 
 	"github.com/newrelic/infrastructure-agent/pkg/backend/identityapi"
+	"github.com/newrelic/infrastructure-agent/pkg/backend/spool"
 	"github.com/newrelic/infrastructure-agent/pkg/backend/state"
+	"github.com/newrelic/infrastructure-agent/pkg/backend/throttle"
 	"github.com/newrelic/infrastructure-agent/pkg/helpers/fingerprint"
 	"github.com/newrelic/infrastructure-agent/pkg/log"
 	"github.com/newrelic/infrastructure-agent/pkg/sysinfo"
@@ -40,6 +45,7 @@ import (
 	"github.com/newrelic/infrastructure-agent/internal/agent/debug"
 	"github.com/newrelic/infrastructure-agent/internal/agent/delta"
 	"github.com/newrelic/infrastructure-agent/internal/agent/id"
+	"github.com/newrelic/infrastructure-agent/internal/agent/wal"
 	"github.com/newrelic/infrastructure-agent/pkg/disk"
 	"github.com/newrelic/infrastructure-agent/pkg/entity"
 	"github.com/newrelic/infrastructure-agent/pkg/plugins/ids"
@@ -54,6 +60,8 @@ import (
 
 const (
 	defaultRemoveEntitiesPeriod = 48 * time.Hour
+	expireEntitiesPeriod        = time.Minute
+	dataDirQuotaPeriod          = time.Minute
 	activeEntitiesBufferLength  = 32
 )
 
@@ -62,6 +70,11 @@ type registerableSender interface {
 	Stop() error
 }
 
+// Flusher submits the data buffered by a component when the agent stops.
+type Flusher interface {
+	Flush(ctx context2.Context) error
+}
+
 type Agent struct {
 	inv                 inventoryState
 	plugins             []Plugin              // Slice of registered plugins
@@ -70,8 +83,10 @@ type Agent struct {
 	extDir              string                // Location of external data input
 	userAgent           string                // User-Agent making requests to warlock
 	inventories         map[string]*inventory // Inventory reaper and sender instances (key: entity ID)
+	ephemeralEntities   map[string]time.Time  // Expiration of the short-lived entities inventories (key: entity ID)
 	Context             *context              // Agent context data that is passed around the place
 	metricsSender       registerableSender
+	flushers            []Flusher // components whose data is flushed when the agent stops
 	store               *delta.Store
 	debugProvide        debug.Provide
 	httpClient          backendhttp.Client // http client for both data submission types: events and inventory
@@ -151,6 +166,13 @@ type context struct {
 	EntityMap          entity.KnownIDs
 	idLookup           host.IDLookup
 	shouldIncludeEvent sampler.IncludeSampleMatchFn
+	enricher           enrich.Chain
+	assignedTags       atomic.Value // map[string]string assigned by the backend on connect
+	transformer        *transform.Transformer
+	recentSamples      *recent.Store // nil: the emitted samples aren't kept for local querying
+	spool              *spool.Spool  // nil: payloads failing while the backend is unavailable are dropped
+	eventLog           *wal.Log      // nil: the queued events are kept only in memory
+	throttle           *throttle.Controller
 }
 
 func (c *context) Context() context2.Context {
@@ -185,6 +207,12 @@ func (c *context) SetAgentIdentity(id entity.Identity) {
 	c.id.SetAgentIdentity(id)
 }
 
+// SetAssignedTags replaces the tags assigned by the backend to the agent entity, which decorate
+// the samples sent from then on.
+func (c *context) SetAssignedTags(tags map[string]string) {
+	c.assignedTags.Store(tags)
+}
+
 //IDLookup returns the IDLookup map.
 func (c *context) IDLookup() host.IDLookup {
 	return c.idLookup
@@ -197,6 +225,8 @@ func NewContext(
 	resolver hostname.ResolverChangeNotifier,
 	lookup host.IDLookup,
 	sampleMatchFn sampler.IncludeSampleMatchFn,
+	enricher enrich.Chain,
+	transformer *transform.Transformer,
 ) *context {
 	ctx, cancel := context2.WithCancel(context2.Background())
 
@@ -214,6 +244,8 @@ func NewContext(
 		resolver:           resolver,
 		idLookup:           lookup,
 		shouldIncludeEvent: sampleMatchFn,
+		enricher:           enricher,
+		transformer:        transformer,
 		agentKey:           agentKey,
 	}
 }
@@ -303,7 +335,21 @@ func NewAgent(
 
 	idLookupTable := NewIdLookup(hostnameResolver, cloudHarvester, cfg.DisplayName)
 	sampleMatchFn := sampler.NewSampleMatchFn(cfg.EnableProcessMetrics, cfg.IncludeMetricsMatchers, ffRetriever)
-	ctx := NewContext(cfg, buildVersion, hostnameResolver, idLookupTable, sampleMatchFn)
+
+	var enricher enrich.Chain
+	if cfg.EnrichmentRulesFile != "" {
+		if enricher, err = enrich.LoadRules(cfg.EnrichmentRulesFile); err != nil {
+			return nil, err
+		}
+	}
+	transformer, err := transform.New(cfg.PayloadTransforms)
+	if err != nil {
+		return nil, err
+	}
+	ctx := NewContext(cfg, buildVersion, hostnameResolver, idLookupTable, sampleMatchFn, enricher, transformer)
+	if cfg.RecentSamplesRetentionMinutes > 0 {
+		ctx.recentSamples = recent.NewStore(time.Duration(cfg.RecentSamplesRetentionMinutes)*time.Minute, cfg.RecentSamplesMaxCount)
+	}
 
 	agentKey, err := idLookupTable.AgentKey()
 	if err != nil {
@@ -318,14 +364,43 @@ func NewAgent(
 		dataDir = filepath.Join(cfg.AgentDir, "data")
 	}
 
+	if cfg.PayloadSpoolMaxSize > 0 {
+		spoolDir := filepath.Join(filepath.Dir(dataDir), "spool")
+		if ctx.spool, err = spool.New(spoolDir, cfg.PayloadSpoolMaxSize, time.Duration(cfg.PayloadSpoolMaxAgeSec)*time.Second); err != nil {
+			alog.WithError(err).Warn("Payload spool disabled, payloads failing while the backend is unavailable will be dropped.")
+			err = nil
+		}
+	}
+
+	if cfg.EventQueueWAL {
+		walDir := filepath.Join(filepath.Dir(dataDir), "event_wal")
+		if ctx.eventLog, err = wal.Open(walDir, eventWALSegmentSize); err != nil {
+			alog.WithError(err).Warn("Event queue write-ahead log disabled, queued events will be lost on restart.")
+			err = nil
+		}
+	}
+
+	ctx.throttle = throttle.New(cfg.ThrottleMaxFactor, time.Duration(cfg.ThrottleRecoverySec)*time.Second)
+
 	maxInventorySize := cfg.MaxInventorySize
 	if cfg.DisableInventorySplit {
 		maxInventorySize = delta.DisableInventorySplit
 	}
 
-	s := delta.NewStore(dataDir, ctx.EntityKey(), maxInventorySize)
+	var s *delta.Store
+	switch cfg.InventoryStoreBackend {
+	case config.InventoryStoreBackendBolt:
+		s = delta.NewBoltStore(dataDir, ctx.EntityKey(), maxInventorySize)
+	default:
+		if cfg.InventoryStoreBackend != config.InventoryStoreBackendFile {
+			alog.WithField("backend", cfg.InventoryStoreBackend).Warn("Unknown inventory store backend, using the file one.")
+		}
+		s = delta.NewStore(dataDir, ctx.EntityKey(), maxInventorySize)
+	}
+	s.SetCompressionLevel(cfg.InventoryStoreCompressionLevel)
+	s.SetNoiseRules(noiseRules(cfg.InventoryNoiseRules))
 
-	transport := backendhttp.BuildTransport(cfg, backendhttp.ClientTimeout)
+	transport := backendhttp.GetSharedTransport(cfg)
 
 	httpClient := backendhttp.GetHttpClient(backendhttp.ClientTimeout, transport)
 
@@ -532,6 +607,7 @@ func (a *Agent) unregisterEntityInventory(entityKey string) error {
 	if ok {
 		delete(a.inventories, entityKey)
 	}
+	delete(a.ephemeralEntities, entityKey)
 
 	return a.store.RemoveEntity(entityKey)
 }
@@ -559,6 +635,13 @@ func (a *Agent) RegisterMetricsSender(s registerableSender) {
 	a.metricsSender = s
 }
 
+// RegisterFlusher adds a component whose data is flushed when the agent stops.
+func (a *Agent) RegisterFlusher(f Flusher) {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+	a.flushers = append(a.flushers, f)
+}
+
 // RegisterPlugin takes a Plugin instance and registers it in the
 // agent's plugin map
 func (a *Agent) RegisterPlugin(p Plugin) {
@@ -588,6 +671,19 @@ func (a *Agent) DeprecatePlugin(plugin ids.PluginID) {
 	a.oldPlugins = append(a.oldPlugins, plugin)
 }
 
+// noiseRules returns the configured inventory noise rules, ignoring the invalid ones.
+func noiseRules(configured []config.InventoryNoiseRule) (rules []delta.NoiseRule) {
+	for _, r := range configured {
+		rule, err := delta.NewNoiseRule(r.Plugin, r.Path, r.Value)
+		if err != nil {
+			alog.WithError(err).Warn("Ignoring invalid inventory noise rule.")
+			continue
+		}
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
 // storePluginOutput will take a PluginOutput and persist it in the store
 func (a *Agent) storePluginOutput(plugin PluginOutput) error {
 
@@ -758,6 +854,17 @@ func (a *Agent) Run() (err error) {
 	removeEntitiesTicker := time.NewTicker(removeEntitiesPeriod)
 	reportedEntities := map[string]bool{}
 
+	// Short-lived entities are removed as soon as they haven't reported during their TTL
+	expireEntitiesTicker := time.NewTicker(expireEntitiesPeriod)
+
+	// The inventory of the least recently updated entities is evicted when the data directory exceeds its quota
+	var dataDirQuotaC <-chan time.Time
+	if cfg.MaxDataDirSize > 0 {
+		dataDirQuotaTicker := time.NewTicker(dataDirQuotaPeriod)
+		defer dataDirQuotaTicker.Stop()
+		dataDirQuotaC = dataDirQuotaTicker.C
+	}
+
 	// Wait no more than this long for initial inventory reap even if some plugins haven't reported data
 	initialReapTimeout := time.NewTimer(config.INITIAL_REAP_MAX_WAIT_SECONDS * time.Second)
 
@@ -790,16 +897,18 @@ func (a *Agent) Run() (err error) {
 		if removeEntitiesTicker != nil {
 			removeEntitiesTicker.Stop()
 		}
-		if a.Context.eventSender != nil {
-			if err := a.Context.eventSender.Stop(); err != nil {
-				log.WithError(err).Error("failed to stop event sender")
-			}
-		}
+		expireEntitiesTicker.Stop()
+		// samplers are stopped first, so they don't queue events after the event sender is flushed
 		if a.metricsSender != nil {
 			if err := a.metricsSender.Stop(); err != nil {
 				log.WithError(err).Error("failed to stop metrics subsystem")
 			}
 		}
+		if a.Context.eventSender != nil {
+			if err := a.Context.eventSender.Stop(); err != nil {
+				log.WithError(err).Error("failed to stop event sender")
+			}
+		}
 
 		if a.notificationHandler != nil {
 			a.notificationHandler.Stop()
@@ -821,6 +930,13 @@ func (a *Agent) Run() (err error) {
 	for {
 		select {
 		case <-exit:
+			a.flush(time.Duration(cfg.ShutdownFlushTimeoutSec) * time.Second)
+			// the flushed inventory deltas are written to the store, so it's closed afterwards
+			if a.store != nil {
+				if err := a.store.Close(); err != nil {
+					log.WithError(err).Warn("failed to close the delta store")
+				}
+			}
 			return nil
 			// agent gets notified about active entities
 		case ent := <-a.Context.activeEntities:
@@ -839,6 +955,8 @@ func (a *Agent) Run() (err error) {
 					_ = a.registerEntityInventory(data.Entity)
 				}
 
+				a.trackEntityExpiration(data.Entity, time.Now())
+
 				if !data.NotApplicable {
 					if err := a.storePluginOutput(data); err != nil {
 						alog.WithError(err).Error("problem storing plugin output")
@@ -901,6 +1019,69 @@ func (a *Agent) Run() (err error) {
 			reportedEntities = map[string]bool{} // reset the set of reporting entities the next period
 			alog.Debug("Triggered periodic removal of outdated entities.")
 			a.removeOutdatedEntities(pastPeriodReportedEntities)
+		case now := <-expireEntitiesTicker.C:
+			a.removeExpiredEntities(now)
+		case <-dataDirQuotaC:
+			a.enforceDataDirQuota(cfg.MaxDataDirSize)
+		}
+	}
+}
+
+// flush submits the inventory deltas, events and metrics pending when the agent stops, waiting no longer than
+// the timeout. Inventory deltas not sent remain in the delta store for the next execution.
+func (a *Agent) flush(timeout time.Duration) {
+	if timeout <= 0 {
+		return
+	}
+	ctx, cancel := context2.WithTimeout(context2.Background(), timeout)
+	defer cancel()
+
+	a.mtx.Lock()
+	flushers := append([]Flusher{}, a.flushers...)
+	a.mtx.Unlock()
+	if f, ok := a.Context.eventSender.(Flusher); ok {
+		flushers = append(flushers, f)
+	}
+
+	alog.WithField("timeout", timeout).Info("Flushing pending data.")
+	var wg sync.WaitGroup
+	wg.Add(len(flushers) + 1)
+	for _, f := range flushers {
+		go func(f Flusher) {
+			defer wg.Done()
+			if err := f.Flush(ctx); err != nil {
+				alog.WithError(err).Warn("Pending data not flushed.")
+			}
+		}(f)
+	}
+	go func() {
+		defer wg.Done()
+		a.flushInventory()
+	}()
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		alog.Info("Pending data flushed.")
+	case <-ctx.Done():
+		alog.Warn("Shutdown flush timeout exceeded, exiting with data pending.")
+	}
+}
+
+// flushInventory reaps and sends the inventory data received since the last reap.
+func (a *Agent) flushInventory() {
+	for _, inventory := range a.inventories {
+		if a.inv.readyToReap && inventory.needsReaping {
+			inventory.reaper.Reap()
+			inventory.needsReaping = false
+		}
+		if err := inventory.sender.Process(); err != nil {
+			alog.WithError(err).Warn("Inventory deltas not flushed, they will be sent by the next execution.")
+			return
 		}
 	}
 }
@@ -913,6 +1094,7 @@ func (a *Agent) sendInventory(sendTimer *time.Timer) {
 			if ingestError, ok := err.(*inventoryapi.IngestError); ok &&
 				ingestError.StatusCode == http.StatusTooManyRequests {
 				alog.Warn("server is rate limiting inventory submission")
+				a.Context.throttle.Throttled()
 				backoffMax = config.RATE_LIMITED_BACKOFF
 				a.inv.sendErrorCount = helpers.MaxBackoffErrorCount
 			} else {
@@ -973,6 +1155,61 @@ func (a *Agent) removeOutdatedEntities(reportedEntities map[string]bool) {
 	alog.WithField("remaining", len(a.inventories)).Debug("Some entities may remain registered.")
 }
 
+// trackEntityExpiration extends the expiration of the short-lived entities each time they report.
+func (a *Agent) trackEntityExpiration(ent entity.Entity, now time.Time) {
+	entityKey := ent.Key.String()
+	// the local entity never expires
+	if ent.TTL <= 0 || entityKey == a.Context.EntityKey() {
+		delete(a.ephemeralEntities, entityKey)
+		return
+	}
+	if a.ephemeralEntities == nil {
+		a.ephemeralEntities = map[string]time.Time{}
+	}
+	a.ephemeralEntities[entityKey] = now.Add(ent.TTL)
+}
+
+// removeExpiredEntities unregisters the inventories of the short-lived entities that haven't reported
+// during their TTL, without waiting for the periodic removal of outdated entities.
+func (a *Agent) removeExpiredEntities(now time.Time) {
+	for entityKey, expiration := range a.ephemeralEntities {
+		if now.Before(expiration) {
+			continue
+		}
+		delete(a.ephemeralEntities, entityKey)
+		elog := alog.WithField("entityKey", entityKey)
+		elog.Debug("Removing inventory for expired entity.")
+		if err := a.unregisterEntityInventory(entityKey); err != nil {
+			elog.WithError(err).Warn("unregistering inventory for expired entity")
+		}
+	}
+}
+
+// enforceDataDirQuota evicts the inventory of the least recently updated entities when the data directory is
+// larger than maxSize, reporting an event about it.
+func (a *Agent) enforceDataDirQuota(maxSize uint64) {
+	evicted, err := a.store.EvictEntities(maxSize)
+	if err != nil {
+		alog.WithError(err).Warn("error evicting entities from the data directory")
+	}
+	if len(evicted) == 0 {
+		return
+	}
+
+	alog.WithFields(logrus.Fields{
+		"maxDataDirSize": maxSize,
+		"entities":       evicted,
+	}).Warn("Data directory quota exceeded, the inventory of the least recently updated entities has been evicted.")
+	a.Context.SendEvent(mapEvent{
+		"eventType":       "InfrastructureEvent",
+		"category":        "agent",
+		"summary":         fmt.Sprintf("Data directory quota of %d bytes exceeded, evicted the inventory of %d entities", maxSize, len(evicted)),
+		"maxDataDirSize":  maxSize,
+		"evictedEntities": len(evicted),
+		"timestamp":       time.Now().Unix(),
+	}, entity.Key(a.Context.EntityKey()))
+}
+
 func (c *context) SendData(data PluginOutput) {
 	c.ch <- data
 }
@@ -983,6 +1220,12 @@ func (c *context) ActiveEntitiesChannel() chan string {
 
 func (c *context) SendEvent(event sample.Event, entityKey entity.Key) {
 	if c.eventSender != nil {
+		// samples collected during a silent window are tagged once filtered
+		var silentWindow string
+		if tagged, ok := event.(*sampler.TaggedEvent); ok {
+			event, silentWindow = tagged.Event, tagged.Window
+		}
+
 		// limits any string field larger than 4095 chars
 		if c.cfg.TruncTextValues {
 			event = metric.TruncateLength(event, metric.NRDBLimit)
@@ -990,15 +1233,72 @@ func (c *context) SendEvent(event sample.Event, entityKey entity.Key) {
 
 		includeSample := c.shouldIncludeEvent(event)
 		if includeSample {
+			enriched, err := c.enrichers().Apply(event)
+			if err != nil {
+				alog.WithError(err).Warn("could not enrich event")
+			}
+			event = enriched
+
+			if silentWindow != "" {
+				if event, err = sampler.Tag(event, silentWindow); err != nil {
+					alog.WithError(err).Warn("could not tag event with silent window")
+				}
+			}
+
+			transformed, keep, err := c.transformer.Event(event)
+			if err != nil {
+				alog.WithError(err).Warn("could not transform event")
+			}
+			if !keep {
+				return
+			}
+			event = transformed
+
 			if err := c.eventSender.QueueEvent(event, entityKey); err != nil {
 				alog.WithField(
 					"entityKey", entityKey,
 				).WithError(err).Error("could not queue event")
+			} else if c.recentSamples != nil {
+				// queued samples already have their entity key
+				c.recentSamples.Add(event)
 			}
 		}
 	}
 }
 
+// enrichers returns the configured enrichment rules, followed by the tags assigned by the backend.
+// Assigned tags don't replace the attributes of the samples nor the ones set by the local rules.
+func (c *context) enrichers() enrich.Chain {
+	tags, _ := c.assignedTags.Load().(map[string]string)
+	if len(tags) == 0 {
+		return c.enricher
+	}
+	chain := make(enrich.Chain, len(c.enricher), len(c.enricher)+1)
+	copy(chain, c.enricher)
+	return append(chain, enrich.EnricherFunc(func(attributes map[string]interface{}) {
+		for k, v := range tags {
+			if _, ok := attributes[k]; !ok {
+				attributes[k] = v
+			}
+		}
+	}))
+}
+
+// RecentSamples returns the store of the last emitted samples, or nil if they aren't kept.
+func (c *context) RecentSamples() *recent.Store {
+	return c.recentSamples
+}
+
+// PayloadSpool returns the spool storing the payloads that couldn't be submitted, or nil when it's disabled.
+func (c *context) PayloadSpool() *spool.Spool {
+	return c.spool
+}
+
+// Throttle returns the controller slowing down the agent while the backend throttles it, or nil when disabled.
+func (c *context) Throttle() *throttle.Controller {
+	return c.throttle
+}
+
 func (c *context) Unregister(id ids.PluginID) {
 	c.ch <- NewNotApplicableOutput(id)
 }
@@ -1086,6 +1386,7 @@ func (c *context) getAgentKey() (agentKey string) {
 func (a *Agent) connect() {
 	alog.Debug("Performing connect.")
 	a.Context.SetAgentIdentity(a.connectSrv.Connect())
+	a.Context.SetAssignedTags(a.connectSrv.AssignedTags())
 
 	updateFreq := time.Duration(a.Context.cfg.FingerprintUpdateFreqSec) * time.Second
 	ticker := time.NewTicker(updateFreq)
@@ -1098,6 +1399,7 @@ func (a *Agent) connect() {
 			continue
 		}
 		a.Context.SetAgentIdentity(identity)
+		a.Context.SetAssignedTags(a.connectSrv.AssignedTags())
 	}
 }
 
@@ -16,6 +16,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"strings"
 	"path/filepath"
 	"sync"
 	"sync/atomic"
@@ -26,7 +27,10 @@ import (
 	"github.com/newrelic/infrastructure-agent/internal/feature_flags/test"
 	"github.com/newrelic/infrastructure-agent/pkg/backend/backoff"
 	"github.com/newrelic/infrastructure-agent/pkg/ctl"
+	"github.com/newrelic/infrastructure-agent/pkg/metrics/enrich"
+	"github.com/newrelic/infrastructure-agent/pkg/metrics/transform"
 	"github.com/newrelic/infrastructure-agent/pkg/metrics/types"
+	"github.com/newrelic/infrastructure-agent/pkg/sample"
 
 	"github.com/newrelic/infrastructure-agent/pkg/sysinfo"
 	"github.com/newrelic/infrastructure-agent/pkg/sysinfo/cloud"
@@ -61,7 +65,7 @@ func newTesting(cfg *config.Config) *Agent {
 	cloudDetector := cloud.NewDetector(true, 0, 0, 0, false)
 	lookups := NewIdLookup(hostname.CreateResolver("", "", true), cloudDetector, cfg.DisplayName)
 
-	ctx := NewContext(cfg, "1.2.3", testhelpers.NullHostnameResolver, lookups, matcher)
+	ctx := NewContext(cfg, "1.2.3", testhelpers.NullHostnameResolver, lookups, matcher, nil, nil)
 
 	st := delta.NewStore(dataDir, "default", cfg.MaxInventorySize)
 
@@ -140,7 +144,7 @@ func TestIgnoreInventory(t *testing.T) {
 
 func TestServicePidMap(t *testing.T) {
 
-	ctx := NewContext(&config.Config{}, "", testhelpers.NullHostnameResolver, NilIDLookup, matcher)
+	ctx := NewContext(&config.Config{}, "", testhelpers.NullHostnameResolver, NilIDLookup, matcher, nil, nil)
 	svc, ok := ctx.GetServiceForPid(1)
 	assert.False(t, ok)
 	assert.Len(t, svc, 0)
@@ -825,3 +829,139 @@ func Test_ProcessSampling(t *testing.T) {
 		})
 	}
 }
+
+type recordingEventSender struct {
+	events []sample.Event
+}
+
+func (r *recordingEventSender) QueueEvent(event sample.Event, _ entity.Key) error {
+	r.events = append(r.events, event)
+	return nil
+}
+
+func (r *recordingEventSender) Start() error { return nil }
+
+func (r *recordingEventSender) Stop() error { return nil }
+
+func TestContext_SendEvent_Enriched(t *testing.T) {
+	// GIVEN an agent context with an enricher
+	enricher := enrich.Chain{enrich.EnricherFunc(func(attributes map[string]interface{}) {
+		attributes["business_unit"] = "platform"
+	})}
+	ctx := NewContext(&config.Config{}, "", testhelpers.NullHostnameResolver, NilIDLookup, matcher, enricher, nil)
+	sender := &recordingEventSender{}
+	ctx.eventSender = sender
+
+	// WHEN an event is sent
+	ctx.SendEvent(&types.ProcessSample{ProcessID: 1}, "")
+
+	// THEN the queued event has been enriched
+	require.Len(t, sender.events, 1)
+	enriched, ok := sender.events[0].(*enrich.Event)
+	require.True(t, ok)
+	assert.Equal(t, "platform", (*enriched)["business_unit"])
+}
+
+func TestContext_SendEvent_AssignedTags(t *testing.T) {
+	// GIVEN an agent context with tags assigned by the backend
+	ctx := NewContext(&config.Config{}, "", testhelpers.NullHostnameResolver, NilIDLookup, matcher, nil, nil)
+	sender := &recordingEventSender{}
+	ctx.eventSender = sender
+	ctx.SetAssignedTags(map[string]string{"costCenter": "cc-42", "processId": "overridden"})
+
+	// WHEN an event is sent
+	ctx.SendEvent(&types.ProcessSample{ProcessID: 1}, "")
+
+	// THEN the queued event is decorated with the tags, without replacing its attributes
+	require.Len(t, sender.events, 1)
+	tagged, ok := sender.events[0].(*enrich.Event)
+	require.True(t, ok)
+	assert.Equal(t, "cc-42", (*tagged)["costCenter"])
+	assert.Equal(t, json.Number("1"), (*tagged)["processId"])
+}
+
+func TestContext_SendEvent_Transformed(t *testing.T) {
+	// GIVEN an agent context with payload transforms
+	transformer, err := transform.New([]string{
+		`event.processId == 2.0 ? drop() : keep()`,
+		`set("team", "security")`,
+	})
+	require.NoError(t, err)
+	ctx := NewContext(&config.Config{}, "", testhelpers.NullHostnameResolver, NilIDLookup, matcher, nil, transformer)
+	sender := &recordingEventSender{}
+	ctx.eventSender = sender
+
+	// WHEN some events are sent
+	ctx.SendEvent(&types.ProcessSample{ProcessID: 1}, "")
+	ctx.SendEvent(&types.ProcessSample{ProcessID: 2}, "")
+
+	// THEN the dropped events aren't queued and the rest are modified
+	require.Len(t, sender.events, 1)
+	transformed, ok := sender.events[0].(*enrich.Event)
+	require.True(t, ok)
+	assert.Equal(t, "security", (*transformed)["team"])
+}
+
+func TestRemoveExpiredEntities(t *testing.T) {
+	// Given an agent
+	agent := newTesting(nil)
+	defer os.RemoveAll(agent.store.DataDir)
+	agent.inventories = map[string]*inventory{}
+
+	// With registered short-lived and long-lived entities
+	now := time.Now()
+	for _, ent := range []entity.Entity{
+		{Key: "job:1", TTL: time.Minute},
+		{Key: "job:2", TTL: time.Hour},
+		{Key: "host:1"},
+	} {
+		require.NoError(t, agent.registerEntityInventory(ent))
+		agent.trackEntityExpiration(ent, now)
+	}
+
+	// When the expired entities are removed after the TTL of one of them
+	agent.removeExpiredEntities(now.Add(2 * time.Minute))
+
+	// Then only the expired entity is unregistered
+	assert.NotContains(t, agent.inventories, "job:1")
+	assert.Contains(t, agent.inventories, "job:2")
+	assert.Contains(t, agent.inventories, "host:1")
+
+	// And reporting again extends the expiration
+	agent.trackEntityExpiration(entity.Entity{Key: "job:2", TTL: time.Hour}, now.Add(50*time.Minute))
+	agent.removeExpiredEntities(now.Add(90 * time.Minute))
+	assert.Contains(t, agent.inventories, "job:2")
+	agent.removeExpiredEntities(now.Add(111 * time.Minute))
+	assert.NotContains(t, agent.inventories, "job:2")
+}
+
+func TestEnforceDataDirQuota(t *testing.T) {
+	// Given an agent storing the inventory of the local entity and of an integration entity
+	agent := newTesting(nil)
+	defer os.RemoveAll(agent.store.DataDir)
+	sender := &recordingEventSender{}
+	agent.Context.eventSender = sender
+	source := map[string]interface{}{"item": map[string]interface{}{"value": strings.Repeat("x", 1000)}}
+	require.NoError(t, agent.store.SavePluginSource("", "metadata", "local", source))
+	require.NoError(t, agent.store.SavePluginSource("integration:1", "metadata", "remote", source))
+
+	// When the data directory exceeds the quota
+	agent.enforceDataDirQuota(1500)
+
+	// Then the integration entity inventory is evicted
+	entities, err := agent.store.ScanEntityFolders()
+	require.NoError(t, err)
+	assert.NotContains(t, entities, helpers.SanitizeFileName("integration:1"))
+	assert.Len(t, entities, 1)
+
+	// And an event reports the eviction
+	require.Len(t, sender.events, 1)
+	event, ok := sender.events[0].(mapEvent)
+	require.True(t, ok)
+	assert.Equal(t, "agent", event["category"])
+	assert.Equal(t, 1, event["evictedEntities"])
+
+	// And nothing is evicted once below the quota
+	agent.enforceDataDirQuota(1500)
+	assert.Len(t, sender.events, 1)
+}
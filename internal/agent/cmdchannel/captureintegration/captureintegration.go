@@ -0,0 +1,58 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package captureintegration
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/newrelic/infrastructure-agent/internal/agent/cmdchannel"
+	"github.com/newrelic/infrastructure-agent/internal/agent/cmdchannel/runintegration"
+	"github.com/newrelic/infrastructure-agent/internal/integrations/v4/capture"
+	"github.com/newrelic/infrastructure-agent/pkg/backend/commandapi"
+	"github.com/newrelic/infrastructure-agent/pkg/log"
+)
+
+// DefaultPayloads is the number of payloads captured when the command doesn't specify it.
+const DefaultPayloads = 10
+
+// CaptureIntArgs are the arguments of the capture-integration requests.
+type CaptureIntArgs struct {
+	IntegrationName string `json:"integration_name"`
+	// Payloads to capture. Zero stops an ongoing capture.
+	Payloads *int `json:"payloads"`
+}
+
+// NewHandler creates a cmd-channel handler for capture-integration requests, which store the next
+// payloads and resolved configs of an integration into the debug capture directory.
+func NewHandler(logger log.Entry) *cmdchannel.CmdHandler {
+	handleF := func(ctx context.Context, cmd commandapi.Command, initialFetch bool) (err error) {
+		var args CaptureIntArgs
+		if err = json.Unmarshal(cmd.Args, &args); err != nil {
+			err = cmdchannel.NewArgsErr(err)
+			return
+		}
+
+		if args.IntegrationName == "" {
+			err = cmdchannel.NewArgsErr(runintegration.ErrNoIntName)
+			return
+		}
+
+		payloads := DefaultPayloads
+		if args.Payloads != nil {
+			payloads = *args.Payloads
+		}
+
+		if err = capture.Start(args.IntegrationName, payloads); err != nil {
+			logger.
+				WithField("cmd_id", cmd.ID).
+				WithField("cmd_name", cmd.Name).
+				WithField("cmd_args", string(cmd.Args)).
+				WithError(err).
+				Warn("cannot capture integration")
+		}
+		return
+	}
+
+	return cmdchannel.NewCmdHandler("capture_integration", handleF)
+}
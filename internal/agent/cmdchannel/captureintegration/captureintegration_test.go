@@ -0,0 +1,50 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package captureintegration
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/newrelic/infrastructure-agent/internal/agent/cmdchannel"
+	"github.com/newrelic/infrastructure-agent/internal/agent/cmdchannel/runintegration"
+	"github.com/newrelic/infrastructure-agent/internal/integrations/v4/capture"
+	"github.com/newrelic/infrastructure-agent/pkg/backend/commandapi"
+	"github.com/newrelic/infrastructure-agent/pkg/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	l = log.WithComponent("test")
+)
+
+func TestHandle_returnsErrorOnMissingIntegrationName(t *testing.T) {
+	h := NewHandler(l)
+
+	cmdArgsMissingName := commandapi.Command{
+		Args: []byte(`{ "payloads": 3 }`),
+	}
+
+	err := h.Handle(context.Background(), cmdArgsMissingName, false)
+	assert.Equal(t, cmdchannel.NewArgsErr(runintegration.ErrNoIntName).Error(), err.Error())
+}
+
+func TestHandle_startsAndStopsCapture(t *testing.T) {
+	dir, err := ioutil.TempDir("", "capture")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	capture.SetDir(dir)
+
+	h := NewHandler(l)
+
+	err = h.Handle(context.Background(), commandapi.Command{Args: []byte(`{ "integration_name": "nri-foo" }`)}, false)
+	require.NoError(t, err)
+	assert.True(t, capture.Active("nri-foo"))
+
+	err = h.Handle(context.Background(), commandapi.Command{Args: []byte(`{ "integration_name": "nri-foo", "payloads": 0 }`)}, false)
+	require.NoError(t, err)
+	assert.False(t, capture.Active("nri-foo"))
+}
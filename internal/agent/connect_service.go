@@ -19,6 +19,8 @@ type identityConnectService struct {
 	fingerprintHarvest fingerprint.Harvester
 	lastFingerprint    fingerprint.Fingerprint
 	client             identityapi.IdentityConnectClient
+	// tags assigned by the backend in the last connect or connect update
+	assignedTags map[string]string
 }
 
 // ErrEmptyEntityID is returned when the entityID is empty.
@@ -46,15 +48,16 @@ func (ic *identityConnectService) Connect() entity.Identity {
 
 		trace.Connect("connect request with fingerprint: %+v", f)
 
-		ids, retry, err := ic.client.Connect(f)
+		result, retry, err := ic.client.Connect(f)
 
-		if !ids.ID.IsEmpty() {
+		if ids := result.Identity; !ids.ID.IsEmpty() {
 			logger.
 				WithField("agent-id", ids.ID).
 				WithField("agent-guid", ids.GUID).
 				Infof("connect got id")
 			// save fingerprint for later (connect update)
 			ic.lastFingerprint = f
+			ic.setAssignedTags(result.Tags)
 			return ids
 		}
 
@@ -102,7 +105,7 @@ func (ic *identityConnectService) ConnectUpdate(agentIdn entity.Identity) (entit
 	var retryBO *backoff.Backoff
 	for {
 		trace.Connect("connect update request with fingerprint: %+v", f)
-		retry, entityIdn, err := ic.client.ConnectUpdate(agentIdn, f)
+		retry, result, err := ic.client.ConnectUpdate(agentIdn, f)
 		if retry.After > 0 {
 			logger.WithField("retryAfter", retry.After).Debug("Connect update retry requested.")
 			retryBO = nil
@@ -121,10 +124,24 @@ func (ic *identityConnectService) ConnectUpdate(agentIdn entity.Identity) (entit
 			time.Sleep(retryBOAfter)
 			continue
 		}
-		return entityIdn, nil
+		ic.setAssignedTags(result.Tags)
+		return result.Identity, nil
 	}
 }
 
+// AssignedTags returns the tags assigned by the backend to the agent entity in the last connect
+// or connect update, to be added to the submitted data.
+func (ic *identityConnectService) AssignedTags() map[string]string {
+	return ic.assignedTags
+}
+
+func (ic *identityConnectService) setAssignedTags(tags map[string]string) {
+	if len(tags) > 0 {
+		logger.WithField("tags", tags).Info("Backend assigned tags to the agent.")
+	}
+	ic.assignedTags = tags
+}
+
 // Disconnect is used to signal the backend that the agent will stop.
 func (ic *identityConnectService) Disconnect(agentID entity.ID, state identityapi.DisconnectReason) error {
 	logger.WithField("state", state).Info("calling disconnect")
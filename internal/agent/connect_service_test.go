@@ -19,16 +19,17 @@ var (
 )
 
 type MockIdentityConnectClient struct {
+	tags map[string]string
 }
 
-func (icc *MockIdentityConnectClient) Connect(fp fingerprint.Fingerprint) (entity.Identity, backendhttp.RetryPolicy, error) {
+func (icc *MockIdentityConnectClient) Connect(fp fingerprint.Fingerprint) (identityapi.ConnectResult, backendhttp.RetryPolicy, error) {
 	var retry backendhttp.RetryPolicy
-	return testEntityId, retry, nil
+	return identityapi.ConnectResult{Identity: testEntityId, Tags: icc.tags}, retry, nil
 }
 
-func (icc *MockIdentityConnectClient) ConnectUpdate(entityID entity.Identity, fp fingerprint.Fingerprint) (backendhttp.RetryPolicy, entity.Identity, error) {
+func (icc *MockIdentityConnectClient) ConnectUpdate(entityID entity.Identity, fp fingerprint.Fingerprint) (backendhttp.RetryPolicy, identityapi.ConnectResult, error) {
 	var retry backendhttp.RetryPolicy
-	return retry, testEntityId, nil
+	return retry, identityapi.ConnectResult{Identity: testEntityId, Tags: icc.tags}, nil
 }
 
 func (icc *MockIdentityConnectClient) Disconnect(entityID entity.ID, state identityapi.DisconnectReason) error {
@@ -77,3 +78,25 @@ func Test_ConnectUpdate_ReturnsSameDifferentIDForDifferentFingerprint(t *testing
 	assert.Equal(t, testEntityId, entityID)
 	assert.NotEqual(t, testEntityId, agentIdn)
 }
+
+func TestConnect_AssignedTags(t *testing.T) {
+	tags := map[string]string{"workload": "checkout"}
+	client := &MockIdentityConnectClient{tags: tags}
+	harvester := &fingerprint.MockHarvestor{}
+	service := NewIdentityConnectService(client, harvester)
+
+	service.Connect()
+	assert.Equal(t, tags, service.AssignedTags())
+
+	// tags are kept while the fingerprint doesn't change
+	client.tags = map[string]string{"workload": "payments"}
+	_, err := service.ConnectUpdate(testEntityId)
+	assert.NoError(t, err)
+	assert.Equal(t, tags, service.AssignedTags())
+
+	// and replaced by the ones of the connect update otherwise
+	service.lastFingerprint.Hostname = "someHostName"
+	_, err = service.ConnectUpdate(testEntityId)
+	assert.NoError(t, err)
+	assert.Equal(t, client.tags, service.AssignedTags())
+}
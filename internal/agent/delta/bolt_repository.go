@@ -0,0 +1,285 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package delta
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+const (
+	// BOLT_FILE is the database, placed in the data directory, of the bolt backed repository
+	BOLT_FILE = "inventory.db"
+	// boltOpenTimeout bounds the wait for the database lock, held by any other agent sharing the data directory
+	boltOpenTimeout = 5 * time.Second
+)
+
+var boltBucket = []byte("files")
+
+// notMigratedFolders are the data directory folders not handled by the Store, so they are left in the filesystem
+// when importing the files into a bolt repository.
+var notMigratedFolders = map[string]bool{
+	SAMPLING_REPO:               true,
+	lastSuccessSubmissionFolder: true,
+	lastEntityIDFolder:          true,
+}
+
+// boltRepository keeps the files in a single bbolt database, keyed by their slash separated path relative to
+// the data directory, so the store doesn't create thousands of small files.
+type boltRepository struct {
+	db      *bolt.DB
+	dataDir string
+}
+
+func newBoltRepository(dataDir string) (*boltRepository, error) {
+	db, err := bolt.Open(filepath.Join(dataDir, BOLT_FILE), DATA_FILE_MODE, &bolt.Options{Timeout: boltOpenTimeout})
+	if err != nil {
+		return nil, err
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(boltBucket)
+		return err
+	})
+	if err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	return &boltRepository{db: db, dataDir: dataDir}, nil
+}
+
+// key returns the database key of a path, which is empty for the data directory.
+func (r *boltRepository) key(path string) string {
+	rel, err := filepath.Rel(r.dataDir, path)
+	if err != nil || rel == "." {
+		return ""
+	}
+	return filepath.ToSlash(rel)
+}
+
+// prefix returns the prefix of the keys of the files under a directory.
+func (r *boltRepository) prefix(dir string) []byte {
+	key := r.key(dir)
+	if key == "" {
+		return nil
+	}
+	return []byte(key + "/")
+}
+
+// keysUnder returns the keys of the file and the files under the directory of the passed path.
+func (r *boltRepository) keysUnder(b *bolt.Bucket, path string) (keys [][]byte) {
+	if key := []byte(r.key(path)); len(key) > 0 && b.Get(key) != nil {
+		keys = append(keys, key)
+	}
+	prefix := r.prefix(path)
+	c := b.Cursor()
+	for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Next() {
+		keys = append(keys, append([]byte(nil), k...))
+	}
+	return keys
+}
+
+func (r *boltRepository) ReadFile(path string) (data []byte, err error) {
+	err = r.db.View(func(tx *bolt.Tx) error {
+		value := tx.Bucket(boltBucket).Get([]byte(r.key(path)))
+		if value == nil {
+			return &os.PathError{Op: "open", Path: path, Err: os.ErrNotExist}
+		}
+		// values are only valid during the transaction
+		data = append([]byte{}, value...)
+		return nil
+	})
+	return data, err
+}
+
+func (r *boltRepository) WriteFile(path string, data []byte) error {
+	return r.db.Update(func(tx *bolt.Tx) error {
+		// empty files are stored as empty, non nil, values
+		return tx.Bucket(boltBucket).Put([]byte(r.key(path)), append([]byte{}, data...))
+	})
+}
+
+func (r *boltRepository) AppendFile(path string, data []byte) error {
+	return r.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(boltBucket)
+		key := []byte(r.key(path))
+		value := append(append([]byte{}, b.Get(key)...), data...)
+		return b.Put(key, value)
+	})
+}
+
+func (r *boltRepository) Exists(path string) bool {
+	found := false
+	_ = r.db.View(func(tx *bolt.Tx) error {
+		found = len(r.keysUnder(tx.Bucket(boltBucket), path)) > 0
+		return nil
+	})
+	return found || r.key(path) == ""
+}
+
+func (r *boltRepository) Remove(path string) error {
+	return r.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(boltBucket)
+		key := []byte(r.key(path))
+		if b.Get(key) == nil {
+			return &os.PathError{Op: "remove", Path: path, Err: os.ErrNotExist}
+		}
+		return b.Delete(key)
+	})
+}
+
+func (r *boltRepository) RemoveAll(path string) error {
+	return r.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(boltBucket)
+		// deleting while iterating a cursor skips keys
+		for _, key := range r.keysUnder(b, path) {
+			if err := b.Delete(key); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// ReadDir returns the files and folders directly under the directory, sorted by name, where the folders are
+// the first path segment of the deeper keys.
+func (r *boltRepository) ReadDir(dir string) ([]os.FileInfo, error) {
+	entries := map[string]*boltFileInfo{}
+	err := r.db.View(func(tx *bolt.Tx) error {
+		prefix := r.prefix(dir)
+		c := tx.Bucket(boltBucket).Cursor()
+		for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+			name := string(k[len(prefix):])
+			if i := strings.Index(name, "/"); i >= 0 {
+				name = name[:i]
+				if _, ok := entries[name]; !ok {
+					entries[name] = &boltFileInfo{name: name, dir: true}
+				}
+				entries[name].size += int64(len(v))
+			} else {
+				entries[name] = &boltFileInfo{name: name, size: int64(len(v))}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 && r.key(dir) != "" {
+		return nil, &os.PathError{Op: "open", Path: dir, Err: os.ErrNotExist}
+	}
+
+	infos := make([]os.FileInfo, 0, len(entries))
+	for _, entry := range entries {
+		infos = append(infos, entry)
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name() < infos[j].Name() })
+	return infos, nil
+}
+
+func (r *boltRepository) Size(dir string) (size uint64, err error) {
+	err = r.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(boltBucket)
+		for _, key := range r.keysUnder(b, dir) {
+			size += uint64(len(b.Get(key)))
+		}
+		return nil
+	})
+	return size, err
+}
+
+func (r *boltRepository) Close() error {
+	return r.db.Close()
+}
+
+// importFiles moves into the database the files left in the data directory by a file backed Store, so
+// switching the backend doesn't require to submit again the whole inventory.
+func (r *boltRepository) importFiles() (imported int, err error) {
+	folders, err := ioutil.ReadDir(r.dataDir)
+	if err != nil {
+		return 0, err
+	}
+
+	var migrated []string
+	err = r.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(boltBucket)
+		for _, folder := range folders {
+			if !folder.IsDir() || notMigratedFolders[folder.Name()] {
+				continue
+			}
+			dir := filepath.Join(r.dataDir, folder.Name())
+			err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+				if err != nil || info.IsDir() {
+					return err
+				}
+				key := []byte(r.key(path))
+				// files left by an interrupted removal may be older than the imported ones
+				if b.Get(key) != nil {
+					return nil
+				}
+				data, err := ioutil.ReadFile(path)
+				if err != nil {
+					return err
+				}
+				imported++
+				return b.Put(key, data)
+			})
+			if err != nil {
+				return err
+			}
+			migrated = append(migrated, dir)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	for _, dir := range migrated {
+		if err = os.RemoveAll(dir); err != nil {
+			return imported, err
+		}
+	}
+	return imported, nil
+}
+
+// boltFileInfo describes the files and folders of a bolt repository.
+type boltFileInfo struct {
+	name string
+	size int64
+	dir  bool
+}
+
+func (i *boltFileInfo) Name() string {
+	return i.name
+}
+
+func (i *boltFileInfo) Size() int64 {
+	return i.size
+}
+
+func (i *boltFileInfo) Mode() os.FileMode {
+	if i.dir {
+		return os.ModeDir | DATA_DIR_MODE
+	}
+	return DATA_FILE_MODE
+}
+
+func (i *boltFileInfo) ModTime() time.Time {
+	return time.Time{}
+}
+
+func (i *boltFileInfo) IsDir() bool {
+	return i.dir
+}
+
+func (i *boltFileInfo) Sys() interface{} {
+	return nil
+}
@@ -0,0 +1,133 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package delta
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBoltRepository_Files(t *testing.T) {
+	dataDir, err := TempDeltaStoreDir()
+	require.NoError(t, err)
+	defer os.RemoveAll(dataDir)
+	repo, err := newBoltRepository(dataDir)
+	require.NoError(t, err)
+	defer repo.Close()
+
+	// GIVEN files in nested folders
+	pending := filepath.Join(dataDir, CACHE_DIR, "packages", "entity", "rpm.pending")
+	require.NoError(t, repo.AppendFile(pending, []byte(`{"id":1},`)))
+	require.NoError(t, repo.AppendFile(pending, []byte(`{"id":2},`)))
+	require.NoError(t, repo.WriteFile(filepath.Join(dataDir, CACHE_DIR, "packages", "entity", "rpm.json"), []byte(`{}`)))
+	require.NoError(t, repo.WriteFile(filepath.Join(dataDir, CACHE_DIR, CACHE_ID_FILE), []byte(`{}`)))
+
+	// THEN appended content is kept
+	content, err := repo.ReadFile(pending)
+	require.NoError(t, err)
+	assert.Equal(t, `{"id":1},{"id":2},`, string(content))
+
+	// AND folders are listed from the file paths
+	infos, err := repo.ReadDir(filepath.Join(dataDir, CACHE_DIR))
+	require.NoError(t, err)
+	require.Len(t, infos, 2)
+	assert.Equal(t, CACHE_ID_FILE, infos[0].Name())
+	assert.False(t, infos[0].IsDir())
+	assert.Equal(t, "packages", infos[1].Name())
+	assert.True(t, infos[1].IsDir())
+	assert.True(t, repo.Exists(filepath.Join(dataDir, CACHE_DIR, "packages", "entity")))
+
+	size, err := repo.Size(filepath.Join(dataDir, CACHE_DIR, "packages"))
+	require.NoError(t, err)
+	assert.Equal(t, uint64(20), size)
+
+	// WHEN a folder is removed
+	require.NoError(t, repo.RemoveAll(filepath.Join(dataDir, CACHE_DIR, "packages")))
+
+	// THEN its files don't exist anymore
+	_, err = repo.ReadFile(pending)
+	assert.True(t, os.IsNotExist(err))
+	_, err = repo.ReadDir(filepath.Join(dataDir, CACHE_DIR, "packages"))
+	assert.True(t, os.IsNotExist(err))
+	assert.True(t, repo.Exists(filepath.Join(dataDir, CACHE_DIR, CACHE_ID_FILE)))
+}
+
+func TestBoltStore_Deltas(t *testing.T) {
+	dataDir, err := TempDeltaStoreDir()
+	require.NoError(t, err)
+	defer os.RemoveAll(dataDir)
+	ds := NewBoltStore(dataDir, "localhost", maxInventorySize)
+	defer ds.Close()
+
+	// GIVEN the inventory of an entity
+	require.NoError(t, ds.SavePluginSource("entity", "packages", "rpm", map[string]interface{}{"bash": map[string]interface{}{"version": "4.4"}}))
+	require.NoError(t, ds.UpdatePluginsInventoryCache("entity"))
+
+	// WHEN the deltas are read
+	deltas, err := ds.ReadDeltas("entity")
+
+	// THEN they are read from the database
+	require.NoError(t, err)
+	require.Len(t, deltas, 1)
+	require.Len(t, deltas[0], 1)
+	assert.Equal(t, "packages/rpm", deltas[0][0].Source)
+	assert.True(t, deltas[0][0].FullDiff)
+	_, err = os.Stat(ds.SourceFilePath(&PluginInfo{Plugin: "packages", FileName: "rpm.json"}, "entity"))
+	assert.True(t, os.IsNotExist(err))
+
+	// AND the sent deltas are archived
+	ds.UpdateState("entity", deltas[0], nil)
+	deltas, err = ds.ReadDeltas("entity")
+	require.NoError(t, err)
+	assert.Empty(t, deltas)
+
+	// AND the entity can be removed
+	entities, err := ds.ScanEntityFolders()
+	require.NoError(t, err)
+	assert.Contains(t, entities, "entity")
+	require.NoError(t, ds.RemoveEntity("entity"))
+	entities, err = ds.ScanEntityFolders()
+	require.NoError(t, err)
+	assert.NotContains(t, entities, "entity")
+}
+
+func TestNewBoltStore_ImportsFiles(t *testing.T) {
+	dataDir, err := TempDeltaStoreDir()
+	require.NoError(t, err)
+	defer os.RemoveAll(dataDir)
+
+	// GIVEN pending deltas of a file backed store
+	fileStore := NewStore(dataDir, "localhost", maxInventorySize)
+	require.NoError(t, fileStore.SavePluginSource("entity", "packages", "rpm", map[string]interface{}{"bash": map[string]interface{}{"version": "4.4"}}))
+	require.NoError(t, fileStore.UpdatePluginsInventoryCache("entity"))
+	expected, err := fileStore.ReadDeltas("entity")
+	require.NoError(t, err)
+	require.NoError(t, os.MkdirAll(filepath.Join(dataDir, lastSuccessSubmissionFolder), DATA_DIR_MODE))
+
+	// WHEN the store is created with the bolt backend
+	ds := NewBoltStore(dataDir, "localhost", maxInventorySize)
+	defer ds.Close()
+
+	// THEN the pending deltas are kept
+	deltas, err := ds.ReadDeltas("entity")
+	require.NoError(t, err)
+	assert.Equal(t, expected, deltas)
+
+	// AND the imported files are removed, keeping the ones not handled by the store
+	files, err := ioutil.ReadDir(dataDir)
+	require.NoError(t, err)
+	var names []string
+	for _, file := range files {
+		names = append(names, file.Name())
+	}
+	assert.ElementsMatch(t, []string{CACHE_DIR, SAMPLING_REPO, BOLT_FILE, lastSuccessSubmissionFolder}, names)
+	empty, err := ioutil.ReadDir(filepath.Join(dataDir, CACHE_DIR))
+	require.NoError(t, err)
+	assert.Empty(t, empty)
+}
@@ -0,0 +1,93 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package delta
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+)
+
+// gzipMagic starts any gzip stream, while the stored JSON documents and journals start with a bracket.
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// compressedRepository gzips the files written into the wrapped repository, unless its level is
+// gzip.NoCompression. Files are read whether they are compressed or not, so the level can be changed
+// between agent restarts.
+type compressedRepository struct {
+	repository
+	level int
+}
+
+func isCompressed(data []byte) bool {
+	return bytes.HasPrefix(data, gzipMagic)
+}
+
+func (r *compressedRepository) compress(data []byte) ([]byte, error) {
+	if r.level == gzip.NoCompression {
+		return data, nil
+	}
+	var buf bytes.Buffer
+	w, err := gzip.NewWriterLevel(&buf, r.level)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = w.Write(data); err != nil {
+		return nil, err
+	}
+	if err = w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// decompress reads all the gzip members of the data, as each journal append writes a new one.
+func decompress(data []byte) ([]byte, error) {
+	if !isCompressed(data) {
+		return data, nil
+	}
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return ioutil.ReadAll(r)
+}
+
+func (r *compressedRepository) ReadFile(path string) ([]byte, error) {
+	data, err := r.repository.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return decompress(data)
+}
+
+func (r *compressedRepository) WriteFile(path string, data []byte) error {
+	compressed, err := r.compress(data)
+	if err != nil {
+		return err
+	}
+	return r.repository.WriteFile(path, compressed)
+}
+
+func (r *compressedRepository) AppendFile(path string, data []byte) error {
+	if len(data) == 0 {
+		return r.repository.AppendFile(path, data)
+	}
+
+	// files written with a different compression are rewritten, as plain and gzip content can't be mixed
+	current, err := r.repository.ReadFile(path)
+	if err == nil && len(current) > 0 && isCompressed(current) != (r.level != gzip.NoCompression) {
+		if current, err = decompress(current); err != nil {
+			return err
+		}
+		return r.WriteFile(path, append(current, data...))
+	}
+
+	compressed, err := r.compress(data)
+	if err != nil {
+		return err
+	}
+	return r.repository.AppendFile(path, compressed)
+}
@@ -0,0 +1,80 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package delta
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompressedRepository_AppendFile(t *testing.T) {
+	dir, err := TempDeltaStoreDir()
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	journal := filepath.Join(dir, "packages", "entity", "rpm.pending")
+
+	// GIVEN a journal written without compression
+	plain := &compressedRepository{repository: fileRepository{}, level: gzip.NoCompression}
+	require.NoError(t, plain.AppendFile(journal, []byte(`{"id":1},`)))
+
+	// WHEN deltas are appended with compression
+	compressed := &compressedRepository{repository: fileRepository{}, level: gzip.BestSpeed}
+	require.NoError(t, compressed.AppendFile(journal, []byte(`{"id":2},`)))
+	require.NoError(t, compressed.AppendFile(journal, []byte(`{"id":3},`)))
+
+	// THEN the journal is stored compressed
+	raw, err := ioutil.ReadFile(journal)
+	require.NoError(t, err)
+	assert.True(t, isCompressed(raw))
+
+	// AND all the deltas are read, also after disabling the compression
+	content, err := compressed.ReadFile(journal)
+	require.NoError(t, err)
+	assert.Equal(t, `{"id":1},{"id":2},{"id":3},`, string(content))
+
+	require.NoError(t, plain.AppendFile(journal, []byte(`{"id":4},`)))
+	raw, err = ioutil.ReadFile(journal)
+	require.NoError(t, err)
+	assert.Equal(t, `{"id":1},{"id":2},{"id":3},{"id":4},`, string(raw))
+}
+
+func TestStore_CompressedFiles(t *testing.T) {
+	dataDir, err := TempDeltaStoreDir()
+	require.NoError(t, err)
+	defer os.RemoveAll(dataDir)
+	source := map[string]interface{}{"bash": map[string]interface{}{"version": "4.4"}}
+
+	// GIVEN a store whose deltas were stored without compression
+	ds := NewStore(dataDir, "localhost", maxInventorySize)
+	require.NoError(t, ds.SavePluginSource("entity", "packages", "rpm", source))
+	require.NoError(t, ds.UpdatePluginsInventoryCache("entity"))
+
+	// WHEN the compression is enabled and the inventory changes
+	ds.SetCompressionLevel(gzip.BestCompression)
+	source["zsh"] = map[string]interface{}{"version": "5.8"}
+	require.NoError(t, ds.SavePluginSource("entity", "packages", "rpm", source))
+	require.NoError(t, ds.UpdatePluginsInventoryCache("entity"))
+
+	// THEN the files are compressed
+	plugin := &PluginInfo{Source: "packages/rpm", Plugin: "packages", FileName: "rpm.json"}
+	for _, file := range []string{ds.SourceFilePath(plugin, "entity"), ds.DeltaFilePath(plugin, "entity")} {
+		raw, err := ioutil.ReadFile(file)
+		require.NoError(t, err)
+		assert.True(t, isCompressed(raw), file)
+	}
+
+	// AND both deltas are read
+	deltas, err := ds.ReadDeltas("entity")
+	require.NoError(t, err)
+	require.Len(t, deltas, 1)
+	require.Len(t, deltas[0], 2)
+	assert.True(t, deltas[0][0].FullDiff)
+	assert.Equal(t, map[string]interface{}{"zsh": map[string]interface{}{"version": "5.8"}}, deltas[0][1].Diff)
+}
@@ -0,0 +1,115 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package delta
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+// NoiseRule matches inventory attributes whose changes alone don't generate a delta, like timestamps or counters
+// embedded in configuration files. When other attributes of the plugin change, the delta includes the matched
+// ones too.
+type NoiseRule struct {
+	plugin string
+	path   []string
+	value  *regexp.Regexp
+}
+
+// NewNoiseRule returns a rule for the plugins matching the glob pattern (ie: "config/*"). The attribute path is
+// made of dot-separated glob patterns (ie: "*.mtime"), each one matching the keys of a level of the plugin
+// inventory. The optional value regular expression restricts the rule to the attributes whose values match it.
+func NewNoiseRule(plugin, attributePath, value string) (NoiseRule, error) {
+	if plugin == "" || attributePath == "" {
+		return NoiseRule{}, fmt.Errorf("noise rules require a plugin and an attribute path")
+	}
+	if _, err := path.Match(plugin, ""); err != nil {
+		return NoiseRule{}, fmt.Errorf("invalid plugin pattern %q: %s", plugin, err)
+	}
+	r := NoiseRule{plugin: plugin, path: strings.Split(attributePath, ".")}
+	for _, segment := range r.path {
+		if _, err := path.Match(segment, ""); err != nil {
+			return NoiseRule{}, fmt.Errorf("invalid attribute path %q: %s", attributePath, err)
+		}
+	}
+	if value != "" {
+		var err error
+		if r.value, err = regexp.Compile(value); err != nil {
+			return NoiseRule{}, fmt.Errorf("invalid value expression %q: %s", value, err)
+		}
+	}
+	return r, nil
+}
+
+func (r NoiseRule) appliesTo(pluginID string) bool {
+	matched, _ := path.Match(r.plugin, pluginID)
+	return matched
+}
+
+// mask removes the attributes matching the rule from the decoded inventory.
+func (r NoiseRule) mask(node interface{}, segments []string) {
+	switch n := node.(type) {
+	case map[string]interface{}:
+		for key, child := range n {
+			if matched, _ := path.Match(segments[0], key); !matched {
+				continue
+			}
+			if len(segments) > 1 {
+				r.mask(child, segments[1:])
+			} else if r.value == nil || r.value.MatchString(valueString(child)) {
+				delete(n, key)
+			}
+		}
+	case []interface{}:
+		for _, item := range n {
+			r.mask(item, segments)
+		}
+	}
+}
+
+func valueString(value interface{}) string {
+	if s, ok := value.(string); ok {
+		return s
+	}
+	return fmt.Sprint(value)
+}
+
+// onlyNoise returns whether the differences between two versions of a plugin inventory are only in attributes
+// matched by the noise rules.
+func (s *Store) onlyNoise(pluginID string, previous, current []byte) bool {
+	var rules []NoiseRule
+	for _, rule := range s.noiseRules {
+		if rule.appliesTo(pluginID) {
+			rules = append(rules, rule)
+		}
+	}
+	if len(rules) == 0 {
+		return false
+	}
+
+	decode := func(data []byte) (inventory interface{}, err error) {
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.UseNumber()
+		err = decoder.Decode(&inventory)
+		return inventory, err
+	}
+	previousInv, err := decode(previous)
+	if err != nil {
+		return false
+	}
+	currentInv, err := decode(current)
+	if err != nil {
+		return false
+	}
+	for _, rule := range rules {
+		rule.mask(previousInv, rule.path)
+		rule.mask(currentInv, rule.path)
+	}
+	return reflect.DeepEqual(previousInv, currentInv)
+}
@@ -0,0 +1,105 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package delta
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewNoiseRule_Invalid(t *testing.T) {
+	_, err := NewNoiseRule("", "*.mtime", "")
+	assert.Error(t, err)
+	_, err = NewNoiseRule("config/*", "", "")
+	assert.Error(t, err)
+	_, err = NewNoiseRule("config/[", "*.mtime", "")
+	assert.Error(t, err)
+	_, err = NewNoiseRule("config/*", "*.mtime", "(")
+	assert.Error(t, err)
+}
+
+func TestStore_NoiseRules(t *testing.T) {
+	dataDir, err := TempDeltaStoreDir()
+	require.NoError(t, err)
+	defer os.RemoveAll(dataDir)
+
+	// GIVEN a store ignoring the changes of the mtime attributes and of numeric generation values
+	ds := NewStore(dataDir, "localhost", maxInventorySize)
+	mtime, err := NewNoiseRule("config/*", "*.mtime", "")
+	require.NoError(t, err)
+	generation, err := NewNoiseRule("config/*", "*.generation", `^\d+$`)
+	require.NoError(t, err)
+	ds.SetNoiseRules([]NoiseRule{mtime, generation})
+
+	save := func(source map[string]interface{}) []string {
+		require.NoError(t, ds.SavePluginSource("entity", "config", "app", source))
+		require.NoError(t, ds.UpdatePluginsInventoryCache("entity"))
+		deltas, err := ds.ReadDeltas("entity")
+		require.NoError(t, err)
+		var diffs []string
+		for _, block := range deltas {
+			for _, d := range block {
+				diffs = append(diffs, toJSON(t, d.Diff))
+			}
+		}
+		return diffs
+	}
+	item := func(value string, mtime int, generation interface{}) map[string]interface{} {
+		return map[string]interface{}{"app.conf": map[string]interface{}{"value": value, "mtime": mtime, "generation": generation}}
+	}
+	require.Len(t, save(item("a", 1, 1)), 1)
+
+	// WHEN only the ignored attributes change
+	diffs := save(item("a", 2, 2))
+
+	// THEN no delta is generated
+	assert.Len(t, diffs, 1)
+
+	// WHEN an ignored attribute changes to a value not matching the rule
+	diffs = save(item("a", 3, "broken"))
+
+	// THEN the delta includes all the changed attributes
+	require.Len(t, diffs, 2)
+	assert.JSONEq(t, `{"app.conf":{"generation":"broken","mtime":3}}`, diffs[1])
+
+	// AND changes in other attributes also include the ignored ones
+	diffs = save(item("b", 4, "broken"))
+	require.Len(t, diffs, 3)
+	assert.JSONEq(t, `{"app.conf":{"value":"b","mtime":4}}`, diffs[2])
+}
+
+func TestStore_NoiseRulesOtherPlugins(t *testing.T) {
+	dataDir, err := TempDeltaStoreDir()
+	require.NoError(t, err)
+	defer os.RemoveAll(dataDir)
+
+	// GIVEN a noise rule for other plugins
+	ds := NewStore(dataDir, "localhost", maxInventorySize)
+	rule, err := NewNoiseRule("config/*", "*.mtime", "")
+	require.NoError(t, err)
+	ds.SetNoiseRules([]NoiseRule{rule})
+
+	// WHEN the attribute changes
+	for _, mtime := range []int{1, 2} {
+		source := map[string]interface{}{"bash": map[string]interface{}{"mtime": mtime}}
+		require.NoError(t, ds.SavePluginSource("entity", "packages", "rpm", source))
+		require.NoError(t, ds.UpdatePluginsInventoryCache("entity"))
+	}
+
+	// THEN its changes are reported
+	deltas, err := ds.ReadDeltas("entity")
+	require.NoError(t, err)
+	require.Len(t, deltas, 1)
+	assert.Len(t, deltas[0], 2)
+}
+
+func toJSON(t *testing.T, v interface{}) string {
+	b, err := json.Marshal(v)
+	require.NoError(t, err)
+	return string(b)
+}
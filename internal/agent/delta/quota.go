@@ -0,0 +1,76 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package delta
+
+import (
+	"path/filepath"
+	"sort"
+)
+
+// EvictEntities removes the stored inventory of the least recently saved entities until the size of the data
+// directory is not larger than maxSize, returning the folders of the evicted entities. The inventory of the agent
+// entity is never evicted. Entities saved by previous agent executions are evicted first.
+func (s *Store) EvictEntities(maxSize uint64) (evicted []string, err error) {
+	size, err := s.repo.Size(s.DataDir)
+	if err != nil || size <= maxSize {
+		return nil, err
+	}
+
+	entities, err := s.ScanEntityFolders()
+	if err != nil && entities == nil {
+		return nil, err
+	}
+	folders := make([]string, 0, len(entities))
+	for folder := range entities {
+		if folder != localEntityFolder {
+			folders = append(folders, folder)
+		}
+	}
+	sort.Slice(folders, func(i, j int) bool {
+		ti, tj := s.lastSaved[folders[i]], s.lastSaved[folders[j]]
+		if ti.Equal(tj) {
+			return folders[i] < folders[j]
+		}
+		return ti.Before(tj)
+	})
+
+	for _, folder := range folders {
+		if size <= maxSize {
+			break
+		}
+		entitySize := s.entityFolderSize(folder)
+		if err = s.RemoveEntityFolders(folder); err != nil {
+			return evicted, err
+		}
+		evicted = append(evicted, folder)
+		if entitySize > size {
+			entitySize = size
+		}
+		size -= entitySize
+	}
+	return evicted, nil
+}
+
+// entityFolderSize returns the size of the inventory sources, caches and deltas of an entity.
+func (s *Store) entityFolderSize(entityFolder string) (size uint64) {
+	for _, dir := range []string{s.DataDir, s.CacheDir} {
+		plugins, err := s.repo.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, plugin := range plugins {
+			if !plugin.IsDir() || nonEntityFolders[plugin.Name()] {
+				continue
+			}
+			path := filepath.Join(dir, plugin.Name(), entityFolder)
+			if !s.repo.Exists(path) {
+				continue
+			}
+			if pluginSize, err := s.repo.Size(path); err == nil {
+				size += pluginSize
+			}
+		}
+	}
+	return size
+}
@@ -0,0 +1,51 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package delta
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStore_EvictEntities(t *testing.T) {
+	dataDir, err := TempDeltaStoreDir()
+	require.NoError(t, err)
+	defer os.RemoveAll(dataDir)
+	ds := NewStore(dataDir, "localhost", maxInventorySize)
+	source := map[string]interface{}{"item": map[string]interface{}{"value": strings.Repeat("x", 1000)}}
+
+	// GIVEN the inventory of the agent and of three entities, one of them saved by a previous execution
+	require.NoError(t, ds.SavePluginSource("localhost", "packages", "rpm", source))
+	for _, entityKey := range []string{"previous", "oldest", "newest"} {
+		require.NoError(t, ds.SavePluginSource(entityKey, "packages", "rpm", source))
+		require.NoError(t, ds.UpdatePluginsInventoryCache(entityKey))
+	}
+	delete(ds.lastSaved, "previous")
+	ds.lastSaved["oldest"] = ds.lastSaved["newest"].Add(-time.Minute)
+	size, err := ds.StorageSize(dataDir)
+	require.NoError(t, err)
+
+	// WHEN the data directory exceeds its quota by less than two entities
+	evicted, err := ds.EvictEntities(size - 4000)
+
+	// THEN the entities not saved by this execution and the least recently saved are evicted
+	require.NoError(t, err)
+	assert.Equal(t, []string{"previous", "oldest"}, evicted)
+	entities, err := ds.ScanEntityFolders()
+	require.NoError(t, err)
+	assert.Len(t, entities, 2)
+	assert.Contains(t, entities, localEntityFolder)
+	assert.Contains(t, entities, "newest")
+
+	// AND the agent inventory is never evicted
+	evicted, err = ds.EvictEntities(0)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"newest"}, evicted)
+	assert.True(t, ds.repo.Exists(ds.PluginDirPath("packages", "localhost")))
+}
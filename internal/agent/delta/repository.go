@@ -0,0 +1,90 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package delta
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/newrelic/infrastructure-agent/pkg/disk"
+)
+
+// repository persists the inventory sources, caches and delta journals of the Store, which are addressed by
+// their file path within the data directory.
+type repository interface {
+	// ReadFile returns an error satisfying os.IsNotExist when the file doesn't exist.
+	ReadFile(path string) ([]byte, error)
+	WriteFile(path string, data []byte) error
+	AppendFile(path string, data []byte) error
+	Exists(path string) bool
+	Remove(path string) error
+	RemoveAll(path string) error
+	ReadDir(dir string) ([]os.FileInfo, error)
+	// Size returns the size in bytes of all the files under the directory.
+	Size(dir string) (uint64, error)
+	Close() error
+}
+
+// fileRepository stores each of the files in the filesystem.
+type fileRepository struct{}
+
+func (fileRepository) ReadFile(path string) ([]byte, error) {
+	return ioutil.ReadFile(path)
+}
+
+func (fileRepository) WriteFile(path string, data []byte) error {
+	if err := disk.MkdirAll(filepath.Dir(path), DATA_DIR_MODE); err != nil {
+		return err
+	}
+	return disk.WriteFile(path, data, DATA_FILE_MODE)
+}
+
+func (fileRepository) AppendFile(path string, data []byte) error {
+	if err := disk.MkdirAll(filepath.Dir(path), DATA_DIR_MODE); err != nil {
+		return err
+	}
+	f, err := disk.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, DATA_FILE_MODE)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.Write(data)
+	return err
+}
+
+func (fileRepository) Exists(path string) bool {
+	return exists(path)
+}
+
+func (fileRepository) Remove(path string) error {
+	return os.Remove(path)
+}
+
+func (fileRepository) RemoveAll(path string) error {
+	return os.RemoveAll(path)
+}
+
+func (fileRepository) ReadDir(dir string) ([]os.FileInfo, error) {
+	return ioutil.ReadDir(dir)
+}
+
+func (fileRepository) Size(dir string) (uint64, error) {
+	var size int64
+	err := filepath.Walk(dir, func(_ string, info os.FileInfo, err error) error {
+		// if err is not nil, info will be nil and makes agent panic
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			size += info.Size()
+		}
+		return err
+	})
+	return uint64(size), err
+}
+
+func (fileRepository) Close() error {
+	return nil
+}
@@ -5,9 +5,9 @@ package delta
 
 import (
 	"bytes"
+	"compress/gzip"
 	"encoding/json"
 	"fmt"
-	"io/ioutil"
 	"os"
 	"path/filepath"
 	"regexp"
@@ -102,10 +102,44 @@ type Store struct {
 	plugins pluginSource2Info
 	// stores time of last success submission of inventory to backend
 	lastSuccessSubmission time.Time
+	// repo persists the inventory files
+	repo *compressedRepository
+	// lastSaved holds when the inventory of each entity folder was last saved, to evict the least recent ones
+	lastSaved map[string]time.Time
+	// noiseRules match the attributes whose changes alone don't generate deltas
+	noiseRules []NoiseRule
 }
 
 // NewStore creates a new Store and returns a pointer to it. If maxInventorySize <= 0, the inventory splitting is disabled
 func NewStore(dataDir string, defaultEntityKey string, maxInventorySize int) *Store {
+	return newStore(dataDir, defaultEntityKey, maxInventorySize, fileRepository{})
+}
+
+// NewBoltStore creates a new Store that keeps its files in a bbolt database within the data directory, instead of
+// a file per plugin and entity. The files left by a Store created with NewStore are imported into the database.
+func NewBoltStore(dataDir string, defaultEntityKey string, maxInventorySize int) *Store {
+	if err := disk.MkdirAll(dataDir, DATA_DIR_MODE); err != nil {
+		slog.WithError(err).Error("can't create data directory")
+		panic(err)
+	}
+
+	repo, err := newBoltRepository(dataDir)
+	if err != nil {
+		slog.WithError(err).WithField("file", filepath.Join(dataDir, BOLT_FILE)).Error("can't open inventory database")
+		panic(err)
+	}
+
+	imported, err := repo.importFiles()
+	if err != nil {
+		slog.WithError(err).Warn("can't import inventory files into the database")
+	} else if imported > 0 {
+		slog.WithField("files", imported).Info("Inventory files imported into the database.")
+	}
+
+	return newStore(dataDir, defaultEntityKey, maxInventorySize, repo)
+}
+
+func newStore(dataDir string, defaultEntityKey string, maxInventorySize int, repo repository) *Store {
 	if defaultEntityKey == "" {
 		slog.Error("creating delta store: default entity ID can't be empty")
 		panic("default entity ID can't be empty")
@@ -117,6 +151,8 @@ func NewStore(dataDir string, defaultEntityKey string, maxInventorySize int) *St
 		maxInventorySize: maxInventorySize,
 		defaultEntityKey: defaultEntityKey,
 		plugins:          make(pluginSource2Info),
+		lastSaved:        make(map[string]time.Time),
+		repo:             &compressedRepository{repository: repo, level: gzip.NoCompression},
 	}
 
 	// Nice2Have: remove side effects from constructor
@@ -129,7 +165,7 @@ func NewStore(dataDir string, defaultEntityKey string, maxInventorySize int) *St
 	cachedDeltaPath := filepath.Join(d.CacheDir, CACHE_ID_FILE)
 	if err := d.readPluginIDMap(cachedDeltaPath); err != nil {
 		slog.WithError(err).WithField("file", cachedDeltaPath).Error("can't initialize plugin-id map")
-		err = os.Remove(cachedDeltaPath)
+		err = d.repo.Remove(cachedDeltaPath)
 		if err != nil {
 			panic(err)
 		}
@@ -138,6 +174,22 @@ func NewStore(dataDir string, defaultEntityKey string, maxInventorySize int) *St
 	return d
 }
 
+// SetCompressionLevel sets the gzip compression level of the inventory files written from now on. Files are not
+// compressed with gzip.NoCompression, which is the default, and are read whether they are compressed or not.
+func (s *Store) SetCompressionLevel(level int) {
+	s.repo.level = level
+}
+
+// SetNoiseRules sets the rules of the inventory attributes whose changes alone don't generate deltas.
+func (s *Store) SetNoiseRules(rules []NoiseRule) {
+	s.noiseRules = rules
+}
+
+// Close releases the resources held by the storage backend.
+func (s *Store) Close() error {
+	return s.repo.Close()
+}
+
 func (s *Store) createDataStore() (err error) {
 	if err = disk.MkdirAll(s.DataDir, DATA_DIR_MODE); err != nil {
 		return fmt.Errorf("can't create data directory: %s err: %s", s.DataDir, err)
@@ -169,12 +221,6 @@ func (s *Store) DeltaFilePath(pluginItem *PluginInfo, entityKey string) string {
 	return fmt.Sprintf("%s%s", strings.TrimSuffix(file, filepath.Ext(file)), UNSENT_DELTA_JOURNAL_EXT)
 }
 
-func (s *Store) cachedDirPath(pluginItem *PluginInfo, entityKey string) string {
-	return filepath.Join(s.CacheDir,
-		pluginItem.Plugin,
-		s.entityFolder(entityKey))
-}
-
 func (s *Store) cachedFilePath(pluginItem *PluginInfo, entityKey string) string {
 	return filepath.Join(s.CacheDir,
 		pluginItem.Plugin,
@@ -200,9 +246,9 @@ func (s *Store) clearPluginDeltaStore(pluginItem *PluginInfo, entityKey string)
 	archiveFilePath := s.archiveFilePath(pluginItem, entityKey)
 	helpers.DebugStackf("Clearing delta store for plugin %s and entity %s: %s, %s, %s",
 		pluginItem.Source, entityKey, cachedFilePath, deltaFilePath, archiveFilePath)
-	_ = os.Remove(cachedFilePath)
-	_ = os.Remove(deltaFilePath)
-	_ = os.Remove(archiveFilePath)
+	_ = s.repo.Remove(cachedFilePath)
+	_ = s.repo.Remove(deltaFilePath)
+	_ = s.repo.Remove(archiveFilePath)
 	return
 }
 
@@ -229,7 +275,7 @@ func (s *Store) compactCacheStorage(entityKey string, threshold uint64) (err err
 			// Now for the active ones, remove their archives
 			for _, p := range activePlugins {
 				archiveFilePath := s.archiveFilePath(p, entityKey)
-				_ = os.Remove(archiveFilePath)
+				_ = s.repo.Remove(archiveFilePath)
 			}
 
 		}
@@ -267,18 +313,7 @@ func (s *Store) CompactStorage(entityKey string, threshold uint64) (err error) {
 
 // StorageSize returns the size used in bytes of all the loose objects in the cache, non-inclusive of dirs
 func (s *Store) StorageSize(path string) (uint64, error) {
-	var size int64
-	err := filepath.Walk(path, func(_ string, info os.FileInfo, err error) error {
-		// if err is not nil, info will be nil and makes agent panic
-		if err != nil {
-			return err
-		}
-		if !info.IsDir() {
-			size += info.Size()
-		}
-		return err
-	})
-	return uint64(size), err
+	return s.repo.Size(path)
 }
 
 func (s *Store) archivePlugin(pluginItem *PluginInfo, entityKey string) (err error) {
@@ -308,12 +343,12 @@ func (s *Store) archivePlugin(pluginItem *PluginInfo, entityKey string) (err err
 		}
 	}
 
-	err = s.rewriteDeltas(s.archiveFilePath(pluginItem, entityKey), os.O_CREATE|os.O_APPEND|os.O_WRONLY, archiveDeltas)
+	err = s.rewriteDeltas(s.archiveFilePath(pluginItem, entityKey), true, archiveDeltas)
 	if err != nil {
 		return
 	}
 
-	return s.rewriteDeltas(s.DeltaFilePath(pluginItem, entityKey), os.O_CREATE|os.O_TRUNC|os.O_WRONLY, keepDeltas)
+	return s.rewriteDeltas(s.DeltaFilePath(pluginItem, entityKey), false, keepDeltas)
 }
 
 // ResetAllDeltas clears the plugin delta store for all the existing plugins
@@ -434,12 +469,12 @@ func (s *Store) SaveState() (err error) {
 }
 
 func (s *Store) readPluginIDMap(cachedDeltaPath string) (err error) {
-	ok := exists(cachedDeltaPath)
+	ok := s.repo.Exists(cachedDeltaPath)
 	if !ok {
 		return nil
 	}
 
-	if deltaIDBytes, err := ioutil.ReadFile(cachedDeltaPath); err == nil {
+	if deltaIDBytes, err := s.repo.ReadFile(cachedDeltaPath); err == nil {
 		return s.loadPluginIDMap(deltaIDBytes)
 	}
 
@@ -473,7 +508,7 @@ func (s *Store) writePluginIDMap() (err error) {
 		slog.WithError(err).Error("can't marshal id map?")
 	} else {
 		cachedDeltaPath := filepath.Join(s.CacheDir, CACHE_ID_FILE)
-		if err = disk.WriteFile(cachedDeltaPath, buf, DATA_FILE_MODE); err != nil {
+		if err = s.repo.WriteFile(cachedDeltaPath, buf); err != nil {
 			slog.WithError(err).WithField("path", cachedDeltaPath).Error("unable to write delta cache")
 		}
 	}
@@ -481,7 +516,7 @@ func (s *Store) writePluginIDMap() (err error) {
 }
 
 func (s *Store) collectPluginFiles(dir string, entityKey string, fileFilterRE *regexp.Regexp) (pluginList []*PluginInfo, err error) {
-	pluginsFileInfo, err := ioutil.ReadDir(dir)
+	pluginsFileInfo, err := s.repo.ReadDir(dir)
 	if err != nil {
 		return
 	}
@@ -492,7 +527,7 @@ func (s *Store) collectPluginFiles(dir string, entityKey string, fileFilterRE *r
 	for _, dirInfo := range pluginsFileInfo {
 		if dirInfo != nil && dirInfo.IsDir() && !nonEntityFolders[dirInfo.Name()] {
 			// Look inside each "plugin" directory to find the plugin's data files
-			filesInfo, err := ioutil.ReadDir(filepath.Join(dir, dirInfo.Name(), entityFolder))
+			filesInfo, err := s.repo.ReadDir(filepath.Join(dir, dirInfo.Name(), entityFolder))
 			if err != nil {
 				// There is no such entity for the given plugin, so continuing
 				continue
@@ -536,57 +571,36 @@ func (s *Store) getDeltaFromJSON(previous, current []byte) (delta []byte, err er
 	return jsonpatch.CreateMergePatch(previous, current)
 }
 
-func (s *Store) rewriteDeltas(deltaFilePath string, flag int, deltas []*inventoryapi.RawDelta) (err error) {
-	f, err := disk.OpenFile(deltaFilePath, flag, DATA_FILE_MODE)
-	if err != nil {
-		slog.WithField("path", deltaFilePath).WithError(err).Error("can't open delta journal file")
-		return
-	}
-	defer f.Close()
-
+// rewriteDeltas appends the deltas to the journal, or replaces its content with them, when appendDeltas is false.
+func (s *Store) rewriteDeltas(deltaFilePath string, appendDeltas bool, deltas []*inventoryapi.RawDelta) (err error) {
+	var entry []byte
 	if len(deltas) > 0 {
 		var deltaBuf []byte
-		if deltaBuf, err = json.Marshal(deltas); err == nil {
-			// strip the square brackets, write as one blob
-			deltaBuf = bytes.Trim(deltaBuf, "[]")
-			err = s.writeDelta(f, deltaBuf)
+		if deltaBuf, err = json.Marshal(deltas); err != nil {
+			return
 		}
+		// strip the square brackets, write as one blob
+		entry = journalEntry(bytes.Trim(deltaBuf, "[]"))
 	}
-	return
-}
 
-func (s *Store) writeDelta(f *os.File, deltaBuf []byte) (err error) {
-	if _, err = f.Write(deltaBuf); err != nil {
-		slog.WithError(err).Error("can't write journal entry")
-		return
+	if appendDeltas {
+		err = s.repo.AppendFile(deltaFilePath, entry)
+	} else {
+		err = s.repo.WriteFile(deltaFilePath, entry)
 	}
-	if _, err = f.Write([]byte{','}); err != nil {
-		slog.WithError(err).Error("can't write journal entry terminator")
+	if err != nil {
+		slog.WithField("path", deltaFilePath).WithError(err).Error("can't write delta journal file")
 	}
 	return
 }
 
-func (s *Store) storeDelta(pluginItem *PluginInfo, entityKey string, d delta) (err error) {
-	// FS mgmt
-	dir := s.cachedDirPath(pluginItem, entityKey)
-	if err = disk.MkdirAll(dir, DATA_DIR_MODE); err != nil {
-		slog.WithFields(logrus.Fields{
-			"path":   dir,
-			"plugin": pluginItem.ID(),
-		}).WithError(err).Error("could not create cache directory")
-		return
-	}
+// journalEntry terminates the delta buffer to be appended to a journal.
+func journalEntry(deltaBuf []byte) []byte {
+	return append(deltaBuf, ',')
+}
 
+func (s *Store) storeDelta(pluginItem *PluginInfo, entityKey string, d delta) (err error) {
 	file := s.DeltaFilePath(pluginItem, entityKey)
-	f, err := disk.OpenFile(file, os.O_CREATE|os.O_APPEND|os.O_WRONLY, DATA_FILE_MODE)
-	if err != nil {
-		slog.WithFields(logrus.Fields{
-			"path":   file,
-			"plugin": pluginItem.ID(),
-		}).WithError(err).Error("can't open delta journal file")
-		return
-	}
-	defer f.Close()
 
 	// format raw diff
 	var diff map[string]interface{}
@@ -609,8 +623,14 @@ func (s *Store) storeDelta(pluginItem *PluginInfo, entityKey string, d delta) (e
 		FullDiff:  d.full,
 	}
 	var deltaBuf []byte
-	if deltaBuf, err = json.Marshal(dRaw); err == nil {
-		err = s.writeDelta(f, deltaBuf)
+	if deltaBuf, err = json.Marshal(dRaw); err != nil {
+		return
+	}
+	if err = s.repo.AppendFile(file, journalEntry(deltaBuf)); err != nil {
+		slog.WithFields(logrus.Fields{
+			"path":   file,
+			"plugin": pluginItem.ID(),
+		}).WithError(err).Error("can't write delta journal file")
 	}
 
 	return
@@ -628,10 +648,8 @@ func (s *Store) wrapBuffer(buf []byte, openWrapChar, closeWrapChar byte, strip s
 // The deltas are a list of json hashes WITHOUT the surrounding square brackets
 func (s *Store) readIndividualPluginDeltas(plugin *PluginInfo, entityKey string) (buf []byte, err error) {
 	deltaFilePath := s.DeltaFilePath(plugin, entityKey)
-	if _, err = os.Stat(deltaFilePath); err == nil {
-		if buf, err = ioutil.ReadFile(deltaFilePath); err != nil {
-			slog.WithField("path", deltaFilePath).WithError(err).Error("can't read delta file")
-		}
+	if buf, err = s.repo.ReadFile(deltaFilePath); err != nil && !os.IsNotExist(err) {
+		slog.WithField("path", deltaFilePath).WithError(err).Error("can't read delta file")
 	}
 	return
 }
@@ -686,7 +704,7 @@ func (s *Store) cleanPluginDeltas(plugins []*PluginInfo, entityKey string) (err
 					}
 				})
 
-				if err = disk.WriteFile(deltaFilePath, []byte(``), DATA_FILE_MODE); err != nil {
+				if err = s.repo.WriteFile(deltaFilePath, []byte(``)); err != nil {
 					cslog.WithError(err).Error("can't clean delta file")
 					return err
 				}
@@ -758,6 +776,7 @@ func (s *Store) RemoveEntity(entityKey string) error {
 
 // RemoveEntityFolders removes the entity cached storage from the entities whose folder is equal to the argument.
 func (s *Store) RemoveEntityFolders(entityFolder string) error {
+	delete(s.lastSaved, entityFolder)
 	errStrings := s.removeEntityEntries(s.DataDir, entityFolder)
 	errStrings = append(errStrings, s.removeEntityEntries(s.CacheDir, entityFolder)...)
 	if len(errStrings) > 0 {
@@ -769,7 +788,7 @@ func (s *Store) RemoveEntityFolders(entityFolder string) error {
 func (s *Store) removeEntityEntries(dir, entityFolder string) (errStrings []string) {
 	errStrings = make([]string, 0)
 	// For all the plugins in the given directory
-	plugins, err := ioutil.ReadDir(dir)
+	plugins, err := s.repo.ReadDir(dir)
 	if err != nil {
 		errStrings = append(errStrings, err.Error())
 		return errStrings
@@ -778,9 +797,9 @@ func (s *Store) removeEntityEntries(dir, entityFolder string) (errStrings []stri
 		if plugin.IsDir() && !nonEntityFolders[plugin.Name()] {
 			// For all the entities under the plugin folder, remove those whose directory name is planned for removal
 			entityPath := filepath.Join(dir, plugin.Name(), entityFolder)
-			if _, err := os.Stat(entityPath); err == nil {
+			if s.repo.Exists(entityPath) {
 				helpers.DebugStackf("removing: %s", entityPath)
-				if err = os.RemoveAll(entityPath); err != nil {
+				if err = s.repo.RemoveAll(entityPath); err != nil {
 					errStrings = append(errStrings, err.Error())
 				}
 			}
@@ -809,14 +828,14 @@ func (s *Store) fetchEntities(dir string) (map[string]interface{}, error) {
 	entities := make(map[string]interface{})
 
 	// For all the plugins in the given directory
-	plugins, err := ioutil.ReadDir(dir)
+	plugins, err := s.repo.ReadDir(dir)
 	if err != nil {
 		return nil, err
 	}
 	for _, plugin := range plugins {
 		if plugin.IsDir() && !nonEntityFolders[plugin.Name()] {
 			// For all the entities under the plugin folder, adds them to the map
-			entityFolders, err := ioutil.ReadDir(filepath.Join(dir, plugin.Name()))
+			entityFolders, err := s.repo.ReadDir(filepath.Join(dir, plugin.Name()))
 			if err != nil {
 				return entities, err
 			}
@@ -836,7 +855,7 @@ func (s *Store) fetchEntities(dir string) (map[string]interface{}, error) {
 // retured `{}`.
 func (s *Store) newPluginDelta(pluginItem *PluginInfo, entityKey string) (delta, error) {
 	sourceFilePath := s.SourceFilePath(pluginItem, entityKey)
-	sourceB, err := ioutil.ReadFile(sourceFilePath)
+	sourceB, err := s.repo.ReadFile(sourceFilePath)
 	if err != nil {
 		slog.WithFields(logrus.Fields{
 			"entityKey": entityKey,
@@ -846,18 +865,20 @@ func (s *Store) newPluginDelta(pluginItem *PluginInfo, entityKey string) (delta,
 	}
 
 	cacheFilePath := s.cachedFilePath(pluginItem, entityKey)
-	_, err = os.Stat(cacheFilePath)
+	cacheB, err := s.repo.ReadFile(cacheFilePath)
 	if os.IsNotExist(err) {
 		return delta{value: sourceB, full: true}, nil
 	}
-
-	cacheB, err := ioutil.ReadFile(cacheFilePath)
 	if err != nil {
 		slog.WithError(err).Error("can't read inventory cache")
 		return delta{}, err
 	}
 
 	del, err := s.getDeltaFromJSON(cacheB, sourceB)
+	if err == nil && !bytes.Equal(EMPTY_DELTA, del) && s.onlyNoise(pluginItem.ID(), cacheB, sourceB) {
+		// the cache is kept, so the delta is calculated against the last reported inventory
+		return delta{value: EMPTY_DELTA}, nil
+	}
 	return delta{value: del, full: false}, err
 }
 
@@ -883,7 +904,7 @@ func (s *Store) updatePluginInventoryCache(pi *PluginInfo, entityKey string) (up
 		if err := s.clearPluginDeltaStore(pi, entityKey); err != nil {
 			llog.WithError(err).Warn("can't clear plugin delta store")
 		}
-		if err := os.RemoveAll(s.SourceFilePath(pi, entityKey)); err != nil {
+		if err := s.repo.RemoveAll(s.SourceFilePath(pi, entityKey)); err != nil {
 			llog.WithError(err).Warn("can't remove source file path")
 		}
 		return
@@ -918,7 +939,11 @@ func (s *Store) updatePluginInventoryCache(pi *PluginInfo, entityKey string) (up
 func (s *Store) replacePluginCacheFileWithSource(pluginItem *PluginInfo, entityKey string) error {
 	sourceFilePath := s.SourceFilePath(pluginItem, entityKey)
 	cachedFilePath := s.cachedFilePath(pluginItem, entityKey)
-	return helpers.CopyFile(sourceFilePath, cachedFilePath)
+	source, err := s.repo.ReadFile(sourceFilePath)
+	if err != nil {
+		return err
+	}
+	return s.repo.WriteFile(cachedFilePath, source)
 }
 
 // UpdatePluginsInventoryCache looks for all the plugins of the given
@@ -967,14 +992,22 @@ func (s *Store) UpdatePluginsInventoryCache(entityKey string) (err error) {
 	return
 }
 
+// RemovePluginSource removes the inventory source of an entity plugin, if it's stored, so it isn't
+// reaped anymore.
+func (s *Store) RemovePluginSource(entityKey, category, term string) error {
+	sourceFile := filepath.Join(s.PluginDirPath(category, entityKey), term+".json")
+	if !s.repo.Exists(sourceFile) {
+		return nil
+	}
+	return s.repo.Remove(sourceFile)
+}
+
 // StorePluginOutput will take a PluginOutput blob and write it to the
 // data directory in JSON format
 func (s *Store) SavePluginSource(entityKey, category, term string, source map[string]interface{}) (err error) {
-	// construct the plugin data directory and ensure it exists
+	// construct the plugin data directory, created by the repository when needed
 	outputDir := s.PluginDirPath(category, entityKey)
-	if err = disk.MkdirAll(outputDir, DATA_DIR_MODE); err != nil {
-		return err
-	}
+	s.lastSaved[s.entityFolder(entityKey)] = time.Now()
 
 	// construct the output file path
 	outputFile := fmt.Sprintf("%s/%s.json", outputDir, term)
@@ -1001,6 +1034,6 @@ func (s *Store) SavePluginSource(entityKey, category, term string, source map[st
 		)
 		return
 	}
-	err = disk.WriteFile(outputFile, sourceB, DATA_FILE_MODE)
+	err = s.repo.WriteFile(outputFile, sourceB)
 	return
 }
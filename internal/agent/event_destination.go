@@ -0,0 +1,171 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package agent
+
+import (
+	"bytes"
+	context2 "context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/newrelic/infrastructure-agent/pkg/config"
+	"github.com/newrelic/infrastructure-agent/pkg/entity"
+)
+
+// destinationQueueCapacity is the number of posts waiting to be sent to each additional destination. Further
+// posts are dropped while the destination is slow or unreachable.
+const destinationQueueCapacity = 50
+
+// eventDestination is an additional account the events are also sent to.
+type eventDestination struct {
+	name            string
+	licenseKey      string
+	metricIngestURL string
+	metricsOnly     bool              // only the samples are sent
+	attributes      map[string]string // set on every event, overriding the existing ones
+	queue           chan destinationPost
+}
+
+// destinationPost is a post waiting to be sent to an additional destination.
+type destinationPost struct {
+	post      MetricPostBatch
+	agentKey  string
+	payloadID string
+}
+
+func newEventDestinations(cfg *config.Config) (destinations []eventDestination) {
+	for _, d := range cfg.AdditionalDestinations {
+		metricIngestURL := fmt.Sprintf("%s/%s", d.CollectorURL, strings.TrimPrefix(cfg.MetricsIngestEndpoint, "/"))
+		destinations = append(destinations, eventDestination{
+			name:            d.Name,
+			licenseKey:      d.LicenseKey,
+			metricIngestURL: strings.TrimSuffix(metricIngestURL, "/"),
+			metricsOnly:     d.MetricsOnly,
+			attributes:      d.Attributes,
+			queue:           make(chan destinationPost, destinationQueueCapacity),
+		})
+	}
+	return destinations
+}
+
+// fanOut queues the batch events to be sent to the additional destinations. Each destination is sent its posts
+// from its own routine, so an unavailable destination doesn't delay the submission to the agent account.
+func (sender *metricsIngestSender) fanOut(batch eventBatch, payloadID string) {
+	for i := range sender.destinations {
+		d := &sender.destinations[i]
+		post, agentKey := d.post(batch)
+		if len(post) == 0 {
+			continue
+		}
+		select {
+		case d.queue <- destinationPost{post: post, agentKey: agentKey, payloadID: payloadID}:
+		default:
+			ilog.WithField("destination", d.name).Warn("Additional destination queue is full, dropping events.")
+		}
+	}
+}
+
+// sendToDestination sends the queued posts to an additional destination until the stop channel is closed.
+// Failed posts aren't retried.
+func (sender *metricsIngestSender) sendToDestination(d *eventDestination, stop <-chan bool) {
+	for {
+		select {
+		case p := <-d.queue:
+			sender.postToDestination(d, p)
+		case <-stop:
+			return
+		}
+	}
+}
+
+func (sender *metricsIngestSender) postToDestination(d *eventDestination, p destinationPost) {
+	if err := sender.postTo(p.post, p.agentKey, p.payloadID, d.metricIngestURL, d.licenseKey, false); err != nil {
+		ilog.WithError(err).WithField("destination", d.name).Warn("Can't send events to additional destination.")
+	}
+}
+
+// flushDestinations sends the posts left in the destination queues once the sender is stopped, until the
+// context is done.
+func (sender *metricsIngestSender) flushDestinations(ctx context2.Context) {
+	var wg sync.WaitGroup
+	for i := range sender.destinations {
+		d := &sender.destinations[i]
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ctx.Err() == nil {
+				select {
+				case p := <-d.queue:
+					sender.postToDestination(d, p)
+				default:
+					return
+				}
+			}
+		}()
+	}
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		ilog.Warn("Events not flushed in time to the additional destinations, they are dropped.")
+	}
+}
+
+// post groups the events to send to the destination by entity. Entity IDs are assigned per account, so entities
+// are identified by their keys.
+func (d *eventDestination) post(batch eventBatch) (post MetricPostBatch, agentKey string) {
+	byEntity := make(map[entity.Key]*MetricPost)
+	for _, event := range batch {
+		data, ok := d.filter(event.data)
+		if !ok {
+			continue
+		}
+		mp := byEntity[event.entityKey]
+		if mp == nil {
+			mp = newMetricPost(event.entityKey, entity.EmptyID, entity.EmptyID, event.agentKey)
+			byEntity[event.entityKey] = mp
+			post = append(post, mp)
+		}
+		mp.Events = append(mp.Events, data)
+		if event.agentKey != "" {
+			agentKey = event.agentKey
+		}
+	}
+	return post, agentKey
+}
+
+// filter returns the event as sent to the destination, or false if it's not sent.
+func (d *eventDestination) filter(data json.RawMessage) (json.RawMessage, bool) {
+	if !d.metricsOnly && len(d.attributes) == 0 {
+		return data, true
+	}
+
+	var event map[string]interface{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&event); err != nil {
+		return nil, false
+	}
+	if d.metricsOnly {
+		if eventType, _ := event["eventType"].(string); !strings.HasSuffix(eventType, "Sample") {
+			return nil, false
+		}
+	}
+	if len(d.attributes) == 0 {
+		return data, true
+	}
+	for k, v := range d.attributes {
+		event[k] = v
+	}
+	overridden, err := json.Marshal(event)
+	if err != nil {
+		return nil, false
+	}
+	return overridden, true
+}
@@ -5,6 +5,7 @@ package agent
 import (
 	"bytes"
 	"compress/gzip"
+	context2 "context"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
@@ -18,8 +19,11 @@ import (
 	"github.com/sirupsen/logrus"
 
 	"github.com/newrelic/infrastructure-agent/pkg/backend/backoff"
+	"github.com/newrelic/infrastructure-agent/pkg/backend/spool"
+	"github.com/newrelic/infrastructure-agent/pkg/backend/throttle"
 
 	"github.com/newrelic/infrastructure-agent/internal/agent/id"
+	"github.com/newrelic/infrastructure-agent/internal/agent/wal"
 
 	"github.com/newrelic/infrastructure-agent/pkg/entity"
 	"github.com/newrelic/infrastructure-agent/pkg/sample"
@@ -33,6 +37,12 @@ const (
 	BATCH_QUEUE_CAPACITY       = 200 // Queue memory consumption cCould be a MAX of config.MaxMetricsBatchSizeBytes * BATCH_QUEUE_CAPACITY in size
 	MAX_EVENT_BATCH_COUNT      = 500
 	EVENT_BATCH_TIMER_DURATION = 1 // seconds, How often we will queue batches of events even if we haven't hit max batch size
+
+	eventWALSegmentSize = 4 << 20 // bytes written to a write-ahead log segment before starting a new one
+
+	// batches aren't shrunk below these sizes while the backend throttles the agent
+	minThrottledBatchSizeBytes = 64 << 10
+	minThrottledBatchCount     = 50
 )
 
 var ilog = log.WithComponent("MetricsIngestSender")
@@ -42,6 +52,23 @@ type eventData struct {
 	entityID  entity.ID
 	agentKey  string
 	data      json.RawMessage // Pre-marshalled JSON data for a single event.
+	walID     uint64          // ID of the event in the write-ahead log, 0 when it isn't logged.
+	sealed    *sealedBatch    // batch the event was posted in, nil when it hasn't been posted yet.
+}
+
+// sealedBatch is a batch of logged events recorded in the write-ahead log before it's posted, so it's sent
+// again with the same events and payload ID after a restart.
+type sealedBatch struct {
+	walID uint64 // ID of the batch record
+	size  int
+}
+
+// walEvent is the queued event, or the sealed batch, stored in the write-ahead log.
+type walEvent struct {
+	EntityKey entity.Key      `json:"entityKey,omitempty"`
+	AgentKey  string          `json:"agentKey,omitempty"`
+	Data      json.RawMessage `json:"data,omitempty"`
+	Batch     []uint64        `json:"batch,omitempty"` // IDs of the events of a sealed batch
 }
 
 type eventBatch []eventData // A collection of pre-marshalled event JSON objects.
@@ -77,7 +104,28 @@ type metricsIngestSender struct {
 	agentIDProvide           id.Provide
 	connectEnabled           bool
 	getBackoffTimer          func(time.Duration) *time.Timer
-	postCount                uint64 // counts post requests for debugging purposes
+	postCount                uint64             // counts post requests for debugging purposes
+	spool                    *spool.Spool       // nil: the posts failing while the backend is unreachable are dropped
+	eventLog                 *wal.Log           // nil: the queued events are lost when the agent stops
+	pendingRead              bool               // the pending events are read once, as later they're also in the queues
+	unbatched                eventBatch         // events accumulated when the sender was stopped
+	destinations             []eventDestination // additional accounts the events are also sent to
+	throttle                 *throttle.Controller
+}
+
+// spooledEventsKind identifies the event posts in the payload spool.
+const spooledEventsKind = "events"
+
+// spooledPost is a metrics post stored in the spool until the backend is reachable again.
+type spooledPost struct {
+	AgentKey  string          `json:"agentKey"`
+	PayloadID string          `json:"payloadId,omitempty"`
+	Post      MetricPostBatch `json:"post"`
+}
+
+// errUnreachable is returned when a post can't be delivered to the backend.
+type errUnreachable struct {
+	error
 }
 
 func newMetricsIngestSender(ctx *context, licenseKey, userAgent string, httpClient backendhttp.Client, connectEnabled bool) *metricsIngestSender {
@@ -120,6 +168,10 @@ func newMetricsIngestSender(ctx *context, licenseKey, userAgent string, httpClie
 		connectEnabled:           connectEnabled,
 		getBackoffTimer:          time.NewTimer,
 		postCount:                0,
+		spool:                    ctx.spool,
+		eventLog:                 ctx.eventLog,
+		destinations:             newEventDestinations(cfg),
+		throttle:                 ctx.throttle,
 	}
 }
 
@@ -149,6 +201,11 @@ func (sender *metricsIngestSender) Start() (err error) {
 		sender.sendBatches()
 	}()
 
+	// not waited on stop, so an unavailable destination doesn't delay it. They exit after their current post.
+	for i := range sender.destinations {
+		go sender.sendToDestination(&sender.destinations[i], sender.stopChannel)
+	}
+
 	return
 }
 
@@ -190,14 +247,208 @@ func (sender *metricsIngestSender) QueueEvent(event sample.Event, key entity.Key
 		agentKey:  agentKey,
 	}
 
+	if sender.eventLog == nil {
+		select {
+		case sender.eventQueue <- queuedEvent:
+			return nil
+		default:
+			return fmt.Errorf("could not queue event: queue is full")
+		}
+	}
+
+	if len(sender.eventQueue) == cap(sender.eventQueue) {
+		return fmt.Errorf("could not queue event: queue is full")
+	}
+	logged, err := json.Marshal(walEvent{EntityKey: key, AgentKey: agentKey, Data: edata})
+	if err != nil {
+		return fmt.Errorf("error marshalling event for the write-ahead log: %v", err)
+	}
+	// the log is synced once per batch, when the events are batched
+	if queuedEvent.walID, err = sender.eventLog.Append(logged); err != nil {
+		ilog.WithError(err).Warn("Can't write the event to the write-ahead log, it will be lost if the agent stops.")
+	}
 	select {
 	case sender.eventQueue <- queuedEvent:
 		return nil
 	default:
+		// the queue filled up meanwhile
+		sender.ackBatch(eventBatch{queuedEvent})
 		return fmt.Errorf("could not queue event: queue is full")
 	}
 }
 
+// pendingEvents returns the events logged by a previous agent execution that weren't sent. The batches that were
+// sealed before being posted are returned apart, so they are posted again with the same events.
+func (sender *metricsIngestSender) pendingEvents() (batches []eventBatch, events eventBatch) {
+	if sender.eventLog == nil || sender.pendingRead {
+		return nil, nil
+	}
+	sender.pendingRead = true
+	records, err := sender.eventLog.Pending()
+	if err != nil {
+		ilog.WithError(err).Warn("Can't read the events write-ahead log, some pending events won't be sent.")
+	}
+
+	var logged []walEvent
+	sealedBy := map[uint64]*sealedBatch{} // by event ID
+	var discarded []uint64
+	for _, record := range records {
+		var e walEvent
+		if err := json.Unmarshal(record.Data, &e); err != nil {
+			ilog.WithError(err).Warn("Discarding invalid event from the write-ahead log.")
+			discarded = append(discarded, record.ID)
+			logged = append(logged, walEvent{})
+			continue
+		}
+		if len(e.Batch) > 0 {
+			sealed := &sealedBatch{walID: record.ID, size: len(e.Batch)}
+			for _, id := range e.Batch {
+				sealedBy[id] = sealed
+			}
+		}
+		logged = append(logged, e)
+	}
+
+	byBatch := map[*sealedBatch]int{} // index of the sealed batches
+	covered := map[*sealedBatch]bool{}
+	for i, record := range records {
+		e := logged[i]
+		if len(e.Batch) > 0 || e.Data == nil {
+			continue
+		}
+		event := eventData{
+			entityKey: e.EntityKey,
+			agentKey:  e.AgentKey,
+			data:      e.Data,
+			walID:     record.ID,
+			sealed:    sealedBy[record.ID],
+		}
+		if event.sealed == nil {
+			events = append(events, event)
+			continue
+		}
+		covered[event.sealed] = true
+		idx, ok := byBatch[event.sealed]
+		if !ok {
+			idx = len(batches)
+			byBatch[event.sealed] = idx
+			batches = append(batches, nil)
+		}
+		batches[idx] = append(batches[idx], event)
+	}
+	// batches whose events were all acknowledged
+	for _, sealed := range sealedBy {
+		if !covered[sealed] {
+			discarded = append(discarded, sealed.walID)
+			covered[sealed] = true
+		}
+	}
+	if len(discarded) > 0 {
+		if err := sender.eventLog.Ack(discarded...); err != nil {
+			ilog.WithError(err).Warn("Can't acknowledge the discarded records in the write-ahead log.")
+		}
+	}
+
+	count := len(events)
+	for _, batch := range batches {
+		count += len(batch)
+	}
+	if count > 0 {
+		ilog.WithField("events", count).Info("Sending events pending from the previous execution.")
+	}
+	return batches, events
+}
+
+// sealBatch records the events of a batch in the write-ahead log before it's posted for the first time, and syncs
+// the log. It returns the payload ID of the batch, which is kept when the batch is posted again after a restart,
+// so the backend can discard the duplicated posts. It's empty when the events aren't logged.
+func (sender *metricsIngestSender) sealBatch(batch eventBatch) string {
+	if sender.eventLog == nil || len(batch) == 0 {
+		return ""
+	}
+	sealed := batch[0].sealed
+	ids := make([]uint64, 0, len(batch))
+	for _, event := range batch {
+		if event.walID == 0 {
+			return ""
+		}
+		if event.sealed != sealed {
+			sealed = nil
+		}
+		ids = append(ids, event.walID)
+	}
+	if sealed != nil && sealed.size == len(batch) {
+		return sender.payloadID(sealed)
+	}
+
+	record, err := json.Marshal(walEvent{Batch: ids})
+	if err == nil {
+		sealed = &sealedBatch{size: len(batch)}
+		sealed.walID, err = sender.eventLog.Append(record)
+	}
+	if err == nil {
+		err = sender.eventLog.Sync()
+	}
+	if err != nil {
+		ilog.WithError(err).Warn("Can't record the events batch in the write-ahead log, it may be sent again with another payload ID after a restart.")
+		return ""
+	}
+	// the batches the events were sealed in before are replaced by the new one
+	var replaced []uint64
+	for i := range batch {
+		if batch[i].sealed != nil && batch[i].sealed != sealed {
+			replaced = append(replaced, batch[i].sealed.walID)
+		}
+		batch[i].sealed = sealed
+	}
+	if len(replaced) > 0 {
+		if err := sender.eventLog.Ack(replaced...); err != nil {
+			ilog.WithError(err).Debug("Can't acknowledge the replaced batches in the write-ahead log.")
+		}
+	}
+	return sender.payloadID(sealed)
+}
+
+func (sender *metricsIngestSender) payloadID(sealed *sealedBatch) string {
+	return fmt.Sprintf("%s-%d", sender.eventLog.ID(), sealed.walID)
+}
+
+// syncLog syncs the events appended to the write-ahead log, once per batch instead of once per event.
+func (sender *metricsIngestSender) syncLog() {
+	if sender.eventLog == nil {
+		return
+	}
+	if err := sender.eventLog.Sync(); err != nil {
+		ilog.WithError(err).Warn("Can't sync the events write-ahead log, the queued events may be lost if the host crashes.")
+	}
+}
+
+// ackBatch acknowledges the batch events in the write-ahead log, so they aren't sent again after a restart.
+// Events posted right before the agent stops abruptly may not be acknowledged yet, so they are sent again with
+// the same payload ID.
+func (sender *metricsIngestSender) ackBatch(batch eventBatch) {
+	if sender.eventLog == nil {
+		return
+	}
+	var ids []uint64
+	var sealed *sealedBatch
+	for _, event := range batch {
+		if event.walID != 0 {
+			ids = append(ids, event.walID)
+		}
+		if event.sealed != nil && event.sealed != sealed {
+			sealed = event.sealed
+			ids = append(ids, sealed.walID)
+		}
+	}
+	if len(ids) == 0 {
+		return
+	}
+	if err := sender.eventLog.Ack(ids...); err != nil {
+		ilog.WithError(err).Warn("Can't acknowledge the sent events in the write-ahead log, they may be sent again after a restart.")
+	}
+}
+
 // Collect events from the queue and accumulate them into batches which can be sent up to metrics ingest.
 // This hands off each batch to another channel which is consumed by a routine which does the actual post.
 // We send batches on a timer or on accumulating some number of events in the current batch.
@@ -209,32 +460,78 @@ func (sender *metricsIngestSender) accumulateBatches() {
 	var batch eventBatch
 	var batchBytes int // Accumulated batch size in bytes
 
+	// the accumulated events are kept to be flushed, or batched again if the sender is restarted
+	for _, event := range sender.unbatched {
+		batch = append(batch, event)
+		batchBytes += len(event.data)
+	}
+	sender.unbatched = nil
+	defer func() {
+		sender.unbatched = batch
+	}()
+
+	withEntityID := func(event eventData) eventData {
+		// Add entityID if connect is enabled and if is not a remote entity.
+		if sender.connectEnabled && event.IsAgent() {
+			event.entityID = sender.agentIDProvide().ID
+		}
+		return event
+	}
+
+	// add returns false when the sender is stopped.
+	add := func(event eventData) bool {
+		event = withEntityID(event)
+		if batchBytes+len(event.data) > sender.maxBatchSizeBytes() || len(batch) >= sender.maxBatchCount() {
+			// Current batch + this event would either be too many events or too many bytes, so queue the batch first.
+			sender.syncLog()
+			select {
+			case sender.batchQueue <- batch:
+				batch = make(eventBatch, 0)
+				batchBytes = 0
+			case <-sender.stopChannel:
+				batch = append(batch, event)
+				return false
+			}
+		}
+		batch = append(batch, event)
+		batchBytes += len(event.data)
+		return true
+	}
+
+	// events not sent before the agent stopped go first. The batches already posted are sent again as they were.
+	sealedBatches, pending := sender.pendingEvents()
+	for i, sealed := range sealedBatches {
+		for j := range sealed {
+			sealed[j] = withEntityID(sealed[j])
+		}
+		select {
+		case sender.batchQueue <- sealed:
+		case <-sender.stopChannel:
+			for _, rest := range sealedBatches[i:] {
+				batch = append(batch, rest...)
+			}
+			batch = append(batch, pending...)
+			return
+		}
+	}
+	for _, event := range pending {
+		if !add(event) {
+			return
+		}
+	}
+
 	sendTimerD := EVENT_BATCH_TIMER_DURATION * time.Second
 	sendTimer := time.NewTimer(sendTimerD)
 	for {
 		select {
 		case event := <-sender.eventQueue:
-
-			// Add entityID if connect is enabled and if is not a remote entity.
-			if sender.connectEnabled && event.IsAgent() {
-				event.entityID = sender.agentIDProvide().ID
-			}
-
-			if batchBytes+len(event.data) > sender.maxMetricsBatchSizeBytes || len(batch) == MAX_EVENT_BATCH_COUNT {
-				// Current batch + this event would either be too many events or too many bytes, so queue the batch first.
-				select {
-				case sender.batchQueue <- batch:
-					batch = make(eventBatch, 0)
-					batchBytes = 0
-				case <-sender.stopChannel:
-					return
-				}
+			if !add(event) {
+				return
 			}
-			batch = append(batch, event)
-			batchBytes += len(event.data)
 		case <-sendTimer.C:
 			// Timer has fired - send any queued events to ensure a minimum delay in sending.
 			if len(batch) > 0 {
+				sender.syncLog()
 				select {
 				case sender.batchQueue <- batch:
 					batch = make(eventBatch, 0)
@@ -322,48 +619,31 @@ func (sender *metricsIngestSender) sendBatches() {
 			pclog := ilog.WithField("postCount", sender.postCount)
 			sender.postCount++
 
-			agentKey := ""
-			dataByEntity := make(map[entity.Key]*MetricPost)
-
-			agentID := sender.agentID()
-
-			// We need to rebuild the array of events as a []json.RawMessage, or else JSON marshalling won't handle them correctly.
-			for _, event := range batch {
-				entityData := dataByEntity[event.entityKey]
-				if entityData == nil {
-					entityData = newMetricPost(event.entityKey, event.entityID, agentID, event.agentKey)
-					dataByEntity[event.entityKey] = entityData
-				}
-				entityData.Events = append(entityData.Events, event.data)
-				if event.agentKey != "" {
-					agentKey = event.agentKey
-				}
-			}
-
-			var bulkPost MetricPostBatch
-			for _, entityData := range dataByEntity {
-
-				pclog.WithFieldsF(entityData.getLoggingField).
-					WithFieldsF(entityData.getTimestampLoggingFields).
-					WithField("numEvents", len(entityData.Events)).
-					Debug("Sending events to metrics-ingest.")
-				bulkPost = append(bulkPost, entityData)
-			}
+			bulkPost, agentKey := sender.bulkPost(batch, pclog)
+			payloadID := sender.sealBatch(batch)
 
 			pclog.Debug("Preparing metrics post.")
 
-			err := sender.doPost(bulkPost, agentKey)
+			err := sender.doPost(bulkPost, agentKey, payloadID)
+			// failed posts are spooled or dropped, so the events are acknowledged anyway
+			sender.ackBatch(batch)
+			sender.fanOut(batch, payloadID)
 
 			if err == nil {
 				pclog.Debug("Metrics post succeeded.")
 				sender.sendErrorCount = 0
 				retryBO.Reset()
+				sender.replaySpooledPosts()
 				continue
 			}
 
 			sender.sendErrorCount++
 			pclog.WithError(err).WithField("sendErrorCount", sender.sendErrorCount).Error("metric sender can't process")
 
+			if sender.spool != nil && isUnavailable(err) {
+				sender.spoolPost(bulkPost, agentKey, payloadID)
+			}
+
 			e, ok := err.(*errRetry)
 			if !ok {
 				continue
@@ -386,6 +666,185 @@ func (sender *metricsIngestSender) sendBatches() {
 	}
 }
 
+// bulkPost groups the batch events by entity.
+func (sender *metricsIngestSender) bulkPost(batch eventBatch, pclog log.Entry) (bulkPost MetricPostBatch, agentKey string) {
+	dataByEntity := make(map[entity.Key]*MetricPost)
+
+	agentID := sender.agentID()
+
+	// We need to rebuild the array of events as a []json.RawMessage, or else JSON marshalling won't handle them correctly.
+	for _, event := range batch {
+		entityData := dataByEntity[event.entityKey]
+		if entityData == nil {
+			entityData = newMetricPost(event.entityKey, event.entityID, agentID, event.agentKey)
+			dataByEntity[event.entityKey] = entityData
+		}
+		entityData.Events = append(entityData.Events, event.data)
+		if event.agentKey != "" {
+			agentKey = event.agentKey
+		}
+	}
+
+	for _, entityData := range dataByEntity {
+
+		pclog.WithFieldsF(entityData.getLoggingField).
+			WithFieldsF(entityData.getTimestampLoggingFields).
+			WithField("numEvents", len(entityData.Events)).
+			Debug("Sending events to metrics-ingest.")
+		bulkPost = append(bulkPost, entityData)
+	}
+	return bulkPost, agentKey
+}
+
+// Flush sends the events left in the queues once the sender is stopped, until the context is done. Events that
+// can't be sent are spooled, or kept in the write-ahead log to be sent by the next agent execution.
+func (sender *metricsIngestSender) Flush(ctx context2.Context) error {
+	// batches are older than the accumulated events, which are older than the queued ones
+	var batches []eventBatch
+	var events eventBatch
+	for drained := false; !drained; {
+		select {
+		case batch := <-sender.batchQueue:
+			batches = append(batches, batch)
+		default:
+			drained = true
+		}
+	}
+	events = append(events, sender.unbatched...)
+	sender.unbatched = nil
+	for drained := false; !drained; {
+		select {
+		case event := <-sender.eventQueue:
+			if sender.connectEnabled && event.IsAgent() {
+				event.entityID = sender.agentIDProvide().ID
+			}
+			events = append(events, event)
+		default:
+			drained = true
+		}
+	}
+	batches = append(batches, sender.splitBatch(events)...)
+
+	for i, batch := range batches {
+		if len(batch) == 0 {
+			continue
+		}
+		if ctx.Err() != nil {
+			sender.persistBatches(batches[i:])
+			return ctx.Err()
+		}
+		bulkPost, agentKey := sender.bulkPost(batch, ilog)
+		payloadID := sender.sealBatch(batch)
+		if err := sender.doPost(bulkPost, agentKey, payloadID); err != nil {
+			ilog.WithError(err).Warn("Can't flush events.")
+			if sender.spool != nil && isUnavailable(err) {
+				sender.spoolPost(bulkPost, agentKey, payloadID)
+			}
+		}
+		sender.ackBatch(batch)
+		sender.fanOut(batch, payloadID)
+	}
+	sender.flushDestinations(ctx)
+	return nil
+}
+
+// maxBatchSizeBytes returns the batch size limit, shrunk while the backend throttles the agent.
+func (sender *metricsIngestSender) maxBatchSizeBytes() int {
+	return sender.throttle.BatchSize(sender.maxMetricsBatchSizeBytes, minThrottledBatchSizeBytes)
+}
+
+// maxBatchCount returns the max events of a batch, shrunk while the backend throttles the agent.
+func (sender *metricsIngestSender) maxBatchCount() int {
+	return sender.throttle.BatchSize(MAX_EVENT_BATCH_COUNT, minThrottledBatchCount)
+}
+
+// splitBatch splits the events in batches within the events count and size limits.
+func (sender *metricsIngestSender) splitBatch(events eventBatch) (batches []eventBatch) {
+	var batch eventBatch
+	var batchBytes int
+	for _, event := range events {
+		if batchBytes+len(event.data) > sender.maxBatchSizeBytes() || len(batch) >= sender.maxBatchCount() {
+			batches = append(batches, batch)
+			batch = nil
+			batchBytes = 0
+		}
+		batch = append(batch, event)
+		batchBytes += len(event.data)
+	}
+	if len(batch) > 0 {
+		batches = append(batches, batch)
+	}
+	return batches
+}
+
+// persistBatches keeps the batches that couldn't be flushed for the next agent execution.
+func (sender *metricsIngestSender) persistBatches(batches []eventBatch) {
+	var count int
+	for _, batch := range batches {
+		count += len(batch)
+	}
+	switch {
+	case sender.spool != nil:
+		for _, batch := range batches {
+			bulkPost, agentKey := sender.bulkPost(batch, ilog)
+			sender.spoolPost(bulkPost, agentKey, sender.sealBatch(batch))
+			sender.ackBatch(batch)
+		}
+	case sender.eventLog != nil:
+		sender.syncLog()
+		ilog.WithField("events", count).Info("Events not flushed in time, they will be sent by the next execution.")
+	default:
+		ilog.WithField("events", count).Warn("Events not flushed in time, they are dropped.")
+	}
+}
+
+// isUnavailable returns whether the post failed because the backend couldn't be reached or was unavailable, so
+// it may be accepted later.
+func isUnavailable(err error) bool {
+	switch e := err.(type) {
+	case *errUnreachable:
+		return true
+	case *errRetry:
+		return e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= http.StatusInternalServerError
+	}
+	return false
+}
+
+// spoolPost stores a failed post to submit it once the backend is available again. The events keep their
+// original timestamps.
+func (sender *metricsIngestSender) spoolPost(post MetricPostBatch, agentKey, payloadID string) {
+	payload, err := json.Marshal(spooledPost{AgentKey: agentKey, PayloadID: payloadID, Post: post})
+	if err == nil {
+		err = sender.spool.Put(spooledEventsKind, payload)
+	}
+	if err != nil {
+		ilog.WithError(err).Warn("Can't spool the failed events post, the events are dropped.")
+		return
+	}
+	ilog.Debug("Failed events post spooled.")
+}
+
+// replaySpooledPosts submits the posts stored while the backend wasn't available.
+func (sender *metricsIngestSender) replaySpooledPosts() {
+	if sender.spool == nil {
+		return
+	}
+	sent, err := sender.spool.Replay(spooledEventsKind, func(payload []byte) error {
+		var spooled spooledPost
+		if err := json.Unmarshal(payload, &spooled); err != nil {
+			ilog.WithError(err).Warn("Discarding invalid spooled events post.")
+			return nil
+		}
+		return sender.doPost(spooled.Post, spooled.AgentKey, spooled.PayloadID)
+	})
+	if sent > 0 {
+		ilog.WithField("posts", sent).Info("Spooled events posts submitted.")
+	}
+	if err != nil {
+		ilog.WithError(err).Debug("Spooled events posts replay interrupted.")
+	}
+}
+
 func (s *metricsIngestSender) agentID() entity.ID {
 	if s.Context != nil &&
 		s.Context.Config() != nil &&
@@ -407,7 +866,17 @@ func (s *metricsIngestSender) backoff(d time.Duration) {
 }
 
 // Make one HTTP call to push a load of events up to the server
-func (sender *metricsIngestSender) doPost(post []*MetricPost, agentKey string) error {
+func (sender *metricsIngestSender) doPost(post []*MetricPost, agentKey, payloadID string) error {
+	err := sender.postTo(post, agentKey, payloadID, sender.metricIngestURL, sender.licenseKey, sender.connectEnabled)
+	if e, ok := err.(*errRetry); ok && throttle.IsThrottling(e.StatusCode) {
+		sender.throttle.Throttled()
+	}
+	return err
+}
+
+// postTo sends the events to the metrics ingest service of an account. The payload ID, if any, identifies the
+// post when it's sent again.
+func (sender *metricsIngestSender) postTo(post []*MetricPost, agentKey, payloadID, metricIngestURL, licenseKey string, connectEnabled bool) error {
 	if agentKey == "" {
 		ilog.Warn("no available agent-id on metrics sender")
 	}
@@ -419,10 +888,10 @@ func (sender *metricsIngestSender) doPost(post []*MetricPost, agentKey string) e
 
 	// GZIP
 	var reqBuf *bytes.Buffer
-	if sender.Context.Config().PayloadCompressionLevel > gzip.NoCompression {
+	if sender.Context.Config().PayloadGzipLevel() > gzip.NoCompression {
 		// GZIP
 		reqBuf = &bytes.Buffer{}
-		compressionLevel := sender.Context.Config().PayloadCompressionLevel
+		compressionLevel := sender.Context.Config().PayloadGzipLevel()
 		gzipWriter, err := gzip.NewWriterLevel(reqBuf, compressionLevel)
 		if err != nil {
 			return fmt.Errorf("Unable to create gzip writer: %v", err)
@@ -436,21 +905,24 @@ func (sender *metricsIngestSender) doPost(post []*MetricPost, agentKey string) e
 	} else {
 		reqBuf = bytes.NewBuffer(postBytes)
 	}
-	req, err := http.NewRequest("POST", fmt.Sprintf("%s/events/bulk", sender.metricIngestURL), reqBuf)
+	req, err := http.NewRequest("POST", fmt.Sprintf("%s/events/bulk", metricIngestURL), reqBuf)
 	if err != nil {
 		return fmt.Errorf("Error creating event POST: %v", err)
 	}
 
-	if sender.Context.Config().PayloadCompressionLevel > gzip.NoCompression {
+	if sender.Context.Config().PayloadGzipLevel() > gzip.NoCompression {
 		req.Header.Set("Content-Encoding", "gzip")
 	}
 
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("User-Agent", sender.userAgent)
-	req.Header.Set(backendhttp.LicenseHeader, sender.licenseKey)
+	req.Header.Set(backendhttp.LicenseHeader, licenseKey)
 
 	req.Header.Set(backendhttp.EntityKeyHeader, agentKey)
-	if sender.connectEnabled {
+	if payloadID != "" {
+		req.Header.Set(backendhttp.PayloadIDHeader, payloadID)
+	}
+	if connectEnabled {
 		agentID := sender.Context.AgentID()
 		if agentID.IsEmpty() {
 			return fmt.Errorf("empty agent-id on metrics sender")
@@ -460,7 +932,7 @@ func (sender *metricsIngestSender) doPost(post []*MetricPost, agentKey string) e
 
 	resp, err := sender.HttpClient(req)
 	if err != nil {
-		return fmt.Errorf("error sending events: %v", err)
+		return &errUnreachable{fmt.Errorf("error sending events: %v", err)}
 	}
 
 	// To let the http client reusing the connections, the response body
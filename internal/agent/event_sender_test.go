@@ -4,23 +4,29 @@ package agent
 
 import (
 	"compress/gzip"
+	context2 "context"
 	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"io/ioutil"
 	"log"
 	"net"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"os"
 	"strconv"
+	"strings"
 	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/newrelic/infrastructure-agent/internal/agent/wal"
 	"github.com/newrelic/infrastructure-agent/internal/testhelpers"
 	"github.com/newrelic/infrastructure-agent/pkg/entity/host"
 	infra "github.com/newrelic/infrastructure-agent/test/infra/http"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"github.com/newrelic/infrastructure-agent/pkg/config"
 	"github.com/newrelic/infrastructure-agent/pkg/entity"
@@ -28,6 +34,8 @@ import (
 	"sync"
 
 	http2 "github.com/newrelic/infrastructure-agent/pkg/backend/http"
+	"github.com/newrelic/infrastructure-agent/pkg/backend/spool"
+	"github.com/newrelic/infrastructure-agent/pkg/backend/throttle"
 	. "gopkg.in/check.v1"
 )
 
@@ -510,7 +518,7 @@ func TestEventSender_ResponseError(t *testing.T) {
 				ConnectEnabled:          true,
 				PayloadCompressionLevel: gzip.NoCompression,
 			}
-			c := NewContext(cfg, "1.2.3", testhelpers.NullHostnameResolver, host.IDLookup{}, nil)
+			c := NewContext(cfg, "1.2.3", testhelpers.NullHostnameResolver, host.IDLookup{}, nil, nil, nil)
 			c.setAgentKey(agentKey)
 			c.SetAgentIdentity(agentIdn)
 
@@ -540,6 +548,180 @@ func TestEventSender_ResponseError(t *testing.T) {
 	}
 }
 
+func TestEventSender_SpoolsPostsWhileUnavailable(t *testing.T) {
+	dir, err := ioutil.TempDir("", "spool")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	payloadSpool, err := spool.New(dir, 1<<20, time.Hour)
+	require.NoError(t, err)
+
+	// GIVEN a backend that is unavailable for the first post
+	rc := infra.NewRequestRecorderClient(infra.ErrorResponse)
+	cfg := &config.Config{
+		ConnectEnabled:          true,
+		PayloadCompressionLevel: gzip.NoCompression,
+	}
+	c := NewContext(cfg, "1.2.3", testhelpers.NullHostnameResolver, host.IDLookup{}, nil, nil, nil)
+	c.setAgentKey(agentKey)
+	c.SetAgentIdentity(agentIdn)
+	c.spool = payloadSpool
+
+	sender := newMetricsIngestSender(c, "license", "userAgent", rc.Client, true)
+	sender.getBackoffTimer = func(time.Duration) *time.Timer {
+		return time.NewTimer(0)
+	}
+	require.NoError(t, sender.Start())
+	defer sender.Stop()
+
+	// WHEN an event can't be submitted
+	require.NoError(t, sender.QueueEvent(mapEvent{"eventType": "TestEvent", "value": "first"}, ""))
+	<-rc.RequestCh
+
+	// THEN its post is spooled
+	assert.Eventually(t, func() bool { return payloadSpool.Len(spooledEventsKind) == 1 }, time.Second, 10*time.Millisecond)
+
+	// WHEN the backend accepts a later post
+	require.NoError(t, sender.QueueEvent(mapEvent{"eventType": "TestEvent", "value": "second"}, ""))
+	<-rc.RequestCh
+
+	// THEN the spooled post is submitted with its original event
+	replayed := <-rc.RequestCh
+	body, err := ioutil.ReadAll(replayed.Body)
+	require.NoError(t, err)
+	assert.Contains(t, string(body), `"value":"first"`)
+	assert.Eventually(t, func() bool { return payloadSpool.Len(spooledEventsKind) == 0 }, time.Second, 10*time.Millisecond)
+}
+
+func TestEventSender_SendsLoggedEventsAfterRestart(t *testing.T) {
+	dir, err := ioutil.TempDir("", "event_wal")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	cfg := &config.Config{PayloadCompressionLevel: gzip.NoCompression}
+	newSender := func(client http2.Client) (*metricsIngestSender, *wal.Log) {
+		eventLog, err := wal.Open(dir, eventWALSegmentSize)
+		require.NoError(t, err)
+		c := NewContext(cfg, "1.2.3", testhelpers.NullHostnameResolver, host.IDLookup{}, nil, nil, nil)
+		c.setAgentKey(agentKey)
+		c.eventLog = eventLog
+		return newMetricsIngestSender(c, "license", "userAgent", client, false), eventLog
+	}
+
+	// GIVEN an event queued by an agent that stopped before sending it
+	sender, eventLog := newSender(http2.NullHttpClient)
+	require.NoError(t, sender.QueueEvent(mapEvent{"eventType": "TestEvent", "value": "pending"}, ""))
+	require.NoError(t, eventLog.Close())
+
+	// WHEN the agent starts again
+	rc := infra.NewRequestRecorderClient()
+	sender, eventLog = newSender(rc.Client)
+	require.NoError(t, sender.Start())
+
+	// THEN the event is sent
+	req := <-rc.RequestCh
+	body, err := ioutil.ReadAll(req.Body)
+	require.NoError(t, err)
+	assert.Contains(t, string(body), `"value":"pending"`)
+
+	// AND it isn't pending anymore
+	assert.Eventually(t, func() bool {
+		pending, err := eventLog.Pending()
+		return err == nil && len(pending) == 0
+	}, time.Second, 10*time.Millisecond)
+	require.NoError(t, sender.Stop())
+	require.NoError(t, eventLog.Close())
+}
+
+func TestEventSender_ResendsPostedBatchWithSamePayloadID(t *testing.T) {
+	dir, err := ioutil.TempDir("", "event_wal")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	cfg := &config.Config{PayloadCompressionLevel: gzip.NoCompression}
+	newSender := func(client http2.Client) (*metricsIngestSender, *wal.Log) {
+		eventLog, err := wal.Open(dir, eventWALSegmentSize)
+		require.NoError(t, err)
+		c := NewContext(cfg, "1.2.3", testhelpers.NullHostnameResolver, host.IDLookup{}, nil, nil, nil)
+		c.setAgentKey(agentKey)
+		c.eventLog = eventLog
+		return newMetricsIngestSender(c, "license", "userAgent", client, false), eventLog
+	}
+
+	// GIVEN a batch posted by an agent that stopped before acknowledging it
+	sender, eventLog := newSender(http2.NullHttpClient)
+	require.NoError(t, sender.QueueEvent(mapEvent{"eventType": "TestEvent", "value": "posted"}, ""))
+	require.NoError(t, sender.QueueEvent(mapEvent{"eventType": "TestEvent", "value": "queued"}, ""))
+	posted := eventBatch{<-sender.eventQueue}
+	payloadID := sender.sealBatch(posted)
+	require.NotEmpty(t, payloadID)
+	require.NoError(t, eventLog.Close())
+
+	// WHEN the agent starts again
+	rc := infra.NewRequestRecorderClient()
+	sender, eventLog = newSender(rc.Client)
+	require.NoError(t, sender.Start())
+
+	// THEN the posted batch is sent again with the same payload ID
+	req := <-rc.RequestCh
+	assert.Equal(t, payloadID, req.Header.Get(http2.PayloadIDHeader))
+	body, err := ioutil.ReadAll(req.Body)
+	require.NoError(t, err)
+	assert.Contains(t, string(body), `"value":"posted"`)
+	assert.NotContains(t, string(body), `"value":"queued"`)
+
+	// AND the events that weren't posted are sent in a new batch
+	req = <-rc.RequestCh
+	assert.NotEmpty(t, req.Header.Get(http2.PayloadIDHeader))
+	assert.NotEqual(t, payloadID, req.Header.Get(http2.PayloadIDHeader))
+	body, err = ioutil.ReadAll(req.Body)
+	require.NoError(t, err)
+	assert.Contains(t, string(body), `"value":"queued"`)
+
+	// AND nothing is pending anymore
+	assert.Eventually(t, func() bool {
+		pending, err := eventLog.Pending()
+		return err == nil && len(pending) == 0
+	}, time.Second, 10*time.Millisecond)
+	require.NoError(t, sender.Stop())
+	require.NoError(t, eventLog.Close())
+}
+
+func TestEventSender_Flush(t *testing.T) {
+	dir, err := ioutil.TempDir("", "spool")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	payloadSpool, err := spool.New(dir, 1<<20, time.Hour)
+	require.NoError(t, err)
+
+	rc := infra.NewRequestRecorderClient()
+	cfg := &config.Config{PayloadCompressionLevel: gzip.NoCompression}
+	c := NewContext(cfg, "1.2.3", testhelpers.NullHostnameResolver, host.IDLookup{}, nil, nil, nil)
+	c.setAgentKey(agentKey)
+	c.spool = payloadSpool
+	sender := newMetricsIngestSender(c, "license", "userAgent", rc.Client, false)
+
+	// GIVEN events queued to a stopped sender
+	require.NoError(t, sender.QueueEvent(mapEvent{"eventType": "TestEvent", "value": "flushed"}, ""))
+
+	// WHEN it's flushed
+	go func() {
+		assert.NoError(t, sender.Flush(context2.Background()))
+	}()
+
+	// THEN the events are sent
+	req := <-rc.RequestCh
+	body, err := ioutil.ReadAll(req.Body)
+	require.NoError(t, err)
+	assert.Contains(t, string(body), `"value":"flushed"`)
+
+	// WHEN the flush deadline is exceeded
+	require.NoError(t, sender.QueueEvent(mapEvent{"eventType": "TestEvent", "value": "spooled"}, ""))
+	ctx, cancel := context2.WithCancel(context2.Background())
+	cancel()
+	assert.Equal(t, context2.Canceled, sender.Flush(ctx))
+
+	// THEN the events are spooled
+	assert.Equal(t, 1, payloadSpool.Len(spooledEventsKind))
+}
+
 func newTestContext(agentKey string, cfg *config.Config) *context {
 	var atomicAgentKey atomic.Value
 	atomicAgentKey.Store(agentKey)
@@ -548,3 +730,112 @@ func newTestContext(agentKey string, cfg *config.Config) *context {
 		cfg:      cfg,
 	}
 }
+
+func TestEventSender_SendsToAdditionalDestinations(t *testing.T) {
+	// GIVEN an additional destination that only receives samples, with an overridden attribute
+	rc := infra.NewRequestRecorderClient()
+	cfg := &config.Config{
+		PayloadCompressionLevel: gzip.NoCompression,
+		AdditionalDestinations: []config.Destination{
+			{
+				Name:         "customer",
+				LicenseKey:   "customer-license",
+				CollectorURL: "https://customer.example.com",
+				MetricsOnly:  true,
+				Attributes:   map[string]string{"team": "msp"},
+			},
+		},
+	}
+	c := NewContext(cfg, "1.2.3", testhelpers.NullHostnameResolver, host.IDLookup{}, nil, nil, nil)
+	c.setAgentKey(agentKey)
+
+	sender := newMetricsIngestSender(c, "license", "userAgent", rc.Client, false)
+	require.NoError(t, sender.Start())
+	defer sender.Stop()
+
+	// WHEN a sample and a custom event are sent
+	require.NoError(t, sender.QueueEvent(mapEvent{"eventType": "SystemSample", "team": "infra"}, ""))
+	require.NoError(t, sender.QueueEvent(mapEvent{"eventType": "TestEvent"}, ""))
+
+	// THEN the agent account receives both events
+	req := <-rc.RequestCh
+	assert.Equal(t, "license", req.Header.Get(http2.LicenseHeader))
+	body, err := ioutil.ReadAll(req.Body)
+	require.NoError(t, err)
+	assert.Contains(t, string(body), `"eventType":"SystemSample"`)
+	assert.Contains(t, string(body), `"eventType":"TestEvent"`)
+
+	// AND the destination receives only the sample, with its attribute
+	req = <-rc.RequestCh
+	assert.Equal(t, "customer-license", req.Header.Get(http2.LicenseHeader))
+	assert.Equal(t, "https://customer.example.com/events/bulk", req.URL.String())
+	body, err = ioutil.ReadAll(req.Body)
+	require.NoError(t, err)
+	assert.Contains(t, string(body), `"team":"msp"`)
+	assert.NotContains(t, string(body), `"eventType":"TestEvent"`)
+}
+
+func TestEventSender_HangingDestinationDoesNotDelayAgentAccount(t *testing.T) {
+	// GIVEN an additional destination that never answers
+	hang := make(chan struct{})
+	defer close(hang)
+	agentPosts := make(chan struct{}, 10)
+	client := func(req *http.Request) (*http.Response, error) {
+		if req.Header.Get(http2.LicenseHeader) == "customer-license" {
+			<-hang
+			return nil, errors.New("timeout")
+		}
+		agentPosts <- struct{}{}
+		return &http.Response{StatusCode: http.StatusAccepted, Body: ioutil.NopCloser(strings.NewReader("{}"))}, nil
+	}
+	cfg := &config.Config{
+		PayloadCompressionLevel: gzip.NoCompression,
+		AdditionalDestinations: []config.Destination{
+			{Name: "customer", LicenseKey: "customer-license", CollectorURL: "https://customer.example.com"},
+		},
+	}
+	c := NewContext(cfg, "1.2.3", testhelpers.NullHostnameResolver, host.IDLookup{}, nil, nil, nil)
+	c.setAgentKey(agentKey)
+
+	sender := newMetricsIngestSender(c, "license", "userAgent", client, false)
+	require.NoError(t, sender.Start())
+	defer sender.Stop()
+
+	// WHEN several batches are sent
+	for i := 0; i < 3; i++ {
+		require.NoError(t, sender.QueueEvent(mapEvent{"eventType": "TestEvent"}, ""))
+
+		// THEN each of them is submitted to the agent account without waiting for the destination
+		select {
+		case <-agentPosts:
+		case <-time.After(5 * time.Second):
+			t.Fatal("agent account post delayed by the additional destination")
+		}
+	}
+}
+
+func TestEventSender_ShrinksBatchesWhileThrottled(t *testing.T) {
+	// GIVEN a backend throttling the agent
+	rc := infra.NewRequestRecorderClient(infra.TooManyRequestsResponse())
+	cfg := &config.Config{PayloadCompressionLevel: gzip.NoCompression}
+	c := NewContext(cfg, "1.2.3", testhelpers.NullHostnameResolver, host.IDLookup{}, nil, nil, nil)
+	c.setAgentKey(agentKey)
+	c.throttle = throttle.New(4, time.Hour)
+
+	sender := newMetricsIngestSender(c, "license", "userAgent", rc.Client, false)
+	sender.getBackoffTimer = func(time.Duration) *time.Timer {
+		return time.NewTimer(0)
+	}
+	assert.Equal(t, MAX_EVENT_BATCH_COUNT, sender.maxBatchCount())
+	require.NoError(t, sender.Start())
+	defer sender.Stop()
+
+	// WHEN an event post is rejected
+	require.NoError(t, sender.QueueEvent(mapEvent{"eventType": "TestEvent"}, ""))
+	<-rc.RequestCh
+
+	// THEN the following batches are smaller
+	assert.Eventually(t, func() bool { return c.throttle.Factor() == 2 }, time.Second, 10*time.Millisecond)
+	assert.Equal(t, MAX_EVENT_BATCH_COUNT/2, sender.maxBatchCount())
+	assert.Equal(t, config.DefaultMaxMetricsBatchSizeBytes/2, sender.maxBatchSizeBytes())
+}
@@ -3,10 +3,6 @@
 package agent
 
 import (
-	"fmt"
-	"os"
-	"path/filepath"
-
 	"github.com/newrelic/infrastructure-agent/pkg/log"
 
 	"github.com/newrelic/infrastructure-agent/internal/agent/delta"
@@ -34,20 +30,13 @@ func newPatchReaper(entityKey string, store *delta.Store) *patchReaper {
 	}
 }
 
-// CleanupOldPlugins deletes old json from plugins that have been
+// CleanupOldPlugins deletes the stored inventory of plugins that have been
 // deprecated or are no longer used
 func (p *patchReaper) CleanupOldPlugins(plugins []ids.PluginID) {
 	for _, plugin := range plugins {
-		// first check if file exists
-		filename := filepath.Join(p.store.DataDir, plugin.Category, fmt.Sprintf("%s.json", plugin.Term))
-		if _, err := os.Stat(filename); err != nil {
-			continue
-		}
-		// next, remove the source file first, which will show
-		// as a deleted delta
-		if err := os.Remove(filename); err != nil {
+		if err := p.store.RemovePluginSource(p.entityKey, plugin.Category, plugin.Term); err != nil {
 			prlog.WithFields(logrus.Fields{
-				"path":      filename,
+				"plugin":    plugin.String(),
 				"entityKey": p.entityKey,
 			}).WithError(err).Error("failed to delete plugin source file")
 		}
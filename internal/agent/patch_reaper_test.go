@@ -10,6 +10,7 @@ import (
 
 	"github.com/newrelic/infrastructure-agent/internal/agent/delta"
 	"github.com/newrelic/infrastructure-agent/internal/testhelpers"
+	"github.com/newrelic/infrastructure-agent/pkg/plugins/ids"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -144,6 +145,30 @@ func TestPatchReapEmptyDelta(t *testing.T) {
 	assert.Equal(t, fixtureB, cacheB)
 }
 
+func TestPatchReaperCleanupOldPlugins_BoltStore(t *testing.T) {
+	dataDir, err := ioutil.TempDir("", "patch_reaper")
+	require.NoError(t, err)
+	defer os.RemoveAll(dataDir)
+	store := delta.NewBoltStore(dataDir, "default", maxInventoryDataSize)
+	defer store.Close()
+
+	// GIVEN the stored inventory of a current and a deprecated plugin
+	require.NoError(t, store.SavePluginSource("", "packages", "rpm", map[string]interface{}{"bash": map[string]interface{}{"version": "4.4"}}))
+	require.NoError(t, store.SavePluginSource("", "deprecated", "plugin", map[string]interface{}{"item": map[string]interface{}{"value": "1"}}))
+	pr := newPatchReaper("", store)
+
+	// WHEN the deprecated plugin is cleaned up before reaping
+	pr.CleanupOldPlugins([]ids.PluginID{{Category: "deprecated", Term: "plugin"}})
+	pr.Reap()
+
+	// THEN only the current plugin inventory is reaped
+	deltas, err := store.ReadDeltas("")
+	require.NoError(t, err)
+	require.Len(t, deltas, 1)
+	require.Len(t, deltas[0], 1)
+	assert.Equal(t, "packages/rpm", deltas[0][0].Source)
+}
+
 func BenchmarkPatchReapNoDiff(b *testing.B) {
 
 	fixB, err := ioutil.ReadFile(filepath.Join("fixtures", "packages_rpm_delta.json"))
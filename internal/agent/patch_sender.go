@@ -70,7 +70,7 @@ func newPatchSender(entityInfo entity.Entity, context AgentContext, store delta.
 		inventoryURL,
 		context.Config().License,
 		userAgent,
-		context.Config().PayloadCompressionLevel,
+		context.Config().PayloadGzipLevel(),
 		context.EntityKey(),
 		agentIDProvide,
 		context.Config().ConnectEnabled,
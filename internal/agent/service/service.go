@@ -43,6 +43,20 @@ func New(arg ...string) (service.Service, error) {
 	return service.New(svc, cfg)
 }
 
+// Install registers the agent in the service manager of the host, running the given service binary.
+func Install(executable string) error {
+	svc, err := service.New(&Service{}, &service.Config{
+		Name:        svcName,
+		DisplayName: "New Relic Infrastructure Agent",
+		Description: "New Relic Infrastructure Agent",
+		Executable:  executable,
+	})
+	if err != nil {
+		return err
+	}
+	return svc.Install()
+}
+
 type daemon struct {
 	sync.Mutex // daemon can be accessed from different routines.
 	args       []string
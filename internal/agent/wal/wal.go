@@ -0,0 +1,414 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package wal provides a write-ahead log of records stored in segment files, so the records that weren't
+// acknowledged before the agent stopped can be read again after a restart. Records are synced to disk in groups,
+// when Sync is called, and acknowledgements before returning, so they survive a host crash too.
+package wal
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/newrelic/infrastructure-agent/pkg/disk"
+	"github.com/newrelic/infrastructure-agent/pkg/log"
+)
+
+const (
+	dirMode    = 0755
+	fileMode   = 0644
+	segmentExt = ".wal"
+	ackedFile  = "acked"
+	idFile     = "id"
+
+	// record header: ID (8 bytes), data length (4 bytes), data CRC32 (4 bytes)
+	headerSize = 16
+	// maxRecordSize discards corrupted headers instead of allocating huge buffers.
+	maxRecordSize = 64 << 20
+)
+
+var wlog = log.WithComponent("WAL")
+
+var errCorrupted = errors.New("corrupted record")
+
+// Record is an entry of the log. IDs are assigned in increasing order.
+type Record struct {
+	ID   uint64
+	Data []byte
+}
+
+type segment struct {
+	path    string
+	firstID uint64
+	lastID  uint64
+	size    int64
+}
+
+// Log appends records to the current segment, starting a new one once it exceeds the segment size. Segments
+// whose records have all been acknowledged are removed.
+type Log struct {
+	dir         string
+	segmentSize int64
+	id          string
+
+	lock     sync.Mutex
+	segments []*segment
+	current  *os.File
+	dirty    bool // records written to the current segment since the last sync
+	nextID   uint64
+	acked    uint64              // all the records up to this ID are acknowledged
+	done     map[uint64]struct{} // records acknowledged after the acked position
+}
+
+// Open loads the log stored in the passed directory, creating it when it doesn't exist. Incomplete records,
+// written while the agent was stopped abruptly, are discarded.
+func Open(dir string, segmentSize int64) (*Log, error) {
+	if err := disk.MkdirAll(dir, dirMode); err != nil {
+		return nil, fmt.Errorf("can't create WAL directory %s: %s", dir, err)
+	}
+	l := &Log{
+		dir:         dir,
+		segmentSize: segmentSize,
+		nextID:      1,
+		done:        map[uint64]struct{}{},
+	}
+	if err := l.loadID(); err != nil {
+		return nil, err
+	}
+	if err := l.load(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// loadID reads the identifier of the log, generating it when the log is created.
+func (l *Log) loadID() error {
+	path := filepath.Join(l.dir, idFile)
+	content, err := ioutil.ReadFile(path)
+	if err == nil && len(strings.TrimSpace(string(content))) > 0 {
+		l.id = strings.TrimSpace(string(content))
+		return nil
+	}
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	random := make([]byte, 16)
+	if _, err := rand.Read(random); err != nil {
+		return err
+	}
+	l.id = hex.EncodeToString(random)
+	return writeSynced(path, []byte(l.id))
+}
+
+// ID returns the identifier of the log, which is kept across restarts. Along with the record IDs, it identifies
+// the records of the log among the ones of other logs.
+func (l *Log) ID() string {
+	return l.id
+}
+
+func (l *Log) load() error {
+	if content, err := ioutil.ReadFile(filepath.Join(l.dir, ackedFile)); err == nil {
+		if l.acked, err = strconv.ParseUint(strings.TrimSpace(string(content)), 10, 64); err != nil {
+			wlog.WithError(err).Warn("Invalid acknowledged WAL position, resending all the records.")
+			l.acked = 0
+		}
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	files, err := ioutil.ReadDir(l.dir)
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		if file.IsDir() || !strings.HasSuffix(file.Name(), segmentExt) {
+			continue
+		}
+		firstID, err := strconv.ParseUint(strings.TrimSuffix(file.Name(), segmentExt), 10, 64)
+		if err != nil {
+			continue
+		}
+		seg := &segment{path: filepath.Join(l.dir, file.Name()), firstID: firstID}
+		records, size, err := readSegment(seg.path)
+		if err != nil {
+			return err
+		}
+		// records after a torn write are lost, the segment is truncated to append after the last valid one
+		if size < file.Size() {
+			wlog.WithField("segment", seg.path).Warn("Discarding incomplete WAL records.")
+			if err := os.Truncate(seg.path, size); err != nil {
+				return err
+			}
+		}
+		seg.size = size
+		seg.lastID = firstID - 1
+		if len(records) > 0 {
+			seg.lastID = records[len(records)-1].ID
+		}
+		if seg.lastID >= l.nextID {
+			l.nextID = seg.lastID + 1
+		}
+		l.segments = append(l.segments, seg)
+	}
+	sort.Slice(l.segments, func(i, j int) bool { return l.segments[i].firstID < l.segments[j].firstID })
+	if l.acked >= l.nextID {
+		l.nextID = l.acked + 1
+	}
+	l.removeAckedSegments()
+	return nil
+}
+
+// readSegment returns the valid records of a segment and the size they take.
+func readSegment(path string) (records []Record, size int64, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	header := make([]byte, headerSize)
+	for {
+		if _, err = io.ReadFull(r, header); err != nil {
+			break
+		}
+		id := binary.BigEndian.Uint64(header[0:8])
+		length := binary.BigEndian.Uint32(header[8:12])
+		checksum := binary.BigEndian.Uint32(header[12:16])
+		if length > maxRecordSize {
+			err = errCorrupted
+			break
+		}
+		data := make([]byte, length)
+		if _, err = io.ReadFull(r, data); err != nil {
+			break
+		}
+		if crc32.ChecksumIEEE(data) != checksum {
+			err = errCorrupted
+			break
+		}
+		records = append(records, Record{ID: id, Data: data})
+		size += headerSize + int64(length)
+	}
+	if err == io.EOF || err == io.ErrUnexpectedEOF || err == errCorrupted {
+		err = nil
+	}
+	return records, size, err
+}
+
+// Append stores a record, returning its ID. The record isn't synced to disk until Sync is called.
+func (l *Log) Append(data []byte) (uint64, error) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	seg, err := l.writableSegment()
+	if err != nil {
+		return 0, err
+	}
+
+	id := l.nextID
+	buf := make([]byte, headerSize+len(data))
+	binary.BigEndian.PutUint64(buf[0:8], id)
+	binary.BigEndian.PutUint32(buf[8:12], uint32(len(data)))
+	binary.BigEndian.PutUint32(buf[12:16], crc32.ChecksumIEEE(data))
+	copy(buf[headerSize:], data)
+	// a single write, so a crashing agent leaves at most one incomplete record
+	if _, err := l.current.Write(buf); err != nil {
+		return 0, err
+	}
+	l.dirty = true
+	l.nextID++
+	seg.lastID = id
+	seg.size += int64(len(buf))
+	return id, nil
+}
+
+// writableSegment returns the current segment, starting a new one when it's full.
+func (l *Log) writableSegment() (*segment, error) {
+	if l.current != nil {
+		seg := l.segments[len(l.segments)-1]
+		if seg.size < l.segmentSize {
+			return seg, nil
+		}
+		if err := l.syncCurrent(); err != nil {
+			wlog.WithError(err).Warn("Can't sync WAL segment.")
+		}
+		if err := l.current.Close(); err != nil {
+			wlog.WithError(err).Warn("Can't close WAL segment.")
+		}
+		l.current = nil
+	}
+
+	var seg *segment
+	if n := len(l.segments); n > 0 && l.segments[n-1].size < l.segmentSize {
+		seg = l.segments[n-1]
+	} else {
+		seg = &segment{
+			path:    filepath.Join(l.dir, fmt.Sprintf("%020d%s", l.nextID, segmentExt)),
+			firstID: l.nextID,
+			lastID:  l.nextID - 1,
+		}
+		l.segments = append(l.segments, seg)
+	}
+	f, err := disk.OpenFile(seg.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, fileMode)
+	if err != nil {
+		return nil, err
+	}
+	l.current = f
+	l.syncDir()
+	return seg, nil
+}
+
+// Sync syncs to disk the records appended since the last sync, so a group of records is synced at once.
+func (l *Log) Sync() error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	return l.syncCurrent()
+}
+
+func (l *Log) syncCurrent() error {
+	if l.current == nil || !l.dirty {
+		return nil
+	}
+	if err := l.current.Sync(); err != nil {
+		return err
+	}
+	l.dirty = false
+	return nil
+}
+
+// Pending returns the records that haven't been acknowledged, in the order they were appended.
+func (l *Log) Pending() ([]Record, error) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	var pending []Record
+	for _, seg := range l.segments {
+		if seg.lastID <= l.acked {
+			continue
+		}
+		records, _, err := readSegment(seg.path)
+		if err != nil {
+			return pending, err
+		}
+		for _, record := range records {
+			if record.ID > l.acked {
+				pending = append(pending, record)
+			}
+		}
+	}
+	return pending, nil
+}
+
+// Ack acknowledges the passed records, which may be acknowledged in any order. The records are kept as pending
+// after a restart until all the previous ones are acknowledged too.
+func (l *Log) Ack(ids ...uint64) error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	for _, id := range ids {
+		if id > l.acked && id < l.nextID {
+			l.done[id] = struct{}{}
+		}
+	}
+	id := l.acked
+	for {
+		if _, ok := l.done[id+1]; !ok {
+			break
+		}
+		id++
+	}
+	if id == l.acked {
+		return nil
+	}
+	// written to a temporary file and renamed, so the position isn't lost if the agent stops while writing it
+	tmp := filepath.Join(l.dir, ackedFile+".tmp")
+	if err := writeSynced(tmp, []byte(strconv.FormatUint(id, 10))); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, filepath.Join(l.dir, ackedFile)); err != nil {
+		return err
+	}
+	l.syncDir()
+	for acked := l.acked + 1; acked <= id; acked++ {
+		delete(l.done, acked)
+	}
+	l.acked = id
+	l.removeAckedSegments()
+	return nil
+}
+
+// writeSynced writes the file contents, syncing them to disk before closing it.
+func writeSynced(path string, data []byte) error {
+	f, err := disk.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, fileMode)
+	if err != nil {
+		return err
+	}
+	if _, err = f.Write(data); err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// syncDir syncs the log directory, so the created and renamed files survive a host crash. Some platforms
+// (e.g. Windows) don't support syncing directories, so it is done on a best-effort basis.
+func (l *Log) syncDir() {
+	d, err := os.Open(l.dir)
+	if err != nil {
+		return
+	}
+	defer d.Close()
+	if err := d.Sync(); err != nil {
+		wlog.WithError(err).Debug("Can't sync WAL directory.")
+	}
+}
+
+// removeAckedSegments removes the segments all whose records have been acknowledged, except the current one.
+func (l *Log) removeAckedSegments() {
+	kept := l.segments[:0]
+	for i, seg := range l.segments {
+		last := i == len(l.segments)-1
+		if seg.lastID > l.acked || (last && l.current != nil) {
+			kept = append(kept, seg)
+			continue
+		}
+		if err := os.Remove(seg.path); err != nil && !os.IsNotExist(err) {
+			wlog.WithError(err).WithField("segment", seg.path).Warn("Can't remove acknowledged WAL segment.")
+			kept = append(kept, seg)
+		}
+	}
+	l.segments = kept
+}
+
+// Close closes the current segment.
+func (l *Log) Close() error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if l.current == nil {
+		return nil
+	}
+	err := l.syncCurrent()
+	if closeErr := l.current.Close(); err == nil {
+		err = closeErr
+	}
+	l.current = nil
+	return err
+}
@@ -0,0 +1,171 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package wal
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func appendAll(t *testing.T, l *Log, data ...string) {
+	for _, d := range data {
+		_, err := l.Append([]byte(d))
+		require.NoError(t, err)
+	}
+}
+
+func pendingData(t *testing.T, l *Log) (data []string) {
+	records, err := l.Pending()
+	require.NoError(t, err)
+	for _, record := range records {
+		data = append(data, string(record.Data))
+	}
+	return data
+}
+
+func segments(t *testing.T, dir string) []string {
+	matches, err := filepath.Glob(filepath.Join(dir, "*"+segmentExt))
+	require.NoError(t, err)
+	return matches
+}
+
+func TestLog_PendingAfterReopen(t *testing.T) {
+	dir, err := ioutil.TempDir("", "wal")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	// GIVEN records appended and partially acknowledged
+	l, err := Open(dir, 1<<20)
+	require.NoError(t, err)
+	appendAll(t, l, "first", "second", "third")
+	require.NoError(t, l.Ack(1))
+	require.NoError(t, l.Close())
+
+	// WHEN the log is opened again
+	l, err = Open(dir, 1<<20)
+	require.NoError(t, err)
+	defer l.Close()
+
+	// THEN the records not acknowledged are pending
+	assert.Equal(t, []string{"second", "third"}, pendingData(t, l))
+
+	// AND new records keep increasing IDs
+	id, err := l.Append([]byte("fourth"))
+	require.NoError(t, err)
+	assert.Equal(t, uint64(4), id)
+}
+
+func TestLog_AckRemovesSegments(t *testing.T) {
+	dir, err := ioutil.TempDir("", "wal")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	l, err := Open(dir, 10)
+	require.NoError(t, err)
+	defer l.Close()
+
+	// GIVEN records larger than the segment size
+	appendAll(t, l, "first", "second", "third")
+	require.Len(t, segments(t, dir), 3)
+
+	// WHEN they are acknowledged
+	require.NoError(t, l.Ack(1, 2, 3))
+
+	// THEN only the current segment is kept
+	assert.Len(t, segments(t, dir), 1)
+	assert.Empty(t, pendingData(t, l))
+}
+
+func TestLog_DiscardsIncompleteRecords(t *testing.T) {
+	dir, err := ioutil.TempDir("", "wal")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	l, err := Open(dir, 1<<20)
+	require.NoError(t, err)
+	appendAll(t, l, "first", "second")
+	require.NoError(t, l.Close())
+
+	// GIVEN the last record was partially written
+	files := segments(t, dir)
+	require.Len(t, files, 1)
+	info, err := os.Stat(files[0])
+	require.NoError(t, err)
+	require.NoError(t, os.Truncate(files[0], info.Size()-2))
+
+	// WHEN the log is opened again
+	l, err = Open(dir, 1<<20)
+	require.NoError(t, err)
+	defer l.Close()
+
+	// THEN the incomplete record is discarded
+	assert.Equal(t, []string{"first"}, pendingData(t, l))
+
+	// AND new records are appended after the last valid one
+	appendAll(t, l, "third")
+	assert.Equal(t, []string{"first", "third"}, pendingData(t, l))
+}
+
+func TestLog_AckOutOfOrder(t *testing.T) {
+	dir, err := ioutil.TempDir("", "wal")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	l, err := Open(dir, 1<<20)
+	require.NoError(t, err)
+
+	// GIVEN records acknowledged out of order
+	appendAll(t, l, "first", "second", "third", "fourth")
+	require.NoError(t, l.Ack(2, 4))
+
+	// THEN only the records before the first unacknowledged one are acknowledged
+	assert.Equal(t, []string{"first", "second", "third", "fourth"}, pendingData(t, l))
+
+	// WHEN the previous records are acknowledged
+	require.NoError(t, l.Ack(1))
+
+	// THEN the acknowledged position moves up to the next unacknowledged record
+	assert.Equal(t, []string{"third", "fourth"}, pendingData(t, l))
+
+	// AND it is kept after a restart
+	require.NoError(t, l.Close())
+	l, err = Open(dir, 1<<20)
+	require.NoError(t, err)
+	defer l.Close()
+	assert.Equal(t, []string{"third", "fourth"}, pendingData(t, l))
+}
+
+func TestLog_ID(t *testing.T) {
+	dir, err := ioutil.TempDir("", "wal")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	// GIVEN a new log
+	l, err := Open(dir, 1<<20)
+	require.NoError(t, err)
+	id := l.ID()
+	assert.Len(t, id, 32)
+	appendAll(t, l, "first")
+	require.NoError(t, l.Sync())
+	require.NoError(t, l.Close())
+
+	// WHEN it is opened again
+	l, err = Open(dir, 1<<20)
+	require.NoError(t, err)
+	defer l.Close()
+
+	// THEN it keeps its identifier
+	assert.Equal(t, id, l.ID())
+
+	// AND the logs in other directories have other identifiers
+	other, err := ioutil.TempDir("", "wal")
+	require.NoError(t, err)
+	defer os.RemoveAll(other)
+	o, err := Open(other, 1<<20)
+	require.NoError(t, err)
+	defer o.Close()
+	assert.NotEqual(t, id, o.ID())
+}
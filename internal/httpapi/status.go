@@ -0,0 +1,102 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+// Package httpapi provides a local HTTP server exposing the agent status.
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/newrelic/infrastructure-agent/internal/integrations/v4/breaker"
+	backendhttp "github.com/newrelic/infrastructure-agent/pkg/backend/http"
+	"github.com/newrelic/infrastructure-agent/pkg/log"
+	"github.com/newrelic/infrastructure-agent/pkg/metrics/recent"
+)
+
+const (
+	integrationsStatusPath = "/v1/status/integrations"
+	samplesStatusPath      = "/v1/status/samples"
+	connectionsStatusPath  = "/v1/status/connections"
+)
+
+var slog = log.WithComponent("StatusServer")
+
+// StatusServer serves the agent status through HTTP on localhost.
+type StatusServer struct {
+	port        int
+	breakers    *breaker.Registry
+	samples     *recent.Store
+	connections *backendhttp.ConnStats
+}
+
+// NewStatusServer creates a status server listening on the given localhost port. The recent samples
+// and the outbound connections usage are only served if they are provided.
+func NewStatusServer(port int, breakers *breaker.Registry, samples *recent.Store, connections *backendhttp.ConnStats) *StatusServer {
+	return &StatusServer{
+		port:        port,
+		breakers:    breakers,
+		samples:     samples,
+		connections: connections,
+	}
+}
+
+// Serve blocks serving the status requests until the context is cancelled.
+func (s *StatusServer) Serve(ctx context.Context) {
+	server := &http.Server{
+		Addr:    fmt.Sprintf("localhost:%d", s.port),
+		Handler: s.router(),
+	}
+
+	go func() {
+		<-ctx.Done()
+		if err := server.Shutdown(context.Background()); err != nil {
+			slog.WithError(err).Debug("Status server shutdown.")
+		}
+	}()
+
+	slog.WithField("port", s.port).Debug("Status server starting listening.")
+	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		slog.WithError(err).Error("unable to start status server")
+	}
+}
+
+func (s *StatusServer) router() http.Handler {
+	router := httprouter.New()
+	router.GET(integrationsStatusPath, s.integrationsHandler)
+	if s.samples != nil {
+		router.GET(samplesStatusPath, s.samplesHandler)
+	}
+	if s.connections != nil {
+		router.GET(connectionsStatusPath, s.connectionsHandler)
+	}
+	return router
+}
+
+func (s *StatusServer) integrationsHandler(w http.ResponseWriter, _ *http.Request, _ httprouter.Params) {
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	if err := json.NewEncoder(w).Encode(s.breakers.Status()); err != nil {
+		slog.WithError(err).Warn("couldn't encode integrations status")
+	}
+}
+
+func (s *StatusServer) samplesHandler(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	query, err := recent.ParseQuery(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	if err := json.NewEncoder(w).Encode(s.samples.Query(query)); err != nil {
+		slog.WithError(err).Warn("couldn't encode recent samples")
+	}
+}
+
+func (s *StatusServer) connectionsHandler(w http.ResponseWriter, _ *http.Request, _ httprouter.Params) {
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	if err := json.NewEncoder(w).Encode(s.connections.Status()); err != nil {
+		slog.WithError(err).Warn("couldn't encode connections status")
+	}
+}
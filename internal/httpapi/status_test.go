@@ -0,0 +1,79 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package httpapi
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/newrelic/infrastructure-agent/internal/integrations/v4/breaker"
+	"github.com/newrelic/infrastructure-agent/pkg/metrics/recent"
+	"github.com/newrelic/infrastructure-agent/pkg/sample"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStatusServer_Integrations(t *testing.T) {
+	// GIVEN an integration whose circuit breaker has been tripped
+	breakers := breaker.NewRegistry(breaker.Config{Threshold: 1}, nil)
+	breakers.Get("nri-failing", "", "").Failure(errors.New("exit status 1"))
+	breakers.Get("nri-working", "", "").Success()
+
+	// WHEN the integrations status is requested
+	rec := httptest.NewRecorder()
+	NewStatusServer(0, breakers, nil, nil).router().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, integrationsStatusPath, nil))
+
+	// THEN the state of all the integrations is returned
+	require.Equal(t, http.StatusOK, rec.Code)
+	var status []breaker.Status
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &status))
+	require.Len(t, status, 2)
+	assert.Equal(t, "nri-failing", status[0].Name)
+	assert.Equal(t, breaker.StateOpen, status[0].State)
+	assert.Equal(t, "exit status 1", status[0].LastError)
+	assert.NotNil(t, status[0].DisabledUntil)
+	assert.Equal(t, "nri-working", status[1].Name)
+	assert.Equal(t, breaker.StateClosed, status[1].State)
+}
+
+func TestStatusServer_Samples(t *testing.T) {
+	// GIVEN a server with the recently emitted samples
+	samples := recent.NewStore(time.Hour, 10)
+	for _, name := range []string{"nginx", "mysqld"} {
+		s := &struct {
+			sample.BaseEvent
+			ProcessDisplayName string `json:"processDisplayName"`
+		}{ProcessDisplayName: name}
+		s.Type("ProcessSample")
+		samples.Add(s)
+	}
+	server := NewStatusServer(0, breaker.NewRegistry(breaker.Config{}, nil), samples, nil)
+
+	// WHEN they are queried with a filter
+	rec := httptest.NewRecorder()
+	server.router().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, samplesStatusPath+"?eventType=ProcessSample&where=processDisplayName=nginx", nil))
+
+	// THEN the matching samples are returned
+	require.Equal(t, http.StatusOK, rec.Code)
+	var results []map[string]interface{}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &results))
+	require.Len(t, results, 1)
+	assert.Equal(t, "nginx", results[0]["processDisplayName"])
+
+	// AND invalid queries are rejected
+	rec = httptest.NewRecorder()
+	server.router().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, samplesStatusPath+"?since=never", nil))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestStatusServer_SamplesDisabled(t *testing.T) {
+	rec := httptest.NewRecorder()
+	NewStatusServer(0, breaker.NewRegistry(breaker.Config{}, nil), nil, nil).router().
+		ServeHTTP(rec, httptest.NewRequest(http.MethodGet, samplesStatusPath, nil))
+
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
@@ -0,0 +1,277 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+// Package breaker implements a circuit breaker that temporarily disables the integrations that
+// repeatedly fail, so they don't keep spamming errors on every execution.
+package breaker
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/newrelic/infrastructure-agent/pkg/backend/backoff"
+	"github.com/newrelic/infrastructure-agent/pkg/entity"
+	"github.com/newrelic/infrastructure-agent/pkg/log"
+	"github.com/newrelic/infrastructure-agent/pkg/sample"
+)
+
+// Breaker states.
+const (
+	StateClosed   = "closed"
+	StateOpen     = "open"
+	StateHalfOpen = "half-open"
+)
+
+const (
+	DefaultMinBackoff = 1 * time.Minute
+	DefaultMaxBackoff = 1 * time.Hour
+)
+
+var (
+	blog    = log.WithComponent("integrations.Breaker")
+	timeNow = time.Now
+)
+
+// SendEventFn submits an event to the platform.
+type SendEventFn func(event sample.Event, entityKey entity.Key)
+
+// Config for the integrations circuit breakers.
+type Config struct {
+	// Threshold is the number of consecutive failed executions that trips the breaker.
+	// Zero or negative values disable the circuit breaker.
+	Threshold int
+	// MinBackoff is the time an integration is disabled the first time the breaker trips.
+	// It is doubled on each consecutive trip, up to MaxBackoff.
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+}
+
+// Registry holds the circuit breakers of the integrations, indexed by the config file and entry
+// they were loaded from, so their state survives the integrations being reloaded and entries
+// sharing the same integration name don't disable each other.
+type Registry struct {
+	cfg       Config
+	sendEvent SendEventFn
+	lock      sync.Mutex
+	breakers  map[string]*Breaker
+}
+
+// NewRegistry creates a circuit breakers Registry. sendEvent is optional (nil allowed).
+func NewRegistry(cfg Config, sendEvent SendEventFn) *Registry {
+	if cfg.MinBackoff <= 0 {
+		cfg.MinBackoff = DefaultMinBackoff
+	}
+	if cfg.MaxBackoff < cfg.MinBackoff {
+		cfg.MaxBackoff = cfg.MinBackoff
+	}
+	return &Registry{
+		cfg:       cfg,
+		sendEvent: sendEvent,
+		breakers:  map[string]*Breaker{},
+	}
+}
+
+// Get returns the circuit breaker for the integration entry identified by its config file path and
+// config hash. Entries that are not loaded from a file (empty path and hash) are identified by their
+// name. A nil Breaker, which never trips, is returned if the registry is nil or the circuit breaker
+// is disabled.
+func (r *Registry) Get(integrationName, configPath, configHash string) *Breaker {
+	if r == nil || r.cfg.Threshold <= 0 {
+		return nil
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	key := integrationName
+	if configPath != "" || configHash != "" {
+		key = configPath + "#" + configHash
+	}
+
+	b, ok := r.breakers[key]
+	if !ok {
+		b = &Breaker{
+			name:       integrationName,
+			configPath: configPath,
+			threshold:  r.cfg.Threshold,
+			sendEvent:  r.sendEvent,
+			backoff: &backoff.Backoff{
+				Factor: backoff.DefaultFactor,
+				Min:    r.cfg.MinBackoff,
+				Max:    r.cfg.MaxBackoff,
+			},
+		}
+		r.breakers[key] = b
+	}
+	return b
+}
+
+// Status returns the state of all the circuit breakers, sorted by integration name and config path.
+func (r *Registry) Status() []Status {
+	if r == nil {
+		return []Status{}
+	}
+
+	r.lock.Lock()
+	breakers := make([]*Breaker, 0, len(r.breakers))
+	for _, b := range r.breakers {
+		breakers = append(breakers, b)
+	}
+	r.lock.Unlock()
+
+	status := make([]Status, 0, len(breakers))
+	for _, b := range breakers {
+		status = append(status, b.Status())
+	}
+	sort.Slice(status, func(i, j int) bool {
+		if status[i].Name != status[j].Name {
+			return status[i].Name < status[j].Name
+		}
+		return status[i].ConfigPath < status[j].ConfigPath
+	})
+	return status
+}
+
+// Status of an integration circuit breaker.
+type Status struct {
+	Name                string     `json:"name"`
+	ConfigPath          string     `json:"config_path,omitempty"`
+	State               string     `json:"state"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	LastError           string     `json:"last_error,omitempty"`
+	DisabledUntil       *time.Time `json:"disabled_until,omitempty"`
+}
+
+// Breaker tracks the consecutive failures of an integration. Once they reach the threshold,
+// the integration is disabled during an exponentially increasing period of time. After that
+// period, the next execution decides whether the breaker is closed again or keeps open.
+// A nil Breaker is valid and never trips.
+type Breaker struct {
+	name       string
+	configPath string
+	threshold  int
+	sendEvent  SendEventFn
+	backoff    *backoff.Backoff
+	lock       sync.Mutex
+	failures   int
+	lastError  string
+	openUntil  time.Time
+}
+
+// Allow returns whether the integration can be executed.
+func (b *Breaker) Allow() bool {
+	if b == nil {
+		return true
+	}
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	return !timeNow().Before(b.openUntil)
+}
+
+// Success records a successful execution, closing the breaker.
+func (b *Breaker) Success() {
+	if b == nil {
+		return
+	}
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if b.failures >= b.threshold {
+		blog.WithField("integration_name", b.name).
+			WithField("config_path", b.configPath).
+			Info("Integration recovered. Enabling it again.")
+	}
+	b.failures = 0
+	b.lastError = ""
+	b.openUntil = time.Time{}
+	b.backoff.Reset()
+}
+
+// Failure records a failed execution, tripping the breaker if the threshold is reached.
+func (b *Breaker) Failure(reason error) {
+	if b == nil {
+		return
+	}
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.failures++
+	if reason != nil {
+		b.lastError = reason.Error()
+	}
+	if b.failures < b.threshold {
+		return
+	}
+
+	disabledFor := b.backoff.Duration()
+	b.openUntil = timeNow().Add(disabledFor)
+
+	blog.WithField("integration_name", b.name).
+		WithField("config_path", b.configPath).
+		WithField("consecutive_failures", b.failures).
+		WithField("disabled_for", disabledFor).
+		WithField("last_error", b.lastError).
+		Warn("integration failed repeatedly. Disabling it temporarily")
+
+	if b.sendEvent != nil {
+		b.sendEvent(newTrippedEvent(b.name, b.configPath, b.failures, disabledFor, b.lastError), "")
+	}
+}
+
+// Status returns the current state of the breaker.
+func (b *Breaker) Status() Status {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	s := Status{
+		Name:                b.name,
+		ConfigPath:          b.configPath,
+		State:               StateClosed,
+		ConsecutiveFailures: b.failures,
+		LastError:           b.lastError,
+	}
+	if b.failures >= b.threshold {
+		if timeNow().Before(b.openUntil) {
+			s.State = StateOpen
+			openUntil := b.openUntil
+			s.DisabledUntil = &openUntil
+		} else {
+			s.State = StateHalfOpen
+		}
+	}
+	return s
+}
+
+// TrippedEvent is submitted as an InfrastructureEvent when an integration is disabled by its breaker.
+type TrippedEvent struct {
+	sample.BaseEvent
+	Category            string `json:"category"`
+	Summary             string `json:"summary"`
+	IntegrationName     string `json:"integrationName"`
+	ConfigPath          string `json:"configPath,omitempty"`
+	ConsecutiveFailures int    `json:"consecutiveFailures"`
+	DisabledSeconds     int64  `json:"disabledSeconds"`
+	LastError           string `json:"lastError,omitempty"`
+}
+
+func newTrippedEvent(name, configPath string, failures int, disabledFor time.Duration, lastError string) *TrippedEvent {
+	return &TrippedEvent{
+		BaseEvent: sample.BaseEvent{
+			EventType: "InfrastructureEvent",
+			Timestmp:  timeNow().Unix(),
+		},
+		Category: "notifications",
+		Summary: fmt.Sprintf("Integration %s disabled for %s after %d consecutive failures",
+			name, disabledFor, failures),
+		IntegrationName:     name,
+		ConfigPath:          configPath,
+		ConsecutiveFailures: failures,
+		DisabledSeconds:     int64(disabledFor.Seconds()),
+		LastError:           lastError,
+	}
+}
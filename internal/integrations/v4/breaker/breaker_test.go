@@ -0,0 +1,142 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package breaker
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/newrelic/infrastructure-agent/pkg/entity"
+	"github.com/newrelic/infrastructure-agent/pkg/sample"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBreaker_TripsAfterThreshold(t *testing.T) {
+	now := time.Now()
+	timeNow = func() time.Time { return now }
+	defer func() { timeNow = time.Now }()
+
+	var events []sample.Event
+	r := NewRegistry(Config{Threshold: 3, MinBackoff: time.Minute, MaxBackoff: time.Hour},
+		func(event sample.Event, _ entity.Key) { events = append(events, event) })
+
+	// GIVEN an integration that fails less times than the threshold
+	b := r.Get("nri-test", "", "")
+	b.Failure(errors.New("exit status 1"))
+	b.Failure(errors.New("exit status 1"))
+
+	// THEN it is still allowed to run
+	assert.True(t, b.Allow())
+	assert.Equal(t, StateClosed, b.Status().State)
+	assert.Empty(t, events)
+
+	// WHEN it fails again
+	b.Failure(errors.New("exit status 2"))
+
+	// THEN it is disabled
+	assert.False(t, b.Allow())
+	status := b.Status()
+	assert.Equal(t, StateOpen, status.State)
+	assert.Equal(t, 3, status.ConsecutiveFailures)
+	assert.Equal(t, "exit status 2", status.LastError)
+	require.NotNil(t, status.DisabledUntil)
+	assert.Equal(t, now.Add(time.Minute), *status.DisabledUntil)
+
+	// AND an event is submitted
+	require.Len(t, events, 1)
+	event, ok := events[0].(*TrippedEvent)
+	require.True(t, ok)
+	assert.Equal(t, "InfrastructureEvent", event.EventType)
+	assert.Equal(t, "nri-test", event.IntegrationName)
+	assert.Equal(t, int64(60), event.DisabledSeconds)
+}
+
+func TestBreaker_ExponentialBackoff(t *testing.T) {
+	now := time.Now()
+	timeNow = func() time.Time { return now }
+	defer func() { timeNow = time.Now }()
+
+	// GIVEN a tripped breaker
+	b := NewRegistry(Config{Threshold: 1, MinBackoff: time.Minute, MaxBackoff: 3 * time.Minute}, nil).Get("nri-test", "", "")
+	b.Failure(nil)
+	assert.Equal(t, now.Add(time.Minute), *b.Status().DisabledUntil)
+
+	// WHEN the integration fails again after the backoff
+	now = now.Add(time.Minute)
+	assert.True(t, b.Allow())
+	assert.Equal(t, StateHalfOpen, b.Status().State)
+	b.Failure(nil)
+
+	// THEN the backoff is doubled
+	assert.False(t, b.Allow())
+	assert.Equal(t, now.Add(2*time.Minute), *b.Status().DisabledUntil)
+
+	// AND it is limited by the max backoff
+	now = now.Add(2 * time.Minute)
+	b.Failure(nil)
+	assert.Equal(t, now.Add(3*time.Minute), *b.Status().DisabledUntil)
+
+	// WHEN the integration succeeds
+	now = now.Add(3 * time.Minute)
+	b.Success()
+
+	// THEN the breaker is closed and reset
+	assert.True(t, b.Allow())
+	assert.Equal(t, Status{Name: "nri-test", State: StateClosed}, b.Status())
+	b.Failure(nil)
+	assert.Equal(t, now.Add(time.Minute), *b.Status().DisabledUntil)
+}
+
+func TestRegistry_Disabled(t *testing.T) {
+	var r *Registry
+	assert.Nil(t, r.Get("nri-test", "", ""))
+	assert.Empty(t, r.Status())
+
+	r = NewRegistry(Config{Threshold: 0}, nil)
+	b := r.Get("nri-test", "", "")
+	assert.Nil(t, b)
+
+	// a nil breaker never trips
+	b.Failure(errors.New("exit status 1"))
+	assert.True(t, b.Allow())
+}
+
+func TestRegistry_Status(t *testing.T) {
+	r := NewRegistry(Config{Threshold: 2}, nil)
+	r.Get("nri-b", "", "").Failure(errors.New("parse error"))
+	r.Get("nri-a", "", "").Success()
+
+	assert.Equal(t, []Status{
+		{Name: "nri-a", State: StateClosed},
+		{Name: "nri-b", State: StateClosed, ConsecutiveFailures: 1, LastError: "parse error"},
+	}, r.Status())
+}
+
+func TestRegistry_EntriesWithSameName(t *testing.T) {
+	r := NewRegistry(Config{Threshold: 1}, nil)
+
+	// GIVEN two entries of the same integration, loaded from different files
+	broken := r.Get("nri-flex", "/etc/newrelic-infra/integrations.d/a.yml", "hash-a")
+	working := r.Get("nri-flex", "/etc/newrelic-infra/integrations.d/b.yml", "hash-a")
+
+	// WHEN one of them fails
+	broken.Failure(errors.New("exit status 1"))
+
+	// THEN only the failing entry is disabled
+	assert.False(t, broken.Allow())
+	assert.True(t, working.Allow())
+
+	// AND each entry has its own status
+	status := r.Status()
+	require.Len(t, status, 2)
+	assert.Equal(t, "/etc/newrelic-infra/integrations.d/a.yml", status[0].ConfigPath)
+	assert.Equal(t, StateOpen, status[0].State)
+	assert.Equal(t, "/etc/newrelic-infra/integrations.d/b.yml", status[1].ConfigPath)
+	assert.Equal(t, StateClosed, status[1].State)
+
+	// AND the same entry gets the same breaker
+	assert.Same(t, broken, r.Get("nri-flex", "/etc/newrelic-infra/integrations.d/a.yml", "hash-a"))
+	assert.NotSame(t, broken, r.Get("nri-flex", "/etc/newrelic-infra/integrations.d/a.yml", "hash-b"))
+}
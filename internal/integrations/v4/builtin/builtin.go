@@ -0,0 +1,83 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+// Package builtin implements lightweight integrations that run inside the agent process, so common
+// services can be monitored with a single line in integrations.d, without installing any integration binary.
+// Built-in collectors write a protocol v3 payload, as an external integration would do.
+package builtin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/newrelic/infrastructure-agent/internal/integrations/v4/executor"
+	"github.com/newrelic/infrastructure-agent/pkg/integrations/v4/protocol"
+)
+
+// collectTimeout bounds the time a collector may wait for the monitored service.
+const collectTimeout = 10 * time.Second
+
+// Collector retrieves the metric samples of a service, given the arguments of its configuration entry.
+type Collector func(ctx context.Context, args []string) ([]protocol.MetricData, error)
+
+var collectors = map[string]Collector{
+	"nginx_status":  nginxStatus,
+	"haproxy_stats": haproxyStats,
+}
+
+// Exists returns whether a built-in collector with the given name exists.
+func Exists(name string) bool {
+	_, ok := collectors[name]
+	return ok
+}
+
+// Names returns the names of the built-in collectors, sorted alphabetically.
+func Names() []string {
+	names := make([]string, 0, len(collectors))
+	for name := range collectors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Execute runs the given built-in collector in background, forwarding its payload or error as an
+// executed integration would do. All the channels are closed when the collector finishes.
+func Execute(ctx context.Context, name string, args []string) executor.OutputReceive {
+	out, receiver := executor.NewOutput()
+	go func() {
+		defer out.Close()
+		ctx, cancel := context.WithTimeout(ctx, collectTimeout)
+		defer cancel()
+		payload, err := collect(ctx, name, args)
+		if err != nil {
+			out.Errors <- err
+			return
+		}
+		out.Stdout <- payload
+	}()
+	return receiver
+}
+
+func collect(ctx context.Context, name string, args []string) ([]byte, error) {
+	collector, ok := collectors[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown built-in collector %q. Available: %s", name, strings.Join(Names(), ", "))
+	}
+	metrics, err := collector(ctx, args)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	return json.Marshal(protocol.PluginDataV3{
+		PluginOutputIdentifier: protocol.PluginOutputIdentifier{
+			Name:               "com.newrelic.builtin." + name,
+			RawProtocolVersion: "3",
+		},
+		DataSets: []protocol.PluginDataSetV3{{
+			PluginDataSet: protocol.PluginDataSet{Metrics: metrics},
+		}},
+	})
+}
@@ -0,0 +1,137 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package builtin
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/newrelic/infrastructure-agent/pkg/integrations/v4/protocol"
+)
+
+// HAProxy "show stat" CSV columns reported as HAProxyStatsSample attributes, by metric name.
+var haproxyNumericFields = map[string]string{
+	"qcur":      "queue.current",
+	"scur":      "session.current",
+	"smax":      "session.max",
+	"slim":      "session.limit",
+	"stot":      "session.total",
+	"rate":      "session.perSecond",
+	"bin":       "net.bytesIn",
+	"bout":      "net.bytesOut",
+	"dreq":      "requests.denied",
+	"dresp":     "responses.denied",
+	"ereq":      "requests.errors",
+	"econ":      "connection.errors",
+	"eresp":     "responses.errors",
+	"wretr":     "connection.retries",
+	"chkfail":   "check.failures",
+	"downtime":  "downtime.seconds",
+	"req_tot":   "requests.total",
+	"hrsp_4xx":  "responses.4xx",
+	"hrsp_5xx":  "responses.5xx",
+	"rtime":     "responseTime.ms",
+	"act":       "servers.active",
+	"bck":       "servers.backup",
+	"lastchg":   "status.lastChangeSeconds",
+	"req_rate":  "requests.perSecond",
+	"cli_abrt":  "clientAborts",
+	"srv_abrt":  "serverAborts",
+	"conn_rate": "connection.perSecond",
+}
+
+// HAProxy proxy types, as reported in the "type" column.
+var haproxyTypes = map[string]string{
+	"0": "frontend",
+	"1": "backend",
+	"2": "server",
+	"3": "listener",
+}
+
+// haproxyStats reports a HAProxyStatsSample per frontend, backend and server, as returned by the
+// stats socket whose address (a unix socket path or a "host:port" TCP address) is passed as argument.
+func haproxyStats(ctx context.Context, args []string) ([]protocol.MetricData, error) {
+	if len(args) != 1 {
+		return nil, errors.New("expected the stats socket address as the only argument")
+	}
+	address := args[0]
+
+	network := "unix"
+	if !strings.HasPrefix(address, "/") {
+		network = "tcp"
+	}
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, network, address)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	if _, err := io.WriteString(conn, "show stat\n"); err != nil {
+		return nil, err
+	}
+	// the socket is closed by HAProxy once the response is written
+	output, err := ioutil.ReadAll(conn)
+	if err != nil {
+		return nil, err
+	}
+	return parseHAProxyStats(string(output))
+}
+
+// parseHAProxyStats parses the CSV output of the "show stat" command, whose first line is the
+// header, prefixed by "# ".
+func parseHAProxyStats(output string) ([]protocol.MetricData, error) {
+	if !strings.HasPrefix(output, "# ") {
+		return nil, fmt.Errorf("unexpected HAProxy stats output: %q", output)
+	}
+	reader := csv.NewReader(strings.NewReader(strings.TrimPrefix(output, "# ")))
+	reader.FieldsPerRecord = -1
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("parsing HAProxy stats: %v", err)
+	}
+	if len(records) == 0 {
+		return nil, errors.New("empty HAProxy stats output")
+	}
+
+	header := records[0]
+	var samples []protocol.MetricData
+	for _, record := range records[1:] {
+		fields := map[string]string{}
+		for i, value := range record {
+			if i < len(header) {
+				fields[header[i]] = value
+			}
+		}
+
+		sample := protocol.MetricData{
+			"event_type":  "HAProxyStatsSample",
+			"proxyName":   fields["pxname"],
+			"serviceName": fields["svname"],
+		}
+		if proxyType, ok := haproxyTypes[fields["type"]]; ok {
+			sample["type"] = proxyType
+		}
+		if status := fields["status"]; status != "" {
+			sample["status"] = status
+		}
+		for column, name := range haproxyNumericFields {
+			// empty values stand for metrics not applying to the proxy type
+			if value, err := strconv.ParseInt(fields[column], 10, 64); err == nil {
+				sample[name] = value
+			}
+		}
+		samples = append(samples, sample)
+	}
+	return samples, nil
+}
@@ -0,0 +1,83 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package builtin
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const haproxyShowStat = `# pxname,svname,qcur,qmax,scur,smax,slim,stot,bin,bout,dreq,dresp,ereq,econ,eresp,wretr,wredis,status,weight,act,bck,chkfail,chkdown,lastchg,downtime,qlimit,pid,iid,sid,throttle,lbtot,tracked,type,rate,rate_lim,rate_max,
+http-in,FRONTEND,,,3,10,2000,150,12000,54000,0,0,2,,,,,OPEN,,,,,,,,,1,2,0,,,,0,1,0,5,
+servers,web1,0,0,1,4,,75,6000,27000,,0,,0,1,0,0,UP,1,1,0,0,0,3600,0,,1,3,1,,75,,2,0,,3,
+servers,BACKEND,0,0,1,4,200,75,6000,27000,0,0,,0,1,0,0,UP,1,1,0,,0,3600,0,,1,3,0,,75,,1,0,,3,
+`
+
+func TestParseHAProxyStats(t *testing.T) {
+	samples, err := parseHAProxyStats(haproxyShowStat)
+	require.NoError(t, err)
+
+	require.Len(t, samples, 3)
+	frontend := samples[0]
+	assert.Equal(t, "HAProxyStatsSample", frontend["event_type"])
+	assert.Equal(t, "http-in", frontend["proxyName"])
+	assert.Equal(t, "FRONTEND", frontend["serviceName"])
+	assert.Equal(t, "frontend", frontend["type"])
+	assert.Equal(t, "OPEN", frontend["status"])
+	assert.EqualValues(t, 3, frontend["session.current"])
+	assert.EqualValues(t, 150, frontend["session.total"])
+	assert.EqualValues(t, 2, frontend["requests.errors"])
+	// not applying to frontends
+	assert.NotContains(t, frontend, "queue.current")
+
+	server := samples[1]
+	assert.Equal(t, "web1", server["serviceName"])
+	assert.Equal(t, "server", server["type"])
+	assert.Equal(t, "UP", server["status"])
+	assert.EqualValues(t, 1, server["responses.errors"])
+	assert.EqualValues(t, 3600, server["status.lastChangeSeconds"])
+
+	assert.Equal(t, "backend", samples[2]["type"])
+}
+
+func TestParseHAProxyStats_Invalid(t *testing.T) {
+	_, err := parseHAProxyStats("Unknown command.\n")
+	assert.Error(t, err)
+}
+
+func TestHAProxyStats_Socket(t *testing.T) {
+	// GIVEN a HAProxy stats socket
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		if cmd, _ := bufio.NewReader(conn).ReadString('\n'); cmd == "show stat\n" {
+			_, _ = conn.Write([]byte(haproxyShowStat))
+		}
+	}()
+
+	// WHEN the stats are collected
+	samples, err := haproxyStats(context.Background(), []string{listener.Addr().String()})
+
+	// THEN all the proxies are reported
+	require.NoError(t, err)
+	assert.Len(t, samples, 3)
+}
+
+func TestExecute_UnknownCollector(t *testing.T) {
+	out := Execute(context.Background(), "unknown", nil)
+
+	_, ok := <-out.Stdout
+	assert.False(t, ok)
+	assert.Error(t, <-out.Errors)
+}
@@ -0,0 +1,82 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package builtin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"strconv"
+
+	"github.com/newrelic/infrastructure-agent/pkg/integrations/v4/protocol"
+)
+
+// nginxStubStatus matches the page returned by the nginx stub_status module, which reports the active
+// connections, the accepts/handled/requests counters and the connections by state (reading, writing, waiting).
+var nginxStubStatus = regexp.MustCompile(`Active connections:\s*(\d+)\s+` +
+	`server accepts handled requests\s+(\d+)\s+(\d+)\s+(\d+)\s+` +
+	`Reading:\s*(\d+)\s+Writing:\s*(\d+)\s+Waiting:\s*(\d+)`)
+
+// nginxStatus reports a NginxStatusSample from the stub_status page whose URL is passed as argument.
+func nginxStatus(ctx context.Context, args []string) ([]protocol.MetricData, error) {
+	if len(args) != 1 {
+		return nil, errors.New("expected the stub_status URL as the only argument")
+	}
+	url := args[0]
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status fetching %s: %s", url, resp.Status)
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	sample, err := parseNginxStubStatus(string(body))
+	if err != nil {
+		return nil, err
+	}
+	sample["url"] = url
+	return []protocol.MetricData{sample}, nil
+}
+
+func parseNginxStubStatus(page string) (protocol.MetricData, error) {
+	match := nginxStubStatus.FindStringSubmatch(page)
+	if match == nil {
+		return nil, fmt.Errorf("unexpected stub_status page: %q", page)
+	}
+	values := make([]int64, len(match)-1)
+	for i, value := range match[1:] {
+		var err error
+		if values[i], err = strconv.ParseInt(value, 10, 64); err != nil {
+			return nil, err
+		}
+	}
+	active, accepted, handled, requests, reading, writing, waiting :=
+		values[0], values[1], values[2], values[3], values[4], values[5], values[6]
+
+	return protocol.MetricData{
+		"event_type":             "NginxStatusSample",
+		"net.connectionsActive":  active,
+		"net.connectionsReading": reading,
+		"net.connectionsWriting": writing,
+		"net.connectionsWaiting": waiting,
+		// cumulative counters, since the nginx start
+		"net.connectionsAccepted": accepted,
+		"net.connectionsHandled":  handled,
+		"net.connectionsDropped":  accepted - handled,
+		"net.requests":            requests,
+	}, nil
+}
@@ -0,0 +1,69 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package builtin
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/newrelic/infrastructure-agent/pkg/integrations/v4/protocol"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const stubStatusPage = `Active connections: 291 
+server accepts handled requests
+ 16630948 16630940 31070465 
+Reading: 6 Writing: 179 Waiting: 106 
+`
+
+func TestExecute_NginxStatus(t *testing.T) {
+	// GIVEN an nginx serving its stub_status page
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(stubStatusPage))
+	}))
+	defer server.Close()
+
+	// WHEN the built-in collector is executed
+	out := Execute(context.Background(), "nginx_status", []string{server.URL})
+
+	// THEN it writes a single protocol v3 payload
+	payload, ok := <-out.Stdout
+	require.True(t, ok)
+	_, ok = <-out.Stdout
+	assert.False(t, ok)
+	assert.NoError(t, <-out.Errors)
+
+	var data protocol.PluginDataV3
+	require.NoError(t, json.Unmarshal(payload, &data))
+	assert.Equal(t, "com.newrelic.builtin.nginx_status", data.Name)
+	require.Len(t, data.DataSets, 1)
+	require.Len(t, data.DataSets[0].Metrics, 1)
+	sample := data.DataSets[0].Metrics[0]
+	assert.Equal(t, "NginxStatusSample", sample["event_type"])
+	assert.Equal(t, server.URL, sample["url"])
+	assert.EqualValues(t, 291, sample["net.connectionsActive"])
+	assert.EqualValues(t, 16630948, sample["net.connectionsAccepted"])
+	assert.EqualValues(t, 8, sample["net.connectionsDropped"])
+	assert.EqualValues(t, 31070465, sample["net.requests"])
+	assert.EqualValues(t, 106, sample["net.connectionsWaiting"])
+}
+
+func TestExecute_NginxStatus_Error(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+
+	out := Execute(context.Background(), "nginx_status", []string{server.URL})
+
+	_, ok := <-out.Stdout
+	assert.False(t, ok)
+	assert.Error(t, <-out.Errors)
+}
+
+func TestParseNginxStubStatus_Invalid(t *testing.T) {
+	_, err := parseNginxStubStatus("<html>Welcome to nginx!</html>")
+	assert.Error(t, err)
+}
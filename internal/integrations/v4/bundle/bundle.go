@@ -0,0 +1,189 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+// Package bundle downloads integration bundles from a URL or an internal repository, verifies their
+// checksum and signature and installs them into the agent integration directories, so integrations
+// can be distributed through the agent configuration in fleets without access to package repositories.
+//
+// A bundle is a gzipped tar archive whose "bin" folder holds integration executables, installed into
+// the integrations bin directory, its "definitions" folder holds v3 definition files, installed into the
+// integrations directory, and its "config" folder holds configuration files, installed into the
+// integrations config directory. Other entries are ignored.
+package bundle
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/newrelic/infrastructure-agent/pkg/log"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// maxBundleSize limits the size of the downloaded archives, which are held in memory while verified.
+	maxBundleSize = 256 << 20
+	// signatureSuffix is appended to the bundle URL to get its detached signature.
+	signatureSuffix = ".sig"
+)
+
+var blog = log.WithComponent("integrations.Bundle")
+
+// ErrUnverified is returned for bundles that have neither a checksum nor trusted keys to verify them.
+var ErrUnverified = errors.New("bundle can't be verified: no sha256 checksum nor public keys configured")
+
+// Bundle describes an integration bundle to install.
+type Bundle struct {
+	// Name identifies the bundle. It is used to build the URL of the bundles fetched from a repository.
+	Name string
+	// Version of the bundle. It is used to build the URL of the bundles fetched from a repository.
+	Version string
+	// URL of the bundle archive. If empty, it is fetched from "<repository>/<name>/<version>.tar.gz".
+	URL string
+	// SHA256 is the optional hex encoded checksum of the archive.
+	SHA256 string
+}
+
+// Config for the bundles Installer.
+type Config struct {
+	// Repository is the base URL for the bundles without an explicit URL.
+	Repository string
+	// PublicKeys are trusted to sign the bundles. If any is provided, all the bundles must have a valid
+	// detached signature, published along the archive with the ".sig" suffix.
+	PublicKeys []PublicKey
+	// BinDir, DefinitionsDir and ConfigDir are the destination of the bundles contents.
+	BinDir         string
+	DefinitionsDir string
+	ConfigDir      string
+	// StateDir stores the manifest of the installed bundles.
+	StateDir string
+}
+
+// DoFn performs HTTP requests.
+type DoFn func(req *http.Request) (*http.Response, error)
+
+// Installer downloads, verifies and installs integration bundles.
+type Installer struct {
+	cfg Config
+	do  DoFn
+}
+
+// NewInstaller creates an Installer for the passed configuration, downloading through the passed function.
+func NewInstaller(cfg Config, do DoFn) *Installer {
+	return &Installer{cfg: cfg, do: do}
+}
+
+// InstallAll installs the passed bundles. A failing bundle does not prevent the installation of the others,
+// and the integrations of its previous installation, if any, are kept.
+func (i *Installer) InstallAll(ctx context.Context, bundles []Bundle) {
+	for _, b := range bundles {
+		llog := blog.WithFields(logrus.Fields{"bundle": b.Name, "version": b.Version})
+		installed, err := i.Install(ctx, b)
+		if err != nil {
+			llog.WithError(err).Error("can't install integrations bundle")
+		} else if installed {
+			llog.Info("Integrations bundle installed.")
+		} else {
+			llog.Debug("Integrations bundle already installed.")
+		}
+	}
+}
+
+// Install downloads and verifies a bundle and, if it differs from the installed one, replaces it. Any
+// failure leaves the previously installed files untouched. It returns whether the bundle was installed.
+func (i *Installer) Install(ctx context.Context, b Bundle) (bool, error) {
+	if b.Name == "" || b.Name == "." || b.Name == ".." || strings.ContainsAny(b.Name, `/\`) {
+		return false, fmt.Errorf("invalid bundle name %q", b.Name)
+	}
+	url, err := i.url(b)
+	if err != nil {
+		return false, err
+	}
+	archive, err := i.download(ctx, url)
+	if err != nil {
+		return false, fmt.Errorf("downloading %s: %s", url, err)
+	}
+	if err = i.verify(ctx, b, url, archive); err != nil {
+		return false, err
+	}
+
+	sum := sha256.Sum256(archive)
+	digest := hex.EncodeToString(sum[:])
+	previous, err := i.readManifest(b.Name)
+	if err != nil {
+		return false, err
+	}
+	if previous.SHA256 == digest {
+		return false, nil
+	}
+
+	files, err := extract(bytes.NewReader(archive))
+	if err != nil {
+		return false, fmt.Errorf("extracting %s: %s", url, err)
+	}
+	if err = i.install(b, digest, files, previous); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (i *Installer) url(b Bundle) (string, error) {
+	if b.URL != "" {
+		return b.URL, nil
+	}
+	if i.cfg.Repository == "" || b.Version == "" {
+		return "", errors.New("bundles without url require a version and a configured repository")
+	}
+	return fmt.Sprintf("%s/%s/%s.tar.gz", strings.TrimSuffix(i.cfg.Repository, "/"), b.Name, b.Version), nil
+}
+
+func (i *Installer) download(ctx context.Context, url string) ([]byte, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := i.do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxBundleSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(body) > maxBundleSize {
+		return nil, fmt.Errorf("larger than %d bytes", maxBundleSize)
+	}
+	return body, nil
+}
+
+// verify checks the archive against the checksum of the bundle and the signature published along it.
+func (i *Installer) verify(ctx context.Context, b Bundle, url string, archive []byte) error {
+	if b.SHA256 == "" && len(i.cfg.PublicKeys) == 0 {
+		return ErrUnverified
+	}
+	if b.SHA256 != "" {
+		if err := verifyChecksum(archive, b.SHA256); err != nil {
+			return err
+		}
+	}
+	if len(i.cfg.PublicKeys) > 0 {
+		signature, err := i.download(ctx, url+signatureSuffix)
+		if err != nil {
+			return fmt.Errorf("downloading signature %s: %s", url+signatureSuffix, err)
+		}
+		if err = verifySignature(archive, signature, i.cfg.PublicKeys); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,281 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package bundle
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type entry struct {
+	name    string
+	content string
+}
+
+func archive(t *testing.T, entries ...entry) []byte {
+	buf := bytes.Buffer{}
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for _, e := range entries {
+		require.NoError(t, tw.WriteHeader(&tar.Header{
+			Name: e.name, Mode: 0644, Size: int64(len(e.content)), Typeflag: tar.TypeReg,
+		}))
+		_, err := tw.Write([]byte(e.content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	require.NoError(t, gz.Close())
+	return buf.Bytes()
+}
+
+func checksum(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// repository serves the passed files, by path.
+func repository(files map[string][]byte) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		content, ok := files[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write(content)
+	}))
+}
+
+type fixture struct {
+	installer *Installer
+	dir       string
+}
+
+func newFixture(t *testing.T, repo string, keys ...PublicKey) fixture {
+	dir, err := ioutil.TempDir("", "bundle")
+	require.NoError(t, err)
+	return fixture{
+		installer: NewInstaller(Config{
+			Repository:     repo,
+			PublicKeys:     keys,
+			BinDir:         filepath.Join(dir, "integrations", "bin"),
+			DefinitionsDir: filepath.Join(dir, "integrations"),
+			ConfigDir:      filepath.Join(dir, "integrations.d"),
+			StateDir:       filepath.Join(dir, "state"),
+		}, http.DefaultClient.Do),
+		dir: dir,
+	}
+}
+
+func (f fixture) read(t *testing.T, path string) string {
+	content, err := ioutil.ReadFile(filepath.Join(f.dir, path))
+	require.NoError(t, err)
+	return string(content)
+}
+
+func TestInstall_Signed(t *testing.T) {
+	// GIVEN a signed bundle served from a repository
+	pub, priv, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	tgz := archive(t,
+		entry{"bin/nri-redis", "#!/bin/sh"},
+		entry{"definitions/redis-definition.yml", "name: com.newrelic.redis"},
+		entry{"config/redis-config.yml", "integrations: []"},
+		entry{"README.md", "ignored"})
+	srv := repository(map[string][]byte{
+		"/redis/1.2.0.tar.gz":     tgz,
+		"/redis/1.2.0.tar.gz.sig": []byte(base64.StdEncoding.EncodeToString(ed25519.Sign(priv, tgz))),
+	})
+	defer srv.Close()
+	f := newFixture(t, srv.URL+"/", pub)
+	defer os.RemoveAll(f.dir)
+
+	// WHEN it is installed
+	installed, err := f.installer.Install(context.Background(), Bundle{Name: "redis", Version: "1.2.0"})
+
+	// THEN its files are placed into the integration folders
+	require.NoError(t, err)
+	assert.True(t, installed)
+	assert.Equal(t, "#!/bin/sh", f.read(t, "integrations/bin/nri-redis"))
+	assert.Equal(t, "name: com.newrelic.redis", f.read(t, "integrations/redis-definition.yml"))
+	assert.Equal(t, "integrations: []", f.read(t, "integrations.d/redis-config.yml"))
+	info, err := os.Stat(filepath.Join(f.dir, "integrations/bin/nri-redis"))
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0755), info.Mode().Perm())
+	_, err = os.Stat(filepath.Join(f.dir, "integrations/README.md"))
+	assert.True(t, os.IsNotExist(err))
+
+	// AND it is not installed again while unchanged
+	installed, err = f.installer.Install(context.Background(), Bundle{Name: "redis", Version: "1.2.0"})
+	require.NoError(t, err)
+	assert.False(t, installed)
+}
+
+func TestInstall_VerificationFailureKeepsPreviousInstallation(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	_, otherPriv, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+
+	v1 := archive(t, entry{"bin/nri-redis", "v1"})
+	v2 := archive(t, entry{"bin/nri-redis", "v2"})
+	srv := repository(map[string][]byte{
+		"/v1.tar.gz":     v1,
+		"/v1.tar.gz.sig": ed25519.Sign(priv, v1),
+		"/v2.tar.gz":     v2,
+		"/v2.tar.gz.sig": ed25519.Sign(otherPriv, v2),
+	})
+	defer srv.Close()
+	f := newFixture(t, "", pub)
+	defer os.RemoveAll(f.dir)
+
+	// GIVEN an installed bundle
+	_, err = f.installer.Install(context.Background(), Bundle{Name: "redis", URL: srv.URL + "/v1.tar.gz"})
+	require.NoError(t, err)
+
+	// WHEN a new version signed by an untrusted key is installed
+	_, err = f.installer.Install(context.Background(), Bundle{Name: "redis", URL: srv.URL + "/v2.tar.gz"})
+
+	// THEN it is rejected and the previous version is kept
+	assert.Equal(t, errBadSignature, err)
+	assert.Equal(t, "v1", f.read(t, "integrations/bin/nri-redis"))
+}
+
+func TestInstall_Checksum(t *testing.T) {
+	tgz := archive(t, entry{"config/redis-config.yml", "integrations: []"})
+	srv := repository(map[string][]byte{"/redis.tar.gz": tgz})
+	defer srv.Close()
+	f := newFixture(t, "")
+	defer os.RemoveAll(f.dir)
+
+	_, err := f.installer.Install(context.Background(), Bundle{Name: "redis", URL: srv.URL + "/redis.tar.gz"})
+	assert.Equal(t, ErrUnverified, err)
+
+	_, err = f.installer.Install(context.Background(), Bundle{Name: "redis", URL: srv.URL + "/redis.tar.gz", SHA256: checksum([]byte("other"))})
+	assert.Error(t, err)
+	_, err = os.Stat(filepath.Join(f.dir, "integrations.d", "redis-config.yml"))
+	assert.True(t, os.IsNotExist(err))
+
+	installed, err := f.installer.Install(context.Background(), Bundle{Name: "redis", URL: srv.URL + "/redis.tar.gz", SHA256: checksum(tgz)})
+	require.NoError(t, err)
+	assert.True(t, installed)
+	assert.Equal(t, "integrations: []", f.read(t, "integrations.d/redis-config.yml"))
+}
+
+func TestInstall_UpgradeRemovesStaleFiles(t *testing.T) {
+	v1 := archive(t, entry{"bin/nri-redis", "v1"}, entry{"config/redis-old.yml", "old"})
+	v2 := archive(t, entry{"bin/nri-redis", "v2"}, entry{"config/redis-config.yml", "new"})
+	srv := repository(map[string][]byte{"/redis/1.tar.gz": v1, "/redis/2.tar.gz": v2})
+	defer srv.Close()
+	f := newFixture(t, srv.URL)
+	defer os.RemoveAll(f.dir)
+
+	// GIVEN an installed bundle
+	_, err := f.installer.Install(context.Background(), Bundle{Name: "redis", Version: "1", SHA256: checksum(v1)})
+	require.NoError(t, err)
+
+	// WHEN a version without some of its files is installed
+	_, err = f.installer.Install(context.Background(), Bundle{Name: "redis", Version: "2", SHA256: checksum(v2)})
+	require.NoError(t, err)
+
+	// THEN the files are replaced and the ones not provided anymore removed
+	assert.Equal(t, "v2", f.read(t, "integrations/bin/nri-redis"))
+	assert.Equal(t, "new", f.read(t, "integrations.d/redis-config.yml"))
+	_, err = os.Stat(filepath.Join(f.dir, "integrations.d", "redis-old.yml"))
+	assert.True(t, os.IsNotExist(err))
+	_, err = os.Stat(filepath.Join(f.dir, "integrations", "bin", "nri-redis"+backupSuffix))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestInstall_RollbackOnInstallFailure(t *testing.T) {
+	v1 := archive(t, entry{"bin/nri-redis", "v1"})
+	v2 := archive(t, entry{"bin/nri-redis", "v2"}, entry{"config/redis-config.yml", "new"})
+	srv := repository(map[string][]byte{"/1.tar.gz": v1, "/2.tar.gz": v2})
+	defer srv.Close()
+	f := newFixture(t, "")
+	defer os.RemoveAll(f.dir)
+
+	// GIVEN an installed bundle
+	_, err := f.installer.Install(context.Background(), Bundle{Name: "redis", URL: srv.URL + "/1.tar.gz", SHA256: checksum(v1)})
+	require.NoError(t, err)
+	// AND one of the files of the new version can't be placed
+	defer func() { rename = os.Rename }()
+	rename = func(from, to string) error {
+		if filepath.Base(to) == "redis-config.yml" {
+			return errors.New("permission denied")
+		}
+		return os.Rename(from, to)
+	}
+
+	// WHEN the new version is installed
+	_, err = f.installer.Install(context.Background(), Bundle{Name: "redis", URL: srv.URL + "/2.tar.gz", SHA256: checksum(v2)})
+
+	// THEN the installation fails, restoring the previous files
+	assert.EqualError(t, err, "permission denied")
+	assert.Equal(t, "v1", f.read(t, "integrations/bin/nri-redis"))
+	_, err = os.Stat(filepath.Join(f.dir, "integrations", "bin", "nri-redis"+newSuffix))
+	assert.True(t, os.IsNotExist(err))
+	m, err := f.installer.readManifest("redis")
+	require.NoError(t, err)
+	assert.Equal(t, checksum(v1), m.SHA256)
+}
+
+func TestInstall_InvalidBundle(t *testing.T) {
+	f := newFixture(t, "")
+	defer os.RemoveAll(f.dir)
+
+	_, err := f.installer.Install(context.Background(), Bundle{Name: "../redis", URL: "http://localhost"})
+	assert.Error(t, err)
+	_, err = f.installer.Install(context.Background(), Bundle{Name: "redis", Version: "1.0"})
+	assert.Error(t, err, "no repository configured")
+}
+
+func TestExtract_RejectsEntriesOutOfTheBundle(t *testing.T) {
+	_, err := extract(bytes.NewReader(archive(t, entry{"bin/../../etc/passwd", "root"})))
+	assert.Error(t, err)
+	_, err = extract(bytes.NewReader(archive(t, entry{"/bin/nri-redis", "x"})))
+	assert.Error(t, err)
+
+	files, err := extract(bytes.NewReader(archive(t, entry{"./bin/nri-redis", "x"}, entry{"bin/../config/c.yml", "y"})))
+	require.NoError(t, err)
+	require.Len(t, files, 2)
+	assert.Equal(t, "nri-redis", files[0].path)
+	assert.Equal(t, configFolder, files[1].folder)
+}
+
+func TestLoadPublicKeys(t *testing.T) {
+	pub, _, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	require.NoError(t, err)
+
+	keyFile, err := ioutil.TempFile("", "key")
+	require.NoError(t, err)
+	defer os.Remove(keyFile.Name())
+	require.NoError(t, pem.Encode(keyFile, &pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+	require.NoError(t, keyFile.Close())
+
+	keys, err := LoadPublicKeys([]string{keyFile.Name()})
+	require.NoError(t, err)
+	assert.Equal(t, []PublicKey{pub}, keys)
+
+	_, err = parsePublicKey([]byte("not a key"))
+	assert.Error(t, err)
+}
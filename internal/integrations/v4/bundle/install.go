@@ -0,0 +1,202 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package bundle
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Bundle folders.
+const (
+	binFolder         = "bin"
+	definitionsFolder = "definitions"
+	configFolder      = "config"
+)
+
+const (
+	newSuffix    = ".new"
+	backupSuffix = ".bak"
+)
+
+var rename = os.Rename
+
+// file extracted from a bundle archive.
+type file struct {
+	folder string // bundle folder
+	path   string // slash separated path, relative to the folder
+	mode   os.FileMode
+	data   []byte
+}
+
+// manifest records the files installed by a bundle, so they are removed when a new version no longer
+// provides them, and the installation is skipped when the bundle is unchanged.
+type manifest struct {
+	Name    string   `json:"name"`
+	Version string   `json:"version"`
+	SHA256  string   `json:"sha256"`
+	Files   []string `json:"files"`
+}
+
+// extract reads the regular files of the known bundle folders from a gzipped tar archive.
+func extract(r io.Reader) ([]file, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	defer gz.Close()
+
+	var files []file
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return files, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		name := path.Clean(strings.TrimPrefix(hdr.Name, "./"))
+		if path.IsAbs(name) || name == ".." || strings.HasPrefix(name, "../") {
+			return nil, fmt.Errorf("entry %q outside of the bundle", hdr.Name)
+		}
+		parts := strings.SplitN(name, "/", 2)
+		if len(parts) < 2 || (parts[0] != binFolder && parts[0] != definitionsFolder && parts[0] != configFolder) {
+			blog.WithField("entry", hdr.Name).Debug("Ignoring bundle entry out of the integration folders.")
+			continue
+		}
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			continue
+		case tar.TypeReg, tar.TypeRegA:
+		default:
+			return nil, fmt.Errorf("entry %q is not a regular file", hdr.Name)
+		}
+		data, err := ioutil.ReadAll(tr)
+		if err != nil {
+			return nil, err
+		}
+		mode := os.FileMode(hdr.Mode).Perm()
+		if parts[0] == binFolder {
+			mode |= 0755
+		} else if mode == 0 {
+			mode = 0644
+		}
+		files = append(files, file{folder: parts[0], path: parts[1], mode: mode, data: data})
+	}
+}
+
+func (i *Installer) destination(f file) string {
+	dir := i.cfg.ConfigDir
+	switch f.folder {
+	case binFolder:
+		dir = i.cfg.BinDir
+	case definitionsFolder:
+		dir = i.cfg.DefinitionsDir
+	}
+	return filepath.Join(dir, filepath.FromSlash(f.path))
+}
+
+// install replaces the files of the previous installation of the bundle by the passed ones. The new
+// files are first written next to their destination and then renamed, backing up the replaced files,
+// so any failure is rolled back restoring them.
+func (i *Installer) install(b Bundle, digest string, files []file, previous manifest) (err error) {
+	installed := manifest{Name: b.Name, Version: b.Version, SHA256: digest}
+	var replaced, backups []string
+	defer func() {
+		if err == nil {
+			return
+		}
+		for _, dest := range installed.Files {
+			os.Remove(dest + newSuffix)
+		}
+		for _, dest := range replaced {
+			os.Remove(dest)
+		}
+		for _, dest := range backups {
+			if rerr := rename(dest+backupSuffix, dest); rerr != nil {
+				blog.WithError(rerr).WithField("file", dest).Warn("can't restore file replaced by bundle")
+			}
+		}
+	}()
+
+	for _, f := range files {
+		dest := i.destination(f)
+		installed.Files = append(installed.Files, dest)
+		if err = os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+			return err
+		}
+		if err = ioutil.WriteFile(dest+newSuffix, f.data, f.mode); err != nil {
+			return err
+		}
+	}
+	for _, dest := range installed.Files {
+		if _, serr := os.Lstat(dest); serr == nil {
+			if err = rename(dest, dest+backupSuffix); err != nil {
+				return err
+			}
+			backups = append(backups, dest)
+		}
+		if err = rename(dest+newSuffix, dest); err != nil {
+			return err
+		}
+		replaced = append(replaced, dest)
+	}
+	if err = i.writeManifest(installed); err != nil {
+		return err
+	}
+
+	for _, dest := range backups {
+		os.Remove(dest + backupSuffix)
+	}
+	current := map[string]struct{}{}
+	for _, dest := range installed.Files {
+		current[dest] = struct{}{}
+	}
+	for _, dest := range previous.Files {
+		if _, ok := current[dest]; !ok {
+			if rerr := os.Remove(dest); rerr != nil && !os.IsNotExist(rerr) {
+				blog.WithError(rerr).WithField("file", dest).Warn("can't remove file of previous bundle version")
+			}
+		}
+	}
+	return nil
+}
+
+func (i *Installer) manifestPath(name string) string {
+	return filepath.Join(i.cfg.StateDir, name+".json")
+}
+
+func (i *Installer) readManifest(name string) (manifest, error) {
+	var m manifest
+	content, err := ioutil.ReadFile(i.manifestPath(name))
+	if os.IsNotExist(err) {
+		return m, nil
+	}
+	if err != nil {
+		return m, err
+	}
+	if err = json.Unmarshal(content, &m); err != nil {
+		return m, fmt.Errorf("invalid bundle manifest %s: %s", i.manifestPath(name), err)
+	}
+	return m, nil
+}
+
+func (i *Installer) writeManifest(m manifest) error {
+	content, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(i.cfg.StateDir, 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(i.manifestPath(m.Name), content, 0644)
+}
@@ -0,0 +1,81 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package bundle
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+)
+
+// PublicKey trusted to sign bundles.
+type PublicKey = ed25519.PublicKey
+
+var errBadSignature = errors.New("bundle signature doesn't match any of the trusted public keys")
+
+// LoadPublicKeys reads the PEM encoded (PKIX, "PUBLIC KEY" block) Ed25519 keys stored in the passed files.
+func LoadPublicKeys(paths []string) ([]PublicKey, error) {
+	var keys []PublicKey
+	for _, path := range paths {
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		key, err := parsePublicKey(content)
+		if err != nil {
+			return nil, fmt.Errorf("invalid public key %s: %s", path, err)
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+func parsePublicKey(content []byte) (PublicKey, error) {
+	block, _ := pem.Decode(content)
+	if block == nil || block.Type != "PUBLIC KEY" {
+		return nil, errors.New("no PEM public key block found")
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	edKey, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("unsupported key type %T, only Ed25519 keys are accepted", key)
+	}
+	return edKey, nil
+}
+
+func verifyChecksum(archive []byte, expected string) error {
+	sum := sha256.Sum256(archive)
+	if actual := hex.EncodeToString(sum[:]); !strings.EqualFold(actual, strings.TrimSpace(expected)) {
+		return fmt.Errorf("bundle checksum mismatch: expected sha256 %s, got %s", expected, actual)
+	}
+	return nil
+}
+
+// verifySignature checks the detached signature of the archive, either raw or base64 encoded, against
+// the trusted keys.
+func verifySignature(archive, signature []byte, keys []PublicKey) error {
+	if len(signature) != ed25519.SignatureSize {
+		decoded, err := base64.StdEncoding.DecodeString(string(bytes.TrimSpace(signature)))
+		if err != nil || len(decoded) != ed25519.SignatureSize {
+			return errors.New("invalid bundle signature format")
+		}
+		signature = decoded
+	}
+	for _, key := range keys {
+		if ed25519.Verify(key, archive, signature) {
+			return nil
+		}
+	}
+	return errBadSignature
+}
@@ -0,0 +1,181 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+// Package capture stores the raw payloads and resolved configurations of a single integration into a
+// debug directory, to troubleshoot it on a busy host without enabling the agent verbose logging.
+// Sensitive values, as passwords or tokens, are obfuscated before being stored.
+package capture
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/newrelic/infrastructure-agent/pkg/helpers"
+	"github.com/newrelic/infrastructure-agent/pkg/log"
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	clog    = log.WithComponent("integrations.Capture")
+	timeNow = time.Now
+	// replaces the characters that aren't safe in a file name
+	unsafeChars = regexp.MustCompile(`[^a-zA-Z0-9._-]+`)
+)
+
+// ResolvedConfig of an integration instance, after its variables have been replaced.
+type ResolvedConfig struct {
+	Command        string            `json:"command"`
+	Args           []string          `json:"args,omitempty"`
+	Environment    map[string]string `json:"environment,omitempty"`
+	ConfigTemplate string            `json:"config_template,omitempty"`
+}
+
+type capturer struct {
+	lock      sync.Mutex
+	dir       string
+	remaining map[string]int // payloads still to capture, by integration name
+	seq       int
+}
+
+// global capturer instance, aimed to be used the same way the trace package is.
+var global = capturer{remaining: map[string]int{}}
+
+// SetDir sets the directory where the captures are stored, in a folder per integration.
+func SetDir(dir string) {
+	global.lock.Lock()
+	defer global.lock.Unlock()
+	global.dir = dir
+}
+
+// Start captures the next passed number of payloads of the integration, along with the configurations
+// its instances are executed with. It replaces any ongoing capture of the integration; zero or negative
+// numbers stop it.
+func Start(integrationName string, payloads int) error {
+	global.lock.Lock()
+	defer global.lock.Unlock()
+	if global.dir == "" {
+		return fmt.Errorf("no capture directory set")
+	}
+	if payloads <= 0 {
+		delete(global.remaining, integrationName)
+		return nil
+	}
+	global.remaining[integrationName] = payloads
+	clog.WithFields(logrus.Fields{
+		"integration_name": integrationName,
+		"payloads":         payloads,
+		"dir":              global.dir,
+	}).Info("Capturing integration payloads.")
+	return nil
+}
+
+// Active returns whether the integration is being captured.
+func Active(integrationName string) bool {
+	global.lock.Lock()
+	defer global.lock.Unlock()
+	return global.remaining[integrationName] > 0
+}
+
+// Payload stores a payload of the integration, if it is being captured.
+func Payload(integrationName string, payload []byte) {
+	global.lock.Lock()
+	defer global.lock.Unlock()
+	remaining := global.remaining[integrationName]
+	if remaining <= 0 {
+		return
+	}
+	if remaining == 1 {
+		delete(global.remaining, integrationName)
+		clog.WithField("integration_name", integrationName).Info("Integration capture finished.")
+	} else {
+		global.remaining[integrationName] = remaining - 1
+	}
+	global.write(integrationName, "payload", sanitizePayload(payload))
+}
+
+// Config stores the resolved configuration of an integration instance, if it is being captured.
+func Config(integrationName string, cfg ResolvedConfig) {
+	global.lock.Lock()
+	defer global.lock.Unlock()
+	if global.remaining[integrationName] <= 0 {
+		return
+	}
+	cfg.Args = helpers.ObfuscateSensitiveDataFromArray(cfg.Args)
+	cfg.Environment = helpers.ObfuscateSensitiveDataFromMap(cfg.Environment)
+	cfg.ConfigTemplate = sanitizeLines(cfg.ConfigTemplate)
+	content, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		clog.WithError(err).Warn("can't marshal integration config capture")
+		return
+	}
+	global.write(integrationName, "config", content)
+}
+
+// write must be invoked with the lock held.
+func (c *capturer) write(integrationName, kind string, content []byte) {
+	dir := filepath.Join(c.dir, unsafeChars.ReplaceAllString(integrationName, "_"))
+	// captures may hold data that isn't obfuscated, so they are only readable by the agent user
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		clog.WithError(err).WithField("dir", dir).Warn("can't create integration capture directory")
+		return
+	}
+	c.seq++
+	name := fmt.Sprintf("%s-%04d-%s.json", timeNow().Format("20060102T150405"), c.seq, kind)
+	if err := ioutil.WriteFile(filepath.Join(dir, name), content, 0600); err != nil {
+		clog.WithError(err).WithField("dir", dir).Warn("can't write integration capture")
+	}
+}
+
+// sanitizePayload obfuscates the string values of the JSON payload fields whose names look sensitive.
+// Payloads that aren't valid JSON are obfuscated as text.
+func sanitizePayload(payload []byte) []byte {
+	var decoded interface{}
+	decoder := json.NewDecoder(bytes.NewReader(payload))
+	// keeps the numbers as they are written
+	decoder.UseNumber()
+	if err := decoder.Decode(&decoded); err != nil {
+		return []byte(sanitizeLines(string(payload)))
+	}
+	sanitized := bytes.Buffer{}
+	encoder := json.NewEncoder(&sanitized)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(sanitizeValue(decoded)); err != nil {
+		return []byte(sanitizeLines(string(payload)))
+	}
+	return bytes.TrimSuffix(sanitized.Bytes(), []byte("\n"))
+}
+
+func sanitizeValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, field := range v {
+			if _, ok := field.(string); ok {
+				if matched, isField, _ := helpers.ObfuscateSensitiveData(key); matched && isField {
+					v[key] = helpers.HiddenField
+					continue
+				}
+			}
+			v[key] = sanitizeValue(field)
+		}
+	case []interface{}:
+		for i := range v {
+			v[i] = sanitizeValue(v[i])
+		}
+	}
+	return value
+}
+
+func sanitizeLines(text string) string {
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		_, _, lines[i] = helpers.ObfuscateSensitiveData(line)
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,115 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package capture
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func captureDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "capture")
+	require.NoError(t, err)
+	SetDir(dir)
+	return dir
+}
+
+func captured(t *testing.T, dir string) []string {
+	files, err := filepath.Glob(filepath.Join(dir, "*"))
+	require.NoError(t, err)
+	sort.Strings(files)
+	var contents []string
+	for _, file := range files {
+		content, err := ioutil.ReadFile(file)
+		require.NoError(t, err)
+		contents = append(contents, string(content))
+	}
+	return contents
+}
+
+func TestPayload(t *testing.T) {
+	dir := captureDir(t)
+	defer os.RemoveAll(dir)
+
+	// GIVEN a capture of 2 payloads of an integration
+	require.NoError(t, Start("nri-redis", 2))
+	assert.True(t, Active("nri-redis"))
+	assert.False(t, Active("nri-mysql"))
+
+	// WHEN it and other integrations emit payloads
+	Payload("nri-redis", []byte(`{"name":"com.newrelic.redis","data":[{"metrics":[{"hits":12345678901234}]}]}`))
+	Payload("nri-mysql", []byte(`{"name":"com.newrelic.mysql"}`))
+	Payload("nri-redis", []byte(`{"name":"com.newrelic.redis","data":[{"inventory":{"config":{"password":"s3cr3t","keyspaces":16}}}]}`))
+	Payload("nri-redis", []byte(`{"name":"com.newrelic.redis"}`))
+
+	// THEN only the requested number of payloads of the integration are stored, sanitized
+	assert.False(t, Active("nri-redis"))
+	assert.Equal(t, []string{
+		`{"data":[{"metrics":[{"hits":12345678901234}]}],"name":"com.newrelic.redis"}`,
+		`{"data":[{"inventory":{"config":{"keyspaces":16,"password":"<HIDDEN>"}}}],"name":"com.newrelic.redis"}`,
+	}, captured(t, filepath.Join(dir, "nri-redis")))
+	_, err := os.Stat(filepath.Join(dir, "nri-mysql"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestConfig(t *testing.T) {
+	dir := captureDir(t)
+	defer os.RemoveAll(dir)
+
+	cfg := ResolvedConfig{
+		Command:        "/usr/bin/nri-redis",
+		Args:           []string{"-hostname", "redis.local", "-password", "s3cr3t"},
+		Environment:    map[string]string{"PORT": "6379", "REDIS_PASSWORD": "s3cr3t"},
+		ConfigTemplate: "hostname: redis.local\npassword: s3cr3t\n",
+	}
+
+	// GIVEN the integration is not being captured, its config is not stored
+	Config("nri-redis", cfg)
+	_, err := os.Stat(filepath.Join(dir, "nri-redis"))
+	assert.True(t, os.IsNotExist(err))
+
+	// WHEN it is captured
+	require.NoError(t, Start("nri-redis", 1))
+	defer Start("nri-redis", 0)
+	Config("nri-redis", cfg)
+
+	// THEN its config is stored, sanitized
+	contents := captured(t, filepath.Join(dir, "nri-redis"))
+	require.Len(t, contents, 1)
+	var stored ResolvedConfig
+	require.NoError(t, json.Unmarshal([]byte(contents[0]), &stored))
+	assert.Equal(t, ResolvedConfig{
+		Command:        "/usr/bin/nri-redis",
+		Args:           []string{"-hostname", "redis.local", "-password", "<HIDDEN>"},
+		Environment:    map[string]string{"PORT": "6379", "REDIS_PASSWORD": "<HIDDEN>"},
+		ConfigTemplate: "hostname: redis.local\npassword: <HIDDEN>\n",
+	}, stored)
+	// AND the capture is still active, as it only counts payloads
+	assert.True(t, Active("nri-redis"))
+}
+
+func TestStart_Stop(t *testing.T) {
+	dir := captureDir(t)
+	defer os.RemoveAll(dir)
+
+	require.NoError(t, Start("nri-redis", 5))
+	require.NoError(t, Start("nri-redis", 0))
+
+	assert.False(t, Active("nri-redis"))
+}
+
+func TestStart_NoDir(t *testing.T) {
+	SetDir("")
+	assert.Error(t, Start("nri-redis", 5))
+}
+
+func TestSanitizePayload_NotJSON(t *testing.T) {
+	assert.Equal(t, "token=<HIDDEN> truncated {", string(sanitizePayload([]byte("token=abcd truncated {"))))
+}
@@ -2,7 +2,10 @@
 // SPDX-License-Identifier: Apache-2.0
 package executor
 
-import "os"
+import (
+	"os"
+	"time"
+)
 
 // Config describes the context to execute a command: user, directory and environment variables.
 type Config struct {
@@ -12,6 +15,9 @@ type Config struct {
 	Environment map[string]string
 	// Global variables that need to be retrieved before the integration runs
 	Passthrough []string
+	// TerminationGracePeriod is the time a cancelled process is given to finish after being
+	// asked to terminate, before it is killed. Zero or negative values kill it immediately.
+	TerminationGracePeriod time.Duration
 }
 
 // BuildEnv returns the environment configuration of an executable, merging the
@@ -60,5 +66,7 @@ func (c *Config) deepClone() *Config {
 		Directory:   c.Directory,
 		Environment: envCopy,
 		Passthrough: passthroughCopy,
+
+		TerminationGracePeriod: c.TerminationGracePeriod,
 	}
 }
@@ -12,6 +12,7 @@ import (
 	"os"
 	"os/exec"
 	"sync"
+	"time"
 
 	"github.com/newrelic/infrastructure-agent/internal/integrations/v4/constants"
 	"github.com/newrelic/infrastructure-agent/pkg/helpers"
@@ -35,15 +36,15 @@ func FromCmdSlice(cmd []string, cfg *Config) Executor {
 
 // Execute runs the command in background, sending by a channel the standard output and error, as well as any execution
 // error may happen (task can't start, task is killed...).
-// The executed process can be cancelled via the provided Context.
+// The executed process can be cancelled via the provided Context. On cancellation, the process is asked to terminate
+// and killed if it's still running after the configured TerminationGracePeriod.
 // When writable PID channel is provided, generated PID will be written, so process could be signaled by 3rd parties.
 // When the process ends, all the channels are closed.
 func (r *Executor) Execute(ctx context.Context, pidChan chan<- int) OutputReceive {
 	out, receiver := NewOutput()
-	commandCtx, cancelCommand := context.WithCancel(ctx)
 
 	go func() {
-		cmd := r.buildCommand(commandCtx)
+		cmd := r.buildCommand(ctx)
 
 		//argsS := make([]string, len(cmd.Args))
 		//copy(argsS, cmd.Args)
@@ -82,11 +83,11 @@ func (r *Executor) Execute(ctx context.Context, pidChan chan<- int) OutputReceiv
 			forwardCmdOutput(cmdError, out.Stderr, out.Errors)
 		}()
 
-		// on normal output, when the output pipes are closed, we cancel the
-		// command context so the parent goroutine can exit
+		// on normal output, the output pipes are closed when the process ends
+		outputForwarded := make(chan struct{})
 		go func() {
 			allOutputForwarded.Wait()
-			cancelCommand()
+			close(outputForwarded)
 		}()
 
 		if err := cmd.Start(); err != nil {
@@ -99,7 +100,11 @@ func (r *Executor) Execute(ctx context.Context, pidChan chan<- int) OutputReceiv
 
 		// Waits for the command to finish (or be externally cancelled) and closes
 		// the OutputSend channels when all the data has been submitted
-		<-commandCtx.Done()
+		select {
+		case <-outputForwarded:
+		case <-ctx.Done():
+			r.terminate(cmd.Process, outputForwarded)
+		}
 		if err := cmd.Wait(); err != nil {
 			out.Errors <- err
 		}
@@ -109,6 +114,32 @@ func (r *Executor) Execute(ctx context.Context, pidChan chan<- int) OutputReceiv
 	return receiver
 }
 
+// terminate asks the process to finish and, if it has not closed its output after the configured
+// grace period, kills it. Any output written before the process ends is still forwarded, so complete
+// payloads flushed during the grace period are not lost.
+func (r *Executor) terminate(process *os.Process, finished <-chan struct{}) {
+	if process == nil {
+		return
+	}
+	if r.Cfg == nil || r.Cfg.TerminationGracePeriod <= 0 {
+		_ = kill(process)
+		return
+	}
+	plog := illog.WithField("command", r.Command).WithField("pid", process.Pid)
+	if err := signalTermination(process); err != nil {
+		plog.WithError(err).Debug("Can't signal process termination. Killing it.")
+		_ = kill(process)
+		return
+	}
+	select {
+	case <-finished:
+	case <-time.After(r.Cfg.TerminationGracePeriod):
+		plog.WithField("grace_period", r.Cfg.TerminationGracePeriod).
+			Warn("process didn't finish after the termination grace period. Killing it")
+		_ = kill(process)
+	}
+}
+
 // reads lines from stdout or stderr and forwards them to the fwd channel
 func forwardCmdOutput(buffer io.Reader, fwd chan<- []byte, errors chan<- error) {
 	lineReader := bufio.NewReader(buffer)
@@ -131,7 +162,8 @@ func forwardCmdOutput(buffer io.Reader, fwd chan<- []byte, errors chan<- error)
 }
 
 func (r *Executor) buildCommand(ctx context.Context) *exec.Cmd {
-	cmd := r.userAwareCmd(ctx)
+	cmd := r.userAwareCmd()
+	runInProcessGroup(cmd)
 	cmd.Env = os.Environ()
 	for key, val := range r.Cfg.BuildEnv() {
 		cmd.Env = append(cmd.Env, key+"="+val)
@@ -3,12 +3,11 @@
 package executor
 
 import (
-	"context"
 	"os/exec"
 )
 
-// userAwareCmd returns a cancellable Cmd struct to execute the given command with the provided
+// userAwareCmd returns a Cmd struct to execute the given command with the provided
 // arguments.
-func (r *Executor) userAwareCmd(ctx context.Context) *exec.Cmd {
-	return exec.CommandContext(ctx, r.Command, r.Args...)
+func (r *Executor) userAwareCmd() *exec.Cmd {
+	return exec.Command(r.Command, r.Args...)
 }
@@ -3,17 +3,16 @@
 package executor
 
 import (
-	"context"
 	"os/exec"
 )
 
-// userAwareCmd returns a cancellable Cmd struct to execute the given command with the provided
+// userAwareCmd returns a Cmd struct to execute the given command with the provided
 // arguments. If the plugin instance contains a value for IntegrationUser the
 // command will be constructed with sudo to allow it to be run as the specified
 // user.
-func (r *Executor) userAwareCmd(ctx context.Context) *exec.Cmd {
+func (r *Executor) userAwareCmd() *exec.Cmd {
 	if r.Cfg.User == "" {
-		return exec.CommandContext(ctx, r.Command, r.Args...)
+		return exec.Command(r.Command, r.Args...)
 	}
 	// The -n flag makes sudo fail, if a password is required, with the
 	// following message: `sudo: a password is required`.
@@ -21,5 +20,5 @@ func (r *Executor) userAwareCmd(ctx context.Context) *exec.Cmd {
 		[]string{"-E", "-n", "-u", r.Cfg.User, r.Command},
 		r.Args...,
 	)
-	return exec.CommandContext(ctx, "/usr/bin/sudo", sudoArgs...)
+	return exec.Command("/usr/bin/sudo", sudoArgs...)
 }
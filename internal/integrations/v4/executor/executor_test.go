@@ -162,6 +162,55 @@ func TestRunnable_Execute_Blocked(t *testing.T) {
 	assert.NotEqual(t, testhelp.ErrChannelTimeout, err)
 }
 
+func TestRunnable_Execute_GracefulTermination(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("termination signals are not supported on windows")
+	}
+	defer leaktest.Check(t)()
+
+	// GIVEN a runnable instance with a termination grace period
+	cfg := execConfig(t)
+	cfg.TerminationGracePeriod = 10 * time.Second
+
+	ctx, cancel := context.WithCancel(context.Background())
+	r := FromCmdSlice(testhelp.Command(fixtures.TrapTermCmd), cfg)
+
+	// THAT is normally working
+	to := r.Execute(ctx, nil)
+	assert.Equal(t, "starting", testhelp.ChannelRead(to.Stdout))
+
+	// WHEN the running context is cancelled
+	cancel()
+
+	// THEN the output written while terminating is forwarded
+	assert.Equal(t, "terminated", testhelp.ChannelRead(to.Stdout))
+
+	// AND the process finishes gracefully
+	assert.NoError(t, testhelp.ChannelErrClosed(to.Errors))
+}
+
+func TestRunnable_Execute_KilledAfterGracePeriod(t *testing.T) {
+	defer leaktest.Check(t)()
+
+	// GIVEN a blocked runnable instance with a short termination grace period
+	cfg := execConfig(t)
+	cfg.TerminationGracePeriod = 100 * time.Millisecond
+
+	ctx, cancel := context.WithCancel(context.Background())
+	r := FromCmdSlice(testhelp.Command(fixtures.BlockedCmd), cfg)
+
+	to := r.Execute(ctx, nil)
+	assert.Equal(t, "starting", testhelp.ChannelRead(to.Stdout))
+
+	// WHEN the running context is cancelled
+	cancel()
+
+	// THEN the runnable is interrupted after the grace period, returning error
+	err := testhelp.ChannelErrClosedTimeout(to.Errors, 5*time.Second)
+	assert.Error(t, err)
+	assert.NotEqual(t, testhelp.ErrChannelTimeout, err)
+}
+
 func TestNoRaces(t *testing.T) {
 	log.SetOutput(ioutil.Discard)  // discard logs so not to break race tests
 	defer log.SetOutput(os.Stderr) // return back to default
@@ -0,0 +1,27 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+// +build linux darwin
+
+package executor
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// runInProcessGroup makes the command to run in its own process group, so the signals
+// sent on termination also reach any child process spawned by it.
+func runInProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+// signalTermination sends a SIGTERM to the process group so it can finish gracefully.
+func signalTermination(process *os.Process) error {
+	return syscall.Kill(-process.Pid, syscall.SIGTERM)
+}
+
+// kill sends a SIGKILL to the process group.
+func kill(process *os.Process) error {
+	return syscall.Kill(-process.Pid, syscall.SIGKILL)
+}
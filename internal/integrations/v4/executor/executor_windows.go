@@ -3,12 +3,26 @@
 package executor
 
 import (
-	"context"
+	"os"
 	"os/exec"
 )
 
-// userAwareCmd returns a cancellable Cmd struct to execute the given command with the provided
+// userAwareCmd returns a Cmd struct to execute the given command with the provided
 // arguments.
-func (r *Executor) userAwareCmd(ctx context.Context) *exec.Cmd {
-	return exec.CommandContext(ctx, r.Command, r.Args...)
+func (r *Executor) userAwareCmd() *exec.Cmd {
+	return exec.Command(r.Command, r.Args...)
+}
+
+// runInProcessGroup does nothing, as Windows processes are terminated individually.
+func runInProcessGroup(_ *exec.Cmd) {}
+
+// signalTermination kills the process, as Windows does not provide a termination signal
+// that can be sent to console-less processes.
+func signalTermination(process *os.Process) error {
+	return process.Kill()
+}
+
+// kill terminates the process.
+func kill(process *os.Process) error {
+	return process.Kill()
 }
@@ -0,0 +1,84 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package executor
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// RetryPolicy configures the re-execution of a failed task within the same execution cycle.
+type RetryPolicy struct {
+	// Count is the maximum number of re-executions after a failure. Zero disables retries
+	Count int
+	// Backoff is the time to wait before the first re-execution. It is doubled after each retry
+	Backoff time.Duration
+}
+
+// Retry runs a task through the passed execute function and, if it fails, runs it again after the backoff of the
+// policy, until it succeeds or the retries are exhausted. The standard output and error of all the attempts are
+// forwarded, but only the errors of the last attempt, so the task is reported as failed only if all of them failed.
+// The returned function provides the number of retries performed.
+func Retry(ctx context.Context, policy RetryPolicy, execute func() OutputReceive) (OutputReceive, func() int) {
+	if policy.Count <= 0 {
+		return execute(), func() int { return 0 }
+	}
+
+	var retries int32
+	out, receiver := NewOutput()
+	go func() {
+		defer out.Close()
+		backoff := policy.Backoff
+		for attempt := 0; ; attempt++ {
+			errs := forwardAttempt(execute(), out)
+			if len(errs) == 0 || attempt >= policy.Count || ctx.Err() != nil {
+				for _, err := range errs {
+					out.Errors <- err
+				}
+				return
+			}
+
+			illog.WithError(errs[len(errs)-1]).
+				WithField("retry", attempt+1).
+				WithField("backoff", backoff).
+				Debug("Task failed. Retrying.")
+			select {
+			case <-ctx.Done():
+				for _, err := range errs {
+					out.Errors <- err
+				}
+				return
+			case <-time.After(backoff):
+			}
+			backoff *= 2
+			atomic.AddInt32(&retries, 1)
+		}
+	}()
+	return receiver, func() int { return int(atomic.LoadInt32(&retries)) }
+}
+
+// forwardAttempt forwards the standard output and error of a task attempt until it finishes,
+// returning its errors.
+func forwardAttempt(attempt OutputReceive, out OutputSend) (errs []error) {
+	wg := sync.WaitGroup{}
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for line := range attempt.Stdout {
+			out.Stdout <- line
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for line := range attempt.Stderr {
+			out.Stderr <- line
+		}
+	}()
+	for err := range attempt.Errors {
+		errs = append(errs, err)
+	}
+	wg.Wait()
+	return errs
+}
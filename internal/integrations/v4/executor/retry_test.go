@@ -0,0 +1,113 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package executor
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/fortytw2/leaktest"
+	"github.com/newrelic/infrastructure-agent/internal/integrations/v4/testhelp"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeAttempts returns an execute function whose attempts write a line to the standard output and
+// fail until the passed number of failures is reached.
+func fakeAttempts(failures int) (execute func() OutputReceive, attempts *int) {
+	attempts = new(int)
+	return func() OutputReceive {
+		out, receiver := NewOutput()
+		*attempts++
+		attempt := *attempts
+		go func() {
+			defer out.Close()
+			out.Stdout <- []byte(fmt.Sprintf("attempt %d", attempt))
+			out.Stderr <- []byte("stderr")
+			if attempt <= failures {
+				out.Errors <- fmt.Errorf("failure %d", attempt)
+			}
+		}()
+		return receiver
+	}, attempts
+}
+
+func TestRetry_SucceedsAfterFailures(t *testing.T) {
+	defer leaktest.Check(t)()
+
+	// GIVEN a task failing twice
+	execute, attempts := fakeAttempts(2)
+
+	// WHEN it is run with up to 3 retries
+	out, retries := Retry(context.Background(), RetryPolicy{Count: 3, Backoff: time.Millisecond}, execute)
+
+	// THEN the output of all the attempts is forwarded
+	assert.Equal(t, "attempt 1", testhelp.ChannelRead(out.Stdout))
+	assert.Equal(t, "attempt 2", testhelp.ChannelRead(out.Stdout))
+	assert.Equal(t, "attempt 3", testhelp.ChannelRead(out.Stdout))
+	// AND no error is reported, as the last attempt succeeded
+	assert.NoError(t, testhelp.ChannelErrClosed(out.Errors))
+	assert.Equal(t, 3, *attempts)
+	assert.Equal(t, 2, retries())
+}
+
+func TestRetry_ExhaustsRetries(t *testing.T) {
+	defer leaktest.Check(t)()
+
+	// GIVEN a task always failing
+	execute, attempts := fakeAttempts(100)
+
+	// WHEN it is run with up to 2 retries
+	out, retries := Retry(context.Background(), RetryPolicy{Count: 2, Backoff: time.Millisecond}, execute)
+	go func() {
+		for range out.Stdout {
+		}
+	}()
+	go func() {
+		for range out.Stderr {
+		}
+	}()
+
+	// THEN only the error of the last attempt is reported
+	assert.EqualError(t, testhelp.ChannelErrClosed(out.Errors), "failure 3")
+	<-out.Done
+	assert.Equal(t, 3, *attempts)
+	assert.Equal(t, 2, retries())
+}
+
+func TestRetry_Disabled(t *testing.T) {
+	defer leaktest.Check(t)()
+
+	// GIVEN a task always failing
+	execute, attempts := fakeAttempts(100)
+
+	// WHEN it is run without retries
+	out, retries := Retry(context.Background(), RetryPolicy{}, execute)
+
+	// THEN it is executed once
+	assert.Equal(t, "attempt 1", testhelp.ChannelRead(out.Stdout))
+	assert.EqualError(t, testhelp.ChannelErrClosed(out.Errors), "failure 1")
+	assert.Equal(t, 1, *attempts)
+	assert.Equal(t, 0, retries())
+}
+
+func TestRetry_CancelledDuringBackoff(t *testing.T) {
+	defer leaktest.Check(t)()
+
+	// GIVEN a task always failing, with a long backoff
+	execute, attempts := fakeAttempts(100)
+	ctx, cancel := context.WithCancel(context.Background())
+
+	out, retries := Retry(ctx, RetryPolicy{Count: 5, Backoff: time.Hour}, execute)
+	assert.Equal(t, "attempt 1", testhelp.ChannelRead(out.Stdout))
+	assert.Equal(t, "stderr", testhelp.ChannelRead(out.Stderr))
+
+	// WHEN the context is cancelled while waiting to retry
+	cancel()
+
+	// THEN the task finishes reporting the last error
+	assert.EqualError(t, testhelp.ChannelErrClosed(out.Errors), "failure 1")
+	assert.Equal(t, 1, *attempts)
+	assert.Equal(t, 0, retries())
+}
@@ -17,4 +17,6 @@ var (
 	// at the moment, unsupported, as they use env vars with Powershell. Left here to avoid compile errors
 	FileContentsCmd        = testhelp.Script("unsupported-test-case")
 	FileContentsFromEnvCmd = testhelp.Script("unsupported-test-case")
+	// termination signals are not supported on Windows
+	TrapTermCmd = testhelp.Script("unsupported-test-case")
 )
@@ -0,0 +1,9 @@
+#!/usr/bin/env sh
+
+trap 'echo "terminated"; exit 0' TERM
+
+echo "starting"
+
+while true; do
+  sleep 0.1
+done
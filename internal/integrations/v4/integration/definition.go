@@ -8,12 +8,17 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/newrelic/infrastructure-agent/internal/integrations/v4/builtin"
+	"github.com/newrelic/infrastructure-agent/internal/integrations/v4/capture"
 	"github.com/newrelic/infrastructure-agent/internal/integrations/v4/executor"
 	"github.com/newrelic/infrastructure-agent/internal/integrations/v4/when"
+	"github.com/newrelic/infrastructure-agent/pkg/databind/pkg/data"
 	"github.com/newrelic/infrastructure-agent/pkg/databind/pkg/databind"
 	"github.com/newrelic/infrastructure-agent/pkg/integrations/v4/config"
+	"github.com/newrelic/infrastructure-agent/pkg/integrations/v4/units"
 	"github.com/newrelic/infrastructure-agent/pkg/log"
 	"github.com/newrelic/infrastructure-agent/pkg/plugins/ids"
+	"github.com/newrelic/infrastructure-agent/pkg/schedule"
 
 	"io/ioutil"
 	"os"
@@ -39,8 +44,26 @@ type Definition struct {
 	InventorySource ids.PluginID
 	WhenConditions  []when.Condition
 	CmdChannelHash  string // not empty: generated by command-channel "run_integration", contains name+args hash
+	ConfigHash      string // not empty: fingerprint of the config entry, template and discovery it was loaded from
+	ConfigPath      string // not empty: path of the config file the entry was loaded from
+	MaxOutputSize   int    // max size, in bytes, of each payload. Zero or negative: no limit
+	DropOversized   bool   // oversized payloads are dropped instead of truncated
+	Retries         executor.RetryPolicy
+	DerivedMetrics  []config.DerivedMetric // raw counters replaced by their rate or delta before being emitted
+	UnitConverter   *units.Converter       // nil: the values are emitted in the units reported by the integration
+	SilentWindows   []*schedule.Window     // periods during which the integration is paused or its data tagged
+	ServeStale      time.Duration          // max age of the last successful output re-emitted on failures. Zero: disabled
 	runnable        executor.Executor
+	builtin         bool // the runnable command is the name of a built-in collector, run in-process
 	newTempFile     func(template []byte) (string, error)
+	rendered        *renderedMatches // last discovery replacement, reused while the discovered values don't change
+}
+
+// renderedMatches holds the integration instances replaced from a set of discovered values.
+type renderedMatches struct {
+	values          databind.Values
+	matches         []data.Transformed
+	foundConfigPath bool
 }
 
 func (d *Definition) TimeoutEnabled() bool {
@@ -62,13 +85,27 @@ func (d *Definition) PluginID(integrationName string) ids.PluginID {
 	return ids.NewDefaultInventoryPluginID(d.Name)
 }
 
+// UsedVariables returns the names of the variables referenced by the integration command line,
+// environment or configuration template.
+func (d *Definition) UsedVariables() []string {
+	template := []string{d.runnable.Command, strings.Join(d.runnable.Args, " "), string(d.ConfigTemplate)}
+	if d.runnable.Cfg != nil {
+		for _, value := range d.runnable.Cfg.Environment {
+			template = append(template, value)
+		}
+	}
+	return databind.VariableNames([]byte(strings.Join(template, "\n")))
+}
+
 func (d *Definition) Run(ctx context.Context, bind *databind.Values, pidC chan<- int) ([]Output, error) {
 	logger := elog.WithField("integration_name", d.Name)
 	logger.Debug("Running task.")
 	// no discovery data: execute a single instance
 	if bind == nil {
 		logger.Debug("Running single instance.")
-		return []Output{{Receive: d.runnable.Execute(ctx, pidC)}}, nil
+		d.captureConfig(d.runnable, d.ConfigTemplate)
+		receive, retries := d.execute(ctx, d.runnable, pidC)
+		return []Output{{Receive: receive, Retries: retries}}, nil
 	}
 
 	// apply discovered data to run multiple instances
@@ -87,12 +124,20 @@ func (d *Definition) Run(ctx context.Context, bind *databind.Values, pidC chan<-
 	if d.ConfigTemplate != nil {
 		onDemand = ignoreConfigPathVar(&foundConfigPath)
 	}
-	matches, err := databind.Replace(bind, discoveredConfig{
-		Executor:       d.runnable.DeepClone(),
-		ConfigTemplate: d.ConfigTemplate,
-	}, databind.Provided(onDemand))
-	if err != nil {
-		return nil, err
+	var matches []data.Transformed
+	if d.rendered != nil && d.rendered.values.Equal(bind) {
+		logger.Debug("Discovery matches unchanged. Reusing the rendered configuration.")
+		matches, foundConfigPath = d.rendered.matches, d.rendered.foundConfigPath
+	} else {
+		var err error
+		matches, err = databind.Replace(bind, discoveredConfig{
+			Executor:       d.runnable.DeepClone(),
+			ConfigTemplate: d.ConfigTemplate,
+		}, databind.Provided(onDemand))
+		if err != nil {
+			return nil, err
+		}
+		d.rendered = &renderedMatches{values: *bind, matches: matches, foundConfigPath: foundConfigPath}
 	}
 
 	logger.Debug("Running through all discovery matches.")
@@ -106,6 +151,8 @@ func (d *Definition) Run(ctx context.Context, bind *databind.Values, pidC chan<-
 
 		var removeFile func(<-chan struct{})
 		if dc.ConfigTemplate != nil {
+			// the rendered executor is kept for the next executions, so its copy gets the file path
+			dc.Executor = dc.Executor.DeepClone()
 			templateFile, err := d.newTempFile(dc.ConfigTemplate)
 			if err != nil {
 				return nil, err
@@ -138,15 +185,44 @@ func (d *Definition) Run(ctx context.Context, bind *databind.Values, pidC chan<-
 		}
 
 		logger.Debug("Executing task.")
-		taskOutput := dc.Executor.Execute(ctx, nil)
+		d.captureConfig(dc.Executor, dc.ConfigTemplate)
+		taskOutput, retries := d.execute(ctx, dc.Executor, nil)
 		if removeFile != nil {
 			go removeFile(taskOutput.Done)
 		}
-		tasksOutput = append(tasksOutput, Output{Receive: taskOutput, ExtraLabels: ir.MetricAnnotations, EntityRewrite: ir.EntityRewrites})
+		tasksOutput = append(tasksOutput, Output{Receive: taskOutput, ExtraLabels: ir.MetricAnnotations, EntityRewrite: ir.EntityRewrites, Retries: retries})
 	}
 	return tasksOutput, nil
 }
 
+// execute runs the passed runnable either as an external process or, for built-in integrations,
+// as an in-process collector, retrying it on failure as configured. It also returns a function
+// providing the number of retries performed.
+func (d *Definition) execute(ctx context.Context, runnable executor.Executor, pidC chan<- int) (executor.OutputReceive, func() int) {
+	return executor.Retry(ctx, d.Retries, func() executor.OutputReceive {
+		if d.builtin {
+			return builtin.Execute(ctx, runnable.Command, runnable.Args)
+		}
+		return runnable.Execute(ctx, pidC)
+	})
+}
+
+// captureConfig stores the configuration an instance is executed with, if the integration is being captured.
+func (d *Definition) captureConfig(runnable executor.Executor, configTemplate []byte) {
+	if !capture.Active(d.Name) {
+		return
+	}
+	cfg := capture.ResolvedConfig{
+		Command:        runnable.Command,
+		Args:           runnable.Args,
+		ConfigTemplate: string(configTemplate),
+	}
+	if runnable.Cfg != nil {
+		cfg.Environment = runnable.Cfg.Environment
+	}
+	capture.Config(d.Name, cfg)
+}
+
 // remoteTempFile returns a function that removes the file corresponding to the passed path when the provided channel
 // is closed
 func removeTempFile(path string) func(<-chan struct{}) {
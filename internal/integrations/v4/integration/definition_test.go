@@ -343,3 +343,39 @@ func TestRun_RemoveExternalConfig(t *testing.T) {
 		}
 	})
 }
+
+func TestRun_ReusesRenderedConfig(t *testing.T) {
+	defer leaktest.Check(t)()
+
+	// GIVEN an integration with an external configuration file
+	configEntry := config.ConfigEntry{
+		InstanceName: "foo",
+		Exec:         testhelp.Command(fixtures.FileContentsWithArgCmd, "${config.path}"),
+		Config:       "${discovery.ip}",
+	}
+	template, err := LoadConfigTemplate(configEntry.TemplatePath, configEntry.Config)
+	require.NoError(t, err)
+	def, err := NewDefinition(configEntry, ErrLookup, nil, template)
+	require.NoError(t, err)
+
+	run := func(ip string) string {
+		vals := databind.NewValues(nil, databind.NewDiscovery(data.Map{"discovery.ip": ip}, nil, nil))
+		outs, err := def.Run(context.Background(), &vals, nil)
+		require.NoError(t, err)
+		require.Len(t, outs, 1)
+		assert.NoError(t, testhelp.ChannelErrClosed(outs[0].Receive.Errors))
+		return testhelp.ChannelRead(outs[0].Receive.Stdout)
+	}
+
+	// WHEN it is executed twice with the same discovery matches
+	assert.Equal(t, "1.2.3.4", run("1.2.3.4"))
+	rendered := def.rendered
+	assert.Equal(t, "1.2.3.4", run("1.2.3.4"))
+
+	// THEN the configuration is rendered once, but each execution gets its own configuration file
+	assert.Same(t, rendered, def.rendered)
+
+	// AND it is rendered again when the matches change
+	assert.Equal(t, "5.6.7.8", run("5.6.7.8"))
+	assert.NotSame(t, rendered, def.rendered)
+}
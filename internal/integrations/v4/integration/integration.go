@@ -6,13 +6,16 @@ import (
 	"errors"
 	"fmt"
 	"io/ioutil"
+	"strings"
 	"time"
 
+	"github.com/newrelic/infrastructure-agent/internal/integrations/v4/builtin"
 	"github.com/newrelic/infrastructure-agent/internal/integrations/v4/executor"
 	"github.com/newrelic/infrastructure-agent/internal/integrations/v4/when"
 	"github.com/newrelic/infrastructure-agent/pkg/config"
 	"github.com/newrelic/infrastructure-agent/pkg/databind/pkg/data"
 	config2 "github.com/newrelic/infrastructure-agent/pkg/integrations/v4/config"
+	"github.com/newrelic/infrastructure-agent/pkg/integrations/v4/units"
 	"github.com/newrelic/infrastructure-agent/pkg/log"
 	"github.com/newrelic/infrastructure-agent/pkg/plugins/ids"
 	"github.com/sirupsen/logrus"
@@ -26,6 +29,10 @@ const (
 
 	defaultTimeout = 120 * time.Second
 	minimumTimeout = 100 * time.Millisecond
+
+	defaultGracePeriod = 5 * time.Second
+
+	defaultRetryBackoff = 1 * time.Second
 )
 
 var ilog = log.WithComponent("integrations.Definition")
@@ -34,6 +41,8 @@ type Output struct {
 	Receive       executor.OutputReceive
 	ExtraLabels   data.Map
 	EntityRewrite []data.EntityRewrite
+	// Retries provides the number of times the instance has been re-executed after failing
+	Retries func() int
 }
 
 // InstancesLookup helps looking for integration executables that are not explicitly
@@ -59,20 +68,38 @@ func NewDefinition(ce config2.ConfigEntry, lookup InstancesLookup, passthroughEn
 			Directory:   ce.WorkDir,
 			Environment: ce.Env,
 			Passthrough: passthroughEnv,
+
+			TerminationGracePeriod: getGracePeriod(ce.GracePeriod),
 		},
 		Labels:         ce.Labels,
 		Name:           ce.InstanceName,
 		Interval:       getInterval(ce.Interval),
 		WhenConditions: conditions(ce.When),
 		ConfigTemplate: configTemplate,
+		MaxOutputSize:  int(ce.MaxOutputSize),
+		DropOversized:  ce.MaxOutputStrategy == config2.MaxOutputDrop,
+		Retries:        getRetryPolicy(ce.Retries),
+		DerivedMetrics: ce.DerivedMetrics,
+		ServeStale:     ce.ServeStale,
 		newTempFile:    newTempFile,
 	}
 
+	var err error
+	if d.UnitConverter, err = units.New(ce.UnitConversions); err != nil {
+		return Definition{}, err
+	}
+	for _, sw := range ce.SilentWindows {
+		window, err := sw.Window()
+		if err != nil {
+			return Definition{}, err
+		}
+		d.SilentWindows = append(d.SilentWindows, window)
+	}
+
 	if ce.InventorySource == "" {
 		// Set to empty as currently Inventory source unknown
 		d.InventorySource = ids.EmptyInventorySource
 	} else {
-		var err error
 		d.InventorySource, err = ids.FromString(ce.InventorySource)
 		if err != nil {
 			return Definition{}, errors.New("Error parsing 'inventory_source' YAML property: " + err.Error())
@@ -102,6 +129,10 @@ func NewDefinition(ce config2.ConfigEntry, lookup InstancesLookup, passthroughEn
 		err := d.fromLegacyV3(ce, lookup)
 		return d, err
 	}
+	if len(ce.Builtin) > 0 {
+		err := d.fromBuiltin(ce)
+		return d, err
+	}
 	if ce.Exec != nil {
 		// if providing an executable path directly
 		err := d.fromExecPath(ce)
@@ -110,7 +141,7 @@ func NewDefinition(ce config2.ConfigEntry, lookup InstancesLookup, passthroughEn
 	// if not an "exec" nor legacy integration, we'll look for an
 	// executable corresponding to the "name" field in any of the integrations
 	// folders, and wrap it into an "exec"
-	err := d.fromName(ce, lookup)
+	err = d.fromName(ce, lookup)
 	return d, err
 }
 
@@ -161,6 +192,17 @@ func (d *Definition) fromExecPath(te config2.ConfigEntry) error {
 	return err
 }
 
+// loads the Definition runnable from a collector embedded in the agent
+func (d *Definition) fromBuiltin(te config2.ConfigEntry) error {
+	if !builtin.Exists(te.Builtin[0]) {
+		return fmt.Errorf("unknown built-in integration %q. Available: %s",
+			te.Builtin[0], strings.Join(builtin.Names(), ", "))
+	}
+	d.runnable = executor.FromCmdSlice(te.Builtin, &d.ExecutorConfig)
+	d.builtin = true
+	return nil
+}
+
 // loads the Definition runnable from an executable name, looking for it into a
 // set of predefined folders
 func (d *Definition) fromName(te config2.ConfigEntry, lookup InstancesLookup) error {
@@ -204,6 +246,27 @@ func getInterval(duration string) time.Duration {
 	return d
 }
 
+// getGracePeriod returns the time a cancelled integration is given to finish after receiving the
+// termination signal. If unset, it returns the default grace period. Zero or negative values
+// disable it, so the integration is killed immediately.
+func getGracePeriod(gracePeriod *time.Duration) time.Duration {
+	if gracePeriod == nil {
+		return defaultGracePeriod
+	}
+	if *gracePeriod < 0 {
+		return 0
+	}
+	return *gracePeriod
+}
+
+func getRetryPolicy(retries config2.Retries) executor.RetryPolicy {
+	policy := executor.RetryPolicy{Count: retries.Count, Backoff: retries.Backoff}
+	if policy.Count > 0 && policy.Backoff == 0 {
+		policy.Backoff = defaultRetryBackoff
+	}
+	return policy
+}
+
 // get condition functions from the YAML 'when:' section
 func conditions(enabling config2.EnableConditions) []when.Condition {
 	var conds []when.Condition
@@ -216,6 +279,14 @@ func conditions(enabling config2.EnableConditions) []when.Condition {
 	if len(enabling.EnvExists) > 0 {
 		conds = append(conds, when.EnvExists(enabling.EnvExists))
 	}
+
+	if enabling.PortOpen != "" {
+		conds = append(conds, when.PortOpen(enabling.PortOpen))
+	}
+
+	if enabling.ProcessRunning != "" {
+		conds = append(conds, when.ProcessRunning(enabling.ProcessRunning))
+	}
 	return conds
 }
 
@@ -220,3 +220,39 @@ func TestDefinition_fromName(t *testing.T) {
 	assert.Equal(t, "/path/to/nri-foo", d.runnable.Command)
 	assert.Equal(t, []string{"arg1", "arg2"}, d.runnable.Args)
 }
+
+func TestDefinition_fromBuiltin(t *testing.T) {
+	// GIVEN a one-line built-in integration entry
+	var cfg config2.ConfigEntry
+	require.NoError(t, yaml.Unmarshal([]byte(`builtin: nginx_status http://${discovery.ip}/nginx_status`), &cfg))
+
+	// WHEN the definition is created
+	d, err := NewDefinition(cfg, ErrLookup, nil, nil)
+	require.NoError(t, err)
+
+	// THEN it is named after the collector
+	assert.Equal(t, "nginx_status", d.Name)
+	assert.True(t, d.builtin)
+	assert.Equal(t, "nginx_status", d.runnable.Command)
+
+	// AND its arguments accept discovery variables
+	disc := databind.NewValues(nil,
+		databind.NewDiscovery(data.Map{"discovery.ip": "127.0.0.1:1"}, nil, nil))
+	outputs, err := d.Run(context.Background(), &disc, nil)
+	require.NoError(t, err)
+	require.Len(t, outputs, 1)
+	err = <-outputs[0].Receive.Errors
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "http://127.0.0.1:1/nginx_status")
+}
+
+func TestDefinition_fromBuiltin_Unknown(t *testing.T) {
+	_, err := NewDefinition(config2.ConfigEntry{Builtin: []string{"apache_status"}}, ErrLookup, nil, nil)
+	assert.Error(t, err)
+
+	_, err = NewDefinition(config2.ConfigEntry{
+		Builtin: []string{"nginx_status", "http://localhost"},
+		Exec:    []string{"/bin/nginx_status"},
+	}, ErrLookup, nil, nil)
+	assert.Error(t, err)
+}
@@ -0,0 +1,81 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package runner
+
+import (
+	"context"
+	"sync"
+
+	"github.com/newrelic/infrastructure-agent/internal/integrations/v4/breaker"
+)
+
+// execution tracks the outcome of all the instances of an integration execution, and reports it
+// to the integration circuit breaker once all of them have finished. An execution fails if any
+// of its instances exits with error or emits an unparseable payload.
+// A nil execution is valid and doesn't track anything.
+type execution struct {
+	pending sync.WaitGroup
+	lock    sync.Mutex
+	err     error
+}
+
+// newExecution returns nil if the provided circuit breaker is nil.
+// The outcome is not reported if the passed context is cancelled, as the integration has been
+// stopped rather than failed.
+func newExecution(ctx context.Context, b *breaker.Breaker, instances int) *execution {
+	if b == nil {
+		return nil
+	}
+	e := &execution{}
+	// each instance finishes when both its standard output and errors have been processed
+	e.pending.Add(2 * instances)
+	go func() {
+		e.pending.Wait()
+		if ctx.Err() != nil {
+			return
+		}
+		if e.err != nil {
+			b.Failure(e.err)
+		} else {
+			b.Success()
+		}
+	}()
+	return e
+}
+
+func (e *execution) fail(err error) {
+	if e == nil {
+		return
+	}
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	e.err = err
+}
+
+func (e *execution) done() {
+	if e == nil {
+		return
+	}
+	e.pending.Done()
+}
+
+// trackErrors forwards the passed execution errors to the returned channel, recording them as
+// failures. The returned channel is closed when the passed one is closed.
+func (e *execution) trackErrors(ctx context.Context, errs <-chan error) <-chan error {
+	if e == nil {
+		return errs
+	}
+	fwd := make(chan error)
+	go func() {
+		defer e.done()
+		defer close(fwd)
+		for err := range errs {
+			e.fail(err)
+			select {
+			case fwd <- err:
+			case <-ctx.Done():
+			}
+		}
+	}()
+	return fwd
+}
@@ -4,7 +4,9 @@ package runner
 
 import (
 	"context"
+	"sync"
 
+	"github.com/newrelic/infrastructure-agent/internal/integrations/v4/breaker"
 	"github.com/newrelic/infrastructure-agent/internal/integrations/v4/integration"
 	"github.com/newrelic/infrastructure-agent/pkg/databind/pkg/databind"
 	"github.com/newrelic/infrastructure-agent/pkg/integrations/cmdrequest"
@@ -26,18 +28,34 @@ type Group struct {
 	// error is received. If unset, it will be runner.logErrors
 	handleErrorsProvide func() runnerErrorHandler
 	cmdReqHandle        cmdrequest.HandleFn
+	breakers            *breaker.Registry
+	telemetry           bool
+	// running integrations, so they can be individually stopped when the group is updated
+	running *runningIntegrations
+}
+
+// runningIntegrations stores the cancellation function of each running integration, indexed
+// by its definition ConfigHash.
+type runningIntegrations struct {
+	lock    sync.Mutex
+	ctx     context.Context
+	cancels map[string][]context.CancelFunc
 }
 
 type runnerErrorHandler func(ctx context.Context, errs <-chan error)
 
 // NewGroup configures a Group instance that is provided by the passed LoadFn
 // cfgPath is used for caching to be consumed by cmd-channel FF enabler.
+// breakers is optional (nil allowed), disabling the integrations circuit breaker.
+// telemetry enables the per-execution metrics of the group integrations.
 func NewGroup(
 	loadFn LoadFn,
 	il integration.InstancesLookup,
 	passthroughEnv []string,
 	emitter emitter.Emitter,
 	cmdReqHandle cmdrequest.HandleFn,
+	breakers *breaker.Registry,
+	telemetry bool,
 	cfgPath string,
 ) (g Group, c FeaturesCache, err error) {
 
@@ -47,17 +65,108 @@ func NewGroup(
 	}
 
 	g.emitter = emitter
+	g.breakers = breakers
+	g.telemetry = telemetry
 
 	return
 }
 
 // Run launches all the integrations to run in background. They can be cancelled with the
-// provided context
+// provided context, which also closes the discovery sources of the group.
 func (g *Group) Run(ctx context.Context) (hasStartedAnyOHI bool) {
+	g.running = &runningIntegrations{
+		ctx:     ctx,
+		cancels: map[string][]context.CancelFunc{},
+	}
+
+	g.running.lock.Lock()
+	defer g.running.lock.Unlock()
+
 	for _, integr := range g.integrations {
-		go NewRunner(integr, g.emitter, g.dSources, g.handleErrorsProvide, g.cmdReqHandle).Run(ctx, nil)
+		g.start(integr)
 		hasStartedAnyOHI = true
 	}
 
+	go func() {
+		<-ctx.Done()
+		g.running.lock.Lock()
+		defer g.running.lock.Unlock()
+		g.dSources.Close()
+	}()
+
 	return
 }
+
+// Update replaces the integrations of a running group by the ones of the passed group, loaded from the
+// same configuration file. Only the integrations whose configuration, discovery or environment changed
+// are restarted: the removed and modified ones are stopped, the added and modified ones are started and
+// the rest keep running untouched.
+func (g *Group) Update(newGroup Group) (stopped, started int) {
+	if g.running == nil {
+		return
+	}
+
+	g.running.lock.Lock()
+	defer g.running.lock.Unlock()
+
+	// unchanged integrations are matched by their hash, taking into account that a file may contain
+	// several identical entries
+	available := map[string]int{}
+	for hash, cancels := range g.running.cancels {
+		available[hash] = len(cancels)
+	}
+	kept := map[string]int{}
+	var pending []integration.Definition
+	for _, integr := range newGroup.integrations {
+		if integr.ConfigHash != "" && available[integr.ConfigHash] > 0 {
+			available[integr.ConfigHash]--
+			kept[integr.ConfigHash]++
+			continue
+		}
+		pending = append(pending, integr)
+	}
+
+	for hash, cancels := range g.running.cancels {
+		for _, cancel := range cancels[kept[hash]:] {
+			cancel()
+			stopped++
+		}
+		if kept[hash] == 0 {
+			delete(g.running.cancels, hash)
+		} else {
+			g.running.cancels[hash] = cancels[:kept[hash]]
+		}
+	}
+
+	// the discovery configuration is part of the hash, so if any integration is kept, the new group
+	// discovers the same sources and the running ones can keep being shared
+	if len(kept) > 0 {
+		newGroup.dSources.Close()
+	} else {
+		g.dSources.Close()
+		g.dSources = newGroup.dSources
+	}
+	g.integrations = newGroup.integrations
+	for _, integr := range pending {
+		g.start(integr)
+		started++
+	}
+
+	return
+}
+
+// start launches an integration in background. The running integrations lock must be held.
+func (g *Group) start(integr integration.Definition) {
+	ctx, cancel := context.WithCancel(g.running.ctx)
+	g.running.cancels[integr.ConfigHash] = append(g.running.cancels[integr.ConfigHash], cancel)
+	go NewRunner(integr, g.emitter, g.dSources, g.handleErrorsProvide, g.cmdReqHandle, g.breakers, g.telemetry).Run(ctx, nil)
+}
+
+// RunOnce executes each integration a single time, sequentially, returning once all of them
+// have finished. The discovery sources of the group are closed afterwards.
+func (g *Group) RunOnce(ctx context.Context) {
+	defer g.dSources.Close()
+	for _, integr := range g.integrations {
+		NewRunner(integr, g.emitter, g.dSources, g.handleErrorsProvide, g.cmdReqHandle, g.breakers, g.telemetry).RunOnce(ctx)
+	}
+}
@@ -3,9 +3,14 @@
 package runner
 
 import (
+	"crypto/sha256"
+	"fmt"
+
 	"github.com/newrelic/infrastructure-agent/internal/integrations/v4/integration"
+	"github.com/newrelic/infrastructure-agent/pkg/databind/pkg/databind"
 	"github.com/newrelic/infrastructure-agent/pkg/integrations/cmdrequest"
 	config2 "github.com/newrelic/infrastructure-agent/pkg/integrations/v4/config"
+	"gopkg.in/yaml.v2"
 )
 
 // LoadFn provides a basic, incomplete Group instance to be configured by the NewGroup function.
@@ -39,6 +44,11 @@ func NewLoadFn(cfg config2.YAML, agentAndCCFeatures *Features) LoadFn {
 			if err != nil {
 				return
 			}
+			i.ConfigHash, err = configHash(cfg.Databind, cfgEntry, template)
+			if err != nil {
+				return
+			}
+			i.ConfigPath = cfgPath
 
 			if agentAndCCFeatures == nil {
 				if cfgEntry.When.Feature == "" {
@@ -61,3 +71,17 @@ func NewLoadFn(cfg config2.YAML, agentAndCCFeatures *Features) LoadFn {
 		return
 	}
 }
+
+// configHash fingerprints an integration config entry along with its config template contents and
+// the discovery configuration of its file, so any change on them can be detected on reload.
+func configHash(discovery databind.YAMLConfig, entry config2.ConfigEntry, template []byte) (string, error) {
+	contents, err := yaml.Marshal(struct {
+		Discovery databind.YAMLConfig
+		Entry     config2.ConfigEntry
+		Template  []byte
+	}{discovery, entry, template})
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", sha256.Sum256(contents)), nil
+}
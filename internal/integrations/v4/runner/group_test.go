@@ -38,7 +38,7 @@ func TestGroup_Run(t *testing.T) {
 			{InstanceName: "saygoodbye", Exec: testhelp.Command(fixtures.IntegrationScript, "bye")},
 		},
 	}, nil)
-	gr, _, err := NewGroup(loader, integration.InstancesLookup{}, nil, te, cmdrequest.NoopHandleFn, "")
+	gr, _, err := NewGroup(loader, integration.InstancesLookup{}, nil, te, cmdrequest.NoopHandleFn, nil, false, "")
 	require.NoError(t, err)
 
 	// WHEN the Group executes all the integrations
@@ -76,7 +76,7 @@ func TestGroup_Run_Inventory(t *testing.T) {
 				Labels: map[string]string{"foo": "bar", "ou": "yea"}},
 		},
 	}, nil)
-	gr, _, err := NewGroup(loader, integration.InstancesLookup{}, nil, te, cmdrequest.NoopHandleFn, "")
+	gr, _, err := NewGroup(loader, integration.InstancesLookup{}, nil, te, cmdrequest.NoopHandleFn, nil, false, "")
 	require.NoError(t, err)
 
 	// WHEN the integration is executed
@@ -125,7 +125,7 @@ func TestGroup_Run_Inventory_OverridePrefix(t *testing.T) {
 				InventorySource: "custom/inventory"},
 		},
 	}, nil)
-	gr, _, err := NewGroup(loader, integration.InstancesLookup{}, nil, te, cmdrequest.NoopHandleFn, "")
+	gr, _, err := NewGroup(loader, integration.InstancesLookup{}, nil, te, cmdrequest.NoopHandleFn, nil, false, "")
 	require.NoError(t, err)
 
 	// WHEN the integration is executed
@@ -151,7 +151,7 @@ func TestGroup_Run_Timeout(t *testing.T) {
 			{InstanceName: "Hello", Exec: testhelp.Command(fixtures.BlockedCmd), Timeout: &to},
 		},
 	}, nil)
-	gr, _, err := NewGroup(loader, integration.InstancesLookup{}, nil, te, cmdrequest.NoopHandleFn, "")
+	gr, _, err := NewGroup(loader, integration.InstancesLookup{}, nil, te, cmdrequest.NoopHandleFn, nil, false, "")
 	require.NoError(t, err)
 	errs := interceptGroupErrors(&gr)
 
@@ -170,6 +170,51 @@ func TestGroup_Run_Timeout(t *testing.T) {
 	}
 }
 
+func TestGroup_Update_RestartsOnlyChangedIntegrations(t *testing.T) {
+	defer leaktest.Check(t)()
+
+	// GIVEN a running group with two integrations
+	te := &testemit.RecordEmitter{}
+	loadGroup := func(goodbyeArg string) Group {
+		gr, _, err := NewGroup(NewLoadFn(config2.YAML{
+			Integrations: []config2.ConfigEntry{
+				{InstanceName: "sayhello", Exec: testhelp.Command(fixtures.IntegrationScript, "hello")},
+				{InstanceName: "saygoodbye", Exec: testhelp.Command(fixtures.IntegrationScript, goodbyeArg)},
+			},
+		}, nil), integration.InstancesLookup{}, nil, te, cmdrequest.NoopHandleFn, nil, false, "")
+		require.NoError(t, err)
+		return gr
+	}
+	gr := loadGroup("bye")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	require.True(t, gr.Run(ctx))
+	_, err := te.ReceiveFrom("sayhello")
+	require.NoError(t, err)
+	_, err = te.ReceiveFrom("saygoodbye")
+	require.NoError(t, err)
+
+	// WHEN the group is updated with the same configuration
+	stopped, started := gr.Update(loadGroup("bye"))
+
+	// THEN no integration is restarted
+	assert.Equal(t, 0, stopped)
+	assert.Equal(t, 0, started)
+
+	// WHEN the group is updated with one of the integrations modified
+	stopped, started = gr.Update(loadGroup("adios"))
+
+	// THEN only the modified integration is restarted
+	assert.Equal(t, 1, stopped)
+	assert.Equal(t, 1, started)
+	dataset, err := te.ReceiveFrom("saygoodbye")
+	require.NoError(t, err)
+	require.Len(t, dataset.DataSet.Metrics, 1)
+	assert.Equal(t, "adios", dataset.DataSet.Metrics[0]["value"])
+	assert.NoError(t, te.ExpectTimeout("sayhello", 500*time.Millisecond))
+}
+
 func TestGroup_Run_DiscoveryChangesUpdated(t *testing.T) {
 	defer leaktest.Check(t)()
 
@@ -242,7 +287,7 @@ func TestGroup_Run_ConfigPathUpdated(t *testing.T) {
 			Config:       "hello",
 		}},
 	}, nil)
-	group, _, err := NewGroup(loader, integration.InstancesLookup{}, nil, te, cmdrequest.NoopHandleFn, "")
+	group, _, err := NewGroup(loader, integration.InstancesLookup{}, nil, te, cmdrequest.NoopHandleFn, nil, false, "")
 	require.NoError(t, err)
 	// shortening the interval to avoid long tests
 	group.integrations[0].Interval = 100 * time.Millisecond
@@ -319,7 +364,7 @@ func TestGroup_Run_IntegrationScriptPrintsErrorsAndReturnCodeIsZero(t *testing.T
 			{InstanceName: "log_errors", Exec: testhelp.Command(fixtures.IntegrationPrintsErr, "bye")},
 		},
 	}, nil)
-	gr, _, err := NewGroup(loader, integration.InstancesLookup{}, nil, te, cmdrequest.NoopHandleFn, "")
+	gr, _, err := NewGroup(loader, integration.InstancesLookup{}, nil, te, cmdrequest.NoopHandleFn, nil, false, "")
 	require.NoError(t, err)
 
 	// WHEN we add a hook to the log to capture the "error" and "fatal" levels
@@ -0,0 +1,68 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package runner
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/newrelic/infrastructure-agent/pkg/databind/pkg/data"
+	"github.com/newrelic/infrastructure-agent/pkg/integrations/v4/protocol"
+	"github.com/sirupsen/logrus"
+)
+
+// limitOutput applies the max_output_size of the integration to a payload. Oversized payloads are
+// truncated at the last complete dataset within the limit or, if configured to be dropped or none of
+// their datasets fits, discarded, submitting an event that reports it.
+// It returns false if the payload has to be discarded.
+func (r *runner) limitOutput(line []byte, extraLabels data.Map, entityRewrite []data.EntityRewrite) ([]byte, bool) {
+	max := r.definition.MaxOutputSize
+	if max <= 0 || len(line) <= max {
+		return line, true
+	}
+
+	llog := r.log.WithFields(logrus.Fields{
+		"size":            len(line),
+		"max_output_size": max,
+	})
+	if !r.definition.DropOversized {
+		if truncated, ok := protocol.SalvagePayload(line[:max]); ok {
+			llog.Warn("integration payload exceeds its max_output_size. Emitting only the complete datasets within the limit")
+			return truncated, true
+		}
+	}
+
+	llog.Warn("integration payload exceeds its max_output_size. Dropping it")
+	event, err := r.oversizedOutputEvent(len(line))
+	if err == nil {
+		err = r.emitter.Emit(r.definition, extraLabels, entityRewrite, event)
+	}
+	if err != nil {
+		r.log.WithError(err).Warn("cannot emit the dropped payload event")
+	}
+	return nil, false
+}
+
+// oversizedOutputEvent returns a protocol v3 payload holding an event that reports a dropped payload.
+func (r *runner) oversizedOutputEvent(size int) ([]byte, error) {
+	return json.Marshal(protocol.PluginDataV3{
+		PluginOutputIdentifier: protocol.PluginOutputIdentifier{
+			Name:               r.definition.Name,
+			RawProtocolVersion: "3",
+		},
+		DataSets: []protocol.PluginDataSetV3{{
+			PluginDataSet: protocol.PluginDataSet{
+				Events: []protocol.EventData{{
+					"summary": fmt.Sprintf("Integration %s payload of %d bytes dropped for exceeding its max_output_size of %d bytes",
+						r.definition.Name, size, r.definition.MaxOutputSize),
+					"category": "integration",
+					"attributes": map[string]interface{}{
+						"integrationName":    r.definition.Name,
+						"outputSizeBytes":    size,
+						"maxOutputSizeBytes": r.definition.MaxOutputSize,
+					},
+				}},
+			},
+		}},
+	})
+}
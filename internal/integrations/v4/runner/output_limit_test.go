@@ -0,0 +1,106 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package runner
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/newrelic/infrastructure-agent/internal/integrations/v4/integration"
+	"github.com/newrelic/infrastructure-agent/pkg/databind/pkg/data"
+	"github.com/newrelic/infrastructure-agent/pkg/integrations/v4/protocol"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// payloadsEmitter records the raw emitted payloads.
+type payloadsEmitter struct {
+	payloads [][]byte
+}
+
+func (e *payloadsEmitter) Emit(_ integration.Definition, _ data.Map, _ []data.EntityRewrite, integrationJSON []byte) error {
+	e.payloads = append(e.payloads, integrationJSON)
+	return nil
+}
+
+const threeDataSets = `{"name":"nri-test","protocol_version":"3","integration_version":"1.0.0","data":[` +
+	`{"metrics":[{"event_type":"TestSample","value":1}]},` +
+	`{"metrics":[{"event_type":"TestSample","value":2}]},` +
+	`{"metrics":[{"event_type":"TestSample","value":3}]}]}`
+
+func newLimitedRunner(maxSize int, drop bool, e *payloadsEmitter) *runner {
+	r := NewRunner(integration.Definition{
+		Name:          "nri-test",
+		MaxOutputSize: maxSize,
+		DropOversized: drop,
+	}, e, nil, nil, nil, nil, false)
+	r.log = illog.WithFields(LogFields(r.definition))
+	return r
+}
+
+func Test_runner_limitOutput_WithinLimit(t *testing.T) {
+	e := &payloadsEmitter{}
+	for _, maxSize := range []int{0, len(threeDataSets)} {
+		line, ok := newLimitedRunner(maxSize, true, e).limitOutput([]byte(threeDataSets), nil, nil)
+		assert.True(t, ok)
+		assert.Equal(t, threeDataSets, string(line))
+	}
+	assert.Empty(t, e.payloads)
+}
+
+func Test_runner_limitOutput_Truncate(t *testing.T) {
+	// GIVEN a payload whose third dataset exceeds the max output size
+	e := &payloadsEmitter{}
+	r := newLimitedRunner(len(threeDataSets)-10, false, e)
+
+	// WHEN the limit is applied
+	line, ok := r.limitOutput([]byte(threeDataSets), nil, nil)
+
+	// THEN only the complete datasets within the limit are kept
+	require.True(t, ok)
+	assert.LessOrEqual(t, len(line), len(threeDataSets)-10)
+	var payload protocol.PluginDataV3
+	require.NoError(t, json.Unmarshal(line, &payload))
+	assert.Equal(t, "nri-test", payload.Name)
+	assert.Len(t, payload.DataSets, 2)
+	assert.Empty(t, e.payloads)
+}
+
+func Test_runner_limitOutput_Drop(t *testing.T) {
+	// GIVEN an integration configured to drop oversized payloads
+	e := &payloadsEmitter{}
+	r := newLimitedRunner(100, true, e)
+
+	// WHEN the limit is applied to an oversized payload
+	_, ok := r.limitOutput([]byte(threeDataSets), nil, nil)
+
+	// THEN the payload is discarded
+	assert.False(t, ok)
+
+	// AND an event reporting it is emitted
+	require.Len(t, e.payloads, 1)
+	var payload protocol.PluginDataV3
+	require.NoError(t, json.Unmarshal(e.payloads[0], &payload))
+	require.Len(t, payload.DataSets, 1)
+	require.Len(t, payload.DataSets[0].Events, 1)
+	event := payload.DataSets[0].Events[0]
+	assert.Contains(t, event["summary"], "dropped")
+	assert.Equal(t, map[string]interface{}{
+		"integrationName":    "nri-test",
+		"outputSizeBytes":    float64(len(threeDataSets)),
+		"maxOutputSizeBytes": 100.0,
+	}, event["attributes"])
+}
+
+func Test_runner_limitOutput_TruncateWithoutCompleteDataSets(t *testing.T) {
+	// GIVEN a limit where no dataset fits
+	e := &payloadsEmitter{}
+	r := newLimitedRunner(100, false, e)
+
+	// WHEN the limit is applied
+	_, ok := r.limitOutput([]byte(threeDataSets), nil, nil)
+
+	// THEN the payload is dropped, reporting it
+	assert.False(t, ok)
+	assert.Len(t, e.payloads, 1)
+}
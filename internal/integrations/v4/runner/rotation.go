@@ -0,0 +1,164 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package runner
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/newrelic/infrastructure-agent/pkg/databind/pkg/databind"
+	"github.com/newrelic/infrastructure-agent/pkg/helpers"
+	"github.com/newrelic/infrastructure-agent/pkg/integrations/v4/protocol"
+)
+
+// variableRotations tracks the values of the variables used by an integration, to detect when any of
+// them changes after being fetched again, e.g. a secret rotated once its TTL expired.
+type variableRotations struct {
+	lock       sync.Mutex
+	used       map[string]bool
+	last       *databind.Values
+	onRotation func(rotated []string) // invoked with the used variables that changed
+}
+
+func newVariableRotations(used []string, onRotation func(rotated []string)) *variableRotations {
+	v := &variableRotations{used: map[string]bool{}, onRotation: onRotation}
+	for _, name := range used {
+		v.used[name] = true
+	}
+	return v
+}
+
+// track records the values fetched for the integration, returning true if any of the used variables
+// changed since the previous ones.
+func (v *variableRotations) track(values *databind.Values) bool {
+	if v == nil || values == nil || len(v.used) == 0 {
+		return false
+	}
+	v.lock.Lock()
+	defer v.lock.Unlock()
+
+	last := v.last
+	v.last = values
+	var rotated []string
+	for _, name := range values.ChangedVariables(last) {
+		if v.used[name] {
+			rotated = append(rotated, name)
+		}
+	}
+	if len(rotated) == 0 {
+		return false
+	}
+	v.onRotation(rotated)
+	return true
+}
+
+// rotationWatch fetches the variables of a running integration each time their TTL expires, restarting
+// it as soon as any of the used ones rotates, so it doesn't keep running with stale credentials.
+// A nil rotationWatch is valid and never restarts the integration.
+type rotationWatch struct {
+	restarted int32
+}
+
+// watchRotations returns nil if the integration doesn't use any variable. The watch finishes when the
+// passed context is done, and the restart function cancels it.
+func (r *runner) watchRotations(ctx context.Context, restart context.CancelFunc) *rotationWatch {
+	if r.dSources == nil || r.rotations == nil || len(r.rotations.used) == 0 {
+		return nil
+	}
+	ttl := r.dSources.VariablesTTL()
+	if ttl <= 0 {
+		return nil
+	}
+	w := &rotationWatch{}
+	go func() {
+		ticker := time.NewTicker(ttl)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				values, err := r.applyDiscovery()
+				if err != nil {
+					r.log.WithError(helpers.ObfuscateSensitiveDataFromError(err)).
+						Warn("can't fetch the integration variables to check their rotation")
+					continue
+				}
+				if r.rotations.track(values) {
+					atomic.StoreInt32(&w.restarted, 1)
+					restart()
+					return
+				}
+			}
+		}
+	}()
+	return w
+}
+
+// Restarted returns true if the integration has been restarted due to a variable rotation.
+func (w *rotationWatch) Restarted() bool {
+	return w != nil && atomic.LoadInt32(&w.restarted) == 1
+}
+
+// stopContext wraps the passed context to report it as cancelled once the integration has been
+// restarted, so the outcome of the interrupted execution is neither recorded as a failure nor reported.
+func (w *rotationWatch) stopContext(ctx context.Context) context.Context {
+	if w == nil {
+		return ctx
+	}
+	return &restartedContext{Context: ctx, watch: w}
+}
+
+type restartedContext struct {
+	context.Context
+	watch *rotationWatch
+}
+
+func (c *restartedContext) Err() error {
+	if c.watch.Restarted() {
+		return context.Canceled
+	}
+	return c.Context.Err()
+}
+
+// reportRotation logs and emits an event for the rotated variables of the integration.
+func (r *runner) reportRotation(rotated []string) {
+	r.log.WithField("variables", strings.Join(rotated, ", ")).
+		Info("Integration variables rotated. Restarting it with the new values.")
+	for _, name := range rotated {
+		event, err := r.rotationEvent(name)
+		if err == nil {
+			err = r.emitter.Emit(r.definition, nil, nil, event)
+		}
+		if err != nil {
+			r.log.WithError(err).Warn("cannot emit the variable rotation event")
+		}
+	}
+}
+
+// rotationEvent returns a protocol v3 payload holding an event that reports a variable rotation.
+func (r *runner) rotationEvent(variable string) ([]byte, error) {
+	return json.Marshal(protocol.PluginDataV3{
+		PluginOutputIdentifier: protocol.PluginOutputIdentifier{
+			Name:               r.definition.Name,
+			RawProtocolVersion: "3",
+		},
+		DataSets: []protocol.PluginDataSetV3{{
+			PluginDataSet: protocol.PluginDataSet{
+				Events: []protocol.EventData{{
+					"summary":  fmt.Sprintf("Variable %s rotated. Integration %s restarted with its new value", variable, r.definition.Name),
+					"category": "integration",
+					"attributes": map[string]interface{}{
+						"integrationName": r.definition.Name,
+						"variable":        variable,
+					},
+				}},
+			},
+		}},
+	})
+}
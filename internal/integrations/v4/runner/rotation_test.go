@@ -0,0 +1,136 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package runner
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"runtime"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/newrelic/infrastructure-agent/internal/integrations/v4/integration"
+	"github.com/newrelic/infrastructure-agent/internal/integrations/v4/testhelp"
+	"github.com/newrelic/infrastructure-agent/internal/integrations/v4/testhelp/testemit"
+	"github.com/newrelic/infrastructure-agent/pkg/databind/pkg/databind"
+	"github.com/newrelic/infrastructure-agent/pkg/integrations/cmdrequest"
+	"github.com/newrelic/infrastructure-agent/pkg/integrations/v4/config"
+)
+
+func Test_runner_Run_RestartsOnVariableRotation(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the long-running integration is a shell script")
+	}
+	// GIVEN a secret whose password changes after being read twice
+	var reads int32
+	vault := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		password := "first"
+		if atomic.AddInt32(&reads, 1) > 2 {
+			password = "rotated"
+		}
+		_, _ = fmt.Fprintf(w, `{"data": {"data": {"password": %q}}}`, password)
+	}))
+	defer vault.Close()
+	sources, err := databind.LoadYAML([]byte(`
+variables:
+  creds:
+    ttl: 100ms
+    vault:
+      http:
+        url: ` + vault.URL + `
+  unused:
+    ttl: 1h
+    vault:
+      http:
+        url: ` + vault.URL + `
+`))
+	require.NoError(t, err)
+
+	// AND a long-running integration using it, which reports the password and keeps running
+	script, err := ioutil.TempFile("", "rotating*.sh")
+	require.NoError(t, err)
+	defer os.Remove(script.Name())
+	_, err = script.WriteString(`echo '{"name":"com.newrelic.test","protocol_version":"1","metrics":[{"event_type":"TestSample","value":"'$1'"}]}'
+sleep 60
+`)
+	require.NoError(t, err)
+	require.NoError(t, script.Close())
+	def, err := integration.NewDefinition(config.ConfigEntry{
+		InstanceName: "rotating",
+		Exec:         testhelp.Command(testhelp.Script(script.Name()), "${creds.password}"),
+		Interval:     "1h",
+	}, integration.ErrLookup, nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"creds"}, def.UsedVariables())
+
+	e := &testemit.RecordEmitter{}
+	r := NewRunner(def, e, sources, nil, cmdrequest.NoopHandleFn, nil, false)
+
+	// WHEN the integration runs
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go r.Run(ctx, nil)
+
+	// THEN it runs with the current password
+	dataset := receive(t, e, "rotating")
+	require.Len(t, dataset.DataSet.Metrics, 1)
+	assert.Equal(t, "first", dataset.DataSet.Metrics[0]["value"])
+
+	// AND an event reports the rotation once the TTL of the secret expires
+	dataset = receive(t, e, "rotating")
+	require.Len(t, dataset.DataSet.Events, 1)
+	event := dataset.DataSet.Events[0]
+	assert.Equal(t, "integration", event["category"])
+	assert.Contains(t, event["summary"], "Variable creds rotated")
+	assert.Equal(t, "creds", event["attributes"].(map[string]interface{})["variable"])
+
+	// AND the integration is restarted with the new password, without waiting for its interval
+	dataset = receive(t, e, "rotating")
+	require.Len(t, dataset.DataSet.Metrics, 1)
+	assert.Equal(t, "rotated", dataset.DataSet.Metrics[0]["value"])
+}
+
+func receive(t *testing.T, e *testemit.RecordEmitter, name string) testemit.EmittedData {
+	received := make(chan testemit.EmittedData, 1)
+	go func() {
+		if dataset, err := e.ReceiveFrom(name); err == nil {
+			received <- dataset
+		}
+	}()
+	select {
+	case dataset := <-received:
+		return dataset
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "payload not emitted")
+	}
+	return testemit.EmittedData{}
+}
+
+func TestVariableRotations_OnlyUsedVariables(t *testing.T) {
+	var rotated [][]string
+	rotations := newVariableRotations([]string{"creds"}, func(names []string) {
+		rotated = append(rotated, names)
+	})
+
+	first := databind.NewValues(map[string]string{"creds.password": "a", "other": "x"})
+	assert.False(t, rotations.track(&first))
+
+	otherChanged := databind.NewValues(map[string]string{"creds.password": "a", "other": "y"})
+	assert.False(t, rotations.track(&otherChanged))
+
+	credsChanged := databind.NewValues(map[string]string{"creds.password": "b", "other": "y"})
+	assert.True(t, rotations.track(&credsChanged))
+	assert.Equal(t, [][]string{{"creds"}}, rotated)
+
+	// a nil tracker doesn't track anything
+	var none *variableRotations
+	assert.False(t, none.track(&credsChanged))
+}
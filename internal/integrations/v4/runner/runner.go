@@ -5,11 +5,14 @@ package runner
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"regexp"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/newrelic/infrastructure-agent/internal/integrations/v4/breaker"
+	"github.com/newrelic/infrastructure-agent/internal/integrations/v4/capture"
 	"github.com/newrelic/infrastructure-agent/internal/integrations/v4/integration"
 	"github.com/newrelic/infrastructure-agent/internal/integrations/v4/when"
 	"github.com/newrelic/infrastructure-agent/pkg/databind/pkg/data"
@@ -19,7 +22,9 @@ import (
 	"github.com/newrelic/infrastructure-agent/pkg/integrations/cmdrequest"
 	"github.com/newrelic/infrastructure-agent/pkg/integrations/cmdrequest/protocol"
 	"github.com/newrelic/infrastructure-agent/pkg/integrations/v4/emitter"
+	protocol2 "github.com/newrelic/infrastructure-agent/pkg/integrations/v4/protocol"
 	"github.com/newrelic/infrastructure-agent/pkg/log"
+	"github.com/newrelic/infrastructure-agent/pkg/schedule"
 
 	"github.com/sirupsen/logrus"
 )
@@ -52,16 +57,23 @@ type runner struct {
 	healthCheck    sync.Once
 	heartBeatFunc  func()
 	heartBeatMutex sync.RWMutex
+	breaker        *breaker.Breaker
+	telemetry      bool
+	stale          *staleCache
+	rotations      *variableRotations
 }
 
 // NewRunner creates an integration runner instance.
-// args: discoverySources, handleErrorsProvide and cmdReqHandle are optional (nils allowed).
+// args: discoverySources, handleErrorsProvide, cmdReqHandle and breakers are optional (nils allowed).
+// If telemetry is enabled, the runner emits dimensional metrics describing each integration execution.
 func NewRunner(
 	intDef integration.Definition,
 	emitter emitter.Emitter,
 	dSources *databind.Sources,
 	handleErrorsProvide func() runnerErrorHandler,
 	cmdReqHandle cmdrequest.HandleFn,
+	breakers *breaker.Registry,
+	telemetry bool,
 ) *runner {
 	r := &runner{
 		emitter:       emitter,
@@ -69,8 +81,12 @@ func NewRunner(
 		dSources:      dSources,
 		definition:    intDef,
 		heartBeatFunc: func() {},
-		stderrParser:  parseLogrusFields,
+		stderrParser:  parseStderrFields,
+		breaker:       breakers.Get(intDef.Name, intDef.ConfigPath, intDef.ConfigHash),
+		telemetry:     telemetry,
+		stale:         newStaleCache(intDef.ServeStale),
 	}
+	r.rotations = newVariableRotations(intDef.UsedVariables(), r.reportRotation)
 	if handleErrorsProvide != nil {
 		r.handleErrors = handleErrorsProvide()
 	} else {
@@ -85,15 +101,9 @@ func (r *runner) Run(ctx context.Context, pidWChan chan<- int) {
 	for {
 		waitForNextExecution := time.After(r.definition.Interval)
 
-		values, err := r.applyDiscovery()
-		if err != nil {
-			r.log.
-				WithError(helpers.ObfuscateSensitiveDataFromError(err)).
-				Error("can't fetch discovery items")
-		} else {
-			if when.All(r.definition.WhenConditions...) {
-				r.execute(ctx, values, pidWChan)
-			}
+		if restarted := r.discoverAndExecute(ctx, pidWChan); restarted && ctx.Err() == nil {
+			// rotated variables: restarting without waiting for the next interval
+			continue
 		}
 
 		select {
@@ -105,6 +115,36 @@ func (r *runner) Run(ctx context.Context, pidWChan chan<- int) {
 	}
 }
 
+// RunOnce applies the discovery and executes the integration a single time, returning once all
+// its instances have finished.
+func (r *runner) RunOnce(ctx context.Context) {
+	r.log = illog.WithFields(LogFields(r.definition))
+	r.discoverAndExecute(ctx, nil)
+}
+
+// discoverAndExecute returns true if the execution has been interrupted to restart the integration.
+func (r *runner) discoverAndExecute(ctx context.Context, pidWChan chan<- int) bool {
+	values, err := r.applyDiscovery()
+	if err != nil {
+		r.log.
+			WithError(helpers.ObfuscateSensitiveDataFromError(err)).
+			Error("can't fetch discovery items")
+		return false
+	}
+	r.rotations.track(values)
+
+	if !when.All(r.definition.WhenConditions...) {
+		r.log.Debug("Integration conditions not met. Skipping execution.")
+	} else if w := schedule.Active(r.definition.SilentWindows, timeNow()); w != nil && w.Action == schedule.ActionPause {
+		r.log.WithField("window", w.Name).Debug("Integration paused by silent window. Skipping execution.")
+	} else if !r.breaker.Allow() {
+		r.log.Debug("Integration disabled by its circuit breaker. Skipping execution.")
+	} else {
+		return r.execute(ctx, values, pidWChan)
+	}
+	return false
+}
+
 func LogFields(def integration.Definition) logrus.Fields {
 	fields := logrus.Fields{
 		"integration_name": def.Name,
@@ -148,8 +188,10 @@ func (r *runner) heartBeat() {
 // to finish
 // For long-time running integrations, avoids starting the next
 // discover-execute cycle until all the parallel processes have ended
-func (r *runner) execute(ctx context.Context, matches *databind.Values, pidWChan chan<- int) {
+// Returns true if the instances have been stopped because any of their variables rotated.
+func (r *runner) execute(ctx context.Context, matches *databind.Values, pidWChan chan<- int) bool {
 	def := r.definition
+	stopCtx := ctx
 
 	// If timeout configuration is set, wraps current context in a heartbeat-enabled timeout context
 	if def.TimeoutEnabled() {
@@ -158,24 +200,47 @@ func (r *runner) execute(ctx context.Context, matches *databind.Values, pidWChan
 		r.setHeartBeat(act.HeartBeat)
 	}
 
-	// Runs all the matching integration instances
-	outputs, err := r.definition.Run(ctx, matches, pidWChan)
+	// Runs all the matching integration instances, which are stopped if their variables rotate
+	started := timeNow()
+	runCtx, restart := context.WithCancel(ctx)
+	defer restart()
+	rotation := r.watchRotations(runCtx, restart)
+	stopCtx = rotation.stopContext(stopCtx)
+	outputs, err := r.definition.Run(runCtx, matches, pidWChan)
 	if err != nil {
 		r.log.WithError(err).Error("can't start integration")
-		return
+		r.breaker.Failure(err)
+		for _, output := range r.stale.allFresh() {
+			r.serveStale(output)
+		}
+		return false
 	}
+	exec := newExecution(stopCtx, r.breaker, len(outputs))
 
 	// Waits for all the integrations to finish and reads the standard output and errors
 	wg := sync.WaitGroup{}
 	waitForCurrent := make(chan struct{})
-	wg.Add(len(outputs))
-	for _, out := range outputs {
+	wg.Add(2 * len(outputs))
+	for i, out := range outputs {
 		o := out
-		go r.handleLines(o.Receive.Stdout, o.ExtraLabels, o.EntityRewrite)
-		go r.handleStderr(o.Receive.Stderr)
+		stderrLog := r.log
+		if len(outputs) > 1 {
+			// distinguishes the instances resulting of multiple discovery matches
+			stderrLog = stderrLog.WithField("instance", i)
+		}
+		tel := newInstanceTelemetry(stopCtx, r.telemetry, started, func(t *instanceTelemetry) {
+			r.reportTelemetry(t, o.ExtraLabels, o.EntityRewrite)
+		})
+		tel.countRetries(o.Retries)
+		stale := newStaleInstance(ctx, stopCtx, r.stale, o.ExtraLabels, o.EntityRewrite, r.serveStale)
 		go func() {
 			defer wg.Done()
-			r.handleErrors(ctx, o.Receive.Errors)
+			r.handleLines(o.Receive.Stdout, o.ExtraLabels, o.EntityRewrite, exec, tel, stale)
+		}()
+		go r.handleStderr(o.Receive.Stderr, stderrLog)
+		go func() {
+			defer wg.Done()
+			r.handleErrors(ctx, exec.trackErrors(ctx, tel.trackExit(ctx, stale.trackFailures(ctx, o.Receive.Errors))))
 		}()
 	}
 
@@ -192,22 +257,35 @@ func (r *runner) execute(ctx context.Context, matches *databind.Values, pidWChan
 		r.log.Debug("Integration instances finished their execution. Waiting until next interval.")
 	}
 
-	return
+	return rotation.Restarted()
 }
 
-func (r *runner) handleStderr(stderr <-chan []byte) {
+// handleStderr logs the standard error lines of an integration instance. Lines in a known structured
+// format (logrus text or JSON, zap JSON) are logged at the level they declare, with their fields.
+// Unstructured lines are only logged in debug mode.
+func (r *runner) handleStderr(stderr <-chan []byte, llog log.Entry) {
 	for line := range stderr {
 		r.lastStderr.Add(line)
 
-		if r.log.IsDebugEnabled() {
-			r.log.WithField("line", string(line)).Debug("Integration stderr (not parsed).")
-		} else {
-			fields := r.stderrParser(string(line))
-			if v, ok := fields["level"]; ok && (v == "error" || v == "fatal") {
-				// If a field already exists, like the time, logrus automatically adds the prefix "deps." to the
-				// Duplicated keys
-				r.log.WithFields(logrus.Fields(fields)).Error("received an integration log line")
-			}
+		fields := r.stderrParser(string(line))
+		level, ok := logLevel(fields)
+		if !ok {
+			llog.WithField("line", string(line)).Debug("Integration stderr (not parsed).")
+			continue
+		}
+
+		// If a field already exists, like the time, logrus automatically adds the prefix "fields." to the
+		// duplicated keys
+		flog := llog.WithFields(logrus.Fields(fields))
+		switch level {
+		case logrus.DebugLevel:
+			flog.Debug("received an integration log line")
+		case logrus.InfoLevel:
+			flog.Info("received an integration log line")
+		case logrus.WarnLevel:
+			flog.Warn("received an integration log line")
+		default:
+			flog.Error("received an integration log line")
 		}
 	}
 }
@@ -231,7 +309,10 @@ func (r *runner) logErrors(ctx context.Context, errs <-chan error) {
 	}
 }
 
-func (r *runner) handleLines(stdout <-chan []byte, extraLabels data.Map, entityRewrite []data.EntityRewrite) {
+func (r *runner) handleLines(stdout <-chan []byte, extraLabels data.Map, entityRewrite []data.EntityRewrite, exec *execution, tel *instanceTelemetry, stale *staleInstance) {
+	defer exec.done()
+	defer tel.done()
+	defer stale.done()
 	for line := range stdout {
 		llog := r.log.WithFieldsF(func() logrus.Fields {
 			return logrus.Fields{"payload": string(line)}
@@ -243,6 +324,8 @@ func (r *runner) handleLines(stdout <-chan []byte, extraLabels data.Map, entityR
 			continue
 		}
 
+		capture.Payload(r.definition.Name, line)
+
 		if ok, ver := protocol.IsCommandRequest(line); ok {
 			llog.WithField("version", ver).Debug("Received run request.")
 			cr, err := protocol.DeserializeLine(line)
@@ -262,12 +345,30 @@ func (r *runner) handleLines(stdout <-chan []byte, extraLabels data.Map, entityR
 			continue
 		}
 
+		// an integration terminated while writing its output may leave a truncated payload
+		if !json.Valid(line) {
+			if salvaged, ok := protocol2.SalvagePayload(line); ok {
+				llog.Warn("received a truncated integration payload. Emitting only its complete datasets")
+				line = salvaged
+			}
+		}
+
+		var ok bool
+		if line, ok = r.limitOutput(line, extraLabels, entityRewrite); !ok {
+			continue
+		}
+
 		llog.Debug("Received payload.")
+		tel.payload(line)
 		err := r.emitter.Emit(r.definition, extraLabels, entityRewrite, line)
 		if err != nil {
 			llog.WithError(err).Warn("Cannot emit integration payload")
+			exec.fail(err)
+			tel.parseError()
+			stale.fail()
 		} else {
 			r.heartBeat()
+			stale.emitted(line)
 		}
 
 		r.healthCheck.Do(func() {
@@ -280,10 +381,54 @@ func (r *runner) handleLines(stdout <-chan []byte, extraLabels data.Map, entityR
 	}
 }
 
+// serveStale re-emits the last successful output of an instance, tagged as stale.
+func (r *runner) serveStale(output staleOutput) {
+	r.log.WithField("age", timeNow().Sub(output.at)).Debug("Integration execution failed. Serving its last successful output.")
+	extraLabels := withStaleAttribute(output.extraLabels)
+	for _, payload := range output.payloads {
+		if err := r.emitter.Emit(r.definition, extraLabels, output.entityRewrite, payload); err != nil {
+			r.log.WithError(err).Warn("Cannot emit stale integration payload")
+		}
+	}
+}
+
 func isHeartBeat(line []byte) bool {
 	return bytes.Equal(bytes.Trim(line, " "), heartBeatJSON)
 }
 
+// parseStderrFields parses a log line either in JSON format, as written by the logrus JSON formatter or
+// zap, or in the logrus text format.
+func parseStderrFields(line string) (fields logFields) {
+	if trimmed := strings.TrimSpace(line); strings.HasPrefix(trimmed, "{") {
+		if err := json.Unmarshal([]byte(trimmed), &fields); err == nil {
+			return fields
+		}
+	}
+	return parseLogrusFields(line)
+}
+
+// logLevel returns the agent log level matching the level declared in the passed log line fields,
+// or false if the line doesn't declare any known level.
+func logLevel(fields logFields) (logrus.Level, bool) {
+	for _, key := range []string{"level", "lvl", "severity"} {
+		value, ok := fields[key].(string)
+		if !ok {
+			continue
+		}
+		switch strings.ToLower(value) {
+		case "trace", "debug":
+			return logrus.DebugLevel, true
+		case "info", "notice":
+			return logrus.InfoLevel, true
+		case "warn", "warning":
+			return logrus.WarnLevel, true
+		case "error", "dpanic", "panic", "fatal", "critical":
+			return logrus.ErrorLevel, true
+		}
+	}
+	return logrus.InfoLevel, false
+}
+
 func parseLogrusFields(line string) (fields logFields) {
 	matches := logrusRegexp.FindAllStringSubmatch(line, -1)
 	fields = make(logFields, len(matches))
@@ -2,15 +2,25 @@ package runner
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
 	"testing"
 	"time"
 
+	"github.com/newrelic/infrastructure-agent/internal/integrations/v4/breaker"
 	"github.com/newrelic/infrastructure-agent/internal/integrations/v4/fixtures"
 	"github.com/newrelic/infrastructure-agent/internal/integrations/v4/integration"
 	"github.com/newrelic/infrastructure-agent/internal/integrations/v4/testhelp"
 	"github.com/newrelic/infrastructure-agent/internal/integrations/v4/testhelp/testemit"
+	"github.com/newrelic/infrastructure-agent/pkg/databind/pkg/data"
 	"github.com/newrelic/infrastructure-agent/pkg/integrations/cmdrequest"
 	"github.com/newrelic/infrastructure-agent/pkg/integrations/v4/config"
+	protocol2 "github.com/newrelic/infrastructure-agent/pkg/integrations/v4/protocol"
+	"github.com/newrelic/infrastructure-agent/pkg/log"
+	"github.com/sirupsen/logrus"
+	logtest "github.com/sirupsen/logrus/hooks/test"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -23,7 +33,7 @@ func Test_runner_Run(t *testing.T) {
 	require.NoError(t, err)
 
 	e := &testemit.RecordEmitter{}
-	r := NewRunner(def, e, nil, nil, cmdrequest.NoopHandleFn)
+	r := NewRunner(def, e, nil, nil, cmdrequest.NoopHandleFn, nil, false)
 
 	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
 	defer cancel()
@@ -38,3 +48,195 @@ func Test_runner_Run(t *testing.T) {
 	assert.Equal(t, "bar", metrics[0]["value"])
 	assert.Empty(t, dataset.Metadata.Labels)
 }
+
+func Test_runner_Run_TripsCircuitBreaker(t *testing.T) {
+	// GIVEN an integration that exits with error
+	def, err := integration.NewDefinition(config.ConfigEntry{
+		InstanceName: "failing",
+		Exec:         testhelp.Command(fixtures.ErrorCmd),
+	}, integration.ErrLookup, nil, nil)
+	require.NoError(t, err)
+
+	// AND a circuit breaker that trips on the first failure
+	breakers := breaker.NewRegistry(breaker.Config{Threshold: 1}, nil)
+	r := NewRunner(def, &testemit.RecordEmitter{}, nil, nil, cmdrequest.NoopHandleFn, breakers, false)
+
+	// WHEN the integration is run
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	go r.Run(ctx, nil)
+
+	// THEN the integration is disabled
+	require.Eventually(t, func() bool {
+		return !breakers.Get("failing", "", "").Allow()
+	}, 5*time.Second, 10*time.Millisecond)
+	status := breakers.Status()
+	require.Len(t, status, 1)
+	assert.Equal(t, breaker.StateOpen, status[0].State)
+	assert.NotEmpty(t, status[0].LastError)
+}
+
+// telemetryEmitter records the telemetry payloads, failing to emit any payload from a protocol
+// other than v4.
+type telemetryEmitter struct {
+	payloads chan protocol2.DataV4
+}
+
+func (e *telemetryEmitter) Emit(_ integration.Definition, _ data.Map, _ []data.EntityRewrite, integrationJSON []byte) error {
+	if version, err := protocol2.VersionFromPayload(integrationJSON, true); err != nil || version != protocol2.V4 {
+		return errors.New("unexpected payload")
+	}
+	var payload protocol2.DataV4
+	if err := json.Unmarshal(integrationJSON, &payload); err != nil {
+		return err
+	}
+	e.payloads <- payload
+	return nil
+}
+
+func Test_runner_Run_ReportsTelemetry(t *testing.T) {
+	// GIVEN an integration that writes an unparseable payload and exits with error
+	def, err := integration.NewDefinition(config.ConfigEntry{
+		InstanceName: "failing",
+		Exec:         testhelp.Command(fixtures.ErrorCmd),
+	}, integration.ErrLookup, nil, nil)
+	require.NoError(t, err)
+
+	// AND a runner with telemetry enabled
+	e := &telemetryEmitter{payloads: make(chan protocol2.DataV4, 10)}
+	r := NewRunner(def, e, nil, nil, cmdrequest.NoopHandleFn, nil, true)
+
+	// WHEN the integration is run
+	r.RunOnce(context.Background())
+
+	// THEN the execution telemetry is emitted
+	var payload protocol2.DataV4
+	select {
+	case payload = <-e.payloads:
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "telemetry not emitted")
+	}
+	require.Len(t, payload.DataSets, 1)
+	assert.Equal(t, "failing", payload.DataSets[0].Common.Attributes["integration.name"])
+
+	values := map[string]float64{}
+	for _, m := range payload.DataSets[0].Metrics {
+		assert.Equal(t, protocol2.MetricTypeGauge, m.Type)
+		var value float64
+		require.NoError(t, json.Unmarshal(m.Value, &value))
+		values[m.Name] = value
+	}
+	assert.Equal(t, 3.0, values[metricExitCode])
+	assert.Equal(t, float64(len("starting")), values[metricPayloadBytes])
+	assert.Equal(t, 0.0, values[metricDataSets])
+	assert.Equal(t, 1.0, values[metricParseErrors])
+	assert.Equal(t, 0.0, values[metricRetries])
+	assert.Contains(t, values, metricDuration)
+}
+
+func Test_runner_Run_ReportsRetries(t *testing.T) {
+	// GIVEN a failing integration configured to be retried twice
+	def, err := integration.NewDefinition(config.ConfigEntry{
+		InstanceName: "retried",
+		Exec:         testhelp.Command(fixtures.ErrorCmd),
+		Retries:      config.Retries{Count: 2, Backoff: time.Millisecond},
+	}, integration.ErrLookup, nil, nil)
+	require.NoError(t, err)
+
+	// AND a runner with telemetry enabled
+	e := &telemetryEmitter{payloads: make(chan protocol2.DataV4, 10)}
+	r := NewRunner(def, e, nil, nil, cmdrequest.NoopHandleFn, nil, true)
+
+	// WHEN the integration is run
+	r.RunOnce(context.Background())
+
+	// THEN the telemetry reports the retries of the execution
+	var payload protocol2.DataV4
+	select {
+	case payload = <-e.payloads:
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "telemetry not emitted")
+	}
+	require.Len(t, payload.DataSets, 1)
+	values := map[string]float64{}
+	for _, m := range payload.DataSets[0].Metrics {
+		var value float64
+		require.NoError(t, json.Unmarshal(m.Value, &value))
+		values[m.Name] = value
+	}
+	assert.Equal(t, 2.0, values[metricRetries])
+	assert.Equal(t, 3.0, values[metricExitCode])
+	// AND the payloads written by all the attempts have been processed
+	assert.Equal(t, float64(3*len("starting")), values[metricPayloadBytes])
+}
+
+func TestInstanceTelemetry_Payload(t *testing.T) {
+	tel := &instanceTelemetry{}
+
+	tel.payload([]byte(`{"protocol_version":"4","integration":{"name":"nri-test","version":"2.1.0"},"data":[{},{}]}`))
+	tel.payload([]byte(`{"name":"com.newrelic.test","protocol_version":"1","integration_version":"1.0.0","metrics":[]}`))
+
+	assert.Equal(t, 3, tel.dataSets)
+	assert.Equal(t, "1.0.0", tel.version)
+}
+
+func Test_runner_handleStderr_MapsLevels(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	defer log.SetOutput(os.Stderr)
+	hook := new(logtest.Hook)
+	log.AddHook(hook)
+
+	// GIVEN a runner receiving stderr lines in several formats
+	r := NewRunner(integration.Definition{Name: "nri-test"}, &testemit.RecordEmitter{}, nil, nil, nil, nil, false)
+	stderr := make(chan []byte, 4)
+	stderr <- []byte(`{"level":"warn","ts":1611923766.527,"caller":"main.go:12","msg":"slow query"}`)
+	stderr <- []byte(`time="2020-02-11T17:28:50+01:00" level=info msg="started"`)
+	stderr <- []byte(`{"level":"error","msg":"connection refused","time":"2020-02-11T17:28:50+01:00"}`)
+	stderr <- []byte(`plain line`)
+	close(stderr)
+
+	// WHEN they are handled
+	r.handleStderr(stderr, illog.WithFields(LogFields(r.definition)))
+
+	// THEN the structured lines are logged at their level, along with their fields
+	var entries []*logrus.Entry
+	for _, entry := range hook.AllEntries() {
+		if entry.Data["integration_name"] == "nri-test" {
+			entries = append(entries, entry)
+		}
+	}
+	require.Len(t, entries, 3)
+	assert.Equal(t, logrus.WarnLevel, entries[0].Level)
+	assert.Equal(t, "slow query", entries[0].Data["msg"])
+	assert.Equal(t, "main.go:12", entries[0].Data["caller"])
+	assert.Equal(t, logrus.InfoLevel, entries[1].Level)
+	assert.Equal(t, "started", entries[1].Data["msg"])
+	assert.Equal(t, logrus.ErrorLevel, entries[2].Level)
+	assert.Equal(t, "connection refused", entries[2].Data["msg"])
+}
+
+func Test_logLevel(t *testing.T) {
+	tests := map[string]struct {
+		line  string
+		level logrus.Level
+		ok    bool
+	}{
+		"logrus text":  {`level=debug msg="Temperature changes"`, logrus.DebugLevel, true},
+		"logrus json":  {`{"level":"warning","msg":"m","time":"2015-03-26T01:27:38-04:00"}`, logrus.WarnLevel, true},
+		"zap json":     {`{"level":"dpanic","ts":1611923766.5,"msg":"m"}`, logrus.ErrorLevel, true},
+		"upper case":   {`{"severity":"INFO","message":"m"}`, logrus.InfoLevel, true},
+		"fatal":        {`level=fatal msg="The ice breaks!"`, logrus.ErrorLevel, true},
+		"unknown":      {`level=loud msg=m`, logrus.InfoLevel, false},
+		"unstructured": {`panic: runtime error: index out of range`, logrus.InfoLevel, false},
+		"broken json":  {`{"level":"error"`, logrus.InfoLevel, false},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			level, ok := logLevel(parseStderrFields(tc.line))
+			assert.Equal(t, tc.ok, ok)
+			if tc.ok {
+				assert.Equal(t, tc.level, level)
+			}
+		})
+	}
+}
@@ -0,0 +1,200 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package runner
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/newrelic/infrastructure-agent/pkg/databind/pkg/data"
+)
+
+// staleAttribute is added to the re-emitted payloads, so they can be told apart from fresh data.
+const staleAttribute = "stale"
+
+// staleCache keeps the last successful output of each integration instance, to be re-emitted when a
+// later execution of the instance fails or times out, up to a maximum age.
+// A nil staleCache is valid and doesn't keep anything.
+type staleCache struct {
+	maxAge  time.Duration
+	lock    sync.Mutex
+	outputs map[string]staleOutput // by instance, as identified by its discovery labels
+}
+
+// staleOutput is the output of a successful instance execution.
+type staleOutput struct {
+	payloads      [][]byte
+	extraLabels   data.Map
+	entityRewrite []data.EntityRewrite
+	at            time.Time
+}
+
+// newStaleCache returns nil if the maximum age is not positive.
+func newStaleCache(maxAge time.Duration) *staleCache {
+	if maxAge <= 0 {
+		return nil
+	}
+	return &staleCache{maxAge: maxAge, outputs: map[string]staleOutput{}}
+}
+
+// store replaces the output of an instance, discarding the expired outputs of other instances.
+func (c *staleCache) store(key string, output staleOutput) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for k, o := range c.outputs {
+		if output.at.Sub(o.at) > c.maxAge {
+			delete(c.outputs, k)
+		}
+	}
+	c.outputs[key] = output
+}
+
+// fresh returns the output of an instance if it's not older than the maximum age.
+func (c *staleCache) fresh(key string) (staleOutput, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	o, ok := c.outputs[key]
+	if !ok || timeNow().Sub(o.at) > c.maxAge {
+		return staleOutput{}, false
+	}
+	return o, true
+}
+
+// allFresh returns the outputs of all the instances not older than the maximum age.
+func (c *staleCache) allFresh() []staleOutput {
+	if c == nil {
+		return nil
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	var outputs []staleOutput
+	for _, o := range c.outputs {
+		if timeNow().Sub(o.at) <= c.maxAge {
+			outputs = append(outputs, o)
+		}
+	}
+	return outputs
+}
+
+// instanceKey identifies an integration instance by its discovery labels.
+func instanceKey(extraLabels data.Map) string {
+	pairs := make([]string, 0, len(extraLabels))
+	for k, v := range extraLabels {
+		pairs = append(pairs, k+"="+v)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, "\n")
+}
+
+// staleInstance tracks the output of an integration instance execution, to be stored in the cache
+// once both its standard output and errors have been processed if the execution succeeded, or to
+// serve the cached output otherwise.
+// A nil staleInstance is valid and doesn't track anything.
+type staleInstance struct {
+	pending  sync.WaitGroup
+	lock     sync.Mutex
+	payloads [][]byte
+	failed   bool
+}
+
+// newStaleInstance returns nil if the passed cache is nil. The execution is considered failed if the
+// passed context is done, as its timeout expired. The serve function receives the cached output of a
+// failed execution that didn't emit any payload, unless the stop context is cancelled, as the
+// integration has been stopped rather than failed.
+func newStaleInstance(ctx, stopCtx context.Context, c *staleCache, extraLabels data.Map, entityRewrite []data.EntityRewrite, serve func(staleOutput)) *staleInstance {
+	if c == nil {
+		return nil
+	}
+	s := &staleInstance{}
+	s.pending.Add(2)
+	go func() {
+		s.pending.Wait()
+		if stopCtx.Err() != nil {
+			return
+		}
+		s.lock.Lock()
+		defer s.lock.Unlock()
+		key := instanceKey(extraLabels)
+		if !s.failed && ctx.Err() == nil {
+			if len(s.payloads) > 0 {
+				c.store(key, staleOutput{
+					payloads:      s.payloads,
+					extraLabels:   extraLabels,
+					entityRewrite: entityRewrite,
+					at:            timeNow(),
+				})
+			}
+			return
+		}
+		// the payloads emitted by a failed execution are fresher than the cached ones
+		if len(s.payloads) > 0 {
+			return
+		}
+		if output, ok := c.fresh(key); ok {
+			serve(output)
+		}
+	}()
+	return s
+}
+
+// emitted records a payload successfully emitted by the instance.
+func (s *staleInstance) emitted(payload []byte) {
+	if s == nil {
+		return
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.payloads = append(s.payloads, payload)
+}
+
+// fail records a failure of the instance.
+func (s *staleInstance) fail() {
+	if s == nil {
+		return
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.failed = true
+}
+
+func (s *staleInstance) done() {
+	if s == nil {
+		return
+	}
+	s.pending.Done()
+}
+
+// trackFailures forwards the passed execution errors to the returned channel, recording them as
+// failures. The returned channel is closed when the passed one is closed.
+func (s *staleInstance) trackFailures(ctx context.Context, errs <-chan error) <-chan error {
+	if s == nil {
+		return errs
+	}
+	fwd := make(chan error)
+	go func() {
+		defer s.done()
+		defer close(fwd)
+		for err := range errs {
+			s.fail()
+			select {
+			case fwd <- err:
+			case <-ctx.Done():
+			}
+		}
+	}()
+	return fwd
+}
+
+// withStaleAttribute returns a copy of the extra labels with the stale attribute. Keys without the
+// "label." prefix are emitted as attributes.
+func withStaleAttribute(extraLabels data.Map) data.Map {
+	labels := make(data.Map, len(extraLabels)+1)
+	for k, v := range extraLabels {
+		labels[k] = v
+	}
+	labels[staleAttribute] = "true"
+	return labels
+}
@@ -0,0 +1,123 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package runner
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/newrelic/infrastructure-agent/internal/integrations/v4/integration"
+	"github.com/newrelic/infrastructure-agent/pkg/databind/pkg/data"
+	"github.com/newrelic/infrastructure-agent/pkg/integrations/cmdrequest"
+	"github.com/newrelic/infrastructure-agent/pkg/log"
+)
+
+// runStaleInstance simulates an instance execution, returning the output served by it, if any.
+func runStaleInstance(ctx, stopCtx context.Context, c *staleCache, payloads []string, err error) *staleOutput {
+	served := make(chan staleOutput, 1)
+	s := newStaleInstance(ctx, stopCtx, c, data.Map{"discovery.ip": "10.0.0.1"}, nil, func(o staleOutput) {
+		served <- o
+	})
+	errs := make(chan error, 1)
+	if err != nil {
+		errs <- err
+	}
+	close(errs)
+	for range s.trackFailures(ctx, errs) {
+	}
+	for _, p := range payloads {
+		s.emitted([]byte(p))
+	}
+	s.done()
+
+	select {
+	case o := <-served:
+		return &o
+	case <-time.After(100 * time.Millisecond):
+		return nil
+	}
+}
+
+func TestStaleInstance(t *testing.T) {
+	now := time.Unix(1611923766, 0)
+	timeNow = func() time.Time { return now }
+	defer func() { timeNow = time.Now }()
+	ctx := context.Background()
+
+	// GIVEN a successful execution
+	c := newStaleCache(10 * time.Minute)
+	assert.Nil(t, runStaleInstance(ctx, ctx, c, []string{`{"a":1}`, `{"b":2}`}, nil))
+	require.Eventually(t, func() bool {
+		_, ok := c.fresh(instanceKey(data.Map{"discovery.ip": "10.0.0.1"}))
+		return ok
+	}, time.Second, time.Millisecond)
+
+	// WHEN a later execution fails without emitting any payload
+	now = now.Add(5 * time.Minute)
+	served := runStaleInstance(ctx, ctx, c, nil, errors.New("exit status 1"))
+
+	// THEN the output of the successful execution is served
+	require.NotNil(t, served)
+	assert.Equal(t, [][]byte{[]byte(`{"a":1}`), []byte(`{"b":2}`)}, served.payloads)
+	assert.Equal(t, data.Map{"discovery.ip": "10.0.0.1"}, served.extraLabels)
+
+	t.Run("failed with payloads", func(t *testing.T) {
+		assert.Nil(t, runStaleInstance(ctx, ctx, c, []string{`{"c":3}`}, errors.New("exit status 1")))
+	})
+
+	t.Run("timed out", func(t *testing.T) {
+		timedOut, cancel := context.WithCancel(ctx)
+		cancel()
+		assert.NotNil(t, runStaleInstance(timedOut, ctx, c, nil, nil))
+	})
+
+	t.Run("stopped", func(t *testing.T) {
+		stopped, cancel := context.WithCancel(ctx)
+		cancel()
+		assert.Nil(t, runStaleInstance(stopped, stopped, c, nil, errors.New("killed")))
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		now = now.Add(5*time.Minute + time.Second)
+		assert.Nil(t, runStaleInstance(ctx, ctx, c, nil, errors.New("exit status 1")))
+		assert.Empty(t, c.allFresh())
+	})
+}
+
+func TestStaleCache_Disabled(t *testing.T) {
+	c := newStaleCache(0)
+	assert.Nil(t, c)
+	assert.Nil(t, newStaleInstance(context.Background(), context.Background(), c, nil, nil, nil))
+	assert.Empty(t, c.allFresh())
+}
+
+type labelsEmitter struct {
+	labels   []data.Map
+	payloads []string
+}
+
+func (e *labelsEmitter) Emit(_ integration.Definition, extraLabels data.Map, _ []data.EntityRewrite, integrationJSON []byte) error {
+	e.labels = append(e.labels, extraLabels)
+	e.payloads = append(e.payloads, string(integrationJSON))
+	return nil
+}
+
+func Test_runner_serveStale(t *testing.T) {
+	e := &labelsEmitter{}
+	r := NewRunner(integration.Definition{Name: "flaky", ServeStale: time.Minute}, e, nil, nil, cmdrequest.NoopHandleFn, nil, false)
+	r.log = log.WithComponent("test")
+
+	r.serveStale(staleOutput{
+		payloads:    [][]byte{[]byte(`{"a":1}`)},
+		extraLabels: data.Map{"label.env": "prod"},
+		at:          time.Now(),
+	})
+
+	assert.Equal(t, []string{`{"a":1}`}, e.payloads)
+	assert.Equal(t, []data.Map{{"label.env": "prod", "stale": "true"}}, e.labels)
+}
@@ -0,0 +1,225 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package runner
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os/exec"
+	"sync"
+	"time"
+
+	"github.com/newrelic/infrastructure-agent/pkg/databind/pkg/data"
+	"github.com/newrelic/infrastructure-agent/pkg/integrations/v4/protocol"
+)
+
+// Names of the dimensional metrics reported after each integration instance execution.
+const (
+	metricDuration     = "integration.execution.duration"
+	metricExitCode     = "integration.execution.exitCode"
+	metricPayloadBytes = "integration.execution.payloadBytes"
+	metricDataSets     = "integration.execution.datasets"
+	metricParseErrors  = "integration.execution.parseErrors"
+	metricRetries      = "integration.execution.retries"
+
+	telemetryIntegrationName = "com.newrelic.infrastructure.integrations.telemetry"
+
+	// exit code reported when the integration process couldn't be started or waited for
+	unknownExitCode = -1
+)
+
+// timeNow is replaceable for testing purposes.
+var timeNow = time.Now
+
+// instanceTelemetry accumulates the telemetry of a single integration instance execution, to
+// be reported once both its standard output and errors have been processed.
+// A nil instanceTelemetry is valid and doesn't track anything.
+type instanceTelemetry struct {
+	pending      sync.WaitGroup
+	lock         sync.Mutex
+	start        time.Time
+	version      string
+	exitCode     int
+	payloadBytes int
+	dataSets     int
+	parseErrors  int
+	retries      func() int // nil if the instance isn't retried
+}
+
+// payloadHeader holds the payload fields used to identify the integration version and count
+// its datasets, for any protocol version.
+type payloadHeader struct {
+	Integration        protocol.IntegrationMetadata `json:"integration"`
+	IntegrationVersion string                       `json:"integration_version"`
+	DataSets           *[]json.RawMessage           `json:"data"`
+}
+
+// newInstanceTelemetry returns nil if telemetry is disabled. The passed report function is
+// invoked once the instance has finished, unless the passed context is cancelled, as the
+// integration has been stopped rather than finished.
+func newInstanceTelemetry(ctx context.Context, enabled bool, start time.Time, report func(*instanceTelemetry)) *instanceTelemetry {
+	if !enabled {
+		return nil
+	}
+	t := &instanceTelemetry{start: start}
+	// as for the execution, an instance finishes when both its standard output and errors have been processed
+	t.pending.Add(2)
+	go func() {
+		t.pending.Wait()
+		if ctx.Err() != nil {
+			return
+		}
+		report(t)
+	}()
+	return t
+}
+
+// payload records a payload received from the integration standard output.
+func (t *instanceTelemetry) payload(line []byte) {
+	if t == nil {
+		return
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.payloadBytes += len(line)
+
+	var header payloadHeader
+	if err := json.Unmarshal(line, &header); err != nil {
+		return
+	}
+	if header.Integration.Version != "" {
+		t.version = header.Integration.Version
+	} else if header.IntegrationVersion != "" {
+		t.version = header.IntegrationVersion
+	}
+	if header.DataSets != nil {
+		t.dataSets += len(*header.DataSets)
+	} else {
+		// protocol v1 payloads hold a single dataset
+		t.dataSets++
+	}
+}
+
+// parseError records a payload that couldn't be parsed or emitted.
+func (t *instanceTelemetry) parseError() {
+	if t == nil {
+		return
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.parseErrors++
+}
+
+// countRetries sets the function providing the number of retries of the instance.
+func (t *instanceTelemetry) countRetries(retries func() int) {
+	if t == nil {
+		return
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.retries = retries
+}
+
+func (t *instanceTelemetry) done() {
+	if t == nil {
+		return
+	}
+	t.pending.Done()
+}
+
+// trackExit forwards the passed execution errors to the returned channel, recording the exit code
+// of the instance. The returned channel is closed when the passed one is closed.
+func (t *instanceTelemetry) trackExit(ctx context.Context, errs <-chan error) <-chan error {
+	if t == nil {
+		return errs
+	}
+	fwd := make(chan error)
+	go func() {
+		defer t.done()
+		defer close(fwd)
+		for err := range errs {
+			t.exited(err)
+			select {
+			case fwd <- err:
+			case <-ctx.Done():
+			}
+		}
+	}()
+	return fwd
+}
+
+func (t *instanceTelemetry) exited(err error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		t.exitCode = exitErr.ExitCode()
+	} else {
+		t.exitCode = unknownExitCode
+	}
+}
+
+// metrics returns the telemetry of the instance as a protocol v4 payload, whose metrics belong
+// to the agent host entity.
+func (t *instanceTelemetry) metrics(integrationName string) ([]byte, error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	duration := timeNow().Sub(t.start).Seconds()
+	attributes := map[string]interface{}{
+		"integration.name": integrationName,
+	}
+	if t.version != "" {
+		attributes["integration.version"] = t.version
+	}
+	retries := 0
+	if t.retries != nil {
+		retries = t.retries()
+	}
+
+	values := []struct {
+		name  string
+		value float64
+	}{
+		{metricDuration, duration},
+		{metricExitCode, float64(t.exitCode)},
+		{metricPayloadBytes, float64(t.payloadBytes)},
+		{metricDataSets, float64(t.dataSets)},
+		{metricParseErrors, float64(t.parseErrors)},
+		{metricRetries, float64(retries)},
+	}
+	metrics := make([]protocol.Metric, 0, len(values))
+	for _, v := range values {
+		raw, err := json.Marshal(v.value)
+		if err != nil {
+			return nil, err
+		}
+		metrics = append(metrics, protocol.Metric{
+			Name:  v.name,
+			Type:  protocol.MetricTypeGauge,
+			Value: raw,
+		})
+	}
+
+	return json.Marshal(protocol.DataV4{
+		PluginProtocolVersion: protocol.PluginProtocolVersion{RawProtocolVersion: "4"},
+		Integration:           protocol.IntegrationMetadata{Name: telemetryIntegrationName},
+		DataSets: []protocol.Dataset{{
+			Common:  protocol.Common{Attributes: attributes},
+			Metrics: metrics,
+		}},
+	})
+}
+
+// reportTelemetry emits the telemetry of a finished integration instance.
+func (r *runner) reportTelemetry(t *instanceTelemetry, extraLabels data.Map, entityRewrite []data.EntityRewrite) {
+	payload, err := t.metrics(r.definition.Name)
+	if err != nil {
+		r.log.WithError(err).Warn("cannot build integration telemetry")
+		return
+	}
+	if err := r.emitter.Emit(r.definition, extraLabels, entityRewrite, payload); err != nil {
+		r.log.WithError(err).Warn("cannot emit integration telemetry")
+	}
+}
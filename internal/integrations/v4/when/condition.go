@@ -2,7 +2,17 @@
 // SPDX-License-Identifier: Apache-2.0
 package when
 
-import "os"
+import (
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/shirou/gopsutil/process"
+)
+
+// portOpenTimeout limits the time the PortOpen condition waits for a connection.
+var portOpenTimeout = time.Second
 
 // Condition is any function that can return true or false
 type Condition func() bool
@@ -32,6 +42,40 @@ func EnvExists(envVars map[string]string) Condition {
 	}
 }
 
+// PortOpen creates a Condition returning true when a TCP connection can be established
+// to the passed address. If the address only contains a port, localhost is assumed.
+func PortOpen(address string) Condition {
+	if !strings.Contains(address, ":") {
+		address = net.JoinHostPort("localhost", address)
+	}
+	return func() bool {
+		conn, err := net.DialTimeout("tcp", address, portOpenTimeout)
+		if err != nil {
+			return false
+		}
+		_ = conn.Close()
+		return true
+	}
+}
+
+// ProcessRunning creates a Condition returning true when a process with the passed name
+// is running in the host.
+func ProcessRunning(name string) Condition {
+	return func() bool {
+		procs, err := process.Processes()
+		if err != nil {
+			return false
+		}
+		for _, proc := range procs {
+			// processes may finish while they are listed, so errors are ignored
+			if procName, err := proc.Name(); err == nil && procName == name {
+				return true
+			}
+		}
+		return false
+	}
+}
+
 // All returns true if and only if all the passed conditions are true.
 // If an empty conditions list is passed, it also returns true.
 func All(conditions ...Condition) bool {
@@ -4,7 +4,9 @@ package when
 
 import (
 	"io/ioutil"
+	"net"
 	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -74,6 +76,29 @@ func TestEnvExists(t *testing.T) {
 	}
 }
 
+func TestPortOpen(t *testing.T) {
+	// GIVEN a listening TCP port
+	l, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	defer l.Close()
+	_, port, err := net.SplitHostPort(l.Addr().String())
+	require.NoError(t, err)
+
+	// THEN the PortOpen condition returns true, for both the address and the port
+	assert.True(t, PortOpen(l.Addr().String())())
+	assert.True(t, PortOpen(port)())
+
+	// AND it returns false once the port is closed
+	require.NoError(t, l.Close())
+	assert.False(t, PortOpen(port)())
+}
+
+func TestProcessRunning(t *testing.T) {
+	// the current test binary is a running process
+	assert.True(t, ProcessRunning(filepath.Base(os.Args[0]))())
+	assert.False(t, ProcessRunning("some-unexisting-process")())
+}
+
 func TestAll(t *testing.T) {
 	trueFunc := func() bool { return true }
 	falseFunc := func() bool { return false }
@@ -0,0 +1,194 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+// +build darwin
+
+package darwin
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/newrelic/infrastructure-agent/internal/agent"
+	"github.com/newrelic/infrastructure-agent/pkg/config"
+	"github.com/newrelic/infrastructure-agent/pkg/entity"
+	"github.com/newrelic/infrastructure-agent/pkg/helpers"
+	"github.com/newrelic/infrastructure-agent/pkg/log"
+	"github.com/newrelic/infrastructure-agent/pkg/plugins/ids"
+)
+
+var alog = log.WithPlugin("Applications")
+
+const applicationsDir = "/Applications"
+
+// maxApplicationsDepth bounds the folders searched for bundles, e.g. /Applications/Utilities/Terminal.app
+const maxApplicationsDepth = 2
+
+// Application is an application bundle installed in /Applications.
+type Application struct {
+	// ID is the bundle identifier, or the bundle path for the ones without it
+	ID               string `json:"id"`
+	Name             string `json:"name"`
+	Version          string `json:"version"`
+	BuildVersion     string `json:"build_version,omitempty"`
+	Path             string `json:"path"`
+	SigningAuthority string `json:"signing_authority,omitempty"`
+	TeamID           string `json:"team_id,omitempty"`
+}
+
+func (a Application) SortKey() string {
+	return a.ID
+}
+
+// signature is the signing identity of a bundle, cached by path and version as reading it runs codesign.
+type signature struct {
+	version   string
+	authority string
+	teamID    string
+}
+
+// ApplicationsPlugin reports the application bundles installed in the host.
+type ApplicationsPlugin struct {
+	agent.PluginCommon
+	frequency  time.Duration
+	signatures map[string]signature
+}
+
+func NewApplicationsPlugin(id ids.PluginID, ctx agent.AgentContext) *ApplicationsPlugin {
+	cfg := ctx.Config()
+	return &ApplicationsPlugin{
+		PluginCommon: agent.PluginCommon{ID: id, Context: ctx},
+		frequency: config.ValidateConfigFrequencySetting(
+			cfg.MacApplicationsRefreshSec,
+			config.FREQ_MINIMUM_INVENTORY_SAMPLE_RATE,
+			config.FREQ_PLUGIN_MAC_APPLICATIONS_UPDATES,
+			cfg.DisableAllPlugins,
+		) * time.Second,
+		signatures: map[string]signature{},
+	}
+}
+
+func (p *ApplicationsPlugin) Run() {
+	if p.frequency <= config.FREQ_DISABLE_SAMPLING {
+		alog.Debug("Disabled.")
+		return
+	}
+
+	ticker := time.NewTicker(1)
+	for range ticker.C {
+		ticker.Stop()
+		ticker = time.NewTicker(p.frequency)
+		p.EmitInventory(p.applications(applicationBundles(applicationsDir)), entity.NewFromNameWithoutID(p.Context.EntityKey()))
+	}
+}
+
+// applicationBundles returns the paths of the .app bundles under the passed directory.
+func applicationBundles(root string) []string {
+	var bundles []string
+	baseDepth := strings.Count(filepath.Clean(root), string(os.PathSeparator))
+	_ = filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil || !info.IsDir() {
+			return nil
+		}
+		if strings.HasSuffix(path, ".app") {
+			bundles = append(bundles, path)
+			// bundles can embed helper applications
+			return filepath.SkipDir
+		}
+		if strings.Count(path, string(os.PathSeparator))-baseDepth >= maxApplicationsDepth {
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	return bundles
+}
+
+func (p *ApplicationsPlugin) applications(bundles []string) agent.PluginInventoryDataset {
+	var dataset agent.PluginInventoryDataset
+	seen := make(map[string]bool, len(bundles))
+	for _, bundle := range bundles {
+		// Info.plist files can be binary, so they are converted by plutil
+		output, err := helpers.RunCommand("/usr/bin/plutil", "", "-convert", "json", "-o", "-", filepath.Join(bundle, "Contents", "Info.plist"))
+		if err != nil {
+			alog.WithError(err).WithField("bundle", bundle).Debug("Can't read the bundle information.")
+			continue
+		}
+		app, err := parseInfoPlist(bundle, []byte(output))
+		if err != nil {
+			alog.WithError(err).WithField("bundle", bundle).Debug("Can't parse the bundle information.")
+			continue
+		}
+		// the same application can be installed in several folders
+		if seen[app.ID] {
+			app.ID = bundle
+		}
+		seen[app.ID] = true
+
+		sig, ok := p.signatures[bundle]
+		if !ok || sig.version != app.BuildVersion+app.Version {
+			sig = signature{version: app.BuildVersion + app.Version}
+			// codesign exits with error for unsigned bundles
+			output, _ := helpers.RunCommand("/usr/bin/codesign", "", "--display", "--verbose=2", bundle)
+			sig.authority, sig.teamID = parseCodesign(output)
+			p.signatures[bundle] = sig
+		}
+		app.SigningAuthority, app.TeamID = sig.authority, sig.teamID
+		dataset = append(dataset, app)
+	}
+	sort.Sort(dataset)
+	return dataset
+}
+
+// parseInfoPlist reads the JSON conversion of the Info.plist of a bundle.
+func parseInfoPlist(bundle string, content []byte) (Application, error) {
+	var info struct {
+		Identifier   string `json:"CFBundleIdentifier"`
+		Name         string `json:"CFBundleName"`
+		ShortVersion string `json:"CFBundleShortVersionString"`
+		Version      string `json:"CFBundleVersion"`
+	}
+	if err := json.Unmarshal(content, &info); err != nil {
+		return Application{}, err
+	}
+	app := Application{
+		ID:           info.Identifier,
+		Name:         info.Name,
+		Version:      info.ShortVersion,
+		BuildVersion: info.Version,
+		Path:         bundle,
+	}
+	if app.ID == "" {
+		app.ID = bundle
+	}
+	if app.Name == "" {
+		app.Name = strings.TrimSuffix(filepath.Base(bundle), ".app")
+	}
+	if app.Version == "" {
+		app.Version, app.BuildVersion = app.BuildVersion, ""
+	}
+	return app, nil
+}
+
+// parseCodesign returns the leaf signing authority and the team of the codesign details, e.g.
+// Authority=Developer ID Application: Google LLC (EQHXZ8M8AV)
+// Authority=Developer ID Certification Authority
+// TeamIdentifier=EQHXZ8M8AV
+func parseCodesign(output string) (authority, teamID string) {
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "Authority=") && authority == "" {
+			authority = strings.TrimPrefix(line, "Authority=")
+		} else if strings.HasPrefix(line, "TeamIdentifier=") {
+			teamID = strings.TrimPrefix(line, "TeamIdentifier=")
+			if teamID == "not set" {
+				teamID = ""
+			}
+		}
+	}
+	return authority, teamID
+}
@@ -0,0 +1,101 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+// +build darwin
+
+package darwin
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/newrelic/infrastructure-agent/internal/agent"
+)
+
+func TestApplicationBundles(t *testing.T) {
+	// GIVEN applications in the root and in a folder, one of them with an embedded helper
+	root, err := ioutil.TempDir("", "Applications")
+	require.NoError(t, err)
+	defer os.RemoveAll(root)
+	for _, dir := range []string{
+		"Safari.app/Contents",
+		"Utilities/Terminal.app/Contents",
+		"Chrome.app/Contents/Frameworks/Helper.app/Contents",
+		"Vendor/Tools/Deep.app/Contents",
+	} {
+		require.NoError(t, os.MkdirAll(filepath.Join(root, dir), 0755))
+	}
+
+	// WHEN the bundles are searched
+	bundles := applicationBundles(root)
+
+	// THEN the bundles up to two levels are returned, without the embedded ones
+	assert.Equal(t, []string{
+		filepath.Join(root, "Chrome.app"),
+		filepath.Join(root, "Safari.app"),
+		filepath.Join(root, "Utilities", "Terminal.app"),
+	}, bundles)
+}
+
+func TestParseInfoPlist(t *testing.T) {
+	app, err := parseInfoPlist("/Applications/Slack.app", []byte(`{
+		"CFBundleIdentifier": "com.tinyspeck.slackmacgap",
+		"CFBundleName": "Slack",
+		"CFBundleShortVersionString": "4.9.1",
+		"CFBundleVersion": "6386"
+	}`))
+
+	require.NoError(t, err)
+	assert.Equal(t, Application{
+		ID:           "com.tinyspeck.slackmacgap",
+		Name:         "Slack",
+		Version:      "4.9.1",
+		BuildVersion: "6386",
+		Path:         "/Applications/Slack.app",
+	}, app)
+
+	// bundles without identifier nor short version
+	app, err = parseInfoPlist("/Applications/Tool.app", []byte(`{"CFBundleVersion": "1.0"}`))
+	require.NoError(t, err)
+	assert.Equal(t, Application{ID: "/Applications/Tool.app", Name: "Tool", Version: "1.0", Path: "/Applications/Tool.app"}, app)
+}
+
+func TestParseCodesign(t *testing.T) {
+	authority, team := parseCodesign(`Executable=/Applications/Google Chrome.app/Contents/MacOS/Google Chrome
+Identifier=com.google.Chrome
+Authority=Developer ID Application: Google LLC (EQHXZ8M8AV)
+Authority=Developer ID Certification Authority
+Authority=Apple Root CA
+TeamIdentifier=EQHXZ8M8AV`)
+
+	assert.Equal(t, "Developer ID Application: Google LLC (EQHXZ8M8AV)", authority)
+	assert.Equal(t, "EQHXZ8M8AV", team)
+
+	authority, team = parseCodesign("/Applications/Tool.app: code object is not signed at all")
+	assert.Empty(t, authority)
+	assert.Empty(t, team)
+}
+
+func TestHomebrewPackages(t *testing.T) {
+	// GIVEN a prefix with two versions of a formula, the older one linked, and a cask
+	prefix, err := ioutil.TempDir("", "homebrew")
+	require.NoError(t, err)
+	defer os.RemoveAll(prefix)
+	for _, dir := range []string{"Cellar/go/1.15.1", "Cellar/go/1.15.2", "Caskroom/docker/2.4.0.0", "Caskroom/docker/.metadata", "opt"} {
+		require.NoError(t, os.MkdirAll(filepath.Join(prefix, dir), 0755))
+	}
+	require.NoError(t, os.Symlink("../Cellar/go/1.15.1", filepath.Join(prefix, "opt", "go")))
+
+	// WHEN the packages are read
+	dataset := homebrewPackages([]string{prefix, filepath.Join(prefix, "missing")})
+
+	// THEN the linked formula version and the cask are reported
+	assert.Equal(t, agent.PluginInventoryDataset{
+		HomebrewPackage{ID: "cask:docker", Name: "docker", Type: "cask", Version: "2.4.0.0", Prefix: prefix},
+		HomebrewPackage{ID: "formula:go", Name: "go", Type: "formula", Version: "1.15.1", Prefix: prefix},
+	}, dataset)
+}
@@ -0,0 +1,138 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+// +build darwin
+
+package darwin
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/newrelic/infrastructure-agent/internal/agent"
+	"github.com/newrelic/infrastructure-agent/pkg/config"
+	"github.com/newrelic/infrastructure-agent/pkg/entity"
+	"github.com/newrelic/infrastructure-agent/pkg/log"
+	"github.com/newrelic/infrastructure-agent/pkg/plugins/ids"
+)
+
+var hblog = log.WithPlugin("Homebrew")
+
+// homebrewPrefixes are the default installation prefixes in Intel and Apple silicon Macs.
+var homebrewPrefixes = []string{"/usr/local", "/opt/homebrew"}
+
+// HomebrewPackage is a formula or a cask installed by Homebrew.
+type HomebrewPackage struct {
+	// ID is the type followed by the name, as formulae and casks can have the same name
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	Type    string `json:"type"`
+	Version string `json:"version"`
+	Prefix  string `json:"prefix"`
+}
+
+func (p HomebrewPackage) SortKey() string {
+	return p.ID
+}
+
+// HomebrewPlugin reports the Homebrew packages, reading the Cellar and Caskroom folders, as brew refuses
+// to run as root.
+type HomebrewPlugin struct {
+	agent.PluginCommon
+	frequency time.Duration
+	prefixes  []string
+}
+
+func NewHomebrewPlugin(id ids.PluginID, ctx agent.AgentContext) *HomebrewPlugin {
+	cfg := ctx.Config()
+	return &HomebrewPlugin{
+		PluginCommon: agent.PluginCommon{ID: id, Context: ctx},
+		frequency: config.ValidateConfigFrequencySetting(
+			cfg.MacApplicationsRefreshSec,
+			config.FREQ_MINIMUM_INVENTORY_SAMPLE_RATE,
+			config.FREQ_PLUGIN_MAC_APPLICATIONS_UPDATES,
+			cfg.DisableAllPlugins,
+		) * time.Second,
+		prefixes: homebrewPrefixes,
+	}
+}
+
+func (p *HomebrewPlugin) Run() {
+	if p.frequency <= config.FREQ_DISABLE_SAMPLING {
+		hblog.Debug("Disabled.")
+		return
+	}
+
+	ticker := time.NewTicker(1)
+	for range ticker.C {
+		ticker.Stop()
+		ticker = time.NewTicker(p.frequency)
+		p.EmitInventory(homebrewPackages(p.prefixes), entity.NewFromNameWithoutID(p.Context.EntityKey()))
+	}
+}
+
+func homebrewPackages(prefixes []string) agent.PluginInventoryDataset {
+	var dataset agent.PluginInventoryDataset
+	for _, prefix := range prefixes {
+		for _, name := range subdirectories(filepath.Join(prefix, "Cellar")) {
+			dataset = append(dataset, HomebrewPackage{
+				ID:      "formula:" + name,
+				Name:    name,
+				Type:    "formula",
+				Version: formulaVersion(prefix, name),
+				Prefix:  prefix,
+			})
+		}
+		for _, name := range subdirectories(filepath.Join(prefix, "Caskroom")) {
+			dataset = append(dataset, HomebrewPackage{
+				ID:      "cask:" + name,
+				Name:    name,
+				Type:    "cask",
+				Version: latestVersion(filepath.Join(prefix, "Caskroom", name)),
+				Prefix:  prefix,
+			})
+		}
+	}
+	sort.Sort(dataset)
+	return dataset
+}
+
+// formulaVersion returns the version linked in the opt folder, which is the one in use when several are
+// installed.
+func formulaVersion(prefix, name string) string {
+	if target, err := os.Readlink(filepath.Join(prefix, "opt", name)); err == nil {
+		return filepath.Base(target)
+	}
+	return latestVersion(filepath.Join(prefix, "Cellar", name))
+}
+
+// latestVersion returns the last of the version folders of a package.
+func latestVersion(dir string) string {
+	versions := subdirectories(dir)
+	if len(versions) == 0 {
+		return ""
+	}
+	return versions[len(versions)-1]
+}
+
+// subdirectories returns the sorted names of the folders in a directory, skipping the hidden ones such as the
+// .metadata of the casks.
+func subdirectories(dir string) []string {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			hblog.WithError(err).WithField("dir", dir).Debug("Can't read Homebrew folder.")
+		}
+		return nil
+	}
+	var names []string
+	for _, entry := range entries {
+		if entry.IsDir() && !strings.HasPrefix(entry.Name(), ".") {
+			names = append(names, entry.Name())
+		}
+	}
+	return names
+}
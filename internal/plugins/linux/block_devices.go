@@ -0,0 +1,209 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+// +build linux
+
+package linux
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/sys/unix"
+
+	"github.com/newrelic/infrastructure-agent/internal/agent"
+	"github.com/newrelic/infrastructure-agent/pkg/config"
+	"github.com/newrelic/infrastructure-agent/pkg/entity"
+	"github.com/newrelic/infrastructure-agent/pkg/helpers"
+	"github.com/newrelic/infrastructure-agent/pkg/log"
+	"github.com/newrelic/infrastructure-agent/pkg/plugins/ids"
+)
+
+var bdlog = log.WithPlugin("BlockDevices")
+
+// BlockDevice is a disk, as listed in /sys/block.
+type BlockDevice struct {
+	Name      string `json:"id"`
+	SizeBytes int64  `json:"sizeBytes"`
+	Removable bool   `json:"removable"`
+	ReadOnly  bool   `json:"readOnly"`
+	Vendor    string `json:"vendor,omitempty"`
+	Model     string `json:"model,omitempty"`
+}
+
+func (d BlockDevice) SortKey() string {
+	return d.Name
+}
+
+// uevent is a kernel device event, as a set of KEY=value properties.
+type uevent map[string]string
+
+// BlockDevicesPlugin reports the disks of the host, submitting an event each time a disk or a USB device is
+// added or removed. Device hotplug is notified by the kernel, so the inventory is updated immediately.
+type BlockDevicesPlugin struct {
+	agent.PluginCommon
+	frequency time.Duration
+	sysBlock  string
+}
+
+func NewBlockDevicesPlugin(id ids.PluginID, ctx agent.AgentContext) *BlockDevicesPlugin {
+	cfg := ctx.Config()
+	return &BlockDevicesPlugin{
+		PluginCommon: agent.PluginCommon{ID: id, Context: ctx},
+		frequency: config.ValidateConfigFrequencySetting(
+			cfg.StorageDevicesRefreshSec,
+			config.FREQ_MINIMUM_FAST_INVENTORY_SAMPLE_RATE,
+			config.FREQ_PLUGIN_STORAGE_DEVICES_UPDATES,
+			cfg.DisableAllPlugins,
+		) * time.Second,
+		sysBlock: helpers.HostSys("block"),
+	}
+}
+
+func (p *BlockDevicesPlugin) Run() {
+	if p.frequency <= config.FREQ_DISABLE_SAMPLING {
+		bdlog.Debug("Disabled.")
+		return
+	}
+
+	events := make(chan uevent, 100)
+	if err := watchUevents(events); err != nil {
+		bdlog.WithError(err).Warn("can't subscribe to kernel device events. Block devices will be only refreshed periodically")
+	}
+
+	entityKey := p.Context.EntityKey()
+	refreshTimer := time.NewTimer(1)
+	for {
+		select {
+		case ev := <-events:
+			event, ok := deviceEvent(ev)
+			if !ok {
+				continue
+			}
+			p.EmitEvent(event, entity.Key(entityKey))
+			if ev["SUBSYSTEM"] != "block" {
+				continue
+			}
+		case <-refreshTimer.C:
+			refreshTimer.Reset(p.frequency)
+		}
+		p.EmitInventory(readBlockDevices(p.sysBlock), entity.NewFromNameWithoutID(entityKey))
+	}
+}
+
+// readBlockDevices lists the block devices with a size, which excludes unused loop and ram devices.
+func readBlockDevices(sysBlock string) (dataset agent.PluginInventoryDataset) {
+	entries, err := ioutil.ReadDir(sysBlock)
+	if err != nil {
+		bdlog.WithError(err).Error("can't list block devices")
+		return nil
+	}
+	for _, entry := range entries {
+		dir := filepath.Join(sysBlock, entry.Name())
+		attr := func(name string) string {
+			value, _ := ioutil.ReadFile(filepath.Join(dir, name))
+			return strings.TrimSpace(string(value))
+		}
+		// the size is expressed in 512 bytes sectors, regardless of the device sector size
+		sectors, _ := strconv.ParseInt(attr("size"), 10, 64)
+		if sectors == 0 {
+			continue
+		}
+		dataset = append(dataset, BlockDevice{
+			Name:      entry.Name(),
+			SizeBytes: sectors * 512,
+			Removable: attr("removable") == "1",
+			ReadOnly:  attr("ro") == "1",
+			Vendor:    attr("device/vendor"),
+			Model:     attr("device/model"),
+		})
+	}
+	return dataset
+}
+
+// watchUevents forwards the kernel device events, received from a netlink socket.
+func watchUevents(events chan<- uevent) error {
+	fd, err := unix.Socket(unix.AF_NETLINK, unix.SOCK_DGRAM|unix.SOCK_CLOEXEC, unix.NETLINK_KOBJECT_UEVENT)
+	if err != nil {
+		return err
+	}
+	// multicast group 1 receives the events as sent by the kernel
+	if err = unix.Bind(fd, &unix.SockaddrNetlink{Family: unix.AF_NETLINK, Groups: 1}); err != nil {
+		unix.Close(fd)
+		return err
+	}
+	go func() {
+		defer unix.Close(fd)
+		buf := make([]byte, 64*1024)
+		for {
+			n, _, err := unix.Recvfrom(fd, buf, 0)
+			if err != nil {
+				if err == unix.EINTR || err == unix.ENOBUFS {
+					continue
+				}
+				bdlog.WithError(err).Warn("stopped receiving kernel device events")
+				return
+			}
+			if ev, ok := parseUevent(buf[:n]); ok {
+				events <- ev
+			}
+		}
+	}()
+	return nil
+}
+
+// parseUevent parses a kernel uevent message: an "action@devpath" header followed by
+// NUL separated KEY=value properties.
+func parseUevent(msg []byte) (uevent, bool) {
+	parts := bytes.Split(msg, []byte{0})
+	if len(parts) < 2 || !bytes.Contains(parts[0], []byte("@")) {
+		return nil, false
+	}
+	ev := uevent{}
+	for _, part := range parts[1:] {
+		if kv := strings.SplitN(string(part), "=", 2); len(kv) == 2 {
+			ev[kv[0]] = kv[1]
+		}
+	}
+	return ev, ev["ACTION"] != "" && ev["SUBSYSTEM"] != ""
+}
+
+// deviceEvent returns the event to submit for the addition or removal of a disk or a USB device.
+// Other events, e.g. partitions, USB interfaces or attribute changes, are ignored.
+func deviceEvent(ev uevent) (map[string]interface{}, bool) {
+	var action string
+	switch ev["ACTION"] {
+	case "add":
+		action = "added"
+	case "remove":
+		action = "removed"
+	default:
+		return nil, false
+	}
+
+	event := map[string]interface{}{
+		"eventType":  "InfrastructureEvent",
+		"action":     action,
+		"subsystem":  ev["SUBSYSTEM"],
+		"devicePath": ev["DEVPATH"],
+	}
+	switch {
+	case ev["SUBSYSTEM"] == "block" && ev["DEVTYPE"] == "disk":
+		event["category"] = "storage"
+		event["deviceName"] = ev["DEVNAME"]
+		event["summary"] = fmt.Sprintf("Disk %s %s", ev["DEVNAME"], action)
+	case ev["SUBSYSTEM"] == "usb" && ev["DEVTYPE"] == "usb_device":
+		event["category"] = "devices"
+		event["deviceName"] = ev["DEVNAME"]
+		// vendor ID, product ID and revision of the device, e.g. 781/5581/100
+		event["usbProduct"] = ev["PRODUCT"]
+		event["summary"] = fmt.Sprintf("USB device %s %s", ev["PRODUCT"], action)
+	default:
+		return nil, false
+	}
+	return event, true
+}
@@ -0,0 +1,138 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+// +build linux
+
+package linux
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/newrelic/infrastructure-agent/internal/agent"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func ueventMessage(header string, properties ...string) []byte {
+	return []byte(strings.Join(append([]string{header}, properties...), "\x00") + "\x00")
+}
+
+func TestParseUevent(t *testing.T) {
+	msg := ueventMessage("add@/devices/pci0000:00/0000:00:14.0/usb1/1-2/1-2:1.0/host6/target6:0:0/6:0:0:0/block/sdb",
+		"ACTION=add",
+		"DEVPATH=/devices/pci0000:00/0000:00:14.0/usb1/1-2/1-2:1.0/host6/target6:0:0/6:0:0:0/block/sdb",
+		"SUBSYSTEM=block",
+		"MAJOR=8",
+		"MINOR=16",
+		"DEVNAME=sdb",
+		"DEVTYPE=disk",
+		"SEQNUM=4321")
+
+	ev, ok := parseUevent(msg)
+
+	require.True(t, ok)
+	assert.Equal(t, "add", ev["ACTION"])
+	assert.Equal(t, "block", ev["SUBSYSTEM"])
+	assert.Equal(t, "sdb", ev["DEVNAME"])
+	assert.Equal(t, "disk", ev["DEVTYPE"])
+}
+
+func TestParseUevent_Invalid(t *testing.T) {
+	// udev rebroadcasts its processed events with a "libudev" header in a binary format
+	_, ok := parseUevent([]byte("libudev\x00\xfe\xed\xca\xfe"))
+	assert.False(t, ok)
+
+	_, ok = parseUevent(ueventMessage("change@/devices/virtual/block/loop0"))
+	assert.False(t, ok)
+}
+
+func TestDeviceEvent(t *testing.T) {
+	tests := []struct {
+		name  string
+		ev    uevent
+		event map[string]interface{}
+	}{
+		{
+			name: "disk added",
+			ev:   uevent{"ACTION": "add", "SUBSYSTEM": "block", "DEVTYPE": "disk", "DEVNAME": "sdb", "DEVPATH": "/devices/block/sdb"},
+			event: map[string]interface{}{
+				"eventType":  "InfrastructureEvent",
+				"category":   "storage",
+				"summary":    "Disk sdb added",
+				"action":     "added",
+				"subsystem":  "block",
+				"deviceName": "sdb",
+				"devicePath": "/devices/block/sdb",
+			},
+		},
+		{
+			name: "USB device removed",
+			ev: uevent{"ACTION": "remove", "SUBSYSTEM": "usb", "DEVTYPE": "usb_device", "DEVNAME": "bus/usb/001/005",
+				"DEVPATH": "/devices/usb1/1-2", "PRODUCT": "781/5581/100"},
+			event: map[string]interface{}{
+				"eventType":  "InfrastructureEvent",
+				"category":   "devices",
+				"summary":    "USB device 781/5581/100 removed",
+				"action":     "removed",
+				"subsystem":  "usb",
+				"deviceName": "bus/usb/001/005",
+				"devicePath": "/devices/usb1/1-2",
+				"usbProduct": "781/5581/100",
+			},
+		},
+		{
+			name: "partition added",
+			ev:   uevent{"ACTION": "add", "SUBSYSTEM": "block", "DEVTYPE": "partition", "DEVNAME": "sdb1"},
+		},
+		{
+			name: "USB interface added",
+			ev:   uevent{"ACTION": "add", "SUBSYSTEM": "usb", "DEVTYPE": "usb_interface"},
+		},
+		{
+			name: "disk changed",
+			ev:   uevent{"ACTION": "change", "SUBSYSTEM": "block", "DEVTYPE": "disk", "DEVNAME": "sdb"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			event, ok := deviceEvent(tt.ev)
+			assert.Equal(t, tt.event != nil, ok)
+			assert.Equal(t, tt.event, event)
+		})
+	}
+}
+
+func TestReadBlockDevices(t *testing.T) {
+	// GIVEN a sys/block directory with a disk, a removable read-only disk and an unused loop device
+	sysBlock, err := ioutil.TempDir("", "block")
+	require.NoError(t, err)
+	defer os.RemoveAll(sysBlock)
+
+	for path, content := range map[string]string{
+		"sda/size":          "1953525168\n",
+		"sda/removable":     "0\n",
+		"sda/ro":            "0\n",
+		"sda/device/vendor": "ATA     \n",
+		"sda/device/model":  "Samsung SSD 860 \n",
+		"sdb/size":          "60063744\n",
+		"sdb/removable":     "1\n",
+		"sdb/ro":            "1\n",
+		"loop0/size":        "0\n",
+	} {
+		path = filepath.Join(sysBlock, path)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, ioutil.WriteFile(path, []byte(content), 0644))
+	}
+
+	// WHEN the block devices are read
+	dataset := readBlockDevices(sysBlock)
+
+	// THEN the devices with a size are reported
+	assert.Equal(t, agent.PluginInventoryDataset{
+		BlockDevice{Name: "sda", SizeBytes: 1953525168 * 512, Vendor: "ATA", Model: "Samsung SSD 860"},
+		BlockDevice{Name: "sdb", SizeBytes: 60063744 * 512, Removable: true, ReadOnly: true},
+	}, dataset)
+}
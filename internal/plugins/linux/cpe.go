@@ -0,0 +1,318 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+// +build linux
+
+package linux
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/newrelic/infrastructure-agent/internal/agent"
+	"github.com/newrelic/infrastructure-agent/pkg/config"
+	"github.com/newrelic/infrastructure-agent/pkg/entity"
+	"github.com/newrelic/infrastructure-agent/pkg/helpers"
+	"github.com/newrelic/infrastructure-agent/pkg/log"
+	"github.com/newrelic/infrastructure-agent/pkg/plugins/ids"
+)
+
+var cpelog = log.WithPlugin("CPE")
+
+// osCPEs maps the os-release IDs to the vendor and product of their CPE names, for the distros
+// that don't provide the CPE_NAME field.
+var osCPEs = map[string][2]string{
+	"ubuntu":        {"canonical", "ubuntu_linux"},
+	"debian":        {"debian", "debian_linux"},
+	"centos":        {"centos", "centos"},
+	"rhel":          {"redhat", "enterprise_linux"},
+	"fedora":        {"fedoraproject", "fedora"},
+	"amzn":          {"amazon", "linux"},
+	"sles":          {"suse", "linux_enterprise_server"},
+	"opensuse-leap": {"opensuse", "leap"},
+	"rocky":         {"rocky", "rocky_linux"},
+	"almalinux":     {"almalinux", "almalinux"},
+	"ol":            {"oracle", "linux"},
+}
+
+// Fingerprint identifies the operating system or an installed package, so it can be matched
+// against vulnerability databases.
+type Fingerprint struct {
+	// ID is the package name, or "os:<os-release ID>" for the operating system
+	ID string `json:"id"`
+	// CPE is the CPE 2.3 formatted string
+	CPE string `json:"cpe"`
+	// PURL is the package URL, which qualifies the package with its distro for distro-specific advisories
+	PURL    string `json:"purl,omitempty"`
+	Version string `json:"version"`
+}
+
+func (f Fingerprint) SortKey() string {
+	return f.ID
+}
+
+// installedPackage as reported by the package manager.
+type installedPackage struct {
+	name    string
+	epoch   string
+	version string // upstream version, as provided by the package maintainers
+	release string // distro revision of the package
+	arch    string
+}
+
+// CPEPlugin reports the CPE names and package URLs of the operating system and the installed packages,
+// generated from the package manager data and the os-release facts.
+type CPEPlugin struct {
+	agent.PluginCommon
+	frequency   time.Duration
+	packageType string // purl type: deb or rpm
+	packages    func() ([]installedPackage, error)
+	osInfo      func() (map[string]string, error)
+}
+
+func NewCPEPlugin(id ids.PluginID, ctx agent.AgentContext) *CPEPlugin {
+	cfg := ctx.Config()
+	p := &CPEPlugin{
+		PluginCommon: agent.PluginCommon{ID: id, Context: ctx},
+		osInfo:       helpers.GetLinuxOSInfo,
+	}
+	// the fingerprints are refreshed as often as the package inventory they are generated from
+	interval := int64(config.FREQ_DISABLE_SAMPLING)
+	switch helpers.GetLinuxDistro() {
+	case helpers.LINUX_DEBIAN:
+		interval = cfg.DpkgRefreshSec
+		p.packageType = "deb"
+		p.packages = dpkgPackages
+	case helpers.LINUX_REDHAT, helpers.LINUX_AWS_REDHAT, helpers.LINUX_SUSE:
+		interval = cfg.RpmRefreshSec
+		p.packageType = "rpm"
+		p.packages = rpmPackages
+	}
+	p.frequency = config.ValidateConfigFrequencySetting(
+		interval,
+		config.FREQ_MINIMUM_INVENTORY_SAMPLE_RATE,
+		config.FREQ_PLUGIN_PACKAGE_MGRS_UPDATES,
+		cfg.DisableAllPlugins || !cfg.EnableCPEInventory,
+	) * time.Second
+	return p
+}
+
+func (p *CPEPlugin) Run() {
+	if p.frequency <= config.FREQ_DISABLE_SAMPLING {
+		cpelog.Debug("Disabled.")
+		return
+	}
+	if p.packages == nil {
+		cpelog.Warn("unsupported package manager, CPE fingerprints won't be reported")
+		p.Unregister()
+		return
+	}
+
+	ticker := time.NewTicker(1)
+	for range ticker.C {
+		ticker.Stop()
+		ticker = time.NewTicker(p.frequency)
+		dataset, err := p.fingerprints()
+		if err != nil {
+			cpelog.WithError(err).Error("can't generate CPE fingerprints")
+			continue
+		}
+		p.EmitInventory(dataset, entity.NewFromNameWithoutID(p.Context.EntityKey()))
+	}
+}
+
+func (p *CPEPlugin) fingerprints() (agent.PluginInventoryDataset, error) {
+	osInfo, err := p.osInfo()
+	if err != nil {
+		cpelog.WithError(err).Debug("Can't read os-release. The packages won't be qualified with their distro.")
+		osInfo = map[string]string{}
+	}
+	packages, err := p.packages()
+	if err != nil {
+		return nil, err
+	}
+
+	var dataset agent.PluginInventoryDataset
+	if os, ok := osFingerprint(osInfo); ok {
+		dataset = append(dataset, os)
+	}
+	for _, pkg := range packages {
+		dataset = append(dataset, packageFingerprint(p.packageType, pkg, osInfo))
+	}
+	sort.Slice(dataset, func(i, j int) bool {
+		return dataset[i].SortKey() < dataset[j].SortKey()
+	})
+	return dataset, nil
+}
+
+// osFingerprint generates the fingerprint of the operating system, preferring the CPE name provided by
+// the distro in the os-release file.
+func osFingerprint(osInfo map[string]string) (Fingerprint, bool) {
+	id := osInfo["ID"]
+	if id == "" {
+		return Fingerprint{}, false
+	}
+	version := osInfo["VERSION_ID"]
+	f := Fingerprint{ID: "os:" + id, Version: version}
+	if name := osInfo["CPE_NAME"]; name != "" {
+		f.CPE = cpeFromURI(name)
+	}
+	if f.CPE == "" {
+		vendorProduct, ok := osCPEs[id]
+		if !ok {
+			vendorProduct = [2]string{id, id}
+		}
+		f.CPE = cpe("o", vendorProduct[0], vendorProduct[1], version)
+	}
+	return f, true
+}
+
+// packageFingerprint generates the fingerprint of a package. As the packages don't provide the vendor of
+// the software, the CPE uses the package name for both the vendor and the product, as the vulnerability
+// scanners do when guessing CPEs. The version is the upstream one, without the epoch and the distro revision.
+func packageFingerprint(packageType string, pkg installedPackage, osInfo map[string]string) Fingerprint {
+	version := pkg.version
+	if pkg.release != "" {
+		version += "-" + pkg.release
+	}
+	f := Fingerprint{
+		ID:      pkg.name,
+		CPE:     cpe("a", pkg.name, pkg.name, pkg.version),
+		Version: version,
+	}
+
+	purl := "pkg:" + packageType + "/"
+	if id := osInfo["ID"]; id != "" {
+		purl += purlEscape(id) + "/"
+	}
+	purl += purlEscape(pkg.name) + "@" + purlEscape(version)
+	var qualifiers []string // sorted by key, as required by the spec
+	if pkg.arch != "" {
+		qualifiers = append(qualifiers, "arch="+purlEscape(pkg.arch))
+	}
+	if id, versionID := osInfo["ID"], osInfo["VERSION_ID"]; id != "" && versionID != "" {
+		qualifiers = append(qualifiers, "distro="+purlEscape(id+"-"+versionID))
+	}
+	if pkg.epoch != "" && pkg.epoch != "0" {
+		qualifiers = append(qualifiers, "epoch="+purlEscape(pkg.epoch))
+	}
+	if len(qualifiers) > 0 {
+		purl += "?" + strings.Join(qualifiers, "&")
+	}
+	f.PURL = purl
+	return f
+}
+
+// cpe returns a CPE 2.3 formatted string, with any edition, language and target.
+func cpe(part, vendor, product, version string) string {
+	if version == "" {
+		version = "*"
+	} else {
+		version = cpeEscape(version)
+	}
+	return fmt.Sprintf("cpe:2.3:%s:%s:%s:%s:*:*:*:*:*:*:*",
+		part, cpeEscape(strings.ToLower(vendor)), cpeEscape(strings.ToLower(product)), version)
+}
+
+// cpeFromURI converts a CPE 2.2 URI, as the provided by the os-release files, to a CPE 2.3 formatted
+// string. E.g. "cpe:/o:redhat:enterprise_linux:8.2:GA" is "cpe:2.3:o:redhat:enterprise_linux:8.2:ga:*:*:*:*:*:*".
+func cpeFromURI(uri string) string {
+	if strings.HasPrefix(uri, "cpe:2.3:") {
+		return uri
+	}
+	if !strings.HasPrefix(uri, "cpe:/") {
+		return ""
+	}
+	components := strings.Split(strings.TrimPrefix(uri, "cpe:/"), ":")
+	fields := make([]string, 11)
+	for i := range fields {
+		fields[i] = "*"
+		if i < len(components) && components[i] != "" {
+			fields[i] = cpeEscape(strings.ToLower(components[i]))
+		}
+	}
+	return "cpe:2.3:" + strings.Join(fields, ":")
+}
+
+// cpeEscape quotes the characters that aren't allowed unquoted in the CPE 2.3 formatted strings.
+func cpeEscape(value string) string {
+	var sb strings.Builder
+	for _, r := range strings.Replace(value, " ", "_", -1) {
+		if !isAlphanumeric(r) && r != '_' && r != '-' && r != '.' {
+			sb.WriteRune('\\')
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
+
+// purlEscape percent-encodes the characters that aren't unreserved in the package URLs.
+func purlEscape(value string) string {
+	var sb strings.Builder
+	for _, b := range []byte(value) {
+		if isAlphanumeric(rune(b)) || b == '.' || b == '-' || b == '_' || b == '~' {
+			sb.WriteByte(b)
+		} else {
+			fmt.Fprintf(&sb, "%%%02X", b)
+		}
+	}
+	return sb.String()
+}
+
+func isAlphanumeric(r rune) bool {
+	return (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9')
+}
+
+// dpkgPackages reads the installed packages from the dpkg database.
+func dpkgPackages() ([]installedPackage, error) {
+	dataset, err := (&DpkgPlugin{}).fetchPackageInfo()
+	if err != nil {
+		return nil, err
+	}
+	packages := make([]installedPackage, 0, len(dataset))
+	for _, item := range dataset {
+		d, ok := item.(DpkgItem)
+		// only installed packages: dpkg also lists the removed packages whose config files are kept
+		if !ok || d.Status != "installed" {
+			continue
+		}
+		packages = append(packages, parseDebianVersion(d.Name, d.Version, d.Architecture))
+	}
+	return packages, nil
+}
+
+// parseDebianVersion splits a Debian version, formatted as [epoch:]upstream_version[-debian_revision].
+func parseDebianVersion(name, version, arch string) installedPackage {
+	pkg := installedPackage{name: name, arch: arch}
+	if i := strings.Index(version, ":"); i >= 0 {
+		pkg.epoch, version = version[:i], version[i+1:]
+	}
+	if i := strings.LastIndex(version, "-"); i >= 0 {
+		pkg.version, pkg.release = version[:i], version[i+1:]
+	} else {
+		pkg.version = version
+	}
+	return pkg
+}
+
+// rpmPackages reads the installed packages from the rpm database.
+func rpmPackages() ([]installedPackage, error) {
+	dataset, err := (&rpmPlugin{erroredLines: map[string]struct{}{}}).fetchPackageInfo()
+	if err != nil {
+		return nil, err
+	}
+	packages := make([]installedPackage, 0, len(dataset))
+	for _, item := range dataset {
+		r, ok := item.(RpmItem)
+		if !ok {
+			continue
+		}
+		pkg := installedPackage{name: r.Name, version: r.Version, release: r.Release, arch: r.Architecture}
+		if r.EpochTag != "none" {
+			pkg.epoch = r.EpochTag
+		}
+		packages = append(packages, pkg)
+	}
+	return packages, nil
+}
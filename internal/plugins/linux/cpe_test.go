@@ -0,0 +1,105 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+// +build linux
+
+package linux
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOSFingerprint(t *testing.T) {
+	tests := []struct {
+		name     string
+		osInfo   map[string]string
+		expected string
+	}{
+		{"os-release CPE name", map[string]string{"ID": "rhel", "VERSION_ID": "8.2", "CPE_NAME": "cpe:/o:redhat:enterprise_linux:8.2:GA"},
+			"cpe:2.3:o:redhat:enterprise_linux:8.2:ga:*:*:*:*:*:*"},
+		{"known distro", map[string]string{"ID": "ubuntu", "VERSION_ID": "20.04"},
+			"cpe:2.3:o:canonical:ubuntu_linux:20.04:*:*:*:*:*:*:*"},
+		{"unknown distro", map[string]string{"ID": "mint", "VERSION_ID": "20"},
+			"cpe:2.3:o:mint:mint:20:*:*:*:*:*:*:*"},
+		{"rolling release", map[string]string{"ID": "arch"},
+			"cpe:2.3:o:arch:arch:*:*:*:*:*:*:*:*"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, ok := osFingerprint(tt.osInfo)
+			require.True(t, ok)
+			assert.Equal(t, "os:"+tt.osInfo["ID"], f.ID)
+			assert.Equal(t, tt.expected, f.CPE)
+		})
+	}
+
+	_, ok := osFingerprint(map[string]string{})
+	assert.False(t, ok)
+}
+
+func TestPackageFingerprint(t *testing.T) {
+	osInfo := map[string]string{"ID": "debian", "VERSION_ID": "10"}
+
+	f := packageFingerprint("deb", parseDebianVersion("libc6", "2.28-10", "amd64"), osInfo)
+	assert.Equal(t, Fingerprint{
+		ID:      "libc6",
+		CPE:     "cpe:2.3:a:libc6:libc6:2.28:*:*:*:*:*:*:*",
+		PURL:    "pkg:deb/debian/libc6@2.28-10?arch=amd64&distro=debian-10",
+		Version: "2.28-10",
+	}, f)
+
+	// the epoch is a qualifier of the purl, and isn't part of the CPE version
+	f = packageFingerprint("deb", parseDebianVersion("libpam0g", "1:1.3.1-5", "amd64"), osInfo)
+	assert.Equal(t, "cpe:2.3:a:libpam0g:libpam0g:1.3.1:*:*:*:*:*:*:*", f.CPE)
+	assert.Equal(t, "pkg:deb/debian/libpam0g@1.3.1-5?arch=amd64&distro=debian-10&epoch=1", f.PURL)
+
+	// special characters are quoted
+	f = packageFingerprint("rpm", installedPackage{name: "libstdc++", version: "8.3.1", release: "5.el8", arch: "x86_64"}, map[string]string{})
+	assert.Equal(t, `cpe:2.3:a:libstdc\+\+:libstdc\+\+:8.3.1:*:*:*:*:*:*:*`, f.CPE)
+	assert.Equal(t, "pkg:rpm/libstdc%2B%2B@8.3.1-5.el8?arch=x86_64", f.PURL)
+}
+
+func TestParseDebianVersion(t *testing.T) {
+	tests := []struct {
+		version  string
+		expected installedPackage
+	}{
+		{"1.0", installedPackage{name: "p", version: "1.0"}},
+		{"1.0-1ubuntu2", installedPackage{name: "p", version: "1.0", release: "1ubuntu2"}},
+		{"2:8.1-2-3", installedPackage{name: "p", epoch: "2", version: "8.1-2", release: "3"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.version, func(t *testing.T) {
+			assert.Equal(t, tt.expected, parseDebianVersion("p", tt.version, ""))
+		})
+	}
+}
+
+func TestCPEPlugin_Fingerprints(t *testing.T) {
+	// GIVEN a plugin reading from a package manager
+	p := &CPEPlugin{
+		packageType: "rpm",
+		osInfo: func() (map[string]string, error) {
+			return nil, errors.New("no os-release")
+		},
+		packages: func() ([]installedPackage, error) {
+			return []installedPackage{
+				{name: "zlib", version: "1.2.11", release: "16.el8"},
+				{name: "bash", version: "4.4.19", release: "10.el8"},
+			}, nil
+		},
+	}
+
+	// WHEN the fingerprints are generated without the os-release facts
+	dataset, err := p.fingerprints()
+
+	// THEN the packages are reported, sorted, without the operating system
+	require.NoError(t, err)
+	require.Len(t, dataset, 2)
+	assert.Equal(t, "bash", dataset[0].SortKey())
+	assert.Equal(t, "pkg:rpm/bash@4.4.19-10.el8", dataset[0].(Fingerprint).PURL)
+	assert.Equal(t, "zlib", dataset[1].SortKey())
+}
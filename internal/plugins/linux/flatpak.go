@@ -0,0 +1,117 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+// +build linux
+
+package linux
+
+import (
+	"bufio"
+	"os/exec"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/newrelic/infrastructure-agent/internal/agent"
+	"github.com/newrelic/infrastructure-agent/pkg/config"
+	"github.com/newrelic/infrastructure-agent/pkg/entity"
+	"github.com/newrelic/infrastructure-agent/pkg/helpers"
+	"github.com/newrelic/infrastructure-agent/pkg/log"
+	"github.com/newrelic/infrastructure-agent/pkg/plugins/ids"
+)
+
+var fplog = log.WithPlugin("Flatpak")
+
+// FlatpakItem is an application or runtime of the Flatpak system installation.
+type FlatpakItem struct {
+	// Ref identifies the installed branch and architecture, e.g. app/org.gimp.GIMP/x86_64/stable
+	Ref         string `json:"id"`
+	Application string `json:"application"`
+	Version     string `json:"version,omitempty"`
+	Branch      string `json:"branch"`
+	Arch        string `json:"arch"`
+	Origin      string `json:"origin"`
+}
+
+func (f FlatpakItem) SortKey() string {
+	return f.Ref
+}
+
+// FlatpakPlugin reports the Flatpak applications and runtimes installed system-wide.
+type FlatpakPlugin struct {
+	agent.PluginCommon
+	frequency time.Duration
+}
+
+func NewFlatpakPlugin(id ids.PluginID, ctx agent.AgentContext) *FlatpakPlugin {
+	cfg := ctx.Config()
+	return &FlatpakPlugin{
+		PluginCommon: agent.PluginCommon{ID: id, Context: ctx},
+		frequency: config.ValidateConfigFrequencySetting(
+			cfg.FlatpakRefreshSec,
+			config.FREQ_MINIMUM_INVENTORY_SAMPLE_RATE,
+			config.FREQ_PLUGIN_SANDBOXED_PACKAGES_UPDATES,
+			cfg.DisableAllPlugins,
+		) * time.Second,
+	}
+}
+
+func (p *FlatpakPlugin) Run() {
+	if p.frequency <= config.FREQ_DISABLE_SAMPLING {
+		fplog.Debug("Disabled.")
+		return
+	}
+	flatpak, err := exec.LookPath("flatpak")
+	if err != nil {
+		fplog.Debug("flatpak not found, Flatpak packages will not be monitored.")
+		p.Unregister()
+		return
+	}
+
+	ticker := time.NewTicker(1)
+	for range ticker.C {
+		ticker.Stop()
+		ticker = time.NewTicker(p.frequency)
+		dataset, err := flatpakPackages(flatpak)
+		if err != nil {
+			fplog.WithError(err).Debug("Can't list the Flatpak packages.")
+			continue
+		}
+		p.EmitInventory(dataset, entity.NewFromNameWithoutID(p.Context.EntityKey()))
+	}
+}
+
+// flatpakPackages lists the applications and the runtimes separately, as their kind isn't a column.
+func flatpakPackages(flatpak string) (agent.PluginInventoryDataset, error) {
+	var dataset agent.PluginInventoryDataset
+	for _, kind := range []string{"app", "runtime"} {
+		output, err := helpers.RunCommand(flatpak, "", "list", "--system", "--"+kind, "--columns=ref,application,version,branch,arch,origin")
+		if err != nil {
+			return nil, err
+		}
+		dataset = append(dataset, parseFlatpakList(kind, output)...)
+	}
+	sort.Sort(dataset)
+	return dataset, nil
+}
+
+// parseFlatpakList reads the tab separated columns of flatpak list for a kind of packages, whose refs are
+// listed without it.
+func parseFlatpakList(kind, output string) agent.PluginInventoryDataset {
+	var dataset agent.PluginInventoryDataset
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		columns := strings.Split(scanner.Text(), "\t")
+		if len(columns) < 6 {
+			continue
+		}
+		dataset = append(dataset, FlatpakItem{
+			Ref:         kind + "/" + strings.TrimSpace(columns[0]),
+			Application: strings.TrimSpace(columns[1]),
+			Version:     strings.TrimSpace(columns[2]),
+			Branch:      strings.TrimSpace(columns[3]),
+			Arch:        strings.TrimSpace(columns[4]),
+			Origin:      strings.TrimSpace(columns[5]),
+		})
+	}
+	return dataset
+}
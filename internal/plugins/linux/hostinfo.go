@@ -234,6 +234,21 @@ func getCpuNum(cpuInfoFile string, fallback string) string {
 	}
 }
 
+// getCpuName reads the `model name` entry from `cpuInfoFile`. RISC-V hosts don't report it, so
+// their micro-architecture, or their ISA if it's not available either, is returned instead.
+func getCpuName(cpuInfoFile string) string {
+	for _, re := range []*regexp.Regexp{
+		regexp.MustCompile(`model\sname\s*:\s`),
+		regexp.MustCompile(`^uarch\s*:\s`),
+		regexp.MustCompile(`^isa\s*:\s`),
+	} {
+		if name := readProcFile(cpuInfoFile, re); name != "unknown" {
+			return name
+		}
+	}
+	return "unknown"
+}
+
 func (self *HostinfoPlugin) gatherHostinfo(context agent.AgentContext) *HostinfoData {
 	infoFile := helpers.HostProc("/cpuinfo")
 	totalCpu := getTotalCpu(infoFile)
@@ -255,7 +270,7 @@ func (self *HostinfoPlugin) gatherHostinfo(context agent.AgentContext) *Hostinfo
 		Distro:          distro,
 		KernelVersion:   getKernelRelease(),
 		HostType:        hostType,
-		CpuName:         readProcFile(helpers.HostProc("/cpuinfo"), regexp.MustCompile(`model\sname\s*:\s`)),
+		CpuName:         getCpuName(infoFile),
 		CpuNum:          getCpuNum(infoFile, totalCpu),
 		TotalCpu:        totalCpu,
 		Ram:             readProcFile(helpers.HostProc("/meminfo"), regexp.MustCompile(`MemTotal:\s*`)),
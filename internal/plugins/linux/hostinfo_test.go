@@ -95,6 +95,18 @@ func (s *HostinfoSuite) TestGetCpuNum(c *C) {
 	c.Assert(getCpuNum("/tmp/cpuinfo", "1"), Equals, "2")
 }
 
+func (s *HostinfoSuite) TestGetCpuName(c *C) {
+	err := ioutil.WriteFile("/tmp/cpuinfo", []byte(cpuinfo), 0644)
+	c.Assert(err, IsNil)
+	c.Assert(getCpuName("/tmp/cpuinfo"), Equals, "Intel(R) Core(TM) i7-4790K CPU @ 4.00GHz")
+
+	riscv := "processor\t: 0\nhart\t\t: 1\nisa\t\t: rv64imafdc\nmmu\t\t: sv39\nuarch\t\t: sifive,u74-mc\n"
+	err = ioutil.WriteFile("/tmp/cpuinfo", []byte(riscv), 0644)
+	c.Assert(err, IsNil)
+	c.Assert(getCpuName("/tmp/cpuinfo"), Equals, "sifive,u74-mc")
+	c.Assert(getCpuNum("/tmp/cpuinfo", "1"), Equals, "1")
+}
+
 func (s *HostinfoSuite) TestGetCpuNumFallback(c *C) {
 	err := os.Remove("/tmp/cpuinfo")
 	c.Assert(err, IsNil)
@@ -8,8 +8,11 @@ import (
 	"bufio"
 	"fmt"
 	"github.com/newrelic/infrastructure-agent/pkg/entity"
+	"io/ioutil"
 	"os"
+	"path/filepath"
 	"regexp"
+	"sort"
 	"strings"
 	"time"
 
@@ -29,12 +32,22 @@ type KernelModulesPlugin struct {
 	loadedModules map[string]KernelModule
 	needsFlush    bool
 	frequency     time.Duration
+	sysModuleDir  string
 }
 
 type KernelModule struct {
 	Name        string `json:"id"`
 	Version     string `json:"version"`
 	Description string `json:"description"`
+	SrcVersion  string `json:"srcversion,omitempty"`
+	// signature of the module, empty if it isn't signed
+	Signer      string `json:"signer,omitempty"`
+	SigKey      string `json:"sig_key,omitempty"`
+	SigHashAlgo string `json:"sig_hashalgo,omitempty"`
+	// taint flags of the module, e.g. O for out-of-tree or E for unsigned modules
+	Taint string `json:"taint,omitempty"`
+	// readable parameters of the module, as space separated name=value pairs sorted by name
+	Parameters string `json:"parameters,omitempty"`
 }
 
 func (self KernelModule) SortKey() string {
@@ -47,6 +60,7 @@ func NewKernelModulesPlugin(id ids.PluginID, ctx agent.AgentContext) agent.Plugi
 		PluginCommon:  agent.PluginCommon{ID: id, Context: ctx},
 		loadedModules: make(map[string]KernelModule),
 		needsFlush:    true,
+		sysModuleDir:  helpers.HostSys("module"),
 		frequency: config.ValidateConfigFrequencySetting(
 			cfg.KernelModulesRefreshSec,
 			config.FREQ_MINIMUM_FAST_INVENTORY_SAMPLE_RATE,
@@ -73,7 +87,7 @@ func (self *KernelModulesPlugin) populateKernelModuleInfo(modInfo *KernelModule)
 		return
 	}
 
-	reModuleInfo := regexp.MustCompile(`^(filename|version|description):\s+(.*)`)
+	reModuleInfo := regexp.MustCompile(`^(filename|version|description|srcversion|signer|sig_key|sig_hashalgo):\s+(.*)`)
 
 	scanner := bufio.NewScanner(strings.NewReader(output))
 	for scanner.Scan() {
@@ -86,6 +100,14 @@ func (self *KernelModulesPlugin) populateKernelModuleInfo(modInfo *KernelModule)
 				modInfo.Version = matches[2]
 			case "description":
 				modInfo.Description = matches[2]
+			case "srcversion":
+				modInfo.SrcVersion = matches[2]
+			case "signer":
+				modInfo.Signer = matches[2]
+			case "sig_key":
+				modInfo.SigKey = matches[2]
+			case "sig_hashalgo":
+				modInfo.SigHashAlgo = matches[2]
 			}
 		}
 	}
@@ -145,9 +167,44 @@ func (self *KernelModulesPlugin) processUpdates(seenModules map[string]bool) (er
 			self.needsFlush = true
 		}
 	}
+
+	// Parameters can be changed at runtime, and the kernel taints the modules as it finds issues
+	for k, module := range self.loadedModules {
+		taint, parameters := readModuleState(self.sysModuleDir, k)
+		if taint != module.Taint || parameters != module.Parameters {
+			module.Taint = taint
+			module.Parameters = parameters
+			self.loadedModules[k] = module
+			self.needsFlush = true
+		}
+	}
 	return
 }
 
+// readModuleState returns the taint flags and the parameters of a loaded module from its sysfs directory.
+// Parameters that can't be read, as some are write-only, are skipped.
+func readModuleState(sysModuleDir, name string) (taint, parameters string) {
+	if content, err := ioutil.ReadFile(filepath.Join(sysModuleDir, name, "taint")); err == nil {
+		taint = strings.TrimSpace(string(content))
+	}
+
+	paramsDir := filepath.Join(sysModuleDir, name, "parameters")
+	files, err := ioutil.ReadDir(paramsDir)
+	if err != nil {
+		return taint, ""
+	}
+	pairs := make([]string, 0, len(files))
+	for _, file := range files {
+		content, err := ioutil.ReadFile(filepath.Join(paramsDir, file.Name()))
+		if err != nil {
+			continue
+		}
+		pairs = append(pairs, file.Name()+"="+strings.TrimSpace(string(content)))
+	}
+	sort.Strings(pairs)
+	return taint, strings.Join(pairs, " ")
+}
+
 func (self *KernelModulesPlugin) Run() {
 	if self.frequency <= config.FREQ_DISABLE_SAMPLING {
 		kmlog.Debug("Disabled.")
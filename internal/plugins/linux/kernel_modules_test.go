@@ -0,0 +1,59 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+// +build linux
+
+package linux
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKernelModules_ModuleState(t *testing.T) {
+	// GIVEN an out-of-tree module with parameters, and an in-tree module without them
+	dir, err := ioutil.TempDir("", "module")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	for path, content := range map[string]string{
+		"zfs/taint":                     "OE\n",
+		"zfs/parameters/zfs_arc_max":    "0\n",
+		"zfs/parameters/spa_slop_shift": "5\n",
+		"loop/taint":                    "\n",
+		"loop/coresize":                 "40960\n",
+	} {
+		path = filepath.Join(dir, path)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, ioutil.WriteFile(path, []byte(content), 0644))
+	}
+
+	p := &KernelModulesPlugin{
+		sysModuleDir: dir,
+		loadedModules: map[string]KernelModule{
+			"zfs":  {Name: "zfs", Version: "0.8.3"},
+			"loop": {Name: "loop"},
+		},
+	}
+
+	// WHEN the loaded modules are processed
+	require.NoError(t, p.processUpdates(map[string]bool{"zfs": true, "loop": true}))
+
+	// THEN their taint flags and parameters are reported
+	assert.True(t, p.needsFlush)
+	zfs := p.loadedModules["zfs"]
+	assert.Equal(t, "OE", zfs.Taint)
+	assert.Equal(t, "spa_slop_shift=5 zfs_arc_max=0", zfs.Parameters)
+	assert.Equal(t, "0.8.3", zfs.Version)
+	assert.Equal(t, KernelModule{Name: "loop"}, p.loadedModules["loop"])
+
+	// AND a parameter change is flushed
+	p.needsFlush = false
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "zfs/parameters/zfs_arc_max"), []byte("1073741824\n"), 0644))
+	require.NoError(t, p.processUpdates(map[string]bool{"zfs": true, "loop": true}))
+	assert.True(t, p.needsFlush)
+	assert.Equal(t, "spa_slop_shift=5 zfs_arc_max=1073741824", p.loadedModules["zfs"].Parameters)
+}
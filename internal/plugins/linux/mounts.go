@@ -0,0 +1,244 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+// +build linux
+
+package linux
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/sys/unix"
+
+	"github.com/newrelic/infrastructure-agent/internal/agent"
+	"github.com/newrelic/infrastructure-agent/pkg/config"
+	"github.com/newrelic/infrastructure-agent/pkg/entity"
+	"github.com/newrelic/infrastructure-agent/pkg/helpers"
+	"github.com/newrelic/infrastructure-agent/pkg/log"
+	"github.com/newrelic/infrastructure-agent/pkg/plugins/ids"
+)
+
+var mntlog = log.WithPlugin("Mounts")
+
+// Network filesystems reported along with the ones backed by a device.
+var networkFilesystems = map[string]bool{
+	"nfs":        true,
+	"nfs4":       true,
+	"cifs":       true,
+	"smb3":       true,
+	"ceph":       true,
+	"glusterfs":  true,
+	"fuse.sshfs": true,
+}
+
+// Mount is a mounted filesystem.
+type Mount struct {
+	MountPoint     string `json:"id"`
+	Device         string `json:"device"`
+	FilesystemType string `json:"filesystemType"`
+	Options        string `json:"options"`
+}
+
+func (m Mount) SortKey() string {
+	return m.MountPoint
+}
+
+// MountsPlugin reports the mounted filesystems, submitting an event each time one of them is mounted or
+// unmounted. The kernel notifies the mount table changes, so the inventory is updated immediately.
+type MountsPlugin struct {
+	agent.PluginCommon
+	frequency  time.Duration
+	mountsFile string
+	// last reported mounts, by mount point
+	mounts map[string]Mount
+}
+
+func NewMountsPlugin(id ids.PluginID, ctx agent.AgentContext) *MountsPlugin {
+	cfg := ctx.Config()
+	return &MountsPlugin{
+		PluginCommon: agent.PluginCommon{ID: id, Context: ctx},
+		frequency: config.ValidateConfigFrequencySetting(
+			cfg.StorageDevicesRefreshSec,
+			config.FREQ_MINIMUM_FAST_INVENTORY_SAMPLE_RATE,
+			config.FREQ_PLUGIN_STORAGE_DEVICES_UPDATES,
+			cfg.DisableAllPlugins,
+		) * time.Second,
+		mountsFile: helpers.HostProc("self", "mounts"),
+	}
+}
+
+func (p *MountsPlugin) Run() {
+	if p.frequency <= config.FREQ_DISABLE_SAMPLING {
+		mntlog.Debug("Disabled.")
+		return
+	}
+
+	changes := make(chan struct{}, 1)
+	if err := watchMountTable(p.mountsFile, changes); err != nil {
+		mntlog.WithError(err).Warn("can't watch the mount table. Mounts will be only refreshed periodically")
+	}
+
+	refreshTimer := time.NewTimer(1)
+	for {
+		select {
+		case <-changes:
+		case <-refreshTimer.C:
+			refreshTimer.Reset(p.frequency)
+		}
+		p.refresh()
+	}
+}
+
+// refresh reads the mount table, submitting the inventory and an event for each change since the previous read.
+func (p *MountsPlugin) refresh() {
+	file, err := os.Open(p.mountsFile)
+	if err != nil {
+		mntlog.WithError(err).Error("can't read mount table")
+		return
+	}
+	mounts, err := parseMounts(file)
+	file.Close()
+	if err != nil {
+		mntlog.WithError(err).Error("can't parse mount table")
+		return
+	}
+
+	entityKey := p.Context.EntityKey()
+	// no events are reported for the mounts found on startup
+	if p.mounts != nil {
+		for _, event := range mountEvents(p.mounts, mounts) {
+			p.EmitEvent(event, entity.Key(entityKey))
+		}
+	}
+	p.mounts = mounts
+
+	var dataset agent.PluginInventoryDataset
+	for _, mount := range mounts {
+		dataset = append(dataset, mount)
+	}
+	p.EmitInventory(dataset, entity.NewFromNameWithoutID(entityKey))
+}
+
+// watchMountTable notifies the mount table changes, which the kernel signals as an exceptional
+// condition when polling the mounts file.
+func watchMountTable(mountsFile string, changes chan<- struct{}) error {
+	file, err := os.Open(mountsFile)
+	if err != nil {
+		return err
+	}
+	go func() {
+		defer file.Close()
+		fds := []unix.PollFd{{Fd: int32(file.Fd()), Events: unix.POLLPRI}}
+		for {
+			// the file has to be read to acknowledge the previous change
+			if _, err := file.Seek(0, io.SeekStart); err == nil {
+				_, _ = io.Copy(ioutil.Discard, file)
+			}
+			if _, err := unix.Poll(fds, -1); err != nil {
+				if err == unix.EINTR {
+					continue
+				}
+				mntlog.WithError(err).Warn("stopped watching the mount table")
+				return
+			}
+			if fds[0].Revents&(unix.POLLPRI|unix.POLLERR) != 0 {
+				select {
+				case changes <- struct{}{}:
+				default: // a refresh is already pending
+				}
+			}
+		}
+	}()
+	return nil
+}
+
+// parseMounts parses a /proc/<pid>/mounts file, returning the filesystems backed by a device or
+// by a network share, by mount point. Pseudo filesystems such as proc or tmpfs are ignored.
+func parseMounts(mountsFile io.Reader) (map[string]Mount, error) {
+	mounts := map[string]Mount{}
+	scanner := bufio.NewScanner(mountsFile)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 4 {
+			continue
+		}
+		mount := Mount{
+			Device:         unescapeMountField(fields[0]),
+			MountPoint:     unescapeMountField(fields[1]),
+			FilesystemType: fields[2],
+			Options:        fields[3],
+		}
+		if !strings.HasPrefix(mount.Device, "/") && !networkFilesystems[mount.FilesystemType] {
+			continue
+		}
+		// the last mount over a mount point hides the previous ones
+		mounts[mount.MountPoint] = mount
+	}
+	return mounts, scanner.Err()
+}
+
+// unescapeMountField decodes the octal escapes (e.g. \040 for a space) of the mounts file fields.
+func unescapeMountField(field string) string {
+	if !strings.Contains(field, `\`) {
+		return field
+	}
+	var sb strings.Builder
+	for i := 0; i < len(field); i++ {
+		if field[i] == '\\' && i+3 < len(field) {
+			if b, err := strconv.ParseUint(field[i+1:i+4], 8, 8); err == nil {
+				sb.WriteByte(byte(b))
+				i += 3
+				continue
+			}
+		}
+		sb.WriteByte(field[i])
+	}
+	return sb.String()
+}
+
+// mountEvents returns an event for each filesystem mounted or unmounted between two reads of the
+// mount table. Remounts with a different device or filesystem are reported as an unmount followed by a mount.
+func mountEvents(previous, current map[string]Mount) []map[string]interface{} {
+	var events []map[string]interface{}
+	for _, mountPoint := range sortedMountPoints(previous) {
+		before := previous[mountPoint]
+		if after, ok := current[mountPoint]; !ok || after.Device != before.Device || after.FilesystemType != before.FilesystemType {
+			events = append(events, mountEvent("unmounted", before))
+		}
+	}
+	for _, mountPoint := range sortedMountPoints(current) {
+		after := current[mountPoint]
+		if before, ok := previous[mountPoint]; !ok || after.Device != before.Device || after.FilesystemType != before.FilesystemType {
+			events = append(events, mountEvent("mounted", after))
+		}
+	}
+	return events
+}
+
+func mountEvent(action string, mount Mount) map[string]interface{} {
+	return map[string]interface{}{
+		"eventType":      "InfrastructureEvent",
+		"category":       "storage",
+		"summary":        fmt.Sprintf("Filesystem %s %s on %s", mount.Device, action, mount.MountPoint),
+		"action":         action,
+		"mountPoint":     mount.MountPoint,
+		"device":         mount.Device,
+		"filesystemType": mount.FilesystemType,
+	}
+}
+
+func sortedMountPoints(mounts map[string]Mount) []string {
+	mountPoints := make([]string, 0, len(mounts))
+	for mountPoint := range mounts {
+		mountPoints = append(mountPoints, mountPoint)
+	}
+	sort.Strings(mountPoints)
+	return mountPoints
+}
@@ -0,0 +1,95 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+// +build linux
+
+package linux
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const procMounts = `sysfs /sys sysfs rw,nosuid,nodev,noexec,relatime 0 0
+proc /proc proc rw,nosuid,nodev,noexec,relatime 0 0
+/dev/nvme0n1p2 / ext4 rw,relatime,errors=remount-ro 0 0
+tmpfs /run tmpfs rw,nosuid,nodev,noexec,relatime,size=1611896k,mode=755 0 0
+/dev/nvme0n1p1 /boot/efi vfat rw,relatime,fmask=0077,dmask=0077 0 0
+/dev/sdb1 /media/usb\040drive vfat rw,nosuid,nodev,relatime 0 0
+10.0.0.5:/exports /mnt/nfs nfs4 rw,relatime,vers=4.2 0 0
+overlay /var/lib/docker/overlay2/abc/merged overlay rw,relatime 0 0
+`
+
+func TestParseMounts(t *testing.T) {
+	mounts, err := parseMounts(strings.NewReader(procMounts))
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]Mount{
+		"/": {
+			MountPoint: "/", Device: "/dev/nvme0n1p2", FilesystemType: "ext4",
+			Options: "rw,relatime,errors=remount-ro",
+		},
+		"/boot/efi": {
+			MountPoint: "/boot/efi", Device: "/dev/nvme0n1p1", FilesystemType: "vfat",
+			Options: "rw,relatime,fmask=0077,dmask=0077",
+		},
+		"/media/usb drive": {
+			MountPoint: "/media/usb drive", Device: "/dev/sdb1", FilesystemType: "vfat",
+			Options: "rw,nosuid,nodev,relatime",
+		},
+		"/mnt/nfs": {
+			MountPoint: "/mnt/nfs", Device: "10.0.0.5:/exports", FilesystemType: "nfs4",
+			Options: "rw,relatime,vers=4.2",
+		},
+	}, mounts)
+}
+
+func TestUnescapeMountField(t *testing.T) {
+	assert.Equal(t, "/mnt/a b", unescapeMountField(`/mnt/a\040b`))
+	assert.Equal(t, "/mnt/tab\there", unescapeMountField(`/mnt/tab\011here`))
+	assert.Equal(t, `/mnt/trailing\04`, unescapeMountField(`/mnt/trailing\04`))
+	assert.Equal(t, "/mnt/plain", unescapeMountField("/mnt/plain"))
+}
+
+func TestMountEvents(t *testing.T) {
+	// GIVEN a root filesystem and a USB drive mounted
+	root := Mount{MountPoint: "/", Device: "/dev/sda1", FilesystemType: "ext4", Options: "rw"}
+	usb := Mount{MountPoint: "/media/usb", Device: "/dev/sdb1", FilesystemType: "vfat", Options: "rw"}
+	previous := map[string]Mount{"/": root, "/media/usb": usb}
+
+	// WHEN the USB drive is unmounted, the root remounted read-only and a new disk mounted
+	roRoot := root
+	roRoot.Options = "ro"
+	data := Mount{MountPoint: "/data", Device: "/dev/sdc1", FilesystemType: "xfs", Options: "rw"}
+	events := mountEvents(previous, map[string]Mount{"/": roRoot, "/data": data})
+
+	// THEN the unmount and the mount are reported, but not the remount with other options
+	require.Len(t, events, 2)
+	assert.Equal(t, map[string]interface{}{
+		"eventType":      "InfrastructureEvent",
+		"category":       "storage",
+		"summary":        "Filesystem /dev/sdb1 unmounted on /media/usb",
+		"action":         "unmounted",
+		"mountPoint":     "/media/usb",
+		"device":         "/dev/sdb1",
+		"filesystemType": "vfat",
+	}, events[0])
+	assert.Equal(t, "mounted", events[1]["action"])
+	assert.Equal(t, "/data", events[1]["mountPoint"])
+	assert.Equal(t, "/dev/sdc1", events[1]["device"])
+}
+
+func TestMountEvents_DeviceReplaced(t *testing.T) {
+	previous := map[string]Mount{"/mnt": {MountPoint: "/mnt", Device: "/dev/sdb1", FilesystemType: "ext4"}}
+	current := map[string]Mount{"/mnt": {MountPoint: "/mnt", Device: "/dev/sdc1", FilesystemType: "ext4"}}
+
+	events := mountEvents(previous, current)
+
+	require.Len(t, events, 2)
+	assert.Equal(t, "unmounted", events[0]["action"])
+	assert.Equal(t, "/dev/sdb1", events[0]["device"])
+	assert.Equal(t, "mounted", events[1]["action"])
+	assert.Equal(t, "/dev/sdc1", events[1]["device"])
+}
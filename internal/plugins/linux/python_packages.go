@@ -0,0 +1,212 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+// +build linux
+
+package linux
+
+import (
+	"bufio"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/newrelic/infrastructure-agent/internal/agent"
+	"github.com/newrelic/infrastructure-agent/pkg/config"
+	"github.com/newrelic/infrastructure-agent/pkg/entity"
+	"github.com/newrelic/infrastructure-agent/pkg/log"
+	"github.com/newrelic/infrastructure-agent/pkg/plugins/ids"
+)
+
+var pylog = log.WithPlugin("PythonPackages")
+
+// maxVirtualenvDepth bounds the directory levels walked to find virtualenvs, e.g. /home/<user>/<project>/.venv
+const maxVirtualenvDepth = 4
+
+var defaultVirtualenvRoots = []string{"/opt", "/srv", "/home"}
+
+// systemSitePackagesGlobs match the site-packages of the Python interpreters installed by the distro and
+// by pip into /usr/local. Debian based distros name them dist-packages.
+var systemSitePackagesGlobs = []string{
+	"/usr/lib/python*/site-packages",
+	"/usr/lib/python*/dist-packages",
+	"/usr/lib64/python*/site-packages",
+	"/usr/local/lib/python*/site-packages",
+	"/usr/local/lib/python*/dist-packages",
+	"/usr/local/lib64/python*/site-packages",
+}
+
+// PythonPackage is a Python distribution installed in a site-packages directory.
+type PythonPackage struct {
+	// ID is the location followed by the package name, as the same package can be installed in several
+	// environments
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	Version  string `json:"version"`
+	Location string `json:"location"`
+}
+
+func (p PythonPackage) SortKey() string {
+	return p.ID
+}
+
+// PythonPackagesPlugin reports the Python packages installed in the system and in the virtualenvs. The
+// package metadata is read from the site-packages directories, so no interpreter or pip is run.
+type PythonPackagesPlugin struct {
+	agent.PluginCommon
+	frequency       time.Duration
+	virtualenvRoots []string
+}
+
+func NewPythonPackagesPlugin(id ids.PluginID, ctx agent.AgentContext) *PythonPackagesPlugin {
+	cfg := ctx.Config()
+	roots := cfg.PythonVirtualenvPaths
+	if len(roots) == 0 {
+		roots = defaultVirtualenvRoots
+	}
+	return &PythonPackagesPlugin{
+		PluginCommon: agent.PluginCommon{ID: id, Context: ctx},
+		frequency: config.ValidateConfigFrequencySetting(
+			cfg.PythonPackagesRefreshSec,
+			config.FREQ_MINIMUM_INVENTORY_SAMPLE_RATE,
+			config.FREQ_PLUGIN_PYTHON_PACKAGES_UPDATES,
+			cfg.DisableAllPlugins || !cfg.EnablePythonPackagesInventory,
+		) * time.Second,
+		virtualenvRoots: roots,
+	}
+}
+
+func (p *PythonPackagesPlugin) Run() {
+	if p.frequency <= config.FREQ_DISABLE_SAMPLING {
+		pylog.Debug("Disabled.")
+		return
+	}
+
+	ticker := time.NewTicker(1)
+	for range ticker.C {
+		ticker.Stop()
+		ticker = time.NewTicker(p.frequency)
+		dirs := append(systemSitePackages(), virtualenvSitePackages(p.virtualenvRoots)...)
+		p.EmitInventory(pythonPackages(dirs), entity.NewFromNameWithoutID(p.Context.EntityKey()))
+	}
+}
+
+func systemSitePackages() []string {
+	var dirs []string
+	for _, pattern := range systemSitePackagesGlobs {
+		matches, _ := filepath.Glob(pattern)
+		dirs = append(dirs, matches...)
+	}
+	return dirs
+}
+
+// virtualenvSitePackages returns the site-packages of the virtualenvs found under the passed roots.
+func virtualenvSitePackages(roots []string) []string {
+	var dirs []string
+	for _, root := range roots {
+		root = filepath.Clean(root)
+		baseDepth := strings.Count(root, string(os.PathSeparator))
+		_ = filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				// e.g. unreadable home directories
+				return nil
+			}
+			if !info.IsDir() {
+				return nil
+			}
+			if strings.Count(path, string(os.PathSeparator))-baseDepth > maxVirtualenvDepth {
+				return filepath.SkipDir
+			}
+			if _, err := os.Stat(filepath.Join(path, "pyvenv.cfg")); err != nil {
+				return nil
+			}
+			matches, _ := filepath.Glob(filepath.Join(path, "lib", "python*", "site-packages"))
+			dirs = append(dirs, matches...)
+			// virtualenvs aren't nested
+			return filepath.SkipDir
+		})
+	}
+	return dirs
+}
+
+// pythonPackages reads the packages installed in the site-packages directories from their dist-info
+// (wheels) or egg-info (setuptools) metadata.
+func pythonPackages(dirs []string) agent.PluginInventoryDataset {
+	var dataset agent.PluginInventoryDataset
+	seen := map[string]bool{}
+	for _, dir := range dirs {
+		// the lib64 directories are usually links to the lib ones
+		if resolved, err := filepath.EvalSymlinks(dir); err == nil {
+			dir = resolved
+		}
+		if seen[dir] {
+			continue
+		}
+		seen[dir] = true
+
+		entries, err := ioutil.ReadDir(dir)
+		if err != nil {
+			pylog.WithError(err).WithField("location", dir).Debug("Can't read site-packages.")
+			continue
+		}
+		for _, entry := range entries {
+			var metadata string
+			switch {
+			case strings.HasSuffix(entry.Name(), ".dist-info"):
+				metadata = filepath.Join(dir, entry.Name(), "METADATA")
+			case strings.HasSuffix(entry.Name(), ".egg-info") && entry.IsDir():
+				metadata = filepath.Join(dir, entry.Name(), "PKG-INFO")
+			case strings.HasSuffix(entry.Name(), ".egg-info"):
+				// single file egg-info of the distutils installs
+				metadata = filepath.Join(dir, entry.Name())
+			default:
+				continue
+			}
+			name, version, err := readPythonMetadata(metadata)
+			if err != nil || name == "" {
+				pylog.WithError(err).WithField("metadata", metadata).Debug("Can't read Python package metadata.")
+				continue
+			}
+			dataset = append(dataset, PythonPackage{
+				ID:       filepath.Join(dir, name),
+				Name:     name,
+				Version:  version,
+				Location: dir,
+			})
+		}
+	}
+	sort.Sort(dataset)
+	return dataset
+}
+
+// readPythonMetadata returns the name and version of the headers of a package metadata file, e.g.
+// Metadata-Version: 2.1
+// Name: requests
+// Version: 2.25.1
+func readPythonMetadata(path string) (name, version string, err error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", "", err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := scanner.Text()
+		// the headers end at the first empty line, followed by the description
+		if line == "" {
+			break
+		}
+		if strings.HasPrefix(line, "Name:") {
+			name = strings.TrimSpace(strings.TrimPrefix(line, "Name:"))
+		} else if strings.HasPrefix(line, "Version:") {
+			version = strings.TrimSpace(strings.TrimPrefix(line, "Version:"))
+		}
+		if name != "" && version != "" {
+			break
+		}
+	}
+	return name, version, scanner.Err()
+}
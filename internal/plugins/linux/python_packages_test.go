@@ -0,0 +1,55 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+// +build linux
+
+package linux
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writePythonFiles(t *testing.T, dir string, files map[string]string) {
+	for path, content := range files {
+		path = filepath.Join(dir, path)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, ioutil.WriteFile(path, []byte(content), 0644))
+	}
+}
+
+func TestPythonPackages(t *testing.T) {
+	// GIVEN a project virtualenv with a wheel, a setuptools and a distutils package
+	root, err := ioutil.TempDir("", "python")
+	require.NoError(t, err)
+	defer os.RemoveAll(root)
+	writePythonFiles(t, root, map[string]string{
+		"app/.venv/pyvenv.cfg": "home = /usr/bin\n",
+		"app/.venv/lib/python3.8/site-packages/requests-2.25.1.dist-info/METADATA": "Metadata-Version: 2.1\n" +
+			"Name: requests\nVersion: 2.25.1\n\nName: not a header\n",
+		"app/.venv/lib/python3.8/site-packages/six-1.15.0.egg-info/PKG-INFO": "Metadata-Version: 1.2\nName: six\nVersion: 1.15.0\n",
+		"app/.venv/lib/python3.8/site-packages/legacy-0.1.egg-info":          "Metadata-Version: 1.0\nName: legacy\nVersion: 0.1\n",
+		"app/.venv/lib/python3.8/site-packages/requests/__init__.py":         "",
+		"app/.venv/lib/python3.8/site-packages/broken.dist-info/RECORD":      "",
+		// too deep to be discovered
+		"a/b/c/d/e/.venv/pyvenv.cfg": "home = /usr/bin\n",
+		"a/b/c/d/e/.venv/lib/python3.8/site-packages/flask-1.1.2.dist-info/METADATA": "Name: flask\nVersion: 1.1.2\n",
+	})
+
+	// WHEN the packages of the discovered virtualenvs are read
+	dirs := virtualenvSitePackages([]string{root})
+	dataset := pythonPackages(dirs)
+
+	// THEN the packages are reported by location
+	sitePackages, err := filepath.EvalSymlinks(filepath.Join(root, "app/.venv/lib/python3.8/site-packages"))
+	require.NoError(t, err)
+	require.Equal(t, []string{filepath.Join(root, "app/.venv/lib/python3.8/site-packages")}, dirs)
+	require.Len(t, dataset, 3)
+	assert.Equal(t, PythonPackage{ID: filepath.Join(sitePackages, "legacy"), Name: "legacy", Version: "0.1", Location: sitePackages}, dataset[0])
+	assert.Equal(t, PythonPackage{ID: filepath.Join(sitePackages, "requests"), Name: "requests", Version: "2.25.1", Location: sitePackages}, dataset[1])
+	assert.Equal(t, PythonPackage{ID: filepath.Join(sitePackages, "six"), Name: "six", Version: "1.15.0", Location: sitePackages}, dataset[2])
+}
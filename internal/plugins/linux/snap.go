@@ -0,0 +1,156 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+// +build linux
+
+package linux
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/newrelic/infrastructure-agent/internal/agent"
+	"github.com/newrelic/infrastructure-agent/pkg/config"
+	"github.com/newrelic/infrastructure-agent/pkg/entity"
+	"github.com/newrelic/infrastructure-agent/pkg/log"
+	"github.com/newrelic/infrastructure-agent/pkg/plugins/ids"
+)
+
+const (
+	snapdSocket  = "/run/snapd.socket"
+	snapdTimeout = 10 * time.Second
+)
+
+var snaplog = log.WithPlugin("Snap")
+
+// SnapItem is a snap installed through snapd.
+type SnapItem struct {
+	Name        string `json:"id"`
+	Version     string `json:"version"`
+	Revision    string `json:"revision"`
+	Channel     string `json:"channel,omitempty"`
+	Type        string `json:"type"`
+	Confinement string `json:"confinement"`
+	Publisher   string `json:"publisher,omitempty"`
+}
+
+func (s SnapItem) SortKey() string {
+	return s.Name
+}
+
+// SnapPlugin reports the installed snaps from the snapd REST API.
+type SnapPlugin struct {
+	agent.PluginCommon
+	frequency time.Duration
+	client    *http.Client
+}
+
+func NewSnapPlugin(id ids.PluginID, ctx agent.AgentContext) *SnapPlugin {
+	cfg := ctx.Config()
+	return &SnapPlugin{
+		PluginCommon: agent.PluginCommon{ID: id, Context: ctx},
+		frequency: config.ValidateConfigFrequencySetting(
+			cfg.SnapRefreshSec,
+			config.FREQ_MINIMUM_INVENTORY_SAMPLE_RATE,
+			config.FREQ_PLUGIN_SANDBOXED_PACKAGES_UPDATES,
+			cfg.DisableAllPlugins,
+		) * time.Second,
+		client: &http.Client{
+			Timeout: snapdTimeout,
+			Transport: &http.Transport{
+				DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					return (&net.Dialer{}).DialContext(ctx, "unix", snapdSocket)
+				},
+			},
+		},
+	}
+}
+
+func (p *SnapPlugin) Run() {
+	if p.frequency <= config.FREQ_DISABLE_SAMPLING {
+		snaplog.Debug("Disabled.")
+		return
+	}
+	if _, err := os.Stat(snapdSocket); err != nil {
+		snaplog.Debug("snapd not found, snaps will not be monitored.")
+		p.Unregister()
+		return
+	}
+
+	ticker := time.NewTicker(1)
+	for range ticker.C {
+		ticker.Stop()
+		ticker = time.NewTicker(p.frequency)
+		dataset, err := p.snaps()
+		if err != nil {
+			snaplog.WithError(err).Debug("Can't list the snaps.")
+			continue
+		}
+		p.EmitInventory(dataset, entity.NewFromNameWithoutID(p.Context.EntityKey()))
+	}
+}
+
+func (p *SnapPlugin) snaps() (agent.PluginInventoryDataset, error) {
+	// the host is ignored, as the requests are sent to the socket
+	resp, err := p.client.Get("http://localhost/v2/snaps")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("snapd returned %s", resp.Status)
+	}
+	return parseSnaps(body)
+}
+
+// parseSnaps reads the response of the snapd snaps endpoint.
+func parseSnaps(body []byte) (agent.PluginInventoryDataset, error) {
+	var response struct {
+		Result []struct {
+			Name            string `json:"name"`
+			Version         string `json:"version"`
+			Revision        string `json:"revision"`
+			Channel         string `json:"channel"`
+			TrackingChannel string `json:"tracking-channel"`
+			Type            string `json:"type"`
+			Confinement     string `json:"confinement"`
+			Publisher       struct {
+				Username string `json:"username"`
+			} `json:"publisher"`
+		} `json:"result"`
+	}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, err
+	}
+
+	dataset := make(agent.PluginInventoryDataset, 0, len(response.Result))
+	for _, snap := range response.Result {
+		// the tracked channel includes the track, e.g. latest/stable, while the channel of the installed
+		// revision doesn't
+		channel := snap.TrackingChannel
+		if channel == "" {
+			channel = snap.Channel
+		}
+		dataset = append(dataset, SnapItem{
+			Name:        snap.Name,
+			Version:     snap.Version,
+			Revision:    snap.Revision,
+			Channel:     channel,
+			Type:        snap.Type,
+			Confinement: snap.Confinement,
+			Publisher:   snap.Publisher.Username,
+		})
+	}
+	sort.Sort(dataset)
+	return dataset, nil
+}
@@ -0,0 +1,44 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+// +build linux
+
+package linux
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/newrelic/infrastructure-agent/internal/agent"
+)
+
+func TestParseSnaps(t *testing.T) {
+	body := []byte(`{"type":"sync","status-code":200,"status":"OK","result":[
+		{"name":"lxd","version":"4.6","revision":"17738","channel":"stable","tracking-channel":"latest/stable",
+		 "type":"app","confinement":"strict","publisher":{"id":"canonical","username":"canonical"}},
+		{"name":"core18","version":"20200724","revision":"1885","channel":"stable",
+		 "type":"base","confinement":"strict","publisher":{"username":"canonical"}}
+	]}`)
+
+	dataset, err := parseSnaps(body)
+
+	require.NoError(t, err)
+	assert.Equal(t, agent.PluginInventoryDataset{
+		SnapItem{Name: "core18", Version: "20200724", Revision: "1885", Channel: "stable", Type: "base", Confinement: "strict", Publisher: "canonical"},
+		SnapItem{Name: "lxd", Version: "4.6", Revision: "17738", Channel: "latest/stable", Type: "app", Confinement: "strict", Publisher: "canonical"},
+	}, dataset)
+}
+
+func TestParseFlatpakList(t *testing.T) {
+	output := "org.gimp.GIMP/x86_64/stable\torg.gimp.GIMP\t2.10.20\tstable\tx86_64\tflathub\n" +
+		"com.spotify.Client/x86_64/stable\tcom.spotify.Client\t\tstable\tx86_64\tflathub\n" +
+		"incomplete line\n"
+
+	dataset := parseFlatpakList("app", output)
+
+	assert.Equal(t, agent.PluginInventoryDataset{
+		FlatpakItem{Ref: "app/org.gimp.GIMP/x86_64/stable", Application: "org.gimp.GIMP", Version: "2.10.20", Branch: "stable", Arch: "x86_64", Origin: "flathub"},
+		FlatpakItem{Ref: "app/com.spotify.Client/x86_64/stable", Application: "com.spotify.Client", Branch: "stable", Arch: "x86_64", Origin: "flathub"},
+	}, dataset)
+}
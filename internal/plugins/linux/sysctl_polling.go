@@ -9,6 +9,7 @@ import (
 	"github.com/newrelic/infrastructure-agent/pkg/entity"
 	"io/ioutil"
 	"os"
+	"path"
 	"path/filepath"
 	"regexp"
 	"strings"
@@ -29,7 +30,8 @@ type SysctlPlugin struct {
 	procSysDir    string
 	fileService   fileService
 	ignoredListRE *regexp.Regexp
-	regexpCache   *lru.Cache
+	allowlist     []string   // patterns of the reported sysctls, all of them if empty
+	regexpCache   *lru.Cache // whether a path is excluded, by path
 }
 
 // NewSysctlPollingMonitor creates a /proc/sys parser polling on intervals
@@ -50,6 +52,7 @@ func NewSysctlPollingMonitor(id ids.PluginID, ctx agent.AgentContext) *SysctlPlu
 			read: ioutil.ReadFile,
 		},
 		ignoredListRE: regexp.MustCompile(fmt.Sprintf("(%s)", strings.Join(ignoredListPatterns, ")|("))),
+		allowlist:     cfg.SysctlAllowlist,
 		regexpCache:   lru.New(),
 	}
 }
@@ -78,12 +81,7 @@ func (sp *SysctlPlugin) walkSysctl(path string, fi os.FileInfo, _ error) (err er
 		return
 	}
 
-	matches, ok := sp.regexpCache.Get(path)
-	if !ok {
-		matches = sp.ignoredListRE.MatchString(path)
-		sp.regexpCache.Add(path, matches)
-	}
-	if matches == true {
+	if sp.excluded(path) {
 		return
 	}
 
@@ -105,11 +103,37 @@ func (sp *SysctlPlugin) walkSysctl(path string, fi os.FileInfo, _ error) (err er
 	return
 }
 
+// excluded returns whether a sysctl file is ignored or not allowlisted.
+func (sp *SysctlPlugin) excluded(filePath string) bool {
+	excluded, ok := sp.regexpCache.Get(filePath)
+	if !ok {
+		excluded = sp.ignoredListRE.MatchString(filePath) || !sp.allowed(sp.sysctlName(filePath))
+		sp.regexpCache.Add(filePath, excluded)
+	}
+	return excluded == true
+}
+
+// allowed returns whether a sysctl matches the allowlist.
+func (sp *SysctlPlugin) allowed(name string) bool {
+	if len(sp.allowlist) == 0 {
+		return true
+	}
+	for _, pattern := range sp.allowlist {
+		if matched, _ := path.Match(pattern, name); matched {
+			return true
+		}
+	}
+	return false
+}
+
 // reformat path into sysctl style dot separated
-func (sp *SysctlPlugin) newSysctlItem(filePath string, output []byte) SysctlItem {
+func (sp *SysctlPlugin) sysctlName(filePath string) string {
 	keyPath := strings.TrimPrefix(filePath, sp.procSysDir)
-	keyPath = strings.Replace(keyPath, "/", ".", -1)
-	return SysctlItem{keyPath, strings.TrimSpace(string(output))}
+	return strings.Replace(keyPath, "/", ".", -1)
+}
+
+func (sp *SysctlPlugin) newSysctlItem(filePath string, output []byte) SysctlItem {
+	return SysctlItem{sp.sysctlName(filePath), strings.TrimSpace(string(output))}
 }
 
 func (sp *SysctlPlugin) Sysctls() (dataset agent.PluginInventoryDataset, err error) {
@@ -77,7 +77,7 @@ func (p *SysctlSubscriberPlugin) Run() {
 				continue
 			}
 
-			if event.Op&fsnotify.Write == fsnotify.Write {
+			if event.Op&fsnotify.Write == fsnotify.Write && !p.excluded(event.Name) {
 				needsFlush = true
 				output, err := ioutil.ReadFile(event.Name)
 				if err != nil {
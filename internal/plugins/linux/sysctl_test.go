@@ -0,0 +1,68 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+// +build linux
+
+package linux
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/newrelic/infrastructure-agent/internal/agent"
+	"github.com/newrelic/infrastructure-agent/pkg/helpers/lru"
+)
+
+func TestSysctls_Allowlist(t *testing.T) {
+	// GIVEN a /proc/sys with vm, net and fs sysctls
+	dir, err := ioutil.TempDir("", "sys")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	for name, value := range map[string]string{
+		"vm/swappiness":          "60\n",
+		"vm/mmap_rnd_bits":       "28\n",
+		"net/core/somaxconn":     "4096\n",
+		"net/ipv4/ip_forward":    "1\n",
+		"fs/file-max":            "9223372036854775807\n",
+		"fs/nr_open":             "1048576\n",
+		"kernel/sched_child_run": "0\n",
+	} {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, ioutil.WriteFile(path, []byte(value), 0644))
+	}
+
+	sp := &SysctlPlugin{
+		errorsLogged:  map[string]bool{},
+		procSysDir:    dir + "/",
+		fileService:   fileService{walk: filepath.Walk, read: ioutil.ReadFile},
+		ignoredListRE: regexp.MustCompile(fmt.Sprintf("(%s)", strings.Join(ignoredListPatterns, ")|("))),
+		allowlist:     []string{"vm.*", "net.core.*", "fs.file-max"},
+		regexpCache:   lru.New(),
+	}
+
+	// WHEN the sysctls are read
+	dataset, err := sp.Sysctls()
+	require.NoError(t, err)
+
+	// THEN only the allowlisted ones that aren't ignored are reported
+	assert.Equal(t, agent.PluginInventoryDataset{
+		SysctlItem{Sysctl: "fs.file-max", Value: "9223372036854775807"},
+		SysctlItem{Sysctl: "net.core.somaxconn", Value: "4096"},
+		SysctlItem{Sysctl: "vm.swappiness", Value: "60"},
+	}, dataset)
+
+	// AND all of them are reported without allowlist
+	sp.allowlist = nil
+	sp.regexpCache = lru.New()
+	dataset, err = sp.Sysctls()
+	require.NoError(t, err)
+	assert.Len(t, dataset, 6)
+}
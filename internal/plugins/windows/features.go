@@ -0,0 +1,108 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+// +build windows
+
+package windows
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/StackExchange/wmi"
+
+	"github.com/newrelic/infrastructure-agent/internal/agent"
+	"github.com/newrelic/infrastructure-agent/pkg/config"
+	"github.com/newrelic/infrastructure-agent/pkg/entity"
+	"github.com/newrelic/infrastructure-agent/pkg/log"
+	"github.com/newrelic/infrastructure-agent/pkg/plugins/ids"
+)
+
+var flog = log.WithComponent("FeaturesPlugin")
+
+// FeaturesPlugin reports the server roles, role services and features installed in Windows Server.
+type FeaturesPlugin struct {
+	agent.PluginCommon
+	frequency time.Duration
+}
+
+// See https://docs.microsoft.com/en-us/windows/win32/wmisdk/win32-serverfeature
+type Win32_ServerFeature struct {
+	ID       uint32
+	ParentID uint32
+	Name     string
+}
+
+type WindowsFeature struct {
+	Name      string `json:"id"`
+	FeatureID string `json:"feature_id"`
+	Parent    string `json:"parent,omitempty"`
+}
+
+func (f WindowsFeature) SortKey() string {
+	return f.Name
+}
+
+func NewFeaturesPlugin(id ids.PluginID, ctx agent.AgentContext) agent.Plugin {
+	cfg := ctx.Config()
+	return &FeaturesPlugin{
+		PluginCommon: agent.PluginCommon{ID: id, Context: ctx},
+		frequency: config.ValidateConfigFrequencySetting(
+			cfg.WindowsFeaturesRefreshSec,
+			config.FREQ_MINIMUM_FAST_INVENTORY_SAMPLE_RATE,
+			config.FREQ_PLUGIN_WINDOWS_FEATURES,
+			cfg.DisableAllPlugins || !cfg.EnableWindowsFeaturesInventory,
+		) * time.Second,
+	}
+}
+
+func (p *FeaturesPlugin) getDataset() (agent.PluginInventoryDataset, error) {
+	var wmiResults []Win32_ServerFeature
+	wmiQuery := wmi.CreateQuery(&wmiResults, "")
+	if err := wmi.QueryNamespace(wmiQuery, &wmiResults, config.DefaultWMINamespace); err != nil {
+		return nil, fmt.Errorf("Error querying WMI: %s", err)
+	}
+	return featuresDataset(wmiResults), nil
+}
+
+// featuresDataset returns the installed features, referencing the role or feature they belong to by name.
+func featuresDataset(features []Win32_ServerFeature) agent.PluginInventoryDataset {
+	names := make(map[uint32]string, len(features))
+	for _, feature := range features {
+		names[feature.ID] = feature.Name
+	}
+	dataset := make(agent.PluginInventoryDataset, 0, len(features))
+	for _, feature := range features {
+		dataset = append(dataset, WindowsFeature{
+			Name:      feature.Name,
+			FeatureID: strconv.FormatUint(uint64(feature.ID), 10),
+			// top level features have 0 as parent
+			Parent: names[feature.ParentID],
+		})
+	}
+	sort.Sort(dataset)
+	return dataset
+}
+
+func (p *FeaturesPlugin) Run() {
+	if p.frequency <= config.FREQ_DISABLE_SAMPLING {
+		flog.Debug("Disabled.")
+		return
+	}
+
+	// Introduce some jitter to wait randomly before reporting based on frequency time
+	time.Sleep(config.JitterFrequency(p.frequency))
+
+	refreshTimer := time.NewTicker(p.frequency)
+	for {
+		// the class is only available in the Windows Server editions
+		dataset, err := p.getDataset()
+		if err != nil {
+			flog.WithError(err).Debug("features plugin can't get dataset")
+		} else {
+			p.EmitInventory(dataset, entity.NewFromNameWithoutID(p.Context.EntityKey()))
+		}
+		<-refreshTimer.C
+	}
+}
@@ -0,0 +1,32 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+// +build windows
+
+package windows
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/newrelic/infrastructure-agent/internal/agent"
+)
+
+func TestFeaturesDataset(t *testing.T) {
+	// GIVEN a role with a role service, and a top level feature
+	features := []Win32_ServerFeature{
+		{ID: 2, ParentID: 0, Name: "Web Server (IIS)"},
+		{ID: 140, ParentID: 2, Name: "Web Server"},
+		{ID: 99, ParentID: 0, Name: ".NET Framework 4.7 Features"},
+	}
+
+	// WHEN the dataset is built
+	dataset := featuresDataset(features)
+
+	// THEN the role services reference their role by name
+	assert.Equal(t, agent.PluginInventoryDataset{
+		WindowsFeature{Name: ".NET Framework 4.7 Features", FeatureID: "99"},
+		WindowsFeature{Name: "Web Server", FeatureID: "140", Parent: "Web Server (IIS)"},
+		WindowsFeature{Name: "Web Server (IIS)", FeatureID: "2"},
+	}, dataset)
+}
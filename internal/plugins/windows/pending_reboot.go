@@ -0,0 +1,111 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+// +build windows
+
+package windows
+
+import (
+	"time"
+
+	"golang.org/x/sys/windows/registry"
+
+	"github.com/newrelic/infrastructure-agent/internal/agent"
+	"github.com/newrelic/infrastructure-agent/pkg/config"
+	"github.com/newrelic/infrastructure-agent/pkg/entity"
+	"github.com/newrelic/infrastructure-agent/pkg/log"
+	"github.com/newrelic/infrastructure-agent/pkg/plugins/ids"
+)
+
+var prlog = log.WithComponent("PendingRebootPlugin")
+
+// Registry entries set by the installers that need a reboot to complete.
+const (
+	cbsRebootPendingKey       = `SOFTWARE\Microsoft\Windows\CurrentVersion\Component Based Servicing\RebootPending`
+	windowsUpdateRebootKey    = `SOFTWARE\Microsoft\Windows\CurrentVersion\WindowsUpdate\Auto Update\RebootRequired`
+	sessionManagerKey         = `SYSTEM\CurrentControlSet\Control\Session Manager`
+	pendingFileRenamesValue   = "PendingFileRenameOperations"
+	pendingFileRenames2Value  = "PendingFileRenameOperations2"
+	pendingRebootInventoryKey = "reboot"
+)
+
+// PendingRebootPlugin reports whether the host needs a reboot to complete the installation of updates.
+type PendingRebootPlugin struct {
+	agent.PluginCommon
+	frequency time.Duration
+}
+
+// PendingReboot holds the indicators of a pending reboot.
+type PendingReboot struct {
+	ID                      string `json:"id"`
+	Pending                 bool   `json:"pending"`
+	ComponentBasedServicing bool   `json:"component_based_servicing"`
+	WindowsUpdate           bool   `json:"windows_update"`
+	PendingFileRenames      bool   `json:"pending_file_rename_operations"`
+}
+
+func (r PendingReboot) SortKey() string {
+	return r.ID
+}
+
+func NewPendingRebootPlugin(id ids.PluginID, ctx agent.AgentContext) agent.Plugin {
+	cfg := ctx.Config()
+	return &PendingRebootPlugin{
+		PluginCommon: agent.PluginCommon{ID: id, Context: ctx},
+		frequency: config.ValidateConfigFrequencySetting(
+			cfg.PendingRebootRefreshSec,
+			config.FREQ_MINIMUM_FAST_INVENTORY_SAMPLE_RATE,
+			config.FREQ_PLUGIN_WINDOWS_PENDING_REBOOT,
+			cfg.DisableAllPlugins || !cfg.EnablePendingRebootInventory,
+		) * time.Second,
+	}
+}
+
+func (p *PendingRebootPlugin) getDataset() agent.PluginInventoryDataset {
+	reboot := PendingReboot{
+		ID:                      pendingRebootInventoryKey,
+		ComponentBasedServicing: registryKeyExists(cbsRebootPendingKey),
+		WindowsUpdate:           registryKeyExists(windowsUpdateRebootKey),
+		PendingFileRenames:      pendingFileRenames(),
+	}
+	reboot.Pending = reboot.ComponentBasedServicing || reboot.WindowsUpdate || reboot.PendingFileRenames
+	return agent.PluginInventoryDataset{reboot}
+}
+
+func registryKeyExists(path string) bool {
+	key, err := registry.OpenKey(registry.LOCAL_MACHINE, path, registry.QUERY_VALUE)
+	if err != nil {
+		return false
+	}
+	key.Close()
+	return true
+}
+
+// pendingFileRenames returns whether files are replaced on the next boot, as done for the files in use
+// while they are updated.
+func pendingFileRenames() bool {
+	key, err := registry.OpenKey(registry.LOCAL_MACHINE, sessionManagerKey, registry.QUERY_VALUE)
+	if err != nil {
+		prlog.WithError(err).Debug("Can't open the Session Manager registry key.")
+		return false
+	}
+	defer key.Close()
+	for _, name := range []string{pendingFileRenamesValue, pendingFileRenames2Value} {
+		if renames, _, err := key.GetStringsValue(name); err == nil && len(renames) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *PendingRebootPlugin) Run() {
+	if p.frequency <= config.FREQ_DISABLE_SAMPLING {
+		prlog.Debug("Disabled.")
+		return
+	}
+
+	refreshTimer := time.NewTicker(p.frequency)
+	for {
+		p.EmitInventory(p.getDataset(), entity.NewFromNameWithoutID(p.Context.EntityKey()))
+		<-refreshTimer.C
+	}
+}
@@ -7,6 +7,8 @@ package nrwin
 
 import (
 	"fmt"
+	"syscall"
+	"unsafe"
 
 	"github.com/newrelic/infrastructure-agent/internal/windows/api"
 )
@@ -82,6 +84,47 @@ func (pdh *PdhPoll) Poll() (map[string]float64, error) {
 	return counters, nil
 }
 
+// PollInstances polls metrics whose paths use a wildcard as instance name (e.g. "\\GPU Engine(*)\\Running Time")
+// and returns, for each metric, the values indexed by instance name.
+func (pdh *PdhPoll) PollInstances() (map[string]map[string]float64, error) {
+	ret := winapi.PdhCollectQueryData(pdh.queryHandler)
+	if ret != winapi.ERROR_SUCCESS {
+		return nil, fmt.Errorf("collect query returned with %#v", ret)
+	}
+
+	counters := map[string]map[string]float64{}
+	var emptyBuf [1]winapi.PDH_FMT_COUNTERVALUE_ITEM_DOUBLE
+	for i, cHandle := range pdh.counterHandles {
+		var bufSize, bufCount uint32
+		// the first call returns the size of the buffer, in bytes, required to store the items and their names
+		ret = winapi.PdhGetFormattedCounterArrayDouble(cHandle, &bufSize, &bufCount, &emptyBuf[0])
+		if ret != winapi.PDH_MORE_DATA {
+			if pdh.debugLog != nil {
+				pdh.debugLog("Error getting counter array size for %s (error %#v)", pdh.metrics[i], ret)
+			}
+			continue
+		}
+		items := make([]winapi.PDH_FMT_COUNTERVALUE_ITEM_DOUBLE, bufSize/uint32(unsafe.Sizeof(emptyBuf[0]))+1)
+		ret = winapi.PdhGetFormattedCounterArrayDouble(cHandle, &bufSize, &bufCount, &items[0])
+		if ret != winapi.ERROR_SUCCESS {
+			if pdh.debugLog != nil {
+				pdh.debugLog("Error getting counter array for %s (error %#v)", pdh.metrics[i], ret)
+			}
+			continue
+		}
+
+		values := make(map[string]float64, bufCount)
+		for _, item := range items[:bufCount] {
+			if item.FmtValue.CStatus != winapi.ERROR_SUCCESS {
+				continue
+			}
+			values[UTF16PtrToString(item.SzName)] = item.FmtValue.DoubleValue
+		}
+		counters[pdh.metrics[i]] = values
+	}
+	return counters, nil
+}
+
 // Close frees the associated resources a handlers for a PDH query
 func (pdh *PdhPoll) Close() error {
 	ret := winapi.PdhCloseQuery(pdh.queryHandler)
@@ -90,3 +133,15 @@ func (pdh *PdhPoll) Close() error {
 	}
 	return nil
 }
+
+// UTF16PtrToString converts a null-terminated UTF-16 string, as returned by the Windows API, to a Go string.
+func UTF16PtrToString(p *uint16) string {
+	if p == nil {
+		return ""
+	}
+	var chars []uint16
+	for ptr := unsafe.Pointer(p); *(*uint16)(ptr) != 0; ptr = unsafe.Pointer(uintptr(ptr) + unsafe.Sizeof(*p)) {
+		chars = append(chars, *(*uint16)(ptr))
+	}
+	return syscall.UTF16ToString(chars)
+}
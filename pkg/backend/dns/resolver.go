@@ -0,0 +1,148 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+// Package dns provides a host name resolver for the agent outbound connections, supporting static
+// host overrides, caching and custom DNS servers.
+package dns
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/newrelic/infrastructure-agent/pkg/log"
+)
+
+const defaultDNSPort = "53"
+
+var (
+	rlog    = log.WithComponent("DNSResolver")
+	timeNow = time.Now
+)
+
+// Config of the resolver.
+type Config struct {
+	// Overrides maps host names to static IP addresses, skipping their resolution.
+	Overrides map[string]string
+	// CacheTTL is the time a resolved host name is cached. Zero or negative values disable caching.
+	CacheTTL time.Duration
+	// Servers are the "host[:port]" addresses of the DNS servers to query. If empty, the system
+	// resolver is used.
+	Servers []string
+}
+
+// IsEmpty returns true if the configuration doesn't modify the system resolution.
+func (c Config) IsEmpty() bool {
+	return len(c.Overrides) == 0 && c.CacheTTL <= 0 && len(c.Servers) == 0
+}
+
+// Resolver resolves host names for the agent outbound connections.
+type Resolver struct {
+	overrides map[string]string
+	ttl       time.Duration
+	resolver  *net.Resolver
+	lock      sync.Mutex
+	cache     map[string]cacheEntry
+}
+
+type cacheEntry struct {
+	addrs   []string
+	expires time.Time
+}
+
+// NewResolver creates a Resolver for the given configuration.
+func NewResolver(cfg Config) *Resolver {
+	r := &Resolver{
+		overrides: cfg.Overrides,
+		ttl:       cfg.CacheTTL,
+		resolver:  net.DefaultResolver,
+		cache:     map[string]cacheEntry{},
+	}
+	if len(cfg.Servers) > 0 {
+		r.resolver = serversResolver(cfg.Servers)
+	}
+	return r
+}
+
+// serversResolver returns a resolver that queries the passed DNS servers in a round-robin fashion.
+func serversResolver(servers []string) *net.Resolver {
+	addrs := make([]string, 0, len(servers))
+	for _, server := range servers {
+		if _, _, err := net.SplitHostPort(server); err != nil {
+			server = net.JoinHostPort(server, defaultDNSPort)
+		}
+		addrs = append(addrs, server)
+	}
+	var next uint32
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			server := addrs[int(atomic.AddUint32(&next, 1)-1)%len(addrs)]
+			var d net.Dialer
+			return d.DialContext(ctx, network, server)
+		},
+	}
+}
+
+// LookupHost returns the addresses of the passed host name.
+func (r *Resolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	if net.ParseIP(host) != nil {
+		return []string{host}, nil
+	}
+	if addr, ok := r.overrides[host]; ok {
+		return []string{addr}, nil
+	}
+
+	if r.ttl > 0 {
+		r.lock.Lock()
+		entry, ok := r.cache[host]
+		r.lock.Unlock()
+		if ok && timeNow().Before(entry.expires) {
+			return entry.addrs, nil
+		}
+	}
+
+	addrs, err := r.resolver.LookupHost(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+
+	if r.ttl > 0 {
+		r.lock.Lock()
+		r.cache[host] = cacheEntry{addrs: addrs, expires: timeNow().Add(r.ttl)}
+		r.lock.Unlock()
+	}
+	return addrs, nil
+}
+
+// DialContext wraps the passed dialer so the host names are resolved by the Resolver. The
+// resolved addresses are tried in order until a connection is established.
+func (r *Resolver) DialContext(dialer *net.Dialer) func(ctx context.Context, network, address string) (net.Conn, error) {
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		host, port, err := net.SplitHostPort(address)
+		if err != nil {
+			return dialer.DialContext(ctx, network, address)
+		}
+
+		addrs, err := r.LookupHost(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		if len(addrs) == 0 {
+			return nil, fmt.Errorf("no addresses found for host %q", host)
+		}
+
+		var conn net.Conn
+		for _, addr := range addrs {
+			conn, err = dialer.DialContext(ctx, network, net.JoinHostPort(addr, port))
+			if err == nil {
+				return conn, nil
+			}
+			rlog.WithError(err).WithField("host", host).WithField("address", addr).
+				Debug("Cannot connect to resolved address.")
+		}
+		return nil, err
+	}
+}
@@ -0,0 +1,72 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package dns
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolver_Overrides(t *testing.T) {
+	r := NewResolver(Config{Overrides: map[string]string{"collector.newrelic.com": "10.0.0.1"}})
+
+	addrs, err := r.LookupHost(context.Background(), "collector.newrelic.com")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"10.0.0.1"}, addrs)
+
+	// IP addresses are not resolved
+	addrs, err = r.LookupHost(context.Background(), "127.0.0.1")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"127.0.0.1"}, addrs)
+}
+
+func TestResolver_Cache(t *testing.T) {
+	now := time.Now()
+	timeNow = func() time.Time { return now }
+	defer func() { timeNow = time.Now }()
+
+	// GIVEN a resolver whose host names have been cached
+	r := NewResolver(Config{CacheTTL: time.Minute})
+	r.cache["cached.invalid"] = cacheEntry{addrs: []string{"10.0.0.2"}, expires: now.Add(time.Second)}
+
+	// THEN the cached addresses are returned
+	addrs, err := r.LookupHost(context.Background(), "cached.invalid")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"10.0.0.2"}, addrs)
+
+	// AND they are resolved again once the TTL expires
+	now = now.Add(2 * time.Second)
+	_, err = r.LookupHost(context.Background(), "cached.invalid")
+	assert.Error(t, err)
+}
+
+func TestResolver_DialContext(t *testing.T) {
+	// GIVEN a listening server
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer l.Close()
+	_, port, err := net.SplitHostPort(l.Addr().String())
+	require.NoError(t, err)
+
+	// AND a resolver overriding a host name with the server address
+	r := NewResolver(Config{Overrides: map[string]string{"my-collector": "127.0.0.1"}})
+
+	// WHEN dialing the host name
+	conn, err := r.DialContext(&net.Dialer{Timeout: time.Second})(context.Background(), "tcp", "my-collector:"+port)
+
+	// THEN the connection is established with the overridden address
+	require.NoError(t, err)
+	defer conn.Close()
+	assert.Equal(t, l.Addr().String(), conn.RemoteAddr().String())
+}
+
+func TestConfig_IsEmpty(t *testing.T) {
+	assert.True(t, Config{}.IsEmpty())
+	assert.False(t, Config{CacheTTL: time.Second}.IsEmpty())
+	assert.False(t, Config{Servers: []string{"8.8.8.8"}}.IsEmpty())
+}
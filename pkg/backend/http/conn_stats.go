@@ -0,0 +1,183 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package http
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptrace"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// ConnStats aggregates, per endpoint, how the outbound requests got their connections.
+type ConnStats struct {
+	lock      sync.Mutex
+	endpoints map[string]*endpointConnStats
+}
+
+type endpointConnStats struct {
+	requests       uint64
+	newConns       uint64
+	reusedConns    uint64
+	dnsLookups     uint64
+	connectTime    time.Duration
+	handshakes     uint64
+	resumed        uint64
+	handshakeErrs  uint64
+	handshakeTime  time.Duration
+	lastNewConnAt  time.Time
+	lastHandshaked time.Duration
+}
+
+// EndpointConnStatus is the connection usage of an endpoint, as served by the status server.
+type EndpointConnStatus struct {
+	Endpoint           string     `json:"endpoint"`
+	Requests           uint64     `json:"requests"`
+	NewConnections     uint64     `json:"newConnections"`
+	ReusedConnections  uint64     `json:"reusedConnections"`
+	DNSLookups         uint64     `json:"dnsLookups"`
+	AvgConnectMs       float64    `json:"avgConnectMs"`
+	TLSHandshakes      uint64     `json:"tlsHandshakes"`
+	TLSResumedSessions uint64     `json:"tlsResumedSessions"`
+	TLSHandshakeErrors uint64     `json:"tlsHandshakeErrors"`
+	AvgTLSHandshakeMs  float64    `json:"avgTlsHandshakeMs"`
+	LastNewConnection  *time.Time `json:"lastNewConnection,omitempty"`
+}
+
+// NewConnStats creates an empty set of connection stats.
+func NewConnStats() *ConnStats {
+	return &ConnStats{endpoints: map[string]*endpointConnStats{}}
+}
+
+// Status returns the connection usage of each endpoint, sorted by endpoint.
+func (s *ConnStats) Status() []EndpointConnStatus {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	status := make([]EndpointConnStatus, 0, len(s.endpoints))
+	for endpoint, e := range s.endpoints {
+		st := EndpointConnStatus{
+			Endpoint:           endpoint,
+			Requests:           e.requests,
+			NewConnections:     e.newConns,
+			ReusedConnections:  e.reusedConns,
+			DNSLookups:         e.dnsLookups,
+			TLSHandshakes:      e.handshakes,
+			TLSResumedSessions: e.resumed,
+			TLSHandshakeErrors: e.handshakeErrs,
+		}
+		if e.newConns > 0 {
+			st.AvgConnectMs = durationMs(e.connectTime) / float64(e.newConns)
+			lastNewConn := e.lastNewConnAt
+			st.LastNewConnection = &lastNewConn
+		}
+		if e.handshakes > 0 {
+			st.AvgTLSHandshakeMs = durationMs(e.handshakeTime) / float64(e.handshakes)
+		}
+		status = append(status, st)
+	}
+	sort.Slice(status, func(i, j int) bool {
+		return status[i].Endpoint < status[j].Endpoint
+	})
+	return status
+}
+
+func (s *ConnStats) update(endpoint string, fn func(e *endpointConnStats)) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	e, ok := s.endpoints[endpoint]
+	if !ok {
+		e = &endpointConnStats{}
+		s.endpoints[endpoint] = e
+	}
+	fn(e)
+}
+
+// tracedTransport records in the stats the connections used by each request. In audit mode, it also logs
+// the new connections, as they are the ones causing outbound traffic churn.
+type tracedTransport struct {
+	rt    http.RoundTripper
+	stats *ConnStats
+	audit bool
+}
+
+func (t *tracedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	endpoint := req.URL.Host
+	var connectStart, handshakeStart time.Time
+
+	trace := &httptrace.ClientTrace{
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			t.stats.update(endpoint, func(e *endpointConnStats) { e.dnsLookups++ })
+		},
+		ConnectStart: func(string, string) {
+			connectStart = time.Now()
+		},
+		ConnectDone: func(_, _ string, err error) {
+			if err == nil && !connectStart.IsZero() {
+				elapsed := time.Since(connectStart)
+				t.stats.update(endpoint, func(e *endpointConnStats) { e.connectTime += elapsed })
+			}
+		},
+		TLSHandshakeStart: func() {
+			handshakeStart = time.Now()
+		},
+		TLSHandshakeDone: func(state tls.ConnectionState, err error) {
+			elapsed := time.Since(handshakeStart)
+			t.stats.update(endpoint, func(e *endpointConnStats) {
+				e.handshakes++
+				e.handshakeTime += elapsed
+				e.lastHandshaked = elapsed
+				if err != nil {
+					e.handshakeErrs++
+				} else if state.DidResume {
+					e.resumed++
+				}
+			})
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			t.stats.update(endpoint, func(e *endpointConnStats) {
+				e.requests++
+				if info.Reused {
+					e.reusedConns++
+					return
+				}
+				e.newConns++
+				e.lastNewConnAt = time.Now()
+			})
+			if t.audit && !info.Reused {
+				t.logNewConnection(endpoint, info)
+			}
+		},
+	}
+	return t.rt.RoundTrip(req.WithContext(httptrace.WithClientTrace(req.Context(), trace)))
+}
+
+func (t *tracedTransport) logNewConnection(endpoint string, info httptrace.GotConnInfo) {
+	t.stats.lock.Lock()
+	e := *t.stats.endpoints[endpoint]
+	t.stats.lock.Unlock()
+
+	fields := logrus.Fields{
+		"endpoint":          endpoint,
+		"newConnections":    e.newConns,
+		"reusedConnections": e.reusedConns,
+		"tlsResumed":        e.resumed,
+	}
+	if info.Conn != nil {
+		fields["localAddr"] = info.Conn.LocalAddr().String()
+		fields["remoteAddr"] = info.Conn.RemoteAddr().String()
+	}
+	if e.lastHandshaked > 0 {
+		fields["tlsHandshakeMs"] = durationMs(e.lastHandshaked)
+	}
+	plog.WithFields(fields).Info("Opened new outbound connection.")
+}
+
+func durationMs(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
@@ -0,0 +1,72 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package http
+
+import (
+	"crypto/tls"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/newrelic/infrastructure-agent/pkg/config"
+)
+
+func TestTracedTransport_ConnectionReuse(t *testing.T) {
+	// GIVEN a TLS endpoint and a traced transport caching the TLS sessions
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+	transport := server.Client().Transport.(*http.Transport)
+	transport.TLSClientConfig.ClientSessionCache = tls.NewLRUClientSessionCache(tlsSessionCacheSize)
+	traced := &tracedTransport{rt: transport, stats: NewConnStats(), audit: true}
+	client := GetHttpClient(ClientTimeout, traced)
+
+	get := func() {
+		resp, err := client.Get(server.URL)
+		require.NoError(t, err)
+		_, _ = ioutil.ReadAll(resp.Body)
+		require.NoError(t, resp.Body.Close())
+	}
+
+	// WHEN some requests are submitted through the same connection
+	for i := 0; i < 3; i++ {
+		get()
+	}
+	// AND the connection is closed before the next one
+	transport.CloseIdleConnections()
+	get()
+
+	// THEN the new and reused connections are reported for the endpoint
+	u, err := url.Parse(server.URL)
+	require.NoError(t, err)
+	status := traced.stats.Status()
+	require.Len(t, status, 1)
+	assert.Equal(t, u.Host, status[0].Endpoint)
+	assert.EqualValues(t, 4, status[0].Requests)
+	assert.EqualValues(t, 2, status[0].NewConnections)
+	assert.EqualValues(t, 2, status[0].ReusedConnections)
+	assert.EqualValues(t, 2, status[0].TLSHandshakes)
+	assert.NotNil(t, status[0].LastNewConnection)
+	// AND the second connection resumed the TLS session of the first one
+	assert.EqualValues(t, 1, status[0].TLSResumedSessions)
+	assert.Zero(t, status[0].TLSHandshakeErrors)
+}
+
+func TestGetSharedTransport(t *testing.T) {
+	cfg := &config.Config{}
+
+	transport := GetSharedTransport(cfg)
+
+	assert.Same(t, transport, GetSharedTransport(cfg))
+	assert.NotSame(t, transport, GetSharedTransport(&config.Config{}))
+	rt, ok := transport.rt.(*http.Transport)
+	require.True(t, ok)
+	assert.NotNil(t, rt.TLSClientConfig.ClientSessionCache)
+	assert.Equal(t, maxIdleConnsPerHost, rt.MaxIdleConnsPerHost)
+}
@@ -12,6 +12,7 @@ const (
 	LicenseHeader       = "X-License-Key"
 	EntityKeyHeader     = "X-NRI-Entity-Key" // populated with the agent-id for the backend deny mechanism
 	AgentEntityIdHeader = "X-NRI-Agent-Entity-Id"
+	PayloadIDHeader     = "X-NRI-Payload-Id" // kept when a post is sent again, so the backend can discard duplicates
 
 	TrialStatusHeader = "X-Trial-Status"
 	TrialStarting     = "starting"
@@ -0,0 +1,136 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package http
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/newrelic/infrastructure-agent/pkg/config"
+	"github.com/newrelic/infrastructure-agent/pkg/log"
+)
+
+var clog = log.WithComponent("PayloadCompression")
+
+// zstdTransport compresses the inventory, event and dimensional metric payloads with zstd. The senders leave them
+// uncompressed, but the gzip ones are re-encoded too. The endpoints rejecting zstd with an unsupported media type
+// response get the payloads in gzip from then on.
+type zstdTransport struct {
+	rt        http.RoundTripper
+	encoder   *zstd.Encoder // safe for concurrent EncodeAll calls
+	gzipLevel int
+
+	lock          sync.Mutex
+	gzipEndpoints map[string]bool
+}
+
+// newZstdTransport wraps the transport with the zstd compression, when configured.
+func newZstdTransport(rt http.RoundTripper, cfg *config.Config) http.RoundTripper {
+	if cfg.PayloadCompression != config.PayloadCompressionZstd {
+		return rt
+	}
+	encoder, err := zstd.NewWriter(nil)
+	if err != nil {
+		clog.WithError(err).Warn("Can't create the zstd encoder, payloads are sent as the senders encode them.")
+		return rt
+	}
+	return &zstdTransport{
+		rt:            rt,
+		encoder:       encoder,
+		gzipLevel:     cfg.PayloadCompressionLevel,
+		gzipEndpoints: map[string]bool{},
+	}
+}
+
+func (t *zstdTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	encoding := req.Header.Get("Content-Encoding")
+	if req.Body == nil || egressPayloadType(req) == "" || (encoding != "" && encoding != "gzip") {
+		return t.rt.RoundTrip(req)
+	}
+
+	body, err := ioutil.ReadAll(req.Body)
+	_ = req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	payload := body
+	if encoding == "gzip" {
+		if payload, err = gunzip(body); err != nil {
+			return nil, err
+		}
+	}
+
+	endpoint := req.URL.Host + req.URL.Path
+	if !t.acceptsZstd(endpoint) {
+		return t.sendGzip(req, encoding, body, payload)
+	}
+	resp, err := t.rt.RoundTrip(withBody(req, "zstd", t.encoder.EncodeAll(payload, nil)))
+	if err != nil || resp.StatusCode != http.StatusUnsupportedMediaType {
+		return resp, err
+	}
+
+	_, _ = io.Copy(ioutil.Discard, resp.Body)
+	_ = resp.Body.Close()
+	t.lock.Lock()
+	t.gzipEndpoints[endpoint] = true
+	t.lock.Unlock()
+	clog.WithField("endpoint", endpoint).Warn("Endpoint doesn't accept zstd payloads, falling back to gzip.")
+	return t.sendGzip(req, encoding, body, payload)
+}
+
+func (t *zstdTransport) acceptsZstd(endpoint string) bool {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	return !t.gzipEndpoints[endpoint]
+}
+
+// sendGzip sends the payload as the sender encoded it, or gzip compressed when the sender left it uncompressed.
+func (t *zstdTransport) sendGzip(req *http.Request, encoding string, body, payload []byte) (*http.Response, error) {
+	if encoding == "gzip" || t.gzipLevel <= gzip.NoCompression {
+		return t.rt.RoundTrip(withBody(req, encoding, body))
+	}
+	var buf bytes.Buffer
+	gzipWriter, err := gzip.NewWriterLevel(&buf, t.gzipLevel)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = gzipWriter.Write(payload); err != nil {
+		return nil, err
+	}
+	if err = gzipWriter.Close(); err != nil {
+		return nil, err
+	}
+	return t.rt.RoundTrip(withBody(req, "gzip", buf.Bytes()))
+}
+
+func gunzip(body []byte) ([]byte, error) {
+	reader, err := gzip.NewReader(bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return ioutil.ReadAll(reader)
+}
+
+// withBody returns a copy of the request with the body in the passed encoding, as the round trippers can't modify
+// the requests.
+func withBody(req *http.Request, encoding string, body []byte) *http.Request {
+	clone := req.Clone(req.Context())
+	clone.Body = ioutil.NopCloser(bytes.NewReader(body))
+	clone.GetBody = func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewReader(body)), nil
+	}
+	clone.ContentLength = int64(len(body))
+	if encoding == "" {
+		clone.Header.Del("Content-Encoding")
+	} else {
+		clone.Header.Set("Content-Encoding", encoding)
+	}
+	return clone
+}
@@ -0,0 +1,135 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package http
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/newrelic/infrastructure-agent/pkg/config"
+)
+
+var zstdMagic = []byte{0x28, 0xB5, 0x2F, 0xFD}
+
+func unzstd(t *testing.T, body []byte) string {
+	decoder, err := zstd.NewReader(nil)
+	require.NoError(t, err)
+	defer decoder.Close()
+	decompressed, err := decoder.DecodeAll(body, nil)
+	require.NoError(t, err)
+	return string(decompressed)
+}
+
+type receivedRequest struct {
+	path     string
+	encoding string
+	body     []byte
+}
+
+// recordingTransport records the requests, replying with the status of the endpoints not accepting zstd.
+type recordingTransport struct {
+	requests       []receivedRequest
+	rejectZstdPath string
+}
+
+func (t *recordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		return nil, err
+	}
+	encoding := req.Header.Get("Content-Encoding")
+	t.requests = append(t.requests, receivedRequest{path: req.URL.Path, encoding: encoding, body: body})
+	status := http.StatusAccepted
+	if encoding == "zstd" && req.URL.Path == t.rejectZstdPath {
+		status = http.StatusUnsupportedMediaType
+	}
+	return &http.Response{StatusCode: status, Body: ioutil.NopCloser(bytes.NewReader(nil))}, nil
+}
+
+func gzipped(t *testing.T, payload string) []byte {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	_, err := w.Write([]byte(payload))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	return buf.Bytes()
+}
+
+func postPayload(t *testing.T, rt http.RoundTripper, url, encoding string, body []byte) *http.Response {
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	require.NoError(t, err)
+	if encoding != "" {
+		req.Header.Set("Content-Encoding", encoding)
+	}
+	resp, err := rt.RoundTrip(req)
+	require.NoError(t, err)
+	return resp
+}
+
+func TestZstdTransport(t *testing.T) {
+	rt := &recordingTransport{}
+	transport := newZstdTransport(rt, &config.Config{
+		PayloadCompression:      config.PayloadCompressionZstd,
+		PayloadCompressionLevel: gzip.BestSpeed,
+	})
+	payload := `[{"eventType":"SystemSample","cpuPercent":12.5}]`
+
+	// WHEN the payloads are sent uncompressed or gzip compressed
+	postPayload(t, transport, "https://infra-api.newrelic.com/inventory/deltas", "", []byte(payload))
+	postPayload(t, transport, "https://metric-api.newrelic.com/metric/v1/infra", "gzip", gzipped(t, payload))
+
+	// THEN they are sent compressed with zstd
+	require.Len(t, rt.requests, 2)
+	for _, req := range rt.requests {
+		assert.Equal(t, "zstd", req.encoding)
+		assert.Equal(t, zstdMagic, req.body[:4])
+		assert.Equal(t, payload, unzstd(t, req.body))
+	}
+
+	// AND the requests that aren't payload submissions are left as they are
+	postPayload(t, transport, "https://infra-api.newrelic.com/identity/v1/connect", "gzip", gzipped(t, "{}"))
+	assert.Equal(t, "gzip", rt.requests[2].encoding)
+}
+
+func TestZstdTransport_FallsBackToGzip(t *testing.T) {
+	// GIVEN an endpoint not accepting zstd
+	rt := &recordingTransport{rejectZstdPath: "/metrics/events/bulk"}
+	transport := newZstdTransport(rt, &config.Config{
+		PayloadCompression:      config.PayloadCompressionZstd,
+		PayloadCompressionLevel: gzip.BestSpeed,
+	})
+	payload := `[{"eventType":"ProcessSample"}]`
+
+	// WHEN a payload is sent to it
+	resp := postPayload(t, transport, "https://infra-api.newrelic.com/metrics/events/bulk", "", []byte(payload))
+
+	// THEN it's sent again in gzip
+	assert.Equal(t, http.StatusAccepted, resp.StatusCode)
+	require.Len(t, rt.requests, 2)
+	assert.Equal(t, "zstd", rt.requests[0].encoding)
+	assert.Equal(t, "gzip", rt.requests[1].encoding)
+	decompressed, err := gunzip(rt.requests[1].body)
+	require.NoError(t, err)
+	assert.Equal(t, payload, string(decompressed))
+
+	// AND the next payloads are sent in gzip right away
+	postPayload(t, transport, "https://infra-api.newrelic.com/metrics/events/bulk", "", []byte(payload))
+	require.Len(t, rt.requests, 3)
+	assert.Equal(t, "gzip", rt.requests[2].encoding)
+
+	// AND the other endpoints keep getting zstd
+	postPayload(t, transport, "https://infra-api.newrelic.com/inventory/deltas", "", []byte(payload))
+	assert.Equal(t, "zstd", rt.requests[3].encoding)
+}
+
+func TestNewZstdTransport_Gzip(t *testing.T) {
+	rt := &recordingTransport{}
+	assert.Equal(t, rt, newZstdTransport(rt, &config.Config{PayloadCompression: config.PayloadCompressionGzip}))
+}
@@ -12,6 +12,7 @@ import (
 	"os"
 	"time"
 
+	"github.com/newrelic/infrastructure-agent/pkg/backend/dns"
 	"github.com/newrelic/infrastructure-agent/pkg/config"
 	"github.com/newrelic/infrastructure-agent/pkg/log"
 	"github.com/sirupsen/logrus"
@@ -21,6 +22,11 @@ const (
 	sourceHttpsProxy = "HTTPS_PROXY environment variable"
 	sourceProxy      = "proxy configuration option"
 	sourceHttpProxy  = "HTTP_PROXY environment variable"
+
+	// the senders to the same endpoint share the transport, so the Go default (2) would close most of
+	// their connections after each submission
+	maxIdleConnsPerHost = 16
+	tlsSessionCacheSize = 64
 )
 
 // function type that can be assigned to transport.Proxy
@@ -43,16 +49,24 @@ func defaultHttpTransport(
 	certDirectory string,
 	httpTimeout time.Duration,
 	p proxyFunc,
+	resolver *dns.Resolver,
 ) *http.Transport {
-	var cfg *tls.Config
+	// sessions are cached so reconnections resume them instead of doing full handshakes
+	cfg := &tls.Config{ClientSessionCache: tls.NewLRUClientSessionCache(tlsSessionCacheSize)}
 	if certFile != "" || certDirectory != "" {
-		cfg = &tls.Config{RootCAs: getCertPool(certFile, certDirectory)}
+		cfg.RootCAs = getCertPool(certFile, certDirectory)
+	}
+	dialer := &net.Dialer{Timeout: httpTimeout, KeepAlive: 30 * time.Second}
+	dialContext := dialer.DialContext
+	if resolver != nil {
+		dialContext = resolver.DialContext(dialer)
 	}
 	// go default Http Transport
 	return &http.Transport{
 		Proxy:                 p,
-		DialContext:           (&net.Dialer{Timeout: httpTimeout, KeepAlive: 30 * time.Second}).DialContext,
+		DialContext:           dialContext,
 		MaxIdleConns:          100,
+		MaxIdleConnsPerHost:   maxIdleConnsPerHost,
 		IdleConnTimeout:       90 * time.Second,
 		TLSHandshakeTimeout:   httpTimeout,
 		ExpectContinueTimeout: 1 * time.Second,
@@ -127,6 +141,7 @@ func proxyByPriority(cfg *config.Config) proxyConfig {
 // certificates
 func BuildTransport(cfg *config.Config, timeout time.Duration) http.RoundTripper {
 	proxyConfig := proxyByPriority(cfg)
+	resolver := buildResolver(cfg)
 
 	if proxyConfig.isEmpty() {
 		return defaultHttpTransport(
@@ -134,6 +149,7 @@ func BuildTransport(cfg *config.Config, timeout time.Duration) http.RoundTripper
 			cfg.CABundleDir,
 			timeout,
 			nil, // no proxy configuration
+			resolver,
 		)
 	}
 
@@ -152,7 +168,8 @@ func BuildTransport(cfg *config.Config, timeout time.Duration) http.RoundTripper
 			cfg.CABundleFile,
 			cfg.CABundleDir,
 			timeout,
-			proxyWithError(err))
+			proxyWithError(err),
+			resolver)
 	}
 
 	if proxyConfig.forceSchema != "" && proxyConfig.forceSchema != u.Scheme {
@@ -162,7 +179,8 @@ func BuildTransport(cfg *config.Config, timeout time.Duration) http.RoundTripper
 			cfg.CABundleFile,
 			cfg.CABundleDir,
 			timeout,
-			proxyWithError(err))
+			proxyWithError(err),
+			resolver)
 	}
 
 	t := defaultHttpTransport(
@@ -170,6 +188,7 @@ func BuildTransport(cfg *config.Config, timeout time.Duration) http.RoundTripper
 		cfg.CABundleDir,
 		timeout,
 		proxy(u),
+		resolver,
 	)
 
 	if cfg.ProxyValidateCerts {
@@ -188,6 +207,19 @@ func BuildTransport(cfg *config.Config, timeout time.Duration) http.RoundTripper
 	return t
 }
 
+// buildResolver returns nil if the configuration doesn't modify the system host names resolution.
+func buildResolver(cfg *config.Config) *dns.Resolver {
+	dnsCfg := dns.Config{
+		Overrides: cfg.DNSOverrides,
+		CacheTTL:  time.Duration(cfg.DNSCacheTTLSec) * time.Second,
+		Servers:   cfg.DNSServers,
+	}
+	if dnsCfg.IsEmpty() {
+		return nil
+	}
+	return dns.NewResolver(dnsCfg)
+}
+
 func hasValidScheme(s string) bool {
 	return s == "http" || s == "https" || s == "socks5"
 }
@@ -0,0 +1,184 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package http
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/newrelic/infrastructure-agent/pkg/config"
+	"github.com/newrelic/infrastructure-agent/pkg/log"
+)
+
+var rlog = log.WithComponent("EgressRateLimit")
+
+// tokenBucket is refilled at rate tokens per second, holding up to one second of them. Reservations larger than
+// the available tokens are granted in debt, delaying the following ones, so payloads larger than the bucket are
+// sent too.
+type tokenBucket struct {
+	rate float64
+	now  func() time.Time
+
+	lock   sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rate float64) *tokenBucket {
+	b := &tokenBucket{
+		rate: rate,
+		now:  time.Now,
+	}
+	b.tokens = b.capacity()
+	b.last = b.now()
+	return b
+}
+
+func (b *tokenBucket) capacity() float64 {
+	return math.Max(b.rate, 1)
+}
+
+// reserve takes n tokens, returning how long to wait until they are available.
+func (b *tokenBucket) reserve(n float64) time.Duration {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	now := b.now()
+	b.tokens = math.Min(b.capacity(), b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+	b.tokens -= n
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+// cancel returns the tokens of a reservation that wasn't used.
+func (b *tokenBucket) cancel(n float64) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.tokens = math.Min(b.capacity(), b.tokens+n)
+}
+
+// rateLimiter limits the requests and bytes sent per second.
+type rateLimiter struct {
+	requests *tokenBucket
+	bytes    *tokenBucket
+}
+
+func newRateLimiter(limit config.RateLimit) *rateLimiter {
+	l := &rateLimiter{}
+	if limit.RequestsPerSec > 0 {
+		l.requests = newTokenBucket(limit.RequestsPerSec)
+	}
+	if limit.BytesPerSec > 0 {
+		l.bytes = newTokenBucket(limit.BytesPerSec)
+	}
+	return l
+}
+
+// reserve returns how long a request of the passed size has to wait, and a function cancelling the reservation.
+func (l *rateLimiter) reserve(size int64) (delay time.Duration, cancel func()) {
+	var buckets []*tokenBucket
+	var tokens []float64
+	if l.requests != nil {
+		buckets, tokens = append(buckets, l.requests), append(tokens, 1)
+	}
+	if l.bytes != nil && size > 0 {
+		buckets, tokens = append(buckets, l.bytes), append(tokens, float64(size))
+	}
+	for i, b := range buckets {
+		if d := b.reserve(tokens[i]); d > delay {
+			delay = d
+		}
+	}
+	return delay, func() {
+		for i, b := range buckets {
+			b.cancel(tokens[i])
+		}
+	}
+}
+
+// rateLimitedTransport delays the requests exceeding the egress rate limit of their payload type, or the one of
+// all the payloads.
+type rateLimitedTransport struct {
+	rt       http.RoundTripper
+	limiters map[string]*rateLimiter
+}
+
+// newRateLimitedTransport wraps the transport with the configured rate limits, if any.
+func newRateLimitedTransport(rt http.RoundTripper, limits map[string]config.RateLimit) http.RoundTripper {
+	if len(limits) == 0 {
+		return rt
+	}
+	limiters := make(map[string]*rateLimiter, len(limits))
+	for payloadType, limit := range limits {
+		limiters[payloadType] = newRateLimiter(limit)
+	}
+	return &rateLimitedTransport{rt: rt, limiters: limiters}
+}
+
+func (t *rateLimitedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	payloadType := egressPayloadType(req)
+	if payloadType == "" {
+		return t.rt.RoundTrip(req)
+	}
+	if err := t.wait(req.Context(), payloadType, req.ContentLength); err != nil {
+		if req.Body != nil {
+			_ = req.Body.Close()
+		}
+		return nil, err
+	}
+	return t.rt.RoundTrip(req)
+}
+
+// wait blocks until the request can be sent according to the limits of its payload type and of all the payloads.
+func (t *rateLimitedTransport) wait(ctx context.Context, payloadType string, size int64) error {
+	var delay time.Duration
+	var cancels []func()
+	for _, name := range []string{payloadType, config.EgressAll} {
+		if limiter, ok := t.limiters[name]; ok {
+			d, cancel := limiter.reserve(size)
+			if d > delay {
+				delay = d
+			}
+			cancels = append(cancels, cancel)
+		}
+	}
+	if delay <= 0 {
+		return nil
+	}
+
+	rlog.WithField("payloadType", payloadType).WithField("delay", delay).Debug("Egress rate limit exceeded, delaying submission.")
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		for _, cancel := range cancels {
+			cancel()
+		}
+		return ctx.Err()
+	}
+}
+
+// egressPayloadType returns the payload type submitted by the request, or empty for the requests that aren't
+// limited, like the agent connection or the command channel ones.
+func egressPayloadType(req *http.Request) string {
+	path := req.URL.Path
+	switch {
+	case strings.HasSuffix(path, "/events/bulk"):
+		return config.EgressEvents
+	case strings.Contains(path, "/metric/v1"):
+		return config.EgressMetrics
+	case strings.Contains(path, "/deltas"):
+		return config.EgressInventory
+	}
+	return ""
+}
@@ -0,0 +1,111 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package http
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/newrelic/infrastructure-agent/pkg/config"
+)
+
+func TestTokenBucket_Reserve(t *testing.T) {
+	now := time.Now()
+	b := newTokenBucket(100)
+	b.now = func() time.Time { return now }
+	b.last = now
+
+	// GIVEN a bucket allowing a burst of one second
+	assert.Equal(t, time.Duration(0), b.reserve(60))
+	assert.Equal(t, time.Duration(0), b.reserve(40))
+
+	// WHEN it's exhausted, THEN the reservations wait for the tokens to be refilled
+	assert.Equal(t, 500*time.Millisecond, b.reserve(50))
+	assert.Equal(t, 1500*time.Millisecond, b.reserve(100))
+
+	// AND the refilled tokens pay the debt
+	now = now.Add(1500 * time.Millisecond)
+	assert.Equal(t, 10*time.Millisecond, b.reserve(1))
+}
+
+func TestTokenBucket_ReserveLargerThanCapacity(t *testing.T) {
+	now := time.Now()
+	b := newTokenBucket(10)
+	b.now = func() time.Time { return now }
+	b.last = now
+
+	assert.Equal(t, 2*time.Second, b.reserve(30))
+	b.cancel(30)
+	assert.Equal(t, time.Duration(0), b.reserve(10))
+}
+
+type countingTransport struct {
+	requests []string
+}
+
+func (t *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.requests = append(t.requests, req.URL.Path)
+	return &http.Response{StatusCode: http.StatusAccepted}, nil
+}
+
+func TestRateLimitedTransport(t *testing.T) {
+	// GIVEN a limit of one inventory request per second
+	rt := &countingTransport{}
+	transport := newRateLimitedTransport(rt, map[string]config.RateLimit{
+		config.EgressInventory: {RequestsPerSec: 1},
+	})
+	post := func(url string) error {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader([]byte("{}")))
+		require.NoError(t, err)
+		_, err = transport.RoundTrip(req.WithContext(ctx))
+		return err
+	}
+
+	// WHEN two inventory requests are sent within the same second
+	assert.NoError(t, post("https://infra-api.newrelic.com/inventory/deltas"))
+	err := post("https://infra-api.newrelic.com/inventory/deltas")
+
+	// THEN the second one waits until its deadline is exceeded
+	assert.Equal(t, context.DeadlineExceeded, err)
+
+	// AND the requests of other payload types are not limited
+	assert.NoError(t, post("https://infra-api.newrelic.com/metrics/events/bulk"))
+	assert.NoError(t, post("https://infra-api.newrelic.com/identity/v1/connect"))
+	assert.Equal(t, []string{"/inventory/deltas", "/metrics/events/bulk", "/identity/v1/connect"}, rt.requests)
+}
+
+func TestRateLimitedTransport_AllPayloads(t *testing.T) {
+	// GIVEN a limit of bytes per second for all the payloads
+	rt := &countingTransport{}
+	transport := newRateLimitedTransport(rt, map[string]config.RateLimit{
+		config.EgressAll: {BytesPerSec: 10},
+	})
+	post := func(url string, body string) error {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader([]byte(body)))
+		require.NoError(t, err)
+		_, err = transport.RoundTrip(req.WithContext(ctx))
+		return err
+	}
+
+	// WHEN the payloads of different types exceed it
+	assert.NoError(t, post("https://metric-api.newrelic.com/metric/v1/infra", "0123456789"))
+
+	// THEN the next submission waits
+	assert.Equal(t, context.DeadlineExceeded, post("https://infra-api.newrelic.com/metrics/events/bulk", "{}"))
+	assert.Len(t, rt.requests, 1)
+}
+
+func TestNewRateLimitedTransport_NoLimits(t *testing.T) {
+	rt := &countingTransport{}
+	assert.Equal(t, rt, newRateLimitedTransport(rt, nil))
+}
@@ -0,0 +1,43 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package http
+
+import (
+	"sync"
+
+	"github.com/newrelic/infrastructure-agent/pkg/config"
+)
+
+// SharedTransport is the outbound transport of all the agent senders, so they share the pool of
+// connections, the DNS cache and the TLS sessions instead of opening their own.
+type SharedTransport struct {
+	tracedTransport
+}
+
+var shared = struct {
+	sync.Mutex
+	cfg       *config.Config
+	transport *SharedTransport
+}{}
+
+// GetSharedTransport returns the transport built for the given configuration, which is only built on
+// the first invocation.
+func GetSharedTransport(cfg *config.Config) *SharedTransport {
+	shared.Lock()
+	defer shared.Unlock()
+
+	if shared.transport == nil || shared.cfg != cfg {
+		shared.cfg = cfg
+		shared.transport = &SharedTransport{tracedTransport{
+			rt:    newZstdTransport(newRateLimitedTransport(BuildTransport(cfg, ClientTimeout), cfg.EgressRateLimits), cfg),
+			stats: NewConnStats(),
+			audit: cfg.ConnectionAuditEnabled,
+		}}
+	}
+	return shared.transport
+}
+
+// Stats returns the connections usage of the transport.
+func (t *SharedTransport) Stats() *ConnStats {
+	return t.stats
+}
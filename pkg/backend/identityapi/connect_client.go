@@ -33,8 +33,8 @@ var (
 var ilog = log.WithComponent("IdentityConnectClient")
 
 type IdentityConnectClient interface {
-	Connect(fingerprint fingerprint.Fingerprint) (entity.Identity, backendhttp.RetryPolicy, error)
-	ConnectUpdate(entity.Identity, fingerprint.Fingerprint) (backendhttp.RetryPolicy, entity.Identity, error)
+	Connect(fingerprint fingerprint.Fingerprint) (ConnectResult, backendhttp.RetryPolicy, error)
+	ConnectUpdate(entity.Identity, fingerprint.Fingerprint) (backendhttp.RetryPolicy, ConnectResult, error)
 	Disconnect(entityID entity.ID, reason DisconnectReason) error
 }
 
@@ -55,7 +55,16 @@ type postConnectBody struct {
 }
 
 type postConnectResponse struct {
-	Identity IdentityResponse `json:"identity"`
+	Identity IdentityResponse  `json:"identity"`
+	Tags     map[string]string `json:"tags,omitempty"`
+}
+
+// ConnectResult is the outcome of a successful connect or connect update step.
+type ConnectResult struct {
+	Identity entity.Identity
+	// Tags assigned by the backend to the agent entity (e.g. its workload or cost center), to be
+	// added to the data it submits from then on.
+	Tags map[string]string
 }
 
 type IdentityResponse struct {
@@ -71,6 +80,13 @@ func (r *IdentityResponse) ToIdentity() entity.Identity {
 	}
 }
 
+func (r *postConnectResponse) toResult() ConnectResult {
+	return ConnectResult{
+		Identity: r.Identity.ToIdentity(),
+		Tags:     r.Tags,
+	}
+}
+
 type putDisconnectBody struct {
 	EntityID entity.ID        `json:"entityId"`
 	Reason   DisconnectReason `json:"reason"`
@@ -97,7 +113,7 @@ func NewIdentityConnectClient(
 
 // Perform the Connect step. The Agent must supply a fingerprint for the host. Backend should reply
 // with a unique Entity ID across NR.
-func (ic *identityClient) Connect(fingerprint fingerprint.Fingerprint) (ids entity.Identity, retry backendhttp.RetryPolicy, err error) {
+func (ic *identityClient) Connect(fingerprint fingerprint.Fingerprint) (result ConnectResult, retry backendhttp.RetryPolicy, err error) {
 	buf, err := ic.marshal(postConnectBody{
 		Fingerprint: fingerprint,
 		Type:        ic.agentType(),
@@ -156,12 +172,12 @@ func (ic *identityClient) Connect(fingerprint fingerprint.Fingerprint) (ids enti
 		return
 	}
 
-	ids = response.Identity.ToIdentity()
+	result = response.toResult()
 	return
 }
 
 // ConnectUpdate is used to update the host fingerprint of the entityID to the backend.
-func (ic *identityClient) ConnectUpdate(entityIdn entity.Identity, fingerprint fingerprint.Fingerprint) (retry backendhttp.RetryPolicy, ids entity.Identity, err error) {
+func (ic *identityClient) ConnectUpdate(entityIdn entity.Identity, fingerprint fingerprint.Fingerprint) (retry backendhttp.RetryPolicy, result ConnectResult, err error) {
 	buf, err := ic.marshal(postConnectBody{
 		Fingerprint: fingerprint,
 		Type:        ic.agentType(),
@@ -223,7 +239,7 @@ func (ic *identityClient) ConnectUpdate(entityIdn entity.Identity, fingerprint f
 		return
 	}
 
-	ids = pcr.Identity.ToIdentity()
+	result = pcr.toResult()
 	return
 }
 
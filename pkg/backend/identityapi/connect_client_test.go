@@ -119,7 +119,7 @@ func TestConnectRetryTime(t *testing.T) {
 		MaxBackOff: 5 * time.Minute,
 	}
 	assert.EqualValues(t, expectedRetryPolicy, retryPolicy)
-	assert.EqualValues(t, entity.EmptyIdentity, entityId)
+	assert.EqualValues(t, entity.EmptyIdentity, entityId.Identity)
 }
 
 func TestConnectErrorNoRetryAfterHeader(t *testing.T) {
@@ -154,7 +154,7 @@ func TestConnectErrorNoRetryAfterHeader(t *testing.T) {
 		MaxBackOff: 5 * time.Minute,
 	}
 	assert.EqualValues(t, expectedRetryPolicy, retryPolicy)
-	assert.EqualValues(t, entity.EmptyIdentity, entityId)
+	assert.EqualValues(t, entity.EmptyIdentity, entityId.Identity)
 }
 
 func TestConnectErrorTrialExpired(t *testing.T) {
@@ -189,7 +189,7 @@ func TestConnectErrorTrialExpired(t *testing.T) {
 		MaxBackOff: 1 * time.Hour,
 	}
 	assert.EqualValues(t, expectedRetryPolicy, retryPolicy)
-	assert.EqualValues(t, entity.EmptyIdentity, entityId)
+	assert.EqualValues(t, entity.EmptyIdentity, entityId.Identity)
 }
 
 func TestConnectErrorTrialInactive(t *testing.T) {
@@ -225,7 +225,7 @@ func TestConnectErrorTrialInactive(t *testing.T) {
 		MaxBackOff: 5 * time.Minute,
 	}
 	assert.EqualValues(t, expectedRetryPolicy, retryPolicy)
-	assert.EqualValues(t, entity.EmptyIdentity, entityId)
+	assert.EqualValues(t, entity.EmptyIdentity, entityId.Identity)
 }
 
 func TestConnectFingerprint(t *testing.T) {
@@ -254,7 +254,7 @@ func TestConnectFingerprint(t *testing.T) {
 	}
 
 	assert.EqualValues(t, expectedRetryPolicy, retryPolicy)
-	assert.EqualValues(t, expectedIdentity, entityId)
+	assert.EqualValues(t, expectedIdentity, entityId.Identity)
 }
 
 func TestConnectOk(t *testing.T) {
@@ -270,7 +270,7 @@ func TestConnectOk(t *testing.T) {
 	assert.EqualValues(t, EmptyRetryTime, retryPolicy.After)
 	assert.EqualValues(t, EmptyRetryTime, retryPolicy.MaxBackOff)
 
-	assert.EqualValues(t, expectedIdentity, entityId)
+	assert.EqualValues(t, expectedIdentity, entityId.Identity)
 }
 
 func TestConnectOkWithNoGUID(t *testing.T) {
@@ -286,7 +286,7 @@ func TestConnectOkWithNoGUID(t *testing.T) {
 	assert.EqualValues(t, EmptyRetryTime, retryPolicy.After)
 	assert.EqualValues(t, EmptyRetryTime, retryPolicy.MaxBackOff)
 
-	assert.EqualValues(t, entity.Identity{ID: testAgentEntityId}, entityId)
+	assert.EqualValues(t, entity.Identity{ID: testAgentEntityId}, entityId.Identity)
 }
 
 func TestConnectMakeUrl(t *testing.T) {
@@ -376,7 +376,7 @@ func Test_identityClient_ConnectUpdateReturnsEntityID(t *testing.T) {
 
 	_, entityID, err := client.ConnectUpdate(entity.Identity{ID: 1}, fp)
 	assert.NoError(t, err)
-	assert.Equal(t, expectedIdentity, entityID)
+	assert.Equal(t, expectedIdentity, entityID.Identity)
 }
 
 func Test_identityClient_DisconnectReturnsNoErr(t *testing.T) {
@@ -397,3 +397,25 @@ func Test_identityClient_DisconnectReturnsNoErr(t *testing.T) {
 
 	assert.NoError(t, err)
 }
+
+func TestConnectAssignedTags(t *testing.T) {
+	// GIVEN a connect response with tags assigned to the agent entity
+	mockHttp := func(req *http.Request) (*http.Response, error) {
+		body := `{"identity":{"entityId":999666333,"GUID":"FOO"},"tags":{"workload":"checkout","costCenter":"cc-42"}}`
+		reader := ioutil.NopCloser(bytes.NewReader([]byte(body)))
+		return &http.Response{Status: "200 OK", StatusCode: 200, Body: reader, Header: http.Header{}}, nil
+	}
+	client, err := NewIdentityConnectClient(testUrl, testLicenseKey, testUserAgent, gzip.BestCompression, true, mockHttp)
+	assert.NoError(t, err)
+
+	// WHEN connecting and updating the fingerprint
+	result, _, err := client.Connect(generateDefaultFingerprint())
+	assert.NoError(t, err)
+	_, updated, err := client.ConnectUpdate(expectedIdentity, generateDefaultFingerprint())
+	assert.NoError(t, err)
+
+	// THEN the tags are returned along with the identity
+	expectedTags := map[string]string{"workload": "checkout", "costCenter": "cc-42"}
+	assert.Equal(t, ConnectResult{Identity: expectedIdentity, Tags: expectedTags}, result)
+	assert.Equal(t, expectedTags, updated.Tags)
+}
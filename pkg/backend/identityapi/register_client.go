@@ -172,6 +172,10 @@ func newRegisterRequest(entity entity.Fields) identity.RegisterRequest {
 		DisplayName: entity.DisplayName,
 		Metadata:    convertMetadataToMapStringString(entity.Metadata),
 	}
+	// lifecycle hints allow the backend to expire the short-lived entities
+	for k, v := range entity.LifecycleMetadata() {
+		registerRequest.Metadata[k] = v
+	}
 	return registerRequest
 }
 
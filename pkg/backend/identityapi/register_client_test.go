@@ -256,6 +256,21 @@ func TestRegisterClient_RegisterEntity(t *testing.T) {
 	mc.AssertExpectations(t)
 }
 
+func TestNewRegisterRequest_Lifecycle(t *testing.T) {
+	req := newRegisterRequest(entity.Fields{
+		Name:      "job-1",
+		Type:      "BATCH_JOB",
+		Lifecycle: entity.LifecycleEphemeral,
+		TTL:       entity.TTL(5 * time.Minute),
+	})
+
+	assert.Equal(t, map[string]string{"lifecycle": "ephemeral", "ttl": "300"}, req.Metadata)
+
+	req = newRegisterRequest(entity.Fields{Name: "host", Metadata: map[string]interface{}{"env": "prod"}})
+
+	assert.Equal(t, map[string]string{"env": "prod"}, req.Metadata)
+}
+
 func TestRegisterClient_RegisterEntity_err(t *testing.T) {
 	expectedError := errors.New("some random error")
 	mc := &mockAPIClient{}
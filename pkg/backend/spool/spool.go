@@ -0,0 +1,203 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package spool persists on disk the payloads that couldn't be submitted to the backend, to replay them once
+// it's reachable again.
+package spool
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/newrelic/infrastructure-agent/pkg/disk"
+	"github.com/newrelic/infrastructure-agent/pkg/log"
+)
+
+const (
+	dirMode  = 0755
+	fileMode = 0644
+	fileExt  = ".payload"
+)
+
+var slog = log.WithComponent("PayloadSpool")
+
+// Spool stores payloads grouped by kind (e.g. events or metrics), each one in a file named after the time it
+// was stored. The payloads older than the max age are discarded, and the oldest ones are evicted when the
+// spool is larger than its max size.
+type Spool struct {
+	dir     string
+	maxSize int64
+	maxAge  time.Duration
+	now     func() time.Time
+
+	lock      sync.Mutex
+	seq       uint64
+	replaying map[string]bool
+}
+
+// New creates a spool storing the payloads under the passed directory.
+func New(dir string, maxSize int64, maxAge time.Duration) (*Spool, error) {
+	if err := disk.MkdirAll(dir, dirMode); err != nil {
+		return nil, fmt.Errorf("can't create spool directory %s: %s", dir, err)
+	}
+	return &Spool{
+		dir:       dir,
+		maxSize:   maxSize,
+		maxAge:    maxAge,
+		now:       time.Now,
+		replaying: map[string]bool{},
+	}, nil
+}
+
+// Put stores a payload of the passed kind, evicting the oldest payloads when the spool exceeds its size.
+func (s *Spool) Put(kind string, payload []byte) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	dir := filepath.Join(s.dir, kind)
+	if err := disk.MkdirAll(dir, dirMode); err != nil {
+		return err
+	}
+	// the sequence keeps the order of the payloads stored within the same clock tick
+	s.seq++
+	name := fmt.Sprintf("%020d-%010d%s", s.now().UnixNano(), s.seq, fileExt)
+	if err := disk.WriteFile(filepath.Join(dir, name), payload, fileMode); err != nil {
+		return err
+	}
+	s.evict()
+	return nil
+}
+
+// Replay sends the payloads of the passed kind, oldest first, removing them once sent. It stops at the first
+// payload that can't be sent, which is kept for the next replay. Expired payloads are removed without sending
+// them. It returns the number of payloads sent.
+func (s *Spool) Replay(kind string, send func(payload []byte) error) (sent int, err error) {
+	s.lock.Lock()
+	if s.replaying[kind] {
+		s.lock.Unlock()
+		return 0, nil
+	}
+	s.replaying[kind] = true
+	s.lock.Unlock()
+	defer func() {
+		s.lock.Lock()
+		delete(s.replaying, kind)
+		s.lock.Unlock()
+	}()
+
+	for _, file := range s.files(kind) {
+		if s.expired(file.name) {
+			s.remove(file.path)
+			continue
+		}
+		payload, err := ioutil.ReadFile(file.path)
+		if err != nil {
+			// evicted meanwhile
+			if os.IsNotExist(err) {
+				continue
+			}
+			return sent, err
+		}
+		if err = send(payload); err != nil {
+			return sent, err
+		}
+		s.remove(file.path)
+		sent++
+	}
+	return sent, nil
+}
+
+// Len returns the number of stored payloads of the passed kind.
+func (s *Spool) Len(kind string) int {
+	return len(s.files(kind))
+}
+
+type spooledFile struct {
+	name string
+	path string
+	size int64
+}
+
+// files returns the payload files of a kind, sorted from the oldest.
+func (s *Spool) files(kind string) []spooledFile {
+	dir := filepath.Join(s.dir, kind)
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			slog.WithError(err).WithField("dir", dir).Warn("Can't read spooled payloads.")
+		}
+		return nil
+	}
+	files := make([]spooledFile, 0, len(infos))
+	for _, info := range infos {
+		if !info.IsDir() && strings.HasSuffix(info.Name(), fileExt) {
+			files = append(files, spooledFile{name: info.Name(), path: filepath.Join(dir, info.Name()), size: info.Size()})
+		}
+	}
+	// ReadDir already sorts by name, which starts with the storing time
+	return files
+}
+
+// evict removes the expired payloads and, when the spool is still too large, the oldest ones of all kinds.
+func (s *Spool) evict() {
+	kinds, err := ioutil.ReadDir(s.dir)
+	if err != nil {
+		slog.WithError(err).Warn("Can't read spool directory.")
+		return
+	}
+	var all []spooledFile
+	var size int64
+	for _, kind := range kinds {
+		if !kind.IsDir() {
+			continue
+		}
+		for _, file := range s.files(kind.Name()) {
+			if s.expired(file.name) {
+				s.remove(file.path)
+				continue
+			}
+			all = append(all, file)
+			size += file.size
+		}
+	}
+
+	sort.Slice(all, func(i, j int) bool { return all[i].name < all[j].name })
+	evicted := 0
+	for i := 0; size > s.maxSize && i < len(all); i++ {
+		s.remove(all[i].path)
+		size -= all[i].size
+		evicted++
+	}
+	if evicted > 0 {
+		slog.WithField("payloads", evicted).Warn("Spool size exceeded, the oldest payloads have been discarded.")
+	}
+}
+
+// expired returns whether the payload file is older than the max age, according to its name.
+func (s *Spool) expired(name string) bool {
+	if s.maxAge <= 0 {
+		return false
+	}
+	i := strings.Index(name, "-")
+	if i < 0 {
+		return false
+	}
+	nanos, err := strconv.ParseInt(name[:i], 10, 64)
+	if err != nil {
+		return false
+	}
+	return s.now().Sub(time.Unix(0, nanos)) > s.maxAge
+}
+
+func (s *Spool) remove(path string) {
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		slog.WithError(err).WithField("file", path).Warn("Can't remove spooled payload.")
+	}
+}
@@ -0,0 +1,102 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package spool
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestSpool(t *testing.T, maxSize int64, maxAge time.Duration) (*Spool, func()) {
+	dir, err := ioutil.TempDir("", "spool")
+	require.NoError(t, err)
+	s, err := New(dir, maxSize, maxAge)
+	require.NoError(t, err)
+	return s, func() { os.RemoveAll(dir) }
+}
+
+func replayAll(t *testing.T, s *Spool, kind string) (payloads []string) {
+	_, err := s.Replay(kind, func(payload []byte) error {
+		payloads = append(payloads, string(payload))
+		return nil
+	})
+	require.NoError(t, err)
+	return payloads
+}
+
+func TestSpool_ReplayOldestFirst(t *testing.T) {
+	s, cleanup := newTestSpool(t, 1<<20, time.Hour)
+	defer cleanup()
+
+	// GIVEN payloads of different kinds
+	require.NoError(t, s.Put("events", []byte("first")))
+	require.NoError(t, s.Put("metrics", []byte("metric")))
+	require.NoError(t, s.Put("events", []byte("second")))
+
+	// WHEN the events are replayed
+	payloads := replayAll(t, s, "events")
+
+	// THEN they are sent in the order they were stored, and removed
+	assert.Equal(t, []string{"first", "second"}, payloads)
+	assert.Equal(t, 0, s.Len("events"))
+	assert.Equal(t, 1, s.Len("metrics"))
+}
+
+func TestSpool_ReplayStopsOnError(t *testing.T) {
+	s, cleanup := newTestSpool(t, 1<<20, time.Hour)
+	defer cleanup()
+	require.NoError(t, s.Put("events", []byte("first")))
+	require.NoError(t, s.Put("events", []byte("second")))
+
+	// WHEN the second payload can't be sent
+	sendErr := errors.New("unavailable")
+	sent, err := s.Replay("events", func(payload []byte) error {
+		if string(payload) == "second" {
+			return sendErr
+		}
+		return nil
+	})
+
+	// THEN it's kept for the next replay
+	assert.Equal(t, sendErr, err)
+	assert.Equal(t, 1, sent)
+	assert.Equal(t, []string{"second"}, replayAll(t, s, "events"))
+}
+
+func TestSpool_MaxSize(t *testing.T) {
+	s, cleanup := newTestSpool(t, 10, time.Hour)
+	defer cleanup()
+
+	// WHEN the stored payloads exceed the max size
+	require.NoError(t, s.Put("events", []byte("0123")))
+	require.NoError(t, s.Put("metrics", []byte("4567")))
+	require.NoError(t, s.Put("events", []byte("89ab")))
+
+	// THEN the oldest ones are evicted
+	assert.Equal(t, []string{"89ab"}, replayAll(t, s, "events"))
+	assert.Equal(t, []string{"4567"}, replayAll(t, s, "metrics"))
+}
+
+func TestSpool_MaxAge(t *testing.T) {
+	s, cleanup := newTestSpool(t, 1<<20, time.Hour)
+	defer cleanup()
+	now := time.Now()
+	s.now = func() time.Time { return now.Add(-2 * time.Hour) }
+	require.NoError(t, s.Put("events", []byte("expired")))
+	s.now = func() time.Time { return now }
+	require.NoError(t, s.Put("events", []byte("recent")))
+
+	// WHEN the payloads are replayed
+	payloads := replayAll(t, s, "events")
+
+	// THEN the ones older than the max age are discarded
+	assert.Equal(t, []string{"recent"}, payloads)
+	assert.Equal(t, 0, s.Len("events"))
+}
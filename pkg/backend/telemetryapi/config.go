@@ -10,6 +10,8 @@ import (
 	"log"
 	"net/http"
 	"time"
+
+	"github.com/newrelic/infrastructure-agent/pkg/backend/throttle"
 )
 
 const (
@@ -68,6 +70,13 @@ type Config struct {
 	// MaxEntitiesPerBatch limits the total of metrics to queue
 	// If zero, DefaultMaxEntitiesPerBatch is used (1000 entities).
 	MaxEntitiesPerBatch int
+	// SpoolRequest, if set, receives the requests given up because the backend
+	// was unavailable until the HarvestTimeout elapsed, instead of dropping
+	// them. They can be submitted later with Harvester.SendSpooled.
+	SpoolRequest func(SpooledRequest)
+	// Throttle, if set, records the throttling responses of the backend and
+	// shrinks the requests while it's throttling.
+	Throttle *throttle.Controller
 }
 
 // ConfigAPIKey sets the Config's APIKey which is required and refers to your
@@ -163,6 +172,22 @@ func ConfigSpansURLOverride(url string) func(*Config) {
 	}
 }
 
+// ConfigSpoolRequest sets the Config's SpoolRequest field which receives the
+// requests that couldn't be submitted while the backend was unavailable.
+func ConfigSpoolRequest(spool func(SpooledRequest)) func(*Config) {
+	return func(cfg *Config) {
+		cfg.SpoolRequest = spool
+	}
+}
+
+// ConfigThrottle sets the Config's Throttle field which shrinks the requests
+// while the backend is throttling.
+func ConfigThrottle(c *throttle.Controller) func(*Config) {
+	return func(cfg *Config) {
+		cfg.Throttle = c
+	}
+}
+
 // configTesting is the config function to be used when testing. It sets the
 // APIKey but disables the harvest goroutine.
 func configTesting(cfg *Config) {
@@ -14,6 +14,8 @@ import (
 	"net/http"
 	"sync"
 	"time"
+
+	"github.com/newrelic/infrastructure-agent/pkg/backend/throttle"
 )
 
 // Harvester aggregates and reports metrics and spans.
@@ -219,7 +221,7 @@ func (h *Harvester) RecordInfraMetrics(commonAttributes Attributes, metrics []Me
 			logger.WithError(errJSON).Warn("Setting default common attributes")
 			attributesJSON = h.commonAttributesJSON
 		} else {
-			identity = commonAttributes[nrEntityID].(string)
+			identity, _ = commonAttributes[nrEntityID].(string)
 		}
 	}
 
@@ -241,6 +243,9 @@ var (
 	backoffSequenceSeconds = []int{0, 1, 2, 4, 8, 16}
 )
 
+// minThrottledEntitiesPerRequest bounds how much the requests shrink while the backend is throttling.
+const minThrottledEntitiesPerRequest = 10
+
 func (r response) needsRetry(_ *Config, attempts int) (bool, time.Duration) {
 	if attempts >= len(backoffSequenceSeconds) {
 		attempts = len(backoffSequenceSeconds) - 1
@@ -348,7 +353,7 @@ func (h *Harvester) swapOutBatchMetrics(ctx context.Context) (req []request) {
 		h.config.APIKey,
 		h.config.metricURL(),
 		h.config.userAgent(),
-		h.config.MaxEntitiesPerRequest,
+		h.config.Throttle.BatchSize(h.config.MaxEntitiesPerRequest, minThrottledEntitiesPerRequest),
 	}
 	req, err = newBatchRequest(ctx, r)
 	if err != nil {
@@ -416,6 +421,9 @@ func harvestRequest(req request, cfg *Config) {
 				"body":   jsonOrString(resp.body),
 			})
 		}
+		if throttle.IsThrottling(resp.statusCode) {
+			cfg.Throttle.Throttled()
+		}
 		retry, backoff := resp.needsRetry(cfg, attempts)
 		if !retry {
 			return
@@ -427,6 +435,9 @@ func harvestRequest(req request, cfg *Config) {
 			break
 		case <-req.Request.Context().Done():
 			tmr.Stop()
+			if nil != cfg.SpoolRequest {
+				cfg.SpoolRequest(newSpooledRequest(req))
+			}
 			return
 		}
 		attempts++
@@ -471,6 +482,31 @@ func (h *Harvester) HarvestNow(ct context.Context) {
 	}
 }
 
+// Flush sends the recorded data synchronously, returning once it's sent or
+// the context is done. Requests that can't be sent in time are passed to
+// Config.SpoolRequest, when set.
+func (h *Harvester) Flush(ct context.Context) {
+	if nil == h {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ct, h.config.HarvestTimeout)
+	defer cancel()
+
+	var reqs []request
+	reqs = append(reqs, h.swapOutMetrics(ctx, time.Now())...)
+	reqs = append(reqs, h.swapOutSpans(ctx)...)
+	reqs = append(reqs, h.swapOutBatchMetrics(ctx)...)
+
+	for _, req := range reqs {
+		if ctx.Err() == nil {
+			harvestRequest(req, &h.config)
+		} else if nil != h.config.SpoolRequest {
+			h.config.SpoolRequest(newSpooledRequest(req))
+		}
+	}
+}
+
 func minDuration(d1, d2 time.Duration) time.Duration {
 	if d1 < d2 {
 		return d1
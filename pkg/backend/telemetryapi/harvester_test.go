@@ -18,6 +18,8 @@ import (
 	"sync/atomic"
 	"testing"
 	"time"
+
+	"github.com/newrelic/infrastructure-agent/pkg/backend/throttle"
 )
 
 // compactJSONString removes the whitespace from a JSON string.  This function
@@ -360,6 +362,32 @@ func TestReturnCodes(t *testing.T) {
 	}
 }
 
+func TestHarvestThrottled(t *testing.T) {
+	// the agent slows down when the backend rejects a too large payload
+	wg := &sync.WaitGroup{}
+	wg.Add(1)
+	c := throttle.New(4, time.Hour)
+	h, _ := NewHarvester(configTesting, ConfigThrottle(c), func(cfg *Config) {
+		cfg.Client.Transport = roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			defer wg.Done()
+			return emptyResponse(413), nil
+		})
+	})
+
+	h.RecordSpan(Span{TraceID: "id", ID: "id", Name: "span1", Timestamp: time.Date(2014, time.November, 28, 1, 1, 0, 0, time.UTC)})
+	h.HarvestNow(context.Background())
+
+	if testTimesout(wg) {
+		t.Fatal("request not posted")
+	}
+	for i := 0; i < 100 && c.Factor() == 1; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if f := c.Factor(); f != 2 {
+		t.Error("incorrect throttle factor", f)
+	}
+}
+
 func testTimesout(wg *sync.WaitGroup) bool {
 	c := make(chan struct{})
 	go func() {
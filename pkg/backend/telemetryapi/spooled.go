@@ -0,0 +1,53 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package telemetryapi
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// SpooledRequest is a request that couldn't be submitted, stored to be sent
+// once the backend is available again. The body is kept compressed, and the
+// API key is not stored.
+type SpooledRequest struct {
+	URL    string      `json:"url"`
+	Header http.Header `json:"header"`
+	Body   []byte      `json:"body"`
+}
+
+func newSpooledRequest(req request) SpooledRequest {
+	header := req.Request.Header.Clone()
+	header.Del("Api-Key")
+	return SpooledRequest{
+		URL:    req.Request.URL.String(),
+		Header: header,
+		Body:   req.compressedBody,
+	}
+}
+
+// SendSpooled submits a request previously given up, returning an error when
+// it isn't accepted by the backend.
+func (h *Harvester) SendSpooled(ctx context.Context, sr SpooledRequest) error {
+	reqHTTP, err := http.NewRequest("POST", sr.URL, bytes.NewReader(sr.Body))
+	if nil != err {
+		return fmt.Errorf("error creating request: %v", err)
+	}
+	for name, values := range sr.Header {
+		for _, value := range values {
+			reqHTTP.Header.Add(name, value)
+		}
+	}
+	reqHTTP.Header.Set("Api-Key", h.config.APIKey)
+
+	h.config.logDebug(map[string]interface{}{
+		"event":       "spooled data post",
+		"url":         sr.URL,
+		"body-length": len(sr.Body),
+	})
+	resp := postData(reqHTTP.WithContext(ctx), h.config.Client)
+	return resp.err
+}
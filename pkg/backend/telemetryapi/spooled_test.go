@@ -0,0 +1,108 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package telemetryapi
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHarvestSpoolsUnavailableRequests(t *testing.T) {
+	var available int32
+	bodies := make(chan []byte, 10)
+	rt := roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		if r.Header.Get("Api-Key") != "key" {
+			t.Error("unexpected api key", r.Header.Get("Api-Key"))
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		bodies <- body
+		if atomic.LoadInt32(&available) == 0 {
+			return emptyResponse(503), nil
+		}
+		return emptyResponse(202), nil
+	})
+	spooled := make(chan SpooledRequest, 1)
+	h, _ := NewHarvester(func(cfg *Config) {
+		cfg.HarvestPeriod = 0
+		cfg.HarvestTimeout = 100 * time.Millisecond
+		cfg.Client.Transport = rt
+		cfg.APIKey = "key"
+		cfg.SpoolRequest = func(req SpooledRequest) {
+			spooled <- req
+		}
+	})
+	h.RecordMetric(Gauge{Name: "gauge", Value: 1})
+
+	// WHEN the backend is unavailable until the harvest times out
+	h.HarvestNow(context.Background())
+	body := <-bodies
+
+	// THEN the request is spooled without the api key
+	var req SpooledRequest
+	select {
+	case req = <-spooled:
+	case <-time.After(5 * time.Second):
+		t.Fatal("request not spooled")
+	}
+	if string(req.Body) != string(body) {
+		t.Error("unexpected spooled body")
+	}
+	if req.Header.Get("Api-Key") != "" {
+		t.Error("api key spooled")
+	}
+	if req.Header.Get("Content-Encoding") != "gzip" {
+		t.Error("missing headers", req.Header)
+	}
+
+	// AND it's submitted once the backend is available
+	atomic.StoreInt32(&available, 1)
+	if err := h.SendSpooled(context.Background(), req); err != nil {
+		t.Fatal(err)
+	}
+	if string(<-bodies) != string(body) {
+		t.Error("unexpected submitted body")
+	}
+}
+
+func TestFlushSpoolsAfterDeadline(t *testing.T) {
+	var posts int32
+	rt := roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		atomic.AddInt32(&posts, 1)
+		return emptyResponse(202), nil
+	})
+	var spooled []SpooledRequest
+	h, _ := NewHarvester(func(cfg *Config) {
+		cfg.HarvestPeriod = 0
+		cfg.Client.Transport = rt
+		cfg.APIKey = "key"
+		cfg.SpoolRequest = func(req SpooledRequest) {
+			spooled = append(spooled, req)
+		}
+	})
+	h.RecordMetric(Gauge{Name: "gauge", Value: 1})
+
+	// WHEN the harvester is flushed after the deadline
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	h.Flush(ctx)
+
+	// THEN the requests are spooled without sending them
+	if len(spooled) != 1 {
+		t.Fatal("unexpected spooled requests", len(spooled))
+	}
+	if atomic.LoadInt32(&posts) != 0 {
+		t.Error("requests sent after the deadline")
+	}
+
+	// AND they are sent while the deadline isn't exceeded
+	h.RecordMetric(Gauge{Name: "gauge", Value: 2})
+	h.Flush(context.Background())
+	if atomic.LoadInt32(&posts) != 1 {
+		t.Error("request not sent")
+	}
+}
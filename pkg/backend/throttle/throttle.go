@@ -0,0 +1,110 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package throttle slows down the agent while the backend is throttling its submissions, so retries don't compound
+// the overload.
+package throttle
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/newrelic/infrastructure-agent/pkg/log"
+)
+
+var tlog = log.WithComponent("Throttle")
+
+// IsThrottling returns whether the backend response status asks the agent to send less data.
+func IsThrottling(statusCode int) bool {
+	return statusCode == http.StatusTooManyRequests || statusCode == http.StatusRequestEntityTooLarge
+}
+
+// Controller keeps a slowdown factor that doubles on each throttling response, up to its max, and halves after
+// each recovery period without them. The sampling intervals are multiplied by the factor, and the batch sizes
+// divided by it. A nil Controller never slows down.
+type Controller struct {
+	maxFactor int
+	recovery  time.Duration
+	now       func() time.Time
+
+	lock    sync.Mutex
+	factor  int
+	changed time.Time
+}
+
+// New returns a controller slowing down up to maxFactor times. A maxFactor lower than 2 returns nil.
+func New(maxFactor int, recovery time.Duration) *Controller {
+	if maxFactor < 2 {
+		return nil
+	}
+	return &Controller{
+		maxFactor: maxFactor,
+		recovery:  recovery,
+		now:       time.Now,
+		factor:    1,
+	}
+}
+
+// Throttled records a throttling response of the backend.
+func (c *Controller) Throttled() {
+	if c == nil {
+		return
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.recover()
+	c.changed = c.now()
+	if c.factor >= c.maxFactor {
+		return
+	}
+	c.factor *= 2
+	if c.factor > c.maxFactor {
+		c.factor = c.maxFactor
+	}
+	tlog.WithField("factor", c.factor).Warn("Backend is throttling the agent, slowing down the submissions.")
+}
+
+// Factor returns the current slowdown factor, 1 when the agent isn't throttled.
+func (c *Controller) Factor() int {
+	if c == nil {
+		return 1
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.recover()
+	return c.factor
+}
+
+// recover halves the factor for each recovery period elapsed since the last change.
+func (c *Controller) recover() {
+	if c.factor == 1 || c.recovery <= 0 {
+		return
+	}
+	for c.factor > 1 && c.now().Sub(c.changed) >= c.recovery {
+		c.factor /= 2
+		c.changed = c.changed.Add(c.recovery)
+		if c.factor == 1 {
+			tlog.Info("Backend stopped throttling the agent, submissions are back to normal.")
+		}
+	}
+}
+
+// Interval returns the interval slowed down by the current factor.
+func (c *Controller) Interval(interval time.Duration) time.Duration {
+	return interval * time.Duration(c.Factor())
+}
+
+// BatchSize returns the size shrunk by the current factor, but not below min.
+func (c *Controller) BatchSize(size, min int) int {
+	shrunk := size / c.Factor()
+	if shrunk < min {
+		if size < min {
+			return size
+		}
+		return min
+	}
+	return shrunk
+}
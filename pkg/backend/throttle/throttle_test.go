@@ -0,0 +1,63 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package throttle
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestController(t *testing.T) {
+	now := time.Now()
+	c := New(4, time.Minute)
+	c.now = func() time.Time { return now }
+
+	// GIVEN a controller not throttled
+	assert.Equal(t, 1, c.Factor())
+	assert.Equal(t, 15*time.Second, c.Interval(15*time.Second))
+
+	// WHEN the backend throttles the agent
+	c.Throttled()
+
+	// THEN the intervals are slowed down and the batches shrunk
+	assert.Equal(t, 2, c.Factor())
+	assert.Equal(t, 30*time.Second, c.Interval(15*time.Second))
+	assert.Equal(t, 500, c.BatchSize(1000, 100))
+
+	// AND the factor doesn't exceed its max
+	c.Throttled()
+	c.Throttled()
+	assert.Equal(t, 4, c.Factor())
+	assert.Equal(t, 100, c.BatchSize(200, 100))
+	assert.Equal(t, 50, c.BatchSize(50, 100))
+
+	// WHEN the backend stops throttling the agent
+	now = now.Add(time.Minute)
+
+	// THEN the agent recovers gradually
+	assert.Equal(t, 2, c.Factor())
+	now = now.Add(30 * time.Second)
+	assert.Equal(t, 2, c.Factor())
+	now = now.Add(time.Hour)
+	assert.Equal(t, 1, c.Factor())
+}
+
+func TestNew_Disabled(t *testing.T) {
+	c := New(1, time.Minute)
+	assert.Nil(t, c)
+
+	c.Throttled()
+	assert.Equal(t, 1, c.Factor())
+	assert.Equal(t, time.Second, c.Interval(time.Second))
+	assert.Equal(t, 1000, c.BatchSize(1000, 10))
+}
+
+func TestIsThrottling(t *testing.T) {
+	assert.True(t, IsThrottling(http.StatusTooManyRequests))
+	assert.True(t, IsThrottling(http.StatusRequestEntityTooLarge))
+	assert.False(t, IsThrottling(http.StatusInternalServerError))
+	assert.False(t, IsThrottling(http.StatusAccepted))
+}
@@ -0,0 +1,114 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	defaultBootstrapURL        = defaultIdentityURL + "/bootstrap/v1/exchange"
+	defaultBootstrapStagingURL = defaultIdentityStagingURL + "/bootstrap/v1/exchange"
+	bootstrapTimeout           = 30 * time.Second
+	bootstrapLicenseFile       = "bootstrap_license"
+)
+
+type bootstrapRequest struct {
+	Hostname string `json:"hostname,omitempty"`
+}
+
+type bootstrapResponse struct {
+	LicenseKey string `json:"licenseKey"`
+}
+
+// resolveBootstrapLicense exchanges the short-lived bootstrap token for the license key when no
+// license key is configured. The obtained license is stored in the agent directory, so the agent
+// can restart once the token has expired.
+func resolveBootstrapLicense(cfg *Config) error {
+	if cfg.License != "" || cfg.BootstrapToken == "" {
+		return nil
+	}
+
+	cacheFile := filepath.Join(cfg.AgentDir, bootstrapLicenseFile)
+	if cached, err := ioutil.ReadFile(cacheFile); err == nil && len(bytes.TrimSpace(cached)) > 0 {
+		clog.WithField("file", cacheFile).Debug("Using license key obtained from a previous bootstrap.")
+		cfg.License = string(bytes.TrimSpace(cached))
+		return nil
+	}
+
+	license, err := exchangeBootstrapToken(cfg)
+	if err != nil {
+		return fmt.Errorf("unable to exchange the bootstrap token for a license key: %s", err)
+	}
+	cfg.License = license
+
+	if err := ioutil.WriteFile(cacheFile, []byte(license), 0600); err != nil {
+		clog.WithError(err).WithField("file", cacheFile).
+			Warn("Cannot store the bootstrap license key, the token will be exchanged again on restart.")
+	}
+	return nil
+}
+
+// exchangeBootstrapToken requests the license key to the bootstrap endpoint, authenticated with the token.
+func exchangeBootstrapToken(cfg *Config) (string, error) {
+	endpoint := cfg.BootstrapURL
+	if endpoint == "" {
+		endpoint = defaultBootstrapURL
+		if cfg.Staging {
+			endpoint = defaultBootstrapStagingURL
+		}
+	}
+
+	hostname, _ := os.Hostname()
+	body, err := json.Marshal(bootstrapRequest{Hostname: hostname})
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequest(http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+cfg.BootstrapToken)
+
+	transport := &http.Transport{Proxy: http.ProxyFromEnvironment}
+	if cfg.IgnoreSystemProxy {
+		transport.Proxy = nil
+	}
+	if cfg.Proxy != "" {
+		proxyURL, err := url.Parse(cfg.Proxy)
+		if err != nil {
+			return "", fmt.Errorf("invalid proxy %q: %s", cfg.Proxy, err)
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+	client := http.Client{Timeout: bootstrapTimeout, Transport: transport}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("bootstrap endpoint returned status %s", resp.Status)
+	}
+	var response bootstrapResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return "", fmt.Errorf("invalid bootstrap response: %s", err)
+	}
+	license := strings.TrimSpace(response.LicenseKey)
+	if license == "" {
+		return "", fmt.Errorf("bootstrap response has no license key")
+	}
+	return license, nil
+}
@@ -63,6 +63,25 @@ type Config struct {
 	// Public: Yes
 	License string `yaml:"license_key" envconfig:"license_key" public:"obfuscate"`
 
+	// Variables defines data binding variables gathered from secrets providers (e.g. azure-key-vault, vault or
+	// aws-secrets-manager), with the same format as the integrations ones. The license key can reference them as
+	// ${variable}, so it doesn't need to be written in the configuration file.
+	// Default: Empty
+	// Public: No
+	Variables map[string]interface{} `yaml:"variables" ignored:"true" public:"false"`
+
+	// BootstrapToken is a short-lived token that the agent exchanges for the license key when no license key is
+	// configured, so machine images and autoscaling templates don't need to contain it. The obtained license key is
+	// stored in the agent directory and reused on restarts.
+	// Default: ""
+	// Public: Yes
+	BootstrapToken string `yaml:"bootstrap_token" envconfig:"bootstrap_token" public:"obfuscate"`
+
+	// BootstrapURL defines the endpoint where the bootstrap token is exchanged for the license key.
+	// Default: https://identity-api.newrelic.com/bootstrap/v1/exchange
+	// Public: No
+	BootstrapURL string `yaml:"bootstrap_url" envconfig:"bootstrap_url" public:"false"`
+
 	// Staging is staging environment.
 	// Default: false
 	// Public: No
@@ -165,6 +184,13 @@ type Config struct {
 	// Public: Yes
 	DockerApiVersion string `yaml:"docker_api_version" envconfig:"docker_api_version"`
 
+	// ContainerRuntimeEndpoint is the CRI endpoint, as accepted by crictl, used to decorate the process samples with
+	// the metadata of their containers when they don't run in Docker, e.g. on Kubernetes nodes running containerd or
+	// CRI-O. Empty disables the decoration through the CRI API.
+	// Default: unix:///run/containerd/containerd.sock
+	// Public: Yes
+	ContainerRuntimeEndpoint string `yaml:"container_runtime_endpoint" envconfig:"container_runtime_endpoint" os:"linux"`
+
 	// CustomAttributes is a list of custom attributes to annotate the data from this agent instance. Separate keys and
 	// values with colons :, as in KEY: VALUE, and separate each key-value pair with a line break. Keys can be any
 	// valid YAML except slashes /. Values can be any YAML string, including spaces.
@@ -329,17 +355,17 @@ type Config struct {
 
 	// MetricsStorageSampleRate Sample rate of Storage Samples in seconds. Minimum value is 5. If value is -1 then
 	// the sampler is disabled.
-	// Default: 5
+	// Default: 20
 	// Public: Yes
 	MetricsStorageSampleRate int `yaml:"metrics_storage_sample_rate" envconfig:"metrics_storage_sample_rate"`
 
-	// MetricsNetworkSampleRate Sample rate of Network Samples in seconds. Minimum value is 10. If value is -1 then
+	// MetricsNetworkSampleRate Sample rate of Network Samples in seconds. Minimum value is 5. If value is -1 then
 	// the sampler is disabled.
 	// Default: 5
 	// Public: Yes
 	MetricsNetworkSampleRate int `yaml:"metrics_network_sample_rate" envconfig:"metrics_network_sample_rate"`
 
-	// MetricsProcessSampleRate Sample rate of System Samples in seconds. Minimum value is 20. If value is -1 then
+	// MetricsProcessSampleRate Sample rate of Process Samples in seconds. Minimum value is 20. If value is -1 then
 	// the sampler is disabled.
 	// Default: 20
 	// Public: Yes
@@ -419,6 +445,100 @@ type Config struct {
 	// Public: Yes
 	DpkgRefreshSec int64 `yaml:"dpkg_interval_sec" envconfig:"dpkg_interval_sec"`
 
+	// SnapRefreshSec Sampling period / interval in seconds for the Snap plugin, reporting the snaps installed through
+	// snapd with their channel. Set as value -1 for disabling it. 30 is the minimum value.
+	// Default: 300
+	// Public: Yes
+	SnapRefreshSec int64 `yaml:"snap_interval_sec" envconfig:"snap_interval_sec" os:"linux"`
+
+	// FlatpakRefreshSec Sampling period / interval in seconds for the Flatpak plugin, reporting the applications
+	// and runtimes of the system installation with their branch. Set as value -1 for disabling it. 30 is the
+	// minimum value.
+	// Default: 300
+	// Public: Yes
+	FlatpakRefreshSec int64 `yaml:"flatpak_interval_sec" envconfig:"flatpak_interval_sec" os:"linux"`
+
+	// EnableCPEInventory reports the CPE names and package URLs of the operating system and the installed
+	// packages, so they can be matched against vulnerability databases. They are generated from the Dpkg or
+	// Rpm packages and refreshed at the same interval. Only activated in root or privileged modes.
+	// Default: false
+	// Public: Yes
+	EnableCPEInventory bool `yaml:"enable_cpe_inventory" envconfig:"enable_cpe_inventory"`
+
+	// EnablePythonPackagesInventory reports the Python packages installed in the system site-packages and in
+	// the virtualenvs found under python_virtualenv_paths, with their version and location.
+	// Default: false
+	// Public: Yes
+	EnablePythonPackagesInventory bool `yaml:"enable_python_packages_inventory" envconfig:"enable_python_packages_inventory" os:"linux"`
+
+	// PythonPackagesRefreshSec Sampling period / interval in seconds for the Python packages plugin. Set as value
+	// -1 for disabling it. 30 is the minimum value.
+	// Default: 3600
+	// Public: Yes
+	PythonPackagesRefreshSec int64 `yaml:"python_packages_interval_sec" envconfig:"python_packages_interval_sec" os:"linux"`
+
+	// PythonVirtualenvPaths lists the directories searched for virtualenvs, identified by their pyvenv.cfg
+	// file, up to 4 levels deep.
+	// Default: /opt, /srv, /home
+	// Public: Yes
+	PythonVirtualenvPaths []string `yaml:"python_virtualenv_paths" envconfig:"python_virtualenv_paths" os:"linux"`
+
+	// EnableDockerImagesInventory reports the images present in the local Docker daemon, with their
+	// repository, tag, digest, size and creation time. Only activated when the Docker socket is found.
+	// Default: false
+	// Public: Yes
+	EnableDockerImagesInventory bool `yaml:"enable_docker_images_inventory" envconfig:"enable_docker_images_inventory"`
+
+	// DockerImagesRefreshSec Sampling period / interval in seconds for the Docker images plugin. Set as value
+	// -1 for disabling it. 30 is the minimum value.
+	// Default: 300
+	// Public: Yes
+	DockerImagesRefreshSec int64 `yaml:"docker_images_interval_sec" envconfig:"docker_images_interval_sec"`
+
+	// TLSCertificatePaths lists the files, or glob patterns, whose PEM encoded X.509 certificates are reported
+	// as inventory with their subject, issuer, SANs and days to expiry. The TLS certificates plugin is enabled
+	// when this or tls_certificate_ports is set.
+	// Default: Empty
+	// Public: Yes
+	TLSCertificatePaths []string `yaml:"tls_certificate_paths" envconfig:"tls_certificate_paths"`
+
+	// TLSCertificatePorts lists the local ports whose TLS servers certificates are reported as inventory. The
+	// certificates are retrieved through a handshake with localhost, without validating them.
+	// Default: Empty
+	// Public: Yes
+	TLSCertificatePorts []int `yaml:"tls_certificate_ports" envconfig:"tls_certificate_ports"`
+
+	// TLSCertificateExpiryThresholdDays is the number of days to expiry below which an InfrastructureEvent is
+	// submitted for a certificate. Another event is submitted when it expires.
+	// Default: 30
+	// Public: Yes
+	TLSCertificateExpiryThresholdDays int `yaml:"tls_certificate_expiry_threshold_days" envconfig:"tls_certificate_expiry_threshold_days"`
+
+	// TLSCertificatesRefreshSec Sampling period / interval in seconds for the TLS certificates plugin. Set as
+	// value -1 for disabling it. 30 is the minimum value.
+	// Default: 3600
+	// Public: Yes
+	TLSCertificatesRefreshSec int64 `yaml:"tls_certificates_interval_sec" envconfig:"tls_certificates_interval_sec"`
+
+	// EnableScheduledTasksInventory reports the cron entries and the systemd timers on Linux, and the Task
+	// Scheduler tasks on Windows, with their schedule, command and last result.
+	// Default: false
+	// Public: Yes
+	EnableScheduledTasksInventory bool `yaml:"enable_scheduled_tasks_inventory" envconfig:"enable_scheduled_tasks_inventory"`
+
+	// ScheduledTasksRefreshSec Sampling period / interval in seconds for the scheduled tasks plugin. Set as
+	// value -1 for disabling it. 30 is the minimum value.
+	// Default: 300
+	// Public: Yes
+	ScheduledTasksRefreshSec int64 `yaml:"scheduled_tasks_interval_sec" envconfig:"scheduled_tasks_interval_sec"`
+
+	// MacApplicationsRefreshSec Sampling period / interval in seconds for the macOS applications and Homebrew
+	// plugins, reporting the application bundles with their signing identity and the Homebrew formulae and casks.
+	// Set as value -1 for disabling them. 30 is the minimum value.
+	// Default: 300
+	// Public: Yes
+	MacApplicationsRefreshSec int64 `yaml:"mac_applications_interval_sec" envconfig:"mac_applications_interval_sec" os:"darwin"`
+
 	// DaemontoolsRefreshSec Sampling period / interval in seconds for Daemontools plugin. Set as value -1 for
 	// disabling it. 10 is the minimum value
 	// Default: 15
@@ -460,6 +580,13 @@ type Config struct {
 	// Public: Yes
 	SysctlIntervalSec int64 `yaml:"sysctl_interval_sec" envconfig:"sysctl_interval_sec"`
 
+	// SysctlAllowlist restricts the sysctls reported by the Sysctl plugin to the ones matching any of these
+	// patterns, in dot notation with shell wildcards, e.g. vm.*, net.core.* or fs.file-max. All the writable
+	// sysctls are reported when empty.
+	// Default: Empty
+	// Public: Yes
+	SysctlAllowlist []string `yaml:"sysctl_allowlist" envconfig:"sysctl_allowlist"`
+
 	// SystemdIntervalSec Sampling period / interval in seconds for Systemd plugin. Set as value -1 for disabling it.
 	// 10 is the minimum value.
 	// Default: 30
@@ -508,6 +635,14 @@ type Config struct {
 	// Public: Yes
 	SshdConfigRefreshSec int64 `yaml:"sshd_config_refresh_sec" envconfig:"sshd_config_refresh_sec"`
 
+	// StorageDevicesRefreshSec Interval in seconds to refresh the inventory of the Mounts and BlockDevices plugins.
+	// Mounts, unmounts and device hotplug are notified by the kernel and reported immediately, so this interval
+	// only applies if the notifications are unavailable. Set as value -1 for disabling both plugins and their
+	// events. 10 is the minimum value.
+	// Default: 60
+	// Public: Yes
+	StorageDevicesRefreshSec int64 `yaml:"storage_devices_refresh_sec" envconfig:"storage_devices_refresh_sec"`
+
 	// WindowsServicesRefreshSec Sampling period / interval in seconds for WindowsServices plugin. Set as value -1
 	// for disabling it. 10 is the minimum value.
 	// Default: 30
@@ -520,6 +655,30 @@ type Config struct {
 	// Public: Yes
 	WindowsUpdatesRefreshSec int64 `yaml:"windows_updates_refresh_sec" envconfig:"windows_updates_refresh_sec" os:"windows"`
 
+	// EnableWindowsFeaturesInventory reports the server roles and features installed on the host. They are only
+	// available on the Windows Server editions.
+	// Default: True
+	// Public: Yes
+	EnableWindowsFeaturesInventory bool `yaml:"enable_windows_features_inventory" envconfig:"enable_windows_features_inventory" os:"windows"`
+
+	// WindowsFeaturesRefreshSec Sampling period / interval in seconds for the Windows roles and features plugin.
+	// Set as value -1 for disabling it. 10 is the minimum value.
+	// Default: 300
+	// Public: Yes
+	WindowsFeaturesRefreshSec int64 `yaml:"windows_features_interval_sec" envconfig:"windows_features_interval_sec" os:"windows"`
+
+	// EnablePendingRebootInventory reports whether a reboot is pending, along with the indicator that requires
+	// it: component based servicing, Windows Update or pending file rename operations.
+	// Default: True
+	// Public: Yes
+	EnablePendingRebootInventory bool `yaml:"enable_pending_reboot_inventory" envconfig:"enable_pending_reboot_inventory" os:"windows"`
+
+	// PendingRebootRefreshSec Sampling period / interval in seconds for the pending reboot plugin. Set as value
+	// -1 for disabling it. 10 is the minimum value.
+	// Default: 60
+	// Public: Yes
+	PendingRebootRefreshSec int64 `yaml:"pending_reboot_interval_sec" envconfig:"pending_reboot_interval_sec" os:"windows"`
+
 	// LogToStdout By default all logs are displayed in both standard output and a log file. If you want to disable
 	// logs in the standard output you can set this configuration option to FALSE.
 	// Default: True
@@ -543,6 +702,27 @@ type Config struct {
 	// Public: Yes
 	PayloadCompressionLevel int `yaml:"payload_compression_level" envconfig:"payload_compression_level"`
 
+	// PayloadCompression sets the algorithm compressing the inventory, event and dimensional metric payloads:
+	// "gzip", at the PayloadCompressionLevel, or "zstd". The endpoints rejecting the zstd payloads with an
+	// unsupported media type response get them in gzip from then on.
+	// Default: gzip
+	// Public: Yes
+	PayloadCompression string `yaml:"payload_compression" envconfig:"payload_compression"`
+
+	// PayloadSpoolMaxSize Size in bytes of the on-disk spool where the event and dimensional metric payloads are
+	// stored when they can't be submitted because the backend or the network is unavailable. Spooled payloads are
+	// submitted with their original timestamps once the backend is reachable again. When the spool is full, the
+	// oldest payloads are discarded. Zero disables the spool, so the payloads are dropped after the retries.
+	// Default: 0
+	// Public: Yes
+	PayloadSpoolMaxSize int64 `yaml:"payload_spool_max_size" envconfig:"payload_spool_max_size"`
+
+	// PayloadSpoolMaxAgeSec Time in seconds that a payload is kept in the spool. Older payloads are discarded
+	// instead of being submitted.
+	// Default: 86400 (24 hours)
+	// Public: Yes
+	PayloadSpoolMaxAgeSec int `yaml:"payload_spool_max_age_sec" envconfig:"payload_spool_max_age_sec"`
+
 	// PartitionsTTL Time duration to expire the cached list of storage partitions.
 	// Default: 60
 	// Public: No
@@ -597,11 +777,56 @@ type Config struct {
 	// Public: No
 	BatchQueueDepth int `yaml:"batch_queue_depth" envconfig:"batch_queue_depth" public:"false"` // See event_sender.go
 
+	// EventQueueWAL backs the event queue with a write-ahead log stored in the agent directory, so the events queued
+	// but not sent yet aren't lost when the agent or the host crashes or restarts. They are sent after the agent
+	// starts. The log is synced once per batch. Each batch is recorded before it's posted, so a post that
+	// succeeded right before a crash, but that wasn't acknowledged in the log yet, is sent again with the same
+	// events and payload ID, letting the backend discard the duplicate.
+	// Default: False
+	// Public: Yes
+	EventQueueWAL bool `yaml:"event_queue_wal" envconfig:"event_queue_wal"`
+
+	// ShutdownFlushTimeoutSec Time in seconds that the agent spends, when it's stopped, submitting the inventory
+	// deltas, events and dimensional metrics not sent yet, after stopping the samplers. The data that can't be
+	// submitted in time is persisted in the payload spool or the event write-ahead log, when they're enabled, to be
+	// submitted by the next execution. Zero stops the agent without flushing.
+	// Default: 10
+	// Public: Yes
+	ShutdownFlushTimeoutSec int `yaml:"shutdown_flush_timeout_sec" envconfig:"shutdown_flush_timeout_sec"`
+
+	// ThrottleMaxFactor bounds how much the agent slows down while the backend throttles it, answering 429 (too many
+	// requests) or 413 (payload too large). Each of these responses doubles the slowdown factor up to this value:
+	// the sampling intervals are multiplied by it, and the event and dimensional metric batches are divided by it.
+	// The factor halves after each ThrottleRecoverySec without throttling responses. 1 disables the slowdown.
+	// Default: 4
+	// Public: Yes
+	ThrottleMaxFactor int `yaml:"throttle_max_factor" envconfig:"throttle_max_factor"`
+
+	// ThrottleRecoverySec Time in seconds without throttling responses after which the slowdown factor halves.
+	// Default: 60
+	// Public: Yes
+	ThrottleRecoverySec int `yaml:"throttle_recovery_sec" envconfig:"throttle_recovery_sec"`
+
 	// InventoryQueueLen sets the inventory processing queue size. Zero value makes inventory processing synchronous (blocking call).
 	// Default: 0
 	// Public: Yes
 	InventoryQueueLen int `yaml:"inventory_queue_len" envconfig:"inventory_queue_len" public:"true"`
 
+	// InventoryStoreBackend selects how the inventory sources, caches and deltas are persisted in the data
+	// directory: "file" keeps a JSON file per plugin and entity, while "bolt" keeps all of them in a single
+	// embedded database, which performs better for hosts reporting many entities. Switching to "bolt" imports
+	// the existing files into the database.
+	// Default: file
+	// Public: Yes
+	InventoryStoreBackend string `yaml:"inventory_store_backend" envconfig:"inventory_store_backend"`
+
+	// InventoryStoreCompressionLevel sets the gzip compression level of the inventory sources, caches and deltas
+	// stored in the data directory, using the same values as PayloadCompressionLevel. Files stored with a
+	// different level, or without compression, are still read, so the level can be changed at any time.
+	// Default: 0
+	// Public: Yes
+	InventoryStoreCompressionLevel int `yaml:"inventory_store_compression_level" envconfig:"inventory_store_compression_level"`
+
 	// EnableWinUpdatePlugin enables the windows updates plugin which retrieves the lists of hotfix that are installed
 	// on the host.
 	// Default: False
@@ -622,11 +847,28 @@ type Config struct {
 	// Public: No
 	CompactThreshold uint64 `yaml:"compaction_threshold" envconfig:"compaction_threshold" public:"false"`
 
+	// MaxDataDirSize Size in bytes that the inventory data stored in the agent data directory can reach. When it's
+	// exceeded, the inventory of the least recently updated entities is evicted, and an InfrastructureEvent is
+	// reported, so integrations reporting too many entities can't fill the filesystem. The agent entity inventory
+	// is never evicted. Zero disables the limit.
+	// Default: 0
+	// Public: Yes
+	MaxDataDirSize uint64 `yaml:"max_data_dir_size" envconfig:"max_data_dir_size"`
+
 	// IgnoredInventoryPaths is not a configurable option. It maps the values from ignored_inventory config option
 	// Default: Empty
 	// Public: No
 	IgnoredInventoryPaths []string `yaml:"ignored_inventory" envconfig:"ignored_inventory" public:"false"`
 
+	// InventoryNoiseRules lists the inventory attributes whose changes alone don't generate deltas, so attributes
+	// churning every cycle, like timestamps or counters embedded in configuration files, don't report changes.
+	// Each rule has a "plugin" glob pattern (ie: "config/*"), an attribute "path" of dot-separated glob patterns
+	// (ie: "*.mtime") and an optional "value" regular expression the attribute values must match. When other
+	// attributes of the plugin change, the matched ones are reported too. Invalid rules are ignored.
+	// Default: none
+	// Public: Yes
+	InventoryNoiseRules []InventoryNoiseRule `yaml:"inventory_noise_rules" envconfig:"ignored"`
+
 	// WhitelistProcessSample only collects process samples for processes we care about, this is a WINDOWS ONLY CONFIG
 	// Default: Empty
 	// Public: No
@@ -966,8 +1208,10 @@ type Config struct {
 	// Public: No
 	K8sIntegrationSamplesIntervalSec int64 `yaml:"k8s_integration_samples_interval_sec" envconfig:"k8s_integration_samples_interval_sec" public:"false"`
 
-	// MetricsNFSSampleRate Sample rate of NFS Storage Samples in seconds. Minimum value is 5. If value is -1 then
-	// the sampler is disabled.
+	// MetricsNFSSampleRate Sample rate of NFS Storage Samples in seconds. On Linux, each NFS mount reports its
+	// operations, throughput, latency, RTT and retransmissions. On Windows, each share used by the SMB client
+	// reports its operations, throughput and latency, with "smb" as filesystemType. Minimum value is 5. If
+	// value is -1 then the sampler is disabled.
 	// Default: 20
 	// Public: Yes
 	MetricsNFSSampleRate int `yaml:"metrics_nfs_sample_rate" envconfig:"metrics_nfs_sample_rate"`
@@ -1000,6 +1244,396 @@ type Config struct {
 	// Default: none
 	// Public: Yes
 	IncludeMetricsMatchers IncludeMetricsMap `yaml:"include_matching_metrics" envconfig:"include_matching_metrics"`
+
+	// ProcessIncludeNames, ProcessIncludeCmdlines and ProcessIncludeUsers are lists of regular expressions on the
+	// process name, command line and user. When any of them is set, only the processes matching any of their
+	// expressions are sampled. Unlike include_matching_metrics, they are evaluated by the process sampler before
+	// harvesting the process metrics, cutting the agent CPU usage on hosts with thousands of processes. The command
+	// line is matched as reported, i.e. without arguments if strip_command_line is enabled.
+	// Default: Empty
+	// Public: Yes
+	ProcessIncludeNames    []string `yaml:"process_include_names" envconfig:"process_include_names" os:"linux"`
+	ProcessIncludeCmdlines []string `yaml:"process_include_cmdlines" envconfig:"process_include_cmdlines" os:"linux"`
+	ProcessIncludeUsers    []string `yaml:"process_include_users" envconfig:"process_include_users" os:"linux"`
+
+	// ProcessExcludeNames, ProcessExcludeCmdlines and ProcessExcludeUsers are lists of regular expressions on the
+	// process name, command line and user. The processes matching any of them are not sampled, even if they match
+	// the include lists.
+	// Default: Empty
+	// Public: Yes
+	ProcessExcludeNames    []string `yaml:"process_exclude_names" envconfig:"process_exclude_names" os:"linux"`
+	ProcessExcludeCmdlines []string `yaml:"process_exclude_cmdlines" envconfig:"process_exclude_cmdlines" os:"linux"`
+	ProcessExcludeUsers    []string `yaml:"process_exclude_users" envconfig:"process_exclude_users" os:"linux"`
+
+	// ProcessTopN When greater than zero, only the N processes using more CPU and the N processes using more
+	// memory are reported each interval, along with the processes whose name matches any of the
+	// ProcessTopNIncludeNames regular expressions. The rest are rolled up into a single ProcessSample, named
+	// "other", with the sum of their metrics and the number of processes in processCount.
+	// Default: 0
+	// Public: Yes
+	ProcessTopN             int      `yaml:"process_top_n" envconfig:"process_top_n" os:"linux"`
+	ProcessTopNIncludeNames []string `yaml:"process_top_n_include_names" envconfig:"process_top_n_include_names" os:"linux"`
+
+	// IntegrationsCircuitBreakerThreshold is the number of consecutive failed executions (non-zero exit or
+	// unparseable output) after which an integration is temporarily disabled. Disabled integrations are
+	// executed again after an exponentially increasing backoff. Each integration config entry has its own
+	// circuit breaker. Zero or negative values disable the circuit breaker.
+	// Default: 0
+	// Public: Yes
+	IntegrationsCircuitBreakerThreshold int `yaml:"integrations_circuit_breaker_threshold" envconfig:"integrations_circuit_breaker_threshold"`
+
+	// IntegrationsCircuitBreakerMaxBackoffSec is the maximum time, in seconds, an integration is disabled
+	// by its circuit breaker.
+	// Default: 3600
+	// Public: Yes
+	IntegrationsCircuitBreakerMaxBackoffSec int `yaml:"integrations_circuit_breaker_max_backoff_sec" envconfig:"integrations_circuit_breaker_max_backoff_sec"`
+
+	// IntegrationsTelemetryEnabled reports dimensional metrics after each integration execution: its
+	// duration, exit code, payload size, number of datasets and parse errors, tagged with the integration
+	// name and version. It requires the dimensional metrics (protocol v4) support to be enabled.
+	// Default: False
+	// Public: Yes
+	IntegrationsTelemetryEnabled bool `yaml:"integrations_telemetry_enabled" envconfig:"integrations_telemetry_enabled"`
+
+	// StatusServerEnabled enables a local HTTP server exposing the agent status, as the state of the
+	// integrations circuit breakers under /v1/status/integrations.
+	// Default: False
+	// Public: Yes
+	StatusServerEnabled bool `yaml:"status_server_enabled" envconfig:"status_server_enabled"`
+
+	// StatusServerPort is the localhost port where the status server listens.
+	// Default: 18003
+	// Public: Yes
+	StatusServerPort int `yaml:"status_server_port" envconfig:"status_server_port"`
+
+	// RecentSamplesRetentionMinutes keeps the samples emitted during the last minutes in memory, so they
+	// can be queried through the status server under /v1/status/samples, even if the backend is
+	// unreachable. The samples can be filtered by the eventType, entityKey, since (e.g. 5m) and limit
+	// parameters, and by where conditions on their attributes (e.g. where=cpuPercent>50). It requires the
+	// status server to be enabled. Zero disables it.
+	// Default: 0
+	// Public: Yes
+	RecentSamplesRetentionMinutes int `yaml:"recent_samples_retention_minutes" envconfig:"recent_samples_retention_minutes"`
+
+	// RecentSamplesMaxCount is the maximum number of samples kept for local querying. Once reached, the
+	// oldest samples are discarded.
+	// Default: 10000
+	// Public: Yes
+	RecentSamplesMaxCount int `yaml:"recent_samples_max_count" envconfig:"recent_samples_max_count"`
+
+	// DNSOverrides maps host names to static IP addresses that are used by the agent outbound connections
+	// instead of resolving them. Useful where the system DNS is unreliable or split-brain.
+	// Default: none
+	// Public: Yes
+	DNSOverrides map[string]string `yaml:"dns_overrides" envconfig:"dns_overrides"`
+
+	// DNSCacheTTLSec is the time, in seconds, the host names resolved for the agent outbound connections are
+	// cached. Zero disables the cache.
+	// Default: 0
+	// Public: Yes
+	DNSCacheTTLSec int `yaml:"dns_cache_ttl_sec" envconfig:"dns_cache_ttl_sec"`
+
+	// DNSServers are the "host[:port]" addresses of the DNS servers queried to resolve the agent outbound
+	// connections host names, instead of the system ones.
+	// Default: none
+	// Public: Yes
+	DNSServers []string `yaml:"dns_servers" envconfig:"dns_servers"`
+
+	// ConnectionAuditEnabled logs each new outbound connection opened by the agent, with the TLS handshake
+	// time and whether the TLS session was resumed, to troubleshoot the connection churn. The connections
+	// usage per endpoint is always served by the status server under /v1/status/connections.
+	// Default: False
+	// Public: Yes
+	ConnectionAuditEnabled bool `yaml:"connection_audit_enabled" envconfig:"connection_audit_enabled"`
+
+	// EnrichmentRulesFile is the path of a YAML file with rules that add or modify attributes of every sample
+	// at harvest time, ie: setting a business unit looked up by process user.
+	// Default: none
+	// Public: Yes
+	EnrichmentRulesFile string `yaml:"enrichment_rules_file" envconfig:"enrichment_rules_file"`
+
+	// PayloadTransforms is a list of CEL expressions applied, in order, to every event and dimensional metric
+	// just before it is batched for submission. Each expression returns the drop(), keep(), set(field, value)
+	// or remove(field) actions, ie: 'metric.name.startsWith("debug_") ? drop() : keep()'. Numbers are doubles,
+	// so they are compared with double literals, ie: 'event.cpuPercent > 90.0 ? set("hot", true) : keep()'.
+	// Default: none
+	// Public: Yes
+	PayloadTransforms []string `yaml:"payload_transforms" envconfig:"payload_transforms"`
+
+	// SilentWindows are recurring periods during which the listed samplers (ie: StorageSampler) aren't run, or
+	// their samples are tagged with the "silentWindow" attribute, so known noisy periods like the nightly backups
+	// don't trigger alerts. Each window starts at the times matching its "schedule" cron expression, in its
+	// optional "timezone", and lasts its "duration". Its "action" is pause (default) or tag. The integrations
+	// declare their silent windows in their own configuration.
+	// Default: none
+	// Public: Yes
+	SilentWindows []SilentWindow `yaml:"silent_windows" envconfig:"ignored"`
+
+	// AdditionalDestinations lists other accounts that also receive the events and dimensional metrics, ie: for
+	// managed-service providers reporting a host to both their account and the customer's one. Each destination
+	// has its "license_key" and optional "collector_url", which is calculated from the license key by default.
+	// Its "metrics_only" option sends only the samples and dimensional metrics, and its "attributes" are set on
+	// all the data sent to it, overriding existing ones. Inventory is only sent to the agent account, and the
+	// entity IDs, assigned per account, aren't sent to the additional destinations. Failed submissions to the
+	// additional destinations aren't retried, and the events are dropped while a destination is too slow to keep up.
+	// Default: none
+	// Public: Yes
+	AdditionalDestinations []Destination `yaml:"additional_destinations" envconfig:"ignored"`
+
+	// EgressRateLimits caps the submissions to the backend of each payload type, so the agent can run on
+	// bandwidth-constrained links. Its keys are the payload types: "inventory", "events" (which includes the
+	// samples), "metrics" (dimensional metrics) and "all", applied to the submissions of all the types. Each limit
+	// sets "requests_per_sec" and/or "bytes_per_sec", allowing one second of burst. Submissions over the limit wait
+	// for their turn, and those not sent before the submission timeout are retried as if the backend had failed.
+	// Default: none
+	// Public: Yes
+	EgressRateLimits map[string]RateLimit `yaml:"egress_rate_limits" envconfig:"ignored"`
+
+	// MetricsGPUSampleRate Sample rate of GPU Samples in seconds. On Windows, it also samples the RemoteFX
+	// graphics sessions. On Linux, NVIDIA GPUs are sampled through nvidia-smi, which must be in the PATH of
+	// the agent, and AMD GPUs through the amdgpu driver, also reporting the GPU memory used by each process.
+	// Minimum value is 5. If value is -1 then the sampler is disabled.
+	// Default: -1
+	// Public: Yes
+	MetricsGPUSampleRate int `yaml:"metrics_gpu_sample_rate" envconfig:"metrics_gpu_sample_rate"`
+
+	// MetricsSessionSampleRate Sample rate of remote desktop Session Samples in seconds. Minimum value is 5.
+	// If value is -1 then the sampler is disabled. Only available on Windows.
+	// Default: -1
+	// Public: Yes
+	MetricsSessionSampleRate int `yaml:"metrics_session_sample_rate" envconfig:"metrics_session_sample_rate"`
+
+	// SessionSampleUserAnonymization defines how the user, domain and client names of the remote desktop
+	// sessions are reported: "none" reports them as they are, "hash" reports a hash of their values and
+	// "redact" does not report them.
+	// Default: none
+	// Public: Yes
+	SessionSampleUserAnonymization string `yaml:"session_sample_user_anonymization" envconfig:"session_sample_user_anonymization"`
+
+	// MetricsNTPServerSampleRate Sample rate of NTP Server Samples in seconds, reporting the status of the
+	// chronyd or ntpd daemon of hosts acting as NTP servers. Minimum value is 10. If value is -1 then the
+	// sampler is disabled. Only available on Linux.
+	// Default: -1
+	// Public: Yes
+	MetricsNTPServerSampleRate int `yaml:"metrics_ntp_server_sample_rate" envconfig:"metrics_ntp_server_sample_rate"`
+
+	// MetricsProcessCrashSampleRate Sample rate of Process Crash Samples in seconds. Each sample reports the
+	// processes crashed since the previous one, as recorded by systemd-coredump, the core_pattern directory or
+	// apport on Linux, and by Windows Error Reporting on Windows. Minimum value is 10. If value is -1 then the
+	// sampler is disabled.
+	// Default: -1
+	// Public: Yes
+	MetricsProcessCrashSampleRate int `yaml:"metrics_process_crash_sample_rate" envconfig:"metrics_process_crash_sample_rate"`
+
+	// MetricsPowerSampleRate Sample rate of Power Samples in seconds. Each sample reports the power drawn by
+	// the CPU packages, from the Intel RAPL or AMD energy counters, the status of the batteries and power
+	// supplies, and an estimation of the power drawn by the host. Reading the energy counters may require the
+	// agent to run as root. Minimum value is 10. If value is -1 then the sampler is disabled.
+	// Default: -1
+	// Public: Yes
+	MetricsPowerSampleRate int `yaml:"metrics_power_sample_rate" envconfig:"metrics_power_sample_rate" os:"linux"`
+
+	// MetricsStorageCgroupSampleRate Sample rate of Storage Cgroup Samples in seconds. Each sample reports the
+	// IO of a systemd service or scope (including containers) in a block device, from the io.stat file of the
+	// cgroup v2 unified hierarchy, so the services hammering a disk can be told apart. The cgroups without IO
+	// during the sample interval are not reported. Minimum value is 10. If value is -1 then the sampler is disabled.
+	// Default: -1
+	// Public: Yes
+	MetricsStorageCgroupSampleRate int `yaml:"metrics_storage_cgroup_sample_rate" envconfig:"metrics_storage_cgroup_sample_rate" os:"linux"`
+
+	// MetricsSensorSampleRate Sample rate of Sensor Samples in seconds. Each sample reports a hardware
+	// temperature sensor (CPU packages, disks and other components) or fan, from the hwmon drivers. The
+	// kinds of sensors not exposed by hwmon, usually the fans of servers, are read through IPMI if ipmitool
+	// is installed. Minimum value is 10. If value is -1 then the sampler is disabled.
+	// Default: -1
+	// Public: Yes
+	MetricsSensorSampleRate int `yaml:"metrics_sensor_sample_rate" envconfig:"metrics_sensor_sample_rate" os:"linux"`
+
+	// MetricsSmartSampleRate Sample rate of SMART Samples in seconds. Each sample reports the SMART health of
+	// a disk, read through smartctl (smartmontools 7.0 or newer), with its reallocated sectors, media errors
+	// and wear level. An InfrastructureEvent is reported when the predicted health of a disk degrades. Disks
+	// in standby are not woken up. Minimum value is 300. If value is -1 then the sampler is disabled.
+	// Default: -1
+	// Public: Yes
+	MetricsSmartSampleRate int `yaml:"metrics_smart_sample_rate" envconfig:"metrics_smart_sample_rate"`
+
+	// MetricsConnectionSampleRate Sample rate of Network Connection Samples in seconds. Each sample reports the
+	// TCP connections of the host by state (ESTABLISHED, TIME_WAIT, CLOSE_WAIT...), and a Network Listen Port
+	// Sample is reported per listening port with the connections it accepted and the length of its accept
+	// queue. Minimum value is 10. If value is -1 then the sampler is disabled.
+	// Default: -1
+	// Public: Yes
+	MetricsConnectionSampleRate int `yaml:"metrics_connection_sample_rate" envconfig:"metrics_connection_sample_rate" os:"linux"`
+
+	// MetricsTCPHealthSampleRate Sample rate of TCP Health Samples in seconds. Each sample reports the TCP
+	// retransmissions, round trip time percentiles and connect latency of the connections to a destination,
+	// traced with eBPF. It requires bpftrace and Linux 4.16 or newer, the round trip times also need the kernel
+	// BTF type information. If eBPF tracing is unavailable, a single sample reports the retransmissions and
+	// connection failures of the whole host. Minimum value is 10. If value is -1 then the sampler is disabled.
+	// Default: -1
+	// Public: Yes
+	MetricsTCPHealthSampleRate int `yaml:"metrics_tcp_health_sample_rate" envconfig:"metrics_tcp_health_sample_rate" os:"linux"`
+
+	// MetricsSystemdUnitSampleRate Sample rate of Systemd Unit Samples in seconds. Each sample reports the state,
+	// restart count and resource usage of one of the units listed in systemd_units, read through D-Bus. An
+	// InfrastructureEvent is reported when a unit fails or is restarted. Minimum value is 10. If value is -1 then
+	// the sampler is disabled.
+	// Default: 30
+	// Public: Yes
+	MetricsSystemdUnitSampleRate int `yaml:"metrics_systemd_unit_sample_rate" envconfig:"metrics_systemd_unit_sample_rate" os:"linux"`
+
+	// SystemdUnits is the list of systemd units reported by the Systemd Unit Samples, e.g. [nginx.service,
+	// "postgresql@*.service"]. Shell-style wildcards are accepted. The units not loaded by systemd are not
+	// reported. Empty disables the sampler.
+	// Default: Empty
+	// Public: Yes
+	SystemdUnits []string `yaml:"systemd_units" envconfig:"systemd_units" os:"linux"`
+
+	// EnableProcessNetworkMetrics reports, along with each set of NetworkSamples, a ProcessNetworkSample per
+	// process with the TCP and UDP bytes it sent and received, traced through the Microsoft-Windows-Kernel-Network
+	// ETW provider. The processes without network traffic during the sample interval are not reported.
+	// Default: false
+	// Public: Yes
+	EnableProcessNetworkMetrics bool `yaml:"enable_process_network_metrics" envconfig:"enable_process_network_metrics" os:"windows"`
+
+	// EnableCPUCoreMetrics reports, along with each SystemSample, a CPUCoreSample per CPU core with its
+	// utilization, steal and, on Linux, its frequency and NUMA node. It helps tracking latency sensitive
+	// workloads pinned to specific cores, whose saturation is hidden by the aggregated CPU utilization.
+	// Default: false
+	// Public: Yes
+	EnableCPUCoreMetrics bool `yaml:"enable_cpu_core_metrics" envconfig:"enable_cpu_core_metrics"`
+
+	// CPUCoreMetricsCPUs restricts the CPUCoreSamples to a list of CPUs in the format of the kernel CPU
+	// lists, e.g. "0-3,8,10-11". Hosts with many cores can use it to only report the isolated ones.
+	// Default: all the CPUs
+	// Public: Yes
+	CPUCoreMetricsCPUs string `yaml:"cpu_core_metrics_cpus" envconfig:"cpu_core_metrics_cpus"`
+
+	// CPUCoreMetricsMaxCores is the maximum number of CPUCoreSamples reported on each SystemSample
+	// interval, so the data reported by hosts with hundreds of cores is bounded. The CPUs with the lowest
+	// IDs are the reported ones.
+	// Default: 64
+	// Public: Yes
+	CPUCoreMetricsMaxCores int `yaml:"cpu_core_metrics_max_cores" envconfig:"cpu_core_metrics_max_cores"`
+
+	// EnableNUMANodeMetrics reports, along with each SystemSample, a NUMANodeSample per NUMA node with the
+	// utilization, steal and average frequency of its CPUs. Hosts with a single node don't report it.
+	// Default: false
+	// Public: Yes
+	EnableNUMANodeMetrics bool `yaml:"enable_numa_node_metrics" envconfig:"enable_numa_node_metrics" os:"linux"`
+
+	// CgroupRelativeUtilization reports the SystemSample CPU and memory utilization relative to the cgroup
+	// limits of the agent (CPU quota and memory limit, on cgroup v1 or v2) instead of the host totals. It's
+	// meant for agents running in a container with resource limits, so the percentages reflect the sandbox.
+	// Limits that aren't set keep reporting the host utilization.
+	// Default: false
+	// Public: Yes
+	CgroupRelativeUtilization bool `yaml:"cgroup_relative_utilization" envconfig:"cgroup_relative_utilization" os:"linux"`
+
+	// IntegrationsBundles lists the integration bundles to install on startup. Each bundle is a gzipped tar
+	// archive with the "bin", "definitions" and "config" folders of one or more integrations, downloaded from
+	// its "url" or from "<integrations_bundles_repository>/<name>/<version>.tar.gz". Bundles are verified
+	// against their "sha256" checksum and/or the integrations_bundles_public_keys before being installed;
+	// unverified bundles are rejected. A bundle failing to download, verify or install keeps its previous
+	// installation.
+	// Default: none
+	// Public: Yes
+	IntegrationsBundles []IntegrationsBundle `yaml:"integrations_bundles" envconfig:"ignored"`
+
+	// IntegrationsBundlesRepository is the base URL of an internal repository serving the integration
+	// bundles without an explicit URL.
+	// Default: none
+	// Public: Yes
+	IntegrationsBundlesRepository string `yaml:"integrations_bundles_repository" envconfig:"integrations_bundles_repository"`
+
+	// IntegrationsBundlesPublicKeys are the paths of the PEM encoded Ed25519 public keys trusted to sign the
+	// integration bundles. If any is set, every bundle requires a valid detached signature, published next to
+	// its archive with the ".sig" suffix.
+	// Default: none
+	// Public: Yes
+	IntegrationsBundlesPublicKeys []string `yaml:"integrations_bundles_public_keys" envconfig:"integrations_bundles_public_keys"`
+
+	// IntegrationsDebugCaptureName is the name of an integration whose next raw payloads and resolved
+	// configurations are stored, with their sensitive values obfuscated, into the
+	// integrations_debug_capture_dir, without enabling the verbose logs. A capture can also be started
+	// with the "capture_integration" command.
+	// Default: none
+	// Public: Yes
+	IntegrationsDebugCaptureName string `yaml:"integrations_debug_capture_name" envconfig:"integrations_debug_capture_name"`
+
+	// IntegrationsDebugCapturePayloads is the number of payloads captured from the
+	// integrations_debug_capture_name integration.
+	// Default: 10
+	// Public: Yes
+	IntegrationsDebugCapturePayloads int `yaml:"integrations_debug_capture_payloads" envconfig:"integrations_debug_capture_payloads"`
+
+	// IntegrationsDebugCaptureDir is the directory where the integration captures are stored, in a folder
+	// per integration.
+	// Default: <agent_dir>/integrations-capture
+	// Public: Yes
+	IntegrationsDebugCaptureDir string `yaml:"integrations_debug_capture_dir" envconfig:"integrations_debug_capture_dir"`
+
+	// IntegrationsDerivedMetricsDir is the directory where the previous values of the integrations
+	// derived_metrics are stored, so their rates and deltas continue across agent restarts.
+	// Default: <agent_dir>/integrations-derived-metrics
+	// Public: Yes
+	IntegrationsDerivedMetricsDir string `yaml:"integrations_derived_metrics_dir" envconfig:"integrations_derived_metrics_dir"`
+}
+
+// IntegrationsBundle is an integration bundle to install, as configured in integrations_bundles.
+type IntegrationsBundle struct {
+	Name    string `yaml:"name"`
+	Version string `yaml:"version"`
+	URL     string `yaml:"url"`
+	SHA256  string `yaml:"sha256"`
+}
+
+// SilentWindow is a recurring period for some samplers, as configured in silent_windows.
+type SilentWindow struct {
+	Name     string   `yaml:"name"`
+	Schedule string   `yaml:"schedule"`
+	Duration string   `yaml:"duration"`
+	Timezone string   `yaml:"timezone"`
+	Action   string   `yaml:"action"`
+	Samplers []string `yaml:"samplers"`
+}
+
+// Destination is another account the telemetry is sent to, as configured in additional_destinations.
+type Destination struct {
+	Name         string            `yaml:"name"`
+	LicenseKey   string            `yaml:"license_key"`
+	CollectorURL string            `yaml:"collector_url"`
+	MetricsOnly  bool              `yaml:"metrics_only"`
+	Attributes   map[string]string `yaml:"attributes"`
+	// MetricURL is calculated from the collector URL and license key.
+	MetricURL string `yaml:"-"`
+}
+
+// InventoryNoiseRule matches inventory attributes whose changes are ignored, as configured in inventory_noise_rules.
+type InventoryNoiseRule struct {
+	Plugin string `yaml:"plugin"`
+	Path   string `yaml:"path"`
+	Value  string `yaml:"value"`
+}
+
+// Payload types of the egress_rate_limits.
+const (
+	EgressAll       = "all"
+	EgressInventory = "inventory"
+	EgressEvents    = "events"
+	EgressMetrics   = "metrics"
+)
+
+// Algorithms of the payload_compression.
+const (
+	PayloadCompressionGzip = "gzip"
+	PayloadCompressionZstd = "zstd"
+)
+
+// RateLimit caps a payload type submissions, as configured in egress_rate_limits. Zero values don't limit.
+type RateLimit struct {
+	RequestsPerSec float64 `yaml:"requests_per_sec"`
+	BytesPerSec    float64 `yaml:"bytes_per_sec"`
 }
 
 // Troubleshoot trobleshoot mode configuration.
@@ -1063,6 +1697,15 @@ func (c *Config) IsTroubleshootMode() bool {
 	return c.Verbose == TroubleshootLogging
 }
 
+// PayloadGzipLevel returns the gzip level the senders compress their payloads with. The payloads compressed with
+// zstd are left uncompressed by the senders, as the shared transport compresses them.
+func (c *Config) PayloadGzipLevel() int {
+	if c.PayloadCompression == PayloadCompressionZstd {
+		return gzip.NoCompression
+	}
+	return c.PayloadCompressionLevel
+}
+
 // GetDefaultLogFile sets log file to defined app data dir or default.
 func (c *Config) GetDefaultLogFile() string {
 	if c.AppDataDir == "" {
@@ -1209,6 +1852,14 @@ func LoadConfig(configFile string) (*Config, error) {
 	// After the config file has loaded,  override via any environment variables
 	configOverride(cfg)
 
+	if err = resolveLicenseVariables(cfg); err != nil {
+		return cfg, err
+	}
+
+	if err = resolveBootstrapLicense(cfg); err != nil {
+		return cfg, err
+	}
+
 	cfg.RunMode, cfg.AgentUser, cfg.ExecutablePath = runtimeValues()
 
 	// Move any other post processing steps that clean up or announce settings to be
@@ -1243,6 +1894,7 @@ func NewConfig() *Config {
 		HTTPServerHost:                defaultHTTPServerHost,
 		HTTPServerPort:                defaultHTTPServerPort,
 		DockerApiVersion:              DefaultDockerApiVersion,
+		ContainerRuntimeEndpoint:      DefaultContainerRuntimeEndpoint,
 		FingerprintUpdateFreqSec:      defaultFingerprintUpdateFreqSec,
 		CloudMetadataExpiryInSec:      defaultCloudMetadataExpiryInSec,
 		RegisterConcurrency:           defaultRegisterConcurrency,
@@ -1275,6 +1927,11 @@ func NewConfig() *Config {
 		OfflineTimeToReset:          DefaultOfflineTimeToReset,
 		FilesConfigOn:               defaultFilesConfigOn,
 		PayloadCompressionLevel:     defaultPayloadCompressionLevel,
+		PayloadSpoolMaxAgeSec:       defaultPayloadSpoolMaxAgeSec,
+		ShutdownFlushTimeoutSec:     defaultShutdownFlushTimeoutSec,
+		ThrottleMaxFactor:           defaultThrottleMaxFactor,
+		ThrottleRecoverySec:         defaultThrottleRecoverySec,
+		PayloadCompression:          defaultPayloadCompression,
 		EnableWinUpdatePlugin:       defaultWinUpdatePlugin,
 		LogToStdout:                 defaultLogToStdout,
 		IpData:                      defaultIpData,
@@ -1286,6 +1943,29 @@ func NewConfig() *Config {
 		DefaultIntegrationsTempDir:  defaultIntegrationsTempDir,
 		IncludeMetricsMatchers:      defaultMetricsMatcherConfig,
 		InventoryQueueLen:           DefaultInventoryQueue,
+
+		IntegrationsCircuitBreakerThreshold:     defaultIntegrationsCircuitBreakerThreshold,
+		IntegrationsCircuitBreakerMaxBackoffSec: defaultIntegrationsCircuitBreakerMaxBackoffSec,
+		StatusServerPort:                        defaultStatusServerPort,
+		RecentSamplesMaxCount:                   defaultRecentSamplesMaxCount,
+		MetricsGPUSampleRate:                    defaultMetricsGPUSampleRate,
+		MetricsSessionSampleRate:                defaultMetricsSessionSampleRate,
+		SessionSampleUserAnonymization:          defaultSessionSampleUserAnonymization,
+		MetricsNTPServerSampleRate:              defaultMetricsNTPServerSampleRate,
+		MetricsProcessCrashSampleRate:           defaultMetricsProcessCrashSampleRate,
+		MetricsPowerSampleRate:                  defaultMetricsPowerSampleRate,
+		MetricsStorageCgroupSampleRate:          defaultMetricsStorageCgroupSampleRate,
+		MetricsSensorSampleRate:                 defaultMetricsSensorSampleRate,
+		MetricsSmartSampleRate:                  defaultMetricsSmartSampleRate,
+		MetricsConnectionSampleRate:             defaultMetricsConnectionSampleRate,
+		MetricsTCPHealthSampleRate:              defaultMetricsTCPHealthSampleRate,
+		MetricsSystemdUnitSampleRate:            defaultMetricsSystemdUnitSampleRate,
+		CPUCoreMetricsMaxCores:                  defaultCPUCoreMetricsMaxCores,
+		IntegrationsDebugCapturePayloads:        defaultIntegrationsDebugCapturePayloads,
+		TLSCertificateExpiryThresholdDays:       defaultTLSCertificateExpiryThresholdDays,
+		InventoryStoreBackend:                   defaultInventoryStoreBackend,
+		EnableWindowsFeaturesInventory:          defaultWindowsFeaturesInventory,
+		EnablePendingRebootInventory:            defaultPendingRebootInventory,
 	}
 }
 
@@ -1317,6 +1997,24 @@ func (c Config) GenerateInventoryURL() string {
 	return strings.TrimSuffix(inventoryURL, "/")
 }
 
+// normalizeSampleRate validates the sample rate of a sampler, in seconds, raising it to the minimum of the
+// sampler. Unset (zero) rates take the minimum silently, while the configured ones are warned about. Disabled
+// samplers (-1) are kept as is.
+func normalizeSampleRate(nlog log.Entry, option string, rate, minimum int) int {
+	switch {
+	case rate == FREQ_DEFAULT_SAMPLING:
+		return minimum
+	case rate > FREQ_DISABLE_SAMPLING && rate < minimum:
+		nlog.WithFields(logrus.Fields{
+			"option":   option,
+			"provided": rate,
+			"minimum":  minimum,
+		}).Warn("Sample rate is below the minimum, using the minimum.")
+		return minimum
+	}
+	return rate
+}
+
 func isConfigDefined(key string, cfgMetadata config_loader.YAMLMetadata) bool {
 	prefixedKey := strings.ToUpper(fmt.Sprint(envPrefix, "_", key))
 	if os.Getenv(prefixedKey) != "" {
@@ -1462,7 +2160,7 @@ func NormalizeConfig(cfg *Config, cfgMetadata config_loader.YAMLMetadata) (err e
 
 	// Setting default values
 	if cfg.License == "" {
-		err = fmt.Errorf("no license key, please add it to agent's config file or NRIA_LICENSE_KEY environment variable")
+		err = fmt.Errorf("no license key, please add it to agent's config file or NRIA_LICENSE_KEY environment variable, or provide a bootstrap token")
 		return
 	}
 
@@ -1498,6 +2196,21 @@ func NormalizeConfig(cfg *Config, cfgMetadata config_loader.YAMLMetadata) (err e
 
 	nlog.WithField("collectorURL", cfg.CollectorURL).Debug("Collector URL")
 
+	destinations := cfg.AdditionalDestinations[:0]
+	for _, d := range cfg.AdditionalDestinations {
+		d.LicenseKey = strings.TrimSpace(d.LicenseKey)
+		if !license.IsValid(d.LicenseKey) {
+			nlog.WithField("destination", d.Name).Warn("Ignoring additional destination with an invalid license key")
+			continue
+		}
+		d.MetricURL = calculateDimensionalMetricURL(d.CollectorURL, d.LicenseKey, cfg.Staging)
+		if d.CollectorURL == "" {
+			d.CollectorURL = calculateCollectorURL(d.LicenseKey, cfg.Staging)
+		}
+		destinations = append(destinations, d)
+	}
+	cfg.AdditionalDestinations = destinations
+
 	if cfg.IdentityURL == "" {
 		cfg.IdentityURL = calculateIdentityURL(cfg.License, cfg.Staging)
 	}
@@ -1566,6 +2279,14 @@ func NormalizeConfig(cfg *Config, cfgMetadata config_loader.YAMLMetadata) (err e
 		cfg.FluentBitNRLibPath = filepath.Join(cfg.LoggingBinDir, defaultFluentBitNRLib)
 	}
 
+	if cfg.IntegrationsDebugCaptureDir == "" {
+		cfg.IntegrationsDebugCaptureDir = filepath.Join(cfg.AgentDir, defaultIntegrationsDebugCaptureDir)
+	}
+
+	if cfg.IntegrationsDerivedMetricsDir == "" {
+		cfg.IntegrationsDerivedMetricsDir = filepath.Join(cfg.AgentDir, defaultIntegrationsDerivedMetricsDir)
+	}
+
 	cfg.PluginInstanceDirs = helpers.RemoveEmptyAndDuplicateEntries(
 		[]string{cfg.PluginDir, defaultPluginInstanceDir, filepath.Join(cfg.AgentDir, defaultPluginActiveConfigsDir)})
 
@@ -1590,6 +2311,63 @@ func NormalizeConfig(cfg *Config, cfgMetadata config_loader.YAMLMetadata) (err e
 	}
 	nlog.WithField("PayloadCompressionLevel", cfg.PayloadCompressionLevel).Debug("Payload Compression Level.")
 
+	if cfg.InventoryStoreCompressionLevel < gzip.HuffmanOnly || cfg.InventoryStoreCompressionLevel > gzip.BestCompression {
+		nlog.WithField("provided", cfg.InventoryStoreCompressionLevel).
+			Warn("Inventory store compression level is invalid, the inventory files won't be compressed")
+		cfg.InventoryStoreCompressionLevel = gzip.NoCompression
+	}
+
+	if cfg.PayloadSpoolMaxSize > 0 && cfg.PayloadSpoolMaxAgeSec <= 0 {
+		nlog.WithField("provided", cfg.PayloadSpoolMaxAgeSec).
+			Warn("Payload spool max age is invalid, overriding it to the default")
+		cfg.PayloadSpoolMaxAgeSec = defaultPayloadSpoolMaxAgeSec
+	}
+
+	for payloadType, limit := range cfg.EgressRateLimits {
+		switch payloadType {
+		case EgressAll, EgressInventory, EgressEvents, EgressMetrics:
+		default:
+			nlog.WithField("payloadType", payloadType).Warn("Ignoring egress rate limit of unknown payload type")
+			delete(cfg.EgressRateLimits, payloadType)
+			continue
+		}
+		if limit.RequestsPerSec < 0 || limit.BytesPerSec < 0 {
+			nlog.WithField("payloadType", payloadType).Warn("Ignoring negative egress rate limit")
+			delete(cfg.EgressRateLimits, payloadType)
+		}
+	}
+
+	switch cfg.PayloadCompression {
+	case PayloadCompressionGzip, PayloadCompressionZstd:
+	default:
+		nlog.WithField("provided", cfg.PayloadCompression).
+			Warn("Payload compression algorithm is invalid, overriding it to the default")
+		cfg.PayloadCompression = defaultPayloadCompression
+	}
+
+	if cfg.ThrottleMaxFactor < 1 {
+		nlog.WithField("provided", cfg.ThrottleMaxFactor).
+			Warn("Throttle max factor is invalid, the agent won't slow down when throttled")
+		cfg.ThrottleMaxFactor = 1
+	}
+	if cfg.ThrottleRecoverySec <= 0 {
+		nlog.WithField("provided", cfg.ThrottleRecoverySec).
+			Warn("Throttle recovery time is invalid, overriding it to the default")
+		cfg.ThrottleRecoverySec = defaultThrottleRecoverySec
+	}
+
+	if cfg.ShutdownFlushTimeoutSec < 0 {
+		nlog.WithField("provided", cfg.ShutdownFlushTimeoutSec).
+			Warn("Shutdown flush timeout is invalid, the agent will stop without flushing")
+		cfg.ShutdownFlushTimeoutSec = 0
+	}
+
+	if cfg.RecentSamplesMaxCount < 0 {
+		nlog.WithField("provided", cfg.RecentSamplesMaxCount).
+			Warn("Recent samples max count is invalid, overriding it to the default")
+		cfg.RecentSamplesMaxCount = defaultRecentSamplesMaxCount
+	}
+
 	nlog.WithField("CompactEnabled", cfg.CompactEnabled).Debug("Repository compaction.")
 
 	if cfg.CompactThreshold == 0 {
@@ -1598,25 +2376,36 @@ func NormalizeConfig(cfg *Config, cfgMetadata config_loader.YAMLMetadata) (err e
 		cfg.CompactThreshold = cfg.CompactThreshold * 1024 * 1024
 	}
 
-	if cfg.MetricsSystemSampleRate < FREQ_INTERVAL_FLOOR_SYSTEM_METRICS && cfg.MetricsSystemSampleRate > FREQ_DISABLE_SAMPLING {
-		cfg.MetricsSystemSampleRate = FREQ_INTERVAL_FLOOR_SYSTEM_METRICS
-	}
+	cfg.MetricsSystemSampleRate = normalizeSampleRate(nlog, "metrics_system_sample_rate", cfg.MetricsSystemSampleRate, FREQ_INTERVAL_FLOOR_SYSTEM_METRICS)
 	nlog.WithField("MetricsSystemSampleRate", cfg.MetricsSystemSampleRate).Debug("Metrics System Sample Rate.")
 
-	if cfg.MetricsStorageSampleRate < FREQ_INTERVAL_FLOOR_STORAGE_METRICS && cfg.MetricsStorageSampleRate > FREQ_DISABLE_SAMPLING {
+	if cfg.MetricsStorageSampleRate == FREQ_DEFAULT_SAMPLING {
 		cfg.MetricsStorageSampleRate = DefaultStorageSamplerRateSecs
 	}
+	cfg.MetricsStorageSampleRate = normalizeSampleRate(nlog, "metrics_storage_sample_rate", cfg.MetricsStorageSampleRate, FREQ_INTERVAL_FLOOR_STORAGE_METRICS)
 	nlog.WithField("MetricsStorageSampleRate", cfg.MetricsStorageSampleRate).Debug("Metrics Storage Sample Rate.")
 
-	if cfg.MetricsNetworkSampleRate < FREQ_INTERVAL_FLOOR_STORAGE_METRICS && cfg.MetricsNetworkSampleRate > FREQ_DISABLE_SAMPLING {
-		cfg.MetricsNetworkSampleRate = FREQ_INTERVAL_FLOOR_STORAGE_METRICS
-	}
+	cfg.MetricsNetworkSampleRate = normalizeSampleRate(nlog, "metrics_network_sample_rate", cfg.MetricsNetworkSampleRate, FREQ_INTERVAL_FLOOR_STORAGE_METRICS)
 	nlog.WithField("MetricsNetworkSampleRate", cfg.MetricsNetworkSampleRate).Debug("Metrics Network Sample Rate.")
 
-	if cfg.MetricsProcessSampleRate < FREQ_INTERVAL_FLOOR_PROCESS_METRICS && cfg.MetricsProcessSampleRate > FREQ_DISABLE_SAMPLING {
-		cfg.MetricsProcessSampleRate = FREQ_INTERVAL_FLOOR_PROCESS_METRICS
+	cfg.MetricsProcessSampleRate = normalizeSampleRate(nlog, "metrics_process_sample_rate", cfg.MetricsProcessSampleRate, FREQ_INTERVAL_FLOOR_PROCESS_METRICS)
+	nlog.WithField("MetricsProcessSampleRate", cfg.MetricsProcessSampleRate).Debug("Metrics Process Sample Rate.")
+
+	cfg.MetricsGPUSampleRate = normalizeSampleRate(nlog, "metrics_gpu_sample_rate", cfg.MetricsGPUSampleRate, FREQ_INTERVAL_FLOOR_SYSTEM_METRICS)
+	cfg.MetricsSessionSampleRate = normalizeSampleRate(nlog, "metrics_session_sample_rate", cfg.MetricsSessionSampleRate, FREQ_INTERVAL_FLOOR_SYSTEM_METRICS)
+	cfg.MetricsNTPServerSampleRate = normalizeSampleRate(nlog, "metrics_ntp_server_sample_rate", cfg.MetricsNTPServerSampleRate, FREQ_INTERVAL_FLOOR_NETWORK_METRICS)
+	cfg.MetricsProcessCrashSampleRate = normalizeSampleRate(nlog, "metrics_process_crash_sample_rate", cfg.MetricsProcessCrashSampleRate, FREQ_INTERVAL_FLOOR_NETWORK_METRICS)
+	cfg.MetricsPowerSampleRate = normalizeSampleRate(nlog, "metrics_power_sample_rate", cfg.MetricsPowerSampleRate, FREQ_INTERVAL_FLOOR_NETWORK_METRICS)
+	cfg.MetricsStorageCgroupSampleRate = normalizeSampleRate(nlog, "metrics_storage_cgroup_sample_rate", cfg.MetricsStorageCgroupSampleRate, FREQ_INTERVAL_FLOOR_NETWORK_METRICS)
+	cfg.MetricsSensorSampleRate = normalizeSampleRate(nlog, "metrics_sensor_sample_rate", cfg.MetricsSensorSampleRate, FREQ_INTERVAL_FLOOR_NETWORK_METRICS)
+	cfg.MetricsSmartSampleRate = normalizeSampleRate(nlog, "metrics_smart_sample_rate", cfg.MetricsSmartSampleRate, FREQ_INTERVAL_FLOOR_SMART_METRICS)
+	cfg.MetricsConnectionSampleRate = normalizeSampleRate(nlog, "metrics_connection_sample_rate", cfg.MetricsConnectionSampleRate, FREQ_INTERVAL_FLOOR_NETWORK_METRICS)
+	cfg.MetricsTCPHealthSampleRate = normalizeSampleRate(nlog, "metrics_tcp_health_sample_rate", cfg.MetricsTCPHealthSampleRate, FREQ_INTERVAL_FLOOR_NETWORK_METRICS)
+	cfg.MetricsSystemdUnitSampleRate = normalizeSampleRate(nlog, "metrics_systemd_unit_sample_rate", cfg.MetricsSystemdUnitSampleRate, FREQ_INTERVAL_FLOOR_NETWORK_METRICS)
+
+	if cfg.CPUCoreMetricsMaxCores <= 0 {
+		cfg.CPUCoreMetricsMaxCores = defaultCPUCoreMetricsMaxCores
 	}
-	nlog.WithField("MetricsNetworkSampleRate", cfg.MetricsProcessSampleRate).Debug("Metrics Process Sample Rate.")
 
 	nlog.WithField("FilesConfigOn", cfg.FilesConfigOn).Debug("Configuration file monitoring.")
 
@@ -3,9 +3,13 @@
 package config
 
 import (
+	"compress/gzip"
 	"fmt"
 	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
 	"reflect"
 	"runtime"
 	"testing"
@@ -60,6 +64,218 @@ remove_entities_period: 1h
 	c.Assert(cfg.RemoveEntitiesPeriod, Equals, "1h")
 }
 
+func (s *ConfigSuite) TestParseConfigSampleRates(c *C) {
+	config := `
+license_key: abc123
+metrics_system_sample_rate: 15
+metrics_process_sample_rate: 60
+metrics_network_sample_rate: 1
+metrics_storage_sample_rate: -1
+metrics_gpu_sample_rate: 0
+`
+	f, err := ioutil.TempFile("", "opsmatic_config_test")
+	c.Assert(err, IsNil)
+	defer os.Remove(f.Name())
+	f.WriteString(config)
+	f.Close()
+
+	cfg, err := LoadConfig(f.Name())
+	c.Assert(err, IsNil)
+	c.Assert(cfg.MetricsSystemSampleRate, Equals, 15)
+	c.Assert(cfg.MetricsProcessSampleRate, Equals, 60)
+	c.Assert(cfg.MetricsNetworkSampleRate, Equals, FREQ_INTERVAL_FLOOR_STORAGE_METRICS)
+	c.Assert(cfg.MetricsStorageSampleRate, Equals, FREQ_DISABLE_SAMPLING)
+	c.Assert(cfg.MetricsGPUSampleRate, Equals, FREQ_INTERVAL_FLOOR_SYSTEM_METRICS)
+}
+
+func (s *ConfigSuite) TestParseConfigLicenseVariables(c *C) {
+	vault := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"data":{"data":{"key":"abc123"}}}`))
+	}))
+	defer vault.Close()
+	config := fmt.Sprintf(`
+license_key: ${newrelic.key}
+variables:
+  newrelic:
+    vault:
+      http:
+        url: %s
+`, vault.URL)
+	f, err := ioutil.TempFile("", "opsmatic_config_test")
+	c.Assert(err, IsNil)
+	defer os.Remove(f.Name())
+	f.WriteString(config)
+	f.Close()
+
+	cfg, err := LoadConfig(f.Name())
+	c.Assert(err, IsNil)
+	c.Assert(cfg.License, Equals, "abc123")
+}
+
+func (s *ConfigSuite) TestParseConfigBootstrapToken(c *C) {
+	var exchanges int
+	bootstrap := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.Header.Get("Authorization") != "Bearer tmp-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		exchanges++
+		_, _ = w.Write([]byte(`{"licenseKey":"abc123"}`))
+	}))
+	defer bootstrap.Close()
+	agentDir, err := ioutil.TempDir("", "bootstrap_test")
+	c.Assert(err, IsNil)
+	defer os.RemoveAll(agentDir)
+	config := fmt.Sprintf(`
+bootstrap_token: tmp-token
+bootstrap_url: %s
+agent_dir: %s
+`, bootstrap.URL, agentDir)
+	f, err := ioutil.TempFile("", "opsmatic_config_test")
+	c.Assert(err, IsNil)
+	defer os.Remove(f.Name())
+	f.WriteString(config)
+	f.Close()
+
+	cfg, err := LoadConfig(f.Name())
+	c.Assert(err, IsNil)
+	c.Assert(cfg.License, Equals, "abc123")
+	info, err := os.Stat(filepath.Join(agentDir, bootstrapLicenseFile))
+	c.Assert(err, IsNil)
+	c.Assert(info.Mode().Perm(), Equals, os.FileMode(0600))
+
+	// once the token expires, the stored license key is used
+	bootstrap.Close()
+	cfg, err = LoadConfig(f.Name())
+	c.Assert(err, IsNil)
+	c.Assert(cfg.License, Equals, "abc123")
+	c.Assert(exchanges, Equals, 1)
+}
+
+func (s *ConfigSuite) TestParseConfigBootstrapTokenRejected(c *C) {
+	bootstrap := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer bootstrap.Close()
+	agentDir, err := ioutil.TempDir("", "bootstrap_test")
+	c.Assert(err, IsNil)
+	defer os.RemoveAll(agentDir)
+
+	cfg := NewConfig()
+	cfg.AgentDir = agentDir
+	cfg.BootstrapToken = "expired"
+	cfg.BootstrapURL = bootstrap.URL
+
+	c.Assert(resolveBootstrapLicense(cfg), NotNil)
+	c.Assert(cfg.License, Equals, "")
+}
+
+func (s *ConfigSuite) TestParseConfigAdditionalDestinations(c *C) {
+	config := `
+license_key: abc123
+additional_destinations:
+  - name: customer
+    license_key: eu01xx0123456789012345678901234567890NRAL
+    metrics_only: true
+    attributes:
+      team: msp
+  - name: staging
+    license_key: 0123456789012345678901234567890123456789
+    collector_url: https://staging-collector.newrelic.com
+  - name: invalid
+    license_key: not valid
+`
+	f, err := ioutil.TempFile("", "opsmatic_config_test")
+	c.Assert(err, IsNil)
+	defer os.Remove(f.Name())
+	f.WriteString(config)
+	f.Close()
+
+	cfg, err := LoadConfig(f.Name())
+	c.Assert(err, IsNil)
+	c.Assert(cfg.AdditionalDestinations, HasLen, 2)
+	customer := cfg.AdditionalDestinations[0]
+	c.Assert(customer.MetricsOnly, Equals, true)
+	c.Assert(customer.Attributes, DeepEquals, map[string]string{"team": "msp"})
+	c.Assert(customer.CollectorURL, Equals, "https://infra-api.eu.newrelic.com")
+	c.Assert(customer.MetricURL, Equals, "https://metric-api.eu.newrelic.com")
+	staging := cfg.AdditionalDestinations[1]
+	c.Assert(staging.CollectorURL, Equals, "https://staging-collector.newrelic.com")
+	c.Assert(staging.MetricURL, Equals, "https://staging-collector.newrelic.com")
+}
+
+func (s *ConfigSuite) TestParseConfigEgressRateLimits(c *C) {
+	config := `
+license_key: abc123
+egress_rate_limits:
+  inventory:
+    requests_per_sec: 0.5
+  all:
+    bytes_per_sec: 65536
+  logs:
+    requests_per_sec: 1
+  events:
+    requests_per_sec: -1
+`
+	f, err := ioutil.TempFile("", "opsmatic_config_test")
+	c.Assert(err, IsNil)
+	defer os.Remove(f.Name())
+	f.WriteString(config)
+	f.Close()
+
+	cfg, err := LoadConfig(f.Name())
+	c.Assert(err, IsNil)
+	c.Assert(cfg.EgressRateLimits, DeepEquals, map[string]RateLimit{
+		EgressInventory: {RequestsPerSec: 0.5},
+		EgressAll:       {BytesPerSec: 65536},
+	})
+}
+
+func (s *ConfigSuite) TestParseConfigPayloadCompression(c *C) {
+	for provided, expected := range map[string]string{
+		"":                          PayloadCompressionGzip,
+		"payload_compression: zstd": PayloadCompressionZstd,
+		"payload_compression: lz4":  PayloadCompressionGzip,
+	} {
+		f, err := ioutil.TempFile("", "opsmatic_config_test")
+		c.Assert(err, IsNil)
+		f.WriteString("license_key: abc123\n" + provided + "\n")
+		f.Close()
+
+		cfg, err := LoadConfig(f.Name())
+		os.Remove(f.Name())
+		c.Assert(err, IsNil)
+		c.Assert(cfg.PayloadCompression, Equals, expected)
+	}
+}
+
+func (s *ConfigSuite) TestParseConfigRecentSamplesMaxCount(c *C) {
+	for provided, expected := range map[string]int{
+		"":                               defaultRecentSamplesMaxCount,
+		"recent_samples_max_count: 0":    0,
+		"recent_samples_max_count: 500":  500,
+		"recent_samples_max_count: -100": defaultRecentSamplesMaxCount,
+	} {
+		f, err := ioutil.TempFile("", "opsmatic_config_test")
+		c.Assert(err, IsNil)
+		f.WriteString("license_key: abc123\n" + provided + "\n")
+		f.Close()
+
+		cfg, err := LoadConfig(f.Name())
+		os.Remove(f.Name())
+		c.Assert(err, IsNil)
+		c.Assert(cfg.RecentSamplesMaxCount, Equals, expected)
+	}
+}
+
+func (s *ConfigSuite) TestPayloadGzipLevel(c *C) {
+	cfg := &Config{PayloadCompression: PayloadCompressionGzip, PayloadCompressionLevel: 4}
+	c.Assert(cfg.PayloadGzipLevel(), Equals, 4)
+
+	cfg.PayloadCompression = PayloadCompressionZstd
+	c.Assert(cfg.PayloadGzipLevel(), Equals, gzip.NoCompression)
+}
+
 func (s *ConfigSuite) TestParseConfigBadLicense(c *C) {
 	keyTest := []struct {
 		inputKey  string
@@ -293,6 +509,8 @@ agent_dir: /my/overriden/agent/dir
 	c.Assert(cfg.LogToStdout, Equals, defaultLogToStdout)
 	c.Assert(cfg.DisableWinSharedWMI, Equals, defaultDisableWinSharedWMI)
 	c.Assert(cfg.EnableWinUpdatePlugin, Equals, defaultWinUpdatePlugin)
+	c.Assert(cfg.EnableWindowsFeaturesInventory, Equals, defaultWindowsFeaturesInventory)
+	c.Assert(cfg.EnablePendingRebootInventory, Equals, defaultPendingRebootInventory)
 	c.Assert(cfg.PayloadCompressionLevel, Equals, defaultPayloadCompressionLevel)
 	c.Assert(cfg.CompactEnabled, Equals, defaultCompactEnabled)
 	c.Assert(cfg.CompactThreshold, Equals, uint64(defaultCompactThreshold))
@@ -14,6 +14,11 @@ const (
 	// JSON log format.
 	LogFormatJSON = "json"
 
+	// Inventory store backend keeping a file per plugin and entity.
+	InventoryStoreBackendFile = "file"
+	// Inventory store backend keeping the files in an embedded bbolt database.
+	InventoryStoreBackendBolt = "bolt"
+
 	// Non configurable stuff
 	defaultIdentityURLEu          = "https://identity-api.eu.newrelic.com"
 	defaultIdentityStagingURLEu   = "https://staging-identity-api.eu.newrelic.com"
@@ -33,6 +38,7 @@ var (
 	// public
 	DefaultContainerCacheMetadataLimit = 60
 	DefaultDockerApiVersion            = "1.24" // minimum supported API by Docker 18.09.0
+	DefaultContainerRuntimeEndpoint    = "unix:///run/containerd/containerd.sock"
 	DefaultHeartBeatFrequencySecs      = 60
 	DefaultDMPeriodSecs                = 5           // default telemetry SDK value
 	DefaultMaxMetricsBatchSizeBytes    = 1000 * 1000 // Size limit from Vortex collector service (1MB)
@@ -68,6 +74,11 @@ var (
 	defaultLogFormat                     = LogFormatText
 	defaultMaxInventorySize              = 1000 * 1000 // Size limit from Vortex collector service (1MB)
 	defaultPayloadCompressionLevel       = 6           // default compression level used in go, higher than this does not show tangible benefits
+	defaultPayloadSpoolMaxAgeSec         = 86400       // payloads older than a day are discarded from the spool
+	defaultShutdownFlushTimeoutSec       = 10          // seconds to submit the pending data when the agent stops
+	defaultThrottleMaxFactor             = 4           // throttled agents send up to 4 times slower
+	defaultThrottleRecoverySec           = 60
+	defaultPayloadCompression            = PayloadCompressionGzip
 	defaultPidFile                       = "/var/run/newrelic-infra/newrelic-infra.pid"
 	defaultPluginActiveConfigsDir        = "integrations.d"
 	defaultSelinuxEnableSemodule         = true
@@ -76,6 +87,8 @@ var (
 	defaultStartupConnectionRetries      = 6     // -1 will try forever with an exponential backoff algorithm
 	defaultSupervisorRpcSock             = "/var/run/supervisor.sock"
 	defaultWinUpdatePlugin               = false
+	defaultWindowsFeaturesInventory      = true
+	defaultPendingRebootInventory        = true
 	defaultMetricsIngestEndpoint         = "/metrics"          // default: V1 endpoint root (/events/bulk), combine this with defaultCollectorURL
 	defaultInventoryIngestEndpoint       = "/inventory"        // default: V1 endpoint root (/deltas, /deltas/bulk)
 	defaultIdentityIngestEndpoint        = "/identity/v1"      // default: V1 endpoint root (/connect, /register/batch)
@@ -94,6 +107,29 @@ var (
 	defaultTraces                        = []trace.Feature{trace.CONN}
 	defaultMetricsMatcherConfig          = IncludeMetricsMap{}
 	defaultRegisterMaxRetryBoSecs        = 60
+
+	defaultIntegrationsCircuitBreakerThreshold     = 0
+	defaultIntegrationsCircuitBreakerMaxBackoffSec = 3600 // In seconds.
+	defaultStatusServerPort                        = 18003
+	defaultRecentSamplesMaxCount                   = 10000
+	defaultMetricsGPUSampleRate                    = FREQ_DISABLE_SAMPLING
+	defaultMetricsSessionSampleRate                = FREQ_DISABLE_SAMPLING
+	defaultSessionSampleUserAnonymization          = "none"
+	defaultMetricsNTPServerSampleRate              = FREQ_DISABLE_SAMPLING
+	defaultMetricsProcessCrashSampleRate           = FREQ_DISABLE_SAMPLING
+	defaultMetricsPowerSampleRate                  = FREQ_DISABLE_SAMPLING
+	defaultMetricsStorageCgroupSampleRate          = FREQ_DISABLE_SAMPLING
+	defaultMetricsSensorSampleRate                 = FREQ_DISABLE_SAMPLING
+	defaultMetricsSmartSampleRate                  = FREQ_DISABLE_SAMPLING
+	defaultMetricsConnectionSampleRate             = FREQ_DISABLE_SAMPLING
+	defaultMetricsTCPHealthSampleRate              = FREQ_DISABLE_SAMPLING
+	defaultMetricsSystemdUnitSampleRate            = 30
+	defaultCPUCoreMetricsMaxCores                  = 64
+	defaultIntegrationsDebugCapturePayloads        = 10
+	defaultTLSCertificateExpiryThresholdDays       = 30
+	defaultInventoryStoreBackend                   = InventoryStoreBackendFile
+	defaultIntegrationsDebugCaptureDir             = "integrations-capture"
+	defaultIntegrationsDerivedMetricsDir           = "integrations-derived-metrics"
 )
 
 // Default internal values
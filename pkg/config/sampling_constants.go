@@ -21,6 +21,8 @@ const (
 
 	FREQ_PLUGIN_K8S_INTEGRATION_SAMPLES_UPDATES = 30 // seconds
 
+	FREQ_INTERVAL_FLOOR_SMART_METRICS = 300 // seconds, querying SMART data may stall the disk IO for a while
+
 	// DefaultWMINamespace is the Namespace where the WMI queries will be executed
 	DefaultWMINamespace = "root/cimv2"
 )
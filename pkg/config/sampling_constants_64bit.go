@@ -1,7 +1,7 @@
 // Copyright 2020 New Relic Corporation. All rights reserved.
 // SPDX-License-Identifier: Apache-2.0
 // +build linux darwin windows
-// +build amd64 arm64 mips64 mips64le ppc64 ppc64le s390x
+// +build amd64 arm64 mips64 mips64le ppc64 ppc64le riscv64 s390x
 
 //
 // NOTE: These constants are tuned for 64-bit builds of the agent are are generally
@@ -39,15 +39,26 @@ const (
 	FREQ_PLUGIN_HOST_ALIASES              = 30 // seconds
 	FREQ_PLUGIN_NETWORK_INTERFACE_UPDATES = 60 // seconds
 	FREQ_PLUGIN_CLOUD_SECURITY_UPDATES    = 60 // seconds
+	FREQ_PLUGIN_STORAGE_DEVICES_UPDATES   = 60 // seconds -- mounts, block devices: refreshed on kernel notifications too
+
+	FREQ_PLUGIN_PYTHON_PACKAGES_UPDATES    = 3600 // seconds -- walks the site-packages of the system and the virtualenvs
+	FREQ_PLUGIN_SANDBOXED_PACKAGES_UPDATES = 300  // seconds -- snap and flatpak, which have no lock to watch
 
 	// WINDOWS PLUGINS
-	FREQ_PLUGIN_WINDOWS_SERVICES = 30 // seconds, 0 == off, 30 == minimum otherwise: inventory: running services
-	FREQ_PLUGIN_WINDOWS_UPDATES  = 60 // seconds
+	FREQ_PLUGIN_WINDOWS_SERVICES       = 30  // seconds, 0 == off, 30 == minimum otherwise: inventory: running services
+	FREQ_PLUGIN_WINDOWS_UPDATES        = 60  // seconds
+	FREQ_PLUGIN_WINDOWS_FEATURES       = 300 // seconds -- server roles and features change rarely
+	FREQ_PLUGIN_WINDOWS_PENDING_REBOOT = 60  // seconds
 
 	// BOTH
 	FREQ_EXTERNAL_USER_DATA      = 10 // seconds between external user data samples (deprecated user json plugin)
 	FREQ_PLUGIN_EXTERNAL_PLUGINS = 30 // seconds
 
+	FREQ_PLUGIN_DOCKER_IMAGES_UPDATES    = 300  // seconds -- lists the images of the Docker daemon
+	FREQ_PLUGIN_TLS_CERTIFICATES_UPDATES = 3600 // seconds -- days to expiry change slowly
+	FREQ_PLUGIN_SCHEDULED_TASKS_UPDATES  = 300  // seconds -- cron, systemd timers and Windows Task Scheduler
+	FREQ_PLUGIN_MAC_APPLICATIONS_UPDATES = 300  // seconds -- application bundles and Homebrew packages
+
 	defaultFirstReapInterval = 1 * time.Second  // inventory: reap every second until first successful reap, then switch to DefaultReapInterval
 	defaultReapInterval      = 10 * time.Second // inventory: fire reap trigger every 10 seconds after first successful reap
 	defaultSendInterval      = 10 * time.Second // inventory: fire send trigger every 10 seconds
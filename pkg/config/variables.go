@@ -0,0 +1,40 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package config
+
+import (
+	"fmt"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/newrelic/infrastructure-agent/pkg/databind/pkg/databind"
+)
+
+// resolveLicenseVariables replaces the ${variable} placeholders of the license key with the values
+// gathered from the variables section.
+func resolveLicenseVariables(cfg *Config) error {
+	if len(cfg.Variables) == 0 {
+		return nil
+	}
+	raw, err := yaml.Marshal(map[string]interface{}{"variables": cfg.Variables})
+	if err != nil {
+		return fmt.Errorf("invalid variables: %s", err)
+	}
+	sources, err := databind.LoadYAML(raw)
+	if err != nil {
+		return fmt.Errorf("invalid variables: %s", err)
+	}
+	values, err := databind.Fetch(sources)
+	if err != nil {
+		return fmt.Errorf("unable to gather variables: %s", err)
+	}
+	license, err := databind.ReplaceBytes(&values, []byte(cfg.License))
+	if err != nil {
+		return fmt.Errorf("unable to replace license key variables: %s", err)
+	}
+	if len(license) != 1 {
+		return fmt.Errorf("license key variables must have a single value")
+	}
+	cfg.License = string(license[0])
+	return nil
+}
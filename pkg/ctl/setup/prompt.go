@@ -0,0 +1,51 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package setup
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Prompter asks the user for the setup values through a line based terminal.
+type Prompter struct {
+	in  *bufio.Reader
+	out io.Writer
+}
+
+// NewPrompter creates a Prompter reading the answers from in and writing the questions to out.
+func NewPrompter(in io.Reader, out io.Writer) *Prompter {
+	return &Prompter{in: bufio.NewReader(in), out: out}
+}
+
+// Ask returns the answer to the question, or defaultValue when it's left empty.
+func (p *Prompter) Ask(question, defaultValue string) string {
+	if defaultValue != "" {
+		fmt.Fprintf(p.out, "%s [%s]: ", question, defaultValue)
+	} else {
+		fmt.Fprintf(p.out, "%s: ", question)
+	}
+	answer, _ := p.in.ReadString('\n')
+	answer = strings.TrimSpace(answer)
+	if answer == "" {
+		return defaultValue
+	}
+	return answer
+}
+
+// Confirm asks a yes/no question, returning defaultValue when it's left empty or the answer isn't understood.
+func (p *Prompter) Confirm(question string, defaultValue bool) bool {
+	options := "y/N"
+	if defaultValue {
+		options = "Y/n"
+	}
+	switch strings.ToLower(p.Ask(question+" ("+options+")", "")) {
+	case "y", "yes":
+		return true
+	case "n", "no":
+		return false
+	}
+	return defaultValue
+}
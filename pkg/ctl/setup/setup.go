@@ -0,0 +1,358 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package setup provides the guided setup of the agent on manually managed hosts: it writes a validated
+// configuration file, tests the connectivity with New Relic, enables the forwarding of the detected log
+// files and installs the agent service.
+package setup
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+
+	backendhttp "github.com/newrelic/infrastructure-agent/pkg/backend/http"
+	"github.com/newrelic/infrastructure-agent/pkg/config"
+	"github.com/newrelic/infrastructure-agent/pkg/license"
+)
+
+const (
+	// LogForwardingFile is the name of the log forwarding configuration written in the logging.d folder.
+	LogForwardingFile = "setup-detected-logs.yml"
+
+	defaultConnectivityTimeout = 10 * time.Second
+	licenseAttempts            = 3
+	newConfigFileMode          = 0640
+)
+
+// DefaultLogFiles are the well-known log files offered for forwarding, when they exist.
+var DefaultLogFiles = []string{
+	"/var/log/syslog",
+	"/var/log/messages",
+	"/var/log/auth.log",
+	"/var/log/secure",
+	"/var/log/nginx/access.log",
+	"/var/log/nginx/error.log",
+	"/var/log/apache2/access.log",
+	"/var/log/apache2/error.log",
+	"/var/log/httpd/access_log",
+	"/var/log/httpd/error_log",
+	"/var/log/mysql/error.log",
+	"/var/log/postgresql/postgresql.log",
+}
+
+// ErrInvalidLicense is returned when no valid license key is provided.
+var ErrInvalidLicense = errors.New("a valid license key is required")
+
+// Options are the values of the setup. When it's interactive, the user is asked for them, taking these as defaults.
+type Options struct {
+	ConfigFile            string
+	LicenseKey            string
+	Proxy                 string
+	DisplayName           string
+	EnableProcessMetrics  bool
+	ForwardLogs           bool
+	LogFiles              []string // candidate files to forward, DefaultLogFiles if empty
+	InstallService        bool
+	SkipConnectivityCheck bool
+	Interactive           bool
+}
+
+// Setup runs the steps of the guided setup.
+type Setup struct {
+	opts                Options
+	prompter            *Prompter
+	out                 io.Writer
+	installService      func() error
+	connectivityTimeout time.Duration
+}
+
+// New creates a Setup with the given options. installService registers the agent in the service manager
+// of the host.
+func New(opts Options, prompter *Prompter, out io.Writer, installService func() error) *Setup {
+	if len(opts.LogFiles) == 0 {
+		opts.LogFiles = DefaultLogFiles
+	}
+	return &Setup{
+		opts:                opts,
+		prompter:            prompter,
+		out:                 out,
+		installService:      installService,
+		connectivityTimeout: defaultConnectivityTimeout,
+	}
+}
+
+// Run writes the configuration file and, as requested, tests the connectivity, enables the log forwarding
+// and installs the service. The configuration file is left untouched if the resulting one isn't valid.
+func (s *Setup) Run() error {
+	current, err := readConfigFile(s.opts.ConfigFile)
+	if err != nil {
+		return err
+	}
+
+	logFiles := DetectLogFiles(s.opts.LogFiles)
+	if s.opts.Interactive {
+		if err := s.ask(current, logFiles); err != nil {
+			return err
+		}
+	}
+	if err := s.validate(current); err != nil {
+		return err
+	}
+
+	cfg, err := s.writeConfig(current)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(s.out, "Configuration written to %s\n", s.opts.ConfigFile)
+
+	if !s.opts.SkipConnectivityCheck {
+		if err := CheckConnectivity(cfg, s.connectivityTimeout); err != nil {
+			fmt.Fprintf(s.out, "WARNING: New Relic is not reachable at %s: %s. Review the network and proxy settings.\n",
+				cfg.CollectorURL, err)
+		} else {
+			fmt.Fprintf(s.out, "Connectivity with %s verified\n", cfg.CollectorURL)
+		}
+	}
+
+	if s.opts.ForwardLogs {
+		if len(logFiles) == 0 {
+			fmt.Fprintln(s.out, "No known log files detected, log forwarding not configured")
+		} else {
+			path, err := WriteLogForwarding(cfg.LoggingConfigsDir, logFiles)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(s.out, "Forwarding %s, configured in %s\n", strings.Join(logFiles, ", "), path)
+		}
+	}
+
+	if s.opts.InstallService {
+		if err := s.installService(); err != nil {
+			return fmt.Errorf("unable to install the agent service: %s", err)
+		}
+		fmt.Fprintln(s.out, "Agent service installed")
+	}
+	return nil
+}
+
+// ask fills the options from the user answers, offering the current configuration values as defaults.
+func (s *Setup) ask(current yaml.MapSlice, logFiles []string) error {
+	currentLicense := lookup(current, "license_key")
+	for attempt := 0; ; attempt++ {
+		s.opts.LicenseKey = s.prompter.Ask("License key", valueOr(s.opts.LicenseKey, currentLicense))
+		// the current one might be a reference to a variable
+		if license.IsValid(s.opts.LicenseKey) || (s.opts.LicenseKey != "" && s.opts.LicenseKey == currentLicense) {
+			break
+		}
+		if attempt+1 == licenseAttempts {
+			return ErrInvalidLicense
+		}
+		fmt.Fprintln(s.out, "The license key is not valid, please try again")
+	}
+	s.opts.DisplayName = s.prompter.Ask("Display name (optional)", valueOr(s.opts.DisplayName, lookup(current, "display_name")))
+	s.opts.Proxy = s.prompter.Ask("Proxy URL (optional)", valueOr(s.opts.Proxy, lookup(current, "proxy")))
+	s.opts.EnableProcessMetrics = s.prompter.Confirm("Enable process metrics",
+		s.opts.EnableProcessMetrics || lookup(current, "enable_process_metrics") == "true")
+	if len(logFiles) > 0 {
+		s.opts.ForwardLogs = s.prompter.Confirm("Forward the detected log files ("+strings.Join(logFiles, ", ")+")",
+			s.opts.ForwardLogs)
+	}
+	s.opts.InstallService = s.prompter.Confirm("Install the agent service", s.opts.InstallService)
+	return nil
+}
+
+func (s *Setup) validate(current yaml.MapSlice) error {
+	if s.opts.LicenseKey != "" && !license.IsValid(s.opts.LicenseKey) &&
+		s.opts.LicenseKey != lookup(current, "license_key") {
+		return ErrInvalidLicense
+	}
+	if s.opts.LicenseKey == "" && lookup(current, "license_key") == "" && lookup(current, "bootstrap_token") == "" {
+		return ErrInvalidLicense
+	}
+	if s.opts.Proxy != "" {
+		u, err := url.Parse(s.opts.Proxy)
+		if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https" && u.Scheme != "socks5") {
+			return fmt.Errorf("invalid proxy URL %q: it must be an http, https or socks5 URL", s.opts.Proxy)
+		}
+	}
+	return nil
+}
+
+// writeConfig sets the options into the current configuration and, if the agent accepts the result, replaces
+// the configuration file with it. The previous file is kept with the .bak extension.
+func (s *Setup) writeConfig(current yaml.MapSlice) (*config.Config, error) {
+	updated := current
+	if s.opts.LicenseKey != "" {
+		updated = set(updated, "license_key", s.opts.LicenseKey)
+	}
+	if s.opts.DisplayName != "" {
+		updated = set(updated, "display_name", s.opts.DisplayName)
+	}
+	if s.opts.Proxy != "" {
+		updated = set(updated, "proxy", s.opts.Proxy)
+	}
+	updated = set(updated, "enable_process_metrics", s.opts.EnableProcessMetrics)
+
+	content, err := yaml.Marshal(updated)
+	if err != nil {
+		return nil, err
+	}
+
+	dir := filepath.Dir(s.opts.ConfigFile)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	tmp, err := ioutil.TempFile(dir, ".newrelic-infra-setup-*.yml")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(content)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	cfg, err := config.LoadConfig(tmp.Name())
+	if err != nil {
+		return nil, fmt.Errorf("the resulting configuration is not valid: %s", err)
+	}
+
+	mode := os.FileMode(newConfigFileMode)
+	if info, err := os.Stat(s.opts.ConfigFile); err == nil {
+		mode = info.Mode().Perm()
+		previous, err := ioutil.ReadFile(s.opts.ConfigFile)
+		if err != nil {
+			return nil, err
+		}
+		if err := ioutil.WriteFile(s.opts.ConfigFile+".bak", previous, mode); err != nil {
+			return nil, err
+		}
+	}
+	if err := os.Chmod(tmp.Name(), mode); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(tmp.Name(), s.opts.ConfigFile); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// CheckConnectivity verifies the collector endpoint is reachable with the network settings of the configuration.
+func CheckConnectivity(cfg *config.Config, timeout time.Duration) error {
+	request, err := http.NewRequest(http.MethodHead, cfg.CollectorURL, nil)
+	if err != nil {
+		return err
+	}
+	client := backendhttp.GetHttpClient(timeout, backendhttp.BuildTransport(cfg, timeout))
+	response, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	return response.Body.Close()
+}
+
+// DetectLogFiles returns the candidate log files that exist in the host.
+func DetectLogFiles(candidates []string) []string {
+	var detected []string
+	for _, file := range candidates {
+		if info, err := os.Stat(file); err == nil && info.Mode().IsRegular() {
+			detected = append(detected, file)
+		}
+	}
+	return detected
+}
+
+type logForwarding struct {
+	Logs []logFile `yaml:"logs"`
+}
+
+type logFile struct {
+	Name string `yaml:"name"`
+	File string `yaml:"file"`
+}
+
+// WriteLogForwarding writes a log forwarding configuration for the files into the logging configs folder,
+// returning its path.
+func WriteLogForwarding(loggingConfigsDir string, files []string) (string, error) {
+	logs := logForwarding{}
+	for _, file := range files {
+		logs.Logs = append(logs.Logs, logFile{Name: logName(file), File: file})
+	}
+	content, err := yaml.Marshal(logs)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(loggingConfigsDir, 0755); err != nil {
+		return "", err
+	}
+	path := filepath.Join(loggingConfigsDir, LogForwardingFile)
+	return path, ioutil.WriteFile(path, content, 0644)
+}
+
+// logName names the forwarded file by its path under /var/log, e.g. nginx-access for /var/log/nginx/access.log,
+// or by its base name otherwise.
+func logName(file string) string {
+	name := filepath.ToSlash(file)
+	if strings.HasPrefix(name, "/var/log/") {
+		name = strings.TrimPrefix(name, "/var/log/")
+	} else {
+		name = filepath.Base(file)
+	}
+	name = strings.TrimSuffix(name, filepath.Ext(name))
+	return strings.NewReplacer("/", "-", "_", "-", ".", "-").Replace(strings.Trim(name, "/"))
+}
+
+func readConfigFile(path string) (yaml.MapSlice, error) {
+	content, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var current yaml.MapSlice
+	if err := yaml.Unmarshal(content, &current); err != nil {
+		return nil, fmt.Errorf("unable to parse %s: %s", path, err)
+	}
+	return current, nil
+}
+
+func lookup(cfg yaml.MapSlice, key string) string {
+	for _, item := range cfg {
+		if item.Key == key && item.Value != nil {
+			return fmt.Sprint(item.Value)
+		}
+	}
+	return ""
+}
+
+// set replaces the value of the key, keeping its position, or appends it.
+func set(cfg yaml.MapSlice, key string, value interface{}) yaml.MapSlice {
+	for i, item := range cfg {
+		if item.Key == key {
+			cfg[i].Value = value
+			return cfg
+		}
+	}
+	return append(cfg, yaml.MapItem{Key: key, Value: value})
+}
+
+func valueOr(value, defaultValue string) string {
+	if value == "" {
+		return defaultValue
+	}
+	return value
+}
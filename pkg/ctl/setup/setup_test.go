@@ -0,0 +1,127 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package setup
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+func tempDir(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir("", "setup")
+	require.NoError(t, err)
+	return dir, func() { _ = os.RemoveAll(dir) }
+}
+
+func TestSetup_Interactive(t *testing.T) {
+	// GIVEN a reachable collector, a log file and an existing configuration
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer collector.Close()
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+	logFile := filepath.Join(dir, "app.log")
+	require.NoError(t, ioutil.WriteFile(logFile, []byte("started\n"), 0644))
+	configFile := filepath.Join(dir, "newrelic-infra.yml")
+	previous := "collector_url: " + collector.URL + "\nconfig_dir: " + dir + "\n"
+	require.NoError(t, ioutil.WriteFile(configFile, []byte(previous), 0600))
+
+	// WHEN the setup is run answering the questions
+	answers := strings.Join([]string{"not a license!", "eu01xxabcdef1234", "my-host", "", "y", "y", "n"}, "\n")
+	out := &bytes.Buffer{}
+	installed := false
+	s := New(Options{ConfigFile: configFile, LogFiles: []string{logFile, filepath.Join(dir, "missing.log")}, Interactive: true},
+		NewPrompter(strings.NewReader(answers), out), out, func() error {
+			installed = true
+			return nil
+		})
+	require.NoError(t, s.Run())
+
+	// THEN the answers are merged into the configuration, keeping the previous one as backup
+	content, err := ioutil.ReadFile(configFile)
+	require.NoError(t, err)
+	var written yaml.MapSlice
+	require.NoError(t, yaml.Unmarshal(content, &written))
+	assert.Equal(t, "eu01xxabcdef1234", lookup(written, "license_key"))
+	assert.Equal(t, "my-host", lookup(written, "display_name"))
+	assert.Equal(t, "true", lookup(written, "enable_process_metrics"))
+	assert.Equal(t, collector.URL, lookup(written, "collector_url"))
+	assert.Equal(t, "", lookup(written, "proxy"))
+	backup, err := ioutil.ReadFile(configFile + ".bak")
+	require.NoError(t, err)
+	assert.Equal(t, previous, string(backup))
+	info, err := os.Stat(configFile)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	// AND the connectivity is verified
+	assert.Contains(t, out.String(), "The license key is not valid")
+	assert.Contains(t, out.String(), "Connectivity with "+collector.URL+" verified")
+
+	// AND the existing log files are forwarded
+	logs, err := ioutil.ReadFile(filepath.Join(dir, "logging.d", LogForwardingFile))
+	require.NoError(t, err)
+	assert.Equal(t, "logs:\n- name: app\n  file: "+logFile+"\n", string(logs))
+	assert.False(t, installed)
+}
+
+func TestSetup_NonInteractive(t *testing.T) {
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+	configFile := filepath.Join(dir, "etc", "newrelic-infra.yml")
+	installed := false
+
+	s := New(Options{
+		ConfigFile:            configFile,
+		LicenseKey:            "abcdef1234",
+		Proxy:                 "https://proxy.corp:3128",
+		InstallService:        true,
+		SkipConnectivityCheck: true,
+	}, nil, ioutil.Discard, func() error {
+		installed = true
+		return nil
+	})
+	require.NoError(t, s.Run())
+
+	content, err := ioutil.ReadFile(configFile)
+	require.NoError(t, err)
+	assert.Equal(t, "license_key: abcdef1234\nproxy: https://proxy.corp:3128\nenable_process_metrics: false\n", string(content))
+	assert.True(t, installed)
+}
+
+func TestSetup_Invalid(t *testing.T) {
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+	configFile := filepath.Join(dir, "newrelic-infra.yml")
+
+	for name, opts := range map[string]Options{
+		"no license":      {},
+		"invalid license": {LicenseKey: "abc-123"},
+		"invalid proxy":   {LicenseKey: "abcdef1234", Proxy: "proxy.corp:3128"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			opts.ConfigFile = configFile
+			opts.SkipConnectivityCheck = true
+
+			assert.Error(t, New(opts, nil, ioutil.Discard, nil).Run())
+
+			_, err := os.Stat(configFile)
+			assert.True(t, os.IsNotExist(err), "the configuration file must not be written")
+		})
+	}
+}
+
+func TestLogName(t *testing.T) {
+	assert.Equal(t, "nginx-access", logName("/var/log/nginx/access.log"))
+	assert.Equal(t, "httpd-error-log", logName("/var/log/httpd/error_log"))
+	assert.Equal(t, "syslog", logName("/var/log/syslog"))
+}
@@ -0,0 +1,32 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package discovery
+
+// Changes notifies that the discovered sources may have changed (e.g. a container started or a pod
+// was deleted), so the cached discoveries are fetched again before their TTL expires. Notifications
+// never block, as a pending one is enough to invalidate the cache. A nil Changes ignores them.
+type Changes chan struct{}
+
+// NewChanges returns a Changes notifier.
+func NewChanges() Changes {
+	return make(Changes, 1)
+}
+
+// Notify signals a change of the discovered sources.
+func (c Changes) Notify() {
+	select {
+	case c <- struct{}{}:
+	default:
+	}
+}
+
+// Changed returns whether any change has been notified since the last invocation.
+func (c Changes) Changed() bool {
+	select {
+	case <-c:
+		return true
+	default:
+		return false
+	}
+}
@@ -6,16 +6,26 @@ package discovery
 import (
 	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/google/shlex"
+
+	"github.com/newrelic/infrastructure-agent/pkg/databind/internal/jsonpath"
 )
 
 type Command struct {
 	Exec        ShlexOpt          `yaml:"exec"`
 	Environment map[string]string `yaml:"env"`
-	Matcher     map[string]string `yaml:"match"`
-	Timeout     time.Duration     `yaml:"timeout"`
+	// Matcher keys starting with $ are JSONPath expressions, evaluated against each item
+	Matcher map[string]string `yaml:"match"`
+	Timeout time.Duration     `yaml:"timeout"`
+	// Items is a JSONPath expression selecting the discovery items in an arbitrary JSON output of the
+	// command (e.g. "$.services[*]"). If empty, the output must be a list of discovery items.
+	Items string `yaml:"items"`
+	// Variables maps discovery variables to JSONPath expressions relative to each item (e.g.
+	// "ip: $.address"). If empty, all the fields of the items are discovered.
+	Variables map[string]string `yaml:"variables"`
 }
 
 func (c *Command) Validate() error {
@@ -25,9 +35,40 @@ func (c *Command) Validate() error {
 	if len(c.Matcher) == 0 {
 		return errors.New("missing 'match' entries")
 	}
+	if c.Items == "" {
+		if len(c.Variables) > 0 {
+			return errors.New("'variables' require an 'items' JSONPath")
+		}
+		for field := range c.Matcher {
+			if IsJSONPath(field) {
+				return fmt.Errorf("JSONPath matcher %q requires an 'items' JSONPath", field)
+			}
+		}
+		return nil
+	}
+	if _, err := jsonpath.Compile(c.Items); err != nil {
+		return err
+	}
+	for _, expression := range c.Variables {
+		if _, err := jsonpath.Compile(expression); err != nil {
+			return err
+		}
+	}
+	for field := range c.Matcher {
+		if IsJSONPath(field) {
+			if _, err := jsonpath.Compile(field); err != nil {
+				return err
+			}
+		}
+	}
 	return nil
 }
 
+// IsJSONPath returns whether a matcher field is a JSONPath expression instead of a variable name.
+func IsJSONPath(field string) bool {
+	return strings.HasPrefix(field, "$")
+}
+
 // ShlexOpt is a wrapper around []string so we can use go-shlex for shell tokenizing
 type ShlexOpt []string
 
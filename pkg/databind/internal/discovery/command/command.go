@@ -23,6 +23,13 @@ var (
 // Discoverer returns an executable discoverer from the provided configuration.
 // The fetching process will return an array of map values
 func Discoverer(d discovery.Command) (fetchDiscoveries func() (discoveries []discovery.Discovery, err error), err error) {
+	if d.Items != "" {
+		items, err := newItemsCommand(d)
+		if err != nil {
+			return nil, err
+		}
+		return items.fetch, nil
+	}
 	matcher, err := discovery.NewMatcher(d.Matcher)
 	if err != nil {
 		return nil, err
@@ -42,6 +49,16 @@ type executable struct {
 }
 
 func run(d discovery.Command) (results []data.GenericDiscovery, err error) {
+	out, err := execute(d)
+	if err != nil {
+		return results, err
+	}
+	err = json.NewDecoder(bytes.NewReader(out)).Decode(&results)
+	return results, err
+}
+
+// execute runs the command, returning its standard output.
+func execute(d discovery.Command) ([]byte, error) {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 	cmd := exec.CommandContext(ctx, d.Exec[0], d.Exec[1:]...)
@@ -50,19 +67,16 @@ func run(d discovery.Command) (results []data.GenericDiscovery, err error) {
 		cmd.Env = append(cmd.Env, k+"="+d.Environment[k])
 	}
 
-	done := make(chan error)
+	done := make(chan error, 1)
+	out := new(bytes.Buffer)
 	go func() {
 		defer close(done)
-		out := new(bytes.Buffer)
 		stderr := new(bytes.Buffer)
 		cmd.Stdout = out
 		cmd.Stderr = stderr
-		decoder := json.NewDecoder(out)
-		e := cmd.Run()
-		if e != nil {
+		if e := cmd.Run(); e != nil {
 			done <- errors.New(stderr.String() + e.Error())
 		}
-		done <- decoder.Decode(&results)
 	}()
 
 	timeout := time.Minute
@@ -72,9 +86,12 @@ func run(d discovery.Command) (results []data.GenericDiscovery, err error) {
 	select {
 	case <-time.After(timeout):
 		cancel()
-		return results, timeoutError
-	case err = <-done:
-		return results, err
+		return nil, timeoutError
+	case err := <-done:
+		if err != nil {
+			return nil, err
+		}
+		return out.Bytes(), nil
 	}
 }
 
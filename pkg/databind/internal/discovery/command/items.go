@@ -0,0 +1,149 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package command
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/newrelic/infrastructure-agent/pkg/databind/internal/discovery"
+	"github.com/newrelic/infrastructure-agent/pkg/databind/internal/jsonpath"
+	"github.com/newrelic/infrastructure-agent/pkg/databind/pkg/data"
+)
+
+// itemsCommand discovers the items selected through JSONPath in the arbitrary JSON output of a command,
+// so the output of CMDBs or orchestrators can be used without being converted to the discovery format.
+type itemsCommand struct {
+	d         discovery.Command
+	items     *jsonpath.Path
+	variables map[string]*jsonpath.Path
+	// matcher of the discovered variables
+	matcher discovery.FieldsMatcher
+	// pathMatchers match the values selected by JSONPath in each item
+	pathMatchers []pathMatcher
+	execute      func(d discovery.Command) ([]byte, error)
+}
+
+// pathMatcher matches an item if any of the values selected by its path matches.
+type pathMatcher struct {
+	path    *jsonpath.Path
+	matcher discovery.FieldsMatcher
+}
+
+// scalarItemVariable is the variable holding the value of the items that aren't objects.
+const scalarItemVariable = "value"
+
+func newItemsCommand(d discovery.Command) (*itemsCommand, error) {
+	items, err := jsonpath.Compile(d.Items)
+	if err != nil {
+		return nil, err
+	}
+	c := &itemsCommand{d: d, items: items, variables: map[string]*jsonpath.Path{}, execute: execute}
+	for name, expression := range d.Variables {
+		if c.variables[name], err = jsonpath.Compile(expression); err != nil {
+			return nil, err
+		}
+	}
+	fields := map[string]string{}
+	for field, value := range d.Matcher {
+		if !discovery.IsJSONPath(field) {
+			fields[field] = value
+			continue
+		}
+		path, err := jsonpath.Compile(field)
+		if err != nil {
+			return nil, err
+		}
+		matcher, err := discovery.NewMatcher(map[string]string{field: value})
+		if err != nil {
+			return nil, err
+		}
+		c.pathMatchers = append(c.pathMatchers, pathMatcher{path: path, matcher: matcher})
+	}
+	if c.matcher, err = discovery.NewMatcher(fields); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *itemsCommand) fetch() ([]discovery.Discovery, error) {
+	out, err := c.execute(c.d)
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(out))
+	// keeps the numbers as they were written
+	decoder.UseNumber()
+	var document interface{}
+	if err := decoder.Decode(&document); err != nil {
+		return nil, fmt.Errorf("can't parse command output: %s", err)
+	}
+
+	var results []discovery.Discovery
+	for _, item := range c.items.Get(document) {
+		if !c.matchesPaths(item) {
+			continue
+		}
+		fields := c.fields(item)
+		if c.matcher.All(fields) {
+			results = append(results, discovery.Discovery{
+				Variables: discovery.LabelsToMap(data.DiscoveryPrefix, fields),
+			})
+		}
+	}
+	return results, nil
+}
+
+func (c *itemsCommand) matchesPaths(item interface{}) bool {
+	for _, pm := range c.pathMatchers {
+		matches := false
+		for _, value := range pm.path.Get(item) {
+			if pm.matcher.All(map[string]string{pm.path.String(): toString(value)}) {
+				matches = true
+				break
+			}
+		}
+		if !matches {
+			return false
+		}
+	}
+	return true
+}
+
+// fields returns the variables of an item: the values selected by the configured paths, or all the
+// item fields in dot notation if no variables are configured.
+func (c *itemsCommand) fields(item interface{}) map[string]string {
+	if len(c.variables) == 0 {
+		if object, ok := item.(map[string]interface{}); ok {
+			return data.InterfaceMapToMap(object)
+		}
+		return map[string]string{scalarItemVariable: toString(item)}
+	}
+	fields := make(map[string]string, len(c.variables))
+	for name, path := range c.variables {
+		// unmatched paths leave the variable undefined
+		if values := path.Get(item); len(values) > 0 {
+			fields[name] = toString(values[0])
+		}
+	}
+	return fields
+}
+
+// toString returns scalar values as they were written, and structured values as JSON.
+func toString(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	case nil:
+		return ""
+	case map[string]interface{}, []interface{}:
+		if encoded, err := json.Marshal(v); err == nil {
+			return string(encoded)
+		}
+	}
+	return fmt.Sprintf("%v", value)
+}
@@ -0,0 +1,100 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package command
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/newrelic/infrastructure-agent/pkg/databind/internal/discovery"
+	"github.com/newrelic/infrastructure-agent/pkg/databind/pkg/data"
+)
+
+const cmdbOutput = `{
+  "services": [
+    {"name": "redis", "address": "10.0.0.1", "port": 6379, "tags": ["production", "cache"]},
+    {"name": "mysql", "address": "10.0.0.2", "port": 3306, "tags": ["staging"]},
+    {"name": "nginx", "address": "10.0.0.3", "port": 8080, "tags": ["production"], "owner": {"team": "web"}}
+  ]
+}`
+
+func itemsDiscoverer(t *testing.T, d discovery.Command, output string, err error) *itemsCommand {
+	c, cerr := newItemsCommand(d)
+	require.NoError(t, cerr)
+	c.execute = func(discovery.Command) ([]byte, error) {
+		return []byte(output), err
+	}
+	return c
+}
+
+func TestItemsCommand_Variables(t *testing.T) {
+	// GIVEN a command whose output lists services, matched by JSONPath
+	c := itemsDiscoverer(t, discovery.Command{
+		Items:     "$.services[*]",
+		Variables: map[string]string{"ip": "$.address", "port": "$.port", "team": "$.owner.team"},
+		Matcher:   map[string]string{"$.tags[*]": "production", "port": "/^[0-9]{4}$/"},
+	}, cmdbOutput, nil)
+
+	// WHEN the items are discovered
+	discoveries, err := c.fetch()
+
+	// THEN the matching items are returned with the configured variables
+	require.NoError(t, err)
+	assert.Equal(t, []discovery.Discovery{
+		{Variables: data.Map{"discovery.ip": "10.0.0.1", "discovery.port": "6379"}},
+		{Variables: data.Map{"discovery.ip": "10.0.0.3", "discovery.port": "8080", "discovery.team": "web"}},
+	}, discoveries)
+}
+
+func TestItemsCommand_AllFields(t *testing.T) {
+	// GIVEN a command discoverer without configured variables
+	c := itemsDiscoverer(t, discovery.Command{
+		Items:   "$.services[?(@.owner)]",
+		Matcher: map[string]string{"name": "nginx"},
+	}, cmdbOutput, nil)
+
+	// WHEN the items are discovered
+	discoveries, err := c.fetch()
+
+	// THEN all the item fields are discovered
+	require.NoError(t, err)
+	assert.Equal(t, []discovery.Discovery{{Variables: data.Map{
+		"discovery.name":       "nginx",
+		"discovery.address":    "10.0.0.3",
+		"discovery.port":       "8080",
+		"discovery.tags[0]":    "production",
+		"discovery.owner.team": "web",
+	}}}, discoveries)
+}
+
+func TestItemsCommand_ScalarItems(t *testing.T) {
+	c := itemsDiscoverer(t, discovery.Command{
+		Items:   "$.services[*].address",
+		Matcher: map[string]string{"value": "/^10\\.0\\.0\\.[12]$/"},
+	}, cmdbOutput, nil)
+
+	discoveries, err := c.fetch()
+
+	require.NoError(t, err)
+	assert.Equal(t, []discovery.Discovery{
+		{Variables: data.Map{"discovery.value": "10.0.0.1"}},
+		{Variables: data.Map{"discovery.value": "10.0.0.2"}},
+	}, discoveries)
+}
+
+func TestItemsCommand_Errors(t *testing.T) {
+	d := discovery.Command{Items: "$[*]", Matcher: map[string]string{"$.name": "redis"}}
+
+	_, err := itemsDiscoverer(t, d, "", errors.New("command failed")).fetch()
+	assert.EqualError(t, err, "command failed")
+
+	_, err = itemsDiscoverer(t, d, "not json", nil).fetch()
+	assert.Error(t, err)
+
+	_, err = newItemsCommand(discovery.Command{Items: "$[*]", Matcher: map[string]string{"$.name": "/((/"}})
+	assert.Error(t, err)
+}
@@ -14,6 +14,8 @@ func TestExecutable_Validate(t *testing.T) {
 		Exec        ShlexOpt
 		Environment map[string]string
 		Matcher     map[string]string
+		Items       string
+		Variables   map[string]string
 	}
 	tests := []struct {
 		name    string
@@ -36,6 +38,51 @@ func TestExecutable_Validate(t *testing.T) {
 			},
 			wantErr: "missing 'match' entries",
 		},
+		{
+			name: "Variables without items",
+			fields: fields{
+				Exec:      ShlexOpt{"/usr/bin/cmd"},
+				Matcher:   map[string]string{"ip": "/.*/"},
+				Variables: map[string]string{"ip": "$.address"},
+			},
+			wantErr: "'variables' require an 'items' JSONPath",
+		},
+		{
+			name: "JSONPath matcher without items",
+			fields: fields{
+				Exec:    ShlexOpt{"/usr/bin/cmd"},
+				Matcher: map[string]string{"$.tags.env": "production"},
+			},
+			wantErr: "JSONPath matcher \"$.tags.env\" requires an 'items' JSONPath",
+		},
+		{
+			name: "Invalid items",
+			fields: fields{
+				Exec:    ShlexOpt{"/usr/bin/cmd"},
+				Matcher: map[string]string{"ip": "/.*/"},
+				Items:   "$.services[",
+			},
+			wantErr: "invalid JSONPath \"$.services[\": at position 11: expected an index",
+		},
+		{
+			name: "Invalid variable",
+			fields: fields{
+				Exec:      ShlexOpt{"/usr/bin/cmd"},
+				Matcher:   map[string]string{"ip": "/.*/"},
+				Items:     "$.services[*]",
+				Variables: map[string]string{"ip": "address"},
+			},
+			wantErr: "invalid JSONPath \"address\": at position 0: expected '$'",
+		},
+		{
+			name: "Happy with items",
+			fields: fields{
+				Exec:      ShlexOpt{"/usr/bin/cmd"},
+				Matcher:   map[string]string{"$.tags.env": "production"},
+				Items:     "$.services[*]",
+				Variables: map[string]string{"ip": "$.address"},
+			},
+		},
 		{
 			name: "Happy",
 			fields: fields{
@@ -52,6 +99,8 @@ func TestExecutable_Validate(t *testing.T) {
 				Exec:        tt.fields.Exec,
 				Environment: tt.fields.Environment,
 				Matcher:     tt.fields.Matcher,
+				Items:       tt.fields.Items,
+				Variables:   tt.fields.Variables,
 			}
 			err := e.Validate()
 			if (err == nil) && (len(tt.wantErr) > 0) {
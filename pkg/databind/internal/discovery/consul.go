@@ -0,0 +1,53 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package discovery
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Consul sources of the discovered services.
+const (
+	ConsulCatalog = "catalog"
+	ConsulAgent   = "agent"
+)
+
+// Consul discovery parameters
+type Consul struct {
+	Match map[string]string `yaml:"match"`
+	// Address of the Consul HTTP API. Default: CONSUL_HTTP_ADDR environment variable or http://127.0.0.1:8500
+	Address string `yaml:"address"`
+	// Token for the Consul ACLs. Default: CONSUL_HTTP_TOKEN environment variable
+	Token string `yaml:"token"`
+	// Datacenter of the catalog. Default: datacenter of the queried agent
+	Datacenter string `yaml:"datacenter"`
+	// Source of the services: catalog (default), or agent for the services registered in the local agent
+	Source string `yaml:"source"`
+	// Services restricts the discovery to the services with these names
+	Services []string `yaml:"services"`
+	// Tags that the discovered services must have
+	Tags []string `yaml:"tags"`
+	// PassingOnly discovers only the catalog instances whose health checks are passing
+	PassingOnly bool `yaml:"passing_only"`
+	// Blocking watches the service changes in background through Consul blocking queries, instead of
+	// querying them on each discovery refresh
+	Blocking bool `yaml:"blocking"`
+}
+
+func (c *Consul) Validate() error {
+	if len(c.Match) == 0 && len(c.Services) == 0 && len(c.Tags) == 0 {
+		return errors.New("missing 'match', 'services' or 'tags' entries")
+	}
+	switch c.Source {
+	case "", ConsulCatalog:
+	case ConsulAgent:
+		if c.PassingOnly {
+			return errors.New("'passing_only' is only supported for the catalog source")
+		}
+	default:
+		return fmt.Errorf("unsupported consul source %q. Use %s or %s", c.Source, ConsulCatalog, ConsulAgent)
+	}
+	return nil
+}
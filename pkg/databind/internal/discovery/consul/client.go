@@ -0,0 +1,128 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package consul
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/newrelic/infrastructure-agent/pkg/databind/internal/discovery"
+)
+
+const (
+	defaultAddress = "http://127.0.0.1:8500"
+	// blockingWait is the maximum time a blocking query waits for changes
+	blockingWait   = 5 * time.Minute
+	requestTimeout = 30 * time.Second
+)
+
+// client performs the requests to the Consul HTTP API.
+type client struct {
+	address    string
+	token      string
+	datacenter string
+	http       *http.Client
+}
+
+func newClient(cfg discovery.Consul) *client {
+	address := cfg.Address
+	if address == "" {
+		address = os.Getenv("CONSUL_HTTP_ADDR")
+	}
+	if address == "" {
+		address = defaultAddress
+	}
+	// the Consul tools accept addresses without scheme
+	if !strings.Contains(address, "://") {
+		address = "http://" + address
+	}
+	token := cfg.Token
+	if token == "" {
+		token = os.Getenv("CONSUL_HTTP_TOKEN")
+	}
+	return &client{
+		address:    strings.TrimSuffix(address, "/"),
+		token:      token,
+		datacenter: cfg.Datacenter,
+		http:       &http.Client{},
+	}
+}
+
+// host returns the host of the Consul API address.
+func (c *client) host() string {
+	u, err := url.Parse(c.address)
+	if err != nil {
+		return ""
+	}
+	return u.Hostname()
+}
+
+// get decodes the response of an API path into out. A non-empty index makes it a blocking query, which
+// waits for changes since that index. It returns the index of the response, to be used by the next
+// blocking query. The agent endpoints are indexed by content hash instead of by raft index.
+func (c *client) get(ctx context.Context, path string, query url.Values, index string, out interface{}) (string, error) {
+	agentPath := strings.HasPrefix(path, "/v1/agent/")
+	q := url.Values{}
+	for k, v := range query {
+		q[k] = v
+	}
+	if c.datacenter != "" && !agentPath {
+		q.Set("dc", c.datacenter)
+	}
+	if index != "" {
+		if agentPath {
+			q.Set("hash", index)
+		} else {
+			q.Set("index", index)
+		}
+		q.Set("wait", strconv.Itoa(int(blockingWait/time.Second))+"s")
+	} else {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, requestTimeout)
+		defer cancel()
+	}
+
+	u := c.address + path
+	if len(q) > 0 {
+		u += "?" + q.Encode()
+	}
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return "", err
+	}
+	if c.token != "" {
+		req.Header.Set("X-Consul-Token", c.token)
+	}
+	resp, err := c.http.Do(req.WithContext(ctx))
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return "", fmt.Errorf("consul responded %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	if err = json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return "", err
+	}
+
+	if agentPath {
+		return resp.Header.Get("X-Consul-ContentHash"), nil
+	}
+	newIndex := resp.Header.Get("X-Consul-Index")
+	// the index may go backwards after a Consul reset, which requires to start again from zero
+	previous, _ := strconv.ParseUint(index, 10, 64)
+	if current, err := strconv.ParseUint(newIndex, 10, 64); err != nil || current < previous || current == 0 {
+		return "0", nil
+	}
+	return newIndex, nil
+}
@@ -0,0 +1,305 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package consul
+
+import (
+	"context"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/newrelic/infrastructure-agent/pkg/databind/internal/discovery"
+	"github.com/newrelic/infrastructure-agent/pkg/databind/internal/discovery/naming"
+	"github.com/newrelic/infrastructure-agent/pkg/databind/pkg/data"
+)
+
+const metricAnnotationsToAdd = 2
+
+// instance of a service, as registered in Consul.
+type instance struct {
+	ID          string
+	Name        string
+	Address     string
+	Port        int
+	Tags        []string
+	Meta        map[string]string
+	Node        string
+	NodeAddress string
+	NodeMeta    map[string]string
+	Datacenter  string
+}
+
+// catalogService as returned by the /v1/catalog/service endpoint
+type catalogService struct {
+	Node           string
+	Address        string
+	Datacenter     string
+	NodeMeta       map[string]string
+	ServiceID      string
+	ServiceName    string
+	ServiceAddress string
+	ServicePort    int
+	ServiceTags    []string
+	ServiceMeta    map[string]string
+}
+
+// agentService as returned by the /v1/agent/services endpoint, and as part of the health entries
+type agentService struct {
+	ID         string
+	Service    string
+	Address    string
+	Port       int
+	Tags       []string
+	Meta       map[string]string
+	Datacenter string
+}
+
+// healthEntry as returned by the /v1/health/service endpoint
+type healthEntry struct {
+	Node struct {
+		Node       string
+		Address    string
+		Datacenter string
+		Meta       map[string]string
+	}
+	Service agentService
+}
+
+// querier queries the services of the configured source.
+type querier struct {
+	client *client
+	cfg    discovery.Consul
+}
+
+// services returns the names of the catalog services that may match the configured names and tags.
+func (q *querier) services(ctx context.Context, index string) ([]string, string, error) {
+	services := map[string][]string{}
+	index, err := q.client.get(ctx, "/v1/catalog/services", nil, index, &services)
+	if err != nil {
+		return nil, "", err
+	}
+	var names []string
+	for name, tags := range services {
+		if len(q.cfg.Services) > 0 && !contains(q.cfg.Services, name) {
+			continue
+		}
+		if !hasTags(tags, q.cfg.Tags) {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, index, nil
+}
+
+// instances returns the catalog instances of a service.
+func (q *querier) instances(ctx context.Context, name, index string) ([]instance, string, error) {
+	var instances []instance
+	if q.cfg.PassingOnly {
+		var entries []healthEntry
+		index, err := q.client.get(ctx, "/v1/health/service/"+url.PathEscape(name), url.Values{"passing": {"true"}}, index, &entries)
+		if err != nil {
+			return nil, "", err
+		}
+		for _, e := range entries {
+			i := fromAgentService(e.Service)
+			i.Node, i.NodeAddress, i.NodeMeta = e.Node.Node, e.Node.Address, e.Node.Meta
+			i.Datacenter = e.Node.Datacenter
+			if i.Address == "" {
+				i.Address = e.Node.Address
+			}
+			instances = append(instances, i)
+		}
+		return instances, index, nil
+	}
+
+	var services []catalogService
+	index, err := q.client.get(ctx, "/v1/catalog/service/"+url.PathEscape(name), nil, index, &services)
+	if err != nil {
+		return nil, "", err
+	}
+	for _, s := range services {
+		i := instance{
+			ID:          s.ServiceID,
+			Name:        s.ServiceName,
+			Address:     s.ServiceAddress,
+			Port:        s.ServicePort,
+			Tags:        s.ServiceTags,
+			Meta:        s.ServiceMeta,
+			Node:        s.Node,
+			NodeAddress: s.Address,
+			NodeMeta:    s.NodeMeta,
+			Datacenter:  s.Datacenter,
+		}
+		// services without address listen in the address of their node
+		if i.Address == "" {
+			i.Address = s.Address
+		}
+		instances = append(instances, i)
+	}
+	return instances, index, nil
+}
+
+// agentInstances returns the services registered in the local agent.
+func (q *querier) agentInstances(ctx context.Context, index string) ([]instance, string, error) {
+	services := map[string]agentService{}
+	index, err := q.client.get(ctx, "/v1/agent/services", nil, index, &services)
+	if err != nil {
+		return nil, "", err
+	}
+	var instances []instance
+	for _, s := range services {
+		i := fromAgentService(s)
+		// services without address listen in the address of the agent
+		if i.Address == "" {
+			i.Address = q.client.host()
+		}
+		instances = append(instances, i)
+	}
+	return instances, index, nil
+}
+
+// all queries the instances of all the services of the configured source.
+func (q *querier) all(ctx context.Context) ([]instance, error) {
+	if q.cfg.Source == discovery.ConsulAgent {
+		instances, _, err := q.agentInstances(ctx, "")
+		return instances, err
+	}
+	names, _, err := q.services(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	var instances []instance
+	for _, name := range names {
+		found, _, err := q.instances(ctx, name, "")
+		if err != nil {
+			return nil, err
+		}
+		instances = append(instances, found...)
+	}
+	return instances, nil
+}
+
+func fromAgentService(s agentService) instance {
+	return instance{
+		ID:         s.ID,
+		Name:       s.Service,
+		Address:    s.Address,
+		Port:       s.Port,
+		Tags:       s.Tags,
+		Meta:       s.Meta,
+		Datacenter: s.Datacenter,
+	}
+}
+
+// Discoverer returns a Consul service discoverer from the provided configuration.
+// The fetching process will return an array of map values for each discovered service instance, with
+// keys as discovery.ip, discovery.port, discovery.tag.<tag> or discovery.meta.<key>. The blocking
+// queries, if enabled, stop when the passed context is cancelled.
+func Discoverer(ctx context.Context, d discovery.Consul) (fetchDiscoveries func() (discoveries []discovery.Discovery, err error), err error) {
+	matcher, err := discovery.NewMatcher(d.Match)
+	if err != nil {
+		return nil, err
+	}
+	q := &querier{client: newClient(d), cfg: d}
+	if d.Blocking {
+		w := newWatcher(ctx, q)
+		return func() ([]discovery.Discovery, error) {
+			instances, err := w.instances()
+			if err != nil {
+				return nil, err
+			}
+			return match(instances, d, &matcher), nil
+		}, nil
+	}
+	return func() ([]discovery.Discovery, error) {
+		instances, err := q.all(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return match(instances, d, &matcher), nil
+	}, nil
+}
+
+func match(instances []instance, d discovery.Consul, matcher *discovery.FieldsMatcher) []discovery.Discovery {
+	// the services are returned always in the same order
+	sort.SliceStable(instances, func(i, j int) bool {
+		a, b := instances[i], instances[j]
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		if a.Node != b.Node {
+			return a.Node < b.Node
+		}
+		return a.ID < b.ID
+	})
+
+	var matches []discovery.Discovery
+	for _, i := range instances {
+		if len(d.Services) > 0 && !contains(d.Services, i.Name) {
+			continue
+		}
+		if !hasTags(i.Tags, d.Tags) {
+			continue
+		}
+		labels := map[string]string{
+			data.Name: i.Name,
+			data.ID:   i.ID,
+			data.IP:   i.Address,
+			data.Port: strconv.Itoa(i.Port),
+			data.Tags: strings.Join(i.Tags, ","),
+		}
+		if i.Node != "" {
+			labels[data.Node] = i.Node
+			labels[data.NodeIP] = i.NodeAddress
+		}
+		if i.Datacenter != "" {
+			labels[data.Datacenter] = i.Datacenter
+		}
+		for index, tag := range i.Tags {
+			labels[data.Tags+"."+strconv.Itoa(index)] = tag // discovery.tags.0 = <...>
+			labels[data.TagInfix+tag] = "true"              // discovery.tag.primary = true
+		}
+		for k, v := range i.Meta {
+			labels[data.MetaInfix+k] = v
+		}
+		for k, v := range i.NodeMeta {
+			labels[data.NodeMetaInfix+k] = v
+		}
+
+		if !matcher.All(labels) {
+			continue
+		}
+		ma := make(data.InterfaceMap, metricAnnotationsToAdd)
+		naming.AddServiceName(ma, i.Name)
+		if i.Node != "" {
+			naming.AddNodeName(ma, i.Node)
+		}
+		matches = append(matches, discovery.Discovery{
+			Variables:         discovery.LabelsToMap(data.DiscoveryPrefix, labels),
+			MetricAnnotations: ma,
+		})
+	}
+	return matches
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// hasTags returns whether all the required tags are within the passed ones.
+func hasTags(tags, required []string) bool {
+	for _, r := range required {
+		if !contains(tags, r) {
+			return false
+		}
+	}
+	return true
+}
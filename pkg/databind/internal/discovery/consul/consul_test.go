@@ -0,0 +1,285 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package consul
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/newrelic/infrastructure-agent/pkg/databind/internal/discovery"
+	"github.com/newrelic/infrastructure-agent/pkg/databind/pkg/data"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const catalogServices = `{"consul": [], "redis": ["primary", "v6"], "web": ["primary"]}`
+
+const redisPrimary = `{
+  "Node": "node-a", "Address": "10.0.0.1", "Datacenter": "dc1", "NodeMeta": {"zone": "a"},
+  "ServiceID": "redis-1", "ServiceName": "redis", "ServiceAddress": "", "ServicePort": 6379,
+  "ServiceTags": ["primary", "v6"], "ServiceMeta": {"version": "6.0"}
+}`
+
+const redisInstances = `[` + redisPrimary + `, {
+  "Node": "node-b", "Address": "10.0.0.2", "Datacenter": "dc1",
+  "ServiceID": "redis-2", "ServiceName": "redis", "ServiceAddress": "10.1.0.2", "ServicePort": 6380,
+  "ServiceTags": ["replica", "v6"], "ServiceMeta": {"version": "6.2"}
+}]`
+
+func TestDiscoverer_Catalog(t *testing.T) {
+	// GIVEN a catalog with some services
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "secret", r.Header.Get("X-Consul-Token"))
+		assert.Equal(t, "dc1", r.URL.Query().Get("dc"))
+		assert.Empty(t, r.URL.Query().Get("index"))
+		switch r.URL.Path {
+		case "/v1/catalog/services":
+			fmt.Fprint(w, catalogServices)
+		case "/v1/catalog/service/redis":
+			fmt.Fprint(w, redisInstances)
+		default:
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+	}))
+	defer srv.Close()
+
+	fetch, err := Discoverer(context.Background(), discovery.Consul{
+		Address:    srv.URL,
+		Token:      "secret",
+		Datacenter: "dc1",
+		Tags:       []string{"v6"},
+	})
+	require.NoError(t, err)
+
+	// WHEN the services with a tag are discovered
+	discoveries, err := fetch()
+
+	// THEN all their instances are returned with their attributes
+	require.NoError(t, err)
+	require.Len(t, discoveries, 2)
+	assert.Equal(t, data.Map{
+		"discovery.name":           "redis",
+		"discovery.id":             "redis-1",
+		"discovery.ip":             "10.0.0.1",
+		"discovery.port":           "6379",
+		"discovery.node":           "node-a",
+		"discovery.node.ip":        "10.0.0.1",
+		"discovery.datacenter":     "dc1",
+		"discovery.tags":           "primary,v6",
+		"discovery.tags.0":         "primary",
+		"discovery.tags.1":         "v6",
+		"discovery.tag.primary":    "true",
+		"discovery.tag.v6":         "true",
+		"discovery.meta.version":   "6.0",
+		"discovery.node.meta.zone": "a",
+	}, discoveries[0].Variables)
+	assert.Equal(t, "redis", discoveries[0].MetricAnnotations[data.ServiceName])
+	assert.Equal(t, "node-a", discoveries[0].MetricAnnotations[data.NodeName])
+	assert.Equal(t, "10.1.0.2", discoveries[1].Variables["discovery.ip"])
+	assert.Equal(t, "10.0.0.2", discoveries[1].Variables["discovery.node.ip"])
+}
+
+func TestDiscoverer_CatalogMatch(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/catalog/services":
+			fmt.Fprint(w, catalogServices)
+		case "/v1/catalog/service/redis":
+			fmt.Fprint(w, redisInstances)
+		default:
+			fmt.Fprint(w, `[]`)
+		}
+	}))
+	defer srv.Close()
+
+	// GIVEN a discoverer that matches the instance metadata
+	fetch, err := Discoverer(context.Background(), discovery.Consul{
+		Address: srv.URL,
+		Match:   map[string]string{"meta.version": "/^6\\.2/", "tag.replica": "true"},
+	})
+	require.NoError(t, err)
+
+	// WHEN the services are discovered
+	discoveries, err := fetch()
+
+	// THEN only the matching instances are returned
+	require.NoError(t, err)
+	require.Len(t, discoveries, 1)
+	assert.Equal(t, "redis-2", discoveries[0].Variables["discovery.id"])
+}
+
+func TestDiscoverer_PassingOnly(t *testing.T) {
+	// GIVEN a catalog whose health endpoint returns the passing instances
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/catalog/services":
+			fmt.Fprint(w, catalogServices)
+		case "/v1/health/service/redis":
+			assert.Equal(t, "true", r.URL.Query().Get("passing"))
+			fmt.Fprint(w, `[{
+  "Node": {"Node": "node-a", "Address": "10.0.0.1", "Datacenter": "dc1"},
+  "Service": {"ID": "redis-1", "Service": "redis", "Port": 6379, "Tags": ["primary"]}
+}]`)
+		default:
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+	}))
+	defer srv.Close()
+
+	fetch, err := Discoverer(context.Background(), discovery.Consul{Address: srv.URL, Services: []string{"redis"}, PassingOnly: true})
+	require.NoError(t, err)
+
+	// WHEN the services are discovered
+	discoveries, err := fetch()
+
+	// THEN the passing instances are returned
+	require.NoError(t, err)
+	require.Len(t, discoveries, 1)
+	assert.Equal(t, "10.0.0.1", discoveries[0].Variables["discovery.ip"])
+	assert.Equal(t, "node-a", discoveries[0].Variables["discovery.node"])
+	assert.Equal(t, "dc1", discoveries[0].Variables["discovery.datacenter"])
+}
+
+func TestDiscoverer_Agent(t *testing.T) {
+	// GIVEN an agent with registered services
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/agent/services", r.URL.Path)
+		assert.Empty(t, r.URL.Query().Get("dc"))
+		fmt.Fprint(w, `{
+  "redis-1": {"ID": "redis-1", "Service": "redis", "Port": 6379, "Tags": ["primary"], "Meta": {"version": "6.0"}},
+  "web-1": {"ID": "web-1", "Service": "web", "Address": "10.1.0.9", "Port": 8080}
+}`)
+	}))
+	defer srv.Close()
+
+	fetch, err := Discoverer(context.Background(), discovery.Consul{
+		Address:    srv.URL,
+		Datacenter: "dc1",
+		Source:     discovery.ConsulAgent,
+		Services:   []string{"redis"},
+	})
+	require.NoError(t, err)
+
+	// WHEN the services are discovered
+	discoveries, err := fetch()
+
+	// THEN the services without address are reachable through the agent address
+	require.NoError(t, err)
+	require.Len(t, discoveries, 1)
+	assert.Equal(t, "127.0.0.1", discoveries[0].Variables["discovery.ip"])
+	assert.Equal(t, "6.0", discoveries[0].Variables["discovery.meta.version"])
+	assert.NotContains(t, discoveries[0].Variables, "discovery.node")
+}
+
+func TestDiscoverer_Blocking(t *testing.T) {
+	// GIVEN a catalog whose redis service gets a new instance after the first query
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		index := r.URL.Query().Get("index")
+		switch {
+		case r.URL.Path == "/v1/catalog/services" && index == "":
+			w.Header().Set("X-Consul-Index", "5")
+			fmt.Fprint(w, catalogServices)
+		case r.URL.Path == "/v1/catalog/service/redis" && index == "":
+			w.Header().Set("X-Consul-Index", "5")
+			fmt.Fprint(w, `[`+redisPrimary+`]`)
+		case r.URL.Path == "/v1/catalog/service/redis" && index == "5":
+			assert.NotEmpty(t, r.URL.Query().Get("wait"))
+			w.Header().Set("X-Consul-Index", "6")
+			fmt.Fprint(w, redisInstances)
+		default:
+			// no more changes
+			<-r.Context().Done()
+		}
+	}))
+	defer srv.Close()
+	defer srv.CloseClientConnections()
+
+	fetch, err := Discoverer(context.Background(), discovery.Consul{Address: srv.URL, Services: []string{"redis"}, Blocking: true})
+	require.NoError(t, err)
+
+	// WHEN the services are discovered
+	discoveries, err := fetch()
+	require.NoError(t, err)
+	require.NotEmpty(t, discoveries)
+
+	// THEN the changes are eventually reflected
+	require.Eventually(t, func() bool {
+		discoveries, err = fetch()
+		return err == nil && len(discoveries) == 2
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, "redis-2", discoveries[1].Variables["discovery.id"])
+}
+
+func TestDiscoverer_BlockingStopsOnCancel(t *testing.T) {
+	// GIVEN a catalog without changes, holding the blocking queries
+	var lock sync.Mutex
+	pending := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Consul-Index", "5")
+		if r.URL.Query().Get("index") == "" {
+			if r.URL.Path == "/v1/catalog/services" {
+				fmt.Fprint(w, catalogServices)
+			} else {
+				fmt.Fprint(w, `[`+redisPrimary+`]`)
+			}
+			return
+		}
+		lock.Lock()
+		pending++
+		lock.Unlock()
+		<-r.Context().Done()
+		lock.Lock()
+		pending--
+		lock.Unlock()
+	}))
+	defer srv.Close()
+	pendingQueries := func() int {
+		lock.Lock()
+		defer lock.Unlock()
+		return pending
+	}
+
+	// AND a blocking discoverer watching the services and each of their instances
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	fetch, err := Discoverer(ctx, discovery.Consul{Address: srv.URL, Blocking: true})
+	require.NoError(t, err)
+	_, err = fetch()
+	require.NoError(t, err)
+	require.Eventually(t, func() bool { return pendingQueries() == 4 }, 5*time.Second, 10*time.Millisecond)
+
+	// WHEN the context is cancelled
+	cancel()
+
+	// THEN all the blocking queries are stopped
+	require.Eventually(t, func() bool { return pendingQueries() == 0 }, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestClient_IndexReset(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Consul-Index", "3")
+		fmt.Fprint(w, `{}`)
+	}))
+	defer srv.Close()
+	c := newClient(discovery.Consul{Address: srv.URL})
+
+	// GIVEN a blocking query whose index goes backwards
+	index, err := c.get(context.Background(), "/v1/catalog/services", nil, "10", &map[string][]string{})
+
+	// THEN the next blocking query starts again from zero
+	require.NoError(t, err)
+	assert.Equal(t, "0", index)
+}
+
+func TestNewClient_Address(t *testing.T) {
+	assert.Equal(t, "http://consul:8500", newClient(discovery.Consul{Address: "consul:8500"}).address)
+	assert.Equal(t, "https://consul:8501", newClient(discovery.Consul{Address: "https://consul:8501/"}).address)
+	assert.Equal(t, "consul", newClient(discovery.Consul{Address: "consul:8500"}).host())
+}
@@ -0,0 +1,198 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package consul
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/newrelic/infrastructure-agent/pkg/databind/internal/discovery"
+	"github.com/newrelic/infrastructure-agent/pkg/log"
+)
+
+const (
+	// minInterval between blocking queries, as they may return immediately, e.g. after an index reset
+	minInterval = time.Second
+	minBackoff  = time.Second
+	maxBackoff  = time.Minute
+)
+
+var wlog = log.WithComponent("databind.ConsulWatcher")
+
+// watcher keeps an up-to-date copy of the matching service instances. They are queried on the first
+// access and then updated in background through blocking queries, which return as soon as the services
+// change, until the context is cancelled.
+type watcher struct {
+	ctx     context.Context
+	querier *querier
+	// start serializes the initial queries
+	start   sync.Mutex
+	started bool
+	lock    sync.Mutex
+	// byService holds the instances of each catalog service, or of the whole agent
+	byService map[string][]instance
+	// cancels stops the watch of each catalog service. Only accessed by the services watch.
+	cancels map[string]context.CancelFunc
+}
+
+func newWatcher(ctx context.Context, q *querier) *watcher {
+	return &watcher{
+		ctx:       ctx,
+		querier:   q,
+		byService: map[string][]instance{},
+		cancels:   map[string]context.CancelFunc{},
+	}
+}
+
+// instances returns the current service instances. The first invocation queries them and starts
+// watching their changes.
+func (w *watcher) instances() ([]instance, error) {
+	w.start.Lock()
+	if !w.started {
+		if err := w.sync(); err != nil {
+			w.start.Unlock()
+			return nil, err
+		}
+		w.started = true
+	}
+	w.start.Unlock()
+
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	var instances []instance
+	for _, found := range w.byService {
+		instances = append(instances, found...)
+	}
+	return instances, nil
+}
+
+// sync queries the current instances and starts the blocking queries from their index.
+func (w *watcher) sync() error {
+	ctx := w.ctx
+	if w.querier.cfg.Source == discovery.ConsulAgent {
+		query := w.agentQuery()
+		hash, err := query(ctx, "")
+		if err != nil {
+			return err
+		}
+		go w.loop(ctx, hash, query)
+		return nil
+	}
+
+	names, index, err := w.querier.services(ctx, "")
+	if err != nil {
+		return err
+	}
+	indexes := make(map[string]string, len(names))
+	for _, name := range names {
+		if indexes[name], err = w.serviceQuery(name)(ctx, ""); err != nil {
+			return err
+		}
+	}
+	for _, name := range names {
+		serviceCtx, cancel := context.WithCancel(ctx)
+		w.cancels[name] = cancel
+		go w.loop(serviceCtx, indexes[name], w.serviceQuery(name))
+	}
+	go w.loop(ctx, index, w.servicesQuery())
+	return nil
+}
+
+// loop repeats a blocking query, from the index returned by the previous one, until the context is cancelled.
+func (w *watcher) loop(ctx context.Context, index string, query func(ctx context.Context, index string) (string, error)) {
+	backoff := minBackoff
+	for ctx.Err() == nil {
+		started := time.Now()
+		newIndex, err := query(ctx, index)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			wlog.WithError(err).Warn("consul blocking query failed, retrying")
+			sleep(ctx, backoff)
+			if backoff *= 2; backoff > maxBackoff {
+				backoff = maxBackoff
+			}
+			continue
+		}
+		backoff = minBackoff
+		index = newIndex
+		sleep(ctx, minInterval-time.Since(started))
+	}
+}
+
+// servicesQuery updates the watched services as they are registered or deregistered in the catalog.
+func (w *watcher) servicesQuery() func(ctx context.Context, index string) (string, error) {
+	return func(ctx context.Context, index string) (string, error) {
+		names, index, err := w.querier.services(ctx, index)
+		if err != nil {
+			return "", err
+		}
+		current := make(map[string]bool, len(names))
+		for _, name := range names {
+			current[name] = true
+			if _, ok := w.cancels[name]; !ok {
+				serviceCtx, cancel := context.WithCancel(ctx)
+				w.cancels[name] = cancel
+				go w.loop(serviceCtx, "", w.serviceQuery(name))
+			}
+		}
+		for name, cancel := range w.cancels {
+			if current[name] {
+				continue
+			}
+			cancel()
+			delete(w.cancels, name)
+			w.lock.Lock()
+			delete(w.byService, name)
+			w.lock.Unlock()
+		}
+		return index, nil
+	}
+}
+
+// serviceQuery updates the instances of a catalog service.
+func (w *watcher) serviceQuery(name string) func(ctx context.Context, index string) (string, error) {
+	return func(ctx context.Context, index string) (string, error) {
+		instances, index, err := w.querier.instances(ctx, name, index)
+		if err != nil {
+			return "", err
+		}
+		w.lock.Lock()
+		// the service may have been deregistered meanwhile
+		if ctx.Err() == nil {
+			w.byService[name] = instances
+		}
+		w.lock.Unlock()
+		return index, nil
+	}
+}
+
+// agentQuery updates the services of the local agent.
+func (w *watcher) agentQuery() func(ctx context.Context, index string) (string, error) {
+	return func(ctx context.Context, index string) (string, error) {
+		instances, index, err := w.querier.agentInstances(ctx, index)
+		if err != nil {
+			return "", err
+		}
+		w.lock.Lock()
+		w.byService[""] = instances
+		w.lock.Unlock()
+		return index, nil
+	}
+}
+
+// sleep waits for the passed duration, or until the context is cancelled.
+func sleep(ctx context.Context, d time.Duration) {
+	if d <= 0 {
+		return
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+	case <-ctx.Done():
+	}
+}
@@ -0,0 +1,38 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package discovery
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Containerd APIs to list the containers from
+const (
+	ContainerdAPI = "containerd"
+	CRIAPI        = "cri"
+)
+
+// Containerd discovery parameters
+type Containerd struct {
+	Match map[string]string `yaml:"match"`
+	// Socket is the containerd socket, serving both the containerd and the CRI APIs.
+	// Default: /run/containerd/containerd.sock
+	Socket string `yaml:"socket"`
+	// Namespace of the containers listed through the containerd API. Default: k8s.io
+	Namespace string `yaml:"namespace"`
+	// API is either containerd or cri. By default the containerd API is used, falling back to CRI
+	// if the containerd API can't be queried
+	API string `yaml:"api"`
+}
+
+func (c *Containerd) Validate() error {
+	if len(c.Match) == 0 {
+		return errors.New("missing 'match' entries")
+	}
+	if c.API != "" && c.API != ContainerdAPI && c.API != CRIAPI {
+		return fmt.Errorf("unsupported containerd api %q, expected %q or %q", c.API, ContainerdAPI, CRIAPI)
+	}
+	return nil
+}
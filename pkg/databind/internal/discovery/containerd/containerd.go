@@ -0,0 +1,223 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package containerd discovers the containers of the hosts running containerd with no docker socket,
+// as Kubernetes nodes without dockershim. Containers are listed through the containerd API by means
+// of the ctr client shipped with containerd, or through the CRI API by means of crictl. The matched
+// fields and the discovery variables are the same as for the docker discovery.
+package containerd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/newrelic/infrastructure-agent/pkg/databind/internal/counter"
+	"github.com/newrelic/infrastructure-agent/pkg/databind/internal/discovery"
+	"github.com/newrelic/infrastructure-agent/pkg/databind/internal/discovery/naming"
+	"github.com/newrelic/infrastructure-agent/pkg/databind/pkg/data"
+	"github.com/newrelic/infrastructure-agent/pkg/helpers"
+)
+
+const (
+	defaultSocket          = "/run/containerd/containerd.sock"
+	defaultNamespace       = "k8s.io"
+	commandTimeout         = 30 * time.Second
+	metricAnnotationsToAdd = 4
+)
+
+// container as listed by any of the APIs.
+type container struct {
+	id     string
+	name   string
+	image  string
+	labels map[string]string
+	ips    []string
+	ports  []portMapping
+}
+
+// portMapping of a container port. Fields are matched case-insensitively, so it can be decoded from
+// both the nerdctl ports label and the Kubernetes ports annotation.
+type portMapping struct {
+	HostIP        string `json:"hostIP"`
+	HostPort      int    `json:"hostPort"`
+	ContainerPort int    `json:"containerPort"`
+	Protocol      string `json:"protocol"`
+}
+
+// lister returns the running containers.
+type lister func() ([]container, error)
+
+// Discoverer returns a containerd container discoverer from the provided configuration.
+func Discoverer(c discovery.Containerd) (fetchDiscoveries func() (discoveries []discovery.Discovery, err error), err error) {
+	matcher, err := discovery.NewMatcher(c.Match)
+	if err != nil {
+		return nil, err
+	}
+	if c.Socket == "" {
+		c.Socket = defaultSocket
+	}
+	if c.Namespace == "" {
+		c.Namespace = defaultNamespace
+	}
+	ctr := &ctrClient{socket: c.Socket, namespace: c.Namespace, procDir: helpers.HostProc(), run: runCommand}
+	cri := &criClient{endpoint: "unix://" + strings.TrimPrefix(c.Socket, "unix://"), run: runCommand}
+
+	var list lister
+	switch c.API {
+	case discovery.ContainerdAPI:
+		list = ctr.containers
+	case discovery.CRIAPI:
+		list = cri.containers
+	default:
+		list = withFallback(ctr.containers, cri.containers)
+	}
+	return func() ([]discovery.Discovery, error) {
+		containers, err := list()
+		if err != nil {
+			return nil, err
+		}
+		return match(containers, &matcher), nil
+	}, nil
+}
+
+// withFallback lists the containers from the fallback lister when the primary one fails.
+func withFallback(primary, fallback lister) lister {
+	return func() ([]container, error) {
+		containers, err := primary()
+		if err == nil {
+			return containers, nil
+		}
+		containers, fallbackErr := fallback()
+		if fallbackErr != nil {
+			return nil, fmt.Errorf("containerd API: %s. CRI API: %s", err, fallbackErr)
+		}
+		return containers, nil
+	}
+}
+
+func match(containers []container, matcher *discovery.FieldsMatcher) []discovery.Discovery {
+	var matches []discovery.Discovery
+	for _, cont := range containers {
+		// discovery attributes that identify the container
+		labels := map[string]string{}
+		for k, v := range cont.labels {
+			labels[data.LabelInfix+k] = v
+		}
+		labels[data.Name] = cont.name
+		labels[data.Image] = cont.image
+		labels[data.ContainerID] = cont.id
+
+		for index, ip := range cont.ips {
+			if index == 0 {
+				labels[data.PrivateIP] = ip
+			}
+			labels[data.PrivateIP+"."+strconv.Itoa(index)] = ip
+		}
+
+		addPorts(cont, labels)
+
+		// only containers matching all the criteria will be added
+		if !matcher.All(labels) {
+			continue
+		}
+		ma := make(data.InterfaceMap, metricAnnotationsToAdd)
+		naming.AddImage(ma, cont.image)
+		naming.AddContainerName(ma, cont.name)
+		naming.AddContainerID(ma, cont.id)
+		naming.AddLabels(ma, cont.labels)
+
+		matches = append(matches, discovery.Discovery{
+			Variables: discovery.LabelsToMap(data.DiscoveryPrefix, labels),
+			EntityRewrites: []data.EntityRewrite{
+				{
+					Action:       data.EntityRewriteActionReplace,
+					Match:        naming.ToVariable(data.IP),
+					ReplaceField: data.ContainerReplaceFieldPrefix + naming.ToVariable(data.ContainerID),
+				},
+			},
+			MetricAnnotations: ma,
+		})
+	}
+	return matches
+}
+
+// addPorts labels the ports as the docker discovery does. Ports not published in the host are
+// reachable in the container IP, so it is taken as their public address.
+func addPorts(cont container, labels map[string]string) {
+	// sort ports from lower to higher so we are always consistent with the returned ports
+	sort.Slice(cont.ports, func(i, j int) bool {
+		return cont.ports[i].ContainerPort < cont.ports[j].ContainerPort
+	})
+
+	types := counter.ByKind{}
+	for index, port := range cont.ports {
+		ip, publicPort := port.HostIP, port.HostPort
+		if publicPort == 0 {
+			publicPort = port.ContainerPort
+			if len(cont.ips) > 0 {
+				ip = cont.ips[0]
+			}
+		} else if ip == "" {
+			ip = "0.0.0.0"
+		}
+		indexStr := "." + strconv.Itoa(index)
+		labels[data.IP+indexStr] = ip
+		labels[data.IP] = ip
+
+		public := strconv.Itoa(publicPort)
+		private := strconv.Itoa(port.ContainerPort)
+		if index == 0 {
+			labels[data.Port] = public
+			labels[data.PrivatePort] = private
+		}
+		labels[data.Ports+indexStr] = public
+		labels[data.PrivatePorts+indexStr] = private
+
+		// label ports by type (e.g. discovery.port.tcp.1)
+		if kind := strings.ToLower(port.Protocol); kind != "" {
+			if types.Count(kind) == 0 {
+				labels[data.Ports+"."+kind] = public
+				labels[data.PrivatePorts+"."+kind] = private
+			}
+			labels[data.Ports+"."+kind+indexStr] = public
+			labels[data.PrivatePorts+"."+kind+indexStr] = private
+		}
+	}
+}
+
+// decodePorts decodes a JSON list of port mappings, ignoring the malformed ones.
+func decodePorts(encoded string) []portMapping {
+	if encoded == "" {
+		return nil
+	}
+	var ports []portMapping
+	if err := json.Unmarshal([]byte(encoded), &ports); err != nil {
+		return nil
+	}
+	return ports
+}
+
+// runCommand runs an API client, returning its standard output.
+func runCommand(name string, args ...string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), commandTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, name, args...)
+	stdout, stderr := new(bytes.Buffer), new(bytes.Buffer)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("%s timed out", name)
+		}
+		return nil, errors.New(strings.TrimSpace(stderr.String() + " " + err.Error()))
+	}
+	return stdout.Bytes(), nil
+}
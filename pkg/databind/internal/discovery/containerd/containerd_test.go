@@ -0,0 +1,174 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package containerd
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/newrelic/infrastructure-agent/pkg/databind/internal/discovery"
+	"github.com/newrelic/infrastructure-agent/pkg/databind/pkg/data"
+)
+
+// fakeRun returns the output of the client invocations from the passed map, keyed by the
+// command line without the connection flags.
+func fakeRun(outputs map[string]string) func(name string, args ...string) ([]byte, error) {
+	return func(name string, args ...string) ([]byte, error) {
+		// skip --address/--namespace or --runtime-endpoint flags
+		for len(args) > 1 && strings.HasPrefix(args[0], "--") {
+			args = args[2:]
+		}
+		out, ok := outputs[name+" "+strings.Join(args, " ")]
+		if !ok {
+			return nil, errors.New(name + ": not found")
+		}
+		return []byte(out), nil
+	}
+}
+
+const fibTrie = `Main:
+  +-- 0.0.0.0/0 3 0 5
+     |-- 0.0.0.0
+        /0 universe UNICAST
+     +-- 10.88.0.0/16 2 0 2
+        |-- 10.88.0.0
+           /16 link UNICAST
+        |-- 10.88.0.5
+           /32 host LOCAL
+     |-- 127.0.0.1
+        /32 host LOCAL
+Local:
+  +-- 10.88.0.0/16 2 0 2
+     |-- 10.88.0.5
+        /32 host LOCAL
+`
+
+func TestCtrClient(t *testing.T) {
+	// GIVEN a proc filesystem with the network namespace of a task
+	procDir, err := ioutil.TempDir("", "proc")
+	require.NoError(t, err)
+	defer os.RemoveAll(procDir)
+	require.NoError(t, os.MkdirAll(filepath.Join(procDir, "1234", "net"), 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(procDir, "1234", "net", "fib_trie"), []byte(fibTrie), 0644))
+
+	// AND the containerd API listing a nerdctl container, a pod sandbox and a stopped task
+	c := &ctrClient{procDir: procDir, run: fakeRun(map[string]string{
+		"ctr tasks ls": `TASK       PID     STATUS
+redis-1    1234    RUNNING
+pause-1    1200    RUNNING
+old-1      0       STOPPED
+`,
+		"ctr containers info redis-1": `{"ID":"redis-1","Image":"docker.io/library/redis:6",
+"Labels":{"nerdctl/name":"redis","app":"cache","nerdctl/ports":"[{\"HostPort\":16379,\"ContainerPort\":6379,\"Protocol\":\"tcp\",\"HostIP\":\"0.0.0.0\"}]"}}`,
+		"ctr containers info pause-1": `{"ID":"pause-1","Image":"k8s.gcr.io/pause:3.2","Labels":{"io.cri-containerd.kind":"sandbox"}}`,
+	})}
+
+	// WHEN the containers are listed
+	containers, err := c.containers()
+	require.NoError(t, err)
+
+	// THEN only the running workloads are returned, with the addresses of their network namespace
+	require.Len(t, containers, 1)
+	assert.Equal(t, "redis", containers[0].name)
+	assert.Equal(t, "docker.io/library/redis:6", containers[0].image)
+	assert.Equal(t, []string{"10.88.0.5"}, containers[0].ips)
+	assert.Equal(t, []portMapping{{HostIP: "0.0.0.0", HostPort: 16379, ContainerPort: 6379, Protocol: "tcp"}}, containers[0].ports)
+}
+
+func TestCriClient(t *testing.T) {
+	// GIVEN the CRI API listing the containers of a Kubernetes pod
+	c := &criClient{run: fakeRun(map[string]string{
+		"crictl ps -o json": `{"containers":[{
+  "id": "a1b2", "podSandboxId": "pod-1", "metadata": {"name": "redis"},
+  "image": {"image": "sha256:ef47"}, "imageRef": "docker.io/library/redis@sha256:ef47",
+  "labels": {"io.kubernetes.pod.name": "cache-0"},
+  "annotations": {"io.kubernetes.container.ports": "[{\"name\":\"redis\",\"containerPort\":6379,\"protocol\":\"TCP\"}]"}
+}, {
+  "id": "c3d4", "podSandboxId": "pod-1", "metadata": {"name": "exporter"},
+  "image": {"image": "docker.io/oliver006/redis_exporter:v1"}
+}]}`,
+		"crictl inspectp -o json pod-1": `{"status":{"network":{"ip":"10.42.0.7","additionalIps":[{"ip":"fd00::7"}]}}}`,
+	})}
+
+	// WHEN the containers are listed
+	containers, err := c.containers()
+	require.NoError(t, err)
+
+	// THEN they are returned with the pod addresses and the declared ports
+	require.Len(t, containers, 2)
+	assert.Equal(t, "redis", containers[0].name)
+	assert.Equal(t, "docker.io/library/redis@sha256:ef47", containers[0].image)
+	assert.Equal(t, []string{"10.42.0.7", "fd00::7"}, containers[0].ips)
+	assert.Equal(t, []portMapping{{ContainerPort: 6379, Protocol: "TCP"}}, containers[0].ports)
+	assert.Equal(t, "docker.io/oliver006/redis_exporter:v1", containers[1].image)
+	assert.Equal(t, []string{"10.42.0.7", "fd00::7"}, containers[1].ips)
+}
+
+func TestMatch(t *testing.T) {
+	containers := []container{{
+		id:     "a1b2",
+		name:   "redis",
+		image:  "docker.io/library/redis:6",
+		labels: map[string]string{"app": "cache"},
+		ips:    []string{"10.42.0.7"},
+		ports:  []portMapping{{ContainerPort: 9121, Protocol: "TCP"}, {ContainerPort: 6379, Protocol: "TCP"}},
+	}, {
+		id:    "c3d4",
+		name:  "web",
+		image: "docker.io/library/nginx:1.19",
+	}}
+	matcher, err := discovery.NewMatcher(map[string]string{"image": "/redis/", "label.app": "cache"})
+	require.NoError(t, err)
+
+	matches := match(containers, &matcher)
+
+	require.Len(t, matches, 1)
+	assert.Equal(t, data.Map{
+		"discovery.containerId":         "a1b2",
+		"discovery.name":                "redis",
+		"discovery.image":               "docker.io/library/redis:6",
+		"discovery.label.app":           "cache",
+		"discovery.private.ip":          "10.42.0.7",
+		"discovery.private.ip.0":        "10.42.0.7",
+		"discovery.ip":                  "10.42.0.7",
+		"discovery.ip.0":                "10.42.0.7",
+		"discovery.ip.1":                "10.42.0.7",
+		"discovery.port":                "6379",
+		"discovery.ports.0":             "6379",
+		"discovery.ports.1":             "9121",
+		"discovery.ports.tcp":           "6379",
+		"discovery.ports.tcp.0":         "6379",
+		"discovery.ports.tcp.1":         "9121",
+		"discovery.private.port":        "6379",
+		"discovery.private.ports.0":     "6379",
+		"discovery.private.ports.1":     "9121",
+		"discovery.private.ports.tcp":   "6379",
+		"discovery.private.ports.tcp.0": "6379",
+		"discovery.private.ports.tcp.1": "9121",
+	}, matches[0].Variables)
+	assert.Equal(t, "a1b2", matches[0].MetricAnnotations["containerId"])
+}
+
+func TestWithFallback(t *testing.T) {
+	failing := func() ([]container, error) { return nil, errors.New("ctr: not found") }
+	working := func() ([]container, error) { return []container{{id: "a1b2"}}, nil }
+
+	containers, err := withFallback(working, failing)()
+	require.NoError(t, err)
+	assert.Len(t, containers, 1)
+
+	containers, err = withFallback(failing, working)()
+	require.NoError(t, err)
+	assert.Len(t, containers, 1)
+
+	_, err = withFallback(failing, failing)()
+	assert.EqualError(t, err, "containerd API: ctr: not found. CRI API: ctr: not found")
+}
@@ -0,0 +1,109 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package containerd
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// criPortsAnnotation is set by the kubelet with the ports declared in the container spec.
+const criPortsAnnotation = "io.kubernetes.container.ports"
+
+// criClient lists the containers through the CRI API.
+type criClient struct {
+	endpoint string
+	run      func(name string, args ...string) ([]byte, error)
+}
+
+// criContainers is the output of "crictl ps -o json", which lists the running containers.
+type criContainers struct {
+	Containers []struct {
+		ID           string `json:"id"`
+		PodSandboxID string `json:"podSandboxId"`
+		Metadata     struct {
+			Name string `json:"name"`
+		} `json:"metadata"`
+		Image struct {
+			Image string `json:"image"`
+		} `json:"image"`
+		ImageRef    string            `json:"imageRef"`
+		Labels      map[string]string `json:"labels"`
+		Annotations map[string]string `json:"annotations"`
+	} `json:"containers"`
+}
+
+// criPod is the output of "crictl inspectp -o json".
+type criPod struct {
+	Status struct {
+		Network struct {
+			IP            string `json:"ip"`
+			AdditionalIPs []struct {
+				IP string `json:"ip"`
+			} `json:"additionalIps"`
+		} `json:"network"`
+	} `json:"status"`
+}
+
+func (c *criClient) crictl(args ...string) ([]byte, error) {
+	return c.run("crictl", append([]string{"--runtime-endpoint", c.endpoint}, args...)...)
+}
+
+func (c *criClient) containers() ([]container, error) {
+	out, err := c.crictl("ps", "-o", "json")
+	if err != nil {
+		return nil, err
+	}
+	var list criContainers
+	if err := json.Unmarshal(out, &list); err != nil {
+		return nil, fmt.Errorf("can't parse CRI containers: %s", err)
+	}
+
+	// containers of the same pod share its network
+	podIPs := map[string][]string{}
+	containers := make([]container, 0, len(list.Containers))
+	for _, cont := range list.Containers {
+		ips, ok := podIPs[cont.PodSandboxID]
+		if !ok && cont.PodSandboxID != "" {
+			if ips, err = c.podIPs(cont.PodSandboxID); err != nil {
+				return nil, err
+			}
+			podIPs[cont.PodSandboxID] = ips
+		}
+		// the image may be referred by its ID
+		image := cont.Image.Image
+		if strings.HasPrefix(image, "sha256:") && cont.ImageRef != "" {
+			image = cont.ImageRef
+		}
+		containers = append(containers, container{
+			id:     cont.ID,
+			name:   cont.Metadata.Name,
+			image:  image,
+			labels: cont.Labels,
+			ips:    ips,
+			ports:  decodePorts(cont.Annotations[criPortsAnnotation]),
+		})
+	}
+	return containers, nil
+}
+
+func (c *criClient) podIPs(podID string) ([]string, error) {
+	out, err := c.crictl("inspectp", "-o", "json", podID)
+	if err != nil {
+		return nil, err
+	}
+	var pod criPod
+	if err := json.Unmarshal(out, &pod); err != nil {
+		return nil, fmt.Errorf("can't parse CRI pod %s: %s", podID, err)
+	}
+	var ips []string
+	if pod.Status.Network.IP != "" {
+		ips = append(ips, pod.Status.Network.IP)
+	}
+	for _, additional := range pod.Status.Network.AdditionalIPs {
+		ips = append(ips, additional.IP)
+	}
+	return ips, nil
+}
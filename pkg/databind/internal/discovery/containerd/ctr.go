@@ -0,0 +1,146 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package containerd
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Labels set by nerdctl and by the containerd CRI plugin.
+const (
+	nerdctlNameLabel  = "nerdctl/name"
+	nerdctlPortsLabel = "nerdctl/ports"
+	criNameLabel      = "io.kubernetes.container.name"
+	criKindLabel      = "io.cri-containerd.kind"
+	criSandboxKind    = "sandbox"
+)
+
+const (
+	runningTaskStatus = "RUNNING"
+	fibTrieLocalEntry = "/32 host LOCAL"
+)
+
+// ctrClient lists the containers through the containerd API.
+type ctrClient struct {
+	socket    string
+	namespace string
+	// procDir is the proc filesystem, to read the addresses of the tasks network namespaces
+	procDir string
+	run     func(name string, args ...string) ([]byte, error)
+}
+
+// ctrContainer is the container record returned by "ctr containers info".
+type ctrContainer struct {
+	ID     string            `json:"ID"`
+	Labels map[string]string `json:"Labels"`
+	Image  string            `json:"Image"`
+}
+
+func (c *ctrClient) ctr(args ...string) ([]byte, error) {
+	return c.run("ctr", append([]string{"--address", c.socket, "--namespace", c.namespace}, args...)...)
+}
+
+func (c *ctrClient) containers() ([]container, error) {
+	out, err := c.ctr("tasks", "ls")
+	if err != nil {
+		return nil, err
+	}
+	tasks := runningTasks(out)
+	ids := make([]string, 0, len(tasks))
+	for id := range tasks {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	var containers []container
+	for _, id := range ids {
+		out, err := c.ctr("containers", "info", id)
+		if err != nil {
+			return nil, err
+		}
+		var info ctrContainer
+		if err := json.Unmarshal(out, &info); err != nil {
+			return nil, fmt.Errorf("can't parse container %s info: %s", id, err)
+		}
+		// Kubernetes pod sandboxes are not workloads
+		if info.Labels[criKindLabel] == criSandboxKind {
+			continue
+		}
+		name := info.Labels[nerdctlNameLabel]
+		if name == "" {
+			name = info.Labels[criNameLabel]
+		}
+		if name == "" {
+			name = id
+		}
+		containers = append(containers, container{
+			id:     id,
+			name:   name,
+			image:  info.Image,
+			labels: info.Labels,
+			ips:    c.taskIPs(tasks[id]),
+			ports:  decodePorts(info.Labels[nerdctlPortsLabel]),
+		})
+	}
+	return containers, nil
+}
+
+// runningTasks returns the PIDs of the running tasks, by container ID, from the "ctr tasks ls" table.
+func runningTasks(table []byte) map[string]string {
+	tasks := map[string]string{}
+	scanner := bufio.NewScanner(bytes.NewReader(table))
+	// skip header
+	scanner.Scan()
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 3 && fields[2] == runningTaskStatus {
+			tasks[fields[0]] = fields[1]
+		}
+	}
+	return tasks
+}
+
+// taskIPs returns the non-loopback IPv4 addresses of the network namespace of a task, as the containerd
+// API doesn't know about the container networks.
+func (c *ctrClient) taskIPs(pid string) []string {
+	f, err := os.Open(filepath.Join(c.procDir, pid, "net", "fib_trie"))
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	return localAddresses(f)
+}
+
+// localAddresses returns the local addresses listed in a fib_trie file, where they are followed
+// by a "/32 host LOCAL" line.
+func localAddresses(r io.Reader) []string {
+	var ips []string
+	seen := map[string]bool{}
+	last := ""
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "|-- ") {
+			last = strings.TrimPrefix(line, "|-- ")
+			continue
+		}
+		if line != fibTrieLocalEntry || seen[last] {
+			continue
+		}
+		if ip := net.ParseIP(last); ip != nil && !ip.IsLoopback() {
+			ips = append(ips, last)
+			seen[last] = true
+		}
+	}
+	return ips
+}
@@ -0,0 +1,33 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package discovery
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// DNS SRV discovery parameters
+type DNS struct {
+	Match map[string]string `yaml:"match"`
+	// Record is the name of the SRV record, e.g. _postgresql._tcp.db.example.com
+	Record string `yaml:"record"`
+	// Server is the address of the DNS server. Default: first nameserver in /etc/resolv.conf
+	Server string `yaml:"server"`
+	// Timeout of the DNS queries. Default: 5s
+	Timeout string `yaml:"timeout"`
+}
+
+func (d *DNS) Validate() error {
+	if d.Record == "" {
+		return errors.New("missing 'record' entry")
+	}
+	if d.Timeout != "" {
+		if _, err := time.ParseDuration(d.Timeout); err != nil {
+			return fmt.Errorf("invalid DNS timeout %q: %s", d.Timeout, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,278 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package dns
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+const (
+	resolvConf     = "/etc/resolv.conf"
+	defaultServer  = "127.0.0.1:53"
+	udpPayloadSize = 4096
+	// negativeTTL caches the non-existing records when the server doesn't provide the SOA record
+	negativeTTL = 30 * time.Second
+)
+
+// srv is a target of an SRV record.
+type srv struct {
+	target   string
+	port     uint16
+	priority uint16
+	weight   uint16
+}
+
+// answer of an SRV query.
+type answer struct {
+	records []srv
+	// addresses of the targets, as provided in the additional section of the response
+	addresses map[string][]string
+	// ttl is the minimum TTL of the returned records
+	ttl time.Duration
+}
+
+// client queries a DNS server. Go's resolver doesn't provide the TTL of the records, so the queries
+// are built and sent by the client itself.
+type client struct {
+	server  string
+	timeout time.Duration
+}
+
+// lookupSRV queries the SRV records of a fully qualified name, through UDP and, if the response is
+// truncated, through TCP.
+func (c *client) lookupSRV(name string) (answer, error) {
+	query, id, err := buildQuery(name)
+	if err != nil {
+		return answer{}, err
+	}
+	response, err := c.exchange("udp", query)
+	if err != nil {
+		return answer{}, err
+	}
+	a, truncated, err := parseAnswer(response, id)
+	if err == nil && truncated {
+		if response, err = c.exchange("tcp", query); err != nil {
+			return answer{}, err
+		}
+		a, _, err = parseAnswer(response, id)
+	}
+	return a, err
+}
+
+func (c *client) exchange(network string, query []byte) ([]byte, error) {
+	conn, err := net.DialTimeout(network, c.server, c.timeout)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if err := conn.SetDeadline(time.Now().Add(c.timeout)); err != nil {
+		return nil, err
+	}
+
+	if network == "udp" {
+		if _, err := conn.Write(query); err != nil {
+			return nil, err
+		}
+		response := make([]byte, udpPayloadSize)
+		n, err := conn.Read(response)
+		if err != nil {
+			return nil, err
+		}
+		return response[:n], nil
+	}
+
+	// TCP messages are prefixed by their length
+	message := make([]byte, 2+len(query))
+	binary.BigEndian.PutUint16(message, uint16(len(query)))
+	copy(message[2:], query)
+	if _, err := conn.Write(message); err != nil {
+		return nil, err
+	}
+	var length [2]byte
+	if _, err := io.ReadFull(conn, length[:]); err != nil {
+		return nil, err
+	}
+	response := make([]byte, binary.BigEndian.Uint16(length[:]))
+	if _, err := io.ReadFull(conn, response); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+func buildQuery(name string) ([]byte, uint16, error) {
+	qname, err := dnsmessage.NewName(name)
+	if err != nil {
+		return nil, 0, err
+	}
+	id := uint16(rand.Uint32())
+	b := dnsmessage.NewBuilder(make([]byte, 0, 512), dnsmessage.Header{ID: id, RecursionDesired: true})
+	b.EnableCompression()
+	if err := b.StartQuestions(); err != nil {
+		return nil, 0, err
+	}
+	if err := b.Question(dnsmessage.Question{Name: qname, Type: dnsmessage.TypeSRV, Class: dnsmessage.ClassINET}); err != nil {
+		return nil, 0, err
+	}
+	// EDNS allows receiving UDP responses bigger than 512 bytes
+	if err := b.StartAdditionals(); err != nil {
+		return nil, 0, err
+	}
+	var opt dnsmessage.ResourceHeader
+	if err := opt.SetEDNS0(udpPayloadSize, dnsmessage.RCodeSuccess, false); err != nil {
+		return nil, 0, err
+	}
+	if err := b.OPTResource(opt, dnsmessage.OPTResource{}); err != nil {
+		return nil, 0, err
+	}
+	query, err := b.Finish()
+	return query, id, err
+}
+
+// parseAnswer returns the SRV records of a response, and whether it was truncated.
+func parseAnswer(response []byte, id uint16) (answer, bool, error) {
+	var p dnsmessage.Parser
+	header, err := p.Start(response)
+	if err != nil {
+		return answer{}, false, err
+	}
+	if header.ID != id || !header.Response {
+		return answer{}, false, errors.New("unexpected DNS response")
+	}
+	if header.Truncated {
+		return answer{}, true, nil
+	}
+	if err := p.SkipAllQuestions(); err != nil {
+		return answer{}, false, err
+	}
+
+	a := answer{addresses: map[string][]string{}}
+	var minTTL uint32
+	hasTTL := false
+	setTTL := func(ttl uint32) {
+		if !hasTTL || ttl < minTTL {
+			minTTL, hasTTL = ttl, true
+		}
+	}
+
+	switch header.RCode {
+	case dnsmessage.RCodeSuccess:
+	case dnsmessage.RCodeNameError:
+		// the record doesn't exist: no targets are discovered until the negative TTL expires
+		a.ttl = negativeTTL
+		if err := p.SkipAllAnswers(); err != nil {
+			return a, false, nil
+		}
+		if h, err := p.AuthorityHeader(); err == nil && h.Type == dnsmessage.TypeSOA {
+			if soa, err := p.SOAResource(); err == nil {
+				ttl := soa.MinTTL
+				if h.TTL < ttl {
+					ttl = h.TTL
+				}
+				a.ttl = time.Duration(ttl) * time.Second
+			}
+		}
+		return a, false, nil
+	default:
+		return answer{}, false, fmt.Errorf("DNS query failed: %s", header.RCode)
+	}
+
+	for {
+		h, err := p.AnswerHeader()
+		if err == dnsmessage.ErrSectionDone {
+			break
+		}
+		if err != nil {
+			return answer{}, false, err
+		}
+		if h.Type != dnsmessage.TypeSRV {
+			if err := p.SkipAnswer(); err != nil {
+				return answer{}, false, err
+			}
+			continue
+		}
+		r, err := p.SRVResource()
+		if err != nil {
+			return answer{}, false, err
+		}
+		setTTL(h.TTL)
+		a.records = append(a.records, srv{
+			target:   strings.TrimSuffix(r.Target.String(), "."),
+			port:     r.Port,
+			priority: r.Priority,
+			weight:   r.Weight,
+		})
+	}
+	if err := p.SkipAllAuthorities(); err != nil {
+		return answer{}, false, err
+	}
+
+	for {
+		h, err := p.AdditionalHeader()
+		if err == dnsmessage.ErrSectionDone {
+			break
+		}
+		if err != nil {
+			return answer{}, false, err
+		}
+		host := strings.TrimSuffix(h.Name.String(), ".")
+		switch h.Type {
+		case dnsmessage.TypeA:
+			r, err := p.AResource()
+			if err != nil {
+				return answer{}, false, err
+			}
+			setTTL(h.TTL)
+			a.addresses[host] = append(a.addresses[host], net.IP(r.A[:]).String())
+		case dnsmessage.TypeAAAA:
+			r, err := p.AAAAResource()
+			if err != nil {
+				return answer{}, false, err
+			}
+			setTTL(h.TTL)
+			a.addresses[host] = append(a.addresses[host], net.IP(r.AAAA[:]).String())
+		default:
+			if err := p.SkipAdditional(); err != nil {
+				return answer{}, false, err
+			}
+		}
+	}
+	a.ttl = time.Duration(minTTL) * time.Second
+	return a, false, nil
+}
+
+// systemServer returns the address of the first nameserver in the resolv.conf file.
+func systemServer(path string) string {
+	file, err := os.Open(path)
+	if err != nil {
+		return defaultServer
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 2 && fields[0] == "nameserver" {
+			return serverAddress(fields[1])
+		}
+	}
+	return defaultServer
+}
+
+// serverAddress adds the default DNS port to the server address, if missing.
+func serverAddress(server string) string {
+	if _, _, err := net.SplitHostPort(server); err == nil {
+		return server
+	}
+	return net.JoinHostPort(strings.Trim(server, "[]"), "53")
+}
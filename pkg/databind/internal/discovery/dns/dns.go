@@ -0,0 +1,141 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package dns discovers the targets of a DNS SRV record. The records are cached for their TTL, so
+// they are queried again once they expire.
+package dns
+
+import (
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/newrelic/infrastructure-agent/pkg/databind/internal/discovery"
+	"github.com/newrelic/infrastructure-agent/pkg/databind/pkg/data"
+	"github.com/newrelic/infrastructure-agent/pkg/log"
+)
+
+const defaultTimeout = 5 * time.Second
+
+var dlog = log.WithComponent("databind.DNSDiscovery")
+
+var timeNow = time.Now
+
+// lookupHost resolves the targets whose addresses aren't provided in the SRV response.
+var lookupHost = net.LookupHost
+
+// Discoverer returns a discoverer of the targets of the SRV record in the provided configuration.
+func Discoverer(d discovery.DNS) (fetchDiscoveries func() (discoveries []discovery.Discovery, err error), err error) {
+	matcher, err := discovery.NewMatcher(d.Match)
+	if err != nil {
+		return nil, err
+	}
+	timeout := defaultTimeout
+	if d.Timeout != "" {
+		if timeout, err = time.ParseDuration(d.Timeout); err != nil {
+			return nil, err
+		}
+	}
+	server := systemServer(resolvConf)
+	if d.Server != "" {
+		server = serverAddress(d.Server)
+	}
+	record := d.Record
+	if !strings.HasSuffix(record, ".") {
+		record += "."
+	}
+	c := &cache{
+		client: &client{server: server, timeout: timeout},
+		record: record,
+	}
+	return func() ([]discovery.Discovery, error) {
+		targets, err := c.targets()
+		if err != nil {
+			return nil, err
+		}
+		return match(strings.TrimSuffix(record, "."), targets, &matcher), nil
+	}, nil
+}
+
+// target of the SRV record, with its resolved address.
+type target struct {
+	srv
+	address string
+}
+
+// cache keeps the targets of the SRV record until the TTL of the record expires.
+type cache struct {
+	client  *client
+	record  string
+	lock    sync.Mutex
+	cached  []target
+	expires time.Time
+}
+
+func (c *cache) targets() ([]target, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	now := timeNow()
+	if now.Before(c.expires) {
+		return c.cached, nil
+	}
+	a, err := c.client.lookupSRV(c.record)
+	if err != nil {
+		return nil, err
+	}
+
+	targets := make([]target, 0, len(a.records))
+	for _, r := range a.records {
+		t := target{srv: r, address: r.target}
+		if addresses := a.addresses[r.target]; len(addresses) > 0 {
+			t.address = addresses[0]
+		} else if addresses, err := lookupHost(r.target); err == nil && len(addresses) > 0 {
+			t.address = addresses[0]
+		} else {
+			dlog.WithError(err).WithField("target", r.target).Debug("Can't resolve SRV target, using its name as address.")
+		}
+		targets = append(targets, t)
+	}
+	// targets are sorted as the clients should try them: lower priorities first, and higher weights first
+	sort.Slice(targets, func(i, j int) bool {
+		ti, tj := targets[i], targets[j]
+		if ti.priority != tj.priority {
+			return ti.priority < tj.priority
+		}
+		if ti.weight != tj.weight {
+			return ti.weight > tj.weight
+		}
+		if ti.target != tj.target {
+			return ti.target < tj.target
+		}
+		return ti.port < tj.port
+	})
+	c.cached = targets
+	c.expires = now.Add(a.ttl)
+	return targets, nil
+}
+
+// match returns the targets matching all the criteria.
+func match(record string, targets []target, matcher *discovery.FieldsMatcher) []discovery.Discovery {
+	var matches []discovery.Discovery
+	for _, t := range targets {
+		labels := map[string]string{
+			data.Record:   record,
+			data.Target:   t.target,
+			data.IP:       t.address,
+			data.Port:     strconv.Itoa(int(t.port)),
+			data.Priority: strconv.Itoa(int(t.priority)),
+			data.Weight:   strconv.Itoa(int(t.weight)),
+		}
+		if matcher.All(labels) {
+			matches = append(matches, discovery.Discovery{
+				Variables: discovery.LabelsToMap(data.DiscoveryPrefix, labels),
+			})
+		}
+	}
+	return matches
+}
@@ -0,0 +1,235 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package dns
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/dns/dnsmessage"
+
+	"github.com/newrelic/infrastructure-agent/pkg/databind/internal/discovery"
+	"github.com/newrelic/infrastructure-agent/pkg/databind/pkg/data"
+)
+
+// fakeServer answers the SRV queries of a record, through UDP and TCP.
+type fakeServer struct {
+	udp     net.PacketConn
+	tcp     net.Listener
+	queries int32
+	// truncate makes the UDP responses truncated, so the client has to retry through TCP
+	truncate bool
+}
+
+func newFakeServer(t *testing.T, truncate bool) *fakeServer {
+	tcp, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	udp, err := net.ListenPacket("udp", tcp.Addr().String())
+	require.NoError(t, err)
+	s := &fakeServer{udp: udp, tcp: tcp, truncate: truncate}
+	go s.serveUDP()
+	go s.serveTCP()
+	return s
+}
+
+func (s *fakeServer) address() string {
+	return s.tcp.Addr().String()
+}
+
+func (s *fakeServer) close() {
+	_ = s.udp.Close()
+	_ = s.tcp.Close()
+}
+
+func (s *fakeServer) serveUDP() {
+	buf := make([]byte, 512)
+	for {
+		n, addr, err := s.udp.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		if response, err := s.respond(buf[:n], s.truncate); err == nil {
+			_, _ = s.udp.WriteTo(response, addr)
+		}
+	}
+}
+
+func (s *fakeServer) serveTCP() {
+	for {
+		conn, err := s.tcp.Accept()
+		if err != nil {
+			return
+		}
+		var length [2]byte
+		if _, err := io.ReadFull(conn, length[:]); err == nil {
+			query := make([]byte, binary.BigEndian.Uint16(length[:]))
+			if _, err := io.ReadFull(conn, query); err == nil {
+				if response, err := s.respond(query, false); err == nil {
+					binary.BigEndian.PutUint16(length[:], uint16(len(response)))
+					_, _ = conn.Write(append(length[:], response...))
+				}
+			}
+		}
+		_ = conn.Close()
+	}
+}
+
+func (s *fakeServer) respond(query []byte, truncate bool) ([]byte, error) {
+	atomic.AddInt32(&s.queries, 1)
+	var p dnsmessage.Parser
+	header, err := p.Start(query)
+	if err != nil {
+		return nil, err
+	}
+	question, err := p.Question()
+	if err != nil {
+		return nil, err
+	}
+
+	b := dnsmessage.NewBuilder(nil, dnsmessage.Header{ID: header.ID, Response: true, Truncated: truncate})
+	_ = b.StartQuestions()
+	_ = b.Question(question)
+	if truncate {
+		return b.Finish()
+	}
+	if question.Name.String() != "_redis._tcp.example.com." {
+		b = dnsmessage.NewBuilder(nil, dnsmessage.Header{ID: header.ID, Response: true, RCode: dnsmessage.RCodeNameError})
+		_ = b.StartQuestions()
+		_ = b.Question(question)
+		return b.Finish()
+	}
+	_ = b.StartAnswers()
+	for _, r := range []struct {
+		target   string
+		port     uint16
+		priority uint16
+		weight   uint16
+		ttl      uint32
+	}{
+		{"backup.example.com.", 6380, 20, 0, 300},
+		{"redis-1.example.com.", 6379, 10, 50, 60},
+		{"localhost.", 6381, 10, 100, 300},
+	} {
+		_ = b.SRVResource(dnsmessage.ResourceHeader{
+			Name: question.Name, Class: dnsmessage.ClassINET, TTL: r.ttl,
+		}, dnsmessage.SRVResource{
+			Target: dnsmessage.MustNewName(r.target), Port: r.port, Priority: r.priority, Weight: r.weight,
+		})
+	}
+	_ = b.StartAdditionals()
+	_ = b.AResource(dnsmessage.ResourceHeader{
+		Name: dnsmessage.MustNewName("redis-1.example.com."), Class: dnsmessage.ClassINET, TTL: 300,
+	}, dnsmessage.AResource{A: [4]byte{10, 0, 0, 1}})
+	return b.Finish()
+}
+
+func TestDiscoverer(t *testing.T) {
+	now := time.Unix(1611923766, 0)
+	timeNow = func() time.Time { return now }
+	lookupHost = func(host string) ([]string, error) {
+		if host == "localhost" {
+			return []string{"127.0.0.1"}, nil
+		}
+		return nil, errors.New("no such host")
+	}
+	defer func() {
+		timeNow = time.Now
+		lookupHost = net.LookupHost
+	}()
+
+	for _, truncate := range []bool{false, true} {
+		t.Run("truncated: "+strconv.FormatBool(truncate), func(t *testing.T) {
+			// GIVEN a DNS server with an SRV record
+			server := newFakeServer(t, truncate)
+			defer server.close()
+			fetch, err := Discoverer(discovery.DNS{Record: "_redis._tcp.example.com", Server: server.address()})
+			require.NoError(t, err)
+
+			// WHEN the targets are discovered
+			discoveries, err := fetch()
+
+			// THEN they are returned in the order the clients should try them
+			require.NoError(t, err)
+			require.Len(t, discoveries, 3)
+			assert.Equal(t, data.Map{
+				"discovery.record":   "_redis._tcp.example.com",
+				"discovery.target":   "localhost",
+				"discovery.ip":       "127.0.0.1",
+				"discovery.port":     "6381",
+				"discovery.priority": "10",
+				"discovery.weight":   "100",
+			}, discoveries[0].Variables)
+			// AND the addresses provided by the server are used
+			assert.Equal(t, "10.0.0.1", discoveries[1].Variables["discovery.ip"])
+			// AND the unresolvable targets are addressed by their name
+			assert.Equal(t, "backup.example.com", discoveries[2].Variables["discovery.ip"])
+		})
+	}
+
+	t.Run("cached for the TTL", func(t *testing.T) {
+		// GIVEN a discoverer that has already queried the record
+		server := newFakeServer(t, false)
+		defer server.close()
+		fetch, err := Discoverer(discovery.DNS{
+			Record: "_redis._tcp.example.com.", Server: server.address(), Match: map[string]string{"priority": "10"},
+		})
+		require.NoError(t, err)
+		discoveries, err := fetch()
+		require.NoError(t, err)
+		assert.Len(t, discoveries, 2)
+
+		// WHEN the targets are discovered again before the minimum TTL expires
+		now = now.Add(59 * time.Second)
+		_, err = fetch()
+		require.NoError(t, err)
+
+		// THEN the record isn't queried again
+		assert.EqualValues(t, 1, atomic.LoadInt32(&server.queries))
+
+		// WHEN the TTL expires
+		now = now.Add(time.Second)
+		discoveries, err = fetch()
+
+		// THEN the record is queried again
+		require.NoError(t, err)
+		assert.Len(t, discoveries, 2)
+		assert.EqualValues(t, 2, atomic.LoadInt32(&server.queries))
+	})
+
+	t.Run("missing record", func(t *testing.T) {
+		server := newFakeServer(t, false)
+		defer server.close()
+		fetch, err := Discoverer(discovery.DNS{Record: "_missing._tcp.example.com", Server: server.address()})
+		require.NoError(t, err)
+
+		discoveries, err := fetch()
+
+		require.NoError(t, err)
+		assert.Empty(t, discoveries)
+	})
+}
+
+func TestSystemServer(t *testing.T) {
+	dir, err := ioutil.TempDir("", "resolv")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "resolv.conf")
+	require.NoError(t, ioutil.WriteFile(path, []byte("# comment\nsearch example.com\nnameserver fd00::1\nnameserver 10.0.0.2\n"), 0644))
+
+	assert.Equal(t, "[fd00::1]:53", systemServer(path))
+	assert.Equal(t, defaultServer, systemServer(filepath.Join(dir, "missing")))
+	assert.Equal(t, "10.0.0.2:5353", serverAddress("10.0.0.2:5353"))
+	assert.Equal(t, "10.0.0.2:53", serverAddress("10.0.0.2"))
+}
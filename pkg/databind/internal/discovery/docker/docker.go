@@ -8,6 +8,7 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/client"
@@ -25,8 +26,15 @@ const (
 
 // Discoverer returns a Docker container discoverer from the provided configuration.
 // The fetching process will return an array of map values for each discovered container, with the
-// keys discovery.port and discovery.ip
-func Discoverer(d discovery.Container) (fetchDiscoveries func() (discoveries []discovery.Discovery, err error), err error) {
+// keys discovery.port and discovery.ip. The container events are notified to the provided changes
+// since the first fetch, until the passed context is cancelled.
+func Discoverer(ctx context.Context, d discovery.Container, changes discovery.Changes) (fetchDiscoveries func() (discoveries []discovery.Discovery, err error), err error) {
+	return HostDiscoverer(ctx, d, "", changes)
+}
+
+// HostDiscoverer returns a container discoverer for the Docker API served in the provided host (e.g.
+// unix:///run/podman/podman.sock). If the host is empty, it is taken from the DOCKER_HOST environment variable.
+func HostDiscoverer(ctx context.Context, d discovery.Container, host string, changes discovery.Changes) (fetchDiscoveries func() (discoveries []discovery.Discovery, err error), err error) {
 	if d.ApiVersion == "" {
 		d.ApiVersion = defaultDockerAPIVersion
 	}
@@ -34,15 +42,25 @@ func Discoverer(d discovery.Container) (fetchDiscoveries func() (discoveries []d
 	if err != nil {
 		return nil, err
 	}
+	watch := sync.Once{}
 	return func() ([]discovery.Discovery, error) {
-		return fetch(d, &matcher)
+		if changes != nil {
+			watch.Do(func() {
+				go watchEvents(ctx, host, changes)
+			})
+		}
+		return fetch(host, &matcher)
 	}, nil
 }
 
-func fetch(d discovery.Container, matcher *discovery.FieldsMatcher) ([]discovery.Discovery, error) {
+func fetch(host string, matcher *discovery.FieldsMatcher) ([]discovery.Discovery, error) {
 	var matches []discovery.Discovery
 
-	dc, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	opts := []client.Opt{client.FromEnv, client.WithAPIVersionNegotiation()}
+	if host != "" {
+		opts = append(opts, client.WithHost(host))
+	}
+	dc, err := client.NewClientWithOpts(opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,80 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package docker
+
+import (
+	"context"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/client"
+
+	"github.com/newrelic/infrastructure-agent/pkg/databind/internal/discovery"
+	"github.com/newrelic/infrastructure-agent/pkg/log"
+)
+
+const (
+	minBackoff = time.Second
+	maxBackoff = time.Minute
+)
+
+var elog = log.WithComponent("databind.DockerEvents")
+
+// container events that may change the discovered containers or their attributes
+var containerEvents = []string{"start", "die", "destroy", "pause", "unpause", "rename", "update"}
+
+// watchEvents notifies the changes of the containers until the context is cancelled, subscribing
+// again to the docker events whenever the stream is broken.
+func watchEvents(ctx context.Context, host string, changes discovery.Changes) {
+	backoff := minBackoff
+	for {
+		err := streamEvents(ctx, host, changes, func() { backoff = minBackoff })
+		if ctx.Err() != nil {
+			return
+		}
+		elog.WithError(err).WithField("host", host).Debug("docker events stream broken, subscribing again")
+		// events may have been missed meanwhile
+		changes.Notify()
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		if backoff *= 2; backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+}
+
+// streamEvents notifies a change for each received container event, until the stream is broken.
+// The connected function is invoked once the first event is received.
+func streamEvents(ctx context.Context, host string, changes discovery.Changes, connected func()) error {
+	opts := []client.Opt{client.FromEnv, client.WithAPIVersionNegotiation()}
+	if host != "" {
+		opts = append(opts, client.WithHost(host))
+	}
+	dc, err := client.NewClientWithOpts(opts...)
+	if err != nil {
+		return err
+	}
+	defer dc.Close()
+
+	args := filters.NewArgs(filters.Arg("type", "container"))
+	for _, event := range containerEvents {
+		args.Add("event", event)
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	messages, errs := dc.Events(ctx, types.EventsOptions{Filters: args})
+	for {
+		select {
+		case <-messages:
+			connected()
+			changes.Notify()
+		case err := <-errs:
+			return err
+		}
+	}
+}
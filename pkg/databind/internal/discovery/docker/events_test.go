@@ -0,0 +1,67 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package docker
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/newrelic/infrastructure-agent/pkg/databind/internal/discovery"
+)
+
+func TestHostDiscoverer_StopsWatchingEvents(t *testing.T) {
+	// GIVEN a Docker API streaming a container event and keeping the stream open
+	dir, err := ioutil.TempDir("", "docker")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	socket := filepath.Join(dir, "docker.sock")
+	listener, err := net.Listen("unix", socket)
+	require.NoError(t, err)
+	unsubscribed := make(chan struct{}, 1)
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("API-Version", "1.40")
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/containers/json"):
+			_, _ = w.Write([]byte("[]"))
+		case strings.HasSuffix(r.URL.Path, "/events"):
+			_, _ = w.Write([]byte(`{"Type":"container","Action":"start","id":"1f8c2e0e2a5b"}` + "\n"))
+			w.(http.Flusher).Flush()
+			<-r.Context().Done()
+			unsubscribed <- struct{}{}
+		}
+	}))
+	server.Listener = listener
+	server.Start()
+	defer server.Close()
+
+	// AND a discoverer watching its events
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	changes := discovery.NewChanges()
+	fetch, err := HostDiscoverer(ctx, discovery.Container{}, "unix://"+socket, changes)
+	require.NoError(t, err)
+	_, err = fetch()
+	require.NoError(t, err)
+	require.Eventually(t, changes.Changed, 5*time.Second, 10*time.Millisecond)
+
+	// WHEN the context is cancelled
+	cancel()
+
+	// THEN the events stream is closed
+	select {
+	case <-unsubscribed:
+	case <-time.After(5 * time.Second):
+		require.Fail(t, "the docker events are still being watched")
+	}
+}
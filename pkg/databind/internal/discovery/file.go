@@ -0,0 +1,29 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package discovery
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+)
+
+// File discovery parameters
+type File struct {
+	Match map[string]string `yaml:"match"`
+	// Files describing the targets, in JSON or YAML. They may contain glob patterns (e.g. /etc/targets/*.yml)
+	Files []string `yaml:"files"`
+}
+
+func (f *File) Validate() error {
+	if len(f.Files) == 0 {
+		return errors.New("missing 'files' entries")
+	}
+	for _, pattern := range f.Files {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid file pattern %q: %s", pattern, err)
+		}
+	}
+	return nil
+}
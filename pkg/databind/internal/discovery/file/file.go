@@ -0,0 +1,74 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package file discovers the targets described in JSON or YAML files, in the same format as the
+// Prometheus file-based service discovery:
+//
+//	[{"targets": ["10.0.0.1:6379", "10.0.0.2:6379"], "labels": {"env": "production"}}]
+//
+// The files are watched, so the changes are reflected on the next discovery refresh.
+package file
+
+import (
+	"context"
+	"net"
+	"sort"
+
+	"github.com/newrelic/infrastructure-agent/pkg/databind/internal/discovery"
+	"github.com/newrelic/infrastructure-agent/pkg/databind/pkg/data"
+)
+
+// targetGroup is a set of targets sharing the same labels.
+type targetGroup struct {
+	Targets []string          `yaml:"targets"`
+	Labels  map[string]string `yaml:"labels"`
+}
+
+// Discoverer returns a discoverer of the targets in the files of the provided configuration. The files
+// stop being watched when the context is cancelled.
+func Discoverer(ctx context.Context, f discovery.File) (fetchDiscoveries func() (discoveries []discovery.Discovery, err error), err error) {
+	matcher, err := discovery.NewMatcher(f.Match)
+	if err != nil {
+		return nil, err
+	}
+	w := newWatcher(ctx, f.Files)
+	return func() ([]discovery.Discovery, error) {
+		return match(w.groups(), &matcher), nil
+	}, nil
+}
+
+// match returns the targets matching all the criteria, sorted by file.
+func match(groups map[string][]targetGroup, matcher *discovery.FieldsMatcher) []discovery.Discovery {
+	paths := make([]string, 0, len(groups))
+	for path := range groups {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	var matches []discovery.Discovery
+	for _, path := range paths {
+		for _, group := range groups[path] {
+			for _, target := range group.Targets {
+				labels := map[string]string{
+					data.Target: target,
+					data.File:   path,
+				}
+				if host, port, err := net.SplitHostPort(target); err == nil {
+					labels[data.IP] = host
+					labels[data.Port] = port
+				} else {
+					labels[data.IP] = target
+				}
+				for k, v := range group.Labels {
+					labels[data.LabelInfix+k] = v
+				}
+				if matcher.All(labels) {
+					matches = append(matches, discovery.Discovery{
+						Variables: discovery.LabelsToMap(data.DiscoveryPrefix, labels),
+					})
+				}
+			}
+		}
+	}
+	return matches
+}
@@ -0,0 +1,135 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package file
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/newrelic/infrastructure-agent/pkg/databind/internal/discovery"
+	"github.com/newrelic/infrastructure-agent/pkg/databind/pkg/data"
+)
+
+const jsonTargets = `[
+  {"targets": ["10.0.0.1:6379", "10.0.0.2:6379"], "labels": {"env": "production", "role": "cache"}},
+  {"targets": ["redis.staging"], "labels": {"env": "staging", "role": "cache"}}
+]`
+
+const yamlTargets = `
+- targets: ["10.0.1.1:5432"]
+  labels:
+    env: production
+    role: db
+`
+
+func TestDiscoverer(t *testing.T) {
+	// GIVEN a directory with JSON and YAML target files
+	dir, err := ioutil.TempDir("", "file_sd")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "cache.json"), []byte(jsonTargets), 0644))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "db.yml"), []byte(yamlTargets), 0644))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	fetch, err := Discoverer(ctx, discovery.File{
+		Files: []string{filepath.Join(dir, "*.json"), filepath.Join(dir, "*.yml")},
+		Match: map[string]string{"label.env": "/production|staging/"},
+	})
+	require.NoError(t, err)
+
+	// WHEN the targets are discovered
+	discoveries, err := fetch()
+
+	// THEN all the targets are returned, sorted by file
+	require.NoError(t, err)
+	require.Len(t, discoveries, 4)
+	assert.Equal(t, data.Map{
+		"discovery.target":     "10.0.0.1:6379",
+		"discovery.ip":         "10.0.0.1",
+		"discovery.port":       "6379",
+		"discovery.file":       filepath.Join(dir, "cache.json"),
+		"discovery.label.env":  "production",
+		"discovery.label.role": "cache",
+	}, discoveries[0].Variables)
+	assert.Equal(t, "10.0.0.2", discoveries[1].Variables["discovery.ip"])
+	assert.Equal(t, "redis.staging", discoveries[2].Variables["discovery.ip"])
+	assert.NotContains(t, discoveries[2].Variables, "discovery.port")
+	assert.Equal(t, "db", discoveries[3].Variables["discovery.label.role"])
+
+	// WHEN a file is replaced by an invalid one
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "db.yml"), []byte("targets: ["), 0644))
+	// AND another file is updated
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "cache.json"),
+		[]byte(`[{"targets": ["10.0.0.3:6379"], "labels": {"env": "production"}}]`), 0644))
+
+	// THEN the updated targets are discovered, and the invalid file keeps its previous targets
+	assert.Eventually(t, func() bool {
+		discoveries, err = fetch()
+		return err == nil && len(discoveries) == 2 && discoveries[0].Variables["discovery.ip"] == "10.0.0.3"
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, "10.0.1.1", discoveries[1].Variables["discovery.ip"])
+
+	// WHEN a file is removed
+	require.NoError(t, os.Remove(filepath.Join(dir, "cache.json")))
+
+	// THEN its targets aren't discovered anymore
+	assert.Eventually(t, func() bool {
+		discoveries, err = fetch()
+		return err == nil && len(discoveries) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestDiscoverer_NotWatched(t *testing.T) {
+	// GIVEN a target file in a directory that can't be watched, as it doesn't exist yet
+	dir, err := ioutil.TempDir("", "file_sd")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	targets := filepath.Join(dir, "targets", "cache.yml")
+	fetch, err := Discoverer(context.Background(), discovery.File{Files: []string{targets}})
+	require.NoError(t, err)
+
+	discoveries, err := fetch()
+	require.NoError(t, err)
+	assert.Empty(t, discoveries)
+
+	// WHEN the file is created
+	require.NoError(t, os.Mkdir(filepath.Dir(targets), 0755))
+	require.NoError(t, ioutil.WriteFile(targets, []byte(yamlTargets), 0644))
+
+	// THEN its targets are discovered on the next access
+	discoveries, err = fetch()
+	require.NoError(t, err)
+	require.Len(t, discoveries, 1)
+	assert.Equal(t, "10.0.1.1:5432", discoveries[0].Variables["discovery.target"])
+}
+
+func TestWatcher_StopsOnCancel(t *testing.T) {
+	// GIVEN a watcher of the files of a directory
+	dir, err := ioutil.TempDir("", "file_sd")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "db.yml"), []byte(yamlTargets), 0644))
+	ctx, cancel := context.WithCancel(context.Background())
+	w := newWatcher(ctx, []string{filepath.Join(dir, "*.yml")})
+	require.Len(t, w.groups(), 1)
+	require.True(t, w.watching)
+
+	// WHEN the context is cancelled
+	cancel()
+
+	// THEN the directory stops being watched
+	select {
+	case <-w.stopped:
+	case <-time.After(5 * time.Second):
+		require.Fail(t, "the discovery files are still being watched")
+	}
+}
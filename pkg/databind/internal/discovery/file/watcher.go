@@ -0,0 +1,150 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package file
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/fsnotify/fsnotify"
+	"gopkg.in/yaml.v2"
+
+	"github.com/newrelic/infrastructure-agent/pkg/log"
+)
+
+var wlog = log.WithComponent("databind.FileWatcher")
+
+// watcher keeps an up-to-date copy of the target groups of the files matching a set of patterns. They
+// are read on the first access and then re-read whenever any file of their directories changes. If the
+// directories can't be watched, the files are read again on each access. The directories are watched
+// until the context is cancelled.
+type watcher struct {
+	ctx      context.Context
+	patterns []string
+	// start serializes the first read
+	start    sync.Mutex
+	started  bool
+	watching bool
+	lock     sync.Mutex
+	files    map[string][]targetGroup // by path
+	// stopped is closed once the background watch finishes
+	stopped chan struct{}
+}
+
+func newWatcher(ctx context.Context, patterns []string) *watcher {
+	return &watcher{
+		ctx:      ctx,
+		patterns: patterns,
+		files:    map[string][]targetGroup{},
+		stopped:  make(chan struct{}),
+	}
+}
+
+// groups returns the current target groups, by file path. The returned map must not be modified.
+func (w *watcher) groups() map[string][]targetGroup {
+	w.start.Lock()
+	if !w.started {
+		w.started = true
+		w.watching = w.watch()
+		w.reload()
+	} else if !w.watching {
+		w.reload()
+	}
+	w.start.Unlock()
+
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	return w.files
+}
+
+// watch starts watching the directories of the patterns in background, returning false if it isn't possible.
+func (w *watcher) watch() bool {
+	fw, err := fsnotify.NewWatcher()
+	if err != nil {
+		wlog.WithError(err).Warn("can't watch the discovery files, they will be read on each discovery refresh")
+		return false
+	}
+	dirs := map[string]struct{}{}
+	for _, pattern := range w.patterns {
+		dirs[filepath.Dir(pattern)] = struct{}{}
+	}
+	for dir := range dirs {
+		// the directories are watched, instead of the files, to notice the created and replaced files
+		if err := fw.Add(dir); err != nil {
+			wlog.WithError(err).WithField("dir", dir).
+				Warn("can't watch the discovery files directory, the files will be read on each discovery refresh")
+			_ = fw.Close()
+			return false
+		}
+	}
+	go func() {
+		defer close(w.stopped)
+		defer fw.Close()
+		for {
+			select {
+			case <-w.ctx.Done():
+				return
+			case _, ok := <-fw.Events:
+				if !ok {
+					return
+				}
+				w.reload()
+			case err, ok := <-fw.Errors:
+				if !ok {
+					return
+				}
+				wlog.WithError(err).Warn("error watching the discovery files")
+			}
+		}
+	}()
+	return true
+}
+
+// reload reads the files matching the patterns. A file that can't be read or parsed keeps its previous targets.
+func (w *watcher) reload() {
+	var paths []string
+	for _, pattern := range w.patterns {
+		// the patterns have been validated
+		matched, _ := filepath.Glob(pattern)
+		paths = append(paths, matched...)
+	}
+	sort.Strings(paths)
+
+	w.lock.Lock()
+	previous := w.files
+	w.lock.Unlock()
+
+	files := make(map[string][]targetGroup, len(paths))
+	for _, path := range paths {
+		if _, ok := files[path]; ok {
+			continue
+		}
+		groups, err := read(path)
+		if err != nil {
+			wlog.WithError(err).WithField("file", path).Warn("can't read discovery file, keeping its previous targets")
+			groups = previous[path]
+		}
+		files[path] = groups
+	}
+
+	w.lock.Lock()
+	w.files = files
+	w.lock.Unlock()
+}
+
+// read parses the target groups of a file. As JSON is a subset of YAML, both formats are parsed the same way.
+func read(path string) ([]targetGroup, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var groups []targetGroup
+	if err := yaml.Unmarshal(content, &groups); err != nil {
+		return nil, err
+	}
+	return groups, nil
+}
@@ -0,0 +1,46 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package discovery
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Kubernetes resources that can be discovered.
+const (
+	KindPod       = "pod"
+	KindService   = "service"
+	KindEndpoints = "endpoints"
+)
+
+// Kubernetes discovery parameters
+type Kubernetes struct {
+	Match map[string]string `yaml:"match"`
+	// Kind of the discovered resources: pod (default), service or endpoints
+	Kind string `yaml:"kind"`
+	// Namespace restricts the discovery to a namespace. All namespaces are watched if empty.
+	Namespace string `yaml:"namespace"`
+	// Node restricts the discovery of pods and endpoints to the ones placed in the node.
+	Node string `yaml:"node"`
+	// Kubeconfig file to access the API server. The in-cluster configuration is used if empty.
+	Kubeconfig string `yaml:"kubeconfig"`
+	// Context of the kubeconfig file. The current context is used if empty.
+	Context string `yaml:"context"`
+}
+
+func (k *Kubernetes) Validate() error {
+	if len(k.Match) == 0 {
+		return errors.New("missing 'match' entries")
+	}
+	switch k.Kind {
+	case "", KindPod, KindService, KindEndpoints:
+	default:
+		return fmt.Errorf("unsupported kubernetes kind %q. Use %s, %s or %s", k.Kind, KindPod, KindService, KindEndpoints)
+	}
+	if k.Context != "" && k.Kubeconfig == "" {
+		return errors.New("'context' requires a 'kubeconfig' file")
+	}
+	return nil
+}
@@ -0,0 +1,239 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package kubernetes
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+const serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+
+// client performs the requests to the Kubernetes API server.
+type client struct {
+	baseURL string
+	http    *http.Client
+	token   string
+	// tokenFile is read on each request, as the projected service account tokens are rotated
+	tokenFile string
+}
+
+// inClusterClient accesses the API server with the service account of the pod the agent runs in.
+func inClusterClient() (*client, error) {
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return nil, errors.New("not running in a kubernetes cluster and no kubeconfig file provided")
+	}
+	ca, err := ioutil.ReadFile(filepath.Join(serviceAccountDir, "ca.crt"))
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig, err := newTLSConfig(ca, false)
+	if err != nil {
+		return nil, err
+	}
+	return &client{
+		baseURL:   "https://" + net.JoinHostPort(host, port),
+		http:      &http.Client{Transport: &http.Transport{TLSClientConfig: tlsConfig, Proxy: http.ProxyFromEnvironment}},
+		tokenFile: filepath.Join(serviceAccountDir, "token"),
+	}, nil
+}
+
+// kubeconfig holds the subset of the kubeconfig file fields required to access an API server.
+type kubeconfig struct {
+	CurrentContext string `yaml:"current-context"`
+	Clusters       []struct {
+		Name    string `yaml:"name"`
+		Cluster struct {
+			Server                   string `yaml:"server"`
+			CertificateAuthority     string `yaml:"certificate-authority"`
+			CertificateAuthorityData string `yaml:"certificate-authority-data"`
+			InsecureSkipTLSVerify    bool   `yaml:"insecure-skip-tls-verify"`
+		} `yaml:"cluster"`
+	} `yaml:"clusters"`
+	Contexts []struct {
+		Name    string `yaml:"name"`
+		Context struct {
+			Cluster string `yaml:"cluster"`
+			User    string `yaml:"user"`
+		} `yaml:"context"`
+	} `yaml:"contexts"`
+	Users []struct {
+		Name string `yaml:"name"`
+		User struct {
+			Token                 string `yaml:"token"`
+			TokenFile             string `yaml:"tokenFile"`
+			ClientCertificate     string `yaml:"client-certificate"`
+			ClientCertificateData string `yaml:"client-certificate-data"`
+			ClientKey             string `yaml:"client-key"`
+			ClientKeyData         string `yaml:"client-key-data"`
+		} `yaml:"user"`
+	} `yaml:"users"`
+}
+
+// kubeconfigClient accesses the API server of a context from a kubeconfig file. Only token and client
+// certificate authentications are supported.
+func kubeconfigClient(path, contextName string) (*client, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	kc := kubeconfig{}
+	if err = yaml.Unmarshal(content, &kc); err != nil {
+		return nil, fmt.Errorf("invalid kubeconfig file %s: %s", path, err)
+	}
+	if contextName == "" {
+		contextName = kc.CurrentContext
+	}
+	// relative paths in the kubeconfig file refer to its directory
+	dir := filepath.Dir(path)
+	read := func(data, file string) ([]byte, error) {
+		if data != "" {
+			return base64.StdEncoding.DecodeString(data)
+		}
+		if file == "" {
+			return nil, nil
+		}
+		if !filepath.IsAbs(file) {
+			file = filepath.Join(dir, file)
+		}
+		return ioutil.ReadFile(file)
+	}
+
+	var clusterName, userName string
+	found := false
+	for _, c := range kc.Contexts {
+		if c.Name == contextName {
+			clusterName, userName, found = c.Context.Cluster, c.Context.User, true
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("context %q not found in kubeconfig file %s", contextName, path)
+	}
+
+	cl := &client{}
+	transport := &http.Transport{Proxy: http.ProxyFromEnvironment}
+	found = false
+	for _, c := range kc.Clusters {
+		if c.Name != clusterName {
+			continue
+		}
+		found = true
+		cl.baseURL = strings.TrimSuffix(c.Cluster.Server, "/")
+		ca, err := read(c.Cluster.CertificateAuthorityData, c.Cluster.CertificateAuthority)
+		if err != nil {
+			return nil, fmt.Errorf("reading certificate authority of cluster %q: %s", clusterName, err)
+		}
+		if transport.TLSClientConfig, err = newTLSConfig(ca, c.Cluster.InsecureSkipTLSVerify); err != nil {
+			return nil, err
+		}
+	}
+	if !found || cl.baseURL == "" {
+		return nil, fmt.Errorf("cluster %q not found in kubeconfig file %s", clusterName, path)
+	}
+
+	for _, u := range kc.Users {
+		if u.Name != userName {
+			continue
+		}
+		cl.token = u.User.Token
+		if u.User.TokenFile != "" {
+			cl.tokenFile = u.User.TokenFile
+			if !filepath.IsAbs(cl.tokenFile) {
+				cl.tokenFile = filepath.Join(dir, cl.tokenFile)
+			}
+		}
+		cert, err := read(u.User.ClientCertificateData, u.User.ClientCertificate)
+		if err != nil {
+			return nil, fmt.Errorf("reading client certificate of user %q: %s", userName, err)
+		}
+		key, err := read(u.User.ClientKeyData, u.User.ClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("reading client key of user %q: %s", userName, err)
+		}
+		if len(cert) > 0 && len(key) > 0 {
+			pair, err := tls.X509KeyPair(cert, key)
+			if err != nil {
+				return nil, fmt.Errorf("invalid client certificate of user %q: %s", userName, err)
+			}
+			if transport.TLSClientConfig == nil {
+				transport.TLSClientConfig = &tls.Config{}
+			}
+			transport.TLSClientConfig.Certificates = []tls.Certificate{pair}
+		}
+	}
+	cl.http = &http.Client{Transport: transport}
+	return cl, nil
+}
+
+func newTLSConfig(ca []byte, insecure bool) (*tls.Config, error) {
+	if len(ca) == 0 && !insecure {
+		// system certificate authorities
+		return nil, nil
+	}
+	cfg := &tls.Config{InsecureSkipVerify: insecure}
+	if len(ca) > 0 {
+		cfg.RootCAs = x509.NewCertPool()
+		if !cfg.RootCAs.AppendCertsFromPEM(ca) {
+			return nil, errors.New("invalid kubernetes certificate authority")
+		}
+	}
+	return cfg, nil
+}
+
+// get requests a path of the API server. The response body must be closed by the caller.
+func (c *client) get(ctx context.Context, path string, query url.Values) (*http.Response, error) {
+	u := c.baseURL + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	token := c.token
+	if c.tokenFile != "" {
+		content, err := ioutil.ReadFile(c.tokenFile)
+		if err != nil {
+			return nil, err
+		}
+		token = strings.TrimSpace(string(content))
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := c.http.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		return nil, &statusError{code: resp.StatusCode, message: strings.TrimSpace(string(body))}
+	}
+	return resp, nil
+}
+
+type statusError struct {
+	code    int
+	message string
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("kubernetes API server responded %d: %s", e.code, e.message)
+}
@@ -0,0 +1,312 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package kubernetes
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/newrelic/infrastructure-agent/pkg/databind/internal/counter"
+	"github.com/newrelic/infrastructure-agent/pkg/databind/internal/discovery"
+	"github.com/newrelic/infrastructure-agent/pkg/databind/internal/discovery/naming"
+	"github.com/newrelic/infrastructure-agent/pkg/databind/pkg/data"
+)
+
+const metricAnnotationsToAdd = 6
+
+// resources by discovered kind
+var resources = map[string]string{
+	discovery.KindPod:       "pods",
+	discovery.KindService:   "services",
+	discovery.KindEndpoints: "endpoints",
+}
+
+type pod struct {
+	Metadata objectMeta `json:"metadata"`
+	Spec     struct {
+		NodeName   string `json:"nodeName"`
+		Containers []struct {
+			Name  string `json:"name"`
+			Image string `json:"image"`
+			Ports []struct {
+				Name          string `json:"name"`
+				ContainerPort int    `json:"containerPort"`
+				Protocol      string `json:"protocol"`
+			} `json:"ports"`
+		} `json:"containers"`
+	} `json:"spec"`
+	Status struct {
+		Phase  string `json:"phase"`
+		PodIP  string `json:"podIP"`
+		HostIP string `json:"hostIP"`
+	} `json:"status"`
+}
+
+type service struct {
+	Metadata objectMeta `json:"metadata"`
+	Spec     struct {
+		ClusterIP string `json:"clusterIP"`
+		Ports     []port `json:"ports"`
+	} `json:"spec"`
+}
+
+type endpoints struct {
+	Metadata objectMeta `json:"metadata"`
+	Subsets  []struct {
+		// only the ready addresses are discovered
+		Addresses []struct {
+			IP        string `json:"ip"`
+			NodeName  string `json:"nodeName"`
+			TargetRef *struct {
+				Kind string `json:"kind"`
+				Name string `json:"name"`
+			} `json:"targetRef"`
+		} `json:"addresses"`
+		Ports []port `json:"ports"`
+	} `json:"subsets"`
+}
+
+type port struct {
+	Name     string `json:"name"`
+	Port     int    `json:"port"`
+	Protocol string `json:"protocol"`
+}
+
+// Discoverer returns a Kubernetes discoverer from the provided configuration. The resources of the
+// configured kind are watched through the API server, and the fetching process returns an array of
+// map values for each discovered pod, service or endpoint address, with keys as discovery.ip,
+// discovery.port, discovery.label.<label> or discovery.annotation.<annotation>. The watched changes are
+// notified to the provided changes. The resources stop being watched when the passed context is cancelled.
+func Discoverer(ctx context.Context, d discovery.Kubernetes, changes discovery.Changes) (fetchDiscoveries func() (discoveries []discovery.Discovery, err error), err error) {
+	matcher, err := discovery.NewMatcher(d.Match)
+	if err != nil {
+		return nil, err
+	}
+	var c *client
+	if d.Kubeconfig != "" {
+		c, err = kubeconfigClient(d.Kubeconfig, d.Context)
+	} else {
+		c, err = inClusterClient()
+	}
+	if err != nil {
+		return nil, err
+	}
+	return discoverer(ctx, c, d, &matcher, changes), nil
+}
+
+func discoverer(ctx context.Context, c *client, d discovery.Kubernetes, matcher *discovery.FieldsMatcher, changes discovery.Changes) func() ([]discovery.Discovery, error) {
+	if d.Kind == "" {
+		d.Kind = discovery.KindPod
+	}
+	path := "/api/v1/" + resources[d.Kind]
+	if d.Namespace != "" {
+		path = "/api/v1/namespaces/" + url.PathEscape(d.Namespace) + "/" + resources[d.Kind]
+	}
+	query := url.Values{}
+	if d.Kind == discovery.KindPod && d.Node != "" {
+		query.Set("fieldSelector", "spec.nodeName="+d.Node)
+	}
+	w := newWatcher(ctx, c, path, query, changes)
+
+	return func() ([]discovery.Discovery, error) {
+		items, err := w.items()
+		if err != nil {
+			return nil, err
+		}
+		var matches []discovery.Discovery
+		for _, item := range items {
+			var found []discovery.Discovery
+			switch d.Kind {
+			case discovery.KindPod:
+				found, err = podDiscoveries(item, matcher)
+			case discovery.KindService:
+				found, err = serviceDiscoveries(item, matcher)
+			case discovery.KindEndpoints:
+				found, err = endpointsDiscoveries(item, d.Node, matcher)
+			}
+			if err != nil {
+				return nil, err
+			}
+			matches = append(matches, found...)
+		}
+		// the watched resources aren't sorted, so the discoveries are always returned in the same order
+		sort.SliceStable(matches, func(i, j int) bool {
+			vi, vj := matches[i].Variables, matches[j].Variables
+			for _, key := range []string{data.Namespace, data.Name, data.IP} {
+				if vi[data.DiscoveryPrefix+key] != vj[data.DiscoveryPrefix+key] {
+					return vi[data.DiscoveryPrefix+key] < vj[data.DiscoveryPrefix+key]
+				}
+			}
+			return false
+		})
+		return matches, nil
+	}
+}
+
+func podDiscoveries(raw json.RawMessage, matcher *discovery.FieldsMatcher) ([]discovery.Discovery, error) {
+	p := pod{}
+	if err := json.Unmarshal(raw, &p); err != nil {
+		return nil, err
+	}
+	// pods that aren't running can't be queried by the integrations
+	if p.Status.Phase != "Running" || p.Status.PodIP == "" {
+		return nil, nil
+	}
+	labels := metaLabels(p.Metadata)
+	labels[data.IP] = p.Status.PodIP
+	labels[data.PrivateIP] = p.Status.PodIP
+	labels[data.Node] = p.Spec.NodeName
+	labels[data.NodeIP] = p.Status.HostIP
+	var ports []port
+	for i, c := range p.Spec.Containers {
+		if i == 0 {
+			labels[data.Image] = c.Image
+		}
+		for _, cp := range c.Ports {
+			ports = append(ports, port{Name: cp.Name, Port: cp.ContainerPort, Protocol: cp.Protocol})
+		}
+	}
+	addPorts(ports, labels)
+
+	if !matcher.All(labels) {
+		return nil, nil
+	}
+	ma := make(data.InterfaceMap, metricAnnotationsToAdd)
+	naming.AddNamespaceName(ma, p.Metadata.Namespace)
+	naming.AddPodName(ma, p.Metadata.Name)
+	naming.AddNodeName(ma, p.Spec.NodeName)
+	naming.AddLabels(ma, p.Metadata.Labels)
+	if len(p.Spec.Containers) > 0 {
+		naming.AddImage(ma, p.Spec.Containers[0].Image)
+		naming.AddContainerName(ma, p.Spec.Containers[0].Name)
+	}
+	return []discovery.Discovery{{
+		Variables:         discovery.LabelsToMap(data.DiscoveryPrefix, labels),
+		MetricAnnotations: ma,
+	}}, nil
+}
+
+func serviceDiscoveries(raw json.RawMessage, matcher *discovery.FieldsMatcher) ([]discovery.Discovery, error) {
+	s := service{}
+	if err := json.Unmarshal(raw, &s); err != nil {
+		return nil, err
+	}
+	labels := metaLabels(s.Metadata)
+	// headless services have no cluster IP
+	if s.Spec.ClusterIP != "" && s.Spec.ClusterIP != "None" {
+		labels[data.IP] = s.Spec.ClusterIP
+	}
+	addPorts(s.Spec.Ports, labels)
+
+	if !matcher.All(labels) {
+		return nil, nil
+	}
+	ma := make(data.InterfaceMap, metricAnnotationsToAdd)
+	naming.AddNamespaceName(ma, s.Metadata.Namespace)
+	naming.AddServiceName(ma, s.Metadata.Name)
+	naming.AddLabels(ma, s.Metadata.Labels)
+	return []discovery.Discovery{{
+		Variables:         discovery.LabelsToMap(data.DiscoveryPrefix, labels),
+		MetricAnnotations: ma,
+	}}, nil
+}
+
+// endpointsDiscoveries returns a discovery for each ready address of the endpoints, optionally
+// restricted to the addresses placed in a node.
+func endpointsDiscoveries(raw json.RawMessage, node string, matcher *discovery.FieldsMatcher) ([]discovery.Discovery, error) {
+	e := endpoints{}
+	if err := json.Unmarshal(raw, &e); err != nil {
+		return nil, err
+	}
+	var matches []discovery.Discovery
+	for _, subset := range e.Subsets {
+		for _, address := range subset.Addresses {
+			if node != "" && address.NodeName != node {
+				continue
+			}
+			labels := metaLabels(e.Metadata)
+			labels[data.IP] = address.IP
+			labels[data.PrivateIP] = address.IP
+			if address.NodeName != "" {
+				labels[data.Node] = address.NodeName
+			}
+			podName := ""
+			if address.TargetRef != nil && address.TargetRef.Kind == "Pod" {
+				podName = address.TargetRef.Name
+				labels[data.Pod] = podName
+			}
+			addPorts(subset.Ports, labels)
+
+			if !matcher.All(labels) {
+				continue
+			}
+			ma := make(data.InterfaceMap, metricAnnotationsToAdd)
+			naming.AddNamespaceName(ma, e.Metadata.Namespace)
+			naming.AddServiceName(ma, e.Metadata.Name)
+			naming.AddLabels(ma, e.Metadata.Labels)
+			if podName != "" {
+				naming.AddPodName(ma, podName)
+			}
+			if address.NodeName != "" {
+				naming.AddNodeName(ma, address.NodeName)
+			}
+			matches = append(matches, discovery.Discovery{
+				Variables:         discovery.LabelsToMap(data.DiscoveryPrefix, labels),
+				MetricAnnotations: ma,
+			})
+		}
+	}
+	return matches, nil
+}
+
+// metaLabels returns the discovery attributes common to all the resources.
+func metaLabels(meta objectMeta) map[string]string {
+	labels := map[string]string{
+		data.Name:      meta.Name,
+		data.Namespace: meta.Namespace,
+	}
+	for k, v := range meta.Labels {
+		labels[data.LabelInfix+k] = v
+	}
+	for k, v := range meta.Annotations {
+		labels[data.AnnotationInfix+k] = v
+	}
+	return labels
+}
+
+func addPorts(ports []port, labels map[string]string) {
+	// sort ports from lower to higher so we are always consistent with the returned ports
+	sort.SliceStable(ports, func(i, j int) bool {
+		return ports[i].Port < ports[j].Port
+	})
+
+	protocols := counter.ByKind{}
+	for index, p := range ports {
+		portStr := strconv.Itoa(p.Port)
+		if index == 0 {
+			labels[data.Port] = portStr // discovery.port = <...>
+		}
+		labels[data.Ports+"."+strconv.Itoa(index)] = portStr // discovery.ports.0 = <...>
+
+		protocol := strings.ToLower(p.Protocol)
+		if protocol == "" {
+			protocol = "tcp"
+		}
+		pNum := protocols.Count(protocol)
+		if pNum == 0 {
+			labels[data.Ports+"."+protocol] = portStr // discovery.ports.tcp = <...>
+		}
+		labels[data.Ports+"."+protocol+"."+strconv.Itoa(pNum)] = portStr // discovery.ports.tcp.0 = <...>
+	}
+	// named ports are set at the end, so they aren't overridden by the protocol ones
+	for _, p := range ports {
+		if p.Name != "" {
+			labels[data.Ports+"."+p.Name] = strconv.Itoa(p.Port) // discovery.ports.http = <...>
+		}
+	}
+}
@@ -0,0 +1,279 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package kubernetes
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/newrelic/infrastructure-agent/pkg/databind/internal/discovery"
+	"github.com/newrelic/infrastructure-agent/pkg/databind/pkg/data"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const redisPod = `{
+  "metadata": {"name": "redis-0", "namespace": "cache", "uid": "uid-1", "resourceVersion": "10",
+    "labels": {"app": "redis"}, "annotations": {"newrelic.com/integration": "nri-redis"}},
+  "spec": {"nodeName": "node-a", "containers": [
+    {"name": "redis", "image": "redis:6", "ports": [{"name": "redis", "containerPort": 6379, "protocol": "TCP"}]},
+    {"name": "exporter", "image": "exporter:1", "ports": [{"name": "metrics", "containerPort": 9121}]}
+  ]},
+  "status": {"phase": "Running", "podIP": "10.1.0.5", "hostIP": "192.168.0.10"}
+}`
+
+func podJSON(name, uid, version, phase string) string {
+	return fmt.Sprintf(`{"metadata": {"name": %q, "namespace": "cache", "uid": %q, "resourceVersion": %q, "labels": {"app": "redis"}},
+  "spec": {"nodeName": "node-a", "containers": [{"name": "redis", "image": "redis:6"}]},
+  "status": {"phase": %q, "podIP": "10.1.0.6"}}`, name, uid, version, phase)
+}
+
+func matcher(t *testing.T, match map[string]string) *discovery.FieldsMatcher {
+	m, err := discovery.NewMatcher(match)
+	require.NoError(t, err)
+	return &m
+}
+
+func TestDiscoverer_Pods(t *testing.T) {
+	// GIVEN an API server with a running pod, which is later followed by another one
+	watched := make(chan string, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/v1/namespaces/cache/pods", r.URL.Path)
+		assert.Equal(t, "spec.nodeName=node-a", r.URL.Query().Get("fieldSelector"))
+		assert.Equal(t, "Bearer secret", r.Header.Get("Authorization"))
+		if r.URL.Query().Get("watch") == "" {
+			fmt.Fprintf(w, `{"metadata": {"resourceVersion": "10"}, "items": [%s, %s]}`,
+				redisPod, podJSON("pending", "uid-3", "10", "Pending"))
+			return
+		}
+		select {
+		case watched <- r.URL.Query().Get("resourceVersion"):
+			fmt.Fprintf(w, `{"type": "ADDED", "object": %s}`, podJSON("redis-1", "uid-2", "11", "Running"))
+			w.(http.Flusher).Flush()
+		default:
+		}
+		// keeps the watch open
+		<-r.Context().Done()
+	}))
+	defer srv.Close()
+	// closes the open watches, so the server can be closed
+	defer srv.CloseClientConnections()
+
+	changes := discovery.NewChanges()
+	fetch := discoverer(context.Background(), &client{baseURL: srv.URL, http: srv.Client(), token: "secret"},
+		discovery.Kubernetes{Namespace: "cache", Node: "node-a"},
+		matcher(t, map[string]string{"label.app": "redis"}), changes)
+
+	// WHEN the pods are discovered
+	discoveries, err := fetch()
+
+	// THEN the running pods are returned with their attributes
+	require.NoError(t, err)
+	require.Len(t, discoveries, 1)
+	assert.Equal(t, data.Map{
+		"discovery.name":                                "redis-0",
+		"discovery.namespace":                           "cache",
+		"discovery.label.app":                           "redis",
+		"discovery.annotation.newrelic.com/integration": "nri-redis",
+		"discovery.ip":                                  "10.1.0.5",
+		"discovery.private.ip":                          "10.1.0.5",
+		"discovery.node":                                "node-a",
+		"discovery.node.ip":                             "192.168.0.10",
+		"discovery.image":                               "redis:6",
+		"discovery.port":                                "6379",
+		"discovery.ports.0":                             "6379",
+		"discovery.ports.1":                             "9121",
+		"discovery.ports.tcp":                           "6379",
+		"discovery.ports.tcp.0":                         "6379",
+		"discovery.ports.tcp.1":                         "9121",
+		"discovery.ports.redis":                         "6379",
+		"discovery.ports.metrics":                       "9121",
+	}, discoveries[0].Variables)
+	assert.Equal(t, "redis-0", discoveries[0].MetricAnnotations[data.PodName])
+	assert.Equal(t, "cache", discoveries[0].MetricAnnotations[data.NamespaceName])
+
+	// AND the changes are watched from the listed version and notified
+	assert.Equal(t, "10", <-watched)
+	require.Eventually(t, changes.Changed, 5*time.Second, 10*time.Millisecond)
+	require.Eventually(t, func() bool {
+		discoveries, err = fetch()
+		return err == nil && len(discoveries) == 2
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, "redis-1", discoveries[1].Variables["discovery.name"])
+}
+
+func TestDiscoverer_Endpoints(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("watch") != "" {
+			<-r.Context().Done()
+			return
+		}
+		fmt.Fprint(w, `{"metadata": {"resourceVersion": "3"}, "items": [{
+  "metadata": {"name": "redis", "namespace": "cache", "uid": "uid-1", "labels": {"app": "redis"}},
+  "subsets": [{
+    "addresses": [
+      {"ip": "10.1.0.5", "nodeName": "node-a", "targetRef": {"kind": "Pod", "name": "redis-0"}},
+      {"ip": "10.1.0.6", "nodeName": "node-b", "targetRef": {"kind": "Pod", "name": "redis-1"}}
+    ],
+    "ports": [{"name": "redis", "port": 6379, "protocol": "TCP"}]
+  }]
+}]}`)
+	}))
+	defer srv.Close()
+	defer srv.CloseClientConnections()
+
+	// GIVEN the endpoints of a service spread in two nodes
+	fetch := discoverer(context.Background(), &client{baseURL: srv.URL, http: srv.Client()},
+		discovery.Kubernetes{Kind: discovery.KindEndpoints, Node: "node-b"},
+		matcher(t, map[string]string{"name": "redis"}), nil)
+
+	// WHEN they are discovered from a node
+	discoveries, err := fetch()
+
+	// THEN only the addresses of the node are returned
+	require.NoError(t, err)
+	require.Len(t, discoveries, 1)
+	assert.Equal(t, "10.1.0.6", discoveries[0].Variables["discovery.ip"])
+	assert.Equal(t, "redis-1", discoveries[0].Variables["discovery.pod"])
+	assert.Equal(t, "6379", discoveries[0].Variables["discovery.ports.redis"])
+	assert.Equal(t, "redis", discoveries[0].MetricAnnotations[data.ServiceName])
+}
+
+func TestServiceDiscoveries(t *testing.T) {
+	discoveries, err := serviceDiscoveries([]byte(`{
+  "metadata": {"name": "web", "namespace": "default", "uid": "uid-1"},
+  "spec": {"clusterIP": "10.96.0.10", "ports": [{"port": 443, "protocol": "TCP"}, {"name": "dns", "port": 53, "protocol": "UDP"}]}
+}`), matcher(t, map[string]string{"namespace": "default"}))
+	require.NoError(t, err)
+	require.Len(t, discoveries, 1)
+	assert.Equal(t, "10.96.0.10", discoveries[0].Variables["discovery.ip"])
+	assert.Equal(t, "53", discoveries[0].Variables["discovery.port"])
+	assert.Equal(t, "53", discoveries[0].Variables["discovery.ports.udp"])
+	assert.Equal(t, "443", discoveries[0].Variables["discovery.ports.tcp"])
+
+	discoveries, err = serviceDiscoveries([]byte(`{"metadata": {"name": "web", "namespace": "other"}}`),
+		matcher(t, map[string]string{"namespace": "default"}))
+	require.NoError(t, err)
+	assert.Empty(t, discoveries)
+}
+
+func TestWatcher_RelistsOnExpiredVersion(t *testing.T) {
+	lists := make(chan struct{}, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("watch") == "" {
+			select {
+			case lists <- struct{}{}:
+			default:
+			}
+			fmt.Fprintf(w, `{"metadata": {"resourceVersion": "1"}, "items": [%s]}`, redisPod)
+			return
+		}
+		fmt.Fprint(w, `{"type": "ERROR", "object": {"kind": "Status", "code": 410, "message": "too old resource version"}}`)
+	}))
+	defer srv.Close()
+
+	// GIVEN a watcher whose resource version expires
+	w := newWatcher(context.Background(), &client{baseURL: srv.URL, http: srv.Client()}, "/api/v1/pods", nil, nil)
+
+	// WHEN its items are requested
+	items, err := w.items()
+	require.NoError(t, err)
+	assert.Len(t, items, 1)
+
+	// THEN the resources are listed again
+	<-lists
+	select {
+	case <-lists:
+	case <-time.After(5 * time.Second):
+		t.Fatal("resources not listed again")
+	}
+}
+
+func TestWatcher_StopsOnCancel(t *testing.T) {
+	watching := make(chan struct{}, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("watch") == "" {
+			fmt.Fprintf(w, `{"metadata": {"resourceVersion": "1"}, "items": [%s]}`, redisPod)
+			return
+		}
+		// the watch is kept open until the client disconnects
+		w.(http.Flusher).Flush()
+		select {
+		case watching <- struct{}{}:
+		default:
+		}
+		<-r.Context().Done()
+	}))
+	defer srv.Close()
+
+	// GIVEN a watcher watching the resources in background
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	w := newWatcher(ctx, &client{baseURL: srv.URL, http: srv.Client()}, "/api/v1/pods", nil, nil)
+	_, err := w.items()
+	require.NoError(t, err)
+	select {
+	case <-watching:
+	case <-time.After(5 * time.Second):
+		t.Fatal("resources not watched")
+	}
+
+	// WHEN its context is cancelled
+	cancel()
+
+	// THEN the background watch finishes
+	select {
+	case <-w.stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("watcher still running")
+	}
+}
+
+func TestKubeconfigClient(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kubeconfig")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "token"), []byte("file-token\n"), 0600))
+	path := filepath.Join(dir, "config")
+	require.NoError(t, ioutil.WriteFile(path, []byte(`
+current-context: dev
+contexts:
+- name: dev
+  context: {cluster: dev-cluster, user: dev-user}
+- name: prod
+  context: {cluster: prod-cluster, user: prod-user}
+clusters:
+- name: dev-cluster
+  cluster: {server: "https://dev:6443/", insecure-skip-tls-verify: true}
+- name: prod-cluster
+  cluster: {server: "https://prod:6443"}
+users:
+- name: dev-user
+  user: {token: dev-token}
+- name: prod-user
+  user: {tokenFile: token}
+`), 0600))
+
+	c, err := kubeconfigClient(path, "")
+	require.NoError(t, err)
+	assert.Equal(t, "https://dev:6443", c.baseURL)
+	assert.Equal(t, "dev-token", c.token)
+	assert.True(t, c.http.Transport.(*http.Transport).TLSClientConfig.InsecureSkipVerify)
+
+	c, err = kubeconfigClient(path, "prod")
+	require.NoError(t, err)
+	assert.Equal(t, "https://prod:6443", c.baseURL)
+	assert.Equal(t, filepath.Join(dir, "token"), c.tokenFile)
+
+	_, err = kubeconfigClient(path, "missing")
+	assert.Error(t, err)
+}
@@ -0,0 +1,252 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package kubernetes
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/newrelic/infrastructure-agent/pkg/databind/internal/discovery"
+	"github.com/newrelic/infrastructure-agent/pkg/log"
+)
+
+const (
+	listTimeout = 30 * time.Second
+	// watchTimeout makes the API server close the watches periodically, so broken connections are detected
+	watchTimeout = 5 * time.Minute
+	minBackoff   = time.Second
+	maxBackoff   = time.Minute
+)
+
+var wlog = log.WithComponent("databind.KubernetesWatcher")
+
+// errExpired is returned when the resource version to watch from isn't available anymore.
+var errExpired = errors.New("resource version expired")
+
+type objectMeta struct {
+	Name            string            `json:"name"`
+	Namespace       string            `json:"namespace"`
+	UID             string            `json:"uid"`
+	ResourceVersion string            `json:"resourceVersion"`
+	Labels          map[string]string `json:"labels"`
+	Annotations     map[string]string `json:"annotations"`
+}
+
+type object struct {
+	Metadata objectMeta `json:"metadata"`
+}
+
+type list struct {
+	Metadata struct {
+		ResourceVersion string `json:"resourceVersion"`
+	} `json:"metadata"`
+	Items []json.RawMessage `json:"items"`
+}
+
+type event struct {
+	Type   string          `json:"type"`
+	Object json.RawMessage `json:"object"`
+}
+
+// watcher keeps an up-to-date copy of the resources of a kind. They are listed on the first access and
+// then watched in background until the context is cancelled, so the discovery doesn't query the whole
+// resources on each refresh. The resource changes are notified, so the cached discoveries are refreshed.
+type watcher struct {
+	ctx     context.Context
+	client  *client
+	path    string
+	query   url.Values
+	changes discovery.Changes
+	// start serializes the initial listing
+	start   sync.Mutex
+	lock    sync.Mutex
+	started bool
+	objects map[string]json.RawMessage // by uid
+	// stopped is closed once the background watch finishes
+	stopped chan struct{}
+}
+
+func newWatcher(ctx context.Context, c *client, path string, query url.Values, changes discovery.Changes) *watcher {
+	return &watcher{
+		ctx:     ctx,
+		client:  c,
+		path:    path,
+		query:   query,
+		changes: changes,
+		objects: map[string]json.RawMessage{},
+		stopped: make(chan struct{}),
+	}
+}
+
+// items returns the current resources. The first invocation lists them and starts watching their changes.
+func (w *watcher) items() ([]json.RawMessage, error) {
+	w.start.Lock()
+	if !w.started {
+		version, err := w.list()
+		if err != nil {
+			w.start.Unlock()
+			return nil, err
+		}
+		w.started = true
+		go w.run(version)
+	}
+	w.start.Unlock()
+
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	items := make([]json.RawMessage, 0, len(w.objects))
+	for _, o := range w.objects {
+		items = append(items, o)
+	}
+	return items, nil
+}
+
+// run watches the resources from the passed version, listing them again whenever the watch can't be resumed,
+// until the watcher context is cancelled.
+func (w *watcher) run(version string) {
+	defer close(w.stopped)
+	backoff := minBackoff
+	for {
+		var err error
+		if version, err = w.watch(version); err == nil {
+			// the server closed the watch on timeout: it is resumed
+			backoff = minBackoff
+			continue
+		}
+		if w.ctx.Err() != nil {
+			return
+		}
+		if err != errExpired {
+			wlog.WithError(err).WithField("path", w.path).Warn("kubernetes watch failed, listing resources again")
+			if !w.sleep(backoff) {
+				return
+			}
+			if backoff *= 2; backoff > maxBackoff {
+				backoff = maxBackoff
+			}
+		}
+		for {
+			if version, err = w.list(); err == nil {
+				break
+			}
+			if w.ctx.Err() != nil {
+				return
+			}
+			wlog.WithError(err).WithField("path", w.path).Warn("can't list kubernetes resources")
+			if !w.sleep(backoff) {
+				return
+			}
+			if backoff *= 2; backoff > maxBackoff {
+				backoff = maxBackoff
+			}
+		}
+		// changes may have been missed while the resources weren't watched
+		w.changes.Notify()
+	}
+}
+
+// sleep waits for the passed duration, returning false if the watcher context is cancelled meanwhile.
+func (w *watcher) sleep(d time.Duration) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return true
+	case <-w.ctx.Done():
+		return false
+	}
+}
+
+// list replaces the stored resources by the current ones, returning their resource version.
+func (w *watcher) list() (string, error) {
+	ctx, cancel := context.WithTimeout(w.ctx, listTimeout)
+	defer cancel()
+	resp, err := w.client.get(ctx, w.path, w.query)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	l := list{}
+	if err = json.NewDecoder(resp.Body).Decode(&l); err != nil {
+		return "", err
+	}
+	objects := make(map[string]json.RawMessage, len(l.Items))
+	for _, item := range l.Items {
+		o := object{}
+		if err = json.Unmarshal(item, &o); err != nil {
+			return "", err
+		}
+		objects[o.Metadata.UID] = item
+	}
+	w.lock.Lock()
+	w.objects = objects
+	w.lock.Unlock()
+	return l.Metadata.ResourceVersion, nil
+}
+
+// watch applies the resource changes since the passed version until the server closes the watch. It
+// returns the version of the last applied change.
+func (w *watcher) watch(version string) (string, error) {
+	query := url.Values{}
+	for k, v := range w.query {
+		query[k] = v
+	}
+	query.Set("watch", "true")
+	query.Set("resourceVersion", version)
+	query.Set("allowWatchBookmarks", "true")
+	query.Set("timeoutSeconds", strconv.Itoa(int(watchTimeout/time.Second)))
+
+	resp, err := w.client.get(w.ctx, w.path, query)
+	if se, ok := err.(*statusError); ok && se.code == http.StatusGone {
+		return version, errExpired
+	}
+	if err != nil {
+		return version, err
+	}
+	defer resp.Body.Close()
+
+	decoder := json.NewDecoder(resp.Body)
+	for {
+		e := event{}
+		if err := decoder.Decode(&e); err == io.EOF {
+			return version, nil
+		} else if err != nil {
+			return version, err
+		}
+		if e.Type == "ERROR" {
+			status := struct {
+				Code    int    `json:"code"`
+				Message string `json:"message"`
+			}{}
+			if err := json.Unmarshal(e.Object, &status); err == nil && status.Code == http.StatusGone {
+				return version, errExpired
+			}
+			return version, &statusError{code: status.Code, message: status.Message}
+		}
+		o := object{}
+		if err := json.Unmarshal(e.Object, &o); err != nil {
+			return version, err
+		}
+		version = o.Metadata.ResourceVersion
+		w.lock.Lock()
+		switch e.Type {
+		case "ADDED", "MODIFIED":
+			w.objects[o.Metadata.UID] = e.Object
+		case "DELETED":
+			delete(w.objects, o.Metadata.UID)
+		}
+		w.lock.Unlock()
+		// bookmarks only update the resource version
+		if e.Type != "BOOKMARK" {
+			w.changes.Notify()
+		}
+	}
+}
@@ -86,3 +86,23 @@ func AddCommand(metricAnnotations data.InterfaceMap, command string) {
 func AddDockerContainerName(metricAnnotations data.InterfaceMap, dockerContainerName string) {
 	metricAnnotations[data.DockerContainerName] = dockerContainerName
 }
+
+// AddNamespaceName adds Kubernetes namespace name to metricAnnotations
+func AddNamespaceName(metricAnnotations data.InterfaceMap, namespace string) {
+	metricAnnotations[data.NamespaceName] = namespace
+}
+
+// AddPodName adds Kubernetes pod name to metricAnnotations
+func AddPodName(metricAnnotations data.InterfaceMap, pod string) {
+	metricAnnotations[data.PodName] = pod
+}
+
+// AddNodeName adds Kubernetes node name to metricAnnotations
+func AddNodeName(metricAnnotations data.InterfaceMap, node string) {
+	metricAnnotations[data.NodeName] = node
+}
+
+// AddServiceName adds Kubernetes service name to metricAnnotations
+func AddServiceName(metricAnnotations data.InterfaceMap, service string) {
+	metricAnnotations[data.ServiceName] = service
+}
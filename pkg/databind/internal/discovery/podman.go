@@ -0,0 +1,28 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package discovery
+
+import (
+	"errors"
+	"strings"
+)
+
+// Podman discovery parameters
+type Podman struct {
+	Match map[string]string `yaml:"match"`
+	// Socket is the path of the Podman API service socket. Default: CONTAINER_HOST environment variable,
+	// /run/podman/podman.sock for root, or $XDG_RUNTIME_DIR/podman/podman.sock for rootless Podman
+	Socket     string `yaml:"socket"`
+	ApiVersion string `yaml:"api_version"`
+}
+
+func (p *Podman) Validate() error {
+	if len(p.Match) == 0 {
+		return errors.New("missing 'match' entries")
+	}
+	if strings.Contains(p.Socket, "://") && !strings.HasPrefix(p.Socket, "unix://") {
+		return errors.New("podman discovery only supports unix sockets")
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package podman discovers the containers managed by Podman, including the rootless ones.
+// The containers are listed through the Docker compatible endpoints of the Podman API service
+// (podman system service), so the matched fields and the discovery variables are the same as
+// for the docker discovery.
+package podman
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/newrelic/infrastructure-agent/pkg/databind/internal/discovery"
+	"github.com/newrelic/infrastructure-agent/pkg/databind/internal/discovery/docker"
+)
+
+const rootSocket = "/run/podman/podman.sock"
+
+// Discoverer returns a Podman container discoverer from the provided configuration. The container
+// events are notified to the provided changes, as for the docker discovery, until the passed context
+// is cancelled.
+func Discoverer(ctx context.Context, p discovery.Podman, changes discovery.Changes) (fetchDiscoveries func() (discoveries []discovery.Discovery, err error), err error) {
+	return docker.HostDiscoverer(ctx, discovery.Container{
+		Match:      p.Match,
+		ApiVersion: p.ApiVersion,
+	}, host(p.Socket, os.Getenv, os.Geteuid()), changes)
+}
+
+// host returns the address of the Podman API service socket, by default the one of the user running the agent.
+func host(socket string, getenv func(string) string, uid int) string {
+	if socket == "" {
+		socket = getenv("CONTAINER_HOST")
+	}
+	if socket == "" {
+		if uid == 0 {
+			socket = rootSocket
+		} else if runtimeDir := getenv("XDG_RUNTIME_DIR"); runtimeDir != "" {
+			socket = filepath.Join(runtimeDir, "podman", "podman.sock")
+		} else {
+			socket = fmt.Sprintf("/run/user/%d/podman/podman.sock", uid)
+		}
+	}
+	if !strings.HasPrefix(socket, "unix://") {
+		socket = "unix://" + socket
+	}
+	return socket
+}
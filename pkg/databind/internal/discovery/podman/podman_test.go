@@ -0,0 +1,89 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package podman
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/newrelic/infrastructure-agent/pkg/databind/internal/discovery"
+)
+
+// containers as returned by the Docker compatible endpoint of a rootless Podman service
+const containers = `[{
+  "Id": "1f8c2e0e2a5b",
+  "Names": ["/redis"],
+  "Image": "docker.io/library/redis:6",
+  "ImageID": "sha256:ef47",
+  "Command": "redis-server",
+  "Labels": {"app": "cache"},
+  "Ports": [{"IP": "0.0.0.0", "PrivatePort": 6379, "PublicPort": 16379, "Type": "tcp"}],
+  "NetworkSettings": {"Networks": {"podman": {"IPAddress": "10.88.0.5"}}}
+}, {
+  "Id": "9a3d7c1b4e2f",
+  "Names": ["/web"],
+  "Image": "docker.io/library/nginx:1.19",
+  "Labels": {"app": "web"},
+  "NetworkSettings": {"Networks": {}}
+}]`
+
+func TestDiscoverer(t *testing.T) {
+	// GIVEN a Podman API service listening in a unix socket
+	dir, err := ioutil.TempDir("", "podman")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	socket := filepath.Join(dir, "podman.sock")
+	listener, err := net.Listen("unix", socket)
+	require.NoError(t, err)
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("API-Version", "1.40")
+		if strings.HasSuffix(r.URL.Path, "/containers/json") {
+			_, _ = w.Write([]byte(containers))
+		}
+	}))
+	server.Listener = listener
+	server.Start()
+	defer server.Close()
+
+	// WHEN the containers are discovered
+	fetch, err := Discoverer(context.Background(), discovery.Podman{Socket: socket, Match: map[string]string{"label.app": "cache"}}, nil)
+	require.NoError(t, err)
+	discoveries, err := fetch()
+
+	// THEN the matching containers are returned with the same variables as the docker discovery
+	require.NoError(t, err)
+	require.Len(t, discoveries, 1)
+	vars := discoveries[0].Variables
+	assert.Equal(t, "redis", vars["discovery.name"])
+	assert.Equal(t, "1f8c2e0e2a5b", vars["discovery.containerId"])
+	assert.Equal(t, "docker.io/library/redis:6", vars["discovery.image"])
+	assert.Equal(t, "10.88.0.5", vars["discovery.private.ip"])
+	assert.Equal(t, "16379", vars["discovery.port"])
+	assert.Equal(t, "6379", vars["discovery.private.port"])
+	assert.Equal(t, "cache", vars["discovery.label.app"])
+}
+
+func TestHost(t *testing.T) {
+	env := func(vars map[string]string) func(string) string {
+		return func(key string) string {
+			return vars[key]
+		}
+	}
+	assert.Equal(t, "unix:///tmp/podman.sock", host("/tmp/podman.sock", env(nil), 1000))
+	assert.Equal(t, "unix:///tmp/podman.sock", host("unix:///tmp/podman.sock", env(nil), 1000))
+	assert.Equal(t, "unix:///tmp/remote.sock", host("", env(map[string]string{"CONTAINER_HOST": "unix:///tmp/remote.sock"}), 1000))
+	assert.Equal(t, "unix:///run/podman/podman.sock", host("", env(nil), 0))
+	assert.Equal(t, "unix:///run/user/1000/podman/podman.sock", host("", env(map[string]string{"XDG_RUNTIME_DIR": "/run/user/1000"}), 1000))
+	assert.Equal(t, "unix:///run/user/1001/podman/podman.sock", host("", env(nil), 1001))
+}
@@ -0,0 +1,21 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package discovery
+
+import (
+	"errors"
+)
+
+// Swarm discovery parameters
+type Swarm struct {
+	Match      map[string]string `yaml:"match"`
+	ApiVersion string            `yaml:"api_version"` // for docker client
+}
+
+func (s *Swarm) Validate() error {
+	if len(s.Match) == 0 {
+		return errors.New("missing 'match' entries")
+	}
+	return nil
+}
@@ -0,0 +1,224 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package swarm discovers the running tasks of the Docker Swarm services, so integrations can be
+// fanned out per task. The Swarm API is only served by manager nodes.
+package swarm
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/swarm"
+	"github.com/docker/docker/client"
+
+	"github.com/newrelic/infrastructure-agent/pkg/databind/internal/counter"
+	"github.com/newrelic/infrastructure-agent/pkg/databind/internal/discovery"
+	"github.com/newrelic/infrastructure-agent/pkg/databind/internal/discovery/naming"
+	"github.com/newrelic/infrastructure-agent/pkg/databind/pkg/data"
+)
+
+const metricAnnotationsToAdd = 5
+
+// Discoverer returns a Docker Swarm task discoverer from the provided configuration.
+func Discoverer(s discovery.Swarm) (fetchDiscoveries func() (discoveries []discovery.Discovery, err error), err error) {
+	return hostDiscoverer(s, "")
+}
+
+// hostDiscoverer returns a Swarm task discoverer for the Docker API served in the provided host. If the
+// host is empty, it is taken from the DOCKER_HOST environment variable.
+func hostDiscoverer(s discovery.Swarm, host string) (fetchDiscoveries func() (discoveries []discovery.Discovery, err error), err error) {
+	matcher, err := discovery.NewMatcher(s.Match)
+	if err != nil {
+		return nil, err
+	}
+	return func() ([]discovery.Discovery, error) {
+		return fetch(host, s.ApiVersion, &matcher)
+	}, nil
+}
+
+func fetch(host, apiVersion string, matcher *discovery.FieldsMatcher) ([]discovery.Discovery, error) {
+	opts := []client.Opt{client.FromEnv, client.WithAPIVersionNegotiation()}
+	if apiVersion != "" {
+		opts = append(opts, client.WithVersion(apiVersion))
+	}
+	if host != "" {
+		opts = append(opts, client.WithHost(host))
+	}
+	dc, err := client.NewClientWithOpts(opts...)
+	if err != nil {
+		return nil, err
+	}
+	defer dc.Close()
+
+	ctx := context.Background()
+	services, err := dc.ServiceList(ctx, types.ServiceListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	nodes, err := dc.NodeList(ctx, types.NodeListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	tasks, err := dc.TaskList(ctx, types.TaskListOptions{
+		Filters: filters.NewArgs(filters.Arg("desired-state", string(swarm.TaskStateRunning))),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return match(tasks, services, nodes, matcher), nil
+}
+
+func match(tasks []swarm.Task, services []swarm.Service, nodes []swarm.Node, matcher *discovery.FieldsMatcher) []discovery.Discovery {
+	servicesByID := make(map[string]swarm.Service, len(services))
+	for _, s := range services {
+		servicesByID[s.ID] = s
+	}
+	nodesByID := make(map[string]swarm.Node, len(nodes))
+	for _, n := range nodes {
+		nodesByID[n.ID] = n
+	}
+
+	// sorted by service and slot, so the matches are always returned in the same order
+	sort.Slice(tasks, func(i, j int) bool {
+		if tasks[i].ServiceID != tasks[j].ServiceID {
+			return tasks[i].ServiceID < tasks[j].ServiceID
+		}
+		return tasks[i].Slot < tasks[j].Slot
+	})
+
+	var matches []discovery.Discovery
+	for _, task := range tasks {
+		service, ok := servicesByID[task.ServiceID]
+		if !ok || task.Status.State != swarm.TaskStateRunning || task.Spec.ContainerSpec == nil {
+			continue
+		}
+		node := nodesByID[task.NodeID]
+		labels := taskLabels(task, service, node)
+
+		// only tasks matching all the criteria will be added
+		if !matcher.All(labels) {
+			continue
+		}
+		ma := make(data.InterfaceMap, metricAnnotationsToAdd)
+		naming.AddImage(ma, labels[data.Image])
+		naming.AddContainerName(ma, labels[data.Name])
+		naming.AddServiceName(ma, service.Spec.Name)
+		naming.AddLabels(ma, service.Spec.Labels)
+		entityRewrites := []data.EntityRewrite(nil)
+		if containerID := labels[data.ContainerID]; containerID != "" {
+			naming.AddContainerID(ma, containerID)
+			entityRewrites = append(entityRewrites, data.EntityRewrite{
+				Action:       data.EntityRewriteActionReplace,
+				Match:        naming.ToVariable(data.IP),
+				ReplaceField: data.ContainerReplaceFieldPrefix + naming.ToVariable(data.ContainerID),
+			})
+		}
+
+		matches = append(matches, discovery.Discovery{
+			Variables:         discovery.LabelsToMap(data.DiscoveryPrefix, labels),
+			EntityRewrites:    entityRewrites,
+			MetricAnnotations: ma,
+		})
+	}
+	return matches
+}
+
+// taskLabels returns the discovery attributes that identify a task.
+func taskLabels(task swarm.Task, service swarm.Service, node swarm.Node) map[string]string {
+	labels := map[string]string{}
+	// service labels take precedence over the container ones
+	for k, v := range task.Spec.ContainerSpec.Labels {
+		labels[data.LabelInfix+k] = v
+	}
+	for k, v := range service.Spec.Labels {
+		labels[data.LabelInfix+k] = v
+	}
+	labels[data.Service] = service.Spec.Name
+	labels[data.ID] = task.ID
+	// tasks of replicated services are named after their slot, and tasks of global services after their node
+	if task.Slot > 0 {
+		labels[data.Slot] = strconv.Itoa(task.Slot)
+		labels[data.Name] = service.Spec.Name + "." + strconv.Itoa(task.Slot)
+	} else {
+		labels[data.Name] = service.Spec.Name + "." + task.NodeID
+	}
+	// image references are pinned to a digest
+	labels[data.Image] = strings.SplitN(task.Spec.ContainerSpec.Image, "@", 2)[0]
+	if task.Status.ContainerStatus != nil && task.Status.ContainerStatus.ContainerID != "" {
+		labels[data.ContainerID] = task.Status.ContainerStatus.ContainerID
+	}
+	if node.Description.Hostname != "" {
+		labels[data.Node] = node.Description.Hostname
+	}
+	if node.Status.Addr != "" {
+		labels[data.NodeIP] = node.Status.Addr
+	}
+
+	index := 0
+	for _, attachment := range task.NetworksAttachments {
+		for _, address := range attachment.Addresses {
+			// addresses are in CIDR notation
+			ip := strings.SplitN(address, "/", 2)[0]
+			if index == 0 {
+				labels[data.PrivateIP] = ip
+				labels[data.IP] = ip
+			}
+			labels[data.PrivateIP+"."+strconv.Itoa(index)] = ip
+			index++
+		}
+	}
+
+	ports := make([]swarm.PortConfig, 0, len(service.Endpoint.Ports)+len(task.Status.PortStatus.Ports))
+	ports = append(ports, service.Endpoint.Ports...)
+	addPorts(append(ports, task.Status.PortStatus.Ports...), labels)
+	return labels
+}
+
+// addPorts labels the ports published by the service in the ingress routing mesh and by the task
+// in its node. Published ports are reachable in the node address, so it is taken as the task IP.
+func addPorts(ports []swarm.PortConfig, labels map[string]string) {
+	var published []swarm.PortConfig
+	for _, port := range ports {
+		if port.PublishedPort > 0 {
+			published = append(published, port)
+		}
+	}
+	if len(published) == 0 {
+		return
+	}
+	// sort ports from lower to higher so we are always consistent with the returned ports
+	sort.Slice(published, func(i, j int) bool {
+		return published[i].PublishedPort < published[j].PublishedPort
+	})
+	if nodeIP, ok := labels[data.NodeIP]; ok {
+		labels[data.IP] = nodeIP
+	}
+
+	types := counter.ByKind{}
+	for index, port := range published {
+		indexStr := "." + strconv.Itoa(index)
+		publicPort := strconv.Itoa(int(port.PublishedPort))
+		privatePort := strconv.Itoa(int(port.TargetPort))
+		if index == 0 {
+			labels[data.Port] = publicPort
+			labels[data.PrivatePort] = privatePort
+		}
+		labels[data.Ports+indexStr] = publicPort
+		labels[data.PrivatePorts+indexStr] = privatePort
+
+		// label ports by type (e.g. discovery.port.tcp.1)
+		if kind := string(port.Protocol); kind != "" {
+			if types.Count(kind) == 0 {
+				labels[data.Ports+"."+kind] = publicPort
+				labels[data.PrivatePorts+"."+kind] = privatePort
+			}
+			labels[data.Ports+"."+kind+indexStr] = publicPort
+			labels[data.PrivatePorts+"."+kind+indexStr] = privatePort
+		}
+	}
+}
@@ -0,0 +1,140 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package swarm
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/api/types/swarm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/newrelic/infrastructure-agent/pkg/databind/internal/discovery"
+	"github.com/newrelic/infrastructure-agent/pkg/databind/pkg/data"
+)
+
+const services = `[{
+  "ID": "svc-redis",
+  "Spec": {"Name": "cache_redis", "Labels": {"com.docker.stack.namespace": "cache", "tier": "backend"}},
+  "Endpoint": {"Ports": [{"Protocol": "tcp", "TargetPort": 6379, "PublishedPort": 16379, "PublishMode": "ingress"}]}
+}, {
+  "ID": "svc-agent",
+  "Spec": {"Name": "monitoring_agent", "Labels": {"tier": "monitoring"}}
+}]`
+
+const nodes = `[{
+  "ID": "node-1",
+  "Description": {"Hostname": "worker-1"},
+  "Status": {"State": "ready", "Addr": "192.168.1.10"}
+}]`
+
+const tasks = `[{
+  "ID": "task-2", "ServiceID": "svc-redis", "Slot": 2, "NodeID": "node-1",
+  "Spec": {"ContainerSpec": {"Image": "redis:6@sha256:ef47", "Labels": {"tier": "container", "app": "redis"}}},
+  "Status": {"State": "running", "ContainerStatus": {"ContainerID": "c0ffee2"}},
+  "NetworksAttachments": [{"Addresses": ["10.0.1.6/24"]}]
+}, {
+  "ID": "task-1", "ServiceID": "svc-redis", "Slot": 1, "NodeID": "node-1",
+  "Spec": {"ContainerSpec": {"Image": "redis:6@sha256:ef47"}},
+  "Status": {"State": "running", "ContainerStatus": {"ContainerID": "c0ffee1"}},
+  "NetworksAttachments": [{"Addresses": ["10.0.1.5/24"]}]
+}, {
+  "ID": "task-3", "ServiceID": "svc-redis", "Slot": 3, "NodeID": "node-1",
+  "Spec": {"ContainerSpec": {"Image": "redis:6@sha256:ef47"}},
+  "Status": {"State": "starting"}
+}, {
+  "ID": "task-4", "ServiceID": "svc-agent", "NodeID": "node-1",
+  "Spec": {"ContainerSpec": {"Image": "newrelic/infrastructure:latest"}},
+  "Status": {"State": "running", "ContainerStatus": {"ContainerID": "c0ffee4"},
+    "PortStatus": {"Ports": [{"Protocol": "tcp", "TargetPort": 8080, "PublishedPort": 8080, "PublishMode": "host"}]}}
+}]`
+
+func TestDiscoverer(t *testing.T) {
+	// GIVEN a Docker API of a Swarm manager listening in a unix socket
+	dir, err := ioutil.TempDir("", "swarm")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	socket := filepath.Join(dir, "docker.sock")
+	listener, err := net.Listen("unix", socket)
+	require.NoError(t, err)
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("API-Version", "1.40")
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/services"):
+			_, _ = w.Write([]byte(services))
+		case strings.HasSuffix(r.URL.Path, "/nodes"):
+			_, _ = w.Write([]byte(nodes))
+		case strings.HasSuffix(r.URL.Path, "/tasks"):
+			_, _ = w.Write([]byte(tasks))
+		}
+	}))
+	server.Listener = listener
+	server.Start()
+	defer server.Close()
+
+	// WHEN the tasks of the services with a given label are discovered
+	fetch, err := hostDiscoverer(discovery.Swarm{Match: map[string]string{"label.tier": "backend"}}, "unix://"+socket)
+	require.NoError(t, err)
+	discoveries, err := fetch()
+	require.NoError(t, err)
+
+	// THEN a match is returned for each running task, ordered by slot
+	require.Len(t, discoveries, 2)
+	assert.Equal(t, data.Map{
+		"discovery.label.com.docker.stack.namespace": "cache",
+		"discovery.label.tier":                       "backend",
+		"discovery.service":                          "cache_redis",
+		"discovery.id":                               "task-1",
+		"discovery.slot":                             "1",
+		"discovery.name":                             "cache_redis.1",
+		"discovery.image":                            "redis:6",
+		"discovery.containerId":                      "c0ffee1",
+		"discovery.node":                             "worker-1",
+		"discovery.node.ip":                          "192.168.1.10",
+		"discovery.private.ip":                       "10.0.1.5",
+		"discovery.private.ip.0":                     "10.0.1.5",
+		"discovery.ip":                               "192.168.1.10",
+		"discovery.port":                             "16379",
+		"discovery.ports.0":                          "16379",
+		"discovery.ports.tcp":                        "16379",
+		"discovery.ports.tcp.0":                      "16379",
+		"discovery.private.port":                     "6379",
+		"discovery.private.ports.0":                  "6379",
+		"discovery.private.ports.tcp":                "6379",
+		"discovery.private.ports.tcp.0":              "6379",
+	}, discoveries[0].Variables)
+	assert.Equal(t, "cache_redis.2", discoveries[1].Variables["discovery.name"])
+	assert.Equal(t, "redis", discoveries[1].Variables["discovery.label.app"])
+	assert.Equal(t, "cache_redis", discoveries[0].MetricAnnotations["serviceName"])
+	assert.Equal(t, "c0ffee1", discoveries[0].MetricAnnotations["containerId"])
+}
+
+func TestMatch_GlobalService(t *testing.T) {
+	var ss []swarm.Service
+	var ns []swarm.Node
+	var ts []swarm.Task
+	require.NoError(t, json.Unmarshal([]byte(services), &ss))
+	require.NoError(t, json.Unmarshal([]byte(nodes), &ns))
+	require.NoError(t, json.Unmarshal([]byte(tasks), &ts))
+	matcher, err := discovery.NewMatcher(map[string]string{"service": "monitoring_agent"})
+	require.NoError(t, err)
+
+	matches := match(ts, ss, ns, &matcher)
+
+	// tasks of global services are named after their node, and their ports are published in the node
+	require.Len(t, matches, 1)
+	vars := matches[0].Variables
+	assert.Equal(t, "monitoring_agent.node-1", vars["discovery.name"])
+	assert.NotContains(t, vars, "discovery.slot")
+	assert.Equal(t, "8080", vars["discovery.port"])
+	assert.Equal(t, "192.168.1.10", vars["discovery.ip"])
+}
@@ -0,0 +1,678 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package jsonpath evaluates JSONPath expressions over decoded JSON documents. It supports the
+// commonly used subset of the syntax:
+//
+//	$                  the root of the document
+//	.name, ['name']    the child with the given name. Many names can be selected: ['a','b']
+//	.*, [*]            all the children
+//	..name, ..[*]      recursive descent: the matches in the node and all its descendants
+//	[0], [-1], [0,2]   array elements by index. Negative indexes count from the end
+//	[1:3], [::2]       array slices
+//	[?(expression)]    the children for which the filter expression is true, e.g.
+//	                   [?(@.port >= 1024 && @.tags.env == 'production')]
+//
+// Filter expressions compare paths relative to the current node (@) or the root ($) with literals
+// (strings, numbers, true, false and null) through ==, !=, <, <=, > and >=. A path without
+// comparison checks its existence. They can be combined through &&, || and !, and grouped in
+// parentheses.
+package jsonpath
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Path is a compiled JSONPath expression.
+type Path struct {
+	expression string
+	segments   []segment
+}
+
+// segment of a path, selecting children from the nodes matched by the previous segment.
+type segment struct {
+	// recursive segments are applied to the node and all its descendants
+	recursive bool
+	selector  selector
+}
+
+type selector interface {
+	// selects the children of a node. The root is the document, as referred from filters.
+	selectFrom(node, root interface{}) []interface{}
+}
+
+// Compile parses a JSONPath expression.
+func Compile(expression string) (*Path, error) {
+	p := &parser{input: strings.TrimSpace(expression)}
+	segments, err := p.path('$')
+	if err == nil && p.pos < len(p.input) {
+		err = p.errorf("unexpected character %q", p.input[p.pos])
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid JSONPath %q: %s", expression, err)
+	}
+	return &Path{expression: expression, segments: segments}, nil
+}
+
+// MustCompile is like Compile but panics if the expression can't be parsed.
+func MustCompile(expression string) *Path {
+	p, err := Compile(expression)
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+func (p *Path) String() string {
+	return p.expression
+}
+
+// Get returns the values matched by the path in a document, as decoded by encoding/json.
+func (p *Path) Get(document interface{}) []interface{} {
+	return evaluate(p.segments, document, document)
+}
+
+func evaluate(segments []segment, node, root interface{}) []interface{} {
+	nodes := []interface{}{node}
+	for _, s := range segments {
+		var selected []interface{}
+		for _, n := range nodes {
+			if s.recursive {
+				for _, d := range descendants(n) {
+					selected = append(selected, s.selector.selectFrom(d, root)...)
+				}
+			} else {
+				selected = append(selected, s.selector.selectFrom(n, root)...)
+			}
+		}
+		nodes = selected
+	}
+	return nodes
+}
+
+// descendants returns the node and all its descendants, in document order.
+func descendants(node interface{}) []interface{} {
+	nodes := []interface{}{node}
+	for _, child := range children(node) {
+		nodes = append(nodes, descendants(child)...)
+	}
+	return nodes
+}
+
+// children returns the elements of an array, or the values of an object sorted by key.
+func children(node interface{}) []interface{} {
+	switch n := node.(type) {
+	case []interface{}:
+		return n
+	case map[string]interface{}:
+		keys := make([]string, 0, len(n))
+		for k := range n {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		values := make([]interface{}, 0, len(n))
+		for _, k := range keys {
+			values = append(values, n[k])
+		}
+		return values
+	}
+	return nil
+}
+
+type wildcard struct{}
+
+func (wildcard) selectFrom(node, _ interface{}) []interface{} {
+	return children(node)
+}
+
+type names []string
+
+func (s names) selectFrom(node, _ interface{}) []interface{} {
+	object, ok := node.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	var selected []interface{}
+	for _, name := range s {
+		if value, ok := object[name]; ok {
+			selected = append(selected, value)
+		}
+	}
+	return selected
+}
+
+type indexes []int
+
+func (s indexes) selectFrom(node, _ interface{}) []interface{} {
+	array, ok := node.([]interface{})
+	if !ok {
+		return nil
+	}
+	var selected []interface{}
+	for _, i := range s {
+		if i < 0 {
+			i += len(array)
+		}
+		if i >= 0 && i < len(array) {
+			selected = append(selected, array[i])
+		}
+	}
+	return selected
+}
+
+type slice struct {
+	start, end *int
+	step       int
+}
+
+func (s slice) selectFrom(node, _ interface{}) []interface{} {
+	array, ok := node.([]interface{})
+	if !ok {
+		return nil
+	}
+	bound := func(i *int, def int) int {
+		if i == nil {
+			return def
+		}
+		b := *i
+		if b < 0 {
+			b += len(array)
+		}
+		if b < 0 {
+			return 0
+		}
+		if b > len(array) {
+			return len(array)
+		}
+		return b
+	}
+	var selected []interface{}
+	for i := bound(s.start, 0); i < bound(s.end, len(array)); i += s.step {
+		selected = append(selected, array[i])
+	}
+	return selected
+}
+
+type filter struct {
+	condition expression
+}
+
+func (s filter) selectFrom(node, root interface{}) []interface{} {
+	var selected []interface{}
+	for _, child := range children(node) {
+		if s.condition.eval(child, root) {
+			selected = append(selected, child)
+		}
+	}
+	return selected
+}
+
+// expression of a filter.
+type expression interface {
+	eval(current, root interface{}) bool
+}
+
+type or []expression
+
+func (e or) eval(current, root interface{}) bool {
+	for _, x := range e {
+		if x.eval(current, root) {
+			return true
+		}
+	}
+	return false
+}
+
+type and []expression
+
+func (e and) eval(current, root interface{}) bool {
+	for _, x := range e {
+		if !x.eval(current, root) {
+			return false
+		}
+	}
+	return true
+}
+
+type not struct {
+	expression
+}
+
+func (e not) eval(current, root interface{}) bool {
+	return !e.expression.eval(current, root)
+}
+
+// operand of a comparison: a path relative to the current node or the root, or a literal.
+type operand struct {
+	segments []segment
+	relative bool
+	literal  interface{}
+	isPath   bool
+}
+
+func (o operand) value(current, root interface{}) (interface{}, bool) {
+	if !o.isPath {
+		return o.literal, true
+	}
+	node := root
+	if o.relative {
+		node = current
+	}
+	values := evaluate(o.segments, node, root)
+	if len(values) == 0 {
+		return nil, false
+	}
+	return values[0], true
+}
+
+// exists is true when the path of the operand matches any value.
+type exists struct {
+	operand
+}
+
+func (e exists) eval(current, root interface{}) bool {
+	_, ok := e.value(current, root)
+	return ok
+}
+
+type comparison struct {
+	left, right operand
+	operator    string
+}
+
+func (e comparison) eval(current, root interface{}) bool {
+	left, ok := e.left.value(current, root)
+	if !ok {
+		return false
+	}
+	right, ok := e.right.value(current, root)
+	if !ok {
+		return false
+	}
+	switch e.operator {
+	case "==":
+		return equal(left, right)
+	case "!=":
+		return !equal(left, right)
+	}
+	cmp, ok := compare(left, right)
+	if !ok {
+		return false
+	}
+	switch e.operator {
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	default:
+		return cmp >= 0
+	}
+}
+
+// number returns the value as a float, if it's numeric.
+func number(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	case int:
+		return float64(v), true
+	}
+	return 0, false
+}
+
+func equal(a, b interface{}) bool {
+	if x, ok := number(a); ok {
+		y, ok := number(b)
+		return ok && x == y
+	}
+	switch a.(type) {
+	case string, bool, nil:
+		return a == b
+	}
+	return false
+}
+
+// compare returns the order of two numbers or two strings.
+func compare(a, b interface{}) (int, bool) {
+	if x, ok := number(a); ok {
+		y, ok := number(b)
+		if !ok {
+			return 0, false
+		}
+		switch {
+		case x < y:
+			return -1, true
+		case x > y:
+			return 1, true
+		}
+		return 0, true
+	}
+	x, ok := a.(string)
+	if !ok {
+		return 0, false
+	}
+	y, ok := b.(string)
+	if !ok {
+		return 0, false
+	}
+	return strings.Compare(x, y), true
+}
+
+type parser struct {
+	input string
+	pos   int
+}
+
+func (p *parser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("at position %d: %s", p.pos, fmt.Sprintf(format, args...))
+}
+
+func (p *parser) peek() byte {
+	if p.pos < len(p.input) {
+		return p.input[p.pos]
+	}
+	return 0
+}
+
+func (p *parser) skipSpaces() {
+	for p.pos < len(p.input) && (p.input[p.pos] == ' ' || p.input[p.pos] == '\t') {
+		p.pos++
+	}
+}
+
+func (p *parser) consume(token string) bool {
+	if strings.HasPrefix(p.input[p.pos:], token) {
+		p.pos += len(token)
+		return true
+	}
+	return false
+}
+
+func (p *parser) expect(token string) error {
+	p.skipSpaces()
+	if !p.consume(token) {
+		return p.errorf("expected %q", token)
+	}
+	return nil
+}
+
+// path parses a path starting with the root ($) or current node (@) symbol.
+func (p *parser) path(start byte) ([]segment, error) {
+	if p.peek() != start {
+		return nil, p.errorf("expected %q", start)
+	}
+	p.pos++
+	var segments []segment
+	for {
+		var s segment
+		switch {
+		case p.consume(".."):
+			s.recursive = true
+			if p.peek() == '[' {
+				break
+			}
+			fallthrough
+		case p.peek() == '.':
+			if !s.recursive {
+				p.pos++
+			}
+			if p.consume("*") {
+				s.selector = wildcard{}
+			} else if name := p.name(); name != "" {
+				s.selector = names{name}
+			} else {
+				return nil, p.errorf("expected a name")
+			}
+		case p.peek() == '[':
+		default:
+			return segments, nil
+		}
+		if s.selector == nil {
+			var err error
+			if s.selector, err = p.bracket(); err != nil {
+				return nil, err
+			}
+		}
+		segments = append(segments, s)
+	}
+}
+
+// name parses an unquoted child name.
+func (p *parser) name() string {
+	start := p.pos
+	for p.pos < len(p.input) && !strings.ContainsRune(".[]()=!<>&|,'\" \t", rune(p.input[p.pos])) {
+		p.pos++
+	}
+	return p.input[start:p.pos]
+}
+
+// bracket parses a [...] selector.
+func (p *parser) bracket() (selector, error) {
+	p.pos++ // [
+	p.skipSpaces()
+	var s selector
+	switch c := p.peek(); {
+	case c == '*':
+		p.pos++
+		s = wildcard{}
+	case c == '?':
+		p.pos++
+		if err := p.expect("("); err != nil {
+			return nil, err
+		}
+		condition, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		s = filter{condition: condition}
+	case c == '\'' || c == '"':
+		var list names
+		for {
+			p.skipSpaces()
+			name, err := p.quoted()
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, name)
+			p.skipSpaces()
+			if !p.consume(",") {
+				break
+			}
+		}
+		s = list
+	default:
+		var err error
+		if s, err = p.indexes(); err != nil {
+			return nil, err
+		}
+	}
+	if err := p.expect("]"); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// indexes parses a list of indexes or a slice.
+func (p *parser) indexes() (selector, error) {
+	var list indexes
+	var bounds []*int
+	for {
+		p.skipSpaces()
+		var n *int
+		if c := p.peek(); c == '-' || (c >= '0' && c <= '9') {
+			start := p.pos
+			p.pos++
+			for c := p.peek(); c >= '0' && c <= '9'; c = p.peek() {
+				p.pos++
+			}
+			i, err := strconv.Atoi(p.input[start:p.pos])
+			if err != nil {
+				return nil, p.errorf("invalid index %q", p.input[start:p.pos])
+			}
+			n = &i
+		}
+		p.skipSpaces()
+		switch {
+		case p.consume(":"):
+			if len(list) > 0 || len(bounds) == 2 {
+				return nil, p.errorf("invalid slice")
+			}
+			bounds = append(bounds, n)
+			continue
+		case len(bounds) > 0:
+			bounds = append(bounds, n)
+			s := slice{start: bounds[0], end: bounds[1], step: 1}
+			if len(bounds) == 3 && bounds[2] != nil {
+				if s.step = *bounds[2]; s.step <= 0 {
+					return nil, p.errorf("slice step must be positive")
+				}
+			}
+			return s, nil
+		case n == nil:
+			return nil, p.errorf("expected an index")
+		}
+		list = append(list, *n)
+		if !p.consume(",") {
+			return list, nil
+		}
+	}
+}
+
+// quoted parses a single or double quoted string. The quote and backslash can be escaped with a backslash.
+func (p *parser) quoted() (string, error) {
+	quote := p.peek()
+	if quote != '\'' && quote != '"' {
+		return "", p.errorf("expected a quoted string")
+	}
+	p.pos++
+	var b strings.Builder
+	for p.pos < len(p.input) {
+		c := p.input[p.pos]
+		p.pos++
+		switch {
+		case c == quote:
+			return b.String(), nil
+		case c == '\\' && p.pos < len(p.input):
+			b.WriteByte(p.input[p.pos])
+			p.pos++
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", p.errorf("unterminated string")
+}
+
+func (p *parser) or() (expression, error) {
+	var terms or
+	for {
+		term, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		terms = append(terms, term)
+		p.skipSpaces()
+		if !p.consume("||") {
+			break
+		}
+	}
+	if len(terms) == 1 {
+		return terms[0], nil
+	}
+	return terms, nil
+}
+
+func (p *parser) and() (expression, error) {
+	var terms and
+	for {
+		term, err := p.term()
+		if err != nil {
+			return nil, err
+		}
+		terms = append(terms, term)
+		p.skipSpaces()
+		if !p.consume("&&") {
+			break
+		}
+	}
+	if len(terms) == 1 {
+		return terms[0], nil
+	}
+	return terms, nil
+}
+
+func (p *parser) term() (expression, error) {
+	p.skipSpaces()
+	if p.peek() == '!' && !strings.HasPrefix(p.input[p.pos:], "!=") {
+		p.pos++
+		e, err := p.term()
+		return not{e}, err
+	}
+	if p.consume("(") {
+		e, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		return e, p.expect(")")
+	}
+
+	left, err := p.operand()
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpaces()
+	for _, operator := range []string{"==", "!=", "<=", ">=", "<", ">"} {
+		if p.consume(operator) {
+			p.skipSpaces()
+			right, err := p.operand()
+			if err != nil {
+				return nil, err
+			}
+			return comparison{left: left, right: right, operator: operator}, nil
+		}
+	}
+	if !left.isPath {
+		return nil, p.errorf("expected a comparison")
+	}
+	return exists{left}, nil
+}
+
+func (p *parser) operand() (operand, error) {
+	switch c := p.peek(); {
+	case c == '@' || c == '$':
+		segments, err := p.path(c)
+		return operand{segments: segments, relative: c == '@', isPath: true}, err
+	case c == '\'' || c == '"':
+		s, err := p.quoted()
+		return operand{literal: s}, err
+	case p.consume("true"):
+		return operand{literal: true}, nil
+	case p.consume("false"):
+		return operand{literal: false}, nil
+	case p.consume("null"):
+		return operand{literal: nil}, nil
+	case c == '-' || (c >= '0' && c <= '9'):
+		start := p.pos
+		p.pos++
+		for strings.ContainsRune("0123456789.eE+-", rune(p.peek())) {
+			p.pos++
+		}
+		f, err := strconv.ParseFloat(p.input[start:p.pos], 64)
+		if err != nil {
+			return operand{}, p.errorf("invalid number %q", p.input[start:p.pos])
+		}
+		return operand{literal: f}, nil
+	}
+	return operand{}, p.errorf("expected a path or a literal")
+}
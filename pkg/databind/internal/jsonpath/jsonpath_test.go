@@ -0,0 +1,83 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package jsonpath
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const document = `{
+  "datacenter": "eu-1",
+  "services": [
+    {"name": "redis", "address": "10.0.0.1", "port": 6379, "tags": {"env": "production"}},
+    {"name": "mysql", "address": "10.0.0.2", "port": 3306, "tags": {"env": "staging"}, "enabled": false},
+    {"name": "nginx", "address": "10.0.0.3", "port": 8080, "tags": {"env": "production", "region": "eu-1"}}
+  ]
+}`
+
+func TestPath_Get(t *testing.T) {
+	decoder := json.NewDecoder(strings.NewReader(document))
+	decoder.UseNumber()
+	var doc interface{}
+	require.NoError(t, decoder.Decode(&doc))
+
+	for expression, expected := range map[string][]interface{}{
+		"$":                                 {doc},
+		"$.datacenter":                      {"eu-1"},
+		"$['datacenter']":                   {"eu-1"},
+		"$.services[0].name":                {"redis"},
+		"$.services[-1].name":               {"nginx"},
+		"$.services[0,2].name":              {"redis", "nginx"},
+		"$.services[1:].name":               {"mysql", "nginx"},
+		"$.services[::2].name":              {"redis", "nginx"},
+		"$.services[*].port":                {json.Number("6379"), json.Number("3306"), json.Number("8080")},
+		"$.services[0]['name','port']":      {"redis", json.Number("6379")},
+		"$..region":                         {"eu-1"},
+		"$..tags.env":                       {"production", "staging", "production"},
+		"$.services[0].tags.*":              {"production"},
+		"$.services[?(@.port > 4000)].name": {"redis", "nginx"},
+		"$.services[?(@.tags.env == 'production' && @.port < 7000)].name":       {"redis"},
+		"$.services[?(@.tags.env != \"production\" || @.name == 'nginx')].name": {"mysql", "nginx"},
+		"$.services[?(@.enabled)].name":                                         {"mysql"},
+		"$.services[?(!@.enabled)].name":                                        {"redis", "nginx"},
+		"$.services[?(@.enabled == false)].name":                                {"mysql"},
+		"$.services[?(@.tags.region == $.datacenter)].name":                     {"nginx"},
+		"$.services[?((@.port == 3306 || @.port == 8080) && !@.enabled)].name":  {"nginx"},
+		"$.missing":       nil,
+		"$.services[5]":   nil,
+		"$.datacenter[0]": nil,
+	} {
+		t.Run(expression, func(t *testing.T) {
+			path, err := Compile(expression)
+			require.NoError(t, err)
+			assert.Equal(t, expected, path.Get(doc))
+		})
+	}
+}
+
+func TestCompile_Invalid(t *testing.T) {
+	for _, expression := range []string{
+		"",
+		"services",
+		"$.",
+		"$.services[",
+		"$.services[0",
+		"$['unterminated]",
+		"$.services[?(@.port >)]",
+		"$.services[?(@.port == 1]",
+		"$.services[::0]",
+		"$.services[?('literal')]",
+		"$.services]",
+	} {
+		t.Run(expression, func(t *testing.T) {
+			_, err := Compile(expression)
+			assert.Error(t, err)
+		})
+	}
+}
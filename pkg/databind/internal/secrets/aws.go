@@ -0,0 +1,72 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package secrets
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials/stscreds"
+	"github.com/aws/aws-sdk-go/aws/ec2metadata"
+	"github.com/aws/aws-sdk-go/aws/session"
+)
+
+// awsSession returns an AWS session from the passed configuration files. If no region is passed, it is
+// taken from the environment or the shared config file, or from the instance metadata as a last resort.
+func awsSession(region, credentialFile, configFile string, disableSSL bool) (*session.Session, error) {
+	var configFiles []string
+	for _, file := range []string{credentialFile, configFile} {
+		if file == "" {
+			continue
+		}
+		if _, err := os.Stat(file); err != nil {
+			slog.WithError(err).WithField("file", file).Warn("could not find aws configuration file so ignoring it")
+			continue
+		}
+		configFiles = append(configFiles, file)
+	}
+
+	cfgs := aws.NewConfig()
+	if region != "" {
+		cfgs = cfgs.WithRegion(region)
+	}
+	if disableSSL {
+		cfgs = cfgs.WithDisableSSL(disableSSL)
+	}
+	sess, err := session.NewSessionWithOptions(session.Options{
+		Config:            *cfgs,
+		SharedConfigFiles: configFiles,
+		SharedConfigState: session.SharedConfigEnable,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if aws.StringValue(sess.Config.Region) == "" {
+		region, err := ec2metadata.New(sess).Region()
+		if err != nil {
+			return nil, fmt.Errorf("no region configured and it can't be retrieved from the instance metadata: %s", err)
+		}
+		sess.Config.Region = aws.String(region)
+	}
+	return sess, nil
+}
+
+// awsClientConfig returns the configuration of a service client using the passed endpoint, and the
+// credentials of the role, if any, assumed with the session ones. The endpoint only applies to the
+// service, so the role is assumed through the regular STS endpoint.
+func awsClientConfig(sess *session.Session, endpoint, roleARN, externalID string) *aws.Config {
+	clientCfg := aws.NewConfig()
+	if endpoint != "" {
+		clientCfg = clientCfg.WithEndpoint(endpoint)
+	}
+	if roleARN != "" {
+		clientCfg = clientCfg.WithCredentials(stscreds.NewCredentials(sess, roleARN, func(p *stscreds.AssumeRoleProvider) {
+			if externalID != "" {
+				p.ExternalID = aws.String(externalID)
+			}
+		}))
+	}
+	return clientCfg
+}
@@ -0,0 +1,142 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package secrets
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+const (
+	azureKeyVaultAPIVersion = "7.1"
+	azureKeyVaultResource   = "https://vault.azure.net"
+)
+
+// token endpoints of the managed identities (through the Azure instance metadata service) and of the
+// Azure AD tenants. Overridden in tests.
+var (
+	azureIMDSTokenURL = "http://169.254.169.254/metadata/identity/oauth2/token"
+	azureLoginURL     = "https://login.microsoftonline.com"
+)
+
+// AzureKeyVault defines the Azure Key Vault data source
+type AzureKeyVault struct {
+	VaultURL string `yaml:"vault_url"`         // e.g. https://my-vault.vault.azure.net
+	Secret   string `yaml:"secret"`            // name of the secret
+	Version  string `yaml:"version,omitempty"` // latest by default
+	Type     string `yaml:"type,omitempty"`    // can be 'json', 'equal' and 'plain' (default)
+	// service principal credentials. Without them, the managed identity of the Azure VM is used
+	TenantID     string `yaml:"tenant_id,omitempty"`
+	ClientID     string `yaml:"client_id,omitempty"` // also selects a user-assigned managed identity
+	ClientSecret string `yaml:"client_secret,omitempty"`
+}
+
+type azureKeyVaultGatherer struct {
+	cfg *AzureKeyVault
+}
+
+// AzureKeyVaultGatherer instantiates an Azure Key Vault variable gatherer from the given configuration.
+// The fetching process returns the value of the secret decoded according to its type. E.g. if the
+// stored secret is `{"username":"admin","password":"s3cr3t"}`, with the json type the returned Map
+// contents will be:
+// "username" -> "admin"
+// "password" -> "s3cr3t"
+// The vault is accessed with the service principal credentials if they are configured, or with the
+// managed identity of the Azure VM otherwise.
+func AzureKeyVaultGatherer(kv *AzureKeyVault) func() (interface{}, error) {
+	g := azureKeyVaultGatherer{cfg: kv}
+	return func() (interface{}, error) {
+		dt, err := g.get()
+		if err != nil {
+			return "", err
+		}
+		return dt, err
+	}
+}
+
+// Validate checks if the Azure Key Vault configuration is correct
+func (kv *AzureKeyVault) Validate() error {
+	if kv.VaultURL == "" {
+		return errors.New("azure-key-vault must have a vault_url parameter")
+	}
+	if u, err := url.Parse(kv.VaultURL); err != nil || u.Host == "" {
+		return fmt.Errorf("azure-key-vault vault_url must be an absolute URL: %q", kv.VaultURL)
+	}
+	if kv.Secret == "" {
+		return errors.New("azure-key-vault must have a secret parameter with the secret name")
+	}
+	if kv.Type != "" && kv.Type != typeJson && kv.Type != typeEqual && kv.Type != typePlain {
+		return errors.New("type can be only " + typePlain + ", " + typeJson + " or " + typeEqual)
+	}
+	if (kv.TenantID != "" || kv.ClientSecret != "") && (kv.TenantID == "" || kv.ClientID == "" || kv.ClientSecret == "") {
+		return errors.New("azure-key-vault service principal requires tenant_id, client_id and client_secret")
+	}
+	return nil
+}
+
+func (g *azureKeyVaultGatherer) get() (interface{}, error) {
+	token, err := g.token()
+	if err != nil {
+		return nil, fmt.Errorf("unable to authenticate to azure-key-vault: %s", err)
+	}
+	secretURL := strings.TrimSuffix(g.cfg.VaultURL, "/") + "/secrets/" + url.PathEscape(g.cfg.Secret)
+	if g.cfg.Version != "" {
+		secretURL += "/" + url.PathEscape(g.cfg.Version)
+	}
+	body, err := httpRequest(&http{
+		URL:     secretURL + "?api-version=" + azureKeyVaultAPIVersion,
+		Headers: map[string]string{"Authorization": "Bearer " + token},
+	}, "GET", nil)
+	if err != nil {
+		return nil, fmt.Errorf("unable to retrieve secret %q from azure-key-vault: %s", g.cfg.Secret, err)
+	}
+	var secret struct {
+		Value *string `json:"value"`
+	}
+	if err := json.Unmarshal(body, &secret); err != nil || secret.Value == nil {
+		return nil, fmt.Errorf("azure-key-vault returned an unexpected format for secret %q", g.cfg.Secret)
+	}
+	return handleDataType([]byte(*secret.Value), g.cfg.Type)
+}
+
+// token returns an access token for Key Vault from the service principal credentials, if configured,
+// or from the managed identity.
+func (g *azureKeyVaultGatherer) token() (string, error) {
+	var body []byte
+	var err error
+	if g.cfg.ClientSecret != "" {
+		form := url.Values{
+			"grant_type":    {"client_credentials"},
+			"client_id":     {g.cfg.ClientID},
+			"client_secret": {g.cfg.ClientSecret},
+			"scope":         {azureKeyVaultResource + "/.default"},
+		}
+		body, err = httpRequest(&http{
+			URL:     azureLoginURL + "/" + url.PathEscape(g.cfg.TenantID) + "/oauth2/v2.0/token",
+			Headers: map[string]string{"Content-Type": "application/x-www-form-urlencoded"},
+		}, "POST", strings.NewReader(form.Encode()))
+	} else {
+		query := url.Values{"api-version": {"2018-02-01"}, "resource": {azureKeyVaultResource}}
+		if g.cfg.ClientID != "" {
+			query.Set("client_id", g.cfg.ClientID)
+		}
+		body, err = httpRequest(&http{
+			URL:     azureIMDSTokenURL + "?" + query.Encode(),
+			Headers: map[string]string{"Metadata": "true"},
+		}, "GET", nil)
+	}
+	if err != nil {
+		return "", err
+	}
+	var token struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.Unmarshal(body, &token); err != nil || token.AccessToken == "" {
+		return "", errors.New("no access token returned")
+	}
+	return token.AccessToken, nil
+}
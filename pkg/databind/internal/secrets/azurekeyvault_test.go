@@ -0,0 +1,95 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package secrets
+
+import (
+	nethttp "net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/newrelic/infrastructure-agent/pkg/databind/pkg/data"
+)
+
+func TestAzureKeyVault(t *testing.T) {
+	// GIVEN an Azure AD tenant, a managed identity endpoint and a Key Vault storing a JSON secret
+	var authorizations []string
+	server := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		switch r.URL.Path {
+		case "/identity/oauth2/token":
+			assert.Equal(t, "true", r.Header.Get("Metadata"))
+			assert.Equal(t, "https://vault.azure.net", r.URL.Query().Get("resource"))
+			_, _ = w.Write([]byte(`{"access_token": "identity-token"}`))
+		case "/tenant-1/oauth2/v2.0/token":
+			require.NoError(t, r.ParseForm())
+			if r.PostForm.Get("client_id") != "app-1" || r.PostForm.Get("client_secret") != "app-secret" {
+				w.WriteHeader(nethttp.StatusUnauthorized)
+				return
+			}
+			assert.Equal(t, "client_credentials", r.PostForm.Get("grant_type"))
+			_, _ = w.Write([]byte(`{"access_token": "app-token"}`))
+		case "/secrets/mysql", "/secrets/mysql/a1b2c3":
+			assert.Equal(t, "7.1", r.URL.Query().Get("api-version"))
+			authorizations = append(authorizations, r.Header.Get("Authorization"))
+			_, _ = w.Write([]byte(`{"value": "{\"username\":\"admin\",\"password\":\"s3cr3t\"}", "id": "https://vault/secrets/mysql/a1b2c3"}`))
+		default:
+			w.WriteHeader(nethttp.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	defer func(imds, login string) {
+		azureIMDSTokenURL, azureLoginURL = imds, login
+	}(azureIMDSTokenURL, azureLoginURL)
+	azureIMDSTokenURL, azureLoginURL = server.URL+"/identity/oauth2/token", server.URL
+
+	t.Run("managed identity", func(t *testing.T) {
+		authorizations = nil
+		cfg := AzureKeyVault{VaultURL: server.URL, Secret: "mysql", Type: typeJson}
+		require.NoError(t, cfg.Validate())
+
+		// WHEN the secret is gathered with the managed identity
+		value, err := AzureKeyVaultGatherer(&cfg)()
+
+		// THEN it is returned according to its type
+		require.NoError(t, err)
+		assert.Equal(t, data.InterfaceMap{"username": "admin", "password": "s3cr3t"}, value)
+		assert.Equal(t, []string{"Bearer identity-token"}, authorizations)
+	})
+
+	t.Run("service principal", func(t *testing.T) {
+		authorizations = nil
+		cfg := AzureKeyVault{VaultURL: server.URL + "/", Secret: "mysql", Version: "a1b2c3",
+			TenantID: "tenant-1", ClientID: "app-1", ClientSecret: "app-secret"}
+		require.NoError(t, cfg.Validate())
+
+		// WHEN a version of the secret is gathered with a service principal
+		value, err := AzureKeyVaultGatherer(&cfg)()
+
+		// THEN its plain value is returned
+		require.NoError(t, err)
+		assert.Equal(t, `{"username":"admin","password":"s3cr3t"}`, value)
+		assert.Equal(t, []string{"Bearer app-token"}, authorizations)
+	})
+
+	t.Run("invalid credentials", func(t *testing.T) {
+		cfg := AzureKeyVault{VaultURL: server.URL, Secret: "mysql", TenantID: "tenant-1", ClientID: "app-1", ClientSecret: "wrong"}
+		_, err := AzureKeyVaultGatherer(&cfg)()
+		assert.Error(t, err)
+	})
+
+	t.Run("missing secret", func(t *testing.T) {
+		_, err := AzureKeyVaultGatherer(&AzureKeyVault{VaultURL: server.URL, Secret: "other"})()
+		assert.Error(t, err)
+	})
+}
+
+func TestAzureKeyVault_Validate(t *testing.T) {
+	assert.Error(t, (&AzureKeyVault{Secret: "mysql"}).Validate())
+	assert.Error(t, (&AzureKeyVault{VaultURL: "my-vault", Secret: "mysql"}).Validate())
+	assert.Error(t, (&AzureKeyVault{VaultURL: "https://my-vault.vault.azure.net"}).Validate())
+	assert.Error(t, (&AzureKeyVault{VaultURL: "https://my-vault.vault.azure.net", Secret: "mysql", TenantID: "t", ClientSecret: "s"}).Validate())
+	assert.NoError(t, (&AzureKeyVault{VaultURL: "https://my-vault.vault.azure.net", Secret: "mysql", ClientID: "identity"}).Validate())
+}
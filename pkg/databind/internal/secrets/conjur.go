@@ -0,0 +1,121 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package secrets
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"strings"
+)
+
+const defaultConjurAuthenticator = "authn"
+
+// Conjur defines the CyberArk Conjur data source
+type Conjur struct {
+	URL      string `yaml:"url"`            // appliance URL, e.g. https://conjur.example.com
+	Account  string `yaml:"account"`        // organization account
+	Login    string `yaml:"login"`          // host identity, e.g. host/infra/agent-01
+	Variable string `yaml:"variable"`       // variable ID, e.g. prod/mysql/password
+	Type     string `yaml:"type,omitempty"` // can be 'json', 'equal' and 'plain' (default)
+	// API key of the host identity, for the default authenticator
+	APIKey     string `yaml:"api_key,omitempty"`
+	APIKeyFile string `yaml:"api_key_file,omitempty"`
+	// authenticator and service ID, e.g. authn-k8s/prod. With a client certificate in the tls_config, the
+	// host is authenticated by it instead of by an API key
+	Authenticator string    `yaml:"authenticator,omitempty"`
+	TLSConfig     tlsConfig `yaml:"tls_config"`
+}
+
+type conjurGatherer struct {
+	cfg *Conjur
+}
+
+// ConjurGatherer instantiates a CyberArk Conjur variable gatherer from the given configuration. The
+// fetching process authenticates the host identity and returns the value of the variable decoded
+// according to its type.
+func ConjurGatherer(c *Conjur) func() (interface{}, error) {
+	g := conjurGatherer{cfg: c}
+	return func() (interface{}, error) {
+		dt, err := g.get()
+		if err != nil {
+			return "", err
+		}
+		return dt, err
+	}
+}
+
+// Validate checks if the Conjur configuration is correct
+func (c *Conjur) Validate() error {
+	if u, err := url.Parse(c.URL); c.URL == "" || err != nil || u.Host == "" {
+		return errors.New("conjur must have an absolute url parameter")
+	}
+	if c.Account == "" || c.Login == "" || c.Variable == "" {
+		return errors.New("conjur must have account, login and variable parameters")
+	}
+	if c.Type != "" && c.Type != typeJson && c.Type != typeEqual && c.Type != typePlain {
+		return errors.New("type can be only " + typePlain + ", " + typeJson + " or " + typeEqual)
+	}
+	certAuthn := c.TLSConfig.CertFile != ""
+	if certAuthn && c.TLSConfig.KeyFile == "" {
+		return errors.New("conjur tls_config cert_file requires a key_file")
+	}
+	if c.APIKey != "" && c.APIKeyFile != "" {
+		return errors.New("conjur api_key and api_key_file are mutually exclusive")
+	}
+	if !certAuthn && c.APIKey == "" && c.APIKeyFile == "" {
+		return errors.New("conjur must have an api_key, an api_key_file or a client certificate in tls_config")
+	}
+	if certAuthn && c.Authenticator == "" {
+		return errors.New("conjur certificate authentication requires an authenticator, e.g. authn-k8s/<service-id>")
+	}
+	return nil
+}
+
+func (g *conjurGatherer) get() (interface{}, error) {
+	token, err := g.authenticate()
+	if err != nil {
+		return nil, fmt.Errorf("unable to authenticate to conjur as %q: %s", g.cfg.Login, err)
+	}
+	value, err := httpRequest(&http{
+		URL:       g.baseURL() + "/secrets/" + url.PathEscape(g.cfg.Account) + "/variable/" + url.PathEscape(g.cfg.Variable),
+		TLSConfig: g.cfg.TLSConfig,
+		Headers:   map[string]string{"Authorization": `Token token="` + base64.StdEncoding.EncodeToString(token) + `"`},
+	}, "GET", nil)
+	if err != nil {
+		return nil, fmt.Errorf("unable to retrieve variable %q from conjur: %s", g.cfg.Variable, err)
+	}
+	return handleDataType(value, g.cfg.Type)
+}
+
+// authenticate returns the short-lived access token of the host identity, sending its API key or, with
+// certificate authentication, through the mutual TLS connection.
+func (g *conjurGatherer) authenticate() ([]byte, error) {
+	var body string
+	if g.cfg.TLSConfig.CertFile == "" {
+		body = g.cfg.APIKey
+		if g.cfg.APIKeyFile != "" {
+			apiKey, err := ioutil.ReadFile(g.cfg.APIKeyFile)
+			if err != nil {
+				return nil, fmt.Errorf("unable to read api_key_file: %s", err)
+			}
+			body = strings.TrimSpace(string(apiKey))
+		}
+	}
+	authenticator := strings.Trim(g.cfg.Authenticator, "/")
+	if authenticator == "" {
+		authenticator = defaultConjurAuthenticator
+	}
+	return httpRequest(&http{
+		URL: g.baseURL() + "/" + authenticator + "/" + url.PathEscape(g.cfg.Account) + "/" +
+			url.PathEscape(g.cfg.Login) + "/authenticate",
+		TLSConfig: g.cfg.TLSConfig,
+	}, "POST", strings.NewReader(body))
+}
+
+func (g *conjurGatherer) baseURL() string {
+	return strings.TrimSuffix(g.cfg.URL, "/")
+}
@@ -0,0 +1,153 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package secrets
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	nethttp "net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/newrelic/infrastructure-agent/pkg/databind/pkg/data"
+)
+
+// conjurHandler mocks the Conjur authentication and secrets endpoints. Hosts authenticated by a client
+// certificate don't need an API key.
+func conjurHandler(t *testing.T) nethttp.HandlerFunc {
+	accessToken := `{"protected":"eyJhbGciOiJjb25qdXIub3JnL3Nsb3NpbG8vdjIifQ==","payload":"host","signature":"sig"}`
+	return func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		switch r.URL.EscapedPath() {
+		case "/authn/myorg/host%2Finfra%2Fagent/authenticate":
+			body, _ := ioutil.ReadAll(r.Body)
+			if string(body) != "api-key-1" {
+				w.WriteHeader(nethttp.StatusUnauthorized)
+				return
+			}
+			_, _ = w.Write([]byte(accessToken))
+		case "/authn-cert/prod/myorg/host%2Finfra%2Fagent/authenticate":
+			if r.TLS == nil || len(r.TLS.PeerCertificates) == 0 {
+				w.WriteHeader(nethttp.StatusUnauthorized)
+				return
+			}
+			_, _ = w.Write([]byte(accessToken))
+		case "/secrets/myorg/variable/prod%2Fmysql":
+			expected := `Token token="` + base64.StdEncoding.EncodeToString([]byte(accessToken)) + `"`
+			if r.Header.Get("Authorization") != expected {
+				w.WriteHeader(nethttp.StatusUnauthorized)
+				return
+			}
+			_, _ = w.Write([]byte(`{"username":"admin","password":"s3cr3t"}`))
+		default:
+			w.WriteHeader(nethttp.StatusNotFound)
+		}
+	}
+}
+
+func TestConjur_APIKey(t *testing.T) {
+	// GIVEN a Conjur server
+	server := httptest.NewServer(conjurHandler(t))
+	defer server.Close()
+	cfg := Conjur{URL: server.URL, Account: "myorg", Login: "host/infra/agent", Variable: "prod/mysql",
+		Type: typeJson, APIKey: "api-key-1"}
+	require.NoError(t, cfg.Validate())
+
+	// WHEN a variable is gathered with the API key of the host
+	value, err := ConjurGatherer(&cfg)()
+
+	// THEN its value is returned according to its type
+	require.NoError(t, err)
+	assert.Equal(t, data.InterfaceMap{"username": "admin", "password": "s3cr3t"}, value)
+
+	// AND invalid API keys are rejected
+	cfg.APIKey = "wrong"
+	_, err = ConjurGatherer(&cfg)()
+	assert.Error(t, err)
+}
+
+func TestConjur_Certificate(t *testing.T) {
+	// GIVEN a Conjur server requesting client certificates
+	server := httptest.NewUnstartedServer(conjurHandler(t))
+	server.TLS = &tls.Config{ClientAuth: tls.RequestClientCert}
+	server.StartTLS()
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "conjur")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	caFile := filepath.Join(dir, "ca.pem")
+	require.NoError(t, ioutil.WriteFile(caFile,
+		pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}), 0600))
+	certFile, keyFile := writeClientCertificate(t, dir)
+
+	cfg := Conjur{URL: server.URL, Account: "myorg", Login: "host/infra/agent", Variable: "prod/mysql",
+		Authenticator: "authn-cert/prod", TLSConfig: tlsConfig{Ca: caFile, CertFile: certFile, KeyFile: keyFile}}
+	require.NoError(t, cfg.Validate())
+
+	// WHEN a variable is gathered authenticating the host by its certificate
+	value, err := ConjurGatherer(&cfg)()
+
+	// THEN its plain value is returned
+	require.NoError(t, err)
+	assert.Equal(t, `{"username":"admin","password":"s3cr3t"}`, value)
+}
+
+func TestConjur_Validate(t *testing.T) {
+	valid := func() Conjur {
+		return Conjur{URL: "https://conjur", Account: "myorg", Login: "host/agent", Variable: "db/pass", APIKey: "key"}
+	}
+	c := valid()
+	assert.NoError(t, c.Validate())
+
+	c = valid()
+	c.URL = "conjur"
+	assert.Error(t, c.Validate())
+	c = valid()
+	c.Variable = ""
+	assert.Error(t, c.Validate())
+	c = valid()
+	c.APIKey = ""
+	assert.Error(t, c.Validate())
+	c = valid()
+	c.APIKeyFile = "/etc/conjur-key"
+	assert.Error(t, c.Validate())
+	c = valid()
+	c.APIKey, c.TLSConfig.CertFile, c.TLSConfig.KeyFile = "", "cert.pem", "key.pem"
+	assert.Error(t, c.Validate(), "certificate authentication requires an authenticator")
+	c.Authenticator = "authn-k8s/prod"
+	assert.NoError(t, c.Validate())
+}
+
+func writeClientCertificate(t *testing.T, dir string) (certFile, keyFile string) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "host/infra/agent"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	certFile, keyFile = filepath.Join(dir, "client.pem"), filepath.Join(dir, "client-key.pem")
+	require.NoError(t, ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	require.NoError(t, ioutil.WriteFile(keyFile,
+		pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}), 0600))
+	return certFile, keyFile
+}
@@ -0,0 +1,141 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package secrets
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/newrelic/infrastructure-agent/pkg/databind/pkg/data"
+)
+
+// EncryptedFile defines a local YAML or JSON secrets file encrypted with NaCl secretbox. The file holds,
+// either raw or base64-encoded, the 24-byte nonce followed by the box.
+type EncryptedFile struct {
+	File     string   `yaml:"file"`
+	KeyEnv   string   `yaml:"key_env"`  // environment variable holding the key
+	Keystore *Keyring `yaml:"keystore"` // OS keyring credential holding the key
+}
+
+type encryptedFileGatherer struct {
+	cfg *EncryptedFile
+}
+
+// EncryptedFileGatherer instantiates an encrypted file variable gatherer from the given configuration.
+// The decrypted document is returned as a map, whose nested values can be accessed with the
+// `variable.key.subkey` or `variable.list[0]` syntax.
+func EncryptedFileGatherer(file *EncryptedFile) func() (interface{}, error) {
+	g := encryptedFileGatherer{cfg: file}
+	return func() (interface{}, error) {
+		dt, err := g.get()
+		if err != nil {
+			return "", err
+		}
+		return dt, err
+	}
+}
+
+// Validate checks if the encrypted file configuration is correct
+func (f *EncryptedFile) Validate() error {
+	if f.File == "" {
+		return errors.New("encrypted-file secrets must have a file in order to be set")
+	}
+	if (f.KeyEnv == "") == (f.Keystore == nil) {
+		return errors.New("encrypted-file secrets must have either a key_env or a keystore parameter")
+	}
+	if f.Keystore != nil {
+		return f.Keystore.Validate()
+	}
+	return nil
+}
+
+func (g *encryptedFileGatherer) get() (data.InterfaceMap, error) {
+	key, err := g.key()
+	if err != nil {
+		return nil, err
+	}
+
+	content, err := ioutil.ReadFile(g.cfg.File)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read encrypted secrets file '%s': %s", g.cfg.File, err)
+	}
+	if decoded, err := base64.StdEncoding.DecodeString(string(bytes.TrimSpace(content))); err == nil {
+		content = decoded
+	}
+	if len(content) < secretboxNonceSize+secretboxOverhead {
+		return nil, fmt.Errorf("encrypted secrets file '%s' is too short", g.cfg.File)
+	}
+	var nonce [secretboxNonceSize]byte
+	copy(nonce[:], content)
+	plain, err := secretboxOpen(content[secretboxNonceSize:], &nonce, key)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decrypt secrets file '%s': %s", g.cfg.File, err)
+	}
+
+	// YAML is a superset of JSON, so both formats are accepted
+	var doc map[string]interface{}
+	if err := yaml.Unmarshal(plain, &doc); err != nil {
+		return nil, fmt.Errorf("unable to parse decrypted secrets file '%s': %s", g.cfg.File, err)
+	}
+	result := data.InterfaceMap{}
+	for k, v := range doc {
+		result[k] = stringKeys(v)
+	}
+	return result, nil
+}
+
+// key returns the secretbox key, encoded either in base64 or hex in its source.
+func (g *encryptedFileGatherer) key() (*[secretboxKeySize]byte, error) {
+	var encoded string
+	if g.cfg.KeyEnv != "" {
+		encoded = os.Getenv(g.cfg.KeyEnv)
+		if encoded == "" {
+			return nil, fmt.Errorf("environment variable %s, holding the secrets file key, is not set", g.cfg.KeyEnv)
+		}
+	} else {
+		var err error
+		if _, encoded, err = g.cfg.Keystore.lookup(); err != nil {
+			return nil, err
+		}
+	}
+	encoded = strings.TrimSpace(encoded)
+
+	raw, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(raw) != secretboxKeySize {
+		raw, err = hex.DecodeString(encoded)
+	}
+	if err != nil || len(raw) != secretboxKeySize {
+		return nil, fmt.Errorf("the secrets file key must be %d bytes, encoded in base64 or hex", secretboxKeySize)
+	}
+	var key [secretboxKeySize]byte
+	copy(key[:], raw)
+	return &key, nil
+}
+
+// stringKeys converts the nested maps decoded from YAML into string keyed maps.
+func stringKeys(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[interface{}]interface{}:
+		converted := make(map[string]interface{}, len(v))
+		for key, val := range v {
+			converted[fmt.Sprint(key)] = stringKeys(val)
+		}
+		return converted
+	case []interface{}:
+		for i := range v {
+			v[i] = stringKeys(v[i])
+		}
+		return v
+	default:
+		return v
+	}
+}
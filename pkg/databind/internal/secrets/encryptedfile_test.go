@@ -0,0 +1,139 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package secrets
+
+import (
+	"encoding/base64"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/newrelic/infrastructure-agent/pkg/databind/pkg/data"
+)
+
+// sealed with libsodium's crypto_secretbox_easy, prefixed by their nonce
+const (
+	testSecretsKey = "AAECAwQFBgcICQoLDA0ODxAREhMUFRYXGBkaGxwdHh8="
+	// password: s3cr3t
+	// db:
+	//   user: admin
+	//   hosts: [a, b]
+	testSecretsYAML = "ZGVmZ2hpamtsbW5vcHFyc3R1dnd4eXp77wwthE2OrvC5VSnFlPUmBXLY6rpN2byNit1Qp1T2kGw0zlqd7oZrbtVpMK4q6q3lwQH+OG9f9JeBZS1CW3LxRaPNpA=="
+	// {"token":"abc"} repeated 10 times, spanning several Salsa20 blocks
+	testSecretsLong = "ZGVmZ2hpamtsbW5vcHFyc3R1dnd4eXp7bG15qcXdKXeDHrIfRXHWnXmb7aZR06DLit9C9lSm3mMc3lfMgchpIYRtIPco9rKq3ACffSEVocaUdHRAfWj/Ea77y3YwvBs+YYJxeaCQ6gso7iJP8h4p6NqtYolit4L4MfmvPYWeGWGw5vIFDImOo5Tr01mOu1nlfgBd/9M38FZaFbQrtUhidDuuesyPdLgehCJselTsYHJvV0RTuKfCb0FKlHwPWg=="
+)
+
+func writeSecretsFile(t *testing.T, content []byte) string {
+	f, err := ioutil.TempFile("", "secrets")
+	require.NoError(t, err)
+	_, err = f.Write(content)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	return f.Name()
+}
+
+// the boxes sealed by libsodium are opened by golang.org/x/crypto/nacl/secretbox
+func TestSecretboxOpen_Libsodium(t *testing.T) {
+	var key [secretboxKeySize]byte
+	for i := range key {
+		key[i] = byte(i)
+	}
+	box, err := base64.StdEncoding.DecodeString(testSecretsLong)
+	require.NoError(t, err)
+	var nonce [secretboxNonceSize]byte
+	copy(nonce[:], box)
+
+	plain, err := secretboxOpen(box[secretboxNonceSize:], &nonce, &key)
+	require.NoError(t, err)
+	expected := ""
+	for i := 0; i < 10; i++ {
+		expected += `{"token":"abc"}`
+	}
+	assert.Equal(t, expected, string(plain))
+
+	// tampered data is rejected
+	box[len(box)-1] ^= 1
+	_, err = secretboxOpen(box[secretboxNonceSize:], &nonce, &key)
+	assert.Equal(t, errSecretboxAuth, err)
+}
+
+func TestEncryptedFile_KeyEnv(t *testing.T) {
+	// GIVEN an encrypted YAML file, stored as base64
+	file := writeSecretsFile(t, []byte(testSecretsYAML+"\n"))
+	defer os.Remove(file)
+	// AND its key in an environment variable
+	require.NoError(t, os.Setenv("NRIA_TEST_SECRETS_KEY", testSecretsKey))
+	defer os.Unsetenv("NRIA_TEST_SECRETS_KEY")
+
+	// WHEN the secrets are gathered
+	cfg := &EncryptedFile{File: file, KeyEnv: "NRIA_TEST_SECRETS_KEY"}
+	require.NoError(t, cfg.Validate())
+	result, err := EncryptedFileGatherer(cfg)()
+	require.NoError(t, err)
+
+	// THEN the decrypted document is returned, including its nested values
+	flattened := data.Map{}
+	data.AddValues(flattened, "creds", result)
+	assert.Equal(t, data.Map{
+		"creds.password":    "s3cr3t",
+		"creds.db.user":     "admin",
+		"creds.db.hosts[0]": "a",
+		"creds.db.hosts[1]": "b",
+	}, flattened)
+}
+
+func TestEncryptedFile_Keystore(t *testing.T) {
+	if runtime.GOOS != "linux" && runtime.GOOS != "darwin" {
+		t.Skip("the keyring is looked up through an API")
+	}
+	// GIVEN a raw encrypted file
+	raw, err := base64.StdEncoding.DecodeString(testSecretsLong)
+	require.NoError(t, err)
+	file := writeSecretsFile(t, raw)
+	defer os.Remove(file)
+	// AND a keystore holding its key in hex
+	var invoked []string
+	keyringExecCommand = func(name string, args ...string) *exec.Cmd {
+		invoked = append([]string{name}, args...)
+		return exec.Command("echo", "000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f")
+	}
+	defer func() { keyringExecCommand = exec.Command }()
+
+	// WHEN the secrets are gathered
+	cfg := &EncryptedFile{File: file, Keystore: &Keyring{Service: "newrelic-infra", Account: "secrets"}}
+	require.NoError(t, cfg.Validate())
+	result, err := EncryptedFileGatherer(cfg)()
+
+	// THEN the key is looked up by its service and account
+	require.NoError(t, err)
+	assert.Contains(t, invoked, "newrelic-infra")
+	assert.Contains(t, invoked, "secrets")
+	assert.Equal(t, "abc", result.(data.InterfaceMap)["token"])
+}
+
+func TestEncryptedFile_Errors(t *testing.T) {
+	file := writeSecretsFile(t, []byte(testSecretsYAML))
+	defer os.Remove(file)
+	defer os.Unsetenv("NRIA_TEST_SECRETS_KEY")
+
+	// missing key
+	_, err := EncryptedFileGatherer(&EncryptedFile{File: file, KeyEnv: "NRIA_TEST_SECRETS_KEY"})()
+	assert.Error(t, err)
+
+	// wrong key
+	require.NoError(t, os.Setenv("NRIA_TEST_SECRETS_KEY", base64.StdEncoding.EncodeToString(make([]byte, 32))))
+	_, err = EncryptedFileGatherer(&EncryptedFile{File: file, KeyEnv: "NRIA_TEST_SECRETS_KEY"})()
+	assert.Error(t, err)
+
+	// invalid configurations
+	assert.Error(t, (&EncryptedFile{KeyEnv: "KEY"}).Validate())
+	assert.Error(t, (&EncryptedFile{File: file}).Validate())
+	assert.Error(t, (&EncryptedFile{File: file, KeyEnv: "KEY", Keystore: &Keyring{Service: "s", Account: "a"}}).Validate())
+	assert.Error(t, (&EncryptedFile{File: file, Keystore: &Keyring{Account: "a"}}).Validate())
+}
@@ -0,0 +1,231 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package secrets
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	gcpCloudPlatformScope = "https://www.googleapis.com/auth/cloud-platform"
+	gcpDefaultTokenURI    = "https://oauth2.googleapis.com/token"
+	gcpJWTBearerGrant     = "urn:ietf:params:oauth:grant-type:jwt-bearer"
+)
+
+// access token endpoint of the workload identity (through the GCE/GKE metadata server) and Secret
+// Manager API. Overridden in tests.
+var (
+	gcpMetadataTokenURL = "http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/token"
+	gcpSecretManagerURL = "https://secretmanager.googleapis.com/v1"
+)
+
+// GCPSecretManager defines the Google Cloud Secret Manager data source
+type GCPSecretManager struct {
+	Project string `yaml:"project,omitempty"` // project ID or number, optional if secret is a resource name
+	Secret  string `yaml:"secret"`            // secret ID, or resource name as projects/<p>/secrets/<s>[/versions/<v>]
+	Version string `yaml:"version,omitempty"` // latest by default
+	Type    string `yaml:"type,omitempty"`    // can be 'json' (default), 'equal' and 'plain'
+	// service account key file. Without it, GOOGLE_APPLICATION_CREDENTIALS or the workload identity is used
+	CredentialFile string `yaml:"credential_file,omitempty"`
+}
+
+type gcpSecretManagerGatherer struct {
+	cfg *GCPSecretManager
+}
+
+type gcpServiceAccountKey struct {
+	Type         string `json:"type"`
+	ClientEmail  string `json:"client_email"`
+	PrivateKey   string `json:"private_key"`
+	PrivateKeyID string `json:"private_key_id"`
+	TokenURI     string `json:"token_uri"`
+}
+
+// GCPSecretManagerGatherer instantiates a Google Cloud Secret Manager variable gatherer from the given
+// configuration. As for Vault, JSON secrets are returned as a Map with the access paths to their fields.
+// E.g. if the stored secret is `{"username":"admin","password":"s3cr3t"}`, the returned Map contents will be:
+// "username" -> "admin"
+// "password" -> "s3cr3t"
+// Other formats are selected with the secret type.
+func GCPSecretManagerGatherer(sm *GCPSecretManager) func() (interface{}, error) {
+	g := gcpSecretManagerGatherer{cfg: sm}
+	return func() (interface{}, error) {
+		dt, err := g.get()
+		if err != nil {
+			return "", err
+		}
+		return dt, err
+	}
+}
+
+// Validate checks if the Google Cloud Secret Manager configuration is correct
+func (sm *GCPSecretManager) Validate() error {
+	if sm.Secret == "" {
+		return errors.New("gcp-secret-manager must have a secret parameter with the secret name")
+	}
+	if strings.HasPrefix(sm.Secret, "projects/") {
+		if sm.Project != "" {
+			return errors.New("gcp-secret-manager project can't be set when secret is a resource name")
+		}
+		if sm.Version != "" && strings.Contains(sm.Secret, "/versions/") {
+			return errors.New("gcp-secret-manager version is already set in the secret resource name")
+		}
+	} else if sm.Project == "" {
+		return errors.New("gcp-secret-manager must have a project parameter")
+	}
+	if sm.Type != "" && sm.Type != typeJson && sm.Type != typeEqual && sm.Type != typePlain {
+		return errors.New("type can be only " + typePlain + ", " + typeJson + " or " + typeEqual)
+	}
+	return nil
+}
+
+// resourceName returns the name of the secret version to access.
+func (sm *GCPSecretManager) resourceName() string {
+	name := sm.Secret
+	if !strings.HasPrefix(name, "projects/") {
+		name = "projects/" + url.PathEscape(sm.Project) + "/secrets/" + url.PathEscape(sm.Secret)
+	}
+	if strings.Contains(name, "/versions/") {
+		return name
+	}
+	version := sm.Version
+	if version == "" {
+		version = "latest"
+	}
+	return name + "/versions/" + url.PathEscape(version)
+}
+
+func (g *gcpSecretManagerGatherer) get() (interface{}, error) {
+	token, err := g.token()
+	if err != nil {
+		return nil, fmt.Errorf("unable to authenticate to gcp-secret-manager: %s", err)
+	}
+	body, err := httpRequest(&http{
+		URL:     gcpSecretManagerURL + "/" + g.cfg.resourceName() + ":access",
+		Headers: map[string]string{"Authorization": "Bearer " + token},
+	}, "GET", nil)
+	if err != nil {
+		return nil, fmt.Errorf("unable to retrieve secret %q from gcp-secret-manager: %s", g.cfg.Secret, err)
+	}
+	var version struct {
+		Payload struct {
+			Data string `json:"data"`
+		} `json:"payload"`
+	}
+	if err := json.Unmarshal(body, &version); err != nil {
+		return nil, fmt.Errorf("gcp-secret-manager returned an unexpected format for secret %q", g.cfg.Secret)
+	}
+	payload, err := base64.StdEncoding.DecodeString(version.Payload.Data)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decode gcp-secret-manager secret %q: %s", g.cfg.Secret, err)
+	}
+	secretType := g.cfg.Type
+	if secretType == "" {
+		secretType = typeJson
+	}
+	return handleDataType(payload, secretType)
+}
+
+// token returns an access token from the service account key, if any, or from the workload identity.
+func (g *gcpSecretManagerGatherer) token() (string, error) {
+	keyFile := g.cfg.CredentialFile
+	if keyFile == "" {
+		keyFile = os.Getenv("GOOGLE_APPLICATION_CREDENTIALS")
+	}
+
+	var body []byte
+	var err error
+	if keyFile != "" {
+		var assertion, tokenURI string
+		assertion, tokenURI, err = serviceAccountAssertion(keyFile)
+		if err != nil {
+			return "", err
+		}
+		form := url.Values{"grant_type": {gcpJWTBearerGrant}, "assertion": {assertion}}
+		body, err = httpRequest(&http{
+			URL:     tokenURI,
+			Headers: map[string]string{"Content-Type": "application/x-www-form-urlencoded"},
+		}, "POST", strings.NewReader(form.Encode()))
+	} else {
+		body, err = httpRequest(&http{
+			URL:     gcpMetadataTokenURL,
+			Headers: map[string]string{"Metadata-Flavor": "Google"},
+		}, "GET", nil)
+	}
+	if err != nil {
+		return "", err
+	}
+	var token struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.Unmarshal(body, &token); err != nil || token.AccessToken == "" {
+		return "", errors.New("no access token returned")
+	}
+	return token.AccessToken, nil
+}
+
+// serviceAccountAssertion returns a JWT signed with the service account key, to be exchanged for an
+// access token in the returned token URI.
+func serviceAccountAssertion(keyFile string) (string, string, error) {
+	content, err := ioutil.ReadFile(keyFile)
+	if err != nil {
+		return "", "", fmt.Errorf("unable to read service account key: %s", err)
+	}
+	var key gcpServiceAccountKey
+	if err := json.Unmarshal(content, &key); err != nil {
+		return "", "", fmt.Errorf("invalid service account key %s: %s", keyFile, err)
+	}
+	if key.Type != "service_account" || key.ClientEmail == "" {
+		return "", "", fmt.Errorf("%s is not a service account key", keyFile)
+	}
+	block, _ := pem.Decode([]byte(key.PrivateKey))
+	if block == nil {
+		return "", "", fmt.Errorf("invalid private key in %s", keyFile)
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return "", "", fmt.Errorf("invalid private key in %s: %s", keyFile, err)
+	}
+	rsaKey, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return "", "", fmt.Errorf("private key in %s is not an RSA key", keyFile)
+	}
+	if key.TokenURI == "" {
+		key.TokenURI = gcpDefaultTokenURI
+	}
+
+	now := time.Now()
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT", "kid": key.PrivateKeyID})
+	claims, _ := json.Marshal(map[string]interface{}{
+		"iss":   key.ClientEmail,
+		"scope": gcpCloudPlatformScope,
+		"aud":   key.TokenURI,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	})
+	unsigned := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(unsigned))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, rsaKey, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", "", fmt.Errorf("unable to sign service account assertion: %s", err)
+	}
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(signature), key.TokenURI, nil
+}
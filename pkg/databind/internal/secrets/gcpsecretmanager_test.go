@@ -0,0 +1,124 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package secrets
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	nethttp "net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/newrelic/infrastructure-agent/pkg/databind/pkg/data"
+)
+
+func TestGCPSecretManager(t *testing.T) {
+	defer os.Setenv("GOOGLE_APPLICATION_CREDENTIALS", os.Getenv("GOOGLE_APPLICATION_CREDENTIALS"))
+	require.NoError(t, os.Unsetenv("GOOGLE_APPLICATION_CREDENTIALS"))
+
+	// GIVEN a metadata server, an OAuth token endpoint and a Secret Manager storing a JSON secret
+	var accessed []string
+	server := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		switch {
+		case r.URL.Path == "/metadata/token":
+			assert.Equal(t, "Google", r.Header.Get("Metadata-Flavor"))
+			_, _ = w.Write([]byte(`{"access_token": "workload-token"}`))
+		case r.URL.Path == "/oauth2/token":
+			require.NoError(t, r.ParseForm())
+			assert.Equal(t, gcpJWTBearerGrant, r.PostForm.Get("grant_type"))
+			claims := strings.Split(r.PostForm.Get("assertion"), ".")
+			require.Len(t, claims, 3)
+			payload, err := base64.RawURLEncoding.DecodeString(claims[1])
+			require.NoError(t, err)
+			assert.Contains(t, string(payload), `"iss":"agent@my-project.iam.gserviceaccount.com"`)
+			_, _ = w.Write([]byte(`{"access_token": "key-token"}`))
+		case strings.HasPrefix(r.URL.Path, "/v1/projects/my-project/secrets/mysql/versions/"):
+			accessed = append(accessed, r.URL.Path+" "+r.Header.Get("Authorization"))
+			secret := base64.StdEncoding.EncodeToString([]byte(`{"username":"admin","password":"s3cr3t"}`))
+			_, _ = w.Write([]byte(`{"name": "projects/123/secrets/mysql/versions/2", "payload": {"data": "` + secret + `"}}`))
+		default:
+			w.WriteHeader(nethttp.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	defer func(metadata, api string) {
+		gcpMetadataTokenURL, gcpSecretManagerURL = metadata, api
+	}(gcpMetadataTokenURL, gcpSecretManagerURL)
+	gcpMetadataTokenURL, gcpSecretManagerURL = server.URL+"/metadata/token", server.URL+"/v1"
+
+	t.Run("workload identity", func(t *testing.T) {
+		accessed = nil
+		cfg := GCPSecretManager{Project: "my-project", Secret: "mysql"}
+		require.NoError(t, cfg.Validate())
+
+		// WHEN the secret is gathered with the workload identity
+		value, err := GCPSecretManagerGatherer(&cfg)()
+
+		// THEN the fields of the latest version are returned
+		require.NoError(t, err)
+		assert.Equal(t, data.InterfaceMap{"username": "admin", "password": "s3cr3t"}, value)
+		assert.Equal(t, []string{"/v1/projects/my-project/secrets/mysql/versions/latest:access Bearer workload-token"}, accessed)
+	})
+
+	t.Run("service account key", func(t *testing.T) {
+		accessed = nil
+		keyFile := writeServiceAccountKey(t, server.URL+"/oauth2/token")
+		defer os.Remove(keyFile)
+		cfg := GCPSecretManager{Secret: "projects/my-project/secrets/mysql/versions/2", Type: typePlain, CredentialFile: keyFile}
+		require.NoError(t, cfg.Validate())
+
+		// WHEN a version of the secret is gathered with a service account key
+		value, err := GCPSecretManagerGatherer(&cfg)()
+
+		// THEN its plain value is returned
+		require.NoError(t, err)
+		assert.Equal(t, `{"username":"admin","password":"s3cr3t"}`, value)
+		assert.Equal(t, []string{"/v1/projects/my-project/secrets/mysql/versions/2:access Bearer key-token"}, accessed)
+	})
+
+	t.Run("missing secret", func(t *testing.T) {
+		_, err := GCPSecretManagerGatherer(&GCPSecretManager{Project: "my-project", Secret: "other"})()
+		assert.Error(t, err)
+	})
+}
+
+func TestGCPSecretManager_Validate(t *testing.T) {
+	assert.Error(t, (&GCPSecretManager{Project: "my-project"}).Validate())
+	assert.Error(t, (&GCPSecretManager{Secret: "mysql"}).Validate())
+	assert.Error(t, (&GCPSecretManager{Project: "my-project", Secret: "projects/p/secrets/mysql"}).Validate())
+	assert.Error(t, (&GCPSecretManager{Secret: "projects/p/secrets/mysql/versions/1", Version: "2"}).Validate())
+	assert.NoError(t, (&GCPSecretManager{Secret: "projects/p/secrets/mysql", Version: "2"}).Validate())
+	assert.NoError(t, (&GCPSecretManager{Project: "my-project", Secret: "mysql", Type: typeEqual}).Validate())
+}
+
+func writeServiceAccountKey(t *testing.T, tokenURI string) string {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+	content, err := json.Marshal(gcpServiceAccountKey{
+		Type:         "service_account",
+		ClientEmail:  "agent@my-project.iam.gserviceaccount.com",
+		PrivateKey:   string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})),
+		PrivateKeyID: "key-1",
+		TokenURI:     tokenURI,
+	})
+	require.NoError(t, err)
+	f, err := ioutil.TempFile("", "gcp-key")
+	require.NoError(t, err)
+	_, err = f.Write(content)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	return f.Name()
+}
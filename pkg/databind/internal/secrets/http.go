@@ -24,6 +24,9 @@ type tlsConfig struct {
 	MinVersion         uint16 `yaml:"min_version"`
 	MaxVersion         uint16 `yaml:"max_version"`
 	Ca                 string `yaml:"ca"`
+	// client certificate, for the servers requiring mutual TLS
+	CertFile string `yaml:"cert_file"`
+	KeyFile  string `yaml:"key_file"`
 }
 
 func httpRequest(config *http, method string, body io.Reader) ([]byte, error) {
@@ -45,6 +48,13 @@ func httpRequest(config *http, method string, body io.Reader) ([]byte, error) {
 		rootCAs.AppendCertsFromPEM(ca)
 		tlsConfig.RootCAs = rootCAs
 	}
+	if config.TLSConfig.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(config.TLSConfig.CertFile, config.TLSConfig.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to load client certificate: %s", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
 	client.Transport = &gohttp.Transport{
 		TLSClientConfig: tlsConfig,
 	}
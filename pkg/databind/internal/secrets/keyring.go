@@ -0,0 +1,71 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package secrets
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+
+	"github.com/newrelic/infrastructure-agent/pkg/databind/pkg/data"
+)
+
+// Make mocking simpler
+var keyringExecCommand = exec.Command
+
+// Keyring defines a credential stored in the OS credential store: the Credential Manager on Windows,
+// the Keychain on macOS and the Secret Service (libsecret, through the secret-tool command) on Linux.
+type Keyring struct {
+	Service string `yaml:"service"` // target name of the generic credential on Windows
+	Account string `yaml:"account"` // optional
+}
+
+type keyringGatherer struct {
+	cfg *Keyring
+}
+
+// KeyringGatherer instantiates an OS keyring variable gatherer from the given configuration.
+// The result is a map with the "password" and, if known, the "username" of the credential.
+func KeyringGatherer(keyring *Keyring) func() (interface{}, error) {
+	g := keyringGatherer{cfg: keyring}
+	return func() (interface{}, error) {
+		dt, err := g.get()
+		if err != nil {
+			return "", err
+		}
+		return dt, err
+	}
+}
+
+func (g *keyringGatherer) get() (data.InterfaceMap, error) {
+	username, password, err := g.cfg.lookup()
+	if err != nil {
+		return nil, err
+	}
+	result := data.InterfaceMap{"password": password}
+	if username != "" {
+		result["username"] = username
+	}
+	return result, nil
+}
+
+// Validate checks if the keyring configuration is correct
+func (k *Keyring) Validate() error {
+	if k.Service == "" {
+		return errors.New("keyring secrets must have a service in order to be set")
+	}
+	return nil
+}
+
+// lookup returns the username and password of the credential.
+func (k *Keyring) lookup() (string, string, error) {
+	username, password, err := keyringLookup(k.Service, k.Account)
+	if err != nil {
+		return "", "", fmt.Errorf("unable to retrieve '%s' credential from the OS keyring: %s", k.Service, err)
+	}
+	if password == "" {
+		return "", "", fmt.Errorf("empty password returned from the OS keyring for '%s'", k.Service)
+	}
+	return username, password, nil
+}
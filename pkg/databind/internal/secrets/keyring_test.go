@@ -0,0 +1,62 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package secrets
+
+import (
+	"os/exec"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/newrelic/infrastructure-agent/pkg/databind/pkg/data"
+)
+
+func TestKeyring(t *testing.T) {
+	if runtime.GOOS != "linux" && runtime.GOOS != "darwin" {
+		t.Skip("the keyring is looked up through an API")
+	}
+	// GIVEN a credential stored in the OS keyring
+	var invoked []string
+	keyringExecCommand = func(name string, args ...string) *exec.Cmd {
+		invoked = append([]string{name}, args...)
+		return exec.Command("echo", "pass word")
+	}
+	defer func() { keyringExecCommand = exec.Command }()
+
+	// WHEN it's gathered
+	cfg := &Keyring{Service: "newrelic-infra/mssql", Account: "monitor"}
+	require.NoError(t, cfg.Validate())
+	result, err := KeyringGatherer(cfg)()
+
+	// THEN its password and username are returned
+	require.NoError(t, err)
+	assert.Equal(t, data.InterfaceMap{"password": "pass word", "username": "monitor"}, result)
+	// AND the credential is looked up by service and account
+	if runtime.GOOS == "linux" {
+		assert.Equal(t, []string{"secret-tool", "lookup", "service", "newrelic-infra/mssql", "account", "monitor"}, invoked)
+	} else {
+		assert.Equal(t, []string{"security", "find-generic-password", "-s", "newrelic-infra/mssql", "-w", "-a", "monitor"}, invoked)
+	}
+}
+
+func TestKeyring_Errors(t *testing.T) {
+	if runtime.GOOS != "linux" && runtime.GOOS != "darwin" {
+		t.Skip("the keyring is looked up through an API")
+	}
+	defer func() { keyringExecCommand = exec.Command }()
+
+	// credential not found
+	keyringExecCommand = func(string, ...string) *exec.Cmd { return exec.Command("false") }
+	_, err := KeyringGatherer(&Keyring{Service: "missing"})()
+	assert.Error(t, err)
+
+	// empty password
+	keyringExecCommand = func(string, ...string) *exec.Cmd { return exec.Command("echo") }
+	_, err = KeyringGatherer(&Keyring{Service: "empty"})()
+	assert.Error(t, err)
+
+	assert.Error(t, (&Keyring{Account: "monitor"}).Validate())
+}
@@ -0,0 +1,45 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+// +build !windows
+
+package secrets
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// keyringLookup reads the password through the command line tool of the OS keyring. The account
+// narrows the search, when provided.
+func keyringLookup(service, account string) (string, string, error) {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "linux":
+		args := []string{"lookup", "service", service}
+		if account != "" {
+			args = append(args, "account", account)
+		}
+		cmd = keyringExecCommand("secret-tool", args...)
+	case "darwin":
+		args := []string{"find-generic-password", "-s", service, "-w"}
+		if account != "" {
+			args = append(args, "-a", account)
+		}
+		cmd = keyringExecCommand("security", args...)
+	default:
+		return "", "", fmt.Errorf("the OS keyring is not supported on %s", runtime.GOOS)
+	}
+
+	var out, stderr bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", "", fmt.Errorf("err: %s err msg: %s", err, stderr.String())
+	}
+	// End-of-line fixup from CLI
+	password := strings.TrimSuffix(out.String(), "\n")
+	return account, password, nil
+}
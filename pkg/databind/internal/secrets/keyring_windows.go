@@ -0,0 +1,98 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+// +build windows
+
+package secrets
+
+import (
+	"fmt"
+	"syscall"
+	"unicode/utf16"
+	"unsafe"
+)
+
+const credTypeGeneric = 1
+
+var (
+	modAdvapi32   = syscall.NewLazyDLL("advapi32.dll")
+	procCredReadW = modAdvapi32.NewProc("CredReadW")
+	procCredFree  = modAdvapi32.NewProc("CredFree")
+)
+
+// credential maps the CREDENTIALW structure of the Credential Manager API.
+type credential struct {
+	Flags              uint32
+	Type               uint32
+	TargetName         *uint16
+	Comment            *uint16
+	LastWritten        syscall.Filetime
+	CredentialBlobSize uint32
+	CredentialBlob     *byte
+	Persist            uint32
+	AttributeCount     uint32
+	Attributes         uintptr
+	TargetAlias        *uint16
+	UserName           *uint16
+}
+
+// keyringLookup reads the generic credential whose target name is the service, as stored by the
+// Credential Manager UI or `cmdkey /generic:<service> /user:<account> /pass`. When the account is
+// provided, it must match the credential user name.
+func keyringLookup(service, account string) (string, string, error) {
+	target, err := syscall.UTF16PtrFromString(service)
+	if err != nil {
+		return "", "", err
+	}
+	var cred *credential
+	ret, _, err := procCredReadW.Call(uintptr(unsafe.Pointer(target)), credTypeGeneric, 0, uintptr(unsafe.Pointer(&cred)))
+	if ret == 0 {
+		return "", "", err
+	}
+	defer procCredFree.Call(uintptr(unsafe.Pointer(cred)))
+
+	username := utf16PtrToString(cred.UserName)
+	if account != "" && account != username {
+		return "", "", fmt.Errorf("the credential belongs to user '%s' instead of '%s'", username, account)
+	}
+	var blob []byte
+	if cred.CredentialBlobSize > 0 {
+		blob = (*[1 << 20]byte)(unsafe.Pointer(cred.CredentialBlob))[:cred.CredentialBlobSize:cred.CredentialBlobSize]
+	}
+	return username, decodeCredentialBlob(blob), nil
+}
+
+// decodeCredentialBlob returns the password of a credential. Credential Manager tools store it
+// as UTF-16, whose high bytes are zero for ASCII characters, whereas other applications might store
+// UTF-8 text.
+func decodeCredentialBlob(blob []byte) string {
+	utf16Encoded := false
+	for i := 1; i < len(blob) && len(blob)%2 == 0; i += 2 {
+		if blob[i] == 0 {
+			utf16Encoded = true
+			break
+		}
+	}
+	if !utf16Encoded {
+		return string(blob)
+	}
+	chars := make([]uint16, len(blob)/2)
+	for i := range chars {
+		chars[i] = uint16(blob[2*i]) | uint16(blob[2*i+1])<<8
+	}
+	return string(utf16.Decode(chars))
+}
+
+func utf16PtrToString(p *uint16) string {
+	if p == nil {
+		return ""
+	}
+	var chars []uint16
+	for ptr := unsafe.Pointer(p); ; ptr = unsafe.Pointer(uintptr(ptr) + 2) {
+		c := *(*uint16)(ptr)
+		if c == 0 {
+			break
+		}
+		chars = append(chars, c)
+	}
+	return string(utf16.Decode(chars))
+}
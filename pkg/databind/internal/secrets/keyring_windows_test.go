@@ -0,0 +1,19 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+// +build windows
+
+package secrets
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecodeCredentialBlob(t *testing.T) {
+	// as stored by cmdkey
+	assert.Equal(t, "pässword", decodeCredentialBlob([]byte{'p', 0, 0xe4, 0, 's', 0, 's', 0, 'w', 0, 'o', 0, 'r', 0, 'd', 0}))
+	// as stored by applications writing UTF-8
+	assert.Equal(t, "pässword", decodeCredentialBlob([]byte("pässword")))
+	assert.Equal(t, "odd", decodeCredentialBlob([]byte("odd")))
+}
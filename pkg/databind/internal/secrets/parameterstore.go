@@ -0,0 +1,135 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package secrets
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ssm"
+
+	"github.com/newrelic/infrastructure-agent/pkg/databind/pkg/data"
+)
+
+// ParameterStore defines the AWS Systems Manager Parameter Store data source
+type ParameterStore struct {
+	Name           string `yaml:"name,omitempty"`      // name or ARN of a single parameter
+	Path           string `yaml:"path,omitempty"`      // hierarchy of the parameters to fetch as a batch, e.g. /prod/mysql
+	Recursive      bool   `yaml:"recursive,omitempty"` // whether to fetch the nested levels of the path
+	Type           string `yaml:"type,omitempty"`      // for single parameters: 'json', 'equal' and 'plain' (default)
+	Decrypt        *bool  `yaml:"decrypt,omitempty"`   // KMS decryption of SecureString parameters, true by default
+	Region         string `yaml:"region"`
+	RoleARN        string `yaml:"role_arn,omitempty"`
+	ExternalID     string `yaml:"external_id,omitempty"`
+	CredentialFile string `yaml:"credential_file"`
+	ConfigFile     string `yaml:"config_file"`
+	Endpoint       string `yaml:"endpoint"`
+	DisableSSL     bool   `yaml:"disableSSL"`
+}
+
+type parameterStoreGatherer struct {
+	cfg *ParameterStore
+}
+
+// ParameterStoreGatherer instantiates an AWS SSM Parameter Store variable gatherer from the given
+// configuration. A single parameter is returned decoded according to its type, while the parameters of
+// a path are returned as a Map keyed by their name relative to the path, with dots as separators. E.g.
+// for the /prod/mysql path storing /prod/mysql/password and /prod/mysql/tls/ca, the returned Map
+// contents will be:
+// "password" -> <password value>
+// "tls" -> {"ca" -> <ca value>}
+// SecureString parameters are decrypted with their KMS key, unless decryption is disabled.
+func ParameterStoreGatherer(ps *ParameterStore) func() (interface{}, error) {
+	g := parameterStoreGatherer{cfg: ps}
+	return func() (interface{}, error) {
+		dt, err := g.get()
+		if err != nil {
+			return "", err
+		}
+		return dt, err
+	}
+}
+
+// Validate checks if the Parameter Store configuration is correct
+func (p *ParameterStore) Validate() error {
+	if (p.Name == "") == (p.Path == "") {
+		return errors.New("aws-parameter-store must have either a name or a path parameter")
+	}
+	if p.Path != "" && !strings.HasPrefix(p.Path, "/") {
+		return errors.New("aws-parameter-store path must start with /")
+	}
+	if p.Recursive && p.Path == "" {
+		return errors.New("aws-parameter-store recursive requires a path")
+	}
+	if p.Type != "" && p.Type != typeJson && p.Type != typeEqual && p.Type != typePlain {
+		return errors.New("type can be only " + typePlain + ", " + typeJson + " or " + typeEqual)
+	}
+	if p.Type != "" && p.Path != "" {
+		return errors.New("aws-parameter-store type can only be set for a single parameter")
+	}
+	if p.ExternalID != "" && p.RoleARN == "" {
+		return errors.New("aws-parameter-store external_id requires a role_arn")
+	}
+	return nil
+}
+
+func (g *parameterStoreGatherer) get() (interface{}, error) {
+	sess, err := awsSession(g.cfg.Region, g.cfg.CredentialFile, g.cfg.ConfigFile, g.cfg.DisableSSL)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create aws-parameter-store session: %s", err)
+	}
+	client := ssm.New(sess, awsClientConfig(sess, g.cfg.Endpoint, g.cfg.RoleARN, g.cfg.ExternalID))
+	decrypt := g.cfg.Decrypt == nil || *g.cfg.Decrypt
+
+	if g.cfg.Name != "" {
+		res, err := client.GetParameter(&ssm.GetParameterInput{
+			Name:           aws.String(g.cfg.Name),
+			WithDecryption: aws.Bool(decrypt),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("unable to retrieve parameter %q from aws-parameter-store: %s", g.cfg.Name, err)
+		}
+		return handleDataType([]byte(aws.StringValue(res.Parameter.Value)), g.cfg.Type)
+	}
+
+	prefix := strings.TrimSuffix(g.cfg.Path, "/") + "/"
+	result := data.InterfaceMap{}
+	err = client.GetParametersByPathPages(&ssm.GetParametersByPathInput{
+		Path:           aws.String(g.cfg.Path),
+		Recursive:      aws.Bool(g.cfg.Recursive),
+		WithDecryption: aws.Bool(decrypt),
+	}, func(page *ssm.GetParametersByPathOutput, _ bool) bool {
+		for _, p := range page.Parameters {
+			name := strings.TrimPrefix(aws.StringValue(p.Name), prefix)
+			addParameter(result, strings.Split(name, "/"), aws.StringValue(p.Value))
+		}
+		return true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to retrieve parameters in path %q from aws-parameter-store: %s", g.cfg.Path, err)
+	}
+	if len(result) == 0 {
+		return nil, fmt.Errorf("no parameters found in aws-parameter-store path %q", g.cfg.Path)
+	}
+	return result, nil
+}
+
+// addParameter adds a parameter value in the nested maps of its name levels.
+func addParameter(dst map[string]interface{}, levels []string, value string) {
+	if len(levels) == 1 {
+		// a parameter can be named as the level of other ones (e.g. /db and /db/user), which take precedence
+		if _, ok := dst[levels[0]]; !ok {
+			dst[levels[0]] = value
+		}
+		return
+	}
+	nested, ok := dst[levels[0]].(map[string]interface{})
+	if !ok {
+		nested = map[string]interface{}{}
+		dst[levels[0]] = nested
+	}
+	addParameter(nested, levels[1:], value)
+}
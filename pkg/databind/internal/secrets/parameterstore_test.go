@@ -0,0 +1,94 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package secrets
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	nethttp "net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/newrelic/infrastructure-agent/pkg/databind/pkg/data"
+)
+
+func TestParameterStore(t *testing.T) {
+	for _, env := range []string{"AWS_ACCESS_KEY_ID", "AWS_SECRET_ACCESS_KEY"} {
+		defer os.Setenv(env, os.Getenv(env))
+		require.NoError(t, os.Setenv(env, "test"))
+	}
+
+	// GIVEN a Parameter Store service with some parameters in the /prod/mysql path, paginated
+	var requests []map[string]interface{}
+	server := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		request := map[string]interface{}{}
+		_ = json.Unmarshal(body, &request)
+		requests = append(requests, request)
+		switch r.Header.Get("X-Amz-Target") {
+		case "AmazonSSM.GetParameter":
+			if request["Name"] != "/prod/mysql/password" {
+				w.WriteHeader(nethttp.StatusBadRequest)
+				_, _ = w.Write([]byte(`{"__type": "ParameterNotFound"}`))
+				return
+			}
+			_, _ = w.Write([]byte(`{"Parameter": {"Name": "/prod/mysql/password", "Type": "SecureString", "Value": "s3cr3t"}}`))
+		case "AmazonSSM.GetParametersByPath":
+			if request["NextToken"] == nil {
+				_, _ = w.Write([]byte(`{"Parameters": [
+					{"Name": "/prod/mysql/username", "Type": "String", "Value": "admin"},
+					{"Name": "/prod/mysql/password", "Type": "SecureString", "Value": "s3cr3t"}
+				], "NextToken": "page2"}`))
+				return
+			}
+			_, _ = w.Write([]byte(`{"Parameters": [{"Name": "/prod/mysql/tls/ca", "Type": "String", "Value": "/etc/ca.pem"}]}`))
+		}
+	}))
+	defer server.Close()
+
+	t.Run("single parameter", func(t *testing.T) {
+		requests = nil
+		cfg := ParameterStore{Name: "/prod/mysql/password", Region: "us-east-1", Endpoint: server.URL, DisableSSL: true}
+		require.NoError(t, cfg.Validate())
+
+		// WHEN a single parameter is gathered
+		value, err := ParameterStoreGatherer(&cfg)()
+
+		// THEN its decrypted value is returned
+		require.NoError(t, err)
+		assert.Equal(t, "s3cr3t", value)
+		require.Len(t, requests, 1)
+		assert.Equal(t, true, requests[0]["WithDecryption"])
+	})
+
+	t.Run("path", func(t *testing.T) {
+		requests = nil
+		noDecrypt := false
+		cfg := ParameterStore{Path: "/prod/mysql/", Recursive: true, Decrypt: &noDecrypt, Region: "us-east-1", Endpoint: server.URL, DisableSSL: true}
+		require.NoError(t, cfg.Validate())
+
+		// WHEN the parameters of a path are gathered
+		value, err := ParameterStoreGatherer(&cfg)()
+
+		// THEN all the pages are returned, keyed by their name in the path
+		require.NoError(t, err)
+		assert.Equal(t, data.InterfaceMap{
+			"username": "admin",
+			"password": "s3cr3t",
+			"tls":      map[string]interface{}{"ca": "/etc/ca.pem"},
+		}, value)
+		require.Len(t, requests, 2)
+		assert.Equal(t, true, requests[0]["Recursive"])
+		assert.Equal(t, false, requests[0]["WithDecryption"])
+	})
+
+	t.Run("missing parameter", func(t *testing.T) {
+		_, err := ParameterStoreGatherer(&ParameterStore{Name: "/prod/other", Region: "us-east-1", Endpoint: server.URL, DisableSSL: true})()
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,30 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package secrets
+
+import (
+	"errors"
+
+	"golang.org/x/crypto/nacl/secretbox"
+)
+
+// Sizes of the NaCl secretbox (XSalsa20-Poly1305) construction, as implemented by libsodium's
+// crypto_secretbox_easy and the golang.org/x/crypto/nacl/secretbox package.
+const (
+	secretboxKeySize   = 32
+	secretboxNonceSize = 24
+	secretboxOverhead  = secretbox.Overhead
+)
+
+var errSecretboxAuth = errors.New("message authentication failed: wrong key or corrupted data")
+
+// secretboxOpen authenticates and decrypts a box (Poly1305 tag followed by the ciphertext) sealed
+// with the given nonce and key.
+func secretboxOpen(box []byte, nonce *[secretboxNonceSize]byte, key *[secretboxKeySize]byte) ([]byte, error) {
+	plain, ok := secretbox.Open(nil, box, nonce, key)
+	if !ok {
+		return nil, errSecretboxAuth
+	}
+	return plain, nil
+}
@@ -0,0 +1,141 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package secrets
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+
+	"github.com/newrelic/infrastructure-agent/pkg/databind/pkg/data"
+)
+
+// SecretsManager defines the AWS Secrets Manager data source
+type SecretsManager struct {
+	SecretID       string `yaml:"secret_id"`               // name or ARN of the secret
+	VersionStage   string `yaml:"version_stage,omitempty"` // AWSCURRENT by default
+	Key            string `yaml:"key,omitempty"`           // dot-separated path of a single value in a JSON secret
+	Type           string `yaml:"type,omitempty"`          // can be 'json', 'equal' and 'plain' (default)
+	Region         string `yaml:"region"`
+	RoleARN        string `yaml:"role_arn,omitempty"`
+	ExternalID     string `yaml:"external_id,omitempty"`
+	CredentialFile string `yaml:"credential_file"`
+	ConfigFile     string `yaml:"config_file"`
+	Endpoint       string `yaml:"endpoint"`
+	DisableSSL     bool   `yaml:"disableSSL"`
+}
+
+type secretsManagerGatherer struct {
+	cfg *SecretsManager
+}
+
+// SecretsManagerGatherer instantiates an AWS Secrets Manager variable gatherer from the given
+// configuration. The fetching process returns the secret decoded according to its type, or the single
+// value selected by its key. E.g. if the stored secret is `{"username":"admin","password":"s3cr3t"}`,
+// with the json type the returned Map contents will be:
+// "username" -> "admin"
+// "password" -> "s3cr3t"
+// and with the "password" key it will return "s3cr3t".
+// The credentials are taken from the environment, the shared credentials file or the EC2 instance
+// metadata service (IMDS), and optionally used to assume the configured role.
+func SecretsManagerGatherer(sm *SecretsManager) func() (interface{}, error) {
+	g := secretsManagerGatherer{cfg: sm}
+	return func() (interface{}, error) {
+		dt, err := g.get()
+		if err != nil {
+			return "", err
+		}
+		return dt, err
+	}
+}
+
+// Validate checks if the Secrets Manager configuration is correct
+func (s *SecretsManager) Validate() error {
+	if s.SecretID == "" {
+		return errors.New("aws-secrets-manager must have a secret_id parameter with the secret name or ARN")
+	}
+	if s.Type != "" && s.Type != typeJson && s.Type != typeEqual && s.Type != typePlain {
+		return errors.New("type can be only " + typePlain + ", " + typeJson + " or " + typeEqual)
+	}
+	if s.Key != "" && s.Type != "" && s.Type != typeJson {
+		return errors.New("aws-secrets-manager key can only be selected from json secrets")
+	}
+	if s.ExternalID != "" && s.RoleARN == "" {
+		return errors.New("aws-secrets-manager external_id requires a role_arn")
+	}
+	return nil
+}
+
+func (g *secretsManagerGatherer) get() (interface{}, error) {
+	sess, err := awsSession(g.cfg.Region, g.cfg.CredentialFile, g.cfg.ConfigFile, g.cfg.DisableSSL)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create aws-secrets-manager session: %s", err)
+	}
+	client := secretsmanager.New(sess, awsClientConfig(sess, g.cfg.Endpoint, g.cfg.RoleARN, g.cfg.ExternalID))
+
+	input := &secretsmanager.GetSecretValueInput{SecretId: aws.String(g.cfg.SecretID)}
+	if g.cfg.VersionStage != "" {
+		input.VersionStage = aws.String(g.cfg.VersionStage)
+	}
+	res, err := client.GetSecretValue(input)
+	if err != nil {
+		return nil, fmt.Errorf("unable to retrieve secret %q from aws-secrets-manager: %s", g.cfg.SecretID, err)
+	}
+	var payload []byte
+	if res.SecretString != nil {
+		payload = []byte(*res.SecretString)
+	} else {
+		payload = res.SecretBinary
+	}
+
+	if g.cfg.Key != "" {
+		return selectKey(payload, g.cfg.Key)
+	}
+	return handleDataType(payload, g.cfg.Type)
+}
+
+// selectKey returns the value in the passed dot-separated path of a JSON secret. Keys containing dots
+// are matched before being split.
+func selectKey(payload []byte, key string) (interface{}, error) {
+	var value interface{}
+	if err := json.Unmarshal(payload, &value); err != nil {
+		return nil, fmt.Errorf("unable to select key %q from aws-secrets-manager secret, as it isn't JSON: %s", key, err)
+	}
+	value, ok := lookup(value, key)
+	if !ok {
+		return nil, fmt.Errorf("key %q not found in aws-secrets-manager secret", key)
+	}
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case map[string]interface{}:
+		return data.InterfaceMap(v), nil
+	default:
+		// numbers, booleans or arrays
+		raw, err := json.Marshal(v)
+		return string(raw), err
+	}
+}
+
+func lookup(value interface{}, path string) (interface{}, bool) {
+	object, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, false
+	}
+	if v, ok := object[path]; ok {
+		return v, true
+	}
+	parts := strings.SplitN(path, ".", 2)
+	if len(parts) < 2 {
+		return nil, false
+	}
+	if v, ok := object[parts[0]]; ok {
+		return lookup(v, parts[1])
+	}
+	return nil, false
+}
@@ -0,0 +1,80 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package secrets
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	nethttp "net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/newrelic/infrastructure-agent/pkg/databind/pkg/data"
+)
+
+func TestSecretsManager(t *testing.T) {
+	for _, env := range []string{"AWS_ACCESS_KEY_ID", "AWS_SECRET_ACCESS_KEY"} {
+		defer os.Setenv(env, os.Getenv(env))
+		require.NoError(t, os.Setenv(env, "test"))
+	}
+
+	// GIVEN a Secrets Manager service storing a JSON secret
+	var requested map[string]string
+	server := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		assert.Equal(t, "secretsmanager.GetSecretValue", r.Header.Get("X-Amz-Target"))
+		body, _ := ioutil.ReadAll(r.Body)
+		requested = map[string]string{}
+		_ = json.Unmarshal(body, &requested)
+		if requested["SecretId"] != "prod/mysql" {
+			w.WriteHeader(nethttp.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"__type": "ResourceNotFoundException", "Message": "Secrets Manager can't find the specified secret."}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"Name": "prod/mysql", "SecretString": "{\"username\":\"admin\",\"password\":\"s3cr3t\",\"port\":3306,\"tls\":{\"ca\":\"/etc/ca.pem\"}}"}`))
+	}))
+	defer server.Close()
+	cfg := SecretsManager{SecretID: "prod/mysql", Region: "us-east-1", Endpoint: server.URL, DisableSSL: true}
+
+	cases := []struct {
+		name     string
+		typ      string
+		key      string
+		expected interface{}
+	}{
+		{"plain", "", "", `{"username":"admin","password":"s3cr3t","port":3306,"tls":{"ca":"/etc/ca.pem"}}`},
+		{"json", typeJson, "", data.InterfaceMap{"username": "admin", "password": "s3cr3t", "port": float64(3306), "tls": map[string]interface{}{"ca": "/etc/ca.pem"}}},
+		{"key", "", "password", "s3cr3t"},
+		{"numeric key", "", "port", "3306"},
+		{"nested key", "", "tls.ca", "/etc/ca.pem"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			cfg.Type, cfg.Key = c.typ, c.key
+			require.NoError(t, cfg.Validate())
+
+			// WHEN the secret is gathered
+			value, err := SecretsManagerGatherer(&cfg)()
+
+			// THEN it is returned according to its type or key
+			require.NoError(t, err)
+			assert.Equal(t, c.expected, value)
+			assert.Equal(t, "prod/mysql", requested["SecretId"])
+		})
+	}
+
+	t.Run("missing key", func(t *testing.T) {
+		cfg.Type, cfg.Key = "", "token"
+		_, err := SecretsManagerGatherer(&cfg)()
+		assert.Error(t, err)
+	})
+
+	t.Run("missing secret", func(t *testing.T) {
+		_, err := SecretsManagerGatherer(&SecretsManager{SecretID: "prod/other", Region: "us-east-1", Endpoint: server.URL, DisableSSL: true})()
+		assert.Error(t, err)
+	})
+}
@@ -7,15 +7,24 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"sync"
+	"time"
+
 	"github.com/newrelic/infrastructure-agent/pkg/databind/pkg/data"
 )
 
 type Vault struct {
 	HTTP *http
+	// Auth logs in to Vault to get its token, instead of setting a static X-Vault-Token header
+	Auth *vaultAuth `yaml:"auth,omitempty"`
 }
 
 type vaultGatherer struct {
 	cfg *Vault
+	// token obtained with the auth method, reused until it has to be renewed
+	lock  sync.Mutex
+	token *vaultToken
+	now   func() time.Time
 }
 
 // VaultGatherer instantiates a Vault variable gatherer from the given configuration. The fetching process
@@ -25,7 +34,7 @@ type vaultGatherer struct {
 // "person.name"    -> "Matias"
 // "person.surname" -> "Burni"
 func VaultGatherer(vault *Vault) func() (interface{}, error) {
-	g := vaultGatherer{cfg: vault}
+	g := &vaultGatherer{cfg: vault, now: time.Now}
 	return func() (interface{}, error) {
 		dt, err := g.get()
 		if err != nil {
@@ -36,9 +45,20 @@ func VaultGatherer(vault *Vault) func() (interface{}, error) {
 }
 
 func (g *vaultGatherer) get() (data.InterfaceMap, error) {
-	secret := g.cfg
-	dt, err := httpRequest(secret.HTTP, "GET", nil)
+	request := g.cfg.HTTP
+	if g.cfg.Auth != nil {
+		token, err := g.authToken()
+		if err != nil {
+			return nil, fmt.Errorf("unable to authenticate to vault: %s", err)
+		}
+		request = withVaultToken(g.cfg.HTTP, token)
+	}
+	dt, err := httpRequest(request, "GET", nil)
 	if err != nil {
+		if g.cfg.Auth != nil {
+			// the token could have been revoked, so the next attempt logs in again
+			g.invalidateToken()
+		}
 		return nil, fmt.Errorf("unable to retrieve vault secret from http server: %s", err)
 	}
 
@@ -66,5 +86,8 @@ func (g *Vault) Validate() error {
 	if g.HTTP.URL == "" {
 		return errors.New("vault secrets must have an http URL parameter in order to be set")
 	}
+	if g.Auth != nil {
+		return g.Auth.validate()
+	}
 	return nil
 }
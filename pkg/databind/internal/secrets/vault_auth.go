@@ -0,0 +1,214 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package secrets
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	vaultTokenHeader     = "X-Vault-Token"
+	defaultK8sTokenFile  = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+	defaultAppRoleMount  = "approle"
+	defaultK8sAuthMount  = "kubernetes"
+	vaultRenewSelfPath   = "/v1/auth/token/renew-self"
+	vaultRenewLeftFactor = 3 // the token is renewed when less than a third of its lease is left
+)
+
+// vaultAuth selects the method to log in to Vault.
+type vaultAuth struct {
+	AppRole    *vaultAppRole    `yaml:"approle,omitempty"`
+	Kubernetes *vaultKubernetes `yaml:"kubernetes,omitempty"`
+}
+
+type vaultAppRole struct {
+	Mount        string `yaml:"mount,omitempty"` // approle by default
+	RoleID       string `yaml:"role_id"`
+	SecretID     string `yaml:"secret_id,omitempty"`
+	SecretIDFile string `yaml:"secret_id_file,omitempty"` // alternative to secret_id, e.g. delivered by the orchestrator
+}
+
+type vaultKubernetes struct {
+	Mount     string `yaml:"mount,omitempty"` // kubernetes by default
+	Role      string `yaml:"role"`
+	TokenFile string `yaml:"token_file,omitempty"` // the pod service account token by default
+}
+
+// vaultToken is a client token obtained by logging in.
+type vaultToken struct {
+	value     string
+	renewable bool
+	lease     time.Duration // zero for tokens that don't expire
+	expiresAt time.Time
+}
+
+type vaultAuthResponse struct {
+	Auth *struct {
+		ClientToken   string `json:"client_token"`
+		LeaseDuration int64  `json:"lease_duration"`
+		Renewable     bool   `json:"renewable"`
+	} `json:"auth"`
+}
+
+func (a *vaultAuth) validate() error {
+	if (a.AppRole == nil) == (a.Kubernetes == nil) {
+		return errors.New("vault auth must have either an approle or a kubernetes section")
+	}
+	if a.AppRole != nil {
+		if a.AppRole.RoleID == "" {
+			return errors.New("vault approle auth must have a role_id")
+		}
+		if (a.AppRole.SecretID == "") == (a.AppRole.SecretIDFile == "") {
+			return errors.New("vault approle auth must have either a secret_id or a secret_id_file")
+		}
+	}
+	if a.Kubernetes != nil && a.Kubernetes.Role == "" {
+		return errors.New("vault kubernetes auth must have a role")
+	}
+	return nil
+}
+
+// authToken returns the token obtained with the auth method. It is renewed when it's close to expire
+// and, if that isn't possible, a new one is requested.
+func (g *vaultGatherer) authToken() (string, error) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	now := g.now()
+	if t := g.token; t != nil {
+		if t.lease == 0 || now.Before(t.expiresAt.Add(-t.lease/vaultRenewLeftFactor)) {
+			return t.value, nil
+		}
+		if t.renewable && now.Before(t.expiresAt) {
+			renewed, err := g.renewToken(t.value)
+			if err == nil {
+				g.token = renewed
+				return renewed.value, nil
+			}
+			slog.WithError(err).Debug("Unable to renew vault token, logging in again.")
+		}
+		g.token = nil
+	}
+
+	token, err := g.login()
+	if err != nil {
+		return "", err
+	}
+	g.token = token
+	return token.value, nil
+}
+
+// invalidateToken discards the token, so the next access logs in again.
+func (g *vaultGatherer) invalidateToken() {
+	g.lock.Lock()
+	g.token = nil
+	g.lock.Unlock()
+}
+
+func (g *vaultGatherer) login() (*vaultToken, error) {
+	var mount string
+	var body map[string]string
+	if approle := g.cfg.Auth.AppRole; approle != nil {
+		mount = valueOrDefault(approle.Mount, defaultAppRoleMount)
+		secretID := approle.SecretID
+		if approle.SecretIDFile != "" {
+			content, err := ioutil.ReadFile(approle.SecretIDFile)
+			if err != nil {
+				return nil, fmt.Errorf("unable to read approle secret_id_file: %s", err)
+			}
+			secretID = strings.TrimSpace(string(content))
+		}
+		body = map[string]string{"role_id": approle.RoleID, "secret_id": secretID}
+	} else {
+		k8s := g.cfg.Auth.Kubernetes
+		mount = valueOrDefault(k8s.Mount, defaultK8sAuthMount)
+		jwt, err := ioutil.ReadFile(valueOrDefault(k8s.TokenFile, defaultK8sTokenFile))
+		if err != nil {
+			return nil, fmt.Errorf("unable to read kubernetes service account token: %s", err)
+		}
+		body = map[string]string{"role": k8s.Role, "jwt": strings.TrimSpace(string(jwt))}
+	}
+
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	loginURL, err := g.vaultURL("/v1/auth/" + strings.Trim(mount, "/") + "/login")
+	if err != nil {
+		return nil, err
+	}
+	res, err := httpRequest(g.authRequest(loginURL, ""), "POST", bytes.NewReader(payload))
+	if err != nil {
+		return nil, fmt.Errorf("%s login failed: %s", mount, err)
+	}
+	return g.parseToken(res)
+}
+
+func (g *vaultGatherer) renewToken(token string) (*vaultToken, error) {
+	renewURL, err := g.vaultURL(vaultRenewSelfPath)
+	if err != nil {
+		return nil, err
+	}
+	res, err := httpRequest(g.authRequest(renewURL, token), "POST", bytes.NewReader([]byte("{}")))
+	if err != nil {
+		return nil, err
+	}
+	return g.parseToken(res)
+}
+
+func (g *vaultGatherer) parseToken(res []byte) (*vaultToken, error) {
+	var auth vaultAuthResponse
+	if err := json.Unmarshal(res, &auth); err != nil || auth.Auth == nil || auth.Auth.ClientToken == "" {
+		return nil, errors.New("vault returned no client token")
+	}
+	lease := time.Duration(auth.Auth.LeaseDuration) * time.Second
+	return &vaultToken{
+		value:     auth.Auth.ClientToken,
+		renewable: auth.Auth.Renewable,
+		lease:     lease,
+		expiresAt: g.now().Add(lease),
+	}, nil
+}
+
+// vaultURL returns the URL of an API path in the Vault server of the secret URL.
+func (g *vaultGatherer) vaultURL(path string) (string, error) {
+	u, err := url.Parse(g.cfg.HTTP.URL)
+	if err != nil || u.Host == "" {
+		return "", fmt.Errorf("invalid vault URL: %q", g.cfg.HTTP.URL)
+	}
+	return u.Scheme + "://" + u.Host + path, nil
+}
+
+// authRequest returns a request to the Vault API with the same TLS configuration of the secret one.
+func (g *vaultGatherer) authRequest(url, token string) *http {
+	headers := map[string]string{"Content-Type": "application/json"}
+	if token != "" {
+		headers[vaultTokenHeader] = token
+	}
+	return &http{URL: url, TLSConfig: g.cfg.HTTP.TLSConfig, Headers: headers}
+}
+
+// withVaultToken returns a copy of the request authenticated with the token.
+func withVaultToken(request *http, token string) *http {
+	headers := make(map[string]string, len(request.Headers)+1)
+	for k, v := range request.Headers {
+		headers[k] = v
+	}
+	headers[vaultTokenHeader] = token
+	return &http{URL: request.URL, TLSConfig: request.TLSConfig, Headers: headers}
+}
+
+func valueOrDefault(value, defaultValue string) string {
+	if value == "" {
+		return defaultValue
+	}
+	return value
+}
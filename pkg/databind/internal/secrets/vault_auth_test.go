@@ -0,0 +1,151 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package secrets
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	nethttp "net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/newrelic/infrastructure-agent/pkg/databind/pkg/data"
+)
+
+// vaultServer mocks the Vault login, token renewal and KV endpoints.
+type vaultServer struct {
+	*httptest.Server
+	logins   []map[string]string
+	renewals int
+	tokens   []string
+}
+
+func newVaultServer(t *testing.T) *vaultServer {
+	vs := &vaultServer{}
+	vs.Server = httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		switch r.URL.Path {
+		case "/v1/auth/approle/login", "/v1/auth/k8s-cluster/login":
+			body := map[string]string{}
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+			vs.logins = append(vs.logins, body)
+			if body["secret_id"] == "wrong" {
+				w.WriteHeader(nethttp.StatusBadRequest)
+				return
+			}
+			_, _ = w.Write([]byte(`{"auth": {"client_token": "login-token", "lease_duration": 3600, "renewable": true}}`))
+		case "/v1/auth/token/renew-self":
+			vs.renewals++
+			assert.Equal(t, "login-token", r.Header.Get(vaultTokenHeader))
+			_, _ = w.Write([]byte(`{"auth": {"client_token": "login-token", "lease_duration": 3600, "renewable": true}}`))
+		case "/v1/secret/data/mysql":
+			vs.tokens = append(vs.tokens, r.Header.Get(vaultTokenHeader))
+			_, _ = w.Write([]byte(`{"data": {"data": {"password": "s3cr3t"}}}`))
+		default:
+			w.WriteHeader(nethttp.StatusNotFound)
+		}
+	}))
+	return vs
+}
+
+func TestVault_AppRoleAuth(t *testing.T) {
+	// GIVEN a Vault server with the AppRole auth method
+	server := newVaultServer(t)
+	defer server.Close()
+	cfg := Vault{
+		HTTP: &http{URL: server.URL + "/v1/secret/data/mysql"},
+		Auth: &vaultAuth{AppRole: &vaultAppRole{RoleID: "role-1", SecretID: "secret-1"}},
+	}
+	require.NoError(t, cfg.Validate())
+	now := time.Now()
+	g := &vaultGatherer{cfg: &cfg, now: func() time.Time { return now }}
+
+	// WHEN the secret is read
+	value, err := g.get()
+
+	// THEN the agent logs in with the role credentials and reads the secret with the obtained token
+	require.NoError(t, err)
+	assert.Equal(t, data.InterfaceMap{"password": "s3cr3t"}, value)
+	assert.Equal(t, []map[string]string{{"role_id": "role-1", "secret_id": "secret-1"}}, server.logins)
+	assert.Equal(t, []string{"login-token"}, server.tokens)
+
+	// AND the token is reused while its lease is far from expiring
+	now = now.Add(30 * time.Minute)
+	_, err = g.get()
+	require.NoError(t, err)
+	assert.Len(t, server.logins, 1)
+	assert.Zero(t, server.renewals)
+
+	// AND it's renewed before expiring
+	now = now.Add(15 * time.Minute)
+	_, err = g.get()
+	require.NoError(t, err)
+	assert.Len(t, server.logins, 1)
+	assert.Equal(t, 1, server.renewals)
+
+	// AND the agent logs in again once it has expired
+	now = now.Add(2 * time.Hour)
+	_, err = g.get()
+	require.NoError(t, err)
+	assert.Len(t, server.logins, 2)
+	assert.Equal(t, 1, server.renewals)
+}
+
+func TestVault_KubernetesAuth(t *testing.T) {
+	// GIVEN a Vault server with the Kubernetes auth method and a service account token
+	server := newVaultServer(t)
+	defer server.Close()
+	tokenFile, err := ioutil.TempFile("", "k8s-token")
+	require.NoError(t, err)
+	defer os.Remove(tokenFile.Name())
+	_, err = tokenFile.WriteString("service-account-jwt\n")
+	require.NoError(t, err)
+	require.NoError(t, tokenFile.Close())
+
+	cfg := Vault{
+		HTTP: &http{URL: server.URL + "/v1/secret/data/mysql"},
+		Auth: &vaultAuth{Kubernetes: &vaultKubernetes{Mount: "k8s-cluster", Role: "newrelic", TokenFile: tokenFile.Name()}},
+	}
+	require.NoError(t, cfg.Validate())
+
+	// WHEN the secret is gathered
+	value, err := VaultGatherer(&cfg)()
+
+	// THEN the agent logs in with the service account token
+	require.NoError(t, err)
+	assert.Equal(t, data.InterfaceMap{"password": "s3cr3t"}, value)
+	assert.Equal(t, []map[string]string{{"role": "newrelic", "jwt": "service-account-jwt"}}, server.logins)
+	assert.Equal(t, []string{"login-token"}, server.tokens)
+}
+
+func TestVault_AuthFailure(t *testing.T) {
+	server := newVaultServer(t)
+	defer server.Close()
+
+	_, err := VaultGatherer(&Vault{
+		HTTP: &http{URL: server.URL + "/v1/secret/data/mysql"},
+		Auth: &vaultAuth{AppRole: &vaultAppRole{RoleID: "role-1", SecretID: "wrong"}},
+	})()
+
+	assert.Error(t, err)
+	assert.Empty(t, server.tokens)
+}
+
+func TestVault_ValidateAuth(t *testing.T) {
+	vault := func(auth *vaultAuth) *Vault {
+		return &Vault{HTTP: &http{URL: "https://vault:8200/v1/secret/data/mysql"}, Auth: auth}
+	}
+	assert.Error(t, vault(&vaultAuth{}).Validate())
+	assert.Error(t, vault(&vaultAuth{AppRole: &vaultAppRole{SecretID: "s"}}).Validate())
+	assert.Error(t, vault(&vaultAuth{AppRole: &vaultAppRole{RoleID: "r"}}).Validate())
+	assert.Error(t, vault(&vaultAuth{AppRole: &vaultAppRole{RoleID: "r", SecretID: "s", SecretIDFile: "f"}}).Validate())
+	assert.Error(t, vault(&vaultAuth{Kubernetes: &vaultKubernetes{}}).Validate())
+	assert.Error(t, vault(&vaultAuth{AppRole: &vaultAppRole{RoleID: "r", SecretID: "s"}, Kubernetes: &vaultKubernetes{Role: "r"}}).Validate())
+	assert.NoError(t, vault(&vaultAuth{AppRole: &vaultAppRole{RoleID: "r", SecretIDFile: "f"}}).Validate())
+	assert.NoError(t, vault(&vaultAuth{Kubernetes: &vaultKubernetes{Role: "r"}}).Validate())
+}
@@ -28,6 +28,28 @@ const (
 	Label                      = "label"
 	Command                    = "command"
 	DockerContainerName        = "dockerContainerName"
+	AnnotationInfix            = "annotation."
+	Namespace                  = "namespace"
+	Node                       = "node"
+	NodeIP                     = "node.ip"
+	Pod                        = "pod"
+	NamespaceName              = "namespaceName"
+	PodName                    = "podName"
+	NodeName                   = "nodeName"
+	ServiceName                = "serviceName"
+	ID                         = "id"
+	Datacenter                 = "datacenter"
+	Tags                       = "tags"
+	TagInfix                   = "tag."
+	MetaInfix                  = "meta."
+	NodeMetaInfix              = "node.meta."
+	Target                     = "target"
+	File                       = "file"
+	Priority                   = "priority"
+	Weight                     = "weight"
+	Record                     = "record"
+	Service                    = "service"
+	Slot                       = "slot"
 	EntityRewriteActionReplace = "replace"
 )
 
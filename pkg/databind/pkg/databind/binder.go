@@ -4,6 +4,11 @@
 package databind
 
 import (
+	"context"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/newrelic/infrastructure-agent/pkg/databind/internal/discovery"
@@ -13,9 +18,11 @@ import (
 // Sources holds the configuration of all the discovery and variable sources.
 // It is built from the LoadYAML function
 type Sources struct {
+	lock       sync.Mutex // the Sources of a config file are shared by all its integrations
 	clock      func() time.Time
 	discoverer *discoverer
 	variables  map[string]*gatherer // key: variable name
+	cancel     context.CancelFunc   // stops the background watches of the discoverer, if any
 }
 
 // NewValues returns an instance of value
@@ -42,9 +49,78 @@ type Values struct {
 	discov []discovery.Discovery
 }
 
+// Equal returns whether both Values hold the same variables and discovered matches, in the same
+// order, so anything replaced from one of them can be reused for the other.
+func (v *Values) Equal(other *Values) bool {
+	if v == nil || other == nil {
+		return v == other
+	}
+	if len(v.vars) != len(other.vars) || len(v.discov) != len(other.discov) {
+		return false
+	}
+	return (len(v.vars) == 0 || reflect.DeepEqual(v.vars, other.vars)) &&
+		(len(v.discov) == 0 || reflect.DeepEqual(v.discov, other.discov))
+}
+
+// ChangedVariables returns the sorted names of the variables whose values differ between both Values,
+// e.g. a secret that has been rotated since the other Values were fetched.
+func (v *Values) ChangedVariables(other *Values) []string {
+	if v == nil || other == nil {
+		return nil
+	}
+	changed := map[string]bool{}
+	diff := func(a, b data.Map) {
+		for key, value := range a {
+			if otherValue, ok := b[key]; !ok || otherValue != value {
+				changed[variableName(key)] = true
+			}
+		}
+	}
+	diff(v.vars, other.vars)
+	diff(other.vars, v.vars)
+
+	names := make([]string, 0, len(changed))
+	for name := range changed {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// VariablesTTL returns the shortest time-to-live of the variables, or zero if there are no variables.
+func (s *Sources) VariablesTTL() time.Duration {
+	var ttl time.Duration
+	for _, g := range s.variables {
+		if ttl == 0 || g.cache.ttl < ttl {
+			ttl = g.cache.ttl
+		}
+	}
+	return ttl
+}
+
+// Close stops watching the discovery sources in background. It must be invoked once the Sources are
+// discarded, e.g. when their integrations config file is reloaded or removed.
+func (s *Sources) Close() {
+	if s == nil || s.cancel == nil {
+		return
+	}
+	s.cancel()
+}
+
+// variableName returns the name of the variable holding a value, e.g. creds for creds.password or hosts[0].
+func variableName(key string) string {
+	if i := strings.IndexAny(key, ".["); i > 0 {
+		return key[:i]
+	}
+	return key
+}
+
 // Fetch queries the Sources for discovery data and user-defined variables, and returns the
 // acquired Values.
 func Fetch(ctx *Sources) (Values, error) {
+	ctx.lock.Lock()
+	defer ctx.lock.Unlock()
+
 	now := ctx.clock()
 	vals := NewValues(data.Map{})
 	if ctx.discoverer != nil {
@@ -98,3 +98,80 @@ func TestContextCache(t *testing.T) {
 	result = fetch()
 	assert.Equal(t, fetched{"bye", "bye", "bye"}, result)
 }
+
+func TestContextCache_Changes(t *testing.T) {
+	now := time.Now()
+	value := "hello"
+	changes := discovery.NewChanges()
+
+	// GIVEN a discoverer whose source notifies its changes
+	ctx := Sources{
+		clock: func() time.Time { return now },
+		discoverer: &discoverer{
+			cache: cachedEntry{ttl: time.Hour},
+			fetch: func() ([]discovery.Discovery, error) {
+				return []discovery.Discovery{NewDiscovery(data.Map{"value": value}, nil, nil)}, nil
+			},
+			changes: changes,
+		},
+	}
+	fetch := func() string {
+		vals, err := Fetch(&ctx)
+		require.NoError(t, err)
+		require.Len(t, vals.discov, 1)
+		return vals.discov[0].Variables["value"]
+	}
+	assert.Equal(t, "hello", fetch())
+
+	// WHEN the source changes before the TTL expires
+	value = "changed"
+	now = now.Add(time.Minute)
+	// THEN the cached discoveries are kept until the change is notified
+	assert.Equal(t, "hello", fetch())
+	changes.Notify()
+	assert.Equal(t, "changed", fetch())
+
+	// AND are cached again
+	value = "not fetched"
+	assert.Equal(t, "changed", fetch())
+}
+
+func TestValues_Equal(t *testing.T) {
+	disc := NewDiscovery(data.Map{"discovery.ip": "10.0.0.1"}, data.InterfaceMap{"containerName": "redis"}, nil)
+	vals := NewValues(data.Map{"secret": "s3cr3t"}, disc)
+
+	same := NewValues(data.Map{"secret": "s3cr3t"}, NewDiscovery(data.Map{"discovery.ip": "10.0.0.1"}, data.InterfaceMap{"containerName": "redis"}, nil))
+	assert.True(t, vals.Equal(&same))
+
+	otherMatch := NewValues(data.Map{"secret": "s3cr3t"}, NewDiscovery(data.Map{"discovery.ip": "10.0.0.2"}, nil, nil))
+	assert.False(t, vals.Equal(&otherMatch))
+
+	moreMatches := NewValues(data.Map{"secret": "s3cr3t"}, disc, disc)
+	assert.False(t, vals.Equal(&moreMatches))
+
+	otherVars := NewValues(data.Map{"secret": "rotated"}, disc)
+	assert.False(t, vals.Equal(&otherVars))
+
+	// empty values are equal regardless of their allocation
+	empty := NewValues(nil)
+	assert.True(t, empty.Equal(&Values{vars: data.Map{}}))
+	assert.False(t, empty.Equal(nil))
+}
+
+func TestValues_ChangedVariables(t *testing.T) {
+	previous := NewValues(data.Map{"creds.user": "admin", "creds.password": "a", "hosts[0]": "h1", "token": "t"})
+	current := NewValues(data.Map{"creds.user": "admin", "creds.password": "b", "hosts[0]": "h1", "hosts[1]": "h2", "token": "t"})
+
+	assert.Equal(t, []string{"creds", "hosts"}, current.ChangedVariables(&previous))
+	assert.Empty(t, current.ChangedVariables(&current))
+	assert.Empty(t, current.ChangedVariables(nil))
+}
+
+func TestSources_VariablesTTL(t *testing.T) {
+	sources := Sources{variables: map[string]*gatherer{
+		"hour":   {cache: cachedEntry{ttl: time.Hour}},
+		"minute": {cache: cachedEntry{ttl: time.Minute}},
+	}}
+	assert.Equal(t, time.Minute, sources.VariablesTTL())
+	assert.Zero(t, (&Sources{}).VariablesTTL())
+}
@@ -35,9 +35,14 @@ type discoverer struct {
 	cache cachedEntry
 	// any discovery source must provide a function of this signature
 	fetch func() ([]discovery.Discovery, error)
+	// optional. Sources notifying their changes (e.g. docker events) invalidate the cache before its TTL
+	changes discovery.Changes
 }
 
 func (d *discoverer) do(now time.Time) ([]discovery.Discovery, error) {
+	if d.changes.Changed() {
+		d.cache.stored = nil
+	}
 	if vals, ok := d.cache.get(now); ok {
 		return vals.([]discovery.Discovery), nil
 	}
@@ -4,6 +4,7 @@
 package databind
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"time"
@@ -11,8 +12,15 @@ import (
 	"github.com/newrelic/infrastructure-agent/pkg/databind/internal/discovery/command"
 
 	"github.com/newrelic/infrastructure-agent/pkg/databind/internal/discovery"
+	"github.com/newrelic/infrastructure-agent/pkg/databind/internal/discovery/consul"
+	"github.com/newrelic/infrastructure-agent/pkg/databind/internal/discovery/containerd"
+	"github.com/newrelic/infrastructure-agent/pkg/databind/internal/discovery/dns"
 	"github.com/newrelic/infrastructure-agent/pkg/databind/internal/discovery/docker"
 	"github.com/newrelic/infrastructure-agent/pkg/databind/internal/discovery/fargate"
+	"github.com/newrelic/infrastructure-agent/pkg/databind/internal/discovery/file"
+	"github.com/newrelic/infrastructure-agent/pkg/databind/internal/discovery/kubernetes"
+	"github.com/newrelic/infrastructure-agent/pkg/databind/internal/discovery/podman"
+	"github.com/newrelic/infrastructure-agent/pkg/databind/internal/discovery/swarm"
 	"github.com/newrelic/infrastructure-agent/pkg/databind/internal/secrets"
 	"gopkg.in/yaml.v2"
 )
@@ -25,10 +33,17 @@ const (
 type YAMLConfig struct {
 	Variables map[string]varEntry `yaml:"variables,omitempty"` // key: variable name
 	Discovery struct {
-		TTL     string               `yaml:"ttl,omitempty"`
-		Docker  *discovery.Container `yaml:"docker,omitempty"`
-		Fargate *discovery.Container `yaml:"fargate,omitempty"`
-		Command *discovery.Command   `yaml:"command,omitempty"`
+		TTL        string                `yaml:"ttl,omitempty"`
+		Docker     *discovery.Container  `yaml:"docker,omitempty"`
+		Fargate    *discovery.Container  `yaml:"fargate,omitempty"`
+		Command    *discovery.Command    `yaml:"command,omitempty"`
+		Kubernetes *discovery.Kubernetes `yaml:"kubernetes,omitempty"`
+		Consul     *discovery.Consul     `yaml:"consul,omitempty"`
+		Podman     *discovery.Podman     `yaml:"podman,omitempty"`
+		Containerd *discovery.Containerd `yaml:"containerd,omitempty"`
+		Swarm      *discovery.Swarm      `yaml:"swarm,omitempty"`
+		File       *discovery.File       `yaml:"file,omitempty"`
+		DNS        *discovery.DNS        `yaml:"dns,omitempty"`
 	} `yaml:"discovery"`
 }
 
@@ -36,15 +51,29 @@ func (y *YAMLConfig) Enabled() bool {
 	return len(y.Variables) > 0 ||
 		y.Discovery.Docker != nil ||
 		y.Discovery.Fargate != nil ||
-		y.Discovery.Command != nil
+		y.Discovery.Command != nil ||
+		y.Discovery.Kubernetes != nil ||
+		y.Discovery.Consul != nil ||
+		y.Discovery.Podman != nil ||
+		y.Discovery.Containerd != nil ||
+		y.Discovery.Swarm != nil ||
+		y.Discovery.File != nil ||
+		y.Discovery.DNS != nil
 }
 
 type varEntry struct {
-	TTL         string               `yaml:"ttl,omitempty"`
-	KMS         *secrets.KMS         `yaml:"aws-kms,omitempty"`
-	Vault       *secrets.Vault       `yaml:"vault,omitempty"`
-	CyberArkCLI *secrets.CyberArkCLI `yaml:"cyberark-cli,omitempty"`
-	CyberArkAPI *secrets.CyberArkAPI `yaml:"cyberark-api,omitempty"`
+	TTL              string                    `yaml:"ttl,omitempty"`
+	KMS              *secrets.KMS              `yaml:"aws-kms,omitempty"`
+	SecretsManager   *secrets.SecretsManager   `yaml:"aws-secrets-manager,omitempty"`
+	ParameterStore   *secrets.ParameterStore   `yaml:"aws-parameter-store,omitempty"`
+	AzureKeyVault    *secrets.AzureKeyVault    `yaml:"azure-key-vault,omitempty"`
+	GCPSecretManager *secrets.GCPSecretManager `yaml:"gcp-secret-manager,omitempty"`
+	Vault            *secrets.Vault            `yaml:"vault,omitempty"`
+	CyberArkCLI      *secrets.CyberArkCLI      `yaml:"cyberark-cli,omitempty"`
+	CyberArkAPI      *secrets.CyberArkAPI      `yaml:"cyberark-api,omitempty"`
+	Conjur           *secrets.Conjur           `yaml:"conjur,omitempty"`
+	EncryptedFile    *secrets.EncryptedFile    `yaml:"encrypted-file,omitempty"`
+	Keyring          *secrets.Keyring          `yaml:"keyring,omitempty"`
 }
 
 // LoadYaml builds a set of data binding Sources from a YAML file
@@ -58,7 +87,8 @@ func LoadYAML(bytes []byte) (*Sources, error) {
 	return dc.DataSources()
 }
 
-// DataSources builds a set of data binding sources for the YAMLConfig instance.
+// DataSources builds a set of data binding sources for the YAMLConfig instance. The returned Sources
+// must be closed once they are not used anymore.
 func (dc *YAMLConfig) DataSources() (*Sources, error) {
 	if err := dc.validate(); err != nil {
 		return nil, fmt.Errorf("error parsing YAML configuration: %s", err)
@@ -69,12 +99,15 @@ func (dc *YAMLConfig) DataSources() (*Sources, error) {
 		return nil, err
 	}
 
+	ctx, cancel := context.WithCancel(context.Background())
 	cfg := Sources{
 		clock:     time.Now,
 		variables: map[string]*gatherer{},
+		cancel:    cancel,
 	}
-	cfg.discoverer, err = dc.selectDiscoverer(ttl)
+	cfg.discoverer, err = dc.selectDiscoverer(ctx, ttl)
 	if err != nil {
+		cancel()
 		return nil, err
 	}
 
@@ -104,7 +137,9 @@ func duration(fmt string, def time.Duration) (time.Duration, error) {
 	return duration, nil
 }
 
-func (dc *YAMLConfig) selectDiscoverer(ttl time.Duration) (*discoverer, error) {
+// selectDiscoverer returns the configured discoverer. The sources watched in background are stopped
+// when the passed context is cancelled.
+func (dc *YAMLConfig) selectDiscoverer(ctx context.Context, ttl time.Duration) (*discoverer, error) {
 	if dc.Discovery.Fargate != nil {
 		fetch, err := fargate.Discoverer(*dc.Discovery.Fargate)
 		return &discoverer{
@@ -113,10 +148,12 @@ func (dc *YAMLConfig) selectDiscoverer(ttl time.Duration) (*discoverer, error) {
 		}, err
 
 	} else if dc.Discovery.Docker != nil {
-		fetch, err := docker.Discoverer(*dc.Discovery.Docker)
+		changes := discovery.NewChanges()
+		fetch, err := docker.Discoverer(ctx, *dc.Discovery.Docker, changes)
 		return &discoverer{
-			cache: cachedEntry{ttl: ttl},
-			fetch: fetch,
+			cache:   cachedEntry{ttl: ttl},
+			fetch:   fetch,
+			changes: changes,
 		}, err
 
 	} else if dc.Discovery.Command != nil {
@@ -126,6 +163,58 @@ func (dc *YAMLConfig) selectDiscoverer(ttl time.Duration) (*discoverer, error) {
 			fetch: fetch,
 		}, err
 
+	} else if dc.Discovery.Kubernetes != nil {
+		changes := discovery.NewChanges()
+		fetch, err := kubernetes.Discoverer(ctx, *dc.Discovery.Kubernetes, changes)
+		return &discoverer{
+			cache:   cachedEntry{ttl: ttl},
+			fetch:   fetch,
+			changes: changes,
+		}, err
+
+	} else if dc.Discovery.Consul != nil {
+		fetch, err := consul.Discoverer(ctx, *dc.Discovery.Consul)
+		return &discoverer{
+			cache: cachedEntry{ttl: ttl},
+			fetch: fetch,
+		}, err
+
+	} else if dc.Discovery.Podman != nil {
+		changes := discovery.NewChanges()
+		fetch, err := podman.Discoverer(ctx, *dc.Discovery.Podman, changes)
+		return &discoverer{
+			cache:   cachedEntry{ttl: ttl},
+			fetch:   fetch,
+			changes: changes,
+		}, err
+
+	} else if dc.Discovery.Containerd != nil {
+		fetch, err := containerd.Discoverer(*dc.Discovery.Containerd)
+		return &discoverer{
+			cache: cachedEntry{ttl: ttl},
+			fetch: fetch,
+		}, err
+
+	} else if dc.Discovery.Swarm != nil {
+		fetch, err := swarm.Discoverer(*dc.Discovery.Swarm)
+		return &discoverer{
+			cache: cachedEntry{ttl: ttl},
+			fetch: fetch,
+		}, err
+
+	} else if dc.Discovery.File != nil {
+		fetch, err := file.Discoverer(ctx, *dc.Discovery.File)
+		return &discoverer{
+			cache: cachedEntry{ttl: ttl},
+			fetch: fetch,
+		}, err
+
+	} else if dc.Discovery.DNS != nil {
+		fetch, err := dns.Discoverer(*dc.Discovery.DNS)
+		return &discoverer{
+			cache: cachedEntry{ttl: ttl},
+			fetch: fetch,
+		}, err
 	}
 	return nil, nil
 }
@@ -152,6 +241,55 @@ func (y *YAMLConfig) validate() error {
 		}
 	}
 
+	if y.Discovery.Kubernetes != nil {
+		sections++
+		if err := y.Discovery.Kubernetes.Validate(); err != nil {
+			return err
+		}
+	}
+
+	if y.Discovery.Consul != nil {
+		sections++
+		if err := y.Discovery.Consul.Validate(); err != nil {
+			return err
+		}
+	}
+
+	if y.Discovery.Podman != nil {
+		sections++
+		if err := y.Discovery.Podman.Validate(); err != nil {
+			return err
+		}
+	}
+
+	if y.Discovery.Containerd != nil {
+		sections++
+		if err := y.Discovery.Containerd.Validate(); err != nil {
+			return err
+		}
+	}
+
+	if y.Discovery.Swarm != nil {
+		sections++
+		if err := y.Discovery.Swarm.Validate(); err != nil {
+			return err
+		}
+	}
+
+	if y.Discovery.File != nil {
+		sections++
+		if err := y.Discovery.File.Validate(); err != nil {
+			return err
+		}
+	}
+
+	if y.Discovery.DNS != nil {
+		sections++
+		if err := y.Discovery.DNS.Validate(); err != nil {
+			return err
+		}
+	}
+
 	if sections > 1 {
 		return errors.New("only one discovery source allowed")
 	}
@@ -178,6 +316,30 @@ func (v *varEntry) validate() error {
 			return err
 		}
 	}
+	if v.SecretsManager != nil {
+		sections++
+		if err := v.SecretsManager.Validate(); err != nil {
+			return err
+		}
+	}
+	if v.ParameterStore != nil {
+		sections++
+		if err := v.ParameterStore.Validate(); err != nil {
+			return err
+		}
+	}
+	if v.AzureKeyVault != nil {
+		sections++
+		if err := v.AzureKeyVault.Validate(); err != nil {
+			return err
+		}
+	}
+	if v.GCPSecretManager != nil {
+		sections++
+		if err := v.GCPSecretManager.Validate(); err != nil {
+			return err
+		}
+	}
 	if v.Vault != nil {
 		sections++
 		if err := v.Vault.Validate(); err != nil {
@@ -196,8 +358,26 @@ func (v *varEntry) validate() error {
 			return err
 		}
 	}
+	if v.Conjur != nil {
+		sections++
+		if err := v.Conjur.Validate(); err != nil {
+			return err
+		}
+	}
+	if v.EncryptedFile != nil {
+		sections++
+		if err := v.EncryptedFile.Validate(); err != nil {
+			return err
+		}
+	}
+	if v.Keyring != nil {
+		sections++
+		if err := v.Keyring.Validate(); err != nil {
+			return err
+		}
+	}
 	if sections == 0 {
-		return errors.New("you should specify one source to gather the variable: aws-kms or vault or cyberark-cli")
+		return errors.New("you should specify one source to gather the variable: aws-kms, aws-secrets-manager, aws-parameter-store, azure-key-vault, gcp-secret-manager, vault, cyberark-cli, cyberark-api, conjur, encrypted-file or keyring")
 	}
 	if sections > 1 {
 		return errors.New("you can't specify more than one source into a single variable. Use another variable")
@@ -212,6 +392,30 @@ func (v *varEntry) selectGatherer(ttl time.Duration) *gatherer {
 			fetch: secrets.KMSGatherer(v.KMS),
 		}
 
+	} else if v.SecretsManager != nil {
+		return &gatherer{
+			cache: cachedEntry{ttl: ttl},
+			fetch: secrets.SecretsManagerGatherer(v.SecretsManager),
+		}
+
+	} else if v.ParameterStore != nil {
+		return &gatherer{
+			cache: cachedEntry{ttl: ttl},
+			fetch: secrets.ParameterStoreGatherer(v.ParameterStore),
+		}
+
+	} else if v.AzureKeyVault != nil {
+		return &gatherer{
+			cache: cachedEntry{ttl: ttl},
+			fetch: secrets.AzureKeyVaultGatherer(v.AzureKeyVault),
+		}
+
+	} else if v.GCPSecretManager != nil {
+		return &gatherer{
+			cache: cachedEntry{ttl: ttl},
+			fetch: secrets.GCPSecretManagerGatherer(v.GCPSecretManager),
+		}
+
 	} else if v.Vault != nil {
 		return &gatherer{
 			cache: cachedEntry{ttl: ttl},
@@ -229,6 +433,24 @@ func (v *varEntry) selectGatherer(ttl time.Duration) *gatherer {
 			cache: cachedEntry{ttl: ttl},
 			fetch: secrets.CyberArkAPIGatherer(v.CyberArkAPI),
 		}
+
+	} else if v.Conjur != nil {
+		return &gatherer{
+			cache: cachedEntry{ttl: ttl},
+			fetch: secrets.ConjurGatherer(v.Conjur),
+		}
+
+	} else if v.EncryptedFile != nil {
+		return &gatherer{
+			cache: cachedEntry{ttl: ttl},
+			fetch: secrets.EncryptedFileGatherer(v.EncryptedFile),
+		}
+
+	} else if v.Keyring != nil {
+		return &gatherer{
+			cache: cachedEntry{ttl: ttl},
+			fetch: secrets.KeyringGatherer(v.Keyring),
+		}
 	}
 
 	// should never reach here as long as "varEntry.validate()" does its job
@@ -20,12 +20,68 @@ variables:
     aws-kms:
       data: T0hBSStGTEVY
       region: us-east-1
+`}, {"simple aws-secrets-manager variable", `
+variables:
+  creds:
+    aws-secrets-manager:
+      secret_id: arn:aws:secretsmanager:us-east-1:123456789012:secret:prod/mysql-AbCdEf
+      key: password
+      role_arn: arn:aws:iam::123456789012:role/newrelic-secrets
+`}, {"aws-parameter-store variables", `
+variables:
+  token:
+    aws-parameter-store:
+      name: /prod/api/token
+  mysql:
+    ttl: 1h
+    aws-parameter-store:
+      path: /prod/mysql
+      recursive: true
+      region: eu-west-1
+`}, {"azure-key-vault variable", `
+variables:
+  license:
+    azure-key-vault:
+      vault_url: https://my-vault.vault.azure.net
+      secret: newrelic-license
+      tenant_id: 72f988bf-86f1-41af-91ab-2d7cd011db47
+      client_id: 04b07795-8ddb-461a-bbee-02f9e1bf7b46
+      client_secret: app-secret
+`}, {"gcp-secret-manager variables", `
+variables:
+  mysql:
+    ttl: 1h
+    gcp-secret-manager:
+      project: my-project
+      secret: mysql
+  token:
+    gcp-secret-manager:
+      secret: projects/my-project/secrets/api-token/versions/3
+      type: plain
+      credential_file: /etc/newrelic-infra/gcp-key.json
 `}, {"simple vault variable", `
 variables:
   myData:
     vault:
       http:
         url: http://www.example.com
+`}, {"vault variables with auth methods", `
+variables:
+  mysql:
+    vault:
+      http:
+        url: https://vault:8200/v1/secret/data/mysql
+      auth:
+        approle:
+          role_id: 675a50e7-cfe0-be76-e35f-49ec009731ea
+          secret_id_file: /etc/newrelic-infra/vault-secret-id
+  redis:
+    vault:
+      http:
+        url: https://vault:8200/v1/secret/data/redis
+      auth:
+        kubernetes:
+          role: newrelic-infra
 `}, {"simple cyberark-cli variable", `
 variables:
   myData:
@@ -41,6 +97,48 @@ variables:
     cyberark-api:
       http:
         url: https://10.1.0.5/AIMWebService/api/Accounts?AppID=NewRelic&Query=Safe=ALL-NERE-WIN-A-NEWRELIC-UP;Object=ALL-localhost-testuser
+`}, {"conjur variables", `
+variables:
+  mysql:
+    conjur:
+      url: https://conjur.example.com
+      account: myorg
+      login: host/infra/agent-01
+      variable: prod/mysql/password
+      api_key_file: /etc/newrelic-infra/conjur-api-key
+  redis:
+    conjur:
+      url: https://conjur.example.com
+      account: myorg
+      login: host/infra/agent-01
+      variable: prod/redis
+      type: json
+      authenticator: authn-cert/prod
+      tls_config:
+        ca: /etc/conjur/ca.pem
+        cert_file: /etc/conjur/agent.pem
+        key_file: /etc/conjur/agent-key.pem
+`}, {"encrypted file variables", `
+variables:
+  creds:
+    encrypted-file:
+      file: /etc/newrelic-infra/secrets.enc
+      key_env: NRIA_SECRETS_KEY
+  other:
+    encrypted-file:
+      file: /etc/newrelic-infra/other.enc
+      keystore:
+        service: newrelic-infra
+        account: secrets
+`}, {"keyring variables", `
+variables:
+  mssql:
+    keyring:
+      service: newrelic-infra/mssql
+      account: monitor
+  redis:
+    keyring:
+      service: redis
 `}}
 	for _, input := range inputs {
 		t.Run(input.description, func(t *testing.T) {
@@ -68,6 +166,46 @@ variables:
   myData:
     aws-kms:
       region: us-east-1
+`}, {"aws-secrets-manager variable without secret", `
+variables:
+  myData:
+    aws-secrets-manager:
+      region: us-east-1
+`}, {"aws-secrets-manager key from a plain secret", `
+variables:
+  myData:
+    aws-secrets-manager:
+      secret_id: prod/mysql
+      type: plain
+      key: password
+`}, {"aws-parameter-store with name and path", `
+variables:
+  myData:
+    aws-parameter-store:
+      name: /prod/mysql/password
+      path: /prod/mysql
+`}, {"aws-parameter-store relative path", `
+variables:
+  myData:
+    aws-parameter-store:
+      path: prod/mysql
+`}, {"azure-key-vault without secret", `
+variables:
+  myData:
+    azure-key-vault:
+      vault_url: https://my-vault.vault.azure.net
+`}, {"gcp-secret-manager without project", `
+variables:
+  myData:
+    gcp-secret-manager:
+      secret: mysql
+`}, {"vault auth without method", `
+variables:
+  myData:
+    vault:
+      http:
+        url: https://vault:8200/v1/secret/data/mysql
+      auth: {}
 `}, {"empty variable name", `
 variables:
   :    
@@ -97,7 +235,87 @@ variables:
     cyberark-api:
       http:
         url: 
-      `}}
+      `}, {"conjur variable without credentials", `
+variables:
+  myData:
+    conjur:
+      url: https://conjur.example.com
+      account: myorg
+      login: host/infra/agent-01
+      variable: prod/mysql/password
+      `}, {"encrypted file variable without key", `
+variables:
+  myData:
+    encrypted-file:
+      file: /etc/newrelic-infra/secrets.enc
+      `}, {"keyring variable without service", `
+variables:
+  myData:
+    keyring:
+      account: monitor
+      `}, {"kubernetes discovery without match", `
+discovery:
+  kubernetes:
+    namespace: default
+`}, {"unsupported kubernetes kind", `
+discovery:
+  kubernetes:
+    kind: deployment
+    match:
+      label.app: redis
+`}, {"consul discovery without filters", `
+discovery:
+  consul:
+    address: consul:8500
+`}, {"consul agent source with passing only", `
+discovery:
+  consul:
+    source: agent
+    passing_only: true
+    services: [redis]
+`}, {"podman discovery without match", `
+discovery:
+  podman:
+    socket: /run/podman/podman.sock
+`}, {"podman discovery through tcp", `
+discovery:
+  podman:
+    socket: tcp://127.0.0.1:8888
+    match:
+      image: /redis/
+`}, {"containerd discovery without match", `
+discovery:
+  containerd:
+    api: cri
+`}, {"containerd discovery with unknown api", `
+discovery:
+  containerd:
+    api: docker
+    match:
+      image: /redis/
+`}, {"swarm discovery without match", `
+discovery:
+  swarm:
+    api_version: "1.40"
+`}, {"file discovery without files", `
+discovery:
+  file:
+    match:
+      label.env: production
+`}, {"file discovery with invalid pattern", `
+discovery:
+  file:
+    files: ["/etc/targets/[.yml"]
+`}, {"dns discovery without record", `
+discovery:
+  dns:
+    server: 10.0.0.2
+`}, {"dns discovery with invalid timeout", `
+discovery:
+  dns:
+    record: _redis._tcp.example.com
+    timeout: 5
+`}}
 	for _, input := range inputs {
 		t.Run(input.description, func(t *testing.T) {
 			_, err := LoadYAML([]byte(input.yaml))
@@ -0,0 +1,176 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package databind
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// function transforms the value of a variable placeholder, as in ${variable | function "arg"}.
+type function struct {
+	args  int
+	apply func(value string, args []string) (string, error)
+}
+
+const defaultFunc = "default"
+
+// functions that can be applied in a placeholder pipeline, with a syntax similar to the Sprig templates.
+// The "default" function is handled apart, as it is the only one applied to missing variables.
+var functions = map[string]function{
+	"trim": {apply: func(value string, _ []string) (string, error) {
+		return strings.TrimSpace(value), nil
+	}},
+	"lower": {apply: func(value string, _ []string) (string, error) {
+		return strings.ToLower(value), nil
+	}},
+	"b64enc": {apply: func(value string, _ []string) (string, error) {
+		return base64.StdEncoding.EncodeToString([]byte(value)), nil
+	}},
+	// regexReplace "regex" "replacement": the replacement may refer to the submatches as $1
+	"regexReplace": {args: 2, apply: func(value string, args []string) (string, error) {
+		re, err := regexp.Compile(args[0])
+		if err != nil {
+			return "", err
+		}
+		return re.ReplaceAllString(value, args[1]), nil
+	}},
+}
+
+// call to a function in a placeholder pipeline.
+type call struct {
+	name string
+	args []string
+}
+
+// parsePlaceholder returns the variable name of a ${variable | function "arg" | ...} placeholder
+// and the functions to apply to its value. A quoted string as a function name is a shortcut for
+// the default function, so ${variable | "value"} equals ${variable | default "value"}.
+func parsePlaceholder(placeholder string) (string, []call, error) {
+	segments, err := split(strings.TrimSpace(placeholder))
+	if err != nil {
+		return "", nil, err
+	}
+	varName := strings.TrimSpace(segments[0])
+	var calls []call
+	for _, segment := range segments[1:] {
+		tokens, err := words(segment)
+		if err != nil {
+			return "", nil, err
+		}
+		if len(tokens) == 0 {
+			return "", nil, errors.New("empty function in placeholder: " + placeholder)
+		}
+		name := tokens[0]
+		if strings.HasPrefix(name, `"`) {
+			name = defaultFunc
+		} else {
+			tokens = tokens[1:]
+		}
+		args := make([]string, 0, len(tokens))
+		for _, word := range tokens {
+			if strings.HasPrefix(word, `"`) {
+				if word, err = strconv.Unquote(word); err != nil {
+					return "", nil, fmt.Errorf("invalid string in placeholder %s: %s", placeholder, err)
+				}
+			}
+			args = append(args, word)
+		}
+		expected := 1
+		if name != defaultFunc {
+			f, ok := functions[name]
+			if !ok {
+				return "", nil, fmt.Errorf("unknown function %q in placeholder: %s", name, placeholder)
+			}
+			expected = f.args
+		}
+		if len(args) != expected {
+			return "", nil, fmt.Errorf("function %q expects %d arguments in placeholder: %s", name, expected, placeholder)
+		}
+		calls = append(calls, call{name: name, args: args})
+	}
+	return varName, calls, nil
+}
+
+// applyCalls applies the functions of a placeholder pipeline to the value of its variable, which
+// may have not been found.
+func applyCalls(value string, found bool, calls []call) (string, bool, error) {
+	for _, c := range calls {
+		if c.name == defaultFunc {
+			if !found || value == "" {
+				value, found = c.args[0], true
+			}
+			continue
+		}
+		if !found {
+			continue
+		}
+		var err error
+		if value, err = functions[c.name].apply(value, c.args); err != nil {
+			return "", false, fmt.Errorf("applying %s: %s", c.name, err)
+		}
+	}
+	return value, found, nil
+}
+
+// split separates the pipeline segments of a placeholder, ignoring the pipes within quoted strings.
+func split(placeholder string) ([]string, error) {
+	var segments []string
+	start, quoted := 0, false
+	for i := 0; i < len(placeholder); i++ {
+		switch placeholder[i] {
+		case '\\':
+			if quoted {
+				i++
+			}
+		case '"':
+			quoted = !quoted
+		case '|':
+			if !quoted {
+				segments = append(segments, placeholder[start:i])
+				start = i + 1
+			}
+		}
+	}
+	if quoted {
+		return nil, errors.New("unterminated string in placeholder: " + placeholder)
+	}
+	return append(segments, placeholder[start:]), nil
+}
+
+// words separates the function name and arguments of a pipeline segment, keeping the quoted
+// strings, with their quotes, as single words.
+func words(segment string) ([]string, error) {
+	var words []string
+	for i := 0; i < len(segment); {
+		switch {
+		case segment[i] == ' ' || segment[i] == '\t':
+			i++
+		case segment[i] == '"':
+			end := i + 1
+			for ; end < len(segment) && segment[end] != '"'; end++ {
+				if segment[end] == '\\' {
+					end++
+				}
+			}
+			if end >= len(segment) {
+				return nil, errors.New("unterminated string: " + segment)
+			}
+			words = append(words, segment[i:end+1])
+			i = end + 1
+		default:
+			end := strings.IndexAny(segment[i:], " \t")
+			if end < 0 {
+				end = len(segment) - i
+			}
+			words = append(words, segment[i:i+end])
+			i += end
+		}
+	}
+	return words, nil
+}
@@ -0,0 +1,86 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package databind
+
+import (
+	"testing"
+
+	"github.com/newrelic/infrastructure-agent/pkg/databind/internal/discovery"
+	"github.com/newrelic/infrastructure-agent/pkg/databind/pkg/data"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReplaceBytes_Functions(t *testing.T) {
+	ctx := &Values{
+		vars: map[string]string{"creds.user": "admin"},
+		discov: []discovery.Discovery{{Variables: data.Map{
+			"discovery.ip":           "10.0.0.5",
+			"discovery.name":         "  Web-Frontend  ",
+			"discovery.label.path":   "",
+			"discovery.label.domain": "shop.example.com",
+		}}},
+	}
+	tests := []struct {
+		template string
+		expected string
+	}{
+		{`${discovery.name | trim | lower}`, "web-frontend"},
+		{`${discovery.label.path | default "status"}`, "status"},
+		{`${discovery.label.missing | default "status" | upper_ignored}`, ""},
+		{`${discovery.label.missing|"status"}`, "status"},
+		{`${creds.user | b64enc}`, "YWRtaW4="},
+		{`${discovery.label.domain | regexReplace "^([a-z]+)\\..*$" "$1"}`, "shop"},
+		{`http://${discovery.ip}:${discovery.label.port | default "80"}/${discovery.label.path | "status"}`, "http://10.0.0.5:80/status"},
+		{`${discovery.label.missing | default "A|B}" | lower}`, "a|b}"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.template, func(t *testing.T) {
+			ret, err := ReplaceBytes(ctx, []byte(tt.template))
+			if tt.expected == "" {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Len(t, ret, 1)
+			assert.Equal(t, tt.expected, string(ret[0]))
+		})
+	}
+}
+
+func TestReplaceBytes_FunctionsOnMissingVariable(t *testing.T) {
+	ctx := &Values{discov: []discovery.Discovery{{Variables: data.Map{"discovery.ip": "10.0.0.5"}}}}
+
+	// functions other than default don't make a missing variable available
+	_, err := ReplaceBytes(ctx, []byte(`${discovery.port | trim}`))
+	assert.EqualError(t, err, "value not found: discovery.port")
+
+	// default can be applied after other functions
+	ret, err := ReplaceBytes(ctx, []byte(`${discovery.port | trim | default "8080"}`))
+	require.NoError(t, err)
+	assert.Equal(t, [][]byte{[]byte("8080")}, ret)
+}
+
+func TestParsePlaceholder(t *testing.T) {
+	varName, calls, err := parsePlaceholder(` discovery.name | regexReplace "a\"b" "c" | default "x" `)
+	require.NoError(t, err)
+	assert.Equal(t, "discovery.name", varName)
+	assert.Equal(t, []call{
+		{name: "regexReplace", args: []string{`a"b`, "c"}},
+		{name: "default", args: []string{"x"}},
+	}, calls)
+
+	for _, invalid := range []string{
+		`name | unknown`,
+		`name | default`,
+		`name | regexReplace "a"`,
+		`name | trim "a"`,
+		`name | default "unterminated`,
+		`name | `,
+	} {
+		_, _, err := parsePlaceholder(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
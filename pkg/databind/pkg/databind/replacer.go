@@ -4,10 +4,10 @@
 package databind
 
 import (
-	"bytes"
 	"errors"
 	"reflect"
 	"regexp"
+	"sort"
 	"unsafe"
 
 	"github.com/newrelic/infrastructure-agent/pkg/databind/internal/discovery"
@@ -22,8 +22,9 @@ type replaceConfig struct {
 // Option provide extra behaviour configuration to the replacement process.
 type ReplaceOption func(rc *replaceConfig)
 
-// This regular expression matches any variable mark ${...} with dots and index marks [ ]
-var regex = regexp.MustCompile(`\$\{[\w\d\._\s\[\]-]*\}`)
+// This regular expression matches any variable mark ${...} with dots and index marks [ ], optionally
+// followed by a pipeline of functions, whose quoted arguments may contain any character
+var regex = regexp.MustCompile(`\$\{[\w\d\._\s\[\]-]*(?:\|(?:[^{}"]|"(?:[^"\\]|\\.)*")*)?\}`)
 
 // Replace receives one template, which may be a map or a struct whose string fields may
 // contain ${variable} placeholders, and returns an array of items of the same type of the
@@ -63,6 +64,25 @@ func Replace(vals *Values, template interface{}, options ...ReplaceOption) (tran
 	return replaceAllSources(template, discoverySources, varSrc, rc)
 }
 
+// VariableNames returns the sorted names of the variables referenced by the ${...} placeholders of
+// the template, e.g. creds for ${creds.password}.
+func VariableNames(template []byte) []string {
+	found := map[string]bool{}
+	for _, match := range regex.FindAll(template, -1) {
+		varName, _, err := parsePlaceholder(string(match[2 : len(match)-1]))
+		if err != nil || varName == "" {
+			continue
+		}
+		found[variableName(varName)] = true
+	}
+	names := make([]string, 0, len(found))
+	for name := range found {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
 // ReplaceBytes receives a byte array that may  contain ${variable} placeholders,
 // and returns an array of byte arrays replacing the variable placeholders from the respective Values.
 func ReplaceBytes(vals *Values, template []byte, options ...ReplaceOption) ([][]byte, error) {
@@ -261,21 +281,39 @@ func replaceBytes(values []data.Map, template []byte, rc replaceConfig, nMatches
 // replaces a variable mark from its corresponding variable or discovered item.
 func variable(values []data.Map, match []byte, rc replaceConfig) ([]byte, error) {
 	// removing ${...}
-	varName := string(bytes.Trim(match, "${}\n\r\t "))
+	varName, calls, err := parsePlaceholder(string(match[2 : len(match)-1]))
+	if err != nil {
+		return match, err
+	}
+
+	value, found := lookup(values, varName, rc)
+	if len(calls) > 0 {
+		var replaced string
+		if replaced, found, err = applyCalls(string(value), found, calls); err != nil {
+			return match, err
+		}
+		value = []byte(replaced)
+	}
+	if found {
+		return value, nil
+	}
 
+	// if the value is not found, returns the match itself
+	return match, errors.New("value not found: " + varName)
+}
+
+func lookup(values []data.Map, varName string, rc replaceConfig) ([]byte, bool) {
 	for _, vmap := range values {
 		if value, ok := vmap[varName]; ok {
-			return []byte(value), nil
+			return []byte(value), true
 		}
 	}
 
 	// if not found in the discovered/variables static sources, we ask dynamically for it
 	for _, onDemand := range rc.onDemand {
 		if value, ok := onDemand(varName); ok {
-			return value, nil
+			return value, true
 		}
 	}
-
-	// if the value is not found, returns the match itself
-	return match, errors.New("value not found: " + varName)
+	return nil, false
 }
@@ -675,3 +675,14 @@ func TestReplace_EntityRewrite(t *testing.T) {
 		}
 	})
 }
+
+func TestVariableNames(t *testing.T) {
+	template := []byte(`user: ${creds.user}
+password: ${ creds.password | trim }
+hosts: ${hosts[0]},${discovery.ip}
+token: ${token|default "${fallback}"}
+literal: $notAVariable`)
+
+	assert.Equal(t, []string{"creds", "discovery", "hosts", "token"}, VariableNames(template))
+	assert.Empty(t, VariableNames([]byte("no variables")))
+}
@@ -11,12 +11,15 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"time"
 )
 
 // Entity information.
 type Entity struct {
 	Key
 	ID
+	// TTL is the time an ephemeral entity is kept without reporting. Zero for long-lived entities
+	TTL time.Duration
 }
 
 // New will create a new Entity object.
@@ -115,6 +118,9 @@ type Fields struct {
 	IDAttributes IDAttributes           `json:"id_attributes"`
 	DisplayName  string                 `json:"displayName"`
 	Metadata     map[string]interface{} `json:"metadata"`
+	// Lifecycle and TTL hint the expiration of short-lived entities
+	Lifecycle Lifecycle `json:"lifecycle,omitempty"`
+	TTL       TTL       `json:"ttl,omitempty"`
 }
 
 // JsonSize will return the size of the json serialization.
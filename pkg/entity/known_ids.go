@@ -73,6 +73,14 @@ func (k *KnownIDs) PutType(entityType Type, key Key, id ID) {
 	}
 }
 
+// PutTTL registers an entity ID for a given entity Key, with a custom TTL.
+func (k *KnownIDs) PutTTL(key Key, id ID, ttl time.Duration) {
+	k.lock.Lock()
+	defer k.lock.Unlock()
+
+	k.putTTL(key, id, ttl)
+}
+
 func (k *KnownIDs) putTTL(key Key, id ID, ttl time.Duration) {
 	k.ids[key] = &idEntry{
 		id:         id,
@@ -202,3 +202,26 @@ func TestKnownIDs_CleanOld_DontChangeExpirations(t *testing.T) {
 	_, ok := kn.Get("entity-1")
 	assert.False(t, ok)
 }
+
+func TestKnownIDs_PutTTL(t *testing.T) {
+	// Given a Key to IDs map
+	kn := NewKnownIDs()
+
+	// When adding an entry with a custom TTL
+	setNow(0, 00, 00)
+	kn.PutTTL("ephemeral", 12345, 10*time.Minute)
+	kn.Put("entity", 54321)
+
+	// The entry is returned before its TTL expires
+	setNow(0, 00, 05)
+	id, ok := kn.Get("ephemeral")
+	assert.True(t, ok)
+	assert.EqualValues(t, 12345, id)
+
+	// And it's not returned once its TTL expires, unlike the default TTL entries
+	setNow(0, 00, 20)
+	_, ok = kn.Get("ephemeral")
+	assert.False(t, ok)
+	_, ok = kn.Get("entity")
+	assert.True(t, ok)
+}
@@ -0,0 +1,82 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package entity
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Lifecycle hints how long an entity lives.
+type Lifecycle string
+
+// LifecycleEphemeral entities, as batch jobs or CI runners, are short-lived. They expire once they
+// haven't reported for their TTL, instead of being kept until the remove_entities_period.
+const LifecycleEphemeral Lifecycle = "ephemeral"
+
+// DefaultEphemeralTTL is the TTL of the ephemeral entities not providing it.
+const DefaultEphemeralTTL = time.Hour
+
+// Registration metadata keys for the lifecycle hints.
+const (
+	MetadataLifecycle = "lifecycle"
+	MetadataTTL       = "ttl"
+)
+
+// TTL is the time an entity is kept without reporting. It's provided either as a number of seconds
+// or as a duration string, as "10m".
+type TTL time.Duration
+
+func (t *TTL) UnmarshalJSON(b []byte) error {
+	var seconds float64
+	if err := json.Unmarshal(b, &seconds); err == nil {
+		*t = TTL(seconds * float64(time.Second))
+		return nil
+	}
+	var duration string
+	if err := json.Unmarshal(b, &duration); err != nil {
+		return fmt.Errorf("invalid entity ttl %s: expected a number of seconds or a duration", b)
+	}
+	d, err := time.ParseDuration(duration)
+	if err != nil {
+		return fmt.Errorf("invalid entity ttl: %s", err)
+	}
+	*t = TTL(d)
+	return nil
+}
+
+// MarshalJSON returns the TTL as a number of seconds.
+func (t TTL) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(t).Seconds())
+}
+
+// IsEphemeral returns whether the entity is short-lived.
+func (f *Fields) IsEphemeral() bool {
+	return f.Lifecycle == LifecycleEphemeral
+}
+
+// Expiration returns the time an ephemeral entity is kept without reporting, or zero for the
+// long-lived entities.
+func (f *Fields) Expiration() time.Duration {
+	if !f.IsEphemeral() {
+		return 0
+	}
+	if f.TTL > 0 {
+		return time.Duration(f.TTL)
+	}
+	return DefaultEphemeralTTL
+}
+
+// LifecycleMetadata returns the lifecycle hints of an ephemeral entity, to be submitted along with its
+// registration metadata. Long-lived entities don't provide any.
+func (f *Fields) LifecycleMetadata() map[string]string {
+	if !f.IsEphemeral() {
+		return nil
+	}
+	return map[string]string{
+		MetadataLifecycle: string(f.Lifecycle),
+		MetadataTTL:       strconv.FormatInt(int64(f.Expiration().Seconds()), 10),
+	}
+}
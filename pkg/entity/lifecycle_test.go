@@ -0,0 +1,71 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package entity
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFields_Lifecycle(t *testing.T) {
+	for name, tc := range map[string]struct {
+		payload    string
+		expiration time.Duration
+		metadata   map[string]string
+	}{
+		"long-lived": {
+			payload: `{"name":"host","type":"HOST"}`,
+		},
+		"ttl without lifecycle": {
+			payload: `{"name":"host","type":"HOST","ttl":60}`,
+		},
+		"ephemeral without ttl": {
+			payload:    `{"name":"job","type":"BATCH_JOB","lifecycle":"ephemeral"}`,
+			expiration: DefaultEphemeralTTL,
+			metadata:   map[string]string{"lifecycle": "ephemeral", "ttl": "3600"},
+		},
+		"ttl in seconds": {
+			payload:    `{"name":"job","type":"BATCH_JOB","lifecycle":"ephemeral","ttl":90}`,
+			expiration: 90 * time.Second,
+			metadata:   map[string]string{"lifecycle": "ephemeral", "ttl": "90"},
+		},
+		"ttl as duration": {
+			payload:    `{"name":"job","type":"BATCH_JOB","lifecycle":"ephemeral","ttl":"10m"}`,
+			expiration: 10 * time.Minute,
+			metadata:   map[string]string{"lifecycle": "ephemeral", "ttl": "600"},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			var f Fields
+			require.NoError(t, json.Unmarshal([]byte(tc.payload), &f))
+			assert.Equal(t, tc.expiration, f.Expiration())
+			assert.Equal(t, tc.metadata, f.LifecycleMetadata())
+		})
+	}
+}
+
+func TestFields_Lifecycle_InvalidTTL(t *testing.T) {
+	for _, payload := range []string{
+		`{"name":"job","lifecycle":"ephemeral","ttl":"soon"}`,
+		`{"name":"job","lifecycle":"ephemeral","ttl":true}`,
+	} {
+		var f Fields
+		assert.Error(t, json.Unmarshal([]byte(payload), &f), payload)
+	}
+}
+
+func TestFields_Lifecycle_Marshal(t *testing.T) {
+	f := Fields{Name: "job", Type: "BATCH_JOB", Lifecycle: LifecycleEphemeral, TTL: TTL(90 * time.Second)}
+	b, err := json.Marshal(f)
+	require.NoError(t, err)
+	assert.Contains(t, string(b), `"lifecycle":"ephemeral","ttl":90`)
+
+	b, err = json.Marshal(Fields{Name: "host"})
+	require.NoError(t, err)
+	assert.NotContains(t, string(b), "lifecycle")
+	assert.NotContains(t, string(b), "ttl")
+}
@@ -710,7 +710,9 @@ func EmitDataSet(
 
 	if len(dataSet.Inventory) > 0 {
 		inventoryDataSet := BuildInventoryDataSet(elog, dataSet.Inventory, labels, integrationUser, pluginName, entityKey.String())
-		emitter.EmitInventory(inventoryDataSet, entity.NewWithoutID(entityKey))
+		e := entity.NewWithoutID(entityKey)
+		e.TTL = dataSet.Entity.Expiration()
+		emitter.EmitInventory(inventoryDataSet, e)
 	}
 
 	for _, metric := range dataSet.Metrics {
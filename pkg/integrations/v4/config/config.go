@@ -5,8 +5,12 @@ package config
 import (
 	"errors"
 	"fmt"
-	"github.com/google/shlex"
 	"time"
+
+	"github.com/docker/go-units"
+	"github.com/google/shlex"
+
+	"github.com/newrelic/infrastructure-agent/pkg/schedule"
 )
 
 // ConfigEntry holds an integrations YAML configuration entry. It may define multiple types of tasks
@@ -17,10 +21,30 @@ type ConfigEntry struct {
 	Env          map[string]string `yaml:"env"`      // User-defined environment variables
 	Interval     string            `yaml:"interval"` // User-defined interval string (duration notation)
 	Timeout      *time.Duration    `yaml:"timeout"`
+	GracePeriod  *time.Duration    `yaml:"termination_grace_period"` // time given to a timed out integration before being killed
 	User         string            `yaml:"integration_user"`
 	WorkDir      string            `yaml:"working_dir"`
 	Labels       map[string]string `yaml:"labels"`
 	When         EnableConditions  `yaml:"when"`
+	// Builtin runs a collector embedded in the agent instead of an executable, followed by its
+	// arguments. E.g. "nginx_status http://127.0.0.1/nginx_status"
+	Builtin ShlexOpt `yaml:"builtin"`
+	// MaxOutputSize limits the size of each payload written by the integration. Unset or zero: no limit
+	MaxOutputSize ByteSize `yaml:"max_output_size"`
+	// MaxOutputStrategy is applied to the payloads exceeding MaxOutputSize: "truncate" (default) or "drop"
+	MaxOutputStrategy string `yaml:"max_output_strategy"`
+	// Retries re-executes a failed integration within the same interval
+	Retries Retries `yaml:"retries"`
+	// DerivedMetrics replaces the raw counters reported by the integration by their per-second rate or
+	// their delta since the previous execution
+	DerivedMetrics []DerivedMetric `yaml:"derived_metrics"`
+	// UnitConversions converts the values of the matching metrics to a common unit before being emitted
+	UnitConversions []UnitConversion `yaml:"unit_conversions"`
+	// ServeStale re-emits the last successful output of the integration, tagged with the "stale" attribute,
+	// when an execution fails or times out, as long as it's not older than this duration. Unset or zero: disabled
+	ServeStale time.Duration `yaml:"serve_stale"`
+	// SilentWindows are recurring periods during which the integration isn't executed, or its data is tagged
+	SilentWindows []SilentWindow `yaml:"silent_windows"`
 
 	// Legacy definition commands
 	Command         string            `yaml:"command"`
@@ -34,6 +58,90 @@ type ConfigEntry struct {
 	TemplatePath string `yaml:"config_template_path"`
 }
 
+// Strategies for the payloads exceeding the max_output_size.
+const (
+	// MaxOutputTruncate emits the complete datasets fitting within the max_output_size.
+	MaxOutputTruncate = "truncate"
+	// MaxOutputDrop discards the whole payload, submitting an event that reports it.
+	MaxOutputDrop = "drop"
+)
+
+// Retries configures the re-execution of a failed integration instance, so transient failures don't
+// have to wait until the next interval.
+type Retries struct {
+	// Count is the maximum number of re-executions after a failure. Unset or zero: no retries
+	Count int `yaml:"count"`
+	// Backoff is the time to wait before the first re-execution, doubled after each retry
+	Backoff time.Duration `yaml:"backoff"`
+}
+
+// Derivations of the DerivedMetric values.
+const (
+	// DeriveRate computes the per-second rate of change of the metric.
+	DeriveRate = "rate"
+	// DeriveDelta computes the difference with the previous value of the metric.
+	DeriveDelta = "delta"
+)
+
+// DerivedMetric selects a counter metric of the integration to be replaced by a derived value.
+type DerivedMetric struct {
+	// Metric is the name of the metric, or a regular expression between slashes. E.g. "/^net\./"
+	Metric string `yaml:"metric"`
+	// Type of derivation: "rate" (default) or "delta"
+	Type string `yaml:"type"`
+}
+
+// UnitConversion converts the values of the matching metrics between units of the same kind, as
+// "bytes" to "MB", "s" to "ms" or "celsius" to "fahrenheit".
+type UnitConversion struct {
+	// Metric is the name of the metric, or a regular expression between slashes. E.g. "/\.bytes$/"
+	Metric string `yaml:"metric"`
+	// From is the unit of the reported values. If empty, the unit declared by each metric is used
+	From string `yaml:"from"`
+	// To is the unit the values are converted to
+	To string `yaml:"to"`
+}
+
+// SilentWindow is a recurring period starting at the times matching a cron expression, as "0 2 * * *".
+type SilentWindow struct {
+	Name     string `yaml:"name"`
+	Schedule string `yaml:"schedule"`
+	// Duration of each occurrence of the window, as "30m"
+	Duration string `yaml:"duration"`
+	// Timezone of the schedule, as "Europe/Madrid". If empty, the local time is used
+	Timezone string `yaml:"timezone"`
+	// Action during the window: "pause" (default) skips the executions and "tag" adds the "silentWindow"
+	// attribute to the emitted data
+	Action string `yaml:"action"`
+}
+
+// Window returns the schedule of the silent window.
+func (sw SilentWindow) Window() (*schedule.Window, error) {
+	return schedule.NewWindow(sw.Name, sw.Schedule, sw.Duration, sw.Timezone, sw.Action)
+}
+
+// ByteSize is a size in bytes that can be provided either as a number or as a human readable
+// string, as "512KB" or "10MB". Units are powers of 1024.
+type ByteSize int64
+
+func (b *ByteSize) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var size int64
+	if err := unmarshal(&size); err == nil {
+		*b = ByteSize(size)
+		return nil
+	}
+	var human string
+	if err := unmarshal(&human); err != nil {
+		return err
+	}
+	size, err := units.RAMInBytes(human)
+	if err != nil {
+		return err
+	}
+	*b = ByteSize(size)
+	return nil
+}
+
 // EnableConditions condition the execution of an integration to the trueness of ALL the conditions
 type EnableConditions struct {
 	// Feature allows enabling/disabling the OHI via agent cfg "feature" or cmd-channel Feature Flag
@@ -43,6 +151,11 @@ type EnableConditions struct {
 	// EnvExists conditions the execution of the OHI only if the given
 	// environment variables exists and match the value.
 	EnvExists map[string]string `yaml:"env_exists"`
+	// PortOpen conditions the execution of the OHI only if a TCP connection can be established
+	// to the given "host:port" address, or localhost port.
+	PortOpen string `yaml:"port_open"`
+	// ProcessRunning conditions the execution of the OHI only if a process with the given name is running.
+	ProcessRunning string `yaml:"process_running"`
 }
 
 // ShlexOpt is a wrapper around []string so we can use go-shlex for shell tokenizing
@@ -90,6 +203,11 @@ func (s *ShlexOpt) Value() []string {
 
 // checks that the format is correct and fixes possible nil leaks with default values
 func (cf *ConfigEntry) Sanitize() error {
+	// built-in collectors don't require a name
+	if cf.InstanceName == "" && len(cf.Builtin) > 0 {
+		cf.InstanceName = cf.Builtin[0]
+	}
+
 	if cf.InstanceName == "" {
 		return errors.New("integration entry requires a non-empty 'name' field")
 	}
@@ -98,6 +216,59 @@ func (cf *ConfigEntry) Sanitize() error {
 		return errors.New("use either 'exec' or 'cli_args' but not both")
 	}
 
+	if cf.MaxOutputSize < 0 {
+		return errors.New("'max_output_size' can't be negative")
+	}
+	switch cf.MaxOutputStrategy {
+	case "":
+		cf.MaxOutputStrategy = MaxOutputTruncate
+	case MaxOutputTruncate, MaxOutputDrop:
+	default:
+		return fmt.Errorf("invalid 'max_output_strategy' %q. Use either %q or %q",
+			cf.MaxOutputStrategy, MaxOutputTruncate, MaxOutputDrop)
+	}
+
+	if cf.Retries.Count < 0 {
+		return errors.New("'retries.count' can't be negative")
+	}
+	if cf.Retries.Backoff < 0 {
+		return errors.New("'retries.backoff' can't be negative")
+	}
+
+	for i := range cf.DerivedMetrics {
+		dm := &cf.DerivedMetrics[i]
+		if dm.Metric == "" {
+			return errors.New("'derived_metrics' entries require a non-empty 'metric' field")
+		}
+		switch dm.Type {
+		case "":
+			dm.Type = DeriveRate
+		case DeriveRate, DeriveDelta:
+		default:
+			return fmt.Errorf("invalid 'derived_metrics' type %q. Use either %q or %q", dm.Type, DeriveRate, DeriveDelta)
+		}
+	}
+
+	for _, uc := range cf.UnitConversions {
+		if uc.Metric == "" || uc.To == "" {
+			return errors.New("'unit_conversions' entries require non-empty 'metric' and 'to' fields")
+		}
+	}
+
+	if cf.ServeStale < 0 {
+		return errors.New("'serve_stale' can't be negative")
+	}
+
+	for _, sw := range cf.SilentWindows {
+		if _, err := sw.Window(); err != nil {
+			return fmt.Errorf("invalid 'silent_windows' entry: %s", err)
+		}
+	}
+
+	if len(cf.Builtin) > 0 && (len(cf.Exec) > 0 || len(cf.CLIArgs) > 0 || cf.IntegrationName != "") {
+		return errors.New("'builtin' can't be used along with 'exec', 'cli_args' or 'integration_name'")
+	}
+
 	// Checking if there is any configuration file or path to be passed externally to the integration
 	if cf.Config != nil && cf.TemplatePath != "" {
 		return fmt.Errorf("only 'config' or 'config_template_path' is allowed, not both at the same time")
@@ -4,6 +4,7 @@ package config
 
 import (
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -36,3 +37,112 @@ integrations:
 	assert.Contains(t, config.Integrations, ConfigEntry{Exec: ShlexOpt{"/path/to/executable"}})
 	assert.Contains(t, config.Integrations, ConfigEntry{Exec: ShlexOpt{"/path/to/another/executable"}})
 }
+
+func TestParse_MaxOutputSize(t *testing.T) {
+	config := YAML{}
+	require.NoError(t, yaml.Unmarshal([]byte(`---
+integrations:
+  - name: nri-human
+    max_output_size: 10MB
+    max_output_strategy: drop
+  - name: nri-bytes
+    max_output_size: 2048
+`), &config))
+
+	require.Len(t, config.Integrations, 2)
+	assert.Equal(t, ByteSize(10*1024*1024), config.Integrations[0].MaxOutputSize)
+	assert.Equal(t, ByteSize(2048), config.Integrations[1].MaxOutputSize)
+
+	require.NoError(t, config.Integrations[0].Sanitize())
+	assert.Equal(t, MaxOutputDrop, config.Integrations[0].MaxOutputStrategy)
+	require.NoError(t, config.Integrations[1].Sanitize())
+	assert.Equal(t, MaxOutputTruncate, config.Integrations[1].MaxOutputStrategy)
+
+	invalid := ConfigEntry{InstanceName: "nri-invalid", MaxOutputStrategy: "compress"}
+	assert.Error(t, invalid.Sanitize())
+	assert.Error(t, yaml.Unmarshal([]byte(`max_output_size: lots`), &ConfigEntry{}))
+}
+
+func TestParse_Retries(t *testing.T) {
+	config := YAML{}
+	require.NoError(t, yaml.Unmarshal([]byte(`---
+integrations:
+  - name: nri-retried
+    retries:
+      count: 3
+      backoff: 500ms
+  - name: nri-default
+`), &config))
+
+	require.Len(t, config.Integrations, 2)
+	assert.Equal(t, Retries{Count: 3, Backoff: 500 * time.Millisecond}, config.Integrations[0].Retries)
+	assert.Equal(t, Retries{}, config.Integrations[1].Retries)
+
+	invalid := ConfigEntry{InstanceName: "nri-invalid", Retries: Retries{Count: -1}}
+	assert.Error(t, invalid.Sanitize())
+}
+
+func TestParse_ServeStale(t *testing.T) {
+	config := YAML{}
+	require.NoError(t, yaml.Unmarshal([]byte(`---
+integrations:
+  - name: nri-flaky
+    serve_stale: 10m
+`), &config))
+
+	require.Len(t, config.Integrations, 1)
+	require.NoError(t, config.Integrations[0].Sanitize())
+	assert.Equal(t, 10*time.Minute, config.Integrations[0].ServeStale)
+
+	invalid := ConfigEntry{InstanceName: "nri-invalid", ServeStale: -time.Second}
+	assert.Error(t, invalid.Sanitize())
+}
+
+func TestParse_DerivedMetrics(t *testing.T) {
+	config := YAML{}
+	require.NoError(t, yaml.Unmarshal([]byte(`---
+integrations:
+  - name: nri-counters
+    derived_metrics:
+      - metric: net.bytesReceived
+      - metric: /^gc\./
+        type: delta
+`), &config))
+
+	require.Len(t, config.Integrations, 1)
+	require.NoError(t, config.Integrations[0].Sanitize())
+	assert.Equal(t, []DerivedMetric{
+		{Metric: "net.bytesReceived", Type: DeriveRate},
+		{Metric: "/^gc\\./", Type: DeriveDelta},
+	}, config.Integrations[0].DerivedMetrics)
+
+	invalid := ConfigEntry{InstanceName: "nri-invalid", DerivedMetrics: []DerivedMetric{{Metric: "a", Type: "average"}}}
+	assert.Error(t, invalid.Sanitize())
+	invalid = ConfigEntry{InstanceName: "nri-invalid", DerivedMetrics: []DerivedMetric{{Type: DeriveRate}}}
+	assert.Error(t, invalid.Sanitize())
+}
+
+func TestParse_SilentWindows(t *testing.T) {
+	config := YAML{}
+	require.NoError(t, yaml.Unmarshal([]byte(`---
+integrations:
+  - name: nri-mysql
+    silent_windows:
+      - name: nightly-backup
+        schedule: "0 2 * * *"
+        duration: 1h
+        timezone: Europe/Madrid
+        action: tag
+`), &config))
+
+	require.Len(t, config.Integrations, 1)
+	require.NoError(t, config.Integrations[0].Sanitize())
+	assert.Equal(t, []SilentWindow{
+		{Name: "nightly-backup", Schedule: "0 2 * * *", Duration: "1h", Timezone: "Europe/Madrid", Action: "tag"},
+	}, config.Integrations[0].SilentWindows)
+
+	invalid := ConfigEntry{InstanceName: "nri-invalid", SilentWindows: []SilentWindow{{Name: "w", Schedule: "0 25 * * *", Duration: "1h"}}}
+	assert.Error(t, invalid.Sanitize())
+	invalid = ConfigEntry{InstanceName: "nri-invalid", SilentWindows: []SilentWindow{{Name: "w", Schedule: "@daily", Duration: "1h", Action: "mute"}}}
+	assert.Error(t, invalid.Sanitize())
+}
@@ -0,0 +1,128 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+// Package conformance exposes how the agent parses and validates the integrations output, so the
+// integration authors can check in their own CI that their payloads are interpreted by the agent
+// version they target as they expect. See RunGoldenFiles.
+package conformance
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/newrelic/infrastructure-agent/pkg/entity"
+	"github.com/newrelic/infrastructure-agent/pkg/integrations/v4/dm"
+	"github.com/newrelic/infrastructure-agent/pkg/integrations/v4/protocol"
+)
+
+// Options sets the integration configuration the payloads are parsed for.
+type Options struct {
+	// Interval of the integration, set to the count and summary metrics without interval.
+	Interval time.Duration
+	// Labels of the integration, added to the metrics as "label.<name>" attributes.
+	Labels map[string]string
+	// ExtraAnnotations are added to the metrics as attributes, unless they already have them.
+	ExtraAnnotations map[string]string
+	// ForceV2ToV3Upgrade parses protocol v2 payloads as v3, as the agent
+	// entityname_integrations_v2_update option does.
+	ForceV2ToV3Upgrade bool
+	// Timestamp, in seconds, of the metrics without timestamp. Current time by default.
+	Timestamp int64
+}
+
+// Result is the agent interpretation of an integration payload.
+type Result struct {
+	ProtocolVersion int             `json:"protocol_version"`
+	Payload         json.RawMessage `json:"payload"`
+	// Metrics are the dimensional metrics submitted for protocol v4 payloads, decorated as the agent does.
+	Metrics []protocol.Metric `json:"dimensional_metrics,omitempty"`
+	// Warnings describe the data of the payload that the agent would discard.
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// Parse parses an integration payload as the agent does. It returns an error if the agent would reject
+// the whole payload, and warnings for the parts of it that would be discarded.
+func Parse(raw []byte, opts Options) (Result, error) {
+	protocolVersion, err := protocol.VersionFromPayload(raw, opts.ForceV2ToV3Upgrade)
+	if err != nil {
+		return Result{}, err
+	}
+	result := Result{ProtocolVersion: protocolVersion}
+
+	if protocolVersion != protocol.V4 {
+		pluginDataV3, err := protocol.ParsePayload(raw, protocolVersion)
+		if err != nil {
+			return Result{}, err
+		}
+		for i := range pluginDataV3.DataSets {
+			result.checkDataset(i, &pluginDataV3.DataSets[i].Entity, pluginDataV3.DataSets[i].Events)
+		}
+		result.Payload, err = json.Marshal(pluginDataV3)
+		return result, err
+	}
+
+	// unlike the agent, the payloads are parsed regardless of the protocol v4 feature flag
+	var pluginDataV4 protocol.DataV4
+	if err := json.Unmarshal(raw, &pluginDataV4); err != nil {
+		return Result{}, err
+	}
+	if result.Payload, err = json.Marshal(pluginDataV4); err != nil {
+		return Result{}, err
+	}
+
+	processor := dm.IntegrationProcessor{
+		IntegrationInterval:         opts.Interval,
+		IntegrationLabels:           opts.Labels,
+		IntegrationExtraAnnotations: opts.ExtraAnnotations,
+	}
+	for i, dataset := range pluginDataV4.DataSets {
+		result.checkDataset(i, &dataset.Entity, dataset.Events)
+		common := dataset.Common
+		if common.Timestamp == nil && opts.Timestamp != 0 {
+			common.Timestamp = &opts.Timestamp
+		}
+		for _, metric := range processor.ProcessMetrics(dataset.Metrics, common, dataset.Entity) {
+			if err := validateMetric(metric); err != nil {
+				result.warn(i, "metric %q discarded: %s", metric.Name, err)
+				continue
+			}
+			result.Metrics = append(result.Metrics, metric)
+		}
+	}
+	return result, nil
+}
+
+func (r *Result) checkDataset(index int, fields *entity.Fields, events []protocol.EventData) {
+	if _, err := fields.Key(); err != nil {
+		r.warn(index, "invalid entity: %s", err)
+	}
+	for _, event := range events {
+		if _, ok := event["summary"]; !ok {
+			r.warn(index, "event discarded: missing required 'summary' field")
+		}
+	}
+}
+
+func (r *Result) warn(dataset int, format string, args ...interface{}) {
+	r.Warnings = append(r.Warnings, fmt.Sprintf("data[%d]: ", dataset)+fmt.Sprintf(format, args...))
+}
+
+// validateMetric checks the metric type and value as the dimensional metrics sender does.
+func validateMetric(m protocol.Metric) (err error) {
+	switch m.Type {
+	case protocol.MetricTypeGauge, protocol.MetricTypeCount, protocol.MetricTypeRate, "cumulative-rate", "cumulative-count":
+		_, err = m.NumericValue()
+	case protocol.MetricTypeSummary:
+		_, err = m.SummaryValue()
+	case protocol.MetricTypePrometheusSummary:
+		_, err = m.GetPrometheusSummaryValue()
+	case protocol.MetricTypePrometheusHistogram:
+		_, err = m.GetPrometheusHistogramValue()
+	default:
+		return fmt.Errorf("unknown metric type %q", m.Type)
+	}
+	if err != nil {
+		return fmt.Errorf("invalid %s value: %s", m.Type, err)
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package conformance
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGoldenFiles(t *testing.T) {
+	RunGoldenFiles(t, "testdata", Options{
+		Interval: 15 * time.Second,
+		Labels:   map[string]string{"role": "cache"},
+	})
+}
+
+func TestParse_ProtocolV4(t *testing.T) {
+	// GIVEN a v4 payload with metrics without timestamp nor interval
+	payload := []byte(`{"protocol_version":"4","integration":{"name":"nri-test"},"data":[{"metrics":[
+		{"name":"requests","type":"count","value":3},
+		{"name":"queued","type":"gauge","value":1}
+	]}]}`)
+
+	// WHEN it is parsed for an integration with labels
+	result, err := Parse(payload, Options{Interval: 30 * time.Second, Labels: map[string]string{"env": "test"}, Timestamp: 1234})
+
+	// THEN the metrics are decorated as the agent does
+	require.NoError(t, err)
+	assert.Equal(t, 4, result.ProtocolVersion)
+	assert.Empty(t, result.Warnings)
+	require.Len(t, result.Metrics, 2)
+	for _, metric := range result.Metrics {
+		assert.Equal(t, "test", metric.Attributes["label.env"])
+		assert.EqualValues(t, 1234, *metric.Timestamp)
+	}
+	assert.NotNil(t, result.Metrics[0].Interval, "count metrics get the integration interval")
+	assert.Nil(t, result.Metrics[1].Interval)
+}
+
+func TestParse_Warnings(t *testing.T) {
+	result, err := Parse([]byte(`{"protocol_version":"4","data":[{
+		"entity":{"name":"no-type"},
+		"metrics":[{"name":"m","type":"summary","value":3}],
+		"events":[{"category":"notifications"}]
+	}]}`), Options{})
+
+	require.NoError(t, err)
+	assert.Empty(t, result.Metrics)
+	assert.Equal(t, []string{
+		"data[0]: invalid entity: missing 'type' field for entity name 'no-type'",
+		"data[0]: event discarded: missing required 'summary' field",
+		`data[0]: metric "m" discarded: invalid summary value: json: cannot unmarshal number into Go value of type protocol.SummaryValue`,
+	}, result.Warnings)
+}
+
+func TestParse_Rejected(t *testing.T) {
+	for name, payload := range map[string]string{
+		"empty":            ``,
+		"no version":       `{"data":[]}`,
+		"unsupported":      `{"protocol_version":"7","data":[]}`,
+		"malformed v3":     `{"protocol_version":"3","data":{}}`,
+		"malformed v4":     `{"protocol_version":"4","data":[{"metrics":{}}]}`,
+		"float version":    `{"protocol_version":3.5,"data":[]}`,
+		"non json payload": `protocol_version: 4`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := Parse([]byte(payload), Options{})
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestParse_ForceV2ToV3Upgrade(t *testing.T) {
+	payload := []byte(`{"name":"nri-test","protocol_version":"2","data":[]}`)
+
+	result, err := Parse(payload, Options{})
+	require.NoError(t, err)
+	assert.Equal(t, 2, result.ProtocolVersion)
+
+	result, err = Parse(payload, Options{ForceV2ToV3Upgrade: true})
+	require.NoError(t, err)
+	assert.Equal(t, 3, result.ProtocolVersion)
+}
@@ -0,0 +1,93 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package conformance
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const (
+	goldenSuffix = ".golden.json"
+	// UpdateEnvVar set to "true" makes RunGoldenFiles write the golden files instead of checking them.
+	UpdateEnvVar = "NRIA_CONFORMANCE_UPDATE"
+	// GoldenTimestamp is the timestamp of the metrics without one, so the golden files are stable.
+	GoldenTimestamp int64 = 1600000000
+)
+
+// golden is the content of a golden file: the expected result of parsing its payload, or its error.
+type golden struct {
+	Result
+	Error string `json:"error,omitempty"`
+}
+
+// RunGoldenFiles runs a subtest for each payload in the directory, named as <name>.json, which checks
+// that it is parsed as stored in its <name>.golden.json file. Golden files are created, or overwritten,
+// by running the tests with the NRIA_CONFORMANCE_UPDATE=true environment variable. Metrics without
+// timestamp get GoldenTimestamp, unless another one is set in the options.
+func RunGoldenFiles(t *testing.T, dir string, opts Options) {
+	payloads, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opts.Timestamp == 0 {
+		opts.Timestamp = GoldenTimestamp
+	}
+	update := os.Getenv(UpdateEnvVar) == "true"
+
+	for _, payload := range payloads {
+		if strings.HasSuffix(payload, goldenSuffix) {
+			continue
+		}
+		payload := payload
+		name := strings.TrimSuffix(filepath.Base(payload), ".json")
+		t.Run(name, func(t *testing.T) {
+			raw, err := ioutil.ReadFile(payload)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var actual golden
+			actual.Result, err = Parse(raw, opts)
+			if err != nil {
+				actual.Error = err.Error()
+			}
+			actualJSON, err := json.MarshalIndent(actual, "", "  ")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			goldenFile := strings.TrimSuffix(payload, ".json") + goldenSuffix
+			if update {
+				if err := ioutil.WriteFile(goldenFile, append(actualJSON, '\n'), 0644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			expectedJSON, err := ioutil.ReadFile(goldenFile)
+			if err != nil {
+				t.Fatalf("%s. Run the tests with %s=true to create it", err, UpdateEnvVar)
+			}
+			if !jsonEqual(t, expectedJSON, actualJSON) {
+				t.Errorf("%s is not parsed as in %s.\nExpected:\n%s\nActual:\n%s",
+					payload, goldenFile, bytes.TrimSpace(expectedJSON), actualJSON)
+			}
+		})
+	}
+}
+
+func jsonEqual(t *testing.T, expected, actual []byte) bool {
+	var e, a interface{}
+	if err := json.Unmarshal(expected, &e); err != nil {
+		t.Fatalf("invalid golden file: %s", err)
+	}
+	if err := json.Unmarshal(actual, &a); err != nil {
+		t.Fatal(err)
+	}
+	return reflect.DeepEqual(e, a)
+}
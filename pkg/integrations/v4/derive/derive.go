@@ -0,0 +1,302 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+// Package derive replaces the raw counters reported by integrations by their per-second rate or their
+// delta since the previous execution, for integrations that can't compute them. The previous values
+// are persisted, so the derivations continue across agent restarts.
+package derive
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/newrelic/infrastructure-agent/pkg/disk"
+	"github.com/newrelic/infrastructure-agent/pkg/entity"
+	"github.com/newrelic/infrastructure-agent/pkg/integrations/v4/config"
+	"github.com/newrelic/infrastructure-agent/pkg/integrations/v4/protocol"
+)
+
+// expirationAge is the age of the values that are discarded, as they belong to series that aren't
+// reported anymore.
+const expirationAge = 24 * time.Hour
+
+var (
+	// we'll identify any regular expression as a string between two slashes, as the discovery matchers
+	metaRegexp = regexp.MustCompile("^/.*/$")
+	// replaces the characters that aren't safe in a file name
+	unsafeChars = regexp.MustCompile(`[^a-zA-Z0-9._-]+`)
+)
+
+type rule struct {
+	matches func(name string) bool
+	delta   bool
+}
+
+// point is the previous value of a series.
+type point struct {
+	Value float64 `json:"value"`
+	// Time in unix milliseconds
+	Time int64 `json:"time"`
+}
+
+// Deriver computes the derived values of the metrics of an integration.
+type Deriver struct {
+	lock      sync.Mutex
+	rules     []rule
+	points    map[string]point // by series
+	statePath string
+}
+
+// New creates a Deriver for the passed metrics, whose previous values are loaded from and stored into
+// statePath. An empty path disables the persistence.
+func New(metrics []config.DerivedMetric, statePath string) (*Deriver, error) {
+	d := &Deriver{points: map[string]point{}, statePath: statePath}
+	for _, m := range metrics {
+		r := rule{delta: m.Type == config.DeriveDelta}
+		if metaRegexp.MatchString(m.Metric) {
+			re, err := regexp.Compile(m.Metric[1 : len(m.Metric)-1])
+			if err != nil {
+				return nil, fmt.Errorf("derived metric %q should be a valid regular expression: %s", m.Metric, err)
+			}
+			r.matches = re.MatchString
+		} else {
+			name := m.Metric
+			r.matches = func(metric string) bool { return metric == name }
+		}
+		d.rules = append(d.rules, r)
+	}
+	if statePath == "" {
+		return d, nil
+	}
+	content, err := ioutil.ReadFile(statePath)
+	if os.IsNotExist(err) {
+		return d, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err = json.Unmarshal(content, &d.points); err != nil {
+		return nil, fmt.Errorf("invalid derived metrics state %s: %s", statePath, err)
+	}
+	return d, nil
+}
+
+func (d *Deriver) rule(metric string) (rule, bool) {
+	for _, r := range d.rules {
+		if r.matches(metric) {
+			return r, true
+		}
+	}
+	return rule{}, false
+}
+
+// derive returns the derived value of a series and stores the current value for the next derivation.
+// There is no derived value for the first value of a series, nor after a counter reset.
+func (d *Deriver) derive(series string, r rule, value float64, now int64) (derived float64, elapsedMs int64, ok bool) {
+	last, found := d.points[series]
+	if found && now <= last.Time {
+		// don't accept values older than the last one
+		return 0, 0, false
+	}
+	d.points[series] = point{Value: value, Time: now}
+	if !found || value < last.Value {
+		return 0, 0, false
+	}
+	elapsedMs = now - last.Time
+	if r.delta {
+		return value - last.Value, elapsedMs, true
+	}
+	return (value - last.Value) / (float64(elapsedMs) / 1000), elapsedMs, true
+}
+
+// DeriveV3 replaces the values of the configured metric sample attributes by their derived values.
+// Attributes without a derived value yet are removed from the sample.
+func (d *Deriver) DeriveV3(data *protocol.PluginDataV3, now time.Time) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	for i := range data.DataSets {
+		ent := data.DataSets[i].Entity
+		for _, sample := range data.DataSets[i].Metrics {
+			var identity string
+			for attr, value := range sample {
+				r, ok := d.rule(attr)
+				if !ok {
+					continue
+				}
+				number, ok := value.(float64)
+				if !ok {
+					continue
+				}
+				if identity == "" {
+					identity = sampleIdentity(ent, sample)
+				}
+				if derived, _, ok := d.derive(identity+"\x00"+attr, r, number, now.UnixNano()/int64(time.Millisecond)); ok {
+					sample[attr] = derived
+				} else {
+					delete(sample, attr)
+				}
+			}
+		}
+	}
+}
+
+// DeriveV4 replaces the configured gauge and count metrics by a gauge with their rate, or a count with
+// their delta. Metrics without a derived value yet are removed.
+func (d *Deriver) DeriveV4(data *protocol.DataV4, now time.Time) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	for i := range data.DataSets {
+		ds := &data.DataSets[i]
+		metrics := ds.Metrics[:0]
+		for _, m := range ds.Metrics {
+			r, ok := d.rule(m.Name)
+			if !ok || (m.Type != protocol.MetricTypeGauge && m.Type != protocol.MetricTypeCount) {
+				metrics = append(metrics, m)
+				continue
+			}
+			var value float64
+			if err := json.Unmarshal(m.Value, &value); err != nil {
+				metrics = append(metrics, m)
+				continue
+			}
+			if m.Timestamp == nil {
+				m.Timestamp = ds.Common.Timestamp
+			}
+			when := now
+			if m.Timestamp != nil {
+				// timestamps may be either in seconds or milliseconds
+				when = m.Time()
+			}
+			timestamp := when.UnixNano() / int64(time.Millisecond)
+			derived, elapsedMs, ok := d.derive(metricIdentity(ds, m), r, value, timestamp)
+			if !ok {
+				continue
+			}
+			m.Value, _ = json.Marshal(derived)
+			ts := timestamp
+			m.Timestamp = &ts
+			if r.delta {
+				m.Type = protocol.MetricTypeCount
+				m.Interval = &elapsedMs
+			} else {
+				m.Type = protocol.MetricTypeGauge
+				m.Interval = nil
+			}
+			metrics = append(metrics, m)
+		}
+		ds.Metrics = metrics
+	}
+}
+
+// Save stores the current values, discarding the expired ones.
+func (d *Deriver) Save(now time.Time) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	cutoff := now.Add(-expirationAge).UnixNano() / int64(time.Millisecond)
+	for series, p := range d.points {
+		if p.Time < cutoff {
+			delete(d.points, series)
+		}
+	}
+	content, err := json.Marshal(d.points)
+	if err != nil || d.statePath == "" {
+		return err
+	}
+	if err = disk.MkdirAll(filepath.Dir(d.statePath), 0755); err != nil {
+		return err
+	}
+	// written aside and renamed, so a crash doesn't leave a corrupt state
+	tmp := d.statePath + ".tmp"
+	if err = disk.WriteFile(tmp, content, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, d.statePath)
+}
+
+// sampleIdentity identifies the series of a v3 metric sample by its entity, event type and string
+// attributes, as the numeric ones are its values.
+func sampleIdentity(ent entity.Fields, sample protocol.MetricData) string {
+	var attrs []string
+	for k, v := range sample {
+		if s, ok := v.(string); ok {
+			attrs = append(attrs, k+"="+s)
+		}
+	}
+	sort.Strings(attrs)
+	return string(ent.Type) + ":" + ent.Name + "\x00" + strings.Join(attrs, "\x00")
+}
+
+// metricIdentity identifies the series of a v4 metric by its entity, name and attributes.
+func metricIdentity(ds *protocol.Dataset, m protocol.Metric) string {
+	// maps are marshaled with sorted keys
+	attrs, _ := json.Marshal(m.Attributes)
+	common, _ := json.Marshal(ds.Common.Attributes)
+	return string(ds.Entity.Type) + ":" + ds.Entity.Name + "\x00" + m.Name + "\x00" + string(attrs) + string(common)
+}
+
+// Registry holds the Derivers of the integrations, so their previous values are kept between executions.
+type Registry struct {
+	dir      string
+	lock     sync.Mutex
+	derivers map[string]registered // by config path and hash, or integration name
+}
+
+type registered struct {
+	deriver *Deriver
+	metrics []config.DerivedMetric
+}
+
+// NewRegistry creates a Registry whose Derivers persist their values in a file per integration entry in dir.
+func NewRegistry(dir string) *Registry {
+	return &Registry{dir: dir, derivers: map[string]registered{}}
+}
+
+// Deriver returns the Deriver of the integration entry identified by its config file path and config
+// hash for the passed metrics, or nil if there are no metrics to derive. Entries that are not loaded
+// from a file (empty path and hash) are identified by their name, and changing their metrics keeps
+// their previous values.
+func (r *Registry) Deriver(integrationName, configPath, configHash string, metrics []config.DerivedMetric) (*Deriver, error) {
+	if len(metrics) == 0 {
+		return nil, nil
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	key := integrationName
+	fileName := unsafeChars.ReplaceAllString(integrationName, "_")
+	if configPath != "" || configHash != "" {
+		key = configPath + "#" + configHash
+		// the same integration may be configured in several entries and files
+		sum := sha256.Sum256([]byte(key))
+		fileName = fmt.Sprintf("%s-%x", fileName, sum[:8])
+	}
+
+	if reg, ok := r.derivers[key]; ok && reflect.DeepEqual(reg.metrics, metrics) {
+		return reg.deriver, nil
+	}
+	statePath := ""
+	if r.dir != "" {
+		statePath = filepath.Join(r.dir, fileName+".json")
+	}
+	if reg, ok := r.derivers[key]; ok {
+		// stores the values of the replaced deriver, so they are loaded by the new one
+		if err := reg.deriver.Save(time.Now()); err != nil {
+			return nil, err
+		}
+	}
+	d, err := New(metrics, statePath)
+	if err != nil {
+		return nil, err
+	}
+	r.derivers[key] = registered{deriver: d, metrics: metrics}
+	return d, nil
+}
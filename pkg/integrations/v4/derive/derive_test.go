@@ -0,0 +1,203 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package derive
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/newrelic/infrastructure-agent/pkg/entity"
+	"github.com/newrelic/infrastructure-agent/pkg/integrations/v4/config"
+	"github.com/newrelic/infrastructure-agent/pkg/integrations/v4/protocol"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	t0      = time.Date(2020, 5, 1, 10, 0, 0, 0, time.UTC)
+	metrics = []config.DerivedMetric{
+		{Metric: "net.bytesReceived", Type: config.DeriveRate},
+		{Metric: "/^gc\\./", Type: config.DeriveDelta},
+	}
+)
+
+func v3Payload(bytes, collections float64) *protocol.PluginDataV3 {
+	return &protocol.PluginDataV3{DataSets: []protocol.PluginDataSetV3{{
+		PluginDataSet: protocol.PluginDataSet{
+			Entity: entity.Fields{Name: "redis:6379", Type: "instance"},
+			Metrics: []protocol.MetricData{{
+				"event_type":        "RedisSample",
+				"displayName":       "redis",
+				"net.bytesReceived": bytes,
+				"gc.collections":    collections,
+				"connectedClients":  3.0,
+			}},
+		},
+	}}}
+}
+
+func TestDeriveV3(t *testing.T) {
+	d, err := New(metrics, "")
+	require.NoError(t, err)
+
+	// GIVEN a first sample of the counters
+	data := v3Payload(1000, 10)
+	d.DeriveV3(data, t0)
+
+	// THEN the counters are removed, as there aren't derived values yet
+	assert.Equal(t, protocol.MetricData{
+		"event_type":       "RedisSample",
+		"displayName":      "redis",
+		"connectedClients": 3.0,
+	}, data.DataSets[0].Metrics[0])
+
+	// WHEN the next sample is received
+	data = v3Payload(4000, 12)
+	d.DeriveV3(data, t0.Add(10*time.Second))
+
+	// THEN the counters are replaced by their derived values
+	sample := data.DataSets[0].Metrics[0]
+	assert.Equal(t, 300.0, sample["net.bytesReceived"])
+	assert.Equal(t, 2.0, sample["gc.collections"])
+	assert.Equal(t, 3.0, sample["connectedClients"])
+
+	// AND a counter reset doesn't report negative values
+	data = v3Payload(100, 13)
+	d.DeriveV3(data, t0.Add(20*time.Second))
+	sample = data.DataSets[0].Metrics[0]
+	assert.NotContains(t, sample, "net.bytesReceived")
+	assert.Equal(t, 1.0, sample["gc.collections"])
+}
+
+func v4Metric(name string, metricType protocol.MetricType, value string, timestamp int64) protocol.Metric {
+	return protocol.Metric{
+		Name:       name,
+		Type:       metricType,
+		Timestamp:  &timestamp,
+		Attributes: map[string]interface{}{"interface": "eth0"},
+		Value:      json.RawMessage(value),
+	}
+}
+
+func TestDeriveV4(t *testing.T) {
+	d, err := New(metrics, "")
+	require.NoError(t, err)
+	payload := func(bytes, collections string, timestamp int64) *protocol.DataV4 {
+		return &protocol.DataV4{DataSets: []protocol.Dataset{{
+			Entity: entity.Fields{Name: "host"},
+			Metrics: []protocol.Metric{
+				v4Metric("net.bytesReceived", protocol.MetricTypeGauge, bytes, timestamp),
+				v4Metric("gc.collections", protocol.MetricTypeCount, collections, timestamp),
+				v4Metric("cpu.percent", protocol.MetricTypeGauge, "50", timestamp),
+			},
+		}}}
+	}
+
+	// GIVEN a first value of the counters, timestamped in seconds
+	data := payload("1000", "10", t0.Unix())
+	d.DeriveV4(data, time.Now())
+	require.Len(t, data.DataSets[0].Metrics, 1)
+	assert.Equal(t, "cpu.percent", data.DataSets[0].Metrics[0].Name)
+
+	// WHEN the next values are received, timestamped in milliseconds
+	data = payload("3000", "15", t0.Add(20*time.Second).UnixNano()/int64(time.Millisecond))
+	d.DeriveV4(data, time.Now())
+
+	// THEN the rate is reported as a gauge and the delta as a count over the elapsed interval
+	require.Len(t, data.DataSets[0].Metrics, 3)
+	rate := data.DataSets[0].Metrics[0]
+	assert.Equal(t, protocol.MetricTypeGauge, rate.Type)
+	assert.JSONEq(t, "100", string(rate.Value))
+	delta := data.DataSets[0].Metrics[1]
+	assert.Equal(t, protocol.MetricTypeCount, delta.Type)
+	assert.JSONEq(t, "5", string(delta.Value))
+	require.NotNil(t, delta.Interval)
+	assert.Equal(t, int64(20000), *delta.Interval)
+}
+
+func TestSave_ContinuesAfterRestart(t *testing.T) {
+	dir, err := ioutil.TempDir("", "derive")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	// GIVEN a deriver that stored its values
+	r := NewRegistry(dir)
+	d, err := r.Deriver("nri-redis", "", "", metrics)
+	require.NoError(t, err)
+	d.DeriveV3(v3Payload(1000, 10), t0)
+	require.NoError(t, d.Save(t0))
+	_, err = os.Stat(filepath.Join(dir, "nri-redis.json"))
+	require.NoError(t, err)
+
+	// WHEN the agent is restarted
+	d, err = NewRegistry(dir).Deriver("nri-redis", "", "", metrics)
+	require.NoError(t, err)
+
+	// THEN the derivation continues from the stored values
+	data := v3Payload(1600, 10)
+	d.DeriveV3(data, t0.Add(time.Minute))
+	assert.Equal(t, 10.0, data.DataSets[0].Metrics[0]["net.bytesReceived"])
+
+	// AND the expired values are discarded
+	require.NoError(t, d.Save(t0.Add(48*time.Hour)))
+	d, err = New(metrics, filepath.Join(dir, "nri-redis.json"))
+	require.NoError(t, err)
+	assert.Empty(t, d.points)
+}
+
+func TestRegistry(t *testing.T) {
+	r := NewRegistry("")
+
+	d, err := r.Deriver("nri-redis", "", "", nil)
+	require.NoError(t, err)
+	assert.Nil(t, d)
+
+	d, err = r.Deriver("nri-redis", "", "", metrics)
+	require.NoError(t, err)
+	same, err := r.Deriver("nri-redis", "", "", metrics)
+	require.NoError(t, err)
+	assert.True(t, d == same)
+
+	_, err = r.Deriver("nri-invalid", "", "", []config.DerivedMetric{{Metric: "/[invalid/"}})
+	assert.Error(t, err)
+}
+
+func TestRegistry_ByConfigEntry(t *testing.T) {
+	dir, err := ioutil.TempDir("", "derive")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	r := NewRegistry(dir)
+
+	// GIVEN the same integration configured in two config files
+	first, err := r.Deriver("nri-redis", "/etc/redis-a.yml", "hash", metrics)
+	require.NoError(t, err)
+	second, err := r.Deriver("nri-redis", "/etc/redis-b.yml", "hash", metrics)
+	require.NoError(t, err)
+
+	// THEN each entry gets its own deriver
+	assert.False(t, first == second)
+	same, err := r.Deriver("nri-redis", "/etc/redis-a.yml", "hash", metrics)
+	require.NoError(t, err)
+	assert.True(t, first == same)
+
+	// AND they store their values in different files
+	first.DeriveV3(v3Payload(1000, 10), t0)
+	second.DeriveV3(v3Payload(5000, 10), t0)
+	require.NoError(t, first.Save(t0))
+	require.NoError(t, second.Save(t0))
+	files, err := ioutil.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, files, 2)
+
+	// AND each one continues from its own values after a restart
+	restarted, err := NewRegistry(dir).Deriver("nri-redis", "/etc/redis-b.yml", "hash", metrics)
+	require.NoError(t, err)
+	data := v3Payload(5600, 10)
+	restarted.DeriveV3(data, t0.Add(time.Minute))
+	assert.Equal(t, 10.0, data.DataSets[0].Metrics[0]["net.bytesReceived"])
+}
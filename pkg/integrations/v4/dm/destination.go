@@ -0,0 +1,96 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package dm
+
+import (
+	"fmt"
+	"net/http"
+
+	telemetry "github.com/newrelic/infrastructure-agent/pkg/backend/telemetryapi"
+)
+
+// entityIDAttribute identifies the entity in the agent account, so it isn't sent to other accounts.
+const entityIDAttribute = "nr.entity.id"
+
+// destination is an additional account the dimensional metrics are also sent to.
+type destination struct {
+	name       string
+	harvester  metricHarvester
+	attributes map[string]string // set on every metric, overriding the existing ones
+}
+
+func newDestinations(conf MetricsSenderConfig, transport http.RoundTripper) (destinations []destination, err error) {
+	for _, d := range conf.Destinations {
+		destConf := conf
+		destConf.LicenseKey = d.LicenseKey
+		destConf.MetricApiURL = fmt.Sprintf("%s/metric/v1/infra", d.MetricURL)
+		// failed requests aren't retried later, and there's no agent entity in the destination account
+		destConf.Spool = nil
+		destConf.Throttle = nil
+		attributes := make(map[string]interface{}, len(d.Attributes))
+		for k, v := range d.Attributes {
+			attributes[k] = v
+		}
+		harvester, err := newTelemetryHarverster(destConf, transport, nil, telemetry.ConfigCommonAttributes(attributes))
+		if err != nil {
+			return nil, fmt.Errorf("can't create harvester for destination %s: %v", d.Name, err)
+		}
+		destinations = append(destinations, destination{
+			name:       d.Name,
+			harvester:  harvester,
+			attributes: d.Attributes,
+		})
+	}
+	return destinations, nil
+}
+
+// commonAttributes returns the common attributes sent to the destination.
+func (d *destination) commonAttributes(attributes map[string]interface{}) map[string]interface{} {
+	common := make(map[string]interface{}, len(attributes)+len(d.attributes))
+	for k, v := range attributes {
+		if k != entityIDAttribute {
+			common[k] = v
+		}
+	}
+	for k, v := range d.attributes {
+		common[k] = v
+	}
+	return common
+}
+
+// metrics returns the metrics sent to the destination, with its attributes overriding the metric ones.
+func (d *destination) metrics(metrics []telemetry.Metric) []telemetry.Metric {
+	if len(d.attributes) == 0 {
+		return metrics
+	}
+	overridden := make([]telemetry.Metric, 0, len(metrics))
+	for _, m := range metrics {
+		switch metric := m.(type) {
+		case telemetry.Gauge:
+			metric.Attributes = d.override(metric.Attributes)
+			m = metric
+		case telemetry.Count:
+			metric.Attributes = d.override(metric.Attributes)
+			m = metric
+		case telemetry.Summary:
+			metric.Attributes = d.override(metric.Attributes)
+			m = metric
+		}
+		overridden = append(overridden, m)
+	}
+	return overridden
+}
+
+func (d *destination) override(attributes map[string]interface{}) map[string]interface{} {
+	if len(attributes) == 0 {
+		return attributes
+	}
+	overridden := make(map[string]interface{}, len(attributes))
+	for k, v := range attributes {
+		if _, ok := d.attributes[k]; !ok && k != entityIDAttribute {
+			overridden[k] = v
+		}
+	}
+	// the destination attributes are sent as common attributes, which the metric ones would take precedence over
+	return overridden
+}
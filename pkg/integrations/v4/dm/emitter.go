@@ -25,6 +25,7 @@ import (
 	"github.com/newrelic/infrastructure-agent/pkg/integrations/legacy"
 	"github.com/newrelic/infrastructure-agent/pkg/integrations/v4/protocol"
 	"github.com/newrelic/infrastructure-agent/pkg/log"
+	"github.com/newrelic/infrastructure-agent/pkg/metrics/transform"
 	"github.com/sirupsen/logrus"
 )
 
@@ -66,6 +67,7 @@ type emitter struct {
 	registerMaxBatchSize      int
 	registerMaxBatchBytesSize int
 	registerMaxBatchTime      time.Duration
+	transformer               *transform.Transformer
 }
 
 type Emitter interface {
@@ -77,6 +79,12 @@ func NewEmitter(
 	dmSender MetricsSender,
 	registerClient identityapi.RegisterClient) Emitter {
 
+	// the expressions are already validated on the agent start
+	transformer, err := transform.New(agentContext.Config().PayloadTransforms)
+	if err != nil {
+		elog.WithError(err).Warn("payload transforms won't be applied to dimensional metrics")
+	}
+
 	return &emitter{
 		retryBo:                   backoff.NewDefaultBackoff(),
 		maxRetryBo:                time.Duration(agentContext.Config().RegisterMaxRetryBoSecs) * time.Second,
@@ -91,6 +99,7 @@ func NewEmitter(
 		registerMaxBatchSize:      defaultRegisterBatchSize,
 		registerMaxBatchBytesSize: defaultRegisterBatchBytesSize,
 		registerMaxBatchTime:      defaultRegisterBatchSecs * time.Second,
+		transformer:               transformer,
 	}
 }
 
@@ -211,7 +220,11 @@ func (e *emitter) processEntityFwRequest(r fwrequest.EntityFwRequest) {
 			Errorf("cannot determine entity")
 	} else {
 		e.idCache.CleanOld()
-		e.idCache.Put(key, r.ID())
+		if ttl := r.Data.Entity.Expiration(); ttl > 0 {
+			e.idCache.PutTTL(key, r.ID(), ttl)
+		} else {
+			e.idCache.Put(key, r.ID())
+		}
 	}
 
 	labels, annos := r.LabelsAndExtraAnnotations()
@@ -229,6 +242,7 @@ func (e *emitter) processEntityFwRequest(r fwrequest.EntityFwRequest) {
 	emitEvent(&plugin, r.Definition, r.Data, labels, r.ID())
 
 	metrics := dmProcessor.ProcessMetrics(r.Data.Metrics, r.Data.Common, r.Data.Entity)
+	metrics = e.transformer.Metrics(metrics)
 	if err := e.metricsSender.SendMetricsWithCommonAttributes(r.Data.Common, metrics); err != nil {
 		elog.WithField("entity", r.ID()).WithError(err).Warn("discarding metrics")
 	}
@@ -250,7 +264,9 @@ func emitInventory(
 			logEntry, dataSet.Inventory, labels, integrationUser, integrationMetadata.Name,
 			dataSet.Entity.Name)
 		entityKey := entity.Key(dataSet.Entity.Name)
-		emitter.EmitInventory(inventoryDataSet, entity.New(entityKey, entityID))
+		e := entity.New(entityKey, entityID)
+		e.TTL = dataSet.Entity.Expiration()
+		emitter.EmitInventory(inventoryDataSet, e)
 	}
 }
 
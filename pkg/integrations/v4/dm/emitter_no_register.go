@@ -79,6 +79,7 @@ func (e *nonRegisterEmitter) Send(dto fwrequest.FwRequest) {
 				dataSet.Entity.Name)
 			emitter.EmitInventory(inventoryDataSet, entity.Entity{
 				Key: entity.Key(dataSet.Entity.Name),
+				TTL: dataSet.Entity.Expiration(),
 			})
 		}
 
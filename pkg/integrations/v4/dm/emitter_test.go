@@ -3,6 +3,7 @@
 package dm
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io/ioutil"
@@ -125,6 +126,10 @@ func (m *mockedMetricsSender) SendMetricsWithCommonAttributes(commonAttributes p
 	return err
 }
 
+func (m *mockedMetricsSender) Flush(ctx context.Context) error {
+	return m.Called(ctx).Error(0)
+}
+
 func TestEmitter_Send_usingIDCache(t *testing.T) {
 	data := integrationFixture.ProtocolV4TwoEntities.Clone().ParsedV4
 
@@ -37,6 +37,9 @@ func (t roundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
 	// Use license key header rather than API key
 	req.Header.Del(apiKeyHeaderToRemove)
 	req.Header.Add(licenseKeyHeader, t.licenseKey)
-	req.Header.Add(agentEntityHeader, t.idProvide().ID.String())
+	// there's no agent entity when sending to other accounts
+	if t.idProvide != nil {
+		req.Header.Add(agentEntityHeader, t.idProvide().ID.String())
+	}
 	return t.rt.RoundTrip(req)
 }
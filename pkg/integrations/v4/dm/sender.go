@@ -3,12 +3,17 @@
 package dm
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"github.com/newrelic/infrastructure-agent/internal/agent/id"
 	"net/http"
 	"time"
 
+	"github.com/newrelic/infrastructure-agent/pkg/backend/spool"
 	telemetry "github.com/newrelic/infrastructure-agent/pkg/backend/telemetryapi"
+	"github.com/newrelic/infrastructure-agent/pkg/backend/throttle"
+	"github.com/newrelic/infrastructure-agent/pkg/config"
 	"github.com/newrelic/infrastructure-agent/pkg/integrations/v4/dm/cumulative"
 	"github.com/newrelic/infrastructure-agent/pkg/integrations/v4/dm/rate"
 	"github.com/newrelic/infrastructure-agent/pkg/integrations/v4/protocol"
@@ -20,6 +25,8 @@ var logger = log.WithComponent("DimensionalMetricsSender")
 type MetricsSender interface {
 	SendMetrics(metrics []protocol.Metric)
 	SendMetricsWithCommonAttributes(commonAttributes protocol.Common, metrics []protocol.Metric) error
+	// Flush sends the metrics recorded but not harvested yet, until the context is done.
+	Flush(ctx context.Context) error
 }
 
 type MetricsSenderConfig struct {
@@ -28,8 +35,17 @@ type MetricsSenderConfig struct {
 	SubmissionPeriod    time.Duration
 	MaxEntitiesPerReq   int
 	MaxEntitiesPerBatch int
+	// Spool stores the requests failing while the backend is unavailable, nil drops them.
+	Spool *spool.Spool
+	// Destinations are additional accounts the metrics are also sent to.
+	Destinations []config.Destination
+	// Throttle shrinks the requests while the backend is throttling the agent, nil doesn't.
+	Throttle *throttle.Controller
 }
 
+// spooledMetricsKind identifies the metric requests in the payload spool.
+const spooledMetricsKind = "metrics"
+
 func NewConfig(baseURL string, licenseKey string, submissionPeriod time.Duration, maxEntitiesPerReq int, maxEntitiesPerBatch int) MetricsSenderConfig {
 	return MetricsSenderConfig{
 		LicenseKey:          licenseKey,
@@ -43,8 +59,16 @@ func NewConfig(baseURL string, licenseKey string, submissionPeriod time.Duration
 // NewDMSender creates a Dimensional Metrics sender.
 func NewDMSender(config MetricsSenderConfig, transport http.RoundTripper, idProvide id.Provide) (s MetricsSender, err error) {
 	harvester, err := newTelemetryHarverster(config, transport, idProvide)
+	if err == nil && config.Spool != nil {
+		go replaySpooledRequests(harvester, config.Spool, config.SubmissionPeriod)
+	}
+	var destinations []destination
+	if err == nil {
+		destinations, err = newDestinations(config, transport)
+	}
 	s = &sender{
-		harvester: harvester,
+		harvester:    harvester,
+		destinations: destinations,
 		calculator: Calculator{
 			rate:  rate.NewCalculator(),
 			delta: cumulative.NewDeltaCalculator(),
@@ -53,9 +77,32 @@ func NewDMSender(config MetricsSenderConfig, transport http.RoundTripper, idProv
 	return
 }
 
+// replaySpooledRequests periodically submits the requests spooled while the backend was unavailable.
+func replaySpooledRequests(harvester *telemetry.Harvester, s *spool.Spool, period time.Duration) {
+	ticker := time.NewTicker(period)
+	defer ticker.Stop()
+	for range ticker.C {
+		sent, err := s.Replay(spooledMetricsKind, func(payload []byte) error {
+			var req telemetry.SpooledRequest
+			if err := json.Unmarshal(payload, &req); err != nil {
+				logger.WithError(err).Warn("Discarding invalid spooled metrics request.")
+				return nil
+			}
+			return harvester.SendSpooled(context.Background(), req)
+		})
+		if sent > 0 {
+			logger.WithField("requests", sent).Info("Spooled metrics requests submitted.")
+		}
+		if err != nil {
+			logger.WithError(err).Debug("Spooled metrics requests replay interrupted.")
+		}
+	}
+}
+
 type sender struct {
-	harvester  metricHarvester
-	calculator Calculator
+	harvester    metricHarvester
+	calculator   Calculator
+	destinations []destination
 }
 
 type Calculator struct {
@@ -77,6 +124,7 @@ type deltaCalculator interface {
 type metricHarvester interface {
 	RecordMetric(m telemetry.Metric)
 	RecordInfraMetrics(commonAttribute telemetry.Attributes, metrics []telemetry.Metric) error
+	Flush(ctx context.Context)
 }
 
 // Deprecated: Use SendMetricsWithCommonAttributes
@@ -119,15 +167,36 @@ func (s *sender) SendMetrics(metrics []protocol.Metric) {
 		for _, m := range recMetric {
 			s.harvester.RecordMetric(m)
 		}
+		for _, d := range s.destinations {
+			for _, m := range d.metrics(recMetric) {
+				d.harvester.RecordMetric(m)
+			}
+		}
 	}
 }
 
 func (s *sender) SendMetricsWithCommonAttributes(commonAttributes protocol.Common, metrics []protocol.Metric) error {
 	dMetrics := s.convertMetrics(metrics)
-	if len(dMetrics) > 0 {
-		return s.harvester.RecordInfraMetrics(commonAttributes.Attributes, dMetrics)
+	if len(dMetrics) == 0 {
+		return nil
+	}
+	// the harvester adds its common attributes to the passed ones
+	for _, d := range s.destinations {
+		if err := d.harvester.RecordInfraMetrics(d.commonAttributes(commonAttributes.Attributes), d.metrics(dMetrics)); err != nil {
+			logger.WithError(err).WithField("destination", d.name).Warn("Can't send metrics to additional destination.")
+		}
+	}
+	return s.harvester.RecordInfraMetrics(commonAttributes.Attributes, dMetrics)
+}
+
+// Flush harvests the pending metrics, spooling the requests not sent before the context is done when the
+// spool is enabled.
+func (s *sender) Flush(ctx context.Context) error {
+	s.harvester.Flush(ctx)
+	for _, d := range s.destinations {
+		d.harvester.Flush(ctx)
 	}
-	return nil
+	return ctx.Err()
 }
 
 func (s *sender) convertMetrics(metrics []protocol.Metric) []telemetry.Metric {
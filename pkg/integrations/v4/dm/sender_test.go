@@ -3,6 +3,7 @@
 package dm
 
 import (
+	"context"
 	"encoding/json"
 	"github.com/newrelic/infrastructure-agent/pkg/integrations/v4/dm/cumulative"
 	"io/ioutil"
@@ -769,6 +770,45 @@ func TestSender_SendMetrics_invalid_metric_type(t *testing.T) {
 	assert.Equal(t, logrus.WarnLevel, entry.Level, "Incorrect log level")
 }
 
+func Test_sender_SendMetricsWithCommonAttributes_Destinations(t *testing.T) {
+	cannedDate := time.Date(1980, time.January, 12, 1, 2, 0, 0, time.Now().Location())
+	cannedDateUnix := cannedDate.Unix()
+	metrics := []protocol.Metric{
+		{
+			Name:       "memory",
+			Type:       "gauge",
+			Attributes: map[string]interface{}{"team": "infra", "cpus": 2},
+			Timestamp:  &cannedDateUnix,
+			Value:      json.RawMessage("42"),
+		},
+	}
+	harvester := &mockHarvester{}
+	destHarvester := &mockHarvester{}
+	s := &sender{
+		harvester:    harvester,
+		destinations: []destination{{name: "customer", harvester: destHarvester, attributes: map[string]string{"team": "msp"}}},
+	}
+
+	// GIVEN metrics reported for an entity of the agent account
+	common := protocol.Common{Attributes: map[string]interface{}{"nr.entity.id": "123", "host": "web"}}
+	harvester.On("RecordInfraMetrics", mock.AnythingOfType("telemetryapi.Attributes"), mock.Anything).Return(nil)
+
+	// THEN the additional destination receives them without the entity ID, and with its attributes
+	destHarvester.On("RecordInfraMetrics", telemetry.Attributes{"host": "web", "team": "msp"}, []telemetry.Metric{
+		telemetry.Gauge{
+			Name:       "memory",
+			Attributes: map[string]interface{}{"cpus": 2},
+			Value:      42,
+			Timestamp:  cannedDate,
+		},
+	}).Return(nil)
+
+	// WHEN they are sent
+	require.NoError(t, s.SendMetricsWithCommonAttributes(common, metrics))
+	harvester.AssertExpectations(t)
+	destHarvester.AssertExpectations(t)
+}
+
 type mockHarvester struct {
 	mock.Mock
 }
@@ -782,6 +822,10 @@ func (m *mockHarvester) RecordInfraMetrics(commonAttributes telemetry.Attributes
 	return args.Error(0)
 }
 
+func (m *mockHarvester) Flush(ctx context.Context) {
+	m.Called(ctx)
+}
+
 type mockRateCalculator struct {
 	mock.Mock
 }
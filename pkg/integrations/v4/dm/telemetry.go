@@ -3,6 +3,7 @@
 package dm
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
@@ -11,6 +12,7 @@ import (
 	"time"
 
 	"github.com/newrelic/infrastructure-agent/internal/agent/id"
+	"github.com/newrelic/infrastructure-agent/pkg/backend/spool"
 	telemetry "github.com/newrelic/infrastructure-agent/pkg/backend/telemetryapi"
 	"github.com/newrelic/infrastructure-agent/pkg/integrations/v4/protocol"
 	"github.com/newrelic/infrastructure-agent/pkg/log"
@@ -45,8 +47,8 @@ func newTelemetryErrorLogger(level string) io.Writer {
 	return &telemetryErrLogger{level: level}
 }
 
-func newTelemetryHarverster(conf MetricsSenderConfig, transport http.RoundTripper, idProvide id.Provide) (*telemetry.Harvester, error) {
-	return telemetry.NewHarvester(
+func newTelemetryHarverster(conf MetricsSenderConfig, transport http.RoundTripper, idProvide id.Provide, options ...func(*telemetry.Config)) (*telemetry.Harvester, error) {
+	return telemetry.NewHarvester(append([]func(*telemetry.Config){
 		telemetry.ConfigAPIKey(conf.LicenseKey),
 		telemetry.ConfigBasicErrorLogger(newTelemetryErrorLogger("error")),
 		telemetry.ConfigBasicDebugLogger(newTelemetryErrorLogger("debug")),
@@ -56,7 +58,28 @@ func newTelemetryHarverster(conf MetricsSenderConfig, transport http.RoundTrippe
 		telemetry.ConfigHarvestPeriod(conf.SubmissionPeriod),
 		telemetry.ConfigMaxEntitiesPerRequest(conf.MaxEntitiesPerReq),
 		telemetry.ConfigMaxEntitiesPerBatch(conf.MaxEntitiesPerBatch),
-	)
+		telemetryHarvesterWithSpool(conf.Spool),
+		telemetry.ConfigThrottle(conf.Throttle),
+	}, options...)...)
+}
+
+func telemetryHarvesterWithSpool(s *spool.Spool) func(*telemetry.Config) {
+	return func(config *telemetry.Config) {
+		if s == nil {
+			return
+		}
+		config.SpoolRequest = func(req telemetry.SpooledRequest) {
+			payload, err := json.Marshal(req)
+			if err == nil {
+				err = s.Put(spooledMetricsKind, payload)
+			}
+			if err != nil {
+				logger.WithError(err).Warn("Can't spool the failed metrics request, the metrics are dropped.")
+				return
+			}
+			logger.Debug("Failed metrics request spooled.")
+		}
+	}
 }
 
 func telemetryHarvesterWithTransport(transport http.RoundTripper, licenseKey string, idProvide id.Provide) func(*telemetry.Config) {
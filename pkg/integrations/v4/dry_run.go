@@ -0,0 +1,31 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package v4
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/newrelic/infrastructure-agent/internal/integrations/v4/integration"
+	"github.com/newrelic/infrastructure-agent/internal/integrations/v4/runner"
+	"github.com/newrelic/infrastructure-agent/pkg/integrations/cmdrequest"
+	"github.com/newrelic/infrastructure-agent/pkg/integrations/v4/emitter"
+)
+
+// DryRun runs once the discovery and the integrations of the given configuration file, emitting
+// their payloads through the passed emitter. It returns once all the integrations have finished.
+func DryRun(ctx context.Context, cfg Configuration, il integration.InstancesLookup, em emitter.Emitter, cfgPath string) error {
+	yml, err := loadConfig(cfgPath)
+	if err != nil {
+		return fmt.Errorf("can't load integration config %q: %v", cfgPath, err)
+	}
+
+	loader := runner.NewLoadFn(yml, runner.NewFeatures(cfg.AgentFeatures, nil))
+	gr, _, err := runner.NewGroup(loader, il, cfg.PassthroughEnvironment, em, cmdrequest.NoopHandleFn, nil, false, cfgPath)
+	if err != nil {
+		return fmt.Errorf("can't instantiate integrations from %q: %v", cfgPath, err)
+	}
+
+	gr.RunOnce(ctx)
+	return nil
+}
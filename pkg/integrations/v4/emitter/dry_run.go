@@ -0,0 +1,73 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package emitter
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+
+	"github.com/newrelic/infrastructure-agent/internal/agent/cmdchannel/fflag"
+	"github.com/newrelic/infrastructure-agent/internal/feature_flags"
+	"github.com/newrelic/infrastructure-agent/internal/integrations/v4/integration"
+	"github.com/newrelic/infrastructure-agent/pkg/databind/pkg/data"
+	"github.com/newrelic/infrastructure-agent/pkg/fwrequest"
+	"github.com/newrelic/infrastructure-agent/pkg/integrations/v4/conformance"
+	"github.com/newrelic/infrastructure-agent/pkg/integrations/v4/dm"
+	"github.com/newrelic/infrastructure-agent/pkg/integrations/v4/protocol"
+)
+
+// DryRunEmitter writes the parsed integration payloads, and the dimensional metrics they would
+// submit, instead of forwarding them to the backend.
+type DryRunEmitter struct {
+	out         io.Writer
+	ffRetriever feature_flags.Retriever
+	lock        sync.Mutex
+}
+
+// DryRunOutput is the document written for each integration payload.
+type DryRunOutput struct {
+	Integration string `json:"integration"`
+	conformance.Result
+}
+
+// NewDryRunEmitter creates an emitter writing indented JSON documents to the passed writer.
+func NewDryRunEmitter(out io.Writer, ffRetriever feature_flags.Retriever) *DryRunEmitter {
+	return &DryRunEmitter{
+		out:         out,
+		ffRetriever: ffRetriever,
+	}
+}
+
+func (e *DryRunEmitter) Emit(definition integration.Definition, extraLabels data.Map, entityRewrite []data.EntityRewrite, integrationJSON []byte) error {
+	meta := fwrequest.FwRequestMeta{Definition: definition, ExtraLabels: extraLabels, EntityRewrite: entityRewrite}
+	labels, annos := meta.LabelsAndExtraAnnotations()
+	result, err := conformance.Parse(integrationJSON, conformance.Options{
+		Interval:           definition.Interval,
+		Labels:             labels,
+		ExtraAnnotations:   annos,
+		ForceV2ToV3Upgrade: true,
+	})
+	if err != nil {
+		return err
+	}
+	if result.ProtocolVersion == protocol.V4 {
+		if enabled, ok := e.ffRetriever.GetFeatureFlag(fflag.FlagProtocolV4); !ok || !enabled {
+			return dm.ProtocolV4NotEnabledErr
+		}
+	}
+
+	return e.write(DryRunOutput{
+		Integration: definition.Name,
+		Result:      result,
+	})
+}
+
+func (e *DryRunEmitter) write(output DryRunOutput) error {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	encoder := json.NewEncoder(e.out)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(output)
+}
@@ -0,0 +1,57 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package emitter
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/newrelic/infrastructure-agent/internal/agent/cmdchannel/fflag"
+	"github.com/newrelic/infrastructure-agent/internal/feature_flags"
+	"github.com/newrelic/infrastructure-agent/internal/integrations/v4/integration"
+	"github.com/newrelic/infrastructure-agent/pkg/databind/pkg/data"
+	"github.com/newrelic/infrastructure-agent/pkg/plugins/ids"
+	integration2 "github.com/newrelic/infrastructure-agent/test/fixture/integration"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDryRunEmitter_Emit_ProtocolV4(t *testing.T) {
+	// GIVEN a dry run emitter
+	out := &bytes.Buffer{}
+	em := NewDryRunEmitter(out, feature_flags.NewManager(map[string]bool{fflag.FlagProtocolV4: true}))
+
+	// WHEN a protocol v4 payload is emitted
+	definition := integration.Definition{
+		Name:            "nri-test",
+		InventorySource: *ids.NewPluginID("cat", "term"),
+		Labels:          map[string]string{"env": "test"},
+	}
+	err := em.Emit(definition, data.Map{}, []data.EntityRewrite{}, integration2.ProtocolV4.Payload)
+	require.NoError(t, err)
+
+	// THEN the payload and its decorated dimensional metrics are written
+	var output DryRunOutput
+	require.NoError(t, json.Unmarshal(out.Bytes(), &output))
+	assert.Equal(t, "nri-test", output.Integration)
+	assert.Equal(t, 4, output.ProtocolVersion)
+	assert.NotEmpty(t, output.Payload)
+	require.NotEmpty(t, output.Metrics)
+	for _, metric := range output.Metrics {
+		assert.Equal(t, "test", metric.Attributes["label.env"])
+	}
+}
+
+func TestDryRunEmitter_Emit_ProtocolV3(t *testing.T) {
+	out := &bytes.Buffer{}
+	em := NewDryRunEmitter(out, feature_flags.NewManager(nil))
+
+	require.NoError(t, em.Emit(integration.Definition{Name: "nri-test"}, data.Map{}, nil,
+		[]byte(`{"name":"nri-test","protocol_version":"3","integration_version":"1.0.0","data":[]}`)))
+
+	var output DryRunOutput
+	require.NoError(t, json.Unmarshal(out.Bytes(), &output))
+	assert.Equal(t, 3, output.ProtocolVersion)
+	assert.Empty(t, output.Metrics)
+}
@@ -5,6 +5,7 @@ package emitter
 import (
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/newrelic/infrastructure-agent/pkg/fwrequest"
 	"github.com/newrelic/infrastructure-agent/pkg/integrations/legacy"
@@ -15,13 +16,17 @@ import (
 
 	"github.com/newrelic/infrastructure-agent/internal/agent"
 	"github.com/newrelic/infrastructure-agent/internal/integrations/v4/integration"
+	"github.com/newrelic/infrastructure-agent/pkg/integrations/v4/derive"
 	"github.com/newrelic/infrastructure-agent/pkg/integrations/v4/dm"
 	"github.com/newrelic/infrastructure-agent/pkg/log"
+	"github.com/newrelic/infrastructure-agent/pkg/schedule"
 )
 
 var (
 	// internal
 	elog = log.WithComponent("integrations.emitter.Emitter")
+	// timeNow is replaceable for testing purposes
+	timeNow = time.Now
 )
 
 // Emitter forwards agent/integration payload to  parser & processors (entity ID decoration...)
@@ -37,11 +42,17 @@ func NewIntegrationEmittor(
 	a Agent,
 	dmEmitter dm.Emitter,
 	ffRetriever feature_flags.Retriever) Emitter {
+	aCtx := a.GetContext()
+	derivedMetricsDir := ""
+	if cfg := aCtx.Config(); cfg != nil {
+		derivedMetricsDir = cfg.IntegrationsDerivedMetricsDir
+	}
 	return &VersionAwareEmitter{
-		aCtx:                a.GetContext(),
+		aCtx:                aCtx,
 		forceProtocolV2ToV3: true,
 		ffRetriever:         ffRetriever,
 		dmEmitter:           dmEmitter,
+		derivers:            derive.NewRegistry(derivedMetricsDir),
 	}
 }
 
@@ -51,6 +62,7 @@ type VersionAwareEmitter struct {
 	forceProtocolV2ToV3 bool
 	ffRetriever         feature_flags.Retriever
 	dmEmitter           dm.Emitter
+	derivers            *derive.Registry
 }
 
 func (e *VersionAwareEmitter) Emit(definition integration.Definition, extraLabels data.Map, entityRewrite []data.EntityRewrite, integrationJSON []byte) error {
@@ -64,6 +76,8 @@ func (e *VersionAwareEmitter) Emit(definition integration.Definition, extraLabel
 		return err
 	}
 
+	extraLabels = silentWindowLabels(definition, extraLabels)
+
 	// dimensional metrics
 	if protocolVersion == protocol.V4 {
 		pluginDataV4, err := dm.ParsePayloadV4(integrationJSON, e.ffRetriever)
@@ -72,6 +86,15 @@ func (e *VersionAwareEmitter) Emit(definition integration.Definition, extraLabel
 			return err
 		}
 
+		if d := e.deriver(definition); d != nil {
+			now := time.Now()
+			d.DeriveV4(&pluginDataV4, now)
+			e.saveDerived(definition, d, now)
+		}
+		if definition.UnitConverter != nil {
+			definition.UnitConverter.ConvertV4(&pluginDataV4)
+		}
+
 		e.dmEmitter.Send(fwrequest.NewFwRequest(definition, extraLabels, entityRewrite, pluginDataV4))
 		return nil
 	}
@@ -82,9 +105,52 @@ func (e *VersionAwareEmitter) Emit(definition integration.Definition, extraLabel
 		return err
 	}
 
+	if d := e.deriver(definition); d != nil {
+		now := time.Now()
+		d.DeriveV3(&pluginDataV3, now)
+		e.saveDerived(definition, d, now)
+	}
+	if definition.UnitConverter != nil {
+		definition.UnitConverter.ConvertV3(&pluginDataV3)
+	}
+
 	return e.emitV3(fwrequest.NewFwRequestLegacy(definition, extraLabels, entityRewrite, pluginDataV3), protocolVersion)
 }
 
+// silentWindowLabels adds the silent window attribute to the extra labels while a window of the tag action
+// is active. Keys without the "label." prefix are emitted as attributes.
+func silentWindowLabels(definition integration.Definition, extraLabels data.Map) data.Map {
+	w := schedule.Active(definition.SilentWindows, timeNow())
+	if w == nil || w.Action != schedule.ActionTag {
+		return extraLabels
+	}
+	labels := make(data.Map, len(extraLabels)+1)
+	for k, v := range extraLabels {
+		labels[k] = v
+	}
+	labels[schedule.TagAttribute] = w.Name
+	return labels
+}
+
+// deriver returns the Deriver for the derived metrics of the integration, if any.
+func (e *VersionAwareEmitter) deriver(definition integration.Definition) *derive.Deriver {
+	if e.derivers == nil {
+		return nil
+	}
+	d, err := e.derivers.Deriver(definition.Name, definition.ConfigPath, definition.ConfigHash, definition.DerivedMetrics)
+	if err != nil {
+		elog.WithError(err).WithField("integration", definition.Name).Warn("can't derive integration metrics, emitting raw values")
+		return nil
+	}
+	return d
+}
+
+func (e *VersionAwareEmitter) saveDerived(definition integration.Definition, d *derive.Deriver, now time.Time) {
+	if err := d.Save(now); err != nil {
+		elog.WithError(err).WithField("integration", definition.Name).Warn("can't store derived metrics state")
+	}
+}
+
 func (e *VersionAwareEmitter) emitV3(dto fwrequest.FwRequestLegacy, protocolVersion int) error {
 	plugin := agent.NewExternalPluginCommon(dto.Definition.PluginID(dto.Data.Name), e.aCtx, dto.Definition.Name)
 	labels, extraAnnotations := dto.LabelsAndExtraAnnotations()
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/newrelic/infrastructure-agent/pkg/entity/host"
 	"github.com/newrelic/infrastructure-agent/pkg/fwrequest"
@@ -23,6 +24,7 @@ import (
 	"github.com/newrelic/infrastructure-agent/pkg/integrations/v4/dm"
 	"github.com/newrelic/infrastructure-agent/pkg/integrations/v4/protocol"
 	"github.com/newrelic/infrastructure-agent/pkg/plugins/ids"
+	"github.com/newrelic/infrastructure-agent/pkg/schedule"
 	"github.com/newrelic/infrastructure-agent/pkg/sysinfo"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -588,6 +590,30 @@ func mockAgent() *mocks.AgentContext {
 	return ma
 }
 
+func TestSilentWindowLabels(t *testing.T) {
+	now := time.Date(2021, 3, 1, 2, 30, 0, 0, time.UTC)
+	timeNow = func() time.Time { return now }
+	defer func() { timeNow = time.Now }()
+
+	tag, err := schedule.NewWindow("backup", "0 2 * * *", "1h", "UTC", schedule.ActionTag)
+	require.NoError(t, err)
+	definition := integration.Definition{SilentWindows: []*schedule.Window{tag}}
+	extraLabels := data.Map{"label.env": "prod"}
+
+	// WHEN a window of the tag action is active
+	labels := silentWindowLabels(definition, extraLabels)
+
+	// THEN the window attribute is added, without modifying the discovered labels
+	assert.Equal(t, data.Map{"label.env": "prod", schedule.TagAttribute: "backup"}, labels)
+	assert.Equal(t, data.Map{"label.env": "prod"}, extraLabels)
+
+	// WHEN no window is active
+	now = now.Add(time.Hour)
+
+	// THEN the labels are kept
+	assert.Equal(t, extraLabels, silentWindowLabels(definition, extraLabels))
+}
+
 func mockMetricSender() *mockedMetricsSender {
 	mockedMetricsSender := &mockedMetricsSender{}
 	mockedMetricsSender.On("SendMetrics", mock.AnythingOfType("[]protocol.Metric")).Once()
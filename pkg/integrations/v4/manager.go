@@ -20,6 +20,7 @@ import (
 	"github.com/newrelic/infrastructure-agent/pkg/integrations/v4/fs"
 
 	"github.com/fsnotify/fsnotify"
+	"github.com/newrelic/infrastructure-agent/internal/integrations/v4/breaker"
 	"github.com/newrelic/infrastructure-agent/internal/integrations/v4/files"
 	"github.com/newrelic/infrastructure-agent/internal/integrations/v4/integration"
 	"github.com/newrelic/infrastructure-agent/internal/integrations/v4/runner"
@@ -108,6 +109,7 @@ type Manager struct {
 	definitionQueue <-chan integration.Definition
 	handleCmdReq    cmdrequest.HandleFn
 	tracker         *stoppable.Tracker
+	breakers        *breaker.Registry
 }
 
 // groupContext pairs a runner.Group with its cancellation context
@@ -143,6 +145,15 @@ func (g *groupContext) stop() {
 	}
 }
 
+// update replaces the running integrations by the ones of the passed group, restarting only the
+// changed ones.
+func (g *groupContext) update(gr runner.Group) (stopped, started int) {
+	g.l.Lock()
+	defer g.l.Unlock()
+
+	return g.runner.Update(gr)
+}
+
 func (g *groupContext) isRunning() bool {
 	g.l.RLock()
 	defer g.l.RUnlock()
@@ -162,15 +173,18 @@ type Configuration struct {
 	Verbose int
 	// PassthroughEnvironment holds a copy of its homonym in config.Config.
 	PassthroughEnvironment []string
+	// Telemetry enables the dimensional metrics reported after each integration execution.
+	Telemetry bool
 }
 
-func NewConfig(verbose int, features map[string]bool, passthroughEnvs, configFolders, definitionFolders []string) Configuration {
+func NewConfig(verbose int, features map[string]bool, passthroughEnvs, configFolders, definitionFolders []string, telemetry bool) Configuration {
 	return Configuration{
 		ConfigFolders:          configFolders,
 		AgentFeatures:          features,
 		DefinitionFolders:      definitionFolders,
 		Verbose:                verbose,
 		PassthroughEnvironment: append(passthroughEnvs, legacy.DefaultInheritedEnv...),
+		Telemetry:              telemetry,
 	}
 }
 
@@ -178,12 +192,14 @@ func NewConfig(verbose int, features map[string]bool, passthroughEnvs, configFol
 // not belonging to the protocol V4.
 // Usually, "configFolders" will be the value of the "pluginInstanceDir" configuration option
 // The "definitionFolders" refer to the v3 definition yaml configs, placed here for v3 integrations backwards-support
+// The "breakers" registry is optional (nil allowed), disabling the integrations circuit breaker.
 func NewManager(
 	cfg Configuration,
 	emitter emitter.Emitter,
 	il integration.InstancesLookup,
 	definitionQ chan integration.Definition,
 	tracker *stoppable.Tracker,
+	breakers *breaker.Registry,
 ) *Manager {
 	watcher, err := fsnotify.NewWatcher()
 	if err != nil {
@@ -200,6 +216,7 @@ func NewManager(
 		definitionQueue: definitionQ,
 		handleCmdReq:    cmdrequest.NewHandleFn(definitionQ, il, illog),
 		tracker:         tracker,
+		breakers:        breakers,
 	}
 
 	// Loads all the configuration files in the passed configFolders
@@ -297,7 +314,7 @@ func (mgr *Manager) loadEnabledRunnerGroups(cfgs map[string]config2.YAML) {
 func (mgr *Manager) loadRunnerGroup(path string, cfg config2.YAML, cmdFF *runner.CmdFF) (*groupContext, error) {
 	f := runner.NewFeatures(mgr.config.AgentFeatures, cmdFF)
 	loader := runner.NewLoadFn(cfg, f)
-	gr, fc, err := runner.NewGroup(loader, mgr.lookup, mgr.config.PassthroughEnvironment, mgr.emitter, mgr.handleCmdReq, path)
+	gr, fc, err := runner.NewGroup(loader, mgr.lookup, mgr.config.PassthroughEnvironment, mgr.emitter, mgr.handleCmdReq, mgr.breakers, mgr.config.Telemetry, path)
 	if err != nil {
 		return nil, err
 	}
@@ -314,7 +331,7 @@ func (mgr *Manager) handleRequestsQueue(ctx context.Context) {
 			return
 
 		case def := <-mgr.definitionQueue:
-			r := runner.NewRunner(def, mgr.emitter, nil, nil, mgr.handleCmdReq)
+			r := runner.NewRunner(def, mgr.emitter, nil, nil, mgr.handleCmdReq, mgr.breakers, mgr.config.Telemetry)
 			// tracking so cmd requests can be stopped by hash
 			runCtx, pidWChan := mgr.tracker.Track(ctx, def.CmdChannelHash)
 			go func(hash string) {
@@ -392,11 +409,10 @@ func (mgr *Manager) handleFileEvent(ctx context.Context, event *fsnotify.Event)
 		return
 	}
 
-	mgr.stopRunnerGroup(event.Name)
-
 	if isDelete {
 		if _, err := os.Stat(event.Name); os.IsNotExist(err) {
 			// if the file has been deleted, we don't continue trying to load configurations
+			mgr.stopRunnerGroup(event.Name)
 			return
 		}
 
@@ -413,13 +429,16 @@ func (mgr *Manager) handleFileEvent(ctx context.Context, event *fsnotify.Event)
 		}
 
 	}
-	// creating new configuration and starting the new runner.Group instances
+	// loading the new configuration and starting or updating the runner.Group instances
 	mgr.runIntegrationFromPath(ctx, event.Name, isCreate, &elog, nil)
 }
 
+// runIntegrationFromPath loads the integrations of a configuration file and runs them. If the file
+// integrations are already running, only the ones whose definition changed are restarted.
 func (mgr *Manager) runIntegrationFromPath(ctx context.Context, cfgPath string, isCreate bool, elog *log.Entry, cmdFF *runner.CmdFF) {
 	cfg, err := loadConfig(cfgPath)
 	if err != nil {
+		mgr.stopRunnerGroup(cfgPath)
 		if err == legacyYAML {
 			elog.Debug("Skipping v3 integration.")
 		} else {
@@ -434,10 +453,20 @@ func (mgr *Manager) runIntegrationFromPath(ctx context.Context, cfgPath string,
 
 	rc, err := mgr.loadRunnerGroup(cfgPath, cfg, cmdFF)
 	if err != nil {
+		mgr.stopRunnerGroup(cfgPath)
 		elog.WithError(err).Warn("can't instantiate integrations from file. This may happen if you are editing a file and saving intermediate changes")
 		return
 	}
 
+	if current, ok := mgr.runners.Get(cfgPath); ok && current != nil && current.isRunning() {
+		stopped, started := current.update(rc.runner)
+		illog.WithField("file", cfgPath).
+			WithField("stopped", stopped).
+			WithField("started", started).
+			Info("integration file modified. Restarting the changed integrations, if any")
+		return
+	}
+
 	mgr.runners.Set(cfgPath, rc)
 	rc.start(ctx)
 }
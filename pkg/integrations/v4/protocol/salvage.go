@@ -0,0 +1,71 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package protocol
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+const dataSetsField = "data"
+
+// SalvagePayload recovers the complete datasets from a truncated integration payload, as the
+// one written by an integration that has been terminated while flushing its output.
+// It returns a valid payload holding the top-level fields and the "data" entries that were
+// completely written, or false if no dataset could be recovered.
+func SalvagePayload(raw []byte) ([]byte, bool) {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	if t, err := dec.Token(); err != nil || t != json.Delim('{') {
+		return nil, false
+	}
+
+	fields := map[string]json.RawMessage{}
+	var dataSets []json.RawMessage
+fields:
+	for dec.More() {
+		t, err := dec.Token()
+		if err != nil {
+			break
+		}
+		key, ok := t.(string)
+		if !ok {
+			break
+		}
+		if key != dataSetsField {
+			var value json.RawMessage
+			if err := dec.Decode(&value); err != nil {
+				break
+			}
+			fields[key] = value
+			continue
+		}
+		if t, err := dec.Token(); err != nil || t != json.Delim('[') {
+			break
+		}
+		for dec.More() {
+			var dataSet json.RawMessage
+			if err := dec.Decode(&dataSet); err != nil {
+				break fields
+			}
+			dataSets = append(dataSets, dataSet)
+		}
+		// closing the data array
+		if _, err := dec.Token(); err != nil {
+			break
+		}
+	}
+
+	if len(dataSets) == 0 {
+		return nil, false
+	}
+
+	var err error
+	if fields[dataSetsField], err = json.Marshal(dataSets); err != nil {
+		return nil, false
+	}
+	salvaged, err := json.Marshal(fields)
+	if err != nil {
+		return nil, false
+	}
+	return salvaged, true
+}
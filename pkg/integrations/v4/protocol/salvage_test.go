@@ -0,0 +1,43 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package protocol
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSalvagePayload(t *testing.T) {
+	truncated := []byte(`{"name":"com.newrelic.test","protocol_version":"3","integration_version":"1.0.0",` +
+		`"data":[{"entity":{"name":"first","type":"test"},"metrics":[{"event_type":"TestSample","value":1}]},` +
+		`{"entity":{"name":"second","type":"test"},"metrics":[{"event_type":"TestSample","val`)
+
+	salvaged, ok := SalvagePayload(truncated)
+	require.True(t, ok)
+
+	version, err := VersionFromPayload(salvaged, true)
+	require.NoError(t, err)
+	assert.Equal(t, V3, version)
+
+	data, err := ParsePayload(salvaged, version)
+	require.NoError(t, err)
+	assert.Equal(t, "com.newrelic.test", data.Name)
+	assert.Equal(t, "1.0.0", data.IntegrationVersion)
+	require.Len(t, data.DataSets, 1)
+	assert.Equal(t, "first", data.DataSets[0].Entity.Name)
+}
+
+func TestSalvagePayload_NothingToSalvage(t *testing.T) {
+	for _, raw := range []string{
+		``,
+		`not a json`,
+		`["data"]`,
+		`{"name":"com.newrelic.test","protocol_version":"3"`,
+		`{"name":"com.newrelic.test","protocol_version":"3","data":[{"entity":{"name":"first"`,
+	} {
+		_, ok := SalvagePayload([]byte(raw))
+		assert.False(t, ok, raw)
+	}
+}
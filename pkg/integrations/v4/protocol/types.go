@@ -59,6 +59,8 @@ type Metric struct {
 	Interval   *int64                 `json:"interval.ms"`
 	Attributes map[string]interface{} `json:"attributes"`
 	Value      json.RawMessage        `json:"value"`
+	// Unit of the metric values, as "bytes", "ms" or "celsius". Optional
+	Unit string `json:"unit,omitempty"`
 }
 
 type SummaryValue struct {
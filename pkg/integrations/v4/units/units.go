@@ -0,0 +1,237 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+// Package units converts the values reported by integrations to the units configured for their
+// metrics, so the same magnitudes are reported consistently across integrations.
+package units
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/newrelic/infrastructure-agent/pkg/integrations/v4/config"
+	"github.com/newrelic/infrastructure-agent/pkg/integrations/v4/protocol"
+)
+
+// we'll identify any regular expression as a string between two slashes, as the discovery matchers
+var metaRegexp = regexp.MustCompile("^/.*/$")
+
+type kind string
+
+const (
+	kindData        kind = "data"
+	kindTime        kind = "time"
+	kindTemperature kind = "temperature"
+)
+
+// unit converts its values to the base unit of its kind as: base = (value + shift) * mul / div.
+// Keeping the multiplier and the divisor apart avoids rounding errors in the usual conversions.
+type unit struct {
+	kind  kind
+	shift float64
+	mul   float64
+	div   float64
+}
+
+// units by lowercase name. Data units are powers of 1024, as the max_output_size
+var units = map[string]unit{
+	"b":     {kind: kindData, mul: 1, div: 1},
+	"byte":  {kind: kindData, mul: 1, div: 1},
+	"bytes": {kind: kindData, mul: 1, div: 1},
+	"kb":    {kind: kindData, mul: 1 << 10, div: 1},
+	"mb":    {kind: kindData, mul: 1 << 20, div: 1},
+	"gb":    {kind: kindData, mul: 1 << 30, div: 1},
+	"tb":    {kind: kindData, mul: 1 << 40, div: 1},
+
+	"ns":      {kind: kindTime, mul: 1, div: 1e9},
+	"us":      {kind: kindTime, mul: 1, div: 1e6},
+	"ms":      {kind: kindTime, mul: 1, div: 1e3},
+	"s":       {kind: kindTime, mul: 1, div: 1},
+	"seconds": {kind: kindTime, mul: 1, div: 1},
+	"min":     {kind: kindTime, mul: 60, div: 1},
+	"h":       {kind: kindTime, mul: 3600, div: 1},
+
+	"celsius":    {kind: kindTemperature, mul: 1, div: 1},
+	"fahrenheit": {kind: kindTemperature, shift: -32, mul: 5, div: 9},
+	"kelvin":     {kind: kindTemperature, shift: -273.15, mul: 1, div: 1},
+}
+
+func lookup(name string) (unit, bool) {
+	u, ok := units[strings.ToLower(name)]
+	return u, ok
+}
+
+// Names returns the supported unit names.
+func Names() []string {
+	names := make([]string, 0, len(units))
+	for name := range units {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// linear conversion between two units of the same kind
+type linear struct {
+	from unit
+	to   unit
+}
+
+func conversion(from, to string) (linear, error) {
+	f, ok := lookup(from)
+	if !ok {
+		return linear{}, fmt.Errorf("unknown unit %q", from)
+	}
+	t, ok := lookup(to)
+	if !ok {
+		return linear{}, fmt.Errorf("unknown unit %q", to)
+	}
+	if f.kind != t.kind {
+		return linear{}, fmt.Errorf("can't convert %s units (%s) to %s units (%s)", f.kind, from, t.kind, to)
+	}
+	return linear{from: f, to: t}, nil
+}
+
+// absolute converts a measured value, as a gauge or a summary min/max.
+func (l linear) absolute(value float64) float64 {
+	return l.relative(value+l.from.shift) - l.to.shift
+}
+
+// relative converts a difference or an accumulation of values, as a count or a rate, which aren't
+// shifted by the offset of the units (e.g. an increase of 1 celsius is an increase of 1.8 fahrenheit).
+func (l linear) relative(value float64) float64 {
+	return value * l.from.mul / l.from.div * l.to.div / l.to.mul
+}
+
+type rule struct {
+	matches func(name string) bool
+	from    string
+	to      string
+}
+
+// Converter converts the values of the metrics that match its rules.
+type Converter struct {
+	rules []rule
+}
+
+// New creates a Converter for the passed conversions, or nil if there aren't conversions.
+func New(conversions []config.UnitConversion) (*Converter, error) {
+	if len(conversions) == 0 {
+		return nil, nil
+	}
+	c := &Converter{}
+	for _, uc := range conversions {
+		if _, ok := lookup(uc.To); !ok {
+			return nil, fmt.Errorf("unit conversion for %q: unknown unit %q. Supported units: %s",
+				uc.Metric, uc.To, strings.Join(Names(), ", "))
+		}
+		if uc.From != "" {
+			if _, err := conversion(uc.From, uc.To); err != nil {
+				return nil, fmt.Errorf("unit conversion for %q: %s", uc.Metric, err)
+			}
+		}
+		r := rule{from: uc.From, to: uc.To}
+		if metaRegexp.MatchString(uc.Metric) {
+			re, err := regexp.Compile(uc.Metric[1 : len(uc.Metric)-1])
+			if err != nil {
+				return nil, fmt.Errorf("unit conversion metric %q should be a valid regular expression: %s", uc.Metric, err)
+			}
+			r.matches = re.MatchString
+		} else {
+			name := uc.Metric
+			r.matches = func(metric string) bool { return metric == name }
+		}
+		c.rules = append(c.rules, r)
+	}
+	return c, nil
+}
+
+// conversion returns the conversion of the first rule matching the metric. The declared unit is used
+// when the rule doesn't specify the source unit.
+func (c *Converter) conversion(metric, declaredUnit string) (linear, string, bool) {
+	for _, r := range c.rules {
+		if !r.matches(metric) {
+			continue
+		}
+		from := r.from
+		if from == "" {
+			from = declaredUnit
+		}
+		if from == "" {
+			return linear{}, "", false
+		}
+		l, err := conversion(from, r.to)
+		if err != nil {
+			return linear{}, "", false
+		}
+		return l, r.to, true
+	}
+	return linear{}, "", false
+}
+
+// ConvertV3 converts the numeric metric sample attributes matching a rule with a source unit, as the
+// v3 protocol doesn't declare the units of the attributes.
+func (c *Converter) ConvertV3(data *protocol.PluginDataV3) {
+	for i := range data.DataSets {
+		for _, sample := range data.DataSets[i].Metrics {
+			for attr, value := range sample {
+				number, ok := value.(float64)
+				if !ok {
+					continue
+				}
+				if l, _, ok := c.conversion(attr, ""); ok {
+					sample[attr] = l.absolute(number)
+				}
+			}
+		}
+	}
+}
+
+// ConvertV4 converts the values of the matching gauge, count, rate and summary metrics, updating their unit.
+func (c *Converter) ConvertV4(data *protocol.DataV4) {
+	for i := range data.DataSets {
+		metrics := data.DataSets[i].Metrics
+		for j := range metrics {
+			m := &metrics[j]
+			l, to, ok := c.conversion(m.Name, m.Unit)
+			if !ok {
+				continue
+			}
+			var converted interface{}
+			switch m.Type {
+			case protocol.MetricTypeGauge:
+				value, err := m.NumericValue()
+				if err != nil {
+					continue
+				}
+				converted = l.absolute(value)
+			case protocol.MetricTypeCount, protocol.MetricTypeRate, "cumulative-count", "cumulative-rate":
+				value, err := m.NumericValue()
+				if err != nil {
+					continue
+				}
+				converted = l.relative(value)
+			case protocol.MetricTypeSummary:
+				value, err := m.SummaryValue()
+				if err != nil {
+					continue
+				}
+				// each of the summed values is shifted by the offset of the units
+				value.Sum = l.relative(value.Sum+l.from.shift*value.Count) - l.to.shift*value.Count
+				value.Min = l.absolute(value.Min)
+				value.Max = l.absolute(value.Max)
+				converted = value
+			default:
+				continue
+			}
+			raw, err := json.Marshal(converted)
+			if err != nil {
+				continue
+			}
+			m.Value = raw
+			m.Unit = to
+		}
+	}
+}
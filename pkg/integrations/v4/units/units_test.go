@@ -0,0 +1,105 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package units
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/newrelic/infrastructure-agent/pkg/integrations/v4/config"
+	"github.com/newrelic/infrastructure-agent/pkg/integrations/v4/protocol"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNew_Invalid(t *testing.T) {
+	c, err := New(nil)
+	require.NoError(t, err)
+	assert.Nil(t, c)
+
+	_, err = New([]config.UnitConversion{{Metric: "memory", To: "furlongs"}})
+	assert.Error(t, err)
+	_, err = New([]config.UnitConversion{{Metric: "memory", From: "bytes", To: "ms"}})
+	assert.Error(t, err)
+	_, err = New([]config.UnitConversion{{Metric: "/[invalid/", To: "MB"}})
+	assert.Error(t, err)
+}
+
+func TestConvertV3(t *testing.T) {
+	// GIVEN conversions for attributes of a sample, one without source unit
+	c, err := New([]config.UnitConversion{
+		{Metric: "/Bytes$/", From: "bytes", To: "MB"},
+		{Metric: "temperature", From: "celsius", To: "fahrenheit"},
+		{Metric: "uptime", To: "h"},
+	})
+	require.NoError(t, err)
+	data := &protocol.PluginDataV3{DataSets: []protocol.PluginDataSetV3{{
+		PluginDataSet: protocol.PluginDataSet{
+			Metrics: []protocol.MetricData{{
+				"event_type":  "SensorSample",
+				"usedBytes":   3145728.0,
+				"temperature": 100.0,
+				"uptime":      7200.0,
+				"readBytes":   "unknown",
+			}},
+		},
+	}}}
+
+	// WHEN they are converted
+	c.ConvertV3(data)
+
+	// THEN the numeric attributes with a source unit are converted
+	assert.Equal(t, protocol.MetricData{
+		"event_type":  "SensorSample",
+		"usedBytes":   3.0,
+		"temperature": 212.0,
+		"uptime":      7200.0,
+		"readBytes":   "unknown",
+	}, data.DataSets[0].Metrics[0])
+}
+
+func TestConvertV4(t *testing.T) {
+	// GIVEN a conversion that relies on the units declared by the metrics
+	c, err := New([]config.UnitConversion{
+		{Metric: "/latency$/", To: "ms"},
+		{Metric: "/temperature/", To: "celsius"},
+	})
+	require.NoError(t, err)
+	metric := func(name string, metricType protocol.MetricType, unit, value string) protocol.Metric {
+		return protocol.Metric{Name: name, Type: metricType, Unit: unit, Value: json.RawMessage(value)}
+	}
+	data := &protocol.DataV4{DataSets: []protocol.Dataset{{
+		Metrics: []protocol.Metric{
+			metric("http.latency", protocol.MetricTypeGauge, "s", "0.25"),
+			metric("db.latency", protocol.MetricTypeGauge, "", "3"),
+			metric("cpu.temperature", protocol.MetricTypeGauge, "fahrenheit", "212"),
+			metric("cpu.temperature.increase", protocol.MetricTypeCount, "fahrenheit", "18"),
+			metric("disk.temperature", protocol.MetricTypeSummary, "kelvin",
+				`{"count": 2, "sum": 600, "min": 293.15, "max": 306.85}`),
+		},
+	}}}
+
+	// WHEN they are converted
+	c.ConvertV4(data)
+
+	// THEN the metrics with a declared unit are converted
+	metrics := data.DataSets[0].Metrics
+	assert.JSONEq(t, "250", string(metrics[0].Value))
+	assert.Equal(t, "ms", metrics[0].Unit)
+	assert.JSONEq(t, "3", string(metrics[1].Value))
+	assert.Empty(t, metrics[1].Unit)
+	assert.JSONEq(t, "100", string(metrics[2].Value))
+	assert.Equal(t, "celsius", metrics[2].Unit)
+
+	// AND the counts aren't shifted by the offset of the units
+	assert.JSONEq(t, "10", string(metrics[3].Value))
+
+	// AND the summaries are converted consistently
+	summary, err := metrics[4].SummaryValue()
+	require.NoError(t, err)
+	assert.Equal(t, 2.0, summary.Count)
+	assert.InDelta(t, 53.7, summary.Sum, 0.0001)
+	assert.InDelta(t, 20.0, summary.Min, 0.0001)
+	assert.InDelta(t, 33.7, summary.Max, 0.0001)
+}
@@ -0,0 +1,71 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package metrics
+
+import "time"
+
+// cgroupUsage is the resource usage of the cgroup of the agent, along with its limits.
+type cgroupUsage struct {
+	cpuUserNs, cpuSystemNs uint64
+	// CPUs allowed by the CPU quota, zero if unlimited
+	cpuLimit float64
+	// memory in use, excluding the inactive page cache as "docker stats" does, and its limit, zero if unlimited
+	memoryUsed, memoryLimit uint64
+	time                    time.Time
+}
+
+// CgroupLimitsMonitor rescales the host CPU and memory utilization of the system samples to the limits of
+// the cgroup the agent runs in, e.g. the limits of its container.
+type CgroupLimitsMonitor struct {
+	dir      string
+	usage    func(dir string) (*cgroupUsage, error)
+	previous *cgroupUsage
+}
+
+func NewCgroupLimitsMonitor() *CgroupLimitsMonitor {
+	return &CgroupLimitsMonitor{dir: cgroupDir, usage: readCgroupUsage}
+}
+
+// Apply replaces the CPU and memory utilization by their cgroup counterparts, for the resources with a
+// limit lower than the host capacity. The CPU utilization is replaced from the second invocation, as it
+// requires the CPU time of the previous one.
+func (m *CgroupLimitsMonitor) Apply(cpu *CPUSample, memory *MemorySample) error {
+	current, err := m.usage(m.dir)
+	if err != nil {
+		return err
+	}
+	previous := m.previous
+	m.previous = current
+
+	if memory != nil && current.memoryLimit > 0 && float64(current.memoryLimit) < memory.MemoryTotal {
+		limit, used := float64(current.memoryLimit), float64(current.memoryUsed)
+		if used > limit {
+			used = limit
+		}
+		memory.MemoryTotal = limit
+		memory.MemoryUsed = used
+		memory.MemoryFree = limit - used
+		memory.MemoryUsedPercent = used / limit * 100
+		memory.MemoryFreePercent = 100 - memory.MemoryUsedPercent
+	}
+
+	if cpu == nil || current.cpuLimit <= 0 || previous == nil {
+		return nil
+	}
+	elapsedNs := current.time.Sub(previous.time).Nanoseconds()
+	if elapsedNs <= 0 || current.cpuUserNs < previous.cpuUserNs || current.cpuSystemNs < previous.cpuSystemNs {
+		return nil
+	}
+	available := float64(elapsedNs) * current.cpuLimit
+	cpu.CPUUserPercent = float64(current.cpuUserNs-previous.cpuUserNs) / available * 100
+	cpu.CPUSystemPercent = float64(current.cpuSystemNs-previous.cpuSystemNs) / available * 100
+	// IO wait and steal time aren't accounted per cgroup
+	cpu.CPUIOWaitPercent = 0
+	cpu.CPUStealPercent = 0
+	cpu.CPUPercent = cpu.CPUUserPercent + cpu.CPUSystemPercent
+	cpu.CPUIdlePercent = 100 - cpu.CPUPercent
+	if cpu.CPUIdlePercent < 0 {
+		cpu.CPUIdlePercent = 0
+	}
+	return nil
+}
@@ -0,0 +1,165 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cgroupDir is where the cgroups of the agent are mounted. Inside a container, it's the cgroup of the
+// container rather than the host root, so the host /sys isn't used.
+const cgroupDir = "/sys/fs/cgroup"
+
+// cgroupV1TickNs is the duration of the USER_HZ ticks of cpuacct.stat.
+const cgroupV1TickNs = uint64(10 * time.Millisecond)
+
+// readCgroupUsage reads the usage and limits of the cgroup mounted in dir, whether it's the cgroup v2
+// unified hierarchy or the cgroup v1 controllers. Missing files are considered unlimited.
+func readCgroupUsage(dir string) (*cgroupUsage, error) {
+	if _, err := os.Stat(filepath.Join(dir, "cgroup.controllers")); err == nil {
+		return readCgroupV2Usage(dir)
+	}
+	return readCgroupV1Usage(dir)
+}
+
+func readCgroupV2Usage(dir string) (*cgroupUsage, error) {
+	usage := &cgroupUsage{time: time.Now()}
+
+	// "max 100000" or "<quota> <period>", in microseconds
+	if content, err := readCgroupFile(filepath.Join(dir, "cpu.max")); err != nil {
+		return nil, err
+	} else if fields := strings.Fields(content); len(fields) == 2 && fields[0] != "max" {
+		if usage.cpuLimit, err = cpuQuota(fields[0], fields[1]); err != nil {
+			return nil, err
+		}
+	}
+	stat, err := readCgroupStat(filepath.Join(dir, "cpu.stat"))
+	if err != nil {
+		return nil, err
+	}
+	usage.cpuUserNs = stat["user_usec"] * uint64(time.Microsecond)
+	usage.cpuSystemNs = stat["system_usec"] * uint64(time.Microsecond)
+
+	if usage.memoryLimit, err = readCgroupUint(filepath.Join(dir, "memory.max")); err != nil {
+		return nil, err
+	}
+	current, err := readCgroupUint(filepath.Join(dir, "memory.current"))
+	if err != nil {
+		return nil, err
+	}
+	memStat, err := readCgroupStat(filepath.Join(dir, "memory.stat"))
+	if err != nil {
+		return nil, err
+	}
+	usage.memoryUsed = workingSet(current, memStat["inactive_file"])
+	return usage, nil
+}
+
+func readCgroupV1Usage(dir string) (*cgroupUsage, error) {
+	usage := &cgroupUsage{time: time.Now()}
+
+	// the quota is -1 if unlimited
+	quota, err := readCgroupFile(filepath.Join(dir, "cpu", "cpu.cfs_quota_us"))
+	if err != nil {
+		return nil, err
+	}
+	period, err := readCgroupFile(filepath.Join(dir, "cpu", "cpu.cfs_period_us"))
+	if err != nil {
+		return nil, err
+	}
+	if quota != "" && period != "" && !strings.HasPrefix(quota, "-") {
+		if usage.cpuLimit, err = cpuQuota(quota, period); err != nil {
+			return nil, err
+		}
+	}
+	stat, err := readCgroupStat(filepath.Join(dir, "cpuacct", "cpuacct.stat"))
+	if err != nil {
+		return nil, err
+	}
+	usage.cpuUserNs = stat["user"] * cgroupV1TickNs
+	usage.cpuSystemNs = stat["system"] * cgroupV1TickNs
+
+	// unlimited memory is reported as a huge page aligned number, larger than the host memory
+	if usage.memoryLimit, err = readCgroupUint(filepath.Join(dir, "memory", "memory.limit_in_bytes")); err != nil {
+		return nil, err
+	}
+	current, err := readCgroupUint(filepath.Join(dir, "memory", "memory.usage_in_bytes"))
+	if err != nil {
+		return nil, err
+	}
+	memStat, err := readCgroupStat(filepath.Join(dir, "memory", "memory.stat"))
+	if err != nil {
+		return nil, err
+	}
+	usage.memoryUsed = workingSet(current, memStat["total_inactive_file"])
+	return usage, nil
+}
+
+func cpuQuota(quota, period string) (float64, error) {
+	q, err := strconv.ParseFloat(quota, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid CPU quota %q: %v", quota, err)
+	}
+	p, err := strconv.ParseFloat(period, 64)
+	if err != nil || p <= 0 {
+		return 0, fmt.Errorf("invalid CPU period %q", period)
+	}
+	return q / p, nil
+}
+
+func workingSet(usage, inactiveFile uint64) uint64 {
+	if inactiveFile > usage {
+		return 0
+	}
+	return usage - inactiveFile
+}
+
+// readCgroupFile returns the trimmed content of a cgroup file, or an empty string if it doesn't exist.
+func readCgroupFile(path string) (string, error) {
+	content, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	return strings.TrimSpace(string(content)), err
+}
+
+// readCgroupUint returns the value of a cgroup file, or zero if it doesn't exist or its value is "max".
+func readCgroupUint(path string) (uint64, error) {
+	content, err := readCgroupFile(path)
+	if err != nil || content == "" || content == "max" {
+		return 0, err
+	}
+	return strconv.ParseUint(content, 10, 64)
+}
+
+// readCgroupStat parses the "key value" lines of a cgroup stat file, or returns an empty map if it doesn't
+// exist.
+func readCgroupStat(path string) (map[string]uint64, error) {
+	stat := map[string]uint64{}
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return stat, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 {
+			continue
+		}
+		if value, err := strconv.ParseUint(fields[1], 10, 64); err == nil {
+			stat[fields[0]] = value
+		}
+	}
+	return stat, scanner.Err()
+}
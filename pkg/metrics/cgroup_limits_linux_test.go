@@ -0,0 +1,81 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package metrics
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeCgroupFiles(t *testing.T, files map[string]string) string {
+	dir, err := ioutil.TempDir("", "cgroup")
+	require.NoError(t, err)
+	for path, content := range files {
+		path = filepath.Join(dir, path)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, ioutil.WriteFile(path, []byte(content), 0644))
+	}
+	return dir
+}
+
+func TestReadCgroupUsage_V2(t *testing.T) {
+	dir := writeCgroupFiles(t, map[string]string{
+		"cgroup.controllers": "cpuset cpu io memory pids\n",
+		"cpu.max":            "150000 100000\n",
+		"cpu.stat":           "usage_usec 3000000\nuser_usec 2000000\nsystem_usec 1000000\n",
+		"memory.max":         "536870912\n",
+		"memory.current":     "314572800\n",
+		"memory.stat":        "anon 209715200\nfile 104857600\ninactive_file 52428800\n",
+	})
+	defer os.RemoveAll(dir)
+
+	usage, err := readCgroupUsage(dir)
+
+	require.NoError(t, err)
+	assert.Equal(t, 1.5, usage.cpuLimit)
+	assert.Equal(t, uint64(2e9), usage.cpuUserNs)
+	assert.Equal(t, uint64(1e9), usage.cpuSystemNs)
+	assert.Equal(t, uint64(536870912), usage.memoryLimit)
+	assert.Equal(t, uint64(262144000), usage.memoryUsed)
+}
+
+func TestReadCgroupUsage_V2_Unlimited(t *testing.T) {
+	dir := writeCgroupFiles(t, map[string]string{
+		"cgroup.controllers": "cpu memory\n",
+		"cpu.max":            "max 100000\n",
+		"memory.max":         "max\n",
+	})
+	defer os.RemoveAll(dir)
+
+	usage, err := readCgroupUsage(dir)
+
+	require.NoError(t, err)
+	assert.Zero(t, usage.cpuLimit)
+	assert.Zero(t, usage.memoryLimit)
+}
+
+func TestReadCgroupUsage_V1(t *testing.T) {
+	dir := writeCgroupFiles(t, map[string]string{
+		"cpu/cpu.cfs_quota_us":         "50000\n",
+		"cpu/cpu.cfs_period_us":        "100000\n",
+		"cpuacct/cpuacct.stat":         "user 300\nsystem 100\n",
+		"memory/memory.limit_in_bytes": "268435456\n",
+		"memory/memory.usage_in_bytes": "134217728\n",
+		"memory/memory.stat":           "cache 67108864\ntotal_inactive_file 33554432\n",
+	})
+	defer os.RemoveAll(dir)
+
+	usage, err := readCgroupUsage(dir)
+
+	require.NoError(t, err)
+	assert.Equal(t, 0.5, usage.cpuLimit)
+	assert.Equal(t, uint64(3e9), usage.cpuUserNs)
+	assert.Equal(t, uint64(1e9), usage.cpuSystemNs)
+	assert.Equal(t, uint64(268435456), usage.memoryLimit)
+	assert.Equal(t, uint64(100663296), usage.memoryUsed)
+}
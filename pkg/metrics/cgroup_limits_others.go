@@ -0,0 +1,13 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+// +build !linux
+
+package metrics
+
+import "errors"
+
+const cgroupDir = ""
+
+func readCgroupUsage(_ string) (*cgroupUsage, error) {
+	return nil, errors.New("cgroups are only supported on Linux")
+}
@@ -0,0 +1,83 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCgroupLimitsMonitor_Apply(t *testing.T) {
+	// GIVEN a cgroup limited to 2 CPUs and 1GiB of memory, on a host with 8GiB
+	now := time.Now()
+	usages := []*cgroupUsage{
+		{cpuUserNs: 1e9, cpuSystemNs: 1e9, cpuLimit: 2, memoryUsed: 256 << 20, memoryLimit: 1 << 30, time: now},
+		{cpuUserNs: 11e9, cpuSystemNs: 6e9, cpuLimit: 2, memoryUsed: 768 << 20, memoryLimit: 1 << 30, time: now.Add(10 * time.Second)},
+	}
+	m := &CgroupLimitsMonitor{usage: func(string) (*cgroupUsage, error) {
+		usage := usages[0]
+		usages = usages[1:]
+		return usage, nil
+	}}
+	hostCPU := func() *CPUSample {
+		return &CPUSample{CPUPercent: 12, CPUUserPercent: 8, CPUSystemPercent: 2, CPUIOWaitPercent: 1, CPUStealPercent: 1, CPUIdlePercent: 88}
+	}
+	hostMemory := func() *MemorySample {
+		return &MemorySample{MemoryTotal: 8 << 30, MemoryUsed: 4 << 30, MemoryFree: 4 << 30, MemoryUsedPercent: 50, MemoryFreePercent: 50}
+	}
+
+	// WHEN the limits are applied for the first time
+	cpu, memory := hostCPU(), hostMemory()
+	require.NoError(t, m.Apply(cpu, memory))
+
+	// THEN the memory utilization is relative to the cgroup limit
+	assert.Equal(t, float64(1<<30), memory.MemoryTotal)
+	assert.Equal(t, float64(256<<20), memory.MemoryUsed)
+	assert.Equal(t, float64(768<<20), memory.MemoryFree)
+	assert.Equal(t, 25.0, memory.MemoryUsedPercent)
+	assert.Equal(t, 75.0, memory.MemoryFreePercent)
+	// AND the CPU utilization is kept until there is a previous CPU time
+	assert.Equal(t, hostCPU(), cpu)
+
+	// WHEN the limits are applied again
+	cpu, memory = hostCPU(), hostMemory()
+	require.NoError(t, m.Apply(cpu, memory))
+
+	// THEN the CPU utilization is relative to the CPU quota
+	assert.InDelta(t, 50.0, cpu.CPUUserPercent, 0.001)
+	assert.InDelta(t, 25.0, cpu.CPUSystemPercent, 0.001)
+	assert.InDelta(t, 75.0, cpu.CPUPercent, 0.001)
+	assert.InDelta(t, 25.0, cpu.CPUIdlePercent, 0.001)
+	assert.Zero(t, cpu.CPUIOWaitPercent)
+	assert.Zero(t, cpu.CPUStealPercent)
+	assert.Equal(t, 75.0, memory.MemoryUsedPercent)
+}
+
+func TestCgroupLimitsMonitor_Apply_Unlimited(t *testing.T) {
+	// GIVEN a cgroup without CPU quota and a memory limit larger than the host memory
+	now := time.Now()
+	usages := []*cgroupUsage{
+		{cpuUserNs: 1e9, memoryUsed: 1 << 30, memoryLimit: 1 << 62, time: now},
+		{cpuUserNs: 5e9, memoryUsed: 1 << 30, memoryLimit: 1 << 62, time: now.Add(time.Second)},
+	}
+	m := &CgroupLimitsMonitor{usage: func(string) (*cgroupUsage, error) {
+		usage := usages[0]
+		usages = usages[1:]
+		return usage, nil
+	}}
+
+	// WHEN the limits are applied
+	for range usages {
+		cpu := &CPUSample{CPUPercent: 12, CPUIdlePercent: 88}
+		memory := &MemorySample{MemoryTotal: 8 << 30, MemoryUsedPercent: 50}
+		require.NoError(t, m.Apply(cpu, memory))
+
+		// THEN the host utilization is kept
+		assert.Equal(t, 12.0, cpu.CPUPercent)
+		assert.Equal(t, float64(8<<30), memory.MemoryTotal)
+		assert.Equal(t, 50.0, memory.MemoryUsedPercent)
+	}
+}
@@ -0,0 +1,103 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package connection
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"unsafe"
+
+	"github.com/newrelic/infrastructure-agent/pkg/helpers"
+)
+
+// hostByteOrder is the byte order of the 32 bits words of the addresses in the /proc/net/tcp files.
+var hostByteOrder = func() binary.ByteOrder {
+	word := uint16(1)
+	if *(*byte)(unsafe.Pointer(&word)) == 1 {
+		return binary.LittleEndian
+	}
+	return binary.BigEndian
+}()
+
+// readSockets reads the IPv4 and IPv6 TCP sockets of the network namespace of the init process, which
+// is the one of the host even when the agent runs in a container sharing the host PID namespace.
+func readSockets() ([]socket, error) {
+	sockets, err := readSocketsFrom(helpers.HostProc("1", "net", "tcp"))
+	if err != nil {
+		return nil, err
+	}
+	// hosts with IPv6 disabled don't have the file
+	if sockets6, err := readSocketsFrom(helpers.HostProc("1", "net", "tcp6")); err == nil {
+		sockets = append(sockets, sockets6...)
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+	return sockets, nil
+}
+
+// readSocketsFrom parses a /proc/net/tcp or /proc/net/tcp6 file, e.g.:
+//
+//	sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode
+//	 0: 0100007F:0CEA 00000000:0000 0A 00000000:00000000 00:00000000 00000000   107        0 23145 ...
+func readSocketsFrom(path string) ([]socket, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var sockets []socket
+	scanner := bufio.NewScanner(file)
+	// skip the header
+	scanner.Scan()
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 5 {
+			continue
+		}
+		ip, port, err := parseAddress(fields[1])
+		if err != nil {
+			slog.WithError(err).Debug("Skipping TCP socket.")
+			continue
+		}
+		state, err := strconv.ParseUint(fields[3], 16, 8)
+		if err != nil {
+			continue
+		}
+		s := socket{localIP: ip, localPort: port, state: uint8(state)}
+		// for the listening sockets, rx_queue is the length of the accept queue
+		if queues := strings.SplitN(fields[4], ":", 2); state == stateListen && len(queues) == 2 {
+			s.acceptQueue, _ = strconv.ParseUint(queues[1], 16, 64)
+		}
+		sockets = append(sockets, s)
+	}
+	return sockets, scanner.Err()
+}
+
+// parseAddress parses an address of the /proc/net/tcp files, formatted as the hexadecimal IP address,
+// in 32 bits words of the host byte order, and port.
+func parseAddress(address string) (net.IP, uint16, error) {
+	parts := strings.SplitN(address, ":", 2)
+	if len(parts) != 2 {
+		return nil, 0, fmt.Errorf("invalid address %q", address)
+	}
+	raw, err := hex.DecodeString(parts[0])
+	if err != nil || (len(raw) != net.IPv4len && len(raw) != net.IPv6len) {
+		return nil, 0, fmt.Errorf("invalid IP address %q", address)
+	}
+	port, err := strconv.ParseUint(parts[1], 16, 16)
+	if err != nil {
+		return nil, 0, fmt.Errorf("invalid port %q", address)
+	}
+	ip := make(net.IP, len(raw))
+	for i := 0; i < len(raw); i += 4 {
+		binary.BigEndian.PutUint32(ip[i:], hostByteOrder.Uint32(raw[i:]))
+	}
+	return ip, uint16(port), nil
+}
@@ -0,0 +1,62 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package connection
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadSocketsFrom(t *testing.T) {
+	dir, err := ioutil.TempDir("", "net")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	// GIVEN a listening socket with a connection waiting to be accepted, and an IPv6 established connection
+	tcp := filepath.Join(dir, "tcp")
+	require.NoError(t, ioutil.WriteFile(tcp, []byte(
+		`  sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode
+   0: 0100007F:0CEA 00000000:0000 0A 00000000:00000002 00:00000000 00000000   107        0 23145 1 0000000000000000 100 0 0 10 0
+   1: 0F02000A:0016 0102000A:D3A4 01 00000000:00000000 02:0009F0A5 00000000     0        0 31512 4 0000000000000000 20 4 30 10 -1
+`), 0644))
+	tcp6 := filepath.Join(dir, "tcp6")
+	require.NoError(t, ioutil.WriteFile(tcp6, []byte(
+		`  sl  local_address                         remote_address                        st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode
+   0: 0000000000000000FFFF00000F02000A:0050 0000000000000000FFFF00000102000A:C350 08 00000000:00000000 00:00000000 00000000    33        0 40210 1 0000000000000000 20 4 0 10 -1
+`), 0644))
+
+	// WHEN the files are read
+	sockets, err := readSocketsFrom(tcp)
+	require.NoError(t, err)
+	sockets6, err := readSocketsFrom(tcp6)
+	require.NoError(t, err)
+
+	// THEN the addresses, states and accept queues are parsed
+	require.Len(t, sockets, 2)
+	assert.Equal(t, "127.0.0.1", sockets[0].localIP.String())
+	assert.Equal(t, uint16(3306), sockets[0].localPort)
+	assert.Equal(t, uint8(stateListen), sockets[0].state)
+	assert.Equal(t, uint64(2), sockets[0].acceptQueue)
+	assert.Equal(t, "10.0.2.15", sockets[1].localIP.String())
+	assert.Equal(t, uint16(22), sockets[1].localPort)
+	assert.Equal(t, uint8(stateEstablished), sockets[1].state)
+	assert.Equal(t, uint64(0), sockets[1].acceptQueue)
+
+	// AND the IPv4 addresses mapped to IPv6 are reported as IPv4
+	require.Len(t, sockets6, 1)
+	assert.True(t, sockets6[0].localIP.Equal(net.ParseIP("10.0.2.15")))
+	assert.Equal(t, uint16(80), sockets6[0].localPort)
+	assert.Equal(t, uint8(stateCloseWait), sockets6[0].state)
+}
+
+func TestParseAddress_Invalid(t *testing.T) {
+	for _, address := range []string{"", "0100007F", "0100007:0016", "0100007F:XYZ", "01000000007F:0016"} {
+		_, _, err := parseAddress(address)
+		assert.Error(t, err, address)
+	}
+}
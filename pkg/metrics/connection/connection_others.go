@@ -0,0 +1,12 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+// +build !linux
+
+package connection
+
+import "errors"
+
+// readSockets is only supported on Linux.
+func readSockets() ([]socket, error) {
+	return nil, errors.New("TCP connections are only sampled on Linux")
+}
@@ -0,0 +1,223 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+// Package connection samples the TCP connections of the host, summarized by state and by listening port,
+// so connection leaks and full accept queues are visible.
+package connection
+
+import (
+	"fmt"
+	"net"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/newrelic/infrastructure-agent/internal/agent"
+	"github.com/newrelic/infrastructure-agent/pkg/config"
+	"github.com/newrelic/infrastructure-agent/pkg/log"
+	"github.com/newrelic/infrastructure-agent/pkg/sample"
+)
+
+var slog = log.WithComponent("ConnectionSampler")
+
+// TCP states, as numbered by the Linux kernel.
+const (
+	stateEstablished = 0x01
+	stateSynSent     = 0x02
+	stateSynRecv     = 0x03
+	stateFinWait1    = 0x04
+	stateFinWait2    = 0x05
+	stateTimeWait    = 0x06
+	stateClose       = 0x07
+	stateCloseWait   = 0x08
+	stateLastAck     = 0x09
+	stateListen      = 0x0A
+	stateClosing     = 0x0B
+)
+
+// socket is a TCP socket of the host.
+type socket struct {
+	localIP   net.IP
+	localPort uint16
+	state     uint8
+	// queued connections not accepted yet, for the listening sockets
+	acceptQueue uint64
+}
+
+// Sample holds the number of TCP connections of the host by state.
+type Sample struct {
+	sample.BaseEvent
+
+	Established int `json:"establishedConnections"`
+	SynSent     int `json:"synSentConnections"`
+	SynRecv     int `json:"synRecvConnections"`
+	FinWait1    int `json:"finWait1Connections"`
+	FinWait2    int `json:"finWait2Connections"`
+	TimeWait    int `json:"timeWaitConnections"`
+	CloseWait   int `json:"closeWaitConnections"`
+	LastAck     int `json:"lastAckConnections"`
+	Closing     int `json:"closingConnections"`
+	// Connections in any state, the listening sockets excluded
+	Total     int `json:"totalConnections"`
+	Listening int `json:"listeningSockets"`
+}
+
+// ListenPortSample holds the TCP connections accepted by a listening port, in the states pointing to
+// overloaded servers (SYN_RECV and a long accept queue) or leaked connections (CLOSE_WAIT).
+type ListenPortSample struct {
+	sample.BaseEvent
+
+	Port uint16 `json:"port"`
+	// Listening addresses of the port, comma separated, "*" for all of them
+	LocalAddresses string `json:"localAddresses"`
+	Established    int    `json:"establishedConnections"`
+	SynRecv        int    `json:"synRecvConnections"`
+	TimeWait       int    `json:"timeWaitConnections"`
+	CloseWait      int    `json:"closeWaitConnections"`
+	// Connections pending to be accepted by the server
+	AcceptQueueLength uint64 `json:"acceptQueueLength"`
+}
+
+// listenAddress returns the address of a listening socket, with the IPv4 and IPv6 wildcard addresses
+// folded into "*", as dual stack sockets accept both.
+func listenAddress(ip net.IP) string {
+	if ip.IsUnspecified() {
+		return "*"
+	}
+	return ip.String()
+}
+
+// summarize counts the connections of the host by state, and those accepted by each listening port.
+// Connections are attributed to a port when their local address and port match a listening socket.
+func summarize(sockets []socket) (*Sample, []*ListenPortSample) {
+	summary := &Sample{}
+	summary.Type("NetworkConnectionSample")
+
+	ports := map[uint16]*ListenPortSample{}
+	addresses := map[uint16]map[string]bool{}
+	for _, s := range sockets {
+		if s.state != stateListen {
+			continue
+		}
+		summary.Listening++
+		port, ok := ports[s.localPort]
+		if !ok {
+			port = &ListenPortSample{Port: s.localPort}
+			port.Type("NetworkListenPortSample")
+			ports[s.localPort] = port
+			addresses[s.localPort] = map[string]bool{}
+		}
+		port.AcceptQueueLength += s.acceptQueue
+		addresses[s.localPort][listenAddress(s.localIP)] = true
+	}
+
+	for _, s := range sockets {
+		var port *ListenPortSample
+		if listening := addresses[s.localPort]; listening != nil && (listening["*"] || listening[s.localIP.String()]) {
+			port = ports[s.localPort]
+		}
+		switch s.state {
+		case stateListen, stateClose:
+			continue
+		case stateEstablished:
+			summary.Established++
+			if port != nil {
+				port.Established++
+			}
+		case stateSynSent:
+			summary.SynSent++
+		case stateSynRecv:
+			summary.SynRecv++
+			if port != nil {
+				port.SynRecv++
+			}
+		case stateFinWait1:
+			summary.FinWait1++
+		case stateFinWait2:
+			summary.FinWait2++
+		case stateTimeWait:
+			summary.TimeWait++
+			if port != nil {
+				port.TimeWait++
+			}
+		case stateCloseWait:
+			summary.CloseWait++
+			if port != nil {
+				port.CloseWait++
+			}
+		case stateLastAck:
+			summary.LastAck++
+		case stateClosing:
+			summary.Closing++
+		}
+		summary.Total++
+	}
+
+	samples := make([]*ListenPortSample, 0, len(ports))
+	for number, port := range ports {
+		var list []string
+		for address := range addresses[number] {
+			list = append(list, address)
+		}
+		sort.Strings(list)
+		port.LocalAddresses = strings.Join(list, ",")
+		samples = append(samples, port)
+	}
+	sort.Slice(samples, func(i, j int) bool { return samples[i].Port < samples[j].Port })
+	return summary, samples
+}
+
+// Sampler reports a NetworkConnectionSample with the TCP connections of the host by state, and a
+// NetworkListenPortSample per listening port.
+type Sampler struct {
+	context    agent.AgentContext
+	sampleRate time.Duration
+	sockets    func() ([]socket, error)
+}
+
+func NewSampler(context agent.AgentContext) *Sampler {
+	sampleRateSec := config.FREQ_DISABLE_SAMPLING
+	if context != nil {
+		sampleRateSec = context.Config().MetricsConnectionSampleRate
+	}
+
+	return &Sampler{
+		context:    context,
+		sampleRate: time.Second * time.Duration(sampleRateSec),
+		sockets:    readSockets,
+	}
+}
+
+func (s *Sampler) OnStartup() {}
+
+func (s *Sampler) Name() string {
+	return "ConnectionSampler"
+}
+
+func (s *Sampler) Interval() time.Duration {
+	return s.sampleRate
+}
+
+func (s *Sampler) Disabled() bool {
+	return s.Interval() <= config.FREQ_DISABLE_SAMPLING
+}
+
+func (s *Sampler) Sample() (eventBatch sample.EventBatch, err error) {
+	defer func() {
+		if panicErr := recover(); panicErr != nil {
+			err = fmt.Errorf("Panic in connection.Sampler: %v\nStack: %s", panicErr, debug.Stack())
+		}
+	}()
+
+	sockets, err := s.sockets()
+	if err != nil {
+		slog.WithError(err).Debug("Unable to read the TCP sockets.")
+		return nil, nil
+	}
+	summary, ports := summarize(sockets)
+	eventBatch = append(eventBatch, summary)
+	for _, port := range ports {
+		eventBatch = append(eventBatch, port)
+	}
+	return eventBatch, nil
+}
@@ -0,0 +1,77 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package connection
+
+import (
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSummarize(t *testing.T) {
+	// GIVEN a dual stack server on port 80, a server on a local address, and outbound connections
+	sockets := []socket{
+		{localIP: net.IPv4zero, localPort: 80, state: stateListen, acceptQueue: 3},
+		{localIP: net.IPv6unspecified, localPort: 80, state: stateListen, acceptQueue: 1},
+		{localIP: net.ParseIP("127.0.0.1"), localPort: 5432, state: stateListen},
+		{localIP: net.ParseIP("10.0.0.5"), localPort: 80, state: stateEstablished},
+		{localIP: net.ParseIP("2001:db8::5"), localPort: 80, state: stateEstablished},
+		{localIP: net.ParseIP("10.0.0.5"), localPort: 80, state: stateCloseWait},
+		{localIP: net.ParseIP("10.0.0.5"), localPort: 80, state: stateSynRecv},
+		{localIP: net.ParseIP("127.0.0.1"), localPort: 5432, state: stateTimeWait},
+		// a connection to a port listening on another address
+		{localIP: net.ParseIP("10.0.0.5"), localPort: 5432, state: stateEstablished},
+		{localIP: net.ParseIP("10.0.0.5"), localPort: 41000, state: stateSynSent},
+		{localIP: net.ParseIP("10.0.0.5"), localPort: 41001, state: stateFinWait2},
+	}
+
+	// WHEN they are summarized
+	summary, ports := summarize(sockets)
+
+	// THEN the connections are counted by state
+	assert.Equal(t, "NetworkConnectionSample", summary.EventType)
+	assert.Equal(t, 3, summary.Listening)
+	assert.Equal(t, 8, summary.Total)
+	assert.Equal(t, 3, summary.Established)
+	assert.Equal(t, 1, summary.CloseWait)
+	assert.Equal(t, 1, summary.SynRecv)
+	assert.Equal(t, 1, summary.SynSent)
+	assert.Equal(t, 1, summary.TimeWait)
+	assert.Equal(t, 1, summary.FinWait2)
+
+	// AND the connections accepted by each listening port
+	require.Len(t, ports, 2)
+	assert.Equal(t, "NetworkListenPortSample", ports[0].EventType)
+	assert.Equal(t, uint16(80), ports[0].Port)
+	assert.Equal(t, "*", ports[0].LocalAddresses)
+	assert.Equal(t, 2, ports[0].Established)
+	assert.Equal(t, 1, ports[0].CloseWait)
+	assert.Equal(t, 1, ports[0].SynRecv)
+	assert.Equal(t, uint64(4), ports[0].AcceptQueueLength)
+	assert.Equal(t, uint16(5432), ports[1].Port)
+	assert.Equal(t, "127.0.0.1", ports[1].LocalAddresses)
+	assert.Equal(t, 0, ports[1].Established)
+	assert.Equal(t, 1, ports[1].TimeWait)
+}
+
+func TestSampler_Sample(t *testing.T) {
+	s := NewSampler(nil)
+	assert.True(t, s.Disabled())
+
+	s.sockets = func() ([]socket, error) {
+		return []socket{{localIP: net.IPv4zero, localPort: 22, state: stateListen}}, nil
+	}
+	batch, err := s.Sample()
+	require.NoError(t, err)
+	require.Len(t, batch, 2)
+	assert.IsType(t, &Sample{}, batch[0])
+	assert.IsType(t, &ListenPortSample{}, batch[1])
+
+	s.sockets = func() ([]socket, error) { return nil, errors.New("failed") }
+	batch, err = s.Sample()
+	assert.NoError(t, err)
+	assert.Empty(t, batch)
+}
@@ -0,0 +1,45 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package metrics
+
+import "time"
+
+// ConntrackSample holds the usage of the Linux netfilter connection tracking table. When the table is full,
+// the kernel drops the packets of new connections, which is a common cause of outages in NAT gateways and
+// Kubernetes nodes. Attributes are only present if the nf_conntrack module is loaded.
+type ConntrackSample struct {
+	ConntrackEntries     *uint64  `json:"conntrackEntries,omitempty"`
+	ConntrackMax         *uint64  `json:"conntrackMax,omitempty"`
+	ConntrackUsedPercent *float64 `json:"conntrackUsedPercent,omitempty"`
+	// Packets dropped because the table was full, and entries that couldn't be inserted, e.g. because of
+	// races between the CPUs. Rates are reported from the second sample.
+	ConntrackDropPerSec         *float64 `json:"conntrackDropPerSecond,omitempty"`
+	ConntrackInsertFailedPerSec *float64 `json:"conntrackInsertFailedPerSecond,omitempty"`
+}
+
+// conntrackStats are the counters of the connection tracking table, summed across the CPUs.
+type conntrackStats struct {
+	drop, insertFailed uint64
+	time               time.Time
+}
+
+// ConntrackMonitor reads the connection tracking table of the host.
+type ConntrackMonitor struct {
+	procDir  string
+	previous *conntrackStats
+}
+
+func NewConntrackMonitor() *ConntrackMonitor {
+	return &ConntrackMonitor{procDir: conntrackProcDir()}
+}
+
+// rates sets the drop and insert failure rates since the previous stats, unless the counters were reset.
+func (s *ConntrackSample) rates(previous, current *conntrackStats) {
+	elapsed := current.time.Sub(previous.time).Seconds()
+	if elapsed <= 0 || current.drop < previous.drop || current.insertFailed < previous.insertFailed {
+		return
+	}
+	drop := float64(current.drop-previous.drop) / elapsed
+	insertFailed := float64(current.insertFailed-previous.insertFailed) / elapsed
+	s.ConntrackDropPerSec, s.ConntrackInsertFailedPerSec = &drop, &insertFailed
+}
@@ -0,0 +1,112 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/newrelic/infrastructure-agent/pkg/helpers"
+)
+
+func conntrackProcDir() string {
+	return helpers.HostProc()
+}
+
+// Sample returns nil if the nf_conntrack module isn't loaded.
+func (m *ConntrackMonitor) Sample() (sample *ConntrackSample, err error) {
+	defer func() {
+		if panicErr := recover(); panicErr != nil {
+			err = fmt.Errorf("Panic in ConntrackMonitor.Sample: %v\nStack: %s", panicErr, debug.Stack())
+		}
+	}()
+
+	entries, err := readUintFile(filepath.Join(m.procDir, "sys", "net", "netfilter", "nf_conntrack_count"))
+	if err != nil {
+		// the files are missing if connection tracking isn't enabled
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	sample = &ConntrackSample{ConntrackEntries: &entries}
+	if max, err := readUintFile(filepath.Join(m.procDir, "sys", "net", "netfilter", "nf_conntrack_max")); err != nil {
+		syslog.WithError(err).Debug("Unable to read the connection tracking table size.")
+	} else if max > 0 {
+		used := float64(entries) / float64(max) * 100
+		sample.ConntrackMax, sample.ConntrackUsedPercent = &max, &used
+	}
+
+	stats, err := readConntrackStats(filepath.Join(m.procDir, "net", "stat", "nf_conntrack"))
+	if err != nil {
+		if !os.IsNotExist(err) {
+			syslog.WithError(err).Debug("Unable to read the connection tracking statistics.")
+		}
+		m.previous = nil
+		return sample, nil
+	}
+	stats.time = time.Now()
+	if m.previous != nil {
+		sample.rates(m.previous, stats)
+	}
+	m.previous = stats
+	return sample, nil
+}
+
+// readConntrackStats sums the per CPU counters of /proc/net/stat/nf_conntrack, whose first line names
+// the hexadecimal columns of the following ones, e.g.
+// entries  clashres found new invalid ignore delete chainlength insert insert_failed drop early_drop ...
+// 000000a8  00000000 00000000 00000000 0000002b 00000c4e 00000000 00000000 00000000 00000000 00000000 ...
+// The columns differ between kernel versions, so they are looked up by name.
+func readConntrackStats(path string) (*conntrackStats, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	if !scanner.Scan() {
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("empty file %s", path)
+	}
+	dropColumn, insertFailedColumn := -1, -1
+	for i, name := range strings.Fields(scanner.Text()) {
+		switch name {
+		case "drop":
+			dropColumn = i
+		case "insert_failed":
+			insertFailedColumn = i
+		}
+	}
+	if dropColumn < 0 || insertFailedColumn < 0 {
+		return nil, fmt.Errorf("missing drop or insert_failed columns in %s", path)
+	}
+
+	stats := &conntrackStats{}
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) <= dropColumn || len(fields) <= insertFailedColumn {
+			continue
+		}
+		drop, err := strconv.ParseUint(fields[dropColumn], 16, 64)
+		if err != nil {
+			return nil, err
+		}
+		insertFailed, err := strconv.ParseUint(fields[insertFailedColumn], 16, 64)
+		if err != nil {
+			return nil, err
+		}
+		stats.drop += drop
+		stats.insertFailed += insertFailed
+	}
+	return stats, scanner.Err()
+}
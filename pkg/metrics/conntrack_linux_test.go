@@ -0,0 +1,69 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package metrics
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeConntrackFiles(t *testing.T, dir string, count string, stats string) {
+	netfilter := filepath.Join(dir, "sys", "net", "netfilter")
+	require.NoError(t, os.MkdirAll(netfilter, 0755))
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "net", "stat"), 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(netfilter, "nf_conntrack_count"), []byte(count), 0644))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(netfilter, "nf_conntrack_max"), []byte("262144\n"), 0644))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "net", "stat", "nf_conntrack"), []byte(stats), 0644))
+}
+
+func TestConntrackMonitor_Sample(t *testing.T) {
+	// GIVEN the connection tracking files of a host with two CPUs
+	dir, err := ioutil.TempDir("", "conntrack")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	header := "entries  clashres found new invalid ignore delete chainlength insert insert_failed drop early_drop error\n"
+	writeConntrackFiles(t, dir, "65536\n", header+
+		"00010000  00000000 00000000 00000000 0000002b 00000c4e 00000000 00000000 00000000 00000001 00000002 00000000 00000000\n"+
+		"00010000  00000000 00000000 00000000 00000000 00000000 00000000 00000000 00000000 00000000 00000003 00000000 00000000\n")
+	m := &ConntrackMonitor{procDir: dir}
+
+	// WHEN they are sampled
+	sample, err := m.Sample()
+	require.NoError(t, err)
+	require.NotNil(t, sample)
+
+	// THEN the table usage is reported
+	assert.Equal(t, uint64(65536), *sample.ConntrackEntries)
+	assert.Equal(t, uint64(262144), *sample.ConntrackMax)
+	assert.Equal(t, 25.0, *sample.ConntrackUsedPercent)
+	// AND the rates aren't, until there is a previous sample
+	assert.Nil(t, sample.ConntrackDropPerSec)
+	assert.Nil(t, sample.ConntrackInsertFailedPerSec)
+
+	// WHEN the counters increase
+	m.previous.time = m.previous.time.Add(-10 * time.Second)
+	writeConntrackFiles(t, dir, "65536\n", header+
+		"00010000  00000000 00000000 00000000 0000002b 00000c4e 00000000 00000000 00000000 0000000b 00000064 00000000 00000000\n"+
+		"00010000  00000000 00000000 00000000 00000000 00000000 00000000 00000000 00000000 00000000 00000003 00000000 00000000\n")
+	sample, err = m.Sample()
+	require.NoError(t, err)
+	require.NotNil(t, sample)
+
+	// THEN their rates since the previous sample are reported
+	require.NotNil(t, sample.ConntrackDropPerSec)
+	assert.InDelta(t, 9.8, *sample.ConntrackDropPerSec, 0.01)
+	assert.InDelta(t, 1.0, *sample.ConntrackInsertFailedPerSec, 0.01)
+}
+
+func TestConntrackMonitor_Sample_Disabled(t *testing.T) {
+	sample, err := (&ConntrackMonitor{procDir: "/non/existing/proc"}).Sample()
+
+	require.NoError(t, err)
+	assert.Nil(t, sample)
+}
@@ -0,0 +1,14 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+// +build !linux
+
+package metrics
+
+func conntrackProcDir() string {
+	return ""
+}
+
+// Sample always returns nil, as the connection tracking table is only reported by Linux.
+func (m *ConntrackMonitor) Sample() (*ConntrackSample, error) {
+	return nil, nil
+}
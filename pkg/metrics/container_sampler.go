@@ -0,0 +1,61 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package metrics
+
+import (
+	metricTypes "github.com/newrelic/infrastructure-agent/pkg/metrics/types"
+)
+
+// containerSamplers decorates the processes with the first of its samplers whose runtime runs them.
+type containerSamplers []ContainerSampler
+
+// NewContainerSamplers returns a container sampler that combines the passed ones, in order of preference.
+func NewContainerSamplers(samplers ...ContainerSampler) ContainerSampler {
+	return containerSamplers(samplers)
+}
+
+func (cs containerSamplers) Enabled() bool {
+	enabled := false
+	// all the samplers are polled, as they become available independently
+	for _, sampler := range cs {
+		if sampler.Enabled() {
+			enabled = true
+		}
+	}
+	return enabled
+}
+
+// NewDecorator returns the decorators of the enabled samplers. If any of them fails, the error is returned
+// along with the decorators of the rest.
+func (cs containerSamplers) NewDecorator() (ProcessDecorator, error) {
+	var decorators processDecorators
+	var firstErr error
+	for _, sampler := range cs {
+		if !sampler.Enabled() {
+			continue
+		}
+		decorator, err := sampler.NewDecorator()
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		decorators = append(decorators, decorator)
+	}
+	if len(decorators) == 0 {
+		return nil, firstErr
+	}
+	return decorators, firstErr
+}
+
+type processDecorators []ProcessDecorator
+
+func (pd processDecorators) Decorate(process *metricTypes.ProcessSample) {
+	for _, decorator := range pd {
+		if process.Contained != "" {
+			return
+		}
+		decorator.Decorate(process)
+	}
+}
@@ -0,0 +1,59 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package metrics
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	metricTypes "github.com/newrelic/infrastructure-agent/pkg/metrics/types"
+)
+
+type fakeContainerSampler struct {
+	enabled   bool
+	err       error
+	decorated string
+}
+
+func (f *fakeContainerSampler) Enabled() bool { return f.enabled }
+
+func (f *fakeContainerSampler) NewDecorator() (ProcessDecorator, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	return f, nil
+}
+
+func (f *fakeContainerSampler) Decorate(process *metricTypes.ProcessSample) {
+	if process.ProcessID == 1 {
+		process.ContainerID = f.decorated
+		process.Contained = "true"
+	}
+}
+
+func TestContainerSamplers(t *testing.T) {
+	// GIVEN a failing sampler, a disabled one and two enabled ones
+	failing := &fakeContainerSampler{enabled: true, err: errors.New("docker failed")}
+	disabled := &fakeContainerSampler{decorated: "disabled"}
+	first := &fakeContainerSampler{enabled: true, decorated: "first"}
+	second := &fakeContainerSampler{enabled: true, decorated: "second"}
+	samplers := NewContainerSamplers(failing, disabled, first, second)
+	require.True(t, samplers.Enabled())
+
+	// WHEN the processes are decorated
+	decorator, err := samplers.NewDecorator()
+
+	// THEN the error is reported, but the rest of samplers decorate the processes
+	assert.EqualError(t, err, "docker failed")
+	require.NotNil(t, decorator)
+	process := &metricTypes.ProcessSample{ProcessID: 1}
+	decorator.Decorate(process)
+	// AND the first sampler running the process decorates it
+	assert.Equal(t, "first", process.ContainerID)
+
+	// AND nothing is enabled if none of the samplers is
+	assert.False(t, NewContainerSamplers(disabled).Enabled())
+}
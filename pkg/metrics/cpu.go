@@ -25,10 +25,15 @@ type CPUMonitor struct {
 	context  agent.AgentContext
 	last     []cpu.TimesStat
 	cpuTimes func(bool) ([]cpu.TimesStat, error)
+
+	cpuClusters func() []cpuCluster
+	// per CPU times and cluster throttling counts of the last cluster samples
+	lastPerCPU    map[string]cpu.TimesStat
+	lastThrottles map[string]uint64
 }
 
 func NewCPUMonitor(context agent.AgentContext) *CPUMonitor {
-	return &CPUMonitor{context: context, cpuTimes: cpu.Times}
+	return &CPUMonitor{context: context, cpuTimes: cpu.Times, cpuClusters: readCPUClusters}
 }
 
 func (self *CPUMonitor) Debug() bool {
@@ -45,7 +50,8 @@ func (self *CPUMonitor) Sample() (sample *CPUSample, err error) {
 		}
 	}()
 
-	if self.last == nil {
+	// retried until the CPU times are available, as they might be empty in some container envs
+	if len(self.last) == 0 {
 		self.last, err = self.cpuTimes(false)
 		return &CPUSample{}, nil
 	}
@@ -0,0 +1,177 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package metrics
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/shirou/gopsutil/cpu"
+
+	"github.com/newrelic/infrastructure-agent/pkg/sample"
+)
+
+// CPUClusterSample holds the utilization of a cluster of CPUs sharing their frequency domain, as the
+// big and LITTLE clusters of ARM64 hosts.
+type CPUClusterSample struct {
+	sample.BaseEvent
+
+	// Name of the cpufreq policy of the cluster, e.g. policy4
+	Cluster string `json:"cpuCluster"`
+	// CPUs of the cluster, as a list of ranges, e.g. 4-7
+	CPUs      string `json:"cpus"`
+	CoreCount int    `json:"coreCount"`
+	// Compute capacity of the cluster cores relative to the most performant ones of the host (1024)
+	Capacity *uint64 `json:"cpuCapacity,omitempty"`
+
+	CPUSample
+
+	CurrentFrequencyMHz *float64 `json:"currentFrequencyMHz,omitempty"`
+	MaxFrequencyMHz     *float64 `json:"maxFrequencyMHz,omitempty"`
+	// Thermal throttling events of the cluster during the sample interval
+	ThermalThrottleEvents *uint64 `json:"thermalThrottleEvents,omitempty"`
+}
+
+// cpuCluster is a group of CPUs sharing their frequency, as read from a cpufreq policy.
+type cpuCluster struct {
+	name string
+	cpus []int
+	// capacity is 0 if the kernel doesn't report it, as in x86 hosts
+	capacity   uint64
+	curFreqKHz uint64
+	maxFreqKHz uint64
+	// throttleCount is the cumulative count of thermal throttling events, nil if not reported
+	throttleCount *uint64
+}
+
+// heterogeneous returns whether the clusters have cores of different kinds, so the aggregated CPU
+// utilization of the host doesn't tell how loaded are the cores of each kind.
+func heterogeneous(clusters []cpuCluster) bool {
+	for _, c := range clusters[1:] {
+		if c.capacity != clusters[0].capacity || c.maxFreqKHz != clusters[0].maxFreqKHz {
+			return true
+		}
+	}
+	return false
+}
+
+// ClusterSamples returns a sample for each CPU cluster of hosts with heterogeneous cores, as big.LITTLE
+// ARM64 ones. Nothing is returned for homogeneous hosts nor for the first invocation, as the utilization
+// is calculated from the CPU times since the previous one.
+func (self *CPUMonitor) ClusterSamples() (samples []*CPUClusterSample, err error) {
+	if self.cpuClusters == nil {
+		return nil, nil
+	}
+	clusters := self.cpuClusters()
+	if len(clusters) < 2 || !heterogeneous(clusters) {
+		return nil, nil
+	}
+	perCPUTimes, err := self.cpuTimes(true)
+	if err != nil {
+		return nil, err
+	}
+	current := make(map[string]cpu.TimesStat, len(perCPUTimes))
+	for _, t := range perCPUTimes {
+		current[t.CPU] = t
+	}
+	lastTimes, lastThrottles := self.lastPerCPU, self.lastThrottles
+	self.lastPerCPU = current
+	self.lastThrottles = make(map[string]uint64, len(clusters))
+	for _, c := range clusters {
+		if c.throttleCount != nil {
+			self.lastThrottles[c.name] = *c.throttleCount
+		}
+	}
+	if lastTimes == nil {
+		return nil, nil
+	}
+
+	for _, c := range clusters {
+		var delta cpu.TimesStat
+		for _, id := range c.cpus {
+			name := "cpu" + strconv.Itoa(id)
+			cur, ok := current[name]
+			if !ok {
+				continue
+			}
+			// CPUs brought online since the last sample are reported from the next one
+			last, ok := lastTimes[name]
+			if !ok {
+				continue
+			}
+			addTimes(&delta, cpuDelta(&cur, &last))
+		}
+		s := &CPUClusterSample{
+			Cluster:   c.name,
+			CPUs:      formatCPUList(c.cpus),
+			CoreCount: len(c.cpus),
+		}
+		s.Type("CPUClusterSample")
+		setCPUPercents(&s.CPUSample, &delta)
+		if c.capacity > 0 {
+			capacity := c.capacity
+			s.Capacity = &capacity
+		}
+		if c.curFreqKHz > 0 {
+			mhz := float64(c.curFreqKHz) / 1000
+			s.CurrentFrequencyMHz = &mhz
+		}
+		if c.maxFreqKHz > 0 {
+			mhz := float64(c.maxFreqKHz) / 1000
+			s.MaxFrequencyMHz = &mhz
+		}
+		if last, ok := lastThrottles[c.name]; ok && c.throttleCount != nil && *c.throttleCount >= last {
+			events := *c.throttleCount - last
+			s.ThermalThrottleEvents = &events
+		}
+		samples = append(samples, s)
+	}
+	return samples, nil
+}
+
+// setCPUPercents sets the utilization percentages of a group of CPUs from the sum of their CPU times.
+func setCPUPercents(s *CPUSample, delta *cpu.TimesStat) {
+	total := delta.Total()
+	if total <= 0 {
+		s.CPUIdlePercent = 100
+		return
+	}
+	s.CPUUserPercent = (delta.User + delta.Nice) / total * 100.0
+	s.CPUSystemPercent = (delta.System + delta.Irq + delta.Softirq) / total * 100.0
+	s.CPUIOWaitPercent = delta.Iowait / total * 100.0
+	s.CPUStealPercent = delta.Steal / total * 100.0
+	s.CPUPercent = s.CPUUserPercent + s.CPUSystemPercent + s.CPUIOWaitPercent + s.CPUStealPercent
+	s.CPUIdlePercent = 100 - s.CPUPercent
+}
+
+func addTimes(sum, delta *cpu.TimesStat) {
+	sum.User += delta.User
+	sum.System += delta.System
+	sum.Idle += delta.Idle
+	sum.Nice += delta.Nice
+	sum.Iowait += delta.Iowait
+	sum.Irq += delta.Irq
+	sum.Softirq += delta.Softirq
+	sum.Steal += delta.Steal
+	sum.Guest += delta.Guest
+	sum.GuestNice += delta.GuestNice
+}
+
+// formatCPUList formats a sorted list of CPUs as the kernel does, e.g. 0-3,6.
+func formatCPUList(cpus []int) string {
+	var ranges []string
+	for i := 0; i < len(cpus); {
+		j := i
+		for j+1 < len(cpus) && cpus[j+1] == cpus[j]+1 {
+			j++
+		}
+		if i == j {
+			ranges = append(ranges, strconv.Itoa(cpus[i]))
+		} else {
+			ranges = append(ranges, fmt.Sprintf("%d-%d", cpus[i], cpus[j]))
+		}
+		i = j + 1
+	}
+	return strings.Join(ranges, ",")
+}
@@ -0,0 +1,128 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package metrics
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/newrelic/infrastructure-agent/pkg/helpers"
+)
+
+func readCPUClusters() []cpuCluster {
+	return readCPUClustersFrom(helpers.HostSys("devices", "system", "cpu"), helpers.HostSys("class", "thermal"))
+}
+
+// readCPUClustersFrom returns a cluster for each cpufreq policy in the passed CPU sysfs directory, sorted
+// by their first CPU. Hosts without cpufreq support, as most virtual machines, have no clusters.
+func readCPUClustersFrom(cpuDir, thermalDir string) []cpuCluster {
+	policies, err := filepath.Glob(filepath.Join(cpuDir, "cpufreq", "policy*"))
+	if err != nil || len(policies) == 0 {
+		return nil
+	}
+	var clusters []cpuCluster
+	for _, policy := range policies {
+		cpus := readCPUIDs(filepath.Join(policy, "related_cpus"))
+		if len(cpus) == 0 {
+			continue
+		}
+		c := cpuCluster{name: filepath.Base(policy), cpus: cpus}
+		c.curFreqKHz, _ = readUintFile(filepath.Join(policy, "scaling_cur_freq"))
+		c.maxFreqKHz, _ = readUintFile(filepath.Join(policy, "cpuinfo_max_freq"))
+		// only reported by ARM64 kernels, the same for all the CPUs of a cluster
+		c.capacity, _ = readUintFile(filepath.Join(cpuDir, "cpu"+strconv.Itoa(cpus[0]), "cpu_capacity"))
+		// x86 kernels count the throttling events of each core
+		for _, id := range cpus {
+			count, err := readUintFile(filepath.Join(cpuDir, "cpu"+strconv.Itoa(id), "thermal_throttle", "core_throttle_count"))
+			if err != nil {
+				continue
+			}
+			if c.throttleCount == nil {
+				c.throttleCount = new(uint64)
+			}
+			*c.throttleCount += count
+		}
+		clusters = append(clusters, c)
+	}
+	sort.Slice(clusters, func(i, j int) bool {
+		return clusters[i].cpus[0] < clusters[j].cpus[0]
+	})
+	addCoolingDeviceThrottles(thermalDir, clusters)
+	return clusters
+}
+
+// addCoolingDeviceThrottles counts the state changes of the cpufreq cooling devices as the throttling
+// events of their clusters, as ARM64 kernels throttle the CPUs through them. Cooling devices are named
+// cpufreq-cpu<first CPU> since Linux 5.7, and thermal-cpufreq-<policy index> before.
+func addCoolingDeviceThrottles(thermalDir string, clusters []cpuCluster) {
+	devices, err := filepath.Glob(filepath.Join(thermalDir, "cooling_device*"))
+	if err != nil {
+		return
+	}
+	for _, device := range devices {
+		kind, err := ioutil.ReadFile(filepath.Join(device, "type"))
+		if err != nil {
+			continue
+		}
+		cluster := coolingDeviceCluster(strings.TrimSpace(string(kind)), clusters)
+		if cluster == nil {
+			continue
+		}
+		// only available if the kernel has been built with CONFIG_THERMAL_STATISTICS
+		transitions, err := readUintFile(filepath.Join(device, "stats", "total_trans"))
+		if err != nil {
+			continue
+		}
+		if cluster.throttleCount == nil {
+			cluster.throttleCount = new(uint64)
+		}
+		*cluster.throttleCount += transitions
+	}
+}
+
+func coolingDeviceCluster(kind string, clusters []cpuCluster) *cpuCluster {
+	if strings.HasPrefix(kind, "cpufreq-cpu") {
+		first, err := strconv.Atoi(strings.TrimPrefix(kind, "cpufreq-cpu"))
+		if err != nil {
+			return nil
+		}
+		for i := range clusters {
+			if clusters[i].cpus[0] == first {
+				return &clusters[i]
+			}
+		}
+	} else if strings.HasPrefix(kind, "thermal-cpufreq-") {
+		index, err := strconv.Atoi(strings.TrimPrefix(kind, "thermal-cpufreq-"))
+		if err == nil && index >= 0 && index < len(clusters) {
+			return &clusters[index]
+		}
+	}
+	return nil
+}
+
+// readCPUIDs reads a space separated list of CPUs, e.g. "4 5 6 7", and returns them sorted.
+func readCPUIDs(path string) []int {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	var cpus []int
+	for _, field := range strings.Fields(string(content)) {
+		if id, err := strconv.Atoi(field); err == nil {
+			cpus = append(cpus, id)
+		}
+	}
+	sort.Ints(cpus)
+	return cpus
+}
+
+func readUintFile(path string) (uint64, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseUint(strings.TrimSpace(string(content)), 10, 64)
+}
@@ -0,0 +1,56 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package metrics
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadCPUClustersFrom(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sysfs")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	// sysfs of an ARM64 host with a LITTLE cluster of 4 CPUs and a big one of 2 CPUs
+	for path, content := range map[string]string{
+		"cpu/cpufreq/policy0/related_cpus":          "0 1 2 3",
+		"cpu/cpufreq/policy0/scaling_cur_freq":      "1416000",
+		"cpu/cpufreq/policy0/cpuinfo_max_freq":      "1416000",
+		"cpu/cpufreq/policy4/related_cpus":          "4 5",
+		"cpu/cpufreq/policy4/scaling_cur_freq":      "408000",
+		"cpu/cpufreq/policy4/cpuinfo_max_freq":      "1800000",
+		"cpu/cpu0/cpu_capacity":                     "381",
+		"cpu/cpu4/cpu_capacity":                     "1024",
+		"thermal/cooling_device0/type":              "cpufreq-cpu4",
+		"thermal/cooling_device0/stats/total_trans": "12",
+		"thermal/cooling_device1/type":              "Processor",
+	} {
+		path = filepath.Join(dir, path)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, ioutil.WriteFile(path, []byte(content+"\n"), 0644))
+	}
+
+	clusters := readCPUClustersFrom(filepath.Join(dir, "cpu"), filepath.Join(dir, "thermal"))
+
+	require.Len(t, clusters, 2)
+	assert.Equal(t, cpuCluster{name: "policy0", cpus: []int{0, 1, 2, 3}, capacity: 381, curFreqKHz: 1416000, maxFreqKHz: 1416000}, clusters[0])
+	assert.Equal(t, "policy4", clusters[1].name)
+	assert.Equal(t, []int{4, 5}, clusters[1].cpus)
+	assert.Equal(t, uint64(1024), clusters[1].capacity)
+	require.NotNil(t, clusters[1].throttleCount)
+	assert.Equal(t, uint64(12), *clusters[1].throttleCount)
+}
+
+func TestReadCPUClustersFrom_NoCpufreq(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sysfs")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	// hosts without cpufreq support, as RISC-V boards or virtual machines, don't have clusters
+	assert.Empty(t, readCPUClustersFrom(dir, dir))
+}
@@ -0,0 +1,10 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+// +build !linux
+
+package metrics
+
+// readCPUClusters is only supported on Linux.
+func readCPUClusters() []cpuCluster {
+	return nil
+}
@@ -0,0 +1,89 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package metrics
+
+import (
+	"testing"
+
+	"github.com/shirou/gopsutil/cpu"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCPUMonitor_ClusterSamples(t *testing.T) {
+	// GIVEN a big.LITTLE host with a LITTLE cluster of 2 CPUs and a big one of 2 CPUs
+	throttles := uint64(3)
+	clusters := []cpuCluster{
+		{name: "policy0", cpus: []int{0, 1}, capacity: 446, curFreqKHz: 1800000, maxFreqKHz: 1800000},
+		{name: "policy2", cpus: []int{2, 3}, capacity: 1024, curFreqKHz: 1200000, maxFreqKHz: 2800000, throttleCount: &throttles},
+	}
+	times := []cpu.TimesStat{
+		{CPU: "cpu0", User: 10, Idle: 10}, {CPU: "cpu1", User: 10, Idle: 10},
+		{CPU: "cpu2", User: 10, Idle: 10}, {CPU: "cpu3", User: 10, Idle: 10},
+	}
+	m := &CPUMonitor{
+		cpuTimes:    func(perCPU bool) ([]cpu.TimesStat, error) { return times, nil },
+		cpuClusters: func() []cpuCluster { return clusters },
+	}
+
+	// WHEN the clusters are sampled twice, with the LITTLE cores idle and the big ones busy
+	samples, err := m.ClusterSamples()
+	require.NoError(t, err)
+	assert.Empty(t, samples)
+
+	times = []cpu.TimesStat{
+		{CPU: "cpu0", User: 10, Idle: 20}, {CPU: "cpu1", User: 10, Idle: 20},
+		{CPU: "cpu2", User: 17, System: 2, Idle: 11}, {CPU: "cpu3", User: 17, System: 2, Idle: 11},
+	}
+	throttles = 5
+	samples, err = m.ClusterSamples()
+	require.NoError(t, err)
+
+	// THEN the utilization of each cluster is reported on its own
+	require.Len(t, samples, 2)
+	little, big := samples[0], samples[1]
+	assert.Equal(t, "policy0", little.Cluster)
+	assert.Equal(t, "0-1", little.CPUs)
+	assert.Equal(t, 2, little.CoreCount)
+	assert.Equal(t, uint64(446), *little.Capacity)
+	assert.Equal(t, 0.0, little.CPUPercent)
+	assert.Equal(t, 100.0, little.CPUIdlePercent)
+	assert.Equal(t, 1800.0, *little.MaxFrequencyMHz)
+	assert.Nil(t, little.ThermalThrottleEvents)
+
+	assert.Equal(t, "policy2", big.Cluster)
+	assert.InDelta(t, 70.0, big.CPUUserPercent, 0.001)
+	assert.InDelta(t, 20.0, big.CPUSystemPercent, 0.001)
+	assert.InDelta(t, 90.0, big.CPUPercent, 0.001)
+	assert.Equal(t, 1200.0, *big.CurrentFrequencyMHz)
+	assert.Equal(t, 2800.0, *big.MaxFrequencyMHz)
+	assert.Equal(t, uint64(2), *big.ThermalThrottleEvents)
+	assert.Equal(t, "CPUClusterSample", big.EventType)
+}
+
+func TestCPUMonitor_ClusterSamples_Homogeneous(t *testing.T) {
+	clusters := []cpuCluster{
+		{name: "policy0", cpus: []int{0}, maxFreqKHz: 3600000},
+		{name: "policy1", cpus: []int{1}, maxFreqKHz: 3600000},
+	}
+	m := &CPUMonitor{
+		cpuTimes: func(perCPU bool) ([]cpu.TimesStat, error) {
+			return []cpu.TimesStat{{CPU: "cpu0", User: 1}, {CPU: "cpu1", User: 1}}, nil
+		},
+		cpuClusters: func() []cpuCluster { return clusters },
+	}
+
+	for i := 0; i < 2; i++ {
+		samples, err := m.ClusterSamples()
+		require.NoError(t, err)
+		// the per CPU policies of homogeneous hosts aren't reported as clusters
+		assert.Empty(t, samples)
+	}
+}
+
+func TestFormatCPUList(t *testing.T) {
+	assert.Equal(t, "0-3", formatCPUList([]int{0, 1, 2, 3}))
+	assert.Equal(t, "0-1,4,6-7", formatCPUList([]int{0, 1, 4, 6, 7}))
+	assert.Equal(t, "5", formatCPUList([]int{5}))
+	assert.Equal(t, "", formatCPUList(nil))
+}
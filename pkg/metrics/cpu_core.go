@@ -0,0 +1,252 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package metrics
+
+import (
+	"fmt"
+	"runtime/debug"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/shirou/gopsutil/cpu"
+
+	"github.com/newrelic/infrastructure-agent/internal/agent"
+	"github.com/newrelic/infrastructure-agent/pkg/sample"
+)
+
+// CPUCoreSample holds the utilization of a single CPU core.
+type CPUCoreSample struct {
+	sample.BaseEvent
+
+	CPU int `json:"cpuId"`
+	// NUMA node of the CPU, only reported by Linux hosts
+	NUMANode *int `json:"numaNode,omitempty"`
+
+	CPUSample
+
+	CurrentFrequencyMHz *float64 `json:"currentFrequencyMHz,omitempty"`
+}
+
+// NUMANodeSample holds the utilization of the CPUs of a NUMA node.
+type NUMANodeSample struct {
+	sample.BaseEvent
+
+	NUMANode int `json:"numaNode"`
+	// CPUs of the node, as a list of ranges, e.g. 0-15,32-47
+	CPUs      string `json:"cpus"`
+	CoreCount int    `json:"coreCount"`
+
+	CPUSample
+
+	// Average of the current frequency of the node CPUs
+	CurrentFrequencyMHz *float64 `json:"currentFrequencyMHz,omitempty"`
+}
+
+// cpuTopology holds the NUMA nodes of the host and the current frequency of its CPUs.
+type cpuTopology struct {
+	// nodes maps each NUMA node to its sorted CPUs
+	nodes map[int][]int
+	// curFreqKHz has the CPUs whose frequency is reported by the kernel
+	curFreqKHz map[int]uint64
+}
+
+// CPUCoreMonitor samples the utilization of each CPU core and NUMA node, as configured by the
+// enable_cpu_core_metrics and enable_numa_node_metrics options.
+type CPUCoreMonitor struct {
+	cores bool
+	nodes bool
+	// cpus restricts the reported cores, nil to report all of them
+	cpus     map[int]bool
+	maxCores int
+	cpuTimes func(bool) ([]cpu.TimesStat, error)
+	topology func() cpuTopology
+	last     map[int]cpu.TimesStat
+}
+
+func NewCPUCoreMonitor(context agent.AgentContext) *CPUCoreMonitor {
+	m := &CPUCoreMonitor{cpuTimes: cpu.Times, topology: readCPUTopology}
+	if context == nil {
+		return m
+	}
+	cfg := context.Config()
+	m.cores = cfg.EnableCPUCoreMetrics
+	m.nodes = cfg.EnableNUMANodeMetrics
+	m.maxCores = cfg.CPUCoreMetricsMaxCores
+	if cfg.CPUCoreMetricsCPUs != "" {
+		cpus, err := parseCPUList(cfg.CPUCoreMetricsCPUs)
+		if err != nil {
+			syslog.WithError(err).Warn("Invalid cpu_core_metrics_cpus, reporting all the CPU cores.")
+			return m
+		}
+		m.cpus = make(map[int]bool, len(cpus))
+		for _, id := range cpus {
+			m.cpus[id] = true
+		}
+	}
+	return m
+}
+
+func (m *CPUCoreMonitor) Disabled() bool {
+	return !m.cores && !m.nodes
+}
+
+// Sample returns the samples of the CPU cores and NUMA nodes, if enabled. Nothing is returned for the
+// first invocation, as the utilization is calculated from the CPU times since the previous one.
+func (m *CPUCoreMonitor) Sample() (cores []*CPUCoreSample, nodes []*NUMANodeSample, err error) {
+	defer func() {
+		if panicErr := recover(); panicErr != nil {
+			err = fmt.Errorf("Panic in CPUCoreMonitor.Sample: %v\nStack: %s", panicErr, debug.Stack())
+		}
+	}()
+
+	if m.Disabled() {
+		return nil, nil, nil
+	}
+	perCPUTimes, err := m.cpuTimes(true)
+	if err != nil {
+		return nil, nil, err
+	}
+	current := make(map[int]cpu.TimesStat, len(perCPUTimes))
+	for _, t := range perCPUTimes {
+		id, err := strconv.Atoi(strings.TrimPrefix(t.CPU, "cpu"))
+		if err != nil {
+			continue
+		}
+		current[id] = t
+	}
+	last := m.last
+	m.last = current
+	if last == nil {
+		return nil, nil, nil
+	}
+
+	deltas := make(map[int]*cpu.TimesStat, len(current))
+	for id, cur := range current {
+		// CPUs brought online since the last sample are reported from the next one
+		if prev, ok := last[id]; ok {
+			deltas[id] = cpuDelta(&cur, &prev)
+		}
+	}
+	topology := m.topology()
+	if m.cores {
+		cores = m.coreSamples(deltas, topology)
+	}
+	if m.nodes {
+		nodes = nodeSamples(deltas, topology)
+	}
+	return cores, nodes, nil
+}
+
+// coreSamples returns a sample per CPU, sorted by ID and bounded to the configured maximum.
+func (m *CPUCoreMonitor) coreSamples(deltas map[int]*cpu.TimesStat, topology cpuTopology) []*CPUCoreSample {
+	ids := make([]int, 0, len(deltas))
+	for id := range deltas {
+		if m.cpus == nil || m.cpus[id] {
+			ids = append(ids, id)
+		}
+	}
+	sort.Ints(ids)
+	if m.maxCores > 0 && len(ids) > m.maxCores {
+		ids = ids[:m.maxCores]
+	}
+
+	cpuNodes := make(map[int]int)
+	for node, cpus := range topology.nodes {
+		for _, id := range cpus {
+			cpuNodes[id] = node
+		}
+	}
+	samples := make([]*CPUCoreSample, 0, len(ids))
+	for _, id := range ids {
+		s := &CPUCoreSample{CPU: id}
+		s.Type("CPUCoreSample")
+		setCPUPercents(&s.CPUSample, deltas[id])
+		if node, ok := cpuNodes[id]; ok {
+			s.NUMANode = &node
+		}
+		if khz, ok := topology.curFreqKHz[id]; ok {
+			mhz := float64(khz) / 1000
+			s.CurrentFrequencyMHz = &mhz
+		}
+		samples = append(samples, s)
+	}
+	return samples
+}
+
+// nodeSamples returns a sample per NUMA node, sorted by node. Hosts with a single node don't report
+// them, as they would duplicate the SystemSample utilization.
+func nodeSamples(deltas map[int]*cpu.TimesStat, topology cpuTopology) []*NUMANodeSample {
+	if len(topology.nodes) < 2 {
+		return nil
+	}
+	nodes := make([]int, 0, len(topology.nodes))
+	for node := range topology.nodes {
+		nodes = append(nodes, node)
+	}
+	sort.Ints(nodes)
+
+	samples := make([]*NUMANodeSample, 0, len(nodes))
+	for _, node := range nodes {
+		cpus := topology.nodes[node]
+		var delta cpu.TimesStat
+		var freqSumKHz, freqCount uint64
+		for _, id := range cpus {
+			if d, ok := deltas[id]; ok {
+				addTimes(&delta, d)
+			}
+			if khz, ok := topology.curFreqKHz[id]; ok {
+				freqSumKHz += khz
+				freqCount++
+			}
+		}
+		s := &NUMANodeSample{
+			NUMANode:  node,
+			CPUs:      formatCPUList(cpus),
+			CoreCount: len(cpus),
+		}
+		s.Type("NUMANodeSample")
+		setCPUPercents(&s.CPUSample, &delta)
+		if freqCount > 0 {
+			mhz := float64(freqSumKHz) / float64(freqCount) / 1000
+			s.CurrentFrequencyMHz = &mhz
+		}
+		samples = append(samples, s)
+	}
+	return samples
+}
+
+// maxCPUListID bounds the CPU IDs of the parsed lists, as the kernel does (CONFIG_NR_CPUS).
+const maxCPUListID = 8192
+
+// parseCPUList parses a list of CPUs in the format of the kernel CPU lists, e.g. 0-3,8,10-11, and
+// returns them sorted and without duplicates.
+func parseCPUList(list string) ([]int, error) {
+	seen := map[int]bool{}
+	for _, item := range strings.Split(strings.TrimSpace(list), ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		bounds := strings.SplitN(item, "-", 2)
+		first, err := strconv.Atoi(strings.TrimSpace(bounds[0]))
+		if err != nil || first < 0 || first >= maxCPUListID {
+			return nil, fmt.Errorf("invalid CPU %q", item)
+		}
+		last := first
+		if len(bounds) == 2 {
+			if last, err = strconv.Atoi(strings.TrimSpace(bounds[1])); err != nil || last < first || last >= maxCPUListID {
+				return nil, fmt.Errorf("invalid CPU range %q", item)
+			}
+		}
+		for id := first; id <= last; id++ {
+			seen[id] = true
+		}
+	}
+	cpus := make([]int, 0, len(seen))
+	for id := range seen {
+		cpus = append(cpus, id)
+	}
+	sort.Ints(cpus)
+	return cpus, nil
+}
@@ -0,0 +1,50 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package metrics
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/newrelic/infrastructure-agent/pkg/helpers"
+)
+
+func readCPUTopology() cpuTopology {
+	return readCPUTopologyFrom(helpers.HostSys("devices", "system", "node"), helpers.HostSys("devices", "system", "cpu"))
+}
+
+// readCPUTopologyFrom reads the CPUs of each NUMA node from the node sysfs directory, and the current
+// frequency of each CPU from the CPU sysfs one. Kernels without NUMA support don't have the nodes.
+func readCPUTopologyFrom(nodeDir, cpuDir string) cpuTopology {
+	topology := cpuTopology{nodes: map[int][]int{}, curFreqKHz: map[int]uint64{}}
+
+	nodes, _ := filepath.Glob(filepath.Join(nodeDir, "node[0-9]*"))
+	for _, path := range nodes {
+		node, err := strconv.Atoi(strings.TrimPrefix(filepath.Base(path), "node"))
+		if err != nil {
+			continue
+		}
+		content, err := ioutil.ReadFile(filepath.Join(path, "cpulist"))
+		if err != nil {
+			continue
+		}
+		// memory only nodes have no CPUs
+		if cpus, err := parseCPUList(string(content)); err == nil && len(cpus) > 0 {
+			topology.nodes[node] = cpus
+		}
+	}
+
+	cpus, _ := filepath.Glob(filepath.Join(cpuDir, "cpu[0-9]*"))
+	for _, path := range cpus {
+		id, err := strconv.Atoi(strings.TrimPrefix(filepath.Base(path), "cpu"))
+		if err != nil {
+			continue
+		}
+		if khz, err := readUintFile(filepath.Join(path, "cpufreq", "scaling_cur_freq")); err == nil && khz > 0 {
+			topology.curFreqKHz[id] = khz
+		}
+	}
+	return topology
+}
@@ -0,0 +1,39 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package metrics
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadCPUTopologyFrom(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sysfs")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	// sysfs of a host with 2 NUMA nodes, a memory only one, and cpufreq only for some CPUs
+	for path, content := range map[string]string{
+		"node/node0/cpulist":                "0-1,4",
+		"node/node1/cpulist":                "2-3",
+		"node/node2/cpulist":                "",
+		"node/possible":                     "0-2",
+		"cpu/cpu0/cpufreq/scaling_cur_freq": "2400000",
+		"cpu/cpu3/cpufreq/scaling_cur_freq": "1200000",
+		"cpu/cpu1/online":                   "1",
+		"cpu/cpufreq/policy0/related_cpus":  "0",
+	} {
+		path = filepath.Join(dir, path)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, ioutil.WriteFile(path, []byte(content+"\n"), 0644))
+	}
+
+	topology := readCPUTopologyFrom(filepath.Join(dir, "node"), filepath.Join(dir, "cpu"))
+
+	assert.Equal(t, map[int][]int{0: {0, 1, 4}, 1: {2, 3}}, topology.nodes)
+	assert.Equal(t, map[int]uint64{0: 2400000, 3: 1200000}, topology.curFreqKHz)
+}
@@ -0,0 +1,11 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+// +build !linux
+
+package metrics
+
+// readCPUTopology is only supported on Linux, so other hosts report their CPU cores without NUMA
+// node nor frequency.
+func readCPUTopology() cpuTopology {
+	return cpuTopology{}
+}
@@ -0,0 +1,124 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package metrics
+
+import (
+	"testing"
+
+	"github.com/shirou/gopsutil/cpu"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCPUCoreMonitor_Sample(t *testing.T) {
+	// GIVEN a host with 2 NUMA nodes of 2 CPUs each
+	times := []cpu.TimesStat{
+		{CPU: "cpu0", User: 10, Idle: 10}, {CPU: "cpu1", User: 10, Idle: 10},
+		{CPU: "cpu2", User: 10, Idle: 10}, {CPU: "cpu3", User: 10, Idle: 10},
+	}
+	m := &CPUCoreMonitor{
+		cores:    true,
+		nodes:    true,
+		cpuTimes: func(perCPU bool) ([]cpu.TimesStat, error) { return times, nil },
+		topology: func() cpuTopology {
+			return cpuTopology{
+				nodes:      map[int][]int{0: {0, 1}, 1: {2, 3}},
+				curFreqKHz: map[int]uint64{0: 2000000, 1: 3000000},
+			}
+		},
+	}
+
+	// WHEN the cores are sampled twice, with CPU 0 busy, CPU 1 stolen by the hypervisor and node 1 idle
+	cores, nodes, err := m.Sample()
+	require.NoError(t, err)
+	assert.Empty(t, cores)
+	assert.Empty(t, nodes)
+
+	times = []cpu.TimesStat{
+		{CPU: "cpu0", User: 18, System: 2, Idle: 10}, {CPU: "cpu1", User: 10, Steal: 5, Idle: 15},
+		{CPU: "cpu2", User: 10, Idle: 20}, {CPU: "cpu3", User: 10, Idle: 20},
+	}
+	cores, nodes, err = m.Sample()
+	require.NoError(t, err)
+
+	// THEN the utilization of each core is reported
+	require.Len(t, cores, 4)
+	assert.Equal(t, "CPUCoreSample", cores[0].EventType)
+	assert.Equal(t, 0, cores[0].CPU)
+	assert.Equal(t, 0, *cores[0].NUMANode)
+	assert.InDelta(t, 80.0, cores[0].CPUUserPercent, 0.001)
+	assert.InDelta(t, 100.0, cores[0].CPUPercent, 0.001)
+	assert.Equal(t, 2000.0, *cores[0].CurrentFrequencyMHz)
+	assert.InDelta(t, 50.0, cores[1].CPUStealPercent, 0.001)
+	assert.Equal(t, 1, *cores[2].NUMANode)
+	assert.Equal(t, 100.0, cores[2].CPUIdlePercent)
+	assert.Nil(t, cores[2].CurrentFrequencyMHz)
+
+	// AND the utilization of each NUMA node
+	require.Len(t, nodes, 2)
+	assert.Equal(t, "NUMANodeSample", nodes[0].EventType)
+	assert.Equal(t, 0, nodes[0].NUMANode)
+	assert.Equal(t, "0-1", nodes[0].CPUs)
+	assert.Equal(t, 2, nodes[0].CoreCount)
+	assert.InDelta(t, 75.0, nodes[0].CPUPercent, 0.001)
+	assert.InDelta(t, 25.0, nodes[0].CPUStealPercent, 0.001)
+	assert.Equal(t, 2500.0, *nodes[0].CurrentFrequencyMHz)
+	assert.Equal(t, 0.0, nodes[1].CPUPercent)
+	assert.Nil(t, nodes[1].CurrentFrequencyMHz)
+}
+
+func TestCPUCoreMonitor_Sample_Bounded(t *testing.T) {
+	// GIVEN a host with 8 CPUs and a single NUMA node, reporting at most 2 cores of CPUs 2 to 7
+	var times []cpu.TimesStat
+	for _, name := range []string{"cpu0", "cpu1", "cpu2", "cpu3", "cpu4", "cpu5", "cpu6", "cpu7"} {
+		times = append(times, cpu.TimesStat{CPU: name, Idle: 10})
+	}
+	m := &CPUCoreMonitor{
+		cores:    true,
+		nodes:    true,
+		cpus:     map[int]bool{2: true, 3: true, 4: true, 5: true, 6: true, 7: true},
+		maxCores: 2,
+		cpuTimes: func(perCPU bool) ([]cpu.TimesStat, error) { return times, nil },
+		topology: func() cpuTopology {
+			return cpuTopology{nodes: map[int][]int{0: {0, 1, 2, 3, 4, 5, 6, 7}}}
+		},
+	}
+
+	// WHEN the cores are sampled twice
+	_, _, err := m.Sample()
+	require.NoError(t, err)
+	cores, nodes, err := m.Sample()
+	require.NoError(t, err)
+
+	// THEN only the first 2 selected cores are reported
+	require.Len(t, cores, 2)
+	assert.Equal(t, 2, cores[0].CPU)
+	assert.Equal(t, 3, cores[1].CPU)
+	// AND no NUMA node samples are reported for a single node
+	assert.Empty(t, nodes)
+}
+
+func TestCPUCoreMonitor_Disabled(t *testing.T) {
+	m := NewCPUCoreMonitor(nil)
+	assert.True(t, m.Disabled())
+
+	cores, nodes, err := m.Sample()
+	require.NoError(t, err)
+	assert.Empty(t, cores)
+	assert.Empty(t, nodes)
+}
+
+func TestParseCPUList(t *testing.T) {
+	cpus, err := parseCPUList("8,0-3, 10-11,2\n")
+	require.NoError(t, err)
+	assert.Equal(t, []int{0, 1, 2, 3, 8, 10, 11}, cpus)
+
+	cpus, err = parseCPUList("")
+	require.NoError(t, err)
+	assert.Empty(t, cpus)
+
+	for _, invalid := range []string{"a", "3-1", "-1", "0-", "0-100000"} {
+		_, err = parseCPUList(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
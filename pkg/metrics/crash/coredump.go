@@ -0,0 +1,189 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package crash
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// coredumpMessageID identifies the journal entries written by systemd-coredump for each dumped process.
+const coredumpMessageID = "fc2e22bc6ee647b6b90729ab34a250b1"
+
+// parseCoredumpJournal parses the output of "journalctl -o json" for the systemd-coredump entries: one JSON
+// object per line, whose fields are strings. Entries out of the [since, until) range are discarded.
+func parseCoredumpJournal(output string, since, until time.Time) []*Sample {
+	var crashes []*Sample
+	for _, line := range strings.Split(output, "\n") {
+		if line = strings.TrimSpace(line); line == "" {
+			continue
+		}
+		var entry map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			clog.WithError(err).Debug("Discarding unparseable journal entry.")
+			continue
+		}
+		field := func(name string) string {
+			value, _ := entry[name].(string)
+			return value
+		}
+
+		// journal timestamps are in microseconds since the epoch
+		usec, err := strconv.ParseInt(field("__REALTIME_TIMESTAMP"), 10, 64)
+		if err != nil {
+			continue
+		}
+		crashTime := time.Unix(0, usec*int64(time.Microsecond))
+		if crashTime.Before(since) || !crashTime.Before(until) {
+			continue
+		}
+
+		crash := newSample(sourceSystemdCoredump, crashTime)
+		crash.Executable = field("COREDUMP_EXE")
+		if crash.Executable == "" {
+			crash.Executable = field("COREDUMP_COMM")
+		}
+		crash.ProcessID, _ = strconv.Atoi(field("COREDUMP_PID"))
+		if signal, err := strconv.Atoi(field("COREDUMP_SIGNAL")); err == nil {
+			crash.setSignal(signal)
+		}
+		crash.DumpPath = field("COREDUMP_FILENAME")
+		crashes = append(crashes, crash)
+	}
+	return crashes
+}
+
+// corePattern describes the files the kernel writes the core dumps to, as set in the
+// /proc/sys/kernel/core_pattern template.
+type corePattern struct {
+	dir  string
+	name *regexp.Regexp
+}
+
+// core_pattern specifiers whose values are reported, mapped to the regexp group capturing them.
+var corePatternGroups = map[byte]string{
+	'e': `(?P<exe>[^/]+?)`,
+	'p': `(?P<pid>\d+)`,
+	's': `(?P<signal>\d+)`,
+}
+
+// parseCorePattern returns the core dump files described by a core_pattern template. It returns false
+// if the dumps are not written to an absolute path: piped to a crash handler, or relative to the
+// working directory of each process.
+func parseCorePattern(pattern string) (corePattern, bool) {
+	pattern = strings.TrimSpace(pattern)
+	if !strings.HasPrefix(pattern, "/") {
+		return corePattern{}, false
+	}
+	dir, base := filepath.Split(pattern)
+	if strings.Contains(dir, "%") {
+		return corePattern{}, false
+	}
+
+	var expr strings.Builder
+	expr.WriteString("^")
+	seen := map[byte]bool{}
+	for i := 0; i < len(base); i++ {
+		if base[i] != '%' || i == len(base)-1 {
+			expr.WriteString(regexp.QuoteMeta(base[i : i+1]))
+			continue
+		}
+		i++
+		specifier := base[i]
+		switch {
+		case specifier == '%':
+			expr.WriteString("%")
+		case corePatternGroups[specifier] != "" && !seen[specifier]:
+			expr.WriteString(corePatternGroups[specifier])
+			seen[specifier] = true
+		default:
+			expr.WriteString(`.*?`)
+		}
+	}
+	// with core_uses_pid, the kernel appends the PID to the templates not containing it
+	if !seen['p'] {
+		expr.WriteString(`(?:\.(?P<pid>\d+))?`)
+	}
+	expr.WriteString("$")
+
+	name, err := regexp.Compile(expr.String())
+	if err != nil {
+		return corePattern{}, false
+	}
+	return corePattern{dir: filepath.Clean(dir), name: name}, true
+}
+
+// sample returns the crash described by a core dump file, or nil if the file does not match the pattern.
+func (c corePattern) sample(fileName string, modTime time.Time) *Sample {
+	match := c.name.FindStringSubmatch(fileName)
+	if match == nil {
+		return nil
+	}
+	crash := newSample(sourceCorePattern, modTime)
+	crash.DumpPath = filepath.Join(c.dir, fileName)
+	for i, group := range c.name.SubexpNames() {
+		switch group {
+		case "exe":
+			// the kernel replaces the slashes of the executable name with exclamation marks
+			crash.Executable = strings.ReplaceAll(match[i], "!", "/")
+		case "pid":
+			crash.ProcessID, _ = strconv.Atoi(match[i])
+		case "signal":
+			if signal, err := strconv.Atoi(match[i]); err == nil {
+				crash.setSignal(signal)
+			}
+		}
+	}
+	return crash
+}
+
+// parseApportReport parses the header fields of an apport crash report. The reading stops at the
+// core dump field, which may take several megabytes.
+func parseApportReport(report io.Reader, path string, modTime time.Time) *Sample {
+	crash := newSample(sourceApport, modTime)
+	crash.DumpPath = path
+
+	scanner := bufio.NewScanner(report)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	var key string
+	for scanner.Scan() {
+		line := scanner.Text()
+		// continuation lines of multi-line fields are indented
+		if strings.HasPrefix(line, " ") {
+			if key == "ProcStatus" {
+				if value := strings.TrimPrefix(strings.TrimSpace(line), "Pid:"); value != strings.TrimSpace(line) {
+					crash.ProcessID, _ = strconv.Atoi(strings.TrimSpace(value))
+				}
+			}
+			continue
+		}
+		var value string
+		key, value = splitField(line)
+		switch key {
+		case "CoreDump":
+			return crash
+		case "ExecutablePath":
+			crash.Executable = value
+		case "Signal":
+			if signal, err := strconv.Atoi(value); err == nil {
+				crash.setSignal(signal)
+			}
+		}
+	}
+	return crash
+}
+
+// splitField splits a "Key: value" line.
+func splitField(line string) (key, value string) {
+	parts := strings.SplitN(line, ":", 2)
+	if len(parts) < 2 {
+		return parts[0], ""
+	}
+	return parts[0], strings.TrimSpace(parts[1])
+}
@@ -0,0 +1,10 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package crash
+
+import "time"
+
+// crashesBetween is not supported on macOS.
+func crashesBetween(since, until time.Time) []*Sample {
+	return nil
+}
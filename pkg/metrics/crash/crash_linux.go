@@ -0,0 +1,95 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package crash
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/newrelic/infrastructure-agent/pkg/helpers"
+)
+
+// apportDir is the directory where apport writes its crash reports.
+var apportDir = "/var/crash"
+
+// crashesBetween returns the crashes recorded by the crash handler the kernel core_pattern points to.
+func crashesBetween(since, until time.Time) []*Sample {
+	pattern, err := ioutil.ReadFile(helpers.HostProc("/sys/kernel/core_pattern"))
+	if err != nil {
+		clog.WithError(err).Debug("Unable to read core_pattern.")
+		return nil
+	}
+
+	handler := strings.TrimSpace(string(pattern))
+	switch {
+	case strings.HasPrefix(handler, "|") && strings.Contains(handler, "systemd-coredump"):
+		return coredumpCrashes(since, until)
+	case strings.HasPrefix(handler, "|") && strings.Contains(handler, "apport"):
+		return apportCrashes(since, until)
+	}
+	if cp, ok := parseCorePattern(handler); ok {
+		return coreFileCrashes(cp, since, until)
+	}
+	clog.WithField("core_pattern", handler).Debug("Core dumps are not written to a known location.")
+	return nil
+}
+
+func coredumpCrashes(since, until time.Time) []*Sample {
+	output, err := runCommand("journalctl", "--no-pager", "-o", "json",
+		"--since", fmt.Sprintf("@%d", since.Unix()), "--until", fmt.Sprintf("@%d", until.Unix()+1),
+		"MESSAGE_ID="+coredumpMessageID)
+	if err != nil {
+		clog.WithError(err).Debug("Unable to query systemd-coredump journal entries.")
+		return nil
+	}
+	return parseCoredumpJournal(output, since, until)
+}
+
+// newFiles returns the files of a directory modified in the [since, until) range.
+func newFiles(dir string, since, until time.Time) []os.FileInfo {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		clog.WithError(err).WithField("dir", dir).Debug("Unable to list crash dumps.")
+		return nil
+	}
+	var files []os.FileInfo
+	for _, entry := range entries {
+		if entry.Mode().IsRegular() && !entry.ModTime().Before(since) && entry.ModTime().Before(until) {
+			files = append(files, entry)
+		}
+	}
+	return files
+}
+
+func coreFileCrashes(cp corePattern, since, until time.Time) []*Sample {
+	var crashes []*Sample
+	for _, file := range newFiles(cp.dir, since, until) {
+		if crash := cp.sample(file.Name(), file.ModTime()); crash != nil {
+			crashes = append(crashes, crash)
+		}
+	}
+	return crashes
+}
+
+func apportCrashes(since, until time.Time) []*Sample {
+	var crashes []*Sample
+	for _, file := range newFiles(apportDir, since, until) {
+		if filepath.Ext(file.Name()) != ".crash" {
+			continue
+		}
+		path := filepath.Join(apportDir, file.Name())
+		report, err := os.Open(path)
+		if err != nil {
+			// reports are readable only by the owner of the crashed process
+			clog.WithError(err).WithField("file", path).Debug("Unable to read apport crash report.")
+			continue
+		}
+		crashes = append(crashes, parseApportReport(report, path, file.ModTime()))
+		report.Close()
+	}
+	return crashes
+}
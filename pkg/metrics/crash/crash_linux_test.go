@@ -0,0 +1,66 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package crash
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCoreFileCrashes(t *testing.T) {
+	// GIVEN a core dumps directory with an old dump, a new dump and an unrelated file
+	dir, err := ioutil.TempDir("", "cores")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	since := time.Now().Add(-time.Minute).Truncate(time.Second)
+	for name, modTime := range map[string]time.Time{
+		"core.old.10.11":  since.Add(-time.Hour),
+		"core.nginx.20.6": since.Add(time.Second),
+		"notes.txt":       since.Add(time.Second),
+	} {
+		path := filepath.Join(dir, name)
+		require.NoError(t, ioutil.WriteFile(path, []byte("core"), 0600))
+		require.NoError(t, os.Chtimes(path, modTime, modTime))
+	}
+	cp, ok := parseCorePattern(filepath.Join(dir, "core.%e.%p.%s"))
+	require.True(t, ok)
+
+	// WHEN the crashes since the previous sample are retrieved
+	crashes := coreFileCrashes(cp, since, time.Now())
+
+	// THEN only the new dump is reported
+	require.Len(t, crashes, 1)
+	assert.Equal(t, "nginx", crashes[0].Executable)
+	assert.Equal(t, 20, crashes[0].ProcessID)
+	assert.Equal(t, "SIGABRT", crashes[0].SignalName)
+	assert.Equal(t, filepath.Join(dir, "core.nginx.20.6"), crashes[0].DumpPath)
+}
+
+func TestApportCrashes(t *testing.T) {
+	// GIVEN an apport crash report
+	dir, err := ioutil.TempDir("", "crash")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	defer func(original string) { apportDir = original }(apportDir)
+	apportDir = dir
+
+	since := time.Now().Add(-time.Minute)
+	report := "ProblemType: Crash\nExecutablePath: /usr/bin/app\nSignal: 11\n"
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "_usr_bin_app.0.crash"), []byte(report), 0600))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "_usr_bin_app.0.upload"), nil, 0600))
+
+	// WHEN the crashes since the previous sample are retrieved
+	crashes := apportCrashes(since, time.Now().Add(time.Second))
+
+	// THEN the report is reported
+	require.Len(t, crashes, 1)
+	assert.Equal(t, "/usr/bin/app", crashes[0].Executable)
+	assert.Equal(t, "SIGSEGV", crashes[0].SignalName)
+}
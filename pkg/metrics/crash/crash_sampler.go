@@ -0,0 +1,148 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+// Package crash reports the crashes of the host processes, as recorded by the operating system crash
+// handlers: systemd-coredump, the kernel core_pattern or apport on Linux and Windows Error Reporting on Windows.
+package crash
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"runtime/debug"
+	"time"
+
+	"github.com/newrelic/infrastructure-agent/internal/agent"
+	"github.com/newrelic/infrastructure-agent/pkg/config"
+	"github.com/newrelic/infrastructure-agent/pkg/log"
+	"github.com/newrelic/infrastructure-agent/pkg/sample"
+)
+
+// Crash handlers the crashes are retrieved from.
+const (
+	sourceSystemdCoredump = "systemd-coredump"
+	sourceCorePattern     = "core_pattern"
+	sourceApport          = "apport"
+	sourceWER             = "wer"
+
+	commandTimeout = 10 * time.Second
+)
+
+var (
+	clog = log.WithComponent("ProcessCrashSampler")
+
+	timeNow = time.Now
+)
+
+// runCommand executes a crash handler client command, returning its standard output.
+var runCommand = func(name string, args ...string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), commandTimeout)
+	defer cancel()
+
+	output, err := exec.CommandContext(ctx, name, args...).Output()
+	return string(output), err
+}
+
+// Sample describes a process crash. Its timestamp is the time of the crash.
+type Sample struct {
+	sample.BaseEvent
+
+	// Crash handler that recorded the crash: systemd-coredump, core_pattern, apport or wer
+	Source string `json:"source"`
+	// Path or name of the crashed executable
+	Executable string `json:"executable"`
+	// Process ID of the crashed process, if known
+	ProcessID int `json:"processId,omitempty"`
+	// Number and name of the signal that terminated the process (Linux)
+	Signal     int    `json:"signal,omitempty"`
+	SignalName string `json:"signalName,omitempty"`
+	// Code of the unhandled exception, e.g. 0xc0000005 (Windows)
+	ExceptionCode string `json:"exceptionCode,omitempty"`
+	// Module where the exception was raised (Windows)
+	FaultingModule string `json:"faultingModule,omitempty"`
+	// Location of the core or memory dump, if any was written
+	DumpPath string `json:"dumpPath,omitempty"`
+}
+
+func newSample(source string, crashTime time.Time) *Sample {
+	return &Sample{
+		BaseEvent: sample.BaseEvent{
+			EventType: "ProcessCrashSample",
+			Timestmp:  crashTime.Unix(),
+		},
+		Source: source,
+	}
+}
+
+// signalNames maps the numbers of the signals that produce a core dump to their names.
+var signalNames = map[int]string{
+	3:  "SIGQUIT",
+	4:  "SIGILL",
+	5:  "SIGTRAP",
+	6:  "SIGABRT",
+	7:  "SIGBUS",
+	8:  "SIGFPE",
+	11: "SIGSEGV",
+	24: "SIGXCPU",
+	25: "SIGXFSZ",
+	31: "SIGSYS",
+}
+
+func (s *Sample) setSignal(signal int) {
+	s.Signal = signal
+	s.SignalName = signalNames[signal]
+}
+
+// Sampler reports a ProcessCrashSample for each process crash happened since its previous execution.
+type Sampler struct {
+	context    agent.AgentContext
+	sampleRate time.Duration
+	// crashes happened before this time have already been reported
+	since time.Time
+}
+
+func NewSampler(context agent.AgentContext) *Sampler {
+	sampleRateSec := config.FREQ_DISABLE_SAMPLING
+	if context != nil {
+		sampleRateSec = context.Config().MetricsProcessCrashSampleRate
+	}
+
+	return &Sampler{
+		context:    context,
+		sampleRate: time.Second * time.Duration(sampleRateSec),
+	}
+}
+
+// OnStartup discards the crashes happened before the agent started.
+func (s *Sampler) OnStartup() {
+	s.since = timeNow()
+}
+
+func (s *Sampler) Name() string {
+	return "ProcessCrashSampler"
+}
+
+func (s *Sampler) Interval() time.Duration {
+	return s.sampleRate
+}
+
+func (s *Sampler) Disabled() bool {
+	return s.Interval() <= config.FREQ_DISABLE_SAMPLING
+}
+
+func (s *Sampler) Sample() (eventBatch sample.EventBatch, err error) {
+	defer func() {
+		if panicErr := recover(); panicErr != nil {
+			err = fmt.Errorf("Panic in crash.Sampler: %v\nStack: %s", panicErr, debug.Stack())
+		}
+	}()
+
+	until := timeNow()
+	if s.since.IsZero() {
+		s.since = until
+	}
+	for _, crash := range crashesBetween(s.since, until) {
+		eventBatch = append(eventBatch, crash)
+	}
+	s.since = until
+	return eventBatch, nil
+}
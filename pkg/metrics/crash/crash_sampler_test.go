@@ -0,0 +1,148 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package crash
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCoredumpJournal(t *testing.T) {
+	// GIVEN the systemd-coredump journal entries of two crashes, one of them before the queried range
+	output := `{"__REALTIME_TIMESTAMP":"1611923766527066","MESSAGE_ID":"fc2e22bc6ee647b6b90729ab34a250b1","COREDUMP_EXE":"/usr/bin/python3.8","COREDUMP_COMM":"python3","COREDUMP_PID":"4321","COREDUMP_SIGNAL":"11","COREDUMP_FILENAME":"/var/lib/systemd/coredump/core.python3.1000.abc.4321.1611923766000000.zst"}
+{"__REALTIME_TIMESTAMP":"1611923000000000","MESSAGE_ID":"fc2e22bc6ee647b6b90729ab34a250b1","COREDUMP_COMM":"old","COREDUMP_PID":"1","COREDUMP_SIGNAL":"6"}
+not a json line
+`
+	since := time.Unix(1611923700, 0)
+
+	// WHEN the journal output is parsed
+	crashes := parseCoredumpJournal(output, since, since.Add(time.Minute*5))
+
+	// THEN only the crash within the range is reported
+	require.Len(t, crashes, 1)
+	c := crashes[0]
+	assert.Equal(t, "ProcessCrashSample", c.EventType)
+	assert.Equal(t, int64(1611923766), c.Timestmp)
+	assert.Equal(t, sourceSystemdCoredump, c.Source)
+	assert.Equal(t, "/usr/bin/python3.8", c.Executable)
+	assert.Equal(t, 4321, c.ProcessID)
+	assert.Equal(t, 11, c.Signal)
+	assert.Equal(t, "SIGSEGV", c.SignalName)
+	assert.Equal(t, "/var/lib/systemd/coredump/core.python3.1000.abc.4321.1611923766000000.zst", c.DumpPath)
+}
+
+func TestParseCorePattern(t *testing.T) {
+	modTime := time.Unix(1611923766, 0)
+	tests := []struct {
+		pattern    string
+		file       string
+		wantDir    string
+		executable string
+		pid        int
+		signal     int
+	}{
+		{"/var/cores/core.%e.%p.%s.%t", "core.nginx.1234.6.1611923766", "/var/cores", "nginx", 1234, 6},
+		{"/tmp/core-%e-%h-%%-%p", "core-!usr!bin!app-myhost-%-99", "/tmp", "/usr/bin/app", 99, 0},
+		{"/var/cores/core", "core.4242", "/var/cores", "", 4242, 0},
+		{"/var/cores/core", "core", "/var/cores", "", 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.pattern, func(t *testing.T) {
+			cp, ok := parseCorePattern(tt.pattern + "\n")
+			require.True(t, ok)
+			assert.Equal(t, tt.wantDir, cp.dir)
+
+			c := cp.sample(tt.file, modTime)
+			require.NotNil(t, c)
+			assert.Equal(t, sourceCorePattern, c.Source)
+			assert.Equal(t, tt.wantDir+"/"+tt.file, c.DumpPath)
+			assert.Equal(t, tt.executable, c.Executable)
+			assert.Equal(t, tt.pid, c.ProcessID)
+			assert.Equal(t, tt.signal, c.Signal)
+			assert.Equal(t, modTime.Unix(), c.Timestmp)
+		})
+	}
+}
+
+func TestParseCorePattern_NotAFileLocation(t *testing.T) {
+	for _, pattern := range []string{
+		"|/usr/lib/systemd/systemd-coredump %P %u %g %s %t %c %h",
+		"core",
+		"/var/cores/%e/core",
+	} {
+		_, ok := parseCorePattern(pattern)
+		assert.False(t, ok, pattern)
+	}
+}
+
+func TestCorePattern_SampleIgnoresOtherFiles(t *testing.T) {
+	cp, ok := parseCorePattern("/var/cores/core.%e.%p")
+	require.True(t, ok)
+
+	assert.Nil(t, cp.sample("README", time.Now()))
+	assert.Nil(t, cp.sample("core.app.notapid", time.Now()))
+}
+
+func TestParseApportReport(t *testing.T) {
+	// GIVEN an apport report, holding the core dump after the fields of interest
+	report := `ProblemType: Crash
+Date: Fri Jan 29 12:36:06 2021
+ExecutablePath: /usr/bin/gedit
+ProcStatus:
+ Name:	gedit
+ State:	S (sleeping)
+ Pid:	2468
+Signal: 6
+CoreDump: base64
+ H4sICAAAAAAC/0NvcmVEdW1wAA==
+ExecutablePath: /ignored
+`
+	modTime := time.Unix(1611923766, 0)
+
+	// WHEN it is parsed
+	c := parseApportReport(strings.NewReader(report), "/var/crash/_usr_bin_gedit.1000.crash", modTime)
+
+	// THEN the crashed process is reported
+	assert.Equal(t, sourceApport, c.Source)
+	assert.Equal(t, "/usr/bin/gedit", c.Executable)
+	assert.Equal(t, 2468, c.ProcessID)
+	assert.Equal(t, 6, c.Signal)
+	assert.Equal(t, "SIGABRT", c.SignalName)
+	assert.Equal(t, "/var/crash/_usr_bin_gedit.1000.crash", c.DumpPath)
+	assert.Equal(t, modTime.Unix(), c.Timestmp)
+}
+
+func TestParseWEREvents(t *testing.T) {
+	// GIVEN two Application Error events, with named and positional data fields
+	output := `<Event xmlns='http://schemas.microsoft.com/win/2004/08/events/event'><System><Provider Name='Application Error'/><EventID Qualifiers='0'>1000</EventID><TimeCreated SystemTime='2021-01-29T12:36:06.5270664Z'/></System><EventData><Data Name='AppName'>app.exe</Data><Data Name='AppVersion'>1.0.0.0</Data><Data Name='AppTimeStamp'>5f4d3a2b</Data><Data Name='ModuleName'>ntdll.dll</Data><Data Name='ModuleVersion'>10.0.17763.1</Data><Data Name='ModuleTimeStamp'>a2b3c4d5</Data><Data Name='ExceptionCode'>c0000005</Data><Data Name='FaultingOffset'>000000000003a1b2</Data><Data Name='ProcessId'>0x1a2c</Data><Data Name='ProcessCreationTime'>0x01d6f6</Data><Data Name='AppPath'>C:\Program Files\App\app.exe</Data></EventData></Event>
+<Event xmlns='http://schemas.microsoft.com/win/2004/08/events/event'><System><Provider Name='Application Error'/><EventID Qualifiers='0'>1000</EventID><TimeCreated SystemTime='2021-01-29T12:40:00.000Z'/></System><EventData><Data>legacy.exe</Data><Data>2.0</Data><Data>0</Data><Data>legacy.exe</Data><Data>2.0</Data><Data>0</Data><Data>C0000409</Data><Data>0</Data><Data>ff</Data><Data>0</Data><Data>C:\legacy\legacy.exe</Data></EventData></Event>
+`
+
+	// WHEN they are parsed
+	crashes, err := parseWEREvents(output)
+	require.NoError(t, err)
+
+	// THEN both crashes are reported
+	require.Len(t, crashes, 2)
+	assert.Equal(t, sourceWER, crashes[0].Source)
+	assert.Equal(t, int64(1611923766), crashes[0].Timestmp)
+	assert.Equal(t, `C:\Program Files\App\app.exe`, crashes[0].Executable)
+	assert.Equal(t, "ntdll.dll", crashes[0].FaultingModule)
+	assert.Equal(t, "0xc0000005", crashes[0].ExceptionCode)
+	assert.Equal(t, 0x1a2c, crashes[0].ProcessID)
+
+	assert.Equal(t, `C:\legacy\legacy.exe`, crashes[1].Executable)
+	assert.Equal(t, "0xc0000409", crashes[1].ExceptionCode)
+	assert.Equal(t, 255, crashes[1].ProcessID)
+}
+
+func TestParseWEREvents_NoEvents(t *testing.T) {
+	crashes, err := parseWEREvents("\r\n")
+
+	require.NoError(t, err)
+	assert.Empty(t, crashes)
+}
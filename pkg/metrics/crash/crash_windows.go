@@ -0,0 +1,66 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+// +build windows
+
+package crash
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"golang.org/x/sys/windows/registry"
+)
+
+const localDumpsKey = `SOFTWARE\Microsoft\Windows\Windows Error Reporting\LocalDumps`
+
+// crashesBetween returns the crashes reported by Windows Error Reporting to the Application event log.
+func crashesBetween(since, until time.Time) []*Sample {
+	query := fmt.Sprintf("*[System[Provider[@Name='Application Error'] and (EventID=1000) and "+
+		"TimeCreated[@SystemTime>='%s' and @SystemTime<'%s']]]",
+		since.UTC().Format(time.RFC3339Nano), until.UTC().Format(time.RFC3339Nano))
+	output, err := runCommand("wevtutil", "qe", "Application", "/q:"+query, "/f:xml")
+	if err != nil {
+		clog.WithError(err).Debug("Unable to query Application Error events.")
+		return nil
+	}
+	crashes, err := parseWEREvents(output)
+	if err != nil {
+		clog.WithError(err).Debug("Unable to parse Application Error events.")
+		return nil
+	}
+
+	dumpFolder := localDumpsFolder()
+	for _, crash := range crashes {
+		if dumpFolder == "" || crash.ProcessID == 0 {
+			continue
+		}
+		// WER local dumps are named after the executable and the process ID
+		dump := filepath.Join(dumpFolder, fmt.Sprintf("%s.%d.dmp", filepath.Base(crash.Executable), crash.ProcessID))
+		if _, err := os.Stat(dump); err == nil {
+			crash.DumpPath = dump
+		}
+	}
+	return crashes
+}
+
+// localDumpsFolder returns the folder WER writes the memory dumps to, or an empty string if
+// local dumps are not enabled.
+func localDumpsFolder() string {
+	key, err := registry.OpenKey(registry.LOCAL_MACHINE, localDumpsKey, registry.QUERY_VALUE)
+	if err != nil {
+		return ""
+	}
+	defer key.Close()
+
+	folder, _, err := key.GetStringValue("DumpFolder")
+	if err != nil || folder == "" {
+		// default folder of the LocalDumps key
+		folder = `%LOCALAPPDATA%\CrashDumps`
+	}
+	if expanded, err := registry.ExpandString(folder); err == nil {
+		folder = expanded
+	}
+	return folder
+}
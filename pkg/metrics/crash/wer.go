@@ -0,0 +1,81 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package crash
+
+import (
+	"encoding/xml"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// werEvent is an "Application Error" (ID 1000) event of the Windows Application log, as
+// returned by "wevtutil qe /f:xml".
+type werEvent struct {
+	System struct {
+		TimeCreated struct {
+			SystemTime string `xml:"SystemTime,attr"`
+		} `xml:"TimeCreated"`
+	} `xml:"System"`
+	Data []struct {
+		Name  string `xml:"Name,attr"`
+		Value string `xml:",chardata"`
+	} `xml:"EventData>Data"`
+}
+
+// Positions of the fields in the event data of the Windows versions not naming them.
+var werFieldPositions = map[string]int{
+	"AppName":       0,
+	"ModuleName":    3,
+	"ExceptionCode": 6,
+	"ProcessId":     8,
+	"AppPath":       10,
+}
+
+func (e *werEvent) field(name string) string {
+	for _, data := range e.Data {
+		if data.Name == name {
+			return strings.TrimSpace(data.Value)
+		}
+	}
+	if pos, ok := werFieldPositions[name]; ok && pos < len(e.Data) && e.Data[pos].Name == "" {
+		return strings.TrimSpace(e.Data[pos].Value)
+	}
+	return ""
+}
+
+// parseWEREvents parses the output of "wevtutil qe /f:xml", a sequence of Event elements
+// with no root element.
+func parseWEREvents(output string) ([]*Sample, error) {
+	var events struct {
+		Events []werEvent `xml:"Event"`
+	}
+	if err := xml.Unmarshal([]byte("<Events>"+output+"</Events>"), &events); err != nil {
+		return nil, err
+	}
+
+	var crashes []*Sample
+	for i := range events.Events {
+		event := &events.Events[i]
+		crashTime, err := time.Parse(time.RFC3339Nano, event.System.TimeCreated.SystemTime)
+		if err != nil {
+			clog.WithError(err).Debug("Discarding Application Error event with unparseable time.")
+			continue
+		}
+		crash := newSample(sourceWER, crashTime)
+		crash.Executable = event.field("AppPath")
+		if crash.Executable == "" {
+			crash.Executable = event.field("AppName")
+		}
+		crash.FaultingModule = event.field("ModuleName")
+		if code := strings.ToLower(event.field("ExceptionCode")); code != "" {
+			crash.ExceptionCode = "0x" + strings.TrimPrefix(code, "0x")
+		}
+		// the process ID is reported in hexadecimal
+		if pid, err := strconv.ParseInt(strings.TrimPrefix(event.field("ProcessId"), "0x"), 16, 64); err == nil {
+			crash.ProcessID = int(pid)
+		}
+		crashes = append(crashes, crash)
+	}
+	return crashes, nil
+}
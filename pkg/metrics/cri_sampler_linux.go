@@ -0,0 +1,185 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package metrics
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/newrelic/infrastructure-agent/pkg/helpers"
+	metricTypes "github.com/newrelic/infrastructure-agent/pkg/metrics/types"
+)
+
+const crictlTimeout = 10 * time.Second
+
+var errNoCrictl = errors.New("crictl not found")
+
+// crictl runs the CRI client against the passed endpoint, returning its standard output.
+var crictl = func(endpoint string, args ...string) ([]byte, error) {
+	path, err := exec.LookPath("crictl")
+	if err != nil {
+		return nil, errNoCrictl
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), crictlTimeout)
+	defer cancel()
+	return exec.CommandContext(ctx, path, append([]string{"--runtime-endpoint", endpoint}, args...)...).Output()
+}
+
+// containerIDPattern matches the last element of the cgroup path of a container, as named by the
+// different runtimes and cgroup drivers, e.g. <id>, cri-containerd-<id>.scope or crio-<id>.scope.
+var containerIDPattern = regexp.MustCompile(`^(?:[a-z-]+-)?([0-9a-f]{64})(?:\.scope)?$`)
+
+// criContainer is a container as listed by "crictl ps -o json".
+type criContainer struct {
+	ID       string `json:"id"`
+	Metadata struct {
+		Name string `json:"name"`
+	} `json:"metadata"`
+	Image struct {
+		Image string `json:"image"`
+	} `json:"image"`
+	ImageRef string            `json:"imageRef"`
+	Labels   map[string]string `json:"labels"`
+}
+
+// CRISampler decorates the processes running in the containers of a CRI runtime, like containerd or
+// CRI-O, by means of crictl. Processes are mapped to their containers from their cgroups.
+type CRISampler struct {
+	endpoint    string
+	retries     int
+	available   bool
+	cacheTTL    time.Duration
+	lastList    time.Time
+	containers  map[string]*criContainer
+	cgroupsFile func(pid int32) string
+}
+
+// NewCRISampler returns a container sampler for the passed CRI endpoint, whose containers are cached
+// for the passed time.
+func NewCRISampler(cacheTTL time.Duration, endpoint string) ContainerSampler {
+	return &CRISampler{
+		endpoint: endpoint,
+		cacheTTL: cacheTTL,
+		cgroupsFile: func(pid int32) string {
+			return helpers.HostProc(strconv.Itoa(int(pid)), "cgroup")
+		},
+	}
+}
+
+// Enabled polls on the runtime socket and crictl availability.
+func (c *CRISampler) Enabled() bool {
+	if c.available {
+		return true
+	}
+	if c.endpoint == "" || c.retries > 100 {
+		return false
+	}
+	c.retries++
+
+	if socket := strings.TrimPrefix(c.endpoint, "unix://"); socket != c.endpoint {
+		if _, err := os.Stat(socket); err != nil {
+			return false
+		}
+	}
+	if _, err := exec.LookPath("crictl"); err != nil {
+		dslog.WithError(err).Debug("CRI runtime endpoint found, but not crictl.")
+		return false
+	}
+	c.available = true
+	return true
+}
+
+func (c *CRISampler) NewDecorator() (ProcessDecorator, error) {
+	if c.containers == nil || time.Since(c.lastList) >= c.cacheTTL {
+		containers, err := c.listContainers()
+		if err != nil {
+			return nil, err
+		}
+		c.containers = containers
+		c.lastList = time.Now()
+	}
+	return &criDecorator{containers: c.containers, cgroupsFile: c.cgroupsFile}, nil
+}
+
+// listContainers returns the running containers, by ID.
+func (c *CRISampler) listContainers() (map[string]*criContainer, error) {
+	out, err := crictl(c.endpoint, "ps", "-o", "json")
+	if err != nil {
+		return nil, fmt.Errorf("listing CRI containers: %s", err)
+	}
+	var list struct {
+		Containers []*criContainer `json:"containers"`
+	}
+	if err := json.Unmarshal(out, &list); err != nil {
+		return nil, fmt.Errorf("can't parse CRI containers: %s", err)
+	}
+	containers := make(map[string]*criContainer, len(list.Containers))
+	for _, container := range list.Containers {
+		containers[container.ID] = container
+	}
+	return containers, nil
+}
+
+type criDecorator struct {
+	containers  map[string]*criContainer
+	cgroupsFile func(pid int32) string
+}
+
+var _ ProcessDecorator = &criDecorator{} // compile-time assertion
+
+// Decorate adds the container information to the process, if it runs in any of the listed containers.
+func (d *criDecorator) Decorate(process *metricTypes.ProcessSample) {
+	if len(d.containers) == 0 {
+		return
+	}
+	cgroups, err := ioutil.ReadFile(d.cgroupsFile(process.ProcessID))
+	if err != nil {
+		return
+	}
+	container, ok := d.containers[containerIDFromCgroups(string(cgroups))]
+	if !ok {
+		return
+	}
+
+	// the image may be referred by its ID, and the image reference by its digest
+	imageName, imageID := container.Image.Image, container.ImageRef
+	if strings.HasPrefix(imageName, "sha256:") && container.ImageRef != "" {
+		imageName = container.ImageRef
+	}
+	imageIDComponents := strings.Split(imageID, ":")
+	process.ContainerImage = imageIDComponents[len(imageIDComponents)-1]
+	process.ContainerImageName = imageName
+	process.ContainerLabels = container.Labels
+	process.ContainerID = container.ID
+	process.ContainerName = container.Metadata.Name
+	process.Contained = "true"
+	decoratePod(process, container.Labels)
+}
+
+// containerIDFromCgroups returns the ID of the container of a process from the content of its
+// /proc/<pid>/cgroup file, or an empty string if it doesn't run in a container.
+func containerIDFromCgroups(cgroups string) string {
+	for _, line := range strings.Split(cgroups, "\n") {
+		// hierarchy-ID:controller-list:cgroup-path
+		fields := strings.SplitN(line, ":", 3)
+		if len(fields) < 3 {
+			continue
+		}
+		path := strings.Split(fields[2], "/")
+		for i := len(path) - 1; i >= 0; i-- {
+			if match := containerIDPattern.FindStringSubmatch(path[i]); match != nil {
+				return match[1]
+			}
+		}
+	}
+	return ""
+}
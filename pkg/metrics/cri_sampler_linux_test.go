@@ -0,0 +1,140 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package metrics
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	metricTypes "github.com/newrelic/infrastructure-agent/pkg/metrics/types"
+)
+
+const (
+	nginxID = "5b3a9c0d1e2f4a5b6c7d8e9f0a1b2c3d4e5f6a7b8c9d0e1f2a3b4c5d6e7f8a9b"
+	redisID = "0f1e2d3c4b5a69788796a5b4c3d2e1f00f1e2d3c4b5a69788796a5b4c3d2e1f0"
+)
+
+const criContainers = `{
+  "containers": [
+    {
+      "id": "` + nginxID + `",
+      "podSandboxId": "a1b2c3",
+      "metadata": {"name": "nginx", "attempt": 0},
+      "image": {"image": "docker.io/library/nginx:1.25"},
+      "imageRef": "docker.io/library/nginx@sha256:4c0fdaa8b6341bfdeca5f18f7837462c80cff90527ee35ef185571e1c327beac",
+      "labels": {
+        "io.kubernetes.container.name": "nginx",
+        "io.kubernetes.pod.name": "web-7f9c6",
+        "io.kubernetes.pod.namespace": "default"
+      }
+    },
+    {
+      "id": "` + redisID + `",
+      "metadata": {"name": "redis"},
+      "image": {"image": "sha256:7614ae9453d1d87e740a2056257a6de7135c84037c367e1fffa92ae922784631"},
+      "imageRef": "sha256:7614ae9453d1d87e740a2056257a6de7135c84037c367e1fffa92ae922784631"
+    }
+  ]
+}`
+
+func TestContainerIDFromCgroups(t *testing.T) {
+	cases := []struct {
+		name     string
+		cgroups  string
+		expected string
+	}{
+		{"cgroup v2, systemd driver", "0::/kubepods.slice/kubepods-burstable.slice/kubepods-burstable-pod1a2b.slice/cri-containerd-" + nginxID + ".scope\n", nginxID},
+		{"cgroup v1, cgroupfs driver", "12:pids:/kubepods/besteffort/pod1a2b/" + nginxID + "\n1:name=systemd:/kubepods/besteffort/pod1a2b/" + nginxID + "\n", nginxID},
+		{"CRI-O", "0::/kubepods.slice/kubepods-pod1a2b.slice/crio-" + redisID + ".scope\n", redisID},
+		{"docker", "0::/system.slice/docker-" + redisID + ".scope\n", redisID},
+		{"host process", "0::/system.slice/sshd.service\n", ""},
+		{"malformed", "garbage", ""},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			assert.Equal(t, c.expected, containerIDFromCgroups(c.cgroups))
+		})
+	}
+}
+
+func TestCRISampler_Decorate(t *testing.T) {
+	defer func(original func(string, ...string) ([]byte, error)) { crictl = original }(crictl)
+	dir, err := ioutil.TempDir("", "proc")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	// GIVEN a CRI runtime with two containers
+	calls := 0
+	crictl = func(endpoint string, args ...string) ([]byte, error) {
+		calls++
+		assert.Equal(t, "unix:///run/containerd/containerd.sock", endpoint)
+		assert.Equal(t, []string{"ps", "-o", "json"}, args)
+		return []byte(criContainers), nil
+	}
+	// AND a process in each container, and another in the host
+	cgroups := map[int32]string{
+		10: "0::/kubepods.slice/cri-containerd-" + nginxID + ".scope",
+		20: "0::/kubepods.slice/cri-containerd-" + redisID + ".scope",
+		30: "0::/system.slice/sshd.service",
+	}
+	for pid, content := range cgroups {
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, strconv.Itoa(int(pid))), []byte(content), 0644))
+	}
+	sampler := NewCRISampler(time.Minute, "unix:///run/containerd/containerd.sock").(*CRISampler)
+	sampler.cgroupsFile = func(pid int32) string { return filepath.Join(dir, strconv.Itoa(int(pid))) }
+
+	// WHEN the processes are decorated
+	decorator, err := sampler.NewDecorator()
+	require.NoError(t, err)
+	nginx := &metricTypes.ProcessSample{ProcessID: 10}
+	redis := &metricTypes.ProcessSample{ProcessID: 20}
+	host := &metricTypes.ProcessSample{ProcessID: 30}
+	for _, process := range []*metricTypes.ProcessSample{nginx, redis, host} {
+		decorator.Decorate(process)
+	}
+
+	// THEN the container processes carry the container and pod metadata
+	assert.Equal(t, "true", nginx.Contained)
+	assert.Equal(t, nginxID, nginx.ContainerID)
+	assert.Equal(t, "nginx", nginx.ContainerName)
+	assert.Equal(t, "docker.io/library/nginx:1.25", nginx.ContainerImageName)
+	assert.Equal(t, "4c0fdaa8b6341bfdeca5f18f7837462c80cff90527ee35ef185571e1c327beac", nginx.ContainerImage)
+	assert.Equal(t, "web-7f9c6", nginx.PodName)
+	assert.Equal(t, "default", nginx.PodNamespace)
+	assert.Equal(t, "nginx", nginx.ContainerLabels["io.kubernetes.container.name"])
+
+	assert.Equal(t, redisID, redis.ContainerID)
+	assert.True(t, strings.HasPrefix(redis.ContainerImageName, "sha256:"))
+	assert.Empty(t, redis.PodName)
+
+	// AND the host processes are not decorated
+	assert.Empty(t, host.Contained)
+	assert.Empty(t, host.ContainerID)
+
+	// AND the containers are cached
+	_, err = sampler.NewDecorator()
+	require.NoError(t, err)
+	assert.Equal(t, 1, calls)
+}
+
+func TestCRISampler_NewDecorator_Error(t *testing.T) {
+	defer func(original func(string, ...string) ([]byte, error)) { crictl = original }(crictl)
+	crictl = func(string, ...string) ([]byte, error) { return nil, errors.New("connection refused") }
+
+	_, err := NewCRISampler(time.Minute, "unix:///run/containerd/containerd.sock").NewDecorator()
+	assert.EqualError(t, err, "listing CRI containers: connection refused")
+}
+
+func TestCRISampler_Disabled(t *testing.T) {
+	assert.False(t, NewCRISampler(time.Minute, "").Enabled())
+	assert.False(t, NewCRISampler(time.Minute, "unix:///non/existing.sock").Enabled())
+}
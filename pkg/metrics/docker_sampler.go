@@ -181,9 +181,16 @@ func (d *decoratorImpl) Decorate(process *metricTypes.ProcessSample) {
 			process.ContainerName = strings.TrimPrefix(container.Names[0], "/")
 		}
 		process.Contained = "true"
+		decoratePod(process, container.Labels)
 	}
 }
 
+// decoratePod adds the Kubernetes pod of the process, from the labels set by the kubelet to the containers.
+func decoratePod(process *metricTypes.ProcessSample, labels map[string]string) {
+	process.PodName = labels["io.kubernetes.pod.name"]
+	process.PodNamespace = labels["io.kubernetes.pod.namespace"]
+}
+
 // Caching container PID samples with an LRU cache with an associated TTL
 type pidsCache struct {
 	ttl   time.Duration
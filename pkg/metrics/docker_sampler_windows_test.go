@@ -76,8 +76,8 @@ func TestDocker(t *testing.T) {
 
 // Function mocks
 
-func mockGetAllWin32Procs() ([]process.Win32_Process, error) {
-	processes := []process.Win32_Process{
+func mockGetAllWin32Procs() ([]Win32_Process, error) {
+	processes := []Win32_Process{
 		{
 			Name:      "Notepad.exe",
 			ProcessID: 1024,
@@ -0,0 +1,81 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+// Package enrich provides hooks to mutate or decorate every sample at harvest time, before it
+// is submitted, so organization-specific attributes can be added without modifying the samplers.
+package enrich
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/newrelic/infrastructure-agent/pkg/entity"
+	"github.com/newrelic/infrastructure-agent/pkg/sample"
+)
+
+// Enricher mutates or decorates the attributes of a sample.
+type Enricher interface {
+	Enrich(attributes map[string]interface{})
+}
+
+// EnricherFunc allows using a function as an Enricher.
+type EnricherFunc func(attributes map[string]interface{})
+
+// Enrich invokes the function.
+func (f EnricherFunc) Enrich(attributes map[string]interface{}) {
+	f(attributes)
+}
+
+// Chain applies a list of enrichers, in order.
+type Chain []Enricher
+
+// Apply returns the event enriched by all the enrichers in the chain. The event is returned
+// untouched if the chain is empty.
+func (c Chain) Apply(event sample.Event) (sample.Event, error) {
+	if len(c) == 0 {
+		return event, nil
+	}
+
+	attributes, err := Flatten(event)
+	if err != nil {
+		return event, err
+	}
+	for _, enricher := range c {
+		enricher.Enrich(attributes)
+	}
+	enriched := Event(attributes)
+	return &enriched, nil
+}
+
+// Flatten converts a sample into its attributes map, as it would be submitted.
+func Flatten(event sample.Event) (map[string]interface{}, error) {
+	raw, err := json.Marshal(event)
+	if err != nil {
+		return nil, fmt.Errorf("can't marshal sample: %v", err)
+	}
+	attributes := map[string]interface{}{}
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	// keeps integer values as they are
+	decoder.UseNumber()
+	if err := decoder.Decode(&attributes); err != nil {
+		return nil, fmt.Errorf("can't flatten sample: %v", err)
+	}
+	return attributes, nil
+}
+
+// Event is a sample whose attributes have been enriched.
+type Event map[string]interface{}
+
+var _ sample.Event = &Event{} // Event implements sample.Event
+
+func (e *Event) Type(eventType string) {
+	(*e)["eventType"] = eventType
+}
+
+func (e *Event) Entity(key entity.Key) {
+	(*e)["entityKey"] = key
+}
+
+func (e *Event) Timestamp(timestamp int64) {
+	(*e)["timestamp"] = timestamp
+}
@@ -0,0 +1,120 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package enrich
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/newrelic/infrastructure-agent/pkg/metrics/types"
+	"github.com/newrelic/infrastructure-agent/pkg/sample"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChain_Apply_Empty(t *testing.T) {
+	event := &types.ProcessSample{ProcessID: 123}
+
+	enriched, err := Chain{}.Apply(event)
+
+	require.NoError(t, err)
+	assert.Same(t, event, enriched)
+}
+
+func TestChain_Apply(t *testing.T) {
+	// GIVEN a chain of enrichers
+	chain := Chain{
+		EnricherFunc(func(attributes map[string]interface{}) { attributes["foo"] = "bar" }),
+		EnricherFunc(func(attributes map[string]interface{}) { delete(attributes, "commandLine") }),
+	}
+
+	// WHEN a sample is enriched
+	event := &types.ProcessSample{ProcessID: 123, CmdLine: "/bin/secret"}
+	event.Type("ProcessSample")
+	enriched, err := chain.Apply(event)
+	require.NoError(t, err)
+	enriched.Timestamp(1234)
+
+	// THEN the submitted sample contains the enriched attributes
+	raw, err := json.Marshal(enriched)
+	require.NoError(t, err)
+	var submitted map[string]interface{}
+	require.NoError(t, json.Unmarshal(raw, &submitted))
+	assert.Equal(t, "bar", submitted["foo"])
+	assert.Equal(t, "ProcessSample", submitted["eventType"])
+	assert.EqualValues(t, 123, submitted["processId"])
+	assert.EqualValues(t, 1234, submitted["timestamp"])
+	assert.NotContains(t, submitted, "commandLine")
+}
+
+func TestLoadRules(t *testing.T) {
+	dir, err := ioutil.TempDir("", "enrich")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	// GIVEN a rules file with a static attribute and a lookup from a file
+	lookupFile := filepath.Join(dir, "units.yml")
+	require.NoError(t, ioutil.WriteFile(lookupFile, []byte("root: platform\n"), 0600))
+	rulesFile := filepath.Join(dir, "rules.yml")
+	require.NoError(t, ioutil.WriteFile(rulesFile, []byte(`
+rules:
+  - attribute: datacenter
+    value: eu-west
+  - attribute: business_unit
+    event_types: [ProcessSample]
+    lookup:
+      key: userName
+      file: `+lookupFile+`
+      values:
+        www-data: web
+      default: unknown
+`), 0600))
+
+	chain, err := LoadRules(rulesFile)
+	require.NoError(t, err)
+
+	// WHEN samples are enriched
+	enrich := func(event sample.Event) map[string]interface{} {
+		enriched, err := chain.Apply(event)
+		require.NoError(t, err)
+		return *enriched.(*Event)
+	}
+	rootProcess := &types.ProcessSample{User: "root"}
+	rootProcess.Type("ProcessSample")
+	webProcess := &types.ProcessSample{User: "www-data"}
+	webProcess.Type("ProcessSample")
+	otherProcess := &types.ProcessSample{User: "nobody"}
+	otherProcess.Type("ProcessSample")
+	otherSample := &sample.BaseEvent{EventType: "SystemSample"}
+
+	// THEN the attributes are set according to the rules
+	assert.Equal(t, "platform", enrich(rootProcess)["business_unit"])
+	assert.Equal(t, "web", enrich(webProcess)["business_unit"])
+	assert.Equal(t, "unknown", enrich(otherProcess)["business_unit"])
+	assert.Equal(t, "eu-west", enrich(otherProcess)["datacenter"])
+	assert.NotContains(t, enrich(otherSample), "business_unit")
+	assert.Equal(t, "eu-west", enrich(otherSample)["datacenter"])
+}
+
+func TestLoadRules_Invalid(t *testing.T) {
+	dir, err := ioutil.TempDir("", "enrich")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	for name, content := range map[string]string{
+		"missing attribute":  "rules:\n  - value: foo\n",
+		"missing value":      "rules:\n  - attribute: foo\n",
+		"missing lookup key": "rules:\n  - attribute: foo\n    lookup:\n      default: bar\n",
+		"malformed":          "rules: {{",
+	} {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(dir, "rules.yml")
+			require.NoError(t, ioutil.WriteFile(path, []byte(content), 0600))
+			_, err := LoadRules(path)
+			assert.Error(t, err)
+		})
+	}
+}
@@ -0,0 +1,136 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package enrich
+
+import (
+	"fmt"
+	"io/ioutil"
+
+	"gopkg.in/yaml.v2"
+)
+
+// RulesFile is the format of the enrichment rules file, ie:
+//
+//	rules:
+//	  - attribute: business_unit
+//	    event_types: [ProcessSample]
+//	    lookup:
+//	      key: userName
+//	      file: /etc/newrelic-infra/business_units.yml
+//	      default: unknown
+//	  - attribute: datacenter
+//	    value: eu-west
+type RulesFile struct {
+	Rules []Rule `yaml:"rules"`
+}
+
+// Rule sets an attribute on the samples, either with a static value or looking it up from the
+// value of another sample attribute.
+type Rule struct {
+	// Attribute is the name of the attribute to set.
+	Attribute string `yaml:"attribute"`
+	// EventTypes restricts the rule to the given event types. All samples are enriched if empty.
+	EventTypes []string `yaml:"event_types"`
+	// Value is the static value of the attribute.
+	Value  string  `yaml:"value"`
+	Lookup *Lookup `yaml:"lookup"`
+
+	eventTypes map[string]bool
+}
+
+// Lookup takes the attribute value from a table, keyed by the value of another sample attribute.
+type Lookup struct {
+	// Key is the sample attribute whose value is looked up.
+	Key string `yaml:"key"`
+	// Values is the lookup table.
+	Values map[string]string `yaml:"values"`
+	// File is a YAML file with a key-value lookup table, merged with Values.
+	File string `yaml:"file"`
+	// Default is the value set when the key is not found. If empty, the attribute is not set.
+	Default string `yaml:"default"`
+}
+
+// LoadRules loads the enrichment rules from a YAML file.
+func LoadRules(path string) (Chain, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("can't read enrichment rules file: %v", err)
+	}
+
+	var file RulesFile
+	if err := yaml.Unmarshal(content, &file); err != nil {
+		return nil, fmt.Errorf("can't parse enrichment rules file %q: %v", path, err)
+	}
+
+	chain := make(Chain, 0, len(file.Rules))
+	for i := range file.Rules {
+		rule := file.Rules[i]
+		if err := rule.init(); err != nil {
+			return nil, fmt.Errorf("invalid enrichment rule #%d in %q: %v", i+1, path, err)
+		}
+		chain = append(chain, &rule)
+	}
+	return chain, nil
+}
+
+func (r *Rule) init() error {
+	if r.Attribute == "" {
+		return fmt.Errorf("missing attribute name")
+	}
+	if (r.Value == "") == (r.Lookup == nil) {
+		return fmt.Errorf("either a value or a lookup must be defined")
+	}
+
+	r.eventTypes = map[string]bool{}
+	for _, eventType := range r.EventTypes {
+		r.eventTypes[eventType] = true
+	}
+
+	if r.Lookup == nil {
+		return nil
+	}
+	if r.Lookup.Key == "" {
+		return fmt.Errorf("missing lookup key")
+	}
+	if r.Lookup.Values == nil {
+		r.Lookup.Values = map[string]string{}
+	}
+	if r.Lookup.File != "" {
+		content, err := ioutil.ReadFile(r.Lookup.File)
+		if err != nil {
+			return fmt.Errorf("can't read lookup file: %v", err)
+		}
+		values := map[string]string{}
+		if err := yaml.Unmarshal(content, &values); err != nil {
+			return fmt.Errorf("can't parse lookup file %q: %v", r.Lookup.File, err)
+		}
+		for k, v := range values {
+			r.Lookup.Values[k] = v
+		}
+	}
+	return nil
+}
+
+// Enrich sets the rule attribute on the matching samples.
+func (r *Rule) Enrich(attributes map[string]interface{}) {
+	if len(r.eventTypes) > 0 {
+		if eventType, _ := attributes["eventType"].(string); !r.eventTypes[eventType] {
+			return
+		}
+	}
+
+	if r.Lookup == nil {
+		attributes[r.Attribute] = r.Value
+		return
+	}
+
+	if key, ok := attributes[r.Lookup.Key]; ok {
+		if value, ok := r.Lookup.Values[fmt.Sprint(key)]; ok {
+			attributes[r.Attribute] = value
+			return
+		}
+	}
+	if r.Lookup.Default != "" {
+		attributes[r.Attribute] = r.Lookup.Default
+	}
+}
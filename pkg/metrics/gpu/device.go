@@ -0,0 +1,147 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package gpu
+
+import (
+	"bufio"
+	"bytes"
+	"strconv"
+	"strings"
+
+	"github.com/newrelic/infrastructure-agent/pkg/sample"
+)
+
+// GPU vendors reported by the device samples.
+const (
+	vendorNVIDIA = "nvidia"
+	vendorAMD    = "amd"
+)
+
+const mebibyte = 1024 * 1024
+
+// DeviceSample holds the utilization, memory usage, temperature and power of a NVIDIA or AMD GPU. It's
+// reported as a GPUSample, sharing the attributes of the Windows adapter samples that have the same meaning.
+type DeviceSample struct {
+	sample.BaseEvent
+
+	// UUID of NVIDIA GPUs, PCI address of AMD GPUs
+	AdapterID string `json:"gpuAdapterId"`
+	Vendor    string `json:"gpuVendor"`
+	Name      string `json:"gpuName,omitempty"`
+	Index     *int   `json:"gpuIndex,omitempty"`
+	PCIBusID  string `json:"gpuPciBusId,omitempty"`
+
+	UtilizationPercent       *float64 `json:"gpuUtilizationPercent,omitempty"`
+	MemoryUtilizationPercent *float64 `json:"gpuMemoryUtilizationPercent,omitempty"`
+	DedicatedUsedBytes       *float64 `json:"gpuDedicatedMemoryUsedBytes,omitempty"`
+	DedicatedTotalBytes      *float64 `json:"gpuDedicatedMemoryTotalBytes,omitempty"`
+	TemperatureCelsius       *float64 `json:"gpuTemperatureCelsius,omitempty"`
+	PowerWatts               *float64 `json:"gpuPowerWatts,omitempty"`
+}
+
+// ProcessSample holds the GPU memory used by a process.
+type ProcessSample struct {
+	sample.BaseEvent
+
+	AdapterID   string  `json:"gpuAdapterId"`
+	Vendor      string  `json:"gpuVendor"`
+	ProcessID   int     `json:"processId"`
+	ProcessName string  `json:"processName,omitempty"`
+	UsedBytes   float64 `json:"gpuMemoryUsedBytes"`
+}
+
+func newDeviceSample(vendor, adapterID string) *DeviceSample {
+	return &DeviceSample{
+		BaseEvent: sample.BaseEvent{EventType: "GPUSample"},
+		AdapterID: adapterID,
+		Vendor:    vendor,
+	}
+}
+
+func newProcessSample(vendor, adapterID string, pid int) *ProcessSample {
+	return &ProcessSample{
+		BaseEvent: sample.BaseEvent{EventType: "GPUProcessSample"},
+		AdapterID: adapterID,
+		Vendor:    vendor,
+		ProcessID: pid,
+	}
+}
+
+// nvidiaGPUQuery are the nvidia-smi --query-gpu fields parsed by parseNVIDIADevices, in order.
+var nvidiaGPUQuery = []string{
+	"index", "uuid", "name", "pci.bus_id", "utilization.gpu", "utilization.memory",
+	"memory.used", "memory.total", "temperature.gpu", "power.draw",
+}
+
+// nvidiaAppsQuery are the nvidia-smi --query-compute-apps fields parsed by parseNVIDIAProcesses, in order.
+var nvidiaAppsQuery = []string{"gpu_uuid", "pid", "process_name", "used_memory"}
+
+// parseNVIDIADevices parses the nvidia-smi output for the nvidiaGPUQuery, in CSV format without header
+// nor units. Values that aren't supported by the GPU (e.g. "[N/A]") are not reported.
+func parseNVIDIADevices(output []byte) []*DeviceSample {
+	var samples []*DeviceSample
+	for _, fields := range csvLines(output, len(nvidiaGPUQuery)) {
+		s := newDeviceSample(vendorNVIDIA, fields[1])
+		if index, err := strconv.Atoi(fields[0]); err == nil {
+			s.Index = &index
+		}
+		s.Name = fields[2]
+		s.PCIBusID = fields[3]
+		s.UtilizationPercent = parseValue(fields[4], 1)
+		s.MemoryUtilizationPercent = parseValue(fields[5], 1)
+		s.DedicatedUsedBytes = parseValue(fields[6], mebibyte)
+		s.DedicatedTotalBytes = parseValue(fields[7], mebibyte)
+		s.TemperatureCelsius = parseValue(fields[8], 1)
+		s.PowerWatts = parseValue(fields[9], 1)
+		samples = append(samples, s)
+	}
+	return samples
+}
+
+// parseNVIDIAProcesses parses the nvidia-smi output for the nvidiaAppsQuery, in CSV format without header
+// nor units.
+func parseNVIDIAProcesses(output []byte) []*ProcessSample {
+	var samples []*ProcessSample
+	for _, fields := range csvLines(output, len(nvidiaAppsQuery)) {
+		pid, err := strconv.Atoi(fields[1])
+		if err != nil {
+			continue
+		}
+		s := newProcessSample(vendorNVIDIA, fields[0], pid)
+		s.ProcessName = fields[2]
+		if used := parseValue(fields[3], mebibyte); used != nil {
+			s.UsedBytes = *used
+		}
+		samples = append(samples, s)
+	}
+	return samples
+}
+
+// csvLines splits the nvidia-smi CSV output into the fields of each line, skipping the lines that don't
+// have the expected number of fields. nvidia-smi doesn't quote the fields, and GPU and process names
+// don't contain commas.
+func csvLines(output []byte, fields int) [][]string {
+	var lines [][]string
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		line := strings.Split(scanner.Text(), ",")
+		if len(line) != fields {
+			continue
+		}
+		for i := range line {
+			line[i] = strings.TrimSpace(line[i])
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// parseValue returns the number multiplied by the passed factor, or nil if it's not a number.
+func parseValue(value string, factor float64) *float64 {
+	number, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return nil
+	}
+	number *= factor
+	return &number
+}
@@ -0,0 +1,252 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package gpu
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/newrelic/infrastructure-agent/pkg/helpers"
+)
+
+const (
+	amdPCIVendor     = "0x1002"
+	nvidiaSMITimeout = 10 * time.Second
+)
+
+var errNoNVIDIASMI = errors.New("nvidia-smi not found")
+
+// nvidiaSMI runs nvidia-smi, the NVML command line tool installed with the NVIDIA driver, with the passed
+// arguments. Overridden by tests.
+var nvidiaSMI = func(args ...string) ([]byte, error) {
+	path, err := exec.LookPath("nvidia-smi")
+	if err != nil {
+		return nil, errNoNVIDIASMI
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), nvidiaSMITimeout)
+	defer cancel()
+	return exec.CommandContext(ctx, path, args...).Output()
+}
+
+// readNVIDIADevices queries the NVIDIA GPUs and the compute processes using them.
+func readNVIDIADevices() ([]*DeviceSample, []*ProcessSample) {
+	output, err := nvidiaSMI("--query-gpu="+strings.Join(nvidiaGPUQuery, ","), "--format=csv,noheader,nounits")
+	if err != nil {
+		if err != errNoNVIDIASMI {
+			gslog.WithError(err).Debug("Unable to query the NVIDIA GPUs.")
+		}
+		return nil, nil
+	}
+	devices := parseNVIDIADevices(output)
+	if len(devices) == 0 {
+		return nil, nil
+	}
+
+	output, err = nvidiaSMI("--query-compute-apps="+strings.Join(nvidiaAppsQuery, ","), "--format=csv,noheader,nounits")
+	if err != nil {
+		gslog.WithError(err).Debug("Unable to query the NVIDIA GPU processes.")
+		return devices, nil
+	}
+	return devices, parseNVIDIAProcesses(output)
+}
+
+// readAMDDevices reads the utilization, memory, temperature and power of the GPUs handled by the amdgpu
+// driver, from the DRM cards in sysfs.
+func readAMDDevices(drmDir string) []*DeviceSample {
+	entries, err := ioutil.ReadDir(drmDir)
+	if err != nil {
+		gslog.WithError(err).Debug("Unable to read DRM cards.")
+		return nil
+	}
+	var samples []*DeviceSample
+	for _, entry := range entries {
+		// connectors are named as their card, e.g. card0-DP-1
+		name := entry.Name()
+		if !strings.HasPrefix(name, "card") || strings.Contains(name, "-") {
+			continue
+		}
+		deviceDir := filepath.Join(drmDir, name, "device")
+		if vendor, _ := readString(filepath.Join(deviceDir, "vendor")); vendor != amdPCIVendor {
+			continue
+		}
+		pciAddress := name
+		if resolved, err := filepath.EvalSymlinks(deviceDir); err == nil {
+			pciAddress = filepath.Base(resolved)
+		}
+
+		s := newDeviceSample(vendorAMD, pciAddress)
+		s.PCIBusID = pciAddress
+		s.Name, _ = readString(filepath.Join(deviceDir, "product_name"))
+		if index, err := strconv.Atoi(strings.TrimPrefix(name, "card")); err == nil {
+			s.Index = &index
+		}
+		s.UtilizationPercent = readValue(filepath.Join(deviceDir, "gpu_busy_percent"), 1)
+		s.MemoryUtilizationPercent = readValue(filepath.Join(deviceDir, "mem_busy_percent"), 1)
+		s.DedicatedUsedBytes = readValue(filepath.Join(deviceDir, "mem_info_vram_used"), 1)
+		s.DedicatedTotalBytes = readValue(filepath.Join(deviceDir, "mem_info_vram_total"), 1)
+
+		if hwmons, _ := filepath.Glob(filepath.Join(deviceDir, "hwmon", "hwmon*")); len(hwmons) > 0 {
+			// edge is the main temperature sensor, reported as temp1
+			s.TemperatureCelsius = readValue(filepath.Join(hwmons[0], "temp1_input"), 1e-3)
+			// power1_input replaces power1_average in recent GPUs
+			if s.PowerWatts = readValue(filepath.Join(hwmons[0], "power1_average"), 1e-6); s.PowerWatts == nil {
+				s.PowerWatts = readValue(filepath.Join(hwmons[0], "power1_input"), 1e-6)
+			}
+		}
+		samples = append(samples, s)
+	}
+	return samples
+}
+
+// amdClient is the VRAM used by a DRM client of the amdgpu driver, from the fdinfo of its file descriptor.
+type amdClient struct {
+	pciAddress string
+	id         string
+	vramBytes  float64
+}
+
+// readAMDProcesses reads the VRAM used by each process from the fdinfo of its DRM file descriptors, as
+// reported by the amdgpu driver since Linux 5.14. Each process is reported once for each GPU it uses.
+func readAMDProcesses(procDir string) []*ProcessSample {
+	entries, err := ioutil.ReadDir(procDir)
+	if err != nil {
+		return nil
+	}
+	var samples []*ProcessSample
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+		pidDir := filepath.Join(procDir, entry.Name())
+		usage := map[string]float64{}
+		seen := map[amdClient]bool{}
+		for _, client := range readAMDClients(pidDir) {
+			// file descriptors duplicated or shared between threads refer to the same client
+			key := amdClient{pciAddress: client.pciAddress, id: client.id}
+			if client.id != "" && seen[key] {
+				continue
+			}
+			seen[key] = true
+			usage[client.pciAddress] += client.vramBytes
+		}
+		if len(usage) == 0 {
+			continue
+		}
+		name, _ := readString(filepath.Join(pidDir, "comm"))
+		for pciAddress, used := range usage {
+			s := newProcessSample(vendorAMD, pciAddress, pid)
+			s.ProcessName = name
+			s.UsedBytes = used
+			samples = append(samples, s)
+		}
+	}
+	sort.Slice(samples, func(i, j int) bool {
+		if samples[i].ProcessID != samples[j].ProcessID {
+			return samples[i].ProcessID < samples[j].ProcessID
+		}
+		return samples[i].AdapterID < samples[j].AdapterID
+	})
+	return samples
+}
+
+// readAMDClients parses the fdinfo of the DRM file descriptors of a process.
+func readAMDClients(pidDir string) []amdClient {
+	fds, err := ioutil.ReadDir(filepath.Join(pidDir, "fd"))
+	if err != nil {
+		return nil
+	}
+	var clients []amdClient
+	for _, fd := range fds {
+		if target, err := os.Readlink(filepath.Join(pidDir, "fd", fd.Name())); err != nil || !strings.HasPrefix(target, "/dev/dri/") {
+			continue
+		}
+		file, err := os.Open(filepath.Join(pidDir, "fdinfo", fd.Name()))
+		if err != nil {
+			continue
+		}
+		var client amdClient
+		amdgpu := false
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			key, value := splitField(scanner.Text())
+			switch key {
+			case "drm-driver":
+				amdgpu = value == "amdgpu"
+			case "drm-pdev", "pdev":
+				client.pciAddress = value
+			case "drm-client-id":
+				client.id = value
+			case "drm-memory-vram", "vram mem":
+				client.vramBytes = parseKiB(value)
+				amdgpu = amdgpu || key == "vram mem"
+			}
+		}
+		_ = file.Close()
+		if amdgpu && client.pciAddress != "" {
+			clients = append(clients, client)
+		}
+	}
+	return clients
+}
+
+func splitField(line string) (string, string) {
+	parts := strings.SplitN(line, ":", 2)
+	if len(parts) != 2 {
+		return "", ""
+	}
+	return strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1])
+}
+
+// parseKiB parses a memory amount as "<n> KiB" or "<n> kB" into bytes.
+func parseKiB(value string) float64 {
+	fields := strings.Fields(value)
+	if len(fields) == 0 {
+		return 0
+	}
+	n, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return 0
+	}
+	if len(fields) > 1 && fields[1] == "MiB" {
+		return n * mebibyte
+	}
+	return n * 1024
+}
+
+func readString(path string) (string, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(content)), nil
+}
+
+// readValue returns the number stored in the file multiplied by the passed factor, or nil if it can't
+// be read.
+func readValue(path string, factor float64) *float64 {
+	value, err := readString(path)
+	if err != nil {
+		return nil
+	}
+	return parseValue(value, factor)
+}
+
+// readDevices returns the samples of the NVIDIA and AMD GPUs of the host and the processes using them.
+func readDevices() ([]*DeviceSample, []*ProcessSample) {
+	devices, processes := readNVIDIADevices()
+	if amd := readAMDDevices(helpers.HostSys("class", "drm")); len(amd) > 0 {
+		devices = append(devices, amd...)
+		processes = append(processes, readAMDProcesses(helpers.HostProc())...)
+	}
+	return devices, processes
+}
@@ -0,0 +1,159 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package gpu
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeFiles creates the files of a fake sysfs or procfs directory.
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	for path, content := range files {
+		path = filepath.Join(dir, path)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, ioutil.WriteFile(path, []byte(content+"\n"), 0644))
+	}
+}
+
+func symlink(t *testing.T, target, path string) {
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+	require.NoError(t, os.Symlink(target, path))
+}
+
+func TestReadAMDDevices(t *testing.T) {
+	dir, err := ioutil.TempDir("", "drm")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	// GIVEN an AMD GPU, an Intel GPU and a display connector
+	device := filepath.Join(dir, "devices", "0000:03:00.0")
+	writeFiles(t, device, map[string]string{
+		"vendor":                    "0x1002",
+		"product_name":              "AMD Instinct MI210",
+		"gpu_busy_percent":          "73",
+		"mem_busy_percent":          "12",
+		"mem_info_vram_used":        "1073741824",
+		"mem_info_vram_total":       "68702699520",
+		"hwmon/hwmon4/temp1_input":  "54000",
+		"hwmon/hwmon4/power1_input": "181000000",
+		"hwmon/hwmon4/temp2_input":  "60000",
+	})
+	symlink(t, device, filepath.Join(dir, "drm", "card1", "device"))
+	writeFiles(t, dir, map[string]string{
+		"drm/card0/device/vendor":      "0x8086",
+		"drm/card1-DP-1/device/vendor": "0x1002",
+	})
+
+	// WHEN the devices are read
+	samples := readAMDDevices(filepath.Join(dir, "drm"))
+
+	// THEN only the AMD GPU is reported
+	require.Len(t, samples, 1)
+	s := samples[0]
+	assert.Equal(t, "GPUSample", s.EventType)
+	assert.Equal(t, vendorAMD, s.Vendor)
+	assert.Equal(t, "0000:03:00.0", s.AdapterID)
+	assert.Equal(t, "AMD Instinct MI210", s.Name)
+	assert.Equal(t, 1, *s.Index)
+	assert.Equal(t, 73.0, *s.UtilizationPercent)
+	assert.Equal(t, 12.0, *s.MemoryUtilizationPercent)
+	assert.Equal(t, 1073741824.0, *s.DedicatedUsedBytes)
+	assert.Equal(t, 68702699520.0, *s.DedicatedTotalBytes)
+	assert.Equal(t, 54.0, *s.TemperatureCelsius)
+	assert.Equal(t, 181.0, *s.PowerWatts)
+}
+
+func TestReadAMDProcesses(t *testing.T) {
+	dir, err := ioutil.TempDir("", "proc")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	// GIVEN a process with two file descriptors of the same DRM client, and another of a different one
+	client := func(id, vram string) string {
+		return strings.Join([]string{"pos:\t0", "drm-driver:\tamdgpu", "drm-pdev:\t0000:03:00.0", "drm-client-id:\t" + id, "drm-memory-vram:\t" + vram}, "\n")
+	}
+	writeFiles(t, dir, map[string]string{
+		"100/comm":     "pytorch",
+		"100/fdinfo/5": client("7", "1048576 KiB"),
+		"100/fdinfo/6": client("7", "1048576 KiB"),
+		"100/fdinfo/7": client("8", "1024 KiB"),
+		"100/fdinfo/1": "pos:\t0",
+		// AND a process using a GPU with an older kernel
+		"200/comm":     "Xorg",
+		"200/fdinfo/3": "pos:\t0\npdev:\t0000:03:00.0\nvram mem:\t2048 kB",
+		// AND a process using an Intel GPU
+		"300/comm":     "gnome-shell",
+		"300/fdinfo/3": "pos:\t0\ndrm-driver:\ti915\ndrm-pdev:\t0000:00:02.0",
+	})
+	for _, fd := range []string{"100/fd/5", "100/fd/6", "100/fd/7", "200/fd/3", "300/fd/3"} {
+		symlink(t, "/dev/dri/renderD128", filepath.Join(dir, fd))
+	}
+	symlink(t, "/dev/null", filepath.Join(dir, "100/fd/1"))
+
+	// WHEN the processes are read
+	samples := readAMDProcesses(dir)
+
+	// THEN the VRAM used by each DRM client is reported once per process
+	require.Len(t, samples, 2)
+	assert.Equal(t, "GPUProcessSample", samples[0].EventType)
+	assert.Equal(t, 100, samples[0].ProcessID)
+	assert.Equal(t, "pytorch", samples[0].ProcessName)
+	assert.Equal(t, "0000:03:00.0", samples[0].AdapterID)
+	assert.Equal(t, float64(1048576+1024)*1024, samples[0].UsedBytes)
+	assert.Equal(t, 200, samples[1].ProcessID)
+	assert.Equal(t, 2048.0*1024, samples[1].UsedBytes)
+}
+
+func TestReadNVIDIADevices(t *testing.T) {
+	defer func(original func(...string) ([]byte, error)) { nvidiaSMI = original }(nvidiaSMI)
+
+	// GIVEN nvidia-smi reporting a GPU and a process
+	nvidiaSMI = func(args ...string) ([]byte, error) {
+		if strings.HasPrefix(args[0], "--query-gpu=") {
+			return []byte("0, GPU-1, Tesla T4, 00000000:0B:00.0, 10, 5, 100, 15360, 35, 27.5\n"), nil
+		}
+		return []byte("GPU-1, 4321, python3, 100\n"), nil
+	}
+	devices, processes := readNVIDIADevices()
+	require.Len(t, devices, 1)
+	require.Len(t, processes, 1)
+	assert.Equal(t, "GPU-1", processes[0].AdapterID)
+
+	// AND no samples when nvidia-smi is missing
+	nvidiaSMI = func(...string) ([]byte, error) { return nil, errNoNVIDIASMI }
+	devices, processes = readNVIDIADevices()
+	assert.Empty(t, devices)
+	assert.Empty(t, processes)
+
+	// AND the GPUs are reported even if the processes can't be queried
+	nvidiaSMI = func(args ...string) ([]byte, error) {
+		if strings.HasPrefix(args[0], "--query-gpu=") {
+			return []byte("0, GPU-1, Tesla T4, 00000000:0B:00.0, 10, 5, 100, 15360, 35, 27.5\n"), nil
+		}
+		return nil, errors.New("failed")
+	}
+	devices, processes = readNVIDIADevices()
+	assert.Len(t, devices, 1)
+	assert.Empty(t, processes)
+}
+
+func TestSampler_Sample(t *testing.T) {
+	s := NewSampler(nil)
+	assert.True(t, s.Disabled())
+
+	s.devices = func() ([]*DeviceSample, []*ProcessSample) {
+		return []*DeviceSample{newDeviceSample(vendorAMD, "0000:03:00.0")},
+			[]*ProcessSample{newProcessSample(vendorAMD, "0000:03:00.0", 100)}
+	}
+	batch, err := s.Sample()
+	require.NoError(t, err)
+	require.Len(t, batch, 2)
+	assert.IsType(t, &DeviceSample{}, batch[0])
+	assert.IsType(t, &ProcessSample{}, batch[1])
+}
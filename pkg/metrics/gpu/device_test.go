@@ -0,0 +1,57 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package gpu
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseNVIDIADevices(t *testing.T) {
+	output := []byte(`0, GPU-5d9a1c3e-1111-2222-3333-444455556666, NVIDIA A100-SXM4-40GB, 00000000:07:00.0, 87, 45, 20480, 40960, 61, 251.32
+1, GPU-0f1e2d3c-aaaa-bbbb-cccc-ddddeeeeffff, Tesla T4, 00000000:0B:00.0, 0, 0, 0, 15360, 35, [N/A]
+unexpected line
+`)
+
+	samples := parseNVIDIADevices(output)
+
+	require.Len(t, samples, 2)
+	a100 := samples[0]
+	assert.Equal(t, "GPUSample", a100.EventType)
+	assert.Equal(t, "GPU-5d9a1c3e-1111-2222-3333-444455556666", a100.AdapterID)
+	assert.Equal(t, vendorNVIDIA, a100.Vendor)
+	assert.Equal(t, "NVIDIA A100-SXM4-40GB", a100.Name)
+	assert.Equal(t, "00000000:07:00.0", a100.PCIBusID)
+	assert.Equal(t, 0, *a100.Index)
+	assert.Equal(t, 87.0, *a100.UtilizationPercent)
+	assert.Equal(t, 45.0, *a100.MemoryUtilizationPercent)
+	assert.Equal(t, 20480.0*mebibyte, *a100.DedicatedUsedBytes)
+	assert.Equal(t, 40960.0*mebibyte, *a100.DedicatedTotalBytes)
+	assert.Equal(t, 61.0, *a100.TemperatureCelsius)
+	assert.Equal(t, 251.32, *a100.PowerWatts)
+
+	t4 := samples[1]
+	assert.Equal(t, 1, *t4.Index)
+	assert.Equal(t, 0.0, *t4.UtilizationPercent)
+	assert.Nil(t, t4.PowerWatts, "unsupported values must not be reported")
+}
+
+func TestParseNVIDIAProcesses(t *testing.T) {
+	output := []byte(`GPU-5d9a1c3e-1111-2222-3333-444455556666, 4321, /usr/bin/python3, 18944
+GPU-5d9a1c3e-1111-2222-3333-444455556666, 4400, /opt/triton/bin/tritonserver, [N/A]
+GPU-5d9a1c3e-1111-2222-3333-444455556666, not-a-pid, python3, 100
+`)
+
+	samples := parseNVIDIAProcesses(output)
+
+	require.Len(t, samples, 2)
+	assert.Equal(t, "GPUProcessSample", samples[0].EventType)
+	assert.Equal(t, "GPU-5d9a1c3e-1111-2222-3333-444455556666", samples[0].AdapterID)
+	assert.Equal(t, 4321, samples[0].ProcessID)
+	assert.Equal(t, "/usr/bin/python3", samples[0].ProcessName)
+	assert.Equal(t, 18944.0*mebibyte, samples[0].UsedBytes)
+	assert.Equal(t, 4400, samples[1].ProcessID)
+	assert.Equal(t, 0.0, samples[1].UsedBytes)
+}
@@ -0,0 +1,240 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+// Package gpu samples the GPU usage of the host. On Windows, the usage of the GPU adapters and the
+// graphics performance of the remote desktop sessions are reported by the GPU and RemoteFX performance
+// counters. On Linux, NVIDIA GPUs are queried through nvidia-smi and AMD GPUs are read from sysfs.
+package gpu
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/newrelic/infrastructure-agent/pkg/sample"
+)
+
+// GPU performance counters. Their instance names identify the adapter, the engine and the process,
+// e.g. "pid_1234_luid_0x00000000_0x0000D1A5_phys_0_eng_0_engtype_3D".
+const (
+	engineUtilizationCounter = "\\GPU Engine(*)\\Utilization Percentage"
+	dedicatedUsageCounter    = "\\GPU Adapter Memory(*)\\Dedicated Usage"
+	sharedUsageCounter       = "\\GPU Adapter Memory(*)\\Shared Usage"
+	totalCommittedCounter    = "\\GPU Adapter Memory(*)\\Total Committed"
+)
+
+// RemoteFX performance counters. Their instance names are the remote desktop session names.
+const (
+	inputFramesCounter          = "\\RemoteFX Graphics(*)\\Input Frames/Second"
+	outputFramesCounter         = "\\RemoteFX Graphics(*)\\Output Frames/Second"
+	skippedClientFramesCounter  = "\\RemoteFX Graphics(*)\\Frames Skipped/Second - Insufficient Client Resources"
+	skippedNetworkFramesCounter = "\\RemoteFX Graphics(*)\\Frames Skipped/Second - Insufficient Network Resources"
+	skippedServerFramesCounter  = "\\RemoteFX Graphics(*)\\Frames Skipped/Second - Insufficient Server Resources"
+	encodingTimeCounter         = "\\RemoteFX Graphics(*)\\Average Encoding Time"
+	frameQualityCounter         = "\\RemoteFX Graphics(*)\\Frame Quality"
+)
+
+var gpuCounters = []string{
+	engineUtilizationCounter,
+	dedicatedUsageCounter,
+	sharedUsageCounter,
+	totalCommittedCounter,
+}
+
+var sessionCounters = []string{
+	inputFramesCounter,
+	outputFramesCounter,
+	skippedClientFramesCounter,
+	skippedNetworkFramesCounter,
+	skippedServerFramesCounter,
+	encodingTimeCounter,
+	frameQualityCounter,
+}
+
+// GPUSample holds the utilization and memory usage of a GPU adapter.
+type GPUSample struct {
+	sample.BaseEvent
+
+	AdapterID string `json:"gpuAdapterId"`
+
+	UtilizationPercent  float64 `json:"gpuUtilizationPercent"`
+	ThreeDPercent       float64 `json:"gpu3dPercent"`
+	CopyPercent         float64 `json:"gpuCopyPercent"`
+	ComputePercent      float64 `json:"gpuComputePercent"`
+	VideoDecodePercent  float64 `json:"gpuVideoDecodePercent"`
+	VideoEncodePercent  float64 `json:"gpuVideoEncodePercent"`
+	DedicatedUsedBytes  float64 `json:"gpuDedicatedMemoryUsedBytes"`
+	SharedUsedBytes     float64 `json:"gpuSharedMemoryUsedBytes"`
+	TotalCommittedBytes float64 `json:"gpuTotalCommittedMemoryBytes"`
+}
+
+// GraphicsSessionSample holds the RemoteFX graphics performance of a remote desktop session.
+type GraphicsSessionSample struct {
+	sample.BaseEvent
+
+	SessionName string `json:"sessionName"`
+
+	InputFramesPerSec          float64 `json:"inputFramesPerSecond"`
+	OutputFramesPerSec         float64 `json:"outputFramesPerSecond"`
+	SkippedClientFramesPerSec  float64 `json:"framesSkippedClientResourcesPerSecond"`
+	SkippedNetworkFramesPerSec float64 `json:"framesSkippedNetworkResourcesPerSecond"`
+	SkippedServerFramesPerSec  float64 `json:"framesSkippedServerResourcesPerSecond"`
+	AverageEncodingTimeMs      float64 `json:"averageEncodingTimeMs"`
+	FrameQualityPercent        float64 `json:"frameQualityPercent"`
+}
+
+// engineInstance is the parsed instance name of a GPU counter.
+type engineInstance struct {
+	adapter    string
+	engine     string
+	engineType string
+}
+
+// parseInstance extracts the adapter ("luid_<high>_<low>_phys_<n>"), the engine and the engine type
+// from a GPU counter instance name. Memory counters only identify the adapter.
+func parseInstance(name string) (engineInstance, bool) {
+	start := strings.Index(name, "luid_")
+	if start < 0 {
+		return engineInstance{}, false
+	}
+	name = name[start:]
+
+	engStart := strings.Index(name, "_eng_")
+	if engStart < 0 {
+		return engineInstance{adapter: name}, true
+	}
+	instance := engineInstance{adapter: name[:engStart]}
+	engine := name[engStart+len("_eng_"):]
+	if typeStart := strings.Index(engine, "_engtype_"); typeStart >= 0 {
+		instance.engineType = engine[typeStart+len("_engtype_"):]
+		engine = engine[:typeStart]
+	}
+	instance.engine = engine
+	return instance, true
+}
+
+// newGPUSamples aggregates the GPU counters values by adapter. As the engine utilization is reported
+// per process, the utilization of an engine type is the one of its busiest engine, being each engine
+// utilization the sum of all the processes using it.
+func newGPUSamples(values map[string]map[string]float64) []*GPUSample {
+	samples := map[string]*GPUSample{}
+	sampleFor := func(adapter string) *GPUSample {
+		s, ok := samples[adapter]
+		if !ok {
+			s = &GPUSample{
+				BaseEvent: sample.BaseEvent{EventType: "GPUSample"},
+				AdapterID: adapter,
+			}
+			samples[adapter] = s
+		}
+		return s
+	}
+
+	engines := map[engineInstance]float64{}
+	for name, value := range values[engineUtilizationCounter] {
+		instance, ok := parseInstance(name)
+		if !ok || instance.engine == "" {
+			continue
+		}
+		engines[instance] += value
+	}
+	for instance, utilization := range engines {
+		utilization = clampPercent(utilization)
+		s := sampleFor(instance.adapter)
+		if utilization > s.UtilizationPercent {
+			s.UtilizationPercent = utilization
+		}
+		if field := engineTypeField(s, instance.engineType); field != nil && utilization > *field {
+			*field = utilization
+		}
+	}
+
+	memoryFields := map[string]func(*GPUSample) *float64{
+		dedicatedUsageCounter: func(s *GPUSample) *float64 { return &s.DedicatedUsedBytes },
+		sharedUsageCounter:    func(s *GPUSample) *float64 { return &s.SharedUsedBytes },
+		totalCommittedCounter: func(s *GPUSample) *float64 { return &s.TotalCommittedBytes },
+	}
+	for counter, field := range memoryFields {
+		for name, value := range values[counter] {
+			instance, ok := parseInstance(name)
+			if !ok {
+				continue
+			}
+			*field(sampleFor(instance.adapter)) = value
+		}
+	}
+
+	result := make([]*GPUSample, 0, len(samples))
+	for _, s := range samples {
+		result = append(result, s)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].AdapterID < result[j].AdapterID
+	})
+	return result
+}
+
+// engineTypeField returns the sample field for the given engine type, or nil if it is not reported.
+// Compute engines are numbered, e.g. "Compute_0", "Compute_1".
+func engineTypeField(s *GPUSample, engineType string) *float64 {
+	switch {
+	case engineType == "3D":
+		return &s.ThreeDPercent
+	case engineType == "Copy":
+		return &s.CopyPercent
+	case strings.HasPrefix(engineType, "Compute"):
+		return &s.ComputePercent
+	case engineType == "VideoDecode":
+		return &s.VideoDecodePercent
+	case engineType == "VideoEncode":
+		return &s.VideoEncodePercent
+	}
+	return nil
+}
+
+func clampPercent(value float64) float64 {
+	if value > 100 {
+		return 100
+	}
+	return value
+}
+
+// newGraphicsSessionSamples creates a sample for each remote desktop session reported by the RemoteFX counters.
+func newGraphicsSessionSamples(values map[string]map[string]float64) []*GraphicsSessionSample {
+	samples := map[string]*GraphicsSessionSample{}
+	for _, counter := range sessionCounters {
+		for session, value := range values[counter] {
+			s, ok := samples[session]
+			if !ok {
+				s = &GraphicsSessionSample{
+					BaseEvent:   sample.BaseEvent{EventType: "GraphicsSessionSample"},
+					SessionName: session,
+				}
+				samples[session] = s
+			}
+			switch counter {
+			case inputFramesCounter:
+				s.InputFramesPerSec = value
+			case outputFramesCounter:
+				s.OutputFramesPerSec = value
+			case skippedClientFramesCounter:
+				s.SkippedClientFramesPerSec = value
+			case skippedNetworkFramesCounter:
+				s.SkippedNetworkFramesPerSec = value
+			case skippedServerFramesCounter:
+				s.SkippedServerFramesPerSec = value
+			case encodingTimeCounter:
+				s.AverageEncodingTimeMs = value
+			case frameQualityCounter:
+				s.FrameQualityPercent = value
+			}
+		}
+	}
+
+	result := make([]*GraphicsSessionSample, 0, len(samples))
+	for _, s := range samples {
+		result = append(result, s)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].SessionName < result[j].SessionName
+	})
+	return result
+}
@@ -0,0 +1,110 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package gpu
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const adapter = "luid_0x00000000_0x0000D1A5_phys_0"
+
+func TestParseInstance(t *testing.T) {
+	tests := []struct {
+		name     string
+		instance string
+		expected engineInstance
+		ok       bool
+	}{
+		{"engine", "pid_1234_" + adapter + "_eng_3_engtype_3D", engineInstance{adapter, "3", "3D"}, true},
+		{"numbered engine type", "pid_1_" + adapter + "_eng_10_engtype_Compute_1", engineInstance{adapter, "10", "Compute_1"}, true},
+		{"adapter memory", adapter, engineInstance{adapter: adapter}, true},
+		{"process memory", "pid_1234_" + adapter, engineInstance{adapter: adapter}, true},
+		{"unknown", "_Total", engineInstance{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			instance, ok := parseInstance(tt.instance)
+			assert.Equal(t, tt.ok, ok)
+			assert.Equal(t, tt.expected, instance)
+		})
+	}
+}
+
+func TestNewGPUSamples(t *testing.T) {
+	// GIVEN two processes using the same 3D engine and another process using a different 3D engine
+	values := map[string]map[string]float64{
+		engineUtilizationCounter: {
+			"pid_10_" + adapter + "_eng_0_engtype_3D":          30,
+			"pid_20_" + adapter + "_eng_0_engtype_3D":          25,
+			"pid_30_" + adapter + "_eng_1_engtype_3D":          40,
+			"pid_10_" + adapter + "_eng_4_engtype_VideoDecode": 12,
+			"pid_10_" + adapter + "_eng_5_engtype_Compute_0":   70,
+			"pid_20_" + adapter + "_eng_6_engtype_Compute_1":   80,
+			"pid_20_" + adapter + "_eng_7_engtype_Security":    90,
+		},
+		dedicatedUsageCounter: {adapter: 1024},
+		sharedUsageCounter:    {adapter: 2048},
+		totalCommittedCounter: {adapter: 4096},
+	}
+
+	// WHEN the samples are created
+	samples := newGPUSamples(values)
+
+	// THEN a sample is reported for the adapter
+	require.Len(t, samples, 1)
+	s := samples[0]
+	assert.Equal(t, "GPUSample", s.EventType)
+	assert.Equal(t, adapter, s.AdapterID)
+
+	// AND each engine type reports its busiest engine
+	assert.Equal(t, 55.0, s.ThreeDPercent)
+	assert.Equal(t, 12.0, s.VideoDecodePercent)
+	assert.Equal(t, 80.0, s.ComputePercent)
+	assert.Equal(t, 0.0, s.VideoEncodePercent)
+	assert.Equal(t, 90.0, s.UtilizationPercent)
+
+	// AND the adapter memory usage
+	assert.Equal(t, 1024.0, s.DedicatedUsedBytes)
+	assert.Equal(t, 2048.0, s.SharedUsedBytes)
+	assert.Equal(t, 4096.0, s.TotalCommittedBytes)
+}
+
+func TestNewGPUSamples_UtilizationIsLimited(t *testing.T) {
+	samples := newGPUSamples(map[string]map[string]float64{
+		engineUtilizationCounter: {
+			"pid_10_" + adapter + "_eng_0_engtype_3D": 70,
+			"pid_20_" + adapter + "_eng_0_engtype_3D": 60,
+		},
+	})
+
+	require.Len(t, samples, 1)
+	assert.Equal(t, 100.0, samples[0].ThreeDPercent)
+	assert.Equal(t, 100.0, samples[0].UtilizationPercent)
+}
+
+func TestNewGraphicsSessionSamples(t *testing.T) {
+	samples := newGraphicsSessionSamples(map[string]map[string]float64{
+		inputFramesCounter:          {"RDP-Tcp 2": 30, "RDP-Tcp 1": 60},
+		outputFramesCounter:         {"RDP-Tcp 2": 24, "RDP-Tcp 1": 60},
+		skippedNetworkFramesCounter: {"RDP-Tcp 2": 6},
+		encodingTimeCounter:         {"RDP-Tcp 2": 8.5},
+		frameQualityCounter:         {"RDP-Tcp 2": 75},
+	})
+
+	require.Len(t, samples, 2)
+	assert.Equal(t, "RDP-Tcp 1", samples[0].SessionName)
+	assert.Equal(t, 60.0, samples[0].OutputFramesPerSec)
+	assert.Equal(t, &GraphicsSessionSample{
+		BaseEvent:                  samples[1].BaseEvent,
+		SessionName:                "RDP-Tcp 2",
+		InputFramesPerSec:          30,
+		OutputFramesPerSec:         24,
+		SkippedNetworkFramesPerSec: 6,
+		AverageEncodingTimeMs:      8.5,
+		FrameQualityPercent:        75,
+	}, samples[1])
+	assert.Equal(t, "GraphicsSessionSample", samples[1].EventType)
+}
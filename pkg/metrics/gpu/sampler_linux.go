@@ -0,0 +1,66 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package gpu
+
+import (
+	"fmt"
+	"runtime/debug"
+	"time"
+
+	"github.com/newrelic/infrastructure-agent/internal/agent"
+	"github.com/newrelic/infrastructure-agent/pkg/config"
+	"github.com/newrelic/infrastructure-agent/pkg/log"
+	"github.com/newrelic/infrastructure-agent/pkg/sample"
+)
+
+var gslog = log.WithComponent("GPUSampler")
+
+// Sampler reports a GPUSample per NVIDIA or AMD GPU and a GPUProcessSample per process using GPU memory.
+// Hosts without GPUs, or without the NVIDIA driver tools, just don't report the related samples.
+type Sampler struct {
+	context  agent.AgentContext
+	interval time.Duration
+	devices  func() ([]*DeviceSample, []*ProcessSample)
+}
+
+// NewSampler creates a GPU sampler.
+func NewSampler(context agent.AgentContext) *Sampler {
+	intervalSec := config.FREQ_DISABLE_SAMPLING
+	if context != nil {
+		intervalSec = context.Config().MetricsGPUSampleRate
+	}
+	return &Sampler{
+		context:  context,
+		interval: time.Second * time.Duration(intervalSec),
+		devices:  readDevices,
+	}
+}
+
+func (s *Sampler) Name() string { return "GPUSampler" }
+
+func (s *Sampler) Interval() time.Duration {
+	return s.interval
+}
+
+func (s *Sampler) Disabled() bool {
+	return s.Interval() <= config.FREQ_DISABLE_SAMPLING
+}
+
+func (s *Sampler) OnStartup() {}
+
+func (s *Sampler) Sample() (results sample.EventBatch, err error) {
+	defer func() {
+		if panicErr := recover(); panicErr != nil {
+			err = fmt.Errorf("Panic in GPUSampler.Sample: %v\nStack: %s", panicErr, debug.Stack())
+		}
+	}()
+
+	devices, processes := s.devices()
+	for _, device := range devices {
+		results = append(results, device)
+	}
+	for _, process := range processes {
+		results = append(results, process)
+	}
+	return results, nil
+}
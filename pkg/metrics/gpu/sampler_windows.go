@@ -0,0 +1,92 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+// +build windows
+
+package gpu
+
+import (
+	"fmt"
+	"runtime/debug"
+	"time"
+
+	"github.com/newrelic/infrastructure-agent/internal/agent"
+	"github.com/newrelic/infrastructure-agent/internal/windows"
+	"github.com/newrelic/infrastructure-agent/pkg/config"
+	"github.com/newrelic/infrastructure-agent/pkg/log"
+	"github.com/newrelic/infrastructure-agent/pkg/sample"
+)
+
+var gslog = log.WithComponent("GPUSampler")
+
+// Sampler reports a GPUSample per GPU adapter and a GraphicsSessionSample per remote desktop session.
+// Hosts without GPU or RemoteFX performance counters just don't report the related samples.
+type Sampler struct {
+	context     agent.AgentContext
+	interval    time.Duration
+	gpuPoll     *nrwin.PdhPoll
+	sessionPoll *nrwin.PdhPoll
+}
+
+// NewSampler creates a GPU sampler.
+func NewSampler(context agent.AgentContext) *Sampler {
+	intervalSec := config.FREQ_DISABLE_SAMPLING
+	if context != nil {
+		intervalSec = context.Config().MetricsGPUSampleRate
+	}
+	return &Sampler{
+		context:  context,
+		interval: time.Second * time.Duration(intervalSec),
+	}
+}
+
+func (s *Sampler) Name() string { return "GPUSampler" }
+
+func (s *Sampler) Interval() time.Duration {
+	return s.interval
+}
+
+func (s *Sampler) Disabled() bool {
+	return s.Interval() <= config.FREQ_DISABLE_SAMPLING
+}
+
+func (s *Sampler) OnStartup() {}
+
+func (s *Sampler) Sample() (results sample.EventBatch, err error) {
+	defer func() {
+		if panicErr := recover(); panicErr != nil {
+			err = fmt.Errorf("Panic in GPUSampler.Sample: %v\nStack: %s", panicErr, debug.Stack())
+		}
+	}()
+
+	if values := poll(&s.gpuPoll, gpuCounters); values != nil {
+		for _, gpuSample := range newGPUSamples(values) {
+			results = append(results, gpuSample)
+		}
+	}
+	if values := poll(&s.sessionPoll, sessionCounters); values != nil {
+		for _, sessionSample := range newGraphicsSessionSamples(values) {
+			results = append(results, sessionSample)
+		}
+	}
+	return results, nil
+}
+
+// poll queries the passed counters, creating the PDH query the first time. It returns nil if the
+// counters are not available in the host.
+func poll(pdh **nrwin.PdhPoll, counters []string) map[string]map[string]float64 {
+	if *pdh == nil {
+		p, err := nrwin.NewPdhPoll(log.Debugf, counters...)
+		if err != nil {
+			gslog.WithError(err).Debug("Creating PDH query.")
+			return nil
+		}
+		*pdh = &p
+	}
+
+	values, err := (*pdh).PollInstances()
+	if err != nil {
+		gslog.WithError(err).Debug("Polling PDH counters.")
+		return nil
+	}
+	return values
+}
@@ -0,0 +1,23 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package metrics
+
+// HugePagesSample holds the usage of the persistent huge pages pool, sized with vm.nr_hugepages, and of the
+// transparent huge pages (THP). Attributes are only present if the kernel reports them.
+type HugePagesSample struct {
+	HugePagesTotal    *uint64 `json:"memoryHugePagesTotal,omitempty"`
+	HugePagesFree     *uint64 `json:"memoryHugePagesFree,omitempty"`
+	HugePagesReserved *uint64 `json:"memoryHugePagesReserved,omitempty"`
+	HugePagesSurplus  *uint64 `json:"memoryHugePagesSurplus,omitempty"`
+	HugePageSizeBytes *uint64 `json:"memoryHugePageSizeBytes,omitempty"`
+	// THP modes: always, madvise or never, as in /sys/kernel/mm/transparent_hugepage
+	TransparentHugePagesEnabled string `json:"memoryTransparentHugePagesEnabled,omitempty"`
+	TransparentHugePagesDefrag  string `json:"memoryTransparentHugePagesDefrag,omitempty"`
+	// Anonymous memory backed by THP
+	AnonHugePagesBytes *uint64 `json:"memoryAnonHugePagesBytes,omitempty"`
+	// THP allocated on page faults or by khugepaged, and page faults falling back to regular pages because
+	// no huge page could be allocated. Rates are reported from the second sample.
+	THPFaultAllocPerSec    *float64 `json:"memoryThpFaultAllocPerSecond,omitempty"`
+	THPFaultFallbackPerSec *float64 `json:"memoryThpFaultFallbackPerSecond,omitempty"`
+	THPCollapseAllocPerSec *float64 `json:"memoryThpCollapseAllocPerSecond,omitempty"`
+}
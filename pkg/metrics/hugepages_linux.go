@@ -0,0 +1,157 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package metrics
+
+import (
+	"bufio"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/newrelic/infrastructure-agent/pkg/helpers"
+)
+
+// thpCounters are the cumulative THP allocation counters of /proc/vmstat.
+type thpCounters struct {
+	faultAlloc, faultFallback, collapseAlloc uint64
+	time                                     time.Time
+}
+
+// hugePagesMonitor reads the huge pages usage from procDir (/proc) and sysDir (/sys).
+type hugePagesMonitor struct {
+	procDir, sysDir string
+	previous        *thpCounters
+}
+
+func newHugePagesMonitor() *hugePagesMonitor {
+	return &hugePagesMonitor{procDir: helpers.HostProc(), sysDir: helpers.HostSys()}
+}
+
+func (m *hugePagesMonitor) sample() (*HugePagesSample, error) {
+	sample, err := readHugePagesMeminfo(filepath.Join(m.procDir, "meminfo"))
+	if err != nil {
+		return nil, err
+	}
+
+	thpDir := filepath.Join(m.sysDir, "kernel", "mm", "transparent_hugepage")
+	sample.TransparentHugePagesEnabled = readTHPMode(filepath.Join(thpDir, "enabled"))
+	sample.TransparentHugePagesDefrag = readTHPMode(filepath.Join(thpDir, "defrag"))
+
+	counters, err := readTHPCounters(filepath.Join(m.procDir, "vmstat"))
+	if err != nil {
+		syslog.WithError(err).Debug("Unable to read the transparent huge pages counters.")
+		m.previous = nil
+		return sample, nil
+	}
+	if previous := m.previous; previous != nil {
+		elapsed := counters.time.Sub(previous.time).Seconds()
+		if elapsed > 0 && counters.faultAlloc >= previous.faultAlloc && counters.faultFallback >= previous.faultFallback &&
+			counters.collapseAlloc >= previous.collapseAlloc {
+			faultAlloc := float64(counters.faultAlloc-previous.faultAlloc) / elapsed
+			faultFallback := float64(counters.faultFallback-previous.faultFallback) / elapsed
+			collapseAlloc := float64(counters.collapseAlloc-previous.collapseAlloc) / elapsed
+			sample.THPFaultAllocPerSec = &faultAlloc
+			sample.THPFaultFallbackPerSec = &faultFallback
+			sample.THPCollapseAllocPerSec = &collapseAlloc
+		}
+	}
+	m.previous = counters
+	return sample, nil
+}
+
+// readHugePagesMeminfo parses the huge pages lines of /proc/meminfo, e.g.
+// AnonHugePages:    137216 kB
+// HugePages_Total:     512
+// HugePages_Free:      480
+// HugePages_Rsvd:       16
+// HugePages_Surp:        0
+// Hugepagesize:       2048 kB
+func readHugePagesMeminfo(path string) (*HugePagesSample, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	sample := &HugePagesSample{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		var field **uint64
+		multiplier := uint64(1)
+		switch fields[0] {
+		case "HugePages_Total:":
+			field = &sample.HugePagesTotal
+		case "HugePages_Free:":
+			field = &sample.HugePagesFree
+		case "HugePages_Rsvd:":
+			field = &sample.HugePagesReserved
+		case "HugePages_Surp:":
+			field = &sample.HugePagesSurplus
+		case "Hugepagesize:":
+			field, multiplier = &sample.HugePageSizeBytes, 1024
+		case "AnonHugePages:":
+			field, multiplier = &sample.AnonHugePagesBytes, 1024
+		default:
+			continue
+		}
+		value, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		value *= multiplier
+		*field = &value
+	}
+	return sample, scanner.Err()
+}
+
+// readTHPMode returns the selected mode of a THP setting file, e.g. "madvise" for "always [madvise] never",
+// or an empty string if THP isn't supported.
+func readTHPMode(path string) string {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	for _, mode := range strings.Fields(string(content)) {
+		if strings.HasPrefix(mode, "[") && strings.HasSuffix(mode, "]") {
+			return strings.Trim(mode, "[]")
+		}
+	}
+	return ""
+}
+
+func readTHPCounters(path string) (*thpCounters, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	counters := &thpCounters{time: time.Now()}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 || !strings.HasPrefix(fields[0], "thp_") {
+			continue
+		}
+		value, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			continue
+		}
+		switch fields[0] {
+		case "thp_fault_alloc":
+			counters.faultAlloc = value
+		case "thp_fault_fallback":
+			counters.faultFallback = value
+		case "thp_collapse_alloc":
+			counters.collapseAlloc = value
+		}
+	}
+	return counters, scanner.Err()
+}
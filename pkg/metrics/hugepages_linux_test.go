@@ -0,0 +1,75 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package metrics
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHugePagesMonitor_Sample(t *testing.T) {
+	// GIVEN a host with a pool of 2MiB huge pages and THP enabled on madvise
+	dir, err := ioutil.TempDir("", "hugepages")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	thpDir := filepath.Join(dir, "sys", "kernel", "mm", "transparent_hugepage")
+	require.NoError(t, os.MkdirAll(thpDir, 0755))
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "proc"), 0755))
+	for path, content := range map[string]string{
+		"proc/meminfo": "MemTotal:       16318432 kB\nAnonHugePages:    137216 kB\nHugePages_Total:     512\n" +
+			"HugePages_Free:      480\nHugePages_Rsvd:       16\nHugePages_Surp:        0\nHugepagesize:       2048 kB\n",
+		"proc/vmstat": "nr_free_pages 1234\nthp_fault_alloc 100\nthp_fault_fallback 10\nthp_collapse_alloc 5\n",
+		"sys/kernel/mm/transparent_hugepage/enabled": "always [madvise] never\n",
+		"sys/kernel/mm/transparent_hugepage/defrag":  "always defer defer+madvise [madvise] never\n",
+	} {
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, path), []byte(content), 0644))
+	}
+	m := &hugePagesMonitor{procDir: filepath.Join(dir, "proc"), sysDir: filepath.Join(dir, "sys")}
+
+	// WHEN they are sampled
+	sample, err := m.sample()
+	require.NoError(t, err)
+
+	// THEN the huge pages pool and THP settings are reported
+	assert.Equal(t, uint64(512), *sample.HugePagesTotal)
+	assert.Equal(t, uint64(480), *sample.HugePagesFree)
+	assert.Equal(t, uint64(16), *sample.HugePagesReserved)
+	assert.Equal(t, uint64(0), *sample.HugePagesSurplus)
+	assert.Equal(t, uint64(2048*1024), *sample.HugePageSizeBytes)
+	assert.Equal(t, uint64(137216*1024), *sample.AnonHugePagesBytes)
+	assert.Equal(t, "madvise", sample.TransparentHugePagesEnabled)
+	assert.Equal(t, "madvise", sample.TransparentHugePagesDefrag)
+	// AND the THP rates aren't, until there is a previous sample
+	assert.Nil(t, sample.THPFaultAllocPerSec)
+
+	// WHEN the THP counters increase
+	m.previous.time = m.previous.time.Add(-10 * time.Second)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "proc", "vmstat"),
+		[]byte("thp_fault_alloc 300\nthp_fault_fallback 60\nthp_collapse_alloc 5\n"), 0644))
+	sample, err = m.sample()
+	require.NoError(t, err)
+
+	// THEN their rates are reported
+	require.NotNil(t, sample.THPFaultAllocPerSec)
+	assert.InDelta(t, 20.0, *sample.THPFaultAllocPerSec, 0.1)
+	assert.InDelta(t, 5.0, *sample.THPFaultFallbackPerSec, 0.1)
+	assert.InDelta(t, 0.0, *sample.THPCollapseAllocPerSec, 0.1)
+}
+
+func TestHugePagesMonitor_Sample_NoTHP(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hugepages")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "meminfo"), []byte("MemTotal:       16318432 kB\n"), 0644))
+
+	sample, err := (&hugePagesMonitor{procDir: dir, sysDir: dir}).sample()
+
+	require.NoError(t, err)
+	assert.Equal(t, &HugePagesSample{}, sample)
+}
@@ -0,0 +1,106 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package metrics
+
+import "time"
+
+// KernelActivitySample holds the rates of the kernel activity that isn't visible in the CPU utilization: context
+// switches, hardware interrupts and software interrupts (softirqs) by type, summed across the CPUs. Rates are
+// reported from the second sample.
+type KernelActivitySample struct {
+	ContextSwitchesPerSec *float64 `json:"contextSwitchesPerSecond,omitempty"`
+	InterruptsPerSec      *float64 `json:"interruptsPerSecond,omitempty"`
+	SoftirqsPerSec        *float64 `json:"softirqsPerSecond,omitempty"`
+	SoftirqHIPerSec       *float64 `json:"softirqHiPerSecond,omitempty"`
+	SoftirqTimerPerSec    *float64 `json:"softirqTimerPerSecond,omitempty"`
+	SoftirqNetTXPerSec    *float64 `json:"softirqNetTxPerSecond,omitempty"`
+	SoftirqNetRXPerSec    *float64 `json:"softirqNetRxPerSecond,omitempty"`
+	SoftirqBlockPerSec    *float64 `json:"softirqBlockPerSecond,omitempty"`
+	SoftirqIRQPollPerSec  *float64 `json:"softirqIrqPollPerSecond,omitempty"`
+	SoftirqTaskletPerSec  *float64 `json:"softirqTaskletPerSecond,omitempty"`
+	SoftirqSchedPerSec    *float64 `json:"softirqSchedPerSecond,omitempty"`
+	SoftirqHRTimerPerSec  *float64 `json:"softirqHrtimerPerSecond,omitempty"`
+	SoftirqRCUPerSec      *float64 `json:"softirqRcuPerSecond,omitempty"`
+}
+
+// kernelCounters are the cumulative kernel activity counters since boot.
+type kernelCounters struct {
+	contextSwitches, interrupts uint64
+	// by softirq type, as named in /proc/softirqs
+	softirqs map[string]uint64
+	time     time.Time
+}
+
+// softirq returns the sample field of a softirq type, or nil for unknown types.
+func (s *KernelActivitySample) softirq(name string) **float64 {
+	switch name {
+	case "HI":
+		return &s.SoftirqHIPerSec
+	case "TIMER":
+		return &s.SoftirqTimerPerSec
+	case "NET_TX":
+		return &s.SoftirqNetTXPerSec
+	case "NET_RX":
+		return &s.SoftirqNetRXPerSec
+	case "BLOCK":
+		return &s.SoftirqBlockPerSec
+	case "IRQ_POLL", "BLOCK_IOPOLL":
+		return &s.SoftirqIRQPollPerSec
+	case "TASKLET":
+		return &s.SoftirqTaskletPerSec
+	case "SCHED":
+		return &s.SoftirqSchedPerSec
+	case "HRTIMER":
+		return &s.SoftirqHRTimerPerSec
+	case "RCU":
+		return &s.SoftirqRCUPerSec
+	}
+	return nil
+}
+
+// counterRate returns the per second increase of a counter, or nil if it was reset.
+func counterRate(current, previous uint64, elapsedSeconds float64) *float64 {
+	if current < previous {
+		return nil
+	}
+	r := float64(current-previous) / elapsedSeconds
+	return &r
+}
+
+// newKernelActivitySample returns the rates between two readings of the counters, or nil if no time elapsed.
+func newKernelActivitySample(previous, current *kernelCounters) *KernelActivitySample {
+	elapsed := current.time.Sub(previous.time).Seconds()
+	if elapsed <= 0 {
+		return nil
+	}
+	s := &KernelActivitySample{
+		ContextSwitchesPerSec: counterRate(current.contextSwitches, previous.contextSwitches, elapsed),
+		InterruptsPerSec:      counterRate(current.interrupts, previous.interrupts, elapsed),
+	}
+	var total, previousTotal uint64
+	for name, count := range current.softirqs {
+		previousCount, ok := previous.softirqs[name]
+		if !ok {
+			continue
+		}
+		total += count
+		previousTotal += previousCount
+		if field := s.softirq(name); field != nil {
+			*field = counterRate(count, previousCount, elapsed)
+		}
+	}
+	if len(current.softirqs) > 0 {
+		s.SoftirqsPerSec = counterRate(total, previousTotal, elapsed)
+	}
+	return s
+}
+
+// KernelActivityMonitor reads the kernel activity counters of the host.
+type KernelActivityMonitor struct {
+	procDir  string
+	previous *kernelCounters
+}
+
+func NewKernelActivityMonitor() *KernelActivityMonitor {
+	return &KernelActivityMonitor{procDir: kernelActivityProcDir()}
+}
@@ -0,0 +1,120 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/newrelic/infrastructure-agent/pkg/helpers"
+)
+
+func kernelActivityProcDir() string {
+	return helpers.HostProc()
+}
+
+// Sample returns nil on the first invocation, as the rates require a previous reading of the counters.
+func (m *KernelActivityMonitor) Sample() (sample *KernelActivitySample, err error) {
+	defer func() {
+		if panicErr := recover(); panicErr != nil {
+			err = fmt.Errorf("Panic in KernelActivityMonitor.Sample: %v\nStack: %s", panicErr, debug.Stack())
+		}
+	}()
+
+	current := &kernelCounters{time: time.Now()}
+	if current.contextSwitches, current.interrupts, err = readStatCounters(filepath.Join(m.procDir, "stat")); err != nil {
+		m.previous = nil
+		return nil, err
+	}
+	// softirqs are optional, as /proc/softirqs is missing in some restricted containers
+	if current.softirqs, err = readSoftirqs(filepath.Join(m.procDir, "softirqs")); err != nil && !os.IsNotExist(err) {
+		syslog.WithError(err).Debug("Unable to read softirqs.")
+	}
+
+	previous := m.previous
+	m.previous = current
+	if previous == nil {
+		return nil, nil
+	}
+	return newKernelActivitySample(previous, current), nil
+}
+
+// readStatCounters returns the context switches and interrupts since boot from /proc/stat, e.g.
+// intr 114930548 113 199 0 0 0 0 0 0 1 0 0 0 157 0 ...
+// ctxt 1990473
+func readStatCounters(path string) (contextSwitches, interrupts uint64, err error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer file.Close()
+
+	var found int
+	scanner := bufio.NewScanner(file)
+	// the intr line has a column per interrupt, which is longer than the default buffer on large hosts
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() && found < 2 {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		switch fields[0] {
+		case "ctxt":
+			contextSwitches, err = strconv.ParseUint(fields[1], 10, 64)
+		case "intr":
+			// the first column is the total
+			interrupts, err = strconv.ParseUint(fields[1], 10, 64)
+		default:
+			continue
+		}
+		if err != nil {
+			return 0, 0, err
+		}
+		found++
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, 0, err
+	}
+	if found < 2 {
+		return 0, 0, fmt.Errorf("missing ctxt or intr lines in %s", path)
+	}
+	return contextSwitches, interrupts, nil
+}
+
+// readSoftirqs returns the softirqs by type since boot from /proc/softirqs, summing the CPU columns, e.g.
+// CPU0       CPU1
+// HI:          0          1
+// TIMER:     123456     234567
+// NET_RX:       9876       5432
+func readSoftirqs(path string) (map[string]uint64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	softirqs := map[string]uint64{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 || !strings.HasSuffix(fields[0], ":") {
+			continue
+		}
+		var total uint64
+		for _, field := range fields[1:] {
+			count, err := strconv.ParseUint(field, 10, 64)
+			if err != nil {
+				return nil, err
+			}
+			total += count
+		}
+		softirqs[strings.TrimSuffix(fields[0], ":")] = total
+	}
+	return softirqs, scanner.Err()
+}
@@ -0,0 +1,73 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package metrics
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeKernelActivityFiles(t *testing.T, dir string, ctxt, intr, netRX, timer int) {
+	stat := "cpu  4705 356 584 3699176 23060 0 277 0 0 0\n" +
+		"intr " + strconv.Itoa(intr) + " 113 199 0 0 0\n" +
+		"ctxt " + strconv.Itoa(ctxt) + "\nbtime 1602835200\nprocesses 4210\n"
+	softirqs := "                    CPU0       CPU1\n" +
+		"          HI:          0          1\n" +
+		"       TIMER:          " + strconv.Itoa(timer) + "          " + strconv.Itoa(timer) + "\n" +
+		"      NET_RX:          " + strconv.Itoa(netRX) + "          0\n"
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "stat"), []byte(stat), 0644))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "softirqs"), []byte(softirqs), 0644))
+}
+
+func TestKernelActivityMonitor_Sample(t *testing.T) {
+	// GIVEN the kernel counters of a host with two CPUs
+	dir, err := ioutil.TempDir("", "kernel")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	writeKernelActivityFiles(t, dir, 1000, 500, 100, 50)
+	m := &KernelActivityMonitor{procDir: dir}
+
+	// WHEN they are sampled for the first time
+	sample, err := m.Sample()
+
+	// THEN no rates are reported
+	require.NoError(t, err)
+	assert.Nil(t, sample)
+
+	// WHEN the counters increase
+	m.previous.time = m.previous.time.Add(-10 * time.Second)
+	writeKernelActivityFiles(t, dir, 21000, 5500, 600, 150)
+	sample, err = m.Sample()
+
+	// THEN their rates are reported
+	require.NoError(t, err)
+	require.NotNil(t, sample)
+	assert.InDelta(t, 2000, *sample.ContextSwitchesPerSec, 1)
+	assert.InDelta(t, 500, *sample.InterruptsPerSec, 1)
+	assert.InDelta(t, 50, *sample.SoftirqNetRXPerSec, 0.1)
+	assert.InDelta(t, 20, *sample.SoftirqTimerPerSec, 0.1)
+	assert.InDelta(t, 0, *sample.SoftirqHIPerSec, 0.1)
+	assert.InDelta(t, 70, *sample.SoftirqsPerSec, 0.1)
+	assert.Nil(t, sample.SoftirqRCUPerSec)
+}
+
+func TestKernelActivityMonitor_Sample_ResetCounters(t *testing.T) {
+	now := time.Now()
+	previous := &kernelCounters{contextSwitches: 100, interrupts: 100, softirqs: map[string]uint64{"NET_RX": 50}, time: now}
+	current := &kernelCounters{contextSwitches: 10, interrupts: 200, softirqs: map[string]uint64{"NET_RX": 10}, time: now.Add(time.Second)}
+
+	sample := newKernelActivitySample(previous, current)
+
+	require.NotNil(t, sample)
+	assert.Nil(t, sample.ContextSwitchesPerSec)
+	assert.Equal(t, 100.0, *sample.InterruptsPerSec)
+	assert.Nil(t, sample.SoftirqNetRXPerSec)
+	assert.Nil(t, sample.SoftirqsPerSec)
+}
@@ -0,0 +1,14 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+// +build !linux
+
+package metrics
+
+func kernelActivityProcDir() string {
+	return ""
+}
+
+// Sample always returns nil, as the kernel activity counters are only reported by Linux.
+func (m *KernelActivityMonitor) Sample() (*KernelActivitySample, error) {
+	return nil, nil
+}
@@ -6,7 +6,29 @@ type LoadSample struct {
 	LoadOne     float64 `json:"loadAverageOneMinute"`
 	LoadFive    float64 `json:"loadAverageFiveMinute"`
 	LoadFifteen float64 `json:"loadAverageFifteenMinute"`
+	// Load averages divided by the number of CPUs, so the same thresholds apply to hosts of any size
+	LoadOnePerCPU     *float64 `json:"loadAverageOneMinutePerCpu,omitempty"`
+	LoadFivePerCPU    *float64 `json:"loadAverageFiveMinutePerCpu,omitempty"`
+	LoadFifteenPerCPU *float64 `json:"loadAverageFifteenMinutePerCpu,omitempty"`
 }
 
 type LoadMonitor struct {
+	// cpuCount returns the number of CPUs of the host
+	cpuCount func() (int, error)
+}
+
+// normalize sets the per CPU load averages of the sample. They are left unset if the CPUs can't be counted.
+func (self *LoadMonitor) normalize(sample *LoadSample) {
+	if self.cpuCount == nil {
+		return
+	}
+	cpus, err := self.cpuCount()
+	if err != nil || cpus <= 0 {
+		syslog.WithError(err).WithField("cpus", cpus).Debug("Unable to count the CPUs to normalize the load average.")
+		return
+	}
+	one := sample.LoadOne / float64(cpus)
+	five := sample.LoadFive / float64(cpus)
+	fifteen := sample.LoadFifteen / float64(cpus)
+	sample.LoadOnePerCPU, sample.LoadFivePerCPU, sample.LoadFifteenPerCPU = &one, &five, &fifteen
 }
@@ -0,0 +1,20 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package metrics
+
+import (
+	"io/ioutil"
+
+	"github.com/newrelic/infrastructure-agent/pkg/helpers"
+)
+
+// onlineCPUs counts the online CPUs of the host. Unlike runtime.NumCPU, it isn't restricted to the CPUs
+// the agent can run on, e.g. by the cpuset of its container, as the load average is host wide.
+func onlineCPUs() (int, error) {
+	content, err := ioutil.ReadFile(helpers.HostSys("devices", "system", "cpu", "online"))
+	if err != nil {
+		return 0, err
+	}
+	cpus, err := parseCPUList(string(content))
+	return len(cpus), err
+}
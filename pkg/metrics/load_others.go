@@ -0,0 +1,11 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+// +build !linux
+
+package metrics
+
+import "runtime"
+
+func onlineCPUs() (int, error) {
+	return runtime.NumCPU(), nil
+}
@@ -0,0 +1,42 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package metrics
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadMonitor_Normalize(t *testing.T) {
+	m := &LoadMonitor{cpuCount: func() (int, error) { return 4, nil }}
+	sample := &LoadSample{LoadOne: 6, LoadFive: 2, LoadFifteen: 1}
+
+	m.normalize(sample)
+
+	require.NotNil(t, sample.LoadOnePerCPU)
+	assert.Equal(t, 1.5, *sample.LoadOnePerCPU)
+	assert.Equal(t, 0.5, *sample.LoadFivePerCPU)
+	assert.Equal(t, 0.25, *sample.LoadFifteenPerCPU)
+}
+
+func TestLoadMonitor_Normalize_UnknownCPUs(t *testing.T) {
+	m := &LoadMonitor{cpuCount: func() (int, error) { return 0, errors.New("no sysfs") }}
+	sample := &LoadSample{LoadOne: 6, LoadFive: 2, LoadFifteen: 1}
+
+	m.normalize(sample)
+
+	assert.Nil(t, sample.LoadOnePerCPU)
+	assert.Nil(t, sample.LoadFivePerCPU)
+	assert.Nil(t, sample.LoadFifteenPerCPU)
+}
+
+func TestLoadMonitor_Sample(t *testing.T) {
+	sample, err := NewLoadMonitor().Sample()
+
+	require.NoError(t, err)
+	require.NotNil(t, sample.LoadOnePerCPU)
+	assert.True(t, *sample.LoadOnePerCPU <= sample.LoadOne)
+}
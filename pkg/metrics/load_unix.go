@@ -12,7 +12,7 @@ import (
 )
 
 func NewLoadMonitor() *LoadMonitor {
-	return &LoadMonitor{}
+	return &LoadMonitor{cpuCount: onlineCPUs}
 }
 
 func (self *LoadMonitor) Sample() (sample *LoadSample, err error) {
@@ -27,10 +27,11 @@ func (self *LoadMonitor) Sample() (sample *LoadSample, err error) {
 		return nil, err
 	}
 
-	return &LoadSample{
+	sample = &LoadSample{
 		LoadOne:     load.Load1,
 		LoadFive:    load.Load5,
 		LoadFifteen: load.Load15,
-	}, nil
-
+	}
+	self.normalize(sample)
+	return sample, nil
 }
@@ -44,7 +44,7 @@ func calcAllLoadsLoop() {
 
 func NewLoadMonitor() *LoadMonitor {
 	go calcAllLoadsLoop()
-	return &LoadMonitor{}
+	return &LoadMonitor{cpuCount: onlineCPUs}
 }
 
 func (self *LoadMonitor) Sample() (sample *LoadSample, err error) {
@@ -57,11 +57,13 @@ func (self *LoadMonitor) Sample() (sample *LoadSample, err error) {
 	one := loadFloor(float64(loadOne) / DIV)
 	five := loadFloor(float64(loadFive) / DIV)
 	fifteen := loadFloor(float64(loadFifteen) / DIV)
-	return &LoadSample{
+	sample = &LoadSample{
 		LoadOne:     one,
 		LoadFive:    five,
 		LoadFifteen: fifteen,
-	}, nil
+	}
+	self.normalize(sample)
+	return sample, nil
 }
 
 func loadFloor(v float64) float64 {
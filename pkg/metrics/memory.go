@@ -19,10 +19,14 @@ type MemorySample struct {
 	SwapTotal float64 `json:"swapTotalBytes"`
 	SwapFree  float64 `json:"swapFreeBytes"`
 	SwapUsed  float64 `json:"swapUsedBytes"`
+
+	*HugePagesSample
 }
 
 type MemoryMonitor struct {
 	vmHarvest func() (*mem.VirtualMemoryStat, error)
+	// hugePages is only set on the platforms reporting huge pages
+	hugePages func() (*HugePagesSample, error)
 }
 
 func (mm *MemoryMonitor) Sample() (result *MemorySample, err error) {
@@ -49,7 +53,7 @@ func (mm *MemoryMonitor) Sample() (result *MemorySample, err error) {
 		memoryUsedPercent = 100.0 - memoryFreePercent
 	}
 
-	return &MemorySample{
+	result = &MemorySample{
 		MemoryTotal:       float64(memory.Total),
 		MemoryFree:        float64(memory.Available),
 		MemoryUsed:        float64(memory.Used),
@@ -59,5 +63,15 @@ func (mm *MemoryMonitor) Sample() (result *MemorySample, err error) {
 		SwapTotal: float64(swap.Total),
 		SwapUsed:  float64(swap.Used),
 		SwapFree:  float64(swap.Free),
-	}, nil
+	}
+
+	if mm.hugePages != nil {
+		// huge pages are optional, so failing to read them doesn't discard the memory sample
+		if hugePages, err := mm.hugePages(); err != nil {
+			syslog.WithError(err).Debug("Unable to sample huge pages.")
+		} else {
+			result.HugePagesSample = hugePages
+		}
+	}
+	return result, nil
 }
@@ -18,7 +18,7 @@ import (
 // If consistentMemory is false, it reports the free memory as the Available Memory, dependant on the current kernel
 // or library implementations.
 func NewMemoryMonitor(ignoreReclaimable bool) *MemoryMonitor {
-	mm := &MemoryMonitor{}
+	mm := &MemoryMonitor{hugePages: newHugePagesMonitor().sample}
 	if ignoreReclaimable {
 		mm.vmHarvest = reclaimableAsFree
 	} else {
@@ -0,0 +1,304 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+// +build windows
+
+package network
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"syscall"
+	"unsafe"
+)
+
+// etwSessionName identifies the real-time trace session of the agent. A session left behind by a
+// previous agent process is stopped before starting a new one.
+const etwSessionName = "NewRelic-Infrastructure-Network"
+
+// https://docs.microsoft.com/en-us/windows/win32/etw/nt-kernel-logger-constants
+const (
+	wnodeFlagTracedGUID            = 0x00020000
+	eventTraceRealTimeMode         = 0x00000100
+	eventTraceControlStop          = 1
+	eventControlCodeEnableProvider = 1
+	traceLevelInformation          = 4
+	processTraceModeRealTime       = 0x00000100
+	processTraceModeEventRecord    = 0x10000000
+	// keywords of the IPv4 and IPv6 events
+	kernelNetworkKeywords = 0x10 | 0x20
+
+	errorAlreadyExists = 183
+	errorCancelled     = 1223
+
+	processQueryLimitedInformation = 0x1000
+)
+
+var invalidProcessTraceHandle = ^uint64(0)
+
+// Microsoft-Windows-Kernel-Network provider: {7DD42A49-5329-4832-8DFD-43D979153A88}
+var kernelNetworkProvider = syscall.GUID{
+	Data1: 0x7dd42a49,
+	Data2: 0x5329,
+	Data3: 0x4832,
+	Data4: [8]byte{0x8d, 0xfd, 0x43, 0xd9, 0x79, 0x15, 0x3a, 0x88},
+}
+
+var (
+	modAdvapi32        = syscall.NewLazyDLL("advapi32.dll")
+	procStartTraceW    = modAdvapi32.NewProc("StartTraceW")
+	procControlTraceW  = modAdvapi32.NewProc("ControlTraceW")
+	procEnableTraceEx2 = modAdvapi32.NewProc("EnableTraceEx2")
+	procOpenTraceW     = modAdvapi32.NewProc("OpenTraceW")
+	procProcessTrace   = modAdvapi32.NewProc("ProcessTrace")
+	procCloseTrace     = modAdvapi32.NewProc("CloseTrace")
+
+	modKernel32                   = syscall.NewLazyDLL("kernel32.dll")
+	procQueryFullProcessImageName = modKernel32.NewProc("QueryFullProcessImageNameW")
+)
+
+// https://docs.microsoft.com/en-us/windows/win32/etw/wnode-header
+type wnodeHeader struct {
+	BufferSize        uint32
+	ProviderID        uint32
+	HistoricalContext uint64
+	TimeStamp         int64
+	GUID              syscall.GUID
+	ClientContext     uint32
+	Flags             uint32
+}
+
+// https://docs.microsoft.com/en-us/windows/win32/api/evntrace/ns-evntrace-event_trace_properties
+type eventTraceProperties struct {
+	Wnode               wnodeHeader
+	BufferSize          uint32
+	MinimumBuffers      uint32
+	MaximumBuffers      uint32
+	MaximumFileSize     uint32
+	LogFileMode         uint32
+	FlushTimer          uint32
+	EnableFlags         uint32
+	AgeLimit            int32
+	NumberOfBuffers     uint32
+	FreeBuffers         uint32
+	EventsLost          uint32
+	BuffersWritten      uint32
+	LogBuffersLost      uint32
+	RealTimeBuffersLost uint32
+	LoggerThreadID      syscall.Handle
+	LogFileNameOffset   uint32
+	LoggerNameOffset    uint32
+}
+
+// https://docs.microsoft.com/en-us/windows/win32/api/evntrace/ns-evntrace-event_trace_header
+type eventTraceHeader struct {
+	Size           uint16
+	FieldTypeFlags uint16
+	Version        uint32
+	ThreadID       uint32
+	ProcessID      uint32
+	TimeStamp      int64
+	GUID           syscall.GUID
+	ProcessorTime  uint64
+}
+
+// https://docs.microsoft.com/en-us/windows/win32/api/evntrace/ns-evntrace-event_trace
+type eventTrace struct {
+	Header           eventTraceHeader
+	InstanceID       uint32
+	ParentInstanceID uint32
+	ParentGUID       syscall.GUID
+	MofData          uintptr
+	MofLength        uint32
+	BufferContext    uint32
+}
+
+// https://docs.microsoft.com/en-us/windows/win32/api/timezoneapi/ns-timezoneapi-time_zone_information
+type timeZoneInformation struct {
+	Bias         int32
+	StandardName [32]uint16
+	StandardDate syscall.Systemtime
+	StandardBias int32
+	DaylightName [32]uint16
+	DaylightDate syscall.Systemtime
+	DaylightBias int32
+}
+
+// https://docs.microsoft.com/en-us/windows/win32/api/evntrace/ns-evntrace-trace_logfile_header
+type traceLogfileHeader struct {
+	BufferSize         uint32
+	Version            uint32
+	ProviderVersion    uint32
+	NumberOfProcessors uint32
+	EndTime            int64
+	TimerResolution    uint32
+	MaximumFileSize    uint32
+	LogFileMode        uint32
+	BuffersWritten     uint32
+	LogInstanceGUID    syscall.GUID
+	LoggerName         uintptr
+	LogFileName        uintptr
+	TimeZone           timeZoneInformation
+	BootTime           int64
+	PerfFreq           int64
+	StartTime          int64
+	ReservedFlags      uint32
+	BuffersLost        uint32
+}
+
+// https://docs.microsoft.com/en-us/windows/win32/api/evntrace/ns-evntrace-event_trace_logfilew
+type eventTraceLogfile struct {
+	LogFileName         uintptr
+	LoggerName          uintptr
+	CurrentTime         int64
+	BuffersRead         uint32
+	ProcessTraceMode    uint32
+	CurrentEvent        eventTrace
+	LogfileHeader       traceLogfileHeader
+	BufferCallback      uintptr
+	BufferSize          uint32
+	Filled              uint32
+	EventsLost          uint32
+	EventRecordCallback uintptr
+	IsKernelTrace       uint32
+	Context             uintptr
+}
+
+// https://docs.microsoft.com/en-us/windows/win32/api/evntcons/ns-evntcons-event_record
+type eventRecord struct {
+	Size              uint16
+	HeaderType        uint16
+	Flags             uint16
+	EventProperty     uint16
+	ThreadID          uint32
+	ProcessID         uint32
+	TimeStamp         int64
+	ProviderID        syscall.GUID
+	EventID           uint16
+	Version           uint8
+	Channel           uint8
+	Level             uint8
+	Opcode            uint8
+	Task              uint16
+	Keyword           uint64
+	ProcessorTime     uint64
+	ActivityID        syscall.GUID
+	BufferContext     uint32
+	ExtendedDataCount uint16
+	UserDataLength    uint16
+	ExtendedData      uintptr
+	UserData          unsafe.Pointer
+	UserContext       uintptr
+}
+
+// etwTraffic receives the events of the trace session. Callbacks can't be released, so a single one
+// is created for the agent process, and a single session can be running.
+var (
+	etwTraffic  *processTraffic
+	etwCallback = syscall.NewCallback(func(record *eventRecord) uintptr {
+		if traffic := etwTraffic; traffic != nil && record.UserDataLength > 0 {
+			payload := (*[1 << 16]byte)(record.UserData)[:record.UserDataLength:record.UserDataLength]
+			traffic.add(record.EventID, payload)
+		}
+		return 0
+	})
+)
+
+// etwSession is a real-time trace session of the kernel network events.
+type etwSession struct {
+	handle      uint64
+	traceHandle uint64
+	name        []uint16
+}
+
+// newSessionProperties returns the properties of the trace session, followed by the room for its name.
+func newSessionProperties(name []uint16) *eventTraceProperties {
+	size := unsafe.Sizeof(eventTraceProperties{})
+	buffer := make([]byte, int(size)+len(name)*2)
+	props := (*eventTraceProperties)(unsafe.Pointer(&buffer[0]))
+	props.Wnode.BufferSize = uint32(len(buffer))
+	props.Wnode.Flags = wnodeFlagTracedGUID
+	// query performance counter timestamps
+	props.Wnode.ClientContext = 1
+	props.LogFileMode = eventTraceRealTimeMode
+	props.LoggerNameOffset = uint32(size)
+	return props
+}
+
+// startETWSession starts tracing the network traffic of the processes into the passed accumulator. The
+// events are consumed in the background until the session is stopped.
+func startETWSession(traffic *processTraffic) (*etwSession, error) {
+	// trace handles and keywords are 64 bits arguments, which the 32 bits calling convention splits
+	if unsafe.Sizeof(uintptr(0)) != 8 {
+		return nil, errors.New("tracing the process network traffic requires a 64 bits agent")
+	}
+	name, err := syscall.UTF16FromString(etwSessionName)
+	if err != nil {
+		return nil, err
+	}
+	s := &etwSession{name: name}
+
+	r1, _, _ := procStartTraceW.Call(uintptr(unsafe.Pointer(&s.handle)), uintptr(unsafe.Pointer(&name[0])), uintptr(unsafe.Pointer(newSessionProperties(name))))
+	if r1 == errorAlreadyExists {
+		s.stop()
+		r1, _, _ = procStartTraceW.Call(uintptr(unsafe.Pointer(&s.handle)), uintptr(unsafe.Pointer(&name[0])), uintptr(unsafe.Pointer(newSessionProperties(name))))
+	}
+	if r1 != 0 {
+		return nil, fmt.Errorf("StartTrace: %v", syscall.Errno(r1))
+	}
+
+	r1, _, _ = procEnableTraceEx2.Call(uintptr(s.handle), uintptr(unsafe.Pointer(&kernelNetworkProvider)), eventControlCodeEnableProvider,
+		traceLevelInformation, kernelNetworkKeywords, 0, 0, 0)
+	if r1 != 0 {
+		s.stop()
+		return nil, fmt.Errorf("EnableTraceEx2: %v", syscall.Errno(r1))
+	}
+
+	logfile := eventTraceLogfile{
+		LoggerName:          uintptr(unsafe.Pointer(&name[0])),
+		ProcessTraceMode:    processTraceModeRealTime | processTraceModeEventRecord,
+		EventRecordCallback: etwCallback,
+	}
+	r1, _, _ = procOpenTraceW.Call(uintptr(unsafe.Pointer(&logfile)))
+	if uint64(r1) == invalidProcessTraceHandle {
+		s.stop()
+		return nil, fmt.Errorf("OpenTrace: %v", syscall.GetLastError())
+	}
+	s.traceHandle = uint64(r1)
+
+	etwTraffic = traffic
+	go func() {
+		// ProcessTrace blocks until the session is stopped
+		r1, _, _ := procProcessTrace.Call(uintptr(unsafe.Pointer(&s.traceHandle)), 1, 0, 0)
+		if r1 != 0 && r1 != errorCancelled {
+			nslog.WithError(syscall.Errno(r1)).Warn("Stopped tracing the process network traffic.")
+		}
+	}()
+	return s, nil
+}
+
+// stop stops the trace session, releasing its buffers.
+func (s *etwSession) stop() {
+	if s.traceHandle != 0 {
+		_, _, _ = procCloseTrace.Call(uintptr(s.traceHandle))
+		s.traceHandle = 0
+	}
+	_, _, _ = procControlTraceW.Call(0, uintptr(unsafe.Pointer(&s.name[0])), uintptr(unsafe.Pointer(newSessionProperties(s.name))), eventTraceControlStop)
+}
+
+// processName returns the executable name of a process, or an empty string if it can't be queried.
+func processName(pid uint32) string {
+	handle, err := syscall.OpenProcess(processQueryLimitedInformation, false, pid)
+	if err != nil {
+		return ""
+	}
+	defer syscall.CloseHandle(handle)
+
+	size := uint32(syscall.MAX_PATH)
+	buffer := make([]uint16, size)
+	r1, _, _ := procQueryFullProcessImageName.Call(uintptr(handle), 0, uintptr(unsafe.Pointer(&buffer[0])), uintptr(unsafe.Pointer(&size)))
+	if r1 == 0 {
+		return ""
+	}
+	return filepath.Base(syscall.UTF16ToString(buffer[:size]))
+}
@@ -37,6 +37,8 @@ type NetworkSample struct {
 	ReceivePacketsPerSec *float64 `json:"receivePacketsPerSecond,omitempty"`
 	ReceiveErrorsPerSec  *float64 `json:"receiveErrorsPerSecond,omitempty"`
 	ReceiveDroppedPerSec *float64 `json:"receiveDroppedPerSecond,omitempty"`
+	// Packets discarded because of an unknown or unsupported protocol, only reported by Windows hosts
+	ReceiveUnknownProtocolsPerSec *float64 `json:"receiveUnknownProtocolsPerSecond,omitempty"`
 
 	TransmitBytesPerSec   *float64 `json:"transmitBytesPerSecond,omitempty"`
 	TransmitPacketsPerSec *float64 `json:"transmitPacketsPerSecond,omitempty"`
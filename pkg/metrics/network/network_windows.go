@@ -17,6 +17,7 @@ import (
 	"sync"
 	"syscall"
 	"time"
+	"unsafe"
 
 	"github.com/newrelic/infrastructure-agent/pkg/sample"
 	gopsnet "github.com/shirou/gopsutil/net"
@@ -26,6 +27,56 @@ import (
 	"github.com/newrelic/infrastructure-agent/pkg/helpers"
 )
 
+var (
+	modIphlpapi     = syscall.NewLazyDLL("iphlpapi.dll")
+	procGetIfEntry2 = modIphlpapi.NewProc("GetIfEntry2")
+)
+
+// https://docs.microsoft.com/en-us/windows/win32/api/netioapi/ns-netioapi-mib_if_row2
+type mibIfRow2 struct {
+	InterfaceLUID               uint64
+	InterfaceIndex              uint32
+	InterfaceGUID               syscall.GUID
+	Alias                       [257]uint16
+	Description                 [257]uint16
+	PhysicalAddressLength       uint32
+	PhysicalAddress             [32]byte
+	PermanentPhysicalAddress    [32]byte
+	MTU                         uint32
+	Type                        uint32
+	TunnelType                  uint32
+	MediaType                   uint32
+	PhysicalMediumType          uint32
+	AccessType                  uint32
+	DirectionType               uint32
+	InterfaceAndOperStatusFlags uint8
+	OperStatus                  uint32
+	AdminStatus                 uint32
+	MediaConnectState           uint32
+	NetworkGUID                 syscall.GUID
+	ConnectionType              uint32
+	TransmitLinkSpeed           uint64
+	ReceiveLinkSpeed            uint64
+	InOctets                    uint64
+	InUcastPkts                 uint64
+	InNUcastPkts                uint64
+	InDiscards                  uint64
+	InErrors                    uint64
+	InUnknownProtos             uint64
+	InUcastOctets               uint64
+	InMulticastOctets           uint64
+	InBroadcastOctets           uint64
+	OutOctets                   uint64
+	OutUcastPkts                uint64
+	OutNUcastPkts               uint64
+	OutDiscards                 uint64
+	OutErrors                   uint64
+	OutUcastOctets              uint64
+	OutMulticastOctets          uint64
+	OutBroadcastOctets          uint64
+	OutQLen                     uint64
+}
+
 type NetworkSampler struct {
 	context         agent.AgentContext
 	lastRun         time.Time
@@ -34,6 +85,10 @@ type NetworkSampler struct {
 	stopChannel     chan bool
 	waitForCleanup  *sync.WaitGroup
 	sampleInterval  time.Duration
+	// traffic of the processes, traced since the previous sample, if enable_process_network_metrics is set
+	processTraffic *processTraffic
+	etwSession     *etwSession
+	etwFailed      bool
 }
 
 func (ss *NetworkSampler) Sample() (results sample.EventBatch, err error) {
@@ -101,6 +156,10 @@ func (ss *NetworkSampler) Sample() (results sample.EventBatch, err error) {
 		results = append(results, sample)
 	}
 
+	if cfg != nil && cfg.EnableProcessNetworkMetrics {
+		results = append(results, ss.processSamples(elapsedSeconds)...)
+	}
+
 	ioCounters, err := IOCountersForInterface(niList)
 	if err != nil {
 		return nil, err
@@ -131,6 +190,11 @@ func (ss *NetworkSampler) Sample() (results sample.EventBatch, err error) {
 				dropSent := acquire.CalculateSafeDelta(counter.Dropout, lastStats.Dropout, elapsedSeconds)
 				dropRecv := acquire.CalculateSafeDelta(counter.Dropin, lastStats.Dropin, elapsedSeconds)
 
+				if counter.UnknownProtosIn != nil && lastStats.UnknownProtosIn != nil {
+					unknownRecv := acquire.CalculateSafeDelta(*counter.UnknownProtosIn, *lastStats.UnknownProtosIn, elapsedSeconds)
+					sample.ReceiveUnknownProtocolsPerSec = &unknownRecv
+				}
+
 				sample.TransmitBytesPerSec = &bytesSent
 				sample.TransmitPacketsPerSec = &packetsSent
 				sample.TransmitErrorsPerSec = &errSent
@@ -148,12 +212,38 @@ func (ss *NetworkSampler) Sample() (results sample.EventBatch, err error) {
 
 	if ss.Debug() {
 		for _, sample := range results {
-			helpers.LogStructureDetails(nslog, sample.(*NetworkSample), "NetworkSample", "final", nil)
+			if networkSample, ok := sample.(*NetworkSample); ok {
+				helpers.LogStructureDetails(nslog, networkSample, "NetworkSample", "final", nil)
+			}
 		}
 	}
 	return results, nil
 }
 
+// processSamples returns the network traffic of the processes since the previous sample. Tracing starts
+// on the first invocation, and isn't retried if it fails.
+func (ss *NetworkSampler) processSamples(elapsedSeconds float64) []sample.Event {
+	if ss.etwFailed {
+		return nil
+	}
+	if ss.etwSession == nil {
+		ss.processTraffic = newProcessTraffic()
+		session, err := startETWSession(ss.processTraffic)
+		if err != nil {
+			nslog.WithError(err).Warn("Can't trace the process network traffic, ProcessNetworkSamples won't be reported.")
+			ss.etwFailed = true
+			return nil
+		}
+		ss.etwSession = session
+		return nil
+	}
+	var results []sample.Event
+	for _, s := range ss.processTraffic.samples(elapsedSeconds, processName) {
+		results = append(results, s)
+	}
+	return results
+}
+
 type IOCountersWithIndexStat struct {
 	Name        string `json:"name"`        // interface name
 	BytesSent   uint64 `json:"bytesSent"`   // number of bytes sent
@@ -167,6 +257,8 @@ type IOCountersWithIndexStat struct {
 	Fifoin      uint64 `json:"fifoin"`      // total number of FIFO buffers errors while receiving
 	Fifoout     uint64 `json:"fifoout"`     // total number of FIFO buffers errors while sending
 	Index       uint32 `json:"index"`
+	// packets received and discarded because of an unknown or unsupported protocol, nil if not reported
+	UnknownProtosIn *uint64 `json:"unknownprotosin,omitempty"`
 }
 
 type InterfaceWithIndexStat struct {
@@ -251,6 +343,25 @@ func IOCountersForInterface(ifs []InterfaceWithIndexStat) ([]IOCountersWithIndex
 			Index: ifi.Index,
 		}
 
+		// the 64 bits counters don't wrap around, and include the non-unicast packets and the packets of
+		// unknown protocols
+		if unsafe.Sizeof(uintptr(0)) == 8 {
+			row2 := mibIfRow2{InterfaceIndex: ifi.Index}
+			if r1, _, _ := procGetIfEntry2.Call(uintptr(unsafe.Pointer(&row2))); r1 == 0 {
+				c.BytesSent = row2.OutOctets
+				c.BytesRecv = row2.InOctets
+				c.PacketsSent = row2.OutUcastPkts + row2.OutNUcastPkts
+				c.PacketsRecv = row2.InUcastPkts + row2.InNUcastPkts
+				c.Errin = row2.InErrors
+				c.Errout = row2.OutErrors
+				c.Dropin = row2.InDiscards
+				c.Dropout = row2.OutDiscards
+				c.UnknownProtosIn = &row2.InUnknownProtos
+				ret = append(ret, c)
+				continue
+			}
+		}
+
 		row := syscall.MibIfRow{Index: ifi.Index}
 		e := syscall.GetIfEntry(&row)
 		if e != nil {
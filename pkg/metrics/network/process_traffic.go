@@ -0,0 +1,101 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package network
+
+import (
+	"encoding/binary"
+	"sort"
+	"sync"
+
+	"github.com/newrelic/infrastructure-agent/pkg/sample"
+)
+
+// Event IDs of the Microsoft-Windows-Kernel-Network ETW provider for the TCP and UDP data sent and received
+// by a process. Their payloads start with the process ID and the size of the data, as 32 bits integers.
+const (
+	etwTCPSendIPv4 = 10
+	etwTCPRecvIPv4 = 11
+	etwTCPSendIPv6 = 26
+	etwTCPRecvIPv6 = 27
+	etwUDPSendIPv4 = 42
+	etwUDPRecvIPv4 = 43
+	etwUDPSendIPv6 = 58
+	etwUDPRecvIPv6 = 59
+)
+
+// ProcessNetworkSample holds the network throughput of a process.
+type ProcessNetworkSample struct {
+	sample.BaseEvent
+
+	ProcessID   uint32 `json:"processId"`
+	ProcessName string `json:"processDisplayName,omitempty"`
+
+	ReceiveBytesPerSec  float64 `json:"receiveBytesPerSecond"`
+	TransmitBytesPerSec float64 `json:"transmitBytesPerSecond"`
+}
+
+// processTraffic accumulates the bytes sent and received by each process, as traced by the kernel
+// network events, until they are taken by the sampler.
+type processTraffic struct {
+	lock     sync.Mutex
+	sent     map[uint32]uint64
+	received map[uint32]uint64
+}
+
+func newProcessTraffic() *processTraffic {
+	return &processTraffic{sent: map[uint32]uint64{}, received: map[uint32]uint64{}}
+}
+
+// add accounts the data of a kernel network event. Other events, or truncated payloads, are ignored.
+func (t *processTraffic) add(eventID uint16, payload []byte) {
+	if len(payload) < 8 {
+		return
+	}
+	pid := binary.LittleEndian.Uint32(payload[0:4])
+	size := uint64(binary.LittleEndian.Uint32(payload[4:8]))
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	switch eventID {
+	case etwTCPSendIPv4, etwTCPSendIPv6, etwUDPSendIPv4, etwUDPSendIPv6:
+		t.sent[pid] += size
+	case etwTCPRecvIPv4, etwTCPRecvIPv6, etwUDPRecvIPv4, etwUDPRecvIPv6:
+		t.received[pid] += size
+	}
+}
+
+// samples returns a sample per process with traffic since the previous invocation, sorted by process ID,
+// and resets the accumulated traffic.
+func (t *processTraffic) samples(elapsedSeconds float64, processName func(pid uint32) string) []*ProcessNetworkSample {
+	t.lock.Lock()
+	sent, received := t.sent, t.received
+	t.sent, t.received = map[uint32]uint64{}, map[uint32]uint64{}
+	t.lock.Unlock()
+
+	if elapsedSeconds <= 0 {
+		return nil
+	}
+	pids := make([]uint32, 0, len(sent)+len(received))
+	for pid := range sent {
+		pids = append(pids, pid)
+	}
+	for pid := range received {
+		if _, ok := sent[pid]; !ok {
+			pids = append(pids, pid)
+		}
+	}
+	sort.Slice(pids, func(i, j int) bool { return pids[i] < pids[j] })
+
+	samples := make([]*ProcessNetworkSample, 0, len(pids))
+	for _, pid := range pids {
+		s := &ProcessNetworkSample{
+			ProcessID:           pid,
+			ProcessName:         processName(pid),
+			ReceiveBytesPerSec:  float64(received[pid]) / elapsedSeconds,
+			TransmitBytesPerSec: float64(sent[pid]) / elapsedSeconds,
+		}
+		s.Type("ProcessNetworkSample")
+		samples = append(samples, s)
+	}
+	return samples
+}
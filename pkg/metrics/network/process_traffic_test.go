@@ -0,0 +1,56 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package network
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// kernelNetworkPayload returns the start of the payload of a Microsoft-Windows-Kernel-Network event.
+func kernelNetworkPayload(pid, size uint32) []byte {
+	payload := make([]byte, 20)
+	binary.LittleEndian.PutUint32(payload[0:4], pid)
+	binary.LittleEndian.PutUint32(payload[4:8], size)
+	return payload
+}
+
+func TestProcessTraffic(t *testing.T) {
+	// GIVEN the TCP and UDP traffic of two processes, over IPv4 and IPv6
+	traffic := newProcessTraffic()
+	traffic.add(etwTCPSendIPv4, kernelNetworkPayload(200, 1000))
+	traffic.add(etwTCPSendIPv6, kernelNetworkPayload(200, 500))
+	traffic.add(etwUDPRecvIPv4, kernelNetworkPayload(200, 300))
+	traffic.add(etwTCPRecvIPv6, kernelNetworkPayload(100, 2000))
+	// AND events of other kinds, or truncated
+	traffic.add(12, kernelNetworkPayload(300, 5000))
+	traffic.add(etwTCPSendIPv4, []byte{1, 2, 3})
+
+	// WHEN the samples are taken
+	names := map[uint32]string{100: "chrome.exe", 200: "sqlservr.exe"}
+	samples := traffic.samples(10, func(pid uint32) string { return names[pid] })
+
+	// THEN the throughput of each process is reported
+	require.Len(t, samples, 2)
+	assert.Equal(t, "ProcessNetworkSample", samples[0].EventType)
+	assert.Equal(t, uint32(100), samples[0].ProcessID)
+	assert.Equal(t, "chrome.exe", samples[0].ProcessName)
+	assert.Equal(t, 200.0, samples[0].ReceiveBytesPerSec)
+	assert.Equal(t, 0.0, samples[0].TransmitBytesPerSec)
+	assert.Equal(t, uint32(200), samples[1].ProcessID)
+	assert.Equal(t, 30.0, samples[1].ReceiveBytesPerSec)
+	assert.Equal(t, 150.0, samples[1].TransmitBytesPerSec)
+
+	// AND the traffic is reset
+	assert.Empty(t, traffic.samples(10, func(uint32) string { return "" }))
+}
+
+func TestProcessTraffic_NoElapsedTime(t *testing.T) {
+	traffic := newProcessTraffic()
+	traffic.add(etwTCPSendIPv4, kernelNetworkPayload(200, 1000))
+
+	assert.Empty(t, traffic.samples(0, func(uint32) string { return "" }))
+}
@@ -0,0 +1,117 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package ntp
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Fields of the "chronyc -c tracking" CSV output.
+const (
+	trackingRefID          = 0
+	trackingStratum        = 2
+	trackingRootDelay      = 10
+	trackingRootDispersion = 11
+	trackingLeapStatus     = 13
+	trackingFields         = 14
+)
+
+// Fields of the "chronyc -c sources" CSV output.
+const (
+	sourcesState   = 1
+	sourcesAddress = 2
+	sourcesReach   = 5
+	sourcesFields  = 10
+)
+
+// chronySample queries chronyd through the chronyc client.
+func chronySample() (*Sample, error) {
+	output, err := runCommand("chronyc", "-c", "-n", "tracking")
+	if err != nil {
+		return nil, fmt.Errorf("running chronyc tracking: %v", err)
+	}
+	s, err := parseChronyTracking(output)
+	if err != nil {
+		return nil, err
+	}
+
+	if output, err = runCommand("chronyc", "-c", "-n", "sources"); err != nil {
+		nlog.WithError(err).Debug("Running chronyc sources.")
+	} else if sources, err := parseChronySources(output); err != nil {
+		nlog.WithError(err).Debug("Parsing chronyc sources.")
+	} else {
+		s.setSources(sources)
+	}
+
+	// listing the clients requires access to the chronyd command socket, usually restricted to root
+	if output, err = runCommand("chronyc", "-c", "-n", "clients"); err != nil {
+		nlog.WithError(err).Debug("Running chronyc clients.")
+	} else {
+		clients := len(nonEmptyLines(output))
+		s.ClientCount = &clients
+	}
+
+	return s, nil
+}
+
+// parseChronyTracking parses the output of "chronyc -c tracking", e.g.:
+// A29FC87B,162.159.200.123,4,1611923766.527066445,-0.000006785,-0.000009637,0.000031106,-11.407,-0.001,0.026,0.012603454,0.000797651,1030.0,Normal
+func parseChronyTracking(output string) (*Sample, error) {
+	lines := nonEmptyLines(output)
+	if len(lines) == 0 {
+		return nil, fmt.Errorf("empty chronyc tracking output")
+	}
+	fields := strings.Split(lines[0], ",")
+	if len(fields) < trackingFields {
+		return nil, fmt.Errorf("unexpected chronyc tracking output: %q", lines[0])
+	}
+
+	stratum, err := strconv.Atoi(fields[trackingStratum])
+	if err != nil {
+		return nil, fmt.Errorf("parsing stratum: %v", err)
+	}
+	rootDelay, err := strconv.ParseFloat(fields[trackingRootDelay], 64)
+	if err != nil {
+		return nil, fmt.Errorf("parsing root delay: %v", err)
+	}
+	rootDispersion, err := strconv.ParseFloat(fields[trackingRootDispersion], 64)
+	if err != nil {
+		return nil, fmt.Errorf("parsing root dispersion: %v", err)
+	}
+	refID := fields[trackingRefID]
+	leapStatus := fields[trackingLeapStatus]
+
+	return &Sample{
+		Daemon:                daemonChrony,
+		Stratum:               &stratum,
+		ReferenceID:           &refID,
+		RootDelaySeconds:      &rootDelay,
+		RootDispersionSeconds: &rootDispersion,
+		LeapStatus:            &leapStatus,
+	}, nil
+}
+
+// parseChronySources parses the output of "chronyc -c sources", e.g.:
+// ^,*,162.159.200.123,3,10,377,558,0.000029285,0.000028364,0.012604520
+// The reachability register is printed in octal.
+func parseChronySources(output string) ([]source, error) {
+	var sources []source
+	for _, line := range nonEmptyLines(output) {
+		fields := strings.Split(line, ",")
+		if len(fields) < sourcesFields {
+			return nil, fmt.Errorf("unexpected chronyc sources output: %q", line)
+		}
+		reach, err := strconv.ParseUint(fields[sourcesReach], 8, 8)
+		if err != nil {
+			return nil, fmt.Errorf("parsing reachability of %s: %v", fields[sourcesAddress], err)
+		}
+		sources = append(sources, source{
+			address:  fields[sourcesAddress],
+			selected: fields[sourcesState] == "*",
+			reach:    uint8(reach),
+		})
+	}
+	return sources, nil
+}
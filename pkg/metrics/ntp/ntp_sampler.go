@@ -0,0 +1,166 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+// Package ntp samples the status of the NTP daemon (chronyd or ntpd) of hosts acting as NTP servers,
+// by querying their command line clients.
+package ntp
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/newrelic/infrastructure-agent/internal/agent"
+	"github.com/newrelic/infrastructure-agent/pkg/config"
+	"github.com/newrelic/infrastructure-agent/pkg/log"
+	"github.com/newrelic/infrastructure-agent/pkg/sample"
+)
+
+const (
+	daemonChrony = "chronyd"
+	daemonNtpd   = "ntpd"
+
+	commandTimeout = 5 * time.Second
+)
+
+var nlog = log.WithComponent("NTPServerSampler")
+
+// runCommand executes an NTP client command, returning its standard output.
+var runCommand = func(name string, args ...string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), commandTimeout)
+	defer cancel()
+
+	output, err := exec.CommandContext(ctx, name, args...).Output()
+	return string(output), err
+}
+
+// lookPath is used to detect which NTP client is installed.
+var lookPath = exec.LookPath
+
+// Sample holds the server side status of the NTP daemon.
+type Sample struct {
+	sample.BaseEvent
+
+	// NTP daemon the data was retrieved from: chronyd or ntpd
+	Daemon string `json:"daemon"`
+	// Distance, in hops, to the reference clock
+	Stratum *int `json:"stratum,omitempty"`
+	// Identifier of the reference the server is synchronized to
+	ReferenceID *string `json:"referenceId,omitempty"`
+	// Total round-trip delay, in seconds, to the stratum-1 reference
+	RootDelaySeconds *float64 `json:"rootDelaySeconds,omitempty"`
+	// Total dispersion, in seconds, accumulated up to the stratum-1 reference
+	RootDispersionSeconds *float64 `json:"rootDispersionSeconds,omitempty"`
+	// Leap second status, e.g. "Normal"
+	LeapStatus *string `json:"leapStatus,omitempty"`
+	// Number of clients that recently queried the server. It may require the agent to run as root
+	ClientCount *int `json:"clientCount,omitempty"`
+	// Number of configured time sources
+	SourceCount *int `json:"sourceCount,omitempty"`
+	// Number of sources that replied to any of their last 8 polls
+	ReachableSourceCount *int `json:"reachableSourceCount,omitempty"`
+	// Percentage of successful polls, among the last 8 polls of every source
+	SourceReachabilityPercent *float64 `json:"sourceReachabilityPercent,omitempty"`
+	// Address of the source currently selected for synchronization
+	SelectedSource *string `json:"selectedSource,omitempty"`
+}
+
+// source is a time source as reported by the NTP client.
+type source struct {
+	address  string
+	selected bool
+	reach    uint8 // reachability register: a bit per each of the last 8 polls
+}
+
+// setSources sets the sources related fields of the sample.
+func (s *Sample) setSources(sources []source) {
+	count, reachable, polled := len(sources), 0, 0
+	for _, src := range sources {
+		if src.reach != 0 {
+			reachable++
+		}
+		for reach := src.reach; reach != 0; reach >>= 1 {
+			polled += int(reach & 1)
+		}
+		if src.selected {
+			address := src.address
+			s.SelectedSource = &address
+		}
+	}
+	s.SourceCount = &count
+	s.ReachableSourceCount = &reachable
+	if count > 0 {
+		percent := 100 * float64(polled) / float64(8*count)
+		s.SourceReachabilityPercent = &percent
+	}
+}
+
+// Sampler reports an NTPServerSample with the status of the host NTP daemon.
+type Sampler struct {
+	context    agent.AgentContext
+	sampleRate time.Duration
+}
+
+func NewSampler(context agent.AgentContext) *Sampler {
+	sampleRateSec := config.FREQ_DISABLE_SAMPLING
+	if context != nil {
+		sampleRateSec = context.Config().MetricsNTPServerSampleRate
+	}
+
+	return &Sampler{
+		context:    context,
+		sampleRate: time.Second * time.Duration(sampleRateSec),
+	}
+}
+
+func (s *Sampler) OnStartup() {}
+
+func (s *Sampler) Name() string {
+	return "NTPServerSampler"
+}
+
+func (s *Sampler) Interval() time.Duration {
+	return s.sampleRate
+}
+
+func (s *Sampler) Disabled() bool {
+	return s.Interval() <= config.FREQ_DISABLE_SAMPLING
+}
+
+func (s *Sampler) Sample() (eventBatch sample.EventBatch, err error) {
+	defer func() {
+		if panicErr := recover(); panicErr != nil {
+			err = fmt.Errorf("Panic in ntp.Sampler: %v\nStack: %s", panicErr, debug.Stack())
+		}
+	}()
+
+	var ss *Sample
+	if _, lookErr := lookPath("chronyc"); lookErr == nil {
+		ss, err = chronySample()
+	} else if _, lookErr = lookPath("ntpq"); lookErr == nil {
+		ss, err = ntpdSample()
+	} else {
+		nlog.Debug("Neither chronyc nor ntpq found. Not reporting NTP server status.")
+		return nil, nil
+	}
+	if err != nil {
+		nlog.WithError(err).Debug("Unable to retrieve NTP server status.")
+		return nil, nil
+	}
+
+	ss.Type("NTPServerSample")
+	return sample.EventBatch{ss}, nil
+}
+
+// nonEmptyLines splits a command output in lines, discarding the empty ones.
+func nonEmptyLines(output string) []string {
+	var lines []string
+	for _, line := range strings.Split(output, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
@@ -0,0 +1,142 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package ntp
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeCommands replaces the NTP client commands by the passed outputs, indexed by command line.
+// It returns a function restoring the original ones.
+func fakeCommands(client string, outputs map[string]string) (restore func()) {
+	runCommandFn, lookPathFn := runCommand, lookPath
+	runCommand = func(name string, args ...string) (string, error) {
+		output, ok := outputs[strings.Join(append([]string{name}, args...), " ")]
+		if !ok {
+			return "", errors.New("exit status 1")
+		}
+		return output, nil
+	}
+	lookPath = func(file string) (string, error) {
+		if file == client {
+			return "/usr/bin/" + file, nil
+		}
+		return "", errors.New("not found")
+	}
+	return func() {
+		runCommand, lookPath = runCommandFn, lookPathFn
+	}
+}
+
+func TestSampler_Chrony(t *testing.T) {
+	// GIVEN a chrony server with two sources and two clients
+	defer fakeCommands("chronyc", map[string]string{
+		"chronyc -c -n tracking": "A29FC87B,162.159.200.123,4,1611923766.527066445,-0.000006785,-0.000009637,0.000031106,-11.407,-0.001,0.026,0.012603454,0.000797651,1030.0,Normal\n",
+		"chronyc -c -n sources": "^,*,162.159.200.123,3,10,377,558,0.000029285,0.000028364,0.012604520\n" +
+			"^,?,10.0.0.1,0,6,0,-,0.000000000,0.000000000,0.000000000\n" +
+			"^,+,10.0.0.2,2,10,17,30,0.000029285,0.000028364,0.012604520\n",
+		"chronyc -c -n clients": "10.0.1.5,12,0,6,-,35,0,0,-,-\n10.0.1.6,3,0,6,-,120,0,0,-,-\n",
+	})()
+
+	// WHEN the sampler runs
+	batch, err := NewSampler(nil).Sample()
+	require.NoError(t, err)
+
+	// THEN the server status is reported
+	require.Len(t, batch, 1)
+	s := batch[0].(*Sample)
+	assert.Equal(t, "NTPServerSample", s.EventType)
+	assert.Equal(t, daemonChrony, s.Daemon)
+	assert.Equal(t, 4, *s.Stratum)
+	assert.Equal(t, "A29FC87B", *s.ReferenceID)
+	assert.Equal(t, 0.012603454, *s.RootDelaySeconds)
+	assert.Equal(t, 0.000797651, *s.RootDispersionSeconds)
+	assert.Equal(t, "Normal", *s.LeapStatus)
+	assert.Equal(t, 2, *s.ClientCount)
+	assert.Equal(t, 3, *s.SourceCount)
+	assert.Equal(t, 2, *s.ReachableSourceCount)
+	// 8 + 0 + 4 successful polls out of 24
+	assert.InDelta(t, 50.0, *s.SourceReachabilityPercent, 0.001)
+	assert.Equal(t, "162.159.200.123", *s.SelectedSource)
+}
+
+func TestSampler_Chrony_WithoutClientsAccess(t *testing.T) {
+	defer fakeCommands("chronyc", map[string]string{
+		"chronyc -c -n tracking": "A29FC87B,162.159.200.123,4,1611923766.527066445,-0.000006785,-0.000009637,0.000031106,-11.407,-0.001,0.026,0.012603454,0.000797651,1030.0,Normal\n",
+	})()
+
+	batch, err := NewSampler(nil).Sample()
+	require.NoError(t, err)
+
+	require.Len(t, batch, 1)
+	s := batch[0].(*Sample)
+	assert.Equal(t, 4, *s.Stratum)
+	assert.Nil(t, s.ClientCount)
+	assert.Nil(t, s.SourceCount)
+}
+
+const ntpqReadVar = `associd=0 status=0615 leap_none, sync_ntp, 1 event, clock_sync,
+version="ntpd 4.2.8p15@1.3728-o Wed Sep 23 11:46:38 UTC 2020 (1)",
+processor="x86_64", system="Linux/5.4.0-66-generic", leap=00, stratum=3,
+precision=-24, rootdelay=12.345, rootdisp=40.5, refid=162.159.200.1,
+reftime=e3c8f0a2.12345678  Thu, Feb 18 2021 10:00:02.071,
+clock=e3c8f0b1.22345678  Thu, Feb 18 2021 10:00:17.133, peer=12345, tc=10,
+mintc=3, offset=-0.021123, frequency=-11.123, sys_jitter=0.123456,
+clk_jitter=0.123, clk_wander=0.001
+`
+
+const ntpqPeers = `     remote           refid      st t when poll reach   delay   offset  jitter
+==============================================================================
+*162.159.200.1   10.20.8.4        3 u   34   64  377    1.234   -0.021   0.123
++10.0.0.1        .GPS.            1 u   12   64    1    2.345    0.120   0.010
+ 10.0.0.2        .INIT.          16 u    -   64    0    0.000    0.000   0.000
+`
+
+const ntpqMRUList = `Ctrl-C will stop MRU retrieval and display partial results.
+Retrieved 3 unique MRU entries and 0 updates.
+lstint avgint rstr r m v  count rport remote address
+==============================================================================
+     0     64    0 . 4 4    100   123 10.0.1.5
+     3     64    0 . 3 4     20   123 10.0.1.6
+    10     64    0 . 4 4     30   123 127.0.0.1
+`
+
+func TestSampler_Ntpd(t *testing.T) {
+	// GIVEN an ntpd server
+	defer fakeCommands("ntpq", map[string]string{
+		"ntpq -n -c rv":      ntpqReadVar,
+		"ntpq -n -c peers":   ntpqPeers,
+		"ntpq -n -c mrulist": ntpqMRUList,
+	})()
+
+	// WHEN the sampler runs
+	batch, err := NewSampler(nil).Sample()
+	require.NoError(t, err)
+
+	// THEN the server status is reported
+	require.Len(t, batch, 1)
+	s := batch[0].(*Sample)
+	assert.Equal(t, daemonNtpd, s.Daemon)
+	assert.Equal(t, 3, *s.Stratum)
+	assert.Equal(t, "162.159.200.1", *s.ReferenceID)
+	assert.InDelta(t, 0.012345, *s.RootDelaySeconds, 0.0000001)
+	assert.InDelta(t, 0.0405, *s.RootDispersionSeconds, 0.0000001)
+	assert.Equal(t, "Normal", *s.LeapStatus)
+	assert.Equal(t, 3, *s.ClientCount)
+	assert.Equal(t, 3, *s.SourceCount)
+	assert.Equal(t, 2, *s.ReachableSourceCount)
+	assert.Equal(t, "162.159.200.1", *s.SelectedSource)
+}
+
+func TestSampler_NoNTPClient(t *testing.T) {
+	defer fakeCommands("none", nil)()
+
+	batch, err := NewSampler(nil).Sample()
+	require.NoError(t, err)
+	assert.Empty(t, batch)
+}
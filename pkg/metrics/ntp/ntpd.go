@@ -0,0 +1,136 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package ntp
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ntpd leap indicator values, as reported by the "leap" system variable.
+var leapStatuses = map[string]string{
+	"00": "Normal",
+	"01": "Insert second",
+	"10": "Delete second",
+	"11": "Not synchronised",
+}
+
+// ntpq peers tally codes, prefixing the remote address. "*" marks the selected peer.
+const tallyCodes = "x.-+#*o"
+
+// ntpdSample queries ntpd through the ntpq client.
+func ntpdSample() (*Sample, error) {
+	output, err := runCommand("ntpq", "-n", "-c", "rv")
+	if err != nil {
+		return nil, fmt.Errorf("running ntpq readvar: %v", err)
+	}
+	s, err := parseNtpqReadVar(output)
+	if err != nil {
+		return nil, err
+	}
+
+	if output, err = runCommand("ntpq", "-n", "-c", "peers"); err != nil {
+		nlog.WithError(err).Debug("Running ntpq peers.")
+	} else if sources, err := parseNtpqPeers(output); err != nil {
+		nlog.WithError(err).Debug("Parsing ntpq peers.")
+	} else {
+		s.setSources(sources)
+	}
+
+	// the MRU list holds the recent clients of the server
+	if output, err = runCommand("ntpq", "-n", "-c", "mrulist"); err != nil {
+		nlog.WithError(err).Debug("Running ntpq mrulist.")
+	} else {
+		clients := len(tableRows(output))
+		s.ClientCount = &clients
+	}
+
+	return s, nil
+}
+
+// parseNtpqReadVar parses the comma separated "name=value" system variables returned by "ntpq -c rv".
+// The root delay and dispersion are reported in milliseconds.
+func parseNtpqReadVar(output string) (*Sample, error) {
+	vars := map[string]string{}
+	for _, assignment := range strings.Split(output, ",") {
+		parts := strings.SplitN(strings.TrimSpace(assignment), "=", 2)
+		if len(parts) == 2 {
+			vars[parts[0]] = strings.Trim(parts[1], `"`)
+		}
+	}
+
+	s := &Sample{Daemon: daemonNtpd}
+	if value, ok := vars["stratum"]; ok {
+		stratum, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, fmt.Errorf("parsing stratum: %v", err)
+		}
+		s.Stratum = &stratum
+	} else {
+		return nil, fmt.Errorf("unexpected ntpq readvar output: %q", output)
+	}
+	if value, ok := vars["rootdelay"]; ok {
+		rootDelay, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return nil, fmt.Errorf("parsing root delay: %v", err)
+		}
+		rootDelay /= 1000
+		s.RootDelaySeconds = &rootDelay
+	}
+	if value, ok := vars["rootdisp"]; ok {
+		rootDispersion, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return nil, fmt.Errorf("parsing root dispersion: %v", err)
+		}
+		rootDispersion /= 1000
+		s.RootDispersionSeconds = &rootDispersion
+	}
+	if refID, ok := vars["refid"]; ok {
+		s.ReferenceID = &refID
+	}
+	if leapStatus, ok := leapStatuses[vars["leap"]]; ok {
+		s.LeapStatus = &leapStatus
+	}
+	return s, nil
+}
+
+// parseNtpqPeers parses the peers table returned by "ntpq -c peers", whose rows look like:
+// "*162.159.200.1   10.20.8.4   3 u   34   64  377    1.234   -0.021   0.123".
+// The first character is the tally code and the reachability register is printed in octal.
+func parseNtpqPeers(output string) ([]source, error) {
+	var sources []source
+	for _, row := range tableRows(output) {
+		fields := strings.Fields(row)
+		if len(fields) < 10 {
+			return nil, fmt.Errorf("unexpected ntpq peers output: %q", row)
+		}
+		address := fields[0]
+		selected := false
+		if strings.ContainsAny(address[:1], tallyCodes) {
+			selected = address[0] == '*'
+			address = address[1:]
+		}
+		reach, err := strconv.ParseUint(fields[6], 8, 8)
+		if err != nil {
+			return nil, fmt.Errorf("parsing reachability of %s: %v", address, err)
+		}
+		sources = append(sources, source{
+			address:  address,
+			selected: selected,
+			reach:    uint8(reach),
+		})
+	}
+	return sources, nil
+}
+
+// tableRows returns the rows of an ntpq table, which follow a "=====" separator line.
+func tableRows(output string) []string {
+	lines := nonEmptyLines(output)
+	for i, line := range lines {
+		if strings.HasPrefix(line, "===") {
+			return lines[i+1:]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,195 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package power
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/newrelic/infrastructure-agent/pkg/helpers"
+)
+
+// readEnergyCounters reads the RAPL energy counters, or the AMD energy driver ones if RAPL is not available.
+func readEnergyCounters() []energyCounter {
+	if counters := readRAPL(helpers.HostSys("class", "powercap")); len(counters) > 0 {
+		return counters
+	}
+	return readAMDEnergy(helpers.HostSys("class", "hwmon"))
+}
+
+func readPowerSupplies() []*Sample {
+	return readPowerSupplyDir(helpers.HostSys("class", "power_supply"))
+}
+
+// readRAPL reads the energy counters of the RAPL zones in the powercap directory. The zones are named
+// intel-rapl:<package> and their subzones intel-rapl:<package>:<subzone>. Since Linux 5.10, the counters
+// are only readable by root.
+func readRAPL(dir string) []energyCounter {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		plog.WithError(err).Debug("Unable to read powercap zones.")
+		return nil
+	}
+	var counters []energyCounter
+	packages := map[string]*int{}
+	for _, entry := range entries {
+		// the intel-rapl-mmio zones duplicate the package domain through a different interface
+		if !strings.HasPrefix(entry.Name(), "intel-rapl:") {
+			continue
+		}
+		zoneDir := filepath.Join(dir, entry.Name())
+		name, err := readString(filepath.Join(zoneDir, "name"))
+		if err != nil {
+			continue
+		}
+		microJ, err := readUint(filepath.Join(zoneDir, "energy_uj"))
+		if err != nil {
+			plog.WithError(err).WithField("zone", entry.Name()).Debug("Unable to read RAPL energy counter.")
+			continue
+		}
+		rangeMicroJ, _ := readUint(filepath.Join(zoneDir, "max_energy_range_uj"))
+
+		ids := strings.Split(strings.TrimPrefix(entry.Name(), "intel-rapl:"), ":")
+		topLevel := len(ids) == 1
+		if topLevel && strings.HasPrefix(name, "package-") {
+			if index, err := strconv.Atoi(strings.TrimPrefix(name, "package-")); err == nil {
+				packages[ids[0]] = &index
+			}
+		}
+		counters = append(counters, energyCounter{
+			key:         entry.Name(),
+			source:      sourceRAPL,
+			domain:      name,
+			microJ:      microJ,
+			rangeMicroJ: rangeMicroJ,
+			host:        (topLevel && strings.HasPrefix(name, "package-")) || name == "dram",
+		})
+	}
+	// the package indexes are known after reading the top level zones
+	for i := range counters {
+		ids := strings.Split(strings.TrimPrefix(counters[i].key, "intel-rapl:"), ":")
+		counters[i].pkg = packages[ids[0]]
+	}
+	return counters
+}
+
+// readAMDEnergy reads the socket energy counters of the amd_energy hwmon driver. The driver accumulates
+// them in 64 bits, so they don't wrap.
+func readAMDEnergy(dir string) []energyCounter {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		plog.WithError(err).Debug("Unable to read hwmon devices.")
+		return nil
+	}
+	var counters []energyCounter
+	for _, entry := range entries {
+		hwmonDir := filepath.Join(dir, entry.Name())
+		if name, err := readString(filepath.Join(hwmonDir, "name")); err != nil || name != "amd_energy" {
+			continue
+		}
+		labels, _ := filepath.Glob(filepath.Join(hwmonDir, "energy*_label"))
+		sort.Strings(labels)
+		for _, labelFile := range labels {
+			// the per core counters (Ecore<n>) are discarded, as they are already part of the sockets ones
+			label, err := readString(labelFile)
+			if err != nil || !strings.HasPrefix(label, "Esocket") {
+				continue
+			}
+			index, err := strconv.Atoi(strings.TrimPrefix(label, "Esocket"))
+			if err != nil {
+				continue
+			}
+			inputFile := strings.TrimSuffix(labelFile, "_label") + "_input"
+			microJ, err := readUint(inputFile)
+			if err != nil {
+				plog.WithError(err).WithField("file", inputFile).Debug("Unable to read AMD energy counter.")
+				continue
+			}
+			counters = append(counters, energyCounter{
+				key:    inputFile,
+				source: sourceAMDEnergy,
+				domain: "package-" + strconv.Itoa(index),
+				pkg:    &index,
+				microJ: microJ,
+				host:   true,
+			})
+		}
+	}
+	return counters
+}
+
+// readPowerSupplyDir reads the batteries and power supplies of the host, discarding the ones powering
+// peripherals (e.g. wireless mice).
+func readPowerSupplyDir(dir string) []*Sample {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			plog.WithError(err).Debug("Unable to read power supplies.")
+		}
+		return nil
+	}
+	var supplies []*Sample
+	for _, entry := range entries {
+		supplyDir := filepath.Join(dir, entry.Name())
+		if scope, err := readString(filepath.Join(supplyDir, "scope")); err == nil && scope == "Device" {
+			continue
+		}
+		supplyType, err := readString(filepath.Join(supplyDir, "type"))
+		if err != nil {
+			continue
+		}
+		ps := newSample(sourcePowerSupply, entry.Name())
+		ps.SupplyType = &supplyType
+		if status, err := readString(filepath.Join(supplyDir, "status")); err == nil {
+			ps.Status = &status
+		}
+		if online, err := readUint(filepath.Join(supplyDir, "online")); err == nil {
+			isOnline := online != 0
+			ps.Online = &isOnline
+		}
+		if capacity, err := readUint(filepath.Join(supplyDir, "capacity")); err == nil {
+			percent := float64(capacity)
+			ps.CapacityPercent = &percent
+		}
+		// the values are reported in micro units
+		if power, err := readUint(filepath.Join(supplyDir, "power_now")); err == nil {
+			watts := float64(power) / 1e6
+			ps.PowerWatts = &watts
+		} else if current, err := readUint(filepath.Join(supplyDir, "current_now")); err == nil {
+			if voltage, err := readUint(filepath.Join(supplyDir, "voltage_now")); err == nil {
+				watts := float64(current) / 1e6 * float64(voltage) / 1e6
+				ps.PowerWatts = &watts
+			}
+		}
+		if energy, err := readUint(filepath.Join(supplyDir, "energy_now")); err == nil {
+			wattHours := float64(energy) / 1e6
+			ps.EnergyWattHours = &wattHours
+		}
+		if energy, err := readUint(filepath.Join(supplyDir, "energy_full")); err == nil {
+			wattHours := float64(energy) / 1e6
+			ps.EnergyFullWattHours = &wattHours
+		}
+		supplies = append(supplies, ps)
+	}
+	return supplies
+}
+
+func readString(path string) (string, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(content)), nil
+}
+
+func readUint(path string) (uint64, error) {
+	value, err := readString(path)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseUint(value, 10, 64)
+}
@@ -0,0 +1,117 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package power
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeFiles creates the files of a fake sysfs directory.
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	for path, content := range files {
+		path = filepath.Join(dir, path)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, ioutil.WriteFile(path, []byte(content+"\n"), 0644))
+	}
+}
+
+func TestReadRAPL(t *testing.T) {
+	dir, err := ioutil.TempDir("", "powercap")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	writeFiles(t, dir, map[string]string{
+		"intel-rapl:0/name":                  "package-0",
+		"intel-rapl:0/energy_uj":             "123456",
+		"intel-rapl:0/max_energy_range_uj":   "262143328850",
+		"intel-rapl:0:0/name":                "core",
+		"intel-rapl:0:0/energy_uj":           "1000",
+		"intel-rapl:0:1/name":                "dram",
+		"intel-rapl:0:1/energy_uj":           "2000",
+		"intel-rapl:1/name":                  "psys",
+		"intel-rapl:1/energy_uj":             "3000",
+		"intel-rapl-mmio:0/name":             "package-0",
+		"intel-rapl-mmio:0/energy_uj":        "123456",
+		"intel-rapl:2/name":                  "package-1",
+		"intel-rapl:2/energy_uj":             "not readable",
+		"intel-rapl:2/max_energy_range_uj":   "262143328850",
+		"intel-rapl:0:1/max_energy_range_uj": "65712999613",
+	})
+
+	counters := readRAPL(dir)
+
+	require.Len(t, counters, 4)
+	assert.Equal(t, "package-0", counters[0].domain)
+	assert.Equal(t, 0, *counters[0].pkg)
+	assert.Equal(t, uint64(123456), counters[0].microJ)
+	assert.Equal(t, uint64(262143328850), counters[0].rangeMicroJ)
+	assert.True(t, counters[0].host)
+	assert.Equal(t, "core", counters[1].domain)
+	assert.Equal(t, 0, *counters[1].pkg)
+	assert.False(t, counters[1].host)
+	assert.Equal(t, "dram", counters[2].domain)
+	assert.True(t, counters[2].host)
+	assert.Equal(t, "psys", counters[3].domain)
+	assert.Nil(t, counters[3].pkg)
+}
+
+func TestReadAMDEnergy(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hwmon")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	writeFiles(t, dir, map[string]string{
+		"hwmon0/name":          "k10temp",
+		"hwmon1/name":          "amd_energy",
+		"hwmon1/energy1_label": "Ecore000",
+		"hwmon1/energy1_input": "1000",
+		"hwmon1/energy2_label": "Esocket0",
+		"hwmon1/energy2_input": "5000000",
+	})
+
+	counters := readAMDEnergy(dir)
+
+	require.Len(t, counters, 1)
+	assert.Equal(t, "package-0", counters[0].domain)
+	assert.Equal(t, sourceAMDEnergy, counters[0].source)
+	assert.Equal(t, uint64(5000000), counters[0].microJ)
+	assert.True(t, counters[0].host)
+}
+
+func TestReadPowerSupplyDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "power_supply")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	writeFiles(t, dir, map[string]string{
+		"AC/type":             "Mains",
+		"AC/online":           "0",
+		"BAT0/type":           "Battery",
+		"BAT0/status":         "Discharging",
+		"BAT0/capacity":       "87",
+		"BAT0/current_now":    "1500000",
+		"BAT0/voltage_now":    "12000000",
+		"BAT0/energy_now":     "43500000",
+		"BAT0/energy_full":    "50000000",
+		"hidpp_battery/type":  "Battery",
+		"hidpp_battery/scope": "Device",
+	})
+
+	supplies := readPowerSupplyDir(dir)
+
+	require.Len(t, supplies, 2)
+	ac, battery := supplies[0], supplies[1]
+	assert.Equal(t, "AC", ac.Domain)
+	assert.Equal(t, "Mains", *ac.SupplyType)
+	assert.False(t, *ac.Online)
+	assert.Nil(t, ac.PowerWatts)
+	assert.Equal(t, "BAT0", battery.Domain)
+	assert.Equal(t, "Discharging", *battery.Status)
+	assert.Equal(t, 87.0, *battery.CapacityPercent)
+	assert.Equal(t, 18.0, *battery.PowerWatts)
+	assert.Equal(t, 43.5, *battery.EnergyWattHours)
+	assert.Equal(t, 50.0, *battery.EnergyFullWattHours)
+}
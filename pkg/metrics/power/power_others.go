@@ -0,0 +1,15 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+// +build !linux
+
+package power
+
+// readEnergyCounters is only supported on Linux.
+func readEnergyCounters() []energyCounter {
+	return nil
+}
+
+// readPowerSupplies is only supported on Linux.
+func readPowerSupplies() []*Sample {
+	return nil
+}
@@ -0,0 +1,208 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+// Package power samples the power drawn by the host, from the CPU energy counters (Intel RAPL or the AMD
+// energy driver) and from the ACPI batteries and power supplies.
+package power
+
+import (
+	"fmt"
+	"runtime/debug"
+	"time"
+
+	"github.com/newrelic/infrastructure-agent/internal/agent"
+	"github.com/newrelic/infrastructure-agent/pkg/config"
+	"github.com/newrelic/infrastructure-agent/pkg/log"
+	"github.com/newrelic/infrastructure-agent/pkg/sample"
+)
+
+// Sources of the power data.
+const (
+	sourceRAPL        = "rapl"
+	sourceAMDEnergy   = "amd_energy"
+	sourcePowerSupply = "power_supply"
+
+	domainHost = "host"
+)
+
+var (
+	plog = log.WithComponent("PowerSampler")
+
+	timeNow = time.Now
+)
+
+// Sample holds the power drawn by a domain: a CPU package or one of its components, a battery or
+// power supply, or the whole host.
+type Sample struct {
+	sample.BaseEvent
+
+	// Source of the data: rapl, amd_energy or power_supply. For the host, the source it is estimated from
+	Source string `json:"source"`
+	// RAPL domain (e.g. package-0, core, dram, psys), power supply name (e.g. BAT0, AC) or host
+	Domain string `json:"domain"`
+	// Index of the CPU package (socket) the domain belongs to
+	Package *int `json:"package,omitempty"`
+	// Average power during the sample interval for energy counters, or instant power for batteries
+	PowerWatts *float64 `json:"powerWatts,omitempty"`
+	// Energy consumed during the sample interval
+	EnergyJoules *float64 `json:"energyJoules,omitempty"`
+	// Power supply type, e.g. Battery, Mains or UPS
+	SupplyType *string `json:"supplyType,omitempty"`
+	// Battery status, e.g. Charging, Discharging or Full
+	Status *string `json:"status,omitempty"`
+	// Whether a mains power supply is connected
+	Online *bool `json:"online,omitempty"`
+	// Battery charge, as a percentage of its full capacity
+	CapacityPercent *float64 `json:"capacityPercent,omitempty"`
+	// Energy stored in the battery
+	EnergyWattHours *float64 `json:"energyWattHours,omitempty"`
+	// Energy stored in the battery when full
+	EnergyFullWattHours *float64 `json:"energyFullWattHours,omitempty"`
+}
+
+func newSample(source, domain string) *Sample {
+	s := &Sample{Source: source, Domain: domain}
+	s.Type("PowerSample")
+	return s
+}
+
+// energyCounter is a reading of a cumulative energy counter.
+type energyCounter struct {
+	// key identifies the counter between readings
+	key         string
+	source      string
+	domain      string
+	pkg         *int
+	microJ      uint64
+	rangeMicroJ uint64 // value the counter wraps at. 0 if it doesn't wrap
+	// included in the host estimation: the packages and the DRAM, which isn't part of the package domain
+	host bool
+}
+
+// delta returns the energy consumed since the previous reading, handling the counter wrapping.
+func (c energyCounter) delta(previous energyCounter) (uint64, bool) {
+	if c.microJ >= previous.microJ {
+		return c.microJ - previous.microJ, true
+	}
+	if c.rangeMicroJ == 0 {
+		// the counter has been reset
+		return 0, false
+	}
+	return c.rangeMicroJ - previous.microJ + c.microJ, true
+}
+
+// Sampler reports a PowerSample for each energy counter and power supply, and an estimation of the power
+// drawn by the whole host.
+type Sampler struct {
+	context    agent.AgentContext
+	sampleRate time.Duration
+
+	energyCounters func() []energyCounter
+	powerSupplies  func() []*Sample
+
+	// previous readings of the energy counters, by key
+	previous     map[string]energyCounter
+	previousTime time.Time
+}
+
+func NewSampler(context agent.AgentContext) *Sampler {
+	sampleRateSec := config.FREQ_DISABLE_SAMPLING
+	if context != nil {
+		sampleRateSec = context.Config().MetricsPowerSampleRate
+	}
+
+	return &Sampler{
+		context:        context,
+		sampleRate:     time.Second * time.Duration(sampleRateSec),
+		energyCounters: readEnergyCounters,
+		powerSupplies:  readPowerSupplies,
+	}
+}
+
+func (s *Sampler) OnStartup() {}
+
+func (s *Sampler) Name() string {
+	return "PowerSampler"
+}
+
+func (s *Sampler) Interval() time.Duration {
+	return s.sampleRate
+}
+
+func (s *Sampler) Disabled() bool {
+	return s.Interval() <= config.FREQ_DISABLE_SAMPLING
+}
+
+func (s *Sampler) Sample() (eventBatch sample.EventBatch, err error) {
+	defer func() {
+		if panicErr := recover(); panicErr != nil {
+			err = fmt.Errorf("Panic in power.Sampler: %v\nStack: %s", panicErr, debug.Stack())
+		}
+	}()
+
+	now := timeNow()
+	elapsed := now.Sub(s.previousTime).Seconds()
+	counters := s.energyCounters()
+
+	// the energy counters are reported from the second sample, as their rate is needed
+	current := make(map[string]energyCounter, len(counters))
+	var host *Sample
+	psys := false
+	for _, counter := range counters {
+		current[counter.key] = counter
+		previous, ok := s.previous[counter.key]
+		if !ok || elapsed <= 0 {
+			continue
+		}
+		microJ, ok := counter.delta(previous)
+		if !ok {
+			continue
+		}
+		joules := float64(microJ) / 1e6
+		watts := joules / elapsed
+		ps := newSample(counter.source, counter.domain)
+		ps.Package = counter.pkg
+		ps.EnergyJoules = &joules
+		ps.PowerWatts = &watts
+		eventBatch = append(eventBatch, ps)
+
+		switch {
+		case counter.domain == "psys":
+			// the platform domain covers the whole SoC, so it is preferred for the host estimation
+			host, psys = hostSample(counter.source, joules, watts), true
+		case counter.host && !psys:
+			if host == nil {
+				host = hostSample(counter.source, 0, 0)
+			}
+			*host.EnergyJoules += joules
+			*host.PowerWatts += watts
+		}
+	}
+	s.previous = current
+	s.previousTime = now
+
+	var batteryWatts float64
+	discharging := false
+	for _, supply := range s.powerSupplies() {
+		if supply.Status != nil && *supply.Status == "Discharging" && supply.PowerWatts != nil {
+			batteryWatts += *supply.PowerWatts
+			discharging = true
+		}
+		eventBatch = append(eventBatch, supply)
+	}
+	// without energy counters, a discharging battery provides the power drawn by the host
+	if host == nil && discharging {
+		host = hostSample(sourcePowerSupply, batteryWatts*s.sampleRate.Seconds(), batteryWatts)
+	}
+
+	if host != nil {
+		eventBatch = append(eventBatch, host)
+	}
+	return eventBatch, nil
+}
+
+func hostSample(source string, joules, watts float64) *Sample {
+	host := newSample(source, domainHost)
+	host.EnergyJoules = &joules
+	host.PowerWatts = &watts
+	return host
+}
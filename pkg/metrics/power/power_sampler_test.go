@@ -0,0 +1,109 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package power
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEnergyCounter_Delta(t *testing.T) {
+	previous := energyCounter{microJ: 900, rangeMicroJ: 1000}
+
+	delta, ok := energyCounter{microJ: 950, rangeMicroJ: 1000}.delta(previous)
+	assert.True(t, ok)
+	assert.Equal(t, uint64(50), delta)
+
+	// the counter wrapped
+	delta, ok = energyCounter{microJ: 100, rangeMicroJ: 1000}.delta(previous)
+	assert.True(t, ok)
+	assert.Equal(t, uint64(200), delta)
+
+	// the counter was reset
+	_, ok = energyCounter{microJ: 100}.delta(energyCounter{microJ: 900})
+	assert.False(t, ok)
+}
+
+func TestSampler_Sample(t *testing.T) {
+	now := time.Unix(1611923766, 0)
+	timeNow = func() time.Time { return now }
+	defer func() { timeNow = time.Now }()
+
+	pkg0, pkg1 := 0, 1
+	readings := [][]energyCounter{{
+		{key: "intel-rapl:0", source: sourceRAPL, domain: "package-0", pkg: &pkg0, microJ: 1000000, host: true},
+		{key: "intel-rapl:0:0", source: sourceRAPL, domain: "core", pkg: &pkg0, microJ: 500000},
+		{key: "intel-rapl:1", source: sourceRAPL, domain: "package-1", pkg: &pkg1, microJ: 2000000, host: true},
+	}, {
+		{key: "intel-rapl:0", source: sourceRAPL, domain: "package-0", pkg: &pkg0, microJ: 301000000, host: true},
+		{key: "intel-rapl:0:0", source: sourceRAPL, domain: "core", pkg: &pkg0, microJ: 200500000},
+		{key: "intel-rapl:1", source: sourceRAPL, domain: "package-1", pkg: &pkg1, microJ: 202000000, host: true},
+	}}
+
+	// GIVEN a sampler reading RAPL energy counters
+	s := NewSampler(nil)
+	s.sampleRate = 10 * time.Second
+	s.powerSupplies = func() []*Sample { return nil }
+	s.energyCounters = func() []energyCounter {
+		counters := readings[0]
+		readings = readings[1:]
+		return counters
+	}
+
+	// WHEN the counters are sampled for the first time
+	batch, err := s.Sample()
+
+	// THEN nothing is reported, as the power can't be calculated yet
+	require.NoError(t, err)
+	assert.Empty(t, batch)
+
+	// WHEN the counters are sampled again
+	now = now.Add(10 * time.Second)
+	batch, err = s.Sample()
+
+	// THEN the average power of each domain is reported
+	require.NoError(t, err)
+	require.Len(t, batch, 4)
+	core := batch[1].(*Sample)
+	assert.Equal(t, "PowerSample", core.EventType)
+	assert.Equal(t, "core", core.Domain)
+	assert.Equal(t, 0, *core.Package)
+	assert.Equal(t, 20.0, *core.PowerWatts)
+	assert.Equal(t, 200.0, *core.EnergyJoules)
+
+	// AND the host estimation sums the packages
+	host := batch[3].(*Sample)
+	assert.Equal(t, domainHost, host.Domain)
+	assert.Equal(t, sourceRAPL, host.Source)
+	assert.Equal(t, 50.0, *host.PowerWatts)
+	assert.Equal(t, 500.0, *host.EnergyJoules)
+}
+
+func TestSampler_Sample_Battery(t *testing.T) {
+	// GIVEN a host without energy counters, running on battery
+	status, watts := "Discharging", 12.5
+	s := NewSampler(nil)
+	s.sampleRate = 10 * time.Second
+	s.energyCounters = func() []energyCounter { return nil }
+	s.powerSupplies = func() []*Sample {
+		battery := newSample(sourcePowerSupply, "BAT0")
+		battery.Status = &status
+		battery.PowerWatts = &watts
+		return []*Sample{battery}
+	}
+
+	// WHEN it is sampled
+	batch, err := s.Sample()
+
+	// THEN the battery power is reported as the host power
+	require.NoError(t, err)
+	require.Len(t, batch, 2)
+	host := batch[1].(*Sample)
+	assert.Equal(t, domainHost, host.Domain)
+	assert.Equal(t, sourcePowerSupply, host.Source)
+	assert.Equal(t, 12.5, *host.PowerWatts)
+	assert.Equal(t, 125.0, *host.EnergyJoules)
+}
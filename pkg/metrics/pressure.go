@@ -0,0 +1,73 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package metrics
+
+// PressureSample holds the Linux Pressure Stall Information: the share of time in which some (or all, for
+// "full") non-idle tasks were stalled waiting for CPU, memory or IO, averaged over 10, 60 and 300 seconds,
+// and the total stall time since boot. Attributes are only present if the kernel reports them.
+type PressureSample struct {
+	CPUSomeAvg10     *float64 `json:"cpuPressureSomeAvg10Percent,omitempty"`
+	CPUSomeAvg60     *float64 `json:"cpuPressureSomeAvg60Percent,omitempty"`
+	CPUSomeAvg300    *float64 `json:"cpuPressureSomeAvg300Percent,omitempty"`
+	CPUSomeTotal     *uint64  `json:"cpuPressureSomeTotalMicroseconds,omitempty"`
+	CPUFullAvg10     *float64 `json:"cpuPressureFullAvg10Percent,omitempty"`
+	CPUFullAvg60     *float64 `json:"cpuPressureFullAvg60Percent,omitempty"`
+	CPUFullAvg300    *float64 `json:"cpuPressureFullAvg300Percent,omitempty"`
+	CPUFullTotal     *uint64  `json:"cpuPressureFullTotalMicroseconds,omitempty"`
+	MemorySomeAvg10  *float64 `json:"memoryPressureSomeAvg10Percent,omitempty"`
+	MemorySomeAvg60  *float64 `json:"memoryPressureSomeAvg60Percent,omitempty"`
+	MemorySomeAvg300 *float64 `json:"memoryPressureSomeAvg300Percent,omitempty"`
+	MemorySomeTotal  *uint64  `json:"memoryPressureSomeTotalMicroseconds,omitempty"`
+	MemoryFullAvg10  *float64 `json:"memoryPressureFullAvg10Percent,omitempty"`
+	MemoryFullAvg60  *float64 `json:"memoryPressureFullAvg60Percent,omitempty"`
+	MemoryFullAvg300 *float64 `json:"memoryPressureFullAvg300Percent,omitempty"`
+	MemoryFullTotal  *uint64  `json:"memoryPressureFullTotalMicroseconds,omitempty"`
+	IOSomeAvg10      *float64 `json:"ioPressureSomeAvg10Percent,omitempty"`
+	IOSomeAvg60      *float64 `json:"ioPressureSomeAvg60Percent,omitempty"`
+	IOSomeAvg300     *float64 `json:"ioPressureSomeAvg300Percent,omitempty"`
+	IOSomeTotal      *uint64  `json:"ioPressureSomeTotalMicroseconds,omitempty"`
+	IOFullAvg10      *float64 `json:"ioPressureFullAvg10Percent,omitempty"`
+	IOFullAvg60      *float64 `json:"ioPressureFullAvg60Percent,omitempty"`
+	IOFullAvg300     *float64 `json:"ioPressureFullAvg300Percent,omitempty"`
+	IOFullTotal      *uint64  `json:"ioPressureFullTotalMicroseconds,omitempty"`
+}
+
+// pressureStall is a line of a PSI file, e.g. "some avg10=0.12 avg60=0.05 avg300=0.01 total=123456".
+type pressureStall struct {
+	avg10, avg60, avg300 *float64
+	total                *uint64
+}
+
+// set assigns the stall of the passed resource (cpu, memory or io) and kind (some or full) to the
+// sample fields. Unknown resources or kinds are ignored.
+func (p *PressureSample) set(resource, kind string, stall pressureStall) {
+	var avg10, avg60, avg300 **float64
+	var total **uint64
+	switch resource + " " + kind {
+	case "cpu some":
+		avg10, avg60, avg300, total = &p.CPUSomeAvg10, &p.CPUSomeAvg60, &p.CPUSomeAvg300, &p.CPUSomeTotal
+	case "cpu full":
+		avg10, avg60, avg300, total = &p.CPUFullAvg10, &p.CPUFullAvg60, &p.CPUFullAvg300, &p.CPUFullTotal
+	case "memory some":
+		avg10, avg60, avg300, total = &p.MemorySomeAvg10, &p.MemorySomeAvg60, &p.MemorySomeAvg300, &p.MemorySomeTotal
+	case "memory full":
+		avg10, avg60, avg300, total = &p.MemoryFullAvg10, &p.MemoryFullAvg60, &p.MemoryFullAvg300, &p.MemoryFullTotal
+	case "io some":
+		avg10, avg60, avg300, total = &p.IOSomeAvg10, &p.IOSomeAvg60, &p.IOSomeAvg300, &p.IOSomeTotal
+	case "io full":
+		avg10, avg60, avg300, total = &p.IOFullAvg10, &p.IOFullAvg60, &p.IOFullAvg300, &p.IOFullTotal
+	default:
+		return
+	}
+	*avg10, *avg60, *avg300, *total = stall.avg10, stall.avg60, stall.avg300, stall.total
+}
+
+// PressureMonitor reads the Pressure Stall Information of the host. Kernels older than 4.20, or built or
+// booted without PSI support, don't report it.
+type PressureMonitor struct {
+	dir string
+}
+
+func NewPressureMonitor() *PressureMonitor {
+	return &PressureMonitor{dir: pressureDir()}
+}
@@ -0,0 +1,95 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"strconv"
+	"strings"
+
+	"github.com/newrelic/infrastructure-agent/pkg/helpers"
+)
+
+var pressureResources = []string{"cpu", "memory", "io"}
+
+func pressureDir() string {
+	return helpers.HostProc("pressure")
+}
+
+// Sample returns nil if the kernel doesn't report the Pressure Stall Information.
+func (m *PressureMonitor) Sample() (sample *PressureSample, err error) {
+	defer func() {
+		if panicErr := recover(); panicErr != nil {
+			err = fmt.Errorf("Panic in PressureMonitor.Sample: %v\nStack: %s", panicErr, debug.Stack())
+		}
+	}()
+
+	for _, resource := range pressureResources {
+		stalls, err := readPressureFile(filepath.Join(m.dir, resource))
+		if err != nil {
+			// the files are missing if PSI is disabled
+			if !os.IsNotExist(err) {
+				syslog.WithError(err).WithField("resource", resource).Debug("Unable to read pressure stall information.")
+			}
+			continue
+		}
+		if sample == nil {
+			sample = &PressureSample{}
+		}
+		for kind, stall := range stalls {
+			sample.set(resource, kind, stall)
+		}
+	}
+	return sample, nil
+}
+
+// readPressureFile parses the stalls of a PSI file by kind (some or full), e.g.
+// some avg10=0.12 avg60=0.05 avg300=0.01 total=123456
+// full avg10=0.00 avg60=0.00 avg300=0.00 total=0
+func readPressureFile(path string) (map[string]pressureStall, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	stalls := map[string]pressureStall{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		var stall pressureStall
+		for _, field := range fields[1:] {
+			kv := strings.SplitN(field, "=", 2)
+			if len(kv) != 2 {
+				continue
+			}
+			if kv[0] == "total" {
+				if total, err := strconv.ParseUint(kv[1], 10, 64); err == nil {
+					stall.total = &total
+				}
+				continue
+			}
+			avg, err := strconv.ParseFloat(kv[1], 64)
+			if err != nil {
+				continue
+			}
+			switch kv[0] {
+			case "avg10":
+				stall.avg10 = &avg
+			case "avg60":
+				stall.avg60 = &avg
+			case "avg300":
+				stall.avg300 = &avg
+			}
+		}
+		stalls[fields[0]] = stall
+	}
+	return stalls, scanner.Err()
+}
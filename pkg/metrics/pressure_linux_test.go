@@ -0,0 +1,70 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package metrics
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPressureMonitor_Sample(t *testing.T) {
+	// GIVEN the PSI files of a kernel that doesn't report the full CPU stalls
+	dir, err := ioutil.TempDir("", "pressure")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	for resource, content := range map[string]string{
+		"cpu": "some avg10=12.50 avg60=8.25 avg300=3.00 total=987654321\n",
+		"memory": "some avg10=0.10 avg60=0.05 avg300=0.01 total=1234\n" +
+			"full avg10=0.00 avg60=0.00 avg300=0.00 total=56\n",
+		"io": "some avg10=40.00 avg60=20.00 avg300=10.00 total=555\n" +
+			"full avg10=30.00 avg60=15.00 avg300=5.00 total=444\n",
+	} {
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, resource), []byte(content), 0644))
+	}
+
+	// WHEN they are sampled
+	sample, err := (&PressureMonitor{dir: dir}).Sample()
+	require.NoError(t, err)
+	require.NotNil(t, sample)
+
+	// THEN the averages and totals are reported
+	marshaled, err := json.Marshal(sample)
+	require.NoError(t, err)
+	var attributes map[string]float64
+	require.NoError(t, json.Unmarshal(marshaled, &attributes))
+	assert.Equal(t, map[string]float64{
+		"cpuPressureSomeAvg10Percent":         12.5,
+		"cpuPressureSomeAvg60Percent":         8.25,
+		"cpuPressureSomeAvg300Percent":        3,
+		"cpuPressureSomeTotalMicroseconds":    987654321,
+		"memoryPressureSomeAvg10Percent":      0.1,
+		"memoryPressureSomeAvg60Percent":      0.05,
+		"memoryPressureSomeAvg300Percent":     0.01,
+		"memoryPressureSomeTotalMicroseconds": 1234,
+		"memoryPressureFullAvg10Percent":      0,
+		"memoryPressureFullAvg60Percent":      0,
+		"memoryPressureFullAvg300Percent":     0,
+		"memoryPressureFullTotalMicroseconds": 56,
+		"ioPressureSomeAvg10Percent":          40,
+		"ioPressureSomeAvg60Percent":          20,
+		"ioPressureSomeAvg300Percent":         10,
+		"ioPressureSomeTotalMicroseconds":     555,
+		"ioPressureFullAvg10Percent":          30,
+		"ioPressureFullAvg60Percent":          15,
+		"ioPressureFullAvg300Percent":         5,
+		"ioPressureFullTotalMicroseconds":     444,
+	}, attributes)
+}
+
+func TestPressureMonitor_Sample_Disabled(t *testing.T) {
+	sample, err := (&PressureMonitor{dir: "/non/existing/pressure"}).Sample()
+
+	require.NoError(t, err)
+	assert.Nil(t, sample)
+}
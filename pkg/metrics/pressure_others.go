@@ -0,0 +1,14 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+// +build !linux
+
+package metrics
+
+func pressureDir() string {
+	return ""
+}
+
+// Sample always returns nil, as the Pressure Stall Information is only reported by Linux.
+func (m *PressureMonitor) Sample() (*PressureSample, error) {
+	return nil, nil
+}
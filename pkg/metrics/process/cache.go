@@ -17,6 +17,8 @@ type cache struct {
 type cacheEntry struct {
 	process    *linuxProcess
 	lastSample *types.ProcessSample // The last event we generated for this process, so we can re-use metadata which doesn't change
+	filtered   *linuxProcess        // The snapshot the process filter was evaluated on
+	excluded   bool                 // Whether the process filter excluded the process
 }
 
 func newCache() cache {
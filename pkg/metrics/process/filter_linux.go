@@ -0,0 +1,106 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package process
+
+import (
+	"errors"
+	"regexp"
+
+	"github.com/newrelic/infrastructure-agent/pkg/config"
+)
+
+// errProcessExcluded is returned by the harvester for the processes excluded by the process filter.
+var errProcessExcluded = errors.New("process excluded")
+
+// patterns holds the regular expressions on each process attribute.
+type patterns struct {
+	names    []*regexp.Regexp
+	cmdlines []*regexp.Regexp
+	users    []*regexp.Regexp
+}
+
+func (p patterns) empty() bool {
+	return len(p.names) == 0 && len(p.cmdlines) == 0 && len(p.users) == 0
+}
+
+// match returns whether any expression matches the process. The command line and user are only read if
+// there are expressions on them.
+func (p patterns) match(process Snapshot, stripCommandLine bool) bool {
+	if matchAny(p.names, process.Command()) {
+		return true
+	}
+	if len(p.cmdlines) > 0 {
+		if cmdLine, err := process.CmdLine(!stripCommandLine); err == nil && matchAny(p.cmdlines, cmdLine) {
+			return true
+		}
+	}
+	if len(p.users) > 0 {
+		if user, err := process.Username(); err == nil && matchAny(p.users, user) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchAny(expressions []*regexp.Regexp, value string) bool {
+	for _, expression := range expressions {
+		if expression.MatchString(value) {
+			return true
+		}
+	}
+	return false
+}
+
+// filter decides which processes are sampled, from the process_include_* and process_exclude_* options.
+type filter struct {
+	include          patterns
+	exclude          patterns
+	stripCommandLine bool
+}
+
+// newFilter returns the process filter of the configuration, nil if there are no expressions. Invalid
+// expressions are ignored.
+func newFilter(cfg *config.Config, stripCommandLine bool) *filter {
+	if cfg == nil {
+		return nil
+	}
+	f := &filter{
+		include: patterns{
+			names:    compilePatterns("process_include_names", cfg.ProcessIncludeNames),
+			cmdlines: compilePatterns("process_include_cmdlines", cfg.ProcessIncludeCmdlines),
+			users:    compilePatterns("process_include_users", cfg.ProcessIncludeUsers),
+		},
+		exclude: patterns{
+			names:    compilePatterns("process_exclude_names", cfg.ProcessExcludeNames),
+			cmdlines: compilePatterns("process_exclude_cmdlines", cfg.ProcessExcludeCmdlines),
+			users:    compilePatterns("process_exclude_users", cfg.ProcessExcludeUsers),
+		},
+		stripCommandLine: stripCommandLine,
+	}
+	if f.include.empty() && f.exclude.empty() {
+		return nil
+	}
+	return f
+}
+
+func compilePatterns(option string, expressions []string) []*regexp.Regexp {
+	var compiled []*regexp.Regexp
+	for _, expression := range expressions {
+		re, err := regexp.Compile(expression)
+		if err != nil {
+			mplog.WithError(err).WithField("option", option).WithField("expression", expression).
+				Warn("Ignoring invalid process filter expression.")
+			continue
+		}
+		compiled = append(compiled, re)
+	}
+	return compiled
+}
+
+// accepts returns whether the process must be sampled.
+func (f *filter) accepts(process Snapshot) bool {
+	if !f.include.empty() && !f.include.match(process, f.stripCommandLine) {
+		return false
+	}
+	return !f.exclude.match(process, f.stripCommandLine)
+}
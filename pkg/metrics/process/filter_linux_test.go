@@ -0,0 +1,49 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package process
+
+import (
+	"testing"
+
+	"github.com/newrelic/infrastructure-agent/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func snapshot(command, cmdLine, user string) *linuxProcess {
+	return &linuxProcess{stats: procStats{command: command}, cmdLine: cmdLine, user: user}
+}
+
+func TestNewFilter_Empty(t *testing.T) {
+	assert.Nil(t, newFilter(nil, true))
+	assert.Nil(t, newFilter(&config.Config{}, true))
+	// invalid expressions are ignored
+	assert.Nil(t, newFilter(&config.Config{ProcessIncludeNames: []string{"("}}, true))
+}
+
+func TestFilter_Accepts(t *testing.T) {
+	nginx := snapshot("nginx", "/usr/sbin/nginx -g daemon off;", "www-data")
+	java := snapshot("java", "/usr/bin/java -jar /opt/app/service.jar", "app")
+	cron := snapshot("sh", "/bin/sh -c /usr/local/bin/backup.sh", "root")
+
+	tests := []struct {
+		name     string
+		cfg      *config.Config
+		accepted []bool
+	}{
+		{"include names", &config.Config{ProcessIncludeNames: []string{"^nginx$", "^java$"}}, []bool{true, true, false}},
+		{"include any attribute", &config.Config{ProcessIncludeNames: []string{"^nginx$"}, ProcessIncludeUsers: []string{"^app$"}}, []bool{true, true, false}},
+		{"exclude cmdlines", &config.Config{ProcessExcludeCmdlines: []string{`backup\.sh`}}, []bool{true, true, false}},
+		{"exclude overrides include", &config.Config{ProcessIncludeUsers: []string{".*"}, ProcessExcludeNames: []string{"^java$"}}, []bool{true, false, true}},
+		{"exclude users", &config.Config{ProcessExcludeUsers: []string{"^root$", "^www-data$"}}, []bool{false, true, false}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFilter(tt.cfg, false)
+			require.NotNil(t, f)
+			for i, process := range []*linuxProcess{nginx, java, cron} {
+				assert.Equal(t, tt.accepted[i], f.accepts(process), process.Command())
+			}
+		})
+	}
+}
@@ -44,6 +44,7 @@ func newHarvester(ctx agent.AgentContext, cache *cache) *linuxHarvester {
 		stripCommandLine:     stripCommandLine,
 		serviceForPid:        ctx.GetServiceForPid,
 		cache:                cache,
+		filter:               newFilter(cfg, stripCommandLine),
 	}
 }
 
@@ -54,6 +55,8 @@ type linuxHarvester struct {
 	stripCommandLine     bool
 	cache                *cache
 	serviceForPid        func(int) (string, bool)
+	// filter selects the sampled processes, nil to sample all of them
+	filter *filter
 }
 
 var _ Harvester = (*linuxHarvester)(nil) // static interface assertion
@@ -85,6 +88,20 @@ func (ps *linuxHarvester) Do(pid int32, elapsedSeconds float64) (*types.ProcessS
 		return nil, errors.New("process with zero rss")
 	}
 
+	if ps.filter != nil {
+		// The filter is only evaluated again if the snapshot isn't reused, as the process may have changed
+		if cached.filtered != cached.process {
+			cached.excluded = !ps.filter.accepts(cached.process)
+			cached.filtered = cached.process
+		}
+		if cached.excluded {
+			if !hasCachedSample {
+				ps.cache.Add(pid, cached)
+			}
+			return nil, errProcessExcluded
+		}
+	}
+
 	// Creates a fresh process sample and populates it with the metrics data
 	sample := metrics.NewProcessSample(pid)
 
@@ -165,6 +182,13 @@ func (ps *linuxHarvester) populateGauges(sample *types.ProcessSample, process Sn
 		if fds >= 0 {
 			sample.FdCount = &fds
 		}
+		// a missing limit shouldn't discard the rest of the sample
+		maxFDs, err := process.MaxFDs()
+		if err != nil {
+			mplog.WithError(err).WithField("processID", sample.ProcessID).Debug("Can't get file descriptor limit for process.")
+		} else if maxFDs >= 0 {
+			sample.FdLimit = &maxFDs
+		}
 	}
 
 	// Extra status data
@@ -172,6 +196,13 @@ func (ps *linuxHarvester) populateGauges(sample *types.ProcessSample, process Sn
 	sample.ThreadCount = process.NumThreads()
 	sample.MemoryVMSBytes = process.VmSize()
 	sample.MemoryRSSBytes = process.VmRSS()
+	// a missing swap size shouldn't discard the rest of the sample
+	swap, err := process.VmSwap()
+	if err != nil {
+		mplog.WithError(err).WithField("processID", sample.ProcessID).Debug("Can't get swap size for process.")
+	} else if swap >= 0 {
+		sample.MemorySwapBytes = &swap
+	}
 
 	return nil
 }
@@ -42,9 +42,11 @@ func TestLinuxHarvester_IsPrivileged(t *testing.T) {
 			require.NoError(t, err)
 			if c.privileged {
 				assert.NotNil(t, sample.FdCount)
+				assert.NotNil(t, sample.FdLimit)
 				assert.NotNil(t, sample.IOTotalReadCount)
 			} else {
 				assert.Nil(t, sample.FdCount)
+				assert.Nil(t, sample.FdLimit)
 				assert.Nil(t, sample.IOTotalReadCount)
 			}
 		})
@@ -269,3 +271,43 @@ func TestLinuxHarvester_GetServiceForPid_OnEmptyUseCommandName(t *testing.T) {
 	assert.Equal(t, "process.test", sample.CommandName)
 	assert.Contains(t, sample.CmdLine, os.Args[0])
 }
+
+func TestLinuxHarvester_Do_Filter(t *testing.T) {
+	currentPid := int32(os.Getpid())
+	cases := []struct {
+		name     string
+		cfg      *config.Config
+		excluded bool
+	}{
+		{"included by name", &config.Config{ProcessIncludeNames: []string{`^process\.test$`}}, false},
+		{"not included", &config.Config{ProcessIncludeNames: []string{"^nginx$"}}, true},
+		{"excluded by command line", &config.Config{ProcessExcludeCmdlines: []string{`process\.test`}}, true},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			// Given a process harvester with a process filter
+			ctx := new(mocks.AgentContext)
+			ctx.On("Config").Return(c.cfg)
+			ctx.On("GetServiceForPid", mock.Anything).Return("", false)
+			cache := newCache()
+			h := newHarvester(ctx, &cache)
+
+			// When the process is harvested twice
+			for i := 0; i < 2; i++ {
+				sample, err := h.Do(currentPid, 0)
+
+				// The excluded processes are not sampled, but their snapshot is cached
+				if c.excluded {
+					assert.Equal(t, errProcessExcluded, err)
+					assert.Nil(t, sample)
+					entry, ok := cache.Get(currentPid)
+					require.True(t, ok)
+					assert.True(t, entry.excluded)
+				} else {
+					require.NoError(t, err)
+					assert.Equal(t, currentPid, sample.ProcessID)
+				}
+			}
+		})
+	}
+}
@@ -25,6 +25,8 @@ type processSampler struct {
 	hasAlreadyRun    bool
 	interval         time.Duration
 	cache            *cache
+	// topN selects the reported processes, nil to report all of them
+	topN *topN
 }
 
 var (
@@ -38,22 +40,32 @@ func NewProcessSampler(ctx agent.AgentContext) sampler.Sampler {
 
 	ttlSecs := config.DefaultContainerCacheMetadataLimit
 	apiVersion := ""
+	runtimeEndpoint := ""
 	interval := config.FREQ_INTERVAL_FLOOR_PROCESS_METRICS
+	var top *topN
 	if hasConfig {
 		cfg := ctx.Config()
 		ttlSecs = cfg.ContainerMetadataCacheLimit
 		apiVersion = cfg.DockerApiVersion
+		runtimeEndpoint = cfg.ContainerRuntimeEndpoint
 		interval = cfg.MetricsProcessSampleRate
+		top = newTopN(cfg)
 	}
 	cache := newCache()
 	harvest := newHarvester(ctx, &cache)
-	dockerSampler := metrics.NewDockerSampler(time.Duration(ttlSecs)*time.Second, apiVersion)
+	ttl := time.Duration(ttlSecs) * time.Second
+	// processes of the containers not managed by Docker are decorated through the CRI API
+	containerSampler := metrics.NewContainerSamplers(
+		metrics.NewDockerSampler(ttl, apiVersion),
+		metrics.NewCRISampler(ttl, runtimeEndpoint),
+	)
 
 	return &processSampler{
 		harvest:          harvest,
-		containerSampler: dockerSampler,
+		containerSampler: containerSampler,
 		cache:            &cache,
 		interval:         time.Second * time.Duration(interval),
+		topN:             top,
 	}
 
 }
@@ -72,7 +84,7 @@ func (ps *processSampler) Disabled() bool {
 	return ps.Interval() <= config.FREQ_DISABLE_SAMPLING
 }
 
-// Sample returns samples for all the running processes, decorated with Docker runtime information, if applies.
+// Sample returns samples for all the running processes, decorated with Docker or CRI runtime information, if applies.
 func (ps *processSampler) Sample() (results sample.EventBatch, err error) {
 	var elapsedMs int64
 	var elapsedSeconds float64
@@ -106,22 +118,34 @@ func (ps *processSampler) Sample() (results sample.EventBatch, err error) {
 		}
 	}
 
+	processSamples := make([]*types.ProcessSample, 0, len(pids))
 	for _, pid := range pids {
-		var processSample *types.ProcessSample
-		var err error
-
-		processSample, err = ps.harvest.Do(pid, elapsedSeconds)
+		processSample, err := ps.harvest.Do(pid, elapsedSeconds)
+		if err == errProcessExcluded {
+			continue
+		}
 		if err != nil {
 			mplog.WithError(err).WithField("pid", pid).Debug("Skipping process.")
 			continue
 		}
+		processSamples = append(processSamples, processSample)
+	}
 
+	var other *types.ProcessSample
+	if ps.topN != nil {
+		processSamples, other = ps.topN.selectSamples(processSamples)
+	}
+
+	for _, processSample := range processSamples {
 		if dockerDecorator != nil {
 			dockerDecorator.Decorate(processSample)
 		}
 
 		results = append(results, ps.normalizeSample(processSample))
 	}
+	if other != nil {
+		results = append(results, other)
+	}
 
 	ps.cache.items.RemoveUntilLen(len(pids))
 	ps.hasAlreadyRun = true
@@ -66,6 +66,34 @@ func TestProcessSampler_DockerDecorator(t *testing.T) {
 	}
 }
 
+func TestProcessSampler_TopN(t *testing.T) {
+	// Given a Process Sampler reporting the top process
+	ctx := new(mocks.AgentContext)
+	ctx.On("Config").Return(&config.Config{ProcessTopN: 1})
+	ctx.On("GetServiceForPid", mock.Anything).Return("", false)
+	ps := NewProcessSampler(ctx).(*processSampler)
+	ps.harvest = &harvesterMock{samples: map[int32]*types.ProcessSample{
+		1: {ProcessID: 1, ProcessDisplayName: "idle", CPUPercent: 1, MemoryRSSBytes: 10},
+		2: {ProcessID: 2, ProcessDisplayName: "busy", CPUPercent: 90, MemoryRSSBytes: 1000},
+		3: {ProcessID: 3, ProcessDisplayName: "sleeping", MemoryRSSBytes: 20},
+	}}
+	ps.containerSampler = &fakeContainerSampler{}
+
+	// When asking for the process samples
+	samples, err := ps.Sample()
+	require.NoError(t, err)
+
+	// The top process is decorated and the rest rolled up
+	require.Len(t, samples, 2)
+	flatProcessSample := samples[0].(*types.FlatProcessSample)
+	assert.Equal(t, "busy", (*flatProcessSample)["processDisplayName"])
+	assert.Equal(t, "decorated", (*flatProcessSample)["containerImage"])
+	other := samples[1].(*types.ProcessSample)
+	assert.Equal(t, "other", other.ProcessDisplayName)
+	assert.Equal(t, 2, *other.ProcessCount)
+	assert.Equal(t, int64(30), other.MemoryRSSBytes)
+}
+
 type harvesterMock struct {
 	samples map[int32]*types.ProcessSample
 }
@@ -51,10 +51,14 @@ type Snapshot interface {
 	NumThreads() int32
 	// NumFDs returns the number of File Descriptors that are open by the process
 	NumFDs() (int32, error)
+	// MaxFDs returns the soft limit of File Descriptors that can be opened by the process
+	MaxFDs() (int64, error)
 	// VmRSS returns the Resident Set Size (memory in RAM) of the process
 	VmRSS() int64
 	// VmSize returns the total memory of the process (RSS + virtual memory)
 	VmSize() int64
+	// VmSwap returns the memory of the process swapped out, or -1 if it isn't reported (e.g. kernel threads)
+	VmSwap() (int64, error)
 }
 
 // linuxProcess is an implementation of the process.Snapshot interface for linux hosts. It is designed to be highly
@@ -86,7 +90,7 @@ func init() {
 		pageSize = 4096 // default value
 	}
 
-	clockTicks = int64(cpu.CPUTick)
+	clockTicks = int64(cpu.ClocksPerSec)
 	if clockTicks <= 0 {
 		clockTicks = 100 // default value
 	}
@@ -168,6 +172,39 @@ func (pw *linuxProcess) NumFDs() (int32, error) {
 	return int32(len(fnames)), nil
 }
 
+// MaxFDs returns the soft limit of open file descriptors, from /proc/<pid>/limits. It returns -1 (and nil error) if
+// the Agent does not have privileges to access the file descriptors of the process, or if the limit is unlimited.
+func (pw *linuxProcess) MaxFDs() (int64, error) {
+	if !pw.privileged {
+		return -1, nil
+	}
+	content, err := ioutil.ReadFile(helpers.HostProc(strconv.Itoa(int(pw.pid)), "limits"))
+	if err != nil {
+		return 0, err
+	}
+	return parseMaxOpenFiles(string(content))
+}
+
+// parseMaxOpenFiles returns the soft limit of the "Max open files" row of a /proc/<pid>/limits file, e.g.
+// "Max open files            1024                 1048576              files", or -1 if unlimited.
+func parseMaxOpenFiles(content string) (int64, error) {
+	const row = "Max open files"
+	for _, line := range strings.Split(content, "\n") {
+		if !strings.HasPrefix(line, row) {
+			continue
+		}
+		fields := strings.Fields(line[len(row):])
+		if len(fields) == 0 {
+			break
+		}
+		if fields[0] == "unlimited" {
+			return -1, nil
+		}
+		return strconv.ParseInt(fields[0], 10, 64)
+	}
+	return 0, fmt.Errorf("could not find %q in process limits", row)
+}
+
 /////////////////////////////
 // Data to be derived from /proc/<pid>/stat
 /////////////////////////////
@@ -323,6 +360,35 @@ func (pw *linuxProcess) VmSize() int64 {
 	return pw.stats.vmSize
 }
 
+// VmSwap reads the swapped out memory of the process from /proc/<pid>/status.
+func (pw *linuxProcess) VmSwap() (int64, error) {
+	content, err := ioutil.ReadFile(helpers.HostProc(strconv.Itoa(int(pw.pid)), "status"))
+	if err != nil {
+		return 0, err
+	}
+	return parseVmSwap(string(content))
+}
+
+// parseVmSwap returns the bytes of the "VmSwap:    1234 kB" line of a /proc/<pid>/status file, or -1 if
+// the line is missing.
+func parseVmSwap(content string) (int64, error) {
+	for _, line := range strings.Split(content, "\n") {
+		if !strings.HasPrefix(line, "VmSwap:") {
+			continue
+		}
+		fields := strings.Fields(line[len("VmSwap:"):])
+		if len(fields) == 0 {
+			return 0, fmt.Errorf("malformed status line: %q", line)
+		}
+		kb, err := strconv.ParseInt(fields[0], 10, 64)
+		if err != nil {
+			return 0, err
+		}
+		return kb * 1024, nil
+	}
+	return -1, nil
+}
+
 func (pw *linuxProcess) Command() string {
 	return pw.stats.command
 }
@@ -190,3 +190,40 @@ func TestParseProcStatUntrimmedCommand(t *testing.T) {
 		})
 	}
 }
+
+func TestParseMaxOpenFiles(t *testing.T) {
+	limits := `Limit                     Soft Limit           Hard Limit           Units
+Max cpu time              unlimited            unlimited            seconds
+Max open files            1024                 1048576              files
+Max locked memory         8388608              8388608              bytes
+`
+	limit, err := parseMaxOpenFiles(limits)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1024), limit)
+
+	limit, err = parseMaxOpenFiles("Max open files            unlimited            unlimited            files\n")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(-1), limit)
+
+	_, err = parseMaxOpenFiles("Max cpu time              unlimited            unlimited            seconds\n")
+	assert.Error(t, err)
+}
+
+func TestParseVmSwap(t *testing.T) {
+	status := `Name:	java
+VmRSS:	  204800 kB
+VmSwap:	    1536 kB
+Threads:	42
+`
+	swap, err := parseVmSwap(status)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1536*1024), swap)
+
+	// kernel threads don't report memory
+	swap, err = parseVmSwap("Name:	kworker/0:1\nThreads:	1\n")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(-1), swap)
+
+	_, err = parseVmSwap("VmSwap:	many kB\n")
+	assert.Error(t, err)
+}
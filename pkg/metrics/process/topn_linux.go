@@ -0,0 +1,112 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package process
+
+import (
+	"regexp"
+	"sort"
+
+	"github.com/newrelic/infrastructure-agent/pkg/config"
+	"github.com/newrelic/infrastructure-agent/pkg/metrics"
+	"github.com/newrelic/infrastructure-agent/pkg/metrics/types"
+)
+
+// otherProcessName names the sample rolling up the processes not reported in the top-N mode.
+const otherProcessName = "other"
+
+// topN selects the processes reported in the top-N mode, from the process_top_n options.
+type topN struct {
+	n     int
+	names []*regexp.Regexp
+}
+
+// newTopN returns the top-N selection of the configuration, nil if the mode is disabled.
+func newTopN(cfg *config.Config) *topN {
+	if cfg == nil || cfg.ProcessTopN <= 0 {
+		return nil
+	}
+	return &topN{
+		n:     cfg.ProcessTopN,
+		names: compilePatterns("process_top_n_include_names", cfg.ProcessTopNIncludeNames),
+	}
+}
+
+// selectSamples returns the top processes by CPU and by memory and those whose name or display name match,
+// keeping their order, and the rest rolled up into a single sample, nil if there are none.
+func (t *topN) selectSamples(samples []*types.ProcessSample) ([]*types.ProcessSample, *types.ProcessSample) {
+	if len(samples) <= t.n {
+		return samples, nil
+	}
+	reported := make(map[*types.ProcessSample]bool, 2*t.n)
+	byUsage := make([]*types.ProcessSample, len(samples))
+	copy(byUsage, samples)
+	sort.SliceStable(byUsage, func(i, j int) bool { return byUsage[i].CPUPercent > byUsage[j].CPUPercent })
+	for _, s := range byUsage[:t.n] {
+		reported[s] = true
+	}
+	sort.SliceStable(byUsage, func(i, j int) bool { return byUsage[i].MemoryRSSBytes > byUsage[j].MemoryRSSBytes })
+	for _, s := range byUsage[:t.n] {
+		reported[s] = true
+	}
+
+	var selected []*types.ProcessSample
+	var other *types.ProcessSample
+	for _, s := range samples {
+		if reported[s] || matchAny(t.names, s.CommandName) || matchAny(t.names, s.ProcessDisplayName) {
+			selected = append(selected, s)
+			continue
+		}
+		if other == nil {
+			other = newOtherSample()
+		}
+		rollUp(other, s)
+	}
+	return selected, other
+}
+
+func newOtherSample() *types.ProcessSample {
+	s := metrics.NewProcessSample(0)
+	s.ProcessDisplayName = otherProcessName
+	s.CommandName = otherProcessName
+	count := 0
+	s.ProcessCount = &count
+	s.Type("ProcessSample")
+	return s
+}
+
+// rollUp adds the metrics of a process to the "other" sample.
+func rollUp(other, s *types.ProcessSample) {
+	*other.ProcessCount++
+	other.MemoryRSSBytes += s.MemoryRSSBytes
+	other.MemoryVMSBytes += s.MemoryVMSBytes
+	if s.MemorySwapBytes != nil {
+		if other.MemorySwapBytes == nil {
+			other.MemorySwapBytes = new(int64)
+		}
+		*other.MemorySwapBytes += *s.MemorySwapBytes
+	}
+	other.CPUPercent += s.CPUPercent
+	other.CPUUserPercent += s.CPUUserPercent
+	other.CPUSystemPercent += s.CPUSystemPercent
+	other.ThreadCount += s.ThreadCount
+	if s.FdCount != nil {
+		if other.FdCount == nil {
+			other.FdCount = new(int32)
+		}
+		*other.FdCount += *s.FdCount
+	}
+	addRate(&other.IOReadCountPerSecond, s.IOReadCountPerSecond)
+	addRate(&other.IOWriteCountPerSecond, s.IOWriteCountPerSecond)
+	addRate(&other.IOReadBytesPerSecond, s.IOReadBytesPerSecond)
+	addRate(&other.IOWriteBytesPerSecond, s.IOWriteBytesPerSecond)
+}
+
+func addRate(total **float64, value *float64) {
+	if value == nil {
+		return
+	}
+	if *total == nil {
+		*total = new(float64)
+	}
+	**total += *value
+}
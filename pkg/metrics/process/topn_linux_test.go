@@ -0,0 +1,68 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package process
+
+import (
+	"testing"
+
+	"github.com/newrelic/infrastructure-agent/pkg/config"
+	"github.com/newrelic/infrastructure-agent/pkg/metrics/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewTopN_Disabled(t *testing.T) {
+	assert.Nil(t, newTopN(nil))
+	assert.Nil(t, newTopN(&config.Config{}))
+	assert.Nil(t, newTopN(&config.Config{ProcessTopN: -1}))
+}
+
+func TestTopN_SelectSamples(t *testing.T) {
+	fds := int32(10)
+	swap := int64(1024)
+	rate := 2.5
+	sample := func(pid int32, name string, cpu float64, rss int64) *types.ProcessSample {
+		return &types.ProcessSample{ProcessID: pid, CommandName: name, ProcessDisplayName: name, CPUPercent: cpu,
+			MemoryRSSBytes: rss, ThreadCount: 2, FdCount: &fds, MemorySwapBytes: &swap, IOReadBytesPerSecond: &rate}
+	}
+	// GIVEN a CPU hungry process, a memory hungry one, a matched one and two idle ones
+	samples := []*types.ProcessSample{
+		sample(1, "systemd", 0.1, 100),
+		sample(2, "ffmpeg", 95, 200),
+		sample(3, "java", 3, 4000),
+		sample(4, "sshd", 0.2, 50),
+		sample(5, "nginx", 0, 10),
+	}
+	top := newTopN(&config.Config{ProcessTopN: 1, ProcessTopNIncludeNames: []string{"^nginx$"}})
+
+	// WHEN the top process by CPU and memory are selected
+	selected, other := top.selectSamples(samples)
+
+	// THEN they are reported, along with the matched one, in the original order
+	require.Len(t, selected, 3)
+	assert.Equal(t, int32(2), selected[0].ProcessID)
+	assert.Equal(t, int32(3), selected[1].ProcessID)
+	assert.Equal(t, int32(5), selected[2].ProcessID)
+
+	// AND the rest are rolled up
+	require.NotNil(t, other)
+	assert.Equal(t, "ProcessSample", other.EventType)
+	assert.Equal(t, otherProcessName, other.ProcessDisplayName)
+	assert.Equal(t, 2, *other.ProcessCount)
+	assert.InDelta(t, 0.3, other.CPUPercent, 0.0001)
+	assert.Equal(t, int64(150), other.MemoryRSSBytes)
+	assert.Equal(t, int32(4), other.ThreadCount)
+	assert.Equal(t, int32(20), *other.FdCount)
+	assert.Equal(t, int64(2048), *other.MemorySwapBytes)
+	assert.Equal(t, 5.0, *other.IOReadBytesPerSecond)
+	assert.Nil(t, other.IOWriteBytesPerSecond)
+}
+
+func TestTopN_SelectSamples_Few(t *testing.T) {
+	samples := []*types.ProcessSample{{ProcessID: 1}, {ProcessID: 2}}
+
+	selected, other := newTopN(&config.Config{ProcessTopN: 2}).selectSamples(samples)
+
+	assert.Equal(t, samples, selected)
+	assert.Nil(t, other)
+}
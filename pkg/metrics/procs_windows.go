@@ -41,7 +41,9 @@ var (
 	getProcessIoCounters = modkernel32.NewProc("GetProcessIoCounters")
 	// https://docs.microsoft.com/en-us/windows/desktop/api/winbase/nf-winbase-queryfullprocessimagenamew
 	queryFullProcessImageName = modkernel32.NewProc("QueryFullProcessImageNameW")
-	containerNotRunningErrs   = map[string]struct{}{}
+	// https://docs.microsoft.com/en-us/windows/win32/api/processthreadsapi/nf-processthreadsapi-getprocesshandlecount
+	getProcessHandleCount   = modkernel32.NewProc("GetProcessHandleCount")
+	containerNotRunningErrs = map[string]struct{}{}
 )
 
 const (
@@ -292,7 +294,7 @@ type ProcsMonitor struct {
 	previousProcessTimes map[string]*SystemTimes
 	stopChannel          chan bool
 	waitForCleanup       *sync.WaitGroup
-	getAllProcs          func() ([]process.Win32_Process, error)
+	getAllProcs          func() ([]Win32_Process, error)
 	getMemoryInfo        func(int32) (*MemoryInfoStat, error)
 	getStatus            func(int32) (string, error)
 	getUsername          func(int32) (string, error)
@@ -392,6 +394,16 @@ func getProcessIo(handle syscall.Handle) (*ioCounters, error) {
 	return io, nil
 }
 
+func getProcessHandles(handle syscall.Handle) (uint32, error) {
+	var count uint32
+	r1, _, err := getProcessHandleCount.Call(uintptr(handle), uintptr(unsafe.Pointer(&count)))
+	if r1 == 0 {
+		return 0, err
+	}
+
+	return count, nil
+}
+
 func getWin32APIProcessPath(handle syscall.Handle) (*string, error) {
 	// We are calling the Unicode version, so the string must be 16-bit
 	bufferSize := uint32(syscall.MAX_PATH)
@@ -407,6 +419,20 @@ func getWin32APIProcessPath(handle syscall.Handle) (*string, error) {
 	return &path, nil
 }
 
+// Win32_Process holds the Win32_Process WMI class fields the process sampler reports.
+type Win32_Process struct {
+	Name                string
+	ExecutablePath      *string
+	CreationDate        *time.Time
+	ProcessID           uint32
+	ThreadCount         uint32
+	ReadOperationCount  uint64
+	ReadTransferCount   uint64
+	WriteOperationCount uint64
+	WriteTransferCount  uint64
+	HandleCount         uint32
+}
+
 type win32_CommandLine struct {
 	CommandLine string
 }
@@ -425,7 +451,7 @@ func getProcessCommandLineWMI(processId uint32) (string, error) {
 	return dst[0].CommandLine, nil
 }
 
-func getWin32Proc(process *process.Win32_Process, path processPathProvider) error {
+func getWin32Proc(process *Win32_Process, path processPathProvider) error {
 
 	// https://docs.microsoft.com/en-us/windows/desktop/api/processthreadsapi/nf-processthreadsapi-openprocess
 	proc, err := syscall.OpenProcess(PROCESS_QUERY_LIMITED_INFORMATION, false, process.ProcessID)
@@ -448,6 +474,11 @@ func getWin32Proc(process *process.Win32_Process, path processPathProvider) erro
 	process.WriteOperationCount = io.WriteOperationCount
 	process.WriteTransferCount = io.WriteTransferCount
 
+	process.HandleCount, err = getProcessHandles(proc)
+	if err != nil {
+		pslog.WithError(err).WithField("process_id", process.ProcessID).Debug("Cannot query handle count.")
+	}
+
 	process.ExecutablePath, err = path(proc)
 	if err != nil {
 		emptyExecutablePath := ""
@@ -464,9 +495,9 @@ func getWin32Proc(process *process.Win32_Process, path processPathProvider) erro
 }
 
 // We return a func for testing purpose so we can easily mock the path provider
-func getAllWin32Procs(path processPathProvider) func() ([]process.Win32_Process, error) {
-	return func() ([]process.Win32_Process, error) {
-		var result []process.Win32_Process
+func getAllWin32Procs(path processPathProvider) func() ([]Win32_Process, error) {
+	return func() ([]Win32_Process, error) {
+		var result []Win32_Process
 
 		// https://docs.microsoft.com/en-us/windows/desktop/api/tlhelp32/nf-tlhelp32-createtoolhelp32snapshot
 		snapshot, err := syscall.CreateToolhelp32Snapshot(syscall.TH32CS_SNAPPROCESS, 0)
@@ -486,7 +517,7 @@ func getAllWin32Procs(path processPathProvider) func() ([]process.Win32_Process,
 		for {
 			// Idle process isn't actually a process, so we can't get information from it
 			if entry.ProcessID != 0 {
-				proc := process.Win32_Process{
+				proc := Win32_Process{
 					Name:        syscall.UTF16ToString(entry.ExeFile[:]),
 					ProcessID:   entry.ProcessID,
 					ThreadCount: entry.Threads,
@@ -517,7 +548,7 @@ func getAllWin32Procs(path processPathProvider) func() ([]process.Win32_Process,
 	}
 }
 
-func logSampleError(pid int32, winProc process.Win32_Process, err error, message string) {
+func logSampleError(pid int32, winProc Win32_Process, err error, message string) {
 	pslog.WithError(err).WithFieldsF(func() logrus.Fields {
 		return logrus.Fields{
 			"pid":     pid,
@@ -727,6 +758,11 @@ func (self *ProcsMonitor) Sample() (results sample.EventBatch, err error) {
 				self.previousProcessTimes[pidAndCreationDate] = currentProcessTime
 
 				sample.ThreadCount = int32(winProc.ThreadCount)
+				// zero handles means they couldn't be queried, as no process can run without them
+				if winProc.HandleCount > 0 {
+					handleCount := winProc.HandleCount
+					sample.HandleCount = &handleCount
+				}
 
 				ioCounters := &process.IOCountersStat{
 					ReadCount:  uint64(winProc.ReadOperationCount),
@@ -0,0 +1,178 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+// Package rds samples the Remote Desktop Services (terminal services) sessions of a Windows host,
+// reporting a SessionSample with the state, user and resources usage of each session.
+package rds
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/newrelic/infrastructure-agent/pkg/sample"
+)
+
+// User anonymization modes.
+const (
+	AnonymizeNone   = "none"   // the user and domain names are reported as they are
+	AnonymizeHash   = "hash"   // the user and domain names are replaced by a hash of their values
+	AnonymizeRedact = "redact" // the user and domain names are not reported
+)
+
+// Session states, as reported by the WTS_CONNECTSTATE_CLASS enumeration.
+var sessionStates = []string{
+	"active",
+	"connected",
+	"connectQuery",
+	"shadow",
+	"disconnected",
+	"idle",
+	"listen",
+	"reset",
+	"down",
+	"init",
+}
+
+const (
+	stateListen = 6
+	// servicesSessionID is the session where the services run, not bound to any user.
+	servicesSessionID = 0
+)
+
+// SessionSample holds the state and resources usage of a remote desktop session.
+type SessionSample struct {
+	sample.BaseEvent
+
+	SessionID   uint32 `json:"sessionId"`
+	SessionName string `json:"sessionName,omitempty"`
+	State       string `json:"state"`
+	UserName    string `json:"userName,omitempty"`
+	DomainName  string `json:"domainName,omitempty"`
+	ClientName  string `json:"clientName,omitempty"`
+
+	ProcessCount          int     `json:"processCount"`
+	CPUPercent            float64 `json:"cpuPercent"`
+	MemoryWorkingSetBytes uint64  `json:"memoryWorkingSetBytes"`
+	MemoryPagefileBytes   uint64  `json:"memoryPagefileBytes"`
+}
+
+// session as reported by the terminal services API.
+type session struct {
+	id         uint32
+	name       string
+	state      uint32
+	userName   string
+	domainName string
+	clientName string
+}
+
+// processUsage is the resources usage of a process, along with the session it belongs to.
+type processUsage struct {
+	sessionID  uint32
+	workingSet uint64
+	pagefile   uint64
+	cpuTime    time.Duration // user + kernel time
+}
+
+// isUserSession returns false for the services session and the listener sessions.
+func (s *session) isUserSession() bool {
+	return s.id != servicesSessionID && s.state != stateListen
+}
+
+func stateName(state uint32) string {
+	if int(state) < len(sessionStates) {
+		return sessionStates[state]
+	}
+	return fmt.Sprintf("unknown(%d)", state)
+}
+
+// anonymizer returns a function that anonymizes user attribution values according to the passed mode.
+// Unknown modes redact the values.
+func anonymizer(mode string) func(string) string {
+	switch mode {
+	case "", AnonymizeNone:
+		return func(value string) string { return value }
+	case AnonymizeHash:
+		return func(value string) string {
+			if value == "" {
+				return ""
+			}
+			return fmt.Sprintf("%x", sha256.Sum256([]byte(value)))[:16]
+		}
+	}
+	return func(string) string { return "" }
+}
+
+// cpuTracker calculates the CPU usage of each session from the accumulated CPU time of its
+// processes between two samples.
+type cpuTracker struct {
+	numCPU   int
+	lastRun  time.Time
+	lastTime map[uint32]time.Duration
+}
+
+// usage returns the CPU percent, normalized by the number of CPUs, used by each session since
+// the previous call. The first call doesn't report any usage.
+func (t *cpuTracker) usage(now time.Time, procs []processUsage) map[uint32]float64 {
+	cpuTime := map[uint32]time.Duration{}
+	for _, p := range procs {
+		cpuTime[p.sessionID] += p.cpuTime
+	}
+
+	percents := map[uint32]float64{}
+	elapsed := now.Sub(t.lastRun)
+	if t.lastTime != nil && elapsed > 0 && t.numCPU > 0 {
+		for id, current := range cpuTime {
+			previous, ok := t.lastTime[id]
+			// processes may have exited, decreasing the accumulated time
+			if !ok || current < previous {
+				continue
+			}
+			percents[id] = 100 * float64(current-previous) / float64(elapsed) / float64(t.numCPU)
+		}
+	}
+
+	t.lastRun = now
+	t.lastTime = cpuTime
+	return percents
+}
+
+// newSessionSamples creates a sample for each user session, aggregating the usage of their processes.
+func newSessionSamples(sessions []session, procs []processUsage, cpuPercents map[uint32]float64, anonymize func(string) string) []*SessionSample {
+	samples := map[uint32]*SessionSample{}
+	for _, s := range sessions {
+		if !s.isUserSession() {
+			continue
+		}
+		samples[s.id] = &SessionSample{
+			BaseEvent:   sample.BaseEvent{EventType: "SessionSample"},
+			SessionID:   s.id,
+			SessionName: s.name,
+			State:       stateName(s.state),
+			UserName:    anonymize(s.userName),
+			DomainName:  anonymize(s.domainName),
+			ClientName:  anonymize(s.clientName),
+			CPUPercent:  cpuPercents[s.id],
+		}
+	}
+
+	for _, p := range procs {
+		s, ok := samples[p.sessionID]
+		if !ok {
+			continue
+		}
+		s.ProcessCount++
+		s.MemoryWorkingSetBytes += p.workingSet
+		s.MemoryPagefileBytes += p.pagefile
+	}
+
+	result := make([]*SessionSample, 0, len(samples))
+	for _, s := range samples {
+		result = append(result, s)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].SessionID < result[j].SessionID
+	})
+	return result
+}
@@ -0,0 +1,87 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package rds
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewSessionSamples(t *testing.T) {
+	// GIVEN the services, listener and two user sessions
+	sessions := []session{
+		{id: 0, name: "Services", state: 4},
+		{id: 2, name: "RDP-Tcp#3", state: 0, userName: "alice", domainName: "CORP", clientName: "LAPTOP-1"},
+		{id: 3, state: 4, userName: "bob", domainName: "CORP"},
+		{id: 65536, name: "RDP-Tcp", state: stateListen},
+	}
+	procs := []processUsage{
+		{sessionID: 0, workingSet: 1000, pagefile: 1000},
+		{sessionID: 2, workingSet: 100, pagefile: 10},
+		{sessionID: 2, workingSet: 200, pagefile: 20},
+		{sessionID: 3, workingSet: 50, pagefile: 5},
+	}
+
+	// WHEN the samples are created
+	samples := newSessionSamples(sessions, procs, map[uint32]float64{2: 12.5}, anonymizer(AnonymizeNone))
+
+	// THEN only the user sessions are reported, with the usage of their processes
+	require.Len(t, samples, 2)
+	assert.Equal(t, &SessionSample{
+		BaseEvent:             samples[0].BaseEvent,
+		SessionID:             2,
+		SessionName:           "RDP-Tcp#3",
+		State:                 "active",
+		UserName:              "alice",
+		DomainName:            "CORP",
+		ClientName:            "LAPTOP-1",
+		ProcessCount:          2,
+		CPUPercent:            12.5,
+		MemoryWorkingSetBytes: 300,
+		MemoryPagefileBytes:   30,
+	}, samples[0])
+	assert.Equal(t, "SessionSample", samples[0].EventType)
+	assert.Equal(t, uint32(3), samples[1].SessionID)
+	assert.Equal(t, "disconnected", samples[1].State)
+	assert.Equal(t, 1, samples[1].ProcessCount)
+}
+
+func TestAnonymizer(t *testing.T) {
+	assert.Equal(t, "alice", anonymizer(AnonymizeNone)("alice"))
+	assert.Equal(t, "alice", anonymizer("")("alice"))
+	assert.Equal(t, "", anonymizer(AnonymizeRedact)("alice"))
+	assert.Equal(t, "", anonymizer("unknown")("alice"))
+
+	hashed := anonymizer(AnonymizeHash)("alice")
+	assert.Len(t, hashed, 16)
+	assert.NotContains(t, hashed, "alice")
+	assert.Equal(t, hashed, anonymizer(AnonymizeHash)("alice"))
+	assert.Empty(t, anonymizer(AnonymizeHash)(""))
+}
+
+func TestCPUTracker_Usage(t *testing.T) {
+	tracker := cpuTracker{numCPU: 2}
+	now := time.Now()
+
+	// GIVEN a first sample, that can't report usage
+	assert.Empty(t, tracker.usage(now, []processUsage{
+		{sessionID: 2, cpuTime: time.Second},
+		{sessionID: 3, cpuTime: 5 * time.Second},
+	}))
+
+	// WHEN a session processes used 1 CPU second in 10 seconds
+	percents := tracker.usage(now.Add(10*time.Second), []processUsage{
+		{sessionID: 2, cpuTime: time.Second},
+		{sessionID: 2, cpuTime: time.Second},
+		{sessionID: 3, cpuTime: time.Second},
+	})
+
+	// THEN its usage is normalized by the number of CPUs
+	assert.InDelta(t, 5.0, percents[2], 0.001)
+	// AND the sessions whose processes exited are not reported
+	_, ok := percents[3]
+	assert.False(t, ok)
+}
@@ -0,0 +1,207 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+// +build windows
+
+package rds
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"github.com/newrelic/infrastructure-agent/internal/agent"
+	"github.com/newrelic/infrastructure-agent/internal/windows"
+	"github.com/newrelic/infrastructure-agent/pkg/config"
+	"github.com/newrelic/infrastructure-agent/pkg/log"
+	"github.com/newrelic/infrastructure-agent/pkg/sample"
+)
+
+var (
+	rlog = log.WithComponent("SessionSampler")
+
+	modwtsapi32                     = syscall.NewLazyDLL("wtsapi32.dll")
+	procWTSEnumerateSessionsW       = modwtsapi32.NewProc("WTSEnumerateSessionsW")
+	procWTSQuerySessionInformationW = modwtsapi32.NewProc("WTSQuerySessionInformationW")
+	procWTSEnumerateProcessesExW    = modwtsapi32.NewProc("WTSEnumerateProcessesExW")
+	procWTSFreeMemory               = modwtsapi32.NewProc("WTSFreeMemory")
+	procWTSFreeMemoryExW            = modwtsapi32.NewProc("WTSFreeMemoryExW")
+)
+
+const (
+	wtsCurrentServerHandle = 0
+	wtsAnySession          = 0xFFFFFFFE
+	// WTS_INFO_CLASS values
+	wtsUserName   = 5
+	wtsDomainName = 7
+	wtsClientName = 10
+	// WTS_TYPE_CLASS value for the WTS_PROCESS_INFO_EX structures
+	wtsTypeProcessInfoLevel1 = 1
+)
+
+// wtsSessionInfo mirrors the WTS_SESSION_INFOW structure.
+type wtsSessionInfo struct {
+	SessionID      uint32
+	WinStationName *uint16
+	State          uint32
+}
+
+// wtsProcessInfoEx mirrors the WTS_PROCESS_INFO_EXW structure.
+type wtsProcessInfoEx struct {
+	SessionID          uint32
+	ProcessID          uint32
+	ProcessName        *uint16
+	UserSid            uintptr
+	NumberOfThreads    uint32
+	HandleCount        uint32
+	PagefileUsage      uint32
+	PeakPagefileUsage  uint32
+	WorkingSetSize     uint32
+	PeakWorkingSetSize uint32
+	UserTime           int64 // in 100-nanosecond units
+	KernelTime         int64 // in 100-nanosecond units
+}
+
+// Sampler reports a SessionSample for each user session of a Remote Desktop Services host.
+type Sampler struct {
+	context   agent.AgentContext
+	interval  time.Duration
+	anonymize func(string) string
+	cpu       cpuTracker
+}
+
+// NewSampler creates a remote desktop sessions sampler.
+func NewSampler(context agent.AgentContext) *Sampler {
+	intervalSec := config.FREQ_DISABLE_SAMPLING
+	anonymization := AnonymizeNone
+	if context != nil {
+		intervalSec = context.Config().MetricsSessionSampleRate
+		anonymization = context.Config().SessionSampleUserAnonymization
+	}
+	switch anonymization {
+	case "", AnonymizeNone, AnonymizeHash, AnonymizeRedact:
+	default:
+		rlog.WithField("anonymization", anonymization).Warn("unknown session user anonymization mode. User names won't be reported")
+	}
+	return &Sampler{
+		context:   context,
+		interval:  time.Second * time.Duration(intervalSec),
+		anonymize: anonymizer(anonymization),
+		cpu:       cpuTracker{numCPU: runtime.NumCPU()},
+	}
+}
+
+func (s *Sampler) Name() string { return "SessionSampler" }
+
+func (s *Sampler) Interval() time.Duration {
+	return s.interval
+}
+
+func (s *Sampler) Disabled() bool {
+	return s.Interval() <= config.FREQ_DISABLE_SAMPLING
+}
+
+func (s *Sampler) OnStartup() {}
+
+func (s *Sampler) Sample() (results sample.EventBatch, err error) {
+	defer func() {
+		if panicErr := recover(); panicErr != nil {
+			err = fmt.Errorf("Panic in SessionSampler.Sample: %v\nStack: %s", panicErr, debug.Stack())
+		}
+	}()
+
+	sessions, err := enumerateSessions()
+	if err != nil {
+		return nil, err
+	}
+	procs, err := enumerateProcesses()
+	if err != nil {
+		// sessions are still reported, without their resources usage
+		rlog.WithError(err).Debug("Enumerating sessions processes.")
+	}
+
+	cpuPercents := s.cpu.usage(time.Now(), procs)
+	for _, sessionSample := range newSessionSamples(sessions, procs, cpuPercents, s.anonymize) {
+		results = append(results, sessionSample)
+	}
+	return results, nil
+}
+
+func enumerateSessions() ([]session, error) {
+	var info *wtsSessionInfo
+	var count uint32
+	ret, _, err := procWTSEnumerateSessionsW.Call(
+		wtsCurrentServerHandle, 0, 1,
+		uintptr(unsafe.Pointer(&info)),
+		uintptr(unsafe.Pointer(&count)))
+	if ret == 0 {
+		return nil, fmt.Errorf("enumerating sessions: %v", err)
+	}
+	defer procWTSFreeMemory.Call(uintptr(unsafe.Pointer(info)))
+
+	infos := (*[1 << 20]wtsSessionInfo)(unsafe.Pointer(info))[:count:count]
+	sessions := make([]session, 0, count)
+	for _, i := range infos {
+		s := session{
+			id:    i.SessionID,
+			name:  nrwin.UTF16PtrToString(i.WinStationName),
+			state: i.State,
+		}
+		if s.isUserSession() {
+			s.userName = querySessionString(s.id, wtsUserName)
+			s.domainName = querySessionString(s.id, wtsDomainName)
+			s.clientName = querySessionString(s.id, wtsClientName)
+		}
+		sessions = append(sessions, s)
+	}
+	return sessions, nil
+}
+
+// querySessionString returns a string attribute of a session, or empty if it can't be retrieved.
+func querySessionString(sessionID uint32, infoClass uintptr) string {
+	var buffer *uint16
+	var size uint32
+	ret, _, err := procWTSQuerySessionInformationW.Call(
+		wtsCurrentServerHandle,
+		uintptr(sessionID),
+		infoClass,
+		uintptr(unsafe.Pointer(&buffer)),
+		uintptr(unsafe.Pointer(&size)))
+	if ret == 0 {
+		rlog.WithError(err).WithField("sessionId", sessionID).Debug("Querying session information.")
+		return ""
+	}
+	defer procWTSFreeMemory.Call(uintptr(unsafe.Pointer(buffer)))
+
+	return nrwin.UTF16PtrToString(buffer)
+}
+
+func enumerateProcesses() ([]processUsage, error) {
+	level := uint32(1)
+	var info *wtsProcessInfoEx
+	var count uint32
+	ret, _, err := procWTSEnumerateProcessesExW.Call(
+		wtsCurrentServerHandle,
+		uintptr(unsafe.Pointer(&level)),
+		wtsAnySession,
+		uintptr(unsafe.Pointer(&info)),
+		uintptr(unsafe.Pointer(&count)))
+	if ret == 0 {
+		return nil, fmt.Errorf("enumerating processes: %v", err)
+	}
+	defer procWTSFreeMemoryExW.Call(wtsTypeProcessInfoLevel1, uintptr(unsafe.Pointer(info)), uintptr(count))
+
+	infos := (*[1 << 20]wtsProcessInfoEx)(unsafe.Pointer(info))[:count:count]
+	procs := make([]processUsage, 0, count)
+	for _, i := range infos {
+		procs = append(procs, processUsage{
+			sessionID:  i.SessionID,
+			workingSet: uint64(i.WorkingSetSize),
+			pagefile:   uint64(i.PagefileUsage),
+			cpuTime:    time.Duration(i.UserTime+i.KernelTime) * 100,
+		})
+	}
+	return procs, nil
+}
@@ -0,0 +1,138 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package recent
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// comparison operators, the longest first so "a>=1" isn't parsed as "a>" "=1"
+var operators = []string{"!=", ">=", "<=", "=", ">", "<"}
+
+// Query selects the stored samples.
+type Query struct {
+	EventType string
+	EntityKey string
+	// Since discards the samples older than it. Zero: all the retained samples
+	Since time.Duration
+	// Limit is the maximum number of returned samples. Zero: no limit
+	Limit int
+	// Where holds the conditions all the returned samples must match
+	Where []Condition
+}
+
+// Condition compares a sample attribute with a value. Numeric attributes are compared numerically,
+// and the rest as strings.
+type Condition struct {
+	Attribute string
+	Operator  string
+	Value     string
+}
+
+// ParseQuery builds a query from the URL parameters eventType, entityKey, since (as a duration),
+// limit and where, which is repeated for each condition, e.g. where=cpuPercent>50.
+func ParseQuery(params url.Values) (Query, error) {
+	q := Query{
+		EventType: params.Get("eventType"),
+		EntityKey: params.Get("entityKey"),
+	}
+	var err error
+	if since := params.Get("since"); since != "" {
+		if q.Since, err = time.ParseDuration(since); err != nil {
+			return q, fmt.Errorf("invalid since duration %q: %v", since, err)
+		}
+	}
+	if limit := params.Get("limit"); limit != "" {
+		if q.Limit, err = strconv.Atoi(limit); err != nil || q.Limit < 0 {
+			return q, fmt.Errorf("invalid limit %q", limit)
+		}
+	}
+	for _, where := range params["where"] {
+		c, err := parseCondition(where)
+		if err != nil {
+			return q, err
+		}
+		q.Where = append(q.Where, c)
+	}
+	return q, nil
+}
+
+func parseCondition(where string) (Condition, error) {
+	index, operator := -1, ""
+	for _, op := range operators {
+		if i := strings.Index(where, op); i > 0 && (index < 0 || i < index) {
+			index, operator = i, op
+		}
+	}
+	if index < 0 {
+		return Condition{}, fmt.Errorf("invalid condition %q, expecting <attribute><operator><value>", where)
+	}
+	return Condition{
+		Attribute: strings.TrimSpace(where[:index]),
+		Operator:  operator,
+		Value:     strings.TrimSpace(where[index+len(operator):]),
+	}, nil
+}
+
+func (q *Query) matches(attributes map[string]interface{}) bool {
+	if q.EventType != "" && fmt.Sprint(attributes["eventType"]) != q.EventType {
+		return false
+	}
+	if q.EntityKey != "" && fmt.Sprint(attributes["entityKey"]) != q.EntityKey {
+		return false
+	}
+	for _, c := range q.Where {
+		if !c.matches(attributes) {
+			return false
+		}
+	}
+	return true
+}
+
+func (c *Condition) matches(attributes map[string]interface{}) bool {
+	value, ok := attributes[c.Attribute]
+	if !ok {
+		// missing attributes only match the inequality
+		return c.Operator == "!="
+	}
+	var cmp int
+	number, isNumber := value.(json.Number)
+	if expected, err := strconv.ParseFloat(c.Value, 64); err == nil && isNumber {
+		actual, err := number.Float64()
+		if err != nil {
+			return false
+		}
+		cmp = compareFloats(actual, expected)
+	} else {
+		cmp = strings.Compare(fmt.Sprint(value), c.Value)
+	}
+	switch c.Operator {
+	case "=":
+		return cmp == 0
+	case "!=":
+		return cmp != 0
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	}
+	return false
+}
+
+func compareFloats(a, b float64) int {
+	if a < b {
+		return -1
+	} else if a > b {
+		return 1
+	}
+	return 0
+}
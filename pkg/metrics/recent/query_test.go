@@ -0,0 +1,68 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package recent
+
+import (
+	"encoding/json"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseQuery(t *testing.T) {
+	params, err := url.ParseQuery("eventType=ProcessSample&entityKey=host&since=5m&limit=10" +
+		"&where=processDisplayName=nginx&where=cpuPercent%3E=50&where=state!=Z")
+	require.NoError(t, err)
+
+	q, err := ParseQuery(params)
+
+	require.NoError(t, err)
+	assert.Equal(t, Query{
+		EventType: "ProcessSample",
+		EntityKey: "host",
+		Since:     5 * time.Minute,
+		Limit:     10,
+		Where: []Condition{
+			{Attribute: "processDisplayName", Operator: "=", Value: "nginx"},
+			{Attribute: "cpuPercent", Operator: ">=", Value: "50"},
+			{Attribute: "state", Operator: "!=", Value: "Z"},
+		},
+	}, q)
+}
+
+func TestParseQuery_Invalid(t *testing.T) {
+	for _, query := range []string{"since=yesterday", "limit=-1", "limit=many", "where=cpuPercent", "where==50"} {
+		t.Run(query, func(t *testing.T) {
+			params, err := url.ParseQuery(query)
+			require.NoError(t, err)
+			_, err = ParseQuery(params)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestCondition_Matches(t *testing.T) {
+	attributes := map[string]interface{}{
+		"cpuPercent":         json.Number("75.5"),
+		"processDisplayName": "nginx",
+	}
+	cases := []struct {
+		condition Condition
+		matches   bool
+	}{
+		{Condition{"cpuPercent", ">", "50"}, true},
+		{Condition{"cpuPercent", "<=", "75.5"}, true},
+		{Condition{"cpuPercent", "<", "8"}, false}, // numeric, not lexicographic
+		{Condition{"processDisplayName", "=", "nginx"}, true},
+		{Condition{"processDisplayName", "!=", "nginx"}, false},
+		{Condition{"processDisplayName", ">", "apache"}, true},
+		{Condition{"missing", "=", "x"}, false},
+		{Condition{"missing", "!=", "x"}, true},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.matches, c.condition.matches(attributes), "%+v", c.condition)
+	}
+}
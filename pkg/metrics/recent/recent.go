@@ -0,0 +1,100 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+// Package recent keeps the samples emitted during the last minutes in a local ring buffer, so they can be
+// inspected on-host, even when the backend is unreachable.
+package recent
+
+import (
+	"sync"
+	"time"
+
+	"github.com/newrelic/infrastructure-agent/pkg/log"
+	"github.com/newrelic/infrastructure-agent/pkg/metrics/enrich"
+	"github.com/newrelic/infrastructure-agent/pkg/sample"
+)
+
+var rlog = log.WithComponent("RecentSamples")
+
+var timeNow = time.Now
+
+type entry struct {
+	time       time.Time
+	attributes map[string]interface{}
+}
+
+// Store keeps the samples emitted within its retention period, up to a maximum number of samples. When
+// it is full, the oldest samples are discarded.
+type Store struct {
+	retention time.Duration
+	lock      sync.Mutex
+	entries   []entry // ring buffer
+	first     int     // index of the oldest entry
+	size      int
+}
+
+// NewStore returns a store retaining the samples for the passed period, up to maxSamples.
+func NewStore(retention time.Duration, maxSamples int) *Store {
+	return &Store{
+		retention: retention,
+		entries:   make([]entry, maxSamples),
+	}
+}
+
+// Add stores an emitted sample, as it would be submitted.
+func (s *Store) Add(event sample.Event) {
+	if len(s.entries) == 0 {
+		return
+	}
+	attributes, err := enrich.Flatten(event)
+	if err != nil {
+		rlog.WithError(err).Debug("Can't store sample.")
+		return
+	}
+	now := timeNow()
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.expire(now)
+	e := entry{time: now, attributes: attributes}
+	if s.size == len(s.entries) {
+		// full: overwrites the oldest sample
+		s.entries[s.first] = e
+		s.first = (s.first + 1) % len(s.entries)
+		return
+	}
+	s.entries[(s.first+s.size)%len(s.entries)] = e
+	s.size++
+}
+
+// Query returns the stored samples matching the query, from the newest to the oldest.
+func (s *Store) Query(q Query) []map[string]interface{} {
+	now := timeNow()
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.expire(now)
+	results := []map[string]interface{}{}
+	for i := s.size - 1; i >= 0; i-- {
+		e := s.entries[(s.first+i)%len(s.entries)]
+		if q.Since > 0 && e.time.Before(now.Add(-q.Since)) {
+			break
+		}
+		if !q.matches(e.attributes) {
+			continue
+		}
+		results = append(results, e.attributes)
+		if q.Limit > 0 && len(results) >= q.Limit {
+			break
+		}
+	}
+	return results
+}
+
+// expire discards the samples older than the retention period. The lock must be held.
+func (s *Store) expire(now time.Time) {
+	for s.size > 0 && s.entries[s.first].time.Before(now.Add(-s.retention)) {
+		s.entries[s.first] = entry{}
+		s.first = (s.first + 1) % len(s.entries)
+		s.size--
+	}
+}
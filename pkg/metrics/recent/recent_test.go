@@ -0,0 +1,92 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package recent
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/newrelic/infrastructure-agent/pkg/sample"
+)
+
+type testSample struct {
+	sample.BaseEvent
+	Value int `json:"value"`
+}
+
+func newSample(eventType string, value int) *testSample {
+	s := &testSample{Value: value}
+	s.Type(eventType)
+	s.Entity("host")
+	return s
+}
+
+func values(results []map[string]interface{}) (vs []json.Number) {
+	for _, r := range results {
+		vs = append(vs, r["value"].(json.Number))
+	}
+	return vs
+}
+
+func TestStore_MaxSamples(t *testing.T) {
+	// GIVEN a store with room for 3 samples
+	store := NewStore(time.Hour, 3)
+
+	// WHEN 5 samples are added
+	for i := 1; i <= 5; i++ {
+		store.Add(newSample("TestSample", i))
+	}
+
+	// THEN only the newest 3 samples are kept, returned from the newest
+	results := store.Query(Query{})
+	assert.Equal(t, []json.Number{"5", "4", "3"}, values(results))
+	assert.Equal(t, "TestSample", results[0]["eventType"])
+	assert.Equal(t, "host", results[0]["entityKey"])
+}
+
+func TestStore_Retention(t *testing.T) {
+	now := time.Unix(1611923766, 0)
+	timeNow = func() time.Time { return now }
+	defer func() { timeNow = time.Now }()
+
+	// GIVEN a store retaining the samples for 10 minutes
+	store := NewStore(10*time.Minute, 100)
+	store.Add(newSample("TestSample", 1))
+	now = now.Add(6 * time.Minute)
+	store.Add(newSample("TestSample", 2))
+
+	// WHEN the samples added in the last 5 minutes are queried
+	// THEN only the newest sample is returned
+	assert.Equal(t, []json.Number{"2"}, values(store.Query(Query{Since: 5 * time.Minute})))
+
+	// AND once the retention period of the first sample expires, it's discarded
+	assert.Equal(t, []json.Number{"2", "1"}, values(store.Query(Query{})))
+	now = now.Add(5 * time.Minute)
+	assert.Equal(t, []json.Number{"2"}, values(store.Query(Query{})))
+	now = now.Add(6 * time.Minute)
+	assert.Empty(t, store.Query(Query{}))
+
+	// AND the freed room is reused
+	store.Add(newSample("TestSample", 3))
+	assert.Equal(t, []json.Number{"3"}, values(store.Query(Query{})))
+}
+
+func TestStore_QueryFilters(t *testing.T) {
+	store := NewStore(time.Hour, 10)
+	for i := 1; i <= 4; i++ {
+		store.Add(newSample("TestSample", i))
+	}
+	store.Add(newSample("OtherSample", 10))
+
+	results := store.Query(Query{EventType: "TestSample", Where: []Condition{{Attribute: "value", Operator: ">=", Value: "2"}}})
+	assert.Equal(t, []json.Number{"4", "3", "2"}, values(results))
+
+	results = store.Query(Query{EventType: "TestSample", Limit: 2})
+	assert.Equal(t, []json.Number{"4", "3"}, values(results))
+
+	require.Empty(t, store.Query(Query{EntityKey: "other-host"}))
+}
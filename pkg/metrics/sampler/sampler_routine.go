@@ -30,15 +30,17 @@ func StartSamplerRoutine(sampler Sampler, sampleQueue chan sample.EventBatch) *S
 	sr.waitForCleanup.Add(1)
 
 	go func() {
-		ticker := time.NewTicker(sampler.Interval())
+		// the interval is read on each run, as throttled samplers slow down while the backend throttles the agent
+		timer := time.NewTimer(sampler.Interval())
 		defer func() {
-			ticker.Stop()
+			timer.Stop()
 			sr.waitForCleanup.Done()
 		}()
 		mslog.WithField("name", sr.name).Debug("Started sampler routine.")
 		for {
 			select {
-			case <-ticker.C:
+			case <-timer.C:
+				timer.Reset(sampler.Interval())
 				samples, err := sampler.Sample()
 				if err != nil {
 					mslog.WithError(err).WithField("samplerName", sr.name).Error("can't get sample from sampler")
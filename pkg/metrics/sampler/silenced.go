@@ -0,0 +1,67 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package sampler
+
+import (
+	"time"
+
+	"github.com/newrelic/infrastructure-agent/pkg/metrics/enrich"
+	"github.com/newrelic/infrastructure-agent/pkg/sample"
+	"github.com/newrelic/infrastructure-agent/pkg/schedule"
+)
+
+var timeNow = time.Now
+
+// TaggedEvent is a sample collected during a silent window of the tag action. The agent unwraps it before
+// filtering the sample, and tags it with the window name once it is included.
+type TaggedEvent struct {
+	sample.Event
+	Window string
+}
+
+// Tag adds the silent window name to the attributes of a sample.
+func Tag(event sample.Event, window string) (sample.Event, error) {
+	if attributes, ok := event.(*enrich.Event); ok {
+		(*attributes)[schedule.TagAttribute] = window
+		return attributes, nil
+	}
+	attributes, err := enrich.Flatten(event)
+	if err != nil {
+		return event, err
+	}
+	attributes[schedule.TagAttribute] = window
+	tagged := enrich.Event(attributes)
+	return &tagged, nil
+}
+
+// silencedSampler pauses a sampler, or tags its samples, during its silent windows.
+type silencedSampler struct {
+	Sampler
+	windows []*schedule.Window
+}
+
+// WithSilentWindows returns a sampler that isn't run during the windows of the pause action, and whose
+// samples are wrapped as TaggedEvent during the windows of the tag action.
+func WithSilentWindows(s Sampler, windows []*schedule.Window) Sampler {
+	if len(windows) == 0 {
+		return s
+	}
+	return &silencedSampler{Sampler: s, windows: windows}
+}
+
+func (s *silencedSampler) Sample() (sample.EventBatch, error) {
+	window := schedule.Active(s.windows, timeNow())
+	if window == nil {
+		return s.Sampler.Sample()
+	}
+	if window.Action == schedule.ActionPause {
+		mslog.WithField("samplerName", s.Name()).WithField("window", window.Name).Debug("Sampler paused by silent window.")
+		return nil, nil
+	}
+
+	batch, err := s.Sampler.Sample()
+	for i, event := range batch {
+		batch[i] = &TaggedEvent{Event: event, Window: window.Name}
+	}
+	return batch, err
+}
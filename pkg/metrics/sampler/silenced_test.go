@@ -0,0 +1,95 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package sampler
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/newrelic/infrastructure-agent/pkg/metrics/enrich"
+	"github.com/newrelic/infrastructure-agent/pkg/sample"
+	"github.com/newrelic/infrastructure-agent/pkg/schedule"
+)
+
+type fakeEvent struct {
+	sample.BaseEvent
+	Value int `json:"value"`
+}
+
+type fakeSampler struct {
+	mockSampler
+	calls int
+}
+
+func (f *fakeSampler) Sample() (sample.EventBatch, error) {
+	f.calls++
+	return sample.EventBatch{&fakeEvent{Value: 1}}, nil
+}
+
+func TestWithSilentWindows(t *testing.T) {
+	now := time.Date(2021, 3, 1, 2, 30, 0, 0, time.UTC)
+	timeNow = func() time.Time { return now }
+	defer func() { timeNow = time.Now }()
+
+	pause, err := schedule.NewWindow("backup", "0 2 * * *", "1h", "UTC", schedule.ActionPause)
+	require.NoError(t, err)
+	tag, err := schedule.NewWindow("maintenance", "0 2 * * mon", "2h", "UTC", schedule.ActionTag)
+	require.NoError(t, err)
+
+	t.Run("without windows", func(t *testing.T) {
+		s := &fakeSampler{}
+		assert.Same(t, s, WithSilentWindows(s, nil))
+	})
+
+	t.Run("paused", func(t *testing.T) {
+		// GIVEN a sampler within a window of the pause action
+		s := &fakeSampler{}
+		silenced := WithSilentWindows(s, []*schedule.Window{tag, pause})
+
+		// WHEN it is sampled
+		batch, err := silenced.Sample()
+
+		// THEN the sampler isn't run
+		require.NoError(t, err)
+		assert.Empty(t, batch)
+		assert.Equal(t, 0, s.calls)
+		assert.Equal(t, "MockSampler", silenced.Name())
+	})
+
+	t.Run("tagged", func(t *testing.T) {
+		// GIVEN a sampler within a window of the tag action
+		silenced := WithSilentWindows(&fakeSampler{}, []*schedule.Window{tag})
+
+		// WHEN it is sampled
+		batch, err := silenced.Sample()
+
+		// THEN its samples are marked with the window
+		require.NoError(t, err)
+		require.Len(t, batch, 1)
+		tagged, ok := batch[0].(*TaggedEvent)
+		require.True(t, ok)
+		assert.Equal(t, "maintenance", tagged.Window)
+		assert.Equal(t, &fakeEvent{Value: 1}, tagged.Event)
+	})
+
+	t.Run("outside the windows", func(t *testing.T) {
+		now = now.Add(3 * time.Hour)
+		batch, err := WithSilentWindows(&fakeSampler{}, []*schedule.Window{tag, pause}).Sample()
+
+		require.NoError(t, err)
+		assert.Equal(t, sample.EventBatch{&fakeEvent{Value: 1}}, batch)
+	})
+}
+
+func TestTag(t *testing.T) {
+	event, err := Tag(&fakeEvent{Value: 1}, "maintenance")
+	require.NoError(t, err)
+	attributes, ok := event.(*enrich.Event)
+	require.True(t, ok)
+	assert.Equal(t, "maintenance", (*attributes)[schedule.TagAttribute])
+	assert.Equal(t, json.Number("1"), (*attributes)["value"])
+}
@@ -0,0 +1,27 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package sampler
+
+import (
+	"time"
+
+	"github.com/newrelic/infrastructure-agent/pkg/backend/throttle"
+)
+
+// throttledSampler runs a sampler less often while the backend throttles the agent.
+type throttledSampler struct {
+	Sampler
+	throttle *throttle.Controller
+}
+
+// WithThrottle returns a sampler whose interval is slowed down by the throttle controller, if any.
+func WithThrottle(s Sampler, c *throttle.Controller) Sampler {
+	if c == nil {
+		return s
+	}
+	return &throttledSampler{Sampler: s, throttle: c}
+}
+
+func (s *throttledSampler) Interval() time.Duration {
+	return s.throttle.Interval(s.Sampler.Interval())
+}
@@ -0,0 +1,25 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package sampler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/newrelic/infrastructure-agent/pkg/backend/throttle"
+)
+
+func TestWithThrottle(t *testing.T) {
+	m := &mockSampler{}
+	assert.Equal(t, m, WithThrottle(m, nil))
+
+	c := throttle.New(4, time.Hour)
+	throttled := WithThrottle(m, c)
+	assert.Equal(t, time.Microsecond, throttled.Interval())
+
+	c.Throttled()
+	assert.Equal(t, 2*time.Microsecond, throttled.Interval())
+	assert.Equal(t, "MockSampler", throttled.Name())
+}
@@ -9,9 +9,11 @@ import (
 	"time"
 
 	"github.com/newrelic/infrastructure-agent/internal/agent"
+	"github.com/newrelic/infrastructure-agent/pkg/backend/throttle"
 	"github.com/newrelic/infrastructure-agent/pkg/log"
 	"github.com/newrelic/infrastructure-agent/pkg/metrics/sampler"
 	"github.com/newrelic/infrastructure-agent/pkg/sample"
+	"github.com/newrelic/infrastructure-agent/pkg/schedule"
 )
 
 const (
@@ -27,24 +29,56 @@ type Sender struct {
 	stopChannel          chan bool       // Channel will be closed when we want to stop all internal goroutines
 	sampleQueue          chan sample.EventBatch
 	samplers             []sampler.Sampler
+	silentWindows        map[string][]*schedule.Window // silent windows by sampler name
+	throttle             *throttle.Controller          // nil: samplers keep their interval when the agent is throttled
+}
+
+// throttledContext is implemented by the agent contexts slowing down while the backend throttles the agent.
+type throttledContext interface {
+	Throttle() *throttle.Controller
 }
 
 func NewSender(ctx agent.AgentContext) *Sender {
-	return &Sender{
+	s := &Sender{
 		ctx:                  ctx,
 		sampleQueue:          make(chan sample.EventBatch, SAMPLE_QUEUE_CAPACITY),
 		internalRoutineWaits: &sync.WaitGroup{},
+		silentWindows:        silentWindows(ctx),
+	}
+	if tc, ok := ctx.(throttledContext); ok {
+		s.throttle = tc.Throttle()
+	}
+	return s
+}
+
+// silentWindows returns the configured silent windows by sampler name. Invalid windows are ignored.
+func silentWindows(ctx agent.AgentContext) map[string][]*schedule.Window {
+	windows := map[string][]*schedule.Window{}
+	if ctx == nil || ctx.Config() == nil {
+		return windows
+	}
+	for _, w := range ctx.Config().SilentWindows {
+		window, err := schedule.NewWindow(w.Name, w.Schedule, w.Duration, w.Timezone, w.Action)
+		if err != nil {
+			slog.WithError(err).Warn("Ignoring invalid silent window")
+			continue
+		}
+		for _, name := range w.Samplers {
+			windows[name] = append(windows[name], window)
+		}
 	}
+	return windows
 }
 
-func (s *Sender) RegisterSampler(sampler sampler.Sampler) {
+func (s *Sender) RegisterSampler(smp sampler.Sampler) {
 	// don't even register the sampler if it's disabled
-	if sampler.Disabled() {
-		slog.WithField("sampler", sampler.Name()).Warn("Sampler is disabled and will not run")
+	if smp.Disabled() {
+		slog.WithField("sampler", smp.Name()).Warn("Sampler is disabled and will not run")
 		return
 	}
 
-	s.samplers = append(s.samplers, sampler)
+	smp = sampler.WithThrottle(smp, s.throttle)
+	s.samplers = append(s.samplers, sampler.WithSilentWindows(smp, s.silentWindows[smp.Name()]))
 }
 
 // Start will register the sender with the collector, then start a couple of background
@@ -0,0 +1,218 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package sensor
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/newrelic/infrastructure-agent/pkg/helpers"
+)
+
+const ipmitoolTimeout = 10 * time.Second
+
+var errNoIPMITool = errors.New("ipmitool not found")
+
+// ipmitool runs ipmitool with the passed arguments. Overridden by tests.
+var ipmitool = func(args ...string) ([]byte, error) {
+	path, err := exec.LookPath("ipmitool")
+	if err != nil {
+		return nil, errNoIPMITool
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), ipmitoolTimeout)
+	defer cancel()
+	return exec.CommandContext(ctx, path, args...).Output()
+}
+
+// cpuChips are the hwmon drivers of the CPU temperature sensors.
+var cpuChips = map[string]bool{"coretemp": true, "k10temp": true, "zenpower": true, "cpu_thermal": true}
+
+// diskChips are the hwmon drivers of the disk temperature sensors.
+var diskChips = map[string]bool{"nvme": true, "drivetemp": true}
+
+// readSensors reads the hwmon sensors, complemented by the IPMI ones of the kinds hwmon doesn't expose,
+// as the fans of servers are usually only reachable through their BMC.
+func readSensors() []*Sample {
+	samples := readHwmon(helpers.HostSys("class", "hwmon"))
+	temperatures, fans := false, false
+	for _, s := range samples {
+		temperatures = temperatures || s.Kind == kindTemperature
+		fans = fans || s.Kind == kindFan
+	}
+	if temperatures && fans {
+		return samples
+	}
+	for _, s := range readIPMISensors() {
+		if (s.Kind == kindTemperature && !temperatures) || (s.Kind == kindFan && !fans) {
+			samples = append(samples, s)
+		}
+	}
+	return samples
+}
+
+func readIPMISensors() []*Sample {
+	output, err := ipmitool("-c", "sdr", "list", "full")
+	if err != nil {
+		if err != errNoIPMITool {
+			slog.WithError(err).Debug("Unable to query the IPMI sensors.")
+		}
+		return nil
+	}
+	return parseIPMISensors(output)
+}
+
+// readHwmon reads the temperature and fan sensors of the hwmon chips in the passed directory. The
+// per core sensors of the coretemp driver are skipped, as the package ones report their maximum.
+func readHwmon(dir string) []*Sample {
+	chips, err := filepath.Glob(filepath.Join(dir, "hwmon*"))
+	if err != nil || len(chips) == 0 {
+		return nil
+	}
+	sort.Slice(chips, func(i, j int) bool { return hwmonIndex(chips[i]) < hwmonIndex(chips[j]) })
+
+	var samples []*Sample
+	for _, chipDir := range chips {
+		chip, err := readString(filepath.Join(chipDir, "name"))
+		if err != nil {
+			continue
+		}
+		component := componentOther
+		device := ""
+		switch {
+		case cpuChips[chip]:
+			component = componentCPU
+		case diskChips[chip]:
+			component = componentDisk
+			device = diskDevice(chipDir)
+		}
+
+		for _, index := range sensorIndexes(chipDir, "temp") {
+			prefix := filepath.Join(chipDir, "temp"+index)
+			milli, err := readInt(prefix + "_input")
+			if err != nil {
+				continue
+			}
+			label, err := readString(prefix + "_label")
+			if err != nil {
+				label = "temp" + index
+			}
+			if chip == "coretemp" && strings.HasPrefix(label, "Core ") {
+				continue
+			}
+			s := newSample(sourceHwmon, kindTemperature, label)
+			s.Chip, s.Component, s.Device = chip, component, device
+			s.TemperatureCelsius = celsius(milli)
+			if high, err := readInt(prefix + "_max"); err == nil {
+				s.HighCelsius = celsius(high)
+			}
+			if crit, err := readInt(prefix + "_crit"); err == nil {
+				s.CriticalCelsius = celsius(crit)
+			}
+			s.Alarm = readAlarm(prefix+"_alarm", prefix+"_max_alarm", prefix+"_crit_alarm")
+			samples = append(samples, s)
+		}
+
+		for _, index := range sensorIndexes(chipDir, "fan") {
+			prefix := filepath.Join(chipDir, "fan"+index)
+			rpm, err := readInt(prefix + "_input")
+			if err != nil {
+				continue
+			}
+			label, err := readString(prefix + "_label")
+			if err != nil {
+				label = "fan" + index
+			}
+			s := newSample(sourceHwmon, kindFan, label)
+			s.Chip = chip
+			speed := float64(rpm)
+			s.FanRPM = &speed
+			if min, err := readInt(prefix + "_min"); err == nil {
+				minSpeed := float64(min)
+				s.FanMinRPM = &minSpeed
+			}
+			s.Alarm = readAlarm(prefix + "_alarm")
+			samples = append(samples, s)
+		}
+	}
+	return samples
+}
+
+// sensorIndexes returns the sorted indexes of the sensors of a type (temp or fan) with an input file.
+func sensorIndexes(chipDir, sensorType string) []string {
+	inputs, _ := filepath.Glob(filepath.Join(chipDir, sensorType+"*_input"))
+	var indexes []int
+	for _, input := range inputs {
+		index := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(input), sensorType), "_input")
+		if i, err := strconv.Atoi(index); err == nil {
+			indexes = append(indexes, i)
+		}
+	}
+	sort.Ints(indexes)
+	result := make([]string, 0, len(indexes))
+	for _, i := range indexes {
+		result = append(result, strconv.Itoa(i))
+	}
+	return result
+}
+
+// diskDevice returns the block device of a disk temperature chip: the drivetemp chips belong to a SCSI
+// device with a block directory, and the nvme ones to an NVMe controller.
+func diskDevice(chipDir string) string {
+	for _, pattern := range []string{"block", "nvme"} {
+		if matches, _ := filepath.Glob(filepath.Join(chipDir, "device", pattern, "*")); len(matches) > 0 {
+			return filepath.Base(matches[0])
+		}
+	}
+	if resolved, err := filepath.EvalSymlinks(filepath.Join(chipDir, "device")); err == nil {
+		return filepath.Base(resolved)
+	}
+	return ""
+}
+
+// readAlarm returns whether any of the passed alarm files is raised, or nil if none is readable.
+func readAlarm(paths ...string) *bool {
+	var alarm *bool
+	for _, path := range paths {
+		value, err := readInt(path)
+		if err != nil {
+			continue
+		}
+		raised := value != 0 || (alarm != nil && *alarm)
+		alarm = &raised
+	}
+	return alarm
+}
+
+func hwmonIndex(path string) int {
+	index, _ := strconv.Atoi(strings.TrimPrefix(filepath.Base(path), "hwmon"))
+	return index
+}
+
+// celsius converts a hwmon temperature, in millidegrees, to degrees Celsius.
+func celsius(milli int64) *float64 {
+	degrees := float64(milli) / 1000
+	return &degrees
+}
+
+func readString(path string) (string, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(content)), nil
+}
+
+func readInt(path string) (int64, error) {
+	value, err := readString(path)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(value, 10, 64)
+}
@@ -0,0 +1,105 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package sensor
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeFiles creates the files of a fake sysfs directory.
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	for path, content := range files {
+		path = filepath.Join(dir, path)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, ioutil.WriteFile(path, []byte(content+"\n"), 0644))
+	}
+}
+
+func TestReadHwmon(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hwmon")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	// GIVEN an Intel CPU, a SATA disk, a super I/O chip with a fan and a chip without readable sensors
+	writeFiles(t, dir, map[string]string{
+		"hwmon1/name":                     "coretemp",
+		"hwmon1/temp1_input":              "61000",
+		"hwmon1/temp1_label":              "Package id 0",
+		"hwmon1/temp1_max":                "84000",
+		"hwmon1/temp1_crit":               "100000",
+		"hwmon1/temp1_crit_alarm":         "0",
+		"hwmon1/temp2_input":              "58000",
+		"hwmon1/temp2_label":              "Core 0",
+		"hwmon2/name":                     "drivetemp",
+		"hwmon2/temp1_input":              "36000",
+		"hwmon2/device/block/sda/size":    "1000",
+		"hwmon10/name":                    "nct6775",
+		"hwmon10/fan2_input":              "1200",
+		"hwmon10/fan2_min":                "300",
+		"hwmon10/fan2_alarm":              "0",
+		"hwmon10/fan10_input":             "0",
+		"hwmon10/fan10_alarm":             "1",
+		"hwmon10/temp3_input":             "-5000",
+		"hwmon3/name":                     "acpitz",
+		"hwmon3/temp1_input_not_a_sensor": "1",
+	})
+
+	// WHEN the chips are read
+	samples := readHwmon(dir)
+
+	// THEN the package temperature is reported, but not the per core ones
+	require.Len(t, samples, 5)
+	pkg := samples[0]
+	assert.Equal(t, "SensorSample", pkg.EventType)
+	assert.Equal(t, sourceHwmon, pkg.Source)
+	assert.Equal(t, kindTemperature, pkg.Kind)
+	assert.Equal(t, "coretemp", pkg.Chip)
+	assert.Equal(t, "Package id 0", pkg.Sensor)
+	assert.Equal(t, componentCPU, pkg.Component)
+	assert.Equal(t, 61.0, *pkg.TemperatureCelsius)
+	assert.Equal(t, 84.0, *pkg.HighCelsius)
+	assert.Equal(t, 100.0, *pkg.CriticalCelsius)
+	assert.False(t, *pkg.Alarm)
+
+	// AND the disk temperature with its device
+	disk := samples[1]
+	assert.Equal(t, componentDisk, disk.Component)
+	assert.Equal(t, "sda", disk.Device)
+	assert.Equal(t, "temp1", disk.Sensor)
+	assert.Nil(t, disk.Alarm)
+
+	// AND the other chip sensors, sorted by chip and index
+	assert.Equal(t, componentOther, samples[2].Component)
+	assert.Equal(t, -5.0, *samples[2].TemperatureCelsius)
+	fan := samples[3]
+	assert.Equal(t, kindFan, fan.Kind)
+	assert.Equal(t, "fan2", fan.Sensor)
+	assert.Equal(t, 1200.0, *fan.FanRPM)
+	assert.Equal(t, 300.0, *fan.FanMinRPM)
+	assert.False(t, *fan.Alarm)
+	assert.Equal(t, "fan10", samples[4].Sensor)
+	assert.True(t, *samples[4].Alarm)
+}
+
+func TestReadIPMISensors(t *testing.T) {
+	defer func(original func(...string) ([]byte, error)) { ipmitool = original }(ipmitool)
+
+	ipmitool = func(args ...string) ([]byte, error) {
+		return []byte("FAN1,4200,RPM,ok\n"), nil
+	}
+	samples := readIPMISensors()
+	require.Len(t, samples, 1)
+	assert.Equal(t, sourceIPMI, samples[0].Source)
+
+	// no samples when ipmitool is missing or fails, as when the IPMI device is not available
+	ipmitool = func(...string) ([]byte, error) { return nil, errNoIPMITool }
+	assert.Empty(t, readIPMISensors())
+	ipmitool = func(...string) ([]byte, error) { return nil, errors.New("Could not open device") }
+	assert.Empty(t, readIPMISensors())
+}
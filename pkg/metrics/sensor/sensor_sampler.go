@@ -0,0 +1,179 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+// Package sensor samples the hardware temperature and fan sensors of the host, from the hwmon drivers
+// and, for the sensors they don't expose, from the baseboard management controller through IPMI.
+package sensor
+
+import (
+	"encoding/csv"
+	"fmt"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/newrelic/infrastructure-agent/internal/agent"
+	"github.com/newrelic/infrastructure-agent/pkg/config"
+	"github.com/newrelic/infrastructure-agent/pkg/log"
+	"github.com/newrelic/infrastructure-agent/pkg/sample"
+)
+
+// Sources of the sensor data.
+const (
+	sourceHwmon = "hwmon"
+	sourceIPMI  = "ipmi"
+)
+
+// Kinds of sensors.
+const (
+	kindTemperature = "temperature"
+	kindFan         = "fan"
+)
+
+// Components whose temperature is measured.
+const (
+	componentCPU   = "cpu"
+	componentDisk  = "disk"
+	componentOther = "other"
+)
+
+var slog = log.WithComponent("SensorSampler")
+
+// Sample holds the reading of a temperature or fan sensor.
+type Sample struct {
+	sample.BaseEvent
+
+	// Source of the data: hwmon or ipmi
+	Source string `json:"source"`
+	// Kind of sensor: temperature or fan
+	Kind string `json:"sensorType"`
+	// Driver of the hwmon chip, e.g. coretemp, k10temp, nvme or drivetemp. Empty for IPMI
+	Chip string `json:"chip,omitempty"`
+	// Name of the sensor, e.g. "Package id 0", "Composite", fan1 or the IPMI sensor name
+	Sensor string `json:"sensor"`
+	// Component whose temperature is measured: cpu, disk or other
+	Component string `json:"component,omitempty"`
+	// Device the sensor belongs to, e.g. sda or nvme0 for disks
+	Device string `json:"device,omitempty"`
+
+	TemperatureCelsius *float64 `json:"temperatureCelsius,omitempty"`
+	// Temperature at which the hardware starts throttling or warns about it
+	HighCelsius *float64 `json:"temperatureHighCelsius,omitempty"`
+	// Temperature at which the hardware shuts down
+	CriticalCelsius *float64 `json:"temperatureCriticalCelsius,omitempty"`
+	FanRPM          *float64 `json:"fanSpeedRpm,omitempty"`
+	FanMinRPM       *float64 `json:"fanMinSpeedRpm,omitempty"`
+	// Whether the hardware reports the sensor out of its limits
+	Alarm *bool `json:"alarm,omitempty"`
+}
+
+func newSample(source, kind, sensor string) *Sample {
+	s := &Sample{Source: source, Kind: kind, Sensor: sensor}
+	s.Type("SensorSample")
+	return s
+}
+
+// Sampler reports a SensorSample per temperature and fan sensor. Hosts without sensors, as most virtual
+// machines, don't report any sample.
+type Sampler struct {
+	context    agent.AgentContext
+	sampleRate time.Duration
+	sensors    func() []*Sample
+}
+
+func NewSampler(context agent.AgentContext) *Sampler {
+	sampleRateSec := config.FREQ_DISABLE_SAMPLING
+	if context != nil {
+		sampleRateSec = context.Config().MetricsSensorSampleRate
+	}
+
+	return &Sampler{
+		context:    context,
+		sampleRate: time.Second * time.Duration(sampleRateSec),
+		sensors:    readSensors,
+	}
+}
+
+func (s *Sampler) OnStartup() {}
+
+func (s *Sampler) Name() string {
+	return "SensorSampler"
+}
+
+func (s *Sampler) Interval() time.Duration {
+	return s.sampleRate
+}
+
+func (s *Sampler) Disabled() bool {
+	return s.Interval() <= config.FREQ_DISABLE_SAMPLING
+}
+
+func (s *Sampler) Sample() (eventBatch sample.EventBatch, err error) {
+	defer func() {
+		if panicErr := recover(); panicErr != nil {
+			err = fmt.Errorf("Panic in sensor.Sampler: %v\nStack: %s", panicErr, debug.Stack())
+		}
+	}()
+
+	for _, sensor := range s.sensors() {
+		eventBatch = append(eventBatch, sensor)
+	}
+	return eventBatch, nil
+}
+
+// ipmiFailureStates are the IPMI sensor states out of the normal thresholds: critical, non-recoverable
+// and non-critical.
+var ipmiFailureStates = map[string]bool{"cr": true, "nr": true, "nc": true}
+
+// parseIPMISensors parses the temperature and fan sensors from the output of "ipmitool -c sdr list full",
+// with a "name,value,unit,state" line per sensor. Sensors without reading are ignored.
+func parseIPMISensors(output []byte) []*Sample {
+	reader := csv.NewReader(strings.NewReader(string(output)))
+	reader.FieldsPerRecord = -1
+	records, err := reader.ReadAll()
+	if err != nil {
+		slog.WithError(err).Debug("Unable to parse IPMI sensors.")
+		return nil
+	}
+	var samples []*Sample
+	for _, record := range records {
+		if len(record) < 4 {
+			continue
+		}
+		value, err := strconv.ParseFloat(strings.TrimSpace(record[1]), 64)
+		if err != nil {
+			continue
+		}
+		name, state := strings.TrimSpace(record[0]), strings.TrimSpace(record[3])
+		var s *Sample
+		switch strings.TrimSpace(record[2]) {
+		case "degrees C":
+			s = newSample(sourceIPMI, kindTemperature, name)
+			s.TemperatureCelsius = &value
+			s.Component = ipmiComponent(name)
+		case "RPM":
+			s = newSample(sourceIPMI, kindFan, name)
+			s.FanRPM = &value
+		default:
+			continue
+		}
+		alarm := ipmiFailureStates[state]
+		s.Alarm = &alarm
+		samples = append(samples, s)
+	}
+	return samples
+}
+
+// ipmiComponent guesses the component of an IPMI temperature sensor from its name, as the naming is
+// vendor specific, e.g. "CPU1 Temp", "Temp_CPU0" or "HDD0 Temp".
+func ipmiComponent(name string) string {
+	lower := strings.ToLower(name)
+	switch {
+	case strings.Contains(lower, "cpu") || strings.Contains(lower, "proc"):
+		return componentCPU
+	case strings.Contains(lower, "hdd") || strings.Contains(lower, "disk") || strings.Contains(lower, "drive") ||
+		strings.Contains(lower, "nvme") || strings.Contains(lower, "ssd"):
+		return componentDisk
+	}
+	return componentOther
+}
@@ -0,0 +1,50 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package sensor
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseIPMISensors(t *testing.T) {
+	output := []byte(`CPU1 Temp,45,degrees C,ok
+CPU2 Temp,97,degrees C,cr
+HDD0 Temp,38,degrees C,ok
+Inlet Temp,21,degrees C,ok
+FAN1,4200,RPM,ok
+FAN2,,RPM,ns
+PSU1 Status,0x01,discrete,ok
+12V,12.10,Volts,ok
+`)
+
+	samples := parseIPMISensors(output)
+
+	require.Len(t, samples, 5)
+	assert.Equal(t, "SensorSample", samples[0].EventType)
+	assert.Equal(t, sourceIPMI, samples[0].Source)
+	assert.Equal(t, kindTemperature, samples[0].Kind)
+	assert.Equal(t, "CPU1 Temp", samples[0].Sensor)
+	assert.Equal(t, componentCPU, samples[0].Component)
+	assert.Equal(t, 45.0, *samples[0].TemperatureCelsius)
+	assert.False(t, *samples[0].Alarm)
+	assert.True(t, *samples[1].Alarm)
+	assert.Equal(t, componentDisk, samples[2].Component)
+	assert.Equal(t, componentOther, samples[3].Component)
+	assert.Equal(t, kindFan, samples[4].Kind)
+	assert.Equal(t, 4200.0, *samples[4].FanRPM)
+}
+
+func TestSampler_Sample(t *testing.T) {
+	s := NewSampler(nil)
+	assert.True(t, s.Disabled())
+
+	s.sensors = func() []*Sample {
+		return []*Sample{newSample(sourceHwmon, kindFan, "fan1"), newSample(sourceHwmon, kindTemperature, "temp1")}
+	}
+	batch, err := s.Sample()
+	require.NoError(t, err)
+	assert.Len(t, batch, 2)
+}
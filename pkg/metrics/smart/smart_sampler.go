@@ -0,0 +1,200 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+// Package smart samples the SMART health of the host disks through smartctl, and reports an event when
+// the predicted health of a disk degrades.
+package smart
+
+import (
+	"fmt"
+	"runtime/debug"
+	"time"
+
+	"github.com/newrelic/infrastructure-agent/internal/agent"
+	"github.com/newrelic/infrastructure-agent/pkg/config"
+	"github.com/newrelic/infrastructure-agent/pkg/log"
+	"github.com/newrelic/infrastructure-agent/pkg/sample"
+)
+
+// Predicted health of a disk, from the best to the worst.
+const (
+	healthPassed  = "passed"
+	healthWarning = "warning"
+	healthFailing = "failing"
+)
+
+// healthRank orders the health values, to tell when they degrade.
+var healthRank = map[string]int{healthPassed: 0, healthWarning: 1, healthFailing: 2}
+
+// wornOutPercent is the percentage of the estimated endurance of an SSD used from which it is reported
+// as a warning.
+const wornOutPercent = 90
+
+var slog = log.WithComponent("SmartSampler")
+
+// Sample holds the SMART health of a disk.
+type Sample struct {
+	sample.BaseEvent
+
+	Device       string `json:"device"`
+	Protocol     string `json:"protocol,omitempty"`
+	Model        string `json:"model,omitempty"`
+	SerialNumber string `json:"serialNumber,omitempty"`
+	// Predicted health: passed, warning (errors or wear reported) or failing
+	Health string `json:"health"`
+	// Overall self-assessment of the disk
+	Passed *bool `json:"smartPassed,omitempty"`
+	// Names of the ATA attributes at or below their failure threshold, comma separated
+	FailingAttributes string `json:"failingAttributes,omitempty"`
+
+	TemperatureCelsius *float64 `json:"temperatureCelsius,omitempty"`
+	PowerOnHours       *uint64  `json:"powerOnHours,omitempty"`
+	// Sectors remapped to the spare area (ATA), or grown defects (SCSI)
+	ReallocatedSectors    *uint64 `json:"reallocatedSectors,omitempty"`
+	PendingSectors        *uint64 `json:"pendingSectors,omitempty"`
+	OfflineUncorrectable  *uint64 `json:"offlineUncorrectableSectors,omitempty"`
+	ReportedUncorrectable *uint64 `json:"reportedUncorrectableErrors,omitempty"`
+	// Unrecovered data integrity errors (NVMe), or uncorrected read and write errors (SCSI)
+	MediaErrors     *uint64 `json:"mediaErrors,omitempty"`
+	ErrorLogEntries *uint64 `json:"errorLogEntries,omitempty"`
+	// NVMe critical warning bitmask, 0 if there are no warnings
+	CriticalWarning *uint64 `json:"criticalWarning,omitempty"`
+	// Percentage of the estimated endurance of the SSD used
+	PercentageUsed                 *float64 `json:"percentageUsed,omitempty"`
+	AvailableSparePercent          *float64 `json:"availableSparePercent,omitempty"`
+	AvailableSpareThresholdPercent *float64 `json:"availableSpareThresholdPercent,omitempty"`
+}
+
+func newSample(device string) *Sample {
+	s := &Sample{Device: device}
+	s.Type("SmartSample")
+	return s
+}
+
+// key identifies the disk between samples, as device names may change across reboots.
+func (s *Sample) key() string {
+	if s.SerialNumber != "" {
+		return s.SerialNumber
+	}
+	return s.Device
+}
+
+// HealthEvent is the InfrastructureEvent reported when the predicted health of a disk degrades.
+type HealthEvent struct {
+	sample.BaseEvent
+
+	Category       string `json:"category"`
+	Action         string `json:"action"`
+	Summary        string `json:"summary"`
+	Device         string `json:"device"`
+	Model          string `json:"model,omitempty"`
+	SerialNumber   string `json:"serialNumber,omitempty"`
+	Health         string `json:"health"`
+	PreviousHealth string `json:"previousHealth"`
+}
+
+func newHealthEvent(s *Sample, previous string) *HealthEvent {
+	e := &HealthEvent{
+		Category:       "storage",
+		Action:         "diskHealthDegraded",
+		Summary:        fmt.Sprintf("Disk %s SMART health is %s", s.Device, s.Health),
+		Device:         s.Device,
+		Model:          s.Model,
+		SerialNumber:   s.SerialNumber,
+		Health:         s.Health,
+		PreviousHealth: previous,
+	}
+	e.Type("InfrastructureEvent")
+	return e
+}
+
+// health predicts the health of a disk from its self-assessment and its error and wear counters.
+func health(s *Sample) string {
+	if (s.Passed != nil && !*s.Passed) || s.FailingAttributes != "" {
+		return healthFailing
+	}
+	if s.CriticalWarning != nil && *s.CriticalWarning != 0 {
+		return healthFailing
+	}
+	for _, counter := range []*uint64{s.ReallocatedSectors, s.PendingSectors, s.OfflineUncorrectable, s.ReportedUncorrectable, s.MediaErrors} {
+		if counter != nil && *counter > 0 {
+			return healthWarning
+		}
+	}
+	if s.PercentageUsed != nil && *s.PercentageUsed >= wornOutPercent {
+		return healthWarning
+	}
+	return healthPassed
+}
+
+// Sampler reports a SmartSample per disk, and an InfrastructureEvent when the predicted health of a disk
+// degrades since the previous sample. Disks in standby are not woken up, so they are not reported until
+// they are active.
+type Sampler struct {
+	context    agent.AgentContext
+	sampleRate time.Duration
+
+	devices func() ([]scannedDevice, error)
+	read    func(scannedDevice) (*Sample, error)
+	// health of the disks in the previous samples, by key
+	previous map[string]string
+}
+
+func NewSampler(context agent.AgentContext) *Sampler {
+	sampleRateSec := config.FREQ_DISABLE_SAMPLING
+	if context != nil {
+		sampleRateSec = context.Config().MetricsSmartSampleRate
+	}
+
+	return &Sampler{
+		context:    context,
+		sampleRate: time.Second * time.Duration(sampleRateSec),
+		devices:    scanDevices,
+		read:       readDevice,
+		previous:   map[string]string{},
+	}
+}
+
+func (s *Sampler) OnStartup() {}
+
+func (s *Sampler) Name() string {
+	return "SmartSampler"
+}
+
+func (s *Sampler) Interval() time.Duration {
+	return s.sampleRate
+}
+
+func (s *Sampler) Disabled() bool {
+	return s.Interval() <= config.FREQ_DISABLE_SAMPLING
+}
+
+func (s *Sampler) Sample() (eventBatch sample.EventBatch, err error) {
+	defer func() {
+		if panicErr := recover(); panicErr != nil {
+			err = fmt.Errorf("Panic in smart.Sampler: %v\nStack: %s", panicErr, debug.Stack())
+		}
+	}()
+
+	devices, err := s.devices()
+	if err != nil {
+		if err != errNoSmartctl {
+			slog.WithError(err).Debug("Unable to list the SMART capable disks.")
+		}
+		return nil, nil
+	}
+	for _, device := range devices {
+		ds, err := s.read(device)
+		if err != nil {
+			slog.WithError(err).WithField("device", device.Name).Debug("Unable to read the SMART data.")
+			continue
+		}
+		eventBatch = append(eventBatch, ds)
+
+		key := ds.key()
+		if previous, ok := s.previous[key]; ok && healthRank[ds.Health] > healthRank[previous] {
+			eventBatch = append(eventBatch, newHealthEvent(ds, previous))
+		}
+		s.previous[key] = ds.Health
+	}
+	return eventBatch, nil
+}
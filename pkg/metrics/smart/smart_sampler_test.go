@@ -0,0 +1,91 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package smart
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSampler_Disabled(t *testing.T) {
+	assert.True(t, NewSampler(nil).Disabled())
+}
+
+func TestSampler_NoSmartctl(t *testing.T) {
+	s := NewSampler(nil)
+	s.devices = func() ([]scannedDevice, error) { return nil, errNoSmartctl }
+
+	batch, err := s.Sample()
+
+	assert.NoError(t, err)
+	assert.Empty(t, batch)
+}
+
+func TestSampler_HealthDegraded(t *testing.T) {
+	// GIVEN a disk reporting no errors
+	var reallocated uint64
+	s := NewSampler(nil)
+	s.devices = func() ([]scannedDevice, error) {
+		return []scannedDevice{{Name: "/dev/sda"}}, nil
+	}
+	s.read = func(device scannedDevice) (*Sample, error) {
+		ds := newSample(device.Name)
+		ds.SerialNumber = "WD-1234"
+		value := reallocated
+		ds.ReallocatedSectors = &value
+		ds.Health = health(ds)
+		return ds, nil
+	}
+
+	// WHEN it is sampled for the first time
+	batch, err := s.Sample()
+	require.NoError(t, err)
+
+	// THEN only its sample is reported
+	require.Len(t, batch, 1)
+	assert.Equal(t, healthPassed, batch[0].(*Sample).Health)
+
+	// WHEN it starts reallocating sectors
+	reallocated = 3
+	batch, err = s.Sample()
+	require.NoError(t, err)
+
+	// THEN an event reports the degraded health
+	require.Len(t, batch, 2)
+	event, ok := batch[1].(*HealthEvent)
+	require.True(t, ok)
+	assert.Equal(t, "InfrastructureEvent", event.EventType)
+	assert.Equal(t, "diskHealthDegraded", event.Action)
+	assert.Equal(t, "WD-1234", event.SerialNumber)
+	assert.Equal(t, healthWarning, event.Health)
+	assert.Equal(t, healthPassed, event.PreviousHealth)
+
+	// AND no more events are reported while the health doesn't degrade further
+	batch, err = s.Sample()
+	require.NoError(t, err)
+	assert.Len(t, batch, 1)
+}
+
+func TestHealth(t *testing.T) {
+	failed := false
+	warning := uint64(2)
+	wornOut := 95.0
+	tests := []struct {
+		name   string
+		sample Sample
+		want   string
+	}{
+		{"no data", Sample{}, healthPassed},
+		{"self-assessment failed", Sample{Passed: &failed}, healthFailing},
+		{"nvme critical warning", Sample{CriticalWarning: &warning}, healthFailing},
+		{"pending sectors", Sample{PendingSectors: &warning}, healthWarning},
+		{"worn out", Sample{PercentageUsed: &wornOut}, healthWarning},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, health(&tt.sample))
+		})
+	}
+}
@@ -0,0 +1,222 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package smart
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+const smartctlTimeout = 30 * time.Second
+
+// smartctl exit status bits signaling that the command failed, instead of reporting the disk status: the
+// command line couldn't be parsed or the device couldn't be opened (or it is in standby).
+const smartctlFailureBits = 0x3
+
+var errNoSmartctl = errors.New("smartctl not found")
+
+// smartctl runs smartctl, from smartmontools 7.0 or newer for its JSON output, with the passed arguments.
+// Overridden by tests.
+var smartctl = func(args ...string) ([]byte, error) {
+	path, err := exec.LookPath("smartctl")
+	if err != nil {
+		return nil, errNoSmartctl
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), smartctlTimeout)
+	defer cancel()
+	output, err := exec.CommandContext(ctx, path, args...).Output()
+	// the exit status is a bitmask, whose higher bits report the disk problems found
+	if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode()&smartctlFailureBits == 0 {
+		err = nil
+	}
+	return output, err
+}
+
+// scannedDevice is a device found by "smartctl --scan".
+type scannedDevice struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+type scanOutput struct {
+	Devices []scannedDevice `json:"devices"`
+}
+
+// smartctlOutput holds the fields of "smartctl --json --all" used by the sampler.
+type smartctlOutput struct {
+	Device struct {
+		Name     string `json:"name"`
+		Protocol string `json:"protocol"`
+	} `json:"device"`
+	ModelName    string `json:"model_name"`
+	SerialNumber string `json:"serial_number"`
+	SmartStatus  *struct {
+		Passed bool `json:"passed"`
+	} `json:"smart_status"`
+	Temperature *struct {
+		Current *float64 `json:"current"`
+	} `json:"temperature"`
+	PowerOnTime *struct {
+		Hours *uint64 `json:"hours"`
+	} `json:"power_on_time"`
+	ATASmartAttributes *struct {
+		Table []ataAttribute `json:"table"`
+	} `json:"ata_smart_attributes"`
+	NVMeHealth *nvmeHealth `json:"nvme_smart_health_information_log"`
+	SCSIErrors *struct {
+		Read  *scsiErrorCounter `json:"read"`
+		Write *scsiErrorCounter `json:"write"`
+	} `json:"scsi_error_counter_log"`
+	SCSIGrownDefects *uint64 `json:"scsi_grown_defect_list"`
+}
+
+type ataAttribute struct {
+	ID         int    `json:"id"`
+	Name       string `json:"name"`
+	Value      int    `json:"value"`
+	Thresh     int    `json:"thresh"`
+	WhenFailed string `json:"when_failed"`
+	Raw        struct {
+		Value uint64 `json:"value"`
+	} `json:"raw"`
+}
+
+type nvmeHealth struct {
+	CriticalWarning         uint64 `json:"critical_warning"`
+	AvailableSpare          uint64 `json:"available_spare"`
+	AvailableSpareThreshold uint64 `json:"available_spare_threshold"`
+	PercentageUsed          uint64 `json:"percentage_used"`
+	MediaErrors             uint64 `json:"media_errors"`
+	NumErrLogEntries        uint64 `json:"num_err_log_entries"`
+}
+
+type scsiErrorCounter struct {
+	TotalUncorrectedErrors uint64 `json:"total_uncorrected_errors"`
+}
+
+// ATA attributes reported by the sampler.
+const (
+	ataReallocatedSectors    = 5
+	ataWearLevelingCount     = 177
+	ataReportedUncorrectable = 187
+	ataPendingSectors        = 197
+	ataOfflineUncorrectable  = 198
+	ataPercentLifetimeRemain = 202
+	ataSSDLifeLeft           = 231
+	ataMediaWearoutIndicator = 233
+)
+
+// ataLifeAttributes are the attributes whose normalized value is the remaining life of an SSD, in percent,
+// depending on the vendor.
+var ataLifeAttributes = []int{ataWearLevelingCount, ataSSDLifeLeft, ataMediaWearoutIndicator, ataPercentLifetimeRemain}
+
+// scanDevices lists the disks smartctl can query.
+func scanDevices() ([]scannedDevice, error) {
+	output, err := smartctl("--scan", "--json")
+	if err != nil {
+		return nil, err
+	}
+	var scan scanOutput
+	if err := json.Unmarshal(output, &scan); err != nil {
+		return nil, err
+	}
+	return scan.Devices, nil
+}
+
+// readDevice queries the SMART data of a disk. Disks in standby are not woken up, so they return an error.
+func readDevice(device scannedDevice) (*Sample, error) {
+	args := []string{"--json", "--all", "--nocheck=standby"}
+	if device.Type != "" {
+		args = append(args, "--device="+device.Type)
+	}
+	output, err := smartctl(append(args, device.Name)...)
+	if err != nil {
+		return nil, err
+	}
+	return parseDevice(output)
+}
+
+// parseDevice returns the sample of a disk from the smartctl JSON output.
+func parseDevice(output []byte) (*Sample, error) {
+	var out smartctlOutput
+	if err := json.Unmarshal(output, &out); err != nil {
+		return nil, err
+	}
+	s := newSample(out.Device.Name)
+	s.Protocol = out.Device.Protocol
+	s.Model = strings.TrimSpace(out.ModelName)
+	s.SerialNumber = strings.TrimSpace(out.SerialNumber)
+	if out.SmartStatus != nil {
+		passed := out.SmartStatus.Passed
+		s.Passed = &passed
+	}
+	if out.Temperature != nil {
+		s.TemperatureCelsius = out.Temperature.Current
+	}
+	if out.PowerOnTime != nil {
+		s.PowerOnHours = out.PowerOnTime.Hours
+	}
+
+	if out.ATASmartAttributes != nil {
+		attributes := map[int]ataAttribute{}
+		var failing []string
+		for _, attribute := range out.ATASmartAttributes.Table {
+			attributes[attribute.ID] = attribute
+			// attributes at or below their threshold predict the failure of the disk
+			if attribute.WhenFailed == "now" {
+				failing = append(failing, attribute.Name)
+			}
+		}
+		s.FailingAttributes = strings.Join(failing, ",")
+		raw := func(id int) *uint64 {
+			if attribute, ok := attributes[id]; ok {
+				value := attribute.Raw.Value
+				return &value
+			}
+			return nil
+		}
+		s.ReallocatedSectors = raw(ataReallocatedSectors)
+		s.PendingSectors = raw(ataPendingSectors)
+		s.OfflineUncorrectable = raw(ataOfflineUncorrectable)
+		s.ReportedUncorrectable = raw(ataReportedUncorrectable)
+		for _, id := range ataLifeAttributes {
+			if attribute, ok := attributes[id]; ok && attribute.Value <= 100 {
+				used := float64(100 - attribute.Value)
+				s.PercentageUsed = &used
+				break
+			}
+		}
+	}
+
+	if nvme := out.NVMeHealth; nvme != nil {
+		used := float64(nvme.PercentageUsed)
+		spare := float64(nvme.AvailableSpare)
+		spareThreshold := float64(nvme.AvailableSpareThreshold)
+		s.PercentageUsed = &used
+		s.AvailableSparePercent = &spare
+		s.AvailableSpareThresholdPercent = &spareThreshold
+		s.MediaErrors = &nvme.MediaErrors
+		s.CriticalWarning = &nvme.CriticalWarning
+		s.ErrorLogEntries = &nvme.NumErrLogEntries
+	}
+
+	if out.SCSIGrownDefects != nil {
+		s.ReallocatedSectors = out.SCSIGrownDefects
+	}
+	if out.SCSIErrors != nil {
+		var uncorrected uint64
+		for _, counter := range []*scsiErrorCounter{out.SCSIErrors.Read, out.SCSIErrors.Write} {
+			if counter != nil {
+				uncorrected += counter.TotalUncorrectedErrors
+			}
+		}
+		s.MediaErrors = &uncorrected
+	}
+
+	s.Health = health(s)
+	return s, nil
+}
@@ -0,0 +1,143 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package smart
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const ataOutput = `{
+  "device": {"name": "/dev/sda", "type": "sat", "protocol": "ATA"},
+  "model_name": "Samsung SSD 860 EVO 500GB ",
+  "serial_number": "S3Z1NB0K123456",
+  "smart_status": {"passed": true},
+  "temperature": {"current": 31},
+  "power_on_time": {"hours": 21543},
+  "ata_smart_attributes": {"table": [
+    {"id": 5, "name": "Reallocated_Sector_Ct", "value": 100, "thresh": 10, "when_failed": "", "raw": {"value": 8}},
+    {"id": 177, "name": "Wear_Leveling_Count", "value": 94, "thresh": 0, "when_failed": "", "raw": {"value": 71}},
+    {"id": 187, "name": "Reported_Uncorrect", "value": 100, "thresh": 0, "when_failed": "", "raw": {"value": 0}},
+    {"id": 197, "name": "Current_Pending_Sector", "value": 100, "thresh": 0, "when_failed": "", "raw": {"value": 0}}
+  ]}
+}`
+
+const nvmeOutput = `{
+  "device": {"name": "/dev/nvme0", "type": "nvme", "protocol": "NVMe"},
+  "model_name": "INTEL SSDPE2KX010T8",
+  "serial_number": "PHLJ0000000001P0DGN",
+  "smart_status": {"passed": true},
+  "temperature": {"current": 38},
+  "power_on_time": {"hours": 13020},
+  "nvme_smart_health_information_log": {
+    "critical_warning": 0, "available_spare": 100, "available_spare_threshold": 10,
+    "percentage_used": 3, "media_errors": 0, "num_err_log_entries": 12
+  }
+}`
+
+const scsiOutput = `{
+  "device": {"name": "/dev/sdb", "type": "scsi", "protocol": "SCSI"},
+  "model_name": "SEAGATE ST4000NM0023",
+  "smart_status": {"passed": true},
+  "scsi_grown_defect_list": 2,
+  "scsi_error_counter_log": {
+    "read": {"total_uncorrected_errors": 1},
+    "write": {"total_uncorrected_errors": 0}
+  }
+}`
+
+func TestParseDevice_ATA(t *testing.T) {
+	s, err := parseDevice([]byte(ataOutput))
+	require.NoError(t, err)
+
+	assert.Equal(t, "SmartSample", s.EventType)
+	assert.Equal(t, "/dev/sda", s.Device)
+	assert.Equal(t, "ATA", s.Protocol)
+	assert.Equal(t, "Samsung SSD 860 EVO 500GB", s.Model)
+	assert.Equal(t, "S3Z1NB0K123456", s.SerialNumber)
+	assert.True(t, *s.Passed)
+	assert.Equal(t, 31.0, *s.TemperatureCelsius)
+	assert.Equal(t, uint64(21543), *s.PowerOnHours)
+	assert.Equal(t, uint64(8), *s.ReallocatedSectors)
+	assert.Equal(t, uint64(0), *s.PendingSectors)
+	assert.Nil(t, s.OfflineUncorrectable)
+	assert.Equal(t, 6.0, *s.PercentageUsed)
+	assert.Empty(t, s.FailingAttributes)
+	assert.Equal(t, healthWarning, s.Health, "reallocated sectors must be reported as a warning")
+}
+
+func TestParseDevice_ATAFailingAttribute(t *testing.T) {
+	output := strings.Replace(ataOutput, `"thresh": 10, "when_failed": ""`, `"thresh": 10, "when_failed": "now"`, 1)
+
+	s, err := parseDevice([]byte(output))
+	require.NoError(t, err)
+
+	assert.Equal(t, "Reallocated_Sector_Ct", s.FailingAttributes)
+	assert.Equal(t, healthFailing, s.Health)
+}
+
+func TestParseDevice_NVMe(t *testing.T) {
+	s, err := parseDevice([]byte(nvmeOutput))
+	require.NoError(t, err)
+
+	assert.Equal(t, "NVMe", s.Protocol)
+	assert.Equal(t, 3.0, *s.PercentageUsed)
+	assert.Equal(t, 100.0, *s.AvailableSparePercent)
+	assert.Equal(t, 10.0, *s.AvailableSpareThresholdPercent)
+	assert.Equal(t, uint64(0), *s.MediaErrors)
+	assert.Equal(t, uint64(0), *s.CriticalWarning)
+	assert.Equal(t, uint64(12), *s.ErrorLogEntries)
+	assert.Nil(t, s.ReallocatedSectors)
+	assert.Equal(t, healthPassed, s.Health, "error log entries alone must not degrade the health")
+}
+
+func TestParseDevice_SCSI(t *testing.T) {
+	s, err := parseDevice([]byte(scsiOutput))
+	require.NoError(t, err)
+
+	assert.Equal(t, uint64(2), *s.ReallocatedSectors)
+	assert.Equal(t, uint64(1), *s.MediaErrors)
+	assert.Nil(t, s.TemperatureCelsius)
+	assert.Equal(t, healthWarning, s.Health)
+}
+
+func TestReadDevice(t *testing.T) {
+	defer func(original func(...string) ([]byte, error)) { smartctl = original }(smartctl)
+
+	// GIVEN smartctl reporting a disk
+	var args []string
+	smartctl = func(a ...string) ([]byte, error) {
+		args = a
+		return []byte(nvmeOutput), nil
+	}
+
+	// WHEN the disk is read
+	s, err := readDevice(scannedDevice{Name: "/dev/nvme0", Type: "nvme"})
+
+	// THEN smartctl is not allowed to wake it up
+	require.NoError(t, err)
+	assert.Equal(t, "/dev/nvme0", s.Device)
+	assert.Equal(t, []string{"--json", "--all", "--nocheck=standby", "--device=nvme", "/dev/nvme0"}, args)
+
+	// AND errors are returned
+	smartctl = func(...string) ([]byte, error) { return nil, errors.New("standby") }
+	_, err = readDevice(scannedDevice{Name: "/dev/sda"})
+	assert.Error(t, err)
+}
+
+func TestScanDevices(t *testing.T) {
+	defer func(original func(...string) ([]byte, error)) { smartctl = original }(smartctl)
+
+	smartctl = func(...string) ([]byte, error) {
+		return []byte(`{"devices": [{"name": "/dev/sda", "type": "sat"}, {"name": "/dev/nvme0", "type": "nvme"}]}`), nil
+	}
+
+	devices, err := scanDevices()
+
+	require.NoError(t, err)
+	assert.Equal(t, []scannedDevice{{Name: "/dev/sda", Type: "sat"}, {Name: "/dev/nvme0", Type: "nvme"}}, devices)
+}
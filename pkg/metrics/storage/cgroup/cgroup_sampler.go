@@ -0,0 +1,253 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+// Package cgroup attributes the IO of the block devices to the systemd services and scopes, from the
+// io.stat files of the cgroup v2 unified hierarchy.
+package cgroup
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"path"
+	"runtime/debug"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/newrelic/infrastructure-agent/internal/agent"
+	"github.com/newrelic/infrastructure-agent/pkg/config"
+	"github.com/newrelic/infrastructure-agent/pkg/log"
+	"github.com/newrelic/infrastructure-agent/pkg/sample"
+)
+
+var (
+	sclog = log.WithComponent("StorageCgroupSampler")
+
+	timeNow = time.Now
+)
+
+// Sample holds the IO of a cgroup in a block device during the sample interval.
+type Sample struct {
+	sample.BaseEvent
+
+	// Path of the cgroup in the unified hierarchy, e.g. system.slice/mysql.service
+	Cgroup string `json:"cgroup"`
+	// Systemd unit of the cgroup, e.g. mysql.service or docker-<id>.scope
+	Unit string `json:"unit"`
+	// Closest slice the unit belongs to, e.g. system.slice
+	Slice string `json:"slice,omitempty"`
+	// Block device, e.g. /dev/sda or /dev/dm-0
+	Device string `json:"device"`
+	// Mount points of the device and its partitions, comma separated
+	MountPoints string `json:"mountPoints,omitempty"`
+
+	ReadBytesPerSec         *float64 `json:"readBytesPerSecond,omitempty"`
+	WriteBytesPerSec        *float64 `json:"writeBytesPerSecond,omitempty"`
+	ReadWriteBytesPerSecond *float64 `json:"readWriteBytesPerSecond,omitempty"`
+	ReadsPerSec             *float64 `json:"readIoPerSecond,omitempty"`
+	WritesPerSec            *float64 `json:"writeIoPerSecond,omitempty"`
+	DiscardBytesPerSec      *float64 `json:"discardBytesPerSecond,omitempty"`
+}
+
+// ioStat is the cumulative IO of a cgroup in a block device, as read from an io.stat line.
+type ioStat struct {
+	cgroup string
+	// device number, as <major>:<minor>
+	device string
+	rbytes uint64
+	wbytes uint64
+	rios   uint64
+	wios   uint64
+	dbytes uint64
+}
+
+func (s ioStat) key() string {
+	return s.cgroup + " " + s.device
+}
+
+// blockDevice is a disk or a device mapper volume IO is accounted to.
+type blockDevice struct {
+	name        string
+	mountPoints []string
+}
+
+// Sampler reports a StorageCgroupSample for each systemd unit with IO in a block device.
+type Sampler struct {
+	context    agent.AgentContext
+	sampleRate time.Duration
+
+	ioStats func() ([]ioStat, error)
+	// devices returns the block devices by device number
+	devices func() map[string]blockDevice
+
+	previous     map[string]ioStat
+	previousTime time.Time
+}
+
+func NewSampler(context agent.AgentContext) *Sampler {
+	sampleRateSec := config.FREQ_DISABLE_SAMPLING
+	if context != nil {
+		sampleRateSec = context.Config().MetricsStorageCgroupSampleRate
+	}
+
+	return &Sampler{
+		context:    context,
+		sampleRate: time.Second * time.Duration(sampleRateSec),
+		ioStats:    readIOStats,
+		devices:    readBlockDevices,
+	}
+}
+
+func (s *Sampler) OnStartup() {}
+
+func (s *Sampler) Name() string {
+	return "StorageCgroupSampler"
+}
+
+func (s *Sampler) Interval() time.Duration {
+	return s.sampleRate
+}
+
+func (s *Sampler) Disabled() bool {
+	return s.Interval() <= config.FREQ_DISABLE_SAMPLING
+}
+
+func (s *Sampler) Sample() (eventBatch sample.EventBatch, err error) {
+	defer func() {
+		if panicErr := recover(); panicErr != nil {
+			err = fmt.Errorf("Panic in cgroup.Sampler: %v\nStack: %s", panicErr, debug.Stack())
+		}
+	}()
+
+	now := timeNow()
+	elapsed := now.Sub(s.previousTime).Seconds()
+	stats, err := s.ioStats()
+	if err != nil {
+		return nil, err
+	}
+
+	// the IO is reported from the second sample, as its rate is needed
+	current := make(map[string]ioStat, len(stats))
+	var devices map[string]blockDevice
+	for _, stat := range stats {
+		current[stat.key()] = stat
+		previous, ok := s.previous[stat.key()]
+		if !ok || elapsed <= 0 {
+			continue
+		}
+		ps := newSample(stat, previous, elapsed)
+		if ps == nil {
+			continue
+		}
+		if devices == nil {
+			devices = s.devices()
+		}
+		ps.Device = stat.device
+		if device, ok := devices[stat.device]; ok {
+			ps.Device = "/dev/" + device.name
+			ps.MountPoints = strings.Join(device.mountPoints, ",")
+		}
+		eventBatch = append(eventBatch, ps)
+	}
+	s.previous = current
+	s.previousTime = now
+	return eventBatch, nil
+}
+
+// newSample returns the rates of the IO since the previous stat, or nil if there was no IO or the
+// counters have been reset, as the cgroup has been recreated.
+func newSample(stat, previous ioStat, elapsed float64) *Sample {
+	if stat.rbytes < previous.rbytes || stat.wbytes < previous.wbytes || stat.rios < previous.rios ||
+		stat.wios < previous.wios || stat.dbytes < previous.dbytes {
+		return nil
+	}
+	if stat.rios == previous.rios && stat.wios == previous.wios && stat.dbytes == previous.dbytes {
+		return nil
+	}
+	rate := func(current, previous uint64) *float64 {
+		r := float64(current-previous) / elapsed
+		return &r
+	}
+	s := &Sample{
+		Cgroup:           stat.cgroup,
+		Unit:             path.Base(stat.cgroup),
+		Slice:            parentSlice(stat.cgroup),
+		ReadBytesPerSec:  rate(stat.rbytes, previous.rbytes),
+		WriteBytesPerSec: rate(stat.wbytes, previous.wbytes),
+		ReadsPerSec:      rate(stat.rios, previous.rios),
+		WritesPerSec:     rate(stat.wios, previous.wios),
+	}
+	readWrite := *s.ReadBytesPerSec + *s.WriteBytesPerSec
+	s.ReadWriteBytesPerSecond = &readWrite
+	if stat.dbytes > previous.dbytes {
+		s.DiscardBytesPerSec = rate(stat.dbytes, previous.dbytes)
+	}
+	s.Type("StorageCgroupSample")
+	return s
+}
+
+// parentSlice returns the closest slice a cgroup belongs to.
+func parentSlice(cgroup string) string {
+	for dir := path.Dir(cgroup); dir != "." && dir != "/"; dir = path.Dir(dir) {
+		if strings.HasSuffix(dir, ".slice") {
+			return path.Base(dir)
+		}
+	}
+	return ""
+}
+
+// isUnit returns whether a cgroup directory belongs to a systemd service or scope. Their IO is reported
+// as a whole, as the io.stat of a cgroup includes the IO of its descendants.
+func isUnit(name string) bool {
+	return strings.HasSuffix(name, ".service") || strings.HasSuffix(name, ".scope")
+}
+
+// parseIOStat parses the io.stat file of a cgroup, with a line for each device:
+// 8:0 rbytes=1459200 wbytes=314773504 rios=192 wios=353 dbytes=0 dios=0
+func parseIOStat(cgroup string, r io.Reader) []ioStat {
+	var stats []ioStat
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		stat := ioStat{cgroup: cgroup, device: fields[0]}
+		for _, field := range fields[1:] {
+			kv := strings.SplitN(field, "=", 2)
+			if len(kv) != 2 {
+				continue
+			}
+			value, err := strconv.ParseUint(kv[1], 10, 64)
+			if err != nil {
+				continue
+			}
+			switch kv[0] {
+			case "rbytes":
+				stat.rbytes = value
+			case "wbytes":
+				stat.wbytes = value
+			case "rios":
+				stat.rios = value
+			case "wios":
+				stat.wios = value
+			case "dbytes":
+				stat.dbytes = value
+			}
+		}
+		stats = append(stats, stat)
+	}
+	return stats
+}
+
+// addMountPoint adds a mount point to a device, keeping them sorted and unique.
+func (d *blockDevice) addMountPoint(mountPoint string) {
+	i := sort.SearchStrings(d.mountPoints, mountPoint)
+	if i < len(d.mountPoints) && d.mountPoints[i] == mountPoint {
+		return
+	}
+	d.mountPoints = append(d.mountPoints, "")
+	copy(d.mountPoints[i+1:], d.mountPoints[i:])
+	d.mountPoints[i] = mountPoint
+}
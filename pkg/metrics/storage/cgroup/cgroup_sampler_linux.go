@@ -0,0 +1,126 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package cgroup
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/newrelic/infrastructure-agent/pkg/helpers"
+)
+
+func readIOStats() ([]ioStat, error) {
+	return readIOStatsDir(helpers.HostSys("fs", "cgroup"))
+}
+
+func readBlockDevices() map[string]blockDevice {
+	return readBlockDevicesFrom(helpers.HostProc("1", "mountinfo"), helpers.HostSys("dev", "block"))
+}
+
+// readIOStatsDir reads the io.stat files of the systemd services and scopes in the cgroup v2 hierarchy
+// mounted in the passed directory.
+func readIOStatsDir(root string) ([]ioStat, error) {
+	if _, err := os.Stat(filepath.Join(root, "cgroup.controllers")); err != nil {
+		return nil, fmt.Errorf("cgroup v2 unified hierarchy not found in %s", root)
+	}
+	var stats []ioStat
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		// cgroups may be removed while walking the hierarchy
+		if err != nil || !info.IsDir() || !isUnit(info.Name()) {
+			return nil
+		}
+		if f, err := os.Open(filepath.Join(path, "io.stat")); err == nil {
+			cgroup, _ := filepath.Rel(root, path)
+			stats = append(stats, parseIOStat(filepath.ToSlash(cgroup), f)...)
+			_ = f.Close()
+		}
+		// the unit io.stat already includes the IO of its descendants
+		return filepath.SkipDir
+	})
+	return stats, err
+}
+
+// readBlockDevicesFrom returns the disks and device mapper volumes in the passed /sys/dev/block directory,
+// with the mount points of the device or its partitions from the passed mountinfo file.
+func readBlockDevicesFrom(mountInfo, sysDevBlock string) map[string]blockDevice {
+	entries, err := ioutil.ReadDir(sysDevBlock)
+	if err != nil {
+		sclog.WithError(err).Debug("Unable to read block devices.")
+		return nil
+	}
+	devices := map[string]blockDevice{}
+	// disk of each partition, by device number
+	disks := map[string]string{}
+	for _, entry := range entries {
+		name, disk, ok := lookupBlockDevice(sysDevBlock, entry.Name())
+		if !ok {
+			continue
+		}
+		if disk != entry.Name() {
+			disks[entry.Name()] = disk
+			continue
+		}
+		devices[disk] = blockDevice{name: name}
+	}
+
+	f, err := os.Open(mountInfo)
+	if err != nil {
+		sclog.WithError(err).Debug("Unable to read mount points.")
+		return devices
+	}
+	defer f.Close()
+	// 36 35 98:0 /mnt1 /mnt2 rw,noatime master:1 - ext3 /dev/root rw,errors=continue
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 5 {
+			continue
+		}
+		number := fields[2]
+		if disk, ok := disks[number]; ok {
+			number = disk
+		}
+		if d, ok := devices[number]; ok {
+			d.addMountPoint(unescapeMountPoint(fields[4]))
+			devices[number] = d
+		}
+	}
+	return devices
+}
+
+// lookupBlockDevice returns the name of a block device, and the number of the disk it belongs to if
+// it's a partition, or its own number otherwise.
+func lookupBlockDevice(sysDevBlock, number string) (name, disk string, ok bool) {
+	dir, err := filepath.EvalSymlinks(filepath.Join(sysDevBlock, number))
+	if err != nil {
+		return "", "", false
+	}
+	uevent, err := ioutil.ReadFile(filepath.Join(dir, "uevent"))
+	if err != nil {
+		return "", "", false
+	}
+	for _, line := range strings.Split(string(uevent), "\n") {
+		if strings.HasPrefix(line, "DEVNAME=") {
+			name = strings.TrimPrefix(line, "DEVNAME=")
+		}
+	}
+	if name == "" {
+		return "", "", false
+	}
+	disk = number
+	if _, err := os.Stat(filepath.Join(dir, "partition")); err == nil {
+		if dev, err := ioutil.ReadFile(filepath.Join(filepath.Dir(dir), "dev")); err == nil {
+			disk = strings.TrimSpace(string(dev))
+		}
+	}
+	return name, disk, true
+}
+
+// unescapeMountPoint replaces the octal escapes of the mountinfo file, as \040 for spaces.
+func unescapeMountPoint(mountPoint string) string {
+	return strings.NewReplacer(`\040`, " ", `\011`, "\t", `\012`, "\n", `\134`, `\`).Replace(mountPoint)
+}
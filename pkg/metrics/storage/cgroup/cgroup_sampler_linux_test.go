@@ -0,0 +1,91 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package cgroup
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+	require.NoError(t, ioutil.WriteFile(path, []byte(content), 0644))
+}
+
+func TestReadIOStatsDir(t *testing.T) {
+	// GIVEN a cgroup v2 hierarchy with services, scopes and their nested cgroups
+	root, err := ioutil.TempDir("", "cgroup")
+	require.NoError(t, err)
+	defer os.RemoveAll(root)
+	writeFile(t, filepath.Join(root, "cgroup.controllers"), "cpu io memory pids\n")
+	writeFile(t, filepath.Join(root, "system.slice", "io.stat"), "8:0 rbytes=9999 wbytes=9999 rios=99 wios=99 dbytes=0 dios=0\n")
+	writeFile(t, filepath.Join(root, "system.slice", "mysql.service", "io.stat"), "8:0 rbytes=1024 wbytes=2048 rios=1 wios=2 dbytes=0 dios=0\n")
+	writeFile(t, filepath.Join(root, "system.slice", "mysql.service", "worker", "io.stat"), "8:0 rbytes=1 wbytes=1 rios=1 wios=1 dbytes=0 dios=0\n")
+	writeFile(t, filepath.Join(root, "user.slice", "user-1000.slice", "session-2.scope", "io.stat"), "253:0 rbytes=4096 wbytes=0 rios=1 wios=0 dbytes=0 dios=0\n")
+
+	// WHEN their io.stat files are read
+	stats, err := readIOStatsDir(root)
+
+	// THEN only the units are read, including the IO of their descendants
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []ioStat{
+		{cgroup: "system.slice/mysql.service", device: "8:0", rbytes: 1024, wbytes: 2048, rios: 1, wios: 2},
+		{cgroup: "user.slice/user-1000.slice/session-2.scope", device: "253:0", rbytes: 4096, rios: 1},
+	}, stats)
+}
+
+func TestReadIOStatsDir_CgroupV1(t *testing.T) {
+	root, err := ioutil.TempDir("", "cgroup")
+	require.NoError(t, err)
+	defer os.RemoveAll(root)
+	require.NoError(t, os.Mkdir(filepath.Join(root, "blkio"), 0755))
+
+	_, err = readIOStatsDir(root)
+
+	assert.Error(t, err)
+}
+
+func TestReadBlockDevicesFrom(t *testing.T) {
+	// GIVEN a /sys/dev/block directory with a partitioned disk, an unmounted disk and a device mapper volume
+	dir, err := ioutil.TempDir("", "blockdevices")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	devices := filepath.Join(dir, "devices")
+	writeFile(t, filepath.Join(devices, "sda", "uevent"), "MAJOR=8\nMINOR=0\nDEVNAME=sda\nDEVTYPE=disk\n")
+	writeFile(t, filepath.Join(devices, "sda", "dev"), "8:0\n")
+	writeFile(t, filepath.Join(devices, "sda", "sda1", "uevent"), "MAJOR=8\nMINOR=1\nDEVNAME=sda1\nDEVTYPE=partition\n")
+	writeFile(t, filepath.Join(devices, "sda", "sda1", "partition"), "1\n")
+	writeFile(t, filepath.Join(devices, "sda", "sda2", "uevent"), "MAJOR=8\nMINOR=2\nDEVNAME=sda2\nDEVTYPE=partition\n")
+	writeFile(t, filepath.Join(devices, "sda", "sda2", "partition"), "2\n")
+	writeFile(t, filepath.Join(devices, "sdb", "uevent"), "MAJOR=8\nMINOR=16\nDEVNAME=sdb\nDEVTYPE=disk\n")
+	writeFile(t, filepath.Join(devices, "dm-0", "uevent"), "MAJOR=253\nMINOR=0\nDEVNAME=dm-0\nDEVTYPE=disk\n")
+	sysDevBlock := filepath.Join(dir, "dev", "block")
+	require.NoError(t, os.MkdirAll(sysDevBlock, 0755))
+	for number, device := range map[string]string{
+		"8:0": "sda", "8:1": "sda/sda1", "8:2": "sda/sda2", "8:16": "sdb", "253:0": "dm-0",
+	} {
+		require.NoError(t, os.Symlink(filepath.Join(devices, device), filepath.Join(sysDevBlock, number)))
+	}
+	// AND a mountinfo file with mount points in the partitions and the volume
+	mountInfo := filepath.Join(dir, "mountinfo")
+	writeFile(t, mountInfo, `22 1 8:1 / / rw,relatime shared:1 - ext4 /dev/sda1 rw
+23 22 8:2 / /boot rw,relatime shared:2 - ext4 /dev/sda2 rw
+24 22 253:0 / /var/lib/my\040data rw,relatime shared:3 - xfs /dev/mapper/vg-data rw
+25 22 0:22 / /proc rw,nosuid shared:4 - proc proc rw
+`)
+
+	// WHEN the block devices are read
+	blockDevices := readBlockDevicesFrom(mountInfo, sysDevBlock)
+
+	// THEN the disks and volumes are returned with the mount points of their partitions
+	assert.Equal(t, map[string]blockDevice{
+		"8:0":   {name: "sda", mountPoints: []string{"/", "/boot"}},
+		"8:16":  {name: "sdb"},
+		"253:0": {name: "dm-0", mountPoints: []string{"/var/lib/my data"}},
+	}, blockDevices)
+}
@@ -0,0 +1,17 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+// +build !linux
+
+package cgroup
+
+import "errors"
+
+// readIOStats is only supported on Linux.
+func readIOStats() ([]ioStat, error) {
+	return nil, errors.New("cgroup IO is only supported on Linux")
+}
+
+// readBlockDevices is only supported on Linux.
+func readBlockDevices() map[string]blockDevice {
+	return nil
+}
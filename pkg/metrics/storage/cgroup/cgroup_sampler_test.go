@@ -0,0 +1,110 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package cgroup
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseIOStat(t *testing.T) {
+	stats := parseIOStat("system.slice/mysql.service", strings.NewReader(
+		"8:0 rbytes=1459200 wbytes=314773504 rios=192 wios=353 dbytes=4096 dios=1\n"+
+			"253:0 rbytes=90112 wbytes=0 rios=22 wios=0 dbytes=0 dios=0\n"+
+			"\n"))
+
+	assert.Equal(t, []ioStat{
+		{cgroup: "system.slice/mysql.service", device: "8:0", rbytes: 1459200, wbytes: 314773504, rios: 192, wios: 353, dbytes: 4096},
+		{cgroup: "system.slice/mysql.service", device: "253:0", rbytes: 90112, rios: 22},
+	}, stats)
+}
+
+func TestParentSlice(t *testing.T) {
+	assert.Equal(t, "system.slice", parentSlice("system.slice/mysql.service"))
+	assert.Equal(t, "user-1000.slice", parentSlice("user.slice/user-1000.slice/session-2.scope"))
+	assert.Equal(t, "", parentSlice("init.scope"))
+}
+
+func TestBlockDevice_AddMountPoint(t *testing.T) {
+	d := blockDevice{name: "sda"}
+	d.addMountPoint("/var")
+	d.addMountPoint("/")
+	d.addMountPoint("/var")
+	d.addMountPoint("/boot")
+
+	assert.Equal(t, []string{"/", "/boot", "/var"}, d.mountPoints)
+}
+
+func TestSampler_Sample(t *testing.T) {
+	now := time.Unix(1611923766, 0)
+	timeNow = func() time.Time { return now }
+	defer func() { timeNow = time.Now }()
+
+	readings := [][]ioStat{{
+		{cgroup: "system.slice/mysql.service", device: "8:0", rbytes: 1000, wbytes: 2000, rios: 10, wios: 20},
+		{cgroup: "system.slice/cron.service", device: "8:0", rbytes: 500, rios: 5},
+		{cgroup: "system.slice/backup.service", device: "8:16", wbytes: 100, wios: 1},
+	}, {
+		{cgroup: "system.slice/mysql.service", device: "8:0", rbytes: 11000, wbytes: 42000, rios: 110, wios: 420, dbytes: 8192},
+		{cgroup: "system.slice/cron.service", device: "8:0", rbytes: 500, rios: 5},
+		{cgroup: "system.slice/backup.service", device: "8:16", wbytes: 1100, wios: 11},
+		{cgroup: "system.slice/new.service", device: "8:0", rbytes: 100, rios: 1},
+	}}
+
+	// GIVEN a sampler reading the io.stat of the systemd units
+	s := NewSampler(nil)
+	s.ioStats = func() ([]ioStat, error) {
+		stats := readings[0]
+		readings = readings[1:]
+		return stats, nil
+	}
+	s.devices = func() map[string]blockDevice {
+		return map[string]blockDevice{"8:0": {name: "sda", mountPoints: []string{"/", "/boot"}}}
+	}
+
+	// WHEN the stats are sampled for the first time
+	batch, err := s.Sample()
+
+	// THEN nothing is reported, as the rates can't be calculated yet
+	require.NoError(t, err)
+	assert.Empty(t, batch)
+
+	// WHEN they are sampled again after 10 seconds
+	now = now.Add(10 * time.Second)
+	batch, err = s.Sample()
+
+	// THEN the rates of the units with IO since the previous sample are reported
+	require.NoError(t, err)
+	require.Len(t, batch, 2)
+
+	mysql := batch[0].(*Sample)
+	assert.Equal(t, "StorageCgroupSample", mysql.EventType)
+	assert.Equal(t, "system.slice/mysql.service", mysql.Cgroup)
+	assert.Equal(t, "mysql.service", mysql.Unit)
+	assert.Equal(t, "system.slice", mysql.Slice)
+	assert.Equal(t, "/dev/sda", mysql.Device)
+	assert.Equal(t, "/,/boot", mysql.MountPoints)
+	assert.Equal(t, 1000.0, *mysql.ReadBytesPerSec)
+	assert.Equal(t, 4000.0, *mysql.WriteBytesPerSec)
+	assert.Equal(t, 5000.0, *mysql.ReadWriteBytesPerSecond)
+	assert.Equal(t, 10.0, *mysql.ReadsPerSec)
+	assert.Equal(t, 40.0, *mysql.WritesPerSec)
+	assert.Equal(t, 819.2, *mysql.DiscardBytesPerSec)
+
+	// devices that can't be resolved are reported by number
+	backup := batch[1].(*Sample)
+	assert.Equal(t, "8:16", backup.Device)
+	assert.Empty(t, backup.MountPoints)
+	assert.Equal(t, 100.0, *backup.WriteBytesPerSec)
+	assert.Nil(t, backup.DiscardBytesPerSec)
+}
+
+func TestNewSample_CounterReset(t *testing.T) {
+	previous := ioStat{cgroup: "system.slice/mysql.service", device: "8:0", rbytes: 1000, rios: 10}
+
+	assert.Nil(t, newSample(ioStat{rbytes: 100, rios: 1}, previous, 10))
+}
@@ -4,6 +4,7 @@ package nfs
 
 import (
 	"fmt"
+	"math"
 	"runtime/debug"
 	"time"
 
@@ -56,6 +57,19 @@ type Sample struct {
 	WritesPerSec *float64 `json:"writesPerSecond,omitempty"`
 	// Total number of operations per second
 	TotalOpsPerSec *float64 `json:"totalOpsPerSecond,omitempty"`
+	// Average time, in milliseconds, taken by the reads completed during the sample, from being queued
+	// by the client to receiving the server reply
+	ReadLatencyMs *float64 `json:"avgReadLatencyMs,omitempty"`
+	// Average time, in milliseconds, taken by the writes completed during the sample
+	WriteLatencyMs *float64 `json:"avgWriteLatencyMs,omitempty"`
+	// Average round trip time, in milliseconds, of the read RPCs completed during the sample
+	ReadRTTMs *float64 `json:"avgReadRttMs,omitempty"`
+	// Average round trip time, in milliseconds, of the write RPCs completed during the sample
+	WriteRTTMs *float64 `json:"avgWriteRttMs,omitempty"`
+	// Number of RPCs retransmitted per second, usually because the server didn't reply in time
+	RetransmissionsPerSec *float64 `json:"retransmissionsPerSecond,omitempty"`
+	// Number of requests per second reaching a major timeout, as logged by "server not responding"
+	MajorTimeoutsPerSec *float64 `json:"majorTimeoutsPerSecond,omitempty"`
 	// NFS version (will be either 3.0 or 4.0)
 	Version *string `json:"version,omitempty"`
 	// Device name
@@ -204,3 +218,17 @@ func NewSampler(context agent.AgentContext) *Sampler {
 		detailed:    detailed,
 	}
 }
+
+func parseFloat(f float64) *float64 {
+	if nanOrInf(f) {
+		return nil
+	}
+	return &f
+}
+
+func nanOrInf(f float64) bool {
+	if math.IsNaN(f) || math.IsInf(f, 0) || math.IsInf(f, 1) {
+		return true
+	}
+	return false
+}
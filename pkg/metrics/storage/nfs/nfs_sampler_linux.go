@@ -6,7 +6,6 @@ package nfs
 
 import (
 	"fmt"
-	"math"
 	"time"
 
 	"github.com/prometheus/procfs"
@@ -135,6 +134,59 @@ func populateNFSPerSecMetrics(lms statsCache, ops []procfs.NFSOperationStats, sa
 	writeBytesPerSec := nfsStatDelta(lms.last.Bytes.WriteTotal, *sample.TotalWriteBytes, lastRun, checkTime)
 	sample.ReadBytesPerSec = &readBytesPerSec
 	sample.WriteBytesPerSec = &writeBytesPerSec
+
+	populateNFSLatencies(lms.last.Operations, ops, sample, lastRun, checkTime)
+}
+
+// populateNFSLatencies sets the average latency and RTT of the reads and writes completed since the last
+// sample, and the rate of retransmissions and major timeouts of all the operations, as nfsiostat does.
+func populateNFSLatencies(last, current []procfs.NFSOperationStats, sample *Sample, lastRun, checkTime time.Time) {
+	lastOps := make(map[string]procfs.NFSOperationStats, len(last))
+	for _, op := range last {
+		lastOps[op.Operation] = op
+	}
+	var lastRetransmissions, retransmissions, lastTimeouts, timeouts uint64
+	for _, op := range current {
+		prev, ok := lastOps[op.Operation]
+		if !ok {
+			continue
+		}
+		lastRetransmissions += retransmissionCount(prev)
+		retransmissions += retransmissionCount(op)
+		lastTimeouts += prev.MajorTimeouts
+		timeouts += op.MajorTimeouts
+
+		// counters are reset when the share is remounted
+		if op.Requests <= prev.Requests || op.CumulativeTotalRequestMilliseconds < prev.CumulativeTotalRequestMilliseconds ||
+			op.CumulativeTotalResponseMilliseconds < prev.CumulativeTotalResponseMilliseconds {
+			continue
+		}
+		requests := float64(op.Requests - prev.Requests)
+		latency := float64(op.CumulativeTotalRequestMilliseconds-prev.CumulativeTotalRequestMilliseconds) / requests
+		rtt := float64(op.CumulativeTotalResponseMilliseconds-prev.CumulativeTotalResponseMilliseconds) / requests
+		switch op.Operation {
+		case "READ":
+			sample.ReadLatencyMs, sample.ReadRTTMs = &latency, &rtt
+		case "WRITE":
+			sample.WriteLatencyMs, sample.WriteRTTMs = &latency, &rtt
+		}
+	}
+	if retransmissions >= lastRetransmissions {
+		perSec := nfsStatDelta(lastRetransmissions, retransmissions, lastRun, checkTime)
+		sample.RetransmissionsPerSec = &perSec
+	}
+	if timeouts >= lastTimeouts {
+		perSec := nfsStatDelta(lastTimeouts, timeouts, lastRun, checkTime)
+		sample.MajorTimeoutsPerSec = &perSec
+	}
+}
+
+// retransmissionCount returns the times the requests of an operation have been transmitted again.
+func retransmissionCount(op procfs.NFSOperationStats) uint64 {
+	if op.Transmissions < op.Requests {
+		return 0
+	}
+	return op.Transmissions - op.Requests
 }
 
 func compareNFSOps(last, current []procfs.NFSOperationStats, lastRun, checkTime time.Time) (float64, float64, float64) {
@@ -168,17 +220,3 @@ func nfsStatDelta(last, current uint64, lastRun, checkTime time.Time) float64 {
 	}
 	return 0
 }
-
-func parseFloat(f float64) *float64 {
-	if nanOrInf(f) {
-		return nil
-	}
-	return &f
-}
-
-func nanOrInf(f float64) bool {
-	if math.IsNaN(f) || math.IsInf(f, 0) || math.IsInf(f, 1) {
-		return true
-	}
-	return false
-}
@@ -84,6 +84,31 @@ func Test_parseNFSOps(t *testing.T) {
 	}
 }
 
+func Test_populateNFSLatencies(t *testing.T) {
+	last := []procfs.NFSOperationStats{
+		{Operation: "READ", Requests: 100, Transmissions: 100, CumulativeTotalResponseMilliseconds: 200, CumulativeTotalRequestMilliseconds: 300},
+		{Operation: "WRITE", Requests: 50, Transmissions: 52, MajorTimeouts: 1, CumulativeTotalResponseMilliseconds: 500, CumulativeTotalRequestMilliseconds: 1000},
+		{Operation: "GETATTR", Requests: 10, Transmissions: 10},
+	}
+	// 20 reads and no writes, with 10 retransmissions and 5 major timeouts during 10 seconds
+	current := []procfs.NFSOperationStats{
+		{Operation: "READ", Requests: 120, Transmissions: 126, CumulativeTotalResponseMilliseconds: 240, CumulativeTotalRequestMilliseconds: 400},
+		{Operation: "WRITE", Requests: 50, Transmissions: 56, MajorTimeouts: 6, CumulativeTotalResponseMilliseconds: 500, CumulativeTotalRequestMilliseconds: 1000},
+		{Operation: "GETATTR", Requests: 10, Transmissions: 10},
+	}
+	lastRun := time.Now()
+	sample := &Sample{}
+
+	populateNFSLatencies(last, current, sample, lastRun, lastRun.Add(10*time.Second))
+
+	assert.Equal(t, 5.0, *sample.ReadLatencyMs)
+	assert.Equal(t, 2.0, *sample.ReadRTTMs)
+	assert.Nil(t, sample.WriteLatencyMs)
+	assert.Nil(t, sample.WriteRTTMs)
+	assert.Equal(t, 1.0, *sample.RetransmissionsPerSec)
+	assert.Equal(t, 0.5, *sample.MajorTimeoutsPerSec)
+}
+
 func Test_nfsStatDelta(t *testing.T) {
 	type args struct {
 		last      uint64
@@ -4,6 +4,124 @@
 
 package nfs
 
-func populateNFS(cache map[string]statsCache, detailed bool) ([]*Sample, error) {
-	return nil, nil
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/shirou/gopsutil/disk"
+
+	"github.com/newrelic/infrastructure-agent/internal/windows"
+	"github.com/newrelic/infrastructure-agent/pkg/log"
+)
+
+// smbFilesystemType is the filesystemType of the samples of the shares accessed through the SMB client.
+const smbFilesystemType = "smb"
+
+// smbCounters are the performance counters of the shares used by the SMB client, with an instance per
+// share named after its UNC path, e.g. \\fileserver\data.
+var smbCounters = []string{
+	`\SMB Client Shares(*)\Read Bytes/sec`,
+	`\SMB Client Shares(*)\Write Bytes/sec`,
+	`\SMB Client Shares(*)\Read Requests/sec`,
+	`\SMB Client Shares(*)\Write Requests/sec`,
+	`\SMB Client Shares(*)\Data Requests/sec`,
+	`\SMB Client Shares(*)\Avg. sec/Read`,
+	`\SMB Client Shares(*)\Avg. sec/Write`,
+}
+
+// Counter indices in the smbCounters array
+const (
+	smbReadBytesSec = iota
+	smbWriteBytesSec
+	smbReadsSec
+	smbWritesSec
+	smbRequestsSec
+	smbSecPerRead
+	smbSecPerWrite
+)
+
+// smbPoll queries the SMB client counters. It is created on the first sample.
+var smbPoll *nrwin.PdhPoll
+
+// populateNFS returns a sample per SMB share used by the host, as Windows doesn't mount NFS shares by
+// default.
+func populateNFS(_ map[string]statsCache, _ bool) ([]*Sample, error) {
+	if smbPoll == nil {
+		p, err := nrwin.NewPdhPoll(log.Debugf, smbCounters...)
+		if err != nil {
+			return nil, fmt.Errorf("error creating SMB client counters query: %s", err)
+		}
+		// rates are calculated from two consecutive collections
+		if _, err := p.PollInstances(); err != nil {
+			return nil, fmt.Errorf("error polling SMB client counters: %s", err)
+		}
+		smbPoll = &p
+	}
+	values, err := smbPoll.PollInstances()
+	if err != nil {
+		return nil, fmt.Errorf("error polling SMB client counters: %s", err)
+	}
+
+	samples := newSMBSamples(values)
+	if len(samples) == 0 {
+		return nil, fmt.Errorf("no SMB shares found")
+	}
+	for _, s := range samples {
+		// shares unreachable or without permissions to be listed don't report their usage
+		usage, err := disk.Usage(*s.Mountpoint + `\`)
+		if err != nil || usage.Total == 0 {
+			continue
+		}
+		s.DiskTotalBytes = &usage.Total
+		s.DiskUsedBytes = &usage.Used
+		s.DiskFreeBytes = &usage.Free
+		s.DiskUsedPercent = parseFloat(usage.UsedPercent)
+		s.DiskFreePercent = parseFloat(float64(usage.Free) / float64(usage.Total) * 100)
+	}
+	return samples, nil
+}
+
+// newSMBSamples returns a sample per SMB share from the polled counters, sorted by share.
+func newSMBSamples(values map[string]map[string]float64) []*Sample {
+	shares := map[string]bool{}
+	for _, instances := range values {
+		for share := range instances {
+			if share != "_Total" && strings.HasPrefix(share, `\`) {
+				shares[share] = true
+			}
+		}
+	}
+	var names []string
+	for share := range shares {
+		names = append(names, share)
+	}
+	sort.Strings(names)
+
+	samples := make([]*Sample, 0, len(names))
+	for _, name := range names {
+		share := name
+		fsType := smbFilesystemType
+		s := &Sample{
+			Device:         &share,
+			Mountpoint:     &share,
+			FilesystemType: &fsType,
+		}
+		value := func(counter int, factor float64) *float64 {
+			v, ok := values[smbCounters[counter]][share]
+			if !ok {
+				return nil
+			}
+			return parseFloat(v * factor)
+		}
+		s.ReadBytesPerSec = value(smbReadBytesSec, 1)
+		s.WriteBytesPerSec = value(smbWriteBytesSec, 1)
+		s.ReadsPerSec = value(smbReadsSec, 1)
+		s.WritesPerSec = value(smbWritesSec, 1)
+		s.TotalOpsPerSec = value(smbRequestsSec, 1)
+		s.ReadLatencyMs = value(smbSecPerRead, 1000)
+		s.WriteLatencyMs = value(smbSecPerWrite, 1000)
+		samples = append(samples, s)
+	}
+	return samples
 }
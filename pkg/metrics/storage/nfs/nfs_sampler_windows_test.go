@@ -0,0 +1,37 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+// +build windows
+
+package nfs
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_newSMBSamples(t *testing.T) {
+	values := map[string]map[string]float64{
+		smbCounters[smbReadBytesSec]:  {`\\fs02\backup`: 0, `\\fs01\data`: 4096, "_Total": 4096},
+		smbCounters[smbWriteBytesSec]: {`\\fs02\backup`: 8192, `\\fs01\data`: 0, "_Total": 8192},
+		smbCounters[smbReadsSec]:      {`\\fs02\backup`: 0, `\\fs01\data`: 2, "_Total": 2},
+		smbCounters[smbWritesSec]:     {`\\fs02\backup`: 4, `\\fs01\data`: 0, "_Total": 4},
+		smbCounters[smbRequestsSec]:   {`\\fs02\backup`: 4, `\\fs01\data`: 2, "_Total": 6},
+		smbCounters[smbSecPerRead]:    {`\\fs02\backup`: 0, `\\fs01\data`: 0.0025, "_Total": 0.0025},
+	}
+
+	samples := newSMBSamples(values)
+
+	require.Len(t, samples, 2)
+	data := samples[0]
+	assert.Equal(t, `\\fs01\data`, *data.Mountpoint)
+	assert.Equal(t, `\\fs01\data`, *data.Device)
+	assert.Equal(t, "smb", *data.FilesystemType)
+	assert.Equal(t, 4096.0, *data.ReadBytesPerSec)
+	assert.Equal(t, 2.0, *data.ReadsPerSec)
+	assert.Equal(t, 2.5, *data.ReadLatencyMs)
+	assert.Nil(t, data.WriteLatencyMs)
+	assert.Equal(t, `\\fs02\backup`, *samples[1].Mountpoint)
+	assert.Equal(t, 8192.0, *samples[1].WriteBytesPerSec)
+}
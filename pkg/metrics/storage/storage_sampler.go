@@ -51,6 +51,8 @@ type BaseSample struct {
 	ReadWriteBytesPerSecond *float64 `json:"readWriteBytesPerSecond,omitempty"`
 	ReadsPerSec             *float64 `json:"readIoPerSecond,omitempty"`
 	WritesPerSec            *float64 `json:"writeIoPerSecond,omitempty"`
+	ReadLatencyMs           *float64 `json:"avgReadLatencyMs,omitempty"`
+	WriteLatencyMs          *float64 `json:"avgWriteLatencyMs,omitempty"`
 	IOTimeDelta             uint64   `json:"-"`
 	ReadTimeDelta           uint64   `json:"-"`
 	WriteTimeDelta          uint64   `json:"-"`
@@ -250,6 +252,9 @@ func (ss *Sampler) Sample() (samples sample.EventBatch, err error) {
 		activeDevices[p.Device] = true
 	}
 
+	// file systems and volume managers whose usage isn't fully reported by statfs
+	populateVolumesOS(dev2Samples)
+
 	// Gather IO stats if the OS supports it
 	ioCounters, err := ss.storageUtilities.IOCounters()
 	if err != nil {
@@ -357,6 +362,8 @@ func populateSample(source, dest *Sample) {
 	dest.WritesPerSec = source.WritesPerSec
 	dest.ReadBytesPerSec = source.ReadBytesPerSec
 	dest.WriteBytesPerSec = source.WriteBytesPerSec
+	dest.ReadLatencyMs = source.ReadLatencyMs
+	dest.WriteLatencyMs = source.WriteLatencyMs
 	dest.ReadWriteBytesPerSecond = calculateReadWriteBytesPerSecond(source.ReadBytesPerSec, source.WriteBytesPerSec)
 	dest.IOTimeDelta = source.IOTimeDelta
 	dest.ReadTimeDelta = source.ReadTimeDelta
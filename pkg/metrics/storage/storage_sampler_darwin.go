@@ -38,3 +38,6 @@ func populateSampleOS(source, dest *Sample) {
 
 func populateUsageOS(_ *disk.UsageStat, _ *Sample) {
 }
+
+func populateVolumesOS(_ map[string][]*Sample) {
+}
@@ -54,6 +54,22 @@ type Sample struct {
 	InodesFree        *uint64  `json:"inodesFree,omitempty"`
 	InodesTotal       *uint64  `json:"inodesTotal,omitempty"`
 	InodesUsedPercent *float64 `json:"inodesUsedPercent,omitempty"`
+	// Average number of IOs queued or being served by the device during the sample, as avgqu-sz in iostat
+	AvgQueueLen *float64 `json:"avgQueueLen,omitempty"`
+	// IOs in flight when the sample was taken
+	CurrentQueueLen *float64 `json:"currentQueueLen,omitempty"`
+	// Space allocated to the btrfs data and metadata chunks and used inside them, and raw device space not
+	// allocated to any chunk. Metadata exhaustion fails the writes even if statfs reports free space.
+	BtrfsDataAllocatedBytes     *uint64  `json:"btrfsDataAllocatedBytes,omitempty"`
+	BtrfsDataUsedBytes          *uint64  `json:"btrfsDataUsedBytes,omitempty"`
+	BtrfsMetadataAllocatedBytes *uint64  `json:"btrfsMetadataAllocatedBytes,omitempty"`
+	BtrfsMetadataUsedBytes      *uint64  `json:"btrfsMetadataUsedBytes,omitempty"`
+	BtrfsMetadataUsedPercent    *float64 `json:"btrfsMetadataUsedPercent,omitempty"`
+	BtrfsUnallocatedBytes       *uint64  `json:"btrfsUnallocatedBytes,omitempty"`
+	// Usage of the LVM thin pool backing a thin volume, which can fill up before the volume does
+	LvmThinPool                    string   `json:"lvmThinPool,omitempty"`
+	LvmThinPoolDataUsedPercent     *float64 `json:"lvmThinPoolDataUsedPercent,omitempty"`
+	LvmThinPoolMetadataUsedPercent *float64 `json:"lvmThinPoolMetadataUsedPercent,omitempty"`
 }
 
 // Enhanced from GOPSUtil, Adding Utilization
@@ -68,6 +84,7 @@ type LinuxIoCountersStat struct {
 	WriteTime               uint64 `json:"writeTime"`
 	IopsInProgress          uint64 `json:"iopsInProgress"`
 	IoTime                  uint64 `json:"ioTime"`
+	WeightedIoTime          uint64 `json:"weightedIoTime"`
 	Name                    string `json:"name"`
 	SerialNumber            string `json:"serialNumber"`
 	TotalUtilizationPercent uint64 `json:"totalUtilizationPercent"`
@@ -138,7 +155,9 @@ func NewStorageSampleWrapper(cfg *config.Config) SampleWrapper {
 
 // populateSampleOS complements the populateSample function by copying into the destinations the fields from the source
 // that are exclusive of Linux Storage Samples
-func populateSampleOS(_, _ *Sample) {
+func populateSampleOS(source, dest *Sample) {
+	dest.AvgQueueLen = source.AvgQueueLen
+	dest.CurrentQueueLen = source.CurrentQueueLen
 }
 
 // populateUsage copies the Usage Stats inside the destination sample, for those metrics that are exclusive of Linux
@@ -183,7 +202,25 @@ func CalculateSampleValues(ioCounter IOCountersStat, ioLastStats IOCountersStat,
 			result.WriteUtilizationPercent = &writeUtilizationPercent
 		}
 		result.TotalUtilizationPercent = &percentUtilized
+
+		// the weighted IO time grows by the number of IOs in flight on each millisecond
+		if counter.WeightedIoTime >= lastStats.WeightedIoTime {
+			avgQueueLen := float64(counter.WeightedIoTime-lastStats.WeightedIoTime) / float64(elapsedMs)
+			result.AvgQueueLen = &avgQueueLen
+		}
+	}
+
+	// latencies are not reported when the counters are reset, e.g. when a device is re-attached
+	if counter.ReadCount > lastStats.ReadCount && counter.ReadTime >= lastStats.ReadTime {
+		readLatency := float64(readTimeDelta) / float64(readCountDelta)
+		result.ReadLatencyMs = &readLatency
 	}
+	if counter.WriteCount > lastStats.WriteCount && counter.WriteTime >= lastStats.WriteTime {
+		writeLatency := float64(writeTimeDelta) / float64(writeCountDelta)
+		result.WriteLatencyMs = &writeLatency
+	}
+	inFlight := float64(counter.IopsInProgress)
+	result.CurrentQueueLen = &inFlight
 
 	readsPerSec := acquire.CalculateSafeDelta(counter.ReadCount, lastStats.ReadCount, elapsedSeconds)
 	writesPerSec := acquire.CalculateSafeDelta(counter.WriteCount, lastStats.WriteCount, elapsedSeconds)
@@ -506,6 +543,10 @@ func fetchIoCounters() (map[string]IOCountersStat, error) {
 		if err != nil {
 			return ret, err
 		}
+		weightedIoTime, err := strconv.ParseUint(fields[13], 10, 64)
+		if err != nil {
+			return ret, err
+		}
 		d := LinuxIoCountersStat{
 			ReadBytes:        rbytes * SectorSize,
 			WriteBytes:       wbytes * SectorSize,
@@ -517,6 +558,7 @@ func fetchIoCounters() (map[string]IOCountersStat, error) {
 			WriteTime:        wtime,
 			IopsInProgress:   iopsInProgress,
 			IoTime:           iotime,
+			WeightedIoTime:   weightedIoTime,
 		}
 		if d == empty {
 			continue
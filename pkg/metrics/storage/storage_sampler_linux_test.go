@@ -96,6 +96,42 @@ func TestCalculateBytesRate(t *testing.T) {
 	assert.Equal(t, *ioSample.WritesPerSec, float64(0))
 }
 
+func TestCalculateLatencyAndQueueLength(t *testing.T) {
+	lastStats := &LinuxIoCountersStat{
+		ReadCount:      uint64(1000),
+		WriteCount:     uint64(500),
+		ReadTime:       uint64(4000),
+		WriteTime:      uint64(9000),
+		WeightedIoTime: uint64(20000),
+	}
+	// 200 reads taking 100ms and 50 writes taking 400ms, with 3 IOs in flight on average during 2 seconds
+	counter := &LinuxIoCountersStat{
+		ReadCount:      uint64(1200),
+		WriteCount:     uint64(550),
+		ReadTime:       uint64(4100),
+		WriteTime:      uint64(9400),
+		WeightedIoTime: uint64(26000),
+		IopsInProgress: uint64(5),
+	}
+
+	ioSample := CalculateSampleValues(counter, lastStats, 2000)
+
+	assert.Equal(t, 0.5, *ioSample.ReadLatencyMs)
+	assert.Equal(t, 8.0, *ioSample.WriteLatencyMs)
+	assert.Equal(t, 3.0, *ioSample.AvgQueueLen)
+	assert.Equal(t, 5.0, *ioSample.CurrentQueueLen)
+
+	// without writes, nor a weighted IO time increase after a counters reset
+	counter.WriteCount, counter.WriteTime = lastStats.WriteCount, lastStats.WriteTime
+	counter.WeightedIoTime = 10
+
+	ioSample = CalculateSampleValues(counter, lastStats, 2000)
+
+	assert.NotNil(t, ioSample.ReadLatencyMs)
+	assert.Nil(t, ioSample.WriteLatencyMs)
+	assert.Nil(t, ioSample.AvgQueueLen)
+}
+
 func TestMarshallableSamples(t *testing.T) {
 	testCases := []struct {
 		elapsedTime int64
@@ -23,6 +23,9 @@ type PdhIoCountersStat struct {
 	AvgReadQueueLen  float64
 	AvgWriteQueueLen float64
 	CurrentQueueLen  float64
+
+	SecPerRead  float64
+	SecPerWrite float64
 }
 
 func (d *PdhIoCountersStat) String() string {
@@ -48,6 +51,9 @@ var metricsNames = []string{
 	"\\LogicalDisk(%s)\\Avg. Disk Read Queue Length",
 	"\\LogicalDisk(%s)\\Avg. Disk Write Queue Length",
 	"\\LogicalDisk(%s)\\Current Disk Queue Length",
+
+	"\\LogicalDisk(%s)\\Avg. Disk sec/Read",
+	"\\LogicalDisk(%s)\\Avg. Disk sec/Write",
 }
 
 // Metric indices in the "metrics" array
@@ -64,6 +70,9 @@ const (
 	avgReadQueueLen
 	avgWriteQueueLen
 	currentQueueLen
+
+	secPerRead
+	secPerWrite
 )
 
 // PdhIoCounters polls for disk IO counters using the Windows PDH interface
@@ -139,6 +148,9 @@ func (io *PdhIoCounters) IoCounters(partitions []PartitionStat) (map[string]IOCo
 			AvgReadQueueLen:  values[fmt.Sprintf(metricsNames[avgReadQueueLen], p.Device)],
 			AvgWriteQueueLen: values[fmt.Sprintf(metricsNames[avgWriteQueueLen], p.Device)],
 			CurrentQueueLen:  values[fmt.Sprintf(metricsNames[currentQueueLen], p.Device)],
+
+			SecPerRead:  values[fmt.Sprintf(metricsNames[secPerRead], p.Device)],
+			SecPerWrite: values[fmt.Sprintf(metricsNames[secPerWrite], p.Device)],
 		}
 	}
 	return counters, nil
@@ -146,6 +158,8 @@ func (io *PdhIoCounters) IoCounters(partitions []PartitionStat) (map[string]IOCo
 
 // CalculatePdhSampleValues return a Sample instance, calculated from a single PdhIoCountersStat
 func CalculatePdhSampleValues(s, _ *PdhIoCountersStat, elapsedMs int64) *Sample {
+	readLatencyMs := s.SecPerRead * 1000
+	writeLatencyMs := s.SecPerWrite * 1000
 	return &Sample{
 		BaseSample: BaseSample{
 			ReadsPerSec:             &s.ReadsPerSec,
@@ -155,6 +169,8 @@ func CalculatePdhSampleValues(s, _ *PdhIoCountersStat, elapsedMs int64) *Sample
 			TotalUtilizationPercent: &s.TimePercent,
 			ReadUtilizationPercent:  &s.ReadTimePercent,
 			WriteUtilizationPercent: &s.WriteTimePercent,
+			ReadLatencyMs:           &readLatencyMs,
+			WriteLatencyMs:          &writeLatencyMs,
 			HasDelta:                true,
 			WriteCountDelta:         uint64(s.WritesPerSec * float64(elapsedMs) / 1000),
 			ReadCountDelta:          uint64(s.ReadsPerSec * float64(elapsedMs) / 1000),
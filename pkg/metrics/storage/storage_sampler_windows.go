@@ -192,3 +192,6 @@ func populateSampleOS(source, dest *Sample) {
 // populateUsage copies the Usage Stats inside the destination sample, for those metrics that are exclusive of Windows
 func populateUsageOS(fsUsage *disk.UsageStat, dest *Sample) {
 }
+
+func populateVolumesOS(_ map[string][]*Sample) {
+}
@@ -0,0 +1,244 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+// +build linux
+
+package storage
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/newrelic/infrastructure-agent/pkg/helpers"
+)
+
+const (
+	btrfsFsType = "btrfs"
+	// block device sizes in sysfs are reported in 512 bytes sectors, whatever the device sector size
+	sysfsSectorSize = 512
+)
+
+// btrfsAllocation is the space of a btrfs block group type (data, metadata or system): the bytes allocated to
+// its chunks, the bytes used inside them, and the raw disk bytes allocated, which are larger for redundant
+// profiles.
+type btrfsAllocation struct {
+	total, used, diskTotal uint64
+}
+
+// populateVolumesOS complements the statfs usage of the btrfs file systems and the LVM thin volumes, whose
+// free space doesn't account for the chunk allocation or the thin pool over-provisioning.
+func populateVolumesOS(dev2Samples map[string][]*Sample) {
+	var hasBtrfs, hasLvm bool
+	for device, samples := range dev2Samples {
+		if _, isLvm := isLvmMount(device); isLvm {
+			hasLvm = true
+		}
+		for _, s := range samples {
+			if s.FileSystemType == btrfsFsType {
+				hasBtrfs = true
+			}
+		}
+	}
+	if hasBtrfs {
+		populateBtrfs(helpers.HostSys("fs", "btrfs"), dev2Samples)
+	}
+	if hasLvm {
+		populateThinPools(dev2Samples)
+	}
+}
+
+// populateBtrfs sets the chunk allocation of the btrfs file systems, read from sysDir (/sys/fs/btrfs).
+func populateBtrfs(sysDir string, dev2Samples map[string][]*Sample) {
+	fsDirs, err := btrfsDevices(sysDir)
+	if err != nil {
+		sslog.WithError(err).Debug("Can't read btrfs file systems.")
+		return
+	}
+	for device, samples := range dev2Samples {
+		if len(samples) == 0 || samples[0].FileSystemType != btrfsFsType {
+			continue
+		}
+		fsDir, ok := fsDirs[blockDeviceName(device)]
+		if !ok {
+			sslog.WithField("device", device).Debug("Can't find btrfs file system of device.")
+			continue
+		}
+		data, dataErr := readBtrfsAllocation(filepath.Join(fsDir, "allocation", "data"))
+		metadata, metadataErr := readBtrfsAllocation(filepath.Join(fsDir, "allocation", "metadata"))
+		if dataErr != nil || metadataErr != nil {
+			sslog.WithField("device", device).Debug("Can't read btrfs allocation.")
+			continue
+		}
+		unallocated, unallocatedErr := btrfsUnallocated(fsDir, data, metadata)
+
+		for _, s := range samples {
+			s.BtrfsDataAllocatedBytes = uint64Ptr(data.total)
+			s.BtrfsDataUsedBytes = uint64Ptr(data.used)
+			s.BtrfsMetadataAllocatedBytes = uint64Ptr(metadata.total)
+			s.BtrfsMetadataUsedBytes = uint64Ptr(metadata.used)
+			if metadata.total > 0 {
+				percent := float64(metadata.used) / float64(metadata.total) * 100
+				s.BtrfsMetadataUsedPercent = &percent
+			}
+			if unallocatedErr == nil {
+				s.BtrfsUnallocatedBytes = uint64Ptr(unallocated)
+			}
+		}
+	}
+}
+
+// btrfsDevices maps the block devices to the sysfs directory of the btrfs file system they belong to,
+// e.g. sda2 -> /sys/fs/btrfs/<UUID>.
+func btrfsDevices(sysDir string) (map[string]string, error) {
+	entries, err := ioutil.ReadDir(sysDir)
+	if err != nil {
+		return nil, err
+	}
+	fsDirs := map[string]string{}
+	for _, entry := range entries {
+		devices, err := ioutil.ReadDir(filepath.Join(sysDir, entry.Name(), "devices"))
+		if err != nil {
+			// e.g. the features directory
+			continue
+		}
+		for _, device := range devices {
+			fsDirs[device.Name()] = filepath.Join(sysDir, entry.Name())
+		}
+	}
+	return fsDirs, nil
+}
+
+// blockDeviceName returns the kernel name of a device path, resolving the device mapper links,
+// e.g. /dev/mapper/vg0-root -> dm-0.
+func blockDeviceName(device string) string {
+	if resolved, err := filepath.EvalSymlinks(device); err == nil {
+		device = resolved
+	}
+	return filepath.Base(device)
+}
+
+func readBtrfsAllocation(dir string) (a btrfsAllocation, err error) {
+	if a.total, err = readSysUint(filepath.Join(dir, "total_bytes")); err != nil {
+		return
+	}
+	if a.used, err = readSysUint(filepath.Join(dir, "bytes_used")); err != nil {
+		return
+	}
+	a.diskTotal, err = readSysUint(filepath.Join(dir, "disk_total"))
+	return
+}
+
+// btrfsUnallocated returns the raw device bytes not allocated to any chunk yet. Once they are exhausted, full
+// metadata chunks can't grow and writes fail with ENOSPC, regardless of the free data space.
+func btrfsUnallocated(fsDir string, data, metadata btrfsAllocation) (uint64, error) {
+	devices, err := ioutil.ReadDir(filepath.Join(fsDir, "devices"))
+	if err != nil {
+		return 0, err
+	}
+	var size uint64
+	for _, device := range devices {
+		sectors, err := readSysUint(filepath.Join(fsDir, "devices", device.Name(), "size"))
+		if err != nil {
+			return 0, err
+		}
+		size += sectors * sysfsSectorSize
+	}
+	allocated := data.diskTotal + metadata.diskTotal
+	if system, err := readBtrfsAllocation(filepath.Join(fsDir, "allocation", "system")); err == nil {
+		allocated += system.diskTotal
+	}
+	if allocated > size {
+		return 0, nil
+	}
+	return size - allocated, nil
+}
+
+func readSysUint(path string) (uint64, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseUint(strings.TrimSpace(string(content)), 10, 64)
+}
+
+// thinPool is the usage of an LVM thin pool, whose volumes can be provisioned beyond its size.
+type thinPool struct {
+	name                     string
+	dataPercent, metaPercent *float64
+}
+
+// populateThinPools sets the usage of the pool of the mounted LVM thin volumes. The pools are read
+// with lvs, which requires privileges.
+func populateThinPools(dev2Samples map[string][]*Sample) {
+	lvs, err := exec.LookPath("lvs")
+	if err != nil {
+		lvs = "/sbin/lvs"
+	}
+	out, err := invoke.Command(lvs, "--noheadings", "--nosuffix", "--separator", ";",
+		"-o", "vg_name,lv_name,lv_attr,pool_lv,data_percent,metadata_percent")
+	if err != nil {
+		sslog.WithError(err).Debug("Can't read LVM thin pools.")
+		return
+	}
+	for device, pool := range parseThinVolumes(string(out)) {
+		for _, s := range dev2Samples[device] {
+			s.LvmThinPool = pool.name
+			s.LvmThinPoolDataUsedPercent = pool.dataPercent
+			s.LvmThinPoolMetadataUsedPercent = pool.metaPercent
+		}
+	}
+}
+
+// parseThinVolumes parses the lvs output and returns the pool of each thin volume, by the paths of the volume
+// device: /dev/<vg>/<lv> and /dev/mapper/<vg>-<lv>, e.g.
+// vg0;pool0;twi-aotz--;;42.10;7.35
+// vg0;home;Vwi-aotz--;pool0;61.02;
+func parseThinVolumes(out string) map[string]thinPool {
+	type volume struct{ vg, lv, pool string }
+	var volumes []volume
+	pools := map[string]thinPool{}
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Split(strings.TrimSpace(line), ";")
+		if len(fields) < 6 || fields[2] == "" {
+			continue
+		}
+		vg, lv, attr, pool := fields[0], fields[1], fields[2], fields[3]
+		switch attr[0] {
+		case 't':
+			pools[vg+"/"+lv] = thinPool{
+				name:        vg + "/" + lv,
+				dataPercent: parsePercent(fields[4]),
+				metaPercent: parsePercent(fields[5]),
+			}
+		case 'V':
+			volumes = append(volumes, volume{vg: vg, lv: lv, pool: pool})
+		}
+	}
+
+	thinVolumes := map[string]thinPool{}
+	for _, v := range volumes {
+		pool, ok := pools[v.vg+"/"+v.pool]
+		if !ok {
+			continue
+		}
+		thinVolumes[fmt.Sprintf("/dev/%s/%s", v.vg, v.lv)] = pool
+		// device mapper escapes the dashes of the names by doubling them
+		thinVolumes[fmt.Sprintf("/dev/mapper/%s-%s", strings.Replace(v.vg, "-", "--", -1), strings.Replace(v.lv, "-", "--", -1))] = pool
+	}
+	return thinVolumes
+}
+
+func parsePercent(field string) *float64 {
+	percent, err := strconv.ParseFloat(strings.TrimSpace(field), 64)
+	if err != nil {
+		return nil
+	}
+	return &percent
+}
+
+func uint64Ptr(v uint64) *uint64 {
+	return &v
+}
@@ -0,0 +1,111 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+// +build linux
+
+package storage
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeInvoker struct {
+	out []byte
+	err error
+}
+
+func (f fakeInvoker) Command(string, ...string) ([]byte, error) {
+	return f.out, f.err
+}
+
+func writeSysFiles(t *testing.T, dir string, files map[string]string) {
+	for path, content := range files {
+		path = filepath.Join(dir, path)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, ioutil.WriteFile(path, []byte(content), 0644))
+	}
+}
+
+func TestPopulateBtrfs(t *testing.T) {
+	// GIVEN a btrfs file system on a 10GiB device, with metadata chunks almost full
+	dir, err := ioutil.TempDir("", "btrfs")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	writeSysFiles(t, dir, map[string]string{
+		"features/mixed_groups":                    "0\n",
+		"0b4c6d2e/devices/sdb1/size":               "20971520\n",
+		"0b4c6d2e/allocation/data/total_bytes":     "8589934592\n",
+		"0b4c6d2e/allocation/data/bytes_used":      "4294967296\n",
+		"0b4c6d2e/allocation/data/disk_total":      "8589934592\n",
+		"0b4c6d2e/allocation/metadata/total_bytes": "536870912\n",
+		"0b4c6d2e/allocation/metadata/bytes_used":  "515396075\n",
+		"0b4c6d2e/allocation/metadata/disk_total":  "1073741824\n",
+		"0b4c6d2e/allocation/system/total_bytes":   "8388608\n",
+		"0b4c6d2e/allocation/system/bytes_used":    "16384\n",
+		"0b4c6d2e/allocation/system/disk_total":    "16777216\n",
+		"9f8e7d6c/devices/sdc1/size":               "2048\n",
+		"9f8e7d6c/allocation/data/total_bytes":     "1\n",
+	})
+	root := &Sample{}
+	root.FileSystemType = "btrfs"
+	ext := &Sample{}
+	ext.FileSystemType = "ext4"
+	samples := map[string][]*Sample{"/dev/sdb1": {root}, "/dev/sda1": {ext}}
+
+	// WHEN the btrfs allocation is populated
+	populateBtrfs(dir, samples)
+
+	// THEN the allocation is reported for the btrfs file system
+	require.NotNil(t, root.BtrfsDataAllocatedBytes)
+	assert.Equal(t, uint64(8589934592), *root.BtrfsDataAllocatedBytes)
+	assert.Equal(t, uint64(4294967296), *root.BtrfsDataUsedBytes)
+	assert.Equal(t, uint64(536870912), *root.BtrfsMetadataAllocatedBytes)
+	assert.Equal(t, uint64(515396075), *root.BtrfsMetadataUsedBytes)
+	assert.InDelta(t, 96.0, *root.BtrfsMetadataUsedPercent, 0.01)
+	assert.Equal(t, uint64(10737418240-8589934592-1073741824-16777216), *root.BtrfsUnallocatedBytes)
+	// AND not for other file systems
+	assert.Nil(t, ext.BtrfsDataAllocatedBytes)
+}
+
+func TestParseThinVolumes(t *testing.T) {
+	out := `  centos;root;-wi-ao----;;;
+  vg-data;pool0;twi-aotz--;;42.10;7.35
+  vg-data;home-dir;Vwi-aotz--;pool0;61.02;
+  vg-data;orphan;Vwi-a-tz--;missing;10.00;
+`
+	volumes := parseThinVolumes(out)
+
+	require.Len(t, volumes, 2)
+	for _, device := range []string{"/dev/vg-data/home-dir", "/dev/mapper/vg--data-home--dir"} {
+		pool, ok := volumes[device]
+		require.True(t, ok, device)
+		assert.Equal(t, "vg-data/pool0", pool.name)
+		assert.Equal(t, 42.10, *pool.dataPercent)
+		assert.Equal(t, 7.35, *pool.metaPercent)
+	}
+}
+
+func TestPopulateThinPools(t *testing.T) {
+	previous := invoke
+	defer func() { invoke = previous }()
+
+	// GIVEN a thin volume and a linear one
+	invoke = fakeInvoker{out: []byte("  vg0;root;-wi-ao----;;;\n  vg0;pool0;twi-aotz--;;90.50;12.00\n  vg0;var;Vwi-aotz--;pool0;30.00;\n")}
+	thin := &Sample{}
+	plain := &Sample{}
+
+	// WHEN the pools of the volumes are populated
+	populateThinPools(map[string][]*Sample{"/dev/mapper/vg0-var": {thin}, "/dev/mapper/vg0-root": {plain}})
+
+	// THEN the thin volume reports the usage of its pool
+	assert.Equal(t, "vg0/pool0", thin.LvmThinPool)
+	assert.Equal(t, 90.5, *thin.LvmThinPoolDataUsedPercent)
+	assert.Equal(t, 12.0, *thin.LvmThinPoolMetadataUsedPercent)
+	assert.Empty(t, plain.LvmThinPool)
+	assert.Nil(t, plain.LvmThinPoolDataUsedPercent)
+}
@@ -28,27 +28,40 @@ type SystemSample struct {
 	*LoadSample
 	*MemorySample
 	*DiskSample
+	*PressureSample
+	*ConntrackSample
+	*KernelActivitySample
 }
 
 type SystemSampler struct {
-	CpuMonitor     *CPUMonitor
-	DiskMonitor    *DiskMonitor
-	LoadMonitor    *LoadMonitor
-	MemoryMonitor  *MemoryMonitor
-	context        agent.AgentContext
-	stopChannel    chan bool
-	waitForCleanup *sync.WaitGroup
+	CpuMonitor          *CPUMonitor
+	CPUCoreMonitor      *CPUCoreMonitor
+	DiskMonitor         *DiskMonitor
+	LoadMonitor         *LoadMonitor
+	MemoryMonitor       *MemoryMonitor
+	PressureMonitor     *PressureMonitor
+	ConntrackMonitor    *ConntrackMonitor
+	KernelMonitor       *KernelActivityMonitor
+	CgroupLimitsMonitor *CgroupLimitsMonitor
+	context             agent.AgentContext
+	stopChannel         chan bool
+	waitForCleanup      *sync.WaitGroup
 }
 
 func NewSystemSampler(context agent.AgentContext, storageSampler *storage.Sampler) *SystemSampler {
 	cfg := context.Config()
 	return &SystemSampler{
-		CpuMonitor:     NewCPUMonitor(context),
-		DiskMonitor:    NewDiskMonitor(storageSampler),
-		LoadMonitor:    NewLoadMonitor(),
-		MemoryMonitor:  NewMemoryMonitor(cfg.IgnoreReclaimable),
-		context:        context,
-		waitForCleanup: &sync.WaitGroup{},
+		CpuMonitor:          NewCPUMonitor(context),
+		CPUCoreMonitor:      NewCPUCoreMonitor(context),
+		DiskMonitor:         NewDiskMonitor(storageSampler),
+		LoadMonitor:         NewLoadMonitor(),
+		MemoryMonitor:       NewMemoryMonitor(cfg.IgnoreReclaimable),
+		PressureMonitor:     NewPressureMonitor(),
+		ConntrackMonitor:    NewConntrackMonitor(),
+		KernelMonitor:       NewKernelActivityMonitor(),
+		CgroupLimitsMonitor: NewCgroupLimitsMonitor(),
+		context:             context,
+		waitForCleanup:      &sync.WaitGroup{},
 	}
 }
 
@@ -113,9 +126,55 @@ func (s *SystemSampler) Sample() (results sample.EventBatch, err error) {
 		sample.MemorySample = memorySample
 	}
 
+	if s.context != nil && s.context.Config().CgroupRelativeUtilization {
+		if err := s.CgroupLimitsMonitor.Apply(sample.CPUSample, sample.MemorySample); err != nil {
+			syslog.WithError(err).Debug("Unable to apply the cgroup limits, reporting the host utilization.")
+		}
+	}
+
+	// pressure stall information is optional, so failing to collect it doesn't discard the system sample
+	if pressureSample, err := s.PressureMonitor.Sample(); err != nil {
+		syslog.WithError(err).Debug("Unable to sample pressure stall information.")
+	} else {
+		sample.PressureSample = pressureSample
+	}
+
+	if conntrackSample, err := s.ConntrackMonitor.Sample(); err != nil {
+		syslog.WithError(err).Debug("Unable to sample the connection tracking table.")
+	} else {
+		sample.ConntrackSample = conntrackSample
+	}
+
+	if kernelSample, err := s.KernelMonitor.Sample(); err != nil {
+		syslog.WithError(err).Debug("Unable to sample the kernel activity.")
+	} else {
+		sample.KernelActivitySample = kernelSample
+	}
+
 	if s.Debug() {
 		helpers.LogStructureDetails(syslog, sample, "SystemSample", "final", nil)
 	}
 	results = append(results, sample)
-	return
+
+	// the cluster, core and NUMA node samples are optional, so failing to collect them doesn't discard the
+	// system one
+	if clusterSamples, err := s.CpuMonitor.ClusterSamples(); err != nil {
+		syslog.WithError(err).Debug("Unable to sample CPU clusters.")
+	} else {
+		for _, clusterSample := range clusterSamples {
+			results = append(results, clusterSample)
+		}
+	}
+
+	if coreSamples, nodeSamples, err := s.CPUCoreMonitor.Sample(); err != nil {
+		syslog.WithError(err).Debug("Unable to sample CPU cores.")
+	} else {
+		for _, coreSample := range coreSamples {
+			results = append(results, coreSample)
+		}
+		for _, nodeSample := range nodeSamples {
+			results = append(results, nodeSample)
+		}
+	}
+	return results, nil
 }
@@ -0,0 +1,98 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package systemd
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/coreos/go-systemd/v22/dbus"
+
+	"github.com/newrelic/infrastructure-agent/pkg/helpers"
+)
+
+const dbusSystemBusAddressEnvVar = "DBUS_SYSTEM_BUS_ADDRESS"
+
+// accountedTypes are the unit types with a cgroup, whose D-Bus interfaces report the resource usage.
+var accountedTypes = map[string]string{
+	"service": "Service",
+	"socket":  "Socket",
+	"mount":   "Mount",
+	"swap":    "Swap",
+	"slice":   "Slice",
+	"scope":   "Scope",
+}
+
+// readUnits reads the loaded units matching the patterns from the systemd D-Bus API.
+func readUnits(patterns []string) ([]*UnitSample, error) {
+	if _, fnd := os.LookupEnv(dbusSystemBusAddressEnvVar); !fnd {
+		_ = os.Setenv(dbusSystemBusAddressEnvVar, fmt.Sprintf("unix:path=%s", helpers.HostVar("/run/dbus/system_bus_socket")))
+	}
+	conn, err := dbus.New()
+	if err != nil {
+		return nil, errNoSystemd
+	}
+	defer conn.Close()
+
+	statuses, err := conn.ListUnitsByPatterns(nil, patterns)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	samples := make([]*UnitSample, 0, len(statuses))
+	for _, status := range statuses {
+		unitProps, err := conn.GetUnitProperties(status.Name)
+		if err != nil {
+			slog.WithError(err).WithField("unit", status.Name).Debug("Unable to read the unit properties.")
+		}
+		var typeProps map[string]interface{}
+		if unitType, ok := accountedTypes[status.Name[strings.LastIndex(status.Name, ".")+1:]]; ok {
+			if typeProps, err = conn.GetUnitTypeProperties(status.Name, unitType); err != nil {
+				slog.WithError(err).WithField("unit", status.Name).Debug("Unable to read the unit resource usage.")
+			}
+		}
+		samples = append(samples, unitSample(status, unitProps, typeProps, now))
+	}
+	return samples, nil
+}
+
+// unitSample builds the sample of a unit from its status, the properties of its Unit D-Bus interface
+// and the properties of its type specific interface.
+func unitSample(status dbus.UnitStatus, unitProps, typeProps map[string]interface{}, now time.Time) *UnitSample {
+	s := newUnitSample(status.Name)
+	s.Description = status.Description
+	s.LoadState = status.LoadState
+	s.ActiveState = status.ActiveState
+	s.SubState = status.SubState
+
+	// timestamps are in microseconds since the epoch, zero if the unit never entered the state
+	if enter, ok := unitProps["ActiveEnterTimestamp"].(uint64); ok && enter > 0 && status.ActiveState == "active" {
+		duration := now.Sub(time.Unix(0, int64(enter)*int64(time.Microsecond))).Seconds()
+		if duration >= 0 {
+			s.ActiveDurationSeconds = &duration
+		}
+	}
+	if restarts, ok := typeProps["NRestarts"].(uint32); ok {
+		s.RestartCount = &restarts
+	}
+	if pid, ok := typeProps["MainPID"].(uint32); ok && pid > 0 {
+		s.MainPID = &pid
+	}
+	s.cpuUsageNs = accounted(typeProps, "CPUUsageNSec")
+	s.MemoryBytes = accounted(typeProps, "MemoryCurrent")
+	s.TaskCount = accounted(typeProps, "TasksCurrent")
+	return s
+}
+
+// accounted returns a resource usage property, or nil if the unit accounting is disabled, in which case
+// systemd reports the maximum integer.
+func accounted(props map[string]interface{}, name string) *uint64 {
+	value, ok := props[name].(uint64)
+	if !ok || value == math.MaxUint64 {
+		return nil
+	}
+	return &value
+}
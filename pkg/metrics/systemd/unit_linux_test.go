@@ -0,0 +1,63 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package systemd
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/coreos/go-systemd/v22/dbus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnitSample(t *testing.T) {
+	now := time.Now()
+	status := dbus.UnitStatus{
+		Name:        "nginx.service",
+		Description: "A high performance web server",
+		LoadState:   "loaded",
+		ActiveState: "active",
+		SubState:    "running",
+	}
+	unitProps := map[string]interface{}{
+		"ActiveEnterTimestamp": uint64(now.Add(-time.Minute).UnixNano() / int64(time.Microsecond)),
+	}
+	typeProps := map[string]interface{}{
+		"NRestarts":     uint32(3),
+		"MainPID":       uint32(1234),
+		"CPUUsageNSec":  uint64(5000000000),
+		"MemoryCurrent": uint64(52428800),
+		// accounting disabled
+		"TasksCurrent": uint64(math.MaxUint64),
+	}
+
+	s := unitSample(status, unitProps, typeProps, now)
+
+	assert.Equal(t, "SystemdUnitSample", s.EventType)
+	assert.Equal(t, "nginx.service", s.Unit)
+	assert.Equal(t, "A high performance web server", s.Description)
+	assert.Equal(t, "loaded", s.LoadState)
+	assert.Equal(t, "active", s.ActiveState)
+	assert.Equal(t, "running", s.SubState)
+	require.NotNil(t, s.ActiveDurationSeconds)
+	assert.InDelta(t, 60, *s.ActiveDurationSeconds, 0.01)
+	assert.Equal(t, uint32(3), *s.RestartCount)
+	assert.Equal(t, uint32(1234), *s.MainPID)
+	assert.Equal(t, uint64(5000000000), *s.cpuUsageNs)
+	assert.Equal(t, uint64(52428800), *s.MemoryBytes)
+	assert.Nil(t, s.TaskCount)
+}
+
+func TestUnitSample_Inactive(t *testing.T) {
+	status := dbus.UnitStatus{Name: "backup.timer", LoadState: "loaded", ActiveState: "inactive", SubState: "dead"}
+
+	s := unitSample(status, map[string]interface{}{"ActiveEnterTimestamp": uint64(0)}, nil, time.Now())
+
+	assert.Equal(t, "inactive", s.ActiveState)
+	assert.Nil(t, s.ActiveDurationSeconds)
+	assert.Nil(t, s.RestartCount)
+	assert.Nil(t, s.MainPID)
+	assert.Nil(t, s.MemoryBytes)
+}
@@ -0,0 +1,9 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+// +build !linux
+
+package systemd
+
+func readUnits(_ []string) ([]*UnitSample, error) {
+	return nil, errNoSystemd
+}
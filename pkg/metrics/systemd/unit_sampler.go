@@ -0,0 +1,171 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+// Package systemd samples the state and resource usage of an allowlisted set of systemd units through
+// D-Bus, and reports an event when any of them fails or is restarted.
+package systemd
+
+import (
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"time"
+
+	"github.com/newrelic/infrastructure-agent/internal/agent"
+	"github.com/newrelic/infrastructure-agent/pkg/config"
+	"github.com/newrelic/infrastructure-agent/pkg/log"
+	"github.com/newrelic/infrastructure-agent/pkg/sample"
+)
+
+const stateFailed = "failed"
+
+var slog = log.WithComponent("SystemdUnitSampler")
+
+var errNoSystemd = errors.New("no systemd found")
+
+// UnitSample holds the state and resource usage of a systemd unit. The resource usage is only reported
+// for the units whose cgroup accounting is enabled.
+type UnitSample struct {
+	sample.BaseEvent
+
+	Unit        string `json:"unitName"`
+	Description string `json:"unitDescription,omitempty"`
+	// Whether the unit configuration is loaded: loaded, not-found, masked...
+	LoadState string `json:"loadState"`
+	// High level state of the unit: active, inactive, failed, activating or deactivating
+	ActiveState string `json:"activeState"`
+	// Unit type specific state, e.g. running or exited for services
+	SubState string `json:"subState"`
+	// Seconds since the unit entered the active state, only reported for the active units
+	ActiveDurationSeconds *float64 `json:"activeDurationSeconds,omitempty"`
+	// Number of automatic restarts of a service since it was started manually
+	RestartCount *uint32 `json:"restartCount,omitempty"`
+	MainPID      *uint32 `json:"mainPid,omitempty"`
+	// CPU time used by the unit processes during the sample interval, as a percentage of a single CPU
+	CPUPercent  *float64 `json:"cpuPercent,omitempty"`
+	MemoryBytes *uint64  `json:"memoryBytes,omitempty"`
+	TaskCount   *uint64  `json:"taskCount,omitempty"`
+
+	// cumulative CPU time, to calculate the usage between samples
+	cpuUsageNs *uint64
+}
+
+func newUnitSample(unit string) *UnitSample {
+	s := &UnitSample{Unit: unit}
+	s.Type("SystemdUnitSample")
+	return s
+}
+
+// UnitEvent is the InfrastructureEvent reported when a unit fails or is restarted.
+type UnitEvent struct {
+	sample.BaseEvent
+
+	Category      string `json:"category"`
+	Action        string `json:"action"`
+	Summary       string `json:"summary"`
+	Unit          string `json:"unitName"`
+	ActiveState   string `json:"activeState"`
+	SubState      string `json:"subState"`
+	PreviousState string `json:"previousActiveState"`
+}
+
+func newUnitEvent(s *UnitSample, previous *UnitSample, action, summary string) *UnitEvent {
+	e := &UnitEvent{
+		Category:      "systemd",
+		Action:        action,
+		Summary:       summary,
+		Unit:          s.Unit,
+		ActiveState:   s.ActiveState,
+		SubState:      s.SubState,
+		PreviousState: previous.ActiveState,
+	}
+	e.Type("InfrastructureEvent")
+	return e
+}
+
+// Sampler reports a SystemdUnitSample per unit matching the systemd_units patterns, and an
+// InfrastructureEvent when a unit enters the failed state or its restart count increases since the
+// previous sample.
+type Sampler struct {
+	context    agent.AgentContext
+	sampleRate time.Duration
+	patterns   []string
+
+	// units returns the loaded units matching the patterns
+	units func(patterns []string) ([]*UnitSample, error)
+	// samples of the previous invocation, by unit
+	previous     map[string]*UnitSample
+	previousTime time.Time
+}
+
+func NewSampler(context agent.AgentContext) *Sampler {
+	sampleRateSec := config.FREQ_DISABLE_SAMPLING
+	var patterns []string
+	if context != nil {
+		sampleRateSec = context.Config().MetricsSystemdUnitSampleRate
+		patterns = context.Config().SystemdUnits
+	}
+
+	return &Sampler{
+		context:    context,
+		sampleRate: time.Second * time.Duration(sampleRateSec),
+		patterns:   patterns,
+		units:      readUnits,
+	}
+}
+
+func (s *Sampler) OnStartup() {}
+
+func (s *Sampler) Name() string {
+	return "SystemdUnitSampler"
+}
+
+func (s *Sampler) Interval() time.Duration {
+	return s.sampleRate
+}
+
+func (s *Sampler) Disabled() bool {
+	return s.Interval() <= config.FREQ_DISABLE_SAMPLING || len(s.patterns) == 0
+}
+
+func (s *Sampler) Sample() (eventBatch sample.EventBatch, err error) {
+	defer func() {
+		if panicErr := recover(); panicErr != nil {
+			err = fmt.Errorf("Panic in systemd.Sampler: %v\nStack: %s", panicErr, debug.Stack())
+		}
+	}()
+
+	units, err := s.units(s.patterns)
+	if err != nil {
+		if err != errNoSystemd {
+			slog.WithError(err).Debug("Unable to read the systemd units.")
+		}
+		return nil, nil
+	}
+	now := time.Now()
+	elapsed := now.Sub(s.previousTime)
+
+	current := make(map[string]*UnitSample, len(units))
+	for _, unit := range units {
+		current[unit.Unit] = unit
+		eventBatch = append(eventBatch, unit)
+
+		previous, ok := s.previous[unit.Unit]
+		if !ok {
+			continue
+		}
+		if unit.cpuUsageNs != nil && previous.cpuUsageNs != nil && *unit.cpuUsageNs >= *previous.cpuUsageNs && elapsed > 0 {
+			cpuPercent := float64(*unit.cpuUsageNs-*previous.cpuUsageNs) / float64(elapsed.Nanoseconds()) * 100
+			unit.CPUPercent = &cpuPercent
+		}
+		if unit.ActiveState == stateFailed && previous.ActiveState != stateFailed {
+			eventBatch = append(eventBatch, newUnitEvent(unit, previous, "unitFailed",
+				fmt.Sprintf("Systemd unit %s failed", unit.Unit)))
+		} else if unit.RestartCount != nil && previous.RestartCount != nil && *unit.RestartCount > *previous.RestartCount {
+			eventBatch = append(eventBatch, newUnitEvent(unit, previous, "unitRestarted",
+				fmt.Sprintf("Systemd unit %s restarted %d times", unit.Unit, *unit.RestartCount-*previous.RestartCount)))
+		}
+	}
+	s.previous = current
+	s.previousTime = now
+	return eventBatch, nil
+}
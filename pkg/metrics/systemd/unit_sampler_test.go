@@ -0,0 +1,88 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package systemd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/newrelic/infrastructure-agent/internal/agent/mocks"
+	"github.com/newrelic/infrastructure-agent/pkg/config"
+)
+
+func TestSampler_Disabled(t *testing.T) {
+	assert.True(t, NewSampler(nil).Disabled())
+
+	// units must be listed for the sampler to be enabled
+	ctx := new(mocks.AgentContext)
+	ctx.On("Config").Return(&config.Config{MetricsSystemdUnitSampleRate: 30})
+	assert.True(t, NewSampler(ctx).Disabled())
+
+	ctx = new(mocks.AgentContext)
+	ctx.On("Config").Return(&config.Config{MetricsSystemdUnitSampleRate: 30, SystemdUnits: []string{"nginx.service"}})
+	s := NewSampler(ctx)
+	assert.False(t, s.Disabled())
+	assert.Equal(t, 30*time.Second, s.Interval())
+}
+
+func TestSampler_NoSystemd(t *testing.T) {
+	s := NewSampler(nil)
+	s.units = func([]string) ([]*UnitSample, error) { return nil, errNoSystemd }
+
+	batch, err := s.Sample()
+
+	assert.NoError(t, err)
+	assert.Empty(t, batch)
+}
+
+func TestSampler_Events(t *testing.T) {
+	unit := func(name, state string, restarts uint32, cpuNs uint64) *UnitSample {
+		s := newUnitSample(name)
+		s.ActiveState = state
+		s.RestartCount = &restarts
+		s.cpuUsageNs = &cpuNs
+		return s
+	}
+	// GIVEN two running services
+	s := NewSampler(nil)
+	s.patterns = []string{"*.service"}
+	s.units = func(patterns []string) ([]*UnitSample, error) {
+		assert.Equal(t, []string{"*.service"}, patterns)
+		return []*UnitSample{unit("nginx.service", "active", 0, 0), unit("worker.service", "active", 0, 0)}, nil
+	}
+	batch, err := s.Sample()
+	require.NoError(t, err)
+	require.Len(t, batch, 2)
+	assert.Nil(t, batch[0].(*UnitSample).CPUPercent, "CPU usage needs a previous sample")
+
+	// WHEN one of them fails and the other is restarted
+	s.previousTime = time.Now().Add(-10 * time.Second)
+	s.units = func([]string) ([]*UnitSample, error) {
+		return []*UnitSample{unit("nginx.service", "failed", 0, 0), unit("worker.service", "active", 2, uint64(5*time.Second))}, nil
+	}
+	batch, err = s.Sample()
+	require.NoError(t, err)
+
+	// THEN an event is reported for each unit
+	require.Len(t, batch, 4)
+	failed := batch[1].(*UnitEvent)
+	assert.Equal(t, "InfrastructureEvent", failed.EventType)
+	assert.Equal(t, "unitFailed", failed.Action)
+	assert.Equal(t, "nginx.service", failed.Unit)
+	assert.Equal(t, "active", failed.PreviousState)
+	restarted := batch[3].(*UnitEvent)
+	assert.Equal(t, "unitRestarted", restarted.Action)
+	assert.Equal(t, "Systemd unit worker.service restarted 2 times", restarted.Summary)
+	// AND the CPU usage is calculated from the previous sample
+	worker := batch[2].(*UnitSample)
+	require.NotNil(t, worker.CPUPercent)
+	assert.InDelta(t, 50, *worker.CPUPercent, 1)
+
+	// AND the failure is not reported again while the unit remains failed
+	batch, err = s.Sample()
+	require.NoError(t, err)
+	assert.Len(t, batch, 2)
+}
@@ -0,0 +1,226 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package tcphealth
+
+import (
+	"encoding/json"
+	"strings"
+	"sync"
+)
+
+// intervalPlaceholder is replaced in the scripts by the sample interval, in seconds.
+const intervalPlaceholder = "INTERVAL_SEC"
+
+// windowMarker is printed by the scripts after the maps of each window.
+const windowMarker = "window"
+
+// tracepointsScript traces the retransmissions and outgoing connections, through the TCP and socket
+// tracepoints of Linux 4.16 or newer. The IPv4 addresses are stored mapped to IPv6 in the *_v6 fields.
+const tracepointsScript = `
+tracepoint:tcp:tcp_retransmit_skb
+{
+	@retransmits[ntop(args->daddr_v6)] = count();
+}
+
+tracepoint:sock:inet_sock_set_state
+/args->protocol == 6 && args->newstate == 2/
+{
+	@connecting[args->skaddr] = nsecs;
+}
+
+tracepoint:sock:inet_sock_set_state
+/args->protocol == 6 && args->oldstate == 2/
+{
+	$start = @connecting[args->skaddr];
+	if ($start != 0) {
+		if (args->newstate == 1) {
+			@connect[ntop(args->daddr_v6)] = stats((nsecs - $start) / 1000);
+		} else {
+			@connect_failures[ntop(args->daddr_v6)] = count();
+		}
+	}
+	delete(@connecting[args->skaddr]);
+}
+`
+
+// rttScript traces the smoothed round trip time of the received segments. It needs the kernel BTF type
+// information (CONFIG_DEBUG_INFO_BTF) to read the TCP sockets.
+const rttScript = `
+kprobe:tcp_rcv_established
+{
+	$sk = (struct sock *)arg0;
+	$tp = (struct tcp_sock *)arg0;
+	$rtt = $tp->srtt_us >> 3;
+	if ($sk->__sk_common.skc_family == 2) {
+		@rtt[ntop($sk->__sk_common.skc_daddr)] = hist($rtt);
+		@rtt_stats[ntop($sk->__sk_common.skc_daddr)] = stats($rtt);
+	} else {
+		@rtt[ntop($sk->__sk_common.skc_v6_daddr.in6_u.u6_addr8)] = hist($rtt);
+		@rtt_stats[ntop($sk->__sk_common.skc_v6_daddr.in6_u.u6_addr8)] = stats($rtt);
+	}
+}
+`
+
+// windowScript prints and clears the maps every interval. The maps are cleared on exit so bpftrace
+// doesn't print them.
+const windowScript = `
+interval:s:` + intervalPlaceholder + `
+{
+	print(@retransmits); print(@connect); print(@connect_failures);
+	clear(@retransmits); clear(@connect); clear(@connect_failures);
+	RTT_PRINT
+	printf("` + windowMarker + `\n");
+}
+
+END
+{
+	clear(@connecting); clear(@retransmits); clear(@connect); clear(@connect_failures);
+	RTT_CLEAR
+}
+`
+
+// scripts returns the bpftrace programs to try, from the most to the least complete.
+func scripts(intervalSec string) []string {
+	window := strings.Replace(windowScript, intervalPlaceholder, intervalSec, 1)
+	withRTT := strings.NewReplacer(
+		"RTT_PRINT", "print(@rtt); print(@rtt_stats); clear(@rtt); clear(@rtt_stats);",
+		"RTT_CLEAR", "clear(@rtt); clear(@rtt_stats);",
+	).Replace(window)
+	withoutRTT := strings.NewReplacer("RTT_PRINT", "", "RTT_CLEAR", "").Replace(window)
+	return []string{
+		tracepointsScript + rttScript + withRTT,
+		tracepointsScript + withoutRTT,
+	}
+}
+
+type stats struct {
+	Count   uint64  `json:"count"`
+	Average float64 `json:"average"`
+	Total   float64 `json:"total"`
+}
+
+type histBucket struct {
+	Min   *int64 `json:"min"`
+	Max   *int64 `json:"max"`
+	Count uint64 `json:"count"`
+}
+
+// window holds the TCP events traced in a sample interval, by destination address.
+type window struct {
+	retransmits     map[string]uint64
+	connects        map[string]stats
+	connectFailures map[string]uint64
+	rtt             map[string][]histBucket
+	rttStats        map[string]stats
+}
+
+func newWindow() *window {
+	return &window{
+		retransmits:     map[string]uint64{},
+		connects:        map[string]stats{},
+		connectFailures: map[string]uint64{},
+		rtt:             map[string][]histBucket{},
+		rttStats:        map[string]stats{},
+	}
+}
+
+// output is a line of the bpftrace JSON output, e.g. {"type": "map", "data": {"@retransmits": {...}}}.
+type output struct {
+	Type string          `json:"type"`
+	Data json.RawMessage `json:"data"`
+}
+
+// collector aggregates the bpftrace JSON output in windows.
+type collector struct {
+	lock     sync.Mutex
+	current  *window
+	complete *window
+	stopped  bool
+	// attached is closed once bpftrace attached its probes
+	attached chan struct{}
+	once     sync.Once
+}
+
+func newCollector() *collector {
+	return &collector{current: newWindow(), attached: make(chan struct{})}
+}
+
+// feed processes a line of the bpftrace output. Unknown or malformed lines are ignored.
+func (c *collector) feed(line []byte) {
+	var out output
+	if err := json.Unmarshal(line, &out); err != nil {
+		return
+	}
+	switch out.Type {
+	case "attached_probes":
+		c.once.Do(func() { close(c.attached) })
+	case "printf":
+		var text string
+		if json.Unmarshal(out.Data, &text) == nil && strings.TrimSpace(text) == windowMarker {
+			c.lock.Lock()
+			c.complete, c.current = c.current, newWindow()
+			c.lock.Unlock()
+		}
+	case "map":
+		var maps map[string]map[string]uint64
+		if json.Unmarshal(out.Data, &maps) != nil {
+			return
+		}
+		c.lock.Lock()
+		defer c.lock.Unlock()
+		for name, values := range maps {
+			target := c.current.retransmits
+			if name == "@connect_failures" {
+				target = c.current.connectFailures
+			} else if name != "@retransmits" {
+				continue
+			}
+			for address, count := range values {
+				target[address] += count
+			}
+		}
+	case "stats":
+		var maps map[string]map[string]stats
+		if json.Unmarshal(out.Data, &maps) != nil {
+			return
+		}
+		c.lock.Lock()
+		defer c.lock.Unlock()
+		for name, values := range maps {
+			target := c.current.connects
+			if name == "@rtt_stats" {
+				target = c.current.rttStats
+			} else if name != "@connect" {
+				continue
+			}
+			for address, s := range values {
+				target[address] = s
+			}
+		}
+	case "hist":
+		var maps map[string]map[string][]histBucket
+		if json.Unmarshal(out.Data, &maps) != nil {
+			return
+		}
+		c.lock.Lock()
+		defer c.lock.Unlock()
+		for address, buckets := range maps["@rtt"] {
+			c.current.rtt[address] = buckets
+		}
+	}
+}
+
+// stop flags that bpftrace exited.
+func (c *collector) stop() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.stopped = true
+}
+
+func (c *collector) take() (*window, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	w := c.complete
+	c.complete = nil
+	return w, !c.stopped
+}
@@ -0,0 +1,85 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package tcphealth
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScripts(t *testing.T) {
+	s := scripts("30")
+
+	require.Len(t, s, 2)
+	assert.Contains(t, s[0], "kprobe:tcp_rcv_established")
+	assert.Contains(t, s[0], "print(@rtt);")
+	assert.NotContains(t, s[1], "@rtt")
+	for _, script := range s {
+		assert.Contains(t, script, "interval:s:30")
+		assert.NotContains(t, script, intervalPlaceholder)
+		assert.NotContains(t, script, "RTT_")
+	}
+}
+
+func TestCollector(t *testing.T) {
+	c := newCollector()
+
+	// GIVEN bpftrace attaching its probes
+	c.feed([]byte(`{"type": "attached_probes", "data": {"probes": 4}}`))
+	select {
+	case <-c.attached:
+	default:
+		t.Fatal("probes not flagged as attached")
+	}
+	w, running := c.take()
+	assert.Nil(t, w)
+	assert.True(t, running)
+
+	// WHEN it prints the maps of a window
+	lines := []string{
+		`{"type": "map", "data": {"@retransmits": {"::ffff:10.0.0.7": 3, "2001:db8::1": 1}}}`,
+		`{"type": "stats", "data": {"@connect": {"::ffff:10.0.0.7": {"count": 2, "average": 1500, "total": 3000}}}}`,
+		`{"type": "map", "data": {"@connect_failures": {"::ffff:10.0.0.9": 4}}}`,
+		`{"type": "hist", "data": {"@rtt": {"10.0.0.7": [{"min": 256, "max": 511, "count": 9}, {"min": 512, "max": 1023, "count": 1}]}}}`,
+		`{"type": "stats", "data": {"@rtt_stats": {"10.0.0.7": {"count": 10, "average": 400, "total": 4000}}}}`,
+		`not json`,
+		`{"type": "printf", "data": "window\n"}`,
+		`{"type": "map", "data": {"@retransmits": {"::ffff:10.0.0.7": 8}}}`,
+	}
+	for _, line := range lines {
+		c.feed([]byte(line))
+	}
+
+	// THEN the complete window is taken once
+	w, running = c.take()
+	require.NotNil(t, w)
+	assert.True(t, running)
+	assert.Equal(t, map[string]uint64{"::ffff:10.0.0.7": 3, "2001:db8::1": 1}, w.retransmits)
+	assert.Equal(t, stats{Count: 2, Average: 1500, Total: 3000}, w.connects["::ffff:10.0.0.7"])
+	assert.Equal(t, uint64(4), w.connectFailures["::ffff:10.0.0.9"])
+	assert.Len(t, w.rtt["10.0.0.7"], 2)
+	assert.Equal(t, uint64(10), w.rttStats["10.0.0.7"].Count)
+	w, _ = c.take()
+	assert.Nil(t, w)
+
+	// AND the tracer is reported as stopped once bpftrace exits
+	c.stop()
+	_, running = c.take()
+	assert.False(t, running)
+}
+
+func TestCollector_IgnoresUnknownMaps(t *testing.T) {
+	c := newCollector()
+
+	c.feed([]byte(`{"type": "map", "data": {"@connecting": {"18446623015445161984": 1234}}}`))
+	c.feed([]byte(`{"type": "printf", "data": "` + strings.Repeat("x", 3) + `\n"}`))
+	c.feed([]byte(`{"type": "printf", "data": "window\n"}`))
+
+	w, _ := c.take()
+	require.NotNil(t, w)
+	assert.Empty(t, w.retransmits)
+	assert.Empty(t, w.connectFailures)
+}
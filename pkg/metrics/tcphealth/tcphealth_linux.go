@@ -0,0 +1,142 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package tcphealth
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/newrelic/infrastructure-agent/pkg/helpers"
+)
+
+// attachTimeout bounds the time bpftrace takes to compile the script and attach its probes.
+const attachTimeout = time.Minute
+
+var errNoBPFTrace = errors.New("bpftrace not found")
+
+// startBPFTrace runs bpftrace with the most complete script the kernel supports, printing the traced
+// events every intervalSec seconds.
+func startBPFTrace(intervalSec int) (tracer, error) {
+	path, err := exec.LookPath("bpftrace")
+	if err != nil {
+		return nil, errNoBPFTrace
+	}
+	for _, script := range scripts(strconv.Itoa(intervalSec)) {
+		var c *collector
+		if c, err = runBPFTrace(path, script); err == nil {
+			return c, nil
+		}
+		slog.WithError(err).Debug("Unable to run the bpftrace script, trying with a simpler one.")
+	}
+	return nil, err
+}
+
+// runBPFTrace runs a bpftrace script, returning once its probes are attached.
+func runBPFTrace(path, script string) (*collector, error) {
+	cmd := exec.Command(path, "-f", "json", "-e", script)
+	// bpftrace must not outlive the agent, so the probes are detached if it is killed
+	cmd.SysProcAttr = &syscall.SysProcAttr{Pdeathsig: syscall.SIGKILL}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	c := newCollector()
+	exited := make(chan struct{})
+	go func() {
+		defer close(exited)
+		scanner := bufio.NewScanner(stdout)
+		// the maps of a window are printed in a single line
+		scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+		for scanner.Scan() {
+			c.feed(scanner.Bytes())
+		}
+		if err := scanner.Err(); err != nil {
+			slog.WithError(err).Warn("Unable to read the bpftrace output.")
+			_ = cmd.Process.Kill()
+		}
+		if err := cmd.Wait(); err != nil {
+			slog.WithError(err).WithField("stderr", strings.TrimSpace(stderr.String())).Debug("bpftrace exited.")
+		}
+		c.stop()
+	}()
+
+	select {
+	case <-c.attached:
+		return c, nil
+	case <-exited:
+		return nil, fmt.Errorf("bpftrace exited: %s", strings.TrimSpace(stderr.String()))
+	case <-time.After(attachTimeout):
+		_ = cmd.Process.Kill()
+		<-exited
+		return nil, errors.New("timeout attaching the bpftrace probes")
+	}
+}
+
+// readSNMP reads the TCP counters of the host network namespace.
+func readSNMP() (snmpCounters, error) {
+	content, err := ioutil.ReadFile(helpers.HostProc("1", "net", "snmp"))
+	if err != nil {
+		return snmpCounters{}, err
+	}
+	return parseSNMP(content)
+}
+
+// parseSNMP parses the TCP counters of a /proc/net/snmp file, where a line with the names of the
+// counters of each protocol precedes the line with their values, e.g.:
+//
+//	Tcp: RtoAlgorithm RtoMin RtoMax MaxConn ActiveOpens PassiveOpens AttemptFails EstabResets ...
+//	Tcp: 1 200 120000 -1 42861 1520 1038 412 ...
+func parseSNMP(content []byte) (snmpCounters, error) {
+	var names []string
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || fields[0] != "Tcp:" {
+			continue
+		}
+		if names == nil {
+			names = fields
+			continue
+		}
+		if len(fields) != len(names) {
+			return snmpCounters{}, errors.New("malformed TCP counters")
+		}
+		var counters snmpCounters
+		for i, name := range names {
+			var target *uint64
+			switch name {
+			case "ActiveOpens":
+				target = &counters.activeOpens
+			case "AttemptFails":
+				target = &counters.attemptFails
+			case "OutSegs":
+				target = &counters.outSegs
+			case "RetransSegs":
+				target = &counters.retransSegs
+			default:
+				continue
+			}
+			value, err := strconv.ParseUint(fields[i], 10, 64)
+			if err != nil {
+				return snmpCounters{}, fmt.Errorf("invalid %s counter: %v", name, err)
+			}
+			*target = value
+		}
+		return counters, nil
+	}
+	return snmpCounters{}, errors.New("TCP counters not found")
+}
@@ -0,0 +1,36 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package tcphealth
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSNMP(t *testing.T) {
+	content := []byte(`Ip: Forwarding DefaultTTL InReceives
+Ip: 1 64 1889372
+Tcp: RtoAlgorithm RtoMin RtoMax MaxConn ActiveOpens PassiveOpens AttemptFails EstabResets CurrEstab InSegs OutSegs RetransSegs InErrs OutRsts InCsumErrors
+Tcp: 1 200 120000 -1 42861 1520 1038 412 23 1763910 1849226 3172 0 9812 0
+Udp: InDatagrams NoPorts
+Udp: 113890 287
+`)
+
+	counters, err := parseSNMP(content)
+
+	require.NoError(t, err)
+	assert.Equal(t, snmpCounters{activeOpens: 42861, attemptFails: 1038, outSegs: 1849226, retransSegs: 3172}, counters)
+}
+
+func TestParseSNMP_Malformed(t *testing.T) {
+	_, err := parseSNMP([]byte("Ip: Forwarding\nIp: 1\n"))
+	assert.Error(t, err)
+
+	_, err = parseSNMP([]byte("Tcp: ActiveOpens RetransSegs\nTcp: 12\n"))
+	assert.Error(t, err)
+
+	_, err = parseSNMP([]byte("Tcp: ActiveOpens RetransSegs\nTcp: 12 x\n"))
+	assert.Error(t, err)
+}
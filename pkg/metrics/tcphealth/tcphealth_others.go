@@ -0,0 +1,19 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+// +build !linux
+
+package tcphealth
+
+import "errors"
+
+var errUnsupported = errors.New("TCP health is only sampled on Linux")
+
+// startBPFTrace is only supported on Linux.
+func startBPFTrace(int) (tracer, error) {
+	return nil, errUnsupported
+}
+
+// readSNMP is only supported on Linux.
+func readSNMP() (snmpCounters, error) {
+	return snmpCounters{}, errUnsupported
+}
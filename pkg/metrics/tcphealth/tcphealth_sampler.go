@@ -0,0 +1,305 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+// Package tcphealth samples the TCP retransmissions, round trip times and connect latencies of the host
+// per destination, traced with eBPF through bpftrace. Hosts where eBPF is unavailable report the host
+// level retransmissions and connection failures instead.
+package tcphealth
+
+import (
+	"fmt"
+	"net"
+	"runtime/debug"
+	"sort"
+	"time"
+
+	"github.com/newrelic/infrastructure-agent/internal/agent"
+	"github.com/newrelic/infrastructure-agent/pkg/config"
+	"github.com/newrelic/infrastructure-agent/pkg/log"
+	"github.com/newrelic/infrastructure-agent/pkg/sample"
+)
+
+// Sources of the samples.
+const (
+	sourceEBPF = "ebpf"
+	sourceSNMP = "snmp"
+)
+
+// maxDestinations bounds the destinations reported per sample, the ones with more retransmissions and
+// connections first.
+const maxDestinations = 100
+
+var slog = log.WithComponent("TCPHealthSampler")
+
+// Sample holds the TCP health of the connections to a destination, or of the whole host when eBPF is
+// unavailable.
+type Sample struct {
+	sample.BaseEvent
+
+	Source string `json:"source"`
+	// Remote address of the connections, only reported by the eBPF source
+	DestinationAddress string `json:"destinationAddress,omitempty"`
+
+	RetransmitsPerSecond *float64 `json:"retransmitsPerSecond,omitempty"`
+	// Share of the sent segments that were retransmitted, only reported by the SNMP source
+	RetransmitPercent        *float64 `json:"retransmitPercent,omitempty"`
+	ConnectsPerSecond        *float64 `json:"connectsPerSecond,omitempty"`
+	ConnectFailuresPerSecond *float64 `json:"connectFailuresPerSecond,omitempty"`
+	// Time from the SYN to the establishment of the outgoing connections
+	ConnectLatencyAvgMs *float64 `json:"connectLatencyAvgMs,omitempty"`
+	// Smoothed round trip time of the received segments. The percentiles are the upper bound of the
+	// power of two bucket they fall in.
+	RTTAvgMs *float64 `json:"rttAvgMs,omitempty"`
+	RTTP50Ms *float64 `json:"rttP50Ms,omitempty"`
+	RTTP90Ms *float64 `json:"rttP90Ms,omitempty"`
+	RTTP99Ms *float64 `json:"rttP99Ms,omitempty"`
+}
+
+func newSample(source string) *Sample {
+	s := &Sample{Source: source}
+	s.Type("TCPHealthSample")
+	return s
+}
+
+// tracer traces the TCP events of the host, aggregated in windows of the sample interval.
+type tracer interface {
+	// take returns the last complete window, nil if none completed since the previous call, and false
+	// once the tracer stopped.
+	take() (*window, bool)
+}
+
+// snmpCounters are the TCP counters of the host since boot, from /proc/net/snmp.
+type snmpCounters struct {
+	activeOpens  uint64
+	attemptFails uint64
+	outSegs      uint64
+	retransSegs  uint64
+}
+
+// Sampler reports a TCPHealthSample per destination traced with eBPF in the sample interval, or a single
+// host level sample if bpftrace can't trace the host.
+type Sampler struct {
+	context    agent.AgentContext
+	sampleRate time.Duration
+
+	startTracer func(intervalSec int) (tracer, error)
+	started     bool
+	tracer      tracer
+	snmp        func() (snmpCounters, error)
+	lastSNMP    *snmpCounters
+	lastTime    time.Time
+}
+
+func NewSampler(context agent.AgentContext) *Sampler {
+	sampleRateSec := config.FREQ_DISABLE_SAMPLING
+	if context != nil {
+		sampleRateSec = context.Config().MetricsTCPHealthSampleRate
+	}
+
+	return &Sampler{
+		context:     context,
+		sampleRate:  time.Second * time.Duration(sampleRateSec),
+		startTracer: startBPFTrace,
+		snmp:        readSNMP,
+	}
+}
+
+func (s *Sampler) OnStartup() {}
+
+// startTracing starts tracing, falling back to the SNMP counters if it isn't possible. It is deferred to
+// the first sample, as bpftrace may take a while to compile the scripts and attach them.
+func (s *Sampler) startTracing() {
+	s.started = true
+	t, err := s.startTracer(int(s.sampleRate / time.Second))
+	if err != nil {
+		slog.WithError(err).Info("Unable to trace TCP with eBPF, reporting the host TCP counters.")
+		return
+	}
+	s.tracer = t
+}
+
+func (s *Sampler) Name() string {
+	return "TCPHealthSampler"
+}
+
+func (s *Sampler) Interval() time.Duration {
+	return s.sampleRate
+}
+
+func (s *Sampler) Disabled() bool {
+	return s.Interval() <= config.FREQ_DISABLE_SAMPLING
+}
+
+func (s *Sampler) Sample() (eventBatch sample.EventBatch, err error) {
+	defer func() {
+		if panicErr := recover(); panicErr != nil {
+			err = fmt.Errorf("Panic in tcphealth.Sampler: %v\nStack: %s", panicErr, debug.Stack())
+		}
+	}()
+
+	if !s.started {
+		s.startTracing()
+	}
+	if s.tracer != nil {
+		w, running := s.tracer.take()
+		if running {
+			for _, ds := range destinationSamples(w, s.sampleRate.Seconds()) {
+				eventBatch = append(eventBatch, ds)
+			}
+			return eventBatch, nil
+		}
+		slog.Warn("eBPF tracing of TCP stopped, reporting the host TCP counters.")
+		s.tracer = nil
+	}
+
+	if hs := s.hostSample(); hs != nil {
+		eventBatch = append(eventBatch, hs)
+	}
+	return eventBatch, nil
+}
+
+// hostSample returns the sample of the host TCP counters since the previous invocation, nil for the
+// first one.
+func (s *Sampler) hostSample() *Sample {
+	counters, err := s.snmp()
+	if err != nil {
+		slog.WithError(err).Debug("Unable to read the TCP counters.")
+		return nil
+	}
+	now := time.Now()
+	last, lastTime := s.lastSNMP, s.lastTime
+	s.lastSNMP, s.lastTime = &counters, now
+	elapsed := now.Sub(lastTime).Seconds()
+	// counters go back on overflow
+	if last == nil || elapsed <= 0 || counters.retransSegs < last.retransSegs || counters.outSegs < last.outSegs ||
+		counters.activeOpens < last.activeOpens || counters.attemptFails < last.attemptFails {
+		return nil
+	}
+
+	hs := newSample(sourceSNMP)
+	retransmits := float64(counters.retransSegs-last.retransSegs) / elapsed
+	connects := float64(counters.activeOpens-last.activeOpens) / elapsed
+	failures := float64(counters.attemptFails-last.attemptFails) / elapsed
+	hs.RetransmitsPerSecond = &retransmits
+	hs.ConnectsPerSecond = &connects
+	hs.ConnectFailuresPerSecond = &failures
+	if sent := counters.outSegs - last.outSegs; sent > 0 {
+		percent := float64(counters.retransSegs-last.retransSegs) / float64(sent) * 100
+		hs.RetransmitPercent = &percent
+	}
+	return hs
+}
+
+// destinationSamples returns the samples of the destinations traced in a window of intervalSec seconds,
+// sorted by retransmissions and connections.
+func destinationSamples(w *window, intervalSec float64) []*Sample {
+	if w == nil || intervalSec <= 0 {
+		return nil
+	}
+	samples := map[string]*Sample{}
+	destination := func(address string) *Sample {
+		address = normalizeAddress(address)
+		ds, ok := samples[address]
+		if !ok {
+			ds = newSample(sourceEBPF)
+			ds.DestinationAddress = address
+			samples[address] = ds
+		}
+		return ds
+	}
+	rate := func(current *float64, count uint64) *float64 {
+		value := float64(count) / intervalSec
+		if current != nil {
+			value += *current
+		}
+		return &value
+	}
+
+	for address, count := range w.retransmits {
+		ds := destination(address)
+		ds.RetransmitsPerSecond = rate(ds.RetransmitsPerSecond, count)
+	}
+	for address, stats := range w.connects {
+		ds := destination(address)
+		ds.ConnectsPerSecond = rate(ds.ConnectsPerSecond, stats.Count)
+		if stats.Count > 0 {
+			avg := stats.Average / 1000
+			ds.ConnectLatencyAvgMs = &avg
+		}
+	}
+	for address, count := range w.connectFailures {
+		ds := destination(address)
+		ds.ConnectFailuresPerSecond = rate(ds.ConnectFailuresPerSecond, count)
+	}
+	for address, stats := range w.rttStats {
+		if stats.Count > 0 {
+			avg := stats.Average / 1000
+			destination(address).RTTAvgMs = &avg
+		}
+	}
+	for address, buckets := range w.rtt {
+		ds := destination(address)
+		ds.RTTP50Ms = percentileMs(buckets, 0.5)
+		ds.RTTP90Ms = percentileMs(buckets, 0.9)
+		ds.RTTP99Ms = percentileMs(buckets, 0.99)
+	}
+
+	result := make([]*Sample, 0, len(samples))
+	for _, ds := range samples {
+		result = append(result, ds)
+	}
+	activity := func(ds *Sample) (value float64) {
+		for _, rate := range []*float64{ds.RetransmitsPerSecond, ds.ConnectsPerSecond, ds.ConnectFailuresPerSecond} {
+			if rate != nil {
+				value += *rate
+			}
+		}
+		return value
+	}
+	sort.Slice(result, func(i, j int) bool {
+		ai, aj := activity(result[i]), activity(result[j])
+		if ai != aj {
+			return ai > aj
+		}
+		return result[i].DestinationAddress < result[j].DestinationAddress
+	})
+	if len(result) > maxDestinations {
+		result = result[:maxDestinations]
+	}
+	return result
+}
+
+// normalizeAddress reports the IPv4 addresses mapped to IPv6 as IPv4 ones.
+func normalizeAddress(address string) string {
+	if ip := net.ParseIP(address); ip != nil {
+		return ip.String()
+	}
+	return address
+}
+
+// percentileMs returns the upper bound, in milliseconds, of the histogram bucket of microseconds in
+// which the passed percentile falls, nil for empty histograms.
+func percentileMs(buckets []histBucket, percentile float64) *float64 {
+	var total uint64
+	for _, b := range buckets {
+		total += b.Count
+	}
+	if total == 0 {
+		return nil
+	}
+	threshold := percentile * float64(total)
+	var cumulative uint64
+	for _, b := range buckets {
+		cumulative += b.Count
+		if float64(cumulative) >= threshold && b.Count > 0 {
+			var upper float64
+			if b.Max != nil {
+				upper = float64(*b.Max)
+			} else if b.Min != nil {
+				upper = float64(*b.Min)
+			}
+			ms := upper / 1000
+			return &ms
+		}
+	}
+	return nil
+}
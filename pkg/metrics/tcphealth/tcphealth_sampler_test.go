@@ -0,0 +1,161 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package tcphealth
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeTracer struct {
+	windows []*window
+	running bool
+}
+
+func (f *fakeTracer) take() (*window, bool) {
+	if len(f.windows) == 0 {
+		return nil, f.running
+	}
+	w := f.windows[0]
+	f.windows = f.windows[1:]
+	return w, f.running
+}
+
+func TestDestinationSamples(t *testing.T) {
+	// GIVEN a window with retransmissions, connections and round trip times
+	w := newWindow()
+	w.retransmits["::ffff:10.0.0.7"] = 30
+	w.retransmits["2001:db8::1"] = 3
+	w.connects["::ffff:10.0.0.7"] = stats{Count: 6, Average: 1500}
+	w.connectFailures["::ffff:10.0.0.9"] = 60
+	w.rttStats["10.0.0.7"] = stats{Count: 10, Average: 400}
+	w.rtt["10.0.0.7"] = []histBucket{bucket(256, 511, 9), bucket(512, 1023, 1)}
+
+	// WHEN the samples of a 30 seconds window are built
+	samples := destinationSamples(w, 30)
+
+	// THEN a sample is reported per destination, the most active first
+	require.Len(t, samples, 3)
+	assert.Equal(t, "TCPHealthSample", samples[0].EventType)
+	assert.Equal(t, sourceEBPF, samples[0].Source)
+	assert.Equal(t, "10.0.0.9", samples[0].DestinationAddress)
+	assert.Equal(t, 2.0, *samples[0].ConnectFailuresPerSecond)
+
+	ds := samples[1]
+	assert.Equal(t, "10.0.0.7", ds.DestinationAddress, "the IPv4 addresses mapped to IPv6 must be merged")
+	assert.Equal(t, 1.0, *ds.RetransmitsPerSecond)
+	assert.Equal(t, 0.2, *ds.ConnectsPerSecond)
+	assert.Equal(t, 1.5, *ds.ConnectLatencyAvgMs)
+	assert.Equal(t, 0.4, *ds.RTTAvgMs)
+	assert.Equal(t, 0.511, *ds.RTTP50Ms)
+	assert.Equal(t, 0.511, *ds.RTTP90Ms)
+	assert.Equal(t, 1.023, *ds.RTTP99Ms)
+	assert.Nil(t, ds.RetransmitPercent)
+
+	assert.Equal(t, "2001:db8::1", samples[2].DestinationAddress)
+	assert.Nil(t, samples[2].RTTP50Ms)
+}
+
+func TestDestinationSamples_Bounded(t *testing.T) {
+	w := newWindow()
+	for i := 0; i < maxDestinations+10; i++ {
+		w.retransmits[string(rune('a'+i%26))+string(rune('a'+i/26))] = uint64(i + 1)
+	}
+
+	samples := destinationSamples(w, 10)
+
+	assert.Len(t, samples, maxDestinations)
+	assert.Equal(t, float64(maxDestinations+10)/10, *samples[0].RetransmitsPerSecond)
+	assert.Empty(t, destinationSamples(nil, 10))
+}
+
+func TestPercentileMs(t *testing.T) {
+	assert.Nil(t, percentileMs(nil, 0.5))
+	assert.Nil(t, percentileMs([]histBucket{bucket(0, 1, 0)}, 0.5))
+
+	buckets := []histBucket{bucket(0, 1, 0), bucket(2, 3, 50), bucket(1024, 2047, 50)}
+	assert.Equal(t, 0.003, *percentileMs(buckets, 0.5))
+	assert.Equal(t, 2.047, *percentileMs(buckets, 0.51))
+}
+
+func TestSampler_Tracing(t *testing.T) {
+	s := NewSampler(nil)
+	assert.True(t, s.Disabled())
+	s.sampleRate = 10 * time.Second
+
+	// GIVEN a tracer that stops after a window
+	w := newWindow()
+	w.retransmits["10.0.0.1"] = 5
+	fake := &fakeTracer{windows: []*window{w}, running: true}
+	var intervalSec int
+	s.startTracer = func(interval int) (tracer, error) {
+		intervalSec = interval
+		return fake, nil
+	}
+	s.snmp = func() (snmpCounters, error) { return snmpCounters{retransSegs: 10, outSegs: 1000}, nil }
+
+	// WHEN it is sampled
+	batch, err := s.Sample()
+
+	// THEN the traced destinations are reported
+	require.NoError(t, err)
+	assert.Equal(t, 10, intervalSec)
+	require.Len(t, batch, 1)
+	assert.Equal(t, "10.0.0.1", batch[0].(*Sample).DestinationAddress)
+	assert.Equal(t, 0.5, *batch[0].(*Sample).RetransmitsPerSecond)
+
+	// AND nothing is reported until the next window completes
+	batch, err = s.Sample()
+	require.NoError(t, err)
+	assert.Empty(t, batch)
+
+	// AND the SNMP counters are read once the tracer stops
+	fake.running = false
+	batch, err = s.Sample()
+	require.NoError(t, err)
+	assert.Empty(t, batch, "the first SNMP read only sets the baseline")
+	assert.NotNil(t, s.lastSNMP)
+}
+
+func TestSampler_Fallback(t *testing.T) {
+	s := NewSampler(nil)
+	s.sampleRate = 10 * time.Second
+	s.startTracer = func(int) (tracer, error) { return nil, errors.New("no BTF") }
+	counters := snmpCounters{activeOpens: 100, attemptFails: 10, outSegs: 10000, retransSegs: 50}
+	s.snmp = func() (snmpCounters, error) { return counters, nil }
+
+	// GIVEN a first sample setting the baseline of the counters
+	batch, err := s.Sample()
+	require.NoError(t, err)
+	assert.Empty(t, batch)
+
+	// WHEN the counters increase
+	counters = snmpCounters{activeOpens: 120, attemptFails: 15, outSegs: 11000, retransSegs: 60}
+	s.lastTime = time.Now().Add(-10 * time.Second)
+	batch, err = s.Sample()
+
+	// THEN the host level sample is reported
+	require.NoError(t, err)
+	require.Len(t, batch, 1)
+	hs := batch[0].(*Sample)
+	assert.Equal(t, sourceSNMP, hs.Source)
+	assert.Empty(t, hs.DestinationAddress)
+	assert.InDelta(t, 1.0, *hs.RetransmitsPerSecond, 0.01)
+	assert.InDelta(t, 2.0, *hs.ConnectsPerSecond, 0.01)
+	assert.InDelta(t, 0.5, *hs.ConnectFailuresPerSecond, 0.01)
+	assert.Equal(t, 1.0, *hs.RetransmitPercent)
+
+	// AND counters going back are not reported
+	counters = snmpCounters{}
+	batch, err = s.Sample()
+	require.NoError(t, err)
+	assert.Empty(t, batch)
+}
+
+func bucket(min, max int64, count uint64) histBucket {
+	return histBucket{Min: &min, Max: &max, Count: count}
+}
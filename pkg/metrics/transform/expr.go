@@ -0,0 +1,220 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package transform
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/checker/decls"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+	"github.com/google/cel-go/common/types/traits"
+	"github.com/google/cel-go/interpreter/functions"
+	exprpb "google.golang.org/genproto/googleapis/api/expr/v1alpha1"
+)
+
+// The expressions are evaluated by the Common Expression Language (CEL) implementation of cel-go, with
+// the drop(), keep(), set(field, value) and remove(field) functions returning the actions. The numbers
+// of the events and metrics are doubles, as in the CEL conversion of JSON, so they must be compared
+// with double literals, ie: event.cpuPercent > 10.0
+
+// actionType is the CEL type of the values returned by the action functions.
+var actionType = types.NewTypeValue("transform.Action")
+
+// actionVal is the CEL value of an action.
+type actionVal struct {
+	action
+}
+
+func (a *actionVal) ConvertToNative(typeDesc reflect.Type) (interface{}, error) {
+	return nil, fmt.Errorf("type conversion error from action to '%v'", typeDesc)
+}
+
+func (a *actionVal) ConvertToType(typeValue ref.Type) ref.Val {
+	if typeValue == types.TypeType {
+		return actionType
+	}
+	return types.NewErr("type conversion error from action to '%s'", typeValue)
+}
+
+func (a *actionVal) Equal(other ref.Val) ref.Val {
+	o, ok := other.(*actionVal)
+	return types.Bool(ok && reflect.DeepEqual(a.action, o.action))
+}
+
+func (a *actionVal) Type() ref.Type {
+	return actionType
+}
+
+func (a *actionVal) Value() interface{} {
+	return a.action
+}
+
+var declarations = cel.Declarations(
+	decls.NewVar("event", decls.NewMapType(decls.String, decls.Dyn)),
+	decls.NewVar("metric", decls.NewMapType(decls.String, decls.Dyn)),
+	decls.NewFunction("drop", decls.NewOverload("drop", []*exprpb.Type{}, decls.Dyn)),
+	decls.NewFunction("keep", decls.NewOverload("keep", []*exprpb.Type{}, decls.Dyn)),
+	decls.NewFunction("set", decls.NewOverload("set", []*exprpb.Type{decls.String, decls.Dyn}, decls.Dyn)),
+	decls.NewFunction("remove", decls.NewOverload("remove", []*exprpb.Type{decls.String}, decls.Dyn)),
+)
+
+var actionFunctions = cel.Functions(
+	&functions.Overload{
+		Operator: "drop",
+		Function: func(...ref.Val) ref.Val { return &actionVal{action{drop: true}} },
+	},
+	&functions.Overload{
+		Operator: "keep",
+		Function: func(...ref.Val) ref.Val { return &actionVal{} },
+	},
+	&functions.Overload{
+		Operator: "set",
+		Binary: func(field, value ref.Val) ref.Val {
+			name, ok := field.(types.String)
+			if !ok {
+				return types.MaybeNoSuchOverloadErr(field)
+			}
+			native, err := nativeValue(value)
+			if err != nil {
+				return types.NewErr("%s", err)
+			}
+			return &actionVal{action{set: map[string]interface{}{string(name): native}}}
+		},
+	},
+	&functions.Overload{
+		Operator: "remove",
+		Unary: func(field ref.Val) ref.Val {
+			name, ok := field.(types.String)
+			if !ok {
+				return types.MaybeNoSuchOverloadErr(field)
+			}
+			return &actionVal{action{remove: []string{string(name)}}}
+		},
+	},
+)
+
+// compile parses and checks an expression.
+func compile(env *cel.Env, expression string) (*cel.Ast, cel.Program, error) {
+	ast, issues := env.Compile(expression)
+	if issues != nil && issues.Err() != nil {
+		return nil, nil, issues.Err()
+	}
+	prg, err := env.Program(ast, actionFunctions)
+	if err != nil {
+		return nil, nil, err
+	}
+	return ast, prg, nil
+}
+
+// returnsActions returns whether an expression of the passed type may result in actions.
+func returnsActions(t *exprpb.Type) bool {
+	if list := t.GetListType(); list != nil {
+		t = list.GetElemType()
+	}
+	_, dyn := t.GetTypeKind().(*exprpb.Type_Dyn)
+	return dyn
+}
+
+// toAction converts the result of an expression to the action to apply.
+func toAction(result ref.Val) (action, error) {
+	switch r := result.(type) {
+	case *actionVal:
+		return r.action, nil
+	case traits.Lister:
+		combined := action{}
+		for it := r.Iterator(); it.HasNext() == types.True; {
+			item := it.Next()
+			a, ok := item.(*actionVal)
+			if !ok {
+				return action{}, fmt.Errorf("expected a list of actions, found a %s item", item.Type().TypeName())
+			}
+			combined.merge(a.action)
+		}
+		return combined, nil
+	}
+	return action{}, fmt.Errorf("expected drop(), keep(), set() or remove() actions, found %s", result.Type().TypeName())
+}
+
+var errUnsupportedValue = errors.New("unsupported value")
+
+// nativeValue converts a CEL value to be set as an attribute.
+func nativeValue(value ref.Val) (interface{}, error) {
+	switch v := value.(type) {
+	case types.Null:
+		return nil, nil
+	case types.Bool, types.Double, types.Int, types.Uint, types.String:
+		return v.Value(), nil
+	case traits.Lister:
+		var list []interface{}
+		for it := v.Iterator(); it.HasNext() == types.True; {
+			item, err := nativeValue(it.Next())
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, item)
+		}
+		return list, nil
+	case traits.Mapper:
+		m := map[string]interface{}{}
+		for it := v.Iterator(); it.HasNext() == types.True; {
+			key := it.Next()
+			name, ok := key.(types.String)
+			if !ok {
+				return nil, errUnsupportedValue
+			}
+			item, err := nativeValue(v.Get(key))
+			if err != nil {
+				return nil, err
+			}
+			m[string(name)] = item
+		}
+		return m, nil
+	}
+	return nil, errUnsupportedValue
+}
+
+// normalize converts the values to the types handled by the expressions.
+func normalize(value interface{}) interface{} {
+	switch v := value.(type) {
+	case json.Number:
+		f, err := v.Float64()
+		if err != nil {
+			return v.String()
+		}
+		return f
+	case int:
+		return float64(v)
+	case int32:
+		return float64(v)
+	case int64:
+		return float64(v)
+	case uint64:
+		return float64(v)
+	case float32:
+		return float64(v)
+	case map[string]interface{}:
+		normalized := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			normalized[k] = normalize(item)
+		}
+		return normalized
+	case map[string]string:
+		normalized := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			normalized[k] = item
+		}
+		return normalized
+	case []interface{}:
+		normalized := make([]interface{}, len(v))
+		for i, item := range v {
+			normalized[i] = normalize(item)
+		}
+		return normalized
+	}
+	return value
+}
@@ -0,0 +1,137 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package transform
+
+import (
+	"testing"
+
+	"github.com/google/cel-go/cel"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestEnv(t *testing.T) *cel.Env {
+	env, err := cel.NewEnv(declarations)
+	require.NoError(t, err)
+	return env
+}
+
+func TestCompile_Eval(t *testing.T) {
+	vars := map[string]interface{}{"event": map[string]interface{}{
+		"eventType":      "ProcessSample",
+		"commandName":    "sshd",
+		"cpuPercent":     12.5,
+		"net.bytesSent":  2048.0,
+		"tags":           []interface{}{"a", "b"},
+		"memoryRssBytes": nil,
+	}}
+	cases := []struct {
+		expression string
+		expected   interface{}
+	}{
+		{`event.eventType == "ProcessSample"`, true},
+		{`event.commandName.startsWith('ss') && event.commandName.endsWith("d")`, true},
+		{`event.commandName.contains("x") || event.cpuPercent > 10.0`, true},
+		{`event.commandName.matches("^s+h")`, true},
+		{`event["net.bytesSent"] / 1024.0`, 2.0},
+		{`"b" in event.tags && size(event.tags) == 2 && "cpuPercent" in event`, true},
+		{`has(event.cpuPercent) && !has(event.missing)`, true},
+		{`event.memoryRssBytes == null`, true},
+		{`event.cpuPercent > 50.0 ? "high" : event.cpuPercent > 10.0 ? "medium" : "low"`, "medium"},
+		// the error of a missing field is absorbed when the other operand decides the result
+		{`event.missing == "x" || true`, true},
+	}
+	env := newTestEnv(t)
+	for _, c := range cases {
+		t.Run(c.expression, func(t *testing.T) {
+			_, prg, err := compile(env, c.expression)
+			require.NoError(t, err)
+			actual, _, err := prg.Eval(vars)
+			require.NoError(t, err)
+			assert.Equal(t, c.expected, actual.Value())
+		})
+	}
+}
+
+func TestCompile_Actions(t *testing.T) {
+	vars := map[string]interface{}{"metric": map[string]interface{}{"name": "debug_requests", "value": 5.0}}
+	cases := []struct {
+		expression string
+		expected   action
+	}{
+		{`metric.name.startsWith("debug_") ? drop() : keep()`, action{drop: true}},
+		{`metric.value > 10.0 ? drop() : keep()`, action{}},
+		{`[set("team", "core"), set("value", metric.value * 2.0), remove("debug")]`,
+			action{set: map[string]interface{}{"team": "core", "value": 10.0}, remove: []string{"debug"}}},
+		{`set("tags", ["a", 1])`, action{set: map[string]interface{}{"tags": []interface{}{"a", int64(1)}}}},
+		{`set("labels", {"env": "prod"})`, action{set: map[string]interface{}{"labels": map[string]interface{}{"env": "prod"}}}},
+	}
+	env := newTestEnv(t)
+	for _, c := range cases {
+		t.Run(c.expression, func(t *testing.T) {
+			ast, prg, err := compile(env, c.expression)
+			require.NoError(t, err)
+			assert.True(t, returnsActions(ast.ResultType()))
+			result, _, err := prg.Eval(vars)
+			require.NoError(t, err)
+			actual, err := toAction(result)
+			require.NoError(t, err)
+			assert.Equal(t, c.expected, actual)
+		})
+	}
+}
+
+func TestEval_Errors(t *testing.T) {
+	vars := map[string]interface{}{"event": map[string]interface{}{"name": "a", "value": 1.0}}
+	env := newTestEnv(t)
+	for _, expression := range []string{
+		`event.missing`,
+		`event.missing == "x" || false`,
+		`metric.name`,
+		`event.name + 1`,
+		`event.value > 0`,
+		`event.name ? 1 : 2`,
+		`event.value.startsWith("a")`,
+	} {
+		t.Run(expression, func(t *testing.T) {
+			_, prg, err := compile(env, expression)
+			require.NoError(t, err)
+			_, _, err = prg.Eval(vars)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestCompile_Errors(t *testing.T) {
+	env := newTestEnv(t)
+	for _, expression := range []string{
+		``,
+		`event.name ==`,
+		`"unterminated`,
+		`event.name == "a" ? drop()`,
+		`unknown(1)`,
+		`drop(1)`,
+		`remove(1)`,
+		`has(event)`,
+		`event.name #`,
+		`(1 + 2`,
+	} {
+		t.Run(expression, func(t *testing.T) {
+			_, _, err := compile(env, expression)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestNew_NoActions(t *testing.T) {
+	for _, expression := range []string{
+		`event.name == "a"`,
+		`[1, 2]`,
+		`"drop"`,
+	} {
+		t.Run(expression, func(t *testing.T) {
+			_, err := New([]string{expression})
+			assert.Error(t, err)
+		})
+	}
+}
@@ -0,0 +1,202 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+// Package transform applies user-defined Common Expression Language (CEL) expressions that drop or
+// modify the events and the dimensional metrics just before they are batched for submission. E.g.:
+//
+//	metric.name.startsWith("debug_") ? drop() : keep()
+//	event.eventType == "ProcessSample" && event.commandName == "sshd" ? set("team", "security") : keep()
+//
+// Each expression must return an action, or a list of actions: drop(), keep(), set(field, value) or
+// remove(field). Events are exposed as the "event" variable, with their attributes as fields. Metrics
+// are exposed as the "metric" variable, with the name, type, value and attributes fields.
+package transform
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/google/cel-go/cel"
+	"github.com/newrelic/infrastructure-agent/pkg/integrations/v4/protocol"
+	"github.com/newrelic/infrastructure-agent/pkg/log"
+	"github.com/newrelic/infrastructure-agent/pkg/metrics/enrich"
+	"github.com/newrelic/infrastructure-agent/pkg/sample"
+)
+
+var tlog = log.WithComponent("PayloadTransformer")
+
+// action resulting from an expression.
+type action struct {
+	drop   bool
+	set    map[string]interface{}
+	remove []string
+}
+
+func (a *action) merge(other action) {
+	a.drop = a.drop || other.drop
+	for k, v := range other.set {
+		if a.set == nil {
+			a.set = map[string]interface{}{}
+		}
+		a.set[k] = v
+	}
+	a.remove = append(a.remove, other.remove...)
+}
+
+// apply modifies the passed attributes, returning whether any of them has been changed.
+func (a *action) apply(attributes ...map[string]interface{}) bool {
+	for _, attrs := range attributes {
+		for _, field := range a.remove {
+			delete(attrs, field)
+		}
+		for field, value := range a.set {
+			attrs[field] = value
+		}
+	}
+	return len(a.set) > 0 || len(a.remove) > 0
+}
+
+type program struct {
+	expression string
+	prg        cel.Program
+}
+
+func (p *program) run(vars map[string]interface{}) (action, error) {
+	result, _, err := p.prg.Eval(vars)
+	if err != nil {
+		return action{}, err
+	}
+	return toAction(result)
+}
+
+// Transformer applies the configured expressions, in order.
+type Transformer struct {
+	programs []program
+}
+
+// New compiles the passed expressions. It returns nil if there are no expressions.
+func New(expressions []string) (*Transformer, error) {
+	if len(expressions) == 0 {
+		return nil, nil
+	}
+	env, err := cel.NewEnv(declarations)
+	if err != nil {
+		return nil, err
+	}
+	t := &Transformer{}
+	for _, expression := range expressions {
+		ast, prg, err := compile(env, expression)
+		if err != nil {
+			return nil, fmt.Errorf("invalid payload transform %q: %s", expression, err)
+		}
+		if !returnsActions(ast.ResultType()) {
+			return nil, fmt.Errorf("invalid payload transform %q: expected drop(), keep(), set() or remove() actions", expression)
+		}
+		t.programs = append(t.programs, program{expression: expression, prg: prg})
+	}
+	return t, nil
+}
+
+// Event applies the expressions to an event. It returns false if the event has to be dropped.
+// The event is returned untouched if no expression modifies it.
+func (t *Transformer) Event(event sample.Event) (sample.Event, bool, error) {
+	if t == nil {
+		return event, true, nil
+	}
+	attributes, err := enrich.Flatten(event)
+	if err != nil {
+		return event, true, err
+	}
+	// the expressions see the changes of the previous ones
+	fields := normalize(attributes).(map[string]interface{})
+	vars := map[string]interface{}{"event": fields}
+
+	changed := false
+	for i := range t.programs {
+		a, err := t.programs[i].run(vars)
+		if err != nil {
+			tlog.WithError(err).WithField("expression", t.programs[i].expression).Debug("Can't evaluate payload transform.")
+			continue
+		}
+		if a.drop {
+			return nil, false, nil
+		}
+		if a.apply(attributes, fields) {
+			changed = true
+		}
+	}
+	if !changed {
+		return event, true, nil
+	}
+	transformed := enrich.Event(attributes)
+	return &transformed, true, nil
+}
+
+// Metrics applies the expressions to dimensional metrics, returning the ones that aren't dropped.
+func (t *Transformer) Metrics(metrics []protocol.Metric) []protocol.Metric {
+	if t == nil {
+		return metrics
+	}
+	kept := metrics[:0]
+	for _, m := range metrics {
+		if t.metric(&m) {
+			kept = append(kept, m)
+		}
+	}
+	return kept
+}
+
+// metric applies the expressions to a metric, returning false if the metric has to be dropped.
+func (t *Transformer) metric(m *protocol.Metric) bool {
+	value, err := decodeValue(m.Value)
+	if err != nil {
+		tlog.WithError(err).WithField("metric", m.Name).Debug("Can't decode metric value for payload transforms.")
+	}
+	attributes := normalize(m.Attributes)
+	if attributes == nil {
+		attributes = map[string]interface{}{}
+	}
+	fields := map[string]interface{}{
+		"name":       m.Name,
+		"type":       string(m.Type),
+		"value":      value,
+		"attributes": attributes,
+	}
+	vars := map[string]interface{}{"metric": fields}
+
+	copied := false
+	for i := range t.programs {
+		a, err := t.programs[i].run(vars)
+		if err != nil {
+			tlog.WithError(err).WithField("expression", t.programs[i].expression).Debug("Can't evaluate payload transform.")
+			continue
+		}
+		if a.drop {
+			return false
+		}
+		if len(a.set) == 0 && len(a.remove) == 0 {
+			continue
+		}
+		// the attributes map may be shared with other metrics of the same payload
+		if !copied {
+			m.Attributes = m.CopyAttrs()
+			copied = true
+		}
+		a.apply(m.Attributes, attributes.(map[string]interface{}))
+	}
+	return true
+}
+
+func decodeValue(raw json.RawMessage) (interface{}, error) {
+	if len(raw) == 0 {
+		return nil, errors.New("empty value")
+	}
+	var value interface{}
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	return normalize(value), nil
+}
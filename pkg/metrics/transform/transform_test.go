@@ -0,0 +1,94 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package transform
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/newrelic/infrastructure-agent/pkg/integrations/v4/protocol"
+	"github.com/newrelic/infrastructure-agent/pkg/metrics/enrich"
+	"github.com/newrelic/infrastructure-agent/pkg/metrics/types"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNew(t *testing.T) {
+	tr, err := New(nil)
+	require.NoError(t, err)
+	assert.Nil(t, tr)
+
+	_, err = New([]string{`keep()`, `event.name ==`})
+	assert.Error(t, err)
+}
+
+func TestTransformer_Event(t *testing.T) {
+	// GIVEN some transforms that drop and modify events
+	tr, err := New([]string{
+		`event.commandName == "debug" ? drop() : keep()`,
+		`event.commandName == "sshd" ? [set("team", "security"), remove("commandLine")] : keep()`,
+		`has(event.team) ? set("owner", event.team + "@example.com") : keep()`,
+	})
+	require.NoError(t, err)
+
+	// WHEN an event that doesn't match any transform is processed
+	untouched := &types.ProcessSample{ProcessID: 1, CommandName: "bash"}
+	transformed, keep, err := tr.Event(untouched)
+
+	// THEN it is kept as it is
+	require.NoError(t, err)
+	assert.True(t, keep)
+	assert.Same(t, untouched, transformed)
+
+	// WHEN an event to be dropped is processed
+	_, keep, err = tr.Event(&types.ProcessSample{ProcessID: 2, CommandName: "debug"})
+
+	// THEN it isn't kept
+	require.NoError(t, err)
+	assert.False(t, keep)
+
+	// WHEN an event to be modified is processed
+	transformed, keep, err = tr.Event(&types.ProcessSample{ProcessID: 3, CommandName: "sshd", CmdLine: "/usr/sbin/sshd -D"})
+
+	// THEN the modifications are applied, and seen by the following transforms
+	require.NoError(t, err)
+	assert.True(t, keep)
+	attributes, ok := transformed.(*enrich.Event)
+	require.True(t, ok)
+	assert.Equal(t, "security", (*attributes)["team"])
+	assert.Equal(t, "security@example.com", (*attributes)["owner"])
+	assert.NotContains(t, *attributes, "commandLine")
+	assert.Equal(t, json.Number("3"), (*attributes)["processId"])
+}
+
+func TestTransformer_Metrics(t *testing.T) {
+	// GIVEN some transforms for dimensional metrics
+	tr, err := New([]string{
+		`metric.name.startsWith("debug_") ? drop() : keep()`,
+		`metric.type == "summary" && metric.value.count == 0.0 ? drop() : keep()`,
+		`metric.attributes.env == "prod" ? set("critical", true) : keep()`,
+	})
+	require.NoError(t, err)
+	shared := map[string]interface{}{"env": "prod"}
+	metrics := []protocol.Metric{
+		{Name: "debug_requests", Type: protocol.MetricTypeCount, Attributes: shared, Value: json.RawMessage("1")},
+		{Name: "latency", Type: protocol.MetricTypeSummary, Attributes: shared,
+			Value: json.RawMessage(`{"count": 0, "sum": 0, "min": 0, "max": 0}`)},
+		{Name: "requests", Type: protocol.MetricTypeCount, Attributes: shared, Value: json.RawMessage("5")},
+		{Name: "errors", Type: protocol.MetricTypeCount, Value: json.RawMessage("0")},
+	}
+
+	// WHEN the metrics are transformed
+	metrics = tr.Metrics(metrics)
+
+	// THEN the dropped metrics are removed and the rest are modified
+	require.Len(t, metrics, 2)
+	assert.Equal(t, "requests", metrics[0].Name)
+	assert.Equal(t, true, metrics[0].Attributes["critical"])
+	assert.Equal(t, "errors", metrics[1].Name)
+	assert.NotContains(t, metrics[1].Attributes, "critical")
+
+	// AND the attributes shared with other metrics aren't modified
+	assert.NotContains(t, shared, "critical")
+}
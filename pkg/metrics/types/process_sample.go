@@ -20,6 +20,7 @@ type ProcessSample struct {
 	User                  string   `json:"userName,omitempty"`
 	MemoryRSSBytes        int64    `json:"memoryResidentSizeBytes"`
 	MemoryVMSBytes        int64    `json:"memoryVirtualSizeBytes"`
+	MemorySwapBytes       *int64   `json:"memorySwapSizeBytes,omitempty"`
 	CPUPercent            float64  `json:"cpuPercent"`
 	CPUUserPercent        float64  `json:"cpuUserPercent"`
 	CPUSystemPercent      float64  `json:"cpuSystemPercent"`
@@ -27,12 +28,16 @@ type ProcessSample struct {
 	ContainerImageName    string   `json:"containerImageName,omitempty"`
 	ContainerName         string   `json:"containerName,omitempty"`
 	ContainerID           string   `json:"containerId,omitempty"`
+	PodName               string   `json:"podName,omitempty"`
+	PodNamespace          string   `json:"podNamespace,omitempty"`
 	Contained             string   `json:"contained,omitempty"`
 	CmdLine               string   `json:"commandLine,omitempty"`
 	Status                string   `json:"state,omitempty"`
 	ParentProcessID       int32    `json:"parentProcessId,omitempty"`
 	ThreadCount           int32    `json:"threadCount,omitempty"`
 	FdCount               *int32   `json:"fileDescriptorCount,omitempty"`
+	FdLimit               *int64   `json:"fileDescriptorLimit,omitempty"`
+	HandleCount           *uint32  `json:"handleCount,omitempty"`
 	IOReadCountPerSecond  *float64 `json:"ioReadCountPerSecond,omitempty"`
 	IOWriteCountPerSecond *float64 `json:"ioWriteCountPerSecond,omitempty"`
 	IOReadBytesPerSecond  *float64 `json:"ioReadBytesPerSecond,omitempty"`
@@ -41,6 +46,8 @@ type ProcessSample struct {
 	IOTotalWriteCount     *uint64  `json:"ioTotalWriteCount,omitempty"`
 	IOTotalReadBytes      *uint64  `json:"ioTotalReadBytes,omitempty"`
 	IOTotalWriteBytes     *uint64  `json:"ioTotalWriteBytes,omitempty"`
+	// Number of processes rolled up into the sample, only reported by the "other" sample of the top-N mode
+	ProcessCount *int `json:"processCount,omitempty"`
 	// Auxiliary values, not to be reported
 	LastIOCounters  *process.IOCountersStat `json:"-"`
 	ContainerLabels map[string]string       `json:"-"`
@@ -40,6 +40,14 @@ func (ac *AgentConfigPlugin) Run() {
 	ac.Context.AddReconnecting(ac)
 
 	ac.config.License = ""
+	if len(ac.config.AdditionalDestinations) > 0 {
+		destinations := make([]config.Destination, len(ac.config.AdditionalDestinations))
+		for i, d := range ac.config.AdditionalDestinations {
+			d.LicenseKey = ""
+			destinations[i] = d
+		}
+		ac.config.AdditionalDestinations = destinations
+	}
 	if ac.config.Proxy != "" {
 		ac.config.Proxy = "<proxy set>"
 	}
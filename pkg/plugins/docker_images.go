@@ -0,0 +1,171 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package plugins
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/client"
+
+	"github.com/newrelic/infrastructure-agent/internal/agent"
+	"github.com/newrelic/infrastructure-agent/pkg/config"
+	"github.com/newrelic/infrastructure-agent/pkg/entity"
+	"github.com/newrelic/infrastructure-agent/pkg/helpers"
+	"github.com/newrelic/infrastructure-agent/pkg/log"
+	"github.com/newrelic/infrastructure-agent/pkg/plugins/ids"
+)
+
+var dilog = log.WithPlugin("DockerImages")
+
+// noneReference is how Docker names the repository and tag of the untagged images.
+const noneReference = "<none>"
+
+// DockerImage is an image present in the local Docker daemon. Images with several tags are reported
+// once per tag.
+type DockerImage struct {
+	// ID is the repository and tag, or the image ID for untagged images
+	ID         string `json:"id"`
+	ImageID    string `json:"image_id"`
+	Repository string `json:"repository"`
+	Tag        string `json:"tag"`
+	Digest     string `json:"digest,omitempty"`
+	SizeBytes  int64  `json:"size_bytes"`
+	Created    string `json:"created"`
+}
+
+func (i DockerImage) SortKey() string {
+	return i.ID
+}
+
+// imageLister is the part of the Docker client used by the plugin.
+type imageLister interface {
+	ImageList(ctx context.Context, options types.ImageListOptions) ([]types.ImageSummary, error)
+}
+
+// DockerImagesPlugin reports the images present in the local Docker daemon, so stale or drifted images
+// can be audited from the inventory.
+type DockerImagesPlugin struct {
+	agent.PluginCommon
+	frequency time.Duration
+	images    imageLister // lazily created, as the daemon can be started after the agent
+}
+
+func NewDockerImagesPlugin(id ids.PluginID, ctx agent.AgentContext) *DockerImagesPlugin {
+	cfg := ctx.Config()
+	return &DockerImagesPlugin{
+		PluginCommon: agent.PluginCommon{ID: id, Context: ctx},
+		frequency: config.ValidateConfigFrequencySetting(
+			cfg.DockerImagesRefreshSec,
+			config.FREQ_MINIMUM_INVENTORY_SAMPLE_RATE,
+			config.FREQ_PLUGIN_DOCKER_IMAGES_UPDATES,
+			cfg.DisableAllPlugins || !cfg.EnableDockerImagesInventory,
+		) * time.Second,
+	}
+}
+
+func (p *DockerImagesPlugin) Run() {
+	if p.frequency <= config.FREQ_DISABLE_SAMPLING {
+		dilog.Debug("Disabled.")
+		return
+	}
+
+	ticker := time.NewTicker(1)
+	for range ticker.C {
+		ticker.Stop()
+		ticker = time.NewTicker(p.frequency)
+		if p.images == nil {
+			if !helpers.IsDockerRunning() {
+				dilog.Debug("No Docker daemon found.")
+				continue
+			}
+			cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+			if err != nil {
+				dilog.WithError(err).Warn("Can't initialize the Docker client.")
+				continue
+			}
+			p.images = cli
+		}
+		dataset, err := dockerImages(p.images)
+		if err != nil {
+			dilog.WithError(err).Debug("Can't list the Docker images.")
+			continue
+		}
+		p.EmitInventory(dataset, entity.NewFromNameWithoutID(p.Context.EntityKey()))
+	}
+}
+
+// dockerImages returns an inventory item per tag of the images, and one per untagged image.
+func dockerImages(lister imageLister) (agent.PluginInventoryDataset, error) {
+	summaries, err := lister.ImageList(context.Background(), types.ImageListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	var dataset agent.PluginInventoryDataset
+	for _, summary := range summaries {
+		created := time.Unix(summary.Created, 0).UTC().Format(time.RFC3339)
+		var tagged bool
+		for _, reference := range summary.RepoTags {
+			repository, tag := splitImageReference(reference)
+			if repository == noneReference {
+				continue
+			}
+			tagged = true
+			dataset = append(dataset, DockerImage{
+				ID:         reference,
+				ImageID:    summary.ID,
+				Repository: repository,
+				Tag:        tag,
+				Digest:     repositoryDigest(summary.RepoDigests, repository),
+				SizeBytes:  summary.Size,
+				Created:    created,
+			})
+		}
+		if tagged {
+			continue
+		}
+		// dangling images, or images pulled by digest, keep the repository in their digests
+		repository, digest := noneReference, ""
+		if len(summary.RepoDigests) > 0 {
+			if at := strings.LastIndex(summary.RepoDigests[0], "@"); at > 0 && summary.RepoDigests[0][:at] != noneReference {
+				repository, digest = summary.RepoDigests[0][:at], summary.RepoDigests[0][at+1:]
+			}
+		}
+		dataset = append(dataset, DockerImage{
+			ID:         summary.ID,
+			ImageID:    summary.ID,
+			Repository: repository,
+			Tag:        noneReference,
+			Digest:     digest,
+			SizeBytes:  summary.Size,
+			Created:    created,
+		})
+	}
+	sort.Sort(dataset)
+	return dataset, nil
+}
+
+// splitImageReference splits a repo tag in its repository and tag. Registries can have a port, so
+// the tag is after the last colon of the last path component, e.g. registry:5000/team/app:1.2
+func splitImageReference(reference string) (repository, tag string) {
+	colon := strings.LastIndex(reference, ":")
+	if colon < 0 || colon < strings.LastIndex(reference, "/") {
+		return reference, ""
+	}
+	return reference[:colon], reference[colon+1:]
+}
+
+// repositoryDigest returns the digest the image has in the passed repository, from its
+// repository@digest references.
+func repositoryDigest(repoDigests []string, repository string) string {
+	for _, reference := range repoDigests {
+		if strings.HasPrefix(reference, repository+"@") {
+			return reference[len(repository)+1:]
+		}
+	}
+	return ""
+}
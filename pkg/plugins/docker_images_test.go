@@ -0,0 +1,74 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package plugins
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/docker/docker/api/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/newrelic/infrastructure-agent/internal/agent"
+)
+
+type fakeImageLister struct {
+	images []types.ImageSummary
+	err    error
+}
+
+func (f *fakeImageLister) ImageList(_ context.Context, _ types.ImageListOptions) ([]types.ImageSummary, error) {
+	return f.images, f.err
+}
+
+func TestDockerImages(t *testing.T) {
+	// GIVEN an image with two tags, an image from a registry with port and a dangling image
+	lister := &fakeImageLister{images: []types.ImageSummary{
+		{
+			ID:          "sha256:aaa",
+			Created:     1600000000,
+			Size:        1024,
+			RepoTags:    []string{"nginx:1.19", "nginx:latest"},
+			RepoDigests: []string{"nginx@sha256:ddd"},
+		},
+		{
+			ID:       "sha256:bbb",
+			Created:  1600000000,
+			Size:     2048,
+			RepoTags: []string{"registry:5000/team/app:1.2"},
+		},
+		{
+			ID:          "sha256:ccc",
+			Created:     1600000000,
+			Size:        512,
+			RepoTags:    []string{"<none>:<none>"},
+			RepoDigests: []string{"<none>@<none>"},
+		},
+	}}
+
+	// WHEN the inventory is built
+	dataset, err := dockerImages(lister)
+	require.NoError(t, err)
+
+	// THEN an item is reported per tag, sorted by ID
+	created := "2020-09-13T12:26:40Z"
+	assert.Equal(t, agent.PluginInventoryDataset{
+		DockerImage{ID: "nginx:1.19", ImageID: "sha256:aaa", Repository: "nginx", Tag: "1.19", Digest: "sha256:ddd", SizeBytes: 1024, Created: created},
+		DockerImage{ID: "nginx:latest", ImageID: "sha256:aaa", Repository: "nginx", Tag: "latest", Digest: "sha256:ddd", SizeBytes: 1024, Created: created},
+		DockerImage{ID: "registry:5000/team/app:1.2", ImageID: "sha256:bbb", Repository: "registry:5000/team/app", Tag: "1.2", SizeBytes: 2048, Created: created},
+		DockerImage{ID: "sha256:ccc", ImageID: "sha256:ccc", Repository: "<none>", Tag: "<none>", SizeBytes: 512, Created: created},
+	}, dataset)
+}
+
+func TestDockerImages_Error(t *testing.T) {
+	// GIVEN a Docker daemon failing to list the images
+	lister := &fakeImageLister{err: errors.New("daemon unavailable")}
+
+	// WHEN the inventory is built
+	_, err := dockerImages(lister)
+
+	// THEN the error is returned, so the previous inventory is kept
+	assert.Error(t, err)
+}
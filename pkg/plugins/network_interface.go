@@ -3,9 +3,14 @@
 package plugins
 
 import (
-	"github.com/newrelic/infrastructure-agent/pkg/entity"
+	"fmt"
+	"sort"
+	"strings"
 	"time"
 
+	"github.com/newrelic/infrastructure-agent/pkg/entity"
+	"github.com/shirou/gopsutil/net"
+
 	"github.com/newrelic/infrastructure-agent/pkg/plugins/ids"
 
 	"github.com/newrelic/infrastructure-agent/internal/agent"
@@ -24,11 +29,22 @@ func (self NetworkInterfaceData) SortKey() string {
 	return self.InterfaceName
 }
 
+// linkState holds the status of a network interface whose changes are reported as events.
+type linkState struct {
+	up        bool
+	addresses []string // sorted
+	speedMbps int      // negotiated link speed, negative if unknown
+}
+
+// NetworkInterfacePlugin reports the network interfaces inventory, submitting an event each time an
+// interface goes up or down, changes its IP addresses or renegotiates its link speed.
 type NetworkInterfacePlugin struct {
 	agent.PluginCommon
 	frequency               time.Duration                      // Plugin emit interval
 	networkInterfaceFilters map[string][]string                // Controls which interfaces to ignore
 	getInterfaces           network_helpers.InterfacesProvider // Provider for []net.InterfaceStat
+	getLinkStatus           linkStatusProvider                 // Provider for the interfaces operational state and speed
+	states                  map[string]linkState               // last seen state, by interface name
 }
 
 func NewNetworkInterfacePlugin(id ids.PluginID, ctx agent.AgentContext) *NetworkInterfacePlugin {
@@ -53,6 +69,7 @@ func NewNetworkInterfacePlugin(id ids.PluginID, ctx agent.AgentContext) *Network
 		networkInterfaceFilters: filters,
 	}
 
+	plugin.getLinkStatus = linkStatus
 	return plugin.WithInterfacesProvider(network_helpers.GopsutilInterfacesProvider)
 }
 
@@ -62,12 +79,19 @@ func (self *NetworkInterfacePlugin) WithInterfacesProvider(p network_helpers.Int
 }
 
 func (self *NetworkInterfacePlugin) getNetworkInterfaceData() (agent.PluginInventoryDataset, error) {
+	dataset, _, err := self.collect()
+	return dataset, err
+}
+
+// collect returns the network interfaces inventory along with the state of each interface.
+func (self *NetworkInterfacePlugin) collect() (agent.PluginInventoryDataset, map[string]linkState, error) {
 	var dataset agent.PluginInventoryDataset
 
 	interfaces, err := self.getInterfaces()
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
+	states := make(map[string]linkState, len(interfaces))
 
 	for _, ni := range interfaces {
 		if network_helpers.ShouldIgnoreInterface(self.networkInterfaceFilters, ni.Name) {
@@ -82,9 +106,33 @@ func (self *NetworkInterfacePlugin) getNetworkInterfaceData() (agent.PluginInven
 			IpV4Address:     ipv4,
 			IpV6Address:     ipv6,
 		})
+		states[ni.Name] = self.linkState(ni)
 	}
 
-	return dataset, nil
+	return dataset, states, nil
+}
+
+func (self *NetworkInterfacePlugin) linkState(ni net.InterfaceStat) linkState {
+	state := linkState{speedMbps: -1}
+	for _, flag := range ni.Flags {
+		if flag == "up" {
+			state.up = true
+		}
+	}
+	if self.getLinkStatus != nil {
+		// the operational state, when known, also reflects the carrier loss
+		var known bool
+		var up bool
+		up, known, state.speedMbps = self.getLinkStatus(ni.Name)
+		if known {
+			state.up = up
+		}
+	}
+	for _, addr := range ni.Addrs {
+		state.addresses = append(state.addresses, addr.Addr)
+	}
+	sort.Strings(state.addresses)
+	return state
 }
 
 func (self *NetworkInterfacePlugin) Run() {
@@ -93,18 +141,92 @@ func (self *NetworkInterfacePlugin) Run() {
 		return
 	}
 
+	changes := make(chan struct{}, 1)
+	if err := watchLinkChanges(changes); err != nil {
+		slog.WithError(err).WithPlugin(self.Id().String()).
+			Debug("Can't subscribe to network interface changes. Refreshing them only periodically.")
+	}
+
 	ticker := time.NewTicker(1)
 	for {
 		select {
 		case <-ticker.C:
 			ticker.Stop()
 			ticker = time.NewTicker(self.frequency)
+		case <-changes:
+		}
 
-			dataset, err := self.getNetworkInterfaceData()
-			if err != nil {
-				slog.WithError(err).WithPlugin(self.Id().String()).Error("fetching network interface data")
+		dataset, states, err := self.collect()
+		if err != nil {
+			slog.WithError(err).WithPlugin(self.Id().String()).Error("fetching network interface data")
+		}
+		entityKey := self.Context.EntityKey()
+		if err == nil {
+			// no events are reported for the interfaces found on startup
+			if self.states != nil {
+				for _, event := range linkEvents(self.states, states) {
+					self.EmitEvent(event, entity.Key(entityKey))
+				}
+			}
+			self.states = states
+		}
+		self.EmitInventory(dataset, entity.NewFromNameWithoutID(entityKey))
+	}
+}
+
+// linkEvents returns an event for each state change of the interfaces present in both passed states.
+func linkEvents(previous, current map[string]linkState) []map[string]interface{} {
+	names := make([]string, 0, len(current))
+	for name := range current {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var events []map[string]interface{}
+	for _, name := range names {
+		before, ok := previous[name]
+		if !ok {
+			continue
+		}
+		after := current[name]
+		if before.up != after.up {
+			action := "down"
+			if after.up {
+				action = "up"
 			}
-			self.EmitInventory(dataset, entity.NewFromNameWithoutID(self.Context.EntityKey()))
+			events = append(events, linkEvent(name, action, fmt.Sprintf("Network interface %s is %s", name, action), nil))
+		}
+		if beforeAddrs, afterAddrs := strings.Join(before.addresses, ","), strings.Join(after.addresses, ","); beforeAddrs != afterAddrs {
+			events = append(events, linkEvent(name, "addressChanged",
+				fmt.Sprintf("Network interface %s addresses changed from [%s] to [%s]", name, beforeAddrs, afterAddrs),
+				map[string]interface{}{
+					"previousAddresses": beforeAddrs,
+					"addresses":         afterAddrs,
+				}))
+		}
+		// a speed becoming unknown is a side effect of the link going down, already reported
+		if before.speedMbps != after.speedMbps && before.speedMbps >= 0 && after.speedMbps >= 0 {
+			events = append(events, linkEvent(name, "linkSpeedChanged",
+				fmt.Sprintf("Network interface %s link speed changed from %d to %d Mbps", name, before.speedMbps, after.speedMbps),
+				map[string]interface{}{
+					"previousLinkSpeedMbps": before.speedMbps,
+					"linkSpeedMbps":         after.speedMbps,
+				}))
 		}
 	}
+	return events
+}
+
+func linkEvent(interfaceName, action, summary string, attributes map[string]interface{}) map[string]interface{} {
+	event := map[string]interface{}{
+		"eventType":     "InfrastructureEvent",
+		"category":      "network",
+		"summary":       summary,
+		"action":        action,
+		"interfaceName": interfaceName,
+	}
+	for k, v := range attributes {
+		event[k] = v
+	}
+	return event
 }
@@ -0,0 +1,71 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package plugins
+
+import (
+	"io/ioutil"
+	"strconv"
+	"strings"
+
+	"github.com/newrelic/infrastructure-agent/pkg/helpers"
+	"golang.org/x/sys/unix"
+)
+
+// linkStatusProvider returns whether a network interface is operationally up, whether this state is
+// known, and its negotiated speed in Mbps (negative if unknown).
+type linkStatusProvider func(name string) (up bool, known bool, speedMbps int)
+
+// linkStatus reads the operational state and speed of an interface from the sys filesystem.
+func linkStatus(name string) (up bool, known bool, speedMbps int) {
+	speedMbps = -1
+	// virtual and down interfaces fail to read or report -1
+	if speed, err := ioutil.ReadFile(helpers.HostSys("class/net", name, "speed")); err == nil {
+		if s, err := strconv.Atoi(strings.TrimSpace(string(speed))); err == nil && s >= 0 {
+			speedMbps = s
+		}
+	}
+	operstate, err := ioutil.ReadFile(helpers.HostSys("class/net", name, "operstate"))
+	if err != nil {
+		return false, false, speedMbps
+	}
+	// "unknown" is reported by interfaces without carrier detection, such as the loopback
+	switch strings.TrimSpace(string(operstate)) {
+	case "up":
+		return true, true, speedMbps
+	case "down", "lowerlayerdown", "notpresent":
+		return false, true, speedMbps
+	}
+	return false, false, speedMbps
+}
+
+// watchLinkChanges signals through the passed channel each time the kernel notifies a change in the
+// network links or their addresses. Signals are coalesced while the receiver is busy.
+func watchLinkChanges(changes chan<- struct{}) error {
+	fd, err := unix.Socket(unix.AF_NETLINK, unix.SOCK_RAW|unix.SOCK_CLOEXEC, unix.NETLINK_ROUTE)
+	if err != nil {
+		return err
+	}
+	groups := uint32(unix.RTMGRP_LINK | unix.RTMGRP_IPV4_IFADDR | unix.RTMGRP_IPV6_IFADDR)
+	if err = unix.Bind(fd, &unix.SockaddrNetlink{Family: unix.AF_NETLINK, Groups: groups}); err != nil {
+		unix.Close(fd)
+		return err
+	}
+	go func() {
+		defer unix.Close(fd)
+		buf := make([]byte, 64*1024)
+		for {
+			if _, _, err := unix.Recvfrom(fd, buf, 0); err != nil {
+				// on overrun, some notifications were lost but a refresh is anyway due
+				if err != unix.EINTR && err != unix.ENOBUFS {
+					slog.WithError(err).Warn("stopped receiving network interface changes")
+					return
+				}
+			}
+			select {
+			case changes <- struct{}{}:
+			default:
+			}
+		}
+	}()
+	return nil
+}
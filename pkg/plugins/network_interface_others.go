@@ -0,0 +1,20 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+// +build !linux
+
+package plugins
+
+import "errors"
+
+// linkStatusProvider returns whether a network interface is operationally up, whether this state is
+// known, and its negotiated speed in Mbps (negative if unknown).
+type linkStatusProvider func(name string) (up bool, known bool, speedMbps int)
+
+// linkStatus is not available in this platform, so the interface state is taken from its flags.
+func linkStatus(string) (up bool, known bool, speedMbps int) {
+	return false, false, -1
+}
+
+func watchLinkChanges(chan<- struct{}) error {
+	return errors.New("network interface change notifications not supported in this platform")
+}
@@ -174,3 +174,82 @@ func TestNetworkPlugin(t *testing.T) {
 
 	assert.Equal(t, expectedInventory, actualInventory)
 }
+
+func TestLinkState(t *testing.T) {
+	plugin := &NetworkInterfacePlugin{}
+	ni := net.InterfaceStat{
+		Name:  "eth0",
+		Flags: []string{"up", "broadcast"},
+		Addrs: []net.InterfaceAddr{{Addr: "fe80::1/64"}, {Addr: "10.0.0.2/24"}},
+	}
+
+	// GIVEN no operational state available, the flags are used
+	assert.Equal(t, linkState{up: true, addresses: []string{"10.0.0.2/24", "fe80::1/64"}, speedMbps: -1},
+		plugin.linkState(ni))
+
+	// GIVEN an interface administratively up but without carrier
+	plugin.getLinkStatus = func(name string) (bool, bool, int) {
+		assert.Equal(t, "eth0", name)
+		return false, true, -1
+	}
+	assert.False(t, plugin.linkState(ni).up)
+
+	// GIVEN an unknown operational state, the flags are used
+	plugin.getLinkStatus = func(string) (bool, bool, int) { return false, false, 1000 }
+	state := plugin.linkState(ni)
+	assert.True(t, state.up)
+	assert.Equal(t, 1000, state.speedMbps)
+}
+
+func TestLinkEvents(t *testing.T) {
+	// GIVEN a set of interfaces
+	previous := map[string]linkState{
+		"eth0": {up: true, addresses: []string{"10.0.0.2/24"}, speedMbps: 1000},
+		"eth1": {up: true, addresses: []string{"10.0.1.2/24"}, speedMbps: 1000},
+		"eth2": {up: true, speedMbps: 10000},
+		"gone": {up: true},
+	}
+
+	// WHEN eth0 renegotiates its speed and changes address, eth1 goes down and a new interface appears
+	current := map[string]linkState{
+		"eth0": {up: true, addresses: []string{"10.0.0.3/24"}, speedMbps: 100},
+		"eth1": {up: false, addresses: []string{"10.0.1.2/24"}, speedMbps: -1},
+		"eth2": {up: true, speedMbps: 10000},
+		"new":  {up: true},
+	}
+	events := linkEvents(previous, current)
+
+	// THEN the changes of the existing interfaces are reported
+	assert.Equal(t, []map[string]interface{}{
+		{
+			"eventType":         "InfrastructureEvent",
+			"category":          "network",
+			"summary":           "Network interface eth0 addresses changed from [10.0.0.2/24] to [10.0.0.3/24]",
+			"action":            "addressChanged",
+			"interfaceName":     "eth0",
+			"previousAddresses": "10.0.0.2/24",
+			"addresses":         "10.0.0.3/24",
+		},
+		{
+			"eventType":             "InfrastructureEvent",
+			"category":              "network",
+			"summary":               "Network interface eth0 link speed changed from 1000 to 100 Mbps",
+			"action":                "linkSpeedChanged",
+			"interfaceName":         "eth0",
+			"previousLinkSpeedMbps": 1000,
+			"linkSpeedMbps":         100,
+		},
+		{
+			"eventType":     "InfrastructureEvent",
+			"category":      "network",
+			"summary":       "Network interface eth1 is down",
+			"action":        "down",
+			"interfaceName": "eth1",
+		},
+	}, events)
+
+	// AND the interface going back up is reported too
+	events = linkEvents(current, previous)
+	assert.Len(t, events, 3)
+	assert.Equal(t, "up", events[2]["action"])
+}
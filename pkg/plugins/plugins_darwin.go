@@ -4,6 +4,7 @@ package plugins
 
 import (
 	"github.com/newrelic/infrastructure-agent/internal/agent"
+	pluginsDarwin "github.com/newrelic/infrastructure-agent/internal/plugins/darwin"
 	"github.com/newrelic/infrastructure-agent/pkg/plugins/ids"
 	"github.com/newrelic/infrastructure-agent/pkg/plugins/proxy"
 )
@@ -23,6 +24,8 @@ func RegisterPlugins(a *agent.Agent) error {
 	if config.FilesConfigOn {
 		a.RegisterPlugin(NewConfigFilePlugin(*ids.NewPluginID("files", "config"), a.Context))
 	}
+	a.RegisterPlugin(pluginsDarwin.NewApplicationsPlugin(*ids.NewPluginID("packages", "applications"), a.Context))
+	a.RegisterPlugin(pluginsDarwin.NewHomebrewPlugin(*ids.NewPluginID("packages", "homebrew"), a.Context))
 
 	return nil
 }
@@ -8,11 +8,21 @@ import (
 	config2 "github.com/newrelic/infrastructure-agent/pkg/config"
 	"github.com/newrelic/infrastructure-agent/pkg/helpers"
 	"github.com/newrelic/infrastructure-agent/pkg/metrics"
+	"github.com/newrelic/infrastructure-agent/pkg/metrics/connection"
+	"github.com/newrelic/infrastructure-agent/pkg/metrics/crash"
+	"github.com/newrelic/infrastructure-agent/pkg/metrics/gpu"
 	"github.com/newrelic/infrastructure-agent/pkg/metrics/network"
+	"github.com/newrelic/infrastructure-agent/pkg/metrics/ntp"
+	"github.com/newrelic/infrastructure-agent/pkg/metrics/power"
 	"github.com/newrelic/infrastructure-agent/pkg/metrics/process"
 	metricsSender "github.com/newrelic/infrastructure-agent/pkg/metrics/sender"
+	"github.com/newrelic/infrastructure-agent/pkg/metrics/sensor"
+	"github.com/newrelic/infrastructure-agent/pkg/metrics/smart"
 	"github.com/newrelic/infrastructure-agent/pkg/metrics/storage"
+	"github.com/newrelic/infrastructure-agent/pkg/metrics/storage/cgroup"
 	"github.com/newrelic/infrastructure-agent/pkg/metrics/storage/nfs"
+	"github.com/newrelic/infrastructure-agent/pkg/metrics/systemd"
+	"github.com/newrelic/infrastructure-agent/pkg/metrics/tcphealth"
 	"github.com/newrelic/infrastructure-agent/pkg/plugins/ids"
 	"github.com/newrelic/infrastructure-agent/pkg/plugins/proxy"
 	"github.com/newrelic/infrastructure-agent/pkg/sysinfo/cloud"
@@ -62,6 +72,13 @@ func RegisterPlugins(agent *agnt.Agent) error {
 		return nil
 	}
 
+	if config.EnableDockerImagesInventory {
+		agent.RegisterPlugin(NewDockerImagesPlugin(ids.PluginID{"packages", "docker_images"}, agent.Context))
+	}
+	if len(config.TLSCertificatePaths) > 0 || len(config.TLSCertificatePorts) > 0 {
+		agent.RegisterPlugin(NewTLSCertificatesPlugin(ids.PluginID{"security", "tls_certificates"}, agent.Context))
+	}
+
 	// register remaining plugins
 	if !config.IsContainerized {
 		// register our plugins
@@ -75,6 +92,14 @@ func RegisterPlugins(agent *agnt.Agent) error {
 		agent.RegisterPlugin(pluginsLinux.NewDaemontoolsPlugin(ids.PluginID{"services", "daemontools"}, agent.Context))
 		agent.RegisterPlugin(pluginsLinux.NewSupervisorPlugin(ids.PluginID{"services", "supervisord"}, agent.Context))
 		agent.RegisterPlugin(NewNetworkInterfacePlugin(ids.PluginID{"system", "network_interfaces"}, agent.Context))
+		agent.RegisterPlugin(pluginsLinux.NewMountsPlugin(ids.PluginID{"storage", "mounts"}, agent.Context))
+		agent.RegisterPlugin(pluginsLinux.NewBlockDevicesPlugin(ids.PluginID{"storage", "block_devices"}, agent.Context))
+		if config.EnablePythonPackagesInventory {
+			agent.RegisterPlugin(pluginsLinux.NewPythonPackagesPlugin(ids.PluginID{"packages", "python"}, agent.Context))
+		}
+		if config.EnableScheduledTasksInventory {
+			agent.RegisterPlugin(NewScheduledTasksPlugin(ids.PluginID{"services", "scheduled_tasks"}, agent.Context))
+		}
 
 		if config.RunMode == config2.ModeRoot || config.RunMode == config2.ModePrivileged {
 			id := ids.PluginID{"kernel", "sysctl"}
@@ -102,6 +127,13 @@ func RegisterPlugins(agent *agnt.Agent) error {
 				slog.Debug("Registering RPM plugins.")
 				agent.RegisterPlugin(pluginsLinux.NewRpmPlugin(agent.Context))
 			}
+
+			agent.RegisterPlugin(pluginsLinux.NewSnapPlugin(ids.PluginID{"packages", "snap"}, agent.Context))
+			agent.RegisterPlugin(pluginsLinux.NewFlatpakPlugin(ids.PluginID{"packages", "flatpak"}, agent.Context))
+
+			if config.EnableCPEInventory {
+				agent.RegisterPlugin(pluginsLinux.NewCPEPlugin(ids.PluginID{"security", "cpe"}, agent.Context))
+			}
 		}
 
 		if config.RunMode == config2.ModeRoot {
@@ -141,6 +173,36 @@ func RegisterPlugins(agent *agnt.Agent) error {
 	sender.RegisterSampler(nfsSampler)
 	sender.RegisterSampler(networkSampler)
 	sender.RegisterSampler(procSampler)
+	if ntpSampler := ntp.NewSampler(agent.Context); !ntpSampler.Disabled() {
+		sender.RegisterSampler(ntpSampler)
+	}
+	if crashSampler := crash.NewSampler(agent.Context); !crashSampler.Disabled() {
+		sender.RegisterSampler(crashSampler)
+	}
+	if powerSampler := power.NewSampler(agent.Context); !powerSampler.Disabled() {
+		sender.RegisterSampler(powerSampler)
+	}
+	if gpuSampler := gpu.NewSampler(agent.Context); !gpuSampler.Disabled() {
+		sender.RegisterSampler(gpuSampler)
+	}
+	if cgroupSampler := cgroup.NewSampler(agent.Context); !cgroupSampler.Disabled() {
+		sender.RegisterSampler(cgroupSampler)
+	}
+	if sensorSampler := sensor.NewSampler(agent.Context); !sensorSampler.Disabled() {
+		sender.RegisterSampler(sensorSampler)
+	}
+	if smartSampler := smart.NewSampler(agent.Context); !smartSampler.Disabled() {
+		sender.RegisterSampler(smartSampler)
+	}
+	if connectionSampler := connection.NewSampler(agent.Context); !connectionSampler.Disabled() {
+		sender.RegisterSampler(connectionSampler)
+	}
+	if tcpHealthSampler := tcphealth.NewSampler(agent.Context); !tcpHealthSampler.Disabled() {
+		sender.RegisterSampler(tcpHealthSampler)
+	}
+	if systemdSampler := systemd.NewSampler(agent.Context); !systemdSampler.Disabled() {
+		sender.RegisterSampler(systemdSampler)
+	}
 
 	agent.RegisterMetricsSender(sender)
 
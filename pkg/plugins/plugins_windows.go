@@ -3,9 +3,14 @@
 package plugins
 
 import (
+	"github.com/newrelic/infrastructure-agent/pkg/metrics/crash"
+	"github.com/newrelic/infrastructure-agent/pkg/metrics/gpu"
 	"github.com/newrelic/infrastructure-agent/pkg/metrics/network"
+	"github.com/newrelic/infrastructure-agent/pkg/metrics/rds"
 	metricsSender "github.com/newrelic/infrastructure-agent/pkg/metrics/sender"
+	"github.com/newrelic/infrastructure-agent/pkg/metrics/smart"
 	"github.com/newrelic/infrastructure-agent/pkg/metrics/storage"
+	"github.com/newrelic/infrastructure-agent/pkg/metrics/storage/nfs"
 	"github.com/newrelic/infrastructure-agent/pkg/plugins/ids"
 	"github.com/newrelic/infrastructure-agent/pkg/plugins/proxy"
 
@@ -45,11 +50,27 @@ func RegisterPlugins(agent *agnt.Agent) error {
 	if config.EnableWinUpdatePlugin {
 		agent.RegisterPlugin(pluginsWindows.NewUpdatesPlugin(ids.PluginID{"packages", "windows_updates"}, agent.Context))
 	}
+	if config.EnableWindowsFeaturesInventory {
+		agent.RegisterPlugin(pluginsWindows.NewFeaturesPlugin(ids.PluginID{"packages", "windows_features"}, agent.Context))
+	}
+	if config.EnablePendingRebootInventory {
+		agent.RegisterPlugin(pluginsWindows.NewPendingRebootPlugin(ids.PluginID{"system", "pending_reboot"}, agent.Context))
+	}
 
 	if config.FilesConfigOn {
 		agent.RegisterPlugin(NewConfigFilePlugin(ids.PluginID{"files", "config"}, agent.Context))
 	}
 
+	if config.EnableDockerImagesInventory {
+		agent.RegisterPlugin(NewDockerImagesPlugin(ids.PluginID{"packages", "docker_images"}, agent.Context))
+	}
+	if len(config.TLSCertificatePaths) > 0 || len(config.TLSCertificatePorts) > 0 {
+		agent.RegisterPlugin(NewTLSCertificatesPlugin(ids.PluginID{"security", "tls_certificates"}, agent.Context))
+	}
+	if config.EnableScheduledTasksInventory {
+		agent.RegisterPlugin(NewScheduledTasksPlugin(ids.PluginID{"services", "scheduled_tasks"}, agent.Context))
+	}
+
 	sender := metricsSender.NewSender(agent.Context)
 	procSampler := metrics.NewProcsMonitor(agent.Context)
 	storageSampler := storage.NewSampler(agent.Context)
@@ -71,6 +92,25 @@ func RegisterPlugins(agent *agnt.Agent) error {
 	sender.RegisterSampler(storageSampler)
 	sender.RegisterSampler(networkSampler)
 	sender.RegisterSampler(procSampler)
+	if gpuSampler := gpu.NewSampler(agent.Context); !gpuSampler.Disabled() {
+		// Prime the GPU Sampler, as the utilization counters are rates
+		if _, err := gpuSampler.Sample(); err != nil {
+			slog.WithError(err).Debug("Warming up GPU Sampler.")
+		}
+		sender.RegisterSampler(gpuSampler)
+	}
+	if sessionSampler := rds.NewSampler(agent.Context); !sessionSampler.Disabled() {
+		sender.RegisterSampler(sessionSampler)
+	}
+	if crashSampler := crash.NewSampler(agent.Context); !crashSampler.Disabled() {
+		sender.RegisterSampler(crashSampler)
+	}
+	if nfsSampler := nfs.NewSampler(agent.Context); !nfsSampler.Disabled() {
+		sender.RegisterSampler(nfsSampler)
+	}
+	if smartSampler := smart.NewSampler(agent.Context); !smartSampler.Disabled() {
+		sender.RegisterSampler(smartSampler)
+	}
 	agent.RegisterMetricsSender(sender)
 
 	return nil
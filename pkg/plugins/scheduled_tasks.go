@@ -0,0 +1,76 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package plugins
+
+import (
+	"sort"
+	"time"
+
+	"github.com/newrelic/infrastructure-agent/internal/agent"
+	"github.com/newrelic/infrastructure-agent/pkg/config"
+	"github.com/newrelic/infrastructure-agent/pkg/entity"
+	"github.com/newrelic/infrastructure-agent/pkg/log"
+	"github.com/newrelic/infrastructure-agent/pkg/plugins/ids"
+)
+
+var stlog = log.WithPlugin("ScheduledTasks")
+
+// ScheduledTask is a job run periodically by cron, a systemd timer or the Windows Task Scheduler.
+type ScheduledTask struct {
+	ID         string `json:"id"`
+	Source     string `json:"source"`
+	Name       string `json:"name"`
+	Schedule   string `json:"schedule"`
+	Command    string `json:"command"`
+	User       string `json:"user,omitempty"`
+	LastResult string `json:"last_result,omitempty"`
+}
+
+func (t ScheduledTask) SortKey() string {
+	return t.ID
+}
+
+// ScheduledTasksPlugin reports the scheduled jobs of the host, so unexpected ones show up as inventory
+// changes.
+type ScheduledTasksPlugin struct {
+	agent.PluginCommon
+	frequency time.Duration
+}
+
+func NewScheduledTasksPlugin(id ids.PluginID, ctx agent.AgentContext) *ScheduledTasksPlugin {
+	cfg := ctx.Config()
+	return &ScheduledTasksPlugin{
+		PluginCommon: agent.PluginCommon{ID: id, Context: ctx},
+		frequency: config.ValidateConfigFrequencySetting(
+			cfg.ScheduledTasksRefreshSec,
+			config.FREQ_MINIMUM_INVENTORY_SAMPLE_RATE,
+			config.FREQ_PLUGIN_SCHEDULED_TASKS_UPDATES,
+			cfg.DisableAllPlugins || !cfg.EnableScheduledTasksInventory,
+		) * time.Second,
+	}
+}
+
+func (p *ScheduledTasksPlugin) Run() {
+	if p.frequency <= config.FREQ_DISABLE_SAMPLING {
+		stlog.Debug("Disabled.")
+		return
+	}
+
+	ticker := time.NewTicker(1)
+	for range ticker.C {
+		ticker.Stop()
+		ticker = time.NewTicker(p.frequency)
+
+		tasks, err := scheduledTasks()
+		if err != nil {
+			// the tasks of the other schedulers are still reported
+			stlog.WithError(err).Debug("Can't read some scheduled tasks.")
+		}
+		dataset := make(agent.PluginInventoryDataset, 0, len(tasks))
+		for _, task := range tasks {
+			dataset = append(dataset, task)
+		}
+		sort.Sort(dataset)
+		p.EmitInventory(dataset, entity.NewFromNameWithoutID(p.Context.EntityKey()))
+	}
+}
@@ -0,0 +1,206 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package plugins
+
+import (
+	"bufio"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/coreos/go-systemd/v22/dbus"
+
+	"github.com/newrelic/infrastructure-agent/pkg/helpers"
+)
+
+const dbusSystemBusAddressEnvVar = "DBUS_SYSTEM_BUS_ADDRESS"
+
+var cronEnvAssignment = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*\s*=`)
+
+// crontab is a file with cron entries. The system ones have a user field after the schedule, the
+// ones of the users run as their owner.
+type crontab struct {
+	path string
+	user string // empty for system crontabs
+}
+
+func scheduledTasks() ([]ScheduledTask, error) {
+	tasks := cronTasks(crontabs())
+	timers, err := systemdTimers()
+	return append(tasks, timers...), err
+}
+
+// crontabs returns the system crontabs and the spools of the users, as laid out by Debian and Red Hat.
+func crontabs() []crontab {
+	tabs := []crontab{{path: helpers.HostEtc("crontab")}}
+	system, _ := filepath.Glob(helpers.HostEtc("cron.d", "*"))
+	for _, path := range system {
+		tabs = append(tabs, crontab{path: path})
+	}
+	for _, pattern := range []string{helpers.HostVar("spool", "cron", "crontabs", "*"), helpers.HostVar("spool", "cron", "*")} {
+		spools, _ := filepath.Glob(pattern)
+		for _, path := range spools {
+			if info, err := os.Stat(path); err == nil && info.Mode().IsRegular() {
+				tabs = append(tabs, crontab{path: path, user: filepath.Base(path)})
+			}
+		}
+	}
+	return tabs
+}
+
+func cronTasks(tabs []crontab) []ScheduledTask {
+	var tasks []ScheduledTask
+	for _, tab := range tabs {
+		file, err := os.Open(tab.path)
+		if err != nil {
+			if !os.IsNotExist(err) {
+				stlog.WithError(err).WithField("file", tab.path).Debug("Can't read crontab.")
+			}
+			continue
+		}
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			if task, ok := parseCronEntry(tab, scanner.Text()); ok {
+				tasks = append(tasks, task)
+			}
+		}
+		file.Close()
+	}
+	return tasks
+}
+
+// parseCronEntry parses a crontab line, e.g.
+// 17 * * * * root cd / && run-parts --report /etc/cron.hourly
+// Comments, empty lines and environment assignments are ignored.
+func parseCronEntry(tab crontab, line string) (ScheduledTask, bool) {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") || cronEnvAssignment.MatchString(line) {
+		return ScheduledTask{}, false
+	}
+	fields := strings.Fields(line)
+	scheduleLen := 5
+	if strings.HasPrefix(fields[0], "@") {
+		// nicknames such as @daily or @reboot
+		scheduleLen = 1
+	}
+	commandStart := scheduleLen
+	if tab.user == "" {
+		commandStart++
+	}
+	if len(fields) <= commandStart {
+		return ScheduledTask{}, false
+	}
+	user := tab.user
+	if user == "" {
+		user = fields[scheduleLen]
+	}
+	schedule := strings.Join(fields[:scheduleLen], " ")
+	command := strings.Join(fields[commandStart:], " ")
+
+	// the entries have no name, so they are identified by their content
+	hash := fnv.New32a()
+	_, _ = hash.Write([]byte(schedule + " " + user + " " + command))
+	return ScheduledTask{
+		ID:       fmt.Sprintf("cron:%s:%08x", tab.path, hash.Sum32()),
+		Source:   "cron",
+		Name:     tab.path,
+		Schedule: schedule,
+		Command:  command,
+		User:     user,
+	}, true
+}
+
+// systemdTimers returns the loaded timers with the command of the unit they activate.
+func systemdTimers() ([]ScheduledTask, error) {
+	if _, fnd := os.LookupEnv(dbusSystemBusAddressEnvVar); !fnd {
+		_ = os.Setenv(dbusSystemBusAddressEnvVar, fmt.Sprintf("unix:path=%s", helpers.HostVar("/run/dbus/system_bus_socket")))
+	}
+	conn, err := dbus.New()
+	if err != nil {
+		return nil, fmt.Errorf("can't connect to systemd: %s", err)
+	}
+	defer conn.Close()
+
+	statuses, err := conn.ListUnitsByPatterns(nil, []string{"*.timer"})
+	if err != nil {
+		return nil, err
+	}
+	tasks := make([]ScheduledTask, 0, len(statuses))
+	for _, status := range statuses {
+		timerProps, err := conn.GetUnitTypeProperties(status.Name, "Timer")
+		if err != nil {
+			stlog.WithError(err).WithField("unit", status.Name).Debug("Can't read the timer properties.")
+			continue
+		}
+		var serviceProps map[string]interface{}
+		if unit, ok := timerProps["Unit"].(string); ok && strings.HasSuffix(unit, ".service") {
+			if serviceProps, err = conn.GetUnitTypeProperties(unit, "Service"); err != nil {
+				stlog.WithError(err).WithField("unit", unit).Debug("Can't read the activated service properties.")
+			}
+		}
+		tasks = append(tasks, timerTask(status.Name, timerProps, serviceProps))
+	}
+	return tasks, nil
+}
+
+// timerTask builds the task of a timer from the properties of its Timer D-Bus interface and the Service
+// interface of the unit it activates. Structs are decoded by D-Bus as slices of their fields.
+func timerTask(name string, timerProps, serviceProps map[string]interface{}) ScheduledTask {
+	var schedules []string
+	// a(sst): the timer base, e.g. OnCalendar, the calendar specification and the next elapse
+	if calendar, ok := timerProps["TimersCalendar"].([][]interface{}); ok {
+		for _, timer := range calendar {
+			if len(timer) >= 2 {
+				schedules = append(schedules, fmt.Sprintf("%v=%v", timer[0], timer[1]))
+			}
+		}
+	}
+	// a(stt): the timer base, e.g. OnUnitActiveUSec, the elapse in microseconds and the next elapse
+	if monotonic, ok := timerProps["TimersMonotonic"].([][]interface{}); ok {
+		for _, timer := range monotonic {
+			if len(timer) < 2 {
+				continue
+			}
+			base, _ := timer[0].(string)
+			usec, ok := timer[1].(uint64)
+			if !ok {
+				continue
+			}
+			// named as in the unit files, e.g. OnUnitActiveSec
+			schedules = append(schedules, fmt.Sprintf("%s=%s", strings.TrimSuffix(base, "USec")+"Sec", time.Duration(usec)*time.Microsecond))
+		}
+	}
+
+	task := ScheduledTask{
+		ID:       "systemd:" + name,
+		Source:   "systemd",
+		Name:     name,
+		Schedule: strings.Join(schedules, "; "),
+	}
+	// a(sasbttttuii): the path, the arguments, whether failures are ignored and the last execution
+	if execStart, ok := serviceProps["ExecStart"].([][]interface{}); ok {
+		var commands []string
+		for _, exec := range execStart {
+			if len(exec) < 2 {
+				continue
+			}
+			if argv, ok := exec[1].([]string); ok && len(argv) > 0 {
+				commands = append(commands, strings.Join(argv, " "))
+			} else if path, ok := exec[0].(string); ok {
+				commands = append(commands, path)
+			}
+		}
+		task.Command = strings.Join(commands, "; ")
+	}
+	if user, ok := serviceProps["User"].(string); ok {
+		task.User = user
+	}
+	if result, ok := serviceProps["Result"].(string); ok {
+		task.LastResult = result
+	}
+	return task
+}
@@ -0,0 +1,75 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package plugins
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCronTasks(t *testing.T) {
+	// GIVEN a system crontab and the crontab of a user
+	dir, err := ioutil.TempDir("", "cron")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	system := filepath.Join(dir, "crontab")
+	require.NoError(t, ioutil.WriteFile(system, []byte(`SHELL=/bin/sh
+PATH = /usr/local/sbin:/usr/local/bin:/sbin:/bin:/usr/sbin:/usr/bin
+
+# m h dom mon dow user	command
+17 *	* * *	root    cd / && run-parts --report /etc/cron.hourly
+@reboot  root  /usr/local/bin/warmup
+* * * * *
+`), 0644))
+	user := filepath.Join(dir, "alice")
+	require.NoError(t, ioutil.WriteFile(user, []byte("*/5 * * * * /home/alice/backup.sh --quiet\n"), 0600))
+
+	// WHEN their entries are read
+	tasks := cronTasks([]crontab{{path: system}, {path: user, user: "alice"}, {path: filepath.Join(dir, "missing")}})
+
+	// THEN the entries are reported without comments, assignments nor incomplete lines
+	require.Len(t, tasks, 3)
+	assert.Equal(t, "17 * * * *", tasks[0].Schedule)
+	assert.Equal(t, "root", tasks[0].User)
+	assert.Equal(t, "cd / && run-parts --report /etc/cron.hourly", tasks[0].Command)
+	assert.Equal(t, system, tasks[0].Name)
+	assert.Equal(t, "cron", tasks[0].Source)
+	assert.Equal(t, "@reboot", tasks[1].Schedule)
+	assert.Equal(t, "/usr/local/bin/warmup", tasks[1].Command)
+	assert.Equal(t, "*/5 * * * *", tasks[2].Schedule)
+	assert.Equal(t, "alice", tasks[2].User)
+	assert.Equal(t, "/home/alice/backup.sh --quiet", tasks[2].Command)
+	assert.NotEqual(t, tasks[0].ID, tasks[1].ID)
+}
+
+func TestTimerTask(t *testing.T) {
+	// GIVEN the D-Bus properties of a timer and of the service it activates
+	timerProps := map[string]interface{}{
+		"Unit":            "logrotate.service",
+		"TimersCalendar":  [][]interface{}{{"OnCalendar", "*-*-* 00:00:00", uint64(0)}},
+		"TimersMonotonic": [][]interface{}{{"OnUnitActiveUSec", uint64(3600000000), uint64(0)}},
+	}
+	serviceProps := map[string]interface{}{
+		"ExecStart": [][]interface{}{{"/usr/sbin/logrotate", []string{"/usr/sbin/logrotate", "/etc/logrotate.conf"}, false}},
+		"User":      "",
+		"Result":    "exit-code",
+	}
+
+	// WHEN the task is built
+	task := timerTask("logrotate.timer", timerProps, serviceProps)
+
+	// THEN its schedules are named as in the unit files
+	assert.Equal(t, ScheduledTask{
+		ID:         "systemd:logrotate.timer",
+		Source:     "systemd",
+		Name:       "logrotate.timer",
+		Schedule:   "OnCalendar=*-*-* 00:00:00; OnUnitActiveSec=1h0m0s",
+		Command:    "/usr/sbin/logrotate /etc/logrotate.conf",
+		LastResult: "exit-code",
+	}, task)
+}
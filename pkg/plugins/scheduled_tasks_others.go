@@ -0,0 +1,11 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+// +build !linux,!windows
+
+package plugins
+
+import "errors"
+
+func scheduledTasks() ([]ScheduledTask, error) {
+	return nil, errors.New("scheduled tasks are not supported on this platform")
+}
@@ -0,0 +1,209 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package plugins
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+
+	"github.com/go-ole/go-ole"
+	"github.com/go-ole/go-ole/oleutil"
+)
+
+// https://docs.microsoft.com/en-us/windows/win32/taskschd/taskschedulerschema-enumerations
+const (
+	taskEnumHidden = 1
+	taskActionExec = 0
+	// returned by CoInitializeEx when COM was already initialized in the thread
+	sFalse = 0x00000001
+)
+
+// taskTriggerTypes names the TASK_TRIGGER_TYPE2 values.
+var taskTriggerTypes = map[int64]string{
+	0:  "event",
+	1:  "once",
+	2:  "daily",
+	3:  "weekly",
+	4:  "monthly",
+	5:  "monthly day of week",
+	6:  "idle",
+	7:  "registration",
+	8:  "boot",
+	9:  "logon",
+	11: "session state change",
+}
+
+// scheduledTasks returns the tasks of all the Task Scheduler folders through its scripting objects.
+func scheduledTasks() ([]ScheduledTask, error) {
+	// COM objects are bound to the thread that created them
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	if err := ole.CoInitializeEx(0, ole.COINIT_MULTITHREADED); err != nil {
+		if oleErr, ok := err.(*ole.OleError); !ok || oleErr.Code() != sFalse {
+			return nil, err
+		}
+	}
+	defer ole.CoUninitialize()
+
+	unknown, err := oleutil.CreateObject("Schedule.Service")
+	if err != nil {
+		return nil, fmt.Errorf("can't create the Task Scheduler service: %s", err)
+	}
+	defer unknown.Release()
+	service, err := unknown.QueryInterface(ole.IID_IDispatch)
+	if err != nil {
+		return nil, err
+	}
+	defer service.Release()
+	if _, err = oleutil.CallMethod(service, "Connect"); err != nil {
+		return nil, fmt.Errorf("can't connect to the Task Scheduler: %s", err)
+	}
+
+	root, err := oleutil.CallMethod(service, "GetFolder", `\`)
+	if err != nil {
+		return nil, err
+	}
+	defer root.Clear()
+	var tasks []ScheduledTask
+	err = folderTasks(root.ToIDispatch(), &tasks)
+	return tasks, err
+}
+
+// folderTasks appends the tasks of a folder and its subfolders.
+func folderTasks(folder *ole.IDispatch, tasks *[]ScheduledTask) error {
+	registered, err := oleutil.CallMethod(folder, "GetTasks", taskEnumHidden)
+	if err != nil {
+		return err
+	}
+	defer registered.Clear()
+	err = oleutil.ForEach(registered.ToIDispatch(), func(item *ole.VARIANT) error {
+		defer item.Clear()
+		task, err := registeredTask(item.ToIDispatch())
+		if err != nil {
+			stlog.WithError(err).Debug("Can't read a scheduled task.")
+			return nil
+		}
+		*tasks = append(*tasks, task)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	subfolders, err := oleutil.CallMethod(folder, "GetFolders", 0)
+	if err != nil {
+		return err
+	}
+	defer subfolders.Clear()
+	return oleutil.ForEach(subfolders.ToIDispatch(), func(item *ole.VARIANT) error {
+		defer item.Clear()
+		return folderTasks(item.ToIDispatch(), tasks)
+	})
+}
+
+// registeredTask reads an IRegisteredTask.
+func registeredTask(registered *ole.IDispatch) (ScheduledTask, error) {
+	path, err := oleutil.GetProperty(registered, "Path")
+	if err != nil {
+		return ScheduledTask{}, err
+	}
+	defer path.Clear()
+	task := ScheduledTask{
+		ID:     "task_scheduler:" + path.ToString(),
+		Source: "task_scheduler",
+		Name:   path.ToString(),
+	}
+	if result, err := oleutil.GetProperty(registered, "LastTaskResult"); err == nil {
+		task.LastResult = fmt.Sprintf("0x%X", uint32(variantInt(result)))
+		result.Clear()
+	}
+
+	definition, err := oleutil.GetProperty(registered, "Definition")
+	if err != nil {
+		return task, nil
+	}
+	defer definition.Clear()
+	task.Schedule = collectionStrings(definition.ToIDispatch(), "Triggers", triggerSchedule)
+	task.Command = collectionStrings(definition.ToIDispatch(), "Actions", actionCommand)
+	if principal, err := oleutil.GetProperty(definition.ToIDispatch(), "Principal"); err == nil {
+		task.User = stringProperty(principal.ToIDispatch(), "UserId")
+		principal.Clear()
+	}
+	return task, nil
+}
+
+// collectionStrings formats the items of a collection property, joining them with semicolons.
+func collectionStrings(disp *ole.IDispatch, name string, format func(*ole.IDispatch) string) string {
+	collection, err := oleutil.GetProperty(disp, name)
+	if err != nil {
+		return ""
+	}
+	defer collection.Clear()
+	var values []string
+	_ = oleutil.ForEach(collection.ToIDispatch(), func(item *ole.VARIANT) error {
+		defer item.Clear()
+		if value := format(item.ToIDispatch()); value != "" {
+			values = append(values, value)
+		}
+		return nil
+	})
+	return strings.Join(values, "; ")
+}
+
+// triggerSchedule formats an ITrigger as its type followed by its start, if any, e.g. daily 2020-09-01T03:00:00
+func triggerSchedule(trigger *ole.IDispatch) string {
+	schedule := "unknown"
+	if triggerType, err := oleutil.GetProperty(trigger, "Type"); err == nil {
+		if name, ok := taskTriggerTypes[variantInt(triggerType)]; ok {
+			schedule = name
+		}
+		triggerType.Clear()
+	}
+	if start := stringProperty(trigger, "StartBoundary"); start != "" {
+		schedule += " " + start
+	}
+	return schedule
+}
+
+// actionCommand formats an IExecAction as its path followed by its arguments. Other actions, such as COM
+// handlers, have no command.
+func actionCommand(action *ole.IDispatch) string {
+	actionType, err := oleutil.GetProperty(action, "Type")
+	if err != nil {
+		return ""
+	}
+	defer actionType.Clear()
+	if variantInt(actionType) != taskActionExec {
+		return ""
+	}
+	return strings.TrimSpace(stringProperty(action, "Path") + " " + stringProperty(action, "Arguments"))
+}
+
+func stringProperty(disp *ole.IDispatch, name string) string {
+	value, err := oleutil.GetProperty(disp, name)
+	if err != nil {
+		return ""
+	}
+	defer value.Clear()
+	return value.ToString()
+}
+
+// variantInt returns the value of an integer VARIANT, whatever its size.
+func variantInt(v *ole.VARIANT) int64 {
+	switch value := v.Value().(type) {
+	case int32:
+		return int64(value)
+	case int64:
+		return value
+	case int16:
+		return int64(value)
+	case uint32:
+		return int64(value)
+	case int8:
+		return int64(value)
+	case uint8:
+		return int64(value)
+	}
+	return 0
+}
@@ -0,0 +1,263 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+// Package simulation generates synthetic hosts and processes samples that are submitted through
+// the regular agent pipeline. It is meant for capacity testing of proxies, gateways and accounts.
+package simulation
+
+import (
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/newrelic/infrastructure-agent/internal/agent"
+	"github.com/newrelic/infrastructure-agent/pkg/entity"
+	"github.com/newrelic/infrastructure-agent/pkg/log"
+	"github.com/newrelic/infrastructure-agent/pkg/metrics"
+	"github.com/newrelic/infrastructure-agent/pkg/metrics/types"
+	"github.com/newrelic/infrastructure-agent/pkg/plugins/ids"
+	"github.com/newrelic/infrastructure-agent/pkg/sample"
+)
+
+const (
+	defaultSeed     = 1
+	defaultInterval = 5 * time.Second
+	gib             = 1 << 30
+)
+
+var (
+	slog    = log.WithComponent("Simulation")
+	timeNow = time.Now
+
+	pluginID = ids.PluginID{
+		Category: "metadata",
+		Term:     "simulation",
+	}
+
+	memoryChoices  = []float64{2 * gib, 4 * gib, 8 * gib, 16 * gib, 32 * gib, 64 * gib}
+	diskChoices    = []float64{20 * gib, 50 * gib, 100 * gib, 500 * gib}
+	processChoices = []string{"java", "nginx", "postgres", "mysqld", "redis-server", "node", "python3",
+		"sshd", "systemd", "dockerd", "containerd", "httpd", "ruby", "php-fpm", "kubelet"}
+	userChoices = []string{"root", "www-data", "postgres", "app", "nobody"}
+)
+
+// Spec describes the simulated load, as provided through the command line in the
+// "hosts=500,procs=200,seed=42" format.
+type Spec struct {
+	// Hosts is the number of simulated hosts.
+	Hosts int
+	// Procs is the number of simulated processes per host.
+	Procs int
+	// Seed of the random generator, so the same spec always generates the same samples.
+	Seed int64
+}
+
+// ParseSpec parses a simulation spec from its command line representation.
+func ParseSpec(value string) (Spec, error) {
+	spec := Spec{Seed: defaultSeed}
+	for _, field := range strings.Split(value, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		kv := strings.SplitN(field, "=", 2)
+		if len(kv) != 2 {
+			return spec, fmt.Errorf("invalid simulation field %q, expected key=value", field)
+		}
+		number, err := strconv.ParseInt(strings.TrimSpace(kv[1]), 10, 64)
+		if err != nil {
+			return spec, fmt.Errorf("invalid simulation value for %q: %v", kv[0], err)
+		}
+		switch strings.TrimSpace(kv[0]) {
+		case "hosts":
+			spec.Hosts = int(number)
+		case "procs":
+			spec.Procs = int(number)
+		case "seed":
+			spec.Seed = number
+		default:
+			return spec, fmt.Errorf("unknown simulation field %q", kv[0])
+		}
+	}
+	if spec.Hosts <= 0 {
+		return spec, fmt.Errorf("simulation requires a positive number of hosts")
+	}
+	if spec.Procs < 0 {
+		return spec, fmt.Errorf("simulation requires a non-negative number of processes")
+	}
+	return spec, nil
+}
+
+// Plugin periodically submits the samples of the simulated hosts.
+type Plugin struct {
+	agent.PluginCommon
+	interval time.Duration
+	hosts    []*host
+	rnd      *rand.Rand
+}
+
+type host struct {
+	key       entity.Key
+	cpu       float64
+	memory    float64
+	memUsed   float64
+	disk      float64
+	diskUsed  float64
+	processes []*process
+}
+
+type process struct {
+	pid     int32
+	name    string
+	user    string
+	cpu     float64
+	rss     int64
+	threads int32
+}
+
+// NewPlugin creates a simulation plugin for the given spec. Samples are submitted at the
+// configured system samples rate.
+func NewPlugin(ctx agent.AgentContext, spec Spec) *Plugin {
+	interval := defaultInterval
+	if rate := ctx.Config().MetricsSystemSampleRate; rate > 0 {
+		interval = time.Duration(rate) * time.Second
+	}
+
+	p := &Plugin{
+		PluginCommon: agent.PluginCommon{
+			ID:      pluginID,
+			Context: ctx,
+		},
+		interval: interval,
+		rnd:      rand.New(rand.NewSource(spec.Seed)),
+	}
+	for i := 0; i < spec.Hosts; i++ {
+		p.hosts = append(p.hosts, p.newHost(i, spec.Procs))
+	}
+	return p
+}
+
+func (p *Plugin) newHost(index, procs int) *host {
+	h := &host{
+		key:    entity.Key(fmt.Sprintf("simulated-host-%05d", index)),
+		cpu:    5 + p.rnd.Float64()*40,
+		memory: memoryChoices[p.rnd.Intn(len(memoryChoices))],
+		disk:   diskChoices[p.rnd.Intn(len(diskChoices))],
+	}
+	h.memUsed = h.memory * (0.2 + p.rnd.Float64()*0.5)
+	h.diskUsed = h.disk * (0.1 + p.rnd.Float64()*0.6)
+	for i := 0; i < procs; i++ {
+		h.processes = append(h.processes, &process{
+			pid:     int32(100 + i),
+			name:    processChoices[p.rnd.Intn(len(processChoices))],
+			user:    userChoices[p.rnd.Intn(len(userChoices))],
+			cpu:     p.rnd.Float64() * 5,
+			rss:     int64(1<<20 + p.rnd.Intn(512<<20)),
+			threads: int32(1 + p.rnd.Intn(64)),
+		})
+	}
+	return h
+}
+
+// Run submits the simulated samples until the agent stops.
+func (p *Plugin) Run() {
+	slog.WithField("hosts", len(p.hosts)).
+		WithField("interval", p.interval).
+		Warn("Simulation mode enabled. Synthetic samples will be submitted.")
+
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+	for {
+		p.submit()
+		<-ticker.C
+	}
+}
+
+func (p *Plugin) submit() {
+	for _, event := range p.harvest() {
+		p.Context.SendEvent(event.sample, event.key)
+	}
+}
+
+type keyedSample struct {
+	key    entity.Key
+	sample sample.Event
+}
+
+// harvest walks the metrics of the simulated hosts and returns their samples.
+func (p *Plugin) harvest() []keyedSample {
+	now := timeNow().Unix()
+	var samples []keyedSample
+	for _, h := range p.hosts {
+		h.cpu = walk(p.rnd, h.cpu, 5, 0, 100)
+		h.memUsed = walk(p.rnd, h.memUsed, h.memory*0.02, h.memory*0.05, h.memory)
+		h.diskUsed = walk(p.rnd, h.diskUsed, h.disk*0.001, 0, h.disk)
+		load := h.cpu / 25
+
+		system := &metrics.SystemSample{
+			CPUSample: &metrics.CPUSample{
+				CPUPercent:       h.cpu,
+				CPUUserPercent:   h.cpu * 0.7,
+				CPUSystemPercent: h.cpu * 0.25,
+				CPUIOWaitPercent: h.cpu * 0.05,
+				CPUIdlePercent:   100 - h.cpu,
+			},
+			LoadSample: &metrics.LoadSample{
+				LoadOne:     load,
+				LoadFive:    load * 0.9,
+				LoadFifteen: load * 0.8,
+			},
+			MemorySample: &metrics.MemorySample{
+				MemoryTotal:       h.memory,
+				MemoryUsed:        h.memUsed,
+				MemoryFree:        h.memory - h.memUsed,
+				MemoryUsedPercent: h.memUsed / h.memory * 100,
+				MemoryFreePercent: (h.memory - h.memUsed) / h.memory * 100,
+			},
+			DiskSample: &metrics.DiskSample{
+				TotalBytes:  h.disk,
+				UsedBytes:   h.diskUsed,
+				FreeBytes:   h.disk - h.diskUsed,
+				UsedPercent: h.diskUsed / h.disk * 100,
+				FreePercent: (h.disk - h.diskUsed) / h.disk * 100,
+			},
+		}
+		system.Type("SystemSample")
+		system.Timestamp(now)
+		samples = append(samples, keyedSample{key: h.key, sample: system})
+
+		for _, proc := range h.processes {
+			proc.cpu = walk(p.rnd, proc.cpu, 1, 0, 100)
+			ps := &types.ProcessSample{
+				ProcessDisplayName: proc.name,
+				ProcessID:          proc.pid,
+				CommandName:        proc.name,
+				User:               proc.user,
+				MemoryRSSBytes:     proc.rss,
+				MemoryVMSBytes:     proc.rss * 4,
+				CPUPercent:         proc.cpu,
+				CPUUserPercent:     proc.cpu * 0.8,
+				CPUSystemPercent:   proc.cpu * 0.2,
+				Status:             "S",
+				ThreadCount:        proc.threads,
+			}
+			ps.Type("ProcessSample")
+			ps.Timestamp(now)
+			samples = append(samples, keyedSample{key: h.key, sample: ps})
+		}
+	}
+	return samples
+}
+
+// walk randomly moves a value up to maxStep in any direction, keeping it within the limits.
+func walk(rnd *rand.Rand, value, maxStep, min, max float64) float64 {
+	value += (rnd.Float64()*2 - 1) * maxStep
+	if value < min {
+		return min
+	}
+	if value > max {
+		return max
+	}
+	return value
+}
@@ -0,0 +1,64 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package simulation
+
+import (
+	"testing"
+	"time"
+
+	"github.com/newrelic/infrastructure-agent/internal/agent/mocks"
+	"github.com/newrelic/infrastructure-agent/pkg/config"
+	"github.com/newrelic/infrastructure-agent/pkg/metrics"
+	"github.com/newrelic/infrastructure-agent/pkg/metrics/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSpec(t *testing.T) {
+	spec, err := ParseSpec("hosts=500,procs=200")
+	require.NoError(t, err)
+	assert.Equal(t, Spec{Hosts: 500, Procs: 200, Seed: defaultSeed}, spec)
+
+	spec, err = ParseSpec("hosts=3, seed=42")
+	require.NoError(t, err)
+	assert.Equal(t, Spec{Hosts: 3, Seed: 42}, spec)
+}
+
+func TestParseSpec_Errors(t *testing.T) {
+	for _, value := range []string{"", "procs=10", "hosts", "hosts=ten", "hosts=1,containers=2", "hosts=1,procs=-1"} {
+		t.Run(value, func(t *testing.T) {
+			_, err := ParseSpec(value)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestPlugin_Harvest(t *testing.T) {
+	now := time.Now()
+	timeNow = func() time.Time { return now }
+	defer func() { timeNow = time.Now }()
+
+	ctx := new(mocks.AgentContext)
+	ctx.On("Config").Return(config.NewConfig())
+
+	// GIVEN a simulation of 2 hosts with 3 processes each
+	spec := Spec{Hosts: 2, Procs: 3, Seed: 42}
+
+	// WHEN it is harvested
+	samples := NewPlugin(ctx, spec).harvest()
+
+	// THEN a system sample and the process samples are returned for each host
+	require.Len(t, samples, 8)
+	assert.Equal(t, "simulated-host-00000", samples[0].key.String())
+	system, ok := samples[0].sample.(*metrics.SystemSample)
+	require.True(t, ok)
+	assert.Equal(t, "SystemSample", system.EventType)
+	assert.InDelta(t, 100, system.CPUPercent+system.CPUIdlePercent, 0.001)
+	process, ok := samples[1].sample.(*types.ProcessSample)
+	require.True(t, ok)
+	assert.Equal(t, "ProcessSample", process.EventType)
+	assert.Equal(t, "simulated-host-00001", samples[4].key.String())
+
+	// AND the same seed generates the same samples
+	assert.Equal(t, samples, NewPlugin(ctx, spec).harvest())
+}
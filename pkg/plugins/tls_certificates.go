@@ -0,0 +1,246 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package plugins
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/newrelic/infrastructure-agent/internal/agent"
+	"github.com/newrelic/infrastructure-agent/pkg/config"
+	"github.com/newrelic/infrastructure-agent/pkg/entity"
+	"github.com/newrelic/infrastructure-agent/pkg/log"
+	"github.com/newrelic/infrastructure-agent/pkg/plugins/ids"
+)
+
+var tlslog = log.WithPlugin("TLSCertificates")
+
+const tlsDialTimeout = 5 * time.Second
+
+// expiry levels of a certificate, events are submitted when they increase
+const (
+	certificateValid = iota
+	certificateExpiring
+	certificateExpired
+)
+
+// TLSCertificate is an X.509 certificate found in a file or served by a local port.
+type TLSCertificate struct {
+	// ID is the source of the certificate, followed by its position for the ones after the first, e.g. the
+	// intermediate certificates of a chain
+	ID           string `json:"id"`
+	Source       string `json:"source"`
+	Subject      string `json:"subject"`
+	Issuer       string `json:"issuer"`
+	SANs         string `json:"sans,omitempty"`
+	SerialNumber string `json:"serial_number"`
+	NotBefore    string `json:"not_before"`
+	NotAfter     string `json:"not_after"`
+	DaysToExpiry int    `json:"days_to_expiry"`
+}
+
+func (c TLSCertificate) SortKey() string {
+	return c.ID
+}
+
+// TLSCertificatesPlugin reports the certificates of the configured files and local ports, submitting an
+// event when one gets close to its expiry, and when it expires.
+type TLSCertificatesPlugin struct {
+	agent.PluginCommon
+	frequency     time.Duration
+	paths         []string
+	ports         []int
+	thresholdDays int
+	levels        map[string]int // expiry level, by certificate ID and serial number
+}
+
+func NewTLSCertificatesPlugin(id ids.PluginID, ctx agent.AgentContext) *TLSCertificatesPlugin {
+	cfg := ctx.Config()
+	return &TLSCertificatesPlugin{
+		PluginCommon: agent.PluginCommon{ID: id, Context: ctx},
+		frequency: config.ValidateConfigFrequencySetting(
+			cfg.TLSCertificatesRefreshSec,
+			config.FREQ_MINIMUM_INVENTORY_SAMPLE_RATE,
+			config.FREQ_PLUGIN_TLS_CERTIFICATES_UPDATES,
+			cfg.DisableAllPlugins || (len(cfg.TLSCertificatePaths) == 0 && len(cfg.TLSCertificatePorts) == 0),
+		) * time.Second,
+		paths:         cfg.TLSCertificatePaths,
+		ports:         cfg.TLSCertificatePorts,
+		thresholdDays: cfg.TLSCertificateExpiryThresholdDays,
+		levels:        map[string]int{},
+	}
+}
+
+func (p *TLSCertificatesPlugin) Run() {
+	if p.frequency <= config.FREQ_DISABLE_SAMPLING {
+		tlslog.Debug("Disabled.")
+		return
+	}
+
+	ticker := time.NewTicker(1)
+	for range ticker.C {
+		ticker.Stop()
+		ticker = time.NewTicker(p.frequency)
+
+		now := time.Now()
+		var dataset agent.PluginInventoryDataset
+		for _, file := range certificateFiles(p.paths) {
+			found, err := readCertificateFile(file)
+			if err != nil {
+				tlslog.WithError(err).WithField("file", file).Debug("Can't read certificates.")
+				continue
+			}
+			dataset = append(dataset, tlsCertificates(file, found, now)...)
+		}
+		for _, port := range p.ports {
+			address := net.JoinHostPort("localhost", strconv.Itoa(port))
+			found, err := dialCertificates(address)
+			if err != nil {
+				tlslog.WithError(err).WithField("address", address).Debug("Can't retrieve certificates.")
+				continue
+			}
+			dataset = append(dataset, tlsCertificates(address, found, now)...)
+		}
+		sort.Sort(dataset)
+
+		entityKey := p.Context.EntityKey()
+		for _, event := range p.expiryEvents(dataset) {
+			p.EmitEvent(event, entity.Key(entityKey))
+		}
+		p.EmitInventory(dataset, entity.NewFromNameWithoutID(entityKey))
+	}
+}
+
+// expiryEvents returns an event for each certificate whose expiry level increased since the previous
+// invocation, including the certificates found for the first time.
+func (p *TLSCertificatesPlugin) expiryEvents(dataset agent.PluginInventoryDataset) []map[string]interface{} {
+	levels := make(map[string]int, len(dataset))
+	var events []map[string]interface{}
+	for _, item := range dataset {
+		cert := item.(TLSCertificate)
+		// a renewed certificate is tracked from scratch
+		key := cert.ID + "/" + cert.SerialNumber
+		level := certificateValid
+		if cert.DaysToExpiry < 0 {
+			level = certificateExpired
+		} else if cert.DaysToExpiry < p.thresholdDays {
+			level = certificateExpiring
+		}
+		levels[key] = level
+		if level <= p.levels[key] {
+			continue
+		}
+		summary := fmt.Sprintf("TLS certificate %s of %s expires in %d days", cert.Subject, cert.ID, cert.DaysToExpiry)
+		action := "expiring"
+		if level == certificateExpired {
+			summary = fmt.Sprintf("TLS certificate %s of %s expired", cert.Subject, cert.ID)
+			action = "expired"
+		}
+		events = append(events, map[string]interface{}{
+			"eventType":    "InfrastructureEvent",
+			"category":     "security",
+			"summary":      summary,
+			"action":       action,
+			"source":       cert.Source,
+			"subject":      cert.Subject,
+			"notAfter":     cert.NotAfter,
+			"daysToExpiry": cert.DaysToExpiry,
+		})
+	}
+	p.levels = levels
+	return events
+}
+
+// certificateFiles returns the files matching the configured paths, which can be glob patterns.
+func certificateFiles(paths []string) []string {
+	var files []string
+	for _, path := range paths {
+		matches, err := filepath.Glob(path)
+		if err != nil {
+			tlslog.WithError(err).WithField("path", path).Warn("Invalid certificate path pattern.")
+			continue
+		}
+		files = append(files, matches...)
+	}
+	return files
+}
+
+// readCertificateFile returns the certificates of a PEM file, ignoring other blocks such as private keys.
+func readCertificateFile(path string) ([]*x509.Certificate, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var certs []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, content = pem.Decode(content)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("no PEM certificates found")
+	}
+	return certs, nil
+}
+
+// dialCertificates returns the chain served by a TLS server. It isn't verified, as local servers are
+// usually reached through names not present in their certificates.
+func dialCertificates(address string) ([]*x509.Certificate, error) {
+	conn, err := tls.DialWithDialer(&net.Dialer{Timeout: tlsDialTimeout}, "tcp", address, &tls.Config{InsecureSkipVerify: true})
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	return conn.ConnectionState().PeerCertificates, nil
+}
+
+// tlsCertificates returns the inventory items of the certificates found in a source.
+func tlsCertificates(source string, certs []*x509.Certificate, now time.Time) agent.PluginInventoryDataset {
+	dataset := make(agent.PluginInventoryDataset, 0, len(certs))
+	for i, cert := range certs {
+		id := source
+		if i > 0 {
+			id = fmt.Sprintf("%s#%d", source, i)
+		}
+		sans := append([]string{}, cert.DNSNames...)
+		for _, ip := range cert.IPAddresses {
+			sans = append(sans, ip.String())
+		}
+		sans = append(sans, cert.EmailAddresses...)
+		for _, uri := range cert.URIs {
+			sans = append(sans, uri.String())
+		}
+		dataset = append(dataset, TLSCertificate{
+			ID:           id,
+			Source:       source,
+			Subject:      cert.Subject.String(),
+			Issuer:       cert.Issuer.String(),
+			SANs:         strings.Join(sans, ","),
+			SerialNumber: cert.SerialNumber.Text(16),
+			NotBefore:    cert.NotBefore.UTC().Format(time.RFC3339),
+			NotAfter:     cert.NotAfter.UTC().Format(time.RFC3339),
+			DaysToExpiry: int(math.Floor(cert.NotAfter.Sub(now).Hours() / 24)),
+		})
+	}
+	return dataset
+}
@@ -0,0 +1,111 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package plugins
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/newrelic/infrastructure-agent/internal/agent"
+)
+
+func testCertificatePEM(t *testing.T, notAfter time.Time) []byte {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(255),
+		Subject:      pkix.Name{CommonName: "example.com"},
+		DNSNames:     []string{"example.com", "www.example.com"},
+		IPAddresses:  []net.IP{net.ParseIP("10.0.0.1")},
+		NotBefore:    notAfter.Add(-365 * 24 * time.Hour),
+		NotAfter:     notAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+	return append(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}),
+		pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})...)
+}
+
+func TestTLSCertificates_File(t *testing.T) {
+	// GIVEN a PEM file with a private key and a certificate expiring in 10 days
+	dir, err := ioutil.TempDir("", "tls_certificates")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	now := time.Date(2020, 9, 1, 0, 0, 0, 0, time.UTC)
+	file := filepath.Join(dir, "server.pem")
+	require.NoError(t, ioutil.WriteFile(file, testCertificatePEM(t, now.Add(10*24*time.Hour+time.Hour)), 0600))
+
+	// WHEN the certificates matching a glob are read
+	files := certificateFiles([]string{filepath.Join(dir, "*.pem")})
+	require.Equal(t, []string{file}, files)
+	certs, err := readCertificateFile(file)
+	require.NoError(t, err)
+
+	// THEN the certificate is reported, skipping the key
+	assert.Equal(t, agent.PluginInventoryDataset{TLSCertificate{
+		ID:           file,
+		Source:       file,
+		Subject:      "CN=example.com",
+		Issuer:       "CN=example.com",
+		SANs:         "example.com,www.example.com,10.0.0.1",
+		SerialNumber: "ff",
+		NotBefore:    "2019-09-12T01:00:00Z",
+		NotAfter:     "2020-09-11T01:00:00Z",
+		DaysToExpiry: 10,
+	}}, tlsCertificates(file, certs, now))
+}
+
+func TestTLSCertificates_Port(t *testing.T) {
+	// GIVEN a local TLS server
+	server := httptest.NewTLSServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	defer server.Close()
+
+	// WHEN its certificates are retrieved
+	certs, err := dialCertificates(server.Listener.Addr().String())
+
+	// THEN the certificate of the server is returned
+	require.NoError(t, err)
+	require.NotEmpty(t, certs)
+	assert.Equal(t, server.Certificate().SerialNumber, certs[0].SerialNumber)
+}
+
+func TestTLSCertificates_ExpiryEvents(t *testing.T) {
+	p := &TLSCertificatesPlugin{thresholdDays: 30, levels: map[string]int{}}
+	cert := func(days int) agent.PluginInventoryDataset {
+		return agent.PluginInventoryDataset{TLSCertificate{ID: "/etc/ssl/server.pem", Source: "/etc/ssl/server.pem", SerialNumber: "ff", DaysToExpiry: days}}
+	}
+
+	// GIVEN a certificate far from its expiry, no event is submitted
+	assert.Empty(t, p.expiryEvents(cert(40)))
+
+	// WHEN it gets below the threshold THEN an event is submitted once
+	events := p.expiryEvents(cert(29))
+	require.Len(t, events, 1)
+	assert.Equal(t, "expiring", events[0]["action"])
+	assert.Equal(t, 29, events[0]["daysToExpiry"])
+	assert.Empty(t, p.expiryEvents(cert(28)))
+
+	// WHEN it expires THEN another event is submitted once
+	events = p.expiryEvents(cert(-1))
+	require.Len(t, events, 1)
+	assert.Equal(t, "expired", events[0]["action"])
+	assert.Empty(t, p.expiryEvents(cert(-2)))
+}
@@ -0,0 +1,152 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package schedule
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cronMacros are the shorthands of the most common expressions.
+var cronMacros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+var monthNames = map[string]int{
+	"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+	"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+}
+
+var dayNames = map[string]int{
+	"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+}
+
+// field of a cron expression, as a bit per allowed value.
+type field struct {
+	bits uint64
+	// any is true for the "*" fields, which matter for the day of month and day of week semantics
+	any bool
+}
+
+func (f field) has(value int) bool {
+	return f.bits&(1<<uint(value)) != 0
+}
+
+// cron is a standard 5 fields expression: minute, hour, day of month, month and day of week.
+type cron struct {
+	minute, hour, dom, month, dow field
+}
+
+func parseCron(expression string) (*cron, error) {
+	expression = strings.TrimSpace(expression)
+	if macro, ok := cronMacros[strings.ToLower(expression)]; ok {
+		expression = macro
+	}
+	fields := strings.Fields(expression)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("expected 5 fields in cron expression %q, found %d", expression, len(fields))
+	}
+	var c cron
+	var err error
+	if c.minute, err = parseField(fields[0], 0, 59, nil); err != nil {
+		return nil, fmt.Errorf("invalid minute: %s", err)
+	}
+	if c.hour, err = parseField(fields[1], 0, 23, nil); err != nil {
+		return nil, fmt.Errorf("invalid hour: %s", err)
+	}
+	if c.dom, err = parseField(fields[2], 1, 31, nil); err != nil {
+		return nil, fmt.Errorf("invalid day of month: %s", err)
+	}
+	if c.month, err = parseField(fields[3], 1, 12, monthNames); err != nil {
+		return nil, fmt.Errorf("invalid month: %s", err)
+	}
+	// both 0 and 7 are Sunday
+	if c.dow, err = parseField(fields[4], 0, 7, dayNames); err != nil {
+		return nil, fmt.Errorf("invalid day of week: %s", err)
+	}
+	if c.dow.has(7) {
+		c.dow.bits |= 1
+	}
+	return &c, nil
+}
+
+// parseField parses a comma separated list of "*", values or ranges, optionally stepped (e.g. "*/15" or "1-5/2").
+func parseField(expression string, min, max int, names map[string]int) (field, error) {
+	var f field
+	for _, part := range strings.Split(expression, ",") {
+		step := 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			var err error
+			if step, err = strconv.Atoi(part[i+1:]); err != nil || step <= 0 {
+				return f, fmt.Errorf("invalid step in %q", part)
+			}
+			part = part[:i]
+		}
+		from, to := min, max
+		switch {
+		case part == "*":
+			f.any = step == 1
+		case strings.Contains(part, "-"):
+			bounds := strings.SplitN(part, "-", 2)
+			var err error
+			if from, err = parseValue(bounds[0], min, max, names); err != nil {
+				return f, err
+			}
+			if to, err = parseValue(bounds[1], min, max, names); err != nil {
+				return f, err
+			}
+			if from > to {
+				return f, fmt.Errorf("invalid range %q", part)
+			}
+		default:
+			value, err := parseValue(part, min, max, names)
+			if err != nil {
+				return f, err
+			}
+			from = value
+			// a single value with a step means from that value to the maximum
+			if step == 1 {
+				to = value
+			}
+		}
+		for value := from; value <= to; value += step {
+			f.bits |= 1 << uint(value)
+		}
+	}
+	return f, nil
+}
+
+func parseValue(value string, min, max int, names map[string]int) (int, error) {
+	if n, ok := names[strings.ToLower(value)]; ok {
+		return n, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value %q", value)
+	}
+	if n < min || n > max {
+		return 0, fmt.Errorf("value %d out of the range %d-%d", n, min, max)
+	}
+	return n, nil
+}
+
+// matches returns whether the minute of the passed time matches the expression. As in cron, when both the
+// day of month and the day of week are restricted, a day matching any of them matches.
+func (c *cron) matches(t time.Time) bool {
+	if !c.minute.has(t.Minute()) || !c.hour.has(t.Hour()) || !c.month.has(int(t.Month())) {
+		return false
+	}
+	dom, dow := c.dom.has(t.Day()), c.dow.has(int(t.Weekday()))
+	if c.dom.any || c.dow.any {
+		return dom && dow
+	}
+	return dom || dow
+}
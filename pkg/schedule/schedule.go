@@ -0,0 +1,96 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+// Package schedule provides recurring time windows, starting at the times matching a cron expression,
+// during which the data collection is paused or its data tagged, so known noisy periods (e.g. nightly
+// backups) don't trigger alerts.
+package schedule
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Actions applied to the collection during a window.
+const (
+	// ActionPause skips the collection
+	ActionPause = "pause"
+	// ActionTag collects the data, adding the TagAttribute with the window name
+	ActionTag = "tag"
+
+	// TagAttribute is the attribute added to the data collected during the windows of the tag action
+	TagAttribute = "silentWindow"
+)
+
+// maxDuration bounds the windows length, as their activity is checked minute by minute.
+const maxDuration = 7 * 24 * time.Hour
+
+// Window is a recurring period of time.
+type Window struct {
+	Name     string
+	Action   string
+	cron     *cron
+	duration time.Duration
+	location *time.Location
+}
+
+// NewWindow returns a window starting at the times matching the cron expression, in the passed timezone
+// (local time if empty), and lasting the passed duration. The action defaults to ActionPause.
+func NewWindow(name, cronExpression, duration, timezone, action string) (*Window, error) {
+	if name == "" {
+		return nil, errors.New("missing window name")
+	}
+	w := &Window{Name: name, Action: action, location: time.Local}
+	if w.Action == "" {
+		w.Action = ActionPause
+	}
+	if w.Action != ActionPause && w.Action != ActionTag {
+		return nil, fmt.Errorf("invalid action %q for window %q: expected %s or %s", action, name, ActionPause, ActionTag)
+	}
+	var err error
+	if w.cron, err = parseCron(cronExpression); err != nil {
+		return nil, fmt.Errorf("invalid schedule for window %q: %s", name, err)
+	}
+	if w.duration, err = time.ParseDuration(duration); err != nil {
+		return nil, fmt.Errorf("invalid duration for window %q: %s", name, err)
+	}
+	if w.duration < time.Minute || w.duration > maxDuration {
+		return nil, fmt.Errorf("duration of window %q must be between %s and %s", name, time.Minute, maxDuration)
+	}
+	if timezone != "" {
+		if w.location, err = time.LoadLocation(timezone); err != nil {
+			return nil, fmt.Errorf("invalid timezone for window %q: %s", name, err)
+		}
+	}
+	return w, nil
+}
+
+// Active returns whether the passed time is within any occurrence of the window.
+func (w *Window) Active(t time.Time) bool {
+	t = t.In(w.location)
+	start := t.Truncate(time.Minute)
+	for ; t.Sub(start) < w.duration; start = start.Add(-time.Minute) {
+		if w.cron.matches(start) {
+			return true
+		}
+	}
+	return false
+}
+
+// Active returns the window the passed time is within, if any. The windows pausing the collection take
+// precedence over the ones tagging it.
+func Active(windows []*Window, t time.Time) *Window {
+	var active *Window
+	for _, w := range windows {
+		if !w.Active(t) {
+			continue
+		}
+		if w.Action == ActionPause {
+			return w
+		}
+		if active == nil {
+			active = w
+		}
+	}
+	return active
+}
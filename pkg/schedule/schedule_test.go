@@ -0,0 +1,116 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package schedule
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCron(t *testing.T) {
+	// Friday 2021-01-29 02:30 UTC
+	friday := time.Date(2021, 1, 29, 2, 30, 0, 0, time.UTC)
+	tests := []struct {
+		expression string
+		matches    bool
+	}{
+		{"30 2 * * *", true},
+		{"*/15 * * * *", true},
+		{"*/20 * * * *", false},
+		{"0-30/10 2 * * *", true},
+		{"30 2 * * mon-fri", true},
+		{"30 2 * * SAT,SUN", false},
+		{"30 2 * jan *", true},
+		{"30 2 1 * *", false},
+		// with both days restricted, any of them matches
+		{"30 2 1 * 5", true},
+		{"30 2 29 * 0", true},
+		{"30 2 * * 7", false},
+		{"@daily", false},
+		{"@hourly", false},
+		{"30/10 2 * * *", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expression, func(t *testing.T) {
+			c, err := parseCron(tt.expression)
+			require.NoError(t, err)
+			assert.Equal(t, tt.matches, c.matches(friday))
+		})
+	}
+
+	c, err := parseCron("0 0 * * 7")
+	require.NoError(t, err)
+	assert.True(t, c.matches(time.Date(2021, 1, 31, 0, 0, 0, 0, time.UTC)), "7 is Sunday")
+}
+
+func TestParseCron_Errors(t *testing.T) {
+	for _, expression := range []string{
+		"",
+		"* * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"*/0 * * * *",
+		"5-1 * * * *",
+		"a * * * *",
+		"@every",
+	} {
+		t.Run(expression, func(t *testing.T) {
+			_, err := parseCron(expression)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestWindow_Active(t *testing.T) {
+	// GIVEN a nightly window of two hours, in a given timezone
+	w, err := NewWindow("backup", "30 1 * * *", "2h", "Europe/Madrid", "")
+	require.NoError(t, err)
+	assert.Equal(t, ActionPause, w.Action)
+
+	madrid, err := time.LoadLocation("Europe/Madrid")
+	require.NoError(t, err)
+	at := func(hour, minute int) time.Time {
+		return time.Date(2021, 1, 29, hour, minute, 0, 0, madrid).UTC()
+	}
+
+	assert.False(t, w.Active(at(1, 29)))
+	assert.True(t, w.Active(at(1, 30)))
+	assert.True(t, w.Active(at(2, 45)))
+	assert.True(t, w.Active(at(3, 29).Add(59*time.Second)))
+	assert.False(t, w.Active(at(3, 30)))
+}
+
+func TestNewWindow_Errors(t *testing.T) {
+	for _, tt := range []struct{ name, cron, duration, timezone, action string }{
+		{"", "0 1 * * *", "1h", "", ""},
+		{"w", "0 1 * *", "1h", "", ""},
+		{"w", "0 1 * * *", "", "", ""},
+		{"w", "0 1 * * *", "30s", "", ""},
+		{"w", "0 1 * * *", "200h", "", ""},
+		{"w", "0 1 * * *", "1h", "Mars/Olympus", ""},
+		{"w", "0 1 * * *", "1h", "", "mute"},
+	} {
+		_, err := NewWindow(tt.name, tt.cron, tt.duration, tt.timezone, tt.action)
+		assert.Error(t, err, "%+v", tt)
+	}
+}
+
+func TestActive(t *testing.T) {
+	tag, err := NewWindow("maintenance", "0 * * * *", "1h", "UTC", ActionTag)
+	require.NoError(t, err)
+	pause, err := NewWindow("backup", "0 2 * * *", "1h", "UTC", ActionPause)
+	require.NoError(t, err)
+	windows := []*Window{tag, pause}
+
+	assert.Equal(t, tag, Active(windows, time.Date(2021, 1, 29, 1, 10, 0, 0, time.UTC)))
+	// the pausing windows take precedence
+	assert.Equal(t, pause, Active(windows, time.Date(2021, 1, 29, 2, 10, 0, 0, time.UTC)))
+	assert.Nil(t, Active(windows[1:], time.Date(2021, 1, 29, 3, 10, 0, 0, time.UTC)))
+	assert.Nil(t, Active(nil, time.Now()))
+}
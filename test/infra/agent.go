@@ -60,7 +60,7 @@ func NewAgentWithConnectClientAndConfig(connectClient *http.Client, dataClient b
 		c(cfg)
 	}
 
-	ctx := agent.NewContext(cfg, "1.2.3", testhelpers.NewFakeHostnameResolver("foobar", "foo", nil), nil, matcher)
+	ctx := agent.NewContext(cfg, "1.2.3", testhelpers.NewFakeHostnameResolver("foobar", "foo", nil), nil, matcher, nil, nil)
 
 	if cfg.AgentDir == "" {
 		var err error